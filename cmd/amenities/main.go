@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/samirrijal/bilbopass/internal/pkg/config"
+)
+
+const (
+	overpassURL = "https://overpass-api.de/api/interpreter"
+	// defaultBBox covers the Basque Country (south,west,north,east).
+	defaultBBox = "42.47,-3.45,43.46,-1.73"
+	// matchRadius is how close (meters) an OSM stop must be to a feed stop.
+	matchRadius = 20.0
+)
+
+type overpassResponse struct {
+	Elements []overpassNode `json:"elements"`
+}
+
+type overpassNode struct {
+	ID   int64             `json:"id"`
+	Lat  float64           `json:"lat"`
+	Lon  float64           `json:"lon"`
+	Tags map[string]string `json:"tags"`
+}
+
+// OSM amenity import: fetches bus stops and platforms from OpenStreetMap and
+// copies their shelter, bench and departure board tags to the nearest feed
+// stop. Stops with amenities from the agency feed or edited by an admin or
+// rider are left alone.
+//
+// usage: amenities [south,west,north,east]
+func main() {
+	cfg, err := config.Load("bilbopass-amenities")
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+
+	ctx := context.Background()
+
+	pool, err := pgxpool.New(ctx, cfg.Database.DSN())
+	if err != nil {
+		log.Fatalf("db: %v", err)
+	}
+	defer pool.Close()
+
+	bbox := defaultBBox
+	if len(os.Args) > 1 {
+		bbox = os.Args[1]
+	}
+
+	log.Printf("BilboPass OSM amenity import — bbox %s", bbox)
+
+	nodes, err := fetchStops(ctx, &http.Client{Timeout: 5 * time.Minute}, bbox)
+	if err != nil {
+		log.Fatalf("overpass: %v", err)
+	}
+
+	batch := &pgx.Batch{}
+	queued := 0
+	for _, n := range nodes {
+		shelter := yesNo(n.Tags["shelter"])
+		bench := yesNo(n.Tags["bench"])
+		display := departureBoard(n.Tags)
+		if shelter == nil && bench == nil && display == nil {
+			continue
+		}
+		batch.Queue(`
+			UPDATE stops
+			SET shelter = $3, bench = $4, realtime_display = $5,
+			    amenities_source = 'osm', amenities_updated_at = NOW()
+			WHERE id = (
+			    SELECT id FROM stops
+			    WHERE ST_DWithin(location, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography, $6)
+			    ORDER BY ST_Distance(location, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography)
+			    LIMIT 1
+			)
+			  AND (amenities_source IS NULL OR amenities_source = 'osm')
+		`, n.Lon, n.Lat, shelter, bench, display, matchRadius)
+		queued++
+	}
+
+	matched := int64(0)
+	if queued > 0 {
+		br := pool.SendBatch(ctx, batch)
+		for i := 0; i < queued; i++ {
+			tag, err := br.Exec()
+			if err != nil {
+				br.Close()
+				log.Fatalf("batch item %d: %v", i, err)
+			}
+			matched += tag.RowsAffected()
+		}
+		br.Close()
+	}
+
+	log.Printf("%d OSM stops, %d with amenities, %d feed stops updated", len(nodes), queued, matched)
+}
+
+func fetchStops(ctx context.Context, client *http.Client, bbox string) ([]overpassNode, error) {
+	query := fmt.Sprintf(`[out:json][timeout:180];
+(
+  node["highway"="bus_stop"](%[1]s);
+  node["public_transport"="platform"](%[1]s);
+);
+out body;`, bbox)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, overpassURL+"?data="+url.QueryEscape(query), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	var out overpassResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	return out.Elements, nil
+}
+
+// yesNo parses an OSM yes/no tag; anything else is unknown.
+func yesNo(v string) *bool {
+	var b bool
+	switch v {
+	case "yes":
+		b = true
+	case "no":
+	default:
+		return nil
+	}
+	return &b
+}
+
+// departureBoard reports whether a stop has a real-time display, from the
+// departures_board tag or the older passenger_information_display.
+func departureBoard(tags map[string]string) *bool {
+	var b bool
+	switch tags["departures_board"] {
+	case "realtime":
+		b = true
+		return &b
+	case "timetable", "no":
+		return &b
+	}
+	return yesNo(tags["passenger_information_display"])
+}
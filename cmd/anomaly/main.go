@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	natsadapter "github.com/samirrijal/bilbopass/internal/adapters/nats"
+	"github.com/samirrijal/bilbopass/internal/adapters/postgres"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+	"github.com/samirrijal/bilbopass/internal/pkg/config"
+)
+
+// Delay anomaly detector: rolls up the last hour's trip update delays per
+// route, compares them with the route's usual delay at that hour of day and
+// raises anomalies as service alerts and NATS events
+// (transit.alerts.anomaly.<agency_id>), then exits. The compensator runs it
+// every 15 minutes as the anomalies job.
+func main() {
+	cfg, err := config.Load("bilbopass-anomaly")
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db, err := postgres.New(ctx, cfg.Database.DSN())
+	if err != nil {
+		log.Fatalf("db: %v", err)
+	}
+	defer db.Close()
+
+	natsOpts, err := cfg.NATS.Options()
+	if err != nil {
+		log.Fatalf("nats: %v", err)
+	}
+	pub, err := natsadapter.NewPublisher(cfg.NATS.URL, natsOpts...)
+	if err != nil {
+		log.Fatalf("nats: %v", err)
+	}
+	defer pub.Close()
+
+	svc := usecases.NewAnomalyService(postgres.NewDelayStatsRepo(db), postgres.NewAlertRepo(db), pub)
+
+	anomalies, err := svc.Detect(ctx, time.Now())
+	if err != nil {
+		log.Fatalf("detect: %v", err)
+	}
+	for _, a := range anomalies {
+		log.Printf("anomaly: route %s mean delay %.0fs (%.1fσ above %.0fs)", a.RouteName, a.MeanDelay, a.Sigma, a.BaselineMean)
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"log/slog"
@@ -9,20 +11,33 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // for the timetable's zone in minimal images
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.temporal.io/sdk/client"
 
+	"github.com/samirrijal/bilbopass/internal/adapters/cdn"
+	"github.com/samirrijal/bilbopass/internal/adapters/geocoding"
 	"github.com/samirrijal/bilbopass/internal/adapters/http"
 	natsadapter "github.com/samirrijal/bilbopass/internal/adapters/nats"
+	"github.com/samirrijal/bilbopass/internal/adapters/notifications"
+	"github.com/samirrijal/bilbopass/internal/adapters/objectstore"
 	"github.com/samirrijal/bilbopass/internal/adapters/postgres"
+	"github.com/samirrijal/bilbopass/internal/adapters/streets"
 	"github.com/samirrijal/bilbopass/internal/adapters/valkey"
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
 	"github.com/samirrijal/bilbopass/internal/core/usecases"
+	"github.com/samirrijal/bilbopass/internal/pkg/auth"
 	"github.com/samirrijal/bilbopass/internal/pkg/config"
 	"github.com/samirrijal/bilbopass/internal/pkg/logging"
+	"github.com/samirrijal/bilbopass/internal/pkg/metrics"
 	"github.com/samirrijal/bilbopass/internal/pkg/telemetry"
+	"github.com/samirrijal/bilbopass/internal/workflows"
 )
 
 func main() {
@@ -66,48 +81,338 @@ func main() {
 		defer cache.Close()
 	}
 
+	natsOpts, err := cfg.NATS.Options()
+	if err != nil {
+		log.Fatalf("nats: %v", err)
+	}
+	relayOpts, err := cfg.NATS.RelayOptions()
+	if err != nil {
+		log.Fatalf("nats relay: %v", err)
+	}
+	if cfg.NATS.IsSet() && !cfg.NATS.Relay.IsSet() {
+		slog.Warn("nats.relay not set, relaying to WebSocket and SSE clients with the service account")
+	}
+
 	// NATS
-	nc, err := natsadapter.NewPublisher(cfg.NATS.URL)
+	nc, err := natsadapter.NewPublisher(cfg.NATS.URL, natsOpts...)
 	if err != nil {
 		slog.Warn("nats unavailable", "error", err)
 	} else {
 		defer nc.Close()
 	}
 
-	// Raw NATS connection for WebSocket relay
-	natsConn, err := natsadapter.RawConn(cfg.NATS.URL)
+	// Raw NATS connection for WebSocket relay, with the relay account
+	natsConn, err := natsadapter.RawConn(cfg.NATS.URL, relayOpts...)
 	if err != nil {
 		slog.Warn("nats ws conn unavailable", "error", err)
 	}
 
+	// Rider tokens. Without a configured secret, tokens only last until restart.
+	jwtSecret := cfg.Auth.JWTSecret
+	if jwtSecret == "" {
+		slog.Warn("auth.jwt_secret not set, using a random per-process secret")
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			log.Fatalf("jwt secret: %v", err)
+		}
+		jwtSecret = hex.EncodeToString(buf)
+	}
+	tokens := auth.NewJWT(jwtSecret, time.Duration(cfg.Auth.TokenTTLHours)*time.Hour)
+
 	// Repos
 	agencyRepo := postgres.NewAgencyRepo(db)
 	stopRepo := postgres.NewStopRepo(db)
 	routeRepo := postgres.NewRouteRepo(db)
 	vehicleRepo := postgres.NewVehiclePositionRepo(db)
 	tripRepo := postgres.NewTripRepo(db)
+	tripUpdateRepo := postgres.NewTripUpdateRepo(db)
 	journeyRepo := postgres.NewJourneyRepo(db)
+	timetableRepo := postgres.NewTimetableRepo(db)
+	staticVersionRepo := postgres.NewStaticVersionRepo(db)
+	shortLinkRepo := postgres.NewShortLinkRepo(db)
+	historyRepo := postgres.NewHistoryRepo(db)
+	checkInRepo := postgres.NewCheckInRepo(db)
+	compensationRepo := postgres.NewCompensationRepo(db)
+	affiliateRepo := postgres.NewAffiliateRepo(db)
+	tileRepo := postgres.NewTileRepo(db)
+	challengeRepo := postgres.NewChallengeRepo(db)
+	slaRepo := postgres.NewSLARepo(db)
+	alertRepo := postgres.NewAlertRepo(db)
+	delayStatsRepo := postgres.NewDelayStatsRepo(db)
+	feedQualityRepo := postgres.NewFeedQualityRepo(db)
+	feedConfigRepo := postgres.NewFeedConfigRepo(db)
+	agencyAliasRepo := postgres.NewAgencyAliasRepo(db)
+	pushKeyRepo := postgres.NewPushKeyRepo(db)
+	userRepo := postgres.NewUserRepo(db)
+	deviceRepo := postgres.NewDeviceRepo(db)
+	notificationPrefRepo := postgres.NewNotificationPreferenceRepo(db)
+	favoriteRepo := postgres.NewFavoriteRepo(db)
+	eventRepo := postgres.NewEventRepo(db)
+	alertSubRepo := postgres.NewAlertSubscriptionRepo(db)
+	accessibleSpaceRepo := postgres.NewAccessibleSpaceRepo(db)
+	feedHistoryRepo := postgres.NewFeedHistoryRepo(db)
+	feedStageRepo := postgres.NewFeedStageRepo(db)
+	brandingRepo := postgres.NewBrandingRepo(db)
+	rtFeedStatusRepo := postgres.NewRealtimeFeedStatusRepo(db)
+	offlineRepo := postgres.NewOfflineRepo(db)
+	syncRepo := postgres.NewSyncRepo(db)
+	occupancyRepo := postgres.NewOccupancyRepo(db)
+	coverageRepo := postgres.NewRealtimeCoverageRepo(db)
+	segmentRepo := postgres.NewSegmentStatsRepo(db)
+	comparisonRepo := postgres.NewTimetableComparisonRepo(db)
+	synonymRepo := postgres.NewSearchSynonymRepo(db)
+	searchLogRepo := postgres.NewSearchLogRepo(db)
+	globalSearchRepo := postgres.NewGlobalSearchRepo(db)
+	punctualityRepo := postgres.NewPunctualityRepo(db)
+	feedHealthRepo := postgres.NewFeedHealthRepo(db)
+
+	// Per-agency feed health gauges, read from the database on each scrape
+	prometheus.MustRegister(metrics.NewFeedHealthCollector(feedHealthRepo))
+
+	// Push notifications; platforms without credentials are skipped
+	pusher, err := notifications.New(deviceRepo, notificationPrefRepo, cfg.Push.FCMCredentialsFile,
+		cfg.Push.VAPIDPublicKey, cfg.Push.VAPIDPrivateKey, cfg.Push.VAPIDSubject)
+	if err != nil {
+		log.Fatalf("push: %v", err)
+	}
+
+	// Branding uploads and timetable exports; without storage, branding can
+	// only be recolored and nothing is exported
+	var assets ports.ObjectStore
+	if cfg.Storage.Endpoint != "" {
+		s3, err := objectstore.NewS3(cfg.Storage.Endpoint, cfg.Storage.Bucket, cfg.Storage.Region,
+			cfg.Storage.AccessKey, cfg.Storage.SecretKey, cfg.Storage.PublicURL)
+		if err != nil {
+			log.Fatalf("storage: %v", err)
+		}
+		assets = s3
+	}
+
+	// Street routing for walks and rides; without OSRM they are estimated
+	var streetRouter ports.StreetRouter
+	if cfg.Streets.WalkURL != "" || cfg.Streets.BikeURL != "" {
+		osrm, err := streets.NewOSRM(cfg.Streets.WalkURL, cfg.Streets.BikeURL)
+		if err != nil {
+			log.Fatalf("streets: %v", err)
+		}
+		streetRouter = osrm
+	}
+
+	// Address lookups through Nominatim or Photon; off without a server
+	var geocoder ports.Geocoder
+	if cfg.Geocoder.URL != "" {
+		g, err := geocoding.New(cfg.Geocoder.Provider, cfg.Geocoder.URL, cfg.Geocoder.UserAgent, cfg.Geocoder.UpstreamPerSecond)
+		if err != nil {
+			log.Fatalf("geocoder: %v", err)
+		}
+		geocoder = g
+	}
+
+	// CDN purges of changed data; without them the CDN keeps nothing long
+	var purger ports.CDNPurger
+	if cfg.CDN.PurgeURL != "" {
+		p, err := cdn.New(cfg.CDN.Provider, cfg.CDN.PurgeURL, cfg.CDN.Token)
+		if err != nil {
+			log.Fatalf("cdn: %v", err)
+		}
+		purger = p
+	}
+
+	// Workflow runs for operators, from Temporal's visibility store; the
+	// client connects on first use, so the API starts without Temporal
+	var workflowHistory ports.WorkflowHistory
+	if cfg.Temporal.HostPort != "" {
+		tc, err := client.NewLazyClient(client.Options{HostPort: cfg.Temporal.HostPort})
+		if err != nil {
+			log.Fatalf("temporal: %v", err)
+		}
+		defer tc.Close()
+		workflowHistory = workflows.NewHistory(tc)
+	}
+
+	// Journeys are planned in memory on the timetable, reloaded once a new
+	// static feed is activated; the SQL planner serves until it is loaded.
+	journeyPlanner := usecases.NewJourneyPlanner(timetableRepo, journeyRepo)
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			start := time.Now()
+			if loaded, err := journeyPlanner.Refresh(ctx); err != nil {
+				slog.Error("journey planner refresh failed", "error", err)
+			} else if loaded {
+				slog.Info("journey planner timetable loaded", "took", time.Since(start))
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Route and nearby vehicles are served from the latest positions in
+	// memory, fed by the vehicle stream, once it has been read up to now;
+	// until then, and without NATS, they are queried from the database.
+	liveVehicles := usecases.NewLiveVehicleCache(vehicleRepo, routeRepo, tripRepo)
+	if natsConn != nil {
+		_, err := natsadapter.FollowVehiclePositions(natsConn, func(vp domain.VehiclePosition) {
+			liveVehicles.Apply(vp, time.Now())
+		}, liveVehicles.Ready)
+		if err != nil {
+			slog.Warn("live vehicle positions unavailable", "error", err)
+		}
+	}
+
+	// Conditional requests for stops, routes and trips are answered from the
+	// static data version in memory, polled like the timetable. Cached
+	// routes are keyed by it, so a new feed drops them. It is read once
+	// before serving; until a read succeeds routes are not cached.
+	staticVersionSvc := usecases.NewStaticVersionService(staticVersionRepo)
+	routeSvc := usecases.NewRouteService(routeRepo, liveVehicles, metrics.InstrumentCache(cache))
+	refreshStaticVersion := func() {
+		if changed, err := staticVersionSvc.Refresh(ctx); err != nil {
+			slog.Error("static version refresh failed", "error", err)
+		} else if changed {
+			routeSvc.Invalidate(staticVersionSvc.Current())
+		}
+	}
+	refreshStaticVersion()
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				refreshStaticVersion()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
 	// Use cases
 	agencySvc := usecases.NewAgencyService(agencyRepo)
-	stopSvc := usecases.NewStopService(stopRepo, cache)
-	routeSvc := usecases.NewRouteService(routeRepo, vehicleRepo)
-	departureSvc := usecases.NewDepartureService(tripRepo)
+	stopSvc := usecases.NewStopService(stopRepo, metrics.InstrumentCache(cache))
+	departureSvc := usecases.NewDepartureService(tripRepo, tripUpdateRepo, delayStatsRepo, occupancyRepo)
 	tripSvc := usecases.NewTripService(tripRepo)
-	realtimeSvc := usecases.NewRealtimeService(vehicleRepo, routeRepo, nc)
-	journeySvc := usecases.NewJourneyService(journeyRepo, stopRepo)
+	realtimeSvc := usecases.NewRealtimeService(vehicleRepo, tripRepo, nc)
+	journeySvc := usecases.NewJourneyService(journeyPlanner, stopRepo, eventRepo, accessibleSpaceRepo, streetRouter, domain.TaxiTariffs{
+		DayBaseFare:     cfg.Taxi.DayBaseFare,
+		DayPerKm:        cfg.Taxi.DayPerKm,
+		NightBaseFare:   cfg.Taxi.NightBaseFare,
+		NightPerKm:      cfg.Taxi.NightPerKm,
+		MinimumFare:     cfg.Taxi.MinimumFare,
+		NightStartHour:  cfg.Taxi.NightStartHour,
+		NightEndHour:    cfg.Taxi.NightEndHour,
+		AverageSpeedKmh: cfg.Taxi.AverageSpeedKmh,
+	})
+	shortLinkSvc := usecases.NewShortLinkService(shortLinkRepo, cfg.ShortLinks.BaseURL, cfg.ShortLinks.BoardURL)
+	historySvc := usecases.NewHistoryService(historyRepo, stopRepo, routeRepo)
+	checkInSvc := usecases.NewCheckInService(checkInRepo, tripRepo)
+	deviceSvc := usecases.NewDeviceService(deviceRepo)
+	favoriteSvc := usecases.NewFavoriteService(favoriteRepo, stopRepo, routeRepo, departureSvc)
+	eventSvc := usecases.NewEventService(eventRepo, stopRepo)
+	// Delay alerts are pushed by the realtime poller; the API only manages them.
+	alertSubSvc := usecases.NewAlertSubscriptionService(alertSubRepo, stopRepo, routeRepo, nil)
+	accessibleSpaceSvc := usecases.NewAccessibleSpaceService(accessibleSpaceRepo, tripRepo)
+	// The API lists, verifies and redeems coupons and issues challenge rewards;
+	// delay coupons are issued by the compensator.
+	compensationSvc := usecases.NewCompensationService(nil, affiliateRepo, compensationRepo, pusher)
+	affiliateSvc := usecases.NewAffiliateService(affiliateRepo)
+	tileSvc := usecases.NewTileService(tileRepo, cache)
+	challengeSvc := usecases.NewChallengeService(challengeRepo, historyRepo, compensationSvc)
+	slaSvc := usecases.NewSLAService(slaRepo, agencyRepo, nil)
+	alertSvc := usecases.NewAlertService(alertRepo, agencyRepo)
+	feedQualitySvc := usecases.NewFeedQualityService(feedQualityRepo, agencyRepo)
+	feedConfigSvc := usecases.NewFeedConfigService(feedConfigRepo, agencyRepo)
+	feedHistorySvc := usecases.NewFeedHistoryService(feedHistoryRepo, agencyRepo)
+	feedStageSvc := usecases.NewFeedStageService(feedStageRepo, agencyRepo)
+	brandingSvc := usecases.NewBrandingService(brandingRepo, agencyRepo, routeRepo, assets)
+	rtFeedStatusSvc := usecases.NewRealtimeFeedStatusService(rtFeedStatusRepo,
+		time.Duration(cfg.Realtime.StaleAfterMinutes)*time.Minute)
+	offlineSvc := usecases.NewOfflineService(offlineRepo, agencyRepo, routeRepo, feedHistoryRepo)
+	syncSvc := usecases.NewSyncService(syncRepo, agencyRepo)
+	occupancySvc := usecases.NewOccupancyService(occupancyRepo, routeRepo, stopRepo)
+	coverageSvc := usecases.NewRealtimeCoverageService(coverageRepo, agencyRepo, routeRepo)
+	fleetSvc := usecases.NewFleetService(vehicleRepo, agencyRepo)
+	segmentSvc := usecases.NewSegmentService(segmentRepo, routeRepo)
+	timetableExportSvc := usecases.NewTimetableExportService(comparisonRepo, agencyRepo, assets)
+	punctualitySvc := usecases.NewPunctualityService(punctualityRepo, routeRepo, agencyRepo)
+	agencyAliasSvc := usecases.NewAgencyAliasService(agencyAliasRepo, agencyRepo)
+	pushKeySvc := usecases.NewPushKeyService(pushKeyRepo, agencyRepo)
+	searchSvc := usecases.NewSearchService(synonymRepo, searchLogRepo)
+	globalSearchSvc := usecases.NewGlobalSearchService(globalSearchRepo, cache)
+	geocodeSvc := usecases.NewGeocodeService(geocoder, cache, cfg.Geocoder.CacheHours*3600)
+	authSvc := usecases.NewAuthService(userRepo, tokens)
+	cdnSvc := usecases.NewCDNPurgeService(purger, syncRepo, agencyRepo)
 
 	deps := &http.Dependencies{
-		Agencies:   agencySvc,
-		Stops:      stopSvc,
-		Routes:     routeSvc,
-		Departures: departureSvc,
-		Trips:      tripSvc,
-		Realtime:   realtimeSvc,
-		Journeys:   journeySvc,
-		NATS:       natsConn,
-		DB:         db,
-		Cache:      cache,
+		Agencies:      agencySvc,
+		Stops:         stopSvc,
+		Routes:        routeSvc,
+		Departures:    departureSvc,
+		Trips:         tripSvc,
+		Realtime:      realtimeSvc,
+		Journeys:      journeySvc,
+		ShortLinks:    shortLinkSvc,
+		History:       historySvc,
+		CheckIns:      checkInSvc,
+		Devices:       deviceSvc,
+		Preferences:   usecases.NewNotificationPreferenceService(notificationPrefRepo),
+		Favorites:     favoriteSvc,
+		Events:        eventSvc,
+		DelayAlerts:   alertSubSvc,
+		Accessibility: accessibleSpaceSvc,
+		Compensations: compensationSvc,
+		Affiliates:    affiliateSvc,
+		Tiles:         tileSvc,
+		Challenges:    challengeSvc,
+		SLA:           slaSvc,
+		Alerts:        alertSvc,
+		FeedQuality:   feedQualitySvc,
+		FeedConfigs:   feedConfigSvc,
+		FeedHistory:   feedHistorySvc,
+		FeedStages:    feedStageSvc,
+		Branding:      brandingSvc,
+		RTFeeds:       rtFeedStatusSvc,
+		Offline:       offlineSvc,
+		Sync:          syncSvc,
+		Occupancy:     occupancySvc,
+		Coverage:      coverageSvc,
+		Fleet:         fleetSvc,
+		Segments:      segmentSvc,
+		Exports:       timetableExportSvc,
+		Search:        searchSvc,
+		GlobalSearch:  globalSearchSvc,
+		Geocode:       geocodeSvc,
+		Punctuality:   punctualitySvc,
+		AgencyAliases: agencyAliasSvc,
+		AgencyFeeds:   usecases.NewAgencyFeedsService(postgres.NewAgencyFeedsRepo(db)),
+		PushKeys:      pushKeySvc,
+		Workflows:     usecases.NewWorkflowService(workflowHistory),
+		Auth:          authSvc,
+		StaticVersion: staticVersionSvc,
+		CDN:           cdnSvc,
+		NATS:          natsConn,
+		DB:            db,
+		Cache:         cache,
+		AdminToken:    cfg.Admin.Token,
+		Tokens:        tokens,
+		VAPIDKey:      pusher.VAPIDKey(),
+		GeocodeLimit:  cfg.Geocoder.PerMinute,
+		Compression: http.CompressionConfig{
+			Brotli:      cfg.Compression.Brotli,
+			BrotliLevel: cfg.Compression.BrotliLevel,
+			GzipLevel:   cfg.Compression.GzipLevel,
+			MinBytes:    cfg.Compression.MinBytes,
+		},
+		GraphQL: http.GraphQLLimits{
+			MaxDepth:     cfg.GraphQL.MaxDepth,
+			MaxCost:      cfg.GraphQL.MaxCost,
+			Budget:       cfg.GraphQL.Budget,
+			BudgetWindow: time.Duration(cfg.GraphQL.BudgetSeconds) * time.Second,
+		},
 	}
 
 	// Fiber
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/adapters/objectstore"
+	"github.com/samirrijal/bilbopass/internal/adapters/postgres"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+	"github.com/samirrijal/bilbopass/internal/pkg/config"
+)
+
+// Realtime archive: writes a day's vehicle positions and delay events
+// (YYYY-MM-DD, by default the day before) to object storage as Parquet
+// partitions for offline analysis, then exits. The compensator runs it
+// nightly as the archive job.
+func main() {
+	cfg, err := config.Load("bilbopass-archive")
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	if cfg.Storage.Endpoint == "" {
+		log.Fatal("storage: BILBOPASS_STORAGE_ENDPOINT is required")
+	}
+
+	day := time.Now().AddDate(0, 0, -1)
+	if len(os.Args) > 1 {
+		day, err = time.ParseInLocation("2006-01-02", os.Args[1], time.Local)
+		if err != nil {
+			log.Fatalf("usage: archive [YYYY-MM-DD]")
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db, err := postgres.New(ctx, cfg.Database.DSN())
+	if err != nil {
+		log.Fatalf("db: %v", err)
+	}
+	defer db.Close()
+
+	store, err := objectstore.NewS3(cfg.Storage.Endpoint, cfg.Storage.Bucket, cfg.Storage.Region,
+		cfg.Storage.AccessKey, cfg.Storage.SecretKey, cfg.Storage.PublicURL)
+	if err != nil {
+		log.Fatalf("storage: %v", err)
+	}
+
+	svc := usecases.NewArchiveService(postgres.NewArchiveRepo(db), store)
+
+	partitions, err := svc.Archive(ctx, day)
+	for _, p := range partitions {
+		log.Printf("archived %d %s rows for %s to %s", p.Rows, p.Table, p.Date, p.URL)
+	}
+	if err != nil {
+		log.Fatalf("archive: %v", err)
+	}
+}
@@ -1,11 +1,20 @@
 package main
 
 import (
+	"context"
 	"log"
+	"time"
+	_ "time/tzdata" // for workflows.ScheduleTimezone in minimal images
 
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/worker"
 
+	natsadapter "github.com/samirrijal/bilbopass/internal/adapters/nats"
+	"github.com/samirrijal/bilbopass/internal/adapters/notifications"
+	"github.com/samirrijal/bilbopass/internal/adapters/objectstore"
+	"github.com/samirrijal/bilbopass/internal/adapters/postgres"
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
 	"github.com/samirrijal/bilbopass/internal/core/usecases"
 	"github.com/samirrijal/bilbopass/internal/pkg/config"
 	"github.com/samirrijal/bilbopass/internal/workflows"
@@ -16,25 +25,119 @@ func main() {
 	if err != nil {
 		log.Fatalf("config: %v", err)
 	}
-	_ = cfg // used for DB connections in activities
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db, err := postgres.New(ctx, cfg.Database.DSN())
+	if err != nil {
+		log.Fatalf("db: %v", err)
+	}
+	defer db.Close()
 
 	// Connect to Temporal
 	c, err := client.Dial(client.Options{
-		HostPort: "localhost:7233",
+		HostPort: cfg.Temporal.HostPort,
 	})
 	if err != nil {
 		log.Fatalf("temporal client: %v", err)
 	}
 	defer c.Close()
 
-	w := worker.New(c, "compensation-queue", worker.Options{})
+	// Start a compensation workflow for each rider on a delayed trip. Delay
+	// events are recorded and published by the realtime poller.
+	starter := workflows.NewStarter(c, cfg.Temporal.TaskQueue)
+	orchestrator := usecases.NewDelayOrchestrator(
+		postgres.NewHistoryRepo(db),
+		postgres.NewCheckInRepo(db),
+		postgres.NewStopRepo(db),
+		starter,
+	)
+	natsOpts, err := cfg.NATS.Options()
+	if err != nil {
+		log.Fatalf("nats: %v", err)
+	}
+	sub, err := natsadapter.NewSubscriber(cfg.NATS.URL, natsOpts...)
+	if err != nil {
+		log.Fatalf("nats: %v", err)
+	}
+	defer sub.Close()
+	pub, err := natsadapter.NewPublisher(cfg.NATS.URL, natsOpts...)
+	if err != nil {
+		log.Fatalf("nats: %v", err)
+	}
+	defer pub.Close()
+	err = sub.SubscribeDelayEvents(ctx, func(ctx context.Context, e *domain.DelayEvent) error {
+		started, err := orchestrator.HandleDelay(ctx, e)
+		if err != nil {
+			log.Printf("delay %s: %v", e.ID, err)
+			return err
+		}
+		if started > 0 {
+			log.Printf("delay %s: %d compensations started", e.ID, started)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("subscribe delay events: %v", err)
+	}
+
+	pusher, err := notifications.New(postgres.NewDeviceRepo(db), postgres.NewNotificationPreferenceRepo(db), cfg.Push.FCMCredentialsFile,
+		cfg.Push.VAPIDPublicKey, cfg.Push.VAPIDPrivateKey, cfg.Push.VAPIDSubject)
+	if err != nil {
+		log.Fatalf("push: %v", err)
+	}
+
+	// The realtime archive job; without storage it is skipped
+	var archive ports.ObjectStore
+	if cfg.Storage.Endpoint != "" {
+		s3, err := objectstore.NewS3(cfg.Storage.Endpoint, cfg.Storage.Bucket, cfg.Storage.Region,
+			cfg.Storage.AccessKey, cfg.Storage.SecretKey, cfg.Storage.PublicURL)
+		if err != nil {
+			log.Fatalf("storage: %v", err)
+		}
+		archive = s3
+	}
+	zone, err := time.LoadLocation(workflows.ScheduleTimezone)
+	if err != nil {
+		log.Fatalf("schedule timezone: %v", err)
+	}
+
+	agencyRepo := postgres.NewAgencyRepo(db)
+	routeRepo := postgres.NewRouteRepo(db)
+
+	w := worker.New(c, cfg.Temporal.TaskQueue, worker.Options{})
 
 	// Register workflow & activities
 	w.RegisterWorkflow(workflows.CompensationWorkflow)
+	// Feed ingests are orchestrated here; their activities run on the
+	// ingestor's workers (`ingestor worker`)
+	w.RegisterWorkflow(workflows.FeedIngestWorkflow)
 	w.RegisterActivity(&workflows.CompensationActivities{
 		// In production, inject real service implementations here.
 		CompensationService: &usecases.CompensationService{},
+		Affiliates:          postgres.NewAffiliateRepo(db),
+		Compensations:       postgres.NewCompensationRepo(db),
+		Notifier:            pusher,
+	})
+
+	// Periodic jobs, as cron workflows: their runs, retries and failures are
+	// visible in the Temporal UI (job-<name>)
+	w.RegisterWorkflow(workflows.ScheduledJobWorkflow)
+	w.RegisterActivity(&workflows.JobActivities{
+		Anomalies: usecases.NewAnomalyService(postgres.NewDelayStatsRepo(db), postgres.NewAlertRepo(db), pub),
+		SLAs:      usecases.NewSLAService(postgres.NewSLARepo(db), agencyRepo, pub),
+		Quality:   usecases.NewFeedQualityService(postgres.NewFeedQualityRepo(db), agencyRepo),
+		Archive:   usecases.NewArchiveService(postgres.NewArchiveRepo(db), archive),
+		Coverage:  usecases.NewRealtimeCoverageService(postgres.NewRealtimeCoverageRepo(db), agencyRepo, routeRepo),
+		Segments:  usecases.NewSegmentService(postgres.NewSegmentStatsRepo(db), routeRepo),
+		Zone:      zone,
 	})
+	started, err := starter.StartScheduledJobs(ctx, workflows.ScheduledJobs)
+	if err != nil {
+		log.Fatalf("scheduled jobs: %v", err)
+	}
+	log.Printf("%d of %d scheduled jobs (re)started", started, len(workflows.ScheduledJobs))
 
 	log.Println("compensator worker started")
 	if err := w.Run(worker.InterruptCh()); err != nil {
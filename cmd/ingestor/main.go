@@ -4,8 +4,11 @@ import (
 	"archive/zip"
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -20,11 +23,16 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/samirrijal/bilbopass/internal/adapters/cdn"
+	"github.com/samirrijal/bilbopass/internal/adapters/notifications"
+	"github.com/samirrijal/bilbopass/internal/adapters/postgres"
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
 	"github.com/samirrijal/bilbopass/internal/pkg/config"
 )
 
 // ---------------------------------------------------------------------------
-// Manifest types
+// Manifest types (imported with `ingestor import`)
 // ---------------------------------------------------------------------------
 
 type Manifest struct {
@@ -43,6 +51,18 @@ type GTFSRTEntry struct {
 	VehiclePositions string `json:"vehicle_positions,omitempty"`
 	TripUpdates      string `json:"trip_updates,omitempty"`
 	Alerts           string `json:"alerts,omitempty"`
+	PollInterval     int    `json:"poll_interval,omitempty"`
+	VehiclePush      bool   `json:"vehicle_push,omitempty"`
+}
+
+// feeds returns the entry as the agency feeds the database holds.
+func (a AgencyEntry) feeds() domain.AgencyFeeds {
+	f := domain.AgencyFeeds{Slug: a.Slug, Name: a.Name, GTFSURL: a.GTFSURL}
+	if a.GTFSRT != nil {
+		rt := domain.GTFSRTFeeds(*a.GTFSRT)
+		f.GTFSRT = &rt
+	}
+	return f
 }
 
 // ---------------------------------------------------------------------------
@@ -63,38 +83,92 @@ func main() {
 	}
 	defer pool.Close()
 
-	// Load manifest
-	manifestPath := "manifest.json"
-	if len(os.Args) > 1 {
-		manifestPath = os.Args[1]
+	// Riders hear about changes to their favorite stops and routes
+	db := &postgres.DB{Pool: pool}
+	pusher, err := notifications.New(postgres.NewDeviceRepo(db), postgres.NewNotificationPreferenceRepo(db), cfg.Push.FCMCredentialsFile,
+		cfg.Push.VAPIDPublicKey, cfg.Push.VAPIDPrivateKey, cfg.Push.VAPIDSubject)
+	if err != nil {
+		log.Fatalf("push: %v", err)
 	}
+	serviceChanges := usecases.NewServiceChangeService(postgres.NewFeedStateRepo(db), pusher)
 
-	data, err := os.ReadFile(manifestPath)
-	if err != nil {
-		log.Fatalf("read manifest: %v", err)
+	// The CDN in front of the API drops what applied feeds change
+	var purges *usecases.CDNPurgeService
+	if cfg.CDN.PurgeURL != "" {
+		purger, err := cdn.New(cfg.CDN.Provider, cfg.CDN.PurgeURL, cfg.CDN.Token)
+		if err != nil {
+			log.Fatalf("cdn: %v", err)
+		}
+		purges = usecases.NewCDNPurgeService(purger, postgres.NewSyncRepo(db), postgres.NewAgencyRepo(db))
 	}
 
-	var manifest Manifest
-	if err := json.Unmarshal(data, &manifest); err != nil {
-		log.Fatalf("parse manifest: %v", err)
+	// `ingestor activate` applies the feeds admins approved (see feed_stages)
+	if len(os.Args) > 1 && os.Args[1] == "activate" {
+		if err := activateApproved(ctx, pool, serviceChanges, purges); err != nil {
+			log.Fatalf("activate: %v", err)
+		}
+		return
+	}
+
+	// `ingestor worker` runs the activities of the feed ingest workflow
+	// (see workflows.FeedIngestWorkflow), which the compensator's worker
+	// orchestrates
+	if len(os.Args) > 1 && os.Args[1] == "worker" {
+		if err := runWorker(cfg, pool, serviceChanges, purges); err != nil {
+			log.Fatalf("worker: %v", err)
+		}
+		return
+	}
+
+	// `ingestor import [manifest]` copies the agencies of a manifest to the
+	// database, replacing the feeds of those already there
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		manifestPath := "manifest.json"
+		if len(os.Args) > 2 {
+			manifestPath = os.Args[2]
+		}
+		if err := importManifest(ctx, db, manifestPath); err != nil {
+			log.Fatalf("import: %v", err)
+		}
+		return
+	}
+
+	// `ingestor start [slugs]` starts a feed ingest workflow for each agency
+	// rather than ingesting them here
+	args := os.Args[1:]
+	workflowIngest := len(args) > 0 && args[0] == "start"
+	if workflowIngest {
+		args = args[1:]
 	}
 
-	log.Printf("BilboPass GTFS Ingestor — %d agencies from %s", len(manifest.Agencies), manifest.Source)
+	// Agencies are managed with /v1/admin/agencies
+	agencies, err := loadAgencies(ctx, db)
+	if err != nil {
+		log.Fatalf("load agencies: %v", err)
+	}
+	log.Printf("BilboPass GTFS Ingestor — %d agencies", len(agencies))
 
 	// Filter agencies (optional CLI arg: slug list)
 	slugFilter := map[string]bool{}
-	if len(os.Args) > 2 {
-		for _, s := range strings.Split(os.Args[2], ",") {
+	if len(args) > 0 {
+		for _, s := range strings.Split(args[0], ",") {
 			slugFilter[strings.TrimSpace(s)] = true
 		}
 	}
 
+	if workflowIngest {
+		if err := startIngests(ctx, cfg, agencies, slugFilter); err != nil {
+			log.Fatalf("start: %v", err)
+		}
+		return
+	}
+
 	client := &http.Client{Timeout: 120 * time.Second}
 
 	var wg sync.WaitGroup
 	sem := make(chan struct{}, 4) // max 4 concurrent downloads
 
-	for _, agency := range manifest.Agencies {
+	for _, agency := range agencies {
 		if len(slugFilter) > 0 && !slugFilter[agency.Slug] {
 			continue
 		}
@@ -105,7 +179,7 @@ func main() {
 			sem <- struct{}{}
 			defer func() { <-sem }()
 
-			if err := ingestAgency(ctx, pool, client, a); err != nil {
+			if err := ingestAgency(ctx, pool, client, serviceChanges, purges, a); err != nil {
 				log.Printf("ERROR [%s]: %v", a.Slug, err)
 			}
 		}(agency)
@@ -115,11 +189,55 @@ func main() {
 	log.Println("ingestion complete")
 }
 
+// ---------------------------------------------------------------------------
+// Agency list
+// ---------------------------------------------------------------------------
+
+// loadAgencies returns the agencies with feeds in the database, by slug.
+func loadAgencies(ctx context.Context, db *postgres.DB) ([]AgencyEntry, error) {
+	feeds, err := postgres.NewAgencyFeedsRepo(db).List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	agencies := make([]AgencyEntry, len(feeds))
+	for i, f := range feeds {
+		agencies[i] = AgencyEntry{Name: f.Name, Slug: f.Slug, GTFSURL: f.GTFSURL}
+	}
+	return agencies, nil
+}
+
+// importManifest adds the agencies of the manifest at path to the
+// database, and replaces the feeds of those it already has.
+func importManifest(ctx context.Context, db *postgres.DB, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read manifest: %w", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("parse manifest: %w", err)
+	}
+
+	svc := usecases.NewAgencyFeedsService(postgres.NewAgencyFeedsRepo(db))
+	for _, a := range manifest.Agencies {
+		f := a.feeds()
+		err := svc.Create(ctx, &f)
+		if errors.Is(err, usecases.ErrAgencyFeedsExist) {
+			err = svc.Update(ctx, f.Slug, &f)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", a.Slug, err)
+		}
+	}
+	log.Printf("imported %d agencies from %s", len(manifest.Agencies), manifest.Source)
+	return nil
+}
+
 // ---------------------------------------------------------------------------
 // Per-agency ingestion
 // ---------------------------------------------------------------------------
 
-func ingestAgency(ctx context.Context, pool *pgxpool.Pool, client *http.Client, agency AgencyEntry) error {
+func ingestAgency(ctx context.Context, pool *pgxpool.Pool, client *http.Client, serviceChanges *usecases.ServiceChangeService, purges *usecases.CDNPurgeService, agency AgencyEntry) error {
 	log.Printf("[%s] downloading GTFS from %s", agency.Slug, agency.GTFSURL)
 
 	resp, err := client.Get(agency.GTFSURL)
@@ -149,27 +267,144 @@ func ingestAgency(ctx context.Context, pool *pgxpool.Pool, client *http.Client,
 	}
 	log.Printf("[%s] agency_id=%s", agency.Slug, agencyID)
 
-	// Process GTFS files in order (stops before stop_times, routes before trips)
-	if err := processStops(ctx, pool, zr, agencyID, agency.Slug); err != nil {
-		log.Printf("[%s] stops: %v", agency.Slug, err)
+	sum := sha256.Sum256(body)
+	checksum := hex.EncodeToString(sum[:])
+	held, err := stageFeed(ctx, pool, zr, agencyID, agency.Slug, checksum, body)
+	if err != nil {
+		return fmt.Errorf("stage feed: %w", err)
 	}
-	if err := processRoutes(ctx, pool, zr, agencyID, agency.Slug); err != nil {
-		log.Printf("[%s] routes: %v", agency.Slug, err)
+	if held {
+		return nil
 	}
-	if err := processTrips(ctx, pool, zr, agencyID, agency.Slug); err != nil {
-		log.Printf("[%s] trips: %v", agency.Slug, err)
+	return applyFeed(ctx, pool, serviceChanges, purges, zr, agencyID, agency.Slug, checksum, body, "")
+}
+
+// applyFeed replaces the agency's live schedule with the feed. stageID is
+// the approved feed stage being applied, empty for a feed applied as it is
+// downloaded.
+func applyFeed(ctx context.Context, pool *pgxpool.Pool, serviceChanges *usecases.ServiceChangeService, purges *usecases.CDNPurgeService,
+	zr *zip.Reader, agencyID, slug, checksum string, body []byte, stageID string) error {
+	// A minute early, in case this clock is ahead of the database's
+	started := time.Now().Add(-time.Minute)
+
+	skip, err := aliasFilter(ctx, pool, zr, agencyID, slug)
+	if err != nil {
+		return err
 	}
-	if err := processStopTimes(ctx, pool, zr, agencyID, agency.Slug); err != nil {
-		log.Printf("[%s] stop_times: %v", agency.Slug, err)
+	changes, err := loadFeed(ctx, pool, serviceChanges, zr, agencyID, slug, skip)
+	if err != nil {
+		log.Printf("[%s] %v", slug, err)
+	}
+	if err := activateFeed(ctx, pool, zr, agencyID, slug, checksum, body, stageID, skip); err != nil {
+		log.Printf("[%s] %v", slug, err)
+	}
+
+	if purges != nil {
+		keys, err := purges.PurgeChanges(ctx, slug, started, time.Now())
+		if err != nil {
+			log.Printf("[%s] cdn purge: %v", slug, err)
+		}
+		log.Printf("[%s] cdn purged %d keys", slug, len(keys))
 	}
-	if err := processShapes(ctx, pool, zr, agencyID, agency.Slug); err != nil {
-		log.Printf("[%s] shapes: %v (may not exist)", agency.Slug, err)
+
+	if len(changes) > 0 {
+		sent, err := serviceChanges.Notify(ctx, changes)
+		if err != nil {
+			log.Printf("[%s] notify service changes: %v", slug, err)
+		}
+		log.Printf("[%s] service changes: %d, notifications sent: %d", slug, len(changes), sent)
 	}
 
-	log.Printf("[%s] done", agency.Slug)
+	log.Printf("[%s] done", slug)
 	return nil
 }
 
+// aliasFilter returns the filter leaving out the agencies the feed
+// duplicates (see agency_aliases), nil when it duplicates none.
+func aliasFilter(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agencyID, slug string) (*feedFilter, error) {
+	aliases, err := loadAliases(ctx, pool, slug, agencyID)
+	if err != nil {
+		return nil, fmt.Errorf("load aliases: %w", err)
+	}
+	if len(aliases) == 0 {
+		return nil, nil
+	}
+	skip, err := buildFeedFilter(zr, aliases)
+	if err != nil {
+		return nil, fmt.Errorf("aliases: %w", err)
+	}
+	return skip, nil
+}
+
+// loadFeed writes the feed's GTFS files over the agency's schedule, and
+// returns the service changes riders are to hear about. Every file is
+// processed; the errors of stops, routes, trips and stop times, which the
+// schedule cannot do without, are returned together, those of optional
+// files only logged.
+func loadFeed(ctx context.Context, pool *pgxpool.Pool, serviceChanges *usecases.ServiceChangeService,
+	zr *zip.Reader, agencyID, slug string, skip *feedFilter) ([]domain.ServiceChange, error) {
+	// Compare with the previous feed before overwriting names
+	changes, err := serviceChanges.Detect(ctx, agencyID, buildFeedSnapshot(zr, skip))
+	if err != nil {
+		log.Printf("[%s] service changes: %v", slug, err)
+	}
+
+	// Process GTFS files in order (stops before stop_times, routes before trips)
+	var errs []error
+	if err := processStops(ctx, pool, zr, agencyID, slug, skip); err != nil {
+		errs = append(errs, fmt.Errorf("stops: %w", err))
+	}
+	if err := processStopAmenities(ctx, pool, zr, agencyID, slug, skip); err != nil {
+		log.Printf("[%s] stop amenities: %v (may not exist)", slug, err)
+	}
+	if err := processRoutes(ctx, pool, zr, agencyID, slug, skip); err != nil {
+		errs = append(errs, fmt.Errorf("routes: %w", err))
+	}
+	if err := processTrips(ctx, pool, zr, agencyID, slug, skip); err != nil {
+		errs = append(errs, fmt.Errorf("trips: %w", err))
+	}
+	if err := processStopTimes(ctx, pool, zr, agencyID, slug, skip); err != nil {
+		errs = append(errs, fmt.Errorf("stop_times: %w", err))
+	}
+	if err := processFrequencies(ctx, pool, zr, agencyID, slug, skip); err != nil {
+		log.Printf("[%s] frequencies: %v (may not exist)", slug, err)
+	}
+	if err := linkBlocks(ctx, pool, agencyID, slug); err != nil {
+		log.Printf("[%s] blocks: %v", slug, err)
+	}
+	if skip != nil {
+		if err := removeAliased(ctx, pool, agencyID, slug, skip); err != nil {
+			log.Printf("[%s] remove aliased: %v", slug, err)
+		}
+	}
+	if err := processShapes(ctx, pool, zr, agencyID, slug); err != nil {
+		log.Printf("[%s] shapes: %v (may not exist)", slug, err)
+	}
+	return changes, errors.Join(errs...)
+}
+
+// activateFeed records the loaded feed as the agency's current version,
+// keeps it for rollbacks and marks the agency ingested. Each step is taken
+// whether or not the others fail; their errors are returned together.
+func activateFeed(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agencyID, slug, checksum string, body []byte, stageID string, skip *feedFilter) error {
+	var errs []error
+	if err := recordFeedVersion(ctx, pool, zr, agencyID, slug, checksum, buildFeedSnapshot(zr, skip), skip); err != nil {
+		errs = append(errs, fmt.Errorf("feed version: %w", err))
+	}
+	if err := keepAppliedFeed(ctx, pool, zr, agencyID, checksum, body, stageID); err != nil {
+		errs = append(errs, fmt.Errorf("keep feed for rollbacks: %w", err))
+	}
+
+	// Seen by the feed health gauges, whether or not the feed changed
+	if _, err := pool.Exec(ctx, `
+		INSERT INTO feed_ingests (agency_id, last_ingest_at) VALUES ($1, NOW())
+		ON CONFLICT (agency_id) DO UPDATE SET last_ingest_at = EXCLUDED.last_ingest_at
+	`, agencyID); err != nil {
+		errs = append(errs, fmt.Errorf("record ingest: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
 // ---------------------------------------------------------------------------
 // Agency upsert
 // ---------------------------------------------------------------------------
@@ -185,11 +420,489 @@ func upsertAgency(ctx context.Context, pool *pgxpool.Pool, a AgencyEntry) (strin
 	return id, err
 }
 
+// ---------------------------------------------------------------------------
+// Agency aliases
+// ---------------------------------------------------------------------------
+
+// feedFilter holds the GTFS IDs of a feed that belong to agencies aliased to
+// another canonical agency. They are left out so the API does not list the
+// same routes and stops twice. A nil feedFilter keeps everything.
+type feedFilter struct {
+	routes map[string]bool
+	trips  map[string]bool
+	stops  map[string]bool
+}
+
+func (f *feedFilter) skipRoute(id string) bool { return f != nil && f.routes[id] }
+func (f *feedFilter) skipTrip(id string) bool  { return f != nil && f.trips[id] }
+func (f *feedFilter) skipStop(id string) bool  { return f != nil && f.stops[id] }
+
+// loadAliases returns the GTFS agency_ids of this feed that are aliases of
+// another canonical agency, mapped to that agency's slug. An empty agency_id
+// aliases the whole feed.
+func loadAliases(ctx context.Context, pool *pgxpool.Pool, slug, agencyID string) (map[string]string, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT al.source_agency_id, a.slug
+		FROM agency_aliases al
+		JOIN agencies a ON a.id = al.agency_id
+		WHERE al.source_slug = $1 AND al.agency_id <> $2
+	`, slug, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := map[string]string{}
+	for rows.Next() {
+		var sourceAgencyID, canonical string
+		if err := rows.Scan(&sourceAgencyID, &canonical); err != nil {
+			return nil, err
+		}
+		aliases[sourceAgencyID] = canonical
+	}
+	return aliases, rows.Err()
+}
+
+// buildFeedFilter collects the routes of aliased agencies, their trips, and
+// the stops served only by those trips.
+func buildFeedFilter(zr *zip.Reader, aliases map[string]string) (*feedFilter, error) {
+	// Routes may omit agency_id when the feed has a single agency.
+	var feedAgencies []string
+	_ = forEachRecord(zr, "agency.txt", func(record []string, cols map[string]int) {
+		feedAgencies = append(feedAgencies, getField(record, cols, "agency_id"))
+	})
+	defaultAgency := ""
+	if len(feedAgencies) == 1 {
+		defaultAgency = feedAgencies[0]
+	}
+	_, wholeFeed := aliases[""]
+
+	f := &feedFilter{routes: map[string]bool{}, trips: map[string]bool{}, stops: map[string]bool{}}
+	err := forEachRecord(zr, "routes.txt", func(record []string, cols map[string]int) {
+		a := getField(record, cols, "agency_id")
+		if a == "" {
+			a = defaultAgency
+		}
+		if _, ok := aliases[a]; ok || wholeFeed {
+			f.routes[getField(record, cols, "route_id")] = true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(f.routes) == 0 {
+		return nil, nil
+	}
+
+	err = forEachRecord(zr, "trips.txt", func(record []string, cols map[string]int) {
+		if f.routes[getField(record, cols, "route_id")] {
+			f.trips[getField(record, cols, "trip_id")] = true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	kept := map[string]bool{}
+	err = forEachRecord(zr, "stop_times.txt", func(record []string, cols map[string]int) {
+		stopID := getField(record, cols, "stop_id")
+		if f.trips[getField(record, cols, "trip_id")] {
+			f.stops[stopID] = true
+		} else {
+			kept[stopID] = true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for id := range kept {
+		delete(f.stops, id)
+	}
+	return f, nil
+}
+
+// removeAliased deletes routes and stops ingested before their agency was
+// aliased. Trips and stop times go with them.
+func removeAliased(ctx context.Context, pool *pgxpool.Pool, agencyID, slug string, f *feedFilter) error {
+	routeIDs := make([]string, 0, len(f.routes))
+	for id := range f.routes {
+		routeIDs = append(routeIDs, id)
+	}
+	stopIDs := make([]string, 0, len(f.stops))
+	for id := range f.stops {
+		stopIDs = append(stopIDs, id)
+	}
+
+	routes, err := pool.Exec(ctx, `DELETE FROM routes WHERE agency_id = $1 AND route_id = ANY($2)`, agencyID, routeIDs)
+	if err != nil {
+		return fmt.Errorf("routes: %w", err)
+	}
+	stops, err := pool.Exec(ctx, `DELETE FROM stops WHERE agency_id = $1 AND stop_id = ANY($2)`, agencyID, stopIDs)
+	if err != nil {
+		return fmt.Errorf("stops: %w", err)
+	}
+	log.Printf("[%s]   aliased: %d routes, %d trips, %d stops skipped (%d routes, %d stops removed)",
+		slug, len(f.routes), len(f.trips), len(f.stops), routes.RowsAffected(), stops.RowsAffected())
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Service changes
+// ---------------------------------------------------------------------------
+
+// buildFeedSnapshot reads the stop names, route names and trips per route the
+// feed ships, named as processStops and processRoutes store them. A missing
+// file leaves its part empty.
+func buildFeedSnapshot(zr *zip.Reader, skip *feedFilter) *domain.FeedSnapshot {
+	snap := &domain.FeedSnapshot{Stops: map[string]string{}, Routes: map[string]domain.FeedRoute{}}
+	_ = forEachRecord(zr, "stops.txt", func(record []string, cols map[string]int) {
+		stopID := getField(record, cols, "stop_id")
+		if stopID != "" && !skip.skipStop(stopID) {
+			snap.Stops[stopID] = getField(record, cols, "stop_name")
+		}
+	})
+	_ = forEachRecord(zr, "routes.txt", func(record []string, cols map[string]int) {
+		routeID := getField(record, cols, "route_id")
+		if routeID == "" || skip.skipRoute(routeID) {
+			return
+		}
+		name := getField(record, cols, "route_short_name")
+		if name == "" {
+			name = getField(record, cols, "route_long_name")
+		}
+		if name == "" {
+			name = routeID
+		}
+		snap.Routes[routeID] = domain.FeedRoute{Name: name}
+	})
+	_ = forEachRecord(zr, "trips.txt", func(record []string, cols map[string]int) {
+		routeID := getField(record, cols, "route_id")
+		if r, ok := snap.Routes[routeID]; ok {
+			r.Trips++
+			snap.Routes[routeID] = r
+		}
+	})
+	return snap
+}
+
+// ---------------------------------------------------------------------------
+// Feed staging (see feed_stages)
+// ---------------------------------------------------------------------------
+
+// keptAppliedFeeds is how many applied feeds each agency keeps: the live one
+// and the previous one a rollback returns to.
+const keptAppliedFeeds = 2
+
+// stageFeed holds a new feed for approval when the agency requires it, and
+// reports whether it was held. A feed equal to the live version is applied
+// as usual; one already staged, approved or rejected is left to that
+// decision.
+func stageFeed(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agencyID, slug, checksum string, body []byte) (bool, error) {
+	var required bool
+	err := pool.QueryRow(ctx, `
+		SELECT require_approval FROM agency_feed_configs WHERE agency_id = $1
+	`, agencyID).Scan(&required)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return false, err
+	}
+	if !required {
+		return false, nil
+	}
+
+	var current string
+	err = pool.QueryRow(ctx, `
+		SELECT sha256 FROM feed_versions WHERE agency_id = $1 ORDER BY activated_at DESC LIMIT 1
+	`, agencyID).Scan(&current)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return false, err
+	}
+	if current == checksum {
+		return false, nil
+	}
+
+	var status string
+	err = pool.QueryRow(ctx, `
+		SELECT status FROM feed_stages
+		WHERE agency_id = $1 AND sha256 = $2 AND status IN ('staged', 'approved', 'rejected')
+		ORDER BY staged_at DESC LIMIT 1
+	`, agencyID, checksum).Scan(&status)
+	if err == nil {
+		log.Printf("[%s] new feed already %s, not applied", slug, status)
+		return true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return false, err
+	}
+
+	stops, routes, trips := countFeed(zr)
+	if _, err := pool.Exec(ctx, `
+		INSERT INTO feed_stages (agency_id, sha256, feed, stops, routes, trips)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, agencyID, checksum, body, stops, routes, trips); err != nil {
+		return false, err
+	}
+	log.Printf("[%s] new feed staged for approval: %d stops, %d routes, %d trips", slug, stops, routes, trips)
+	return true, nil
+}
+
+// keepAppliedFeed marks the approved stage as applied, or keeps a feed
+// applied as downloaded when it differs from the last one kept, so a
+// rollback can return to it. Older applied feeds are dropped.
+func keepAppliedFeed(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agencyID, checksum string, body []byte, stageID string) error {
+	if stageID != "" {
+		if _, err := pool.Exec(ctx, `
+			UPDATE feed_stages SET status = 'applied', applied_at = NOW() WHERE id = $1
+		`, stageID); err != nil {
+			return err
+		}
+	} else {
+		var last string
+		err := pool.QueryRow(ctx, `
+			SELECT sha256 FROM feed_stages
+			WHERE agency_id = $1 AND status = 'applied'
+			ORDER BY applied_at DESC LIMIT 1
+		`, agencyID).Scan(&last)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		if last == checksum {
+			return nil
+		}
+		stops, routes, trips := countFeed(zr)
+		if _, err := pool.Exec(ctx, `
+			INSERT INTO feed_stages (agency_id, sha256, feed, stops, routes, trips, status, applied_at)
+			VALUES ($1, $2, $3, $4, $5, $6, 'applied', NOW())
+		`, agencyID, checksum, body, stops, routes, trips); err != nil {
+			return err
+		}
+	}
+	_, err := pool.Exec(ctx, `
+		DELETE FROM feed_stages
+		WHERE agency_id = $1 AND status = 'applied'
+		  AND id NOT IN (SELECT id FROM feed_stages WHERE agency_id = $1 AND status = 'applied'
+		                 ORDER BY applied_at DESC LIMIT $2)
+	`, agencyID, keptAppliedFeeds)
+	return err
+}
+
+// countFeed counts the rows of the feed's stops, routes and trips, for
+// admins reviewing a staged feed.
+func countFeed(zr *zip.Reader) (stops, routes, trips int) {
+	_ = forEachRecord(zr, "stops.txt", func([]string, map[string]int) { stops++ })
+	_ = forEachRecord(zr, "routes.txt", func([]string, map[string]int) { routes++ })
+	_ = forEachRecord(zr, "trips.txt", func([]string, map[string]int) { trips++ })
+	return stops, routes, trips
+}
+
+// activateApproved applies the feeds admins approved, in the order they
+// were approved. A feed that fails to open stays approved for the next run.
+func activateApproved(ctx context.Context, pool *pgxpool.Pool, serviceChanges *usecases.ServiceChangeService, purges *usecases.CDNPurgeService) error {
+	type approved struct{ id, agencyID, slug, checksum string }
+	rows, err := pool.Query(ctx, `
+		SELECT s.id, s.agency_id, a.slug, s.sha256
+		FROM feed_stages s
+		JOIN agencies a ON a.id = s.agency_id
+		WHERE s.status = 'approved'
+		ORDER BY s.decided_at
+	`)
+	if err != nil {
+		return err
+	}
+	var feeds []approved
+	for rows.Next() {
+		var f approved
+		if err := rows.Scan(&f.id, &f.agencyID, &f.slug, &f.checksum); err != nil {
+			rows.Close()
+			return err
+		}
+		feeds = append(feeds, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	log.Printf("%d approved feeds to apply", len(feeds))
+	for _, f := range feeds {
+		var body []byte
+		if err := pool.QueryRow(ctx, `SELECT feed FROM feed_stages WHERE id = $1`, f.id).Scan(&body); err != nil {
+			log.Printf("ERROR [%s]: load feed %s: %v", f.slug, f.id, err)
+			continue
+		}
+		zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		if err != nil {
+			log.Printf("ERROR [%s]: open feed %s: %v", f.slug, f.id, err)
+			continue
+		}
+		log.Printf("[%s] applying approved feed %s", f.slug, f.id)
+		if err := applyFeed(ctx, pool, serviceChanges, purges, zr, f.agencyID, f.slug, f.checksum, body, f.id); err != nil {
+			log.Printf("ERROR [%s]: %v", f.slug, err)
+		}
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Feed versions
+// ---------------------------------------------------------------------------
+
+// recordFeedVersion starts a new feed version when the feed differs from the
+// agency's current one. Stops, routes and trip timetables that changed or
+// left the feed close their history rows and the current ones are opened, so
+// the API can answer as of past dates. Runs after the feed is ingested.
+func recordFeedVersion(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agencyID, slug, checksum string, snap *domain.FeedSnapshot, skip *feedFilter) error {
+	var current string
+	err := pool.QueryRow(ctx, `
+		SELECT sha256 FROM feed_versions WHERE agency_id = $1 ORDER BY activated_at DESC LIMIT 1
+	`, agencyID).Scan(&current)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	if current == checksum {
+		log.Printf("[%s]   feed version unchanged", slug)
+		return nil
+	}
+
+	// A feed that failed to parse would close every history row.
+	if len(snap.Stops) == 0 || len(snap.Routes) == 0 {
+		return fmt.Errorf("feed has no stops or routes, not versioned")
+	}
+
+	stopIDs := make([]string, 0, len(snap.Stops))
+	for id := range snap.Stops {
+		stopIDs = append(stopIDs, id)
+	}
+	routeIDs := make([]string, 0, len(snap.Routes))
+	for id := range snap.Routes {
+		routeIDs = append(routeIDs, id)
+	}
+	var tripIDs []string
+	_ = forEachRecord(zr, "trips.txt", func(record []string, cols map[string]int) {
+		if id := getField(record, cols, "trip_id"); id != "" && !skip.skipRoute(getField(record, cols, "route_id")) {
+			tripIDs = append(tripIDs, id)
+		}
+	})
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var activatedAt time.Time
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO feed_versions (agency_id, sha256, stops, routes, trips)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING activated_at
+	`, agencyID, checksum, len(stopIDs), len(routeIDs), len(tripIDs)).Scan(&activatedAt); err != nil {
+		return fmt.Errorf("insert version: %w", err)
+	}
+
+	// Each statement closes the open rows whose entity changed or left the
+	// feed, then opens rows for entities without an identical open one.
+	if _, err := tx.Exec(ctx, `
+		WITH cur AS (
+			SELECT id, name, ST_Y(location::geometry) AS lat, ST_X(location::geometry) AS lon,
+			       platform_code, wheelchair_accessible
+			FROM stops WHERE agency_id = $1 AND stop_id = ANY($2)
+		), closed AS (
+			UPDATE stop_history h SET valid_until = $3
+			WHERE h.valid_until IS NULL
+			  AND h.stop_id IN (SELECT id FROM stops WHERE agency_id = $1)
+			  AND NOT EXISTS (
+				SELECT 1 FROM cur WHERE cur.id = h.stop_id
+				  AND (cur.name, cur.lat, cur.lon, cur.platform_code, cur.wheelchair_accessible)
+				      IS NOT DISTINCT FROM (h.name, h.lat, h.lon, h.platform_code, h.wheelchair_accessible))
+		)
+		INSERT INTO stop_history (stop_id, name, lat, lon, platform_code, wheelchair_accessible, valid_from)
+		SELECT cur.id, cur.name, cur.lat, cur.lon, cur.platform_code, cur.wheelchair_accessible, $3
+		FROM cur
+		WHERE NOT EXISTS (
+			SELECT 1 FROM stop_history h WHERE h.stop_id = cur.id AND h.valid_until IS NULL
+			  AND (cur.name, cur.lat, cur.lon, cur.platform_code, cur.wheelchair_accessible)
+			      IS NOT DISTINCT FROM (h.name, h.lat, h.lon, h.platform_code, h.wheelchair_accessible))
+	`, agencyID, stopIDs, activatedAt); err != nil {
+		return fmt.Errorf("stop history: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		WITH cur AS (
+			SELECT id, short_name, long_name, route_type, color, text_color
+			FROM routes WHERE agency_id = $1 AND route_id = ANY($2)
+		), closed AS (
+			UPDATE route_history h SET valid_until = $3
+			WHERE h.valid_until IS NULL
+			  AND h.route_id IN (SELECT id FROM routes WHERE agency_id = $1)
+			  AND NOT EXISTS (
+				SELECT 1 FROM cur WHERE cur.id = h.route_id
+				  AND (cur.short_name, cur.long_name, cur.route_type, cur.color, cur.text_color)
+				      IS NOT DISTINCT FROM (h.short_name, h.long_name, h.route_type, h.color, h.text_color))
+		)
+		INSERT INTO route_history (route_id, short_name, long_name, route_type, color, text_color, valid_from)
+		SELECT cur.id, cur.short_name, cur.long_name, cur.route_type, cur.color, cur.text_color, $3
+		FROM cur
+		WHERE NOT EXISTS (
+			SELECT 1 FROM route_history h WHERE h.route_id = cur.id AND h.valid_until IS NULL
+			  AND (cur.short_name, cur.long_name, cur.route_type, cur.color, cur.text_color)
+			      IS NOT DISTINCT FROM (h.short_name, h.long_name, h.route_type, h.color, h.text_color))
+	`, agencyID, routeIDs, activatedAt); err != nil {
+		return fmt.Errorf("route history: %w", err)
+	}
+
+	// stop_times may hold rows from earlier ingests; the newest per stop
+	// sequence is the one this feed wrote.
+	if _, err := tx.Exec(ctx, `
+		WITH cur AS (
+			SELECT t.id, t.service_id, t.headsign,
+			       COALESCE((
+				SELECT jsonb_agg(jsonb_build_object(
+					'stop_id', st.stop_id,
+					'arrival', EXTRACT(EPOCH FROM st.arrival_time)::int,
+					'departure', EXTRACT(EPOCH FROM st.departure_time)::int,
+					'stop_sequence', st.stop_sequence,
+					'pickup_type', st.pickup_type,
+					'drop_off_type', st.drop_off_type) ORDER BY st.stop_sequence)
+				FROM (
+					SELECT DISTINCT ON (stop_sequence) *
+					FROM stop_times WHERE trip_id = t.id
+					ORDER BY stop_sequence, created_at DESC
+				) st
+			       ), '[]') AS stop_times
+			FROM trips t
+			JOIN routes r ON r.id = t.route_id
+			WHERE r.agency_id = $1 AND t.trip_id = ANY($2)
+		), closed AS (
+			UPDATE trip_history h SET valid_until = $3
+			WHERE h.valid_until IS NULL
+			  AND h.trip_id IN (SELECT t.id FROM trips t JOIN routes r ON r.id = t.route_id WHERE r.agency_id = $1)
+			  AND NOT EXISTS (
+				SELECT 1 FROM cur WHERE cur.id = h.trip_id
+				  AND (cur.service_id, cur.headsign, cur.stop_times)
+				      IS NOT DISTINCT FROM (h.service_id, h.headsign, h.stop_times))
+		)
+		INSERT INTO trip_history (trip_id, service_id, headsign, stop_times, valid_from)
+		SELECT cur.id, cur.service_id, cur.headsign, cur.stop_times, $3
+		FROM cur
+		WHERE NOT EXISTS (
+			SELECT 1 FROM trip_history h WHERE h.trip_id = cur.id AND h.valid_until IS NULL
+			  AND (cur.service_id, cur.headsign, cur.stop_times)
+			      IS NOT DISTINCT FROM (h.service_id, h.headsign, h.stop_times))
+	`, agencyID, tripIDs, activatedAt); err != nil {
+		return fmt.Errorf("trip history: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	log.Printf("[%s]   feed version %.12s activated", slug, checksum)
+	return nil
+}
+
 // ---------------------------------------------------------------------------
 // Stops
 // ---------------------------------------------------------------------------
 
-func processStops(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agencyID, slug string) error {
+func processStops(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agencyID, slug string, skip *feedFilter) error {
 	f, err := openCSV(zr, "stops.txt")
 	if err != nil {
 		return err
@@ -207,6 +920,9 @@ func processStops(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agenc
 	batch := &pgx.Batch{}
 	count := 0
 	total := 0
+	// parent_station of each stop, linked once every stop exists since a
+	// station may come after its platforms
+	var stopIDs, parents []string
 
 	for {
 		record, err := reader.Read()
@@ -223,19 +939,33 @@ func processStops(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agenc
 		lon, _ := strconv.ParseFloat(strings.TrimSpace(record[cols["stop_lon"]]), 64)
 		platformCode := getField(record, cols, "platform_code")
 		wheelchair := getField(record, cols, "wheelchair_boarding") == "1"
+		locationType, _ := strconv.Atoi(getField(record, cols, "location_type"))
 
 		if lat == 0 && lon == 0 {
 			continue
 		}
+		if skip.skipStop(stopID) {
+			continue
+		}
 
 		batch.Queue(`
-			INSERT INTO stops (stop_id, agency_id, name, location, platform_code, wheelchair_accessible)
-			VALUES ($1, $2, $3, ST_SetSRID(ST_MakePoint($4, $5), 4326)::geography, $6, $7)
+			INSERT INTO stops (stop_id, agency_id, name, location, platform_code, wheelchair_accessible, location_type, stop_code,
+			                   description, url, zone_id)
+			VALUES ($1, $2, $3, ST_SetSRID(ST_MakePoint($4, $5), 4326)::geography, $6, $7, $8, $9, $10, $11, $12)
 			ON CONFLICT (agency_id, stop_id) DO UPDATE
 			SET name = EXCLUDED.name, location = EXCLUDED.location,
 			    platform_code = EXCLUDED.platform_code,
-			    wheelchair_accessible = EXCLUDED.wheelchair_accessible
-		`, stopID, agencyID, name, lon, lat, nilEmpty(platformCode), wheelchair)
+			    wheelchair_accessible = EXCLUDED.wheelchair_accessible,
+			    location_type = EXCLUDED.location_type,
+			    stop_code = EXCLUDED.stop_code,
+			    description = EXCLUDED.description,
+			    url = EXCLUDED.url,
+			    zone_id = EXCLUDED.zone_id
+		`, stopID, agencyID, name, lon, lat, nilEmpty(platformCode), wheelchair, locationType,
+			nilEmpty(getField(record, cols, "stop_code")), nilEmpty(getField(record, cols, "stop_desc")),
+			nilEmpty(getField(record, cols, "stop_url")), nilEmpty(getField(record, cols, "zone_id")))
+		stopIDs = append(stopIDs, stopID)
+		parents = append(parents, getField(record, cols, "parent_station"))
 
 		count++
 		total++
@@ -255,7 +985,57 @@ func processStops(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agenc
 		}
 	}
 
-	log.Printf("[%s]   stops: %d", slug, total)
+	// Only rows whose parent changes are written, so re-ingesting a feed
+	// leaves updated_at alone.
+	tag, err := pool.Exec(ctx, `
+		UPDATE stops s
+		SET parent_id = p.id
+		FROM unnest($2::text[], $3::text[]) AS l(stop_id, parent_station)
+		LEFT JOIN stops p ON p.agency_id = $1 AND p.stop_id = NULLIF(l.parent_station, '')
+		WHERE s.agency_id = $1 AND s.stop_id = l.stop_id
+		  AND s.parent_id IS DISTINCT FROM p.id
+	`, agencyID, stopIDs, parents)
+	if err != nil {
+		return fmt.Errorf("link stations: %w", err)
+	}
+
+	log.Printf("[%s]   stops: %d (%d station links changed)", slug, total, tag.RowsAffected())
+	return nil
+}
+
+// processStopAmenities loads shelter, bench and real-time display flags from
+// stop_amenities.txt, which some agencies ship alongside their feed: stop_id
+// plus one 0/1 column per amenity, empty when unknown. Stops whose amenities
+// an admin or rider has edited are left alone.
+func processStopAmenities(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agencyID, slug string, skip *feedFilter) error {
+	batch := &pgx.Batch{}
+	total := 0
+	err := forEachRecord(zr, "stop_amenities.txt", func(record []string, cols map[string]int) {
+		stopID := getField(record, cols, "stop_id")
+		if stopID == "" || skip.skipStop(stopID) {
+			return
+		}
+		batch.Queue(`
+			UPDATE stops
+			SET shelter = $3, bench = $4, realtime_display = $5,
+			    amenities_source = 'agency', amenities_updated_at = NOW()
+			WHERE agency_id = $1 AND stop_id = $2
+			  AND (amenities_source IS NULL OR amenities_source IN ('agency', 'osm'))
+		`, agencyID, stopID, parseFlag(getField(record, cols, "shelter")),
+			parseFlag(getField(record, cols, "bench")),
+			parseFlag(getField(record, cols, "realtime_display")))
+		total++
+	})
+	if err != nil {
+		return err
+	}
+	if total > 0 {
+		if err := flushBatch(ctx, pool, batch, total); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("[%s]   stop amenities: %d", slug, total)
 	return nil
 }
 
@@ -263,12 +1043,22 @@ func processStops(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agenc
 // Routes
 // ---------------------------------------------------------------------------
 
-func processRoutes(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agencyID, slug string) error {
+func processRoutes(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agencyID, slug string, skip *feedFilter) error {
 	f, err := openCSV(zr, "routes.txt")
 	if err != nil {
 		return err
 	}
 
+	// Long names are cleaned up with the agency's rules, keeping the original
+	var rules domain.RouteNameRules
+	err = pool.QueryRow(ctx, `
+		SELECT route_name_rules FROM agency_feed_configs WHERE agency_id = $1
+	`, agencyID).Scan(&rules)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("route name rules: %w", err)
+	}
+	cleaned := 0
+
 	reader := csv.NewReader(f)
 	reader.LazyQuotes = true
 	header, err := reader.Read()
@@ -290,12 +1080,21 @@ func processRoutes(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agen
 		}
 
 		routeID := record[cols["route_id"]]
+		if skip.skipRoute(routeID) {
+			continue
+		}
 		shortName := getField(record, cols, "route_short_name")
 		longName := getField(record, cols, "route_long_name")
 		routeType, _ := strconv.Atoi(getField(record, cols, "route_type"))
 		color := getField(record, cols, "route_color")
 		textColor := getField(record, cols, "route_text_color")
 
+		metadata := map[string]any{}
+		if name := usecases.NormalizeRouteName(rules, shortName, longName); name != longName {
+			metadata[usecases.OriginalLongNameKey] = longName
+			longName = name
+			cleaned++
+		}
 		if longName == "" {
 			longName = shortName
 		}
@@ -310,12 +1109,13 @@ func processRoutes(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agen
 		}
 
 		batch.Queue(`
-			INSERT INTO routes (route_id, agency_id, short_name, long_name, route_type, color, text_color)
-			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			INSERT INTO routes (route_id, agency_id, short_name, long_name, route_type, color, text_color, metadata)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 			ON CONFLICT (agency_id, route_id) DO UPDATE
 			SET short_name = EXCLUDED.short_name, long_name = EXCLUDED.long_name,
-			    route_type = EXCLUDED.route_type, color = EXCLUDED.color, text_color = EXCLUDED.text_color
-		`, routeID, agencyID, shortName, longName, routeType, color, textColor)
+			    route_type = EXCLUDED.route_type, color = EXCLUDED.color, text_color = EXCLUDED.text_color,
+			    metadata = (routes.metadata - $9::text) || EXCLUDED.metadata
+		`, routeID, agencyID, shortName, longName, routeType, color, textColor, metadata, usecases.OriginalLongNameKey)
 
 		count++
 	}
@@ -326,7 +1126,7 @@ func processRoutes(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agen
 		}
 	}
 
-	log.Printf("[%s]   routes: %d", slug, count)
+	log.Printf("[%s]   routes: %d (%d names cleaned up)", slug, count, cleaned)
 	return nil
 }
 
@@ -334,7 +1134,7 @@ func processRoutes(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agen
 // Trips
 // ---------------------------------------------------------------------------
 
-func processTrips(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agencyID, slug string) error {
+func processTrips(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agencyID, slug string, skip *feedFilter) error {
 	f, err := openCSV(zr, "trips.txt")
 	if err != nil {
 		return err
@@ -364,21 +1164,26 @@ func processTrips(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agenc
 
 		tripID := record[cols["trip_id"]]
 		routeID := record[cols["route_id"]]
+		if skip.skipRoute(routeID) {
+			continue
+		}
 		serviceID := record[cols["service_id"]]
 		headsign := getField(record, cols, "trip_headsign")
 		directionID, _ := strconv.Atoi(getField(record, cols, "direction_id"))
 		shapeID := getField(record, cols, "shape_id")
 		wheelchair := getField(record, cols, "wheelchair_accessible") == "1"
 		bikes := getField(record, cols, "bikes_allowed") == "1"
+		blockID := nilEmpty(getField(record, cols, "block_id"))
 
 		// We need the route's internal UUID. Use a subquery.
 		batch.Queue(`
-			INSERT INTO trips (trip_id, route_id, service_id, headsign, direction_id, shape_id, wheelchair_accessible, bikes_allowed)
-			VALUES ($1, (SELECT id FROM routes WHERE route_id = $2 AND agency_id = $3), $4, $5, $6, $7, $8, $9)
+			INSERT INTO trips (trip_id, route_id, service_id, headsign, direction_id, shape_id, wheelchair_accessible, bikes_allowed, block_id)
+			VALUES ($1, (SELECT id FROM routes WHERE route_id = $2 AND agency_id = $3), $4, $5, $6, $7, $8, $9, $10)
 			ON CONFLICT (route_id, trip_id) DO UPDATE
 			SET service_id = EXCLUDED.service_id, headsign = EXCLUDED.headsign,
-			    direction_id = EXCLUDED.direction_id, shape_id = EXCLUDED.shape_id
-		`, tripID, routeID, agencyID, serviceID, headsign, directionID, shapeID, wheelchair, bikes)
+			    direction_id = EXCLUDED.direction_id, shape_id = EXCLUDED.shape_id,
+			    block_id = EXCLUDED.block_id
+		`, tripID, routeID, agencyID, serviceID, headsign, directionID, shapeID, wheelchair, bikes, blockID)
 
 		count++
 		total++
@@ -406,7 +1211,7 @@ func processTrips(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agenc
 // Stop Times
 // ---------------------------------------------------------------------------
 
-func processStopTimes(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agencyID, slug string) error {
+func processStopTimes(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agencyID, slug string, skip *feedFilter) error {
 	f, err := openCSV(zr, "stop_times.txt")
 	if err != nil {
 		return err
@@ -435,6 +1240,9 @@ func processStopTimes(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, a
 		}
 
 		tripID := record[cols["trip_id"]]
+		if skip.skipTrip(tripID) {
+			continue
+		}
 		stopID := record[cols["stop_id"]]
 		arrivalStr := record[cols["arrival_time"]]
 		departureStr := record[cols["departure_time"]]
@@ -479,6 +1287,100 @@ func processStopTimes(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, a
 	return nil
 }
 
+// ---------------------------------------------------------------------------
+// Frequencies
+// ---------------------------------------------------------------------------
+
+// processFrequencies replaces the agency's frequency-based trips with those
+// of the feed, so trips it no longer runs by frequency go back to their stop
+// times.
+func processFrequencies(ctx context.Context, pool *pgxpool.Pool, zr *zip.Reader, agencyID, slug string, skip *feedFilter) error {
+	if _, err := pool.Exec(ctx, `
+		DELETE FROM frequencies
+		WHERE trip_id IN (SELECT t.id FROM trips t JOIN routes r ON r.id = t.route_id WHERE r.agency_id = $1)
+	`, agencyID); err != nil {
+		return err
+	}
+
+	batch := &pgx.Batch{}
+	total := 0
+	err := forEachRecord(zr, "frequencies.txt", func(record []string, cols map[string]int) {
+		tripID := getField(record, cols, "trip_id")
+		headway, _ := strconv.Atoi(getField(record, cols, "headway_secs"))
+		if tripID == "" || headway <= 0 || skip.skipTrip(tripID) {
+			return
+		}
+		batch.Queue(`
+			INSERT INTO frequencies (trip_id, start_time, end_time, headway_secs, exact_times)
+			SELECT id, $3, $4, $5, $6 FROM trips
+			WHERE trip_id = $1 AND route_id IN (SELECT id FROM routes WHERE agency_id = $2)
+			ON CONFLICT (trip_id, start_time) DO UPDATE
+			SET end_time = EXCLUDED.end_time, headway_secs = EXCLUDED.headway_secs, exact_times = EXCLUDED.exact_times
+		`, tripID, agencyID, parseGTFSTime(getField(record, cols, "start_time")),
+			parseGTFSTime(getField(record, cols, "end_time")), headway,
+			getField(record, cols, "exact_times") == "1")
+		total++
+	})
+	if err != nil {
+		return err
+	}
+	if total > 0 {
+		if err := flushBatch(ctx, pool, batch, total); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("[%s]   frequencies: %d", slug, total)
+	return nil
+}
+
+// linkBlocks points each of the agency's trips at the trip its vehicle
+// continues as: the next trip of its block_id on the same service days,
+// leaving from where it ends (or from the same station) no earlier than it
+// arrives. Trips without a block, or ending their block, link to none.
+func linkBlocks(ctx context.Context, pool *pgxpool.Pool, agencyID, slug string) error {
+	tag, err := pool.Exec(ctx, `
+		WITH ends AS (
+			SELECT t.id, t.block_id, t.service_id,
+			       (array_agg(st.departure_time ORDER BY st.stop_sequence))[1] AS first_dep,
+			       (array_agg(st.stop_id ORDER BY st.stop_sequence))[1] AS first_stop,
+			       (array_agg(st.arrival_time ORDER BY st.stop_sequence DESC))[1] AS last_arr,
+			       (array_agg(st.stop_id ORDER BY st.stop_sequence DESC))[1] AS last_stop
+			FROM trips t
+			JOIN routes r ON r.id = t.route_id
+			JOIN stop_times st ON st.trip_id = t.id
+			WHERE r.agency_id = $1 AND t.block_id IS NOT NULL
+			GROUP BY t.id
+		), ordered AS (
+			SELECT id, last_arr, last_stop,
+			       lead(id) OVER w AS next_id,
+			       lead(first_dep) OVER w AS next_dep,
+			       lead(first_stop) OVER w AS next_stop
+			FROM ends
+			WINDOW w AS (PARTITION BY block_id, service_id ORDER BY first_dep)
+		), links AS (
+			SELECT o.id, o.next_id
+			FROM ordered o
+			LEFT JOIN stops a ON a.id = o.last_stop
+			LEFT JOIN stops b ON b.id = o.next_stop
+			WHERE o.next_id IS NOT NULL AND o.next_dep >= o.last_arr
+			  AND (o.next_stop = o.last_stop OR COALESCE(a.parent_id, a.id) = COALESCE(b.parent_id, b.id))
+		)
+		UPDATE trips t
+		SET next_trip_id = l.next_id
+		FROM trips t2
+		JOIN routes r ON r.id = t2.route_id
+		LEFT JOIN links l ON l.id = t2.id
+		WHERE t.id = t2.id AND r.agency_id = $1
+		  AND t.next_trip_id IS DISTINCT FROM l.next_id
+	`, agencyID)
+	if err != nil {
+		return err
+	}
+	log.Printf("[%s]   block links changed: %d", slug, tag.RowsAffected())
+	return nil
+}
+
 // ---------------------------------------------------------------------------
 // Shapes → route geometry
 // ---------------------------------------------------------------------------
@@ -578,6 +1480,33 @@ func openCSV(zr *zip.Reader, name string) (io.ReadCloser, error) {
 	return nil, fmt.Errorf("file %s not found in zip", name)
 }
 
+// forEachRecord calls fn for each row of a CSV file in the feed.
+func forEachRecord(zr *zip.Reader, name string, fn func(record []string, cols map[string]int)) error {
+	f, err := openCSV(zr, name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.LazyQuotes = true
+	header, err := reader.Read()
+	if err != nil {
+		return err
+	}
+	cols := indexColumns(header)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			continue
+		}
+		fn(record, cols)
+	}
+}
+
 func indexColumns(header []string) map[string]int {
 	m := make(map[string]int, len(header))
 	for i, col := range header {
@@ -603,6 +1532,19 @@ func nilEmpty(s string) interface{} {
 	return s
 }
 
+// parseFlag parses a 0/1 field; anything else is unknown.
+func parseFlag(s string) *bool {
+	var v bool
+	switch s {
+	case "1":
+		v = true
+	case "0":
+	default:
+		return nil
+	}
+	return &v
+}
+
 func flushBatch(ctx context.Context, pool *pgxpool.Pool, batch *pgx.Batch, count int) error {
 	br := pool.SendBatch(ctx, batch)
 	defer br.Close()
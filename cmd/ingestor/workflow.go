@@ -0,0 +1,348 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/worker"
+
+	"github.com/samirrijal/bilbopass/internal/adapters/postgres"
+	"github.com/samirrijal/bilbopass/internal/adapters/valkey"
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+	"github.com/samirrijal/bilbopass/internal/pkg/config"
+	"github.com/samirrijal/bilbopass/internal/workflows"
+)
+
+// ---------------------------------------------------------------------------
+// Feed ingest activities (see workflows.FeedIngestWorkflow)
+// ---------------------------------------------------------------------------
+
+// ingestActivities are the steps of the feed ingest workflow, run by
+// `ingestor worker`. Feeds are passed between them as feed stages.
+type ingestActivities struct {
+	pool           *pgxpool.Pool
+	client         *http.Client
+	serviceChanges *usecases.ServiceChangeService
+	purges         *usecases.CDNPurgeService // nil without a CDN
+	routes         *usecases.RouteService    // nil without a cache to warm
+	versions       ports.StaticVersionRepository
+}
+
+// DownloadFeed downloads the agency's feed and stages it, or holds it for
+// approval when the agency requires it. A feed already kept as applied is
+// not stored again.
+func (a *ingestActivities) DownloadFeed(ctx context.Context, input workflows.FeedIngestInput) (workflows.DownloadedFeed, error) {
+	feed := workflows.DownloadedFeed{Slug: input.Slug}
+	log.Printf("[%s] downloading GTFS from %s", input.Slug, input.GTFSURL)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, input.GTFSURL, nil)
+	if err != nil {
+		return feed, temporal.NewNonRetryableApplicationError(err.Error(), workflows.InvalidFeedError, err)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return feed, fmt.Errorf("download: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return feed, fmt.Errorf("HTTP %d for %s", resp.StatusCode, input.GTFSURL)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return feed, fmt.Errorf("read body: %w", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return feed, temporal.NewNonRetryableApplicationError("open zip: "+err.Error(), workflows.InvalidFeedError, err)
+	}
+
+	feed.AgencyID, err = upsertAgency(ctx, a.pool, AgencyEntry{Name: input.Name, Slug: input.Slug, GTFSURL: input.GTFSURL})
+	if err != nil {
+		return feed, fmt.Errorf("upsert agency: %w", err)
+	}
+	sum := sha256.Sum256(body)
+	feed.Checksum = hex.EncodeToString(sum[:])
+	feed.Held, err = stageFeed(ctx, a.pool, zr, feed.AgencyID, input.Slug, feed.Checksum, body)
+	if err != nil || feed.Held {
+		return feed, err
+	}
+
+	// Ingests of an agency run one at a time: downloads still around were
+	// left by one that did not finish
+	if _, err := a.pool.Exec(ctx, `
+		DELETE FROM feed_stages WHERE agency_id = $1 AND status = 'downloaded'
+	`, feed.AgencyID); err != nil {
+		return feed, err
+	}
+
+	type applied struct{ id, checksum string }
+	var kept []applied
+	rows, err := a.pool.Query(ctx, `
+		SELECT id, sha256 FROM feed_stages
+		WHERE agency_id = $1 AND status = 'applied'
+		ORDER BY applied_at DESC
+	`, feed.AgencyID)
+	if err != nil {
+		return feed, err
+	}
+	for rows.Next() {
+		var s applied
+		if err := rows.Scan(&s.id, &s.checksum); err != nil {
+			rows.Close()
+			return feed, err
+		}
+		kept = append(kept, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return feed, err
+	}
+	if len(kept) > 0 {
+		feed.Previous = kept[0].id
+	}
+	for _, s := range kept {
+		if s.checksum == feed.Checksum {
+			feed.StageID, feed.Reused = s.id, true
+			return feed, nil
+		}
+	}
+
+	stops, routes, trips := countFeed(zr)
+	err = a.pool.QueryRow(ctx, `
+		INSERT INTO feed_stages (agency_id, sha256, feed, stops, routes, trips, status)
+		VALUES ($1, $2, $3, $4, $5, $6, 'downloaded')
+		RETURNING id
+	`, feed.AgencyID, feed.Checksum, body, stops, routes, trips).Scan(&feed.StageID)
+	return feed, err
+}
+
+// ValidateFeed checks that the feed holds the files a schedule cannot do
+// without, and that its stops and routes parse. Invalid feeds fail with
+// the non-retryable type workflows.InvalidFeedError.
+func (a *ingestActivities) ValidateFeed(ctx context.Context, feed workflows.DownloadedFeed) error {
+	zr, _, err := loadStage(ctx, a.pool, feed.StageID)
+	if err != nil {
+		return err
+	}
+	invalid := func(format string, args ...any) error {
+		return temporal.NewNonRetryableApplicationError(fmt.Sprintf(format, args...), workflows.InvalidFeedError, nil)
+	}
+	for _, name := range []string{"stops.txt", "routes.txt", "trips.txt", "stop_times.txt"} {
+		rows := 0
+		if err := forEachRecord(zr, name, func([]string, map[string]int) { rows++ }); err != nil {
+			return invalid("%s: %v", name, err)
+		}
+		if rows == 0 {
+			return invalid("%s has no rows", name)
+		}
+	}
+	snap := buildFeedSnapshot(zr, nil)
+	if len(snap.Stops) == 0 || len(snap.Routes) == 0 {
+		return invalid("feed has no stops or routes with IDs")
+	}
+	log.Printf("[%s] feed valid: %d stops, %d routes", feed.Slug, len(snap.Stops), len(snap.Routes))
+	return nil
+}
+
+// DiscardFeed drops the stage of a feed that will not be applied.
+func (a *ingestActivities) DiscardFeed(ctx context.Context, feed workflows.DownloadedFeed) error {
+	if feed.Reused || feed.StageID == "" {
+		return nil
+	}
+	_, err := a.pool.Exec(ctx, `DELETE FROM feed_stages WHERE id = $1`, feed.StageID)
+	return err
+}
+
+// LoadFeed writes the feed over the agency's schedule and returns the
+// service changes riders are to hear about.
+func (a *ingestActivities) LoadFeed(ctx context.Context, feed workflows.DownloadedFeed) ([]domain.ServiceChange, error) {
+	zr, _, err := loadStage(ctx, a.pool, feed.StageID)
+	if err != nil {
+		return nil, err
+	}
+	skip, err := aliasFilter(ctx, a.pool, zr, feed.AgencyID, feed.Slug)
+	if err != nil {
+		return nil, err
+	}
+	return loadFeed(ctx, a.pool, a.serviceChanges, zr, feed.AgencyID, feed.Slug, skip)
+}
+
+// ActivateFeed records the loaded feed as the agency's current version and
+// marks its stage applied.
+func (a *ingestActivities) ActivateFeed(ctx context.Context, feed workflows.DownloadedFeed) error {
+	zr, body, err := loadStage(ctx, a.pool, feed.StageID)
+	if err != nil {
+		return err
+	}
+	skip, err := aliasFilter(ctx, a.pool, zr, feed.AgencyID, feed.Slug)
+	if err != nil {
+		return err
+	}
+	return activateFeed(ctx, a.pool, zr, feed.AgencyID, feed.Slug, feed.Checksum, body, feed.StageID, skip)
+}
+
+// RollbackFeed drops a feed that failed to load and applies again the one
+// that was live before it.
+func (a *ingestActivities) RollbackFeed(ctx context.Context, feed workflows.DownloadedFeed) error {
+	if err := a.DiscardFeed(ctx, feed); err != nil {
+		return fmt.Errorf("discard feed: %w", err)
+	}
+	if feed.Previous == "" {
+		log.Printf("[%s] no previous feed to roll back to", feed.Slug)
+		return nil
+	}
+	zr, body, err := loadStage(ctx, a.pool, feed.Previous)
+	if err != nil {
+		return err
+	}
+	var checksum string
+	if err := a.pool.QueryRow(ctx, `SELECT sha256 FROM feed_stages WHERE id = $1`, feed.Previous).Scan(&checksum); err != nil {
+		return err
+	}
+	log.Printf("[%s] rolling back to feed %s", feed.Slug, feed.Previous)
+	skip, err := aliasFilter(ctx, a.pool, zr, feed.AgencyID, feed.Slug)
+	if err != nil {
+		return err
+	}
+	// Its service changes were told when it was first applied
+	if _, err := loadFeed(ctx, a.pool, a.serviceChanges, zr, feed.AgencyID, feed.Slug, skip); err != nil {
+		return err
+	}
+	return activateFeed(ctx, a.pool, zr, feed.AgencyID, feed.Slug, checksum, body, feed.Previous, skip)
+}
+
+// InvalidateCaches purges from the CDN what the agency's feed changed
+// since the ingest started.
+func (a *ingestActivities) InvalidateCaches(ctx context.Context, slug string, since time.Time) error {
+	if a.purges == nil {
+		return nil
+	}
+	keys, err := a.purges.PurgeChanges(ctx, slug, since, time.Now())
+	if err != nil {
+		return err
+	}
+	log.Printf("[%s] cdn purged %d keys", slug, len(keys))
+	return nil
+}
+
+// WarmCaches caches the agency's routes under the new static data version,
+// so the API's first requests after the feed do not all reach the database.
+func (a *ingestActivities) WarmCaches(ctx context.Context, agencyID string) error {
+	if a.routes == nil {
+		return nil
+	}
+	version, err := a.versions.StaticVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("static version: %w", err)
+	}
+	a.routes.Invalidate(version)
+	routes, err := a.routes.ListByAgency(ctx, agencyID)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, r := range routes {
+		if _, err := a.routes.GetByID(ctx, r.ID); err != nil {
+			errs = append(errs, fmt.Errorf("route %s: %w", r.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NotifyServiceChanges pushes riders a summary of the changes to their
+// favorite stops and routes.
+func (a *ingestActivities) NotifyServiceChanges(ctx context.Context, slug string, changes []domain.ServiceChange) error {
+	sent, err := a.serviceChanges.Notify(ctx, changes)
+	log.Printf("[%s] service changes: %d, notifications sent: %d", slug, len(changes), sent)
+	return err
+}
+
+// runWorker runs the feed ingest activities on the ingest task queue until
+// interrupted. Routes are warmed in the cache the API reads.
+func runWorker(cfg *config.Config, pool *pgxpool.Pool, serviceChanges *usecases.ServiceChangeService, purges *usecases.CDNPurgeService) error {
+	c, err := client.Dial(client.Options{HostPort: cfg.Temporal.HostPort})
+	if err != nil {
+		return fmt.Errorf("temporal client: %w", err)
+	}
+	defer c.Close()
+
+	db := &postgres.DB{Pool: pool}
+	activities := &ingestActivities{
+		pool:           pool,
+		client:         &http.Client{Timeout: 120 * time.Second},
+		serviceChanges: serviceChanges,
+		purges:         purges,
+		versions:       postgres.NewStaticVersionRepo(db),
+	}
+	if cache, err := valkey.New(cfg.Valkey.Addr); err != nil {
+		log.Printf("valkey unavailable, caches will not be warmed: %v", err)
+	} else {
+		defer cache.Close()
+		activities.routes = usecases.NewRouteService(postgres.NewRouteRepo(db), nil, cache)
+	}
+
+	w := worker.New(c, cfg.Temporal.IngestTaskQueue, worker.Options{})
+	w.RegisterActivity(activities)
+	log.Println("ingest worker started")
+	return w.Run(worker.InterruptCh())
+}
+
+// startIngests starts a feed ingest workflow for each of the agencies the
+// filter keeps, all of them when it is empty.
+func startIngests(ctx context.Context, cfg *config.Config, agencies []AgencyEntry, slugFilter map[string]bool) error {
+	c, err := client.Dial(client.Options{HostPort: cfg.Temporal.HostPort})
+	if err != nil {
+		return fmt.Errorf("temporal client: %w", err)
+	}
+	defer c.Close()
+
+	starter := workflows.NewStarter(c, cfg.Temporal.TaskQueue)
+	for _, a := range agencies {
+		if len(slugFilter) > 0 && !slugFilter[a.Slug] {
+			continue
+		}
+		err := starter.StartFeedIngest(ctx, workflows.FeedIngestInput{
+			Name:          a.Name,
+			Slug:          a.Slug,
+			GTFSURL:       a.GTFSURL,
+			ActivityQueue: cfg.Temporal.IngestTaskQueue,
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", a.Slug, err)
+		}
+		log.Printf("[%s] ingest started as %s", a.Slug, workflows.FeedIngestWorkflowID(a.Slug))
+	}
+	return nil
+}
+
+// loadStage opens the feed a stage holds.
+func loadStage(ctx context.Context, pool *pgxpool.Pool, stageID string) (*zip.Reader, []byte, error) {
+	var body []byte
+	err := pool.QueryRow(ctx, `SELECT feed FROM feed_stages WHERE id = $1`, stageID).Scan(&body)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, temporal.NewNonRetryableApplicationError("feed stage "+stageID+" not found", workflows.InvalidFeedError, err)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("load feed stage %s: %w", stageID, err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, nil, temporal.NewNonRetryableApplicationError("open zip: "+err.Error(), workflows.InvalidFeedError, err)
+	}
+	return zr, body, nil
+}
@@ -5,15 +5,37 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/samirrijal/bilbopass/internal/pkg/config"
+	"github.com/samirrijal/bilbopass/migrations"
 )
 
+const usage = `usage: migrate <command>
+
+  up [version]      apply pending migrations, up to version if given
+  down [version]    revert the latest migration, or those above version
+  redo              revert and re-apply the latest migration
+  status            list migrations and whether they are applied
+  baseline version  record migrations up to version as applied, without
+                    running them, for databases migrated before
+  policies          apply the configured TimescaleDB policies
+  seed              load the Bilbao fixture dataset, for development and
+                    integration tests`
+
 func main() {
 	if len(os.Args) < 2 {
-		log.Fatal("usage: migrate <up|down>")
+		log.Fatal(usage)
+	}
+	version := 0
+	if len(os.Args) > 2 {
+		v, err := strconv.Atoi(os.Args[2])
+		if err != nil || v < 0 {
+			log.Fatalf("invalid version %q\n%s", os.Args[2], usage)
+		}
+		version = v
 	}
 
 	cfg, err := config.Load("bilbopass-migrate")
@@ -28,35 +50,113 @@ func main() {
 	}
 	defer pool.Close()
 
-	switch os.Args[1] {
-	case "up":
-		runMigrations(ctx, pool)
-	case "down":
-		log.Println("down migration not yet implemented")
-	default:
-		log.Fatalf("unknown command: %s", os.Args[1])
+	if os.Args[1] == "policies" {
+		applyPolicies(ctx, pool, cfg.Timescale)
+		return
 	}
-}
 
-func runMigrations(ctx context.Context, pool *pgxpool.Pool) {
-	files := []string{
-		"migrations/001_init_extensions.sql",
-		"migrations/002_core_tables.sql",
+	all, err := loadMigrations(migrations.FS)
+	if err != nil {
+		log.Fatalf("migrations: %v", err)
 	}
+	m, err := newMigrator(ctx, pool, all)
+	if err != nil {
+		log.Fatalf("migrate: %v", err)
+	}
+	defer m.close(ctx)
 
-	for _, f := range files {
-		data, err := os.ReadFile(f)
+	switch os.Args[1] {
+	case "up":
+		n, err := m.up(ctx, version)
 		if err != nil {
-			log.Fatalf("read %s: %v", f, err)
+			log.Fatalf("up: %v", err)
 		}
-
-		_, err = pool.Exec(ctx, string(data))
+		log.Printf("%d migrations applied", n)
+		// Policies follow the config rather than the migrations
+		if version == 0 {
+			applyPolicies(ctx, pool, cfg.Timescale)
+		}
+	case "down":
+		if len(os.Args) < 3 {
+			if _, version, err = m.latest(ctx); err != nil {
+				log.Fatalf("down: %v", err)
+			}
+		}
+		n, err := m.down(ctx, version)
 		if err != nil {
-			log.Fatalf("exec %s: %v", f, err)
+			log.Fatalf("down: %v", err)
 		}
+		log.Printf("%d migrations reverted", n)
+	case "redo":
+		latest, previous, err := m.latest(ctx)
+		if err != nil {
+			log.Fatalf("redo: %v", err)
+		}
+		if latest == 0 {
+			log.Fatal("redo: no migration applied")
+		}
+		if _, err := m.down(ctx, previous); err != nil {
+			log.Fatalf("redo: %v", err)
+		}
+		if _, err := m.up(ctx, latest); err != nil {
+			log.Fatalf("redo: %v", err)
+		}
+	case "status":
+		if err := m.status(ctx); err != nil {
+			log.Fatalf("status: %v", err)
+		}
+	case "baseline":
+		if len(os.Args) < 3 {
+			log.Fatal(usage)
+		}
+		n, err := m.baseline(ctx, version)
+		if err != nil {
+			log.Fatalf("baseline: %v", err)
+		}
+		log.Printf("%d migrations recorded as applied", n)
+	case "seed":
+		n, err := m.seed(ctx, migrations.FS)
+		if err != nil {
+			log.Fatalf("seed: %v", err)
+		}
+		log.Printf("%d fixtures loaded", n)
+	default:
+		log.Fatalf("unknown command: %s\n%s", os.Args[1], usage)
+	}
+}
 
-		fmt.Printf("OK  %s\n", f)
+// applyPolicies replaces the compression and retention policies of
+// vehicle_positions and its hourly vehicle counts with the configured
+// ones, all at once.
+func applyPolicies(ctx context.Context, pool *pgxpool.Pool, p config.TimescaleConfig) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Fatalf("begin: %v", err)
 	}
+	defer tx.Rollback(ctx)
 
-	log.Println("all migrations applied")
+	policies := []struct {
+		name string
+		sql  string
+		days int
+	}{
+		{"vehicle_positions compression", `
+			SELECT remove_compression_policy('vehicle_positions', if_exists => true),
+			       add_compression_policy('vehicle_positions', make_interval(days => $1::int))`, p.CompressAfterDays},
+		{"vehicle_positions retention", `
+			SELECT remove_retention_policy('vehicle_positions', if_exists => true),
+			       add_retention_policy('vehicle_positions', make_interval(days => $1::int))`, p.RetainDays},
+		{"vehicle_counts_hourly retention", `
+			SELECT remove_retention_policy('vehicle_counts_hourly', if_exists => true),
+			       add_retention_policy('vehicle_counts_hourly', make_interval(days => $1::int))`, p.AggregateRetainDays},
+	}
+	for _, policy := range policies {
+		if _, err := tx.Exec(ctx, policy.sql, policy.days); err != nil {
+			log.Fatalf("%s: %v", policy.name, err)
+		}
+		fmt.Printf("OK  %s after %d days\n", policy.name, policy.days)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Fatalf("commit: %v", err)
+	}
 }
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migrateLock is the advisory lock held while migrating, so that two
+// deploys do not apply the same migrations at once.
+const migrateLock = 0x62696c626f // "bilbo"
+
+// migrationName matches the file names of migrations; the number is
+// their version.
+var migrationName = regexp.MustCompile(`^(\d+)_[a-z0-9_]+\.sql$`)
+
+// migration is an up migration and its down migration, empty when it has
+// none.
+type migration struct {
+	version  int
+	name     string
+	up       string
+	down     string
+	checksum string // of up
+}
+
+// appliedMigration is a row of schema_migrations.
+type appliedMigration struct {
+	version   int
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// loadMigrations reads the migrations of fsys in version order. Down
+// migrations are under down/, named after the migration they revert.
+func loadMigrations(fsys fs.FS) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	var out []migration
+	seen := map[int]string{}
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".sql" {
+			continue
+		}
+		match := migrationName.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%s: migrations must be named NNN_name.sql", e.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("%s and %s have the same version", other, e.Name())
+		}
+		seen[version] = e.Name()
+
+		up, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		down, err := fs.ReadFile(fsys, path.Join("down", e.Name()))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		sum := sha256.Sum256(up)
+		out = append(out, migration{
+			version:  version,
+			name:     e.Name(),
+			up:       string(up),
+			down:     string(down),
+			checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+
+	downs, err := fs.ReadDir(fsys, "down")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for _, e := range downs {
+		if match := migrationName.FindStringSubmatch(e.Name()); match == nil || seen[atoi(match[1])] != e.Name() {
+			return nil, fmt.Errorf("down/%s reverts no migration", e.Name())
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].version < out[j].version })
+	return out, nil
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// migrator applies and reverts migrations over one connection, which holds
+// migrateLock.
+type migrator struct {
+	conn       *pgxpool.Conn
+	migrations []migration
+}
+
+// newMigrator takes migrateLock, waiting for other migrators to finish, and
+// creates schema_migrations on first use. close releases both.
+func newMigrator(ctx context.Context, pool *pgxpool.Pool, migrations []migration) (*migrator, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrateLock); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("lock: %w", err)
+	}
+	m := &migrator{conn: conn, migrations: migrations}
+	if _, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,              -- sha256 of the up migration
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)
+	`); err != nil {
+		m.close(ctx)
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *migrator) close(ctx context.Context) {
+	_, _ = m.conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, migrateLock)
+	m.conn.Release()
+}
+
+// applied returns the applied migrations, in version order.
+func (m *migrator) applied(ctx context.Context) ([]appliedMigration, error) {
+	rows, err := m.conn.Query(ctx, `
+		SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []appliedMigration
+	for rows.Next() {
+		var a appliedMigration
+		if err := rows.Scan(&a.version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+func (m *migrator) find(version int) *migration {
+	for i := range m.migrations {
+		if m.migrations[i].version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// verify checks that the applied migrations are those of this binary, as
+// they were when applied.
+func (m *migrator) verify(applied []appliedMigration) error {
+	var errs []error
+	for _, a := range applied {
+		switch mig := m.find(a.version); {
+		case mig == nil:
+			errs = append(errs, fmt.Errorf("%s was applied but is not in this binary", a.name))
+		case mig.checksum != a.checksum:
+			errs = append(errs, fmt.Errorf("%s changed since it was applied", mig.name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// up applies the pending migrations up to version target, all of them when
+// it is 0, each in a transaction of its own.
+func (m *migrator) up(ctx context.Context, target int) (int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := m.verify(applied); err != nil {
+		return 0, err
+	}
+	done := map[int]bool{}
+	for _, a := range applied {
+		done[a.version] = true
+	}
+	n := 0
+	for _, mig := range m.migrations {
+		if done[mig.version] || target > 0 && mig.version > target {
+			continue
+		}
+		err := pgx.BeginFunc(ctx, m.conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, mig.up); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `
+				INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)
+			`, mig.version, mig.name, mig.checksum)
+			return err
+		})
+		if err != nil {
+			return n, fmt.Errorf("%s: %w", mig.name, err)
+		}
+		fmt.Printf("UP    %s\n", mig.name)
+		n++
+	}
+	return n, nil
+}
+
+// down reverts the applied migrations above version target, latest first,
+// each in a transaction of its own. Nothing is reverted unless every one
+// of them has a down migration.
+func (m *migrator) down(ctx context.Context, target int) (int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := m.verify(applied); err != nil {
+		return 0, err
+	}
+	var revert []*migration
+	for i := len(applied) - 1; i >= 0 && applied[i].version > target; i-- {
+		mig := m.find(applied[i].version)
+		if strings.TrimSpace(mig.down) == "" {
+			return 0, fmt.Errorf("%s has no down migration", mig.name)
+		}
+		revert = append(revert, mig)
+	}
+	for n, mig := range revert {
+		err := pgx.BeginFunc(ctx, m.conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, mig.down); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mig.version)
+			return err
+		})
+		if err != nil {
+			return n, fmt.Errorf("down/%s: %w", mig.name, err)
+		}
+		fmt.Printf("DOWN  %s\n", mig.name)
+	}
+	return len(revert), nil
+}
+
+// latest returns the version of the latest applied migration, and the one
+// applied before it; 0 when there is none.
+func (m *migrator) latest(ctx context.Context) (latest, previous int, err error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	if n := len(applied); n > 0 {
+		latest = applied[n-1].version
+		if n > 1 {
+			previous = applied[n-2].version
+		}
+	}
+	return latest, previous, nil
+}
+
+// status prints each migration with when it was applied, and the applied
+// migrations that changed since or are missing from this binary.
+func (m *migrator) status(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	byVersion := map[int]appliedMigration{}
+	for _, a := range applied {
+		byVersion[a.version] = a
+		if m.find(a.version) == nil {
+			fmt.Printf("MISSING  %-40s applied %s\n", a.name, a.appliedAt.Format(time.RFC3339))
+		}
+	}
+	for _, mig := range m.migrations {
+		a, ok := byVersion[mig.version]
+		switch {
+		case !ok:
+			fmt.Printf("PENDING  %s\n", mig.name)
+		case a.checksum != mig.checksum:
+			fmt.Printf("CHANGED  %-40s applied %s\n", mig.name, a.appliedAt.Format(time.RFC3339))
+		default:
+			fmt.Printf("APPLIED  %-40s applied %s\n", mig.name, a.appliedAt.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// baseline records the migrations up to version target as applied without
+// running them, for databases migrated before schema_migrations, e.g. by
+// psql. It refuses databases that already record migrations.
+func (m *migrator) baseline(ctx context.Context, target int) (int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(applied) > 0 {
+		return 0, fmt.Errorf("schema_migrations already records %d migrations", len(applied))
+	}
+	n := 0
+	err = pgx.BeginFunc(ctx, m.conn, func(tx pgx.Tx) error {
+		for _, mig := range m.migrations {
+			if mig.version > target {
+				break
+			}
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)
+			`, mig.version, mig.name, mig.checksum); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// seed loads the fixtures of fsys, seed/*.sql in name order, in one
+// transaction. They are written against the latest schema, so no migration
+// may be pending.
+func (m *migrator) seed(ctx context.Context, fsys fs.FS) (int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := m.verify(applied); err != nil {
+		return 0, err
+	}
+	if pending := len(m.migrations) - len(applied); pending > 0 {
+		return 0, fmt.Errorf("%d migrations pending, run migrate up first", pending)
+	}
+	names, err := fs.Glob(fsys, "seed/*.sql")
+	if err != nil {
+		return 0, err
+	}
+	err = pgx.BeginFunc(ctx, m.conn, func(tx pgx.Tx) error {
+		for _, name := range names {
+			sql, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, string(sql)); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			fmt.Printf("SEED  %s\n", path.Base(name))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(names), nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/samirrijal/bilbopass/migrations"
+)
+
+func sqlFile(sql string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(sql)} }
+
+func checksum(sql string) string {
+	sum := sha256.Sum256([]byte(sql))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"010_stops.sql":         sqlFile("CREATE TABLE stops ();"),
+		"002_agencies.sql":      sqlFile("CREATE TABLE agencies ();"),
+		"001_extensions.sql":    sqlFile("CREATE EXTENSION postgis;"),
+		"down/002_agencies.sql": sqlFile("DROP TABLE agencies;"),
+		"down/010_stops.sql":    sqlFile("DROP TABLE stops;"),
+		"migrations.go":         sqlFile("package migrations"),
+		"seed/01_bilbao.sql":    sqlFile("INSERT INTO agencies DEFAULT VALUES;"),
+	}
+
+	got, err := loadMigrations(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []migration{
+		{version: 1, name: "001_extensions.sql", up: "CREATE EXTENSION postgis;"},
+		{version: 2, name: "002_agencies.sql", up: "CREATE TABLE agencies ();", down: "DROP TABLE agencies;"},
+		{version: 10, name: "010_stops.sql", up: "CREATE TABLE stops ();", down: "DROP TABLE stops;"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d migrations, got %d", len(want), len(got))
+	}
+	for i, w := range want {
+		w.checksum = checksum(w.up)
+		if got[i] != w {
+			t.Errorf("migration %d: expected %+v, got %+v", i, w, got[i])
+		}
+	}
+}
+
+func TestLoadMigrations_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		fsys fstest.MapFS
+		want string
+	}{
+		{
+			name: "unnumbered",
+			fsys: fstest.MapFS{"agencies.sql": sqlFile("")},
+			want: "agencies.sql: migrations must be named NNN_name.sql",
+		},
+		{
+			name: "upper case",
+			fsys: fstest.MapFS{"001_Agencies.sql": sqlFile("")},
+			want: "001_Agencies.sql: migrations must be named NNN_name.sql",
+		},
+		{
+			name: "same version",
+			fsys: fstest.MapFS{"002_agencies.sql": sqlFile(""), "02_stops.sql": sqlFile("")},
+			want: "have the same version",
+		},
+		{
+			name: "down without an up",
+			fsys: fstest.MapFS{"001_agencies.sql": sqlFile(""), "down/002_stops.sql": sqlFile("")},
+			want: "down/002_stops.sql reverts no migration",
+		},
+		{
+			name: "down named differently",
+			fsys: fstest.MapFS{"001_agencies.sql": sqlFile(""), "down/001_agency.sql": sqlFile("")},
+			want: "down/001_agency.sql reverts no migration",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := loadMigrations(tc.fsys)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	all, err := loadMigrations(fstest.MapFS{
+		"001_extensions.sql": sqlFile("CREATE EXTENSION postgis;"),
+		"002_agencies.sql":   sqlFile("CREATE TABLE agencies ();"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &migrator{migrations: all}
+	applied := func(version int, name, sql string) appliedMigration {
+		return appliedMigration{version: version, name: name, checksum: checksum(sql)}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		applied []appliedMigration
+		want    []string
+	}{
+		{name: "none applied"},
+		{
+			name:    "applied as they are",
+			applied: []appliedMigration{applied(1, "001_extensions.sql", "CREATE EXTENSION postgis;")},
+		},
+		{
+			name: "changed since applied",
+			applied: []appliedMigration{
+				applied(1, "001_extensions.sql", "CREATE EXTENSION postgis;"),
+				applied(2, "002_agencies.sql", "CREATE TABLE agencies (id TEXT);"),
+			},
+			want: []string{"002_agencies.sql changed since it was applied"},
+		},
+		{
+			name: "changed and missing",
+			applied: []appliedMigration{
+				applied(1, "001_extensions.sql", "CREATE EXTENSION timescaledb;"),
+				applied(3, "003_stops.sql", "CREATE TABLE stops ();"),
+			},
+			want: []string{
+				"001_extensions.sql changed since it was applied",
+				"003_stops.sql was applied but is not in this binary",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := m.verify(tc.applied)
+			if len(tc.want) == 0 {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != strings.Join(tc.want, "\n") {
+				t.Errorf("expected %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	all, err := loadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, mig := range all {
+		if mig.version != i+1 {
+			t.Fatalf("expected %s to be version %d: versions have a gap", mig.name, i+1)
+		}
+	}
+
+	// Continuous aggregates are created WITH NO DATA, so each needs a
+	// refresh policy to be filled at all.
+	aggregate := regexp.MustCompile(`(?i)CREATE MATERIALIZED VIEW (\w+)\s+WITH \(timescaledb\.continuous\)`)
+	var up strings.Builder
+	for _, mig := range all {
+		up.WriteString(mig.up)
+	}
+	for _, match := range aggregate.FindAllStringSubmatch(up.String(), -1) {
+		if !strings.Contains(up.String(), "add_continuous_aggregate_policy('"+match[1]+"'") {
+			t.Errorf("continuous aggregate %s has no refresh policy", match[1])
+		}
+	}
+}
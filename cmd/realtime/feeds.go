@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// The agencies and their feed URLs are managed with /v1/admin/agencies.
+// They are reloaded every feedReloadInterval, so an agency onboarded or
+// changed there is polled without a restart.
+
+const feedReloadInterval = time.Minute
+
+// feedLoop is the polling loop of one feed.
+type feedLoop struct {
+	url      string
+	interval time.Duration
+	cancel   context.CancelFunc
+}
+
+// runFeeds polls the feeds of the agencies in the database until ctx is
+// done, reloading them every feedReloadInterval. When a reload fails the
+// feeds loaded before are kept.
+func (p *poller) runFeeds(ctx context.Context, feeds ports.AgencyFeedsRepository) {
+	ticker := time.NewTicker(feedReloadInterval)
+	defer ticker.Stop()
+	for {
+		agencies, err := feeds.List(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("load agency feeds: %v", err)
+		} else if err == nil {
+			p.syncFeeds(ctx, agencies)
+		}
+
+		select {
+		case <-ctx.Done():
+			p.loops.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncFeeds starts a polling loop for each GTFS-RT feed of the agencies
+// that has none, restarts those whose URL or interval changed and stops
+// those of feeds no longer listed. It also replaces the agencies pushing
+// their vehicle positions.
+func (p *poller) syncFeeds(ctx context.Context, agencies []domain.AgencyFeeds) {
+	listed := map[string]bool{}
+	pushing := map[string]string{} // slug -> UUID
+	started, stopped := 0, 0
+	for _, a := range agencies {
+		if a.GTFSRT == nil {
+			continue
+		}
+		if a.GTFSRT.VehiclePush {
+			pushing[a.Slug] = a.AgencyID
+		}
+		interval := pollInterval(a.GTFSRT)
+		for _, f := range []struct {
+			kind, url string
+			poll      func(context.Context, domain.AgencyFeeds, string, *usecases.IDMapper) error
+		}{
+			{"vehicle_positions", a.GTFSRT.VehiclePositions, p.pollVehiclePositions},
+			{"trip_updates", a.GTFSRT.TripUpdates, p.pollTripUpdates},
+			{"alerts", a.GTFSRT.Alerts, p.pollAlerts},
+		} {
+			if f.url == "" {
+				continue
+			}
+			key := a.Slug + " " + f.kind
+			listed[key] = true
+			if loop, ok := p.feedLoops[key]; ok {
+				if loop.url == f.url && loop.interval == interval {
+					continue
+				}
+				loop.cancel()
+				stopped++
+			}
+
+			loopCtx, cancel := context.WithCancel(ctx)
+			p.feedLoops[key] = &feedLoop{url: f.url, interval: interval, cancel: cancel}
+			started++
+			p.loops.Add(1)
+			go func() {
+				defer p.loops.Done()
+				p.runFeed(loopCtx, a.Slug, f.kind, interval, func(ctx context.Context) error {
+					return f.poll(ctx, a, a.AgencyID, p.idMapper(ctx, a.AgencyID))
+				})
+			}()
+		}
+	}
+	for key, loop := range p.feedLoops {
+		if !listed[key] {
+			loop.cancel()
+			delete(p.feedLoops, key)
+			stopped++
+		}
+	}
+
+	p.pushMu.Lock()
+	p.pushAgencies = pushing
+	p.pushMu.Unlock()
+
+	if started > 0 || stopped > 0 {
+		log.Printf("polling %d feeds (%d started, %d stopped), %d agencies pushing positions",
+			len(p.feedLoops), started, stopped, len(pushing))
+	}
+}
+
+// pushAgency returns the UUID of the agency slug if it pushes its vehicle
+// positions.
+func (p *poller) pushAgency(slug string) (string, bool) {
+	p.pushMu.RLock()
+	defer p.pushMu.RUnlock()
+	id, ok := p.pushAgencies[slug]
+	return id, ok
+}
@@ -3,47 +3,34 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
 	"sync"
 	"syscall"
 	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/nats-io/nats.go"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/samirrijal/bilbopass/internal/adapters/cdn"
+	natsadapter "github.com/samirrijal/bilbopass/internal/adapters/nats"
+	"github.com/samirrijal/bilbopass/internal/adapters/notifications"
+	"github.com/samirrijal/bilbopass/internal/adapters/postgres"
 	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
 	"github.com/samirrijal/bilbopass/internal/gtfsrt"
 	"github.com/samirrijal/bilbopass/internal/pkg/config"
 )
 
-// ---------------------------------------------------------------------------
-// Manifest types (same as ingestor)
-// ---------------------------------------------------------------------------
-
-type Manifest struct {
-	Source   string        `json:"source"`
-	Agencies []AgencyEntry `json:"agencies"`
-}
-
-type AgencyEntry struct {
-	Name    string       `json:"name"`
-	Slug    string       `json:"slug"`
-	GTFSURL string       `json:"gtfs_url"`
-	GTFSRT  *GTFSRTEntry `json:"gtfs_rt,omitempty"`
-}
-
-type GTFSRTEntry struct {
-	VehiclePositions string `json:"vehicle_positions,omitempty"`
-	TripUpdates      string `json:"trip_updates,omitempty"`
-	Alerts           string `json:"alerts,omitempty"`
-}
+// delayThreshold is the delay (seconds) from which a stop time update counts
+// as a significant delay.
+const delayThreshold = 180
 
 // ---------------------------------------------------------------------------
 // Main
@@ -59,149 +46,198 @@ func main() {
 	defer cancel()
 
 	// Database
-	pool, err := pgxpool.New(ctx, cfg.Database.DSN())
+	db, err := postgres.New(ctx, cfg.Database.DSN())
 	if err != nil {
 		log.Fatalf("db: %v", err)
 	}
-	defer pool.Close()
+	defer db.Close()
 
-	// NATS
-	nc, err := nats.Connect(cfg.NATS.URL,
-		nats.RetryOnFailedConnect(true),
-		nats.MaxReconnects(-1),
-		nats.ReconnectWait(2*time.Second),
-	)
+	// NATS: positions and delay events go through the event publisher; delay
+	// and alert broadcasts for WebSocket clients are published directly.
+	natsOpts, err := cfg.NATS.Options()
 	if err != nil {
 		log.Fatalf("nats: %v", err)
 	}
-	defer nc.Drain()
-
-	// Load manifest
-	manifestPath := "manifest.json"
-	if len(os.Args) > 1 {
-		manifestPath = os.Args[1]
-	}
-
-	data, err := os.ReadFile(manifestPath)
+	publisher, err := natsadapter.NewPublisher(cfg.NATS.URL, natsOpts...)
 	if err != nil {
-		log.Fatalf("read manifest: %v", err)
+		log.Fatalf("nats: %v", err)
 	}
-
-	var manifest Manifest
-	if err := json.Unmarshal(data, &manifest); err != nil {
-		log.Fatalf("parse manifest: %v", err)
+	defer publisher.Close()
+	nc, err := natsadapter.RawConn(cfg.NATS.URL, natsOpts...)
+	if err != nil {
+		log.Fatalf("nats: %v", err)
 	}
+	defer nc.Drain()
 
-	// Filter to agencies that have GTFS-RT feeds
-	var rtAgencies []AgencyEntry
-	for _, a := range manifest.Agencies {
-		if a.GTFSRT != nil {
-			rtAgencies = append(rtAgencies, a)
-		}
+	// Repos
+	agencyRepo := postgres.NewAgencyRepo(db)
+	stopRepo := postgres.NewStopRepo(db)
+	routeRepo := postgres.NewRouteRepo(db)
+	tripRepo := postgres.NewTripRepo(db)
+	vehicleRepo := postgres.NewVehiclePositionRepo(db)
+	feedConfigRepo := postgres.NewFeedConfigRepo(db)
+	deviceRepo := postgres.NewDeviceRepo(db)
+	alertSubRepo := postgres.NewAlertSubscriptionRepo(db)
+	feedStatusRepo := postgres.NewRealtimeFeedStatusRepo(db)
+
+	// Delay alert subscriptions, pushed as delays are detected
+	pusher, err := notifications.New(deviceRepo, postgres.NewNotificationPreferenceRepo(db), cfg.Push.FCMCredentialsFile,
+		cfg.Push.VAPIDPublicKey, cfg.Push.VAPIDPrivateKey, cfg.Push.VAPIDSubject)
+	if err != nil {
+		log.Fatalf("push: %v", err)
 	}
 
-	log.Printf("BilboPass Realtime Poller — %d agencies with GTFS-RT feeds", len(rtAgencies))
+	// Use cases
+	realtimeSvc := usecases.NewRealtimeService(vehicleRepo, tripRepo, publisher)
+	delayAlerts := usecases.NewAlertSubscriptionService(alertSubRepo, stopRepo, routeRepo, pusher)
 
-	// Preload agency UUID map
-	agencyIDs := make(map[string]string) // slug -> UUID
-	for _, a := range rtAgencies {
-		var id string
-		err := pool.QueryRow(ctx, `SELECT id FROM agencies WHERE slug = $1`, a.Slug).Scan(&id)
+	// The CDN in front of the API drops the stops and routes alerts change
+	var purges *usecases.CDNPurgeService
+	if cfg.CDN.PurgeURL != "" {
+		purger, err := cdn.New(cfg.CDN.Provider, cfg.CDN.PurgeURL, cfg.CDN.Token)
 		if err != nil {
-			log.Printf("WARNING: agency %s not found in DB (run ingestor first): %v", a.Slug, err)
-			continue
+			log.Fatalf("cdn: %v", err)
 		}
-		agencyIDs[a.Slug] = id
+		purges = usecases.NewCDNPurgeService(purger, postgres.NewSyncRepo(db), agencyRepo)
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	pollInterval := 30 * time.Second
+	p := &poller{
+		nc:          nc,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		sem:         make(chan struct{}, 8), // max 8 concurrent fetches
+		delayEvents: usecases.NewDelayEventService(postgres.NewDelayObservationRepo(db), publisher),
+		tripUpdates: usecases.NewTripUpdateService(tripRepo, postgres.NewTripUpdateRepo(db)),
+		feedAlerts:  usecases.NewFeedAlertService(tripRepo, postgres.NewAlertRepo(db)),
+		feedConfigs: feedConfigRepo,
+		feedStatus:  feedStatusRepo,
+		seenIDs:     postgres.NewFeedQualityRepo(db),
+		realtime:    realtimeSvc,
+		delayAlerts: delayAlerts,
+		purges:      purges,
+		pushKeys:    usecases.NewPushKeyService(postgres.NewPushKeyRepo(db), agencyRepo),
+		feedLoops:   map[string]*feedLoop{},
+	}
+	log.Println("BilboPass Realtime Poller")
 
-	// Start polling loop
-	ticker := time.NewTicker(pollInterval)
-	defer ticker.Stop()
+	// Agencies pushing their vehicle positions instead
+	sub, err := p.receivePushes(ctx)
+	if err != nil {
+		log.Fatalf("subscribe to pushed positions: %v", err)
+	}
+	defer sub.Unsubscribe()
 
-	log.Printf("polling every %s", pollInterval)
+	// One polling loop per feed
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.runFeeds(ctx, postgres.NewAgencyFeedsRepo(db))
+	}()
 
 	// Signal handling
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-
-	// Run once immediately
-	pollAll(ctx, pool, nc, client, rtAgencies, agencyIDs)
-
-	for {
-		select {
-		case <-ticker.C:
-			pollAll(ctx, pool, nc, client, rtAgencies, agencyIDs)
-		case <-ctx.Done():
-			return
-		case sig := <-quit:
-			log.Printf("received signal %v, shutting down realtime poller", sig)
-			cancel()
-			// Give in-flight polls time to finish
-			time.Sleep(2 * time.Second)
-			return
-		}
-	}
+	sig := <-quit
+	log.Printf("received signal %v, shutting down realtime poller", sig)
+	cancel()
+	<-done
 }
 
 // ---------------------------------------------------------------------------
-// Poll all agencies
+// Poll feeds
 // ---------------------------------------------------------------------------
 
-func pollAll(ctx context.Context, pool *pgxpool.Pool, nc *nats.Conn, client *http.Client, agencies []AgencyEntry, agencyIDs map[string]string) {
-	var wg sync.WaitGroup
-	sem := make(chan struct{}, 8) // max 8 concurrent fetches
-
-	for _, a := range agencies {
-		agencyID, ok := agencyIDs[a.Slug]
-		if !ok {
-			continue
-		}
-
-		wg.Add(1)
-		go func(agency AgencyEntry, aID string) {
-			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
-
-			if agency.GTFSRT.VehiclePositions != "" {
-				if err := pollVehiclePositions(ctx, pool, nc, client, agency, aID); err != nil {
-					log.Printf("[%s] vehicle_positions: %v", agency.Slug, err)
-				}
-			}
+// natsConn is the part of the NATS connection the poller uses.
+type natsConn interface {
+	Publish(subject string, data []byte) error
+	QueueSubscribe(subject, queue string, cb nats.MsgHandler) (*nats.Subscription, error)
+}
 
-			if agency.GTFSRT.TripUpdates != "" {
-				if err := pollTripUpdates(ctx, pool, nc, client, agency, aID); err != nil {
-					log.Printf("[%s] trip_updates: %v", agency.Slug, err)
-				}
-			}
+// poller polls the agencies' GTFS-RT feeds.
+type poller struct {
+	nc          natsConn // delay and alert broadcasts, pushed positions
+	client      *http.Client
+	sem         chan struct{} // limits concurrent polls
+	delayEvents *usecases.DelayEventService
+	tripUpdates *usecases.TripUpdateService
+	feedAlerts  *usecases.FeedAlertService
+	feedConfigs ports.FeedConfigRepository
+	feedStatus  ports.RealtimeFeedStatusRepository
+	seenIDs     ports.FeedQualityRepository // RT identifiers, for the nightly reconciliation
+	realtime    *usecases.RealtimeService
+	delayAlerts *usecases.AlertSubscriptionService
+	purges      *usecases.CDNPurgeService // nil without a CDN
+	pushKeys    *usecases.PushKeyService
+
+	feedLoops    map[string]*feedLoop // by slug and feed kind; see runFeeds
+	loops        sync.WaitGroup
+	pushMu       sync.RWMutex
+	pushAgencies map[string]string // slug -> UUID
+}
 
-			if agency.GTFSRT.Alerts != "" {
-				if err := pollAlerts(ctx, nc, client, agency); err != nil {
-					log.Printf("[%s] alerts: %v", agency.Slug, err)
-				}
-			}
-		}(a, agencyID)
+// idMapper builds the agency's RT identifier mapper from its feed config.
+// It is loaded for every poll so admin changes apply without a restart.
+// Agencies with invalid rules are logged and left unmapped.
+func (p *poller) idMapper(ctx context.Context, agencyID string) *usecases.IDMapper {
+	cfg, err := p.feedConfigs.Get(ctx, agencyID)
+	if err != nil {
+		log.Printf("agency %s: load id rules: %v", agencyID, err)
+		return nil
 	}
-
-	wg.Wait()
+	if cfg == nil {
+		return nil
+	}
+	m, err := usecases.NewIDMapper(cfg.IDRules)
+	if err != nil {
+		log.Printf("agency %s: %v", agencyID, err)
+		return nil
+	}
+	return m
 }
 
 // ---------------------------------------------------------------------------
 // Fetch + parse protobuf feed
 // ---------------------------------------------------------------------------
 
-func fetchFeed(client *http.Client, url string) (*gtfsrt.FeedMessage, error) {
-	resp, err := client.Get(url)
+// fetch fetches one of the agency's feeds and records the outcome for
+// GET /v1/feeds/realtime/status.
+func (p *poller) fetch(ctx context.Context, agencyID, kind, url string) (*gtfsrt.FeedMessage, error) {
+	feed, err := fetchFeed(ctx, p.client, url)
+	if ctx.Err() != nil {
+		return nil, err
+	}
+
+	poll := &domain.RealtimeFeedPoll{AgencyID: agencyID, Feed: kind, At: time.Now()}
+	if err != nil {
+		poll.Err = err.Error()
+	} else {
+		poll.Entities = len(feed.GetEntity())
+		if ts := feed.GetHeader().GetTimestamp(); ts > 0 {
+			poll.FeedTimestamp = time.Unix(int64(ts), 0)
+		}
+	}
+	if err := p.feedStatus.RecordPoll(ctx, poll); err != nil {
+		log.Printf("agency %s: record %s status: %v", agencyID, kind, err)
+	}
+	return feed, err
+}
+
+func fetchFeed(ctx context.Context, client *http.Client, url string) (*gtfsrt.FeedMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("GET %s: %w", url, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d for %s", resp.StatusCode, url)
+		return nil, &feedError{
+			URL:        url,
+			Status:     resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -221,109 +257,119 @@ func fetchFeed(client *http.Client, url string) (*gtfsrt.FeedMessage, error) {
 // Vehicle Positions
 // ---------------------------------------------------------------------------
 
-func pollVehiclePositions(ctx context.Context, pool *pgxpool.Pool, nc *nats.Conn, client *http.Client, agency AgencyEntry, agencyID string) error {
-	feed, err := fetchFeed(client, agency.GTFSRT.VehiclePositions)
+func (p *poller) pollVehiclePositions(ctx context.Context, agency domain.AgencyFeeds, agencyID string, ids *usecases.IDMapper) error {
+	feed, err := p.fetch(ctx, agencyID, "vehicle_positions", agency.GTFSRT.VehiclePositions)
 	if err != nil {
 		return err
 	}
 
-	inserted := 0
+	inserted, superseded := 0, 0
 	for _, entity := range feed.GetEntity() {
-		vp := entity.GetVehicle()
-		if vp == nil || vp.GetPosition() == nil {
+		vp, ok := feedVehiclePosition(entity, agency.Slug, ids)
+		if !ok {
 			continue
 		}
+		if err := p.realtime.ProcessVehicleUpdate(ctx, agencyID, &vp); err != nil {
+			// Pushed positions fresher than the feed's are expected.
+			if errors.Is(err, usecases.ErrSupersededPosition) {
+				superseded++
+				continue
+			}
+			log.Printf("[%s] vehicle %s: %v", agency.Slug, vp.VehicleID, err)
+			continue
+		}
+		inserted++
+	}
 
-		pos := vp.GetPosition()
-		trip := vp.GetTrip()
-		vehicle := vp.GetVehicle()
+	if inserted > 0 || superseded > 0 {
+		log.Printf("[%s] %d vehicle positions, %d superseded", agency.Slug, inserted, superseded)
+	}
+	return nil
+}
 
-		ts := time.Now()
-		if vp.Timestamp != nil {
-			ts = time.Unix(int64(vp.GetTimestamp()), 0)
-		}
+// feedVehiclePosition reads the vehicle position of a GTFS-RT entity, with
+// the feed's IDs as mapped by ids; ok is false for entities without one.
+func feedVehiclePosition(entity *gtfsrt.FeedEntity, slug string, ids *usecases.IDMapper) (domain.VehiclePosition, bool) {
+	vp := entity.GetVehicle()
+	if vp == nil || vp.GetPosition() == nil {
+		return domain.VehiclePosition{}, false
+	}
 
-		vehicleID := ""
-		if vehicle != nil {
-			vehicleID = vehicle.GetId()
-			if vehicleID == "" {
-				vehicleID = vehicle.GetLabel()
-			}
-		}
-		if vehicleID == "" {
-			vehicleID = entity.GetId()
-		}
+	pos := vp.GetPosition()
+	trip := vp.GetTrip()
+	vehicle := vp.GetVehicle()
 
-		tripID := ""
-		routeID := ""
-		if trip != nil {
-			tripID = trip.GetTripId()
-			routeID = trip.GetRouteId()
-		}
+	ts := time.Now()
+	if vp.Timestamp != nil {
+		ts = time.Unix(int64(vp.GetTimestamp()), 0)
+	}
 
-		// Insert into vehicle_positions (timescaledb hypertable)
-		_, err := pool.Exec(ctx, `
-			INSERT INTO vehicle_positions (time, vehicle_id, trip_id, route_id, location, bearing, speed, congestion_level, occupancy_status, metadata)
-			VALUES ($1, $2,
-				(SELECT id FROM trips WHERE trip_id = $3 AND route_id IN (SELECT id FROM routes WHERE agency_id = $9) LIMIT 1),
-				(SELECT id FROM routes WHERE route_id = $4 AND agency_id = $9 LIMIT 1),
-				ST_SetSRID(ST_MakePoint($5, $6), 4326)::geography,
-				$7, $8, $10, $11, $12)
-		`, ts, vehicleID,
-			nilEmpty(tripID), nilEmpty(routeID),
-			float64(pos.GetLongitude()), float64(pos.GetLatitude()),
-			float64(pos.GetBearing()), float64(pos.GetSpeed()),
-			agencyID,
-			int(vp.GetCongestionLevel()), int(vp.GetOccupancyStatus()),
-			map[string]any{"agency": agency.Slug},
-		)
-		if err != nil {
-			// Log but continue — some trip/route IDs may not resolve
-			if !strings.Contains(err.Error(), "null value in column") {
-				log.Printf("[%s] insert vp %s: %v", agency.Slug, vehicleID, err)
-			}
-			continue
+	vehicleID := ""
+	if vehicle != nil {
+		vehicleID = vehicle.GetId()
+		if vehicleID == "" {
+			vehicleID = vehicle.GetLabel()
 		}
-		inserted++
+	}
+	if vehicleID == "" {
+		vehicleID = entity.GetId()
+	}
 
-		// Publish to NATS for WebSocket clients
-		vpDomain := domain.VehiclePosition{
-			Time:      ts,
-			VehicleID: vehicleID,
-			TripID:    tripID,
-			RouteID:   routeID,
-			Location: domain.GeoPoint{
-				Lat: float64(pos.GetLatitude()),
-				Lon: float64(pos.GetLongitude()),
-			},
-			Bearing:         float64(pos.GetBearing()),
-			Speed:           float64(pos.GetSpeed()),
-			CongestionLevel: int(vp.GetCongestionLevel()),
-			OccupancyStatus: int(vp.GetOccupancyStatus()),
-			Metadata:        map[string]any{"agency": agency.Slug},
-		}
-		if data, err := json.Marshal(vpDomain); err == nil {
-			_ = nc.Publish(fmt.Sprintf("transit.vehicle.%s.%s", agency.Slug, vehicleID), data)
-		}
+	tripID := ""
+	routeID := ""
+	if trip != nil {
+		tripID = ids.Map("trip", trip.GetTripId())
+		routeID = ids.Map("route", trip.GetRouteId())
 	}
 
-	if inserted > 0 {
-		log.Printf("[%s] %d vehicle positions", agency.Slug, inserted)
+	// Stored with internal trip and route IDs, published with the feed's
+	vpDomain := domain.VehiclePosition{
+		Time:      ts,
+		VehicleID: vehicleID,
+		TripID:    tripID,
+		RouteID:   routeID,
+		Location: domain.GeoPoint{
+			Lat: float64(pos.GetLatitude()),
+			Lon: float64(pos.GetLongitude()),
+		},
+		Bearing:         float64(pos.GetBearing()),
+		Speed:           float64(pos.GetSpeed()),
+		CongestionLevel: int(vp.GetCongestionLevel()),
+		StopID:          ids.Map("stop", vp.GetStopId()),
+		Metadata:        map[string]any{"agency": slug},
 	}
-	return nil
+	// Feeds that do not report occupancy would otherwise read as EMPTY.
+	if vp.OccupancyStatus != nil {
+		occ := int(vp.GetOccupancyStatus())
+		vpDomain.OccupancyStatus = &occ
+	}
+	if vp.CurrentStopSequence != nil {
+		seq := int(vp.GetCurrentStopSequence())
+		vpDomain.StopSequence = &seq
+	}
+	return vpDomain, true
 }
 
 // ---------------------------------------------------------------------------
-// Trip Updates (delay detection)
+// Trip Updates (predictions + delay detection)
 // ---------------------------------------------------------------------------
 
-func pollTripUpdates(ctx context.Context, pool *pgxpool.Pool, nc *nats.Conn, client *http.Client, agency AgencyEntry, agencyID string) error {
-	feed, err := fetchFeed(client, agency.GTFSRT.TripUpdates)
+func (p *poller) pollTripUpdates(ctx context.Context, agency domain.AgencyFeeds, agencyID string, ids *usecases.IDMapper) error {
+	feed, err := p.fetch(ctx, agencyID, "trip_updates", agency.GTFSRT.TripUpdates)
 	if err != nil {
 		return err
 	}
 
+	feedTime := time.Now()
+	if ts := feed.GetHeader().GetTimestamp(); ts > 0 {
+		feedTime = time.Unix(int64(ts), 0)
+	}
+
+	var updates []domain.StopTimeUpdate
+	var observed []domain.DelayObservation
 	delays := 0
+	seenTrips := make(map[string]int)
+	seenStops := make(map[string]int)
 	for _, entity := range feed.GetEntity() {
 		tu := entity.GetTripUpdate()
 		if tu == nil {
@@ -331,34 +377,113 @@ func pollTripUpdates(ctx context.Context, pool *pgxpool.Pool, nc *nats.Conn, cli
 		}
 
 		trip := tu.GetTrip()
-		tripID := trip.GetTripId()
+		tripID := ids.Map("trip", trip.GetTripId())
+		routeID := ids.Map("route", trip.GetRouteId())
+		if tripID != "" {
+			seenTrips[tripID]++
+		}
+
+		ts := feedTime
+		if tu.Timestamp != nil {
+			ts = time.Unix(int64(tu.GetTimestamp()), 0)
+		}
 
 		// Check overall delay
 		overallDelay := int(tu.GetDelay())
 
 		// Also check per-stop delays
 		for _, stu := range tu.GetStopTimeUpdate() {
-			stopDelay := 0
-			if arr := stu.GetArrival(); arr != nil && arr.Delay != nil {
-				stopDelay = int(arr.GetDelay())
-			} else if dep := stu.GetDeparture(); dep != nil && dep.Delay != nil {
-				stopDelay = int(dep.GetDelay())
-			} else {
-				stopDelay = overallDelay
+			stopID := ids.Map("stop", stu.GetStopId())
+			if stopID != "" {
+				seenStops[stopID]++
 			}
+			arrDelay, arrTime := stopTimeEvent(stu.GetArrival())
+			depDelay, depTime := stopTimeEvent(stu.GetDeparture())
 
-			// If delay > 3 minutes, publish alert
-			if stopDelay > 180 {
+			var stopSeq *int
+			if stu.StopSequence != nil {
+				seq := int(stu.GetStopSequence())
+				stopSeq = &seq
+			}
+
+			if tripID != "" {
+				updates = append(updates, domain.StopTimeUpdate{
+					Time: ts, TripID: tripID, StopID: stopID, StopSequence: stopSeq,
+					ArrivalDelay: arrDelay, DepartureDelay: depDelay,
+					PredictedArrival: arrTime, PredictedDeparture: depTime,
+					ScheduleRelationship: int(stu.GetScheduleRelationship()),
+				})
+			}
+
+			stopDelay := overallDelay
+			if arrDelay != nil {
+				stopDelay = *arrDelay
+			} else if depDelay != nil {
+				stopDelay = *depDelay
+			}
+
+			// If delay > 3 minutes, record a delay event and publish alert
+			if stopDelay > delayThreshold {
 				delays++
+				actual := arrTime
+				if actual == nil {
+					actual = depTime
+				}
+				if tripID != "" {
+					observed = append(observed, domain.DelayObservation{
+						Time: ts, AgencyID: agencyID, TripID: tripID, RouteID: routeID, StopID: stopID,
+						StopSequence: stopSeq, StartDate: trip.GetStartDate(), DelaySeconds: stopDelay, ActualArrival: actual,
+					})
+				}
 				alertData, _ := json.Marshal(map[string]any{
 					"agency":    agency.Slug,
 					"trip_id":   tripID,
-					"stop_id":   stu.GetStopId(),
+					"stop_id":   stopID,
 					"delay_sec": stopDelay,
-					"route_id":  trip.GetRouteId(),
+					"route_id":  routeID,
 				})
-				_ = nc.Publish("transit.delays.detected", alertData)
+				_ = p.nc.Publish("transit.delays.detected", alertData)
+			}
+		}
+	}
+
+	if len(updates) > 0 {
+		stored, err := p.tripUpdates.RecordPredictions(ctx, agencyID, updates)
+		if err != nil {
+			return err
+		}
+		log.Printf("[%s] %d stop time predictions", agency.Slug, stored)
+	}
+
+	// Count referenced IDs for the nightly feed quality reconciliation.
+	for kind, seen := range map[string]map[string]int{"trip": seenTrips, "stop": seenStops} {
+		if err := p.seenIDs.RecordSeenIDs(ctx, agencyID, feedTime, kind, seen); err != nil {
+			return fmt.Errorf("record %s ids: %w", kind, err)
+		}
+	}
+
+	if len(observed) > 0 {
+		recorded, err := p.delayEvents.Record(ctx, observed)
+		if err != nil {
+			if len(recorded) == 0 {
+				return err
+			}
+			// The events are recorded; publishing some of them failed.
+			log.Printf("[%s] %v", agency.Slug, err)
+		}
+		if len(recorded) > 0 {
+			log.Printf("[%s] %d new delay events", agency.Slug, len(recorded))
+		}
+		pushed := 0
+		for i := range recorded {
+			n, err := p.delayAlerts.NotifyDelay(ctx, &recorded[i])
+			if err != nil {
+				log.Printf("[%s] delay alerts: %v", agency.Slug, err)
 			}
+			pushed += n
+		}
+		if pushed > 0 {
+			log.Printf("[%s] %d delay alerts pushed", agency.Slug, pushed)
 		}
 	}
 
@@ -368,75 +493,135 @@ func pollTripUpdates(ctx context.Context, pool *pgxpool.Pool, nc *nats.Conn, cli
 	return nil
 }
 
+// stopTimeEvent extracts the delay (seconds) and absolute predicted time of a
+// GTFS-RT StopTimeEvent. Either may be nil when the feed omits it.
+func stopTimeEvent(ev *gtfsrt.TripUpdate_StopTimeEvent) (*int, *time.Time) {
+	if ev == nil {
+		return nil, nil
+	}
+	var delay *int
+	if ev.Delay != nil {
+		d := int(ev.GetDelay())
+		delay = &d
+	}
+	var at *time.Time
+	if ev.Time != nil {
+		t := time.Unix(ev.GetTime(), 0)
+		at = &t
+	}
+	return delay, at
+}
+
 // ---------------------------------------------------------------------------
 // Alerts
 // ---------------------------------------------------------------------------
 
-func pollAlerts(ctx context.Context, nc *nats.Conn, client *http.Client, agency AgencyEntry) error {
-	feed, err := fetchFeed(client, agency.GTFSRT.Alerts)
+func (p *poller) pollAlerts(ctx context.Context, agency domain.AgencyFeeds, agencyID string, ids *usecases.IDMapper) error {
+	feed, err := p.fetch(ctx, agencyID, "alerts", agency.GTFSRT.Alerts)
 	if err != nil {
 		return err
 	}
+	// A minute early, in case this clock is ahead of the database's
+	started := time.Now().Add(-time.Minute)
 
+	var alerts []domain.ServiceAlert
+	var broadcasts [][]byte
 	for _, entity := range feed.GetEntity() {
 		alert := entity.GetAlert()
 		if alert == nil {
 			continue
 		}
 
-		headerText := ""
-		if ht := alert.GetHeaderText(); ht != nil {
-			for _, t := range ht.GetTranslation() {
-				headerText = t.GetText()
-				break
-			}
-		}
-
-		descText := ""
-		if dt := alert.GetDescriptionText(); dt != nil {
-			for _, t := range dt.GetTranslation() {
-				descText = t.GetText()
-				break
-			}
-		}
-
-		if headerText == "" && descText == "" {
+		header := translations(alert.GetHeaderText())
+		description := translations(alert.GetDescriptionText())
+		if len(header) == 0 && len(description) == 0 {
 			continue
 		}
 
 		// Get affected routes/stops
 		var routeIDs, stopIDs []string
 		for _, ie := range alert.GetInformedEntity() {
-			if r := ie.GetRouteId(); r != "" {
+			if r := ids.Map("route", ie.GetRouteId()); r != "" {
 				routeIDs = append(routeIDs, r)
 			}
-			if s := ie.GetStopId(); s != "" {
+			if s := ids.Map("stop", ie.GetStopId()); s != "" {
 				stopIDs = append(stopIDs, s)
 			}
 		}
 
+		periods := []domain.AlertPeriod{}
+		for _, ap := range alert.GetActivePeriod() {
+			var period domain.AlertPeriod
+			if ap.Start != nil {
+				t := time.Unix(int64(ap.GetStart()), 0)
+				period.Start = &t
+			}
+			if ap.End != nil {
+				t := time.Unix(int64(ap.GetEnd()), 0)
+				period.End = &t
+			}
+			periods = append(periods, period)
+		}
+
+		// Stored with the feed's route and stop IDs resolved, broadcast with
+		// the feed's
+		alerts = append(alerts, domain.ServiceAlert{
+			SourceID:      entity.GetId(),
+			Cause:         alert.GetCause().String(),
+			Effect:        alert.GetEffect().String(),
+			Header:        header,
+			Description:   description,
+			URL:           translations(alert.GetUrl()),
+			ActivePeriods: periods,
+			RouteIDs:      routeIDs,
+			StopIDs:       stopIDs,
+		})
+
 		alertData, _ := json.Marshal(map[string]any{
 			"agency":      agency.Slug,
-			"header":      headerText,
-			"description": descText,
+			"header":      firstTranslation(alert.GetHeaderText()),
+			"description": firstTranslation(alert.GetDescriptionText()),
 			"cause":       alert.GetCause().String(),
 			"effect":      alert.GetEffect().String(),
 			"route_ids":   routeIDs,
 			"stop_ids":    stopIDs,
 		})
-		_ = nc.Publish(fmt.Sprintf("transit.alerts.%s", agency.Slug), alertData)
+		broadcasts = append(broadcasts, alertData)
 	}
 
-	return nil
+	// Alerts that left the feed are no longer in effect.
+	err = p.feedAlerts.Replace(ctx, agencyID, alerts)
+	for _, alertData := range broadcasts {
+		_ = p.nc.Publish(fmt.Sprintf("transit.alerts.%s", agency.Slug), alertData)
+	}
+
+	if p.purges != nil {
+		if _, err := p.purges.PurgeChanges(ctx, agency.Slug, started, time.Now()); err != nil {
+			log.Printf("[%s] cdn purge: %v", agency.Slug, err)
+		}
+	}
+	return err
 }
 
-// ---------------------------------------------------------------------------
-// Helpers
-// ---------------------------------------------------------------------------
+// translations maps language code to text; untagged text is stored under "und".
+func translations(ts *gtfsrt.TranslatedString) map[string]string {
+	out := map[string]string{}
+	for _, t := range ts.GetTranslation() {
+		if t.GetText() == "" {
+			continue
+		}
+		lang := t.GetLanguage()
+		if lang == "" {
+			lang = "und"
+		}
+		out[lang] = t.GetText()
+	}
+	return out
+}
 
-func nilEmpty(s string) interface{} {
-	if s == "" {
-		return nil
+func firstTranslation(ts *gtfsrt.TranslatedString) string {
+	for _, t := range ts.GetTranslation() {
+		return t.GetText()
 	}
-	return s
+	return ""
 }
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+	"github.com/samirrijal/bilbopass/internal/gtfsrt"
+)
+
+// The poller is tested against fakes of its ports, polling feeds served
+// by an httptest server.
+
+type fakeNATS struct {
+	mu        sync.Mutex
+	published map[string][]string // subject -> payloads
+}
+
+func (f *fakeNATS) Publish(subject string, data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.published[subject] = append(f.published[subject], string(data))
+	return nil
+}
+
+func (f *fakeNATS) QueueSubscribe(string, string, nats.MsgHandler) (*nats.Subscription, error) {
+	return nil, errors.New("not subscribed")
+}
+
+type fakeFeedStatus struct {
+	ports.RealtimeFeedStatusRepository
+	polls []domain.RealtimeFeedPoll
+}
+
+func (f *fakeFeedStatus) RecordPoll(_ context.Context, p *domain.RealtimeFeedPoll) error {
+	f.polls = append(f.polls, *p)
+	return nil
+}
+
+type fakeSeenIDs struct {
+	ports.FeedQualityRepository
+	seen map[string]map[string]int // kind -> counts
+}
+
+func (f *fakeSeenIDs) RecordSeenIDs(_ context.Context, _ string, _ time.Time, kind string, seen map[string]int) error {
+	f.seen[kind] = seen
+	return nil
+}
+
+// fakeFeedIDs knows trip T1, serving stops S1 and S2, route L1 and stop S1.
+type fakeFeedIDs struct{}
+
+func (fakeFeedIDs) TripStops(_ context.Context, _ string, gtfsTripIDs []string) (map[string][]domain.TripStop, error) {
+	stops := map[string][]domain.TripStop{}
+	for _, id := range gtfsTripIDs {
+		if id == "T1" {
+			stops[id] = []domain.TripStop{
+				{TripID: "trip-1", StopID: "stop-1", GTFSStopID: "S1", StopSequence: 1},
+				{TripID: "trip-1", StopID: "stop-2", GTFSStopID: "S2", StopSequence: 2},
+			}
+		}
+	}
+	return stops, nil
+}
+
+func (fakeFeedIDs) RouteIDs(context.Context, string, []string) (map[string]string, error) {
+	return map[string]string{"L1": "route-1"}, nil
+}
+
+func (fakeFeedIDs) StopIDs(context.Context, string, []string) (map[string]string, error) {
+	return map[string]string{"S1": "stop-1"}, nil
+}
+
+type fakePredictions struct {
+	ports.TripUpdateRepository
+	inserted []domain.StopTimePrediction
+}
+
+func (f *fakePredictions) InsertBatch(_ context.Context, p []domain.StopTimePrediction) error {
+	f.inserted = append(f.inserted, p...)
+	return nil
+}
+
+// fakeObservations records no delay event, as for delays seen before.
+type fakeObservations struct{ obs []domain.DelayObservation }
+
+func (f *fakeObservations) Record(_ context.Context, obs []domain.DelayObservation, _ time.Duration) ([]*domain.DelayEvent, error) {
+	f.obs = append(f.obs, obs...)
+	return make([]*domain.DelayEvent, len(obs)), nil
+}
+
+type fakeAlerts struct {
+	ports.AlertRepository
+	upserted []domain.ServiceAlert
+	live     []string
+	err      error
+}
+
+func (f *fakeAlerts) Upsert(_ context.Context, a *domain.ServiceAlert) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.upserted = append(f.upserted, *a)
+	return nil
+}
+
+func (f *fakeAlerts) RemoveMissing(_ context.Context, _, _ string, live []string) error {
+	f.live = live
+	return nil
+}
+
+type testPoller struct {
+	*poller
+	nc           *fakeNATS
+	status       *fakeFeedStatus
+	seen         *fakeSeenIDs
+	predictions  *fakePredictions
+	observations *fakeObservations
+	alerts       *fakeAlerts
+}
+
+func newTestPoller() *testPoller {
+	tp := &testPoller{
+		nc:           &fakeNATS{published: map[string][]string{}},
+		status:       &fakeFeedStatus{},
+		seen:         &fakeSeenIDs{seen: map[string]map[string]int{}},
+		predictions:  &fakePredictions{},
+		observations: &fakeObservations{},
+		alerts:       &fakeAlerts{},
+	}
+	tp.poller = &poller{
+		nc:          tp.nc,
+		client:      &http.Client{Timeout: time.Second},
+		delayEvents: usecases.NewDelayEventService(tp.observations, nil),
+		tripUpdates: usecases.NewTripUpdateService(fakeFeedIDs{}, tp.predictions),
+		feedAlerts:  usecases.NewFeedAlertService(fakeFeedIDs{}, tp.alerts),
+		feedStatus:  tp.status,
+		seenIDs:     tp.seen,
+	}
+	return tp
+}
+
+// serveFeed serves the feed message and returns its URL.
+func serveFeed(t *testing.T, entities ...*gtfsrt.FeedEntity) string {
+	t.Helper()
+	body, err := proto.Marshal(&gtfsrt.FeedMessage{
+		Header: &gtfsrt.FeedHeader{GtfsRealtimeVersion: proto.String("2.0"), Timestamp: proto.Uint64(1772438400)},
+		Entity: entities,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write(body) }))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func stopTimeUpdate(stopID string, arrivalDelay int32) *gtfsrt.TripUpdate_StopTimeUpdate {
+	return &gtfsrt.TripUpdate_StopTimeUpdate{
+		StopId:  proto.String(stopID),
+		Arrival: &gtfsrt.TripUpdate_StopTimeEvent{Delay: proto.Int32(arrivalDelay)},
+	}
+}
+
+func text(s string) *gtfsrt.TranslatedString {
+	return &gtfsrt.TranslatedString{Translation: []*gtfsrt.TranslatedString_Translation{{Text: proto.String(s), Language: proto.String("es")}}}
+}
+
+func TestPollTripUpdates(t *testing.T) {
+	url := serveFeed(t,
+		&gtfsrt.FeedEntity{Id: proto.String("1"), TripUpdate: &gtfsrt.TripUpdate{
+			Trip: &gtfsrt.TripDescriptor{TripId: proto.String("T1"), RouteId: proto.String("L1")},
+			StopTimeUpdate: []*gtfsrt.TripUpdate_StopTimeUpdate{
+				stopTimeUpdate("S1", 60),
+				stopTimeUpdate("S2", delayThreshold+60),
+				stopTimeUpdate("S9", 0), // not on the trip
+			},
+		}},
+		&gtfsrt.FeedEntity{Id: proto.String("2"), TripUpdate: &gtfsrt.TripUpdate{
+			Trip:           &gtfsrt.TripDescriptor{TripId: proto.String("T9")}, // not scheduled
+			StopTimeUpdate: []*gtfsrt.TripUpdate_StopTimeUpdate{stopTimeUpdate("S1", 0)},
+		}},
+	)
+	p := newTestPoller()
+	agency := domain.AgencyFeeds{AgencyID: "a1", Slug: "bilbobus", GTFSRT: &domain.GTFSRTFeeds{TripUpdates: url}}
+
+	if err := p.pollTripUpdates(context.Background(), agency, "a1", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	feedTime := time.Unix(1772438400, 0)
+	delay, bigDelay := 60, delayThreshold+60
+	want := []domain.StopTimePrediction{
+		{Time: feedTime, TripID: "trip-1", StopID: "stop-1", StopSequence: 1, ArrivalDelay: &delay},
+		{Time: feedTime, TripID: "trip-1", StopID: "stop-2", StopSequence: 2, ArrivalDelay: &bigDelay},
+	}
+	if !reflect.DeepEqual(p.predictions.inserted, want) {
+		t.Errorf("expected predictions\n  %+v\ngot\n  %+v", want, p.predictions.inserted)
+	}
+
+	wantSeen := map[string]map[string]int{
+		"trip": {"T1": 1, "T9": 1},
+		"stop": {"S1": 2, "S2": 1, "S9": 1},
+	}
+	if !reflect.DeepEqual(p.seen.seen, wantSeen) {
+		t.Errorf("expected ids seen %v, got %v", wantSeen, p.seen.seen)
+	}
+
+	if len(p.observations.obs) != 1 || p.observations.obs[0].StopID != "S2" || p.observations.obs[0].DelaySeconds != bigDelay {
+		t.Errorf("expected the delay at S2 observed, got %+v", p.observations.obs)
+	}
+	if got := p.nc.published["transit.delays.detected"]; len(got) != 1 {
+		t.Errorf("expected one delay broadcast, got %v", got)
+	}
+
+	if len(p.status.polls) != 1 || p.status.polls[0].Feed != "trip_updates" || p.status.polls[0].Entities != 2 {
+		t.Errorf("expected the poll recorded, got %+v", p.status.polls)
+	}
+}
+
+func TestPollAlerts(t *testing.T) {
+	url := serveFeed(t,
+		&gtfsrt.FeedEntity{Id: proto.String("works"), Alert: &gtfsrt.Alert{
+			HeaderText:     text("Obras"),
+			InformedEntity: []*gtfsrt.EntitySelector{{RouteId: proto.String("L1")}, {StopId: proto.String("S9")}},
+			Effect:         gtfsrt.Alert_DETOUR.Enum(),
+		}},
+		&gtfsrt.FeedEntity{Id: proto.String("empty"), Alert: &gtfsrt.Alert{}}, // no text
+	)
+	agency := domain.AgencyFeeds{AgencyID: "a1", Slug: "bilbobus", GTFSRT: &domain.GTFSRTFeeds{Alerts: url}}
+
+	p := newTestPoller()
+	if err := p.pollAlerts(context.Background(), agency, "a1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.alerts.upserted) != 1 {
+		t.Fatalf("expected one alert stored, got %+v", p.alerts.upserted)
+	}
+	a := p.alerts.upserted[0]
+	if a.AgencyID != "a1" || a.Source != usecases.FeedAlertSource || a.SourceID != "works" ||
+		a.Effect != "DETOUR" || a.Header["es"] != "Obras" {
+		t.Errorf("expected the feed's alert, got %+v", a)
+	}
+	if !reflect.DeepEqual(a.RouteIDs, []string{"route-1"}) || len(a.StopIDs) != 0 {
+		t.Errorf("expected route L1 resolved and stop S9 dropped, got %v and %v", a.RouteIDs, a.StopIDs)
+	}
+	if !reflect.DeepEqual(p.alerts.live, []string{"works"}) {
+		t.Errorf("expected alerts other than works removed, got %v kept", p.alerts.live)
+	}
+
+	// Broadcast with the feed's IDs.
+	got := p.nc.published["transit.alerts.bilbobus"]
+	var broadcast struct {
+		RouteIDs []string `json:"route_ids"`
+		StopIDs  []string `json:"stop_ids"`
+	}
+	if len(got) != 1 || json.Unmarshal([]byte(got[0]), &broadcast) != nil ||
+		!reflect.DeepEqual(broadcast.RouteIDs, []string{"L1"}) || !reflect.DeepEqual(broadcast.StopIDs, []string{"S9"}) {
+		t.Errorf("expected the alert broadcast with feed IDs, got %v", got)
+	}
+
+	// A failure to store is the poll's, and the alert is still broadcast.
+	p = newTestPoller()
+	p.alerts.err = errors.New("db down")
+	if err := p.pollAlerts(context.Background(), agency, "a1", nil); err == nil {
+		t.Error("expected the store error")
+	}
+	if len(p.nc.published["transit.alerts.bilbobus"]) != 1 {
+		t.Errorf("expected the alert broadcast, got %v", p.nc.published)
+	}
+}
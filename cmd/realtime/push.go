@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+	"github.com/samirrijal/bilbopass/internal/gtfsrt"
+)
+
+// pushSubject is where agencies that push vehicle positions, rather than
+// being polled, send them: transit.push.<slug>.vehicles. MQTT publishers
+// reach it through the NATS server's MQTT gateway, on the topic
+// transit/push/<slug>/vehicles.
+const pushSubject = "transit.push.*.vehicles"
+
+// pushQueue spreads pushed positions over the realtime service's replicas.
+const pushQueue = "realtime-push"
+
+// maxPushedPositions bounds the positions of one push.
+const maxPushedPositions = 1000
+
+// pushedPosition is a vehicle position pushed as JSON. Timestamp is in
+// Unix seconds; positions without one are taken as of their arrival.
+type pushedPosition struct {
+	VehicleID       string   `json:"vehicle_id"`
+	TripID          string   `json:"trip_id"`
+	RouteID         string   `json:"route_id"`
+	StopID          string   `json:"stop_id"`
+	StopSequence    *int     `json:"stop_sequence"`
+	Lat             *float64 `json:"lat"`
+	Lon             *float64 `json:"lon"`
+	Bearing         float64  `json:"bearing"`
+	Speed           float64  `json:"speed"` // m/s
+	Timestamp       int64    `json:"timestamp"`
+	CongestionLevel int      `json:"congestion_level"`
+	OccupancyStatus *int     `json:"occupancy_status"`
+}
+
+// pushAck answers pushes sent as NATS requests. Superseded positions were
+// valid but older than ones already received for their vehicles.
+type pushAck struct {
+	Accepted   int      `json:"accepted"`
+	Superseded int      `json:"superseded"`
+	Rejected   int      `json:"rejected"`
+	Errors     []string `json:"errors,omitempty"` // the first few
+}
+
+// receivePushes accepts vehicle positions pushed by the agencies set to
+// push them (see syncFeeds), in envelopes signed with one of the agency's
+// push keys.
+// Payloads are GTFS-RT FeedMessages or JSON (one position or an array);
+// their positions are validated and processed as polled ones are. Pushes
+// sent as requests are answered with a pushAck.
+func (p *poller) receivePushes(ctx context.Context) (*nats.Subscription, error) {
+	return p.nc.QueueSubscribe(pushSubject, pushQueue, func(msg *nats.Msg) {
+		slug := strings.Split(msg.Subject, ".")[2]
+		agencyID, ok := p.pushAgency(slug)
+		if !ok {
+			respondPush(msg, pushAck{Errors: []string{"agency " + slug + " does not push positions"}})
+			return
+		}
+
+		now := time.Now()
+		positions, err := p.openPush(ctx, msg.Data, slug, agencyID, now)
+		if err != nil {
+			log.Printf("[%s] pushed positions: %v", slug, err)
+			respondPush(msg, pushAck{Errors: []string{err.Error()}})
+			return
+		}
+
+		var ack pushAck
+		for i := range positions {
+			err := p.realtime.ProcessPushedUpdate(ctx, agencyID, &positions[i], now)
+			if errors.Is(err, usecases.ErrSupersededPosition) {
+				ack.Superseded++
+				continue
+			}
+			if err != nil {
+				ack.Rejected++
+				if len(ack.Errors) < 10 {
+					ack.Errors = append(ack.Errors, fmt.Sprintf("vehicle %q: %v", positions[i].VehicleID, err))
+				}
+				if !errors.Is(err, usecases.ErrInvalidPosition) {
+					log.Printf("[%s] pushed vehicle %s: %v", slug, positions[i].VehicleID, err)
+				}
+				continue
+			}
+			ack.Accepted++
+		}
+		if ack.Rejected > 0 {
+			log.Printf("[%s] %d pushed vehicle positions, %d rejected", slug, ack.Accepted, ack.Rejected)
+		}
+		respondPush(msg, ack)
+	})
+}
+
+// openPush verifies the envelope of a push and decodes the positions it
+// carries.
+func (p *poller) openPush(ctx context.Context, data []byte, slug, agencyID string, now time.Time) ([]domain.VehiclePosition, error) {
+	var env domain.PushEnvelope
+	if err := strictJSON(data, &env); err != nil {
+		return nil, fmt.Errorf("%w: not a push envelope: %v", usecases.ErrPushRejected, err)
+	}
+	payload, err := p.pushKeys.Verify(ctx, agencyID, &env, now)
+	if err != nil {
+		return nil, err
+	}
+	return decodePushedPositions(payload, slug, p.idMapper(ctx, agencyID))
+}
+
+// decodePushedPositions reads the payload of a push: JSON when it starts
+// with { or [, else a GTFS-RT FeedMessage. JSON positions must have a
+// vehicle_id, lat and lon, and no other fields than pushedPosition's;
+// FeedMessages must have a header with the GTFS-RT version. IDs are
+// mapped as the agency's feed's are.
+func decodePushedPositions(data []byte, slug string, ids *usecases.IDMapper) ([]domain.VehiclePosition, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, errors.New("empty push")
+	}
+
+	if trimmed[0] != '{' && trimmed[0] != '[' {
+		feed := &gtfsrt.FeedMessage{}
+		if err := proto.Unmarshal(data, feed); err != nil {
+			return nil, fmt.Errorf("neither JSON nor a GTFS-RT FeedMessage: %w", err)
+		}
+		if feed.GetHeader().GetGtfsRealtimeVersion() == "" {
+			return nil, errors.New("GTFS-RT FeedMessage without a header and gtfs_realtime_version")
+		}
+		if len(feed.GetEntity()) > maxPushedPositions {
+			return nil, fmt.Errorf("more than %d entities in one push", maxPushedPositions)
+		}
+		var positions []domain.VehiclePosition
+		for _, entity := range feed.GetEntity() {
+			if vp, ok := feedVehiclePosition(entity, slug, ids); ok {
+				positions = append(positions, vp)
+			}
+		}
+		return positions, nil
+	}
+
+	var pushed []pushedPosition
+	if trimmed[0] == '{' {
+		var one pushedPosition
+		if err := strictJSON(trimmed, &one); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		pushed = append(pushed, one)
+	} else if err := strictJSON(trimmed, &pushed); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if len(pushed) > maxPushedPositions {
+		return nil, fmt.Errorf("more than %d positions in one push", maxPushedPositions)
+	}
+	for i, pp := range pushed {
+		if pp.VehicleID == "" || pp.Lat == nil || pp.Lon == nil {
+			return nil, fmt.Errorf("position %d: vehicle_id, lat and lon are required", i)
+		}
+	}
+
+	positions := make([]domain.VehiclePosition, 0, len(pushed))
+	for _, pp := range pushed {
+		var ts time.Time // taken as of now by ProcessPushedUpdate
+		if pp.Timestamp > 0 {
+			ts = time.Unix(pp.Timestamp, 0)
+		}
+		positions = append(positions, domain.VehiclePosition{
+			Time:            ts,
+			VehicleID:       pp.VehicleID,
+			TripID:          ids.Map("trip", pp.TripID),
+			RouteID:         ids.Map("route", pp.RouteID),
+			Location:        domain.GeoPoint{Lat: *pp.Lat, Lon: *pp.Lon},
+			Bearing:         pp.Bearing,
+			Speed:           pp.Speed,
+			CongestionLevel: pp.CongestionLevel,
+			OccupancyStatus: pp.OccupancyStatus,
+			StopID:          ids.Map("stop", pp.StopID),
+			StopSequence:    pp.StopSequence,
+			Metadata:        map[string]any{"agency": slug},
+		})
+	}
+	return positions, nil
+}
+
+// strictJSON decodes one JSON value, refusing unknown fields and
+// trailing data.
+func strictJSON(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("trailing data after the JSON value")
+	}
+	return nil
+}
+
+// respondPush answers a push sent as a request; other pushes get no answer.
+func respondPush(msg *nats.Msg, ack pushAck) {
+	if msg.Reply == "" {
+		return
+	}
+	data, _ := json.Marshal(ack)
+	_ = msg.Respond(data)
+}
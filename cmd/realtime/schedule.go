@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// Each GTFS-RT feed is polled on its own timer, so a slow or failing feed
+// does not hold back the others and feeds do not all fire at once.
+
+const (
+	defaultPollInterval = 30 * time.Second
+	minPollInterval     = 5 * time.Second
+	maxBackoff          = 10 * time.Minute // cap of exponential backoff
+	maxRetryAfter       = time.Hour        // cap of a feed's Retry-After
+	pollJitter          = 0.1              // fraction each wait varies by
+)
+
+// pollInterval returns the agency's poll interval, defaultPollInterval when
+// it sets none.
+func pollInterval(rt *domain.GTFSRTFeeds) time.Duration {
+	if rt.PollInterval <= 0 {
+		return defaultPollInterval
+	}
+	return max(time.Duration(rt.PollInterval)*time.Second, minPollInterval)
+}
+
+// feedError is a non-200 feed response. RetryAfter is set when the feed sent
+// a Retry-After header.
+type feedError struct {
+	URL        string
+	Status     int
+	RetryAfter time.Duration
+}
+
+func (e *feedError) Error() string { return fmt.Sprintf("HTTP %d for %s", e.Status, e.URL) }
+
+// parseRetryAfter reads a Retry-After header, in seconds or as an HTTP date.
+func parseRetryAfter(h string, now time.Time) time.Duration {
+	if h == "" {
+		return 0
+	}
+	var d time.Duration
+	if secs, err := strconv.Atoi(h); err == nil {
+		d = time.Duration(secs) * time.Second
+	} else if at, err := http.ParseTime(h); err == nil {
+		d = at.Sub(now)
+	}
+	return min(max(d, 0), maxRetryAfter)
+}
+
+// feedSchedule decides when a feed is polled next.
+type feedSchedule struct {
+	interval time.Duration
+	failures int // consecutive failed polls
+}
+
+// next returns how long to wait after a poll that ended with err. Failed
+// polls back off exponentially up to maxBackoff, or for as long as the feed
+// asked with Retry-After.
+func (s *feedSchedule) next(err error) time.Duration {
+	if err == nil {
+		s.failures = 0
+		return jitter(s.interval)
+	}
+	s.failures++
+	wait := min(s.interval<<min(s.failures, 16), maxBackoff)
+	var fe *feedError
+	if errors.As(err, &fe) && fe.RetryAfter > wait {
+		// Never earlier than asked.
+		return fe.RetryAfter + time.Duration(rand.Float64()*pollJitter*float64(fe.RetryAfter))
+	}
+	return jitter(wait)
+}
+
+// jitter varies d by up to ±pollJitter.
+func jitter(d time.Duration) time.Duration {
+	return d + time.Duration((rand.Float64()*2-1)*pollJitter*float64(d))
+}
+
+// runFeed polls one feed until ctx is done. The first poll comes at a random
+// point within the interval.
+func (p *poller) runFeed(ctx context.Context, slug, kind string, interval time.Duration, poll func(context.Context) error) {
+	sched := &feedSchedule{interval: interval}
+	wait := time.Duration(rand.Int64N(int64(interval)))
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		p.sem <- struct{}{}
+		err := poll(ctx)
+		<-p.sem
+		if ctx.Err() != nil {
+			return
+		}
+
+		wait = sched.next(err)
+		if err != nil {
+			log.Printf("[%s] %s: %v (failure %d, next poll in %s)", slug, kind, err, sched.failures, wait.Round(time.Second))
+		}
+		timer.Reset(wait)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+func TestPollInterval(t *testing.T) {
+	for _, tc := range []struct {
+		secs int
+		want time.Duration
+	}{
+		{0, defaultPollInterval},
+		{-1, defaultPollInterval},
+		{1, minPollInterval},
+		{45, 45 * time.Second},
+	} {
+		if got := pollInterval(&domain.GTFSRTFeeds{PollInterval: tc.secs}); got != tc.want {
+			t.Errorf("pollInterval(%d) = %s, want %s", tc.secs, got, tc.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"0", 0},
+		{"-5", 0},
+		{"7200", maxRetryAfter},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{now.Add(3 * time.Hour).Format(http.TimeFormat), maxRetryAfter},
+		{"soon", 0},
+	} {
+		if got := parseRetryAfter(tc.header, now); got != tc.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tc.header, got, tc.want)
+		}
+	}
+}
+
+// within reports whether d is base varied by at most ±pollJitter.
+func within(d, base time.Duration) bool {
+	return d >= base-time.Duration(pollJitter*float64(base)) && d <= base+time.Duration(pollJitter*float64(base))
+}
+
+func TestFeedSchedule_Backoff(t *testing.T) {
+	failed := errors.New("connection refused")
+	for i := 0; i < 100; i++ { // the waits are random
+		s := &feedSchedule{interval: 30 * time.Second}
+		if d := s.next(nil); !within(d, 30*time.Second) {
+			t.Fatalf("success: wait %s, want 30s ±10%%", d)
+		}
+		for n, base := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, maxBackoff, maxBackoff} {
+			if d := s.next(failed); !within(d, base) {
+				t.Fatalf("failure %d: wait %s, want %s ±10%%", n+1, d, base)
+			}
+		}
+		// Many failures stay capped rather than overflowing.
+		for range 100 {
+			s.next(failed)
+		}
+		if d := s.next(failed); !within(d, maxBackoff) {
+			t.Fatalf("after %d failures: wait %s, want %s ±10%%", s.failures, d, maxBackoff)
+		}
+		if d := s.next(nil); s.failures != 0 || !within(d, 30*time.Second) {
+			t.Fatalf("success after failures: wait %s with %d failures, want 30s and 0", d, s.failures)
+		}
+	}
+}
+
+func TestFeedSchedule_RetryAfter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		s := &feedSchedule{interval: 30 * time.Second}
+
+		// Asked for longer than the backoff: never earlier than asked.
+		err := fmt.Errorf("poll: %w", &feedError{Status: 429, RetryAfter: 20 * time.Minute})
+		d := s.next(err)
+		if d < 20*time.Minute || d > 22*time.Minute {
+			t.Fatalf("Retry-After 20m: wait %s, want 20m to 22m", d)
+		}
+
+		// Asked for less than the backoff: the backoff wins.
+		d = s.next(&feedError{Status: 503, RetryAfter: time.Second})
+		if !within(d, 2*time.Minute) {
+			t.Fatalf("Retry-After 1s on failure 2: wait %s, want 2m ±10%%", d)
+		}
+	}
+}
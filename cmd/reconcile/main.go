@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/adapters/postgres"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+	"github.com/samirrijal/bilbopass/internal/pkg/config"
+)
+
+// Feed quality reconciliation: checks the trip and stop IDs the realtime
+// poller saw in GTFS-RT trip updates on a day (YYYY-MM-DD, by default the day
+// before) against the static schedule and stores a report per agency, with
+// suggested matches for unmatched IDs, then exits. The compensator runs it
+// nightly as the reconcile job.
+func main() {
+	cfg, err := config.Load("bilbopass-reconcile")
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+
+	day := time.Now().AddDate(0, 0, -1)
+	if len(os.Args) > 1 {
+		day, err = time.ParseInLocation("2006-01-02", os.Args[1], time.Local)
+		if err != nil {
+			log.Fatalf("usage: reconcile [YYYY-MM-DD]")
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db, err := postgres.New(ctx, cfg.Database.DSN())
+	if err != nil {
+		log.Fatalf("db: %v", err)
+	}
+	defer db.Close()
+
+	svc := usecases.NewFeedQualityService(postgres.NewFeedQualityRepo(db), postgres.NewAgencyRepo(db))
+
+	reports, err := svc.Reconcile(ctx, day)
+	if err != nil {
+		log.Fatalf("reconcile: %v", err)
+	}
+	log.Printf("%d feed quality reports for %s", reports, day.Format("2006-01-02"))
+}
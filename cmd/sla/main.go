@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	natsadapter "github.com/samirrijal/bilbopass/internal/adapters/nats"
+	"github.com/samirrijal/bilbopass/internal/adapters/postgres"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+	"github.com/samirrijal/bilbopass/internal/pkg/config"
+)
+
+// SLA evaluator: rolls up trip update predictions into daily punctuality
+// counts for every active agency SLA contract, evaluates each finished month
+// and publishes breaches to NATS (transit.alerts.sla.<agency_id>), then
+// exits. The compensator runs it hourly as the sla job.
+func main() {
+	cfg, err := config.Load("bilbopass-sla")
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db, err := postgres.New(ctx, cfg.Database.DSN())
+	if err != nil {
+		log.Fatalf("db: %v", err)
+	}
+	defer db.Close()
+
+	natsOpts, err := cfg.NATS.Options()
+	if err != nil {
+		log.Fatalf("nats: %v", err)
+	}
+	pub, err := natsadapter.NewPublisher(cfg.NATS.URL, natsOpts...)
+	if err != nil {
+		log.Fatalf("nats: %v", err)
+	}
+	defer pub.Close()
+
+	svc := usecases.NewSLAService(postgres.NewSLARepo(db), postgres.NewAgencyRepo(db), pub)
+
+	breaches, err := svc.Run(ctx, time.Now())
+	if err != nil {
+		log.Fatalf("sla run: %v", err)
+	}
+	log.Printf("%d SLA breaches published", breaches)
+}
@@ -0,0 +1,79 @@
+// Package cdn purges cached API responses from a CDN by surrogate key.
+package cdn
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// CDN providers.
+const (
+	// ProviderFastly purges through the Fastly API; the URL is the service's
+	// purge endpoint, https://api.fastly.com/service/<id>/purge.
+	ProviderFastly = "fastly"
+	// ProviderVarnish sends PURGE requests to a Varnish whose VCL bans the
+	// keys in the Surrogate-Key header, such as with vmod xkey.
+	ProviderVarnish = "varnish"
+)
+
+// Purger implements ports.CDNPurger.
+type Purger struct {
+	client   *http.Client
+	provider string
+	url      string
+	token    string
+}
+
+// New creates a purger for provider sending purges to purgeURL. token
+// authenticates them: the Fastly API token, or a bearer token for Varnish.
+func New(provider, purgeURL, token string) (*Purger, error) {
+	if provider != ProviderFastly && provider != ProviderVarnish {
+		return nil, fmt.Errorf("cdn provider must be %s or %s", ProviderFastly, ProviderVarnish)
+	}
+	u, err := url.Parse(purgeURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("cdn purge url must be an http(s) URL")
+	}
+	return &Purger{
+		client:   &http.Client{Timeout: 10 * time.Second},
+		provider: provider,
+		url:      u.String(),
+		token:    token,
+	}, nil
+}
+
+// Purge evicts the responses tagged with any of keys in one request.
+func (p *Purger) Purge(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	method := http.MethodPost
+	if p.provider == ProviderVarnish {
+		method = "PURGE"
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Surrogate-Key", strings.Join(keys, " "))
+	if p.token != "" {
+		if p.provider == ProviderFastly {
+			req.Header.Set("Fastly-Key", p.token)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+p.token)
+		}
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s purge: %w", p.provider, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s purge: %s", p.provider, resp.Status)
+	}
+	return nil
+}
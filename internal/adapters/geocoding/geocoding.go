@@ -0,0 +1,318 @@
+// Package geocoding resolves free-text addresses to coordinates with a
+// Nominatim or Photon server.
+package geocoding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// Geocoder providers.
+const (
+	ProviderNominatim = "nominatim"
+	ProviderPhoton    = "photon"
+)
+
+// Client implements ports.Geocoder against a Nominatim or Photon server.
+// Requests are throttled to the server's rate, as public instances ban
+// clients that exceed their usage policy (one request a second for
+// nominatim.openstreetmap.org).
+type Client struct {
+	client    *http.Client
+	provider  string
+	base      string
+	userAgent string
+	limiter   *rate.Limiter
+}
+
+// New creates a geocoder for provider at baseURL sending at most
+// perSecond requests a second. userAgent identifies BilboPass, which
+// Nominatim requires.
+func New(provider, baseURL, userAgent string, perSecond float64) (*Client, error) {
+	if provider != ProviderNominatim && provider != ProviderPhoton {
+		return nil, fmt.Errorf("geocoder provider must be %s or %s", ProviderNominatim, ProviderPhoton)
+	}
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("geocoder url must be an http(s) URL")
+	}
+	if perSecond <= 0 {
+		return nil, fmt.Errorf("geocoder rate must be positive")
+	}
+	return &Client{
+		client:    &http.Client{Timeout: 5 * time.Second},
+		provider:  provider,
+		base:      u.String(),
+		userAgent: userAgent,
+		limiter:   rate.NewLimiter(rate.Limit(perSecond), 1),
+	}, nil
+}
+
+// Geocode returns the places matching q.Text, best first. It waits its turn
+// under the rate limit, failing if ctx ends first.
+func (g *Client) Geocode(ctx context.Context, q domain.GeocodeQuery) ([]domain.Place, error) {
+	if err := g.limiter.Wait(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", g.provider, err)
+	}
+	if g.provider == ProviderPhoton {
+		return g.photon(ctx, q)
+	}
+	return g.nominatim(ctx, q)
+}
+
+// Reverse returns the place nearest p with its street and neighborhood, or
+// nil when the server has none. It waits its turn like Geocode.
+func (g *Client) Reverse(ctx context.Context, p domain.GeoPoint, lang string) (*domain.Place, error) {
+	if err := g.limiter.Wait(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", g.provider, err)
+	}
+	if g.provider == ProviderPhoton {
+		return g.photonReverse(ctx, p, lang)
+	}
+	return g.nominatimReverse(ctx, p, lang)
+}
+
+func (g *Client) get(ctx context.Context, endpoint string, lang string, into any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	if g.userAgent != "" {
+		req.Header.Set("User-Agent", g.userAgent)
+	}
+	if lang != "" {
+		req.Header.Set("Accept-Language", lang)
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", g.provider, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(into); err != nil {
+		return fmt.Errorf("%s: %w", g.provider, err)
+	}
+	return nil
+}
+
+// nominatimBias is how far, in degrees, around the point a search is near
+// Nominatim prefers results; it is a preference, not a bound.
+const nominatimBias = 0.25
+
+func (g *Client) nominatim(ctx context.Context, q domain.GeocodeQuery) ([]domain.Place, error) {
+	params := url.Values{
+		"q":      {q.Text},
+		"format": {"jsonv2"},
+		"limit":  {strconv.Itoa(q.Limit)},
+	}
+	if q.Near != nil {
+		params.Set("viewbox", fmt.Sprintf("%f,%f,%f,%f",
+			q.Near.Lon-nominatimBias, q.Near.Lat+nominatimBias, q.Near.Lon+nominatimBias, q.Near.Lat-nominatimBias))
+	}
+	var body []struct {
+		Name        string `json:"name"`
+		DisplayName string `json:"display_name"`
+		Lat         string `json:"lat"`
+		Lon         string `json:"lon"`
+		Type        string `json:"type"`
+	}
+	if err := g.get(ctx, g.base+"/search?"+params.Encode(), q.Lang, &body); err != nil {
+		return nil, err
+	}
+
+	places := make([]domain.Place, 0, len(body))
+	for _, r := range body {
+		lat, errLat := strconv.ParseFloat(r.Lat, 64)
+		lon, errLon := strconv.ParseFloat(r.Lon, 64)
+		if errLat != nil || errLon != nil {
+			continue
+		}
+		name := r.Name
+		if name == "" {
+			name, _, _ = strings.Cut(r.DisplayName, ",")
+		}
+		places = append(places, domain.Place{
+			Name:     name,
+			Label:    r.DisplayName,
+			Location: domain.GeoPoint{Lat: lat, Lon: lon},
+			Kind:     r.Type,
+		})
+	}
+	return places, nil
+}
+
+func (g *Client) photon(ctx context.Context, q domain.GeocodeQuery) ([]domain.Place, error) {
+	params := url.Values{
+		"q":     {q.Text},
+		"limit": {strconv.Itoa(q.Limit)},
+	}
+	if q.Lang != "" {
+		params.Set("lang", q.Lang)
+	}
+	if q.Near != nil {
+		params.Set("lat", strconv.FormatFloat(q.Near.Lat, 'f', -1, 64))
+		params.Set("lon", strconv.FormatFloat(q.Near.Lon, 'f', -1, 64))
+	}
+	var body struct {
+		Features []struct {
+			Geometry struct {
+				Coordinates []float64 `json:"coordinates"` // lon, lat
+			} `json:"geometry"`
+			Properties struct {
+				Name        string `json:"name"`
+				Street      string `json:"street"`
+				HouseNumber string `json:"housenumber"`
+				City        string `json:"city"`
+				Type        string `json:"type"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := g.get(ctx, g.base+"/api?"+params.Encode(), "", &body); err != nil {
+		return nil, err
+	}
+
+	places := make([]domain.Place, 0, len(body.Features))
+	for _, f := range body.Features {
+		if len(f.Geometry.Coordinates) < 2 {
+			continue
+		}
+		p := f.Properties
+		street := strings.TrimSpace(p.Street + " " + p.HouseNumber)
+		name := p.Name
+		if name == "" {
+			name = street
+		}
+		var label []string
+		for _, part := range []string{p.Name, street, p.City} {
+			if part != "" && (len(label) == 0 || label[len(label)-1] != part) {
+				label = append(label, part)
+			}
+		}
+		places = append(places, domain.Place{
+			Name:     name,
+			Label:    strings.Join(label, ", "),
+			Location: domain.GeoPoint{Lat: f.Geometry.Coordinates[1], Lon: f.Geometry.Coordinates[0]},
+			Kind:     p.Type,
+		})
+	}
+	return places, nil
+}
+
+// nominatimStreetZoom asks Nominatim for street-level detail when reverse
+// geocoding, rather than the nearest building.
+const nominatimStreetZoom = "17"
+
+func (g *Client) nominatimReverse(ctx context.Context, p domain.GeoPoint, lang string) (*domain.Place, error) {
+	params := url.Values{
+		"lat":            {strconv.FormatFloat(p.Lat, 'f', -1, 64)},
+		"lon":            {strconv.FormatFloat(p.Lon, 'f', -1, 64)},
+		"format":         {"jsonv2"},
+		"zoom":           {nominatimStreetZoom},
+		"addressdetails": {"1"},
+	}
+	var body struct {
+		Error       string `json:"error"` // "Unable to geocode", with status 200
+		Name        string `json:"name"`
+		DisplayName string `json:"display_name"`
+		Type        string `json:"type"`
+		Address     struct {
+			Road          string `json:"road"`
+			Pedestrian    string `json:"pedestrian"`
+			Footway       string `json:"footway"`
+			Neighbourhood string `json:"neighbourhood"`
+			Quarter       string `json:"quarter"`
+			Suburb        string `json:"suburb"`
+			CityDistrict  string `json:"city_district"`
+		} `json:"address"`
+	}
+	if err := g.get(ctx, g.base+"/reverse?"+params.Encode(), lang, &body); err != nil {
+		return nil, err
+	}
+	if body.Error != "" || body.DisplayName == "" {
+		return nil, nil
+	}
+	a := body.Address
+	place := &domain.Place{
+		Name:         body.Name,
+		Label:        body.DisplayName,
+		Location:     p,
+		Kind:         body.Type,
+		Street:       firstNonEmpty(a.Road, a.Pedestrian, a.Footway),
+		Neighborhood: firstNonEmpty(a.Neighbourhood, a.Quarter, a.Suburb, a.CityDistrict),
+	}
+	if place.Name == "" {
+		place.Name = firstNonEmpty(place.Street, place.Neighborhood)
+	}
+	return place, nil
+}
+
+func (g *Client) photonReverse(ctx context.Context, p domain.GeoPoint, lang string) (*domain.Place, error) {
+	params := url.Values{
+		"lat":   {strconv.FormatFloat(p.Lat, 'f', -1, 64)},
+		"lon":   {strconv.FormatFloat(p.Lon, 'f', -1, 64)},
+		"limit": {"1"},
+	}
+	if lang != "" {
+		params.Set("lang", lang)
+	}
+	var body struct {
+		Features []struct {
+			Properties struct {
+				Name     string `json:"name"`
+				Street   string `json:"street"`
+				District string `json:"district"`
+				Locality string `json:"locality"`
+				City     string `json:"city"`
+				Type     string `json:"type"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := g.get(ctx, g.base+"/reverse?"+params.Encode(), "", &body); err != nil {
+		return nil, err
+	}
+	if len(body.Features) == 0 {
+		return nil, nil
+	}
+	f := body.Features[0].Properties
+	place := &domain.Place{
+		Name:         firstNonEmpty(f.Name, f.Street),
+		Location:     p,
+		Kind:         f.Type,
+		Street:       f.Street,
+		Neighborhood: firstNonEmpty(f.Locality, f.District),
+	}
+	// A street feature has its own name as name and no street.
+	if place.Street == "" && f.Type == "street" {
+		place.Street = f.Name
+	}
+	var label []string
+	for _, part := range []string{place.Name, place.Street, place.Neighborhood, f.City} {
+		if part != "" && !slices.Contains(label, part) {
+			label = append(label, part)
+		}
+	}
+	place.Label = strings.Join(label, ", ")
+	return place, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// TripAccessibleSpaceHandler returns whether the wheelchair and stroller
+// spaces on a trip are currently free.
+// GET /v1/trips/:id/accessible-space
+func TripAccessibleSpaceHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		space, err := deps.Accessibility.ForTrip(c.Context(), c.Params("id"), time.Now())
+		switch {
+		case err == nil:
+			c.Set("Cache-Control", "public, max-age=30")
+			return c.JSON(space)
+		case errors.Is(err, usecases.ErrTripNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
+
+// ReportAccessibleSpaceHandler records what a rider on board sees of a
+// trip's wheelchair and stroller spaces.
+// POST /v1/trips/:id/accessible-space
+func ReportAccessibleSpaceHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var r domain.AccessibleSpaceReport
+		if err := c.BodyParser(&r); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		r.TripID = c.Params("id")
+		err := deps.Accessibility.Report(c.Context(), currentUserID(c), &r, time.Now())
+		switch {
+		case err == nil:
+			return c.Status(fiber.StatusCreated).JSON(r)
+		case errors.Is(err, usecases.ErrTripNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errBadRequest(c, err.Error())
+		}
+	}
+}
+
+// VehicleAccessibleSpaceHandler ingests a batch of accessible space reports
+// from on-board systems.
+// POST /v1/admin/accessible-space
+func VehicleAccessibleSpaceHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Reports []domain.AccessibleSpaceReport `json:"reports"`
+		}
+		if err := c.BodyParser(&body); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		if err := deps.Accessibility.ReportFromVehicles(c.Context(), body.Reports, time.Now()); err != nil {
+			return errBadRequest(c, err.Error())
+		}
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"accepted": len(body.Reports)})
+	}
+}
@@ -25,7 +25,10 @@ func AccessLogMiddleware() fiber.Handler {
 		// Get response details
 		status := c.Response().StatusCode()
 		latency := time.Since(start)
-		bytesOut := len(c.Response().Body())
+		bytesOut := 0
+		if !c.Response().IsBodyStream() { // reading a stream would drain it
+			bytesOut = len(c.Response().Body())
+		}
 
 		// Log attributes
 		attrs := []slog.Attr{
@@ -0,0 +1,30 @@
+package http
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdminAuthMiddleware guards operator endpoints with a static bearer token.
+// When no token is configured, all admin requests are rejected.
+func AdminAuthMiddleware(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if token == "" {
+			return errForbidden(c, "admin API is disabled")
+		}
+
+		auth := c.Get(fiber.HeaderAuthorization)
+		given, ok := strings.CutPrefix(auth, "Bearer ")
+		if !ok || given == "" {
+			return errUnauthorized(c, "missing bearer token")
+		}
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			return errUnauthorized(c, "invalid admin token")
+		}
+
+		c.Set("Cache-Control", "no-store")
+		return c.Next()
+	}
+}
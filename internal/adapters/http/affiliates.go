@@ -0,0 +1,126 @@
+package http
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// NearbyAffiliatesHandler returns active affiliates near a point, nearest first.
+// GET /v1/affiliates/nearby?lat=&lon=&radius=1000&limit=20
+func NearbyAffiliatesHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		lat := c.QueryFloat("lat", 0)
+		lon := c.QueryFloat("lon", 0)
+		radius := c.QueryFloat("radius", 1000)
+
+		if lat == 0 || lon == 0 {
+			return errBadRequest(c, "lat and lon are required")
+		}
+		if radius <= 0 || radius > 10000 {
+			return errBadRequest(c, "radius must be between 1 and 10000 meters")
+		}
+
+		affiliates, err := deps.Affiliates.Nearby(c.Context(), lat, lon, radius, c.QueryInt("limit", 20))
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		if affiliates == nil {
+			affiliates = []domain.Affiliate{}
+		}
+
+		c.Set("Cache-Control", "public, max-age=300")
+		return c.JSON(affiliates)
+	}
+}
+
+// ListAffiliatesHandler returns all affiliates, including inactive ones.
+// GET /v1/affiliates
+func ListAffiliatesHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		affiliates, err := deps.Affiliates.List(c.Context())
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		if affiliates == nil {
+			affiliates = []domain.Affiliate{}
+		}
+		return c.JSON(affiliates)
+	}
+}
+
+// GetAffiliateHandler returns one affiliate.
+// GET /v1/affiliates/:id
+func GetAffiliateHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		affiliate, err := deps.Affiliates.Get(c.Context(), c.Params("id"))
+		switch {
+		case err == nil:
+			return c.JSON(affiliate)
+		case errors.Is(err, usecases.ErrAffiliateNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
+
+// CreateAffiliateHandler adds an affiliate. It is active unless the body
+// says otherwise.
+// POST /v1/affiliates
+func CreateAffiliateHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		affiliate := domain.Affiliate{Active: true}
+		if err := c.BodyParser(&affiliate); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		affiliate.ID = ""
+		affiliate.Distance = nil
+		if err := deps.Affiliates.Create(c.Context(), &affiliate); err != nil {
+			return errBadRequest(c, err.Error())
+		}
+		return c.Status(fiber.StatusCreated).JSON(affiliate)
+	}
+}
+
+// UpdateAffiliateHandler replaces an affiliate's details.
+// PUT /v1/affiliates/:id
+func UpdateAffiliateHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var affiliate domain.Affiliate
+		if err := c.BodyParser(&affiliate); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		affiliate.ID = c.Params("id")
+		affiliate.Distance = nil
+		err := deps.Affiliates.Update(c.Context(), &affiliate)
+		switch {
+		case err == nil:
+			return c.JSON(affiliate)
+		case errors.Is(err, usecases.ErrAffiliateNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errBadRequest(c, err.Error())
+		}
+	}
+}
+
+// DeleteAffiliateHandler deactivates an affiliate. Coupons already issued
+// for it stay valid.
+// DELETE /v1/affiliates/:id
+func DeleteAffiliateHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := deps.Affiliates.Deactivate(c.Context(), c.Params("id"))
+		switch {
+		case err == nil:
+			return c.SendStatus(fiber.StatusNoContent)
+		case errors.Is(err, usecases.ErrAffiliateNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
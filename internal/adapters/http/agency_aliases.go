@@ -0,0 +1,66 @@
+package http
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// ListAgencyAliasesHandler returns the source feed agencies merged into an agency.
+// GET /v1/admin/agencies/:slug/aliases
+func ListAgencyAliasesHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		aliases, err := deps.AgencyAliases.List(c.Context(), c.Params("slug"))
+		switch {
+		case err == nil:
+			return c.JSON(fiber.Map{"aliases": aliases})
+		case errors.Is(err, usecases.ErrAgencyNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
+
+// CreateAgencyAliasHandler merges an agency of another source feed into an
+// agency. It takes effect on the next ingestion of the source feed.
+// POST /v1/admin/agencies/:slug/aliases
+func CreateAgencyAliasHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var alias domain.AgencyAlias
+		if err := c.BodyParser(&alias); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		err := deps.AgencyAliases.Add(c.Context(), c.Params("slug"), &alias)
+		switch {
+		case err == nil:
+			return c.Status(fiber.StatusCreated).JSON(alias)
+		case errors.Is(err, usecases.ErrAgencyNotFound):
+			return errNotFound(c, err.Error())
+		case errors.Is(err, usecases.ErrAliasExists):
+			return errConflict(c, err.Error())
+		default:
+			return errBadRequest(c, err.Error())
+		}
+	}
+}
+
+// DeleteAgencyAliasHandler removes an alias; the source feed's copy of the
+// agency is ingested again on its next run.
+// DELETE /v1/admin/agencies/:slug/aliases/:source?agency_id=
+func DeleteAgencyAliasHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := deps.AgencyAliases.Remove(c.Context(), c.Params("slug"), c.Params("source"), c.Query("agency_id"))
+		switch {
+		case err == nil:
+			return c.SendStatus(fiber.StatusNoContent)
+		case errors.Is(err, usecases.ErrAgencyNotFound), errors.Is(err, usecases.ErrAliasNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package http
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// PutAgencyContactHandler replaces an agency's customer service phone, lost &
+// found and complaint links, returned on GET /v1/agencies/:slug.
+// PUT /v1/admin/agencies/:slug/contact
+func PutAgencyContactHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var contact domain.AgencyContact
+		if err := c.BodyParser(&contact); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		agency, err := deps.Agencies.UpdateContact(c.Context(), c.Params("slug"), &contact)
+		switch {
+		case err == nil:
+			return c.JSON(agency)
+		case errors.Is(err, usecases.ErrAgencyNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errBadRequest(c, err.Error())
+		}
+	}
+}
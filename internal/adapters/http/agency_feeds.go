@@ -0,0 +1,85 @@
+package http
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// ListAgencyFeedsHandler returns the agencies that are ingested and polled,
+// with their feed URLs.
+// GET /v1/admin/agencies
+func ListAgencyFeedsHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		feeds, err := deps.AgencyFeeds.List(c.Context())
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		return c.JSON(fiber.Map{"agencies": feeds})
+	}
+}
+
+// CreateAgencyFeedsHandler onboards an agency: its schedule is loaded on
+// the ingestor's next run and its realtime feeds polled within a minute.
+// POST /v1/admin/agencies
+func CreateAgencyFeedsHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var feeds domain.AgencyFeeds
+		if err := c.BodyParser(&feeds); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		err := deps.AgencyFeeds.Create(c.Context(), &feeds)
+		switch {
+		case err == nil:
+			return c.Status(fiber.StatusCreated).JSON(feeds)
+		case errors.Is(err, usecases.ErrInvalidAgencyFeeds):
+			return errBadRequest(c, err.Error())
+		case errors.Is(err, usecases.ErrAgencyFeedsExist):
+			return errConflict(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
+
+// PutAgencyFeedsHandler replaces an agency's name and feed URLs.
+// PUT /v1/admin/agencies/:slug
+func PutAgencyFeedsHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var feeds domain.AgencyFeeds
+		if err := c.BodyParser(&feeds); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		err := deps.AgencyFeeds.Update(c.Context(), c.Params("slug"), &feeds)
+		switch {
+		case err == nil:
+			return c.JSON(feeds)
+		case errors.Is(err, usecases.ErrInvalidAgencyFeeds):
+			return errBadRequest(c, err.Error())
+		case errors.Is(err, usecases.ErrAgencyFeedsNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
+
+// DeleteAgencyFeedsHandler stops ingesting and polling an agency. The
+// schedule already loaded stays.
+// DELETE /v1/admin/agencies/:slug
+func DeleteAgencyFeedsHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := deps.AgencyFeeds.Delete(c.Context(), c.Params("slug"))
+		switch {
+		case err == nil:
+			return c.SendStatus(fiber.StatusNoContent)
+		case errors.Is(err, usecases.ErrAgencyFeedsNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
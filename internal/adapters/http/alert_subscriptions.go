@@ -0,0 +1,63 @@
+package http
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// ListAlertSubscriptionsHandler returns the rider's delay alert subscriptions.
+// GET /v1/users/me/alert-subscriptions
+func ListAlertSubscriptionsHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		subs, err := deps.DelayAlerts.List(c.Context(), currentUserID(c))
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		if subs == nil {
+			subs = []domain.AlertSubscription{}
+		}
+		return c.JSON(fiber.Map{"subscriptions": subs})
+	}
+}
+
+// CreateAlertSubscriptionHandler subscribes the rider to push alerts when a
+// route or stop is delayed within a weekly time window.
+// POST /v1/users/me/alert-subscriptions
+func CreateAlertSubscriptionHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var sub domain.AlertSubscription
+		if err := c.BodyParser(&sub); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		sub.ID = ""
+		err := deps.DelayAlerts.Subscribe(c.Context(), currentUserID(c), &sub)
+		switch {
+		case err == nil:
+			return c.Status(fiber.StatusCreated).JSON(sub)
+		case errors.Is(err, usecases.ErrStopNotFound), errors.Is(err, usecases.ErrRouteNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errBadRequest(c, err.Error())
+		}
+	}
+}
+
+// DeleteAlertSubscriptionHandler removes one of the rider's alert subscriptions.
+// DELETE /v1/users/me/alert-subscriptions/:id
+func DeleteAlertSubscriptionHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := deps.DelayAlerts.Remove(c.Context(), currentUserID(c), c.Params("id"))
+		switch {
+		case err == nil:
+			return c.SendStatus(fiber.StatusNoContent)
+		case errors.Is(err, usecases.ErrAlertSubscriptionNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// ListAlertsHandler returns active service alerts.
+// GET /v1/alerts?agency=slug
+func ListAlertsHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		alerts, err := deps.Alerts.Active(c.Context(), c.Query("agency"), time.Now())
+		if err != nil {
+			if errors.Is(err, usecases.ErrAgencyNotFound) {
+				return errNotFound(c, err.Error())
+			}
+			return errInternal(c, err.Error())
+		}
+		return alertsResponse(c, alerts)
+	}
+}
+
+// RouteAlertsHandler returns active alerts affecting a route.
+// GET /v1/routes/:id/alerts
+func RouteAlertsHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if id == "" {
+			return errBadRequest(c, "route id is required")
+		}
+		surrogateKeys(c, usecases.RouteKey(id))
+		alerts, err := deps.Alerts.ForRoute(c.Context(), id, time.Now())
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		return alertsResponse(c, alerts)
+	}
+}
+
+// StopAlertsHandler returns active alerts affecting a stop.
+// GET /v1/stops/:id/alerts
+func StopAlertsHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if id == "" {
+			return errBadRequest(c, "stop id is required")
+		}
+		surrogateKeys(c, usecases.StopKey(id))
+		alerts, err := deps.Alerts.ForStop(c.Context(), id, time.Now())
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		return alertsResponse(c, alerts)
+	}
+}
+
+func alertsResponse(c *fiber.Ctx, alerts []domain.ServiceAlert) error {
+	if alerts == nil {
+		alerts = []domain.ServiceAlert{}
+	}
+	return c.JSON(fiber.Map{"data": alerts})
+}
@@ -0,0 +1,70 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// RoutePunctualityHandler returns a route's on-time performance, by default
+// over the last 30 days: totals, by hour of day, by day and its least
+// punctual stops.
+// GET /v1/analytics/routes/:id/punctuality?from=&to=
+func RoutePunctualityHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		from, to, err := parsePunctualityRange(c)
+		if err != nil {
+			return errBadRequest(c, err.Error())
+		}
+		stats, err := deps.Punctuality.Route(c.Context(), c.Params("id"), from, to, time.Now())
+		return punctualityResult(c, stats, err)
+	}
+}
+
+// NetworkPunctualityHandler returns the on-time performance of an agency, or
+// of every agency, by default over the last 30 days, with its least punctual
+// routes.
+// GET /v1/analytics/network/summary?agency=&from=&to=
+func NetworkPunctualityHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		from, to, err := parsePunctualityRange(c)
+		if err != nil {
+			return errBadRequest(c, err.Error())
+		}
+		stats, err := deps.Punctuality.Network(c.Context(), c.Query("agency"), from, to, time.Now())
+		return punctualityResult(c, stats, err)
+	}
+}
+
+// parsePunctualityRange reads ?from and ?to as RFC 3339 times; either may
+// be omitted.
+func parsePunctualityRange(c *fiber.Ctx) (from, to time.Time, err error) {
+	if raw := c.Query("from"); raw != "" {
+		if from, err = time.Parse(time.RFC3339, raw); err != nil {
+			return from, to, errors.New("from must be an RFC 3339 time")
+		}
+	}
+	if raw := c.Query("to"); raw != "" {
+		if to, err = time.Parse(time.RFC3339, raw); err != nil {
+			return from, to, errors.New("to must be an RFC 3339 time")
+		}
+	}
+	return from, to, nil
+}
+
+func punctualityResult(c *fiber.Ctx, stats any, err error) error {
+	switch {
+	case errors.Is(err, usecases.ErrRouteNotFound), errors.Is(err, usecases.ErrAgencyNotFound):
+		return errNotFound(c, err.Error())
+	case errors.Is(err, usecases.ErrInvalidPunctualityRange):
+		return errBadRequest(c, err.Error())
+	case err != nil:
+		return errInternal(c, err.Error())
+	}
+	// Rollups are refreshed hourly.
+	c.Set("Cache-Control", "public, max-age=3600")
+	return c.JSON(stats)
+}
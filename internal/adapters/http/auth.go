@@ -0,0 +1,73 @@
+package http
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+type credentialsRequest struct {
+	Email       string `json:"email"`
+	Password    string `json:"password"`
+	DisplayName string `json:"display_name"`
+}
+
+// RegisterHandler creates a rider account and returns a bearer token.
+// POST /v1/auth/register
+func RegisterHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req credentialsRequest
+		if err := c.BodyParser(&req); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		token, err := deps.Auth.Register(c.Context(), req.Email, req.Password, req.DisplayName)
+		switch {
+		case err == nil:
+			c.Set("Cache-Control", "no-store")
+			return c.Status(fiber.StatusCreated).JSON(token)
+		case errors.Is(err, usecases.ErrEmailTaken):
+			return errConflict(c, err.Error())
+		default:
+			return errBadRequest(c, err.Error())
+		}
+	}
+}
+
+// LoginHandler exchanges email and password for a bearer token.
+// POST /v1/auth/login
+func LoginHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req credentialsRequest
+		if err := c.BodyParser(&req); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		token, err := deps.Auth.Login(c.Context(), req.Email, req.Password)
+		switch {
+		case err == nil:
+			c.Set("Cache-Control", "no-store")
+			return c.JSON(token)
+		case errors.Is(err, usecases.ErrInvalidCredentials):
+			return errUnauthorized(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
+
+// MeHandler returns the authenticated rider's account.
+// GET /v1/me
+func MeHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := deps.Auth.Me(c.Context(), currentUserID(c))
+		switch {
+		case err == nil:
+			return c.JSON(user)
+		case errors.Is(err, usecases.ErrUserNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package http
+
+import (
+	"errors"
+	"mime"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// AgencyBrandingHandler returns an agency's logo, colors and a line badge
+// for each of its routes, so apps can draw them without bundling assets.
+// GET /v1/agencies/:slug/branding
+func AgencyBrandingHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		b, err := deps.Branding.Get(c.Context(), c.Params("slug"))
+		switch {
+		case errors.Is(err, usecases.ErrAgencyNotFound):
+			return errNotFound(c, err.Error())
+		case err != nil:
+			return errInternal(c, err.Error())
+		}
+		c.Set("Cache-Control", "public, max-age=3600")
+		return c.JSON(b)
+	}
+}
+
+// PutBrandingColorsHandler replaces an agency's brand colors.
+// PUT /v1/admin/agencies/:slug/branding
+func PutBrandingColorsHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var colors domain.AgencyBranding
+		if err := c.BodyParser(&colors); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		b, err := deps.Branding.UpdateColors(c.Context(), c.Params("slug"), &colors)
+		switch {
+		case err == nil:
+			return c.JSON(b)
+		case errors.Is(err, usecases.ErrAgencyNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errBadRequest(c, err.Error())
+		}
+	}
+}
+
+// UploadAgencyLogoHandler stores the request body (PNG, SVG or WebP) as the
+// agency's logo.
+// PUT /v1/admin/agencies/:slug/branding/logo
+func UploadAgencyLogoHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		url, err := deps.Branding.UploadLogo(c.Context(), c.Params("slug"), uploadType(c), c.Body())
+		return uploadResult(c, url, err)
+	}
+}
+
+// UploadRouteIconHandler stores the request body (PNG, SVG or WebP) as a
+// route's line badge icon.
+// PUT /v1/admin/agencies/:slug/branding/routes/:route_id/icon
+func UploadRouteIconHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		url, err := deps.Branding.UploadRouteIcon(c.Context(), c.Params("slug"), c.Params("route_id"), uploadType(c), c.Body())
+		return uploadResult(c, url, err)
+	}
+}
+
+// uploadType returns the upload's media type without parameters.
+func uploadType(c *fiber.Ctx) string {
+	t, _, _ := mime.ParseMediaType(c.Get(fiber.HeaderContentType))
+	return t
+}
+
+func uploadResult(c *fiber.Ctx, url string, err error) error {
+	switch {
+	case err == nil:
+		return c.JSON(fiber.Map{"url": url})
+	case errors.Is(err, usecases.ErrAgencyNotFound), errors.Is(err, usecases.ErrRouteNotFound):
+		return errNotFound(c, err.Error())
+	case errors.Is(err, usecases.ErrUploadsUnavailable):
+		return newError(c, fiber.StatusServiceUnavailable, "unavailable", err.Error())
+	case errors.Is(err, usecases.ErrInvalidAsset):
+		return errBadRequest(c, err.Error())
+	default:
+		return errInternal(c, err.Error())
+	}
+}
@@ -33,16 +33,22 @@ func CachingMiddleware() fiber.Handler {
 		case strings.HasPrefix(path, "/v1/agencies"):
 			ttl = "public, max-age=3600" // 1 hour for stable data
 
-		case path == "/metrics":
-			ttl = "no-cache" // Metrics are real-time
+		case path == "/metrics" || path == "/v1/stream":
+			ttl = "no-cache" // Metrics and event streams are real-time
 
 		case path == "/graphql":
 			ttl = "private, max-age=0" // GraphQL varies wildly
 
+		case path == "/v1/me" || strings.HasPrefix(path, "/v1/me/") || strings.HasPrefix(path, "/v1/users/me"):
+			ttl = "private, no-store" // Per-rider data
+
+		case path == "/v1/alerts" || strings.HasSuffix(path, "/alerts"):
+			ttl = "public, max-age=30" // Alerts change with each feed poll
+
 		case strings.HasPrefix(path, "/v1/stops/nearby"):
 			ttl = "public, max-age=300" // 5 min for location queries
 
-		case strings.HasPrefix(path, "/v1/stops/search"):
+		case strings.HasPrefix(path, "/v1/stops/search") || path == "/v1/search":
 			ttl = "public, max-age=300" // 5 min for search results
 
 		case strings.Contains(path, "/stops/") && strings.Contains(path, "/"):
@@ -0,0 +1,75 @@
+package http
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// cdnMaxAge is how long the CDN may keep static responses when changes
+// purge them; browsers still go by Cache-Control.
+const cdnMaxAge = "max-age=86400"
+
+// surrogateKeys tags the response with the surrogate keys of the stops,
+// routes and agencies it shows, so the CDN evicts it when they change (see
+// usecases.CDNPurgeService). CDNs strip the header before clients see it.
+func surrogateKeys(c *fiber.Ctx, keys ...string) {
+	if existing := c.GetRespHeader("Surrogate-Key"); existing != "" {
+		keys = append([]string{existing}, keys...)
+	}
+	c.Set("Surrogate-Key", strings.Join(keys, " "))
+}
+
+// cdnCacheable lets the CDN keep a tagged static response for a day, when
+// purging is configured to evict it on changes.
+func cdnCacheable(c *fiber.Ctx, deps *Dependencies) {
+	if deps.CDN.Enabled() {
+		c.Set("Surrogate-Control", cdnMaxAge)
+	}
+}
+
+// PurgeCDNHandler evicts responses from the CDN, by surrogate key or by the
+// agency's stops, routes and alerts changed since a time, for producers of
+// changes the ingestor and realtime poller do not purge themselves.
+// POST /v1/admin/cdn/purge {"keys":["stop:<uuid>","agency:metro_bilbao"]}
+// POST /v1/admin/cdn/purge {"agency":"metro_bilbao","since":"2026-03-02T04:00:00Z"}
+func PurgeCDNHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Keys   []string  `json:"keys"`
+			Agency string    `json:"agency"`
+			Since  time.Time `json:"since"`
+		}
+		if err := c.BodyParser(&body); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+
+		var (
+			purged []string
+			err    error
+		)
+		switch {
+		case len(body.Keys) > 0 && body.Agency == "":
+			purged, err = deps.CDN.Purge(c.Context(), body.Keys)
+		case len(body.Keys) == 0 && body.Agency != "" && !body.Since.IsZero():
+			purged, err = deps.CDN.PurgeChanges(c.Context(), body.Agency, body.Since, time.Now())
+		default:
+			return errBadRequest(c, "either keys, or agency and since, are required")
+		}
+		switch {
+		case errors.Is(err, usecases.ErrInvalidPurge):
+			return errBadRequest(c, err.Error())
+		case errors.Is(err, usecases.ErrAgencyNotFound):
+			return errNotFound(c, err.Error())
+		case errors.Is(err, usecases.ErrCDNUnavailable):
+			return newError(c, fiber.StatusServiceUnavailable, "unavailable", err.Error())
+		case err != nil:
+			return errInternal(c, err.Error())
+		}
+		return c.JSON(fiber.Map{"purged": purged})
+	}
+}
@@ -0,0 +1,87 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// ListChallengesHandler returns running challenges with the rider's progress.
+// GET /v1/me/challenges
+func ListChallengesHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		challenges, err := deps.Challenges.List(c.Context(), currentUserID(c), time.Now())
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		return c.JSON(fiber.Map{"data": challenges})
+	}
+}
+
+// EnrollChallengeHandler signs the rider up for a challenge.
+// POST /v1/me/challenges/:id/enroll
+func EnrollChallengeHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		progress, err := deps.Challenges.Enroll(c.Context(), currentUserID(c), c.Params("id"), time.Now())
+		if err != nil {
+			switch {
+			case errors.Is(err, usecases.ErrChallengeNotFound):
+				return errNotFound(c, err.Error())
+			case errors.Is(err, usecases.ErrChallengeClosed):
+				return errConflict(c, err.Error())
+			default:
+				return errInternal(c, err.Error())
+			}
+		}
+		return c.JSON(progress)
+	}
+}
+
+// ClaimChallengeRewardHandler issues the coupon for a completed challenge at
+// the affiliate nearest to the given location.
+// POST /v1/me/challenges/:id/claim?lat=&lon=
+func ClaimChallengeRewardHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		lat := c.QueryFloat("lat", 0)
+		lon := c.QueryFloat("lon", 0)
+		if lat == 0 || lon == 0 {
+			return errBadRequest(c, "lat and lon are required")
+		}
+
+		comp, err := deps.Challenges.Claim(c.Context(), currentUserID(c), c.Params("id"), lat, lon)
+		switch {
+		case err == nil:
+			return c.Status(fiber.StatusCreated).JSON(comp)
+		case errors.Is(err, usecases.ErrChallengeNotFound):
+			return errNotFound(c, err.Error())
+		case errors.Is(err, usecases.ErrNotEnrolled),
+			errors.Is(err, usecases.ErrChallengeIncomplete),
+			errors.Is(err, usecases.ErrRewardClaimed):
+			return errConflict(c, err.Error())
+		case errors.Is(err, usecases.ErrRewardsUnavailable):
+			return newError(c, fiber.StatusServiceUnavailable, "unavailable", err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
+
+// CreateChallengeHandler creates a new challenge.
+// POST /v1/admin/challenges
+func CreateChallengeHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var ch domain.Challenge
+		if err := c.BodyParser(&ch); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		ch.ID = ""
+		if err := deps.Challenges.Create(c.Context(), &ch); err != nil {
+			return errBadRequest(c, err.Error())
+		}
+		return c.Status(fiber.StatusCreated).JSON(ch)
+	}
+}
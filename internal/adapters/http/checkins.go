@@ -0,0 +1,55 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// CheckInHandler records that the rider boarded a trip at a stop, so they can
+// be compensated if it is delayed. time defaults to now.
+// POST /v1/checkins
+func CheckInHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var in domain.CheckIn
+		if err := c.BodyParser(&in); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		if in.TripID == "" || in.StopID == "" {
+			return errBadRequest(c, "trip_id and stop_id are required")
+		}
+		in.ID = ""
+		in.UserID = currentUserID(c)
+
+		err := deps.CheckIns.CheckIn(c.Context(), &in, time.Now())
+		switch {
+		case err == nil:
+			return c.Status(fiber.StatusCreated).JSON(in)
+		case errors.Is(err, usecases.ErrTripNotFound):
+			return errNotFound(c, err.Error())
+		case errors.Is(err, usecases.ErrStopNotOnTrip), errors.Is(err, usecases.ErrInvalidCheckInTime):
+			return errBadRequest(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
+
+// ListCheckInsHandler returns the rider's most recent check-ins.
+// GET /v1/users/me/checkins?limit=50
+func ListCheckInsHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		checkins, err := deps.CheckIns.List(c.Context(), currentUserID(c), c.QueryInt("limit", 50))
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		if checkins == nil {
+			checkins = []domain.CheckIn{}
+		}
+		return c.JSON(fiber.Map{"checkins": checkins})
+	}
+}
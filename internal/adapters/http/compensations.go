@@ -0,0 +1,72 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// ListCompensationsHandler returns the coupons issued to the rider, newest first.
+// GET /v1/users/me/compensations
+func ListCompensationsHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		comps, err := deps.Compensations.ListForUser(c.Context(), currentUserID(c))
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		if comps == nil {
+			comps = []domain.Compensation{}
+		}
+		return c.JSON(fiber.Map{"compensations": comps})
+	}
+}
+
+// VerifyCompensationHandler tells an affiliate terminal whether a coupon can be redeemed.
+// GET /v1/compensations/:code/verify
+func VerifyCompensationHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		staff, err := deps.Auth.Me(c.Context(), currentUserID(c))
+		if err != nil {
+			return compensationError(c, err)
+		}
+		check, err := deps.Compensations.Verify(c.Context(), c.Params("code"), staff, time.Now())
+		if err != nil {
+			return compensationError(c, err)
+		}
+		return c.JSON(check)
+	}
+}
+
+// RedeemCompensationHandler redeems a coupon at an affiliate terminal.
+// POST /v1/compensations/:code/redeem
+func RedeemCompensationHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		staff, err := deps.Auth.Me(c.Context(), currentUserID(c))
+		if err != nil {
+			return compensationError(c, err)
+		}
+		comp, err := deps.Compensations.Redeem(c.Context(), c.Params("code"), staff, time.Now())
+		if err != nil {
+			return compensationError(c, err)
+		}
+		return c.JSON(comp)
+	}
+}
+
+func compensationError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, usecases.ErrCouponNotFound):
+		return errNotFound(c, err.Error())
+	case errors.Is(err, usecases.ErrCouponRedeemed), errors.Is(err, usecases.ErrCouponExpired):
+		return errConflict(c, err.Error())
+	case errors.Is(err, usecases.ErrNotAffiliateStaff), errors.Is(err, usecases.ErrCouponOtherAffiliate),
+		errors.Is(err, usecases.ErrUserNotFound):
+		return errForbidden(c, err.Error())
+	default:
+		return errInternal(c, err.Error())
+	}
+}
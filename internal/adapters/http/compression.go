@@ -0,0 +1,147 @@
+package http
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+
+	"github.com/samirrijal/bilbopass/internal/pkg/metrics"
+)
+
+// CompressionConfig tunes CompressionMiddleware.
+type CompressionConfig struct {
+	Brotli      bool // offer brotli to clients accepting it
+	BrotliLevel int  // 0-11
+	GzipLevel   int  // 1-9
+	MinBytes    int  // smaller responses are sent as they are
+}
+
+// DefaultCompressionConfig favors speed, as most responses are built per
+// request: brotli 4 compresses JSON better than gzip 1 at about its cost.
+var DefaultCompressionConfig = CompressionConfig{Brotli: true, BrotliLevel: 4, GzipLevel: 1, MinBytes: 1024}
+
+// noCompressionKey marks responses withoutCompression opted out.
+const noCompressionKey = "no_compression"
+
+// withoutCompression opts a route out of response compression.
+func withoutCompression(c *fiber.Ctx) error {
+	c.Locals(noCompressionKey, true)
+	return c.Next()
+}
+
+// CompressionMiddleware compresses responses with brotli or gzip, as the
+// client accepts, and records the ratio. Responses smaller than MinBytes,
+// already encoded, streamed, of compressed media types or opted out with
+// withoutCompression are sent as they are, and so are those compression
+// would not shrink. A strong ETag is made weak on compressed responses:
+// their bytes differ from the identity ones the tag was computed for, and
+// If-None-Match compares weakly, so it still matches either.
+func CompressionMiddleware(cfg CompressionConfig) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+		if c.Locals(noCompressionKey) != nil || c.Method() == fiber.MethodHead {
+			return nil
+		}
+		resp := c.Response()
+		if resp.IsBodyStream() || len(resp.Header.Peek(fiber.HeaderContentEncoding)) > 0 {
+			return nil
+		}
+		body := resp.Body()
+		if len(body) < cfg.MinBytes || !compressible(string(resp.Header.ContentType())) {
+			return nil
+		}
+
+		// The response depends on Accept-Encoding from here on, compressed
+		// or not.
+		c.Vary(fiber.HeaderAcceptEncoding)
+		encoding := acceptedEncoding(c.Get(fiber.HeaderAcceptEncoding), cfg.Brotli)
+		var out []byte
+		switch encoding {
+		case "br":
+			out = fasthttp.AppendBrotliBytesLevel(nil, body, cfg.BrotliLevel)
+		case "gzip":
+			out = fasthttp.AppendGzipBytesLevel(nil, body, cfg.GzipLevel)
+		default:
+			return nil
+		}
+		if len(out) >= len(body) {
+			return nil
+		}
+
+		path := c.Route().Path
+		if path == "" {
+			path = c.Path()
+		}
+		metrics.ObserveCompression(encoding, path, len(body), len(out))
+		resp.SetBodyRaw(out)
+		resp.Header.Set(fiber.HeaderContentEncoding, encoding)
+		if etag := string(resp.Header.Peek(fiber.HeaderETag)); strings.HasPrefix(etag, `"`) {
+			resp.Header.Set(fiber.HeaderETag, "W/"+etag)
+		}
+		return nil
+	}
+}
+
+// compressible reports whether responses of contentType shrink when
+// compressed; images other than SVG, audio, video and archives are
+// compressed already.
+func compressible(contentType string) bool {
+	contentType, _, _ = strings.Cut(contentType, ";")
+	contentType = strings.TrimSpace(strings.ToLower(contentType))
+	switch {
+	case contentType == "":
+		return false
+	case contentType == "image/svg+xml":
+		return true
+	case strings.HasPrefix(contentType, "image/"),
+		strings.HasPrefix(contentType, "audio/"),
+		strings.HasPrefix(contentType, "video/"),
+		strings.HasPrefix(contentType, "font/woff"):
+		return false
+	}
+	switch contentType {
+	case "application/zip", "application/gzip", "application/x-gzip", "application/zstd",
+		"application/x-brotli", "application/pdf", "application/octet-stream",
+		"application/vnd.apache.parquet":
+		return false
+	}
+	return true
+}
+
+// acceptedEncoding returns the encoding to compress with for an
+// Accept-Encoding header: br when brotli is on and the client takes it,
+// else gzip, else "". Codings with q=0 are refused; * stands for any not
+// listed.
+func acceptedEncoding(header string, brotli bool) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		ok := true
+		if name, value, found := strings.Cut(strings.TrimSpace(params), "="); found && strings.TrimSpace(name) == "q" {
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			ok = err == nil && q > 0
+		}
+		accepted[coding] = ok
+	}
+	takes := func(coding string) bool {
+		if ok, listed := accepted[coding]; listed {
+			return ok
+		}
+		return accepted["*"]
+	}
+	switch {
+	case brotli && takes("br"):
+		return "br"
+	case takes("gzip"):
+		return "gzip"
+	}
+	return ""
+}
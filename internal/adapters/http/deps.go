@@ -5,6 +5,7 @@ import (
 	"github.com/samirrijal/bilbopass/internal/adapters/postgres"
 	"github.com/samirrijal/bilbopass/internal/adapters/valkey"
 	"github.com/samirrijal/bilbopass/internal/core/usecases"
+	"github.com/samirrijal/bilbopass/internal/pkg/auth"
 )
 
 // Dependencies holds all services needed by HTTP handlers.
@@ -17,7 +18,51 @@ type Dependencies struct {
 	Journeys      *usecases.JourneyService
 	Realtime      *usecases.RealtimeService
 	Compensations *usecases.CompensationService
+	Affiliates    *usecases.AffiliateService
+	ShortLinks    *usecases.ShortLinkService
+	History       *usecases.HistoryService
+	CheckIns      *usecases.CheckInService
+	Devices       *usecases.DeviceService
+	Preferences   *usecases.NotificationPreferenceService
+	Favorites     *usecases.FavoriteService
+	Events        *usecases.EventService
+	DelayAlerts   *usecases.AlertSubscriptionService
+	Accessibility *usecases.AccessibleSpaceService
+	Tiles         *usecases.TileService
+	Challenges    *usecases.ChallengeService
+	SLA           *usecases.SLAService
+	Alerts        *usecases.AlertService
+	FeedQuality   *usecases.FeedQualityService
+	FeedConfigs   *usecases.FeedConfigService
+	FeedHistory   *usecases.FeedHistoryService
+	FeedStages    *usecases.FeedStageService
+	Branding      *usecases.BrandingService
+	RTFeeds       *usecases.RealtimeFeedStatusService
+	Offline       *usecases.OfflineService
+	Sync          *usecases.SyncService
+	Occupancy     *usecases.OccupancyService
+	Coverage      *usecases.RealtimeCoverageService
+	Fleet         *usecases.FleetService
+	Segments      *usecases.SegmentService
+	Exports       *usecases.TimetableExportService
+	Search        *usecases.SearchService
+	GlobalSearch  *usecases.GlobalSearchService
+	Geocode       *usecases.GeocodeService
+	Punctuality   *usecases.PunctualityService
+	AgencyAliases *usecases.AgencyAliasService
+	AgencyFeeds   *usecases.AgencyFeedsService
+	PushKeys      *usecases.PushKeyService
+	Workflows     *usecases.WorkflowService
+	Auth          *usecases.AuthService
+	StaticVersion *usecases.StaticVersionService // nil leaves conditional requests to per-entity ETags
+	CDN           *usecases.CDNPurgeService      // nil tags responses without letting the CDN keep them
 	NATS          *nats.Conn
 	DB            *postgres.DB
 	Cache         *valkey.Cache
+	AdminToken    string
+	Tokens        *auth.JWT         // verifies rider bearer tokens; nil accepts X-User-ID
+	VAPIDKey      string            // Web Push application server key; empty when Web Push is off
+	GeocodeLimit  int               // geocode lookups a minute per client IP; 0 uses the default of 30
+	Compression   CompressionConfig // zero uses DefaultCompressionConfig
+	GraphQL       GraphQLLimits     // fields left zero take DefaultGraphQLLimits values
 }
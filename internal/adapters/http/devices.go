@@ -0,0 +1,71 @@
+package http
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// RegisterDeviceHandler registers the rider's app install (FCM token) or
+// browser (Web Push subscription) for push notifications.
+// POST /v1/users/me/devices
+func RegisterDeviceHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var d domain.Device
+		if err := c.BodyParser(&d); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		d.ID = ""
+		if err := deps.Devices.Register(c.Context(), currentUserID(c), &d); err != nil {
+			return errBadRequest(c, err.Error())
+		}
+		return c.Status(fiber.StatusCreated).JSON(d)
+	}
+}
+
+// ListDevicesHandler returns the rider's registered devices.
+// GET /v1/users/me/devices
+func ListDevicesHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		devices, err := deps.Devices.List(c.Context(), currentUserID(c))
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		if devices == nil {
+			devices = []domain.Device{}
+		}
+		return c.JSON(fiber.Map{"devices": devices})
+	}
+}
+
+// DeleteDeviceHandler stops push notifications to one of the rider's devices.
+// DELETE /v1/users/me/devices/:id
+func DeleteDeviceHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := deps.Devices.Remove(c.Context(), currentUserID(c), c.Params("id"))
+		switch {
+		case err == nil:
+			return c.SendStatus(fiber.StatusNoContent)
+		case errors.Is(err, usecases.ErrDeviceNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
+
+// VAPIDKeyHandler returns the application server key browsers need to create
+// a Web Push subscription.
+// GET /v1/push/vapid-key
+func VAPIDKeyHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if deps.VAPIDKey == "" {
+			return errNotFound(c, "web push is not enabled")
+		}
+		c.Set("Cache-Control", "public, max-age=3600")
+		return c.JSON(fiber.Map{"public_key": deps.VAPIDKey})
+	}
+}
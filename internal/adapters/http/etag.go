@@ -3,12 +3,18 @@ package http
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
 )
 
 // ETagMiddleware computes a weak ETag from the response body
 // and returns 304 Not Modified if the client already has it.
+// Responses whose handler set an ETag (see notModified) are left alone.
 func ETagMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Process request first
@@ -16,8 +22,12 @@ func ETagMiddleware() fiber.Handler {
 			return err
 		}
 
-		// Only apply to successful GET responses with a body
-		if c.Method() != fiber.MethodGet || c.Response().StatusCode() != 200 {
+		// Only apply to successful GET responses with a body, not streamed
+		if c.Method() != fiber.MethodGet || c.Response().StatusCode() != 200 || c.Response().IsBodyStream() {
+			return nil
+		}
+
+		if len(c.Response().Header.Peek(fiber.HeaderETag)) > 0 {
 			return nil
 		}
 
@@ -43,3 +53,56 @@ func ETagMiddleware() fiber.Handler {
 		return nil
 	}
 }
+
+// notModified sets a weak ETag and Last-Modified for a single entity from its
+// ID and updated_at, and reports whether the client's copy is current; the
+// status is then 304 and the handler should return without a body. An ETag
+// already set by staticNotModified is kept.
+func notModified(c *fiber.Ctx, id string, updatedAt time.Time) bool {
+	if updatedAt.IsZero() || len(c.Response().Header.Peek(fiber.HeaderETag)) > 0 {
+		return false
+	}
+	etag := `W/"` + id + "-" + strconv.FormatInt(updatedAt.UnixMicro(), 36) + `"`
+	c.Set(fiber.HeaderETag, etag)
+	c.Set(fiber.HeaderLastModified, updatedAt.UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT"))
+	if c.Get(fiber.HeaderIfNoneMatch) == etag {
+		c.Status(fiber.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// staticNotModified sets a strong ETag and Last-Modified from the version of
+// the static data, and reports whether the client's copy is current before
+// the handler queries anything; the status is then 304 and the handler
+// should return without a body. Without a version yet it does nothing. The
+// version is polled, so changes show within a minute.
+func staticNotModified(c *fiber.Ctx, versions *usecases.StaticVersionService) bool {
+	if versions == nil {
+		return false
+	}
+	version := versions.Current()
+	if version.IsZero() {
+		return false
+	}
+	etag := `"` + strconv.FormatInt(version.UnixMicro(), 36) + `"`
+	c.Set(fiber.HeaderETag, etag)
+	c.Set(fiber.HeaderLastModified, version.UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT"))
+	if etagMatches(c.Get(fiber.HeaderIfNoneMatch), etag) {
+		c.Status(fiber.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// etagMatches reports whether an If-None-Match header lists etag. Weak
+// validators match their strong form, as If-None-Match compares weakly.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
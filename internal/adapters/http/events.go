@@ -0,0 +1,133 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// ListEventsHandler returns events whose service window overlaps a period,
+// by default the coming week.
+// GET /v1/events?from=2026-03-14T00:00:00Z&to=2026-03-21T00:00:00Z
+func ListEventsHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		from, to := time.Now(), time.Time{}
+		if raw := c.Query("from"); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return errBadRequest(c, "from must be an RFC 3339 time")
+			}
+			from = t
+		}
+		if raw := c.Query("to"); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return errBadRequest(c, "to must be an RFC 3339 time")
+			}
+			to = t
+		}
+		events, err := deps.Events.List(c.Context(), from, to)
+		if err != nil {
+			return errBadRequest(c, err.Error())
+		}
+		return eventsResponse(c, events)
+	}
+}
+
+// GetEventHandler returns one event.
+// GET /v1/events/:id
+func GetEventHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		event, err := deps.Events.Get(c.Context(), c.Params("id"))
+		switch {
+		case err == nil:
+			return c.JSON(event)
+		case errors.Is(err, usecases.ErrEventNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
+
+// StopEventsHandler returns the events whose overlay currently covers a stop.
+// GET /v1/stops/:id/events
+func StopEventsHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		events, err := deps.Events.ForStop(c.Context(), c.Params("id"), time.Now())
+		switch {
+		case err == nil:
+			c.Set("Cache-Control", "public, max-age=60")
+			return eventsResponse(c, events)
+		case errors.Is(err, usecases.ErrStopNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
+
+// CreateEventHandler schedules an event overlay. Without a service window
+// it covers two hours either side of the event.
+// POST /v1/admin/events
+func CreateEventHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var event domain.Event
+		if err := c.BodyParser(&event); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		event.ID = ""
+		if err := deps.Events.Create(c.Context(), &event); err != nil {
+			return errBadRequest(c, err.Error())
+		}
+		return c.Status(fiber.StatusCreated).JSON(event)
+	}
+}
+
+// UpdateEventHandler replaces an event.
+// PUT /v1/admin/events/:id
+func UpdateEventHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var event domain.Event
+		if err := c.BodyParser(&event); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		event.ID = c.Params("id")
+		err := deps.Events.Update(c.Context(), &event)
+		switch {
+		case err == nil:
+			return c.JSON(event)
+		case errors.Is(err, usecases.ErrEventNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errBadRequest(c, err.Error())
+		}
+	}
+}
+
+// DeleteEventHandler removes an event and its overlay.
+// DELETE /v1/admin/events/:id
+func DeleteEventHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := deps.Events.Delete(c.Context(), c.Params("id"))
+		switch {
+		case err == nil:
+			return c.SendStatus(fiber.StatusNoContent)
+		case errors.Is(err, usecases.ErrEventNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
+
+func eventsResponse(c *fiber.Ctx, events []domain.Event) error {
+	if events == nil {
+		events = []domain.Event{}
+	}
+	return c.JSON(fiber.Map{"data": events})
+}
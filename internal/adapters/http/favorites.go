@@ -0,0 +1,77 @@
+package http
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// ListFavoritesHandler returns the rider's saved stops, routes and journeys.
+// GET /v1/users/me/favorites
+func ListFavoritesHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		favorites, err := deps.Favorites.List(c.Context(), currentUserID(c))
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		if favorites == nil {
+			favorites = []domain.Favorite{}
+		}
+		return c.JSON(fiber.Map{"favorites": favorites})
+	}
+}
+
+// CreateFavoriteHandler saves a stop, route or journey for the rider.
+// POST /v1/users/me/favorites
+func CreateFavoriteHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var f domain.Favorite
+		if err := c.BodyParser(&f); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		f.ID = ""
+		err := deps.Favorites.Add(c.Context(), currentUserID(c), &f)
+		switch {
+		case err == nil:
+			return c.Status(fiber.StatusCreated).JSON(f)
+		case errors.Is(err, usecases.ErrStopNotFound), errors.Is(err, usecases.ErrRouteNotFound):
+			return errNotFound(c, err.Error())
+		case errors.Is(err, usecases.ErrFavoriteExists):
+			return errConflict(c, err.Error())
+		default:
+			return errBadRequest(c, err.Error())
+		}
+	}
+}
+
+// DeleteFavoriteHandler removes one of the rider's favorites.
+// DELETE /v1/users/me/favorites/:id
+func DeleteFavoriteHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := deps.Favorites.Remove(c.Context(), currentUserID(c), c.Params("id"))
+		switch {
+		case err == nil:
+			return c.SendStatus(fiber.StatusNoContent)
+		case errors.Is(err, usecases.ErrFavoriteNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
+
+// DashboardHandler returns the rider's favorites with the next departures at
+// every favorite stop in one call.
+// GET /v1/users/me/dashboard?limit=3
+func DashboardHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		dashboard, err := deps.Favorites.Dashboard(c.Context(), currentUserID(c), c.QueryInt("limit", 3))
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		return c.JSON(dashboard)
+	}
+}
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// GetFeedConfigHandler returns an agency's feed settings.
+// GET /v1/admin/agencies/:slug/feed-config
+func GetFeedConfigHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cfg, err := deps.FeedConfigs.Get(c.Context(), c.Params("slug"))
+		switch {
+		case err == nil:
+			return c.JSON(cfg)
+		case errors.Is(err, usecases.ErrAgencyNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
+
+// PutFeedConfigHandler replaces an agency's feed settings. ID rules take
+// effect on the realtime worker's next poll, require_approval on the
+// ingestor's next run.
+// PUT /v1/admin/agencies/:slug/feed-config
+func PutFeedConfigHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var cfg domain.FeedConfig
+		if err := c.BodyParser(&cfg); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		err := deps.FeedConfigs.Update(c.Context(), c.Params("slug"), &cfg)
+		switch {
+		case err == nil:
+			return c.JSON(cfg)
+		case errors.Is(err, usecases.ErrAgencyNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errBadRequest(c, err.Error())
+		}
+	}
+}
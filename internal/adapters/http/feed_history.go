@@ -0,0 +1,48 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// parseAsOf reads the as_of query parameter that asks stop, route and
+// timetable endpoints to answer against the feed version current at that
+// time. A plain date means the start of that day (UTC). ok is false when the
+// parameter is absent.
+func parseAsOf(c *fiber.Ctx) (at time.Time, ok bool, err error) {
+	raw := c.Query("as_of")
+	if raw == "" {
+		return time.Time{}, false, nil
+	}
+	if at, err = time.Parse(time.DateOnly, raw); err == nil {
+		return at, true, nil
+	}
+	if at, err = time.Parse(time.RFC3339, raw); err == nil {
+		return at, true, nil
+	}
+	return time.Time{}, false, errors.New("as_of must be a date (YYYY-MM-DD) or an RFC 3339 time")
+}
+
+// FeedVersionsHandler lists the GTFS feed versions an agency published,
+// newest first, with when each was current.
+// GET /v1/agencies/:slug/feed-versions
+func FeedVersionsHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		versions, err := deps.FeedHistory.Versions(c.Context(), c.Params("slug"))
+		switch {
+		case errors.Is(err, usecases.ErrAgencyNotFound):
+			return errNotFound(c, err.Error())
+		case err != nil:
+			return errInternal(c, err.Error())
+		}
+		if versions == nil {
+			versions = []domain.FeedVersion{}
+		}
+		return c.JSON(fiber.Map{"versions": versions})
+	}
+}
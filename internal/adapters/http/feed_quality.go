@@ -0,0 +1,29 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// FeedQualityReportHandler returns how well an agency's GTFS-RT trip and stop
+// IDs matched the static schedule on a day (default: yesterday).
+// GET /v1/admin/agencies/:slug/feed-quality?day=YYYY-MM-DD
+func FeedQualityReportHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		report, err := deps.FeedQuality.Report(c.Context(), c.Params("slug"), c.Query("day"), time.Now())
+		switch {
+		case err == nil:
+			return c.JSON(report)
+		case errors.Is(err, usecases.ErrAgencyNotFound), errors.Is(err, usecases.ErrFeedReportNotFound):
+			return errNotFound(c, err.Error())
+		case errors.Is(err, usecases.ErrInvalidDay):
+			return errBadRequest(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
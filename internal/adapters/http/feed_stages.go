@@ -0,0 +1,72 @@
+package http
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// ListFeedStagesHandler returns the agency's static feeds held for approval,
+// decided, or applied and kept for rollbacks, newest first.
+// GET /v1/admin/agencies/:slug/feed-stages
+func ListFeedStagesHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		stages, err := deps.FeedStages.List(c.Context(), c.Params("slug"))
+		switch {
+		case err == nil:
+			return c.JSON(fiber.Map{"stages": stages})
+		case errors.Is(err, usecases.ErrAgencyNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
+
+// ApproveFeedStageHandler approves a staged feed. The ingestor's activate
+// run applies it.
+// POST /v1/admin/agencies/:slug/feed-stages/:id/approve
+func ApproveFeedStageHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		stage, err := deps.FeedStages.Approve(c.Context(), c.Params("slug"), c.Params("id"))
+		return feedStageResponse(c, stage, err)
+	}
+}
+
+// RejectFeedStageHandler rejects a staged feed, leaving the live schedule as is.
+// POST /v1/admin/agencies/:slug/feed-stages/:id/reject
+func RejectFeedStageHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		stage, err := deps.FeedStages.Reject(c.Context(), c.Params("slug"), c.Params("id"))
+		return feedStageResponse(c, stage, err)
+	}
+}
+
+// RollbackFeedHandler queues the agency's previous feed version to be applied
+// again and rejects any feed waiting to be applied.
+// POST /v1/admin/agencies/:slug/feed-stages/rollback
+func RollbackFeedHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		stage, err := deps.FeedStages.Rollback(c.Context(), c.Params("slug"))
+		if err == nil {
+			return c.Status(fiber.StatusCreated).JSON(stage)
+		}
+		return feedStageResponse(c, stage, err)
+	}
+}
+
+func feedStageResponse(c *fiber.Ctx, stage *domain.FeedStage, err error) error {
+	switch {
+	case err == nil:
+		return c.JSON(stage)
+	case errors.Is(err, usecases.ErrAgencyNotFound), errors.Is(err, usecases.ErrFeedStageNotFound):
+		return errNotFound(c, err.Error())
+	case errors.Is(err, usecases.ErrFeedStageDecided), errors.Is(err, usecases.ErrNoPreviousFeed):
+		return errConflict(c, err.Error())
+	default:
+		return errInternal(c, err.Error())
+	}
+}
@@ -0,0 +1,28 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// AgencyFleetHandler returns how many of the agency's vehicles reported a
+// position in the last ten minutes, in total and by route, with the spread
+// of their speeds and of how long ago they last reported.
+// GET /v1/agencies/:slug/fleet
+func AgencyFleetHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		fleet, err := deps.Fleet.Agency(c.Context(), c.Params("slug"), time.Now())
+		switch {
+		case errors.Is(err, usecases.ErrAgencyNotFound):
+			return errNotFound(c, err.Error())
+		case err != nil:
+			return errInternal(c, err.Error())
+		}
+		c.Set("Cache-Control", "public, max-age=15")
+		return c.JSON(fleet)
+	}
+}
@@ -0,0 +1,108 @@
+package http
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// geocodeLimiter caps geocode lookups per client IP, on top of the global
+// limit, as misses cost a request to the geocoder, which is shared by every
+// client and throttled.
+func geocodeLimiter(deps *Dependencies) fiber.Handler {
+	max := deps.GeocodeLimit
+	if max <= 0 {
+		max = 30
+	}
+	return limiter.New(limiter.Config{
+		Max:        max,
+		Expiration: 1 * time.Minute,
+		KeyGenerator: func(c *fiber.Ctx) string {
+			return "geocode:" + c.IP()
+		},
+		LimitReached: func(c *fiber.Ctx) error {
+			return c.Status(429).JSON(fiber.Map{
+				"error":   "rate limit exceeded",
+				"message": "too many geocoding requests, please try again later",
+			})
+		},
+	})
+}
+
+// GeocodeHandler resolves a free-text address to places, best first, so
+// apps need no geocoder keys of their own. Places near ?lat/?lon rank
+// higher; labels are in ?lang, or the Accept-Language language.
+// GET /v1/geocode?q=&lat=&lon=&lang=&limit=
+func GeocodeHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		q := domain.GeocodeQuery{
+			Text:  strings.TrimSpace(c.Query("q")),
+			Lang:  placeLang(c),
+			Limit: c.QueryInt("limit", 5),
+		}
+		if q.Text == "" {
+			return errBadRequest(c, "q query parameter is required")
+		}
+		if len(q.Text) > 200 {
+			return errBadRequest(c, "query too long (max 200 characters)")
+		}
+		if c.Query("lat") != "" || c.Query("lon") != "" {
+			lat, lon := c.QueryFloat("lat", 0), c.QueryFloat("lon", 0)
+			if lat == 0 || lon == 0 {
+				return errBadRequest(c, "lat and lon must be given together")
+			}
+			q.Near = &domain.GeoPoint{Lat: lat, Lon: lon}
+		}
+
+		places, err := deps.Geocode.Geocode(c.Context(), q)
+		switch {
+		case err == nil:
+			// Answers are cached server-side too; clients may keep them a day.
+			c.Set("Cache-Control", "public, max-age=86400")
+			c.Vary("Accept-Language")
+			return c.JSON(fiber.Map{"places": places})
+		case errors.Is(err, usecases.ErrGeocoderUnavailable):
+			return newError(c, fiber.StatusServiceUnavailable, "unavailable", err.Error())
+		default:
+			log.Printf("geocode: %v", err)
+			return newError(c, fiber.StatusBadGateway, "bad_gateway", "geocoder unavailable, please try again later")
+		}
+	}
+}
+
+// placeLang returns the language place names are wanted in: ?lang, or the
+// first Accept-Language language.
+func placeLang(c *fiber.Ctx) string {
+	lang := c.Query("lang")
+	if lang == "" {
+		lang, _, _ = strings.Cut(c.Get("Accept-Language"), ",")
+	}
+	// "es-ES;q=0.9" asks for Spanish; geocoders take the bare language.
+	lang, _, _ = strings.Cut(lang, ";")
+	lang, _, _ = strings.Cut(lang, "-")
+	return strings.TrimSpace(lang)
+}
+
+// describeWalks names the streets of the plan's walks, when geocoding is on.
+func describeWalks(c *fiber.Ctx, deps *Dependencies, plan *domain.JourneyPlan) {
+	if deps.Geocode != nil {
+		deps.Geocode.DescribeWalks(c.Context(), plan.Journeys, placeLang(c))
+		c.Vary("Accept-Language")
+	}
+}
+
+// describeVehicles names the streets vehicles are on with ?streets=true,
+// when geocoding is on.
+func describeVehicles(c *fiber.Ctx, deps *Dependencies, vehicles []domain.VehiclePosition) {
+	if deps.Geocode != nil && c.QueryBool("streets") {
+		deps.Geocode.DescribeVehicles(c.Context(), vehicles, placeLang(c))
+		c.Vary("Accept-Language")
+	}
+}
@@ -1,13 +1,31 @@
 package http
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/nats-io/nats.go"
 
 	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
 )
 
-// buildSchema creates the GraphQL schema wired to our services.
+// buildSchema creates the GraphQL schema wired to our services. Stops,
+// routes, trips, vehicles, alerts and journeys link to each other through
+// nested fields, resolved from the parent as they are asked for; nested
+// stops, routes and vehicles are loaded in batches (see gqlLoader).
 func buildSchema(deps *Dependencies) (graphql.Schema, error) {
 	geoPointType := graphql.NewObject(graphql.ObjectConfig{
 		Name: "GeoPoint",
@@ -17,54 +35,460 @@ func buildSchema(deps *Dependencies) (graphql.Schema, error) {
 		},
 	})
 
-	agencyType := graphql.NewObject(graphql.ObjectConfig{
-		Name: "Agency",
+	placeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Place",
 		Fields: graphql.Fields{
-			"id":       &graphql.Field{Type: graphql.String},
-			"slug":     &graphql.Field{Type: graphql.String},
-			"name":     &graphql.Field{Type: graphql.String},
-			"url":      &graphql.Field{Type: graphql.String},
-			"timezone": &graphql.Field{Type: graphql.String},
+			"name":         &graphql.Field{Type: graphql.String},
+			"label":        &graphql.Field{Type: graphql.String},
+			"location":     &graphql.Field{Type: geoPointType},
+			"street":       &graphql.Field{Type: graphql.String},
+			"neighborhood": &graphql.Field{Type: graphql.String},
 		},
 	})
 
-	routeType := graphql.NewObject(graphql.ObjectConfig{
-		Name: "Route",
+	alertPeriodType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "AlertPeriod",
 		Fields: graphql.Fields{
-			"id":         &graphql.Field{Type: graphql.String},
-			"route_id":   &graphql.Field{Type: graphql.String},
-			"agency_id":  &graphql.Field{Type: graphql.String},
-			"short_name": &graphql.Field{Type: graphql.String},
-			"long_name":  &graphql.Field{Type: graphql.String},
-			"route_type": &graphql.Field{Type: graphql.Int},
-			"color":      &graphql.Field{Type: graphql.String},
-			"text_color": &graphql.Field{Type: graphql.String},
+			"start": &graphql.Field{Type: graphql.DateTime},
+			"end":   &graphql.Field{Type: graphql.DateTime},
 		},
 	})
 
-	vehicleType := graphql.NewObject(graphql.ObjectConfig{
+	// The object types refer to each other, so their fields are thunks,
+	// built once every type exists.
+	var agencyType, routeType, stopType, tripType, stopTimeType, vehicleType,
+		departureType, alertType, journeyLegType *graphql.Object
+
+	routeTypeArg := &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: "", Description: "Route types to keep, e.g. bus,tram"}
+	langArg := graphql.FieldConfigArgument{
+		"lang": &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: "", Description: "Language wanted; falls back to the untranslated text"},
+	}
+	departureArgs := graphql.FieldConfigArgument{
+		"limit":        &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 10},
+		"route_id":     &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: "", Description: "Keep one route (UUID)"},
+		"direction_id": &graphql.ArgumentConfig{Type: graphql.Int, Description: "Keep one direction, 0 or 1"},
+		"bikes":        &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false, Description: "Keep trips that allow bikes"},
+	}
+
+	// stopDepartures lists a stop's next departures with the departure
+	// arguments.
+	stopDepartures := func(p graphql.ResolveParams, stopID string) (interface{}, error) {
+		filter := domain.DepartureFilter{
+			RouteID: p.Args["route_id"].(string),
+			Bikes:   p.Args["bikes"].(bool),
+		}
+		if dir, ok := p.Args["direction_id"].(int); ok {
+			filter.DirectionID = &dir
+		}
+		return deps.Departures.NextDeparturesAtStop(p.Context, stopID, p.Args["limit"].(int), filter)
+	}
+
+	// filteredRoutes keeps the routes of the route_type argument.
+	filteredRoutes := func(p graphql.ResolveParams, routes []domain.Route, err error) (interface{}, error) {
+		if err != nil {
+			return nil, err
+		}
+		routeTypes, err := usecases.ParseRouteTypes(p.Args["route_type"].(string), "")
+		if err != nil {
+			return nil, err
+		}
+		return usecases.FilterRoutes(routes, routeTypes), nil
+	}
+
+	agencyType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Agency",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":       &graphql.Field{Type: graphql.String},
+				"slug":     &graphql.Field{Type: graphql.String},
+				"name":     &graphql.Field{Type: graphql.String},
+				"url":      &graphql.Field{Type: graphql.String},
+				"timezone": &graphql.Field{Type: graphql.String},
+				"routes": &graphql.Field{
+					Type: graphql.NewList(routeType),
+					Args: graphql.FieldConfigArgument{"route_type": routeTypeArg},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						agency := gqlSource[domain.Agency](p)
+						routes, err := deps.Routes.ListByAgency(p.Context, agency.ID)
+						return filteredRoutes(p, routes, err)
+					},
+				},
+				"alerts": &graphql.Field{
+					Type:        graphql.NewList(alertType),
+					Description: "Active service alerts",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return deps.Alerts.Active(p.Context, gqlSource[domain.Agency](p).Slug, time.Now())
+					},
+				},
+			}
+		}),
+	})
+
+	routeType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Route",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":         &graphql.Field{Type: graphql.String},
+				"route_id":   &graphql.Field{Type: graphql.String},
+				"agency_id":  &graphql.Field{Type: graphql.String},
+				"short_name": &graphql.Field{Type: graphql.String},
+				"long_name":  &graphql.Field{Type: graphql.String},
+				"route_type": &graphql.Field{Type: graphql.Int},
+				"color":      &graphql.Field{Type: graphql.String},
+				"text_color": &graphql.Field{Type: graphql.String},
+				"shape": &graphql.Field{
+					Type:        graphql.NewList(geoPointType),
+					Description: "The route's geometry, as a line of points",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						route, err := deps.Routes.GetShape(p.Context, gqlSource[domain.Route](p).ID)
+						if err != nil || route.Shape == nil {
+							return nil, err
+						}
+						return route.Shape.Coordinates, nil
+					},
+				},
+				"vehicles": &graphql.Field{
+					Type:        graphql.NewList(vehicleType),
+					Description: "Live vehicle positions on the route",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return gqlLoadersOf(p.Context, deps).vehicles.Load(p.Context, gqlSource[domain.Route](p).ID), nil
+					},
+				},
+				"alerts": &graphql.Field{
+					Type:        graphql.NewList(alertType),
+					Description: "Active alerts affecting the route",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return deps.Alerts.ForRoute(p.Context, gqlSource[domain.Route](p).ID, time.Now())
+					},
+				},
+			}
+		}),
+	})
+
+	stopType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Stop",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":                    &graphql.Field{Type: graphql.String},
+				"stop_id":               &graphql.Field{Type: graphql.String},
+				"agency_id":             &graphql.Field{Type: graphql.String},
+				"name":                  &graphql.Field{Type: graphql.String},
+				"description":           &graphql.Field{Type: graphql.String},
+				"url":                   &graphql.Field{Type: graphql.String},
+				"zone_id":               &graphql.Field{Type: graphql.String, Description: "Fare zone, in the agency's feed"},
+				"location":              &graphql.Field{Type: geoPointType},
+				"platform_code":         &graphql.Field{Type: graphql.String},
+				"wheelchair_accessible": &graphql.Field{Type: graphql.Boolean},
+				"distance":              &graphql.Field{Type: graphql.Float},
+				"routes": &graphql.Field{
+					Type:        graphql.NewList(routeType),
+					Description: "Routes serving the stop",
+					Args:        graphql.FieldConfigArgument{"route_type": routeTypeArg},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						routes, err := deps.Routes.ListByStop(p.Context, gqlSource[domain.Stop](p).ID)
+						return filteredRoutes(p, routes, err)
+					},
+				},
+				"departures": &graphql.Field{
+					Type:        graphql.NewList(departureType),
+					Description: "Next departures from the stop",
+					Args:        departureArgs,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return stopDepartures(p, gqlSource[domain.Stop](p).ID)
+					},
+				},
+				"alerts": &graphql.Field{
+					Type:        graphql.NewList(alertType),
+					Description: "Active alerts affecting the stop",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return deps.Alerts.ForStop(p.Context, gqlSource[domain.Stop](p).ID, time.Now())
+					},
+				},
+			}
+		}),
+	})
+
+	tripType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Trip",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":                    &graphql.Field{Type: graphql.String},
+				"trip_id":               &graphql.Field{Type: graphql.String},
+				"route_id":              &graphql.Field{Type: graphql.String},
+				"service_id":            &graphql.Field{Type: graphql.String},
+				"headsign":              &graphql.Field{Type: graphql.String},
+				"direction_id":          &graphql.Field{Type: graphql.Int},
+				"wheelchair_accessible": &graphql.Field{Type: graphql.Boolean},
+				"bikes_allowed":         &graphql.Field{Type: graphql.Boolean},
+				"block_id":              &graphql.Field{Type: graphql.String},
+				"continues_as": &graphql.Field{
+					Type: graphql.NewObject(graphql.ObjectConfig{
+						Name: "TripContinuation",
+						Fields: graphql.Fields{
+							"id":               &graphql.Field{Type: graphql.String},
+							"route_id":         &graphql.Field{Type: graphql.String},
+							"route_short_name": &graphql.Field{Type: graphql.String},
+							"route_long_name":  &graphql.Field{Type: graphql.String},
+							"headsign":         &graphql.Field{Type: graphql.String},
+						},
+					}),
+					Description: "The trip the vehicle continues as after this one, staying aboard",
+				},
+				"route": &graphql.Field{
+					Type: routeType,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return gqlLoadersOf(p.Context, deps).routes.Load(p.Context, gqlSource[domain.Trip](p).RouteID), nil
+					},
+				},
+				"stop_times": &graphql.Field{
+					Type:        graphql.NewList(stopTimeType),
+					Description: "The trip's stops, in order",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return deps.Trips.GetStopTimes(p.Context, gqlSource[domain.Trip](p).ID)
+					},
+				},
+			}
+		}),
+	})
+
+	stopTimeType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "StopTime",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"trip_id":       &graphql.Field{Type: graphql.String},
+				"stop_id":       &graphql.Field{Type: graphql.String},
+				"stop_sequence": &graphql.Field{Type: graphql.Int},
+				"pickup_type":   &graphql.Field{Type: graphql.Int},
+				"drop_off_type": &graphql.Field{Type: graphql.Int},
+				"arrival_time": &graphql.Field{
+					Type:        graphql.String,
+					Description: "HH:MM:SS after midnight of the service day; may pass 24:00:00",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return gtfsTime(gqlSource[domain.StopTime](p).ArrivalTime), nil
+					},
+				},
+				"departure_time": &graphql.Field{
+					Type:        graphql.String,
+					Description: "HH:MM:SS after midnight of the service day; may pass 24:00:00",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return gtfsTime(gqlSource[domain.StopTime](p).DepartureTime), nil
+					},
+				},
+				"stop": &graphql.Field{
+					Type: stopType,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return gqlLoadersOf(p.Context, deps).stops.Load(p.Context, gqlSource[domain.StopTime](p).StopID), nil
+					},
+				},
+			}
+		}),
+	})
+
+	vehicleType = graphql.NewObject(graphql.ObjectConfig{
 		Name: "Vehicle",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"vehicle_id": &graphql.Field{Type: graphql.String},
+				"trip_id":    &graphql.Field{Type: graphql.String},
+				"route_id":   &graphql.Field{Type: graphql.String},
+				"time":       &graphql.Field{Type: graphql.DateTime},
+				"location":   &graphql.Field{Type: geoPointType},
+				"bearing":    &graphql.Field{Type: graphql.Float},
+				"speed":      &graphql.Field{Type: graphql.Float},
+				"route_name": &graphql.Field{Type: graphql.String},
+				"headsign":   &graphql.Field{Type: graphql.String},
+				"distance":   &graphql.Field{Type: graphql.Float},
+				"source": &graphql.Field{
+					Type:        graphql.String,
+					Description: "Where the position came from: gtfs_rt when polled, push when pushed",
+					Resolve:     resolveVehicleSource,
+				},
+				"route": &graphql.Field{
+					Type: routeType,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return gqlLoadersOf(p.Context, deps).routes.Load(p.Context, gqlSource[domain.VehiclePosition](p).RouteID), nil
+					},
+				},
+				"trip": &graphql.Field{
+					Type: tripType,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						id := gqlSource[domain.VehiclePosition](p).TripID
+						if id == "" {
+							return nil, nil
+						}
+						return deps.Trips.GetByID(p.Context, id)
+					},
+				},
+			}
+		}),
+	})
+
+	departureType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Departure",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"scheduled_time": &graphql.Field{
+					Type:        graphql.String,
+					Description: "Local time, HH:MM:SS",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return gqlSource[domain.Departure](p).ScheduledTime.Format("15:04:05"), nil
+					},
+				},
+				"estimated_time": &graphql.Field{
+					Type:        graphql.String,
+					Description: "Local time, HH:MM:SS, when the vehicle reports real-time data",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						if t := gqlSource[domain.Departure](p).EstimatedTime; t != nil {
+							return t.Format("15:04:05"), nil
+						}
+						return nil, nil
+					},
+				},
+				"delay":    &graphql.Field{Type: graphql.Int, Description: "Seconds late"},
+				"platform": &graphql.Field{Type: graphql.String},
+				"trip":     &graphql.Field{Type: tripType},
+			}
+		}),
+	})
+
+	// translated resolves a text field of alerts in the lang argument.
+	translated := func(text func(a *domain.ServiceAlert) map[string]string) graphql.FieldResolveFn {
+		return func(p graphql.ResolveParams) (interface{}, error) {
+			return translation(text(gqlSource[domain.ServiceAlert](p)), p.Args["lang"].(string)), nil
+		}
+	}
+
+	alertType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Alert",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":             &graphql.Field{Type: graphql.String},
+				"agency_id":      &graphql.Field{Type: graphql.String},
+				"source":         &graphql.Field{Type: graphql.String},
+				"cause":          &graphql.Field{Type: graphql.String},
+				"effect":         &graphql.Field{Type: graphql.String},
+				"active_periods": &graphql.Field{Type: graphql.NewList(alertPeriodType)},
+				"route_ids":      &graphql.Field{Type: graphql.NewList(graphql.String)},
+				"stop_ids":       &graphql.Field{Type: graphql.NewList(graphql.String)},
+				"header": &graphql.Field{
+					Type: graphql.String,
+					Args: langArg,
+					Resolve: translated(func(a *domain.ServiceAlert) map[string]string {
+						return a.Header
+					}),
+				},
+				"description": &graphql.Field{
+					Type: graphql.String,
+					Args: langArg,
+					Resolve: translated(func(a *domain.ServiceAlert) map[string]string {
+						return a.Description
+					}),
+				},
+				"url": &graphql.Field{
+					Type: graphql.String,
+					Args: langArg,
+					Resolve: translated(func(a *domain.ServiceAlert) map[string]string {
+						return a.URL
+					}),
+				},
+				"routes": &graphql.Field{
+					Type:        graphql.NewList(routeType),
+					Description: "Routes the alert affects",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return gqlLoadersOf(p.Context, deps).routes.LoadMany(p.Context, gqlSource[domain.ServiceAlert](p).RouteIDs), nil
+					},
+				},
+				"stops": &graphql.Field{
+					Type:        graphql.NewList(stopType),
+					Description: "Stops the alert affects",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return gqlLoadersOf(p.Context, deps).stops.LoadMany(p.Context, gqlSource[domain.ServiceAlert](p).StopIDs), nil
+					},
+				},
+			}
+		}),
+	})
+
+	journeyLegType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "JourneyLeg",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"walk": &graphql.Field{Type: graphql.Boolean},
+				"walk_minutes": &graphql.Field{
+					Type: graphql.Int,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						leg := gqlSource[domain.JourneyLeg](p)
+						if !leg.Walk {
+							return nil, nil
+						}
+						return walkMinutes(leg), nil
+					},
+				},
+				"route":     &graphql.Field{Type: routeType},
+				"from_stop": &graphql.Field{Type: stopType},
+				"to_stop":   &graphql.Field{Type: stopType},
+				"departure_time": &graphql.Field{
+					Type: graphql.DateTime,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return gqlSource[domain.JourneyLeg](p).Departure.ScheduledTime, nil
+					},
+				},
+				"arrival_time": &graphql.Field{Type: graphql.DateTime},
+				"trip": &graphql.Field{
+					Type: tripType,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return gqlSource[domain.JourneyLeg](p).Departure.Trip, nil
+					},
+				},
+				"headway_secs":     &graphql.Field{Type: graphql.Int, Description: "Set for trips running about this often rather than to a timetable"},
+				"bikes_restricted": &graphql.Field{Type: graphql.Boolean},
+				"along":            &graphql.Field{Type: placeType, Description: "The street a walk runs along, when the lang argument of journeys is given"},
+			}
+		}),
+	})
+
+	journeyType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Journey",
 		Fields: graphql.Fields{
-			"vehicle_id": &graphql.Field{Type: graphql.String},
-			"trip_id":    &graphql.Field{Type: graphql.String},
-			"route_id":   &graphql.Field{Type: graphql.String},
-			"location":   &graphql.Field{Type: geoPointType},
-			"bearing":    &graphql.Field{Type: graphql.Float},
-			"speed":      &graphql.Field{Type: graphql.Float},
+			"legs":           &graphql.Field{Type: graphql.NewList(journeyLegType)},
+			"departure_time": &graphql.Field{Type: graphql.DateTime},
+			"arrival_time":   &graphql.Field{Type: graphql.DateTime},
+			"transfers":      &graphql.Field{Type: graphql.Int},
+			"zones": &graphql.Field{
+				Type: graphql.NewList(graphql.NewObject(graphql.ObjectConfig{
+					Name: "FareZone",
+					Fields: graphql.Fields{
+						"agency_id": &graphql.Field{Type: graphql.String},
+						"zone_id":   &graphql.Field{Type: graphql.String},
+					},
+				})),
+				Description: "Fare zones the journey's rides board and alight in, in order",
+			},
+			"duration_minutes": &graphql.Field{
+				Type: graphql.Int,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return int(gqlSource[domain.Journey](p).Duration.Minutes()), nil
+				},
+			},
 		},
 	})
 
-	stopType := graphql.NewObject(graphql.ObjectConfig{
-		Name: "Stop",
+	journeyPlanType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "JourneyPlan",
 		Fields: graphql.Fields{
-			"id":                    &graphql.Field{Type: graphql.String},
-			"stop_id":               &graphql.Field{Type: graphql.String},
-			"agency_id":             &graphql.Field{Type: graphql.String},
-			"name":                  &graphql.Field{Type: graphql.String},
-			"location":              &graphql.Field{Type: geoPointType},
-			"platform_code":         &graphql.Field{Type: graphql.String},
-			"wheelchair_accessible": &graphql.Field{Type: graphql.Boolean},
-			"distance":              &graphql.Field{Type: graphql.Float},
+			"journeys": &graphql.Field{Type: graphql.NewList(journeyType)},
+			"street": &graphql.Field{
+				Description: "The walk or ride along the streets, for mode walk or bike",
+				Type: graphql.NewObject(graphql.ObjectConfig{
+					Name: "StreetRoute",
+					Fields: graphql.Fields{
+						"mode":             &graphql.Field{Type: graphql.String},
+						"distance_km":      &graphql.Field{Type: graphql.Float},
+						"duration_minutes": &graphql.Field{Type: graphql.Int},
+						"estimated":        &graphql.Field{Type: graphql.Boolean},
+					},
+				}),
+			},
 		},
 	})
 
@@ -78,21 +502,38 @@ func buildSchema(deps *Dependencies) (graphql.Schema, error) {
 					return deps.Agencies.List(p.Context)
 				},
 			},
+			"agency": &graphql.Field{
+				Type:        agencyType,
+				Description: "Get an agency by slug",
+				Args: graphql.FieldConfigArgument{
+					"slug": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return deps.Agencies.GetBySlug(p.Context, p.Args["slug"].(string))
+				},
+			},
 			"stopsNearby": &graphql.Field{
 				Type:        graphql.NewList(stopType),
 				Description: "Find stops near a location",
 				Args: graphql.FieldConfigArgument{
-					"lat":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Float)},
-					"lon":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Float)},
-					"radius": &graphql.ArgumentConfig{Type: graphql.Float, DefaultValue: 500.0},
-					"limit":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 20},
+					"lat":         &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Float)},
+					"lon":         &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Float)},
+					"radius":      &graphql.ArgumentConfig{Type: graphql.Float, DefaultValue: 500.0},
+					"limit":       &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 20},
+					"has_shelter": &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+					"route_type":  &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: ""},
 				},
 				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
 					lat := p.Args["lat"].(float64)
 					lon := p.Args["lon"].(float64)
 					radius := p.Args["radius"].(float64)
 					limit := p.Args["limit"].(int)
-					return deps.Stops.FindNearby(p.Context, lat, lon, radius, limit)
+					filter := domain.StopFilter{HasShelter: p.Args["has_shelter"].(bool)}
+					var err error
+					if filter.RouteTypes, err = usecases.ParseRouteTypes(p.Args["route_type"].(string), ""); err != nil {
+						return nil, err
+					}
+					return deps.Stops.FindNearby(p.Context, lat, lon, radius, limit, filter)
 				},
 			},
 			"searchStops": &graphql.Field{
@@ -110,35 +551,44 @@ func buildSchema(deps *Dependencies) (graphql.Schema, error) {
 			},
 			"stop": &graphql.Field{
 				Type:        stopType,
-				Description: "Get a stop by ID",
+				Description: "Get a stop by ID; null when unknown",
 				Args: graphql.FieldConfigArgument{
 					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
 				},
 				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-					id := p.Args["id"].(string)
-					return deps.Stops.GetByID(p.Context, id)
+					return gqlStop(p.Context, deps, p.Args["id"].(string))
 				},
 			},
 			"route": &graphql.Field{
 				Type:        routeType,
-				Description: "Get a route by ID",
+				Description: "Get a route by ID; null when unknown",
 				Args: graphql.FieldConfigArgument{
 					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
 				},
 				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-					id := p.Args["id"].(string)
-					return deps.Routes.GetByID(p.Context, id)
+					return gqlRoute(p.Context, deps, p.Args["id"].(string))
 				},
 			},
 			"routesByAgency": &graphql.Field{
 				Type:        graphql.NewList(routeType),
 				Description: "List routes for an agency",
 				Args: graphql.FieldConfigArgument{
-					"agency_id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"agency_id":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"route_type": routeTypeArg,
 				},
 				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-					agencyID := p.Args["agency_id"].(string)
-					return deps.Routes.ListByAgency(p.Context, agencyID)
+					routes, err := deps.Routes.ListByAgency(p.Context, p.Args["agency_id"].(string))
+					return filteredRoutes(p, routes, err)
+				},
+			},
+			"trip": &graphql.Field{
+				Type:        tripType,
+				Description: "Get a trip by ID",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return deps.Trips.GetByID(p.Context, p.Args["id"].(string))
 				},
 			},
 			"routeVehicles": &graphql.Field{
@@ -152,94 +602,464 @@ func buildSchema(deps *Dependencies) (graphql.Schema, error) {
 					return deps.Routes.GetLiveVehicles(p.Context, routeID)
 				},
 			},
-			"stopDepartures": &graphql.Field{
-				Type: graphql.NewList(graphql.NewObject(graphql.ObjectConfig{
-					Name: "Departure",
-					Fields: graphql.Fields{
-						"scheduled_time": &graphql.Field{Type: graphql.String},
-						"platform":       &graphql.Field{Type: graphql.String},
-						"trip": &graphql.Field{Type: graphql.NewObject(graphql.ObjectConfig{
-							Name: "DepartureTrip",
-							Fields: graphql.Fields{
-								"id":       &graphql.Field{Type: graphql.String},
-								"trip_id":  &graphql.Field{Type: graphql.String},
-								"headsign": &graphql.Field{Type: graphql.String},
-							},
-						})},
-					},
-				})),
-				Description: "Next departures at a stop",
+			"vehiclesNearby": &graphql.Field{
+				Type:        graphql.NewList(vehicleType),
+				Description: "Live vehicles near a location, nearest first",
 				Args: graphql.FieldConfigArgument{
-					"stop_id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
-					"limit":   &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 10},
+					"lat":        &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Float)},
+					"lon":        &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Float)},
+					"radius":     &graphql.ArgumentConfig{Type: graphql.Float, DefaultValue: 1000.0},
+					"limit":      &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 50},
+					"route_type": &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: ""},
 				},
 				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-					stopID := p.Args["stop_id"].(string)
+					lat := p.Args["lat"].(float64)
+					lon := p.Args["lon"].(float64)
+					radius := p.Args["radius"].(float64)
 					limit := p.Args["limit"].(int)
-					deps, err := deps.Departures.NextDeparturesAtStop(p.Context, stopID, limit)
+					routeTypes, err := usecases.ParseRouteTypes(p.Args["route_type"].(string), "")
 					if err != nil {
 						return nil, err
 					}
-					// Convert domain.Departure to a map for GraphQL
-					var result []map[string]interface{}
-					for _, d := range deps {
-						m := map[string]interface{}{
-							"scheduled_time": d.ScheduledTime.Format("15:04:05"),
-							"platform":       d.Platform,
+					return deps.Routes.NearbyVehicles(p.Context, lat, lon, radius, limit, routeTypes)
+				},
+			},
+			"stopDepartures": &graphql.Field{
+				Type:        graphql.NewList(departureType),
+				Description: "Next departures at a stop",
+				Args: graphql.FieldConfigArgument{
+					"stop_id":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"limit":        departureArgs["limit"],
+					"route_id":     departureArgs["route_id"],
+					"direction_id": departureArgs["direction_id"],
+					"bikes":        departureArgs["bikes"],
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return stopDepartures(p, p.Args["stop_id"].(string))
+				},
+			},
+			"alerts": &graphql.Field{
+				Type:        graphql.NewList(alertType),
+				Description: "Active service alerts, of one agency or all",
+				Args: graphql.FieldConfigArgument{
+					"agency": &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: "", Description: "Agency slug"},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return deps.Alerts.Active(p.Context, p.Args["agency"].(string), time.Now())
+				},
+			},
+			"journeys": &graphql.Field{
+				Type:        journeyPlanType,
+				Description: "Plan journeys between two stops (from/to), two named places (from_name/to_name) or two points",
+				Args: graphql.FieldConfigArgument{
+					"from":          &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: "", Description: "Stop UUID"},
+					"to":            &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: "", Description: "Stop UUID"},
+					"from_name":     &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: ""},
+					"to_name":       &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: ""},
+					"from_lat":      &graphql.ArgumentConfig{Type: graphql.Float, DefaultValue: 0.0},
+					"from_lon":      &graphql.ArgumentConfig{Type: graphql.Float, DefaultValue: 0.0},
+					"to_lat":        &graphql.ArgumentConfig{Type: graphql.Float, DefaultValue: 0.0},
+					"to_lon":        &graphql.ArgumentConfig{Type: graphql.Float, DefaultValue: 0.0},
+					"depart_at":     &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: "", Description: "HH:MM today or RFC 3339"},
+					"max_transfers": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 1},
+					"accessible":    &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: "", Description: "wheelchair or stroller"},
+					"route_type":    routeTypeArg,
+					"wheelchair":    &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+					"bikes":         &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false},
+					"mode":          &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: "", Description: "walk or bike for street-only journeys"},
+					"prefer":        &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: "", Description: "earliest_arrival, fewest_transfers or least_walking"},
+					"limit":         &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+					"lang":          &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: "", Description: "Name the streets of walks, in this language"},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return planJourneys(p, deps)
+				},
+			},
+		},
+	})
+
+	// Subscription payloads are NATS events as the realtime poller publishes
+	// them, carrying the feed's IDs rather than ours, so their types have no
+	// nested fields.
+	liveVehicleType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "LiveVehicle",
+		Description: "A vehicle position as its agency's feed reports it, with the feed's trip, route and stop IDs",
+		Fields: graphql.Fields{
+			"agency": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					agency, _ := gqlSource[domain.VehiclePosition](p).Metadata["agency"].(string)
+					return agency, nil
+				},
+			},
+			"vehicle_id":       &graphql.Field{Type: graphql.String},
+			"trip_id":          &graphql.Field{Type: graphql.String},
+			"route_id":         &graphql.Field{Type: graphql.String},
+			"stop_id":          &graphql.Field{Type: graphql.String},
+			"stop_sequence":    &graphql.Field{Type: graphql.Int},
+			"time":             &graphql.Field{Type: graphql.DateTime},
+			"location":         &graphql.Field{Type: geoPointType},
+			"bearing":          &graphql.Field{Type: graphql.Float},
+			"speed":            &graphql.Field{Type: graphql.Float},
+			"congestion_level": &graphql.Field{Type: graphql.Int},
+			"occupancy_status": &graphql.Field{Type: graphql.Int},
+			"source":           &graphql.Field{Type: graphql.String, Resolve: resolveVehicleSource},
+		},
+	})
+
+	liveAlertType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "LiveAlert",
+		Description: "A service alert as its agency's feed reports it, with the feed's route and stop IDs",
+		Fields: graphql.Fields{
+			"agency":      &graphql.Field{Type: graphql.String},
+			"header":      &graphql.Field{Type: graphql.String},
+			"description": &graphql.Field{Type: graphql.String},
+			"cause":       &graphql.Field{Type: graphql.String},
+			"effect":      &graphql.Field{Type: graphql.String},
+			"route_ids":   &graphql.Field{Type: graphql.NewList(graphql.String)},
+			"stop_ids":    &graphql.Field{Type: graphql.NewList(graphql.String)},
+		},
+	})
+
+	// The event itself is the source of each result.
+	resolveEvent := func(p graphql.ResolveParams) (interface{}, error) { return p.Source, nil }
+
+	subscriptionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Subscription",
+		Fields: graphql.Fields{
+			"vehiclePositions": &graphql.Field{
+				Type:        liveVehicleType,
+				Description: "Live vehicle positions, of one agency or all, optionally of one route",
+				Args: graphql.FieldConfigArgument{
+					"agency": &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: "", Description: "Agency slug"},
+					"route":  &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: "", Description: "The feed's route_id"},
+				},
+				Subscribe: func(p graphql.ResolveParams) (interface{}, error) {
+					agency, route := p.Args["agency"].(string), p.Args["route"].(string)
+					subject := "transit.vehicle.>"
+					if agency != "" {
+						if !natsToken(agency) {
+							return nil, fmt.Errorf("invalid agency %q", agency)
 						}
-						if d.Trip != nil {
-							m["trip"] = map[string]interface{}{
-								"id":       d.Trip.ID,
-								"trip_id":  d.Trip.TripID,
-								"headsign": d.Trip.Headsign,
-							}
+						subject = "transit.vehicle." + agency + ".>"
+					}
+					return natsEvents(p.Context, deps.NATS, subject, func(vp *domain.VehiclePosition) bool {
+						return route == "" || vp.RouteID == route
+					})
+				},
+				Resolve: resolveEvent,
+			},
+			"alerts": &graphql.Field{
+				Type:        liveAlertType,
+				Description: "Service alerts as feeds publish them, of one agency or all",
+				Args: graphql.FieldConfigArgument{
+					"agency": &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: "", Description: "Agency slug"},
+				},
+				Subscribe: func(p graphql.ResolveParams) (interface{}, error) {
+					agency := p.Args["agency"].(string)
+					// One token matches every agency's feed alerts but not the
+					// sla and anomaly ones, which are published a level deeper.
+					subject := "transit.alerts.*"
+					if agency != "" {
+						if !natsToken(agency) {
+							return nil, fmt.Errorf("invalid agency %q", agency)
 						}
-						result = append(result, m)
+						subject = "transit.alerts." + agency
 					}
-					return result, nil
+					// Detour events share the subjects but are bare trip IDs,
+					// which do not decode.
+					return natsEvents(p.Context, deps.NATS, subject, func(a *liveAlert) bool {
+						return a.Agency != ""
+					})
 				},
+				Resolve: resolveEvent,
 			},
 		},
 	})
 
 	return graphql.NewSchema(graphql.SchemaConfig{
-		Query: queryType,
+		Query:        queryType,
+		Subscription: subscriptionType,
 	})
 }
 
-// GraphQLHandler serves the GraphQL endpoint.
-func GraphQLHandler(deps *Dependencies) fiber.Handler {
+// liveAlert is a service alert as the realtime poller publishes it to NATS.
+type liveAlert struct {
+	Agency      string   `json:"agency"`
+	Header      string   `json:"header"`
+	Description string   `json:"description"`
+	Cause       string   `json:"cause"`
+	Effect      string   `json:"effect"`
+	RouteIDs    []string `json:"route_ids"`
+	StopIDs     []string `json:"stop_ids"`
+}
+
+// natsEvents subscribes to subject until ctx is done, sending the events
+// that decode as T and that keep accepts as subscription results.
+func natsEvents[T any](ctx context.Context, nc *nats.Conn, subject string, keep func(*T) bool) (chan interface{}, error) {
+	if nc == nil {
+		return nil, errors.New("live updates are unavailable")
+	}
+	events := make(chan interface{})
+	sub, err := nc.Subscribe(subject, func(msg *nats.Msg) {
+		event := new(T)
+		if json.Unmarshal(msg.Data, event) != nil || !keep(event) {
+			return
+		}
+		select {
+		case events <- event:
+		case <-ctx.Done():
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		_ = sub.Unsubscribe()
+	}()
+	return events, nil
+}
+
+// natsToken reports whether s is a single NATS subject token, so that an
+// agency slug cannot widen the subject subscribed to.
+func natsToken(s string) bool {
+	return s != "" && !strings.ContainsAny(s, ".*> \t\r\n")
+}
+
+// planJourneys plans the journeys asked for with the journeys query's
+// arguments, the way JourneyHandler does with query parameters.
+func planJourneys(p graphql.ResolveParams, deps *Dependencies) (*domain.JourneyPlan, error) {
+	if deps.Journeys == nil {
+		return nil, errors.New("journey planning is unavailable")
+	}
+	routeTypes, err := usecases.ParseRouteTypes(p.Args["route_type"].(string), "")
+	if err != nil {
+		return nil, err
+	}
+	filter := domain.JourneyFilter{
+		RouteTypes: routeTypes,
+		Wheelchair: p.Args["wheelchair"].(bool),
+		Bikes:      p.Args["bikes"].(bool),
+		Mode:       p.Args["mode"].(string),
+	}
+	ranking := domain.JourneyRanking{Prefer: p.Args["prefer"].(string), Limit: p.Args["limit"].(int)}
+	departAt := parseDepartAt(p.Args["depart_at"].(string))
+	maxTransfers := p.Args["max_transfers"].(int)
+	accessible := p.Args["accessible"].(string)
+
+	from := domain.GeoPoint{Lat: p.Args["from_lat"].(float64), Lon: p.Args["from_lon"].(float64)}
+	to := domain.GeoPoint{Lat: p.Args["to_lat"].(float64), Lon: p.Args["to_lon"].(float64)}
+	fromID, toID := p.Args["from"].(string), p.Args["to"].(string)
+	fromName, toName := p.Args["from_name"].(string), p.Args["to_name"].(string)
+
+	var plan *domain.JourneyPlan
+	switch {
+	case from != (domain.GeoPoint{}) || to != (domain.GeoPoint{}):
+		if from.Lat == 0 || from.Lon == 0 || to.Lat == 0 || to.Lon == 0 {
+			return nil, errors.New("from_lat, from_lon, to_lat and to_lon are all required")
+		}
+		plan, err = deps.Journeys.PlanJourneyBetween(p.Context, from, to, departAt, maxTransfers, accessible, filter, ranking)
+	case fromName != "" && toName != "":
+		plan, err = deps.Journeys.PlanJourneyByName(p.Context, fromName, toName, departAt, accessible, filter, ranking)
+	case fromID != "" && toID != "":
+		plan, err = deps.Journeys.PlanJourney(p.Context, fromID, toID, departAt, maxTransfers, accessible, filter, ranking)
+	default:
+		return nil, errors.New("from and to (stop UUIDs), from_name and to_name, or coordinates are required")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if lang := p.Args["lang"].(string); lang != "" && deps.Geocode != nil {
+		deps.Geocode.DescribeWalks(p.Context, plan.Journeys, lang)
+	}
+	return plan, nil
+}
+
+// gqlSource returns the parent of a nested field, which graphql-go hands
+// over as a value from lists and as a pointer otherwise.
+func gqlSource[T any](p graphql.ResolveParams) *T {
+	switch v := p.Source.(type) {
+	case *T:
+		return v
+	case T:
+		return &v
+	}
+	return new(T)
+}
+
+// gqlStop looks up a stop, resolving unknown or empty IDs to null.
+func gqlStop(ctx context.Context, deps *Dependencies, id string) (*domain.Stop, error) {
+	if id == "" {
+		return nil, nil
+	}
+	stop, err := deps.Stops.GetByID(ctx, id)
+	if errors.Is(err, usecases.ErrStopNotFound) {
+		return nil, nil
+	}
+	return stop, err
+}
+
+// gqlRoute looks up a route, resolving unknown or empty IDs to null.
+func gqlRoute(ctx context.Context, deps *Dependencies, id string) (*domain.Route, error) {
+	if id == "" {
+		return nil, nil
+	}
+	route, err := deps.Routes.GetByID(ctx, id)
+	if errors.Is(err, usecases.ErrRouteNotFound) {
+		return nil, nil
+	}
+	return route, err
+}
+
+// gtfsTime formats a time since midnight of the service day as GTFS does,
+// HH:MM:SS with hours past 24 for trips running after midnight.
+func gtfsTime(d time.Duration) string {
+	secs := int(d / time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+}
+
+// translation returns the text in lang, else the untranslated text, else
+// Spanish, English or the first language there is.
+func translation(texts map[string]string, lang string) interface{} {
+	for _, l := range []string{lang, "", "es", "en"} {
+		if t, ok := texts[l]; ok {
+			return t
+		}
+	}
+	langs := make([]string, 0, len(texts))
+	for l := range texts {
+		langs = append(langs, l)
+	}
+	if len(langs) == 0 {
+		return nil
+	}
+	sort.Strings(langs)
+	return texts[langs[0]]
+}
+
+// walkMinutes is how long a walking leg takes, in minutes rounded up, at
+// least 1.
+func walkMinutes(leg *domain.JourneyLeg) int {
+	return max(1, int(math.Ceil(leg.ArrivalTime.Sub(leg.Departure.ScheduledTime).Minutes())))
+}
+
+// queryDepth returns how deeply the operations of a query nest fields,
+// following fragments. Fragment cycles are left for validation to reject.
+func queryDepth(doc *ast.Document) int {
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, def := range doc.Definitions {
+		if f, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[f.Name.Value] = f
+		}
+	}
+
+	fragmentDepths := map[string]int{}
+	var depth func(set *ast.SelectionSet) int
+	depth = func(set *ast.SelectionSet) int {
+		if set == nil {
+			return 0
+		}
+		deepest := 0
+		for _, sel := range set.Selections {
+			switch s := sel.(type) {
+			case *ast.Field:
+				deepest = max(deepest, 1+depth(s.SelectionSet))
+			case *ast.InlineFragment:
+				deepest = max(deepest, depth(s.SelectionSet))
+			case *ast.FragmentSpread:
+				name := s.Name.Value
+				d, known := fragmentDepths[name]
+				if !known {
+					fragmentDepths[name] = 0 // in progress; a cycle adds nothing
+					if f := fragments[name]; f != nil {
+						d = depth(f.SelectionSet)
+					}
+					fragmentDepths[name] = d
+				}
+				deepest = max(deepest, d)
+			}
+		}
+		return deepest
+	}
+
+	deepest := 0
+	for _, def := range doc.Definitions {
+		if op, ok := def.(*ast.OperationDefinition); ok {
+			deepest = max(deepest, depth(op.SelectionSet))
+		}
+	}
+	return deepest
+}
+
+// graphQLRequest is a GraphQL operation as clients send it, over HTTP or as
+// the payload of a WebSocket subscribe message.
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// GraphQLHandler serves the GraphQL endpoint. Operations the guard refuses
+// get 400, or 429 past the client's budget, with the reason in errors;
+// the others answer with their cost in extensions. Subscriptions are
+// refused, as GraphQLWebSocketHandler serves them.
+func GraphQLHandler(deps *Dependencies, guard *GraphQLGuard) fiber.Handler {
 	schema, err := buildSchema(deps)
 	if err != nil {
 		// This would be a programming error in the schema definition
 		panic("graphql schema build: " + err.Error())
 	}
 
-	type gqlRequest struct {
-		Query         string                 `json:"query"`
-		OperationName string                 `json:"operationName"`
-		Variables     map[string]interface{} `json:"variables"`
-	}
-
 	return func(c *fiber.Ctx) error {
-		var req gqlRequest
+		var req graphQLRequest
 		if err := c.BodyParser(&req); err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
 		}
 
-		result := graphql.Do(graphql.Params{
-			Schema:         schema,
-			RequestString:  req.Query,
-			VariableValues: req.Variables,
-			OperationName:  req.OperationName,
-			Context:        c.Context(),
-		})
+		doc, err := parser.Parse(parser.ParseParams{Source: req.Query})
+		if err != nil {
+			return c.JSON(&graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		}
+		if operationType(doc, req.OperationName) == ast.OperationTypeSubscription {
+			return c.Status(400).JSON(fiber.Map{"errors": []fiber.Map{{
+				"message": "subscriptions are served over WebSocket, with the " + GraphQLWSProtocol + " protocol",
+			}}})
+		}
+		if validation := graphql.ValidateDocument(&schema, doc, nil); !validation.IsValid {
+			return c.JSON(&graphql.Result{Errors: validation.Errors})
+		}
+		extensions, refusal := guard.admit(&schema, doc, req, c.IP(), time.Now())
+		if refusal != nil {
+			if refusal.retryAfter > 0 {
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(refusal.retryAfter.Seconds()))))
+			}
+			return c.Status(refusal.status).JSON(fiber.Map{"errors": []gqlerrors.FormattedError{refusal.err}})
+		}
 
+		result := graphql.Execute(graphql.ExecuteParams{
+			Schema:        schema,
+			AST:           doc,
+			OperationName: req.OperationName,
+			Args:          req.Variables,
+			Context:       withGQLLoaders(c.Context(), deps),
+		})
+		if result.Extensions == nil {
+			result.Extensions = map[string]interface{}{}
+		}
+		for k, v := range extensions {
+			result.Extensions[k] = v
+		}
 		return c.JSON(result)
 	}
 }
 
-// Ensure domain types implement field resolvers for graphql-go via struct tags
-var _ = domain.Stop{}
-var _ = domain.Route{}
-var _ = domain.Agency{}
+// resolveVehicleSource resolves the source a vehicle position came from,
+// as recorded in its metadata.
+func resolveVehicleSource(p graphql.ResolveParams) (interface{}, error) {
+	source, _ := gqlSource[domain.VehiclePosition](p).Metadata["source"].(string)
+	if source == "" {
+		return nil, nil
+	}
+	return source, nil
+}
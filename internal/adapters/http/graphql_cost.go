@@ -0,0 +1,294 @@
+package http
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// GraphQLLimits bounds what GraphQL operations may cost, so that nested
+// queries cannot hammer the database.
+type GraphQLLimits struct {
+	MaxDepth     int           // deepest field nesting allowed
+	MaxCost      int           // costliest operation allowed
+	Budget       int           // cost a client may spend a BudgetWindow
+	BudgetWindow time.Duration // zero uses a minute
+}
+
+// DefaultGraphQLLimits lets a client run a handful of heavy queries, or
+// many light ones, a minute. Relations run in cycles (trip → stop_times →
+// stop → departures → trip), so depth alone does not bound the cost.
+var DefaultGraphQLLimits = GraphQLLimits{MaxDepth: 10, MaxCost: 1000, Budget: 20000, BudgetWindow: time.Minute}
+
+// graphQLFieldCosts weighs the fields dearer than one lookup, by
+// "Type.field". Other fields with resolvers cost 1; those read off their
+// parent cost nothing.
+var graphQLFieldCosts = map[string]int{
+	"Query.journeys":       50,
+	"Query.stopsNearby":    5,
+	"Query.searchStops":    5,
+	"Query.vehiclesNearby": 5,
+	"Query.stopDepartures": 5,
+	"Stop.departures":      5,
+	"Route.shape":          5,
+}
+
+// graphQLListSizes is how many items lists without a limit argument are
+// taken to hold, by "Type.field"; others are taken to hold 10.
+var graphQLListSizes = map[string]int{
+	"Query.agencies":       40,
+	"Query.routesByAgency": 100,
+	"Agency.routes":        100,
+	"Stop.routes":          20,
+	"Trip.stop_times":      40,
+	"Route.vehicles":       30,
+}
+
+const defaultGraphQLListSize = 10
+
+// graphQLCost scores the operation named operationName in doc (the first
+// when empty): each field's cost, plus the cost of its selections times
+// the items it lists. Introspection is free.
+func graphQLCost(schema *graphql.Schema, doc *ast.Document, operationName string, variables map[string]interface{}) int {
+	op := selectedOperation(doc, operationName)
+	if op == nil {
+		return 0
+	}
+	var root *graphql.Object
+	switch op.Operation {
+	case ast.OperationTypeQuery:
+		root = schema.QueryType()
+	case ast.OperationTypeSubscription:
+		root = schema.SubscriptionType()
+	case ast.OperationTypeMutation:
+		root = schema.MutationType()
+	}
+	if root == nil {
+		return 0
+	}
+
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, def := range doc.Definitions {
+		if f, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[f.Name.Value] = f
+		}
+	}
+
+	visiting := map[string]bool{}
+	var cost func(set *ast.SelectionSet, parent graphql.Type) float64
+	cost = func(set *ast.SelectionSet, parent graphql.Type) float64 {
+		obj, ok := parent.(*graphql.Object)
+		if set == nil || !ok {
+			return 0
+		}
+		total := 0.0
+		for _, sel := range set.Selections {
+			switch s := sel.(type) {
+			case *ast.Field:
+				def := obj.Fields()[s.Name.Value]
+				if def == nil {
+					continue // introspection, or left for validation
+				}
+				key := obj.Name() + "." + def.Name
+				fieldCost, weighed := graphQLFieldCosts[key]
+				if !weighed && (def.Resolve != nil || def.Subscribe != nil) {
+					fieldCost = 1
+				}
+				named, list := unwrapGraphQLType(def.Type)
+				items := 1.0
+				if list {
+					items = float64(listSize(s, def, key, variables))
+				}
+				total += float64(fieldCost) + items*cost(s.SelectionSet, named)
+			case *ast.InlineFragment:
+				on := parent
+				if s.TypeCondition != nil {
+					on = schema.Type(s.TypeCondition.Name.Value)
+				}
+				total += cost(s.SelectionSet, on)
+			case *ast.FragmentSpread:
+				f := fragments[s.Name.Value]
+				if f == nil || visiting[f.Name.Value] {
+					continue
+				}
+				visiting[f.Name.Value] = true
+				total += cost(f.SelectionSet, schema.Type(f.TypeCondition.Name.Value))
+				visiting[f.Name.Value] = false
+			}
+		}
+		return total
+	}
+	return int(math.Min(cost(op.SelectionSet, root), math.MaxInt32))
+}
+
+// listSize is how many items a list field is taken to hold: its limit
+// argument as given or by default, else its size in graphQLListSizes.
+func listSize(field *ast.Field, def *graphql.FieldDefinition, key string, variables map[string]interface{}) int {
+	for _, arg := range def.Args {
+		if arg.Name() != "limit" {
+			continue
+		}
+		limit, _ := arg.DefaultValue.(int)
+		for _, a := range field.Arguments {
+			if a.Name.Value == "limit" {
+				limit = intValue(a.Value, variables)
+			}
+		}
+		if limit > 0 {
+			return limit
+		}
+	}
+	if size, ok := graphQLListSizes[key]; ok {
+		return size
+	}
+	return defaultGraphQLListSize
+}
+
+// intValue reads an Int argument, literal or variable; 0 when it is
+// neither.
+func intValue(v ast.Value, variables map[string]interface{}) int {
+	switch v := v.(type) {
+	case *ast.IntValue:
+		n, _ := strconv.Atoi(v.Value)
+		return n
+	case *ast.Variable:
+		switch n := variables[v.Name.Value].(type) {
+		case int:
+			return n
+		case float64: // JSON numbers
+			return int(math.Min(n, math.MaxInt32))
+		}
+	}
+	return 0
+}
+
+// unwrapGraphQLType strips non-null and list wrappers off t, reporting
+// whether it lists items.
+func unwrapGraphQLType(t graphql.Type) (graphql.Type, bool) {
+	list := false
+	for {
+		switch w := t.(type) {
+		case *graphql.NonNull:
+			t = w.OfType
+		case *graphql.List:
+			list = true
+			t = w.OfType
+		default:
+			return t, list
+		}
+	}
+}
+
+// GraphQLGuard refuses GraphQL operations nesting too deep or costing too
+// much, and charges the cost of the others to their client's budget, which
+// refills every window.
+type GraphQLGuard struct {
+	limits GraphQLLimits
+
+	mu      sync.Mutex
+	windows map[string]*costWindow
+}
+
+// costWindow is what a client spent since start.
+type costWindow struct {
+	start time.Time
+	spent int
+}
+
+// graphQLRefusal is why an operation was refused, and the HTTP status to
+// refuse it with.
+type graphQLRefusal struct {
+	status     int
+	retryAfter time.Duration
+	err        gqlerrors.FormattedError
+}
+
+// NewGraphQLGuard creates a GraphQLGuard; limits left zero take their
+// DefaultGraphQLLimits value.
+func NewGraphQLGuard(limits GraphQLLimits) *GraphQLGuard {
+	if limits.MaxDepth <= 0 {
+		limits.MaxDepth = DefaultGraphQLLimits.MaxDepth
+	}
+	if limits.MaxCost <= 0 {
+		limits.MaxCost = DefaultGraphQLLimits.MaxCost
+	}
+	if limits.Budget <= 0 {
+		limits.Budget = DefaultGraphQLLimits.Budget
+	}
+	if limits.BudgetWindow <= 0 {
+		limits.BudgetWindow = DefaultGraphQLLimits.BudgetWindow
+	}
+	return &GraphQLGuard{limits: limits, windows: map[string]*costWindow{}}
+}
+
+// admit checks a parsed and valid operation and charges its cost to
+// client. It returns the cost extension to answer with, or the refusal.
+func (g *GraphQLGuard) admit(schema *graphql.Schema, doc *ast.Document, req graphQLRequest, client string, now time.Time) (map[string]interface{}, *graphQLRefusal) {
+	if depth := queryDepth(doc); depth > g.limits.MaxDepth {
+		return nil, &graphQLRefusal{status: 400, err: gqlerrors.FormattedError{
+			Message: fmt.Sprintf("query nests %d levels deep, more than the %d allowed", depth, g.limits.MaxDepth),
+			Extensions: map[string]interface{}{
+				"code": "QUERY_TOO_DEEP", "depth": depth, "max_depth": g.limits.MaxDepth,
+			},
+		}}
+	}
+
+	cost := graphQLCost(schema, doc, req.OperationName, req.Variables)
+	if cost > g.limits.MaxCost {
+		return nil, &graphQLRefusal{status: 400, err: gqlerrors.FormattedError{
+			Message: fmt.Sprintf("query costs %d, more than the %d allowed", cost, g.limits.MaxCost),
+			Extensions: map[string]interface{}{
+				"code": "QUERY_TOO_COSTLY", "cost": cost, "max_cost": g.limits.MaxCost,
+			},
+		}}
+	}
+
+	g.mu.Lock()
+	w := g.windows[client]
+	if w == nil || now.Sub(w.start) >= g.limits.BudgetWindow {
+		if len(g.windows) >= 10000 {
+			g.sweep(now)
+		}
+		w = &costWindow{start: now}
+		g.windows[client] = w
+	}
+	reset := w.start.Add(g.limits.BudgetWindow).Sub(now)
+	fits := w.spent+cost <= g.limits.Budget
+	if fits {
+		w.spent += cost
+	}
+	remaining := g.limits.Budget - w.spent
+	g.mu.Unlock()
+
+	budget := map[string]interface{}{
+		"limit":         g.limits.Budget,
+		"remaining":     remaining,
+		"reset_seconds": int(math.Ceil(reset.Seconds())),
+	}
+	if !fits {
+		return nil, &graphQLRefusal{status: 429, retryAfter: reset, err: gqlerrors.FormattedError{
+			Message: fmt.Sprintf("query costs %d, more than the %d left of the budget", cost, remaining),
+			Extensions: map[string]interface{}{
+				"code": "COST_BUDGET_EXCEEDED", "cost": cost, "budget": budget,
+			},
+		}}
+	}
+	return map[string]interface{}{
+		"cost": map[string]interface{}{"requested": cost, "max_cost": g.limits.MaxCost, "budget": budget},
+	}, nil
+}
+
+// sweep forgets the clients whose window has ended. g.mu must be held.
+func (g *GraphQLGuard) sweep(now time.Time) {
+	for client, w := range g.windows {
+		if now.Sub(w.start) >= g.limits.BudgetWindow {
+			delete(g.windows, client)
+		}
+	}
+}
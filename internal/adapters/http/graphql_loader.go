@@ -0,0 +1,161 @@
+package http
+
+import (
+	"context"
+	"sync"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// gqlLoader batches the lookups nested resolvers make within one GraphQL
+// operation, so that listing 40 stop times loads their 40 stops in one
+// query. Load queues a key and returns a thunk, which graphql-go calls
+// once it has resolved every field of the level; the first thunk called
+// loads all the keys queued by then. Values are kept for the rest of the
+// operation, so each key is loaded once.
+type gqlLoader[V any] struct {
+	batch func(ctx context.Context, keys []string) (map[string]V, error)
+
+	mu      sync.Mutex
+	pending []string
+	loaded  map[string]gqlLoaded[V]
+}
+
+// gqlLoaded is a key's value, or the error of the batch that loaded it.
+type gqlLoaded[V any] struct {
+	value V
+	found bool
+	err   error
+}
+
+// newGQLLoader creates a gqlLoader; batch returns the values of the keys
+// it finds, by key.
+func newGQLLoader[V any](batch func(ctx context.Context, keys []string) (map[string]V, error)) *gqlLoader[V] {
+	return &gqlLoader[V]{batch: batch, loaded: map[string]gqlLoaded[V]{}}
+}
+
+// Load queues key and returns a thunk resolving to its value, or to null
+// when the key is empty or not found.
+func (l *gqlLoader[V]) Load(ctx context.Context, key string) func() (interface{}, error) {
+	if key == "" {
+		return func() (interface{}, error) { return nil, nil }
+	}
+	l.queue(key)
+	return func() (interface{}, error) {
+		r := l.get(ctx, key)
+		if r.err != nil || !r.found {
+			return nil, r.err
+		}
+		return r.value, nil
+	}
+}
+
+// LoadMany queues keys and returns a thunk resolving to the values found,
+// in the order of keys.
+func (l *gqlLoader[V]) LoadMany(ctx context.Context, keys []string) func() (interface{}, error) {
+	for _, key := range keys {
+		l.queue(key)
+	}
+	return func() (interface{}, error) {
+		values := make([]V, 0, len(keys))
+		for _, key := range keys {
+			r := l.get(ctx, key)
+			if r.err != nil {
+				return nil, r.err
+			}
+			if r.found {
+				values = append(values, r.value)
+			}
+		}
+		return values, nil
+	}
+}
+
+func (l *gqlLoader[V]) queue(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.loaded[key]; !ok {
+		l.pending = append(l.pending, key)
+	}
+}
+
+// get returns key's value, loading the pending keys first when it is
+// one of them.
+func (l *gqlLoader[V]) get(ctx context.Context, key string) gqlLoaded[V] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if r, ok := l.loaded[key]; ok {
+		return r
+	}
+
+	keys := make([]string, 0, len(l.pending))
+	seen := make(map[string]bool, len(l.pending))
+	for _, k := range append(l.pending, key) {
+		if _, done := l.loaded[k]; !done && !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	l.pending = nil
+
+	values, err := l.batch(ctx, keys)
+	for _, k := range keys {
+		v, found := values[k]
+		l.loaded[k] = gqlLoaded[V]{value: v, found: found, err: err}
+	}
+	return l.loaded[key]
+}
+
+// gqlLoaders are an operation's loaders: stops and routes by ID, and live
+// vehicles by route ID.
+type gqlLoaders struct {
+	stops    *gqlLoader[*domain.Stop]
+	routes   *gqlLoader[*domain.Route]
+	vehicles *gqlLoader[[]domain.VehiclePosition]
+}
+
+type gqlLoadersKey struct{}
+
+func newGQLLoaders(deps *Dependencies) *gqlLoaders {
+	return &gqlLoaders{
+		stops: newGQLLoader(func(ctx context.Context, ids []string) (map[string]*domain.Stop, error) {
+			stops, err := deps.Stops.GetByIDs(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			byID := make(map[string]*domain.Stop, len(stops))
+			for i := range stops {
+				byID[stops[i].ID] = &stops[i]
+			}
+			return byID, nil
+		}),
+		routes: newGQLLoader(func(ctx context.Context, ids []string) (map[string]*domain.Route, error) {
+			routes, err := deps.Routes.GetByIDs(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			byID := make(map[string]*domain.Route, len(routes))
+			for i := range routes {
+				byID[routes[i].ID] = &routes[i]
+			}
+			return byID, nil
+		}),
+		vehicles: newGQLLoader(deps.Routes.GetLiveVehiclesByRoutes),
+	}
+}
+
+// withGQLLoaders gives the operation run with ctx its own loaders.
+// Subscriptions should not have them, or their events would be resolved
+// with what the first one loaded.
+func withGQLLoaders(ctx context.Context, deps *Dependencies) context.Context {
+	return context.WithValue(ctx, gqlLoadersKey{}, newGQLLoaders(deps))
+}
+
+// gqlLoadersOf returns the operation's loaders, or fresh ones, which batch
+// nothing, when it has none.
+func gqlLoadersOf(ctx context.Context, deps *Dependencies) *gqlLoaders {
+	if l, ok := ctx.Value(gqlLoadersKey{}).(*gqlLoaders); ok {
+		return l
+	}
+	return newGQLLoaders(deps)
+}
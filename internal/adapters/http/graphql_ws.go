@@ -0,0 +1,271 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+
+	"github.com/samirrijal/bilbopass/internal/pkg/metrics"
+)
+
+// GraphQLWSProtocol is the WebSocket subprotocol of graphql-ws clients.
+const GraphQLWSProtocol = "graphql-transport-ws"
+
+// gqlWSInitTimeout is how long a client has to send connection_init.
+const gqlWSInitTimeout = 10 * time.Second
+
+// gqlWSMessage is a graphql-transport-ws message, sent either way.
+type gqlWSMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// GraphQLWebSocketHandler serves GraphQL over WebSocket with the
+// graphql-transport-ws protocol of graphql-ws clients. Subscriptions stream
+// NATS events until the client completes them or disconnects; queries are
+// answered once. Clients must send connection_init first, within 10s.
+// Operations are charged to the client's budget as over HTTP, once each.
+func GraphQLWebSocketHandler(deps *Dependencies, guard *GraphQLGuard) func(*websocket.Conn) {
+	schema, err := buildSchema(deps)
+	if err != nil {
+		panic("graphql schema build: " + err.Error())
+	}
+
+	// liveOperation is a running operation, cancelled by the client's
+	// complete message or when the connection closes.
+	type liveOperation struct {
+		cancel context.CancelFunc
+	}
+
+	return func(c *websocket.Conn) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		remoteAddr := c.RemoteAddr().String()
+		log.Printf("graphql ws client connected: %s", remoteAddr)
+		metrics.ActiveWebSockets.Inc()
+		defer metrics.ActiveWebSockets.Dec()
+		client, _, err := net.SplitHostPort(remoteAddr)
+		if err != nil {
+			client = remoteAddr
+		}
+
+		var mu sync.Mutex
+		send := func(msg gqlWSMessage) error {
+			data, err := json.Marshal(msg)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			return c.WriteMessage(websocket.TextMessage, data)
+		}
+		closeWith := func(code int, reason string) {
+			mu.Lock()
+			defer mu.Unlock()
+			_ = c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason))
+		}
+
+		// Keep-alive ping
+		go func() {
+			ticker := time.NewTicker(30 * time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					mu.Lock()
+					err := c.WriteMessage(websocket.PingMessage, nil)
+					mu.Unlock()
+					if err != nil {
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		var opsMu sync.Mutex
+		ops := make(map[string]*liveOperation)
+		acked := false
+		_ = c.SetReadDeadline(time.Now().Add(gqlWSInitTimeout))
+
+	read:
+		for {
+			_, data, err := c.ReadMessage()
+			if err != nil {
+				var netErr net.Error
+				if !acked && errors.As(err, &netErr) && netErr.Timeout() {
+					closeWith(4408, "Connection initialisation timeout")
+				}
+				break
+			}
+
+			var msg gqlWSMessage
+			if err := json.Unmarshal(data, &msg); err != nil || msg.Type == "" {
+				closeWith(4400, "Invalid message")
+				break
+			}
+
+			switch msg.Type {
+			case "connection_init":
+				if acked {
+					closeWith(4429, "Too many initialisation requests")
+					break read
+				}
+				acked = true
+				_ = c.SetReadDeadline(time.Time{})
+				_ = send(gqlWSMessage{Type: "connection_ack"})
+
+			case "ping":
+				_ = send(gqlWSMessage{Type: "pong"})
+
+			case "pong":
+				// Answers our pings; nothing to do.
+
+			case "subscribe":
+				if !acked {
+					closeWith(4401, "Unauthorized")
+					break read
+				}
+				var req graphQLRequest
+				if msg.ID == "" || json.Unmarshal(msg.Payload, &req) != nil {
+					closeWith(4400, "Invalid subscribe message")
+					break read
+				}
+				opsMu.Lock()
+				_, exists := ops[msg.ID]
+				op := &liveOperation{}
+				var opCtx context.Context
+				if !exists {
+					opCtx, op.cancel = context.WithCancel(ctx)
+					ops[msg.ID] = op
+				}
+				opsMu.Unlock()
+				if exists {
+					closeWith(4409, "Subscriber for "+msg.ID+" already exists")
+					break read
+				}
+
+				go func(id string) {
+					runGraphQLOperation(opCtx, deps, schema, guard, client, id, req, send)
+					opsMu.Lock()
+					if ops[id] == op {
+						delete(ops, id)
+					}
+					opsMu.Unlock()
+					op.cancel()
+				}(msg.ID)
+
+			case "complete":
+				opsMu.Lock()
+				if op, ok := ops[msg.ID]; ok {
+					op.cancel()
+					delete(ops, msg.ID)
+				}
+				opsMu.Unlock()
+
+			default:
+				closeWith(4400, "Unknown message type "+msg.Type)
+				break read
+			}
+		}
+
+		// Cleanup: cancelling ctx ends every operation.
+		cancel()
+		log.Printf("graphql ws client disconnected: %s", remoteAddr)
+	}
+}
+
+// runGraphQLOperation runs the operation of a subscribe message, sending
+// each result as a next message and then complete, or a single error
+// message when the document is invalid or the guard refuses it. Once ctx
+// is done nothing more is sent.
+func runGraphQLOperation(ctx context.Context, deps *Dependencies, schema graphql.Schema, guard *GraphQLGuard, client, id string, req graphQLRequest, send func(gqlWSMessage) error) {
+	sendErrors := func(errs []gqlerrors.FormattedError) {
+		payload, _ := json.Marshal(errs)
+		_ = send(gqlWSMessage{ID: id, Type: "error", Payload: payload})
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: req.Query})
+	if err != nil {
+		sendErrors(gqlerrors.FormatErrors(err))
+		return
+	}
+	if validation := graphql.ValidateDocument(&schema, doc, nil); !validation.IsValid {
+		sendErrors(validation.Errors)
+		return
+	}
+	extensions, refusal := guard.admit(&schema, doc, req, client, time.Now())
+	if refusal != nil {
+		sendErrors([]gqlerrors.FormattedError{refusal.err})
+		return
+	}
+
+	params := graphql.ExecuteParams{
+		Schema:        schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	}
+	var results chan *graphql.Result
+	if operationType(doc, req.OperationName) == ast.OperationTypeSubscription {
+		results = graphql.ExecuteSubscription(params)
+	} else {
+		params.Context = withGQLLoaders(ctx, deps)
+		result := graphql.Execute(params)
+		result.Extensions = extensions
+		results = make(chan *graphql.Result, 1)
+		results <- result
+		close(results)
+	}
+
+	// Results are drained to the end, as the executor blocks on sending
+	// them even once ctx is done.
+	for result := range results {
+		if ctx.Err() != nil {
+			continue
+		}
+		payload, err := json.Marshal(result)
+		if err != nil {
+			continue
+		}
+		_ = send(gqlWSMessage{ID: id, Type: "next", Payload: payload})
+	}
+	if ctx.Err() == nil {
+		_ = send(gqlWSMessage{ID: id, Type: "complete"})
+	}
+}
+
+// selectedOperation returns the document's operation named name, or its
+// first operation when name is empty.
+func selectedOperation(doc *ast.Document, name string) *ast.OperationDefinition {
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if ok && (name == "" || (op.Name != nil && op.Name.Value == name)) {
+			return op
+		}
+	}
+	return nil
+}
+
+// operationType returns the type of the selected operation: "query",
+// "mutation" or "subscription".
+func operationType(doc *ast.Document, name string) string {
+	if op := selectedOperation(doc, name); op != nil {
+		return op.Operation
+	}
+	return ""
+}
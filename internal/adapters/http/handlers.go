@@ -1,11 +1,18 @@
 package http
 
 import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+	"github.com/samirrijal/bilbopass/internal/pkg/geospatial"
 )
 
 // FeedStats holds statistics about the ingested GTFS data.
@@ -45,48 +52,38 @@ func FeedStatsHandler(deps *Dependencies) fiber.Handler {
 	}
 }
 
-// ListAgenciesHandler returns all transit agencies.
+// ListAgenciesHandler returns a page of the transit agencies, by name.
 func ListAgenciesHandler(deps *Dependencies) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		agencies, err := deps.Agencies.List(c.Context())
+		offset, limit := parsePage(c, 200)
+		agencies, total, err := deps.Agencies.ListPage(c.Context(), offset, limit)
 		if err != nil {
 			return errInternal(c, err.Error())
 		}
 
-		// Apply offset/limit pagination on the full list
-		offset := c.QueryInt("offset", 0)
-		limit := c.QueryInt("limit", 100)
-		if offset < 0 {
-			offset = 0
-		}
-		if limit <= 0 || limit > 200 {
-			limit = 100
-		}
-
-		total := len(agencies)
-		if offset >= total {
-			agencies = nil
-		} else {
-			end := offset + limit
-			if end > total {
-				end = total
-			}
-			agencies = agencies[offset:end]
-		}
-
 		pg := Pagination{Offset: offset, Limit: limit, Total: total}
 		SetLinkHeaders(c, pg)
 		return c.JSON(PaginatedResponse{Data: agencies, Pagination: pg})
 	}
 }
 
-// NearbyStopsHandler returns stops within a radius of a point.
+// NearbyStopsHandler returns stops within a radius of a point, optionally only
+// those with a shelter, bench or real-time display.
 func NearbyStopsHandler(deps *Dependencies) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		lat := c.QueryFloat("lat", 0)
 		lon := c.QueryFloat("lon", 0)
 		radius := c.QueryFloat("radius", 500)
 		limit := c.QueryInt("limit", 50)
+		filter := domain.StopFilter{
+			HasShelter:         c.QueryBool("has_shelter"),
+			HasBench:           c.QueryBool("has_bench"),
+			HasRealtimeDisplay: c.QueryBool("has_realtime_display"),
+		}
+		var err error
+		if filter.RouteTypes, err = parseRouteTypes(c); err != nil {
+			return errBadRequest(c, err.Error())
+		}
 
 		if lat == 0 || lon == 0 {
 			return errBadRequest(c, "lat and lon are required")
@@ -98,7 +95,7 @@ func NearbyStopsHandler(deps *Dependencies) fiber.Handler {
 			limit = 50
 		}
 
-		stops, err := deps.Stops.FindNearby(c.Context(), lat, lon, radius, limit)
+		stops, err := deps.Stops.FindNearby(c.Context(), lat, lon, radius, limit, filter)
 		if err != nil {
 			return errInternal(c, err.Error())
 		}
@@ -108,7 +105,8 @@ func NearbyStopsHandler(deps *Dependencies) fiber.Handler {
 	}
 }
 
-// SearchStopsHandler performs fuzzy search on stop names.
+// SearchStopsHandler performs fuzzy search on stop names and codes, ranking
+// stops near ?lat&lon higher when given.
 func SearchStopsHandler(deps *Dependencies) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		query := c.Query("q")
@@ -122,47 +120,108 @@ func SearchStopsHandler(deps *Dependencies) fiber.Handler {
 		if limit <= 0 || limit > 100 {
 			limit = 20
 		}
+		var near *domain.GeoPoint
+		if c.Query("lat") != "" || c.Query("lon") != "" {
+			lat, lon := c.QueryFloat("lat", 0), c.QueryFloat("lon", 0)
+			if lat == 0 || lon == 0 {
+				return errBadRequest(c, "lat and lon must be given together")
+			}
+			near = &domain.GeoPoint{Lat: lat, Lon: lon}
+		}
 
-		stops, err := deps.Stops.Search(c.Context(), query, nil, limit)
+		stops, err := deps.Stops.Search(c.Context(), query, near, limit)
 		if err != nil {
 			return errInternal(c, err.Error())
 		}
+		if deps.Search != nil {
+			// Measure the hit rate without holding up the response; Fiber
+			// reuses the query's memory once the handler returns.
+			go func(query string, results int) {
+				if err := deps.Search.Record(context.Background(), query, results, time.Now()); err != nil {
+					log.Printf("record stop search: %v", err)
+				}
+			}(strings.Clone(query), len(stops))
+		}
 
+		if len(stops) == 0 {
+			// Searches that find nothing answer with an object carrying
+			// did-you-mean stop names instead of a bare empty array.
+			suggestions, err := deps.Stops.Suggest(c.Context(), query, 0)
+			if err != nil {
+				return errInternal(c, err.Error())
+			}
+			return c.JSON(fiber.Map{"stops": []domain.Stop{}, "suggestions": suggestions})
+		}
 		return c.JSON(stops)
 	}
 }
 
-// GetStopHandler returns a single stop by ID.
+// GetStopHandler returns a single stop by ID, or as it was at ?as_of.
 func GetStopHandler(deps *Dependencies) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		id := c.Params("id")
 		if id == "" {
 			return errBadRequest(c, "stop id is required")
 		}
-		stop, err := deps.Stops.GetByID(c.Context(), id)
+		asOf, historic, err := parseAsOf(c)
+		if err != nil {
+			return errBadRequest(c, err.Error())
+		}
+		surrogateKeys(c, usecases.StopKey(id))
+		cdnCacheable(c, deps)
+		if !historic && staticNotModified(c, deps.StaticVersion) {
+			return nil
+		}
+		var stop *domain.Stop
+		if historic {
+			stop, err = deps.FeedHistory.Stop(c.Context(), id, asOf)
+		} else {
+			stop, err = deps.Stops.GetByID(c.Context(), id)
+		}
 		if err != nil {
 			return errNotFound(c, "stop not found")
 		}
+		if notModified(c, stop.ID, stop.UpdatedAt) {
+			return nil
+		}
 		return c.JSON(stop)
 	}
 }
 
-// GetRouteHandler returns a route by ID.
+// GetRouteHandler returns a route by ID, or as it was at ?as_of.
 func GetRouteHandler(deps *Dependencies) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		id := c.Params("id")
 		if id == "" {
 			return errBadRequest(c, "route id is required")
 		}
-		route, err := deps.Routes.GetByID(c.Context(), id)
+		asOf, historic, err := parseAsOf(c)
+		if err != nil {
+			return errBadRequest(c, err.Error())
+		}
+		surrogateKeys(c, usecases.RouteKey(id))
+		cdnCacheable(c, deps)
+		if !historic && staticNotModified(c, deps.StaticVersion) {
+			return nil
+		}
+		var route *domain.Route
+		if historic {
+			route, err = deps.FeedHistory.Route(c.Context(), id, asOf)
+		} else {
+			route, err = deps.Routes.GetByID(c.Context(), id)
+		}
 		if err != nil {
 			return errNotFound(c, "route not found")
 		}
+		if notModified(c, route.ID, route.UpdatedAt) {
+			return nil
+		}
 		return c.JSON(route)
 	}
 }
 
-// GetRouteVehiclesHandler returns live vehicle positions for a route.
+// GetRouteVehiclesHandler returns live vehicle positions for a route, with
+// ?streets=true naming the street each is on.
 func GetRouteVehiclesHandler(deps *Dependencies) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		id := c.Params("id")
@@ -173,32 +232,165 @@ func GetRouteVehiclesHandler(deps *Dependencies) fiber.Handler {
 		if err != nil {
 			return errInternal(c, err.Error())
 		}
+		describeVehicles(c, deps, vehicles)
 		return c.JSON(vehicles)
 	}
 }
 
-// ListRoutesHandler lists routes, optionally filtered by agency.
-func ListRoutesHandler(deps *Dependencies) fiber.Handler {
+// NearbyVehiclesHandler returns the live vehicles within a radius of a point,
+// nearest first, with their route and headsign, and with ?streets=true the
+// street each is on.
+func NearbyVehiclesHandler(deps *Dependencies) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		agencyID := c.Query("agency_id")
-		if agencyID == "" {
-			return errBadRequest(c, "agency_id query parameter is required")
+		lat := c.QueryFloat("lat", 0)
+		lon := c.QueryFloat("lon", 0)
+		radius := c.QueryFloat("radius", 1000)
+		limit := c.QueryInt("limit", 50)
+		routeTypes, err := parseRouteTypes(c)
+		if err != nil {
+			return errBadRequest(c, err.Error())
 		}
 
-		routes, err := deps.Routes.ListByAgency(c.Context(), agencyID)
+		if lat == 0 || lon == 0 {
+			return errBadRequest(c, "lat and lon are required")
+		}
+		if radius <= 0 || radius > 5000 {
+			return errBadRequest(c, "radius must be between 1 and 5000 meters")
+		}
+		if limit <= 0 || limit > 200 {
+			limit = 50
+		}
+
+		vehicles, err := deps.Routes.NearbyVehicles(c.Context(), lat, lon, radius, limit, routeTypes)
 		if err != nil {
 			return errInternal(c, err.Error())
 		}
+		if vehicles == nil {
+			vehicles = []domain.VehiclePosition{}
+		}
+		describeVehicles(c, deps, vehicles)
 
-		// Apply offset/limit pagination
-		offset := c.QueryInt("offset", 0)
-		limit := c.QueryInt("limit", 100)
-		if offset < 0 {
-			offset = 0
+		c.Set("Cache-Control", "public, max-age=15")
+		return c.JSON(vehicles)
+	}
+}
+
+// RouteShapeHandler returns the route geometry as a GeoJSON FeatureCollection.
+func RouteShapeHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if id == "" {
+			return errBadRequest(c, "route id is required")
+		}
+		surrogateKeys(c, usecases.RouteKey(id))
+		cdnCacheable(c, deps)
+		if staticNotModified(c, deps.StaticVersion) {
+			return nil
+		}
+		route, err := deps.Routes.GetShape(c.Context(), id)
+		if err != nil {
+			return errNotFound(c, "route not found")
+		}
+		if route.Shape == nil || len(route.Shape.Coordinates) < 2 {
+			return errNotFound(c, "route has no shape")
+		}
+
+		feature := geospatial.Feature{
+			Type:     "Feature",
+			ID:       route.ID,
+			Geometry: geospatial.LineStringGeometry(route.Shape),
+			Properties: map[string]any{
+				"route_id":   route.RouteID,
+				"agency_id":  route.AgencyID,
+				"short_name": route.ShortName,
+				"long_name":  route.LongName,
+				"route_type": route.RouteType,
+				"color":      cssColor(route.Color, "FFFFFF"),
+				"text_color": cssColor(route.TextColor, "000000"),
+			},
+		}
+
+		c.Set("Cache-Control", "public, max-age=3600")
+		return c.JSON(geospatial.NewFeatureCollection(feature), "application/geo+json")
+	}
+}
+
+// cssColor returns a GTFS route color as #RRGGBB. Feeds may leave it out,
+// so def is the GTFS default.
+func cssColor(c, def string) string {
+	if c = strings.TrimPrefix(strings.TrimSpace(c), "#"); c == "" {
+		c = def
+	}
+	return "#" + c
+}
+
+// RouteBadgeHandler renders the route's line badge as SVG, for web clients
+// and emails. ?height (12-256, default 24) and ?min_width size it.
+// GET /v1/routes/:id/badge.svg
+func RouteBadgeHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		svg, err := deps.Routes.Badge(c.Context(), c.Params("id"), usecases.BadgeSize{
+			Height:   c.QueryInt("height", 0),
+			MinWidth: c.QueryInt("min_width", 0),
+		})
+		switch {
+		case errors.Is(err, usecases.ErrInvalidBadgeSize):
+			return errBadRequest(c, err.Error())
+		case err != nil:
+			return errNotFound(c, "route not found")
+		}
+		surrogateKeys(c, usecases.RouteKey(c.Params("id")))
+		cdnCacheable(c, deps)
+		c.Set("Content-Type", "image/svg+xml")
+		c.Set("Cache-Control", "public, max-age=86400")
+		return c.Send(svg)
+	}
+}
+
+// ListRoutesHandler lists routes of every agency, or of ?agency_id, with
+// ?route_type and ?exclude_route_type filters and a ?q name search. With
+// ?as_of, it lists the routes of an agency's feed version current at that
+// time.
+// GET /v1/routes?agency_id=&route_type=&q=&offset=&limit=
+func ListRoutesHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		agencyID := c.Query("agency_id")
+		asOf, historic, err := parseAsOf(c)
+		if err != nil {
+			return errBadRequest(c, err.Error())
+		}
+		routeTypes, err := parseRouteTypes(c)
+		if err != nil {
+			return errBadRequest(c, err.Error())
+		}
+		query := c.Query("q")
+		if len(query) > 200 {
+			return errBadRequest(c, "query too long (max 200 characters)")
+		}
+
+		offset, limit := parsePage(c, 500)
+		if !historic {
+			filter := domain.RouteFilter{AgencyID: agencyID, RouteTypes: routeTypes, Query: query}
+			routes, total, err := deps.Routes.List(c.Context(), filter, offset, limit)
+			if err != nil {
+				return errInternal(c, err.Error())
+			}
+			pg := Pagination{Offset: offset, Limit: limit, Total: total}
+			SetLinkHeaders(c, pg)
+			return c.JSON(PaginatedResponse{Data: routes, Pagination: pg})
+		}
+
+		if agencyID == "" {
+			return errBadRequest(c, "agency_id query parameter is required with as_of")
 		}
-		if limit <= 0 || limit > 500 {
-			limit = 100
+		if query != "" {
+			return errBadRequest(c, "q is not supported with as_of")
 		}
+		routes, err := deps.FeedHistory.Routes(c.Context(), agencyID, asOf)
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		routes = usecases.FilterRoutes(routes, routeTypes)
 
 		total := len(routes)
 		if offset >= total {
@@ -218,6 +410,11 @@ func ListRoutesHandler(deps *Dependencies) fiber.Handler {
 }
 
 // StopDeparturesHandler returns next scheduled departures at a stop.
+// With include_forecast=true, departures in the next hour without real-time
+// data carry a historical delay forecast; with bikes=true, only trips that
+// allow bikes are listed. route_id and direction_id keep one line or
+// direction, and date, after and until set the window listed.
+// GET /v1/stops/:id/departures?route_id=<route_uuid>&direction_id=1&date=2026-03-02&after=07:30&until=09:00
 func StopDeparturesHandler(deps *Dependencies) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		id := c.Params("id")
@@ -229,7 +426,25 @@ func StopDeparturesHandler(deps *Dependencies) fiber.Handler {
 			limit = 10
 		}
 
-		departures, err := deps.Departures.NextDeparturesAtStop(c.Context(), id, limit)
+		filter := domain.DepartureFilter{Bikes: c.QueryBool("bikes"), RouteID: c.Query("route_id")}
+		if raw := c.Query("direction_id"); raw != "" {
+			dir, err := strconv.Atoi(raw)
+			if err != nil || (dir != 0 && dir != 1) {
+				return errBadRequest(c, "direction_id must be 0 or 1")
+			}
+			filter.DirectionID = &dir
+		}
+		var err error
+		if filter.After, filter.Until, err = parseDepartureWindow(c.Query("date"), c.Query("after"), c.Query("until"), time.Now()); err != nil {
+			return errBadRequest(c, err.Error())
+		}
+
+		var departures []domain.Departure
+		if c.QueryBool("include_forecast") {
+			departures, err = deps.Departures.NextDeparturesWithForecast(c.Context(), id, limit, filter)
+		} else {
+			departures, err = deps.Departures.NextDeparturesAtStop(c.Context(), id, limit, filter)
+		}
 		if err != nil {
 			return errInternal(c, err.Error())
 		}
@@ -237,6 +452,35 @@ func StopDeparturesHandler(deps *Dependencies) fiber.Handler {
 	}
 }
 
+// NearbyDeparturesHandler returns the next departures from the stops around
+// a point, soonest first, so a home screen needs a single call.
+// GET /v1/departures/nearby?lat=43.26&lon=-2.93&radius=400&limit=20
+func NearbyDeparturesHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		lat := c.QueryFloat("lat", 0)
+		lon := c.QueryFloat("lon", 0)
+		radius := c.QueryFloat("radius", 400)
+		if lat == 0 || lon == 0 {
+			return errBadRequest(c, "lat and lon are required")
+		}
+		if radius <= 0 || radius > 2000 {
+			return errBadRequest(c, "radius must be between 1 and 2000 meters")
+		}
+
+		filter := domain.DepartureFilter{Bikes: c.QueryBool("bikes")}
+		departures, err := deps.Departures.NextDeparturesNearby(c.Context(), lat, lon, radius, c.QueryInt("limit", 20), filter)
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		if departures == nil {
+			departures = []domain.NearbyDeparture{}
+		}
+		// Predictions move; keep this fresher than the default.
+		c.Set("Cache-Control", "public, max-age=30")
+		return c.JSON(departures)
+	}
+}
+
 // GetAgencyHandler returns a single agency by slug.
 func GetAgencyHandler(deps *Dependencies) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -248,50 +492,42 @@ func GetAgencyHandler(deps *Dependencies) fiber.Handler {
 		if err != nil {
 			return errNotFound(c, "agency not found")
 		}
+		surrogateKeys(c, usecases.AgencyKey(agency.Slug))
+		cdnCacheable(c, deps)
+		if notModified(c, agency.ID, agency.UpdatedAt) {
+			return nil
+		}
 		return c.JSON(agency)
 	}
 }
 
-// AgencyRoutesHandler returns all routes belonging to an agency (by slug).
+// AgencyRoutesHandler returns a page of the routes belonging to an agency
+// (by slug), optionally filtered by route type.
 func AgencyRoutesHandler(deps *Dependencies) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		slug := c.Params("slug")
 		if slug == "" {
 			return errBadRequest(c, "agency slug is required")
 		}
+		routeTypes, err := parseRouteTypes(c)
+		if err != nil {
+			return errBadRequest(c, err.Error())
+		}
 
 		// Resolve slug → agency ID
 		agency, err := deps.Agencies.GetBySlug(c.Context(), slug)
 		if err != nil {
 			return errNotFound(c, "agency not found")
 		}
+		surrogateKeys(c, usecases.AgencyKey(agency.Slug))
 
-		routes, err := deps.Routes.ListByAgency(c.Context(), agency.ID)
+		offset, limit := parsePage(c, 500)
+		filter := domain.RouteFilter{AgencyID: agency.ID, RouteTypes: routeTypes}
+		routes, total, err := deps.Routes.List(c.Context(), filter, offset, limit)
 		if err != nil {
 			return errInternal(c, err.Error())
 		}
 
-		// Pagination
-		offset := c.QueryInt("offset", 0)
-		limit := c.QueryInt("limit", 100)
-		if offset < 0 {
-			offset = 0
-		}
-		if limit <= 0 || limit > 500 {
-			limit = 100
-		}
-
-		total := len(routes)
-		if offset >= total {
-			routes = nil
-		} else {
-			end := offset + limit
-			if end > total {
-				end = total
-			}
-			routes = routes[offset:end]
-		}
-
 		pg := Pagination{Offset: offset, Limit: limit, Total: total}
 		SetLinkHeaders(c, pg)
 		return c.JSON(PaginatedResponse{Data: routes, Pagination: pg})
@@ -354,21 +590,45 @@ func GetTripHandler(deps *Dependencies) fiber.Handler {
 		if id == "" {
 			return errBadRequest(c, "trip id is required")
 		}
+		if staticNotModified(c, deps.StaticVersion) {
+			return nil
+		}
 		trip, err := deps.Trips.GetByID(c.Context(), id)
 		if err != nil {
 			return errNotFound(c, "trip not found")
 		}
+		if notModified(c, trip.ID, trip.UpdatedAt) {
+			return nil
+		}
 		return c.JSON(trip)
 	}
 }
 
 // TripStopTimesHandler returns the stop-times for a trip, ordered by sequence.
+// With ?as_of, they come from the feed version current at that time.
 func TripStopTimesHandler(deps *Dependencies) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		id := c.Params("id")
 		if id == "" {
 			return errBadRequest(c, "trip id is required")
 		}
+		asOf, historic, err := parseAsOf(c)
+		if err != nil {
+			return errBadRequest(c, err.Error())
+		}
+		if historic {
+			stopTimes, err := deps.FeedHistory.StopTimes(c.Context(), id, asOf)
+			if errors.Is(err, usecases.ErrTripNotFound) {
+				return errNotFound(c, "trip not in the feed version current at as_of")
+			}
+			if err != nil {
+				return errInternal(c, err.Error())
+			}
+			return c.JSON(stopTimes)
+		}
+		if staticNotModified(c, deps.StaticVersion) {
+			return nil
+		}
 		stopTimes, err := deps.Trips.GetStopTimes(c.Context(), id)
 		if err != nil {
 			return errInternal(c, err.Error())
@@ -377,9 +637,17 @@ func TripStopTimesHandler(deps *Dependencies) fiber.Handler {
 	}
 }
 
-// JourneyHandler plans a journey between two stops.
+// JourneyHandler plans a journey between two stops, or between two points
+// with walks to and from nearby stops. Walks name the street they run along,
+// in ?lang or the Accept-Language language, when geocoding is on.
 // GET /v1/journeys?from=<stop_uuid>&to=<stop_uuid>&depart_at=15:30&max_transfers=1
 // GET /v1/journeys?from_name=Abando&to_name=Sarriko
+// GET /v1/journeys?from_lat=43.26&from_lon=-2.93&to_lat=43.27&to_lon=-2.95
+// GET /v1/journeys?from=...&to=...&accessible=wheelchair (or stroller)
+// GET /v1/journeys?from=...&to=...&exclude_route_type=bus
+// GET /v1/journeys?from=...&to=...&wheelchair=true&bikes=true
+// GET /v1/journeys?from=...&to=...&prefer=fewest_transfers&limit=3
+// GET /v1/journeys?from_lat=...&to_lon=...&mode=walk (or bike)
 func JourneyHandler(deps *Dependencies) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		fromID := c.Query("from")
@@ -387,74 +655,166 @@ func JourneyHandler(deps *Dependencies) fiber.Handler {
 		fromName := c.Query("from_name")
 		toName := c.Query("to_name")
 
-		// Parse optional departure time (HH:MM or full ISO)
-		var departAt *time.Time
-		if raw := c.Query("depart_at"); raw != "" {
-			// Try HH:MM format first
-			if t, err := time.Parse("15:04", raw); err == nil {
-				now := time.Now()
-				full := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
-				departAt = &full
-			} else if t, err := time.Parse(time.RFC3339, raw); err == nil {
-				departAt = &t
-			}
-		}
+		departAt := parseDepartAt(c.Query("depart_at"))
 
 		maxTransfers := c.QueryInt("max_transfers", 1)
+		accessible := c.Query("accessible")
+		routeTypes, err := parseRouteTypes(c)
+		if err != nil {
+			return errBadRequest(c, err.Error())
+		}
+		filter := domain.JourneyFilter{
+			RouteTypes: routeTypes,
+			Wheelchair: c.QueryBool("wheelchair"),
+			Bikes:      c.QueryBool("bikes"),
+			Mode:       c.Query("mode"),
+		}
+		ranking := domain.JourneyRanking{Prefer: c.Query("prefer"), Limit: c.QueryInt("limit", 0)}
 
-		// By name or by ID
+		// By coordinates, name or ID
+		if c.Query("from_lat") != "" || c.Query("from_lon") != "" || c.Query("to_lat") != "" || c.Query("to_lon") != "" {
+			from := domain.GeoPoint{Lat: c.QueryFloat("from_lat", 0), Lon: c.QueryFloat("from_lon", 0)}
+			to := domain.GeoPoint{Lat: c.QueryFloat("to_lat", 0), Lon: c.QueryFloat("to_lon", 0)}
+			if from.Lat == 0 || from.Lon == 0 || to.Lat == 0 || to.Lon == 0 {
+				return errBadRequest(c, "from_lat, from_lon, to_lat and to_lon are all required")
+			}
+			if math.Abs(from.Lat) > 90 || math.Abs(to.Lat) > 90 || math.Abs(from.Lon) > 180 || math.Abs(to.Lon) > 180 {
+				return errBadRequest(c, "coordinates out of range")
+			}
+			plan, err := deps.Journeys.PlanJourneyBetween(c.Context(), from, to, departAt, maxTransfers, accessible, filter, ranking)
+			if err != nil {
+				return errBadRequest(c, err.Error())
+			}
+			describeWalks(c, deps, plan)
+			return c.JSON(journeyResponse(plan))
+		}
 		if fromName != "" && toName != "" {
-			journeys, err := deps.Journeys.PlanJourneyByName(c.Context(), fromName, toName, departAt)
+			plan, err := deps.Journeys.PlanJourneyByName(c.Context(), fromName, toName, departAt, accessible, filter, ranking)
 			if err != nil {
 				return errBadRequest(c, err.Error())
 			}
-			return c.JSON(journeyResponse(journeys))
+			describeWalks(c, deps, plan)
+			return c.JSON(journeyResponse(plan))
 		}
 
 		if fromID == "" || toID == "" {
 			return errBadRequest(c, "from and to (stop UUIDs) or from_name and to_name are required")
 		}
 
-		journeys, err := deps.Journeys.PlanJourney(c.Context(), fromID, toID, departAt, maxTransfers)
+		plan, err := deps.Journeys.PlanJourney(c.Context(), fromID, toID, departAt, maxTransfers, accessible, filter, ranking)
 		if err != nil {
 			return errBadRequest(c, err.Error())
 		}
+		describeWalks(c, deps, plan)
+		return c.JSON(journeyResponse(plan))
+	}
+}
+
+// parseDepartAt parses an optional departure time, HH:MM today or full ISO.
+// It returns nil when raw is empty or neither.
+func parseDepartAt(raw string) *time.Time {
+	if raw == "" {
+		return nil
+	}
+	// Try HH:MM format first
+	if t, err := time.Parse("15:04", raw); err == nil {
+		now := time.Now()
+		full := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+		return &full
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &t
+	}
+	return nil
+}
+
+// parseDepartureWindow reads the window departures are listed in: date
+// (YYYY-MM-DD, today by default) and after and until as HH:MM on that date
+// or RFC 3339 times. Without after, the window starts now today and at
+// midnight on other dates; an HH:MM until not later than the start is on
+// the next day.
+func parseDepartureWindow(date, after, until string, now time.Time) (*time.Time, *time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	day := today
+	if date != "" {
+		d, err := time.ParseInLocation("2006-01-02", date, now.Location())
+		if err != nil {
+			return nil, nil, errors.New("date must be YYYY-MM-DD")
+		}
+		day = d
+	}
+	// at parses a time, reporting whether it was a time of day.
+	at := func(raw string) (time.Time, bool, error) {
+		if t, err := time.Parse("15:04", raw); err == nil {
+			return day.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute), true, nil
+		}
+		if t, err := time.Parse(time.RFC3339, raw); err == nil {
+			return t, false, nil
+		}
+		return time.Time{}, false, errors.New("after and until must be HH:MM or RFC 3339 times")
+	}
 
-		return c.JSON(journeyResponse(journeys))
+	var from, to *time.Time
+	start := now
+	if after != "" {
+		t, _, err := at(after)
+		if err != nil {
+			return nil, nil, err
+		}
+		from, start = &t, t
+	} else if !day.Equal(today) {
+		from, start = &day, day
 	}
+	if until != "" {
+		t, clock, err := at(until)
+		if err != nil {
+			return nil, nil, err
+		}
+		if clock && !t.After(start) {
+			t = t.AddDate(0, 0, 1)
+		}
+		if !t.After(start) {
+			return nil, nil, errors.New("until must be after the start of the window")
+		}
+		to = &t
+	}
+	return from, to, nil
 }
 
-// journeyResponse formats journeys with human-readable durations.
-func journeyResponse(journeys []domain.Journey) fiber.Map {
+// journeyResponse formats journeys with human-readable durations, plus the
+// event overlays, the walk or ride along the streets and, when there are no
+// journeys, the fallback suggestions.
+func journeyResponse(plan *domain.JourneyPlan) fiber.Map {
 	type legResp struct {
-		Route       interface{} `json:"route"`
-		FromStop    interface{} `json:"from_stop"`
-		ToStop      interface{} `json:"to_stop"`
-		DepartureAt string      `json:"departure_at"`
-		ArrivalAt   string      `json:"arrival_at"`
+		Walk            bool                    `json:"walk,omitempty"`
+		WalkMinutes     int                     `json:"walk_minutes,omitempty"`
+		Route           interface{}             `json:"route"`
+		FromStop        interface{}             `json:"from_stop"`
+		ToStop          interface{}             `json:"to_stop"`
+		DepartureAt     string                  `json:"departure_at"`
+		ArrivalAt       string                  `json:"arrival_at"`
+		AccessibleSpace *domain.AccessibleSpace `json:"accessible_space,omitempty"`
+		HeadwaySecs     int                     `json:"headway_secs,omitempty"`
+		BikesRestricted bool                    `json:"bikes_restricted,omitempty"`
+		Along           *domain.Place           `json:"along,omitempty"`
 	}
 
 	type journeyResp struct {
-		Legs          []legResp `json:"legs"`
-		DepartureTime string    `json:"departure_time"`
-		ArrivalTime   string    `json:"arrival_time"`
-		Duration      string    `json:"duration"`
-		DurationMin   int       `json:"duration_minutes"`
-		Transfers     int       `json:"transfers"`
+		Legs          []legResp         `json:"legs"`
+		DepartureTime string            `json:"departure_time"`
+		ArrivalTime   string            `json:"arrival_time"`
+		Duration      string            `json:"duration"`
+		DurationMin   int               `json:"duration_minutes"`
+		Transfers     int               `json:"transfers"`
+		Zones         []domain.FareZone `json:"zones,omitempty"`
 	}
 
 	var results []journeyResp
-	for _, j := range journeys {
+	for _, j := range plan.Journeys {
 		var legs []legResp
 		for _, l := range j.Legs {
-			legs = append(legs, legResp{
-				Route: fiber.Map{
-					"id":         l.Route.ID,
-					"short_name": l.Route.ShortName,
-					"long_name":  l.Route.LongName,
-					"color":      l.Route.Color,
-					"route_type": l.Route.RouteType,
-				},
+			leg := legResp{
+				Walk: l.Walk,
 				FromStop: fiber.Map{
 					"id":       l.FromStop.ID,
 					"name":     l.FromStop.Name,
@@ -465,9 +825,26 @@ func journeyResponse(journeys []domain.Journey) fiber.Map {
 					"name":     l.ToStop.Name,
 					"location": l.ToStop.Location,
 				},
-				DepartureAt: l.Departure.ScheduledTime.Format("15:04"),
-				ArrivalAt:   l.ArrivalTime.Format("15:04"),
-			})
+				DepartureAt:     l.Departure.ScheduledTime.Format("15:04"),
+				ArrivalAt:       l.ArrivalTime.Format("15:04"),
+				AccessibleSpace: l.AccessibleSpace,
+				HeadwaySecs:     l.HeadwaySecs,
+				BikesRestricted: l.BikesRestricted,
+				Along:           l.Along,
+			}
+			if l.Walk {
+				leg.WalkMinutes = walkMinutes(&l)
+			}
+			if l.Route != nil {
+				leg.Route = fiber.Map{
+					"id":         l.Route.ID,
+					"short_name": l.Route.ShortName,
+					"long_name":  l.Route.LongName,
+					"color":      l.Route.Color,
+					"route_type": l.Route.RouteType,
+				}
+			}
+			legs = append(legs, leg)
 		}
 		results = append(results, journeyResp{
 			Legs:          legs,
@@ -476,13 +853,24 @@ func journeyResponse(journeys []domain.Journey) fiber.Map {
 			Duration:      j.Duration.String(),
 			DurationMin:   int(j.Duration.Minutes()),
 			Transfers:     j.Transfers,
+			Zones:         j.Zones,
 		})
 	}
 
-	return fiber.Map{
+	resp := fiber.Map{
 		"journeys": results,
 		"count":    len(results),
 	}
+	if len(plan.Events) > 0 {
+		resp["events"] = plan.Events
+	}
+	if plan.Street != nil {
+		resp["street"] = plan.Street
+	}
+	if plan.Fallback != nil {
+		resp["fallback"] = plan.Fallback
+	}
+	return resp
 }
 
 // AgencyStatsHandler returns detailed stats for a single agency.
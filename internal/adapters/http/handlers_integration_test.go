@@ -6,6 +6,7 @@ package http_test
 import (
 	"context"
 	"encoding/json"
+	"io/fs"
 	"net/http/httptest"
 	"testing"
 	"time"
@@ -16,6 +17,7 @@ import (
 	"github.com/samirrijal/bilbopass/internal/core/domain"
 	"github.com/samirrijal/bilbopass/internal/core/usecases"
 	"github.com/samirrijal/bilbopass/internal/pkg/config"
+	"github.com/samirrijal/bilbopass/migrations"
 )
 
 // setupTestDB connects to the test database and returns a clean DB instance.
@@ -50,65 +52,31 @@ func setupTestDeps(t *testing.T, db *postgres.DB) *http.Dependencies {
 	stopRepo := postgres.NewStopRepo(db)
 	routeRepo := postgres.NewRouteRepo(db)
 	tripRepo := postgres.NewTripRepo(db)
-	vehicleRepo := postgres.NewVehicleRepo(db)
+	vehicleRepo := postgres.NewVehiclePositionRepo(db)
 
 	return &http.Dependencies{
 		Agencies:   usecases.NewAgencyService(agencyRepo),
 		Stops:      usecases.NewStopService(stopRepo, nil),
-		Routes:     usecases.NewRouteService(routeRepo, vehicleRepo),
-		Departures: usecases.NewDepartureService(tripRepo),
+		Routes:     usecases.NewRouteService(routeRepo, vehicleRepo, nil),
+		Departures: usecases.NewDepartureService(tripRepo, postgres.NewTripUpdateRepo(db), nil, nil),
 		Trips:      usecases.NewTripService(tripRepo),
 		DB:         db,
 	}
 }
 
-// seedTestAgency inserts a test agency and returns its UUID.
-func seedTestAgency(t *testing.T, db *postgres.DB, slug string) string {
-	ctx := context.Background()
-	agency := &domain.Agency{
-		Slug:     slug,
-		Name:     "Test Agency " + slug,
-		Timezone: "Europe/Madrid",
-	}
-	if err := db.Pool.QueryRow(ctx, `
-		INSERT INTO agencies (slug, name, timezone)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (slug) DO UPDATE SET name = EXCLUDED.name
-		RETURNING id
-	`, agency.Slug, agency.Name, agency.Timezone).Scan(&agency.ID); err != nil {
-		t.Fatalf("seed agency: %v", err)
-	}
-	return agency.ID
-}
-
-// seedTestStop inserts a test stop and returns its UUID.
-func seedTestStop(t *testing.T, db *postgres.DB, agencyID, stopID, name string) string {
-	ctx := context.Background()
-	var id string
-	if err := db.Pool.QueryRow(ctx, `
-		INSERT INTO stops (agency_id, stop_id, name, location)
-		VALUES ($1, $2, $3, ST_Point(-2.935, 43.263, 4326))
-		ON CONFLICT (agency_id, stop_id) DO UPDATE SET name = EXCLUDED.name
-		RETURNING id
-	`, agencyID, stopID, name).Scan(&id); err != nil {
-		t.Fatalf("seed stop: %v", err)
-	}
-	return id
-}
+// fixtureMetro is an agency of the Bilbao fixture (migrations/seed/bilbao.sql).
+const fixtureMetro = "metro_bilbao"
 
-// seedTestRoute inserts a test route and returns its UUID.
-func seedTestRoute(t *testing.T, db *postgres.DB, agencyID, routeID, shortName, longName string) string {
-	ctx := context.Background()
-	var id string
-	if err := db.Pool.QueryRow(ctx, `
-		INSERT INTO routes (agency_id, route_id, short_name, long_name, route_type, color)
-		VALUES ($1, $2, $3, $4, 3, 'FF0000')
-		ON CONFLICT (agency_id, route_id) DO UPDATE SET short_name = EXCLUDED.short_name
-		RETURNING id
-	`, agencyID, routeID, shortName, longName).Scan(&id); err != nil {
-		t.Fatalf("seed route: %v", err)
-	}
-	return id
+// loadFixtures loads the Bilbao fixture, as `migrate seed` does, into a
+// database migrated with `migrate up`.
+func loadFixtures(t *testing.T, db *postgres.DB) {
+	sql, err := fs.ReadFile(migrations.FS, "seed/bilbao.sql")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	if _, err := db.Pool.Exec(context.Background(), string(sql)); err != nil {
+		t.Fatalf("load fixture: %v", err)
+	}
 }
 
 // TestListAgencies_Integration_WithRealDB tests agency listing against real database.
@@ -120,9 +88,7 @@ func TestListAgencies_Integration_WithRealDB(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Pool.Close()
 
-	// Seed test data
-	seedTestAgency(t, db, "test_metro")
-	seedTestAgency(t, db, "test_bilbobus")
+	loadFixtures(t, db)
 
 	// Create app with real repos
 	deps := setupTestDeps(t, db)
@@ -160,8 +126,8 @@ func TestGetAgency_Integration(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Pool.Close()
 
-	slug := "test_integ_" + time.Now().Format("20060102150405")
-	seedTestAgency(t, db, slug)
+	slug := fixtureMetro
+	loadFixtures(t, db)
 
 	deps := setupTestDeps(t, db)
 	app := setupApp(deps)
@@ -195,9 +161,8 @@ func TestNearbyStops_Integration(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Pool.Close()
 
-	agencyID := seedTestAgency(t, db, "test_spatial")
-	// Bilbao coordinates: 43.263, -2.935
-	seedTestStop(t, db, agencyID, "stop1", "Abando")
+	// Moyua, in the fixture, is at 43.263, -2.935
+	loadFixtures(t, db)
 
 	deps := setupTestDeps(t, db)
 	app := setupApp(deps)
@@ -1,10 +1,12 @@
 package http_test
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
@@ -15,6 +17,7 @@ import (
 	handler "github.com/samirrijal/bilbopass/internal/adapters/http"
 	"github.com/samirrijal/bilbopass/internal/core/domain"
 	"github.com/samirrijal/bilbopass/internal/core/usecases"
+	"github.com/samirrijal/bilbopass/internal/pkg/auth"
 )
 
 // ---- Mock repositories ----
@@ -31,23 +34,34 @@ func (m *mockAgencyRepo) List(ctx context.Context) ([]domain.Agency, error) {
 	}
 	return nil, nil
 }
+func (m *mockAgencyRepo) ListPage(ctx context.Context, offset, limit int) ([]domain.Agency, int, error) {
+	agencies, err := m.List(ctx)
+	if err != nil || offset >= len(agencies) {
+		return nil, len(agencies), err
+	}
+	return agencies[offset:min(offset+limit, len(agencies))], len(agencies), nil
+}
 func (m *mockAgencyRepo) GetBySlug(ctx context.Context, slug string) (*domain.Agency, error) {
 	if m.getBySlugFn != nil {
 		return m.getBySlugFn(ctx, slug)
 	}
 	return nil, nil
 }
+func (m *mockAgencyRepo) UpdateContact(ctx context.Context, agencyID string, c *domain.AgencyContact) error {
+	return nil
+}
 
 type mockStopRepo struct {
 	findNearbyFn func(ctx context.Context, lat, lon, radius float64, limit int) ([]domain.Stop, error)
 	getByIDFn    func(ctx context.Context, id string) (*domain.Stop, error)
 	getByIDsFn   func(ctx context.Context, ids []string) ([]domain.Stop, error)
 	searchFn     func(ctx context.Context, query string, near *domain.GeoPoint, limit int) ([]domain.Stop, error)
+	suggestions  []string
 }
 
 func (m *mockStopRepo) Upsert(ctx context.Context, s *domain.Stop) error       { return nil }
 func (m *mockStopRepo) UpsertBatch(ctx context.Context, s []domain.Stop) error { return nil }
-func (m *mockStopRepo) FindNearby(ctx context.Context, lat, lon, radius float64, limit int) ([]domain.Stop, error) {
+func (m *mockStopRepo) FindNearby(ctx context.Context, lat, lon, radius float64, limit int, filter domain.StopFilter) ([]domain.Stop, error) {
 	if m.findNearbyFn != nil {
 		return m.findNearbyFn(ctx, lat, lon, radius, limit)
 	}
@@ -63,7 +77,16 @@ func (m *mockStopRepo) GetByIDs(ctx context.Context, ids []string) ([]domain.Sto
 	if m.getByIDsFn != nil {
 		return m.getByIDsFn(ctx, ids)
 	}
-	return nil, nil
+	var stops []domain.Stop
+	for _, id := range ids {
+		if m.getByIDFn == nil {
+			break
+		}
+		if stop, _ := m.getByIDFn(ctx, id); stop != nil {
+			stops = append(stops, *stop)
+		}
+	}
+	return stops, nil
 }
 func (m *mockStopRepo) Search(ctx context.Context, query string, near *domain.GeoPoint, limit int) ([]domain.Stop, error) {
 	if m.searchFn != nil {
@@ -71,11 +94,23 @@ func (m *mockStopRepo) Search(ctx context.Context, query string, near *domain.Ge
 	}
 	return nil, nil
 }
+func (m *mockStopRepo) ListPlatforms(ctx context.Context, stationIDs []string) ([]domain.Stop, error) {
+	return nil, nil
+}
+func (m *mockStopRepo) Suggest(ctx context.Context, query string, limit int) ([]string, error) {
+	return m.suggestions, nil
+}
+func (m *mockStopRepo) UpdateAmenities(ctx context.Context, id string, a *domain.StopAmenities) (bool, error) {
+	return true, nil
+}
 
 type mockRouteRepo struct {
 	getByIDFn    func(ctx context.Context, id string) (*domain.Route, error)
 	listByAgFn   func(ctx context.Context, agencyID string) ([]domain.Route, error)
 	listByStopFn func(ctx context.Context, stopUUID string) ([]domain.Route, error)
+	getShapeFn   func(ctx context.Context, id string) (*domain.GeoLineString, error)
+	lastFilter   domain.RouteFilter
+	batches      int // GetByIDs calls
 }
 
 func (m *mockRouteRepo) Upsert(ctx context.Context, r *domain.Route) error       { return nil }
@@ -86,21 +121,53 @@ func (m *mockRouteRepo) GetByID(ctx context.Context, id string) (*domain.Route,
 	}
 	return nil, nil
 }
+func (m *mockRouteRepo) GetByIDs(ctx context.Context, ids []string) ([]domain.Route, error) {
+	m.batches++
+	var routes []domain.Route
+	for _, id := range ids {
+		if route, _ := m.GetByID(ctx, id); route != nil {
+			routes = append(routes, *route)
+		}
+	}
+	return routes, nil
+}
 func (m *mockRouteRepo) ListByAgency(ctx context.Context, agencyID string) ([]domain.Route, error) {
 	if m.listByAgFn != nil {
 		return m.listByAgFn(ctx, agencyID)
 	}
 	return nil, nil
 }
+func (m *mockRouteRepo) ListAll(ctx context.Context, filter domain.RouteFilter, offset, limit int) ([]domain.Route, int, error) {
+	m.lastFilter = filter
+	routes := []domain.Route{{ID: "r1", ShortName: "L1", RouteType: 1}}
+	if m.listByAgFn != nil {
+		routes, _ = m.listByAgFn(ctx, filter.AgencyID)
+	}
+	routes = usecases.FilterRoutes(routes, filter.RouteTypes)
+	if offset >= len(routes) {
+		return nil, len(routes), nil
+	}
+	return routes[offset:min(offset+limit, len(routes))], len(routes), nil
+}
 func (m *mockRouteRepo) ListByStop(ctx context.Context, stopUUID string) ([]domain.Route, error) {
 	if m.listByStopFn != nil {
 		return m.listByStopFn(ctx, stopUUID)
 	}
 	return nil, nil
 }
+func (m *mockRouteRepo) GetShape(ctx context.Context, id string) (*domain.GeoLineString, error) {
+	if m.getShapeFn != nil {
+		return m.getShapeFn(ctx, id)
+	}
+	return nil, nil
+}
 
 type mockVehicleRepo struct {
 	latestByRouteFn func(ctx context.Context, routeID string) ([]domain.VehiclePosition, error)
+	latestNearbyFn  func(ctx context.Context, lat, lon, radiusMeters float64, since time.Time, limit int) ([]domain.VehiclePosition, error)
+	historyFn       func(ctx context.Context, agency, vehicleID string, from, to time.Time, bucket time.Duration) ([]domain.VehiclePosition, error)
+	fleetFn         func(ctx context.Context, agency string, since, now time.Time) (*domain.Fleet, error)
+	batches         int // LatestByRoutes calls
 }
 
 func (m *mockVehicleRepo) Insert(ctx context.Context, vp *domain.VehiclePosition) error { return nil }
@@ -113,11 +180,43 @@ func (m *mockVehicleRepo) LatestByRoute(ctx context.Context, routeID string) ([]
 	}
 	return nil, nil
 }
+func (m *mockVehicleRepo) LatestByRoutes(ctx context.Context, routeIDs []string) ([]domain.VehiclePosition, error) {
+	m.batches++
+	var positions []domain.VehiclePosition
+	for _, id := range routeIDs {
+		vps, err := m.LatestByRoute(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		positions = append(positions, vps...)
+	}
+	return positions, nil
+}
+func (m *mockVehicleRepo) LatestNearby(ctx context.Context, lat, lon, radiusMeters float64, since time.Time, limit int, routeTypes domain.RouteTypeFilter) ([]domain.VehiclePosition, error) {
+	if m.latestNearbyFn != nil {
+		return m.latestNearbyFn(ctx, lat, lon, radiusMeters, since, limit)
+	}
+	return nil, nil
+}
+func (m *mockVehicleRepo) History(ctx context.Context, agency, vehicleID string, from, to time.Time, bucket time.Duration) ([]domain.VehiclePosition, error) {
+	if m.historyFn != nil {
+		return m.historyFn(ctx, agency, vehicleID, from, to, bucket)
+	}
+	return nil, nil
+}
+
+func (m *mockVehicleRepo) Fleet(ctx context.Context, agency string, since, now time.Time) (*domain.Fleet, error) {
+	if m.fleetFn != nil {
+		return m.fleetFn(ctx, agency, since, now)
+	}
+	return &domain.Fleet{AgencySlug: agency, Since: since}, nil
+}
 
 type mockTripRepo struct {
 	nextDepFn      func(ctx context.Context, stopUUID string, limit int) ([]domain.Departure, error)
 	getByIDFn      func(ctx context.Context, id string) (*domain.Trip, error)
 	getStopTimesFn func(ctx context.Context, tripID string) ([]domain.StopTime, error)
+	filter         domain.DepartureFilter // last departure filter asked for
 }
 
 func (m *mockTripRepo) Upsert(ctx context.Context, t *domain.Trip) error       { return nil }
@@ -135,12 +234,34 @@ func (m *mockTripRepo) GetStopTimes(ctx context.Context, tripID string) ([]domai
 	}
 	return nil, nil
 }
-func (m *mockTripRepo) NextDeparturesAtStop(ctx context.Context, stopUUID string, limit int) ([]domain.Departure, error) {
+func (m *mockTripRepo) NextDeparturesAtStop(ctx context.Context, stopUUID string, limit int, filter domain.DepartureFilter) ([]domain.Departure, error) {
+	m.filter = filter
 	if m.nextDepFn != nil {
 		return m.nextDepFn(ctx, stopUUID, limit)
 	}
 	return nil, nil
 }
+func (m *mockTripRepo) NextDeparturesNearby(ctx context.Context, lat, lon, radiusMeters float64, horizon time.Duration, limit int, filter domain.DepartureFilter) ([]domain.NearbyDeparture, error) {
+	return nil, nil
+}
+
+type mockShortLinkRepo struct {
+	getByCodeFn func(ctx context.Context, code string) (*domain.StopShortLink, error)
+}
+
+func (m *mockShortLinkRepo) Create(ctx context.Context, l *domain.StopShortLink) error { return nil }
+func (m *mockShortLinkRepo) GetByCode(ctx context.Context, code string) (*domain.StopShortLink, error) {
+	if m.getByCodeFn != nil {
+		return m.getByCodeFn(ctx, code)
+	}
+	return nil, fmt.Errorf("not found")
+}
+func (m *mockShortLinkRepo) ListByAgency(ctx context.Context, agencyID string) ([]domain.StopShortLink, error) {
+	return nil, nil
+}
+func (m *mockShortLinkRepo) StopsWithoutLink(ctx context.Context, agencyID string) ([]string, error) {
+	return nil, nil
+}
 
 // ---- Test helpers ----
 
@@ -154,9 +275,10 @@ func makeDeps(opts ...func(*handler.Dependencies)) *handler.Dependencies {
 	d := &handler.Dependencies{
 		Agencies:   usecases.NewAgencyService(&mockAgencyRepo{}),
 		Stops:      usecases.NewStopService(&mockStopRepo{}, nil),
-		Routes:     usecases.NewRouteService(&mockRouteRepo{}, &mockVehicleRepo{}),
-		Departures: usecases.NewDepartureService(&mockTripRepo{}),
+		Routes:     usecases.NewRouteService(&mockRouteRepo{}, &mockVehicleRepo{}, nil),
+		Departures: usecases.NewDepartureService(&mockTripRepo{}, nil, nil, nil),
 		Trips:      usecases.NewTripService(&mockTripRepo{}),
+		ShortLinks: usecases.NewShortLinkService(&mockShortLinkRepo{}, "https://bilbopass.eus", "/v1/stops/{stop_id}/departures"),
 	}
 	for _, o := range opts {
 		o(d)
@@ -309,6 +431,16 @@ func TestNearbyStops_BadRadius(t *testing.T) {
 	}
 }
 
+func TestJourney_PartialCoordinates(t *testing.T) {
+	app := setupApp(makeDeps())
+
+	req := httptest.NewRequest("GET", "/v1/journeys?from_lat=43.26&from_lon=-2.93&to_lat=43.27", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 400 {
+		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	}
+}
+
 func TestSearchStops_Success(t *testing.T) {
 	deps := makeDeps(func(d *handler.Dependencies) {
 		d.Stops = usecases.NewStopService(&mockStopRepo{
@@ -338,6 +470,95 @@ func TestSearchStops_MissingQuery(t *testing.T) {
 	}
 }
 
+func TestSearchStops_Near(t *testing.T) {
+	var got *domain.GeoPoint
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Stops = usecases.NewStopService(&mockStopRepo{
+			searchFn: func(ctx context.Context, query string, near *domain.GeoPoint, limit int) ([]domain.Stop, error) {
+				got = near
+				return nil, nil
+			},
+		}, nil)
+	})
+	app := setupApp(deps)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/v1/stops/search?q=sarriko&lat=43.27&lon=-2.96", nil), -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if got == nil || got.Lat != 43.27 || got.Lon != -2.96 {
+		t.Errorf("expected the search to rank near 43.27,-2.96, got %+v", got)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/v1/stops/search?q=sarriko&lat=43.27", nil), -1)
+	if resp.StatusCode != 400 {
+		t.Errorf("expected 400 for lat without lon, got %d", resp.StatusCode)
+	}
+}
+
+type mockGlobalSearchRepo struct{}
+
+func (m *mockGlobalSearchRepo) Agencies(ctx context.Context, query string, limit int) ([]domain.SearchHit, error) {
+	return nil, nil
+}
+func (m *mockGlobalSearchRepo) Routes(ctx context.Context, query string, limit int) ([]domain.SearchHit, error) {
+	return []domain.SearchHit{{Type: domain.SearchHitRoute, Score: 1, Route: &domain.Route{ShortName: "A3"}}}, nil
+}
+func (m *mockGlobalSearchRepo) Stops(ctx context.Context, query string, limit int) ([]domain.SearchHit, error) {
+	return nil, nil
+}
+
+func TestGlobalSearch(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.GlobalSearch = usecases.NewGlobalSearchService(&mockGlobalSearchRepo{}, nil)
+	})
+	app := setupApp(deps)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/v1/search?q=a3", nil), -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var body struct {
+		Results []domain.SearchHit `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body.Results) != 1 || body.Results[0].Route == nil || body.Results[0].Route.ShortName != "A3" {
+		t.Errorf("expected route A3, got %+v", body.Results)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/v1/search?q=a3&stops=-1", nil), -1)
+	if resp.StatusCode != 400 {
+		t.Errorf("expected 400 for a negative limit, got %d", resp.StatusCode)
+	}
+}
+
+func TestSearchStops_Suggestions(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Stops = usecases.NewStopService(&mockStopRepo{suggestions: []string{"Abando"}}, nil)
+	})
+	app := setupApp(deps)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/v1/stops/search?q=abandu", nil), -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var body struct {
+		Stops       []domain.Stop `json:"stops"`
+		Suggestions []string      `json:"suggestions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Stops == nil || len(body.Stops) != 0 {
+		t.Errorf("expected an empty stops list, got %v", body.Stops)
+	}
+	if len(body.Suggestions) != 1 || body.Suggestions[0] != "Abando" {
+		t.Errorf("expected the suggestion Abando, got %v", body.Suggestions)
+	}
+}
+
 func TestGetStop_NotFound(t *testing.T) {
 	deps := makeDeps(func(d *handler.Dependencies) {
 		d.Stops = usecases.NewStopService(&mockStopRepo{
@@ -378,448 +599,2501 @@ func TestGetStop_Success(t *testing.T) {
 	}
 }
 
-// ---- Route handler tests ----
-
-func TestGetRoute_Success(t *testing.T) {
+func TestGetStop_ETag(t *testing.T) {
+	updated := time.Date(2026, 3, 2, 8, 30, 0, 0, time.UTC)
 	deps := makeDeps(func(d *handler.Dependencies) {
-		d.Routes = usecases.NewRouteService(&mockRouteRepo{
-			getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
-				return &domain.Route{ID: id, LongName: "L1 Etxebarri-Ibarbengoa"}, nil
+		d.Stops = usecases.NewStopService(&mockStopRepo{
+			getByIDFn: func(ctx context.Context, id string) (*domain.Stop, error) {
+				return &domain.Stop{ID: id, Name: "Moyua", UpdatedAt: updated}, nil
 			},
-		}, &mockVehicleRepo{})
+		}, nil)
 	})
 	app := setupApp(deps)
 
-	req := httptest.NewRequest("GET", "/v1/routes/route-uuid", nil)
-	resp, _ := app.Test(req, -1)
-	if resp.StatusCode != 200 {
-		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	resp, _ := app.Test(httptest.NewRequest("GET", "/v1/stops/abc-123", nil), -1)
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != 200 || !strings.HasPrefix(etag, `W/"abc-123-`) {
+		t.Fatalf("expected 200 with an ETag from the stop's updated_at, got %d, %q", resp.StatusCode, etag)
+	}
+	if lm := resp.Header.Get("Last-Modified"); lm != "Mon, 02 Mar 2026 08:30:00 GMT" {
+		t.Errorf("unexpected Last-Modified %q", lm)
 	}
 
-	var route domain.Route
-	json.NewDecoder(resp.Body).Decode(&route)
-	if route.LongName != "L1 Etxebarri-Ibarbengoa" {
-		t.Errorf("unexpected route name: %s", route.LongName)
+	req := httptest.NewRequest("GET", "/v1/stops/abc-123", nil)
+	req.Header.Set("If-None-Match", etag)
+	resp, _ = app.Test(req, -1)
+	if resp.StatusCode != 304 || len(readBody(t, resp.Body)) != 0 {
+		t.Fatalf("expected an empty 304, got %d", resp.StatusCode)
+	}
+
+	updated = updated.Add(time.Second)
+	resp, _ = app.Test(req, -1)
+	if resp.StatusCode != 200 || resp.Header.Get("ETag") == etag {
+		t.Errorf("expected 200 with a new ETag after an update, got %d, %q", resp.StatusCode, resp.Header.Get("ETag"))
 	}
 }
 
-func TestGetRoute_NotFound(t *testing.T) {
+type mockStaticVersionRepo struct{ version time.Time }
+
+func (m *mockStaticVersionRepo) StaticVersion(ctx context.Context) (time.Time, error) {
+	return m.version, nil
+}
+
+func TestGetStop_StaticETag(t *testing.T) {
+	versions := usecases.NewStaticVersionService(&mockStaticVersionRepo{
+		version: time.Date(2026, 3, 2, 8, 30, 0, 0, time.UTC),
+	})
+	if _, err := versions.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	lookups := 0
 	deps := makeDeps(func(d *handler.Dependencies) {
-		d.Routes = usecases.NewRouteService(&mockRouteRepo{
-			getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
-				return nil, fmt.Errorf("not found")
+		d.StaticVersion = versions
+		d.Stops = usecases.NewStopService(&mockStopRepo{
+			getByIDFn: func(ctx context.Context, id string) (*domain.Stop, error) {
+				lookups++
+				return &domain.Stop{ID: id, Name: "Moyua", UpdatedAt: time.Now()}, nil
 			},
-		}, &mockVehicleRepo{})
+		}, nil)
 	})
 	app := setupApp(deps)
 
-	req := httptest.NewRequest("GET", "/v1/routes/bad-id", nil)
-	resp, _ := app.Test(req, -1)
-	if resp.StatusCode != 404 {
-		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	resp, _ := app.Test(httptest.NewRequest("GET", "/v1/stops/abc-123", nil), -1)
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != 200 || !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("expected 200 with a strong ETag, got %d, %q", resp.StatusCode, etag)
+	}
+	if lm := resp.Header.Get("Last-Modified"); lm != "Mon, 02 Mar 2026 08:30:00 GMT" {
+		t.Errorf("expected Last-Modified from the static version, got %q", lm)
+	}
+
+	req := httptest.NewRequest("GET", "/v1/stops/abc-123", nil)
+	req.Header.Set("If-None-Match", `"other", W/`+etag)
+	resp, _ = app.Test(req, -1)
+	if resp.StatusCode != 304 || len(readBody(t, resp.Body)) != 0 {
+		t.Fatalf("expected an empty 304, got %d", resp.StatusCode)
+	}
+	if lookups != 1 {
+		t.Errorf("expected the conditional hit to skip the lookup, got %d lookups", lookups)
 	}
 }
 
-func TestListRoutes_MissingAgencyID(t *testing.T) {
-	app := setupApp(makeDeps())
+func TestGetStop_StaticETagCompressed(t *testing.T) {
+	versions := usecases.NewStaticVersionService(&mockStaticVersionRepo{
+		version: time.Date(2026, 3, 2, 8, 30, 0, 0, time.UTC),
+	})
+	if _, err := versions.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.StaticVersion = versions
+		d.Stops = usecases.NewStopService(&mockStopRepo{
+			getByIDFn: func(ctx context.Context, id string) (*domain.Stop, error) {
+				return &domain.Stop{ID: id, Name: strings.Repeat("Moyua ", 400)}, nil
+			},
+		}, nil)
+	})
+	app := setupApp(deps)
 
-	req := httptest.NewRequest("GET", "/v1/routes", nil)
-	resp, _ := app.Test(req, -1)
-	if resp.StatusCode != 400 {
-		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	get := func(acceptEncoding, ifNoneMatch string) *http.Response {
+		req := httptest.NewRequest("GET", "/v1/stops/abc-123", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		resp, _ := app.Test(req, -1)
+		return resp
+	}
+
+	// The identity response keeps the strong tag; the compressed ones
+	// carry its weak form, as their bytes differ.
+	identity := get("identity", "")
+	strong := identity.Header.Get("ETag")
+	if identity.StatusCode != 200 || identity.Header.Get("Content-Encoding") != "" || !strings.HasPrefix(strong, `"`) {
+		t.Fatalf("expected an uncompressed 200 with a strong ETag, got %d, %q, %q",
+			identity.StatusCode, identity.Header.Get("Content-Encoding"), strong)
+	}
+	for _, encoding := range []string{"gzip", "br"} {
+		resp := get(encoding, "")
+		if got := resp.Header.Get("Content-Encoding"); got != encoding {
+			t.Fatalf("expected the response compressed with %s, got %q", encoding, got)
+		}
+		if got := resp.Header.Get("ETag"); got != "W/"+strong {
+			t.Errorf("%s: expected ETag W/%s, got %q", encoding, strong, got)
+		}
+		if !strings.Contains(resp.Header.Get("Vary"), "Accept-Encoding") {
+			t.Errorf("%s: expected Vary: Accept-Encoding, got %q", encoding, resp.Header.Get("Vary"))
+		}
+	}
+
+	// Either tag revalidates either representation.
+	for _, tc := range []struct{ acceptEncoding, ifNoneMatch string }{
+		{"gzip", "W/" + strong},
+		{"gzip", strong},
+		{"identity", "W/" + strong},
+	} {
+		if resp := get(tc.acceptEncoding, tc.ifNoneMatch); resp.StatusCode != 304 {
+			t.Errorf("Accept-Encoding %q, If-None-Match %q: expected 304, got %d", tc.acceptEncoding, tc.ifNoneMatch, resp.StatusCode)
+		}
 	}
 }
 
-func TestListRoutes_Success(t *testing.T) {
+// recordingPurger records the surrogate keys purged.
+type recordingPurger struct{ keys []string }
+
+func (p *recordingPurger) Purge(ctx context.Context, keys []string) error {
+	p.keys = append(p.keys, keys...)
+	return nil
+}
+
+func TestGetStop_SurrogateKey(t *testing.T) {
+	purger := &recordingPurger{}
 	deps := makeDeps(func(d *handler.Dependencies) {
-		d.Routes = usecases.NewRouteService(&mockRouteRepo{
-			listByAgFn: func(ctx context.Context, agencyID string) ([]domain.Route, error) {
-				return []domain.Route{
-					{ID: "r1", LongName: "Line 1"},
-					{ID: "r2", LongName: "Line 2"},
-				}, nil
+		d.AdminToken = "s3cret"
+		d.CDN = usecases.NewCDNPurgeService(purger, nil, nil)
+		d.Stops = usecases.NewStopService(&mockStopRepo{
+			getByIDFn: func(ctx context.Context, id string) (*domain.Stop, error) {
+				return &domain.Stop{ID: id, Name: "Moyua"}, nil
 			},
-		}, &mockVehicleRepo{})
+		}, nil)
 	})
 	app := setupApp(deps)
 
-	req := httptest.NewRequest("GET", "/v1/routes?agency_id=abc", nil)
-	resp, _ := app.Test(req, -1)
-	if resp.StatusCode != 200 {
-		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	resp, _ := app.Test(httptest.NewRequest("GET", "/v1/stops/abc-123", nil), -1)
+	if key := resp.Header.Get("Surrogate-Key"); key != "stop:abc-123" {
+		t.Errorf("expected Surrogate-Key stop:abc-123, got %q", key)
+	}
+	if sc := resp.Header.Get("Surrogate-Control"); sc == "" {
+		t.Error("expected Surrogate-Control with purging on")
 	}
 
-	var result struct {
-		Data       []domain.Route `json:"data"`
-		Pagination struct {
-			Total int `json:"total"`
-		} `json:"pagination"`
+	purge := func(body string) int {
+		req := httptest.NewRequest("POST", "/v1/admin/cdn/purge", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req, -1)
+		return resp.StatusCode
 	}
-	json.NewDecoder(resp.Body).Decode(&result)
-	if result.Pagination.Total != 2 {
-		t.Errorf("expected 2 routes total, got %d", result.Pagination.Total)
+	if code := purge(`{"keys":["stop:abc-123","stop:abc-123"]}`); code != 200 || len(purger.keys) != 1 {
+		t.Errorf("expected 200 purging one key, got %d, %v", code, purger.keys)
+	}
+	if code := purge(`{"keys":["trip:t1"]}`); code != 400 {
+		t.Errorf("expected 400 for an unknown kind of key, got %d", code)
+	}
+	if code := purge(`{}`); code != 400 {
+		t.Errorf("expected 400 without keys or agency, got %d", code)
+	}
+
+	// Without a CDN responses are tagged but not kept, and purges refused.
+	deps.CDN = nil
+	resp, _ = app.Test(httptest.NewRequest("GET", "/v1/stops/abc-123", nil), -1)
+	if resp.Header.Get("Surrogate-Key") == "" || resp.Header.Get("Surrogate-Control") != "" {
+		t.Errorf("expected only Surrogate-Key, got %v", resp.Header)
+	}
+	if code := purge(`{"keys":["stop:abc-123"]}`); code != 503 {
+		t.Errorf("expected 503 without a CDN, got %d", code)
 	}
 }
 
-// ---- Vehicles handler tests ----
+// mockFeedHistoryRepo answers as_of lookups; nil functions find nothing.
+type mockFeedHistoryRepo struct {
+	stopAsOfFn func(ctx context.Context, id string, at time.Time) (*domain.Stop, error)
+}
 
-func TestGetRouteVehicles_Success(t *testing.T) {
+func (m *mockFeedHistoryRepo) Versions(ctx context.Context, agencyID string) ([]domain.FeedVersion, error) {
+	return nil, nil
+}
+func (m *mockFeedHistoryRepo) StopAsOf(ctx context.Context, id string, at time.Time) (*domain.Stop, error) {
+	if m.stopAsOfFn != nil {
+		return m.stopAsOfFn(ctx, id, at)
+	}
+	return nil, nil
+}
+func (m *mockFeedHistoryRepo) RouteAsOf(ctx context.Context, id string, at time.Time) (*domain.Route, error) {
+	return nil, nil
+}
+func (m *mockFeedHistoryRepo) RoutesAsOf(ctx context.Context, agencyID string, at time.Time) ([]domain.Route, error) {
+	return nil, nil
+}
+func (m *mockFeedHistoryRepo) StopTimesAsOf(ctx context.Context, tripID string, at time.Time) ([]domain.StopTime, error) {
+	return nil, nil
+}
+
+func TestGetStop_AsOf(t *testing.T) {
+	var gotAt time.Time
 	deps := makeDeps(func(d *handler.Dependencies) {
-		d.Routes = usecases.NewRouteService(&mockRouteRepo{}, &mockVehicleRepo{
-			latestByRouteFn: func(ctx context.Context, routeID string) ([]domain.VehiclePosition, error) {
-				return []domain.VehiclePosition{
-					{VehicleID: "v1", Location: domain.GeoPoint{Lat: 43.26, Lon: -2.93}},
-				}, nil
+		d.FeedHistory = usecases.NewFeedHistoryService(&mockFeedHistoryRepo{
+			stopAsOfFn: func(ctx context.Context, id string, at time.Time) (*domain.Stop, error) {
+				gotAt = at
+				return &domain.Stop{ID: id, Name: "Abando"}, nil
 			},
-		})
+		}, &mockAgencyRepo{})
 	})
 	app := setupApp(deps)
 
-	req := httptest.NewRequest("GET", "/v1/routes/some-route/vehicles", nil)
+	req := httptest.NewRequest("GET", "/v1/stops/abc-123?as_of=2026-02-14", nil)
 	resp, _ := app.Test(req, -1)
 	if resp.StatusCode != 200 {
 		t.Fatalf("expected 200, got %d", resp.StatusCode)
 	}
+	var stop domain.Stop
+	json.NewDecoder(resp.Body).Decode(&stop)
+	if stop.Name != "Abando" || !gotAt.Equal(time.Date(2026, 2, 14, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the archived stop at the start of the day, got %q at %s", stop.Name, gotAt)
+	}
 
-	var vehicles []domain.VehiclePosition
-	json.NewDecoder(resp.Body).Decode(&vehicles)
-	if len(vehicles) != 1 {
-		t.Errorf("expected 1 vehicle, got %d", len(vehicles))
+	req = httptest.NewRequest("GET", "/v1/stops/abc-123?as_of=last-week", nil)
+	resp, _ = app.Test(req, -1)
+	if resp.StatusCode != 400 {
+		t.Errorf("expected 400 for a bad as_of, got %d", resp.StatusCode)
 	}
 }
 
-// ---- Departure handler tests ----
-
-func TestStopDepartures_Success(t *testing.T) {
-	now := time.Now()
+func TestTripStopTimes_AsOfNotInVersion(t *testing.T) {
 	deps := makeDeps(func(d *handler.Dependencies) {
-		d.Departures = usecases.NewDepartureService(&mockTripRepo{
-			nextDepFn: func(ctx context.Context, stopUUID string, limit int) ([]domain.Departure, error) {
-				return []domain.Departure{
-					{ScheduledTime: now, Platform: "1"},
-				}, nil
-			},
-		})
+		d.FeedHistory = usecases.NewFeedHistoryService(&mockFeedHistoryRepo{}, &mockAgencyRepo{})
 	})
 	app := setupApp(deps)
 
-	req := httptest.NewRequest("GET", "/v1/stops/stop-uuid/departures", nil)
+	req := httptest.NewRequest("GET", "/v1/trips/trip-1/stop-times?as_of=2026-02-14T08:00:00Z", nil)
 	resp, _ := app.Test(req, -1)
-	if resp.StatusCode != 200 {
-		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	if resp.StatusCode != 404 {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
 	}
 }
 
-// ---- Health handler tests ----
+// mockFeedStatusRepo returns one feed that last answered an hour ago.
+type mockFeedStatusRepo struct{}
 
-func TestHealth_Returns200(t *testing.T) {
-	app := setupApp(makeDeps())
+func (m *mockFeedStatusRepo) RecordPoll(ctx context.Context, p *domain.RealtimeFeedPoll) error {
+	return nil
+}
+func (m *mockFeedStatusRepo) List(ctx context.Context) ([]domain.RealtimeFeedStatus, error) {
+	hourAgo := time.Now().Add(-time.Hour)
+	return []domain.RealtimeFeedStatus{
+		{AgencySlug: "bizkaibus", Feed: "vehicle_positions", LastPollAt: time.Now(), LastSuccessAt: &hourAgo, FeedTimestamp: &hourAgo},
+	}, nil
+}
 
-	req := httptest.NewRequest("GET", "/v1/health", nil)
+func TestRealtimeFeedStatus(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.RTFeeds = usecases.NewRealtimeFeedStatusService(&mockFeedStatusRepo{}, 5*time.Minute)
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/feeds/realtime/status", nil)
 	resp, _ := app.Test(req, -1)
 	if resp.StatusCode != 200 {
 		t.Fatalf("expected 200, got %d", resp.StatusCode)
 	}
-
-	var result map[string]interface{}
-	json.NewDecoder(resp.Body).Decode(&result)
-	if result["status"] != "healthy" {
-		t.Errorf("expected healthy status, got %v", result["status"])
+	var body struct {
+		StaleAfter int                         `json:"stale_after_seconds"`
+		Feeds      []domain.RealtimeFeedStatus `json:"feeds"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if body.StaleAfter != 300 || len(body.Feeds) != 1 || !body.Feeds[0].Stale {
+		t.Errorf("expected one stale feed, got %+v", body)
 	}
 }
 
-func TestReady_NoDB(t *testing.T) {
-	deps := makeDeps()
-	// DB, NATS, Cache are nil → should report not ready
-	app := setupApp(deps)
+func TestOfflineBundle_BadRequest(t *testing.T) {
+	app := setupApp(makeDeps())
 
-	req := httptest.NewRequest("GET", "/v1/ready", nil)
-	resp, _ := app.Test(req, -1)
-	// With nil DB, ready should return 503
-	if resp.StatusCode != 503 {
-		t.Fatalf("expected 503, got %d", resp.StatusCode)
+	for _, q := range []string{
+		"bbox=-2.96,43.24,-2.90,43.28",
+		"agency=metro_bilbao",
+		"agency=metro_bilbao&bbox=-2.96,43.24,-2.90",
+	} {
+		resp, _ := app.Test(httptest.NewRequest("GET", "/v1/offline/bundle?"+q, nil), -1)
+		if resp.StatusCode != 400 {
+			t.Errorf("%s: expected 400, got %d", q, resp.StatusCode)
+		}
 	}
 }
 
-// ---- Nearby stops Cache-Control header ----
+// mockSyncRepo has no changes.
+type mockSyncRepo struct{}
 
-func TestNearbyStops_CacheControlHeader(t *testing.T) {
+func (m *mockSyncRepo) StopChanges(ctx context.Context, agencyID string, after domain.SyncPosition, until time.Time, limit int) ([]domain.StopChange, error) {
+	return nil, nil
+}
+func (m *mockSyncRepo) RouteChanges(ctx context.Context, agencyID string, after domain.SyncPosition, until time.Time, limit int) ([]domain.RouteChange, error) {
+	return nil, nil
+}
+func (m *mockSyncRepo) AlertChanges(ctx context.Context, agencyID string, after domain.SyncPosition, until time.Time, limit int) ([]domain.AlertChange, error) {
+	return nil, nil
+}
+
+func TestSync(t *testing.T) {
 	deps := makeDeps(func(d *handler.Dependencies) {
-		d.Stops = usecases.NewStopService(&mockStopRepo{
-			findNearbyFn: func(ctx context.Context, lat, lon, radius float64, limit int) ([]domain.Stop, error) {
-				return []domain.Stop{}, nil
-			},
-		}, nil)
+		d.Sync = usecases.NewSyncService(&mockSyncRepo{}, &mockAgencyRepo{})
 	})
 	app := setupApp(deps)
 
-	req := httptest.NewRequest("GET", "/v1/stops/nearby?lat=43.26&lon=-2.93", nil)
-	resp, _ := app.Test(req, -1)
+	resp, _ := app.Test(httptest.NewRequest("GET", "/v1/sync/stops", nil), -1)
 	if resp.StatusCode != 200 {
 		t.Fatalf("expected 200, got %d", resp.StatusCode)
 	}
+	var page domain.StopSync
+	json.NewDecoder(resp.Body).Decode(&page)
+	if page.Cursor == "" || page.HasMore {
+		t.Errorf("expected a final page with a cursor, got %+v", page)
+	}
 
-	cc := resp.Header.Get("Cache-Control")
-	if cc != "public, max-age=300" {
-		t.Errorf("expected Cache-Control header, got %q", cc)
+	resp, _ = app.Test(httptest.NewRequest("GET", "/v1/sync/routes?cursor=%25%25", nil), -1)
+	if resp.StatusCode != 400 {
+		t.Errorf("expected 400 for a bad cursor, got %d", resp.StatusCode)
 	}
 }
 
-// ---- Agency slug handler tests ----
+// mockOccupancyRepo has one vehicle reporting occupancy and no history.
+type mockOccupancyRepo struct{}
 
-func TestGetAgency_Success(t *testing.T) {
+func (m *mockOccupancyRepo) LatestByRoute(ctx context.Context, routeID string, since time.Time) ([]domain.VehicleOccupancy, error) {
+	return []domain.VehicleOccupancy{{VehicleID: "v1", Status: 3, UpdatedAt: since}}, nil
+}
+func (m *mockOccupancyRepo) LatestByTrips(ctx context.Context, tripIDs []string, since time.Time) ([]domain.VehicleOccupancy, error) {
+	return nil, nil
+}
+func (m *mockOccupancyRepo) StopCrowding(ctx context.Context, stopUUID, routeID string, since time.Time) ([]domain.CrowdingSlot, error) {
+	return nil, nil
+}
+
+func TestOccupancy(t *testing.T) {
 	deps := makeDeps(func(d *handler.Dependencies) {
-		d.Agencies = usecases.NewAgencyService(&mockAgencyRepo{
-			getBySlugFn: func(ctx context.Context, slug string) (*domain.Agency, error) {
-				return &domain.Agency{ID: "a1", Slug: slug, Name: "Metro Bilbao"}, nil
-			},
-		})
+		routes := &mockRouteRepo{getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
+			if id != "r1" {
+				return nil, fmt.Errorf("not found")
+			}
+			return &domain.Route{ID: "r1"}, nil
+		}}
+		stops := &mockStopRepo{getByIDFn: func(ctx context.Context, id string) (*domain.Stop, error) {
+			return &domain.Stop{ID: id}, nil
+		}}
+		d.Occupancy = usecases.NewOccupancyService(&mockOccupancyRepo{}, routes, stops)
 	})
 	app := setupApp(deps)
 
-	req := httptest.NewRequest("GET", "/v1/agencies/metro_bilbao", nil)
-	resp, _ := app.Test(req, -1)
+	resp, _ := app.Test(httptest.NewRequest("GET", "/v1/routes/r1/occupancy", nil), -1)
 	if resp.StatusCode != 200 {
 		t.Fatalf("expected 200, got %d", resp.StatusCode)
 	}
+	var occ domain.RouteOccupancy
+	json.NewDecoder(resp.Body).Decode(&occ)
+	if len(occ.Vehicles) != 1 || occ.Vehicles[0].Level != "standing_room_only" {
+		t.Errorf("unexpected occupancy: %+v", occ)
+	}
 
-	var agency domain.Agency
-	json.NewDecoder(resp.Body).Decode(&agency)
-	if agency.Slug != "metro_bilbao" {
-		t.Errorf("expected slug metro_bilbao, got %s", agency.Slug)
+	resp, _ = app.Test(httptest.NewRequest("GET", "/v1/routes/nope/occupancy", nil), -1)
+	if resp.StatusCode != 404 {
+		t.Errorf("expected 404 for an unknown route, got %d", resp.StatusCode)
 	}
-}
 
-func TestGetAgency_NotFound(t *testing.T) {
-	deps := makeDeps(func(d *handler.Dependencies) {
-		d.Agencies = usecases.NewAgencyService(&mockAgencyRepo{
-			getBySlugFn: func(ctx context.Context, slug string) (*domain.Agency, error) {
-				return nil, fmt.Errorf("not found")
-			},
-		})
-	})
-	app := setupApp(deps)
+	resp, _ = app.Test(httptest.NewRequest("GET", "/v1/stops/s1/crowding", nil), -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var crowding domain.StopCrowding
+	json.NewDecoder(resp.Body).Decode(&crowding)
+	if crowding.Slots == nil {
+		t.Error("expected an empty slots array, got null")
+	}
 
-	req := httptest.NewRequest("GET", "/v1/agencies/nonexistent", nil)
-	resp, _ := app.Test(req, -1)
-	if resp.StatusCode != 404 {
-		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	resp, _ = app.Test(httptest.NewRequest("GET", "/v1/stops/s1/crowding?weeks=100", nil), -1)
+	if resp.StatusCode != 400 {
+		t.Errorf("expected 400 for too many weeks, got %d", resp.StatusCode)
 	}
 }
 
-// ---- Agency routes handler tests ----
+// mockCoverageRepo has not sampled coverage yet.
+type mockCoverageRepo struct{}
 
-func TestAgencyRoutes_Success(t *testing.T) {
+func (m *mockCoverageRepo) Sample(ctx context.Context, at, liveSince time.Time) error { return nil }
+func (m *mockCoverageRepo) Latest(ctx context.Context, agencyID string) (*domain.CoverageSample, error) {
+	return nil, nil
+}
+
+func TestRealtimeCoverage(t *testing.T) {
 	deps := makeDeps(func(d *handler.Dependencies) {
-		d.Agencies = usecases.NewAgencyService(&mockAgencyRepo{
-			getBySlugFn: func(ctx context.Context, slug string) (*domain.Agency, error) {
-				return &domain.Agency{ID: "a1", Slug: slug, Name: "Metro"}, nil
-			},
-		})
-		d.Routes = usecases.NewRouteService(&mockRouteRepo{
-			listByAgFn: func(ctx context.Context, agencyID string) ([]domain.Route, error) {
-				return []domain.Route{
-					{ID: "r1", LongName: "L1"},
-					{ID: "r2", LongName: "L2"},
-				}, nil
-			},
-		}, &mockVehicleRepo{})
+		agencies := &mockAgencyRepo{getBySlugFn: func(ctx context.Context, slug string) (*domain.Agency, error) {
+			if slug != "bilbobus" {
+				return nil, nil
+			}
+			return &domain.Agency{ID: "a1", Slug: slug}, nil
+		}}
+		d.Coverage = usecases.NewRealtimeCoverageService(&mockCoverageRepo{}, agencies, &mockRouteRepo{})
 	})
 	app := setupApp(deps)
 
-	req := httptest.NewRequest("GET", "/v1/agencies/metro/routes", nil)
-	resp, _ := app.Test(req, -1)
+	resp, _ := app.Test(httptest.NewRequest("GET", "/v1/agencies/bilbobus/realtime-coverage", nil), -1)
 	if resp.StatusCode != 200 {
 		t.Fatalf("expected 200, got %d", resp.StatusCode)
 	}
+	var coverage map[string]any
+	json.NewDecoder(resp.Body).Decode(&coverage)
+	if coverage["sampled_at"] != nil || coverage["live_data"] != false {
+		t.Errorf("expected no sample and no live data badge, got %+v", coverage)
+	}
 
-	var result struct {
-		Data       []domain.Route      `json:"data"`
-		Pagination struct{ Total int } `json:"pagination"`
+	resp, _ = app.Test(httptest.NewRequest("GET", "/v1/agencies/nope/realtime-coverage", nil), -1)
+	if resp.StatusCode != 404 {
+		t.Errorf("expected 404 for an unknown agency, got %d", resp.StatusCode)
 	}
-	json.NewDecoder(resp.Body).Decode(&result)
-	if result.Pagination.Total != 2 {
-		t.Errorf("expected 2 routes, got %d", result.Pagination.Total)
+	resp, _ = app.Test(httptest.NewRequest("GET", "/v1/routes/nope/realtime-coverage", nil), -1)
+	if resp.StatusCode != 404 {
+		t.Errorf("expected 404 for an unknown route, got %d", resp.StatusCode)
 	}
 }
 
-// ---- Stop routes handler tests ----
-
-func TestStopRoutes_Success(t *testing.T) {
+func TestAgencyFleet(t *testing.T) {
 	deps := makeDeps(func(d *handler.Dependencies) {
-		d.Routes = usecases.NewRouteService(&mockRouteRepo{
-			listByStopFn: func(ctx context.Context, stopUUID string) ([]domain.Route, error) {
-				return []domain.Route{
-					{ID: "r1", LongName: "Line 1"},
-				}, nil
-			},
-		}, &mockVehicleRepo{})
+		agencies := &mockAgencyRepo{getBySlugFn: func(ctx context.Context, slug string) (*domain.Agency, error) {
+			if slug != "bilbobus" {
+				return nil, nil
+			}
+			return &domain.Agency{ID: "a1", Slug: slug}, nil
+		}}
+		d.Fleet = usecases.NewFleetService(&mockVehicleRepo{}, agencies)
 	})
 	app := setupApp(deps)
 
-	req := httptest.NewRequest("GET", "/v1/stops/stop-uuid/routes", nil)
-	resp, _ := app.Test(req, -1)
+	resp, _ := app.Test(httptest.NewRequest("GET", "/v1/agencies/bilbobus/fleet", nil), -1)
 	if resp.StatusCode != 200 {
 		t.Fatalf("expected 200, got %d", resp.StatusCode)
 	}
+	var fleet map[string]any
+	json.NewDecoder(resp.Body).Decode(&fleet)
+	if fleet["agency"] != "bilbobus" || fleet["active"] != float64(0) {
+		t.Errorf("expected an empty bilbobus fleet, got %+v", fleet)
+	}
 
-	var routes []domain.Route
-	json.NewDecoder(resp.Body).Decode(&routes)
-	if len(routes) != 1 {
-		t.Errorf("expected 1 route, got %d", len(routes))
+	resp, _ = app.Test(httptest.NewRequest("GET", "/v1/agencies/nope/fleet", nil), -1)
+	if resp.StatusCode != 404 {
+		t.Errorf("expected 404 for an unknown agency, got %d", resp.StatusCode)
 	}
 }
 
-// ---- Trip handler tests ----
+// mockSegmentRepo has one segment observed.
+type mockSegmentRepo struct{}
 
-func TestGetTrip_Success(t *testing.T) {
+func (m *mockSegmentRepo) RollupHour(ctx context.Context, hour time.Time, radiusMeters float64) error {
+	return nil
+}
+func (m *mockSegmentRepo) RouteSegments(ctx context.Context, routeID string, since time.Time) ([]domain.RouteSegment, error) {
+	return []domain.RouteSegment{{FromStopID: "s1", ToStopID: "s2", Distance: 400, Samples: 12, TravelSecs: 72}}, nil
+}
+
+func TestRouteSegments(t *testing.T) {
 	deps := makeDeps(func(d *handler.Dependencies) {
-		d.Trips = usecases.NewTripService(&mockTripRepo{
-			getByIDFn: func(ctx context.Context, id string) (*domain.Trip, error) {
-				return &domain.Trip{ID: id, Headsign: "Etxebarri"}, nil
-			},
-		})
+		routes := &mockRouteRepo{getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
+			if id != "r1" {
+				return nil, fmt.Errorf("not found")
+			}
+			return &domain.Route{ID: "r1"}, nil
+		}}
+		d.Segments = usecases.NewSegmentService(&mockSegmentRepo{}, routes)
 	})
 	app := setupApp(deps)
 
-	req := httptest.NewRequest("GET", "/v1/trips/trip-uuid", nil)
-	resp, _ := app.Test(req, -1)
+	resp, _ := app.Test(httptest.NewRequest("GET", "/v1/routes/r1/segments", nil), -1)
 	if resp.StatusCode != 200 {
 		t.Fatalf("expected 200, got %d", resp.StatusCode)
 	}
+	var out domain.RouteSegments
+	json.NewDecoder(resp.Body).Decode(&out)
+	if len(out.Segments) != 1 || out.Segments[0].SpeedKmh != 20 || !out.Segments[0].Slowest {
+		t.Errorf("unexpected segments: %+v", out.Segments)
+	}
 
-	var trip domain.Trip
-	json.NewDecoder(resp.Body).Decode(&trip)
-	if trip.Headsign != "Etxebarri" {
-		t.Errorf("expected Etxebarri, got %s", trip.Headsign)
+	resp, _ = app.Test(httptest.NewRequest("GET", "/v1/routes/r1/segments?days=365", nil), -1)
+	if resp.StatusCode != 400 {
+		t.Errorf("expected 400 for too many days, got %d", resp.StatusCode)
+	}
+	resp, _ = app.Test(httptest.NewRequest("GET", "/v1/routes/nope/segments", nil), -1)
+	if resp.StatusCode != 404 {
+		t.Errorf("expected 404 for an unknown route, got %d", resp.StatusCode)
 	}
 }
 
-func TestGetTrip_NotFound(t *testing.T) {
-	deps := makeDeps(func(d *handler.Dependencies) {
-		d.Trips = usecases.NewTripService(&mockTripRepo{
-			getByIDFn: func(ctx context.Context, id string) (*domain.Trip, error) {
-				return nil, fmt.Errorf("not found")
-			},
-		})
-	})
-	app := setupApp(deps)
+// mockPunctualityRepo has no observations.
+type mockPunctualityRepo struct{}
 
-	req := httptest.NewRequest("GET", "/v1/trips/bad-id", nil)
-	resp, _ := app.Test(req, -1)
-	if resp.StatusCode != 404 {
-		t.Fatalf("expected 404, got %d", resp.StatusCode)
-	}
+func (m *mockPunctualityRepo) ByHour(ctx context.Context, f domain.PunctualityFilter) ([]domain.HourPunctuality, error) {
+	return nil, nil
+}
+func (m *mockPunctualityRepo) ByDay(ctx context.Context, f domain.PunctualityFilter) ([]domain.DayPunctuality, error) {
+	return nil, nil
+}
+func (m *mockPunctualityRepo) ByStop(ctx context.Context, f domain.PunctualityFilter, minObserved int) ([]domain.StopPunctuality, error) {
+	return nil, nil
+}
+func (m *mockPunctualityRepo) ByRoute(ctx context.Context, f domain.PunctualityFilter, minObserved int) ([]domain.RoutePunctualityStats, error) {
+	return nil, nil
 }
 
-func TestTripStopTimes_Success(t *testing.T) {
+func TestPunctuality(t *testing.T) {
 	deps := makeDeps(func(d *handler.Dependencies) {
-		d.Trips = usecases.NewTripService(&mockTripRepo{
-			getStopTimesFn: func(ctx context.Context, tripID string) ([]domain.StopTime, error) {
-				return []domain.StopTime{
-					{ID: "st1", TripID: tripID, StopSequence: 1},
-					{ID: "st2", TripID: tripID, StopSequence: 2},
-				}, nil
-			},
-		})
+		d.Punctuality = usecases.NewPunctualityService(&mockPunctualityRepo{}, &mockRouteRepo{}, &mockAgencyRepo{})
 	})
 	app := setupApp(deps)
 
-	req := httptest.NewRequest("GET", "/v1/trips/trip-uuid/stop-times", nil)
-	resp, _ := app.Test(req, -1)
+	resp, _ := app.Test(httptest.NewRequest("GET", "/v1/analytics/network/summary", nil), -1)
 	if resp.StatusCode != 200 {
 		t.Fatalf("expected 200, got %d", resp.StatusCode)
 	}
+	var summary domain.NetworkPunctuality
+	json.NewDecoder(resp.Body).Decode(&summary)
+	if summary.WorstRoutes == nil || summary.To.Sub(summary.From) != 30*24*time.Hour {
+		t.Errorf("expected the last 30 days with no routes, got %+v", summary)
+	}
 
-	var sts []domain.StopTime
-	json.NewDecoder(resp.Body).Decode(&sts)
-	if len(sts) != 2 {
-		t.Errorf("expected 2 stop-times, got %d", len(sts))
+	for path, want := range map[string]int{
+		"/v1/analytics/network/summary?from=yesterday":                                    400,
+		"/v1/analytics/network/summary?from=2026-03-02T00:00:00Z&to=2026-03-01T00:00:00Z": 400,
+		"/v1/analytics/network/summary?agency=nope":                                       404,
+		"/v1/analytics/routes/nope/punctuality":                                           404,
+	} {
+		resp, _ := app.Test(httptest.NewRequest("GET", path, nil), -1)
+		if resp.StatusCode != want {
+			t.Errorf("%s: expected %d, got %d", path, want, resp.StatusCode)
+		}
 	}
 }
 
-// ---- X-API-Version header ----
+// mockBrandingRepo holds no branding and no route icons.
+type mockBrandingRepo struct{}
 
-func TestAPIVersionHeader(t *testing.T) {
-	app := setupApp(makeDeps())
+func (m *mockBrandingRepo) Get(ctx context.Context, agencyID string) (*domain.AgencyBranding, error) {
+	return nil, nil
+}
+func (m *mockBrandingRepo) Save(ctx context.Context, b *domain.AgencyBranding) error { return nil }
+func (m *mockBrandingRepo) RouteIcons(ctx context.Context, agencyID string) (map[string]string, error) {
+	return nil, nil
+}
+func (m *mockBrandingRepo) SetRouteIcon(ctx context.Context, routeID, url string) error { return nil }
 
-	req := httptest.NewRequest("GET", "/v1/health", nil)
+func TestAgencyBranding(t *testing.T) {
+	agencies := &mockAgencyRepo{
+		getBySlugFn: func(ctx context.Context, slug string) (*domain.Agency, error) {
+			return &domain.Agency{ID: "a1", Slug: slug}, nil
+		},
+	}
+	routes := &mockRouteRepo{
+		listByAgFn: func(ctx context.Context, agencyID string) ([]domain.Route, error) {
+			return []domain.Route{{ID: "r1", ShortName: "L1", Color: "E30613"}}, nil
+		},
+	}
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Branding = usecases.NewBrandingService(&mockBrandingRepo{}, agencies, routes, nil)
+		d.AdminToken = "s3cret"
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/agencies/metro_bilbao/branding", nil)
 	resp, _ := app.Test(req, -1)
 	if resp.StatusCode != 200 {
 		t.Fatalf("expected 200, got %d", resp.StatusCode)
 	}
+	var b domain.AgencyBranding
+	json.NewDecoder(resp.Body).Decode(&b)
+	if len(b.Routes) != 1 || b.Routes[0].Color != "E30613" {
+		t.Errorf("expected the L1 badge from the GTFS color, got %+v", b.Routes)
+	}
 
-	v := resp.Header.Get("X-API-Version")
-	if v != "1.0.0" {
-		t.Errorf("expected X-API-Version 1.0.0, got %q", v)
+	// Without object storage, uploads are unavailable.
+	req = httptest.NewRequest("PUT", "/v1/admin/agencies/metro_bilbao/branding/logo", strings.NewReader("\x89PNG"))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	req.Header.Set("Content-Type", "image/png")
+	resp, _ = app.Test(req, -1)
+	if resp.StatusCode != 503 {
+		t.Errorf("expected 503, got %d", resp.StatusCode)
 	}
 }
 
-// ---- Link header on pagination ----
+// mockComparisonRepo has no observed trips.
+type mockComparisonRepo struct{}
 
-func TestListAgencies_LinkHeader(t *testing.T) {
-	agencies := make([]domain.Agency, 10)
-	for i := range agencies {
-		agencies[i] = domain.Agency{ID: fmt.Sprintf("a%d", i), Name: fmt.Sprintf("Agency %d", i)}
-	}
+func (m *mockComparisonRepo) Compare(ctx context.Context, agencyID string, from, to time.Time) ([]domain.TimetableComparison, error) {
+	return nil, nil
+}
 
+func TestTimetableExport(t *testing.T) {
 	deps := makeDeps(func(d *handler.Dependencies) {
-		d.Agencies = usecases.NewAgencyService(&mockAgencyRepo{
-			listFn: func(ctx context.Context) ([]domain.Agency, error) { return agencies, nil },
-		})
+		d.Exports = usecases.NewTimetableExportService(&mockComparisonRepo{}, &mockAgencyRepo{}, nil)
+		d.AdminToken = "s3cret"
 	})
 	app := setupApp(deps)
 
-	req := httptest.NewRequest("GET", "/v1/agencies?offset=0&limit=3", nil)
-	resp, _ := app.Test(req, -1)
-	if resp.StatusCode != 200 {
-		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	post := func(body string) int {
+		req := httptest.NewRequest("POST", "/v1/admin/agencies/metro_bilbao/timetable-export", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req, -1)
+		return resp.StatusCode
 	}
+	if code := post(`{"from":"2026-03-08","to":"2026-03-02"}`); code != 400 {
+		t.Errorf("expected 400 for a reversed range, got %d", code)
+	}
+	// Without object storage, exports are unavailable.
+	if code := post(`{"from":"2026-03-02","to":"2026-03-08"}`); code != 503 {
+		t.Errorf("expected 503, got %d", code)
+	}
+}
 
-	link := resp.Header.Get("Link")
-	if link == "" {
-		t.Fatal("expected Link header, got empty")
+// mockSynonymRepo already holds the Casco Viejo synonym.
+type mockSynonymRepo struct{}
+
+func (m *mockSynonymRepo) List(ctx context.Context) ([]domain.SearchSynonym, error) { return nil, nil }
+func (m *mockSynonymRepo) Create(ctx context.Context, s *domain.SearchSynonym) (bool, error) {
+	return strings.ToLower(s.Term) != "casco viejo", nil
+}
+func (m *mockSynonymRepo) Delete(ctx context.Context, id string) (bool, error) { return false, nil }
+
+type mockSearchLogRepo struct{}
+
+func (m *mockSearchLogRepo) Record(ctx context.Context, query string, results int, at time.Time) error {
+	return nil
+}
+func (m *mockSearchLogRepo) Stats(ctx context.Context, from, to time.Time, limit int) (*domain.SearchStats, error) {
+	return &domain.SearchStats{From: from, To: to}, nil
+}
+
+func TestSearchSynonyms(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Search = usecases.NewSearchService(&mockSynonymRepo{}, &mockSearchLogRepo{})
+		d.AdminToken = "s3cret"
+	})
+	app := setupApp(deps)
+
+	do := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req, -1)
+		return resp.StatusCode
 	}
-	// Should contain rel="next"
-	if !strings.Contains(link, `rel="next"`) {
-		t.Errorf("expected next link, got %s", link)
+	if code := do("POST", "/v1/admin/search/synonyms", `{"term":"Abandoo","synonym":"Abando","one_way":true}`); code != 201 {
+		t.Errorf("expected 201, got %d", code)
 	}
-	if !strings.Contains(link, `rel="first"`) {
-		t.Errorf("expected first link, got %s", link)
+	if code := do("POST", "/v1/admin/search/synonyms", `{"term":"Casco Viejo","synonym":"Zazpikaleak"}`); code != 409 {
+		t.Errorf("expected 409 for an existing synonym, got %d", code)
 	}
-	if !strings.Contains(link, `rel="last"`) {
-		t.Errorf("expected last link, got %s", link)
+	if code := do("POST", "/v1/admin/search/synonyms", `{"term":"Abando"}`); code != 400 {
+		t.Errorf("expected 400 without a synonym, got %d", code)
+	}
+	if code := do("DELETE", "/v1/admin/search/synonyms/nope", ""); code != 404 {
+		t.Errorf("expected 404, got %d", code)
+	}
+	if code := do("GET", "/v1/admin/analytics/search?from=2026-03-02T00:00:00Z&to=2026-03-01T00:00:00Z", ""); code != 400 {
+		t.Errorf("expected 400 for a reversed range, got %d", code)
 	}
 }
 
-// TestAccessLogMiddleware verifies structured access logging is emitted.
-func TestAccessLogMiddleware(t *testing.T) {
-	app := fiber.New()
+type mockWorkflowHistory struct{}
 
-	// Register middleware
-	app.Use(handler.AccessLogMiddleware())
+func (m *mockWorkflowHistory) ListRuns(ctx context.Context, f domain.WorkflowFilter) ([]domain.WorkflowRun, error) {
+	return []domain.WorkflowRun{{
+		WorkflowID: "compensation-d1-" + f.UserID, Kind: domain.WorkflowCompensation, Status: "running",
+		UserID: f.UserID, Activity: "SendPushNotification", Attempts: 3, Failure: "push: rate limited",
+	}}, nil
+}
 
-	// Simple test route
-	app.Get("/test", func(c *fiber.Ctx) error {
-		return c.Status(fiber.StatusOK).JSON(fiber.Map{"ok": true})
+func TestListWorkflows(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Workflows = usecases.NewWorkflowService(&mockWorkflowHistory{})
+		d.AdminToken = "s3cret"
 	})
+	app := setupApp(deps)
 
-	// Make request
-	req := httptest.NewRequest("GET", "/test", nil)
-	req.Header.Set("X-Request-ID", "test-req-123")
-
-	resp, err := app.Test(req)
-	if err != nil {
-		t.Fatalf("request failed: %v", err)
+	get := func(path string) *http.Response {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		resp, _ := app.Test(req, -1)
+		return resp
 	}
-
-	if resp.StatusCode != fiber.StatusOK {
-		t.Errorf("expected 200, got %d", resp.StatusCode)
+	resp := get("/v1/admin/workflows?user_id=6f1c2d3e-4a5b-4c6d-8e7f-0a1b2c3d4e5f")
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var result struct {
+		Runs []domain.WorkflowRun `json:"runs"`
+	}
+	if err := json.Unmarshal(readBody(t, resp.Body), &result); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(result.Runs) != 1 || result.Runs[0].Attempts != 3 || result.Runs[0].Failure != "push: rate limited" {
+		t.Errorf("expected the user's compensation retrying its push, got %+v", result.Runs)
+	}
+	if code := get("/v1/admin/workflows?status=stuck").StatusCode; code != 400 {
+		t.Errorf("expected 400 for an unknown status, got %d", code)
+	}
+
+	deps.Workflows = usecases.NewWorkflowService(nil)
+	app = setupApp(deps)
+	if code := get("/v1/admin/workflows").StatusCode; code != 503 {
+		t.Errorf("expected 503 without Temporal, got %d", code)
+	}
+}
+
+// mockAgencyFeedsRepo has feeds for metro_bilbao only.
+type mockAgencyFeedsRepo struct{}
+
+func (m *mockAgencyFeedsRepo) List(ctx context.Context) ([]domain.AgencyFeeds, error) {
+	return []domain.AgencyFeeds{{Slug: "metro_bilbao", Name: "Metro Bilbao", GTFSURL: "https://example.com/metro.zip"}}, nil
+}
+func (m *mockAgencyFeedsRepo) GetBySlug(ctx context.Context, slug string) (*domain.AgencyFeeds, error) {
+	return nil, nil
+}
+func (m *mockAgencyFeedsRepo) Create(ctx context.Context, f *domain.AgencyFeeds) (bool, error) {
+	return f.Slug != "metro_bilbao", nil
+}
+func (m *mockAgencyFeedsRepo) Update(ctx context.Context, f *domain.AgencyFeeds) (bool, error) {
+	return f.Slug == "metro_bilbao", nil
+}
+func (m *mockAgencyFeedsRepo) Delete(ctx context.Context, slug string) (bool, error) {
+	return slug == "metro_bilbao", nil
+}
+
+func TestAgencyFeeds(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.AgencyFeeds = usecases.NewAgencyFeedsService(&mockAgencyFeedsRepo{})
+		d.AdminToken = "s3cret"
+	})
+	app := setupApp(deps)
+
+	do := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req, -1)
+		return resp.StatusCode
+	}
+	const bizkaibus = `{"slug":"bizkaibus","name":"Bizkaibus","gtfs_url":"https://example.com/bizkaibus.zip",` +
+		`"gtfs_rt":{"vehicle_positions":"https://example.com/vp.pb","poll_interval":15}}`
+	if code := do("POST", "/v1/admin/agencies", bizkaibus); code != 201 {
+		t.Errorf("expected 201, got %d", code)
+	}
+	if code := do("POST", "/v1/admin/agencies", `{"slug":"metro_bilbao","name":"Metro Bilbao","gtfs_url":"https://example.com/metro.zip"}`); code != 409 {
+		t.Errorf("expected 409 for an agency with feeds, got %d", code)
+	}
+	if code := do("POST", "/v1/admin/agencies", `{"slug":"Bizkaibus","name":"Bizkaibus","gtfs_url":"https://example.com/bizkaibus.zip"}`); code != 400 {
+		t.Errorf("expected 400 for an invalid slug, got %d", code)
+	}
+	if code := do("PUT", "/v1/admin/agencies/metro_bilbao", `{"name":"Metro Bilbao","gtfs_url":"ftp://example.com/metro.zip"}`); code != 400 {
+		t.Errorf("expected 400 for a non-http gtfs_url, got %d", code)
+	}
+	if code := do("PUT", "/v1/admin/agencies/metro_bilbao", `{"name":"Metro Bilbao","gtfs_url":"https://example.com/metro.zip"}`); code != 200 {
+		t.Errorf("expected 200, got %d", code)
+	}
+	if code := do("PUT", "/v1/admin/agencies/bizkaibus", `{"name":"Bizkaibus","gtfs_url":"https://example.com/bizkaibus.zip"}`); code != 404 {
+		t.Errorf("expected 404 for an agency without feeds, got %d", code)
+	}
+	if code := do("DELETE", "/v1/admin/agencies/bizkaibus", ""); code != 404 {
+		t.Errorf("expected 404, got %d", code)
+	}
+	if code := do("DELETE", "/v1/admin/agencies/metro_bilbao", ""); code != 204 {
+		t.Errorf("expected 204, got %d", code)
+	}
+
+	req := httptest.NewRequest("GET", "/v1/admin/agencies", nil)
+	if resp, _ := app.Test(req, -1); resp.StatusCode != 401 {
+		t.Errorf("expected 401 without the admin token, got %d", resp.StatusCode)
+	}
+}
+
+func TestGeocode(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Geocode = usecases.NewGeocodeService(nil, nil, 3600)
+	})
+	app := setupApp(deps)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/v1/geocode", nil), -1)
+	if resp.StatusCode != 400 {
+		t.Errorf("expected 400 without q, got %d", resp.StatusCode)
+	}
+	resp, _ = app.Test(httptest.NewRequest("GET", "/v1/geocode?q=Gran+V%C3%ADa+1", nil), -1)
+	if resp.StatusCode != 503 {
+		t.Errorf("expected 503 without a geocoder, got %d", resp.StatusCode)
+	}
+}
+
+// mockFeedStageRepo holds one stage; Rollback finds no kept feed.
+type mockFeedStageRepo struct {
+	stage domain.FeedStage
+}
+
+func (m *mockFeedStageRepo) List(ctx context.Context, agencyID string) ([]domain.FeedStage, error) {
+	return []domain.FeedStage{m.stage}, nil
+}
+func (m *mockFeedStageRepo) Decide(ctx context.Context, agencyID, id, status string) (*domain.FeedStage, error) {
+	if id != m.stage.ID {
+		return nil, nil
+	}
+	if m.stage.Status == "staged" {
+		m.stage.Status = status
+	}
+	out := m.stage
+	return &out, nil
+}
+func (m *mockFeedStageRepo) Rollback(ctx context.Context, agencyID string) (*domain.FeedStage, error) {
+	return nil, nil
+}
+
+func TestFeedStages(t *testing.T) {
+	agencies := &mockAgencyRepo{getBySlugFn: func(ctx context.Context, slug string) (*domain.Agency, error) {
+		return &domain.Agency{ID: "a1", Slug: slug}, nil
+	}}
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.FeedStages = usecases.NewFeedStageService(&mockFeedStageRepo{
+			stage: domain.FeedStage{ID: "s1", AgencyID: "a1", Status: "staged"},
+		}, agencies)
+		d.AdminToken = "s3cret"
+	})
+	app := setupApp(deps)
+
+	post := func(path string) *http.Response {
+		req := httptest.NewRequest("POST", path, nil)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		resp, _ := app.Test(req, -1)
+		return resp
+	}
+	resp := post("/v1/admin/agencies/metro_bilbao/feed-stages/s1/approve")
+	var stage domain.FeedStage
+	json.NewDecoder(resp.Body).Decode(&stage)
+	if resp.StatusCode != 200 || stage.Status != "approved" {
+		t.Fatalf("expected s1 approved, got %d, %+v", resp.StatusCode, stage)
+	}
+	for path, want := range map[string]int{
+		"/v1/admin/agencies/metro_bilbao/feed-stages/s1/reject": 409,
+		"/v1/admin/agencies/metro_bilbao/feed-stages/s2/reject": 404,
+		"/v1/admin/agencies/metro_bilbao/feed-stages/rollback":  409,
+	} {
+		if resp := post(path); resp.StatusCode != want {
+			t.Errorf("%s: expected %d, got %d", path, want, resp.StatusCode)
+		}
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/v1/admin/agencies/metro_bilbao/feed-stages", nil), -1)
+	if resp.StatusCode != 401 {
+		t.Errorf("expected 401 without the admin token, got %d", resp.StatusCode)
+	}
+}
+
+// ---- Route handler tests ----
+
+func TestListRoutes_AllAgencies(t *testing.T) {
+	routes := &mockRouteRepo{}
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Routes = usecases.NewRouteService(routes, &mockVehicleRepo{}, nil)
+	})
+	app := setupApp(deps)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/v1/routes?route_type=metro&q=etxebarri", nil), -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if routes.lastFilter.AgencyID != "" || routes.lastFilter.Query != "etxebarri" || len(routes.lastFilter.RouteTypes.Include) == 0 {
+		t.Errorf("expected every agency's metro routes matching etxebarri, got %+v", routes.lastFilter)
+	}
+}
+
+func TestGetRoute_Success(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Routes = usecases.NewRouteService(&mockRouteRepo{
+			getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
+				return &domain.Route{ID: id, LongName: "L1 Etxebarri-Ibarbengoa"}, nil
+			},
+		}, &mockVehicleRepo{}, nil)
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/routes/route-uuid", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	var route domain.Route
+	json.NewDecoder(resp.Body).Decode(&route)
+	if route.LongName != "L1 Etxebarri-Ibarbengoa" {
+		t.Errorf("unexpected route name: %s", route.LongName)
+	}
+}
+
+func TestGetRoute_NotFound(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Routes = usecases.NewRouteService(&mockRouteRepo{
+			getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
+				return nil, fmt.Errorf("not found")
+			},
+		}, &mockVehicleRepo{}, nil)
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/routes/bad-id", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 404 {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestRouteBadge(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Routes = usecases.NewRouteService(&mockRouteRepo{
+			getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
+				return &domain.Route{ID: id, ShortName: "L1", Color: "E30613", TextColor: "FFFFFF"}, nil
+			},
+		}, &mockVehicleRepo{}, nil)
+	})
+	app := setupApp(deps)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/v1/routes/route-uuid/badge.svg?height=48", nil), -1)
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "image/svg+xml" {
+		t.Fatalf("expected an SVG, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	resp, _ = app.Test(httptest.NewRequest("GET", "/v1/routes/route-uuid/badge.svg?height=1000", nil), -1)
+	if resp.StatusCode != 400 {
+		t.Fatalf("expected 400 for an oversized badge, got %d", resp.StatusCode)
+	}
+}
+
+func TestListRoutes_AsOfMissingAgencyID(t *testing.T) {
+	app := setupApp(makeDeps())
+
+	req := httptest.NewRequest("GET", "/v1/routes?as_of=2026-01-01", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 400 {
+		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestListRoutes_Success(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Routes = usecases.NewRouteService(&mockRouteRepo{
+			listByAgFn: func(ctx context.Context, agencyID string) ([]domain.Route, error) {
+				return []domain.Route{
+					{ID: "r1", LongName: "Line 1"},
+					{ID: "r2", LongName: "Line 2"},
+				}, nil
+			},
+		}, &mockVehicleRepo{}, nil)
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/routes?agency_id=abc", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Data       []domain.Route `json:"data"`
+		Pagination struct {
+			Total int `json:"total"`
+		} `json:"pagination"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if result.Pagination.Total != 2 {
+		t.Errorf("expected 2 routes total, got %d", result.Pagination.Total)
+	}
+}
+
+func TestListRoutes_RouteTypes(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Routes = usecases.NewRouteService(&mockRouteRepo{
+			listByAgFn: func(ctx context.Context, agencyID string) ([]domain.Route, error) {
+				return []domain.Route{
+					{ID: "r1", LongName: "Line 1", RouteType: 1},
+					{ID: "r2", LongName: "Artxanda", RouteType: 7},
+					{ID: "r3", LongName: "A3", RouteType: 3},
+				}, nil
+			},
+		}, &mockVehicleRepo{}, nil)
+	})
+	app := setupApp(deps)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/v1/routes?agency_id=abc&route_type=funicular,metro&exclude_route_type=1", nil), -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var result struct {
+		Data []domain.Route `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if len(result.Data) != 1 || result.Data[0].ID != "r2" {
+		t.Errorf("expected only the funicular, got %+v", result.Data)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/v1/routes?agency_id=abc&route_type=zeppelin", nil), -1)
+	if resp.StatusCode != 400 {
+		t.Errorf("expected 400 for an unknown mode, got %d", resp.StatusCode)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/v1/route-types", nil), -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var modes []domain.RouteMode
+	json.NewDecoder(resp.Body).Decode(&modes)
+	if len(modes) == 0 || modes[0].Mode != "tram" || modes[0].Label == "" {
+		t.Errorf("unexpected route types: %+v", modes)
+	}
+}
+
+// ---- Vehicles handler tests ----
+
+func TestGetRouteVehicles_Success(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Routes = usecases.NewRouteService(&mockRouteRepo{}, &mockVehicleRepo{
+			latestByRouteFn: func(ctx context.Context, routeID string) ([]domain.VehiclePosition, error) {
+				return []domain.VehiclePosition{
+					{VehicleID: "v1", Location: domain.GeoPoint{Lat: 43.26, Lon: -2.93}},
+				}, nil
+			},
+		}, nil)
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/routes/some-route/vehicles", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	var vehicles []domain.VehiclePosition
+	json.NewDecoder(resp.Body).Decode(&vehicles)
+	if len(vehicles) != 1 {
+		t.Errorf("expected 1 vehicle, got %d", len(vehicles))
+	}
+}
+
+// streetGeocoder puts every point on Gran Vía.
+type streetGeocoder struct{ lang string }
+
+func (g *streetGeocoder) Geocode(ctx context.Context, q domain.GeocodeQuery) ([]domain.Place, error) {
+	return nil, nil
+}
+
+func (g *streetGeocoder) Reverse(ctx context.Context, p domain.GeoPoint, lang string) (*domain.Place, error) {
+	g.lang = lang
+	return &domain.Place{Name: "Gran Vía", Location: p, Street: "Gran Vía"}, nil
+}
+
+func TestGetRouteVehicles_Streets(t *testing.T) {
+	geocoder := &streetGeocoder{}
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Geocode = usecases.NewGeocodeService(geocoder, nil, 3600)
+		d.Routes = usecases.NewRouteService(&mockRouteRepo{}, &mockVehicleRepo{
+			latestByRouteFn: func(ctx context.Context, routeID string) ([]domain.VehiclePosition, error) {
+				return []domain.VehiclePosition{
+					{VehicleID: "v1", Location: domain.GeoPoint{Lat: 43.26, Lon: -2.93}},
+				}, nil
+			},
+		}, nil)
+	})
+	app := setupApp(deps)
+
+	var vehicles []domain.VehiclePosition
+	resp, _ := app.Test(httptest.NewRequest("GET", "/v1/routes/some-route/vehicles", nil), -1)
+	json.NewDecoder(resp.Body).Decode(&vehicles)
+	if len(vehicles) != 1 || vehicles[0].Place != nil {
+		t.Fatalf("expected a vehicle without a place unless asked, got %+v", vehicles)
+	}
+
+	req := httptest.NewRequest("GET", "/v1/routes/some-route/vehicles?streets=true", nil)
+	req.Header.Set("Accept-Language", "eu-ES,es;q=0.8")
+	resp, _ = app.Test(req, -1)
+	json.NewDecoder(resp.Body).Decode(&vehicles)
+	if len(vehicles) != 1 || vehicles[0].Place == nil || vehicles[0].Place.Street != "Gran Vía" {
+		t.Fatalf("expected the vehicle on Gran Vía, got %+v", vehicles)
+	}
+	if geocoder.lang != "eu" {
+		t.Errorf("expected names in Basque, got %q", geocoder.lang)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	var vehicles []domain.VehiclePosition
+	for i := range 40 {
+		vehicles = append(vehicles, domain.VehiclePosition{VehicleID: fmt.Sprintf("v%d", i), Location: domain.GeoPoint{Lat: 43.26, Lon: -2.93}})
+	}
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Routes = usecases.NewRouteService(&mockRouteRepo{}, &mockVehicleRepo{
+			latestByRouteFn: func(ctx context.Context, routeID string) ([]domain.VehiclePosition, error) {
+				return vehicles, nil
+			},
+		}, nil)
+	})
+	app := setupApp(deps)
+
+	tests := []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{"gzip, deflate, br", "br"},
+		{"gzip", "gzip"},
+		{"br;q=0, gzip;q=0.5", "gzip"},
+		{"*", "br"},
+		{"identity", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/v1/routes/some-route/vehicles", nil)
+		req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		resp, _ := app.Test(req, -1)
+		if got := resp.Header.Get("Content-Encoding"); got != tt.want {
+			t.Errorf("Accept-Encoding %q: expected %q, got %q", tt.acceptEncoding, tt.want, got)
+		}
+		if !strings.Contains(resp.Header.Get("Vary"), "Accept-Encoding") {
+			t.Errorf("Accept-Encoding %q: expected Vary: Accept-Encoding, got %q", tt.acceptEncoding, resp.Header.Get("Vary"))
+		}
+		if tt.want == "gzip" {
+			zr, err := gzip.NewReader(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []domain.VehiclePosition
+			if err := json.NewDecoder(zr).Decode(&got); err != nil || len(got) != 40 {
+				t.Errorf("expected 40 vehicles after gunzipping, got %d, %v", len(got), err)
+			}
+		}
+	}
+
+	// Below the minimum size, responses are sent as they are.
+	vehicles = vehicles[:1]
+	req := httptest.NewRequest("GET", "/v1/routes/some-route/vehicles", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, _ := app.Test(req, -1)
+	if got := resp.Header.Get("Content-Encoding"); got != "" {
+		t.Errorf("expected a small response uncompressed, got %q", got)
+	}
+}
+
+// ---- Departure handler tests ----
+
+func TestStopDepartures_Success(t *testing.T) {
+	now := time.Now()
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Departures = usecases.NewDepartureService(&mockTripRepo{
+			nextDepFn: func(ctx context.Context, stopUUID string, limit int) ([]domain.Departure, error) {
+				return []domain.Departure{
+					{ScheduledTime: now, Platform: "1"},
+				}, nil
+			},
+		}, nil, nil, nil)
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/stops/stop-uuid/departures", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestStopDepartures_Filters(t *testing.T) {
+	trips := &mockTripRepo{}
+	app := setupApp(makeDeps(func(d *handler.Dependencies) {
+		d.Departures = usecases.NewDepartureService(trips, nil, nil, nil)
+	}))
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/v1/stops/s1/departures?route_id=r1&direction_id=1&date=2026-03-02&after=23:30&until=00:30", nil), -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	f := trips.filter
+	if f.RouteID != "r1" || f.DirectionID == nil || *f.DirectionID != 1 {
+		t.Errorf("expected route r1 direction 1, got %+v", f)
+	}
+	if f.After == nil || f.After.Format("2006-01-02 15:04") != "2026-03-02 23:30" {
+		t.Errorf("expected after 2026-03-02 23:30, got %v", f.After)
+	}
+	if f.Until == nil || f.Until.Format("2006-01-02 15:04") != "2026-03-03 00:30" {
+		t.Errorf("expected until past midnight, 2026-03-03 00:30, got %v", f.Until)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/v1/stops/s1/departures?date=2026-03-02", nil), -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if trips.filter.After == nil || trips.filter.After.Format("15:04") != "00:00" || trips.filter.Until != nil {
+		t.Errorf("expected the whole of another date, got %+v", trips.filter)
+	}
+
+	for _, q := range []string{"direction_id=2", "date=02/03/2026", "after=7pm", "after=2026-03-02T09:00:00Z&until=2026-03-02T08:00:00Z"} {
+		resp, _ = app.Test(httptest.NewRequest("GET", "/v1/stops/s1/departures?"+q, nil), -1)
+		if resp.StatusCode != 400 {
+			t.Errorf("%s: expected 400, got %d", q, resp.StatusCode)
+		}
+	}
+}
+
+func TestNearbyDepartures(t *testing.T) {
+	app := setupApp(makeDeps())
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/v1/departures/nearby?lat=43.26&lon=-2.93", nil), -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if body := string(readBody(t, resp.Body)); body != "[]" {
+		t.Errorf("expected an empty list, got %s", body)
+	}
+
+	resp, _ = app.Test(httptest.NewRequest("GET", "/v1/departures/nearby?lat=43.26", nil), -1)
+	if resp.StatusCode != 400 {
+		t.Errorf("expected 400 without lon, got %d", resp.StatusCode)
+	}
+	resp, _ = app.Test(httptest.NewRequest("GET", "/v1/departures/nearby?lat=43.26&lon=-2.93&radius=5000", nil), -1)
+	if resp.StatusCode != 400 {
+		t.Errorf("expected 400 for a radius out of range, got %d", resp.StatusCode)
+	}
+}
+
+// ---- Health handler tests ----
+
+func TestHealth_Returns200(t *testing.T) {
+	app := setupApp(makeDeps())
+
+	req := httptest.NewRequest("GET", "/v1/health", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if result["status"] != "healthy" {
+		t.Errorf("expected healthy status, got %v", result["status"])
+	}
+}
+
+func TestReady_NoDB(t *testing.T) {
+	deps := makeDeps()
+	// DB, NATS, Cache are nil → should report not ready
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/ready", nil)
+	resp, _ := app.Test(req, -1)
+	// With nil DB, ready should return 503
+	if resp.StatusCode != 503 {
+		t.Fatalf("expected 503, got %d", resp.StatusCode)
+	}
+}
+
+// ---- Nearby stops Cache-Control header ----
+
+func TestNearbyStops_CacheControlHeader(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Stops = usecases.NewStopService(&mockStopRepo{
+			findNearbyFn: func(ctx context.Context, lat, lon, radius float64, limit int) ([]domain.Stop, error) {
+				return []domain.Stop{}, nil
+			},
+		}, nil)
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/stops/nearby?lat=43.26&lon=-2.93", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	cc := resp.Header.Get("Cache-Control")
+	if cc != "public, max-age=300" {
+		t.Errorf("expected Cache-Control header, got %q", cc)
+	}
+}
+
+// ---- Agency slug handler tests ----
+
+func TestGetAgency_Success(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Agencies = usecases.NewAgencyService(&mockAgencyRepo{
+			getBySlugFn: func(ctx context.Context, slug string) (*domain.Agency, error) {
+				return &domain.Agency{ID: "a1", Slug: slug, Name: "Metro Bilbao"}, nil
+			},
+		})
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/agencies/metro_bilbao", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	var agency domain.Agency
+	json.NewDecoder(resp.Body).Decode(&agency)
+	if agency.Slug != "metro_bilbao" {
+		t.Errorf("expected slug metro_bilbao, got %s", agency.Slug)
+	}
+}
+
+func TestGetAgency_NotFound(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Agencies = usecases.NewAgencyService(&mockAgencyRepo{
+			getBySlugFn: func(ctx context.Context, slug string) (*domain.Agency, error) {
+				return nil, fmt.Errorf("not found")
+			},
+		})
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/agencies/nonexistent", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 404 {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+}
+
+// ---- Agency routes handler tests ----
+
+func TestAgencyRoutes_Success(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Agencies = usecases.NewAgencyService(&mockAgencyRepo{
+			getBySlugFn: func(ctx context.Context, slug string) (*domain.Agency, error) {
+				return &domain.Agency{ID: "a1", Slug: slug, Name: "Metro"}, nil
+			},
+		})
+		d.Routes = usecases.NewRouteService(&mockRouteRepo{
+			listByAgFn: func(ctx context.Context, agencyID string) ([]domain.Route, error) {
+				return []domain.Route{
+					{ID: "r1", LongName: "L1"},
+					{ID: "r2", LongName: "L2"},
+				}, nil
+			},
+		}, &mockVehicleRepo{}, nil)
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/agencies/metro/routes", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Data       []domain.Route      `json:"data"`
+		Pagination struct{ Total int } `json:"pagination"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if result.Pagination.Total != 2 {
+		t.Errorf("expected 2 routes, got %d", result.Pagination.Total)
+	}
+}
+
+// ---- Stop routes handler tests ----
+
+func TestStopRoutes_Success(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Routes = usecases.NewRouteService(&mockRouteRepo{
+			listByStopFn: func(ctx context.Context, stopUUID string) ([]domain.Route, error) {
+				return []domain.Route{
+					{ID: "r1", LongName: "Line 1"},
+				}, nil
+			},
+		}, &mockVehicleRepo{}, nil)
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/stops/stop-uuid/routes", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	var routes []domain.Route
+	json.NewDecoder(resp.Body).Decode(&routes)
+	if len(routes) != 1 {
+		t.Errorf("expected 1 route, got %d", len(routes))
+	}
+}
+
+// ---- Trip handler tests ----
+
+func TestGetTrip_Success(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Trips = usecases.NewTripService(&mockTripRepo{
+			getByIDFn: func(ctx context.Context, id string) (*domain.Trip, error) {
+				return &domain.Trip{ID: id, Headsign: "Etxebarri"}, nil
+			},
+		})
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/trips/trip-uuid", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	var trip domain.Trip
+	json.NewDecoder(resp.Body).Decode(&trip)
+	if trip.Headsign != "Etxebarri" {
+		t.Errorf("expected Etxebarri, got %s", trip.Headsign)
+	}
+}
+
+func TestGetTrip_ContinuesAs(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Trips = usecases.NewTripService(&mockTripRepo{
+			getByIDFn: func(ctx context.Context, id string) (*domain.Trip, error) {
+				return &domain.Trip{ID: id, Headsign: "Leioa", BlockID: "B12", ContinuesAs: &domain.TripContinuation{
+					ID: "trip-next", RouteID: "route-2", RouteShortName: "A3411", RouteLongName: "Leioa-Getxo", Headsign: "Getxo",
+				}}, nil
+			},
+		})
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/trips/trip-uuid", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	var trip domain.Trip
+	json.NewDecoder(resp.Body).Decode(&trip)
+	if trip.BlockID != "B12" {
+		t.Errorf("expected block B12, got %q", trip.BlockID)
+	}
+	if trip.ContinuesAs == nil || trip.ContinuesAs.RouteShortName != "A3411" || trip.ContinuesAs.Headsign != "Getxo" {
+		t.Errorf("expected to continue as A3411 towards Getxo, got %+v", trip.ContinuesAs)
+	}
+}
+
+func TestGetTrip_NotFound(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Trips = usecases.NewTripService(&mockTripRepo{
+			getByIDFn: func(ctx context.Context, id string) (*domain.Trip, error) {
+				return nil, fmt.Errorf("not found")
+			},
+		})
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/trips/bad-id", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 404 {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestTripStopTimes_Success(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Trips = usecases.NewTripService(&mockTripRepo{
+			getStopTimesFn: func(ctx context.Context, tripID string) ([]domain.StopTime, error) {
+				return []domain.StopTime{
+					{ID: "st1", TripID: tripID, StopSequence: 1},
+					{ID: "st2", TripID: tripID, StopSequence: 2},
+				}, nil
+			},
+		})
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/trips/trip-uuid/stop-times", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	var sts []domain.StopTime
+	json.NewDecoder(resp.Body).Decode(&sts)
+	if len(sts) != 2 {
+		t.Errorf("expected 2 stop-times, got %d", len(sts))
+	}
+}
+
+// ---- X-API-Version header ----
+
+func TestAPIVersionHeader(t *testing.T) {
+	app := setupApp(makeDeps())
+
+	req := httptest.NewRequest("GET", "/v1/health", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	v := resp.Header.Get("X-API-Version")
+	if v != "1.0.0" {
+		t.Errorf("expected X-API-Version 1.0.0, got %q", v)
+	}
+}
+
+// ---- Link header on pagination ----
+
+func TestListAgencies_LinkHeader(t *testing.T) {
+	agencies := make([]domain.Agency, 10)
+	for i := range agencies {
+		agencies[i] = domain.Agency{ID: fmt.Sprintf("a%d", i), Name: fmt.Sprintf("Agency %d", i)}
+	}
+
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Agencies = usecases.NewAgencyService(&mockAgencyRepo{
+			listFn: func(ctx context.Context) ([]domain.Agency, error) { return agencies, nil },
+		})
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/agencies?offset=0&limit=3", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	link := resp.Header.Get("Link")
+	if link == "" {
+		t.Fatal("expected Link header, got empty")
+	}
+	// Should contain rel="next"
+	if !strings.Contains(link, `rel="next"`) {
+		t.Errorf("expected next link, got %s", link)
+	}
+	if !strings.Contains(link, `rel="first"`) {
+		t.Errorf("expected first link, got %s", link)
+	}
+	if !strings.Contains(link, `rel="last"`) {
+		t.Errorf("expected last link, got %s", link)
+	}
+}
+
+func TestListRoutes_LinkHeaderKeepsFilters(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Routes = usecases.NewRouteService(&mockRouteRepo{
+			listByAgFn: func(ctx context.Context, agencyID string) ([]domain.Route, error) {
+				return []domain.Route{{ID: "r1", RouteType: 1}, {ID: "r2", RouteType: 1}}, nil
+			},
+		}, &mockVehicleRepo{}, nil)
+	})
+	app := setupApp(deps)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/v1/routes?route_type=metro&limit=1", nil), -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	link := resp.Header.Get("Link")
+	if !strings.Contains(link, `</v1/routes?limit=1&offset=1&route_type=metro>; rel="next"`) {
+		t.Errorf("expected the next link to keep route_type, got %s", link)
+	}
+}
+
+// TestAccessLogMiddleware verifies structured access logging is emitted.
+func TestAccessLogMiddleware(t *testing.T) {
+	app := fiber.New()
+
+	// Register middleware
+	app.Use(handler.AccessLogMiddleware())
+
+	// Simple test route
+	app.Get("/test", func(c *fiber.Ctx) error {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"ok": true})
+	})
+
+	// Make request
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("X-Request-ID", "test-req-123")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("expected 200, got %d", resp.StatusCode)
+	}
+
+	// Verify response body
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "ok") {
+		t.Errorf("expected response body to contain 'ok', got %s", string(body))
+	}
+}
+
+// ---- Route shape ----
+
+func TestRouteShape_GeoJSON(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Routes = usecases.NewRouteService(&mockRouteRepo{
+			getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
+				return &domain.Route{ID: id, ShortName: "L1", Color: "FF0000", TextColor: "FFFFFF"}, nil
+			},
+			getShapeFn: func(ctx context.Context, id string) (*domain.GeoLineString, error) {
+				return &domain.GeoLineString{Coordinates: []domain.GeoPoint{
+					{Lat: 43.26, Lon: -2.93}, {Lat: 43.27, Lon: -2.94},
+				}}, nil
+			},
+		}, &mockVehicleRepo{}, nil)
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/routes/route-1/shape", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/geo+json") {
+		t.Errorf("expected application/geo+json, got %q", ct)
+	}
+
+	var fc struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type        string      `json:"type"`
+				Coordinates [][]float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]any `json:"properties"`
+		} `json:"features"`
+	}
+	json.NewDecoder(resp.Body).Decode(&fc)
+	if fc.Type != "FeatureCollection" || len(fc.Features) != 1 {
+		t.Fatalf("unexpected collection: %+v", fc)
+	}
+	f := fc.Features[0]
+	if f.Geometry.Type != "LineString" || len(f.Geometry.Coordinates) != 2 {
+		t.Fatalf("unexpected geometry: %+v", f.Geometry)
+	}
+	if f.Geometry.Coordinates[0][0] != -2.93 {
+		t.Errorf("expected [lon, lat] order, got %v", f.Geometry.Coordinates[0])
+	}
+	if f.Properties["color"] != "#FF0000" {
+		t.Errorf("expected color #FF0000, got %v", f.Properties["color"])
+	}
+}
+
+func TestRouteShape_DefaultColors(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Routes = usecases.NewRouteService(&mockRouteRepo{
+			getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
+				return &domain.Route{ID: id, ShortName: "L1"}, nil
+			},
+			getShapeFn: func(ctx context.Context, id string) (*domain.GeoLineString, error) {
+				return &domain.GeoLineString{Coordinates: []domain.GeoPoint{
+					{Lat: 43.26, Lon: -2.93}, {Lat: 43.27, Lon: -2.94},
+				}}, nil
+			},
+		}, &mockVehicleRepo{}, nil)
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/routes/route-1/shape", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var fc struct {
+		Features []struct {
+			Properties map[string]any `json:"properties"`
+		} `json:"features"`
+	}
+	json.NewDecoder(resp.Body).Decode(&fc)
+	if len(fc.Features) != 1 {
+		t.Fatalf("unexpected collection: %+v", fc)
+	}
+	p := fc.Features[0].Properties
+	if p["color"] != "#FFFFFF" || p["text_color"] != "#000000" {
+		t.Errorf("expected GTFS default colors, got %v and %v", p["color"], p["text_color"])
+	}
+}
+
+func TestRouteShape_NoShape(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Routes = usecases.NewRouteService(&mockRouteRepo{
+			getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
+				return &domain.Route{ID: id}, nil
+			},
+		}, &mockVehicleRepo{}, nil)
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/routes/route-1/shape", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 404 {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+}
+
+// ---- Vehicle history ----
+
+func TestVehicleHistory_Track(t *testing.T) {
+	var gotAgency, gotVehicle string
+	var gotFrom, gotTo time.Time
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Routes = usecases.NewRouteService(&mockRouteRepo{}, &mockVehicleRepo{
+			historyFn: func(ctx context.Context, agency, vehicleID string, from, to time.Time, bucket time.Duration) ([]domain.VehiclePosition, error) {
+				gotAgency, gotVehicle, gotFrom, gotTo = agency, vehicleID, from, to
+				return []domain.VehiclePosition{
+					{VehicleID: vehicleID, TripID: "t1", Time: from, Speed: 8, Location: domain.GeoPoint{Lat: 43.26, Lon: -2.93}},
+					{VehicleID: vehicleID, TripID: "t1", Time: from.Add(30 * time.Second), Speed: 9, Location: domain.GeoPoint{Lat: 43.27, Lon: -2.94}},
+				}, nil
+			},
+		}, nil)
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/vehicles/bus-42/history?agency=bizkaibus&from=2026-03-14T08:00:00Z&to=2026-03-14T09:00:00Z", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/geo+json") {
+		t.Errorf("expected application/geo+json, got %q", ct)
+	}
+	if gotAgency != "bizkaibus" || gotVehicle != "bus-42" || gotTo.Sub(gotFrom) != time.Hour {
+		t.Errorf("unexpected query: %q %q %s-%s", gotAgency, gotVehicle, gotFrom, gotTo)
+	}
+
+	var fc struct {
+		Features []struct {
+			Geometry struct {
+				Type        string      `json:"type"`
+				Coordinates [][]float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties struct {
+				Times         []time.Time `json:"times"`
+				Speeds        []float64   `json:"speeds"`
+				BucketSeconds int         `json:"bucket_seconds"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+	json.NewDecoder(resp.Body).Decode(&fc)
+	if len(fc.Features) != 1 {
+		t.Fatalf("expected one track, got %+v", fc)
+	}
+	f := fc.Features[0]
+	if f.Geometry.Type != "LineString" || len(f.Geometry.Coordinates) != 2 || f.Geometry.Coordinates[1][0] != -2.94 {
+		t.Errorf("unexpected geometry: %+v", f.Geometry)
+	}
+	if len(f.Properties.Times) != 2 || f.Properties.Speeds[1] != 9 || f.Properties.BucketSeconds != 2 {
+		t.Errorf("unexpected properties: %+v", f.Properties)
+	}
+}
+
+func TestVehicleHistory_Validation(t *testing.T) {
+	app := setupApp(makeDeps())
+
+	tests := []struct {
+		query string
+		code  int
+	}{
+		{"?from=yesterday", 400},
+		{"?from=2026-03-14T09:00:00Z&to=2026-03-14T08:00:00Z", 400},
+		{"?from=2026-03-01T00:00:00Z&to=2026-03-14T00:00:00Z", 400},
+		{"", 404}, // no positions
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/v1/vehicles/bus-42/history"+tt.query, nil)
+		resp, _ := app.Test(req, -1)
+		if resp.StatusCode != tt.code {
+			t.Errorf("%q: expected %d, got %d", tt.query, tt.code, resp.StatusCode)
+		}
+	}
+}
+
+// ---- Stop short links ----
+
+func TestShortLinkRedirect_Success(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.ShortLinks = usecases.NewShortLinkService(&mockShortLinkRepo{
+			getByCodeFn: func(ctx context.Context, code string) (*domain.StopShortLink, error) {
+				return &domain.StopShortLink{Code: code, StopID: "stop-uuid"}, nil
+			},
+		}, "https://bilbopass.eus", "/v1/stops/{stop_id}/departures")
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/s/abc234", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 302 {
+		t.Fatalf("expected 302, got %d", resp.StatusCode)
 	}
+	if loc := resp.Header.Get("Location"); loc != "/v1/stops/stop-uuid/departures" {
+		t.Errorf("unexpected Location %q", loc)
+	}
+}
 
-	// Verify response body
-	body, _ := io.ReadAll(resp.Body)
-	if !strings.Contains(string(body), "ok") {
-		t.Errorf("expected response body to contain 'ok', got %s", string(body))
+func TestShortLinkRedirect_NotFound(t *testing.T) {
+	app := setupApp(makeDeps())
+
+	req := httptest.NewRequest("GET", "/s/nope99", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 404 {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestAdminQRSheet_RequiresToken(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.AdminToken = "s3cret"
+		d.Agencies = usecases.NewAgencyService(&mockAgencyRepo{
+			getBySlugFn: func(ctx context.Context, slug string) (*domain.Agency, error) {
+				return &domain.Agency{ID: "a1", Slug: slug, Name: "Metro Bilbao"}, nil
+			},
+		})
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/admin/agencies/metro_bilbao/qr-sheet", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 401 {
+		t.Fatalf("expected 401, got %d", resp.StatusCode)
+	}
+
+	req = httptest.NewRequest("GET", "/v1/admin/agencies/metro_bilbao/qr-sheet?format=csv", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	resp, _ = app.Test(req, -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("expected text/csv, got %q", ct)
+	}
+}
+
+// ---- Rider history tests ----
+
+type mockHistoryRepo struct {
+	hasConsentFn func(ctx context.Context, userID string) (bool, error)
+}
+
+func (m *mockHistoryRepo) SetConsent(ctx context.Context, userID string, granted bool) error {
+	return nil
+}
+
+func (m *mockHistoryRepo) HasConsent(ctx context.Context, userID string) (bool, error) {
+	if m.hasConsentFn != nil {
+		return m.hasConsentFn(ctx, userID)
+	}
+	return false, nil
+}
+
+func (m *mockHistoryRepo) Insert(ctx context.Context, j *domain.RiderJourney) error { return nil }
+
+func (m *mockHistoryRepo) ListByUser(ctx context.Context, userID string, from, to time.Time) ([]domain.RiderJourney, error) {
+	return nil, nil
+}
+
+func (m *mockHistoryRepo) DeleteByUser(ctx context.Context, userID string) error { return nil }
+
+func (m *mockHistoryRepo) RidersOnTrip(ctx context.Context, tripID string, from, to time.Time) ([]string, error) {
+	return nil, nil
+}
+
+func TestHistory_RequiresUser(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.History = usecases.NewHistoryService(&mockHistoryRepo{}, &mockStopRepo{}, &mockRouteRepo{})
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("GET", "/v1/me/history", nil)
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 401 {
+		t.Fatalf("expected 401, got %d", resp.StatusCode)
+	}
+
+	req = httptest.NewRequest("GET", "/v1/me/history?months=3", nil)
+	req.Header.Set("X-User-ID", "u1")
+	resp, _ = app.Test(req, -1)
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var body map[string]any
+	json.Unmarshal(readBody(t, resp.Body), &body)
+	if s, ok := body["summaries"].([]any); !ok || len(s) != 3 {
+		t.Errorf("expected 3 summaries, got %v", body["summaries"])
+	}
+}
+
+func TestHistory_RecordWithoutConsent(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.History = usecases.NewHistoryService(&mockHistoryRepo{}, &mockStopRepo{}, &mockRouteRepo{})
+	})
+	app := setupApp(deps)
+
+	req := httptest.NewRequest("POST", "/v1/me/history", strings.NewReader(`{"departed_at":"2026-03-02T08:00:00Z"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-ID", "u1")
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 403 {
+		t.Fatalf("expected 403, got %d", resp.StatusCode)
+	}
+}
+
+// mockPreferenceRepo keeps the preferences saved, by user.
+type mockPreferenceRepo struct {
+	saved map[string]domain.NotificationPreferences
+}
+
+func (m *mockPreferenceRepo) Get(ctx context.Context, userID string) (*domain.NotificationPreferences, error) {
+	if p, ok := m.saved[userID]; ok {
+		return &p, nil
+	}
+	return nil, nil
+}
+func (m *mockPreferenceRepo) Save(ctx context.Context, userID string, p *domain.NotificationPreferences) error {
+	m.saved[userID] = *p
+	return nil
+}
+
+func TestPreferences(t *testing.T) {
+	repo := &mockPreferenceRepo{saved: map[string]domain.NotificationPreferences{}}
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Preferences = usecases.NewNotificationPreferenceService(repo)
+	})
+	app := setupApp(deps)
+
+	do := func(method, body string) (int, domain.NotificationPreferences) {
+		req := httptest.NewRequest(method, "/v1/me/preferences", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User-ID", "u1")
+		resp, _ := app.Test(req, -1)
+		var prefs domain.NotificationPreferences
+		json.Unmarshal(readBody(t, resp.Body), &prefs)
+		return resp.StatusCode, prefs
+	}
+
+	code, prefs := do("GET", "")
+	if code != 200 || !prefs.Categories.Delays || prefs.Categories.Marketing || !prefs.Channels.WebPush {
+		t.Fatalf("expected the defaults, got %d %+v", code, prefs)
+	}
+
+	// Fields left out keep their value
+	code, prefs = do("PUT", `{"categories":{"marketing":true},"quiet_hours":{"from":"22:00","until":"7:00"}}`)
+	if code != 200 {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if !prefs.Categories.Marketing || !prefs.Categories.Coupons || !prefs.Channels.FCM {
+		t.Errorf("expected marketing on and the rest kept, got %+v", prefs)
+	}
+	if q := prefs.QuietHours; q == nil || q.Until != "07:00" || q.Timezone != "Europe/Madrid" {
+		t.Errorf("expected quiet hours until 07:00 in Europe/Madrid, got %+v", q)
+	}
+
+	code, prefs = do("PUT", `{"quiet_hours":null}`)
+	if code != 200 || prefs.QuietHours != nil || !prefs.Categories.Marketing {
+		t.Errorf("expected quiet hours off, got %d %+v", code, prefs)
+	}
+	if code, _ := do("PUT", `{"quiet_hours":{"from":"22:00","until":"22:00"}}`); code != 400 {
+		t.Errorf("expected 400 for empty quiet hours, got %d", code)
+	}
+	if code, _ := do("PUT", `{"quiet_hours":{"from":"22:00","until":"07:00","timezone":"Mars/Olympus"}}`); code != 400 {
+		t.Errorf("expected 400 for an unknown time zone, got %d", code)
+	}
+}
+
+// ---- Map tile tests ----
+
+type mockTileRepo struct{}
+
+func (m *mockTileRepo) MVT(ctx context.Context, z, x, y int, withStops bool) ([]byte, error) {
+	return []byte{}, nil
+}
+
+func (m *mockTileRepo) StopsInTile(ctx context.Context, z, x, y int) ([]domain.Stop, error) {
+	return nil, nil
+}
+
+func (m *mockTileRepo) RouteShapesInTile(ctx context.Context, z, x, y int) ([]domain.Route, error) {
+	return nil, nil
+}
+
+func TestTiles(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Tiles = usecases.NewTileService(&mockTileRepo{}, nil)
+	})
+	app := setupApp(deps)
+
+	tests := []struct {
+		path   string
+		status int
+		ctype  string
+	}{
+		{"/v1/tiles/14/8020/6050.mvt", 200, "application/vnd.mapbox-vector-tile"},
+		{"/v1/tiles/14/8020/6050.geojson", 200, "application/geo+json"},
+		{"/v1/tiles/14/8020/6050.png", 400, ""},
+		{"/v1/tiles/2/9/0.mvt", 400, ""},
+	}
+	for _, tt := range tests {
+		resp, _ := app.Test(httptest.NewRequest("GET", tt.path, nil), -1)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.status, resp.StatusCode)
+		}
+		if tt.ctype != "" && resp.Header.Get("Content-Type") != tt.ctype {
+			t.Errorf("%s: expected %s, got %q", tt.path, tt.ctype, resp.Header.Get("Content-Type"))
+		}
+	}
+}
+
+// ---- Service alerts ----
+
+type mockAlertRepo struct {
+	alerts []domain.ServiceAlert
+}
+
+func (m *mockAlertRepo) Upsert(ctx context.Context, a *domain.ServiceAlert) error { return nil }
+func (m *mockAlertRepo) ListActive(ctx context.Context, agencyID string, at time.Time) ([]domain.ServiceAlert, error) {
+	return m.alerts, nil
+}
+func (m *mockAlertRepo) ListActiveByRoute(ctx context.Context, routeID string, at time.Time) ([]domain.ServiceAlert, error) {
+	return m.alerts, nil
+}
+func (m *mockAlertRepo) ListActiveByStop(ctx context.Context, stopID string, at time.Time) ([]domain.ServiceAlert, error) {
+	return nil, nil
+}
+func (m *mockAlertRepo) RemoveMissing(ctx context.Context, agencyID, source string, live []string) error {
+	return nil
+}
+
+func TestAlerts(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Alerts = usecases.NewAlertService(&mockAlertRepo{alerts: []domain.ServiceAlert{
+			{SourceID: "works-1", Header: map[string]string{"es": "Obras en Abando", "eu": "Obrak Abandon"}},
+		}}, &mockAgencyRepo{})
+	})
+	app := setupApp(deps)
+
+	tests := []struct {
+		path   string
+		status int
+		count  int
+	}{
+		{"/v1/alerts", 200, 1},
+		{"/v1/routes/route-uuid/alerts", 200, 1},
+		{"/v1/stops/stop-uuid/alerts", 200, 0},
+		{"/v1/alerts?agency=unknown", 404, 0},
+	}
+	for _, tt := range tests {
+		resp, _ := app.Test(httptest.NewRequest("GET", tt.path, nil), -1)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.status, resp.StatusCode)
+			continue
+		}
+		if tt.status != 200 {
+			continue
+		}
+		var body struct {
+			Data []domain.ServiceAlert `json:"data"`
+		}
+		if err := json.Unmarshal(readBody(t, resp.Body), &body); err != nil {
+			t.Fatalf("%s: decode: %v", tt.path, err)
+		}
+		if len(body.Data) != tt.count {
+			t.Errorf("%s: expected %d alerts, got %d", tt.path, tt.count, len(body.Data))
+		}
+	}
+}
+
+// ---- Auth tests ----
+
+type mockUserRepo struct {
+	users map[string]*domain.User
+}
+
+func (m *mockUserRepo) Create(ctx context.Context, u *domain.User) (bool, error) {
+	if _, ok := m.users[u.Email]; ok {
+		return false, nil
+	}
+	u.ID = fmt.Sprintf("user-%d", len(m.users)+1)
+	m.users[u.Email] = u
+	return true, nil
+}
+
+func (m *mockUserRepo) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	return m.users[email], nil
+}
+
+func (m *mockUserRepo) GetByID(ctx context.Context, id string) (*domain.User, error) {
+	for _, u := range m.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func TestAuth_RegisterLoginAndMe(t *testing.T) {
+	tokens := auth.NewJWT("test-secret", time.Hour)
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Auth = usecases.NewAuthService(&mockUserRepo{users: map[string]*domain.User{}}, tokens)
+		d.Tokens = tokens
+	})
+	app := setupApp(deps)
+
+	do := func(method, path, body, bearer string) (int, map[string]any) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		resp, _ := app.Test(req, -1)
+		var out map[string]any
+		json.Unmarshal(readBody(t, resp.Body), &out)
+		return resp.StatusCode, out
+	}
+
+	creds := `{"email":"ane@example.eus","password":"correct horse"}`
+	status, reg := do("POST", "/v1/auth/register", creds, "")
+	if status != 201 || reg["token"] == "" {
+		t.Fatalf("register: expected 201 with token, got %d %v", status, reg)
+	}
+	if status, _ := do("POST", "/v1/auth/register", creds, ""); status != 409 {
+		t.Errorf("duplicate register: expected 409, got %d", status)
+	}
+	if status, _ := do("POST", "/v1/auth/register", `{"email":"x","password":"correct horse"}`, ""); status != 400 {
+		t.Errorf("invalid email: expected 400, got %d", status)
+	}
+	if status, _ := do("POST", "/v1/auth/login", `{"email":"ane@example.eus","password":"nope"}`, ""); status != 401 {
+		t.Errorf("bad login: expected 401, got %d", status)
+	}
+	status, login := do("POST", "/v1/auth/login", creds, "")
+	if status != 200 {
+		t.Fatalf("login: expected 200, got %d", status)
+	}
+
+	token, _ := login["token"].(string)
+	status, me := do("GET", "/v1/me", "", token)
+	if status != 200 || me["email"] != "ane@example.eus" || me["password_hash"] != nil {
+		t.Errorf("me: expected account without hash, got %d %v", status, me)
+	}
+	if status, _ := do("GET", "/v1/me", "", ""); status != 401 {
+		t.Errorf("me without token: expected 401, got %d", status)
+	}
+	if status, _ := do("GET", "/v1/me", "", token+"x"); status != 401 {
+		t.Errorf("me with tampered token: expected 401, got %d", status)
+	}
+
+	// With tokens configured, the X-User-ID header no longer identifies a user.
+	req := httptest.NewRequest("GET", "/v1/me/history", nil)
+	req.Header.Set("X-User-ID", "u1")
+	if resp, _ := app.Test(req, -1); resp.StatusCode != 401 {
+		t.Errorf("X-User-ID with auth configured: expected 401, got %d", resp.StatusCode)
+	}
+}
+
+// ---- Isochrones ----
+
+type mockJourneyRepo struct {
+	reachable []domain.ReachableStop
+}
+
+func (m *mockJourneyRepo) FindJourneys(ctx context.Context, fromStopID, toStopID string, departAfter time.Time, maxTransfers int, limit int, filter domain.JourneyFilter) ([]domain.Journey, error) {
+	return nil, nil
+}
+func (m *mockJourneyRepo) FindJourneysBetween(ctx context.Context, origins, destinations []domain.StopAccess, departAfter time.Time, maxTransfers int, limit int, filter domain.JourneyFilter) ([]domain.Journey, error) {
+	return nil, nil
+}
+func (m *mockJourneyRepo) FindNightLines(ctx context.Context, fromStopID, toStopID string, departAfter time.Time, window time.Duration, radiusMeters float64, limit int) ([]domain.NightLine, error) {
+	return nil, nil
+}
+func (m *mockJourneyRepo) FindReachable(ctx context.Context, origins []domain.StopAccess, departAfter time.Time, within time.Duration, maxTransfers int, filter domain.JourneyFilter) ([]domain.ReachableStop, error) {
+	return m.reachable, nil
+}
+
+func TestIsochrone(t *testing.T) {
+	now := time.Now()
+	stops := &mockStopRepo{
+		getByIDFn: func(ctx context.Context, id string) (*domain.Stop, error) {
+			if id == "s1" {
+				return &domain.Stop{ID: "s1", Location: domain.GeoPoint{Lat: 43.26, Lon: -2.93}}, nil
+			}
+			return nil, nil
+		},
+	}
+	journeys := &mockJourneyRepo{reachable: []domain.ReachableStop{
+		{Stop: &domain.Stop{ID: "s1", Name: "Abando", Location: domain.GeoPoint{Lat: 43.26, Lon: -2.93}}, ArrivalTime: now},
+		{Stop: &domain.Stop{ID: "s2", Name: "Sarriko", Location: domain.GeoPoint{Lat: 43.27, Lon: -2.95}}, ArrivalTime: now.Add(9 * time.Minute)},
+		{Stop: &domain.Stop{ID: "s3", Name: "Bolueta", Location: domain.GeoPoint{Lat: 43.25, Lon: -2.90}}, ArrivalTime: now.Add(14 * time.Minute)},
+	}}
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Journeys = usecases.NewJourneyService(journeys, stops, nil, nil, nil, domain.TaxiTariffs{})
+	})
+	app := setupApp(deps)
+
+	resp, _ := app.Test(httptest.NewRequest("GET", "/v1/isochrones?from=s1&minutes=20", nil), -1)
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "application/geo+json" {
+		t.Fatalf("expected GeoJSON, got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	var fc struct {
+		Features []struct {
+			ID       string `json:"id"`
+			Geometry struct {
+				Type string `json:"type"`
+			} `json:"geometry"`
+			Properties map[string]any `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(readBody(t, resp.Body), &fc); err != nil {
+		t.Fatal(err)
+	}
+	if len(fc.Features) != 4 || fc.Features[0].Geometry.Type != "Polygon" {
+		t.Fatalf("expected the hull and three stops, got %+v", fc.Features)
+	}
+	if f := fc.Features[2]; f.ID != "s2" || f.Properties["minutes"] != float64(9) {
+		t.Errorf("expected Sarriko 9 minutes away, got %+v", f)
+	}
+
+	for path, status := range map[string]int{
+		"/v1/isochrones":                        400,
+		"/v1/isochrones?from=43.26,north":       400,
+		"/v1/isochrones?from=s1&minutes=500":    400,
+		"/v1/isochrones?from=nope&minutes=20":   404,
+		"/v1/isochrones?from=43.26,-2.93":       200,
+		"/v1/isochrones?from=s1&route_type=bad": 400,
+	} {
+		resp, _ := app.Test(httptest.NewRequest("GET", path, nil), -1)
+		if resp.StatusCode != status {
+			t.Errorf("%s: expected %d, got %d", path, status, resp.StatusCode)
+		}
+	}
+}
+
+func TestGraphQL_Nested(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Stops = usecases.NewStopService(&mockStopRepo{
+			getByIDFn: func(ctx context.Context, id string) (*domain.Stop, error) {
+				if id != "s1" {
+					return nil, nil
+				}
+				return &domain.Stop{ID: "s1", Name: "Abando"}, nil
+			},
+		}, nil)
+		d.Routes = usecases.NewRouteService(&mockRouteRepo{
+			listByStopFn: func(ctx context.Context, stopUUID string) ([]domain.Route, error) {
+				return []domain.Route{{ID: "r1", ShortName: "L1", RouteType: 1}, {ID: "r2", ShortName: "A1", RouteType: 3}}, nil
+			},
+		}, &mockVehicleRepo{
+			latestByRouteFn: func(ctx context.Context, routeID string) ([]domain.VehiclePosition, error) {
+				return []domain.VehiclePosition{{VehicleID: "v-" + routeID, RouteID: routeID}}, nil
+			},
+		}, nil)
+		d.Alerts = usecases.NewAlertService(&mockAlertRepo{alerts: []domain.ServiceAlert{
+			{SourceID: "works-1", Header: map[string]string{"es": "Obras en Abando", "eu": "Obrak Abandon"}},
+		}}, &mockAgencyRepo{})
+	})
+	app := setupApp(deps)
+
+	post := func(query string) *http.Response {
+		body, _ := json.Marshal(map[string]string{"query": query})
+		req := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := post(`{ stop(id: "s1") { name routes(route_type: "metro") { short_name vehicles { vehicle_id } } }
+		alerts { header(lang: "eu") }
+		missing: stop(id: "nope") { name } }`)
+	var result struct {
+		Data struct {
+			Stop struct {
+				Name   string `json:"name"`
+				Routes []struct {
+					ShortName string `json:"short_name"`
+					Vehicles  []struct {
+						VehicleID string `json:"vehicle_id"`
+					} `json:"vehicles"`
+				} `json:"routes"`
+			} `json:"stop"`
+			Alerts []struct {
+				Header string `json:"header"`
+			} `json:"alerts"`
+			Missing *struct{} `json:"missing"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(readBody(t, resp.Body), &result); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(result.Errors) > 0 {
+		t.Fatalf("unexpected errors: %+v", result.Errors)
+	}
+	routes := result.Data.Stop.Routes
+	if result.Data.Stop.Name != "Abando" || len(routes) != 1 || routes[0].ShortName != "L1" {
+		t.Fatalf("expected Abando served by L1 only, got %+v", result.Data.Stop)
+	}
+	if len(routes[0].Vehicles) != 1 || routes[0].Vehicles[0].VehicleID != "v-r1" {
+		t.Errorf("expected the vehicles of r1, got %+v", routes[0].Vehicles)
+	}
+	if len(result.Data.Alerts) != 1 || result.Data.Alerts[0].Header != "Obrak Abandon" {
+		t.Errorf("expected the Basque header, got %+v", result.Data.Alerts)
+	}
+	if result.Data.Missing != nil {
+		t.Errorf("expected null for an unknown stop, got %+v", result.Data.Missing)
+	}
+
+	resp = post(`query { ...deep } fragment deep on Query { trip(id: "t1") { stop_times { stop { departures { trip { stop_times { stop { routes { vehicles { route { shape { lat } } } } } } } } } } } }`)
+	if resp.StatusCode != 400 {
+		t.Errorf("expected 400 for a query nested too deep, got %d", resp.StatusCode)
+	}
+}
+
+func TestGraphQL_CostLimits(t *testing.T) {
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.GraphQL = handler.GraphQLLimits{MaxDepth: 10, MaxCost: 100, Budget: 150, BudgetWindow: time.Minute}
+	})
+	app := setupApp(deps)
+
+	type gqlError struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	}
+	post := func(query string) (*http.Response, []gqlError, map[string]any) {
+		body, _ := json.Marshal(map[string]string{"query": query})
+		req := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result struct {
+			Errors     []gqlError     `json:"errors"`
+			Extensions map[string]any `json:"extensions"`
+		}
+		if err := json.Unmarshal(readBody(t, resp.Body), &result); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp, result.Errors, result.Extensions
+	}
+
+	// 1 for the agencies and 1 per route of the 40 agencies assumed.
+	resp, errs, ext := post(`{ agencies { slug routes { short_name } } }`)
+	if resp.StatusCode != 200 || len(errs) > 0 {
+		t.Fatalf("expected 200 without errors, got %d %+v", resp.StatusCode, errs)
+	}
+	cost, _ := ext["cost"].(map[string]any)
+	if cost["requested"] != float64(41) {
+		t.Errorf("expected a cost of 41 in extensions, got %+v", ext)
+	}
+	if budget, _ := cost["budget"].(map[string]any); budget["remaining"] != float64(109) {
+		t.Errorf("expected 109 left of the budget, got %+v", cost["budget"])
+	}
+
+	resp, errs, _ = post(`{ agencies { routes { vehicles { vehicle_id } } } }`)
+	if resp.StatusCode != 400 || len(errs) != 1 || errs[0].Extensions["code"] != "QUERY_TOO_COSTLY" {
+		t.Errorf("expected 400 QUERY_TOO_COSTLY, got %d %+v", resp.StatusCode, errs)
+	}
+
+	post(`{ agencies { slug routes { short_name } } }`)
+	post(`{ agencies { slug routes { short_name } } }`)
+	resp, errs, _ = post(`{ agencies { slug routes { short_name } } }`)
+	if resp.StatusCode != 429 || len(errs) != 1 || errs[0].Extensions["code"] != "COST_BUDGET_EXCEEDED" {
+		t.Errorf("expected 429 COST_BUDGET_EXCEEDED, got %d %+v", resp.StatusCode, errs)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("expected Retry-After once the budget is spent")
+	}
+}
+
+func TestGraphQL_SubscriptionNeedsWebSocket(t *testing.T) {
+	app := setupApp(makeDeps())
+
+	body, _ := json.Marshal(map[string]string{"query": `subscription { vehiclePositions(agency: "bizkaibus") { vehicle_id } }`})
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req, -1)
+	if resp.StatusCode != 400 {
+		t.Errorf("expected 400 for a subscription over POST, got %d", resp.StatusCode)
+	}
+
+	req = httptest.NewRequest("GET", "/graphql", nil)
+	resp, _ = app.Test(req, -1)
+	if resp.StatusCode != 426 {
+		t.Errorf("expected 426 without a WebSocket upgrade, got %d", resp.StatusCode)
+	}
+}
+
+func TestGraphQL_BatchesNestedLookups(t *testing.T) {
+	stopBatches := 0
+	routes := &mockRouteRepo{getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
+		if id == "r3" {
+			return nil, nil
+		}
+		return &domain.Route{ID: id, ShortName: strings.ToUpper(id)}, nil
+	}}
+	vehicles := &mockVehicleRepo{latestByRouteFn: func(ctx context.Context, routeID string) ([]domain.VehiclePosition, error) {
+		return []domain.VehiclePosition{{VehicleID: "v-" + routeID, RouteID: routeID}}, nil
+	}}
+	deps := makeDeps(func(d *handler.Dependencies) {
+		d.Stops = usecases.NewStopService(&mockStopRepo{
+			getByIDsFn: func(ctx context.Context, ids []string) ([]domain.Stop, error) {
+				stopBatches++
+				var stops []domain.Stop
+				for _, id := range ids {
+					stops = append(stops, domain.Stop{ID: id, Name: "Stop " + id})
+				}
+				return stops, nil
+			},
+		}, nil)
+		d.Routes = usecases.NewRouteService(routes, vehicles, nil)
+		d.Alerts = usecases.NewAlertService(&mockAlertRepo{alerts: []domain.ServiceAlert{
+			{SourceID: "a1", RouteIDs: []string{"r1", "r2"}, StopIDs: []string{"s1"}},
+			{SourceID: "a2", RouteIDs: []string{"r2", "r3"}, StopIDs: []string{"s1", "s2"}},
+		}}, &mockAgencyRepo{})
+	})
+	app := setupApp(deps)
+
+	body, _ := json.Marshal(map[string]string{"query": `{ alerts { routes { short_name vehicles { vehicle_id } } stops { name } } }`})
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct {
+		Data struct {
+			Alerts []struct {
+				Routes []struct {
+					ShortName string `json:"short_name"`
+					Vehicles  []struct {
+						VehicleID string `json:"vehicle_id"`
+					} `json:"vehicles"`
+				} `json:"routes"`
+				Stops []struct {
+					Name string `json:"name"`
+				} `json:"stops"`
+			} `json:"alerts"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(readBody(t, resp.Body), &result); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(result.Errors) > 0 || len(result.Data.Alerts) != 2 {
+		t.Fatalf("expected two alerts, got %+v", result)
+	}
+	a2 := result.Data.Alerts[1]
+	if len(a2.Routes) != 1 || a2.Routes[0].ShortName != "R2" || len(a2.Stops) != 2 {
+		t.Errorf("expected R2 only, unknown r3 left out, and two stops, got %+v", a2)
+	}
+	if len(a2.Routes) == 1 && (len(a2.Routes[0].Vehicles) != 1 || a2.Routes[0].Vehicles[0].VehicleID != "v-r2") {
+		t.Errorf("expected the vehicles of r2, got %+v", a2.Routes[0].Vehicles)
+	}
+	if routes.batches != 1 || vehicles.batches != 1 || stopBatches != 1 {
+		t.Errorf("expected one batch each of routes, vehicles and stops, got %d, %d and %d", routes.batches, vehicles.batches, stopBatches)
+	}
+}
+
+func TestStream_Validation(t *testing.T) {
+	app := setupApp(makeDeps())
+
+	for url, want := range map[string]int{
+		"/v1/stream?channel=trips":           400,
+		"/v1/stream?agency=bizkaibus.%3E":    400,
+		"/v1/stream?channel=alerts&agency=x": 503, // no NATS in tests
+		"/v1/stream":                         503,
+	} {
+		resp, _ := app.Test(httptest.NewRequest("GET", url, nil), -1)
+		if resp.StatusCode != want {
+			t.Errorf("%s: expected %d, got %d", url, want, resp.StatusCode)
+		}
 	}
 }
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// GetHistoryHandler returns the rider's journeys for one month plus monthly summaries.
+// GET /v1/me/history?month=YYYY-MM&months=6
+func GetHistoryHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := currentUserID(c)
+		now := time.Now()
+
+		month := now
+		if m := c.Query("month"); m != "" {
+			parsed, err := time.ParseInLocation("2006-01", m, now.Location())
+			if err != nil {
+				return errBadRequest(c, "month must be YYYY-MM")
+			}
+			month = parsed
+		}
+		months := c.QueryInt("months", 6)
+		if months < 1 || months > 24 {
+			return errBadRequest(c, "months must be between 1 and 24")
+		}
+
+		consent, err := deps.History.HasConsent(c.Context(), userID)
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		journeys, err := deps.History.ListMonth(c.Context(), userID, month)
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		summaries, err := deps.History.MonthlySummaries(c.Context(), userID, months, now)
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		if journeys == nil {
+			journeys = []domain.RiderJourney{}
+		}
+
+		return c.JSON(fiber.Map{
+			"consent":   consent,
+			"month":     month.Format("2006-01"),
+			"journeys":  journeys,
+			"summaries": summaries,
+		})
+	}
+}
+
+// RecordJourneyHandler logs a journey the rider took.
+// POST /v1/me/history
+func RecordJourneyHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var j domain.RiderJourney
+		if err := c.BodyParser(&j); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		if j.DepartedAt.IsZero() {
+			return errBadRequest(c, "departed_at is required")
+		}
+		if j.Source != "" && j.Source != "planned" && j.Source != "checkin" {
+			return errBadRequest(c, "source must be planned or checkin")
+		}
+		j.ID = ""
+		j.UserID = currentUserID(c)
+
+		if err := deps.History.RecordJourney(c.Context(), &j); err != nil {
+			if errors.Is(err, usecases.ErrHistoryConsentRequired) {
+				return errForbidden(c, "trip history is disabled; grant consent first")
+			}
+			return errInternal(c, err.Error())
+		}
+		return c.Status(fiber.StatusCreated).JSON(j)
+	}
+}
+
+// SetHistoryConsentHandler grants (PUT) or revokes (DELETE) trip history logging.
+// Revoking erases the rider's stored history.
+// PUT|DELETE /v1/me/history/consent
+func SetHistoryConsentHandler(deps *Dependencies, granted bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := deps.History.SetConsent(c.Context(), currentUserID(c), granted); err != nil {
+			return errInternal(c, err.Error())
+		}
+		return c.JSON(fiber.Map{"consent": granted})
+	}
+}
@@ -0,0 +1,109 @@
+package http
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+	"github.com/samirrijal/bilbopass/internal/pkg/geospatial"
+)
+
+// IsochroneHandler returns where transit and walking reach within a number
+// of minutes from a stop or a point, as a GeoJSON FeatureCollection: the
+// concave hull around the stops reached, when there is one, then a point per
+// stop with the minutes and changes it takes.
+// GET /v1/isochrones?from=<stop_uuid>&minutes=30
+// GET /v1/isochrones?from=43.26,-2.93&minutes=45&depart_at=08:00&max_transfers=2
+func IsochroneHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		raw := c.Query("from")
+		if raw == "" {
+			return errBadRequest(c, "from (stop UUID or lat,lon) is required")
+		}
+		var fromStopID string
+		var from *domain.GeoPoint
+		if lat, lon, ok := strings.Cut(raw, ","); ok {
+			p, err := parseLatLon(lat, lon)
+			if err != nil {
+				return errBadRequest(c, err.Error())
+			}
+			from = &p
+		} else {
+			fromStopID = raw
+		}
+		routeTypes, err := parseRouteTypes(c)
+		if err != nil {
+			return errBadRequest(c, err.Error())
+		}
+		filter := domain.JourneyFilter{
+			RouteTypes: routeTypes,
+			Wheelchair: c.QueryBool("wheelchair"),
+			Bikes:      c.QueryBool("bikes"),
+		}
+
+		iso, err := deps.Journeys.Isochrone(c.Context(), fromStopID, from, parseDepartAt(c.Query("depart_at")),
+			c.QueryInt("minutes", 30), c.QueryInt("max_transfers", 1), filter)
+		switch {
+		case errors.Is(err, usecases.ErrStopNotFound):
+			return errNotFound(c, err.Error())
+		case errors.Is(err, usecases.ErrInvalidIsochrone):
+			return errBadRequest(c, err.Error())
+		case err != nil:
+			return errInternal(c, err.Error())
+		}
+		return c.JSON(isochroneFeatures(iso), "application/geo+json")
+	}
+}
+
+// parseLatLon parses a point given as latitude and longitude.
+func parseLatLon(lat, lon string) (domain.GeoPoint, error) {
+	var p domain.GeoPoint
+	var err1, err2 error
+	p.Lat, err1 = strconv.ParseFloat(strings.TrimSpace(lat), 64)
+	p.Lon, err2 = strconv.ParseFloat(strings.TrimSpace(lon), 64)
+	if err1 != nil || err2 != nil {
+		return p, errors.New("from must be a stop UUID or lat,lon")
+	}
+	if math.Abs(p.Lat) > 90 || math.Abs(p.Lon) > 180 {
+		return p, errors.New("coordinates out of range")
+	}
+	return p, nil
+}
+
+func isochroneFeatures(iso *domain.Isochrone) geospatial.FeatureCollection {
+	var features []geospatial.Feature
+	if iso.Hull != nil {
+		features = append(features, geospatial.Feature{
+			Type:     "Feature",
+			ID:       "hull",
+			Geometry: geospatial.PolygonGeometry(iso.Hull),
+			Properties: map[string]any{
+				"minutes":   iso.Minutes,
+				"depart_at": iso.DepartAt.Format(time.RFC3339),
+				"origin":    iso.Origin,
+			},
+		})
+	}
+	for _, r := range iso.Stops {
+		features = append(features, geospatial.Feature{
+			Type:     "Feature",
+			ID:       r.Stop.ID,
+			Geometry: geospatial.PointGeometry(r.Stop.Location),
+			Properties: map[string]any{
+				"stop_id":      r.Stop.StopID,
+				"agency_id":    r.Stop.AgencyID,
+				"name":         r.Stop.Name,
+				"arrival_time": r.ArrivalTime.Format(time.RFC3339),
+				"minutes":      r.Minutes,
+				"transfers":    r.Transfers,
+			},
+		})
+	}
+	return geospatial.NewFeatureCollection(features...)
+}
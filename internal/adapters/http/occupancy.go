@@ -0,0 +1,80 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// RouteOccupancyHandler returns how full the route's live vehicles are, as
+// last reported by the agency's GTFS-RT feed.
+// GET /v1/routes/:id/occupancy
+func RouteOccupancyHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		occ, err := deps.Occupancy.Route(c.Context(), c.Params("id"), time.Now())
+		switch {
+		case errors.Is(err, usecases.ErrRouteNotFound):
+			return errNotFound(c, err.Error())
+		case err != nil:
+			return errInternal(c, err.Error())
+		}
+		c.Set("Cache-Control", "public, max-age=30")
+		return c.JSON(occ)
+	}
+}
+
+// StopCrowdingHandler returns how crowded vehicles calling at a stop usually
+// are, by weekday and hour, optionally for one route.
+// GET /v1/stops/:id/crowding?route_id=&weeks=
+func StopCrowdingHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		crowding, err := deps.Occupancy.StopCrowding(c.Context(), c.Params("id"), c.Query("route_id"), c.QueryInt("weeks", 0), time.Now())
+		switch {
+		case errors.Is(err, usecases.ErrStopNotFound):
+			return errNotFound(c, err.Error())
+		case errors.Is(err, usecases.ErrInvalidCrowdingWeeks):
+			return errBadRequest(c, err.Error())
+		case err != nil:
+			return errInternal(c, err.Error())
+		}
+		c.Set("Cache-Control", "public, max-age=3600")
+		return c.JSON(crowding)
+	}
+}
+
+// AgencyCoverageHandler returns what share of the agency's running trips
+// have realtime data, overall and per route, coverage gaps first.
+// GET /v1/agencies/:slug/realtime-coverage
+func AgencyCoverageHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		coverage, err := deps.Coverage.Agency(c.Context(), c.Params("slug"), time.Now())
+		switch {
+		case errors.Is(err, usecases.ErrAgencyNotFound):
+			return errNotFound(c, err.Error())
+		case err != nil:
+			return errInternal(c, err.Error())
+		}
+		c.Set("Cache-Control", "public, max-age=60")
+		return c.JSON(coverage)
+	}
+}
+
+// RouteCoverageHandler returns what share of the route's running trips have
+// realtime data, for a "live data" badge.
+// GET /v1/routes/:id/realtime-coverage
+func RouteCoverageHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		coverage, err := deps.Coverage.Route(c.Context(), c.Params("id"), time.Now())
+		switch {
+		case errors.Is(err, usecases.ErrRouteNotFound):
+			return errNotFound(c, err.Error())
+		case err != nil:
+			return errInternal(c, err.Error())
+		}
+		c.Set("Cache-Control", "public, max-age=60")
+		return c.JSON(coverage)
+	}
+}
@@ -0,0 +1,60 @@
+package http
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// OfflineBundleHandler returns an agency's stops inside a bbox, its routes
+// and the next 24 hours of departures from those stops, for the PWA to keep
+// for dead zones. With ?since set to the version the client holds, stops
+// and routes are left out unless they changed.
+// GET /v1/offline/bundle?agency=&bbox=min_lon,min_lat,max_lon,max_lat&since=
+func OfflineBundleHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		agency := c.Query("agency")
+		if agency == "" {
+			return errBadRequest(c, "agency query parameter is required")
+		}
+		bounds, err := parseBBox(c.Query("bbox"))
+		if err != nil {
+			return errBadRequest(c, err.Error())
+		}
+
+		bundle, err := deps.Offline.Bundle(c.Context(), agency, bounds, c.Query("since"), time.Now())
+		switch {
+		case errors.Is(err, usecases.ErrAgencyNotFound):
+			return errNotFound(c, err.Error())
+		case errors.Is(err, usecases.ErrInvalidBounds):
+			return errBadRequest(c, err.Error())
+		case err != nil:
+			return errInternal(c, err.Error())
+		}
+		c.Set("Cache-Control", "public, max-age=300")
+		return c.JSON(bundle)
+	}
+}
+
+// parseBBox reads a min_lon,min_lat,max_lon,max_lat bounding box.
+func parseBBox(raw string) (domain.Bounds, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != 4 {
+		return domain.Bounds{}, errors.New("bbox must be min_lon,min_lat,max_lon,max_lat")
+	}
+	var v [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return domain.Bounds{}, errors.New("bbox must be min_lon,min_lat,max_lon,max_lat")
+		}
+		v[i] = f
+	}
+	return domain.Bounds{MinLon: v[0], MinLat: v[1], MaxLon: v[2], MaxLat: v[3]}, nil
+}
@@ -2,6 +2,8 @@ package http
 
 import (
 	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
@@ -20,14 +22,35 @@ type Pagination struct {
 	Total  int `json:"total"`
 }
 
+// parsePage reads ?offset and ?limit, defaulting to the first 100 results
+// and capping limit at maxLimit.
+func parsePage(c *fiber.Ctx, maxLimit int) (offset, limit int) {
+	offset = c.QueryInt("offset", 0)
+	limit = c.QueryInt("limit", 100)
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 || limit > maxLimit {
+		limit = 100
+	}
+	return offset, limit
+}
+
 // SetLinkHeaders adds RFC 8288 Link headers for paginated responses.
-// It uses the current request path and query parameters.
+// It uses the current request path and query parameters, so filters carry
+// over from page to page.
 func SetLinkHeaders(c *fiber.Ctx, p Pagination) {
 	base := c.Path()
+	query, _ := url.ParseQuery(string(c.Request().URI().QueryString()))
+	page := func(offset int) string {
+		query.Set("offset", strconv.Itoa(offset))
+		query.Set("limit", strconv.Itoa(p.Limit))
+		return base + "?" + query.Encode()
+	}
 	var links []string
 
 	// first
-	links = append(links, fmt.Sprintf(`<%s?offset=0&limit=%d>; rel="first"`, base, p.Limit))
+	links = append(links, fmt.Sprintf(`<%s>; rel="first"`, page(0)))
 
 	// prev
 	if p.Offset > 0 {
@@ -35,12 +58,12 @@ func SetLinkHeaders(c *fiber.Ctx, p Pagination) {
 		if prev < 0 {
 			prev = 0
 		}
-		links = append(links, fmt.Sprintf(`<%s?offset=%d&limit=%d>; rel="prev"`, base, prev, p.Limit))
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, page(prev)))
 	}
 
 	// next
 	if p.Offset+p.Limit < p.Total {
-		links = append(links, fmt.Sprintf(`<%s?offset=%d&limit=%d>; rel="next"`, base, p.Offset+p.Limit, p.Limit))
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, page(p.Offset+p.Limit)))
 	}
 
 	// last
@@ -48,7 +71,7 @@ func SetLinkHeaders(c *fiber.Ctx, p Pagination) {
 	if lastOffset < 0 {
 		lastOffset = 0
 	}
-	links = append(links, fmt.Sprintf(`<%s?offset=%d&limit=%d>; rel="last"`, base, lastOffset, p.Limit))
+	links = append(links, fmt.Sprintf(`<%s>; rel="last"`, page(lastOffset)))
 
 	c.Set("Link", strings.Join(links, ", "))
 }
@@ -0,0 +1,47 @@
+package http
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// GetPreferencesHandler returns the rider's notification preferences.
+// GET /v1/me/preferences
+func GetPreferencesHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		prefs, err := deps.Preferences.Get(c.Context(), currentUserID(c))
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		return c.JSON(prefs)
+	}
+}
+
+// PutPreferencesHandler replaces the rider's notification preferences.
+// Fields left out of the body keep their value; a null quiet_hours turns
+// quiet hours off.
+// PUT /v1/me/preferences
+func PutPreferencesHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := currentUserID(c)
+		prefs, err := deps.Preferences.Get(c.Context(), userID)
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		if err := c.BodyParser(prefs); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		err = deps.Preferences.Update(c.Context(), userID, prefs)
+		switch {
+		case err == nil:
+			return c.JSON(prefs)
+		case errors.Is(err, usecases.ErrInvalidPreferences):
+			return errBadRequest(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package http
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// ListPushKeysHandler returns the keys an agency signs pushed realtime data
+// with, without their secrets.
+// GET /v1/admin/agencies/:slug/push-keys
+func ListPushKeysHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		keys, err := deps.PushKeys.List(c.Context(), c.Params("slug"))
+		switch {
+		case err == nil:
+			return c.JSON(fiber.Map{"keys": keys})
+		case errors.Is(err, usecases.ErrAgencyNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
+
+// CreatePushKeyHandler issues a push key to an agency. The response is the
+// only time its secret is shown.
+// POST /v1/admin/agencies/:slug/push-keys
+func CreatePushKeyHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			Label string `json:"label"`
+		}
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&body); err != nil {
+				return errBadRequest(c, "invalid request body")
+			}
+		}
+		key, err := deps.PushKeys.Create(c.Context(), c.Params("slug"), body.Label)
+		switch {
+		case err == nil:
+			c.Set(fiber.HeaderCacheControl, "no-store")
+			return c.Status(fiber.StatusCreated).JSON(key)
+		case errors.Is(err, usecases.ErrAgencyNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errBadRequest(c, err.Error())
+		}
+	}
+}
+
+// DeletePushKeyHandler revokes a push key; pushes signed with it are
+// rejected from then on.
+// DELETE /v1/admin/agencies/:slug/push-keys/:id
+func DeletePushKeyHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := deps.PushKeys.Revoke(c.Context(), c.Params("slug"), c.Params("id"))
+		switch {
+		case err == nil:
+			return c.SendStatus(fiber.StatusNoContent)
+		case errors.Is(err, usecases.ErrAgencyNotFound), errors.Is(err, usecases.ErrPushKeyNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
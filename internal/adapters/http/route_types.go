@@ -0,0 +1,24 @@
+package http
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// parseRouteTypes reads the route_type and exclude_route_type filters:
+// comma-separated modes (metro, tram, funicular, ...) or GTFS route_types.
+func parseRouteTypes(c *fiber.Ctx) (domain.RouteTypeFilter, error) {
+	return usecases.ParseRouteTypes(c.Query("route_type"), c.Query("exclude_route_type"))
+}
+
+// RouteTypesHandler lists the modes route_type filters accept, with their
+// labels and the basic and extended GTFS route_types each stands for.
+// GET /v1/route-types
+func RouteTypesHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set("Cache-Control", "public, max-age=86400")
+		return c.JSON(usecases.RouteModes())
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/fiber/v2/middleware/limiter"
 	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/gofiber/fiber/v2/middleware/timeout"
@@ -18,10 +17,12 @@ func SetupRoutes(app *fiber.App, deps *Dependencies) {
 	app.Use(metrics.Middleware())
 	app.Get("/metrics", metrics.Handler())
 
-	// Response compression (gzip)
-	app.Use(compress.New(compress.Config{
-		Level: compress.LevelBestSpeed, // Balance speed vs compression ratio
-	}))
+	// Response compression (brotli or gzip)
+	compression := deps.Compression
+	if compression == (CompressionConfig{}) {
+		compression = DefaultCompressionConfig
+	}
+	app.Use(CompressionMiddleware(compression))
 
 	// Request ID
 	app.Use(requestid.New())
@@ -73,38 +74,190 @@ func SetupRoutes(app *fiber.App, deps *Dependencies) {
 	v1.Get("/agencies", timeout.NewWithContext(ListAgenciesHandler(deps), 15*time.Second))
 	v1.Get("/agencies/:slug", timeout.NewWithContext(GetAgencyHandler(deps), 15*time.Second))
 	v1.Get("/agencies/:slug/routes", timeout.NewWithContext(AgencyRoutesHandler(deps), 15*time.Second))
+	v1.Get("/agencies/:slug/feed-versions", timeout.NewWithContext(FeedVersionsHandler(deps), 15*time.Second))
+	v1.Get("/agencies/:slug/branding", timeout.NewWithContext(AgencyBrandingHandler(deps), 15*time.Second))
+	v1.Get("/agencies/:slug/realtime-coverage", timeout.NewWithContext(AgencyCoverageHandler(deps), 15*time.Second))
+	v1.Get("/agencies/:slug/fleet", timeout.NewWithContext(AgencyFleetHandler(deps), 15*time.Second))
 	v1.Get("/stops/nearby", timeout.NewWithContext(NearbyStopsHandler(deps), 15*time.Second))
 	v1.Get("/stops/search", timeout.NewWithContext(SearchStopsHandler(deps), 15*time.Second))
+	v1.Get("/search", timeout.NewWithContext(GlobalSearchHandler(deps), 15*time.Second))
+	v1.Get("/geocode", geocodeLimiter(deps), timeout.NewWithContext(GeocodeHandler(deps), 15*time.Second))
 	v1.Get("/stops/batch", timeout.NewWithContext(BatchStopsHandler(deps), 15*time.Second))
 	v1.Get("/stops/:id", timeout.NewWithContext(GetStopHandler(deps), 15*time.Second))
 	v1.Get("/stops/:id/departures", timeout.NewWithContext(StopDeparturesHandler(deps), 15*time.Second))
+	v1.Get("/departures/nearby", timeout.NewWithContext(NearbyDeparturesHandler(deps), 15*time.Second))
 	v1.Get("/stops/:id/routes", timeout.NewWithContext(StopRoutesHandler(deps), 15*time.Second))
+	v1.Get("/stops/:id/alerts", timeout.NewWithContext(StopAlertsHandler(deps), 15*time.Second))
+	v1.Get("/stops/:id/events", timeout.NewWithContext(StopEventsHandler(deps), 15*time.Second))
+	v1.Get("/stops/:id/crowding", timeout.NewWithContext(StopCrowdingHandler(deps), 15*time.Second))
 	v1.Get("/routes", timeout.NewWithContext(ListRoutesHandler(deps), 15*time.Second))
+	v1.Get("/route-types", timeout.NewWithContext(RouteTypesHandler(deps), 15*time.Second))
 	v1.Get("/routes/:id", timeout.NewWithContext(GetRouteHandler(deps), 15*time.Second))
+	v1.Get("/routes/:id/shape", timeout.NewWithContext(RouteShapeHandler(deps), 15*time.Second))
+	v1.Get("/routes/:id/badge.svg", timeout.NewWithContext(RouteBadgeHandler(deps), 15*time.Second))
 	v1.Get("/routes/:id/vehicles", timeout.NewWithContext(GetRouteVehiclesHandler(deps), 15*time.Second))
+	v1.Get("/routes/:id/occupancy", timeout.NewWithContext(RouteOccupancyHandler(deps), 15*time.Second))
+	v1.Get("/routes/:id/realtime-coverage", timeout.NewWithContext(RouteCoverageHandler(deps), 15*time.Second))
+	v1.Get("/routes/:id/segments", timeout.NewWithContext(RouteSegmentsHandler(deps), 15*time.Second))
+	v1.Get("/routes/:id/alerts", timeout.NewWithContext(RouteAlertsHandler(deps), 15*time.Second))
+	v1.Get("/vehicles/nearby", timeout.NewWithContext(NearbyVehiclesHandler(deps), 15*time.Second))
+	v1.Get("/vehicles/:vehicle_id/history", timeout.NewWithContext(VehicleHistoryHandler(deps), 15*time.Second))
 	v1.Get("/trips/:id", timeout.NewWithContext(GetTripHandler(deps), 15*time.Second))
 	v1.Get("/trips/:id/stop-times", timeout.NewWithContext(TripStopTimesHandler(deps), 15*time.Second))
+	v1.Get("/trips/:id/accessible-space", timeout.NewWithContext(TripAccessibleSpaceHandler(deps), 15*time.Second))
+	v1.Post("/trips/:id/accessible-space", RequireUser(deps.Tokens), timeout.NewWithContext(ReportAccessibleSpaceHandler(deps), 15*time.Second))
 	v1.Get("/feeds/status", timeout.NewWithContext(FeedStatsHandler(deps), 15*time.Second))
+	v1.Get("/feeds/realtime/status", timeout.NewWithContext(RealtimeFeedStatusHandler(deps), 15*time.Second))
+	v1.Get("/alerts", timeout.NewWithContext(ListAlertsHandler(deps), 15*time.Second))
+	v1.Get("/events", timeout.NewWithContext(ListEventsHandler(deps), 15*time.Second))
+	v1.Get("/events/:id", timeout.NewWithContext(GetEventHandler(deps), 15*time.Second))
 
 	// Journey planner (from/to)
 	v1.Get("/journeys", timeout.NewWithContext(JourneyHandler(deps), 15*time.Second))
+	v1.Get("/isochrones", timeout.NewWithContext(IsochroneHandler(deps), 15*time.Second))
 
 	// Enriched endpoints
 	v1.Get("/agencies/:slug/stats", timeout.NewWithContext(AgencyStatsHandler(deps), 15*time.Second))
 	v1.Get("/routes/:id/stops", timeout.NewWithContext(RouteStopsHandler(deps), 15*time.Second))
 
-	// GraphQL
-	app.Post("/graphql", GraphQLHandler(deps))
+	// Map tiles, sent as they are: map clients fetch them by the dozen and
+	// cache them, so compressing each costs more than it saves
+	v1.Get("/tiles/:z/:x/:y.:format", withoutCompression, timeout.NewWithContext(TileHandler(deps), 15*time.Second))
+
+	// Offline bundles for the PWA
+	v1.Get("/offline/bundle", timeout.NewWithContext(OfflineBundleHandler(deps), 30*time.Second))
+
+	// Delta sync of reference data for mobile apps
+	v1.Get("/sync/stops", timeout.NewWithContext(SyncStopsHandler(deps), 15*time.Second))
+	v1.Get("/sync/routes", timeout.NewWithContext(SyncRoutesHandler(deps), 15*time.Second))
+	v1.Get("/sync/alerts", timeout.NewWithContext(SyncAlertsHandler(deps), 15*time.Second))
+
+	// On-time performance analytics
+	v1.Get("/analytics/routes/:id/punctuality", timeout.NewWithContext(RoutePunctualityHandler(deps), 15*time.Second))
+	v1.Get("/analytics/network/summary", timeout.NewWithContext(NetworkPunctualityHandler(deps), 15*time.Second))
+
+	// Stop amenities reported by riders
+	v1.Patch("/stops/:id/amenities", RequireUser(deps.Tokens), timeout.NewWithContext(ReportStopAmenitiesHandler(deps), 15*time.Second))
+
+	// Stop signage short links
+	v1.Get("/stops/:id/link", timeout.NewWithContext(StopShortLinkHandler(deps), 15*time.Second))
+	app.Get("/s/:code", timeout.NewWithContext(ShortLinkRedirectHandler(deps), 15*time.Second))
+
+	// Affiliates: nearby for riders, management for admins
+	affiliates := v1.Group("/affiliates")
+	affiliates.Get("/nearby", timeout.NewWithContext(NearbyAffiliatesHandler(deps), 15*time.Second))
+	adminOnly := AdminAuthMiddleware(deps.AdminToken)
+	affiliates.Get("/", adminOnly, timeout.NewWithContext(ListAffiliatesHandler(deps), 15*time.Second))
+	affiliates.Post("/", adminOnly, timeout.NewWithContext(CreateAffiliateHandler(deps), 15*time.Second))
+	affiliates.Get("/:id", adminOnly, timeout.NewWithContext(GetAffiliateHandler(deps), 15*time.Second))
+	affiliates.Put("/:id", adminOnly, timeout.NewWithContext(UpdateAffiliateHandler(deps), 15*time.Second))
+	affiliates.Delete("/:id", adminOnly, timeout.NewWithContext(DeleteAffiliateHandler(deps), 15*time.Second))
+
+	// Rider accounts
+	v1.Post("/auth/register", timeout.NewWithContext(RegisterHandler(deps), 15*time.Second))
+	v1.Post("/auth/login", timeout.NewWithContext(LoginHandler(deps), 15*time.Second))
+
+	// Rider account, history and challenges (per user)
+	me := v1.Group("/me", RequireUser(deps.Tokens))
+	me.Get("/", timeout.NewWithContext(MeHandler(deps), 15*time.Second))
+	me.Get("/history", timeout.NewWithContext(GetHistoryHandler(deps), 15*time.Second))
+	me.Post("/history", timeout.NewWithContext(RecordJourneyHandler(deps), 15*time.Second))
+	me.Put("/history/consent", timeout.NewWithContext(SetHistoryConsentHandler(deps, true), 15*time.Second))
+	me.Delete("/history/consent", timeout.NewWithContext(SetHistoryConsentHandler(deps, false), 15*time.Second))
+	me.Get("/preferences", timeout.NewWithContext(GetPreferencesHandler(deps), 15*time.Second))
+	me.Put("/preferences", timeout.NewWithContext(PutPreferencesHandler(deps), 15*time.Second))
+	me.Get("/challenges", timeout.NewWithContext(ListChallengesHandler(deps), 15*time.Second))
+	me.Post("/challenges/:id/enroll", timeout.NewWithContext(EnrollChallengeHandler(deps), 15*time.Second))
+	me.Post("/challenges/:id/claim", timeout.NewWithContext(ClaimChallengeRewardHandler(deps), 15*time.Second))
+
+	// Trip check-ins, coupons, push devices, favorites and delay alerts (per user)
+	v1.Post("/checkins", RequireUser(deps.Tokens), timeout.NewWithContext(CheckInHandler(deps), 15*time.Second))
+	users := v1.Group("/users/me", RequireUser(deps.Tokens))
+	users.Get("/checkins", timeout.NewWithContext(ListCheckInsHandler(deps), 15*time.Second))
+	users.Get("/compensations", timeout.NewWithContext(ListCompensationsHandler(deps), 15*time.Second))
+	users.Post("/devices", timeout.NewWithContext(RegisterDeviceHandler(deps), 15*time.Second))
+	users.Get("/devices", timeout.NewWithContext(ListDevicesHandler(deps), 15*time.Second))
+	users.Delete("/devices/:id", timeout.NewWithContext(DeleteDeviceHandler(deps), 15*time.Second))
+	users.Get("/favorites", timeout.NewWithContext(ListFavoritesHandler(deps), 15*time.Second))
+	users.Post("/favorites", timeout.NewWithContext(CreateFavoriteHandler(deps), 15*time.Second))
+	users.Delete("/favorites/:id", timeout.NewWithContext(DeleteFavoriteHandler(deps), 15*time.Second))
+	users.Get("/dashboard", timeout.NewWithContext(DashboardHandler(deps), 15*time.Second))
+	users.Get("/alert-subscriptions", timeout.NewWithContext(ListAlertSubscriptionsHandler(deps), 15*time.Second))
+	users.Post("/alert-subscriptions", timeout.NewWithContext(CreateAlertSubscriptionHandler(deps), 15*time.Second))
+	users.Delete("/alert-subscriptions/:id", timeout.NewWithContext(DeleteAlertSubscriptionHandler(deps), 15*time.Second))
+	v1.Get("/push/vapid-key", VAPIDKeyHandler(deps))
+
+	// Coupon verification and redemption (affiliate staff and admins)
+	comps := v1.Group("/compensations", RequireUser(deps.Tokens))
+	comps.Get("/:code/verify", timeout.NewWithContext(VerifyCompensationHandler(deps), 15*time.Second))
+	comps.Post("/:code/redeem", timeout.NewWithContext(RedeemCompensationHandler(deps), 15*time.Second))
+
+	// Admin (bearer token)
+	admin := v1.Group("/admin", AdminAuthMiddleware(deps.AdminToken))
+	admin.Get("/agencies", timeout.NewWithContext(ListAgencyFeedsHandler(deps), 15*time.Second))
+	admin.Post("/agencies", timeout.NewWithContext(CreateAgencyFeedsHandler(deps), 15*time.Second))
+	admin.Put("/agencies/:slug", timeout.NewWithContext(PutAgencyFeedsHandler(deps), 15*time.Second))
+	admin.Delete("/agencies/:slug", timeout.NewWithContext(DeleteAgencyFeedsHandler(deps), 15*time.Second))
+	admin.Get("/agencies/:slug/qr-sheet", timeout.NewWithContext(AgencyQRSheetHandler(deps), 60*time.Second))
+	admin.Post("/agencies/:slug/timetable-export", timeout.NewWithContext(TimetableExportHandler(deps), 120*time.Second))
+	admin.Post("/challenges", timeout.NewWithContext(CreateChallengeHandler(deps), 15*time.Second))
+	admin.Post("/accessible-space", timeout.NewWithContext(VehicleAccessibleSpaceHandler(deps), 15*time.Second))
+	admin.Get("/agencies/:slug/sla", timeout.NewWithContext(ListSLAContractsHandler(deps), 15*time.Second))
+	admin.Post("/agencies/:slug/sla", timeout.NewWithContext(CreateSLAContractHandler(deps), 15*time.Second))
+	admin.Get("/agencies/:slug/sla/report", timeout.NewWithContext(SLAReportHandler(deps), 15*time.Second))
+	admin.Get("/agencies/:slug/feed-quality", timeout.NewWithContext(FeedQualityReportHandler(deps), 15*time.Second))
+	admin.Get("/agencies/:slug/feed-config", timeout.NewWithContext(GetFeedConfigHandler(deps), 15*time.Second))
+	admin.Put("/agencies/:slug/feed-config", timeout.NewWithContext(PutFeedConfigHandler(deps), 15*time.Second))
+	admin.Get("/agencies/:slug/feed-stages", timeout.NewWithContext(ListFeedStagesHandler(deps), 15*time.Second))
+	admin.Post("/agencies/:slug/feed-stages/rollback", timeout.NewWithContext(RollbackFeedHandler(deps), 15*time.Second))
+	admin.Post("/agencies/:slug/feed-stages/:id/approve", timeout.NewWithContext(ApproveFeedStageHandler(deps), 15*time.Second))
+	admin.Post("/agencies/:slug/feed-stages/:id/reject", timeout.NewWithContext(RejectFeedStageHandler(deps), 15*time.Second))
+	admin.Get("/agencies/:slug/aliases", timeout.NewWithContext(ListAgencyAliasesHandler(deps), 15*time.Second))
+	admin.Post("/agencies/:slug/aliases", timeout.NewWithContext(CreateAgencyAliasHandler(deps), 15*time.Second))
+	admin.Delete("/agencies/:slug/aliases/:source", timeout.NewWithContext(DeleteAgencyAliasHandler(deps), 15*time.Second))
+	admin.Get("/agencies/:slug/push-keys", timeout.NewWithContext(ListPushKeysHandler(deps), 15*time.Second))
+	admin.Post("/agencies/:slug/push-keys", timeout.NewWithContext(CreatePushKeyHandler(deps), 15*time.Second))
+	admin.Delete("/agencies/:slug/push-keys/:id", timeout.NewWithContext(DeletePushKeyHandler(deps), 15*time.Second))
+	admin.Put("/agencies/:slug/contact", timeout.NewWithContext(PutAgencyContactHandler(deps), 15*time.Second))
+	admin.Put("/agencies/:slug/branding", timeout.NewWithContext(PutBrandingColorsHandler(deps), 15*time.Second))
+	admin.Put("/agencies/:slug/branding/logo", timeout.NewWithContext(UploadAgencyLogoHandler(deps), 30*time.Second))
+	admin.Put("/agencies/:slug/branding/routes/:route_id/icon", timeout.NewWithContext(UploadRouteIconHandler(deps), 30*time.Second))
+	admin.Patch("/stops/:id/amenities", timeout.NewWithContext(UpdateStopAmenitiesHandler(deps), 15*time.Second))
+	admin.Get("/search/synonyms", timeout.NewWithContext(ListSearchSynonymsHandler(deps), 15*time.Second))
+	admin.Post("/search/synonyms", timeout.NewWithContext(CreateSearchSynonymHandler(deps), 15*time.Second))
+	admin.Delete("/search/synonyms/:id", timeout.NewWithContext(DeleteSearchSynonymHandler(deps), 15*time.Second))
+	admin.Get("/analytics/search", timeout.NewWithContext(SearchStatsHandler(deps), 15*time.Second))
+	admin.Post("/cdn/purge", timeout.NewWithContext(PurgeCDNHandler(deps), 30*time.Second))
+	admin.Get("/workflows", timeout.NewWithContext(ListWorkflowsHandler(deps), 30*time.Second))
+	admin.Post("/events", timeout.NewWithContext(CreateEventHandler(deps), 15*time.Second))
+	admin.Put("/events/:id", timeout.NewWithContext(UpdateEventHandler(deps), 15*time.Second))
+	admin.Delete("/events/:id", timeout.NewWithContext(DeleteEventHandler(deps), 15*time.Second))
+
+	// GraphQL, with subscriptions over WebSocket, sharing cost budgets
+	graphQLGuard := NewGraphQLGuard(deps.GraphQL)
+	app.Post("/graphql", GraphQLHandler(deps, graphQLGuard))
+	app.Get("/graphql", requireWebSocketUpgrade, websocket.New(GraphQLWebSocketHandler(deps, graphQLGuard), websocket.Config{
+		Subprotocols: []string{GraphQLWSProtocol},
+	}))
 
 	// API documentation (Swagger UI)
 	SetupDocs(app)
 
+	// Server-Sent Events, for clients that cannot use the WebSocket
+	v1.Get("/stream", StreamHandler(NewStreamHub(deps.NATS)))
+
 	// WebSocket
-	app.Use("/ws", func(c *fiber.Ctx) error {
-		if websocket.IsWebSocketUpgrade(c) {
-			return c.Next()
-		}
-		return fiber.ErrUpgradeRequired
-	})
-	app.Get("/ws", websocket.New(WebSocketHandler(deps.NATS)))
+	app.Use("/ws", requireWebSocketUpgrade)
+	app.Get("/ws", websocket.New(WebSocketHandler(deps.NATS), websocket.Config{
+		Subprotocols: []string{WSProtobufProtocol},
+	}))
+}
+
+// requireWebSocketUpgrade refuses requests that do not ask to upgrade to
+// WebSocket with 426.
+func requireWebSocketUpgrade(c *fiber.Ctx) error {
+	if websocket.IsWebSocketUpgrade(c) {
+		return c.Next()
+	}
+	return fiber.ErrUpgradeRequired
 }
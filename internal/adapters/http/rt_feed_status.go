@@ -0,0 +1,25 @@
+package http
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RealtimeFeedStatusHandler returns the health of every polled GTFS-RT feed:
+// last poll and success, header timestamp, entity count and whether the
+// feed is stale.
+// GET /v1/feeds/realtime/status
+func RealtimeFeedStatusHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		feeds, err := deps.RTFeeds.Status(c.Context(), time.Now())
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		c.Set("Cache-Control", "public, max-age=15")
+		return c.JSON(fiber.Map{
+			"stale_after_seconds": int(deps.RTFeeds.StaleAfter().Seconds()),
+			"feeds":               feeds,
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// GlobalSearchHandler searches agencies, routes and stops at once,
+// returning a typed list ranked by score. ?agencies=, ?routes= and ?stops=
+// cap each type; 0 leaves it out.
+// GET /v1/search?q=&agencies=&routes=&stops=
+func GlobalSearchHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		query := c.Query("q")
+		if query == "" {
+			return errBadRequest(c, "q query parameter is required")
+		}
+		if len(query) > 200 {
+			return errBadRequest(c, "query too long (max 200 characters)")
+		}
+		limits := domain.SearchLimits{
+			Agencies: c.QueryInt("agencies", usecases.DefaultSearchLimits.Agencies),
+			Routes:   c.QueryInt("routes", usecases.DefaultSearchLimits.Routes),
+			Stops:    c.QueryInt("stops", usecases.DefaultSearchLimits.Stops),
+		}
+		hits, err := deps.GlobalSearch.Search(c.Context(), query, limits)
+		switch {
+		case err == nil:
+			return c.JSON(fiber.Map{"results": hits})
+		case errors.Is(err, usecases.ErrInvalidSearch):
+			return errBadRequest(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
+
+// ListSearchSynonymsHandler returns the stop search synonyms.
+// GET /v1/admin/search/synonyms
+func ListSearchSynonymsHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		synonyms, err := deps.Search.Synonyms(c.Context())
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		return c.JSON(fiber.Map{"synonyms": synonyms})
+	}
+}
+
+// CreateSearchSynonymHandler adds a stop search synonym or misspelling
+// (one_way).
+// POST /v1/admin/search/synonyms
+func CreateSearchSynonymHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var syn domain.SearchSynonym
+		if err := c.BodyParser(&syn); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		err := deps.Search.AddSynonym(c.Context(), &syn)
+		switch {
+		case err == nil:
+			return c.Status(fiber.StatusCreated).JSON(syn)
+		case errors.Is(err, usecases.ErrSynonymExists):
+			return errConflict(c, err.Error())
+		default:
+			return errBadRequest(c, err.Error())
+		}
+	}
+}
+
+// DeleteSearchSynonymHandler removes a stop search synonym.
+// DELETE /v1/admin/search/synonyms/:id
+func DeleteSearchSynonymHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := deps.Search.RemoveSynonym(c.Context(), c.Params("id"))
+		switch {
+		case err == nil:
+			return c.SendStatus(fiber.StatusNoContent)
+		case errors.Is(err, usecases.ErrSynonymNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
+
+// SearchStatsHandler returns the stop search hit rate, by default over the
+// last 30 days, with the queries that most often found nothing. It is admin
+// only, as queries are typed by riders.
+// GET /v1/admin/analytics/search?from=&to=
+func SearchStatsHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		from, to, err := parsePunctualityRange(c)
+		if err != nil {
+			return errBadRequest(c, err.Error())
+		}
+		stats, err := deps.Search.Stats(c.Context(), from, to, time.Now())
+		switch {
+		case err == nil:
+			return c.JSON(stats)
+		case errors.Is(err, usecases.ErrInvalidSearchRange):
+			return errBadRequest(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// RouteSegmentsHandler returns how long the route's vehicles usually take
+// between consecutive stops and dwell at them, with the slowest segments
+// flagged.
+// GET /v1/routes/:id/segments?days=
+func RouteSegmentsHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		segments, err := deps.Segments.Route(c.Context(), c.Params("id"), c.QueryInt("days", 0), time.Now())
+		switch {
+		case errors.Is(err, usecases.ErrRouteNotFound):
+			return errNotFound(c, err.Error())
+		case errors.Is(err, usecases.ErrInvalidSegmentDays):
+			return errBadRequest(c, err.Error())
+		case err != nil:
+			return errInternal(c, err.Error())
+		}
+		c.Set("Cache-Control", "public, max-age=3600")
+		return c.JSON(segments)
+	}
+}
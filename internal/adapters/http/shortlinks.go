@@ -0,0 +1,130 @@
+package http
+
+import (
+	"bytes"
+	"encoding/csv"
+	"html/template"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// qrSheetTemplate renders an A4-friendly grid of stop QR codes for printing.
+// QR images are drawn client-side, like the Swagger UI in docs.go.
+var qrSheetTemplate = template.Must(template.New("qr").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="UTF-8">
+  <title>{{.Agency.Name}} — stop QR codes</title>
+  <style>
+    body{font-family:sans-serif;margin:1cm}
+    .grid{display:grid;grid-template-columns:repeat(3,1fr);gap:1cm}
+    .card{border:1px dashed #999;padding:.5cm;text-align:center;page-break-inside:avoid}
+    .card h2{font-size:14pt;margin:.2cm 0}
+    .qr{display:inline-block}
+    .code{font-family:monospace;font-size:12pt}
+  </style>
+</head>
+<body>
+  <h1>{{.Agency.Name}}</h1>
+  <div class="grid">
+  {{range .Links}}
+    <div class="card">
+      <div class="qr" data-url="{{.URL}}"></div>
+      <h2>{{.StopName}}</h2>
+      <div class="code">{{.Code}}</div>
+    </div>
+  {{end}}
+  </div>
+  <script src="https://cdn.jsdelivr.net/npm/qrcodejs@1.0.0/qrcode.min.js"></script>
+  <script>
+    document.querySelectorAll('.qr').forEach(function (el) {
+      new QRCode(el, {text: el.dataset.url, width: 160, height: 160});
+    });
+  </script>
+</body>
+</html>`))
+
+// ShortLinkRedirectHandler resolves a printed stop code and redirects to the stop board.
+// GET /s/:code
+func ShortLinkRedirectHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		code := c.Params("code")
+		if code == "" {
+			return errBadRequest(c, "code is required")
+		}
+		link, err := deps.ShortLinks.Resolve(c.Context(), code)
+		if err != nil {
+			return errNotFound(c, "short link not found")
+		}
+		c.Set("Cache-Control", "public, max-age=86400")
+		return c.Redirect(link.URL, fiber.StatusFound)
+	}
+}
+
+// StopShortLinkHandler returns (and assigns on first use) the short link of a stop.
+// GET /v1/stops/:id/link
+func StopShortLinkHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if id == "" {
+			return errBadRequest(c, "stop id is required")
+		}
+		if _, err := deps.Stops.GetByID(c.Context(), id); err != nil {
+			return errNotFound(c, "stop not found")
+		}
+		link, err := deps.ShortLinks.LinkForStop(c.Context(), id)
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		return c.JSON(link)
+	}
+}
+
+// AgencyQRSheetHandler exports printable QR codes for every stop of an agency.
+// GET /v1/admin/agencies/:slug/qr-sheet?format=html|csv
+func AgencyQRSheetHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		slug := c.Params("slug")
+		if slug == "" {
+			return errBadRequest(c, "agency slug is required")
+		}
+		format := c.Query("format", "html")
+		if format != "html" && format != "csv" {
+			return errBadRequest(c, "format must be html or csv")
+		}
+
+		agency, err := deps.Agencies.GetBySlug(c.Context(), slug)
+		if err != nil {
+			return errNotFound(c, "agency not found")
+		}
+
+		links, err := deps.ShortLinks.ExportAgency(c.Context(), agency.ID)
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+
+		var buf bytes.Buffer
+		if format == "csv" {
+			w := csv.NewWriter(&buf)
+			_ = w.Write([]string{"stop_id", "stop_name", "code", "url"})
+			for _, l := range links {
+				_ = w.Write([]string{l.StopID, l.StopName, l.Code, l.URL})
+			}
+			w.Flush()
+			c.Set("Content-Type", "text/csv; charset=utf-8")
+			c.Set("Content-Disposition", `attachment; filename="`+agency.Slug+`-stop-qr.csv"`)
+			return c.Send(buf.Bytes())
+		}
+
+		if err := qrSheetTemplate.Execute(&buf, struct {
+			Agency *domain.Agency
+			Links  []domain.StopShortLink
+		}{agency, links}); err != nil {
+			return errInternal(c, err.Error())
+		}
+		c.Set("Content-Type", "text/html; charset=utf-8")
+		return c.Send(buf.Bytes())
+	}
+}
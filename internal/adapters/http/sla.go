@@ -0,0 +1,65 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// CreateSLAContractHandler adds a punctuality commitment for an agency.
+// POST /v1/admin/agencies/:slug/sla
+func CreateSLAContractHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var contract domain.SLAContract
+		if err := c.BodyParser(&contract); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		contract.ID = ""
+		err := deps.SLA.CreateContract(c.Context(), c.Params("slug"), &contract)
+		switch {
+		case err == nil:
+			return c.Status(fiber.StatusCreated).JSON(contract)
+		case errors.Is(err, usecases.ErrAgencyNotFound):
+			return errNotFound(c, err.Error())
+		default:
+			return errBadRequest(c, err.Error())
+		}
+	}
+}
+
+// ListSLAContractsHandler returns an agency's active SLA contracts.
+// GET /v1/admin/agencies/:slug/sla
+func ListSLAContractsHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		contracts, err := deps.SLA.ListContracts(c.Context(), c.Params("slug"))
+		if err != nil {
+			if errors.Is(err, usecases.ErrAgencyNotFound) {
+				return errNotFound(c, err.Error())
+			}
+			return errInternal(c, err.Error())
+		}
+		return c.JSON(fiber.Map{"data": contracts})
+	}
+}
+
+// SLAReportHandler returns an agency's SLA standing for a month (default: current).
+// GET /v1/admin/agencies/:slug/sla/report?month=YYYY-MM
+func SLAReportHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		report, err := deps.SLA.Report(c.Context(), c.Params("slug"), c.Query("month"), time.Now())
+		switch {
+		case err == nil:
+			return c.JSON(report)
+		case errors.Is(err, usecases.ErrAgencyNotFound):
+			return errNotFound(c, err.Error())
+		case errors.Is(err, usecases.ErrInvalidMonth):
+			return errBadRequest(c, err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
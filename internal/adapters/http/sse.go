@@ -0,0 +1,228 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// sseHeartbeat is how often idle streams get a comment, which keeps
+	// proxies from closing them and finds disconnected clients.
+	sseHeartbeat = 15 * time.Second
+	// sseRetry is how long clients wait before reconnecting.
+	sseRetry = 3 * time.Second
+	// Events are kept for resuming streams for sseReplayAge, up to
+	// sseReplayMax of them.
+	sseReplayAge = 2 * time.Minute
+	sseReplayMax = 10000
+	// sseClientQueue bounds the events waiting to be written to a client;
+	// when it is full the oldest is dropped, as on /ws.
+	sseClientQueue = 256
+)
+
+// streamEvent is a NATS message as streamed to SSE clients. IDs are the
+// nanoseconds at which the API received it, made strictly increasing, so
+// a client resuming on another replica misses or repeats little.
+type streamEvent struct {
+	id      int64
+	at      time.Time
+	subject string
+	data    []byte
+}
+
+// streamListener is a client's stream, of the events whose subject
+// matches its pattern.
+type streamListener struct {
+	pattern string
+	events  chan streamEvent
+}
+
+// StreamHub relays the realtime NATS subjects to Server-Sent Events
+// clients and keeps recent events for those resuming with Last-Event-ID.
+// It subscribes to NATS with the first client.
+type StreamHub struct {
+	nc *nats.Conn
+
+	start    sync.Once
+	startErr error
+
+	mu        sync.Mutex
+	lastID    int64
+	recent    []streamEvent // oldest first
+	listeners map[*streamListener]struct{}
+}
+
+// NewStreamHub creates a StreamHub; nc may be nil, when streams are
+// unavailable.
+func NewStreamHub(nc *nats.Conn) *StreamHub {
+	return &StreamHub{nc: nc, listeners: map[*streamListener]struct{}{}}
+}
+
+// subscribe starts the hub's NATS subscriptions, once.
+func (h *StreamHub) subscribe() error {
+	h.start.Do(func() {
+		if h.nc == nil {
+			h.startErr = fmt.Errorf("live updates are unavailable")
+			return
+		}
+		for _, subject := range []string{"transit.vehicle.>", "transit.alerts.>", "transit.delays.detected"} {
+			if _, err := h.nc.Subscribe(subject, func(msg *nats.Msg) {
+				h.publish(msg.Subject, msg.Data, time.Now())
+			}); err != nil {
+				h.startErr = err
+				return
+			}
+		}
+	})
+	return h.startErr
+}
+
+// publish keeps an event and hands it to the listeners matching it. Only
+// JSON events are relayed, compacted to the one line SSE data takes.
+func (h *StreamHub) publish(subject string, data []byte, now time.Time) {
+	var buf bytes.Buffer
+	if json.Compact(&buf, data) != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastID = max(h.lastID+1, now.UnixNano())
+	ev := streamEvent{id: h.lastID, at: now, subject: subject, data: buf.Bytes()}
+
+	h.recent = append(h.recent, ev)
+	drop := max(len(h.recent)-sseReplayMax, 0)
+	for drop < len(h.recent) && now.Sub(h.recent[drop].at) > sseReplayAge {
+		drop++
+	}
+	h.recent = h.recent[drop:]
+
+	for l := range h.listeners {
+		if !subjectMatches(l.pattern, subject) {
+			continue
+		}
+		select {
+		case l.events <- ev:
+		default:
+			// Full: drop the oldest to make room.
+			select {
+			case <-l.events:
+			default:
+			}
+			l.events <- ev
+		}
+	}
+}
+
+// listen registers a listener of the subjects matching pattern, and
+// returns it with the events kept since lastID, when set. Both are taken
+// together, so nothing is missed or repeated in between.
+func (h *StreamHub) listen(pattern string, lastID int64) (*streamListener, []streamEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var replay []streamEvent
+	if lastID > 0 {
+		for _, ev := range h.recent {
+			if ev.id > lastID && subjectMatches(pattern, ev.subject) {
+				replay = append(replay, ev)
+			}
+		}
+	}
+	l := &streamListener{pattern: pattern, events: make(chan streamEvent, sseClientQueue)}
+	h.listeners[l] = struct{}{}
+	return l, replay
+}
+
+func (h *StreamHub) forget(l *streamListener) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.listeners, l)
+}
+
+// StreamHandler streams a realtime channel as Server-Sent Events, for
+// clients that cannot use WebSockets. Events carry the same NATS messages
+// as /ws, named after the channel; idle streams get a heartbeat comment.
+// Clients reconnecting with Last-Event-ID, or last_event_id for those that
+// cannot set headers, first get the events they missed, if still kept.
+// GET /v1/stream?channel=vehicles|alerts|delays&agency=
+func StreamHandler(hub *StreamHub) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		channel := c.Query("channel", "vehicles")
+		agency := c.Query("agency")
+		if agency != "" && !natsToken(agency) {
+			return errBadRequest(c, fmt.Sprintf("invalid agency %q", agency))
+		}
+		subject, ok := realtimeSubject(channel, agency)
+		if !ok {
+			return errBadRequest(c, "channel must be vehicles, alerts or delays")
+		}
+		lastID, _ := strconv.ParseInt(c.Get("Last-Event-ID", c.Query("last_event_id")), 10, 64)
+
+		if err := hub.subscribe(); err != nil {
+			return newError(c, fiber.StatusServiceUnavailable, "unavailable", err.Error())
+		}
+		l, replay := hub.listen(subject, lastID)
+
+		c.Set(fiber.HeaderContentType, "text/event-stream")
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+		c.Set(fiber.HeaderConnection, "keep-alive")
+		c.Set("X-Accel-Buffering", "no") // nginx would buffer the stream
+		shutdown := c.Context().Done()   // closed when the server shuts down
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer hub.forget(l)
+
+			fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+			for _, ev := range replay {
+				writeStreamEvent(w, channel, ev)
+			}
+			if w.Flush() != nil {
+				return
+			}
+
+			heartbeat := time.NewTicker(sseHeartbeat)
+			defer heartbeat.Stop()
+			for {
+				select {
+				case ev := <-l.events:
+					writeStreamEvent(w, channel, ev)
+				case <-heartbeat.C:
+					w.WriteString(": heartbeat\n\n")
+				case <-shutdown:
+					return
+				}
+				if w.Flush() != nil {
+					return
+				}
+			}
+		})
+		return nil
+	}
+}
+
+func writeStreamEvent(w *bufio.Writer, channel string, ev streamEvent) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.id, channel, ev.data)
+}
+
+// subjectMatches reports whether a NATS subject matches pattern, in
+// which * matches one token and a final > the rest.
+func subjectMatches(pattern, subject string) bool {
+	pt, st := strings.Split(pattern, "."), strings.Split(subject, ".")
+	for i, p := range pt {
+		if p == ">" {
+			return len(st) > i
+		}
+		if i >= len(st) || p != "*" && p != st[i] {
+			return false
+		}
+	}
+	return len(pt) == len(st)
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// ReportStopAmenitiesHandler lets a rider report a stop's amenities. Only the
+// fields present in the body change; amenities set by an admin are locked.
+// PATCH /v1/stops/:id/amenities
+func ReportStopAmenitiesHandler(deps *Dependencies) fiber.Handler {
+	return updateStopAmenities(deps, domain.AmenitySourceCommunity)
+}
+
+// UpdateStopAmenitiesHandler sets a stop's amenities as an admin. Only the
+// fields present in the body change.
+// PATCH /v1/admin/stops/:id/amenities
+func UpdateStopAmenitiesHandler(deps *Dependencies) fiber.Handler {
+	return updateStopAmenities(deps, domain.AmenitySourceAdmin)
+}
+
+func updateStopAmenities(deps *Dependencies, source string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var in domain.StopAmenities
+		if err := c.BodyParser(&in); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+
+		stop, err := deps.Stops.UpdateAmenities(c.Context(), c.Params("id"), in, source, time.Now())
+		switch {
+		case err == nil:
+			return c.JSON(stop)
+		case errors.Is(err, usecases.ErrStopNotFound):
+			return errNotFound(c, err.Error())
+		case errors.Is(err, usecases.ErrAmenitiesLocked):
+			return errConflict(c, err.Error())
+		default:
+			return errBadRequest(c, err.Error())
+		}
+	}
+}
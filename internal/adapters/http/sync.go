@@ -0,0 +1,53 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// SyncStopsHandler returns the stops changed or deleted since ?cursor, so
+// apps refresh reference data without a full download. Follow the returned
+// cursor while has_more is set, then keep it for the next sync.
+// GET /v1/sync/stops?agency=&cursor=&limit=
+func SyncStopsHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		page, err := deps.Sync.Stops(c.Context(), c.Query("agency"), c.Query("cursor"), c.QueryInt("limit", 0), time.Now())
+		return syncResult(c, page, err)
+	}
+}
+
+// SyncRoutesHandler returns the routes changed or deleted since ?cursor.
+// GET /v1/sync/routes?agency=&cursor=&limit=
+func SyncRoutesHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		page, err := deps.Sync.Routes(c.Context(), c.Query("agency"), c.Query("cursor"), c.QueryInt("limit", 0), time.Now())
+		return syncResult(c, page, err)
+	}
+}
+
+// SyncAlertsHandler returns the service alerts changed or removed since
+// ?cursor.
+// GET /v1/sync/alerts?agency=&cursor=&limit=
+func SyncAlertsHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		page, err := deps.Sync.Alerts(c.Context(), c.Query("agency"), c.Query("cursor"), c.QueryInt("limit", 0), time.Now())
+		return syncResult(c, page, err)
+	}
+}
+
+func syncResult(c *fiber.Ctx, page any, err error) error {
+	switch {
+	case errors.Is(err, usecases.ErrAgencyNotFound):
+		return errNotFound(c, err.Error())
+	case errors.Is(err, usecases.ErrInvalidCursor):
+		return errBadRequest(c, err.Error())
+	case err != nil:
+		return errInternal(c, err.Error())
+	}
+	c.Set("Cache-Control", "no-cache")
+	return c.JSON(page)
+}
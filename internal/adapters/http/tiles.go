@@ -0,0 +1,48 @@
+package http
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// TileHandler serves stop and route map tiles.
+// GET /v1/tiles/:z/:x/:y.mvt or /v1/tiles/:z/:x/:y.geojson
+func TileHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		z, errZ := c.ParamsInt("z")
+		x, errX := c.ParamsInt("x")
+		y, errY := c.ParamsInt("y")
+		if errZ != nil || errX != nil || errY != nil {
+			return errBadRequest(c, "z, x and y must be integers")
+		}
+
+		var (
+			data  []byte
+			err   error
+			ctype string
+		)
+		switch c.Params("format") {
+		case "mvt":
+			data, err = deps.Tiles.MVT(c.Context(), z, x, y)
+			ctype = "application/vnd.mapbox-vector-tile"
+		case "geojson":
+			data, err = deps.Tiles.GeoJSON(c.Context(), z, x, y)
+			ctype = "application/geo+json"
+		default:
+			return errBadRequest(c, "format must be mvt or geojson")
+		}
+		if err != nil {
+			if errors.Is(err, usecases.ErrInvalidTile) {
+				return errBadRequest(c, err.Error())
+			}
+			return errInternal(c, err.Error())
+		}
+
+		c.Set("Content-Type", ctype)
+		c.Set("Cache-Control", "public, max-age=3600")
+		return c.Send(data)
+	}
+}
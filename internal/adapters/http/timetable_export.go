@@ -0,0 +1,38 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// TimetableExportHandler exports the agency's scheduled against observed
+// timetable for a range of service dates to object storage and returns
+// where to download it.
+// POST /v1/admin/agencies/:slug/timetable-export {"from":"2026-03-02","to":"2026-03-08"}
+func TimetableExportHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		}
+		if err := c.BodyParser(&body); err != nil {
+			return errBadRequest(c, "invalid request body")
+		}
+		export, err := deps.Exports.Export(c.Context(), c.Params("slug"), body.From, body.To, time.Now())
+		switch {
+		case errors.Is(err, usecases.ErrAgencyNotFound):
+			return errNotFound(c, err.Error())
+		case errors.Is(err, usecases.ErrInvalidExportRange):
+			return errBadRequest(c, err.Error())
+		case errors.Is(err, usecases.ErrUploadsUnavailable):
+			return newError(c, fiber.StatusServiceUnavailable, "unavailable", err.Error())
+		case err != nil:
+			return errInternal(c, err.Error())
+		}
+		return c.Status(fiber.StatusCreated).JSON(export)
+	}
+}
@@ -0,0 +1,49 @@
+package http
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/pkg/auth"
+)
+
+// RequireUser authenticates the calling rider from an "Authorization: Bearer"
+// JWT and stores the user ID in c.Locals("user_id"). When tokens is nil (no
+// auth configured, as in tests) the client may identify itself with an
+// X-User-ID header instead.
+func RequireUser(tokens *auth.JWT) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var userID string
+		if tokens == nil {
+			userID = c.Get("X-User-ID")
+			if userID == "" {
+				return errUnauthorized(c, "X-User-ID header is required")
+			}
+		} else {
+			given, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+			if !ok || given == "" {
+				return errUnauthorized(c, "missing bearer token")
+			}
+			claims, err := tokens.Verify(given, time.Now())
+			if errors.Is(err, auth.ErrExpiredToken) {
+				return errUnauthorized(c, "token expired")
+			}
+			if err != nil {
+				return errUnauthorized(c, "invalid token")
+			}
+			userID = claims.Subject
+		}
+		c.Locals("user_id", userID)
+		c.Set("Cache-Control", "private, no-store")
+		return c.Next()
+	}
+}
+
+// currentUserID returns the user ID set by RequireUser.
+func currentUserID(c *fiber.Ctx) string {
+	id, _ := c.Locals("user_id").(string)
+	return id
+}
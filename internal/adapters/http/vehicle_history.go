@@ -0,0 +1,95 @@
+package http
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/pkg/geospatial"
+)
+
+// maxHistoryRange bounds vehicle histories to the positions kept
+// uncompressed by default, which are quick to read.
+const maxHistoryRange = 7 * 24 * time.Hour
+
+// VehicleHistoryHandler returns a vehicle's track over a period, by default
+// the last hour, as a GeoJSON LineString. Long periods are thinned to one
+// position per time bucket; the position times, speeds, bearings and trips
+// are listed alongside the coordinates for replaying. Vehicle IDs are only
+// unique within an agency, so ?agency=slug narrows them down.
+// GET /v1/vehicles/:vehicle_id/history?from=2026-03-14T08:00:00Z&to=2026-03-14T09:00:00Z
+func VehicleHistoryHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		vehicleID := c.Params("vehicle_id")
+		to := time.Now()
+		if raw := c.Query("to"); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return errBadRequest(c, "to must be an RFC 3339 time")
+			}
+			to = t
+		}
+		from := to.Add(-time.Hour)
+		if raw := c.Query("from"); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return errBadRequest(c, "from must be an RFC 3339 time")
+			}
+			from = t
+		}
+		if !from.Before(to) {
+			return errBadRequest(c, "from must be before to")
+		}
+		if to.Sub(from) > maxHistoryRange {
+			return errBadRequest(c, "range must be at most 7 days")
+		}
+
+		agency := c.Query("agency")
+		positions, bucket, err := deps.Routes.VehicleHistory(c.Context(), agency, vehicleID, from, to)
+		if err != nil {
+			return errInternal(c, err.Error())
+		}
+		if len(positions) == 0 {
+			return errNotFound(c, "no positions for vehicle in range")
+		}
+
+		c.Set("Cache-Control", "public, max-age=60")
+		return c.JSON(geospatial.NewFeatureCollection(vehicleTrack(positions, agency, from, to, bucket)), "application/geo+json")
+	}
+}
+
+func vehicleTrack(positions []domain.VehiclePosition, agency string, from, to time.Time, bucket time.Duration) geospatial.Feature {
+	line := &domain.GeoLineString{Coordinates: make([]domain.GeoPoint, len(positions))}
+	times := make([]time.Time, len(positions))
+	speeds := make([]float64, len(positions))
+	bearings := make([]float64, len(positions))
+	tripIDs := make([]string, len(positions))
+	for i, p := range positions {
+		line.Coordinates[i] = p.Location
+		times[i], speeds[i], bearings[i], tripIDs[i] = p.Time, p.Speed, p.Bearing, p.TripID
+	}
+
+	// A single fix is not a line.
+	geometry := geospatial.PointGeometry(positions[0].Location)
+	if len(positions) > 1 {
+		geometry = geospatial.LineStringGeometry(line)
+	}
+	return geospatial.Feature{
+		Type:     "Feature",
+		ID:       positions[0].VehicleID,
+		Geometry: geometry,
+		Properties: map[string]any{
+			"vehicle_id":     positions[0].VehicleID,
+			"agency":         agency,
+			"from":           from,
+			"to":             to,
+			"bucket_seconds": int(bucket.Seconds()),
+			"points":         len(positions),
+			"times":          times,
+			"speeds":         speeds,
+			"bearings":       bearings,
+			"trip_ids":       tripIDs,
+		},
+	}
+}
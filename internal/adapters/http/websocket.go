@@ -1,88 +1,277 @@
 package http
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
-	"sync"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/websocket/v2"
 	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	natsadapter "github.com/samirrijal/bilbopass/internal/adapters/nats"
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/pkg/metrics"
+	pb "github.com/samirrijal/bilbopass/internal/proto/bilbopassv1"
 )
 
+// WSProtobufProtocol is the WebSocket subprotocol of /ws clients that want
+// vehicle positions as bilbopass.v1.VehiclePosition protobuf messages, one
+// per binary frame, rather than as JSON.
+const WSProtobufProtocol = "bilbopass.v1.protobuf"
+
+// maxWSSubscriptions bounds the subscriptions of one connection, the
+// default one included.
+const maxWSSubscriptions = 10
+
+// wsResumeWindow bounds how far back vehicle subscriptions resume; older
+// positions are gone from JetStream by the hour anyway.
+const wsResumeWindow = 15 * time.Minute
+
 // wsMessage is sent from client to subscribe/unsubscribe to feeds.
 type wsMessage struct {
-	Action  string `json:"action"`  // "subscribe" | "unsubscribe"
-	Agency  string `json:"agency"`  // agency slug filter (optional, "" = all)
-	Channel string `json:"channel"` // "vehicles" | "alerts" | "delays" (default: vehicles)
+	Action  string `json:"action"`   // "subscribe" | "unsubscribe"
+	Agency  string `json:"agency"`   // agency slug filter (optional, "" = all)
+	Channel string `json:"channel"`  // "vehicles" | "alerts" | "delays" (default: vehicles)
+	RouteID string `json:"route_id"` // vehicles only: the feed's route_id (optional)
+	BBox    string `json:"bbox"`     // vehicles only: min_lon,min_lat,max_lon,max_lat (optional)
+	// ThrottleMS, for vehicles only, is the least time between two updates
+	// of a vehicle; those in between are coalesced (optional, 0 = all).
+	ThrottleMS int `json:"throttle_ms"`
+	// Since, for vehicles only, is the RFC 3339 time of the last update the
+	// client received; those published since are replayed first (optional).
+	Since string `json:"since"`
+}
+
+// wsVehicleFilter keeps the vehicle positions of one route, or inside a
+// bounding box, or both; the zero filter keeps them all. Those kept are
+// relayed at most once every `every` per vehicle, when set.
+type wsVehicleFilter struct {
+	routeID string
+	bbox    *domain.Bounds
+	every   time.Duration
+}
+
+// wsSubscription is a subscription of a WebSocket connection, with the
+// throttle of its messages if it has one.
+type wsSubscription struct {
+	sub      *nats.Subscription
+	throttle *wsThrottle
+}
+
+func (s *wsSubscription) close() {
+	_ = s.sub.Unsubscribe()
+	if s.throttle != nil {
+		s.throttle.stop()
+	}
+}
+
+// keep reports whether the position published as data passes the filter.
+func (f wsVehicleFilter) keep(data []byte) bool {
+	if f.routeID == "" && f.bbox == nil {
+		return true
+	}
+	var vp struct {
+		RouteID  string          `json:"route_id"`
+		Location domain.GeoPoint `json:"location"`
+	}
+	if err := json.Unmarshal(data, &vp); err != nil {
+		return false
+	}
+	if f.routeID != "" && vp.RouteID != f.routeID {
+		return false
+	}
+	return f.bbox == nil || f.bbox.Contains(vp.Location)
+}
+
+// wsVehicleFilterOf reads the route_id, bbox and throttle_ms of a vehicles
+// subscription.
+func wsVehicleFilterOf(m wsMessage) (wsVehicleFilter, error) {
+	f := wsVehicleFilter{routeID: m.RouteID, every: time.Duration(m.ThrottleMS) * time.Millisecond}
+	if m.ThrottleMS != 0 && (f.every < minWSThrottle || f.every > maxWSThrottle) {
+		return f, fmt.Errorf("throttle_ms must be 0, or %d to %d", minWSThrottle.Milliseconds(), maxWSThrottle.Milliseconds())
+	}
+	if m.BBox != "" {
+		b, err := parseBBox(m.BBox)
+		if err != nil {
+			return f, err
+		}
+		if b.MinLat < -90 || b.MaxLat > 90 || b.MinLon < -180 || b.MaxLon > 180 || b.MinLat >= b.MaxLat || b.MinLon >= b.MaxLon {
+			return f, errors.New("bbox must have min below max, within -180..180 and -90..90")
+		}
+		f.bbox = &b
+	}
+	return f, nil
+}
+
+// realtimeSubject returns the NATS subject of a realtime channel,
+// vehicles, alerts or delays, of one agency or, when agency is empty, all;
+// ok is false for unknown channels. The agency must be a natsToken.
+func realtimeSubject(channel, agency string) (subject string, ok bool) {
+	switch channel {
+	case "vehicles":
+		if agency != "" {
+			return "transit.vehicle." + agency + ".>", true
+		}
+		return "transit.vehicle.>", true
+	case "alerts":
+		if agency != "" {
+			return "transit.alerts." + agency, true
+		}
+		return "transit.alerts.>", true
+	case "delays":
+		return "transit.delays.detected", true
+	}
+	return "", false
+}
+
+// wsResumeSince parses the since of a resumed subscription, which is
+// brought forward to wsResumeWindow ago and back to now, so that a client
+// clock running ahead does not hold back live positions; the zero time
+// means no resume.
+func wsResumeSince(since string, now time.Time) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, since)
+	if err != nil {
+		return time.Time{}, errors.New("since must be an RFC 3339 time")
+	}
+	if oldest := now.Add(-wsResumeWindow); t.Before(oldest) {
+		t = oldest
+	}
+	if t.After(now) {
+		t = now
+	}
+	return t, nil
 }
 
 // WebSocketHandler returns a handler that upgrades to WebSocket
 // and relays real-time NATS events to connected clients.
 // Clients send JSON: {"action":"subscribe","agency":"metro_bilbao","channel":"vehicles"}
 // An empty agency means all agencies. Default channel is "vehicles".
+// Vehicle subscriptions may be narrowed to a route_id or a bbox; their
+// positions are filtered before being relayed, and throttled per vehicle
+// with throttle_ms. Subscriptions are told apart by their channel, agency,
+// filters and throttle, and a connection has at most maxWSSubscriptions.
+// Clients that negotiate WSProtobufProtocol get vehicle positions in
+// binary frames; everything else stays JSON in text frames.
+// Reconnecting clients resume vehicle subscriptions with since, the time of
+// the last update they received, or /ws?since= for the default one: the
+// positions published in between, up to wsResumeWindow ago, are replayed
+// from JetStream before the live ones, and are never dropped for backlog.
 func WebSocketHandler(nc *nats.Conn) func(*websocket.Conn) {
 	return func(c *websocket.Conn) {
 		defer c.Close()
 
 		remoteAddr := c.RemoteAddr().String()
 		log.Printf("ws client connected: %s", remoteAddr)
+		metrics.ActiveWebSockets.Inc()
+		defer metrics.ActiveWebSockets.Dec()
 
-		var mu sync.Mutex
-		subs := make(map[string]*nats.Subscription) // subject -> subscription
+		subs := make(map[string]*wsSubscription) // subject and filters -> subscription
 
-		// Helper: thread-safe write
-		writeJSON := func(v interface{}) error {
+		// Writes go through the sender's queue; it pings the client too.
+		sender := newWSSender(c)
+		go sender.run()
+		defer sender.stop()
+
+		writeJSON := func(v interface{}) {
 			data, err := json.Marshal(v)
 			if err != nil {
-				return err
+				return
 			}
-			mu.Lock()
-			defer mu.Unlock()
-			return c.WriteMessage(websocket.TextMessage, data)
+			sender.send(wsFrame{kind: websocket.TextMessage, data: data})
+		}
+
+		// Frames of NATS messages; ok is false for those that cannot be
+		// relayed.
+		jsonFrame := func(subject string, data []byte) (wsFrame, bool) {
+			var buf bytes.Buffer
+			if json.Compact(&buf, data) != nil {
+				return wsFrame{}, false
+			}
+			return wsFrame{kind: websocket.TextMessage, data: buf.Bytes(), subject: subject}, true
+		}
+		vehicleFrameOf := jsonFrame
+		if c.Subprotocol() == WSProtobufProtocol {
+			vehicleFrameOf = func(subject string, data []byte) (wsFrame, bool) {
+				frame, err := vehicleFrame(data)
+				if err != nil {
+					return wsFrame{}, false
+				}
+				return wsFrame{kind: websocket.BinaryMessage, data: frame, subject: subject}, true
+			}
+		}
+
+		// subscribe subscribes to subject, from since when set: the
+		// messages JetStream kept since then are replayed first, and
+		// handled with replayed set.
+		subscribe := func(subject string, since time.Time, handle func(msg *nats.Msg, replayed bool)) (*nats.Subscription, error) {
+			if since.IsZero() {
+				return nc.Subscribe(subject, func(msg *nats.Msg) { handle(msg, false) })
+			}
+			js, err := nc.JetStream()
+			if err != nil {
+				return nil, err
+			}
+			live := time.Now()
+			return js.Subscribe(subject, func(msg *nats.Msg) {
+				meta, err := msg.Metadata()
+				handle(msg, err == nil && meta.Timestamp.Before(live))
+			}, natsadapter.ReplayOptions(since)...)
 		}
 
 		// Auto-subscribe to all vehicle positions by default
 		defaultSubject := "transit.vehicle.>"
-		sub, err := nc.Subscribe(defaultSubject, func(msg *nats.Msg) {
-			_ = writeJSON(json.RawMessage(msg.Data))
-		})
+		since, err := wsResumeSince(c.Query("since"), time.Now())
+		if err != nil {
+			writeJSON(map[string]string{"error": err.Error()})
+			since = time.Time{}
+		}
+		relayDefault := func(msg *nats.Msg, replayed bool) {
+			if f, ok := vehicleFrameOf(msg.Subject, msg.Data); ok && replayed {
+				sender.sendWait(f)
+			} else if ok {
+				sender.send(f)
+			}
+		}
+		sub, err := subscribe(defaultSubject, since, relayDefault)
+		if err != nil && !since.IsZero() {
+			writeJSON(map[string]string{"error": "resume failed: " + err.Error()})
+			sub, err = subscribe(defaultSubject, time.Time{}, relayDefault)
+		}
 		if err != nil {
 			log.Printf("ws default subscribe error: %v", err)
 			return
 		}
-		subs[defaultSubject] = sub
-
-		// Keep-alive ping
-		done := make(chan struct{})
-		go func() {
-			ticker := time.NewTicker(30 * time.Second)
-			defer ticker.Stop()
-			for {
-				select {
-				case <-ticker.C:
-					mu.Lock()
-					err := c.WriteMessage(websocket.PingMessage, nil)
-					mu.Unlock()
-					if err != nil {
-						return
-					}
-				case <-done:
-					return
-				}
-			}
-		}()
+		subs[defaultSubject] = &wsSubscription{sub: sub}
+
+		// Clients must answer pings, or send something, within wsPongWait.
+		_ = c.SetReadDeadline(time.Now().Add(wsPongWait))
+		c.SetPongHandler(func(string) error {
+			return c.SetReadDeadline(time.Now().Add(wsPongWait))
+		})
 
 		// Read client messages for subscribe/unsubscribe
 		for {
 			_, msg, err := c.ReadMessage()
 			if err != nil {
+				if isTimeout(err) {
+					metrics.WSSlowDisconnects.WithLabelValues("pong_timeout").Inc()
+				}
 				break
 			}
+			_ = c.SetReadDeadline(time.Now().Add(wsPongWait))
 
 			var m wsMessage
 			if err := json.Unmarshal(msg, &m); err != nil {
-				_ = writeJSON(map[string]string{"error": "invalid JSON"})
+				writeJSON(map[string]string{"error": "invalid JSON"})
 				continue
 			}
 
@@ -91,63 +280,153 @@ func WebSocketHandler(nc *nats.Conn) func(*websocket.Conn) {
 			if channel == "" {
 				channel = "vehicles"
 			}
+			if m.Agency != "" && !natsToken(m.Agency) {
+				writeJSON(map[string]string{"error": fmt.Sprintf("invalid agency %q", m.Agency)})
+				continue
+			}
+			if channel != "vehicles" && (m.RouteID != "" || m.BBox != "" || m.ThrottleMS != 0 || m.Since != "") {
+				writeJSON(map[string]string{"error": "route_id, bbox, throttle_ms and since only apply to the vehicles channel"})
+				continue
+			}
 
-			var subject string
-			switch channel {
-			case "vehicles":
-				if m.Agency != "" {
-					subject = "transit.vehicle." + m.Agency + ".>"
-				} else {
-					subject = "transit.vehicle.>"
+			subject, ok := realtimeSubject(channel, m.Agency)
+			if !ok {
+				writeJSON(map[string]string{"error": "unknown channel: " + channel})
+				continue
+			}
+
+			filter, err := wsVehicleFilterOf(m)
+			if err != nil {
+				writeJSON(map[string]string{"error": err.Error()})
+				continue
+			}
+			since, err := wsResumeSince(m.Since, time.Now())
+			if err != nil {
+				writeJSON(map[string]string{"error": err.Error()})
+				continue
+			}
+			// Statuses name the subscription as the client did.
+			status := func(s string) map[string]string {
+				out := map[string]string{"status": s, "subject": subject}
+				if m.RouteID != "" {
+					out["route_id"] = m.RouteID
 				}
-			case "alerts":
-				if m.Agency != "" {
-					subject = "transit.alerts." + m.Agency
-				} else {
-					subject = "transit.alerts.>"
+				if m.BBox != "" {
+					out["bbox"] = m.BBox
 				}
-			case "delays":
-				subject = "transit.delays.detected"
-			default:
-				_ = writeJSON(map[string]string{"error": "unknown channel: " + channel})
-				continue
+				if m.ThrottleMS != 0 {
+					out["throttle_ms"] = strconv.Itoa(m.ThrottleMS)
+				}
+				if !since.IsZero() && s == "subscribed" {
+					out["since"] = since.Format(time.RFC3339Nano)
+				}
+				return out
+			}
+			key := subject
+			if filter.routeID != "" {
+				key += " route_id=" + filter.routeID
+			}
+			if b := filter.bbox; b != nil {
+				key += fmt.Sprintf(" bbox=%g,%g,%g,%g", b.MinLon, b.MinLat, b.MaxLon, b.MaxLat)
+			}
+			if filter.every > 0 {
+				key += fmt.Sprintf(" throttle_ms=%d", m.ThrottleMS)
 			}
 
 			switch m.Action {
 			case "subscribe":
-				if _, exists := subs[subject]; exists {
-					_ = writeJSON(map[string]string{"status": "already subscribed", "subject": subject})
+				if _, exists := subs[key]; exists {
+					writeJSON(status("already subscribed"))
+					continue
+				}
+				if len(subs) >= maxWSSubscriptions {
+					writeJSON(map[string]string{"error": fmt.Sprintf("at most %d subscriptions per connection", maxWSSubscriptions)})
 					continue
 				}
-				s, err := nc.Subscribe(subject, func(msg *nats.Msg) {
-					_ = writeJSON(json.RawMessage(msg.Data))
+				frameOf := jsonFrame
+				if channel == "vehicles" {
+					frameOf = vehicleFrameOf
+				}
+				var throttle *wsThrottle
+				if filter.every > 0 {
+					throttle = newWSThrottle(filter.every, func(subject string, data []byte) {
+						if f, ok := frameOf(subject, data); ok {
+							sender.send(f)
+						}
+					})
+				}
+				s, err := subscribe(subject, since, func(msg *nats.Msg, replayed bool) {
+					if !filter.keep(msg.Data) {
+						return
+					}
+					if throttle != nil {
+						// Replayed positions are coalesced like live ones.
+						throttle.offer(msg.Subject, msg.Data)
+						return
+					}
+					f, ok := frameOf(msg.Subject, msg.Data)
+					if ok && replayed {
+						sender.sendWait(f)
+					} else if ok {
+						sender.send(f)
+					}
 				})
 				if err != nil {
-					_ = writeJSON(map[string]string{"error": "subscribe failed: " + err.Error()})
+					writeJSON(map[string]string{"error": "subscribe failed: " + err.Error()})
 					continue
 				}
-				subs[subject] = s
-				_ = writeJSON(map[string]string{"status": "subscribed", "subject": subject})
+				subs[key] = &wsSubscription{sub: s, throttle: throttle}
+				writeJSON(status("subscribed"))
 
 			case "unsubscribe":
-				if s, exists := subs[subject]; exists {
-					_ = s.Unsubscribe()
-					delete(subs, subject)
-					_ = writeJSON(map[string]string{"status": "unsubscribed", "subject": subject})
+				if s, exists := subs[key]; exists {
+					s.close()
+					delete(subs, key)
+					writeJSON(status("unsubscribed"))
 				} else {
-					_ = writeJSON(map[string]string{"error": "not subscribed to " + subject})
+					writeJSON(map[string]string{"error": "not subscribed to " + key})
 				}
 
 			default:
-				_ = writeJSON(map[string]string{"error": "unknown action: " + m.Action})
+				writeJSON(map[string]string{"error": "unknown action: " + m.Action})
 			}
 		}
 
 		// Cleanup
-		close(done)
 		for _, s := range subs {
-			_ = s.Unsubscribe()
+			s.close()
 		}
 		log.Printf("ws client disconnected: %s", remoteAddr)
 	}
 }
+
+// vehicleFrame encodes a vehicle position, as published to NATS, in the
+// protobuf of binary frames.
+func vehicleFrame(data []byte) ([]byte, error) {
+	var vp domain.VehiclePosition
+	if err := json.Unmarshal(data, &vp); err != nil {
+		return nil, err
+	}
+	agency, _ := vp.Metadata["agency"].(string)
+	msg := &pb.VehiclePosition{
+		VehicleId:       vp.VehicleID,
+		TripId:          vp.TripID,
+		RouteId:         vp.RouteID,
+		Position:        &pb.Position{Latitude: vp.Location.Lat, Longitude: vp.Location.Lon},
+		Bearing:         float32(vp.Bearing),
+		Speed:           float32(vp.Speed),
+		CongestionLevel: int32(vp.CongestionLevel),
+		Agency:          agency,
+		StopId:          vp.StopID,
+	}
+	if !vp.Time.IsZero() {
+		msg.Timestamp = timestamppb.New(vp.Time)
+	}
+	if vp.OccupancyStatus != nil {
+		msg.OccupancyStatus = proto.Int32(int32(*vp.OccupancyStatus))
+	}
+	if vp.StopSequence != nil {
+		msg.StopSequence = proto.Int32(int32(*vp.StopSequence))
+	}
+	return proto.Marshal(msg)
+}
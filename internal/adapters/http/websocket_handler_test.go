@@ -0,0 +1,309 @@
+package http_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	fws "github.com/fasthttp/websocket"
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+
+	handler "github.com/samirrijal/bilbopass/internal/adapters/http"
+	pb "github.com/samirrijal/bilbopass/internal/proto/bilbopassv1"
+)
+
+// fakeNATS speaks enough of the NATS protocol for the WebSocket handler
+// to subscribe, and for tests to publish to its subscriptions.
+type fakeNATS struct {
+	ln net.Listener
+
+	mu   sync.Mutex
+	conn net.Conn
+	subs map[string]string // sid -> subject
+}
+
+func newFakeNATS(t *testing.T) *fakeNATS {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeNATS{ln: ln, subs: map[string]string{}}
+	t.Cleanup(func() { _ = ln.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *fakeNATS) url() string { return "nats://" + s.ln.Addr().String() }
+
+func (s *fakeNATS) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conn = conn
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeNATS) handle(conn net.Conn) {
+	defer conn.Close()
+	s.write(conn, `INFO {"server_id":"fake","version":"2.10.0","proto":1,"headers":true,"max_payload":1048576}`+"\r\n")
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		switch strings.ToUpper(f[0]) {
+		case "PING":
+			s.write(conn, "PONG\r\n")
+		case "SUB":
+			s.mu.Lock()
+			s.subs[f[len(f)-1]] = f[1]
+			s.mu.Unlock()
+		case "UNSUB":
+			s.mu.Lock()
+			delete(s.subs, f[1])
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *fakeNATS) write(conn net.Conn, data string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, _ = conn.Write([]byte(data))
+}
+
+// subscribed waits until a subscription to subject exists.
+func (s *fakeNATS) subscribed(t *testing.T, subject string) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		s.mu.Lock()
+		for _, subj := range s.subs {
+			if subj == subject {
+				s.mu.Unlock()
+				return
+			}
+		}
+		s.mu.Unlock()
+	}
+	t.Fatalf("no subscription to %s", subject)
+}
+
+// publish delivers data on subject to the matching subscriptions.
+func (s *fakeNATS) publish(subject string, data []byte) {
+	s.mu.Lock()
+	var msgs []string
+	for sid, subj := range s.subs {
+		if subjectMatches(subj, subject) {
+			msgs = append(msgs, fmt.Sprintf("MSG %s %s %d\r\n%s\r\n", subject, sid, len(data), data))
+		}
+	}
+	conn := s.conn
+	s.mu.Unlock()
+	for _, m := range msgs {
+		s.write(conn, m)
+	}
+}
+
+func subjectMatches(pattern, subject string) bool {
+	p, t := strings.Split(pattern, "."), strings.Split(subject, ".")
+	for i, tok := range p {
+		if tok == ">" {
+			return len(t) > i
+		}
+		if i >= len(t) || tok != "*" && tok != t[i] {
+			return false
+		}
+	}
+	return len(p) == len(t)
+}
+
+// dialWS serves /ws relaying from a fake NATS and connects to it,
+// negotiating the subprotocols given. It returns once the default
+// subscription is in place.
+func dialWS(t *testing.T, subprotocols ...string) (*fws.Conn, *fakeNATS) {
+	t.Helper()
+	srv := newFakeNATS(t)
+	nc, err := nats.Connect(srv.url(), nats.NoReconnect())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+
+	app := setupApp(makeDeps(func(d *handler.Dependencies) { d.NATS = nc }))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = app.Listener(ln) }()
+	t.Cleanup(func() { _ = app.Shutdown() })
+
+	dialer := fws.Dialer{Subprotocols: subprotocols, HandshakeTimeout: 2 * time.Second}
+	ws, _, err := dialer.Dial("ws://"+ln.Addr().String()+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ws.Close() })
+	srv.subscribed(t, "transit.vehicle.>")
+	return ws, srv
+}
+
+// wsRoundTrip sends msg and returns the reply.
+func wsRoundTrip(t *testing.T, ws *fws.Conn, msg string) map[string]any {
+	t.Helper()
+	if err := ws.WriteMessage(fws.TextMessage, []byte(msg)); err != nil {
+		t.Fatal(err)
+	}
+	return readWSJSON(t, ws)
+}
+
+func readWSJSON(t *testing.T, ws *fws.Conn) map[string]any {
+	t.Helper()
+	_ = ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	kind, data, err := ws.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kind != fws.TextMessage {
+		t.Fatalf("expected a text frame, got %d", kind)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("expected a JSON object, got %s", data)
+	}
+	return out
+}
+
+func TestWebSocket_RejectsInvalidSubscriptions(t *testing.T) {
+	ws, _ := dialWS(t)
+
+	for _, tc := range []struct {
+		name, msg, errPart string
+	}{
+		{"invalid JSON", `{"action":`, "invalid JSON"},
+		{"bbox with three values", `{"action":"subscribe","bbox":"-3,43,-2"}`, "bbox must be"},
+		{"bbox with five values", `{"action":"subscribe","bbox":"-3,43,-2,44,1"}`, "bbox must be"},
+		{"bbox not numbers", `{"action":"subscribe","bbox":"-3,north,-2,44"}`, "bbox must be"},
+		{"bbox latitude out of range", `{"action":"subscribe","bbox":"-3,43,-2,95"}`, "within -180..180 and -90..90"},
+		{"bbox longitude out of range", `{"action":"subscribe","bbox":"-181,43,-2,44"}`, "within -180..180 and -90..90"},
+		{"bbox min longitude above max", `{"action":"subscribe","bbox":"-2,43,-3,44"}`, "min below max"},
+		{"bbox min latitude above max", `{"action":"subscribe","bbox":"-3,44,-2,43"}`, "min below max"},
+		{"bbox empty", `{"action":"subscribe","bbox":"-3,43,-3,43"}`, "min below max"},
+		{"throttle too short", `{"action":"subscribe","throttle_ms":50}`, "throttle_ms must be"},
+		{"throttle too long", `{"action":"subscribe","throttle_ms":120000}`, "throttle_ms must be"},
+		{"since not a time", `{"action":"subscribe","since":"yesterday"}`, "since must be"},
+		{"filter on alerts", `{"action":"subscribe","channel":"alerts","bbox":"-3,43,-2,44"}`, "only apply to the vehicles channel"},
+		{"route on delays", `{"action":"subscribe","channel":"delays","route_id":"L1"}`, "only apply to the vehicles channel"},
+		{"unknown channel", `{"action":"subscribe","channel":"trains"}`, "unknown channel"},
+		{"agency with wildcard", `{"action":"subscribe","agency":"metro.>"}`, "invalid agency"},
+		{"unknown action", `{"action":"watch"}`, "unknown action"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reply := wsRoundTrip(t, ws, tc.msg)
+			if msg, _ := reply["error"].(string); !strings.Contains(msg, tc.errPart) {
+				t.Errorf("expected an error containing %q, got %v", tc.errPart, reply)
+			}
+		})
+	}
+}
+
+func TestWebSocket_FilteredSubscription(t *testing.T) {
+	ws, srv := dialWS(t)
+
+	// Unsubscribe from everything, so only the filtered positions arrive.
+	if reply := wsRoundTrip(t, ws, `{"action":"unsubscribe"}`); reply["status"] != "unsubscribed" {
+		t.Fatalf("expected the default subscription dropped, got %v", reply)
+	}
+	reply := wsRoundTrip(t, ws, `{"action":"subscribe","agency":"bilbobus","route_id":"L1","bbox":"-3.0, 43.2, -2.8, 43.4"}`)
+	if reply["status"] != "subscribed" || reply["subject"] != "transit.vehicle.bilbobus.>" ||
+		reply["route_id"] != "L1" || reply["bbox"] != "-3.0, 43.2, -2.8, 43.4" {
+		t.Fatalf("unexpected reply %v", reply)
+	}
+	srv.subscribed(t, "transit.vehicle.bilbobus.>")
+
+	// The same filters written differently are the same subscription.
+	if reply := wsRoundTrip(t, ws, `{"action":"subscribe","agency":"bilbobus","route_id":"L1","bbox":"-3,43.2,-2.8,43.4"}`); reply["status"] != "already subscribed" {
+		t.Errorf("expected already subscribed, got %v", reply)
+	}
+
+	for _, vp := range []string{
+		`{"vehicle_id":"v1","route_id":"L2","location":{"lat":43.26,"lon":-2.93}}`, // other route
+		`{"vehicle_id":"v2","route_id":"L1","location":{"lat":43.5,"lon":-2.93}}`,  // outside the bbox
+		`{"vehicle_id":"v3","route_id":"L1","location":{"lat":43.26,"lon":-2.93}}`,
+	} {
+		var v struct {
+			VehicleID string `json:"vehicle_id"`
+		}
+		_ = json.Unmarshal([]byte(vp), &v)
+		srv.publish("transit.vehicle.bilbobus."+v.VehicleID, []byte(vp))
+	}
+	got := readWSJSON(t, ws)
+	if got["vehicle_id"] != "v3" {
+		t.Errorf("expected only v3 relayed, got %v", got)
+	}
+}
+
+func TestWebSocket_VehicleFrames(t *testing.T) {
+	position := []byte(`{"time":"2026-03-02T08:15:30Z","vehicle_id":"v1","route_id":"L1","location":{"lat":43.26,"lon":-2.93},` +
+		`"occupancy_status":1,"stop_id":"s7","metadata":{"agency":"bilbobus"}}`)
+
+	for _, tc := range []struct {
+		name         string
+		subprotocols []string
+		protobuf     bool
+	}{
+		{"no subprotocol", nil, false},
+		{"other subprotocol", []string{"graphql-transport-ws"}, false},
+		{"protobuf negotiated", []string{"graphql-transport-ws", handler.WSProtobufProtocol}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ws, srv := dialWS(t, tc.subprotocols...)
+			if got := ws.Subprotocol(); (got == handler.WSProtobufProtocol) != tc.protobuf {
+				t.Fatalf("negotiated %q", got)
+			}
+
+			srv.publish("transit.vehicle.bilbobus.v1", position)
+			_ = ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+			kind, data, err := ws.ReadMessage()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tc.protobuf {
+				var vp map[string]any
+				if kind != fws.TextMessage || json.Unmarshal(data, &vp) != nil || vp["vehicle_id"] != "v1" {
+					t.Fatalf("expected the position as JSON in a text frame, got %d %s", kind, data)
+				}
+				return
+			}
+			var vp pb.VehiclePosition
+			if kind != fws.BinaryMessage || proto.Unmarshal(data, &vp) != nil {
+				t.Fatalf("expected a protobuf binary frame, got %d %q", kind, data)
+			}
+			if vp.GetVehicleId() != "v1" || vp.GetAgency() != "bilbobus" || vp.GetStopId() != "s7" ||
+				vp.OccupancyStatus == nil || vp.GetOccupancyStatus() != 1 || vp.StopSequence != nil {
+				t.Errorf("unexpected position %v", &vp)
+			}
+
+			// Replies to the client stay JSON.
+			if reply := wsRoundTrip(t, ws, `{"action":"watch"}`); reply["error"] == nil {
+				t.Errorf("expected a JSON error reply, got %v", reply)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package http
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+
+	"github.com/samirrijal/bilbopass/internal/pkg/metrics"
+)
+
+const (
+	// wsSendQueue bounds the frames waiting to be written to a client;
+	// when it is full the oldest NATS message is dropped.
+	wsSendQueue = 256
+	// wsWriteTimeout bounds each write; clients that take longer are
+	// disconnected.
+	wsWriteTimeout = 10 * time.Second
+	// wsPingInterval is how often clients are pinged, and wsPongWait how
+	// long they have to answer, or send anything, before being
+	// disconnected.
+	wsPingInterval = 30 * time.Second
+	wsPongWait     = 2*wsPingInterval + wsWriteTimeout
+)
+
+// wsFrame is a message waiting to be written. NATS messages have the
+// subject they were published to; the handler's own replies have none.
+type wsFrame struct {
+	kind    int
+	data    []byte
+	subject string
+}
+
+// wsSender owns the writes to a WebSocket connection, so that a slow
+// client holds up its own queue rather than the NATS callbacks: frames
+// are queued, up to wsSendQueue, and written by run.
+type wsSender struct {
+	conn *websocket.Conn
+
+	mu     sync.Mutex
+	queue  []wsFrame
+	closed bool
+	room   *sync.Cond // signalled when the queue is emptied or closed
+	wake   chan struct{}
+	done   chan struct{}
+}
+
+func newWSSender(conn *websocket.Conn) *wsSender {
+	s := &wsSender{conn: conn, wake: make(chan struct{}, 1), done: make(chan struct{})}
+	s.room = sync.NewCond(&s.mu)
+	return s
+}
+
+// send queues a frame. When the queue is full, the oldest NATS message in
+// it makes room; the handler's replies are only dropped when it holds
+// nothing else.
+func (s *wsSender) send(f wsFrame) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	if len(s.queue) >= wsSendQueue {
+		drop := 0
+		for i, q := range s.queue {
+			if q.subject != "" {
+				drop = i
+				break
+			}
+		}
+		if subject := s.queue[drop].subject; subject != "" {
+			metrics.WSMessagesDropped.WithLabelValues(wsMetricSubject(subject)).Inc()
+		}
+		s.queue = append(s.queue[:drop], s.queue[drop+1:]...)
+	}
+	s.queue = append(s.queue, f)
+	s.mu.Unlock()
+	s.notify()
+}
+
+// sendWait queues a frame like send, but waits for room rather than
+// dropping anything. It is for messages the client asked to replay, whose
+// JetStream consumer it holds back.
+func (s *wsSender) sendWait(f wsFrame) {
+	s.mu.Lock()
+	for len(s.queue) >= wsSendQueue && !s.closed {
+		s.room.Wait()
+	}
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.queue = append(s.queue, f)
+	s.mu.Unlock()
+	s.notify()
+}
+
+func (s *wsSender) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run writes the queued frames and pings the client until stop is called
+// or a write fails. A write failing, e.g. for taking over wsWriteTimeout,
+// closes the connection, which ends the handler's read loop.
+func (s *wsSender) run() {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.wake:
+			s.mu.Lock()
+			frames := s.queue
+			s.queue = nil
+			s.room.Broadcast()
+			s.mu.Unlock()
+			for _, f := range frames {
+				_ = s.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+				if err := s.conn.WriteMessage(f.kind, f.data); err != nil {
+					s.fail(err)
+					return
+				}
+				if f.subject != "" {
+					metrics.WSMessagesSent.WithLabelValues(wsMetricSubject(f.subject)).Inc()
+				}
+			}
+		case <-ticker.C:
+			if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				s.fail(err)
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// fail stops queueing and closes the connection after a failed write.
+func (s *wsSender) fail(err error) {
+	s.mu.Lock()
+	s.closed, s.queue = true, nil
+	s.room.Broadcast()
+	s.mu.Unlock()
+	if isTimeout(err) {
+		metrics.WSSlowDisconnects.WithLabelValues("write_timeout").Inc()
+	}
+	_ = s.conn.Close()
+}
+
+// stop ends run; frames still queued are dropped.
+func (s *wsSender) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed, s.queue = true, nil
+		s.room.Broadcast()
+	}
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+}
+
+// wsMetricSubject is the subject of a NATS message up to its third token,
+// which names the agency of vehicle positions and alerts without the
+// unbounded vehicle IDs.
+func wsMetricSubject(subject string) string {
+	tokens := strings.SplitN(subject, ".", 4)
+	if len(tokens) > 3 {
+		tokens = tokens[:3]
+	}
+	return strings.Join(tokens, ".")
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
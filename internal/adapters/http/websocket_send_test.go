@@ -0,0 +1,175 @@
+package http
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/samirrijal/bilbopass/internal/pkg/metrics"
+)
+
+// The sender's queue is inspected directly: without run nothing is
+// written, so no connection is needed.
+
+func natsFrame(subject string, i int) wsFrame {
+	return wsFrame{kind: websocket.TextMessage, data: []byte(fmt.Sprint(i)), subject: subject}
+}
+
+func queued(s *wsSender) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var data []string
+	for _, f := range s.queue {
+		data = append(data, string(f.data))
+	}
+	return data
+}
+
+func TestWSSender_KeepsOrder(t *testing.T) {
+	s := newWSSender(nil)
+	for i := 0; i < 3; i++ {
+		s.send(natsFrame("transit.vehicle.bilbobus.v1", i))
+	}
+	s.sendWait(natsFrame("transit.replay.bilbobus.v1", 3))
+	s.send(wsFrame{kind: websocket.TextMessage, data: []byte("4")})
+
+	if got := fmt.Sprint(queued(s)); got != "[0 1 2 3 4]" {
+		t.Errorf("expected frames queued in order, got %s", got)
+	}
+}
+
+func TestWSSender_OverflowDropsOldest(t *testing.T) {
+	const subject = "transit.vehicle.overflow.v1"
+	dropped := metrics.WSMessagesDropped.WithLabelValues("transit.vehicle.overflow")
+	before := testutil.ToFloat64(dropped)
+
+	s := newWSSender(nil)
+	for i := 0; i < wsSendQueue+2; i++ {
+		s.send(natsFrame(subject, i))
+	}
+
+	got := queued(s)
+	if len(got) != wsSendQueue || got[0] != "2" || got[len(got)-1] != fmt.Sprint(wsSendQueue+1) {
+		t.Errorf("expected frames 2 to %d queued, got %d frames from %s to %s",
+			wsSendQueue+1, len(got), got[0], got[len(got)-1])
+	}
+	if n := testutil.ToFloat64(dropped) - before; n != 2 {
+		t.Errorf("expected 2 drops counted, got %v", n)
+	}
+}
+
+func TestWSSender_OverflowKeepsReplies(t *testing.T) {
+	s := newWSSender(nil)
+	s.send(wsFrame{kind: websocket.TextMessage, data: []byte("reply")})
+	for i := 1; i <= wsSendQueue; i++ {
+		s.send(natsFrame("transit.vehicle.bilbobus.v1", i))
+	}
+
+	got := queued(s)
+	if len(got) != wsSendQueue || got[0] != "reply" || got[1] != "2" {
+		t.Errorf("expected the reply kept and NATS message 1 dropped, got %v...", got[:2])
+	}
+
+	// A queue of nothing but replies drops the oldest reply.
+	s = newWSSender(nil)
+	for i := 0; i <= wsSendQueue; i++ {
+		s.send(wsFrame{kind: websocket.TextMessage, data: []byte(fmt.Sprint(i))})
+	}
+	if got := queued(s); len(got) != wsSendQueue || got[0] != "1" {
+		t.Errorf("expected reply 0 dropped, got %v...", got[:2])
+	}
+}
+
+func TestWSSender_SendWaitBlocksUntilRoom(t *testing.T) {
+	s := newWSSender(nil)
+	for i := 0; i < wsSendQueue; i++ {
+		s.send(natsFrame("transit.vehicle.bilbobus.v1", i))
+	}
+
+	queuedReplay := make(chan struct{})
+	go func() {
+		s.sendWait(natsFrame("transit.replay.bilbobus.v1", wsSendQueue))
+		close(queuedReplay)
+	}()
+	select {
+	case <-queuedReplay:
+		t.Fatal("sendWait queued into a full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Drain the queue as run does.
+	s.mu.Lock()
+	s.queue = nil
+	s.room.Broadcast()
+	s.mu.Unlock()
+
+	select {
+	case <-queuedReplay:
+	case <-time.After(time.Second):
+		t.Fatal("sendWait still blocked after the queue was drained")
+	}
+	if got := queued(s); len(got) != 1 || got[0] != fmt.Sprint(wsSendQueue) {
+		t.Errorf("expected only the replayed frame queued, got %v", got)
+	}
+}
+
+func TestWSSender_Stop(t *testing.T) {
+	s := newWSSender(nil)
+	for i := 0; i < wsSendQueue; i++ {
+		s.send(natsFrame("transit.vehicle.bilbobus.v1", i))
+	}
+
+	waiting := make(chan struct{})
+	go func() {
+		s.sendWait(natsFrame("transit.replay.bilbobus.v1", 0))
+		close(waiting)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	s.stop()
+
+	select {
+	case <-waiting:
+	case <-time.After(time.Second):
+		t.Fatal("sendWait still blocked after stop")
+	}
+
+	// Sends after stop return at once and queue nothing; stopping again
+	// is harmless.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.send(natsFrame("transit.vehicle.bilbobus.v1", 1))
+		s.sendWait(natsFrame("transit.replay.bilbobus.v1", 2))
+		s.stop()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("send after stop blocked")
+	}
+	if got := queued(s); len(got) != 0 {
+		t.Errorf("expected nothing queued after stop, got %v", got)
+	}
+	select {
+	case <-s.done:
+	default:
+		t.Error("expected done closed")
+	}
+}
+
+func TestWSMetricSubject(t *testing.T) {
+	for subject, want := range map[string]string{
+		"transit.vehicle.bilbobus.v1":   "transit.vehicle.bilbobus",
+		"transit.alert.metro_bilbao":    "transit.alert.metro_bilbao",
+		"transit.delay.t1":              "transit.delay.t1",
+		"transit.vehicle.bilbobus.v1.x": "transit.vehicle.bilbobus",
+		"transit":                       "transit",
+	} {
+		if got := wsMetricSubject(subject); got != want {
+			t.Errorf("wsMetricSubject(%q) = %q, want %q", subject, got, want)
+		}
+	}
+}
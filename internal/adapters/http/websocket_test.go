@@ -0,0 +1,132 @@
+package http
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	pb "github.com/samirrijal/bilbopass/internal/proto/bilbopassv1"
+)
+
+func TestVehicleFrame(t *testing.T) {
+	at := time.Date(2026, 3, 2, 8, 15, 30, 250e6, time.UTC)
+	occupancy, seq := 2, 14
+
+	for _, tc := range []struct {
+		name string
+		vp   domain.VehiclePosition
+		want *pb.VehiclePosition
+	}{
+		{
+			name: "every field",
+			vp: domain.VehiclePosition{
+				Time: at, VehicleID: "v1", TripID: "t1", RouteID: "L1",
+				Location: domain.GeoPoint{Lat: 43.2630, Lon: -2.9350},
+				Bearing:  90.5, Speed: 8.25, CongestionLevel: 1,
+				OccupancyStatus: &occupancy, StopID: "s7", StopSequence: &seq,
+				Metadata: map[string]any{"agency": "bilbobus", "source": "feed"},
+			},
+			want: &pb.VehiclePosition{
+				VehicleId: "v1", TripId: "t1", RouteId: "L1",
+				Position: &pb.Position{Latitude: 43.2630, Longitude: -2.9350},
+				Bearing:  90.5, Speed: 8.25, CongestionLevel: 1,
+				OccupancyStatus: proto.Int32(2), StopSequence: proto.Int32(14),
+				Agency: "bilbobus", StopId: "s7",
+			},
+		},
+		{
+			name: "occupancy and stop sequence unreported",
+			vp: domain.VehiclePosition{
+				Time: at, VehicleID: "v2",
+				Location: domain.GeoPoint{Lat: 43.25, Lon: -2.92},
+			},
+			want: &pb.VehiclePosition{
+				VehicleId: "v2",
+				Position:  &pb.Position{Latitude: 43.25, Longitude: -2.92},
+			},
+		},
+		{
+			name: "zero occupancy is reported, not unset",
+			vp: domain.VehiclePosition{
+				Time: at, VehicleID: "v3", OccupancyStatus: new(int), StopSequence: new(int),
+			},
+			want: &pb.VehiclePosition{
+				VehicleId: "v3", Position: &pb.Position{},
+				OccupancyStatus: proto.Int32(0), StopSequence: proto.Int32(0),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			published, err := json.Marshal(tc.vp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			frame, err := vehicleFrame(published)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got pb.VehiclePosition
+			if err := proto.Unmarshal(frame, &got); err != nil {
+				t.Fatalf("frame is not a bilbopass.v1.VehiclePosition: %v", err)
+			}
+			if !got.GetTimestamp().AsTime().Equal(at) {
+				t.Errorf("expected timestamp %s, got %s", at, got.GetTimestamp().AsTime())
+			}
+			got.Timestamp = nil
+			if !proto.Equal(&got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, &got)
+			}
+			if (tc.vp.OccupancyStatus == nil) != (got.OccupancyStatus == nil) {
+				t.Errorf("occupancy_status presence: expected set=%t", tc.vp.OccupancyStatus != nil)
+			}
+			if (tc.vp.StopSequence == nil) != (got.StopSequence == nil) {
+				t.Errorf("stop_sequence presence: expected set=%t", tc.vp.StopSequence != nil)
+			}
+		})
+	}
+
+	if _, err := vehicleFrame([]byte("not json")); err == nil {
+		t.Error("expected an error for a message that is not a position")
+	}
+}
+
+func TestWSResumeSince(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 15, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		since   string
+		want    time.Time
+		wantErr bool
+	}{
+		{since: "", want: time.Time{}},
+		{since: "2026-03-02T08:10:00.123456789Z", want: time.Date(2026, 3, 2, 8, 10, 0, 123456789, time.UTC)},
+		{since: "2026-03-02T09:10:00+01:00", want: now.Add(-5 * time.Minute)},
+		{since: "2026-03-02T08:00:00Z", want: now.Add(-wsResumeWindow)}, // exactly the window
+		{since: "2026-03-02T07:59:59Z", want: now.Add(-wsResumeWindow)}, // too old
+		{since: "2026-03-01T08:15:00Z", want: now.Add(-wsResumeWindow)}, // a day old
+		{since: "2026-03-02T08:15:00Z", want: now},                      // now
+		{since: "2026-03-02T08:20:00Z", want: now},                      // client clock ahead
+		{since: "2027-03-02T08:15:00Z", want: now},                      // far future
+		{since: "2026-03-02 08:10:00", wantErr: true},
+		{since: "2026-03-02", wantErr: true},
+		{since: "1772439000", wantErr: true},
+		{since: "yesterday", wantErr: true},
+	} {
+		got, err := wsResumeSince(tc.since, now)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %s", tc.since, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tc.since, err)
+		} else if !got.Equal(tc.want) {
+			t.Errorf("%q: expected %s, got %s", tc.since, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"sync"
+	"time"
+)
+
+// Bounds of the throttle_ms of WebSocket subscriptions.
+const (
+	minWSThrottle = 100 * time.Millisecond
+	maxWSThrottle = time.Minute
+)
+
+// wsThrottle relays at most one message per vehicle every `every`, for
+// clients that cannot use every update. Messages arriving sooner are
+// coalesced: only the latest is kept, and sent once the interval is up.
+// Vehicles are told apart by key, their NATS subject.
+type wsThrottle struct {
+	every time.Duration
+	send  func(key string, data []byte)
+
+	mu       sync.Mutex
+	stopped  bool
+	vehicles map[string]*wsThrottled
+}
+
+// wsThrottled is when a vehicle's last message was sent, and the latest
+// one held back since.
+type wsThrottled struct {
+	sent    time.Time
+	pending []byte
+	timer   *time.Timer
+}
+
+func newWSThrottle(every time.Duration, send func(key string, data []byte)) *wsThrottle {
+	return &wsThrottle{every: every, send: send, vehicles: map[string]*wsThrottled{}}
+}
+
+// offer sends data now if the vehicle's last message was sent at least an
+// interval ago, or else holds it back until then, replacing any message
+// already held back.
+func (t *wsThrottle) offer(key string, data []byte) {
+	now := time.Now()
+	t.mu.Lock()
+	if t.stopped {
+		t.mu.Unlock()
+		return
+	}
+	v, ok := t.vehicles[key]
+	if !ok {
+		// Vehicles idle for an interval are as good as unknown.
+		if len(t.vehicles) >= 1000 {
+			for k, old := range t.vehicles {
+				if old.timer == nil && now.Sub(old.sent) >= t.every {
+					delete(t.vehicles, k)
+				}
+			}
+		}
+		v = &wsThrottled{}
+		t.vehicles[key] = v
+	}
+	if v.timer == nil && now.Sub(v.sent) >= t.every {
+		v.sent = now
+		t.mu.Unlock()
+		t.send(key, data)
+		return
+	}
+	v.pending = data
+	if v.timer == nil {
+		v.timer = time.AfterFunc(v.sent.Add(t.every).Sub(now), func() { t.flush(key) })
+	}
+	t.mu.Unlock()
+}
+
+// flush sends the message held back for a vehicle.
+func (t *wsThrottle) flush(key string) {
+	t.mu.Lock()
+	v, ok := t.vehicles[key]
+	if t.stopped || !ok || v.pending == nil {
+		t.mu.Unlock()
+		return
+	}
+	data := v.pending
+	v.pending, v.timer, v.sent = nil, nil, time.Now()
+	t.mu.Unlock()
+	t.send(key, data)
+}
+
+// stop drops the messages held back; nothing is sent afterwards.
+func (t *wsThrottle) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+	for _, v := range t.vehicles {
+		if v.timer != nil {
+			v.timer.Stop()
+		}
+	}
+	t.vehicles = nil
+}
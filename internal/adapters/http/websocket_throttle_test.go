@@ -0,0 +1,136 @@
+package http
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// throttleRecorder records what a wsThrottle sends, and when.
+type throttleRecorder struct {
+	mu   sync.Mutex
+	sent []throttleSend
+}
+
+type throttleSend struct {
+	key, data string
+	at        time.Time
+}
+
+func (r *throttleRecorder) send(key string, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, throttleSend{key: key, data: string(data), at: time.Now()})
+}
+
+func (r *throttleRecorder) got() []throttleSend {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]throttleSend(nil), r.sent...)
+}
+
+func TestWSThrottle(t *testing.T) {
+	const every = 50 * time.Millisecond
+	type offer struct{ key, data string }
+
+	for _, tc := range []struct {
+		name   string
+		offers []offer // offered at once, well within an interval
+		stop   bool    // stop after the offers
+		want   []string
+	}{
+		{
+			name:   "first update sent at once",
+			offers: []offer{{"v1", "a"}},
+			want:   []string{"v1 a"},
+		},
+		{
+			name:   "updates within the interval coalesced, the last flushed",
+			offers: []offer{{"v1", "a"}, {"v1", "b"}, {"v1", "c"}},
+			want:   []string{"v1 a", "v1 c"},
+		},
+		{
+			name:   "vehicles throttled apart",
+			offers: []offer{{"v1", "a"}, {"v2", "b"}, {"v1", "c"}, {"v2", "d"}},
+			want:   []string{"v1 a", "v2 b", "v1 c", "v2 d"},
+		},
+		{
+			name:   "nothing sent after stop",
+			offers: []offer{{"v1", "a"}, {"v1", "b"}},
+			stop:   true,
+			want:   []string{"v1 a"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := &throttleRecorder{}
+			th := newWSThrottle(every, rec.send)
+			start := time.Now()
+			for _, o := range tc.offers {
+				th.offer(o.key, []byte(o.data))
+			}
+			if tc.stop {
+				th.stop()
+				th.offer("v1", []byte("late"))
+			}
+			time.Sleep(3 * every)
+
+			// Updates of different vehicles may be flushed in either order.
+			got := rec.got()
+			sent, want := map[string][]string{}, map[string][]string{}
+			for _, s := range got {
+				sent[s.key] = append(sent[s.key], s.data)
+			}
+			for _, w := range tc.want {
+				key, data, _ := strings.Cut(w, " ")
+				want[key] = append(want[key], data)
+			}
+			if !reflect.DeepEqual(sent, want) {
+				t.Fatalf("expected %v sent, got %v", want, sent)
+			}
+
+			// Each vehicle's updates are an interval apart; the first
+			// goes at once.
+			last := map[string]time.Time{}
+			for _, s := range got {
+				if prev, ok := last[s.key]; ok {
+					if gap := s.at.Sub(prev); gap < every-5*time.Millisecond {
+						t.Errorf("%s sent %s after the previous update, within %s", s.key, gap, every)
+					}
+				} else if s.at.Sub(start) >= every {
+					t.Errorf("%s's first update was held back %s", s.key, s.at.Sub(start))
+				}
+				last[s.key] = s.at
+			}
+
+			// Flushed or stopped, no timer is left behind.
+			th.mu.Lock()
+			defer th.mu.Unlock()
+			for key, v := range th.vehicles {
+				if v.timer != nil || v.pending != nil {
+					t.Errorf("%s still has an update held back", key)
+				}
+			}
+		})
+	}
+}
+
+func TestWSThrottle_SendsAgainAfterIdleInterval(t *testing.T) {
+	const every = 30 * time.Millisecond
+	rec := &throttleRecorder{}
+	th := newWSThrottle(every, rec.send)
+	defer th.stop()
+
+	th.offer("v1", []byte("a"))
+	time.Sleep(every + 10*time.Millisecond)
+	th.offer("v1", []byte("b")) // an interval later: not held back
+	if got := rec.got(); len(got) != 2 || got[1].data != "b" {
+		t.Fatalf("expected b sent at once, got %+v", got)
+	}
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	if th.vehicles["v1"].timer != nil {
+		t.Error("expected no timer for an update sent at once")
+	}
+}
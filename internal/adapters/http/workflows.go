@@ -0,0 +1,40 @@
+package http
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// ListWorkflowsHandler returns the recent compensation, feed ingest and
+// scheduled job workflow runs, newest first, with the activity and failure
+// of those that did not complete. ?user_id= answers whether a rider got
+// their coupon.
+// GET /v1/admin/workflows?kind=&status=&user_id=&delay_event_id=&agency=&days=7&limit=50
+func ListWorkflowsHandler(deps *Dependencies) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		f := domain.WorkflowFilter{
+			Kind:         c.Query("kind"),
+			Status:       c.Query("status"),
+			UserID:       c.Query("user_id"),
+			DelayEventID: c.Query("delay_event_id"),
+			Agency:       c.Query("agency"),
+			Limit:        c.QueryInt("limit", 0),
+		}
+		runs, err := deps.Workflows.Runs(c.Context(), f, c.QueryInt("days", 0), time.Now())
+		switch {
+		case err == nil:
+			return c.JSON(fiber.Map{"runs": runs})
+		case errors.Is(err, usecases.ErrInvalidWorkflowFilter):
+			return errBadRequest(c, err.Error())
+		case errors.Is(err, usecases.ErrWorkflowsUnavailable):
+			return newError(c, fiber.StatusServiceUnavailable, "unavailable", err.Error())
+		default:
+			return errInternal(c, err.Error())
+		}
+	}
+}
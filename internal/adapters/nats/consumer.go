@@ -0,0 +1,18 @@
+package natsadapter
+
+import (
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// ReplayOptions returns the options of an ordered, ephemeral JetStream
+// consumer, one per subscriber so that each reads the stream whole. It
+// replays the messages published since since, or the latest message of
+// each subject when since is zero, and then follows the stream live.
+func ReplayOptions(since time.Time) []nats.SubOpt {
+	if since.IsZero() {
+		return []nats.SubOpt{nats.OrderedConsumer(), nats.DeliverLastPerSubject()}
+	}
+	return []nats.SubOpt{nats.OrderedConsumer(), nats.StartTime(since)}
+}
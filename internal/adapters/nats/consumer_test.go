@@ -0,0 +1,171 @@
+package natsadapter_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	natsadapter "github.com/samirrijal/bilbopass/internal/adapters/nats"
+)
+
+// fakeJetStream is just enough of a NATS server with JetStream to see the
+// consumer a subscription asks for: it maps every subject to stream and
+// records the configuration of each consumer create request, which it
+// then refuses.
+type fakeJetStream struct {
+	stream string
+
+	mu      sync.Mutex
+	subs    map[string]string // sid to subject
+	created []nats.ConsumerConfig
+}
+
+func newFakeJetStream(t *testing.T, stream string) (*fakeJetStream, *nats.Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	f := &fakeJetStream{stream: stream, subs: map[string]string{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+
+	nc, err := nats.Connect("nats://"+ln.Addr().String(), nats.MaxReconnects(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+	return f, nc
+}
+
+func (f *fakeJetStream) serve(conn net.Conn) {
+	defer conn.Close()
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"fake\",\"version\":\"2.10.0\",\"proto\":1,\"headers\":true,\"jetstream\":true,\"max_payload\":1048576}\r\n")
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			fmt.Fprint(conn, "PONG\r\n")
+		case "SUB":
+			f.mu.Lock()
+			f.subs[args[len(args)-1]] = args[1]
+			f.mu.Unlock()
+		case "UNSUB":
+			f.mu.Lock()
+			delete(f.subs, args[1])
+			f.mu.Unlock()
+		case "PUB":
+			size, _ := strconv.Atoi(args[len(args)-1])
+			payload := make([]byte, size+2) // and CRLF
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			if len(args) == 4 {
+				f.request(conn, args[1], args[2], payload[:size])
+			}
+		}
+	}
+}
+
+func (f *fakeJetStream) request(conn net.Conn, subject, reply string, payload []byte) {
+	var resp string
+	switch {
+	case subject == "$JS.API.STREAM.NAMES":
+		resp = fmt.Sprintf(`{"type":"io.nats.jetstream.api.v1.stream_names_response","total":1,"offset":0,"limit":1024,"streams":[%q]}`, f.stream)
+	case strings.HasPrefix(subject, "$JS.API.CONSUMER.CREATE."+f.stream+"."):
+		var req struct {
+			Config nats.ConsumerConfig `json:"config"`
+		}
+		if err := json.Unmarshal(payload, &req); err == nil {
+			f.mu.Lock()
+			f.created = append(f.created, req.Config)
+			f.mu.Unlock()
+		}
+		resp = `{"type":"io.nats.jetstream.api.v1.consumer_create_response","error":{"code":503,"err_code":10000,"description":"recorded"}}`
+	default:
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for sid, s := range f.subs {
+		if strings.HasSuffix(s, ".*") && strings.HasPrefix(reply, strings.TrimSuffix(s, "*")) || s == reply {
+			fmt.Fprintf(conn, "MSG %s %s %d\r\n%s\r\n", reply, sid, len(resp), resp)
+			return
+		}
+	}
+}
+
+func TestReplayOptions(t *testing.T) {
+	since := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		name      string
+		since     time.Time
+		policy    nats.DeliverPolicy
+		startTime *time.Time
+	}{
+		{"latest of each subject", time.Time{}, nats.DeliverLastPerSubjectPolicy, nil},
+		{"resumed since a time", since, nats.DeliverByStartTimePolicy, &since},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, nc := newFakeJetStream(t, "VEHICLE_POSITIONS")
+			js, err := nc.JetStream(nats.MaxWait(time.Second))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := js.Subscribe("transit.vehicle.>", func(*nats.Msg) {}, natsadapter.ReplayOptions(tc.since)...); err == nil {
+				t.Fatal("expected the fake to refuse the consumer")
+			}
+
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			if len(f.created) != 1 {
+				t.Fatalf("expected one consumer created, got %d", len(f.created))
+			}
+			cfg := f.created[0]
+			if cfg.DeliverPolicy != tc.policy {
+				t.Errorf("expected deliver policy %v, got %v", tc.policy, cfg.DeliverPolicy)
+			}
+			if (cfg.OptStartTime == nil) != (tc.startTime == nil) ||
+				cfg.OptStartTime != nil && !cfg.OptStartTime.Equal(*tc.startTime) {
+				t.Errorf("expected start time %v, got %v", tc.startTime, cfg.OptStartTime)
+			}
+			if cfg.FilterSubject != "transit.vehicle.>" {
+				t.Errorf("expected the consumer filtered to the subscription, got %q", cfg.FilterSubject)
+			}
+
+			// Ordered: ephemeral, unacknowledged and in memory.
+			if cfg.Durable != "" || cfg.AckPolicy != nats.AckNonePolicy || cfg.MaxDeliver != 1 ||
+				!cfg.FlowControl || !cfg.MemoryStorage || cfg.Replicas != 1 {
+				t.Errorf("expected an ordered consumer, got %+v", cfg)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package natsadapter
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// FollowVehiclePositions reads the VEHICLE_POSITIONS stream from the latest
+// position of each vehicle on, without a durable consumer of its own, so
+// every API instance gets them all. apply is called with each position and
+// caughtUp once, when those already in the stream have been applied.
+func FollowVehiclePositions(nc *nats.Conn, apply func(domain.VehiclePosition), caughtUp func()) (*nats.Subscription, error) {
+	js, err := nc.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	var once sync.Once
+	info, err := js.StreamInfo("VEHICLE_POSITIONS")
+	if err != nil {
+		return nil, err
+	}
+	if info.State.Msgs == 0 {
+		once.Do(caughtUp)
+	}
+	return js.Subscribe("transit.vehicle.>", func(msg *nats.Msg) {
+		var vp domain.VehiclePosition
+		if err := json.Unmarshal(msg.Data, &vp); err == nil {
+			apply(vp)
+		}
+		if meta, err := msg.Metadata(); err == nil && meta.NumPending == 0 {
+			once.Do(caughtUp)
+		}
+	}, ReplayOptions(time.Time{})...)
+}
@@ -3,6 +3,7 @@ package natsadapter
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -16,13 +17,10 @@ type Publisher struct {
 	js   nats.JetStreamContext
 }
 
-// NewPublisher connects to NATS and enables JetStream.
-func NewPublisher(url string) (*Publisher, error) {
-	conn, err := nats.Connect(url,
-		nats.RetryOnFailedConnect(true),
-		nats.MaxReconnects(-1),
-		nats.ReconnectWait(2*time.Second),
-	)
+// NewPublisher connects to NATS, with opts such as credentials, and
+// enables JetStream.
+func NewPublisher(url string, opts ...nats.Option) (*Publisher, error) {
+	conn, err := nats.Connect(url, connectOptions(opts)...)
 	if err != nil {
 		return nil, fmt.Errorf("nats connect: %w", err)
 	}
@@ -32,12 +30,13 @@ func NewPublisher(url string) (*Publisher, error) {
 		return nil, fmt.Errorf("jetstream: %w", err)
 	}
 
-	// Ensure streams exist
+	// Ensure streams exist. Vehicle positions are kept for their hour
+	// whether consumed or not, so WebSocket clients can replay them.
 	streams := []nats.StreamConfig{
 		{
 			Name:      "VEHICLE_POSITIONS",
 			Subjects:  []string{"transit.vehicle.>"},
-			Retention: nats.WorkQueuePolicy,
+			Retention: nats.LimitsPolicy,
 			MaxAge:    1 * time.Hour,
 			Storage:   nats.FileStorage,
 		},
@@ -57,6 +56,14 @@ func NewPublisher(url string) (*Publisher, error) {
 		},
 	}
 
+	// It was a work queue, whose retention cannot be updated: recreate
+	// it. The positions lost are at most an hour's, also in Postgres.
+	if info, err := js.StreamInfo("VEHICLE_POSITIONS"); err == nil && info.Config.Retention == nats.WorkQueuePolicy {
+		if err := js.DeleteStream("VEHICLE_POSITIONS"); err != nil && !errors.Is(err, nats.ErrStreamNotFound) {
+			return nil, fmt.Errorf("recreate stream VEHICLE_POSITIONS: %w", err)
+		}
+	}
+
 	for _, cfg := range streams {
 		if _, err := js.AddStream(&cfg); err != nil {
 			// Stream may already exist — try update
@@ -69,29 +76,62 @@ func NewPublisher(url string) (*Publisher, error) {
 	return &Publisher{conn: conn, js: js}, nil
 }
 
+// PublishVehiclePosition publishes to transit.vehicle.<agency>.<vehicle_id>,
+// the agency slug coming from the position's metadata, so WebSocket clients
+// can follow one agency.
 func (p *Publisher) PublishVehiclePosition(ctx context.Context, vp *domain.VehiclePosition) error {
 	data, err := json.Marshal(vp)
 	if err != nil {
 		return err
 	}
-	_, err = p.js.Publish("transit.vehicle."+vp.VehicleID, data)
+	subject := "transit.vehicle." + vp.VehicleID
+	if agency, _ := vp.Metadata["agency"].(string); agency != "" {
+		subject = "transit.vehicle." + agency + "." + vp.VehicleID
+	}
+	_, err = p.js.Publish(subject, data)
 	return err
 }
 
+// PublishDelayEvent publishes to DelayEventSubject, in the TRANSIT_DELAYS
+// work queue the compensator consumes.
 func (p *Publisher) PublishDelayEvent(ctx context.Context, event *domain.DelayEvent) error {
 	data, err := json.Marshal(event)
 	if err != nil {
 		return err
 	}
-	_, err = p.js.Publish("transit.delay."+event.TripID, data)
+	_, err = p.js.Publish(DelayEventSubject(event.TripID), data)
 	return err
 }
 
+// DelayEventSubject is the subject of delay events of a trip:
+// transit.delay.<trip_id>, by trip UUID.
+func DelayEventSubject(tripID string) string {
+	return "transit.delay." + tripID
+}
+
 func (p *Publisher) PublishDetourAlert(ctx context.Context, tripID string) error {
 	_, err := p.js.Publish("transit.alerts.detour", []byte(tripID))
 	return err
 }
 
+func (p *Publisher) PublishSLABreach(ctx context.Context, result *domain.SLAResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	_, err = p.js.Publish("transit.alerts.sla."+result.Contract.AgencyID, data)
+	return err
+}
+
+func (p *Publisher) PublishDelayAnomaly(ctx context.Context, anomaly *domain.DelayAnomaly) error {
+	data, err := json.Marshal(anomaly)
+	if err != nil {
+		return err
+	}
+	_, err = p.js.Publish("transit.alerts.anomaly."+anomaly.AgencyID, data)
+	return err
+}
+
 func (p *Publisher) PublishBroadcast(ctx context.Context, data []byte) error {
 	return p.conn.Publish("transit.updates.broadcast", data)
 }
@@ -102,10 +142,15 @@ func (p *Publisher) Close() {
 }
 
 // RawConn creates a plain NATS connection for subscribing (e.g. WebSocket relay).
-func RawConn(url string) (*nats.Conn, error) {
-	return nats.Connect(url,
+func RawConn(url string, opts ...nats.Option) (*nats.Conn, error) {
+	return nats.Connect(url, connectOptions(opts)...)
+}
+
+// connectOptions adds opts to the reconnection options all connections share.
+func connectOptions(opts []nats.Option) []nats.Option {
+	return append([]nats.Option{
 		nats.RetryOnFailedConnect(true),
 		nats.MaxReconnects(-1),
-		nats.ReconnectWait(2*time.Second),
-	)
+		nats.ReconnectWait(2 * time.Second),
+	}, opts...)
 }
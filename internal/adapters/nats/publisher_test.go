@@ -0,0 +1,15 @@
+package natsadapter_test
+
+import (
+	"testing"
+
+	natsadapter "github.com/samirrijal/bilbopass/internal/adapters/nats"
+)
+
+func TestDelayEventSubject(t *testing.T) {
+	tripID := "6f1c2a9e-3b8d-4c1e-9a57-2d0e4b7c8f31"
+	got := natsadapter.DelayEventSubject(tripID)
+	if got != "transit.delay."+tripID {
+		t.Errorf("expected transit.delay.%s, got %s", tripID, got)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/samirrijal/bilbopass/internal/core/domain"
@@ -17,13 +16,10 @@ type Subscriber struct {
 	subs []*nats.Subscription
 }
 
-// NewSubscriber creates a subscriber sharing a NATS connection.
-func NewSubscriber(url string) (*Subscriber, error) {
-	conn, err := nats.Connect(url,
-		nats.RetryOnFailedConnect(true),
-		nats.MaxReconnects(-1),
-		nats.ReconnectWait(2*time.Second),
-	)
+// NewSubscriber creates a subscriber sharing a NATS connection; opts are
+// added to its connection options, e.g. credentials.
+func NewSubscriber(url string, opts ...nats.Option) (*Subscriber, error) {
+	conn, err := nats.Connect(url, connectOptions(opts)...)
 	if err != nil {
 		return nil, fmt.Errorf("nats connect: %w", err)
 	}
@@ -0,0 +1,184 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fcmScope    = "https://www.googleapis.com/auth/firebase.messaging"
+	fcmSendURL  = "https://fcm.googleapis.com/v1/projects/%s/messages:send"
+	fcmTokenTTL = time.Hour
+)
+
+// serviceAccount is the subset of a Google service account key file FCM needs.
+type serviceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// FCM sends notifications through the Firebase Cloud Messaging HTTP v1 API,
+// authenticating as a service account.
+type FCM struct {
+	client  *http.Client
+	account serviceAccount
+	key     *rsa.PrivateKey
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewFCM loads a Firebase service account key file.
+func NewFCM(credentialsFile string, client *http.Client) (*FCM, error) {
+	data, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	var sa serviceAccount
+	if err := json.Unmarshal(data, &sa); err != nil {
+		return nil, fmt.Errorf("parse credentials: %w", err)
+	}
+	if sa.ProjectID == "" || sa.ClientEmail == "" || sa.TokenURI == "" {
+		return nil, fmt.Errorf("credentials need project_id, client_email and token_uri")
+	}
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("credentials: private_key is not PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("credentials: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("credentials: private_key is not RSA")
+	}
+	return &FCM{client: client, account: sa, key: key}, nil
+}
+
+// Send delivers a notification to one FCM registration token.
+func (f *FCM) Send(ctx context.Context, token, title, body string) error {
+	accessToken, err := f.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"message": map[string]any{
+			"token":        token,
+			"notification": map[string]string{"title": title, "body": body},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf(fcmSendURL, f.account.ProjectID), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return requestError("fcm", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		f.mu.Lock()
+		f.token = "" // fetch a fresh access token next time
+		f.mu.Unlock()
+	}
+	if resp.StatusCode >= 300 {
+		return responseError("fcm", resp)
+	}
+	return nil
+}
+
+// accessToken returns a cached OAuth2 access token, exchanging a signed
+// service account assertion for a new one when it is about to expire.
+func (f *FCM) accessToken(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	if f.token != "" && now.Before(f.expires.Add(-time.Minute)) {
+		return f.token, nil
+	}
+
+	assertion, err := f.assertion(now)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", requestError("fcm token", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", responseError("fcm token", resp)
+	}
+
+	var out struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || out.AccessToken == "" {
+		return "", requestError("fcm token", fmt.Errorf("bad token response: %v", err))
+	}
+	f.token = out.AccessToken
+	f.expires = now.Add(time.Duration(out.ExpiresIn) * time.Second)
+	return f.token, nil
+}
+
+// assertion returns an RS256 JWT asserting the service account's identity.
+func (f *FCM) assertion(now time.Time) (string, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		"iss":   f.account.ClientEmail,
+		"scope": fcmScope,
+		"aud":   f.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(fcmTokenTTL).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
@@ -0,0 +1,170 @@
+// Package notifications delivers push notifications to riders' registered
+// devices through Firebase Cloud Messaging and Web Push.
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+// errGone means the push service no longer accepts a device's token.
+var errGone = errors.New("device token no longer valid")
+
+// Pusher implements ports.NotificationService by sending to every device the
+// user has registered, as their notification preferences allow.
+type Pusher struct {
+	devices     ports.DeviceRepository
+	preferences ports.NotificationPreferenceRepository
+	fcm         *FCM
+	webpush     *WebPush
+}
+
+// New creates a Pusher with FCM enabled when fcmCredentialsFile is set and Web
+// Push enabled when a VAPID key pair is set.
+func New(devices ports.DeviceRepository, preferences ports.NotificationPreferenceRepository,
+	fcmCredentialsFile, vapidPublicKey, vapidPrivateKey, vapidSubject string) (*Pusher, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	p := &Pusher{devices: devices, preferences: preferences}
+	if fcmCredentialsFile != "" {
+		fcm, err := NewFCM(fcmCredentialsFile, client)
+		if err != nil {
+			return nil, fmt.Errorf("fcm: %w", err)
+		}
+		p.fcm = fcm
+	}
+	if vapidPublicKey != "" || vapidPrivateKey != "" {
+		wp, err := NewWebPush(vapidPublicKey, vapidPrivateKey, vapidSubject, client)
+		if err != nil {
+			return nil, fmt.Errorf("webpush: %w", err)
+		}
+		p.webpush = wp
+	}
+	return p, nil
+}
+
+// NewPusher creates a Pusher. fcm or webpush may be nil to disable that
+// platform; devices on it are then skipped.
+func NewPusher(devices ports.DeviceRepository, preferences ports.NotificationPreferenceRepository, fcm *FCM, webpush *WebPush) *Pusher {
+	return &Pusher{devices: devices, preferences: preferences, fcm: fcm, webpush: webpush}
+}
+
+// SendPush delivers the notification to all of the user's devices on
+// configured platforms and channels they enabled. It succeeds if any device
+// received it. Tokens the push service rejects as unknown are removed. It
+// returns ports.ErrPushMuted when the user turned the category off, it is
+// their quiet hours, or all their devices are on channels they turned off.
+// When nothing was delivered otherwise it returns a temporary
+// *ports.PushError if any device may succeed on retry, and
+// ports.ErrNoDevices if there was no device to try.
+func (p *Pusher) SendPush(ctx context.Context, userID, category, title, body string) error {
+	prefs, err := p.preferences.Get(ctx, userID)
+	if err != nil {
+		return &ports.PushError{Temporary: true, Err: fmt.Errorf("load preferences: %w", err)}
+	}
+	if prefs == nil {
+		prefs = domain.DefaultNotificationPreferences()
+	}
+	if !prefs.Category(category) || prefs.Quiet(time.Now()) {
+		return ports.ErrPushMuted
+	}
+
+	devices, err := p.devices.ListByUser(ctx, userID)
+	if err != nil {
+		return &ports.PushError{Temporary: true, Err: fmt.Errorf("list devices: %w", err)}
+	}
+
+	var sent, muted int
+	var lastErr, tempErr error
+	for i := range devices {
+		d := &devices[i]
+		if !p.supports(d.Platform) {
+			continue
+		}
+		if !prefs.Channel(d.Platform) {
+			muted++
+			continue
+		}
+		err := p.send(ctx, d, title, body)
+		var pe *ports.PushError
+		switch {
+		case err == nil:
+			sent++
+		case errors.Is(err, errGone):
+			if err := p.devices.DeleteByToken(ctx, d.Token); err != nil {
+				log.Printf("push: forget device %s: %v", d.ID, err)
+			}
+		case errors.As(err, &pe) && pe.Temporary:
+			tempErr = err
+		default:
+			lastErr = err
+			log.Printf("push: device %s (%s): %v", d.ID, d.Platform, err)
+		}
+	}
+
+	switch {
+	case sent > 0:
+		return nil
+	case tempErr != nil:
+		return tempErr
+	case lastErr != nil:
+		return &ports.PushError{Err: lastErr}
+	case muted > 0:
+		return ports.ErrPushMuted
+	default:
+		return ports.ErrNoDevices
+	}
+}
+
+// VAPIDKey returns the Web Push application server key, or "" when Web Push
+// is off.
+func (p *Pusher) VAPIDKey() string {
+	if p.webpush == nil {
+		return ""
+	}
+	return p.webpush.PublicKey()
+}
+
+func (p *Pusher) supports(platform string) bool {
+	switch platform {
+	case domain.DevicePlatformFCM:
+		return p.fcm != nil
+	case domain.DevicePlatformWebPush:
+		return p.webpush != nil
+	}
+	return false
+}
+
+func (p *Pusher) send(ctx context.Context, d *domain.Device, title, body string) error {
+	if d.Platform == domain.DevicePlatformFCM {
+		return p.fcm.Send(ctx, d.Token, title, body)
+	}
+	return p.webpush.Send(ctx, d, title, body)
+}
+
+// responseError classifies a push service's non-2xx response: unknown
+// tokens are gone, rate limits and server errors are temporary.
+func responseError(service string, resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err := fmt.Errorf("%s: HTTP %d: %s", service, resp.StatusCode, msg)
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return fmt.Errorf("%w: %v", errGone, err)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return &ports.PushError{Temporary: true, Err: err}
+	default:
+		return &ports.PushError{Err: err}
+	}
+}
+
+// requestError wraps a failed round trip; the network may recover.
+func requestError(service string, err error) error {
+	return &ports.PushError{Temporary: true, Err: fmt.Errorf("%s: %w", service, err)}
+}
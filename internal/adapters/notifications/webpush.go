@@ -0,0 +1,229 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+const (
+	// webPushTTL is how long a push service keeps an undelivered message.
+	webPushTTL = 24 * time.Hour
+	// vapidTTL is the lifetime of VAPID tokens (at most 24h per RFC 8292).
+	vapidTTL = 12 * time.Hour
+	// recordSize is the aes128gcm record size; payloads fit in one record.
+	recordSize = 4096
+)
+
+// WebPush sends notifications to browser push subscriptions (RFC 8030),
+// encrypting payloads per RFC 8291 and identifying the server with VAPID
+// (RFC 8292).
+type WebPush struct {
+	client    *http.Client
+	key       *ecdsa.PrivateKey
+	publicKey string // base64url uncompressed point, sent as the VAPID k parameter
+	subject   string
+}
+
+// NewWebPush creates a WebPush sender from a base64url VAPID key pair, as
+// generated by the common web-push tools. subject is a mailto: or https:
+// contact for push services.
+func NewWebPush(publicKey, privateKey, subject string, client *http.Client) (*WebPush, error) {
+	d, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(privateKey, "="))
+	if err != nil {
+		return nil, fmt.Errorf("vapid private key: %w", err)
+	}
+	priv, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		return nil, fmt.Errorf("vapid private key: %w", err)
+	}
+	pub := priv.PublicKey().Bytes()
+	if base64.RawURLEncoding.EncodeToString(pub) != strings.TrimRight(publicKey, "=") {
+		return nil, fmt.Errorf("vapid public key does not match private key")
+	}
+	if !strings.HasPrefix(subject, "mailto:") && !strings.HasPrefix(subject, "https://") {
+		return nil, fmt.Errorf("vapid subject must be a mailto: or https: URL")
+	}
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(pub[1:33]),
+			Y:     new(big.Int).SetBytes(pub[33:]),
+		},
+		D: new(big.Int).SetBytes(d),
+	}
+	return &WebPush{
+		client:    client,
+		key:       key,
+		publicKey: base64.RawURLEncoding.EncodeToString(pub),
+		subject:   subject,
+	}, nil
+}
+
+// PublicKey returns the VAPID application server key browsers subscribe with.
+func (w *WebPush) PublicKey() string {
+	return w.publicKey
+}
+
+// Send delivers a notification to one push subscription. The payload is
+// JSON with title and body for the service worker to display.
+func (w *WebPush) Send(ctx context.Context, d *domain.Device, title, body string) error {
+	payload, err := json.Marshal(map[string]string{"title": title, "body": body})
+	if err != nil {
+		return err
+	}
+	msg, err := encrypt(payload, d.P256dh, d.Auth)
+	if err != nil {
+		return fmt.Errorf("webpush: %w", err)
+	}
+	auth, err := w.vapid(d.Token, time.Now())
+	if err != nil {
+		return fmt.Errorf("webpush: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Token, bytes.NewReader(msg))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(webPushTTL.Seconds())))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return requestError("webpush", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return responseError("webpush", resp)
+	}
+	return nil
+}
+
+// vapid returns the Authorization header for a push service endpoint.
+func (w *WebPush) vapid(endpoint string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, err := json.Marshal(map[string]any{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(vapidTTL).Unix(),
+		"sub": w.subject,
+	})
+	if err != nil {
+		return "", err
+	}
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, w.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return "vapid t=" + signed + "." + base64.RawURLEncoding.EncodeToString(sig) + ", k=" + w.publicKey, nil
+}
+
+// encrypt encrypts payload for a subscription's keys with the aes128gcm
+// content coding (RFC 8291, RFC 8188), as a single record.
+func encrypt(payload []byte, p256dh, authSecret string) ([]byte, error) {
+	uaBytes, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(p256dh, "="))
+	if err != nil {
+		return nil, fmt.Errorf("p256dh: %w", err)
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaBytes)
+	if err != nil {
+		return nil, fmt.Errorf("p256dh: %w", err)
+	}
+	auth, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(authSecret, "="))
+	if err != nil {
+		return nil, fmt.Errorf("auth: %w", err)
+	}
+
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return seal(payload, uaPublic, auth, asPrivate, salt)
+}
+
+// seal encrypts payload with the given ephemeral key and salt.
+func seal(payload []byte, uaPublic *ecdh.PublicKey, auth []byte, asPrivate *ecdh.PrivateKey, salt []byte) ([]byte, error) {
+	uaBytes := uaPublic.Bytes()
+	asPublic := asPrivate.PublicKey().Bytes()
+	shared, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	// IKM = HKDF(auth, ecdh_secret, "WebPush: info" || 0x00 || ua_public || as_public)
+	prkKey, err := hkdf.Extract(sha256.New, shared, auth)
+	if err != nil {
+		return nil, err
+	}
+	keyInfo := "WebPush: info\x00" + string(uaBytes) + string(asPublic)
+	ikm, err := hkdf.Expand(sha256.New, prkKey, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := append(append([]byte{}, payload...), 0x02) // last record delimiter
+	if len(plaintext)+gcm.Overhead() > recordSize {
+		return nil, fmt.Errorf("payload too large")
+	}
+
+	// Header: salt || record size || key id length || key id (as_public)
+	out := make([]byte, 0, 16+4+1+len(asPublic)+len(plaintext)+gcm.Overhead())
+	out = append(out, salt...)
+	out = binary.BigEndian.AppendUint32(out, recordSize)
+	out = append(out, byte(len(asPublic)))
+	out = append(out, asPublic...)
+	return gcm.Seal(out, nonce, plaintext, nil), nil
+}
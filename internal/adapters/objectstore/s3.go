@@ -0,0 +1,135 @@
+// Package objectstore stores public assets in S3-compatible object storage
+// (AWS S3, MinIO, Cloudflare R2, ...).
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3 uploads objects with path-style requests signed with AWS Signature
+// Version 4.
+type S3 struct {
+	client    *http.Client
+	endpoint  *url.URL // e.g. https://s3.eu-south-2.amazonaws.com
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	publicURL string // base URL objects are served from
+}
+
+// NewS3 creates an S3 client. publicURL is where clients fetch objects
+// (a CDN, or a public bucket); it defaults to endpoint/bucket.
+func NewS3(endpoint, bucket, region, accessKey, secretKey, publicURL string) (*S3, error) {
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("storage endpoint must be an http(s) URL")
+	}
+	if bucket == "" || accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("storage needs a bucket, access key and secret key")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	if publicURL == "" {
+		publicURL = u.String() + "/" + bucket
+	}
+	return &S3{
+		client:    &http.Client{Timeout: 30 * time.Second},
+		endpoint:  u,
+		bucket:    bucket,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+	}, nil
+}
+
+// Put uploads body under key. Keys are expected to change with the content,
+// so objects are served as immutable.
+func (s *S3) Put(ctx context.Context, key, contentType string, body []byte) (string, error) {
+	path := "/" + s.bucket + "/" + escapeKey(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.endpoint.String()+path, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	s.sign(req, path, body, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("storage returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return s.publicURL + "/" + escapeKey(key), nil
+}
+
+// sign adds SigV4 headers to req. Only host and the x-amz headers are
+// signed; the rest may be changed by proxies without breaking the request.
+func (s *S3) sign(req *http.Request, path string, body []byte, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		"", // query
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	for _, part := range []string{s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+// escapeKey URI-encodes each segment of an object key.
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// AccessibleSpaceRepo implements ports.AccessibleSpaceRepository.
+type AccessibleSpaceRepo struct {
+	db *DB
+}
+
+func NewAccessibleSpaceRepo(db *DB) *AccessibleSpaceRepo {
+	return &AccessibleSpaceRepo{db: db}
+}
+
+func (r *AccessibleSpaceRepo) Insert(ctx context.Context, rep *domain.AccessibleSpaceReport) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO accessible_space_reports (time, trip_id, vehicle_id, source, user_id, wheelchair, stroller)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, rep.Time, rep.TripID, nilIfEmpty(rep.VehicleID), rep.Source, nilIfEmpty(rep.UserID),
+		nilIfEmpty(rep.Wheelchair), nilIfEmpty(rep.Stroller))
+	return err
+}
+
+func (r *AccessibleSpaceRepo) RecentByTrips(ctx context.Context, tripIDs []string, since time.Time) ([]domain.AccessibleSpaceReport, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT time, trip_id, COALESCE(vehicle_id, ''), source, COALESCE(user_id::text, ''),
+		       COALESCE(wheelchair, ''), COALESCE(stroller, '')
+		FROM accessible_space_reports
+		WHERE trip_id = ANY($1::uuid[]) AND time > $2
+		ORDER BY time DESC
+	`, tripIDs, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.AccessibleSpaceReport
+	for rows.Next() {
+		var rep domain.AccessibleSpaceReport
+		if err := rows.Scan(&rep.Time, &rep.TripID, &rep.VehicleID, &rep.Source, &rep.UserID,
+			&rep.Wheelchair, &rep.Stroller); err != nil {
+			return nil, err
+		}
+		out = append(out, rep)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,127 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// AffiliateRepo implements ports.AffiliateRepository.
+type AffiliateRepo struct {
+	db *DB
+}
+
+func NewAffiliateRepo(db *DB) *AffiliateRepo {
+	return &AffiliateRepo{db: db}
+}
+
+const affiliateColumns = `id, name, category,
+	ST_Y(location::geometry), ST_X(location::geometry),
+	COALESCE(address, ''), offer_text, COALESCE(offer_value, 0)::float8,
+	COALESCE(active, false), created_at, COALESCE(updated_at, created_at)`
+
+// FindNearby returns active affiliates within radiusMeters using PostGIS ST_DWithin.
+func (r *AffiliateRepo) FindNearby(ctx context.Context, lat, lon, radiusMeters float64, limit int) ([]domain.Affiliate, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+affiliateColumns+`,
+		       ST_Distance(location, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography) AS distance
+		FROM affiliates
+		WHERE active AND ST_DWithin(location, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography, $3)
+		ORDER BY distance
+		LIMIT $4
+	`, lon, lat, radiusMeters, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.Affiliate
+	for rows.Next() {
+		var a domain.Affiliate
+		var dist float64
+		if err := rows.Scan(affiliateFields(&a, &dist)...); err != nil {
+			return nil, err
+		}
+		a.Distance = &dist
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+func (r *AffiliateRepo) GetByID(ctx context.Context, id string) (*domain.Affiliate, error) {
+	var a domain.Affiliate
+	err := r.db.Pool.QueryRow(ctx, `SELECT `+affiliateColumns+` FROM affiliates WHERE id = $1`, id).
+		Scan(affiliateFields(&a)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *AffiliateRepo) List(ctx context.Context) ([]domain.Affiliate, error) {
+	rows, err := r.db.Pool.Query(ctx, `SELECT `+affiliateColumns+` FROM affiliates ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.Affiliate
+	for rows.Next() {
+		var a domain.Affiliate
+		if err := rows.Scan(affiliateFields(&a)...); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+func (r *AffiliateRepo) Create(ctx context.Context, a *domain.Affiliate) error {
+	return r.db.Pool.QueryRow(ctx, `
+		INSERT INTO affiliates (name, category, location, address, offer_text, offer_value, active)
+		VALUES ($1, $2, ST_SetSRID(ST_MakePoint($3, $4), 4326)::geography, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at
+	`, a.Name, a.Category, a.Location.Lon, a.Location.Lat, nilIfEmpty(a.Address),
+		a.OfferText, a.OfferValue, a.Active).Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt)
+}
+
+func (r *AffiliateRepo) Update(ctx context.Context, a *domain.Affiliate) (bool, error) {
+	err := r.db.Pool.QueryRow(ctx, `
+		UPDATE affiliates
+		SET name = $2, category = $3, location = ST_SetSRID(ST_MakePoint($4, $5), 4326)::geography,
+		    address = $6, offer_text = $7, offer_value = $8, active = $9, updated_at = NOW()
+		WHERE id = $1
+		RETURNING created_at, updated_at
+	`, a.ID, a.Name, a.Category, a.Location.Lon, a.Location.Lat, nilIfEmpty(a.Address),
+		a.OfferText, a.OfferValue, a.Active).Scan(&a.CreatedAt, &a.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Deactivate hides an affiliate from riders and new coupons. Issued coupons
+// keep referring to it.
+func (r *AffiliateRepo) Deactivate(ctx context.Context, id string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		UPDATE affiliates SET active = false, updated_at = NOW() WHERE id = $1
+	`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// affiliateFields returns scan targets matching affiliateColumns, plus extra.
+func affiliateFields(a *domain.Affiliate, extra ...any) []any {
+	return append([]any{
+		&a.ID, &a.Name, &a.Category, &a.Location.Lat, &a.Location.Lon,
+		&a.Address, &a.OfferText, &a.OfferValue, &a.Active, &a.CreatedAt, &a.UpdatedAt,
+	}, extra...)
+}
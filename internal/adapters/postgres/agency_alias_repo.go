@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// AgencyAliasRepo implements ports.AgencyAliasRepository.
+type AgencyAliasRepo struct {
+	db *DB
+}
+
+func NewAgencyAliasRepo(db *DB) *AgencyAliasRepo {
+	return &AgencyAliasRepo{db: db}
+}
+
+func (r *AgencyAliasRepo) ListByAgency(ctx context.Context, agencyID string) ([]domain.AgencyAlias, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT agency_id, source_slug, source_agency_id, created_at
+		FROM agency_aliases
+		WHERE agency_id = $1
+		ORDER BY source_slug, source_agency_id
+	`, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.AgencyAlias
+	for rows.Next() {
+		var a domain.AgencyAlias
+		if err := rows.Scan(&a.AgencyID, &a.SourceSlug, &a.SourceAgencyID, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+func (r *AgencyAliasRepo) Create(ctx context.Context, a *domain.AgencyAlias) (bool, error) {
+	err := r.db.Pool.QueryRow(ctx, `
+		INSERT INTO agency_aliases (source_slug, source_agency_id, agency_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (source_slug, source_agency_id) DO NOTHING
+		RETURNING created_at
+	`, a.SourceSlug, a.SourceAgencyID, a.AgencyID).Scan(&a.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *AgencyAliasRepo) Delete(ctx context.Context, agencyID, sourceSlug, sourceAgencyID string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		DELETE FROM agency_aliases
+		WHERE agency_id = $1 AND source_slug = $2 AND source_agency_id = $3
+	`, agencyID, sourceSlug, sourceAgencyID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
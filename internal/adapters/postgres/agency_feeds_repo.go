@@ -0,0 +1,158 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// AgencyFeedsRepo implements ports.AgencyFeedsRepository.
+type AgencyFeedsRepo struct {
+	db *DB
+}
+
+func NewAgencyFeedsRepo(db *DB) *AgencyFeedsRepo {
+	return &AgencyFeedsRepo{db: db}
+}
+
+const agencyFeedsColumns = `
+	a.id, a.slug, a.name, f.gtfs_url,
+	COALESCE(f.vehicle_positions_url, ''), COALESCE(f.trip_updates_url, ''), COALESCE(f.alerts_url, ''),
+	COALESCE(f.poll_interval, 0), f.vehicle_push, f.created_at, f.updated_at`
+
+func scanAgencyFeeds(row pgx.Row) (*domain.AgencyFeeds, error) {
+	var f domain.AgencyFeeds
+	var rt domain.GTFSRTFeeds
+	err := row.Scan(&f.AgencyID, &f.Slug, &f.Name, &f.GTFSURL,
+		&rt.VehiclePositions, &rt.TripUpdates, &rt.Alerts,
+		&rt.PollInterval, &rt.VehiclePush, &f.CreatedAt, &f.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rt != (domain.GTFSRTFeeds{}) {
+		f.GTFSRT = &rt
+	}
+	return &f, nil
+}
+
+func (r *AgencyFeedsRepo) List(ctx context.Context) ([]domain.AgencyFeeds, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+agencyFeedsColumns+`
+		FROM agency_feeds f JOIN agencies a ON a.id = f.agency_id
+		ORDER BY a.slug
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.AgencyFeeds
+	for rows.Next() {
+		f, err := scanAgencyFeeds(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *f)
+	}
+	return out, rows.Err()
+}
+
+func (r *AgencyFeedsRepo) GetBySlug(ctx context.Context, slug string) (*domain.AgencyFeeds, error) {
+	return scanAgencyFeeds(r.db.Pool.QueryRow(ctx, `
+		SELECT `+agencyFeedsColumns+`
+		FROM agency_feeds f JOIN agencies a ON a.id = f.agency_id
+		WHERE a.slug = $1
+	`, slug))
+}
+
+// rtArgs returns the GTFS-RT columns of f, NULL when unset.
+func rtArgs(f *domain.AgencyFeeds) (vehiclePositions, tripUpdates, alerts, pollInterval interface{}, push bool) {
+	if f.GTFSRT == nil {
+		return nil, nil, nil, nil, false
+	}
+	rt := f.GTFSRT
+	if rt.PollInterval > 0 {
+		pollInterval = rt.PollInterval
+	}
+	return nilIfEmpty(rt.VehiclePositions), nilIfEmpty(rt.TripUpdates), nilIfEmpty(rt.Alerts), pollInterval, rt.VehiclePush
+}
+
+func (r *AgencyFeedsRepo) Create(ctx context.Context, f *domain.AgencyFeeds) (bool, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Agencies ingested before their feeds were managed here keep their ID
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO agencies (slug, name, url, timezone)
+		VALUES ($1, $2, $3, 'Europe/Madrid')
+		ON CONFLICT (slug) DO UPDATE SET name = EXCLUDED.name, url = EXCLUDED.url
+		RETURNING id
+	`, f.Slug, f.Name, f.GTFSURL).Scan(&f.AgencyID); err != nil {
+		return false, err
+	}
+	vp, tu, alerts, interval, push := rtArgs(f)
+	err = tx.QueryRow(ctx, `
+		INSERT INTO agency_feeds (agency_id, gtfs_url, vehicle_positions_url, trip_updates_url, alerts_url,
+		                          poll_interval, vehicle_push)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (agency_id) DO NOTHING
+		RETURNING created_at, updated_at
+	`, f.AgencyID, f.GTFSURL, vp, tu, alerts, interval, push).Scan(&f.CreatedAt, &f.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+func (r *AgencyFeedsRepo) Update(ctx context.Context, f *domain.AgencyFeeds) (bool, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	vp, tu, alerts, interval, push := rtArgs(f)
+	err = tx.QueryRow(ctx, `
+		UPDATE agency_feeds f
+		SET gtfs_url = $2, vehicle_positions_url = $3, trip_updates_url = $4, alerts_url = $5,
+		    poll_interval = $6, vehicle_push = $7, updated_at = NOW()
+		FROM agencies a
+		WHERE a.id = f.agency_id AND a.slug = $1
+		RETURNING f.agency_id, f.created_at, f.updated_at
+	`, f.Slug, f.GTFSURL, vp, tu, alerts, interval, push).Scan(&f.AgencyID, &f.CreatedAt, &f.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE agencies SET name = $2, url = $3 WHERE id = $1
+	`, f.AgencyID, f.Name, f.GTFSURL); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+func (r *AgencyFeedsRepo) Delete(ctx context.Context, slug string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		DELETE FROM agency_feeds f USING agencies a
+		WHERE a.id = f.agency_id AND a.slug = $1
+	`, slug)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
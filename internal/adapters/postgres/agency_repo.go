@@ -29,9 +29,12 @@ func (r *AgencyRepo) GetBySlug(ctx context.Context, slug string) (*domain.Agency
 	a := &domain.Agency{}
 	var urlVal sql.NullString
 	err := r.db.Pool.QueryRow(ctx, `
-		SELECT id, slug, name, COALESCE(url, ''), timezone, created_at
+		SELECT id, slug, name, COALESCE(url, ''), timezone,
+		       COALESCE(contact_phone, ''), COALESCE(lost_found_url, ''), COALESCE(complaint_url, ''),
+		       created_at, updated_at
 		FROM agencies WHERE slug = $1
-	`, slug).Scan(&a.ID, &a.Slug, &a.Name, &urlVal, &a.Timezone, &a.CreatedAt)
+	`, slug).Scan(&a.ID, &a.Slug, &a.Name, &urlVal, &a.Timezone,
+		&a.Contact.Phone, &a.Contact.LostFoundURL, &a.Contact.ComplaintURL, &a.CreatedAt, &a.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -41,7 +44,9 @@ func (r *AgencyRepo) GetBySlug(ctx context.Context, slug string) (*domain.Agency
 
 func (r *AgencyRepo) List(ctx context.Context) ([]domain.Agency, error) {
 	rows, err := r.db.Pool.Query(ctx, `
-		SELECT id, slug, name, COALESCE(url, ''), timezone, created_at
+		SELECT id, slug, name, COALESCE(url, ''), timezone,
+		       COALESCE(contact_phone, ''), COALESCE(lost_found_url, ''), COALESCE(complaint_url, ''),
+		       created_at, updated_at
 		FROM agencies ORDER BY name
 	`)
 	if err != nil {
@@ -52,10 +57,52 @@ func (r *AgencyRepo) List(ctx context.Context) ([]domain.Agency, error) {
 	var agencies []domain.Agency
 	for rows.Next() {
 		var a domain.Agency
-		if err := rows.Scan(&a.ID, &a.Slug, &a.Name, &a.URL, &a.Timezone, &a.CreatedAt); err != nil {
+		if err := rows.Scan(&a.ID, &a.Slug, &a.Name, &a.URL, &a.Timezone,
+			&a.Contact.Phone, &a.Contact.LostFoundURL, &a.Contact.ComplaintURL, &a.CreatedAt, &a.UpdatedAt); err != nil {
 			return nil, err
 		}
 		agencies = append(agencies, a)
 	}
 	return agencies, rows.Err()
 }
+
+func (r *AgencyRepo) ListPage(ctx context.Context, offset, limit int) ([]domain.Agency, int, error) {
+	var total int
+	if err := r.db.Pool.QueryRow(ctx, `SELECT count(*) FROM agencies`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	if offset >= total {
+		return nil, total, nil
+	}
+
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, slug, name, COALESCE(url, ''), timezone,
+		       COALESCE(contact_phone, ''), COALESCE(lost_found_url, ''), COALESCE(complaint_url, ''),
+		       created_at, updated_at
+		FROM agencies ORDER BY name, id
+		OFFSET $1 LIMIT $2
+	`, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var agencies []domain.Agency
+	for rows.Next() {
+		var a domain.Agency
+		if err := rows.Scan(&a.ID, &a.Slug, &a.Name, &a.URL, &a.Timezone,
+			&a.Contact.Phone, &a.Contact.LostFoundURL, &a.Contact.ComplaintURL, &a.CreatedAt, &a.UpdatedAt); err != nil {
+			return nil, 0, err
+		}
+		agencies = append(agencies, a)
+	}
+	return agencies, total, rows.Err()
+}
+
+func (r *AgencyRepo) UpdateContact(ctx context.Context, agencyID string, c *domain.AgencyContact) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE agencies SET contact_phone = $2, lost_found_url = $3, complaint_url = $4
+		WHERE id = $1
+	`, agencyID, nilIfEmpty(c.Phone), nilIfEmpty(c.LostFoundURL), nilIfEmpty(c.ComplaintURL))
+	return err
+}
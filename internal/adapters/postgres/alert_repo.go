@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// AlertRepo implements ports.AlertRepository.
+type AlertRepo struct {
+	db *DB
+}
+
+func NewAlertRepo(db *DB) *AlertRepo { return &AlertRepo{db: db} }
+
+const alertColumns = `a.id, a.agency_id, a.source, a.source_id, COALESCE(a.cause, ''), COALESCE(a.effect, ''),
+	a.header, a.description, a.url, a.active_periods, a.route_ids::text[], a.stop_ids::text[],
+	a.created_at, a.updated_at`
+
+// alertActive matches live alerts with no active period or one containing $1.
+const alertActive = `a.removed_at IS NULL AND (
+	jsonb_array_length(a.active_periods) = 0 OR EXISTS (
+		SELECT 1 FROM jsonb_array_elements(a.active_periods) p
+		WHERE (p->>'start' IS NULL OR (p->>'start')::timestamptz <= $1)
+		  AND (p->>'end' IS NULL OR (p->>'end')::timestamptz > $1)))`
+
+// agencyWide matches alerts not tied to any route or stop.
+const agencyWide = `(cardinality(a.route_ids) = 0 AND cardinality(a.stop_ids) = 0)`
+
+// Upsert inserts or replaces an alert by (agency, source, source_id) and marks it live again.
+func (r *AlertRepo) Upsert(ctx context.Context, a *domain.ServiceAlert) error {
+	return r.db.Pool.QueryRow(ctx, `
+		INSERT INTO service_alerts (agency_id, source, source_id, cause, effect, header, description, url,
+		                            active_periods, route_ids, stop_ids)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10::uuid[], $11::uuid[])
+		ON CONFLICT (agency_id, source, source_id) DO UPDATE
+		SET cause = EXCLUDED.cause, effect = EXCLUDED.effect, header = EXCLUDED.header,
+		    description = EXCLUDED.description, url = EXCLUDED.url,
+		    active_periods = EXCLUDED.active_periods, route_ids = EXCLUDED.route_ids,
+		    stop_ids = EXCLUDED.stop_ids, updated_at = NOW(), removed_at = NULL
+		RETURNING id, created_at, updated_at
+	`, a.AgencyID, a.Source, a.SourceID, nilIfEmpty(a.Cause), nilIfEmpty(a.Effect),
+		a.Header, a.Description, a.URL, a.ActivePeriods, a.RouteIDs, a.StopIDs,
+	).Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt)
+}
+
+// RemoveMissing marks the live alerts of an agency's source not in live as removed.
+func (r *AlertRepo) RemoveMissing(ctx context.Context, agencyID, source string, live []string) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE service_alerts SET removed_at = NOW()
+		WHERE agency_id = $1 AND source = $2 AND removed_at IS NULL
+		  AND NOT (source_id = ANY($3::text[]))
+	`, agencyID, source, live)
+	return err
+}
+
+func (r *AlertRepo) ListActive(ctx context.Context, agencyID string, at time.Time) ([]domain.ServiceAlert, error) {
+	return r.list(ctx, `
+		SELECT `+alertColumns+` FROM service_alerts a
+		WHERE `+alertActive+` AND ($2::uuid IS NULL OR a.agency_id = $2)
+		ORDER BY a.updated_at DESC
+	`, at, nilIfEmpty(agencyID))
+}
+
+func (r *AlertRepo) ListActiveByRoute(ctx context.Context, routeID string, at time.Time) ([]domain.ServiceAlert, error) {
+	return r.list(ctx, `
+		SELECT `+alertColumns+` FROM service_alerts a
+		JOIN routes rt ON rt.id = $2 AND rt.agency_id = a.agency_id
+		WHERE `+alertActive+` AND ($2 = ANY(a.route_ids) OR `+agencyWide+`)
+		ORDER BY a.updated_at DESC
+	`, at, routeID)
+}
+
+func (r *AlertRepo) ListActiveByStop(ctx context.Context, stopID string, at time.Time) ([]domain.ServiceAlert, error) {
+	return r.list(ctx, `
+		SELECT `+alertColumns+` FROM service_alerts a
+		JOIN stops s ON s.id = $2 AND s.agency_id = a.agency_id
+		WHERE `+alertActive+` AND (
+			$2 = ANY(a.stop_ids) OR `+agencyWide+` OR a.route_ids && ARRAY(
+				SELECT DISTINCT t.route_id FROM stop_times st
+				JOIN trips t ON t.id = st.trip_id
+				WHERE st.stop_id = $2))
+		ORDER BY a.updated_at DESC
+	`, at, stopID)
+}
+
+func (r *AlertRepo) list(ctx context.Context, query string, args ...any) ([]domain.ServiceAlert, error) {
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []domain.ServiceAlert
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, *a)
+	}
+	return alerts, rows.Err()
+}
+
+func scanAlert(row pgx.Row) (*domain.ServiceAlert, error) {
+	var a domain.ServiceAlert
+	if err := row.Scan(&a.ID, &a.AgencyID, &a.Source, &a.SourceID, &a.Cause, &a.Effect,
+		&a.Header, &a.Description, &a.URL, &a.ActivePeriods, &a.RouteIDs, &a.StopIDs,
+		&a.CreatedAt, &a.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
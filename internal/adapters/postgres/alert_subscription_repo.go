@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// AlertSubscriptionRepo implements ports.AlertSubscriptionRepository.
+type AlertSubscriptionRepo struct {
+	db *DB
+}
+
+func NewAlertSubscriptionRepo(db *DB) *AlertSubscriptionRepo {
+	return &AlertSubscriptionRepo{db: db}
+}
+
+func (r *AlertSubscriptionRepo) Create(ctx context.Context, sub *domain.AlertSubscription) error {
+	return r.db.Pool.QueryRow(ctx, `
+		INSERT INTO alert_subscriptions (user_id, route_id, stop_id, days, start_time, end_time, min_delay_seconds)
+		VALUES ($1, $2, $3, $4, $5::time, $6::time, $7)
+		RETURNING id, created_at
+	`, sub.UserID, nilIfEmpty(sub.RouteID), nilIfEmpty(sub.StopID), sub.Days, sub.From, sub.Until,
+		sub.MinDelaySeconds).Scan(&sub.ID, &sub.CreatedAt)
+}
+
+func (r *AlertSubscriptionRepo) ListByUser(ctx context.Context, userID string) ([]domain.AlertSubscription, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT a.id, a.user_id, COALESCE(a.route_id::text, ''), COALESCE(a.stop_id::text, ''), a.days,
+		       to_char(a.start_time, 'HH24:MI'), to_char(a.end_time, 'HH24:MI'), a.min_delay_seconds,
+		       COALESCE(NULLIF(r.short_name, ''), r.long_name, ''), COALESCE(s.name, ''), a.created_at
+		FROM alert_subscriptions a
+		LEFT JOIN routes r ON r.id = a.route_id
+		LEFT JOIN stops s ON s.id = a.stop_id
+		WHERE a.user_id = $1
+		ORDER BY a.created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.AlertSubscription
+	for rows.Next() {
+		var a domain.AlertSubscription
+		if err := rows.Scan(&a.ID, &a.UserID, &a.RouteID, &a.StopID, &a.Days, &a.From, &a.Until,
+			&a.MinDelaySeconds, &a.RouteName, &a.StopName, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+func (r *AlertSubscriptionRepo) Delete(ctx context.Context, userID, id string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		DELETE FROM alert_subscriptions WHERE user_id = $1 AND id = $2
+	`, userID, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ClaimDelayAlerts matches e against subscriptions in the agency's local time
+// of the scheduled arrival, then claims each match in alert_subscription_sends.
+// A match already alerted about the trip within `within` fails the upsert's
+// WHERE and is not returned.
+func (r *AlertSubscriptionRepo) ClaimDelayAlerts(ctx context.Context, e *domain.DelayEvent, within time.Duration) ([]domain.DelayAlert, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		WITH ev AS (
+			SELECT t.id AS trip_id, t.route_id, COALESCE(t.headsign, '') AS headsign,
+			       s.id AS stop_id, s.name AS stop_name,
+			       COALESCE(NULLIF(r.short_name, ''), r.long_name, '') AS route_name,
+			       ($3::timestamptz AT TIME ZONE a.timezone) AS local
+			FROM trips t
+			JOIN routes r ON r.id = t.route_id
+			JOIN agencies a ON a.id = r.agency_id
+			JOIN stops s ON s.id = $2
+			WHERE t.id = $1
+		),
+		matched AS (
+			SELECT sub.id, sub.user_id, ev.trip_id, ev.route_name, ev.headsign, ev.stop_name
+			FROM alert_subscriptions sub, ev
+			WHERE (sub.route_id IS NULL OR sub.route_id = ev.route_id)
+			  AND (sub.stop_id IS NULL OR sub.stop_id = ev.stop_id)
+			  AND $4 >= sub.min_delay_seconds
+			  AND EXTRACT(ISODOW FROM ev.local)::int = ANY(sub.days)
+			  AND CASE
+			        WHEN sub.start_time = sub.end_time THEN TRUE
+			        WHEN sub.start_time < sub.end_time THEN ev.local::time >= sub.start_time AND ev.local::time < sub.end_time
+			        ELSE ev.local::time >= sub.start_time OR ev.local::time < sub.end_time
+			      END
+		),
+		claimed AS (
+			INSERT INTO alert_subscription_sends (subscription_id, trip_id, sent_at)
+			SELECT id, trip_id, NOW() FROM matched
+			ON CONFLICT (subscription_id, trip_id) DO UPDATE SET sent_at = EXCLUDED.sent_at
+			WHERE alert_subscription_sends.sent_at < EXCLUDED.sent_at - $5::int * interval '1 second'
+			RETURNING subscription_id
+		)
+		SELECT m.id, m.user_id, m.route_name, m.headsign, m.stop_name
+		FROM matched m
+		JOIN claimed c ON c.subscription_id = m.id
+	`, e.TripID, e.StopID, e.ScheduledArrival, e.DelaySeconds, int(within.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.DelayAlert
+	for rows.Next() {
+		a := domain.DelayAlert{DelaySeconds: e.DelaySeconds}
+		if err := rows.Scan(&a.SubscriptionID, &a.UserID, &a.RouteName, &a.Headsign, &a.StopName); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
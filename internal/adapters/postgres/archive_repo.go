@@ -0,0 +1,87 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// ArchiveRepo implements ports.ArchiveRepository.
+type ArchiveRepo struct {
+	db *DB
+}
+
+func NewArchiveRepo(db *DB) *ArchiveRepo {
+	return &ArchiveRepo{db: db}
+}
+
+// VehiclePositions reads the range in one query and hands rows on as they
+// arrive rather than collecting a day of positions in memory.
+func (r *ArchiveRepo) VehiclePositions(ctx context.Context, from, to time.Time, fn func(domain.VehiclePosition) error) error {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT time, vehicle_id, trip_id::text, route_id::text, stop_id::text,
+			ST_Y(location::geometry) as lat,
+			ST_X(location::geometry) as lon,
+			bearing, speed, congestion_level, occupancy_status, metadata
+		FROM vehicle_positions
+		WHERE time >= $1 AND time < $2
+		ORDER BY time
+	`, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var vp domain.VehiclePosition
+		var tripID, routeID, stopID sql.NullString
+		if err := rows.Scan(
+			&vp.Time, &vp.VehicleID, &tripID, &routeID, &stopID,
+			&vp.Location.Lat, &vp.Location.Lon,
+			&vp.Bearing, &vp.Speed, &vp.CongestionLevel, &vp.OccupancyStatus, &vp.Metadata,
+		); err != nil {
+			return err
+		}
+		vp.TripID = tripID.String
+		vp.RouteID = routeID.String
+		vp.StopID = stopID.String
+		if err := fn(vp); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// DelayEvents reads the range in one query, like VehiclePositions.
+func (r *ArchiveRepo) DelayEvents(ctx context.Context, from, to time.Time, fn func(domain.DelayEvent) error) error {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id::text, time, trip_id::text, stop_id::text, scheduled_arrival, actual_arrival,
+		       delay_seconds, COALESCE(is_compensated, false), compensation_sent_at
+		FROM delay_events
+		WHERE time >= $1 AND time < $2
+		ORDER BY time
+	`, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e domain.DelayEvent
+		var tripID, stopID sql.NullString
+		if err := rows.Scan(
+			&e.ID, &e.Time, &tripID, &stopID, &e.ScheduledArrival, &e.ActualArrival,
+			&e.DelaySeconds, &e.IsCompensated, &e.CompensationSentAt,
+		); err != nil {
+			return err
+		}
+		e.TripID = tripID.String
+		e.StopID = stopID.String
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
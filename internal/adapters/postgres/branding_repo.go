@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// BrandingRepo implements ports.BrandingRepository.
+type BrandingRepo struct {
+	db *DB
+}
+
+func NewBrandingRepo(db *DB) *BrandingRepo { return &BrandingRepo{db: db} }
+
+func (r *BrandingRepo) Get(ctx context.Context, agencyID string) (*domain.AgencyBranding, error) {
+	b := domain.AgencyBranding{AgencyID: agencyID}
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT COALESCE(logo_url, ''), COALESCE(primary_color, ''), COALESCE(secondary_color, ''),
+		       COALESCE(text_color, ''), updated_at
+		FROM agency_branding WHERE agency_id = $1
+	`, agencyID).Scan(&b.LogoURL, &b.PrimaryColor, &b.SecondaryColor, &b.TextColor, &b.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (r *BrandingRepo) Save(ctx context.Context, b *domain.AgencyBranding) error {
+	return r.db.Pool.QueryRow(ctx, `
+		INSERT INTO agency_branding (agency_id, logo_url, primary_color, secondary_color, text_color)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''))
+		ON CONFLICT (agency_id) DO UPDATE
+		SET logo_url = EXCLUDED.logo_url, primary_color = EXCLUDED.primary_color,
+		    secondary_color = EXCLUDED.secondary_color, text_color = EXCLUDED.text_color,
+		    updated_at = NOW()
+		RETURNING updated_at
+	`, b.AgencyID, b.LogoURL, b.PrimaryColor, b.SecondaryColor, b.TextColor).Scan(&b.UpdatedAt)
+}
+
+func (r *BrandingRepo) RouteIcons(ctx context.Context, agencyID string) (map[string]string, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT i.route_id, i.url
+		FROM route_icons i
+		JOIN routes rt ON rt.id = i.route_id
+		WHERE rt.agency_id = $1
+	`, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	icons := make(map[string]string)
+	for rows.Next() {
+		var routeID, url string
+		if err := rows.Scan(&routeID, &url); err != nil {
+			return nil, err
+		}
+		icons[routeID] = url
+	}
+	return icons, rows.Err()
+}
+
+func (r *BrandingRepo) SetRouteIcon(ctx context.Context, routeID, url string) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO route_icons (route_id, url) VALUES ($1, $2)
+		ON CONFLICT (route_id) DO UPDATE SET url = EXCLUDED.url, updated_at = NOW()
+	`, routeID, url)
+	return err
+}
@@ -0,0 +1,140 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// ChallengeRepo implements ports.ChallengeRepository.
+type ChallengeRepo struct {
+	db *DB
+}
+
+func NewChallengeRepo(db *DB) *ChallengeRepo { return &ChallengeRepo{db: db} }
+
+const challengeColumns = `id, slug, title, COALESCE(description, ''), metric, target, route_type,
+	starts_at, ends_at, COALESCE(reward_text, ''), active, created_at`
+
+func scanChallenge(row pgx.Row) (*domain.Challenge, error) {
+	var ch domain.Challenge
+	var routeType sql.NullInt32
+	if err := row.Scan(&ch.ID, &ch.Slug, &ch.Title, &ch.Description, &ch.Metric, &ch.Target, &routeType,
+		&ch.StartsAt, &ch.EndsAt, &ch.RewardText, &ch.Active, &ch.CreatedAt); err != nil {
+		return nil, err
+	}
+	if routeType.Valid {
+		rt := int(routeType.Int32)
+		ch.RouteType = &rt
+	}
+	return &ch, nil
+}
+
+func (r *ChallengeRepo) Create(ctx context.Context, ch *domain.Challenge) error {
+	return r.db.Pool.QueryRow(ctx, `
+		INSERT INTO challenges (slug, title, description, metric, target, route_type,
+		                        starts_at, ends_at, reward_text, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at
+	`, ch.Slug, ch.Title, nilIfEmpty(ch.Description), ch.Metric, ch.Target, ch.RouteType,
+		ch.StartsAt, ch.EndsAt, nilIfEmpty(ch.RewardText), ch.Active,
+	).Scan(&ch.ID, &ch.CreatedAt)
+}
+
+func (r *ChallengeRepo) GetByID(ctx context.Context, id string) (*domain.Challenge, error) {
+	return scanChallenge(r.db.Pool.QueryRow(ctx,
+		`SELECT `+challengeColumns+` FROM challenges WHERE id = $1`, id))
+}
+
+// ListActive returns active challenges whose window contains at.
+func (r *ChallengeRepo) ListActive(ctx context.Context, at time.Time) ([]domain.Challenge, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+challengeColumns+`
+		FROM challenges
+		WHERE active AND starts_at <= $1 AND ends_at > $1
+		ORDER BY ends_at, title
+	`, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var challenges []domain.Challenge
+	for rows.Next() {
+		ch, err := scanChallenge(rows)
+		if err != nil {
+			return nil, err
+		}
+		challenges = append(challenges, *ch)
+	}
+	return challenges, rows.Err()
+}
+
+func (r *ChallengeRepo) Enroll(ctx context.Context, challengeID, userID string) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO challenge_enrollments (challenge_id, user_id) VALUES ($1, $2)
+		ON CONFLICT (challenge_id, user_id) DO NOTHING
+	`, challengeID, userID)
+	return err
+}
+
+// GetEnrollment returns nil without error when the user is not enrolled.
+func (r *ChallengeRepo) GetEnrollment(ctx context.Context, challengeID, userID string) (*domain.ChallengeEnrollment, error) {
+	var e domain.ChallengeEnrollment
+	var code sql.NullString
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT challenge_id, user_id, enrolled_at, rewarded_at, reward_code
+		FROM challenge_enrollments WHERE challenge_id = $1 AND user_id = $2
+	`, challengeID, userID).Scan(&e.ChallengeID, &e.UserID, &e.EnrolledAt, &e.RewardedAt, &code)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	e.RewardCode = code.String
+	return &e, nil
+}
+
+func (r *ChallengeRepo) ListEnrollments(ctx context.Context, userID string) ([]domain.ChallengeEnrollment, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT challenge_id, user_id, enrolled_at, rewarded_at, reward_code
+		FROM challenge_enrollments WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var enrollments []domain.ChallengeEnrollment
+	for rows.Next() {
+		var e domain.ChallengeEnrollment
+		var code sql.NullString
+		if err := rows.Scan(&e.ChallengeID, &e.UserID, &e.EnrolledAt, &e.RewardedAt, &code); err != nil {
+			return nil, err
+		}
+		e.RewardCode = code.String
+		enrollments = append(enrollments, e)
+	}
+	return enrollments, rows.Err()
+}
+
+// MarkRewarded stores the coupon code issued for a completed challenge.
+func (r *ChallengeRepo) MarkRewarded(ctx context.Context, challengeID, userID, code string) error {
+	tag, err := r.db.Pool.Exec(ctx, `
+		UPDATE challenge_enrollments SET rewarded_at = NOW(), reward_code = $3
+		WHERE challenge_id = $1 AND user_id = $2 AND rewarded_at IS NULL
+	`, challengeID, userID, code)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("enrollment not found or already rewarded")
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// CheckInRepo implements ports.CheckInRepository.
+type CheckInRepo struct {
+	db *DB
+}
+
+func NewCheckInRepo(db *DB) *CheckInRepo {
+	return &CheckInRepo{db: db}
+}
+
+func (r *CheckInRepo) Create(ctx context.Context, c *domain.CheckIn) error {
+	return r.db.Pool.QueryRow(ctx, `
+		INSERT INTO checkins (user_id, trip_id, stop_id, boarded_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, c.UserID, c.TripID, c.StopID, c.Time).Scan(&c.ID, &c.CreatedAt)
+}
+
+func (r *CheckInRepo) ListByUser(ctx context.Context, userID string, limit int) ([]domain.CheckIn, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, user_id, trip_id, stop_id, boarded_at, created_at
+		FROM checkins
+		WHERE user_id = $1
+		ORDER BY boarded_at DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.CheckIn
+	for rows.Next() {
+		var c domain.CheckIn
+		if err := rows.Scan(&c.ID, &c.UserID, &c.TripID, &c.StopID, &c.Time, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (r *CheckInRepo) RidersOnTrip(ctx context.Context, tripID string, from, to time.Time) ([]string, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT DISTINCT user_id
+		FROM checkins
+		WHERE trip_id = $1 AND boarded_at >= $2 AND boarded_at < $3
+	`, tripID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []string
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
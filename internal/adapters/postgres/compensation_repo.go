@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// CompensationRepo implements ports.CompensationRepository.
+type CompensationRepo struct {
+	db *DB
+}
+
+func NewCompensationRepo(db *DB) *CompensationRepo {
+	return &CompensationRepo{db: db}
+}
+
+const compensationColumns = `id, user_id::text, COALESCE(delay_event_id::text, ''), COALESCE(affiliate_id::text, ''),
+	code, issued_at, expires_at, redeemed_at, COALESCE(metadata, '{}')`
+
+func (r *CompensationRepo) Create(ctx context.Context, c *domain.Compensation) error {
+	return r.db.Pool.QueryRow(ctx, `
+		INSERT INTO compensations (user_id, delay_event_id, affiliate_id, code, issued_at, expires_at, metadata)
+		VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, '{}'::jsonb))
+		RETURNING id
+	`, c.UserID, nilIfEmpty(c.DelayEventID), nilIfEmpty(c.AffiliateID), c.Code, c.IssuedAt, c.ExpiresAt,
+		c.Metadata).Scan(&c.ID)
+}
+
+func (r *CompensationRepo) GetByCode(ctx context.Context, code string) (*domain.Compensation, error) {
+	c, err := scanCompensation(r.db.Pool.QueryRow(ctx,
+		`SELECT `+compensationColumns+` FROM compensations WHERE code = $1`, code))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (r *CompensationRepo) ListByUser(ctx context.Context, userID string) ([]domain.Compensation, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+compensationColumns+`
+		FROM compensations
+		WHERE user_id = $1
+		ORDER BY issued_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.Compensation
+	for rows.Next() {
+		c, err := scanCompensation(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *c)
+	}
+	return out, rows.Err()
+}
+
+func (r *CompensationRepo) Redeem(ctx context.Context, code string, at time.Time) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		UPDATE compensations SET redeemed_at = $2
+		WHERE code = $1 AND redeemed_at IS NULL AND expires_at > $2
+	`, code, at)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *CompensationRepo) Delete(ctx context.Context, code string) error {
+	_, err := r.db.Pool.Exec(ctx, `DELETE FROM compensations WHERE code = $1`, code)
+	return err
+}
+
+func scanCompensation(row pgx.Row) (*domain.Compensation, error) {
+	var c domain.Compensation
+	err := row.Scan(&c.ID, &c.UserID, &c.DelayEventID, &c.AffiliateID,
+		&c.Code, &c.IssuedAt, &c.ExpiresAt, &c.RedeemedAt, &c.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// DelayObservationRepo implements ports.DelayObservationRepository.
+type DelayObservationRepo struct {
+	db *DB
+}
+
+func NewDelayObservationRepo(db *DB) *DelayObservationRepo {
+	return &DelayObservationRepo{db: db}
+}
+
+// Record inserts the events in one batch. The scheduled arrival comes from
+// the static schedule on the trip's service date; the actual arrival is
+// the predicted time, or scheduled plus delay. Nothing is inserted when
+// the trip or stop is unknown, or when the trip already has a delay event
+// at the stop within window.
+func (r *DelayObservationRepo) Record(ctx context.Context, obs []domain.DelayObservation, window time.Duration) ([]*domain.DelayEvent, error) {
+	batch := &pgx.Batch{}
+	for _, o := range obs {
+		metadata, _ := json.Marshal(map[string]any{
+			"source":        "gtfs-rt",
+			"gtfs_trip_id":  o.TripID,
+			"gtfs_route_id": o.RouteID,
+			"gtfs_stop_id":  o.StopID,
+		})
+		batch.Queue(`
+			WITH target AS (
+				SELECT t.id AS trip_id, st.stop_id,
+					((COALESCE(to_date($5, 'YYYYMMDD'), ($1::timestamptz AT TIME ZONE a.timezone)::date)
+						+ st.arrival_time) AT TIME ZONE a.timezone) AS scheduled
+				FROM trips t
+				JOIN routes r ON r.id = t.route_id
+				JOIN agencies a ON a.id = r.agency_id
+				JOIN stop_times st ON st.trip_id = t.id
+				JOIN stops s ON s.id = st.stop_id
+				WHERE r.agency_id = $2 AND t.trip_id = $3
+				  AND ($4::text IS NULL OR s.stop_id = $4)
+				  AND ($6::int IS NULL OR st.stop_sequence = $6)
+				ORDER BY st.stop_sequence
+				LIMIT 1
+			)
+			INSERT INTO delay_events (time, trip_id, stop_id, scheduled_arrival, actual_arrival, delay_seconds, metadata)
+			SELECT $1, target.trip_id, target.stop_id, target.scheduled,
+				COALESCE($8::timestamptz, target.scheduled + $7::int * interval '1 second'), $7, $9
+			FROM target
+			WHERE NOT EXISTS (
+				SELECT 1 FROM delay_events d
+				WHERE d.trip_id = target.trip_id AND d.stop_id = target.stop_id
+				  AND d.time > $1::timestamptz - $10::int * interval '1 second'
+			)
+			RETURNING id, time, trip_id, stop_id, scheduled_arrival, actual_arrival, delay_seconds
+		`, o.Time, o.AgencyID, o.TripID, nilIfEmpty(o.StopID), nilIfEmpty(o.StartDate),
+			o.StopSequence, o.DelaySeconds, o.ActualArrival, metadata, int(window.Seconds()))
+	}
+
+	br := r.db.Pool.SendBatch(ctx, batch)
+	defer br.Close()
+
+	recorded := make([]*domain.DelayEvent, len(obs))
+	for i := range obs {
+		var e domain.DelayEvent
+		err := br.QueryRow().Scan(&e.ID, &e.Time, &e.TripID, &e.StopID,
+			&e.ScheduledArrival, &e.ActualArrival, &e.DelaySeconds)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue // unknown trip/stop, or already recorded
+		}
+		if err != nil {
+			return recorded, fmt.Errorf("insert delay events: %w", err)
+		}
+		recorded[i] = &e
+	}
+	return recorded, br.Close()
+}
@@ -0,0 +1,140 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// DelayStatsRepo implements ports.DelayStatsRepository.
+type DelayStatsRepo struct {
+	db *DB
+}
+
+func NewDelayStatsRepo(db *DB) *DelayStatsRepo { return &DelayStatsRepo{db: db} }
+
+// RollupHour averages the latest delay of each trip stop predicted during the
+// hour, and keeps those delays as stop observations for delay profiles.
+func (r *DelayStatsRepo) RollupHour(ctx context.Context, hour time.Time) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO stop_delay_observations (time, route_id, stop_id, trip_id, delay)
+		SELECT latest.time, t.route_id, latest.stop_id, latest.trip_id, latest.delay
+		FROM (
+			SELECT DISTINCT ON (p.trip_id, p.stop_sequence)
+				p.time, p.trip_id, p.stop_id, COALESCE(p.departure_delay, p.arrival_delay) AS delay
+			FROM stop_time_predictions p
+			WHERE p.time >= $1 AND p.time < $2 AND COALESCE(p.schedule_relationship, 0) = 0
+			ORDER BY p.trip_id, p.stop_sequence, p.time DESC
+		) latest
+		JOIN trips t ON t.id = latest.trip_id
+		WHERE latest.delay IS NOT NULL
+		ON CONFLICT (trip_id, stop_id, time) DO NOTHING
+	`, hour, hour.Add(time.Hour))
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Pool.Exec(ctx, `
+		INSERT INTO route_delay_hourly (hour, route_id, mean_delay, samples)
+		SELECT $1, t.route_id, AVG(latest.delay), COUNT(*)
+		FROM (
+			SELECT DISTINCT ON (p.trip_id, p.stop_sequence)
+				p.trip_id, COALESCE(p.departure_delay, p.arrival_delay) AS delay
+			FROM stop_time_predictions p
+			WHERE p.time >= $1 AND p.time < $2 AND COALESCE(p.schedule_relationship, 0) = 0
+			ORDER BY p.trip_id, p.stop_sequence, p.time DESC
+		) latest
+		JOIN trips t ON t.id = latest.trip_id
+		WHERE latest.delay IS NOT NULL
+		GROUP BY t.route_id
+		ON CONFLICT (route_id, hour) DO UPDATE
+		SET mean_delay = EXCLUDED.mean_delay, samples = EXCLUDED.samples
+	`, hour, hour.Add(time.Hour))
+	return err
+}
+
+func (r *DelayStatsRepo) HourlyDelays(ctx context.Context, hour time.Time) ([]domain.RouteDelayStat, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT h.route_id, r.agency_id, COALESCE(NULLIF(r.short_name, ''), r.long_name),
+		       h.hour, h.mean_delay, h.samples
+		FROM route_delay_hourly h
+		JOIN routes r ON r.id = h.route_id
+		WHERE h.hour = $1
+	`, hour)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []domain.RouteDelayStat
+	for rows.Next() {
+		var s domain.RouteDelayStat
+		if err := rows.Scan(&s.RouteID, &s.AgencyID, &s.RouteName, &s.Hour, &s.MeanDelay, &s.Samples); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+func (r *DelayStatsRepo) Baselines(ctx context.Context, hour, since time.Time) ([]domain.DelayBaseline, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT h.route_id, AVG(h.mean_delay), COALESCE(STDDEV_SAMP(h.mean_delay), 0), COUNT(*)
+		FROM route_delay_hourly h
+		JOIN routes r ON r.id = h.route_id
+		JOIN agencies a ON a.id = r.agency_id
+		WHERE h.hour >= $2 AND h.hour < $1
+		  AND EXTRACT(HOUR FROM h.hour AT TIME ZONE a.timezone) = EXTRACT(HOUR FROM $1::timestamptz AT TIME ZONE a.timezone)
+		GROUP BY h.route_id
+	`, hour, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var baselines []domain.DelayBaseline
+	for rows.Next() {
+		var b domain.DelayBaseline
+		if err := rows.Scan(&b.RouteID, &b.Mean, &b.StdDev, &b.Hours); err != nil {
+			return nil, err
+		}
+		baselines = append(baselines, b)
+	}
+	return baselines, rows.Err()
+}
+
+// StopProfiles groups observations by route, weekday and hour of day in the
+// agency's timezone.
+func (r *DelayStatsRepo) StopProfiles(ctx context.Context, stopUUID string, since time.Time) ([]domain.DelayProfile, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT o.route_id,
+		       EXTRACT(DOW FROM o.time AT TIME ZONE a.timezone)::int,
+		       EXTRACT(HOUR FROM o.time AT TIME ZONE a.timezone)::int,
+		       percentile_cont(0.5) WITHIN GROUP (ORDER BY o.delay),
+		       percentile_cont(0.25) WITHIN GROUP (ORDER BY o.delay),
+		       percentile_cont(0.75) WITHIN GROUP (ORDER BY o.delay),
+		       COUNT(*)
+		FROM stop_delay_observations o
+		JOIN routes r ON r.id = o.route_id
+		JOIN agencies a ON a.id = r.agency_id
+		WHERE o.stop_id = $1 AND o.time >= $2
+		GROUP BY 1, 2, 3
+	`, stopUUID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []domain.DelayProfile
+	for rows.Next() {
+		var p domain.DelayProfile
+		var weekday int
+		if err := rows.Scan(&p.RouteID, &weekday, &p.Hour, &p.Median, &p.Low, &p.High, &p.Samples); err != nil {
+			return nil, err
+		}
+		p.Weekday = time.Weekday(weekday)
+		profiles = append(profiles, p)
+	}
+	return profiles, rows.Err()
+}
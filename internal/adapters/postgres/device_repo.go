@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// DeviceRepo implements ports.DeviceRepository.
+type DeviceRepo struct {
+	db *DB
+}
+
+func NewDeviceRepo(db *DB) *DeviceRepo {
+	return &DeviceRepo{db: db}
+}
+
+func (r *DeviceRepo) Register(ctx context.Context, d *domain.Device) error {
+	return r.db.Pool.QueryRow(ctx, `
+		INSERT INTO devices (user_id, platform, token, p256dh, auth)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (token) DO UPDATE
+		SET user_id = EXCLUDED.user_id, platform = EXCLUDED.platform,
+		    p256dh = EXCLUDED.p256dh, auth = EXCLUDED.auth, updated_at = NOW()
+		RETURNING id, created_at, updated_at
+	`, d.UserID, d.Platform, d.Token, nilIfEmpty(d.P256dh), nilIfEmpty(d.Auth)).
+		Scan(&d.ID, &d.CreatedAt, &d.UpdatedAt)
+}
+
+func (r *DeviceRepo) ListByUser(ctx context.Context, userID string) ([]domain.Device, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, user_id, platform, token, COALESCE(p256dh, ''), COALESCE(auth, ''), created_at, updated_at
+		FROM devices
+		WHERE user_id = $1
+		ORDER BY updated_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.Device
+	for rows.Next() {
+		var d domain.Device
+		if err := rows.Scan(&d.ID, &d.UserID, &d.Platform, &d.Token, &d.P256dh, &d.Auth, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (r *DeviceRepo) Delete(ctx context.Context, userID, id string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `DELETE FROM devices WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *DeviceRepo) DeleteByToken(ctx context.Context, token string) error {
+	_, err := r.db.Pool.Exec(ctx, `DELETE FROM devices WHERE token = $1`, token)
+	return err
+}
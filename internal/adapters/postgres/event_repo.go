@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// EventRepo implements ports.EventRepository.
+type EventRepo struct {
+	db *DB
+}
+
+func NewEventRepo(db *DB) *EventRepo {
+	return &EventRepo{db: db}
+}
+
+const eventColumns = `id, name, kind, COALESCE(venue, ''),
+	ST_Y(location::geometry), ST_X(location::geometry), radius_meters,
+	starts_at, ends_at, service_from, service_until, description, impacts,
+	created_at, updated_at`
+
+func (r *EventRepo) Create(ctx context.Context, e *domain.Event) error {
+	return r.db.Pool.QueryRow(ctx, `
+		INSERT INTO events (name, kind, venue, location, radius_meters, starts_at, ends_at,
+		                    service_from, service_until, description, impacts)
+		VALUES ($1, $2, $3, ST_SetSRID(ST_MakePoint($4, $5), 4326)::geography, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id, created_at, updated_at
+	`, e.Name, e.Kind, nilIfEmpty(e.Venue), e.Location.Lon, e.Location.Lat, e.RadiusMeters,
+		e.StartsAt, e.EndsAt, e.ServiceFrom, e.ServiceUntil, e.Description, e.Impacts,
+	).Scan(&e.ID, &e.CreatedAt, &e.UpdatedAt)
+}
+
+func (r *EventRepo) Update(ctx context.Context, e *domain.Event) (bool, error) {
+	err := r.db.Pool.QueryRow(ctx, `
+		UPDATE events
+		SET name = $2, kind = $3, venue = $4, location = ST_SetSRID(ST_MakePoint($5, $6), 4326)::geography,
+		    radius_meters = $7, starts_at = $8, ends_at = $9, service_from = $10, service_until = $11,
+		    description = $12, impacts = $13, updated_at = NOW()
+		WHERE id = $1
+		RETURNING created_at, updated_at
+	`, e.ID, e.Name, e.Kind, nilIfEmpty(e.Venue), e.Location.Lon, e.Location.Lat, e.RadiusMeters,
+		e.StartsAt, e.EndsAt, e.ServiceFrom, e.ServiceUntil, e.Description, e.Impacts,
+	).Scan(&e.CreatedAt, &e.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *EventRepo) Delete(ctx context.Context, id string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `DELETE FROM events WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *EventRepo) GetByID(ctx context.Context, id string) (*domain.Event, error) {
+	var e domain.Event
+	err := r.db.Pool.QueryRow(ctx, `SELECT `+eventColumns+` FROM events WHERE id = $1`, id).
+		Scan(eventFields(&e)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *EventRepo) List(ctx context.Context, from, to time.Time) ([]domain.Event, error) {
+	return r.list(ctx, `
+		SELECT `+eventColumns+` FROM events
+		WHERE service_until > $1 AND service_from < $2
+		ORDER BY starts_at
+	`, from, to)
+}
+
+func (r *EventRepo) ListActiveNear(ctx context.Context, point domain.GeoPoint, at time.Time) ([]domain.Event, error) {
+	return r.list(ctx, `
+		SELECT `+eventColumns+` FROM events
+		WHERE service_from <= $3 AND service_until > $3
+		  AND ST_DWithin(location, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography, radius_meters)
+		ORDER BY starts_at
+	`, point.Lon, point.Lat, at)
+}
+
+func (r *EventRepo) list(ctx context.Context, query string, args ...any) ([]domain.Event, error) {
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.Event
+	for rows.Next() {
+		var e domain.Event
+		if err := rows.Scan(eventFields(&e)...); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// eventFields returns scan targets matching eventColumns.
+func eventFields(e *domain.Event) []any {
+	return []any{
+		&e.ID, &e.Name, &e.Kind, &e.Venue, &e.Location.Lat, &e.Location.Lon, &e.RadiusMeters,
+		&e.StartsAt, &e.EndsAt, &e.ServiceFrom, &e.ServiceUntil, &e.Description, &e.Impacts,
+		&e.CreatedAt, &e.UpdatedAt,
+	}
+}
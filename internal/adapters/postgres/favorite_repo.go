@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// FavoriteRepo implements ports.FavoriteRepository.
+type FavoriteRepo struct {
+	db *DB
+}
+
+func NewFavoriteRepo(db *DB) *FavoriteRepo {
+	return &FavoriteRepo{db: db}
+}
+
+func (r *FavoriteRepo) Create(ctx context.Context, f *domain.Favorite) (bool, error) {
+	err := r.db.Pool.QueryRow(ctx, `
+		INSERT INTO user_favorites (user_id, kind, stop_id, to_stop_id, route_id, label)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT DO NOTHING
+		RETURNING id, created_at
+	`, f.UserID, f.Kind, nilIfEmpty(f.StopID), nilIfEmpty(f.ToStopID), nilIfEmpty(f.RouteID),
+		nilIfEmpty(f.Label)).Scan(&f.ID, &f.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *FavoriteRepo) ListByUser(ctx context.Context, userID string) ([]domain.Favorite, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT f.id, f.user_id, f.kind,
+		       COALESCE(f.stop_id::text, ''), COALESCE(f.to_stop_id::text, ''), COALESCE(f.route_id::text, ''),
+		       COALESCE(f.label, ''), COALESCE(s.name, ''), COALESCE(ts.name, ''),
+		       COALESCE(NULLIF(r.short_name, ''), r.long_name, ''), f.created_at
+		FROM user_favorites f
+		LEFT JOIN stops s ON s.id = f.stop_id
+		LEFT JOIN stops ts ON ts.id = f.to_stop_id
+		LEFT JOIN routes r ON r.id = f.route_id
+		WHERE f.user_id = $1
+		ORDER BY f.created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.Favorite
+	for rows.Next() {
+		var f domain.Favorite
+		if err := rows.Scan(&f.ID, &f.UserID, &f.Kind, &f.StopID, &f.ToStopID, &f.RouteID,
+			&f.Label, &f.StopName, &f.ToStopName, &f.RouteName, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+func (r *FavoriteRepo) Delete(ctx context.Context, userID, id string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		DELETE FROM user_favorites WHERE user_id = $1 AND id = $2
+	`, userID, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
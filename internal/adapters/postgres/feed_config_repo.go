@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// FeedConfigRepo implements ports.FeedConfigRepository.
+type FeedConfigRepo struct {
+	db *DB
+}
+
+func NewFeedConfigRepo(db *DB) *FeedConfigRepo { return &FeedConfigRepo{db: db} }
+
+func (r *FeedConfigRepo) Get(ctx context.Context, agencyID string) (*domain.FeedConfig, error) {
+	c := domain.FeedConfig{AgencyID: agencyID}
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT id_rules, require_approval, route_name_rules, updated_at FROM agency_feed_configs WHERE agency_id = $1
+	`, agencyID).Scan(&c.IDRules, &c.RequireApproval, &c.RouteNames, &c.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *FeedConfigRepo) Save(ctx context.Context, c *domain.FeedConfig) error {
+	return r.db.Pool.QueryRow(ctx, `
+		INSERT INTO agency_feed_configs (agency_id, id_rules, require_approval, route_name_rules)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (agency_id) DO UPDATE
+		SET id_rules = EXCLUDED.id_rules, require_approval = EXCLUDED.require_approval,
+		    route_name_rules = EXCLUDED.route_name_rules, updated_at = NOW()
+		RETURNING updated_at
+	`, c.AgencyID, c.IDRules, c.RequireApproval, c.RouteNames).Scan(&c.UpdatedAt)
+}
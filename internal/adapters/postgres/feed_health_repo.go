@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// FeedHealthRepo implements ports.FeedHealthRepository.
+type FeedHealthRepo struct {
+	db *DB
+}
+
+func NewFeedHealthRepo(db *DB) *FeedHealthRepo { return &FeedHealthRepo{db: db} }
+
+func (r *FeedHealthRepo) FeedHealth(ctx context.Context, activeSince time.Time) ([]domain.FeedHealth, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		WITH active AS (
+			SELECT metadata->>'agency' AS slug, COUNT(DISTINCT vehicle_id)::int AS vehicles
+			FROM vehicle_positions
+			WHERE time > $1
+			GROUP BY 1
+		)
+		SELECT a.slug,
+		       (SELECT last_ingest_at FROM feed_ingests WHERE agency_id = a.id),
+		       (SELECT MAX(COALESCE(feed_timestamp, last_success_at)) FROM rt_feed_status WHERE agency_id = a.id),
+		       COALESCE(v.vehicles, 0), COALESCE(u.trips, 0), COALESCE(u.stops, 0)
+		FROM agencies a
+		LEFT JOIN active v ON v.slug = a.slug
+		LEFT JOIN LATERAL (
+			SELECT COUNT(*) FILTER (WHERE f.kind = 'trip' AND NOT EXISTS (
+			           SELECT 1 FROM trips t JOIN routes r ON r.id = t.route_id
+			           WHERE r.agency_id = a.id AND t.trip_id = f.rt_id))::int AS trips,
+			       COUNT(*) FILTER (WHERE f.kind = 'stop' AND NOT EXISTS (
+			           SELECT 1 FROM stops s
+			           WHERE s.agency_id = a.id AND s.stop_id = f.rt_id))::int AS stops
+			FROM rt_feed_ids f
+			WHERE f.agency_id = a.id
+			  AND f.day = (SELECT MAX(day) FROM rt_feed_ids WHERE agency_id = a.id)
+		) u ON TRUE
+		ORDER BY a.slug
+	`, activeSince)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.FeedHealth
+	for rows.Next() {
+		var h domain.FeedHealth
+		if err := rows.Scan(&h.AgencySlug, &h.LastStaticIngest, &h.LastRealtime,
+			&h.ActiveVehicles, &h.UnresolvedTripIDs, &h.UnresolvedStopIDs); err != nil {
+			return nil, err
+		}
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
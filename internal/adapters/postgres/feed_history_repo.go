@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// FeedHistoryRepo implements ports.FeedHistoryRepository.
+type FeedHistoryRepo struct {
+	db *DB
+}
+
+func NewFeedHistoryRepo(db *DB) *FeedHistoryRepo {
+	return &FeedHistoryRepo{db: db}
+}
+
+func (r *FeedHistoryRepo) Versions(ctx context.Context, agencyID string) ([]domain.FeedVersion, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, agency_id, sha256, activated_at,
+		       LAG(activated_at) OVER (ORDER BY activated_at DESC),
+		       stops, routes, trips
+		FROM feed_versions
+		WHERE agency_id = $1
+		ORDER BY activated_at DESC
+	`, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.FeedVersion
+	for rows.Next() {
+		var v domain.FeedVersion
+		if err := rows.Scan(&v.ID, &v.AgencyID, &v.SHA256, &v.ActivatedAt, &v.SupersededAt,
+			&v.Stops, &v.Routes, &v.Trips); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+func (r *FeedHistoryRepo) StopAsOf(ctx context.Context, id string, at time.Time) (*domain.Stop, error) {
+	var s domain.Stop
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT s.id, s.stop_id, s.agency_id, h.name, h.lat, h.lon,
+		       COALESCE(h.platform_code, ''), h.wheelchair_accessible, s.created_at, h.valid_from
+		FROM stop_history h
+		JOIN stops s ON s.id = h.stop_id
+		WHERE h.stop_id = $1 AND h.valid_from <= $2 AND (h.valid_until IS NULL OR h.valid_until > $2)
+	`, id, at).Scan(&s.ID, &s.StopID, &s.AgencyID, &s.Name, &s.Location.Lat, &s.Location.Lon,
+		&s.PlatformCode, &s.WheelchairAccessible, &s.CreatedAt, &s.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *FeedHistoryRepo) RouteAsOf(ctx context.Context, id string, at time.Time) (*domain.Route, error) {
+	routes, err := r.routesAsOf(ctx, `h.route_id = $1`, id, at)
+	if err != nil || len(routes) == 0 {
+		return nil, err
+	}
+	return &routes[0], nil
+}
+
+func (r *FeedHistoryRepo) RoutesAsOf(ctx context.Context, agencyID string, at time.Time) ([]domain.Route, error) {
+	return r.routesAsOf(ctx, `rt.agency_id = $1`, agencyID, at)
+}
+
+func (r *FeedHistoryRepo) routesAsOf(ctx context.Context, where, arg string, at time.Time) ([]domain.Route, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT rt.id, rt.route_id, rt.agency_id, COALESCE(h.short_name, ''), h.long_name,
+		       h.route_type, COALESCE(h.color, ''), COALESCE(h.text_color, ''), rt.created_at, h.valid_from
+		FROM route_history h
+		JOIN routes rt ON rt.id = h.route_id
+		WHERE `+where+` AND h.valid_from <= $2 AND (h.valid_until IS NULL OR h.valid_until > $2)
+		ORDER BY h.short_name
+	`, arg, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var routes []domain.Route
+	for rows.Next() {
+		var rt domain.Route
+		if err := rows.Scan(&rt.ID, &rt.RouteID, &rt.AgencyID, &rt.ShortName, &rt.LongName,
+			&rt.RouteType, &rt.Color, &rt.TextColor, &rt.CreatedAt, &rt.UpdatedAt); err != nil {
+			return nil, err
+		}
+		routes = append(routes, rt)
+	}
+	return routes, rows.Err()
+}
+
+// historyStopTime is a stop_times entry of trip_history.
+type historyStopTime struct {
+	StopID       string `json:"stop_id"`
+	Arrival      int    `json:"arrival"`
+	Departure    int    `json:"departure"`
+	StopSequence int    `json:"stop_sequence"`
+	PickupType   int    `json:"pickup_type"`
+	DropOffType  int    `json:"drop_off_type"`
+}
+
+func (r *FeedHistoryRepo) StopTimesAsOf(ctx context.Context, tripID string, at time.Time) ([]domain.StopTime, error) {
+	var raw []byte
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT stop_times FROM trip_history
+		WHERE trip_id = $1 AND valid_from <= $2 AND (valid_until IS NULL OR valid_until > $2)
+	`, tripID, at).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []historyStopTime
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, err
+	}
+	times := make([]domain.StopTime, 0, len(entries))
+	for _, e := range entries {
+		times = append(times, domain.StopTime{
+			TripID:        tripID,
+			StopID:        e.StopID,
+			ArrivalTime:   time.Duration(e.Arrival) * time.Second,
+			DepartureTime: time.Duration(e.Departure) * time.Second,
+			StopSequence:  e.StopSequence,
+			PickupType:    e.PickupType,
+			DropOffType:   e.DropOffType,
+		})
+	}
+	return times, nil
+}
@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// FeedQualityRepo implements ports.FeedQualityRepository.
+type FeedQualityRepo struct {
+	db *DB
+}
+
+func NewFeedQualityRepo(db *DB) *FeedQualityRepo { return &FeedQualityRepo{db: db} }
+
+// staticIDQueries select the static identifiers of each kind for an agency.
+var staticIDQueries = map[string]string{
+	"trip": `SELECT t.trip_id FROM trips t JOIN routes r ON r.id = t.route_id WHERE r.agency_id = $1`,
+	"stop": `SELECT s.stop_id FROM stops s WHERE s.agency_id = $1`,
+}
+
+func (r *FeedQualityRepo) RecordSeenIDs(ctx context.Context, agencyID string, day time.Time, kind string, seen map[string]int) error {
+	if len(seen) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(seen))
+	counts := make([]int32, 0, len(seen))
+	for id, n := range seen {
+		ids = append(ids, id)
+		counts = append(counts, int32(n))
+	}
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO rt_feed_ids (agency_id, day, kind, rt_id, occurrences, last_seen)
+		SELECT $1, $2::date, $3, u.id, u.n, NOW()
+		FROM unnest($4::text[], $5::int[]) AS u(id, n)
+		ON CONFLICT (agency_id, day, kind, rt_id) DO UPDATE
+		SET occurrences = rt_feed_ids.occurrences + EXCLUDED.occurrences, last_seen = EXCLUDED.last_seen
+	`, agencyID, day.Format("2006-01-02"), kind, ids, counts)
+	return err
+}
+
+func (r *FeedQualityRepo) SeenIDs(ctx context.Context, agencyID string, day time.Time, kind string) ([]domain.SeenID, error) {
+	static, ok := staticIDQueries[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown id kind %q", kind)
+	}
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT f.rt_id, f.occurrences, f.rt_id IN (`+static+`)
+		FROM rt_feed_ids f
+		WHERE f.agency_id = $1 AND f.day = $2 AND f.kind = $3
+	`, agencyID, day.Format("2006-01-02"), kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []domain.SeenID
+	for rows.Next() {
+		var id domain.SeenID
+		if err := rows.Scan(&id.ID, &id.Occurrences, &id.Matched); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *FeedQualityRepo) StaticIDs(ctx context.Context, agencyID, kind string) ([]string, error) {
+	static, ok := staticIDQueries[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown id kind %q", kind)
+	}
+	rows, err := r.db.Pool.Query(ctx, static, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *FeedQualityRepo) SaveReport(ctx context.Context, rep *domain.FeedQualityReport) error {
+	return r.db.Pool.QueryRow(ctx, `
+		INSERT INTO feed_quality_reports (agency_id, day, trip_ids, stop_ids)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (agency_id, day) DO UPDATE
+		SET trip_ids = EXCLUDED.trip_ids, stop_ids = EXCLUDED.stop_ids, created_at = NOW()
+		RETURNING created_at
+	`, rep.AgencyID, rep.Day, rep.TripIDs, rep.StopIDs).Scan(&rep.CreatedAt)
+}
+
+func (r *FeedQualityRepo) GetReport(ctx context.Context, agencyID, day string) (*domain.FeedQualityReport, error) {
+	rep := domain.FeedQualityReport{AgencyID: agencyID, Day: day}
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT trip_ids, stop_ids, created_at
+		FROM feed_quality_reports
+		WHERE agency_id = $1 AND day = $2
+	`, agencyID, day).Scan(&rep.TripIDs, &rep.StopIDs, &rep.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rep, nil
+}
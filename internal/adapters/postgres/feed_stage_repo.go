@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// FeedStageRepo implements ports.FeedStageRepository.
+type FeedStageRepo struct {
+	db *DB
+}
+
+func NewFeedStageRepo(db *DB) *FeedStageRepo { return &FeedStageRepo{db: db} }
+
+const feedStageColumns = `id, agency_id, sha256, stops, routes, trips, status, rollback,
+	staged_at, decided_at, applied_at`
+
+func scanFeedStage(row pgx.Row) (*domain.FeedStage, error) {
+	var s domain.FeedStage
+	err := row.Scan(&s.ID, &s.AgencyID, &s.SHA256, &s.Stops, &s.Routes, &s.Trips, &s.Status,
+		&s.Rollback, &s.StagedAt, &s.DecidedAt, &s.AppliedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *FeedStageRepo) List(ctx context.Context, agencyID string) ([]domain.FeedStage, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+feedStageColumns+`
+		FROM feed_stages
+		WHERE agency_id = $1 AND status <> 'downloaded'
+		ORDER BY staged_at DESC
+	`, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.FeedStage
+	for rows.Next() {
+		s, err := scanFeedStage(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *s)
+	}
+	return out, rows.Err()
+}
+
+func (r *FeedStageRepo) Decide(ctx context.Context, agencyID, id, status string) (*domain.FeedStage, error) {
+	s, err := scanFeedStage(r.db.Pool.QueryRow(ctx, `
+		UPDATE feed_stages
+		SET status = $3, decided_at = NOW(),
+		    feed = CASE WHEN $3 = 'rejected' THEN ''::bytea ELSE feed END
+		WHERE agency_id = $1 AND id = $2 AND status = 'staged'
+		RETURNING `+feedStageColumns,
+		agencyID, id, status))
+	if err != nil || s != nil {
+		return s, err
+	}
+	return scanFeedStage(r.db.Pool.QueryRow(ctx, `
+		SELECT `+feedStageColumns+` FROM feed_stages WHERE agency_id = $1 AND id = $2
+	`, agencyID, id))
+}
+
+func (r *FeedStageRepo) Rollback(ctx context.Context, agencyID string) (*domain.FeedStage, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// The previous version's feed, as last applied
+	var sourceID string
+	err = tx.QueryRow(ctx, `
+		SELECT s.id
+		FROM feed_stages s
+		WHERE s.agency_id = $1 AND s.status = 'applied'
+		  AND s.sha256 = (SELECT sha256 FROM feed_versions WHERE agency_id = $1
+		                  ORDER BY activated_at DESC OFFSET 1 LIMIT 1)
+		ORDER BY s.applied_at DESC
+		LIMIT 1
+	`, agencyID).Scan(&sourceID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE feed_stages SET status = 'rejected', decided_at = NOW(), feed = ''::bytea
+		WHERE agency_id = $1 AND status IN ('staged', 'approved')
+	`, agencyID); err != nil {
+		return nil, err
+	}
+	s, err := scanFeedStage(tx.QueryRow(ctx, `
+		INSERT INTO feed_stages (agency_id, sha256, feed, stops, routes, trips, status, rollback, decided_at)
+		SELECT agency_id, sha256, feed, stops, routes, trips, 'approved', TRUE, NOW()
+		FROM feed_stages WHERE id = $1
+		RETURNING `+feedStageColumns,
+		sourceID))
+	if err != nil {
+		return nil, err
+	}
+	return s, tx.Commit(ctx)
+}
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// FeedStateRepo implements ports.FeedStateRepository.
+type FeedStateRepo struct {
+	db *DB
+}
+
+func NewFeedStateRepo(db *DB) *FeedStateRepo {
+	return &FeedStateRepo{db: db}
+}
+
+func (r *FeedStateRepo) FavoritedSubjects(ctx context.Context, agencyID string) ([]domain.FeedSubject, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT 'stop', s.id, s.stop_id, COALESCE(fs.name, s.name), COALESCE(fs.trips, 0),
+		       COALESCE(fs.present, TRUE), fs.subject_id IS NOT NULL,
+		       array_agg(DISTINCT f.user_id::text)
+		FROM stops s
+		JOIN user_favorites f ON f.stop_id = s.id OR f.to_stop_id = s.id
+		LEFT JOIN favorite_feed_states fs ON fs.kind = 'stop' AND fs.subject_id = s.id
+		WHERE s.agency_id = $1
+		GROUP BY s.id, fs.subject_id, fs.name, fs.trips, fs.present
+		UNION ALL
+		SELECT 'route', rt.id, rt.route_id, COALESCE(fs.name, NULLIF(rt.short_name, ''), rt.long_name),
+		       COALESCE(fs.trips, 0), COALESCE(fs.present, TRUE), fs.subject_id IS NOT NULL,
+		       array_agg(DISTINCT f.user_id::text)
+		FROM routes rt
+		JOIN user_favorites f ON f.route_id = rt.id AND f.kind = 'route'
+		LEFT JOIN favorite_feed_states fs ON fs.kind = 'route' AND fs.subject_id = rt.id
+		WHERE rt.agency_id = $1
+		GROUP BY rt.id, fs.subject_id, fs.name, fs.trips, fs.present
+	`, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.FeedSubject
+	for rows.Next() {
+		var s domain.FeedSubject
+		if err := rows.Scan(&s.Kind, &s.ID, &s.GTFSID, &s.Name, &s.Trips,
+			&s.Present, &s.Known, &s.UserIDs); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (r *FeedStateRepo) Save(ctx context.Context, subjects []domain.FeedSubject) error {
+	batch := &pgx.Batch{}
+	for _, s := range subjects {
+		batch.Queue(`
+			INSERT INTO favorite_feed_states (kind, subject_id, name, trips, present)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (kind, subject_id) DO UPDATE
+			SET name = EXCLUDED.name, trips = EXCLUDED.trips,
+			    present = EXCLUDED.present, updated_at = NOW()
+		`, s.Kind, s.ID, s.Name, s.Trips, s.Present)
+	}
+	br := r.db.Pool.SendBatch(ctx, batch)
+	defer br.Close()
+	for range subjects {
+		if _, err := br.Exec(); err != nil {
+			return fmt.Errorf("batch exec: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// GlobalSearchRepo implements ports.GlobalSearchRepository. Names are
+// scored like StopRepo.Search, by how well the query matches a word run in
+// them, ignoring case and accents.
+type GlobalSearchRepo struct {
+	db *DB
+}
+
+func NewGlobalSearchRepo(db *DB) *GlobalSearchRepo {
+	return &GlobalSearchRepo{db: db}
+}
+
+func (r *GlobalSearchRepo) Agencies(ctx context.Context, query string, limit int) ([]domain.SearchHit, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		WITH q AS (
+			SELECT lower(immutable_unaccent(btrim($1))) AS text
+		)
+		SELECT id, slug, name, COALESCE(url, ''), timezone,
+		       COALESCE(contact_phone, ''), COALESCE(lost_found_url, ''), COALESCE(complaint_url, ''),
+		       created_at, updated_at,
+		       CASE WHEN slug = q.text THEN 1 ELSE word_similarity(q.text, search_name) END AS score
+		FROM agencies, q
+		WHERE search_name %> q.text OR slug = q.text
+		ORDER BY score DESC, name
+		LIMIT $2
+	`, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []domain.SearchHit
+	for rows.Next() {
+		a := &domain.Agency{}
+		h := domain.SearchHit{Type: domain.SearchHitAgency, Agency: a}
+		if err := rows.Scan(&a.ID, &a.Slug, &a.Name, &a.URL, &a.Timezone,
+			&a.Contact.Phone, &a.Contact.LostFoundURL, &a.Contact.ComplaintURL,
+			&a.CreatedAt, &a.UpdatedAt, &h.Score); err != nil {
+			return nil, err
+		}
+		hits = append(hits, h)
+	}
+	return hits, rows.Err()
+}
+
+// Routes scores an exact short name 1, so "A3" finds line A3 before routes
+// merely mentioning it.
+func (r *GlobalSearchRepo) Routes(ctx context.Context, query string, limit int) ([]domain.SearchHit, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		WITH q AS (
+			SELECT lower(immutable_unaccent(btrim($1))) AS text
+		)
+		SELECT id, route_id, agency_id, COALESCE(short_name, ''), long_name, route_type, color, text_color,
+		       created_at, updated_at,
+		       CASE WHEN lower(short_name) = lower(btrim($1)) THEN 1
+		            ELSE word_similarity(q.text, search_name) END AS score
+		FROM routes, q
+		WHERE search_name %> q.text OR lower(short_name) = lower(btrim($1))
+		ORDER BY score DESC, short_name, long_name
+		LIMIT $2
+	`, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []domain.SearchHit
+	for rows.Next() {
+		rt := &domain.Route{}
+		h := domain.SearchHit{Type: domain.SearchHitRoute, Route: rt}
+		if err := rows.Scan(&rt.ID, &rt.RouteID, &rt.AgencyID, &rt.ShortName, &rt.LongName,
+			&rt.RouteType, &rt.Color, &rt.TextColor, &rt.CreatedAt, &rt.UpdatedAt, &h.Score); err != nil {
+			return nil, err
+		}
+		hits = append(hits, h)
+	}
+	return hits, rows.Err()
+}
+
+// Stops expands the query with its search synonyms like StopRepo.Search,
+// without ranking by distance.
+func (r *GlobalSearchRepo) Stops(ctx context.Context, query string, limit int) ([]domain.SearchHit, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		WITH q AS (
+			SELECT lower(immutable_unaccent(btrim($1))) AS text
+		), terms AS (
+			SELECT q.text, 1::float8 AS boost, true AS original FROM q
+			UNION ALL
+			SELECT ss.synonym, ss.boost, false FROM search_synonyms ss, q WHERE ss.term = q.text
+			UNION ALL
+			SELECT ss.term, ss.boost, false FROM search_synonyms ss, q WHERE ss.synonym = q.text AND NOT ss.one_way
+		), matched AS (
+			SELECT DISTINCT ON (s.id) s.*,
+			       CASE WHEN t.original AND lower(s.stop_code) = lower($1) THEN 1
+			            ELSE word_similarity(t.text, s.search_name) * t.boost END AS score
+			FROM stops s, terms t
+			WHERE s.location_type = 0
+			  AND ((t.original AND s.name_vector @@ plainto_tsquery('spanish', $1))
+			       OR s.search_name %> t.text
+			       OR (t.original AND lower(s.stop_code) = lower($1)))
+			ORDER BY s.id, score DESC
+		)
+		SELECT id, stop_id, COALESCE(stop_code, ''), agency_id, name,
+		       ST_Y(location::geometry) as lat,
+		       ST_X(location::geometry) as lon,
+		       COALESCE(platform_code, ''), wheelchair_accessible,
+		       location_type, COALESCE(parent_id::text, ''),
+		       shelter, bench, realtime_display, COALESCE(amenities_source, ''), amenities_updated_at,
+		       created_at, updated_at, score
+		FROM matched
+		ORDER BY score DESC, name
+		LIMIT $2
+	`, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []domain.SearchHit
+	for rows.Next() {
+		s := &domain.Stop{}
+		h := domain.SearchHit{Type: domain.SearchHitStop, Stop: s}
+		if err := rows.Scan(
+			&s.ID, &s.StopID, &s.StopCode, &s.AgencyID, &s.Name,
+			&s.Location.Lat, &s.Location.Lon,
+			&s.PlatformCode, &s.WheelchairAccessible,
+			&s.LocationType, &s.ParentID,
+			&s.Amenities.Shelter, &s.Amenities.Bench, &s.Amenities.RealtimeDisplay,
+			&s.Amenities.Source, &s.Amenities.UpdatedAt,
+			&s.CreatedAt, &s.UpdatedAt, &h.Score,
+		); err != nil {
+			return nil, err
+		}
+		hits = append(hits, h)
+	}
+	return hits, rows.Err()
+}
@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// HistoryRepo implements ports.HistoryRepository.
+type HistoryRepo struct {
+	db *DB
+}
+
+func NewHistoryRepo(db *DB) *HistoryRepo {
+	return &HistoryRepo{db: db}
+}
+
+func (r *HistoryRepo) SetConsent(ctx context.Context, userID string, granted bool) error {
+	if !granted {
+		_, err := r.db.Pool.Exec(ctx, `DELETE FROM history_consents WHERE user_id = $1`, userID)
+		return err
+	}
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO history_consents (user_id) VALUES ($1)
+		ON CONFLICT (user_id) DO NOTHING
+	`, userID)
+	return err
+}
+
+func (r *HistoryRepo) HasConsent(ctx context.Context, userID string) (bool, error) {
+	var ok bool
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM history_consents WHERE user_id = $1)
+	`, userID).Scan(&ok)
+	return ok, err
+}
+
+func (r *HistoryRepo) Insert(ctx context.Context, j *domain.RiderJourney) error {
+	return r.db.Pool.QueryRow(ctx, `
+		INSERT INTO rider_journeys (user_id, trip_id, route_id, route_type, from_stop_id, to_stop_id,
+		                            departed_at, arrived_at, distance_meters, co2_saved_grams, source)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at
+	`, j.UserID, nilIfEmpty(j.TripID), nilIfEmpty(j.RouteID), j.RouteType,
+		nilIfEmpty(j.FromStopID), nilIfEmpty(j.ToStopID),
+		j.DepartedAt, j.ArrivedAt, j.DistanceMeters, j.CO2SavedGrams, j.Source,
+	).Scan(&j.ID, &j.CreatedAt)
+}
+
+// ListByUser returns journeys departed in [from, to), newest first.
+func (r *HistoryRepo) ListByUser(ctx context.Context, userID string, from, to time.Time) ([]domain.RiderJourney, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, user_id, trip_id, route_id, COALESCE(route_type, 3), from_stop_id, to_stop_id,
+		       departed_at, arrived_at, distance_meters, co2_saved_grams, source, created_at
+		FROM rider_journeys
+		WHERE user_id = $1 AND departed_at >= $2 AND departed_at < $3
+		ORDER BY departed_at DESC
+	`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var journeys []domain.RiderJourney
+	for rows.Next() {
+		var j domain.RiderJourney
+		var tripID, routeID, fromStop, toStop sql.NullString
+		if err := rows.Scan(&j.ID, &j.UserID, &tripID, &routeID, &j.RouteType, &fromStop, &toStop,
+			&j.DepartedAt, &j.ArrivedAt, &j.DistanceMeters, &j.CO2SavedGrams, &j.Source, &j.CreatedAt); err != nil {
+			return nil, err
+		}
+		j.TripID = tripID.String
+		j.RouteID = routeID.String
+		j.FromStopID = fromStop.String
+		j.ToStopID = toStop.String
+		journeys = append(journeys, j)
+	}
+	return journeys, rows.Err()
+}
+
+func (r *HistoryRepo) DeleteByUser(ctx context.Context, userID string) error {
+	_, err := r.db.Pool.Exec(ctx, `DELETE FROM rider_journeys WHERE user_id = $1`, userID)
+	return err
+}
+
+func (r *HistoryRepo) RidersOnTrip(ctx context.Context, tripID string, from, to time.Time) ([]string, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT DISTINCT user_id
+		FROM rider_journeys
+		WHERE trip_id = $1 AND departed_at >= $2 AND departed_at < $3
+	`, tripID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []string
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
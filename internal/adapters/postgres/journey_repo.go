@@ -20,7 +20,13 @@ func NewJourneyRepo(db *DB) *JourneyRepo {
 // It uses a two-phase approach:
 //  1. Find direct trips (single leg, no transfers)
 //  2. Find 1-transfer connections via shared intermediate stops
-func (r *JourneyRepo) FindJourneys(ctx context.Context, fromStopID, toStopID string, departAfter time.Time, maxTransfers int, limit int) ([]domain.Journey, error) {
+//
+// The API plans on the in-memory timetable instead, and only uses this until
+// the timetable is loaded. Trips run by frequency only count once, at their
+// stop times. Trips of the previous service day still running after midnight
+// (times of 24:00 and later) are matched too, and a change may be to a trip
+// of the previous or the next service day.
+func (r *JourneyRepo) FindJourneys(ctx context.Context, fromStopID, toStopID string, departAfter time.Time, maxTransfers int, limit int, filter domain.JourneyFilter) ([]domain.Journey, error) {
 	if limit <= 0 || limit > 20 {
 		limit = 5
 	}
@@ -33,8 +39,8 @@ func (r *JourneyRepo) FindJourneys(ctx context.Context, fromStopID, toStopID str
 	// Phase 1: Direct journeys (same trip serves both stops, from before to in sequence)
 	directRows, err := r.db.Pool.Query(ctx, `
         SELECT
-            st_from.departure_time AS dep_time,
-            st_to.arrival_time AS arr_time,
+            st_from.departure_time - shift.d AS dep_time,
+            st_to.arrival_time - shift.d AS arr_time,
             t.id AS trip_id, t.trip_id AS trip_code, COALESCE(t.headsign, '') AS headsign, COALESCE(t.direction_id, 0),
             r.id AS route_id, r.route_id AS route_code, COALESCE(r.short_name, '') AS short_name,
             r.long_name, r.route_type, r.color, r.text_color,
@@ -48,13 +54,21 @@ func (r *JourneyRepo) FindJourneys(ctx context.Context, fromStopID, toStopID str
         JOIN routes r ON r.id = t.route_id
         JOIN stops fs ON fs.id = st_from.stop_id
         JOIN stops ts ON ts.id = st_to.stop_id
+        CROSS JOIN LATERAL (
+            SELECT CASE WHEN st_from.departure_time >= make_interval(secs => $3 + 86400)
+                        THEN interval '24 hours' ELSE interval '0' END AS d
+        ) shift
         WHERE st_from.stop_id = $1
           AND st_to.stop_id = $2
           AND st_from.stop_sequence < st_to.stop_sequence
           AND st_from.departure_time >= make_interval(secs => $3)
-        ORDER BY st_from.departure_time
+          AND ($5::int[] IS NULL OR r.route_type = ANY($5))
+          AND ($6::int[] IS NULL OR r.route_type <> ALL($6))
+          AND (NOT $7 OR (t.wheelchair_accessible AND fs.wheelchair_accessible AND ts.wheelchair_accessible))
+          AND (NOT $8 OR t.bikes_allowed)
+        ORDER BY dep_time
         LIMIT $4
-    `, fromStopID, toStopID, todSeconds, limit)
+    `, fromStopID, toStopID, todSeconds, limit, filter.RouteTypes.Include, filter.RouteTypes.Exclude, filter.Wheelchair, filter.Bikes)
 	if err != nil {
 		return nil, err
 	}
@@ -117,25 +131,31 @@ func (r *JourneyRepo) FindJourneys(ctx context.Context, fromStopID, toStopID str
                 SELECT
                     st1_from.stop_id AS from_stop,
                     st1_to.stop_id AS transfer_stop,
-                    st1_from.departure_time AS dep1,
-                    st1_to.arrival_time AS arr1,
+                    st1_from.departure_time - shift.d AS dep1,
+                    st1_to.arrival_time - shift.d AS arr1,
                     st1_from.trip_id AS trip1_id
                 FROM stop_times st1_from
                 JOIN stop_times st1_to ON st1_from.trip_id = st1_to.trip_id
                     AND st1_from.stop_sequence < st1_to.stop_sequence
+                CROSS JOIN LATERAL (
+                    SELECT CASE WHEN st1_from.departure_time >= make_interval(secs => $3 + 86400)
+                                THEN interval '24 hours' ELSE interval '0' END AS d
+                ) shift
                 WHERE st1_from.stop_id = $1
                   AND st1_from.departure_time >= make_interval(secs => $3)
             ),
+            -- On the previous, the same or the next service day
             leg2 AS (
                 SELECT
                     st2_from.stop_id AS transfer_stop,
                     st2_to.stop_id AS to_stop,
-                    st2_from.departure_time AS dep2,
-                    st2_to.arrival_time AS arr2,
+                    st2_from.departure_time - day.d AS dep2,
+                    st2_to.arrival_time - day.d AS arr2,
                     st2_from.trip_id AS trip2_id
                 FROM stop_times st2_from
                 JOIN stop_times st2_to ON st2_from.trip_id = st2_to.trip_id
                     AND st2_from.stop_sequence < st2_to.stop_sequence
+                CROSS JOIN (VALUES (interval '24 hours'), (interval '0'), (interval '-24 hours')) AS day(d)
                 WHERE st2_to.stop_id = $2
             )
             SELECT
@@ -161,9 +181,14 @@ func (r *JourneyRepo) FindJourneys(ctx context.Context, fromStopID, toStopID str
             JOIN stops xs ON xs.id = l1.transfer_stop
             JOIN stops ds ON ds.id = l2.to_stop
             WHERE r1.id != r2.id
+              AND ($5::int[] IS NULL OR (r1.route_type = ANY($5) AND r2.route_type = ANY($5)))
+              AND ($6::int[] IS NULL OR (r1.route_type <> ALL($6) AND r2.route_type <> ALL($6)))
+              AND (NOT $7 OR (t1.wheelchair_accessible AND t2.wheelchair_accessible
+                              AND fs.wheelchair_accessible AND xs.wheelchair_accessible AND ds.wheelchair_accessible))
+              AND (NOT $8 OR (t1.bikes_allowed AND t2.bikes_allowed))
             ORDER BY l2.arr2 - l1.dep1
             LIMIT $4
-        `, fromStopID, toStopID, todSeconds, remaining)
+        `, fromStopID, toStopID, todSeconds, remaining, filter.RouteTypes.Include, filter.RouteTypes.Exclude, filter.Wheelchair, filter.Bikes)
 		if err != nil {
 			// Transfer query is optional — log and continue with direct results
 			return journeys, nil
@@ -238,3 +263,137 @@ func (r *JourneyRepo) FindJourneys(ctx context.Context, fromStopID, toStopID str
 
 	return journeys, nil
 }
+
+// FindJourneysBetween only plans between the nearest origin and destination
+// stops.
+func (r *JourneyRepo) FindJourneysBetween(ctx context.Context, origins, destinations []domain.StopAccess, departAfter time.Time, maxTransfers int, limit int, filter domain.JourneyFilter) ([]domain.Journey, error) {
+	if len(origins) == 0 || len(destinations) == 0 {
+		return nil, nil
+	}
+	return r.FindJourneys(ctx, origins[0].StopID, destinations[0].StopID, departAfter.Add(origins[0].Walk), maxTransfers, limit, filter)
+}
+
+// FindReachable only finds the stops one vehicle reaches from the nearest
+// origin stop, and the stop itself.
+func (r *JourneyRepo) FindReachable(ctx context.Context, origins []domain.StopAccess, departAfter time.Time, within time.Duration, maxTransfers int, filter domain.JourneyFilter) ([]domain.ReachableStop, error) {
+	if len(origins) == 0 {
+		return nil, nil
+	}
+	at := departAfter.Add(origins[0].Walk)
+	todSeconds := at.Hour()*3600 + at.Minute()*60 + at.Second()
+	untilSeconds := todSeconds + int((within - origins[0].Walk).Seconds())
+	today := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+
+	rows, err := r.db.Pool.Query(ctx, `
+		WITH reach AS (
+			SELECT st_to.stop_id, MIN(st_to.arrival_time) AS arr
+			FROM stop_times st_from
+			JOIN stop_times st_to ON st_to.trip_id = st_from.trip_id
+			    AND st_to.stop_sequence > st_from.stop_sequence
+			JOIN trips t ON t.id = st_from.trip_id
+			JOIN routes r ON r.id = t.route_id
+			JOIN stops ts ON ts.id = st_to.stop_id
+			WHERE st_from.stop_id = $1
+			  AND st_from.departure_time >= make_interval(secs => $2)
+			  AND st_to.arrival_time <= make_interval(secs => $3)
+			  AND ($4::int[] IS NULL OR r.route_type = ANY($4))
+			  AND ($5::int[] IS NULL OR r.route_type <> ALL($5))
+			  AND (NOT $6 OR (t.wheelchair_accessible AND ts.wheelchair_accessible))
+			  AND (NOT $7 OR t.bikes_allowed)
+			  AND st_to.stop_id <> $1
+			GROUP BY st_to.stop_id
+			UNION ALL
+			SELECT $1::uuid, make_interval(secs => $2)
+		)
+		SELECT reach.arr, s.id, s.stop_id, s.agency_id, s.name,
+		       ST_Y(s.location::geometry), ST_X(s.location::geometry), s.wheelchair_accessible
+		FROM reach
+		JOIN stops s ON s.id = reach.stop_id
+		ORDER BY reach.arr
+	`, origins[0].StopID, todSeconds, untilSeconds, filter.RouteTypes.Include, filter.RouteTypes.Exclude, filter.Wheelchair, filter.Bikes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.ReachableStop
+	for rows.Next() {
+		var arr time.Duration
+		var stop domain.Stop
+		if err := rows.Scan(&arr, &stop.ID, &stop.StopID, &stop.AgencyID, &stop.Name,
+			&stop.Location.Lat, &stop.Location.Lon, &stop.WheelchairAccessible); err != nil {
+			return nil, err
+		}
+		out = append(out, domain.ReachableStop{Stop: &stop, ArrivalTime: today.Add(arr)})
+	}
+	return out, rows.Err()
+}
+
+// FindNightLines finds later services between the stops around the origin and
+// the destination. Trips of the previous service day running past midnight
+// (times of 24:00 and later) are matched too.
+func (r *JourneyRepo) FindNightLines(ctx context.Context, fromStopID, toStopID string, departAfter time.Time, window time.Duration, radiusMeters float64, limit int) ([]domain.NightLine, error) {
+	todSeconds := departAfter.Hour()*3600 + departAfter.Minute()*60 + departAfter.Second()
+	today := time.Date(departAfter.Year(), departAfter.Month(), departAfter.Day(), 0, 0, 0, 0, departAfter.Location())
+
+	rows, err := r.db.Pool.Query(ctx, `
+		WITH candidates AS (
+			SELECT DISTINCT ON (t.route_id)
+			       st_from.departure_time - shift.d AS dep_time,
+			       st_to.arrival_time - shift.d AS arr_time,
+			       t.route_id, st_from.stop_id AS from_stop, st_to.stop_id AS to_stop
+			FROM stops o
+			JOIN stops d ON d.id = $2
+			JOIN stops fs ON ST_DWithin(fs.location, o.location, $5)
+			JOIN stop_times st_from ON st_from.stop_id = fs.id
+			CROSS JOIN LATERAL (
+				SELECT CASE WHEN st_from.departure_time >= make_interval(secs => $3 + 86400)
+				            THEN interval '24 hours' ELSE interval '0' END AS d
+			) shift
+			JOIN stop_times st_to ON st_to.trip_id = st_from.trip_id
+			    AND st_to.stop_sequence > st_from.stop_sequence
+			JOIN stops ts ON ts.id = st_to.stop_id AND ST_DWithin(ts.location, d.location, $5)
+			JOIN trips t ON t.id = st_from.trip_id
+			WHERE o.id = $1
+			  AND (st_from.departure_time BETWEEN make_interval(secs => $3) AND make_interval(secs => $3 + $4)
+			       OR st_from.departure_time BETWEEN make_interval(secs => $3 + 86400) AND make_interval(secs => $3 + $4 + 86400))
+			ORDER BY t.route_id, st_to.arrival_time - shift.d
+		)
+		SELECT c.dep_time, c.arr_time,
+		       r.id, r.route_id, COALESCE(r.short_name, ''), r.long_name, r.route_type, r.color, r.text_color,
+		       fs.id, fs.stop_id, fs.name, ST_Y(fs.location::geometry), ST_X(fs.location::geometry),
+		       ts.id, ts.stop_id, ts.name, ST_Y(ts.location::geometry), ST_X(ts.location::geometry)
+		FROM candidates c
+		JOIN routes r ON r.id = c.route_id
+		JOIN stops fs ON fs.id = c.from_stop
+		JOIN stops ts ON ts.id = c.to_stop
+		ORDER BY c.arr_time
+		LIMIT $6
+	`, fromStopID, toStopID, todSeconds, int(window.Seconds()), radiusMeters, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.NightLine
+	for rows.Next() {
+		var dep, arr time.Duration
+		var route domain.Route
+		var fromStop, toStop domain.Stop
+		if err := rows.Scan(&dep, &arr,
+			&route.ID, &route.RouteID, &route.ShortName, &route.LongName, &route.RouteType, &route.Color, &route.TextColor,
+			&fromStop.ID, &fromStop.StopID, &fromStop.Name, &fromStop.Location.Lat, &fromStop.Location.Lon,
+			&toStop.ID, &toStop.StopID, &toStop.Name, &toStop.Location.Lat, &toStop.Location.Lon,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, domain.NightLine{
+			Route:         &route,
+			FromStop:      &fromStop,
+			ToStop:        &toStop,
+			DepartureTime: today.Add(dep),
+			ArrivalTime:   today.Add(arr),
+		})
+	}
+	return out, rows.Err()
+}
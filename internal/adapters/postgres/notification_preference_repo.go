@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// NotificationPreferenceRepo implements ports.NotificationPreferenceRepository.
+type NotificationPreferenceRepo struct {
+	db *DB
+}
+
+func NewNotificationPreferenceRepo(db *DB) *NotificationPreferenceRepo {
+	return &NotificationPreferenceRepo{db: db}
+}
+
+func (r *NotificationPreferenceRepo) Get(ctx context.Context, userID string) (*domain.NotificationPreferences, error) {
+	var p domain.NotificationPreferences
+	var from, until, timezone *string
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT fcm, webpush, delays, alerts, coupons, marketing,
+		       to_char(quiet_from, 'HH24:MI'), to_char(quiet_until, 'HH24:MI'), quiet_timezone, updated_at
+		FROM notification_preferences WHERE user_id = $1
+	`, userID).Scan(&p.Channels.FCM, &p.Channels.WebPush,
+		&p.Categories.Delays, &p.Categories.Alerts, &p.Categories.Coupons, &p.Categories.Marketing,
+		&from, &until, &timezone, &p.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if from != nil && until != nil && timezone != nil {
+		p.QuietHours = &domain.QuietHours{From: *from, Until: *until, Timezone: *timezone}
+	}
+	return &p, nil
+}
+
+func (r *NotificationPreferenceRepo) Save(ctx context.Context, userID string, p *domain.NotificationPreferences) error {
+	var from, until, timezone interface{}
+	if q := p.QuietHours; q != nil {
+		from, until, timezone = q.From, q.Until, q.Timezone
+	}
+	return r.db.Pool.QueryRow(ctx, `
+		INSERT INTO notification_preferences (user_id, fcm, webpush, delays, alerts, coupons, marketing,
+		                                      quiet_from, quiet_until, quiet_timezone)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8::time, $9::time, $10)
+		ON CONFLICT (user_id) DO UPDATE SET
+			fcm = EXCLUDED.fcm, webpush = EXCLUDED.webpush,
+			delays = EXCLUDED.delays, alerts = EXCLUDED.alerts,
+			coupons = EXCLUDED.coupons, marketing = EXCLUDED.marketing,
+			quiet_from = EXCLUDED.quiet_from, quiet_until = EXCLUDED.quiet_until,
+			quiet_timezone = EXCLUDED.quiet_timezone, updated_at = NOW()
+		RETURNING updated_at
+	`, userID, p.Channels.FCM, p.Channels.WebPush,
+		p.Categories.Delays, p.Categories.Alerts, p.Categories.Coupons, p.Categories.Marketing,
+		from, until, timezone).Scan(&p.UpdatedAt)
+}
@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// OccupancyRepo implements ports.OccupancyRepository over vehicle_positions
+// and its stop_occupancy_hourly continuous aggregate.
+type OccupancyRepo struct {
+	db *DB
+}
+
+func NewOccupancyRepo(db *DB) *OccupancyRepo { return &OccupancyRepo{db: db} }
+
+// LatestByRoute takes each vehicle's latest position and keeps those that
+// carry an occupancy, so an old report is not shown once a vehicle stops
+// sending one.
+func (r *OccupancyRepo) LatestByRoute(ctx context.Context, routeID string, since time.Time) ([]domain.VehicleOccupancy, error) {
+	return r.latest(ctx, `
+		WITH latest AS (
+			SELECT DISTINCT ON (vehicle_id) vehicle_id, trip_id, occupancy_status, time
+			FROM vehicle_positions
+			WHERE route_id = $1 AND time > $2
+			ORDER BY vehicle_id, time DESC
+		)
+		SELECT vehicle_id, COALESCE(trip_id::text, ''), occupancy_status, time
+		FROM latest WHERE occupancy_status IS NOT NULL
+		ORDER BY vehicle_id
+	`, routeID, since)
+}
+
+// LatestByTrips is LatestByRoute by trip.
+func (r *OccupancyRepo) LatestByTrips(ctx context.Context, tripIDs []string, since time.Time) ([]domain.VehicleOccupancy, error) {
+	return r.latest(ctx, `
+		WITH latest AS (
+			SELECT DISTINCT ON (trip_id) vehicle_id, trip_id, occupancy_status, time
+			FROM vehicle_positions
+			WHERE trip_id = ANY($1::uuid[]) AND time > $2
+			ORDER BY trip_id, time DESC
+		)
+		SELECT vehicle_id, trip_id::text, occupancy_status, time
+		FROM latest WHERE occupancy_status IS NOT NULL
+	`, tripIDs, since)
+}
+
+func (r *OccupancyRepo) latest(ctx context.Context, query string, args ...any) ([]domain.VehicleOccupancy, error) {
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.VehicleOccupancy
+	for rows.Next() {
+		var o domain.VehicleOccupancy
+		if err := rows.Scan(&o.VehicleID, &o.TripID, &o.Status, &o.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+// StopCrowding weights each hourly bucket by its number of samples.
+func (r *OccupancyRepo) StopCrowding(ctx context.Context, stopUUID, routeID string, since time.Time) ([]domain.CrowdingSlot, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT EXTRACT(DOW FROM o.bucket AT TIME ZONE a.timezone)::int,
+		       EXTRACT(HOUR FROM o.bucket AT TIME ZONE a.timezone)::int,
+		       SUM(o.avg_occupancy * o.samples) / SUM(o.samples),
+		       SUM(o.samples)::int
+		FROM stop_occupancy_hourly o
+		JOIN stops s ON s.id = o.stop_id
+		JOIN agencies a ON a.id = s.agency_id
+		WHERE o.stop_id = $1 AND ($2::uuid IS NULL OR o.route_id = $2) AND o.bucket >= $3
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`, stopUUID, nilIfEmpty(routeID), since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var slots []domain.CrowdingSlot
+	for rows.Next() {
+		var s domain.CrowdingSlot
+		var weekday int
+		if err := rows.Scan(&weekday, &s.Hour, &s.AvgStatus, &s.Samples); err != nil {
+			return nil, err
+		}
+		s.Weekday = time.Weekday(weekday)
+		slots = append(slots, s)
+	}
+	return slots, rows.Err()
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// OfflineRepo implements ports.OfflineRepository.
+type OfflineRepo struct {
+	db *DB
+}
+
+func NewOfflineRepo(db *DB) *OfflineRepo { return &OfflineRepo{db: db} }
+
+func (r *OfflineRepo) Stops(ctx context.Context, agencyID string, b domain.Bounds) ([]domain.OfflineStop, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, name, ST_Y(location::geometry), ST_X(location::geometry),
+		       COALESCE(platform_code, ''), wheelchair_accessible
+		FROM stops
+		WHERE agency_id = $1
+		  AND location && ST_MakeEnvelope($2, $3, $4, $5, 4326)::geography
+		ORDER BY id
+	`, agencyID, b.MinLon, b.MinLat, b.MaxLon, b.MaxLat)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stops []domain.OfflineStop
+	for rows.Next() {
+		var s domain.OfflineStop
+		if err := rows.Scan(&s.ID, &s.Name, &s.Lat, &s.Lon, &s.PlatformCode, &s.WheelchairAccessible); err != nil {
+			return nil, err
+		}
+		stops = append(stops, s)
+	}
+	return stops, rows.Err()
+}
+
+// Departures places each stop time on yesterday's, today's and tomorrow's
+// service day and keeps those inside the window, so times past 24:00 and
+// windows crossing midnight are covered. Stop times without pickup are left
+// out.
+func (r *OfflineRepo) Departures(ctx context.Context, stopIDs []string, from time.Time, window time.Duration) ([]domain.OfflineDeparture, error) {
+	midnight := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT st.stop_id, t.route_id, t.id, COALESCE(t.headsign, ''),
+		       $2::timestamptz + make_interval(days => d.day) + st.departure_time AS at
+		FROM stop_times st
+		JOIN trips t ON t.id = st.trip_id
+		CROSS JOIN generate_series(-1, 1) AS d(day)
+		WHERE st.stop_id = ANY($1::uuid[])
+		  AND st.pickup_type <> 1
+		  AND $2::timestamptz + make_interval(days => d.day) + st.departure_time >= $3
+		  AND $2::timestamptz + make_interval(days => d.day) + st.departure_time < $4
+		ORDER BY at
+	`, stopIDs, midnight, from, from.Add(window))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var departures []domain.OfflineDeparture
+	for rows.Next() {
+		var d domain.OfflineDeparture
+		if err := rows.Scan(&d.StopID, &d.RouteID, &d.TripID, &d.Headsign, &d.Time); err != nil {
+			return nil, err
+		}
+		departures = append(departures, d)
+	}
+	return departures, rows.Err()
+}
@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// PunctualityRepo implements ports.PunctualityRepository over the
+// stop_punctuality_hourly continuous aggregate.
+type PunctualityRepo struct {
+	db *DB
+}
+
+func NewPunctualityRepo(db *DB) *PunctualityRepo { return &PunctualityRepo{db: db} }
+
+// punctualityColumns sums the hourly rollups of a group into the columns
+// scanned by scanPunctuality.
+const punctualityColumns = `
+	SUM(p.observed)::int, SUM(p.on_time)::int, SUM(p.early)::int, SUM(p.late)::int,
+	SUM(p.delay_sum) / SUM(p.observed), MAX(p.max_delay)`
+
+// punctualityFrom selects the rollups of a domain.PunctualityFilter given as
+// $1 to $4: from, to, agency ID and route ID.
+const punctualityFrom = `
+	FROM stop_punctuality_hourly p
+	JOIN routes r ON r.id = p.route_id
+	JOIN agencies a ON a.id = r.agency_id
+	WHERE p.bucket >= $1 AND p.bucket < $2
+	  AND ($3::uuid IS NULL OR r.agency_id = $3)
+	  AND ($4::uuid IS NULL OR p.route_id = $4)`
+
+func punctualityArgs(f domain.PunctualityFilter, extra ...any) []any {
+	return append([]any{f.From, f.To, nilIfEmpty(f.AgencyID), nilIfEmpty(f.RouteID)}, extra...)
+}
+
+func scanPunctuality(rows pgx.Rows, s *domain.PunctualityStats, dest ...any) error {
+	return rows.Scan(append(dest, &s.Observed, &s.OnTime, &s.Early, &s.Late, &s.AvgDelay, &s.MaxDelay)...)
+}
+
+func (r *PunctualityRepo) ByHour(ctx context.Context, f domain.PunctualityFilter) ([]domain.HourPunctuality, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT EXTRACT(HOUR FROM p.bucket AT TIME ZONE a.timezone)::int,`+punctualityColumns+`
+		`+punctualityFrom+`
+		GROUP BY 1
+		ORDER BY 1
+	`, punctualityArgs(f)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.HourPunctuality
+	for rows.Next() {
+		var h domain.HourPunctuality
+		if err := scanPunctuality(rows, &h.PunctualityStats, &h.Hour); err != nil {
+			return nil, err
+		}
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
+
+func (r *PunctualityRepo) ByDay(ctx context.Context, f domain.PunctualityFilter) ([]domain.DayPunctuality, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT to_char(p.bucket AT TIME ZONE a.timezone, 'YYYY-MM-DD'),`+punctualityColumns+`
+		`+punctualityFrom+`
+		GROUP BY 1
+		ORDER BY 1
+	`, punctualityArgs(f)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.DayPunctuality
+	for rows.Next() {
+		var d domain.DayPunctuality
+		if err := scanPunctuality(rows, &d.PunctualityStats, &d.Date); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (r *PunctualityRepo) ByStop(ctx context.Context, f domain.PunctualityFilter, minObserved int) ([]domain.StopPunctuality, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT p.stop_id, (SELECT name FROM stops WHERE id = p.stop_id),`+punctualityColumns+`
+		`+punctualityFrom+`
+		GROUP BY p.stop_id
+		HAVING SUM(p.observed) >= $5
+	`, punctualityArgs(f, minObserved)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.StopPunctuality
+	for rows.Next() {
+		var s domain.StopPunctuality
+		if err := scanPunctuality(rows, &s.PunctualityStats, &s.StopID, &s.StopName); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (r *PunctualityRepo) ByRoute(ctx context.Context, f domain.PunctualityFilter, minObserved int) ([]domain.RoutePunctualityStats, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT r.id, COALESCE(NULLIF(r.short_name, ''), r.long_name), r.agency_id,`+punctualityColumns+`
+		`+punctualityFrom+`
+		GROUP BY r.id
+		HAVING SUM(p.observed) >= $5
+	`, punctualityArgs(f, minObserved)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.RoutePunctualityStats
+	for rows.Next() {
+		var rp domain.RoutePunctualityStats
+		if err := scanPunctuality(rows, &rp.PunctualityStats, &rp.RouteID, &rp.RouteName, &rp.AgencyID); err != nil {
+			return nil, err
+		}
+		out = append(out, rp)
+	}
+	return out, rows.Err()
+}
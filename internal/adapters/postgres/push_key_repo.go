@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// PushKeyRepo implements ports.PushKeyRepository.
+type PushKeyRepo struct {
+	db *DB
+}
+
+func NewPushKeyRepo(db *DB) *PushKeyRepo {
+	return &PushKeyRepo{db: db}
+}
+
+func (r *PushKeyRepo) ListByAgency(ctx context.Context, agencyID string) ([]domain.PushKey, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, agency_id, label, created_at
+		FROM agency_push_keys
+		WHERE agency_id = $1
+		ORDER BY created_at
+	`, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.PushKey
+	for rows.Next() {
+		var k domain.PushKey
+		if err := rows.Scan(&k.ID, &k.AgencyID, &k.Label, &k.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, k)
+	}
+	return out, rows.Err()
+}
+
+func (r *PushKeyRepo) Get(ctx context.Context, agencyID, id string) (*domain.PushKey, error) {
+	var k domain.PushKey
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT id, agency_id, label, secret, created_at
+		FROM agency_push_keys
+		WHERE agency_id = $1 AND id = $2
+	`, agencyID, id).Scan(&k.ID, &k.AgencyID, &k.Label, &k.Secret, &k.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r *PushKeyRepo) Create(ctx context.Context, k *domain.PushKey) error {
+	return r.db.Pool.QueryRow(ctx, `
+		INSERT INTO agency_push_keys (id, agency_id, label, secret)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at
+	`, k.ID, k.AgencyID, k.Label, k.Secret).Scan(&k.CreatedAt)
+}
+
+func (r *PushKeyRepo) Delete(ctx context.Context, agencyID, id string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		DELETE FROM agency_push_keys WHERE agency_id = $1 AND id = $2
+	`, agencyID, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *PushKeyRepo) UseNonce(ctx context.Context, agencyID, nonce string, at, forget time.Time) (bool, error) {
+	if _, err := r.db.Pool.Exec(ctx, `
+		DELETE FROM push_nonces WHERE agency_id = $1 AND seen_at < $2
+	`, agencyID, forget); err != nil {
+		return false, err
+	}
+	tag, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO push_nonces (agency_id, nonce, seen_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (agency_id, nonce) DO NOTHING
+	`, agencyID, nonce, at)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
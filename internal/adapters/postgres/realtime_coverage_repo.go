@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// RealtimeCoverageRepo implements ports.RealtimeCoverageRepository.
+type RealtimeCoverageRepo struct {
+	db *DB
+}
+
+func NewRealtimeCoverageRepo(db *DB) *RealtimeCoverageRepo { return &RealtimeCoverageRepo{db: db} }
+
+// Sample writes a row per route with trips running and a total per agency
+// (route_id NULL), zero when none is running. A trip runs from its first
+// departure to its last arrival in the agency's time zone, including trips
+// of the previous service day past midnight.
+func (r *RealtimeCoverageRepo) Sample(ctx context.Context, at, liveSince time.Time) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		WITH spans AS (
+			SELECT t.id, t.route_id, rt.agency_id,
+			       MIN(st.departure_time) AS first_dep, MAX(st.arrival_time) AS last_arr,
+			       ($1::timestamptz AT TIME ZONE COALESCE(a.timezone, 'Europe/Madrid'))::time - time '00:00' AS tod
+			FROM trips t
+			JOIN routes rt ON rt.id = t.route_id
+			JOIN agencies a ON a.id = rt.agency_id
+			JOIN stop_times st ON st.trip_id = t.id
+			GROUP BY t.id, t.route_id, rt.agency_id, a.timezone
+		),
+		running AS (
+			SELECT id, route_id, agency_id
+			FROM spans
+			WHERE tod BETWEEN first_dep AND last_arr
+			   OR tod + interval '24 hours' BETWEEN first_dep AND last_arr
+		),
+		live AS (
+			SELECT trip_id FROM vehicle_positions WHERE time >= $2 AND trip_id IS NOT NULL
+			UNION
+			SELECT trip_id FROM stop_time_predictions WHERE time >= $2
+		),
+		counts AS (
+			SELECT g.agency_id, g.route_id, COUNT(*)::int AS scheduled, COUNT(l.trip_id)::int AS live
+			FROM running g
+			LEFT JOIN live l ON l.trip_id = g.id
+			GROUP BY GROUPING SETS ((g.agency_id, g.route_id), (g.agency_id))
+		)
+		INSERT INTO realtime_coverage (time, agency_id, route_id, scheduled, live)
+		SELECT $1, a.id, c.route_id, COALESCE(c.scheduled, 0), COALESCE(c.live, 0)
+		FROM agencies a
+		LEFT JOIN counts c ON c.agency_id = a.id
+	`, at, liveSince)
+	return err
+}
+
+func (r *RealtimeCoverageRepo) Latest(ctx context.Context, agencyID string) (*domain.CoverageSample, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT c.time, c.route_id, COALESCE(rt.short_name, ''), c.scheduled, c.live
+		FROM realtime_coverage c
+		LEFT JOIN routes rt ON rt.id = c.route_id
+		WHERE c.agency_id = $1
+		  AND c.time = (SELECT MAX(time) FROM realtime_coverage WHERE agency_id = $1 AND route_id IS NULL)
+	`, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sample *domain.CoverageSample
+	for rows.Next() {
+		var (
+			at        time.Time
+			routeID   *string
+			shortName string
+			scheduled int
+			live      int
+		)
+		if err := rows.Scan(&at, &routeID, &shortName, &scheduled, &live); err != nil {
+			return nil, err
+		}
+		if sample == nil {
+			sample = &domain.CoverageSample{At: at}
+		}
+		if routeID == nil {
+			sample.Scheduled, sample.Live = scheduled, live
+			continue
+		}
+		sample.Routes = append(sample.Routes, domain.RouteCoverage{
+			RouteID: *routeID, ShortName: shortName, Scheduled: scheduled, Live: live,
+		})
+	}
+	return sample, rows.Err()
+}
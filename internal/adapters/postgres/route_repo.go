@@ -2,6 +2,8 @@ package postgres
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/jackc/pgx/v5"
@@ -51,19 +53,49 @@ func (r *RouteRepo) UpsertBatch(ctx context.Context, routes []domain.Route) erro
 func (r *RouteRepo) GetByID(ctx context.Context, id string) (*domain.Route, error) {
 	var rt domain.Route
 	err := r.db.Pool.QueryRow(ctx, `
-		SELECT id, route_id, agency_id, short_name, long_name, route_type, color, text_color, created_at
+		SELECT id, route_id, agency_id, short_name, long_name, route_type, color, text_color, metadata, created_at, updated_at
 		FROM routes WHERE id = $1
 	`, id).Scan(&rt.ID, &rt.RouteID, &rt.AgencyID, &rt.ShortName, &rt.LongName,
-		&rt.RouteType, &rt.Color, &rt.TextColor, &rt.CreatedAt)
+		&rt.RouteType, &rt.Color, &rt.TextColor, &rt.Metadata, &rt.CreatedAt, &rt.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &rt, nil
 }
 
+// GetByIDs returns multiple routes by UUID, in arbitrary order.
+func (r *RouteRepo) GetByIDs(ctx context.Context, ids []string) ([]domain.Route, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, route_id, agency_id, short_name, long_name, route_type, color, text_color, metadata, created_at, updated_at
+		FROM routes WHERE id = ANY($1)
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var routes []domain.Route
+	for rows.Next() {
+		var rt domain.Route
+		if err := rows.Scan(&rt.ID, &rt.RouteID, &rt.AgencyID, &rt.ShortName, &rt.LongName,
+			&rt.RouteType, &rt.Color, &rt.TextColor, &rt.Metadata, &rt.CreatedAt, &rt.UpdatedAt); err != nil {
+			return nil, err
+		}
+		routes = append(routes, rt)
+	}
+	return routes, rows.Err()
+}
+
 func (r *RouteRepo) ListByAgency(ctx context.Context, agencyID string) ([]domain.Route, error) {
 	rows, err := r.db.Pool.Query(ctx, `
-		SELECT id, route_id, agency_id, short_name, long_name, route_type, color, text_color, created_at
+		SELECT id, route_id, agency_id, short_name, long_name, route_type, color, text_color, created_at, updated_at
 		FROM routes WHERE agency_id = $1 ORDER BY short_name
 	`, agencyID)
 	if err != nil {
@@ -75,7 +107,7 @@ func (r *RouteRepo) ListByAgency(ctx context.Context, agencyID string) ([]domain
 	for rows.Next() {
 		var rt domain.Route
 		if err := rows.Scan(&rt.ID, &rt.RouteID, &rt.AgencyID, &rt.ShortName, &rt.LongName,
-			&rt.RouteType, &rt.Color, &rt.TextColor, &rt.CreatedAt); err != nil {
+			&rt.RouteType, &rt.Color, &rt.TextColor, &rt.CreatedAt, &rt.UpdatedAt); err != nil {
 			return nil, err
 		}
 		routes = append(routes, rt)
@@ -83,11 +115,55 @@ func (r *RouteRepo) ListByAgency(ctx context.Context, agencyID string) ([]domain
 	return routes, rows.Err()
 }
 
+func (r *RouteRepo) ListAll(ctx context.Context, filter domain.RouteFilter, offset, limit int) ([]domain.Route, int, error) {
+	// A query matches like GlobalSearchRepo.Routes: a word run of the
+	// unaccented names, or the short name exactly.
+	const where = `
+		WHERE ($1::uuid IS NULL OR agency_id = $1::uuid)
+		  AND ($2::int[] IS NULL OR route_type = ANY($2))
+		  AND ($3::int[] IS NULL OR route_type <> ALL($3))
+		  AND ($4::text = '' OR search_name %> lower(immutable_unaccent($4)) OR lower(short_name) = lower($4))`
+	args := []any{nilIfEmpty(filter.AgencyID), filter.RouteTypes.Include, filter.RouteTypes.Exclude, filter.Query}
+
+	var total int
+	if err := r.db.Pool.QueryRow(ctx, `SELECT count(*) FROM routes`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	if total == 0 || offset >= total {
+		return nil, total, nil
+	}
+
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, route_id, agency_id, COALESCE(short_name, ''), long_name, route_type, color, text_color, created_at, updated_at
+		FROM routes`+where+`
+		ORDER BY CASE WHEN $4 = '' THEN 0
+		              WHEN lower(short_name) = lower($4) THEN -1
+		              ELSE -word_similarity(lower(immutable_unaccent($4)), search_name) END,
+		         short_name, long_name, id
+		OFFSET $5 LIMIT $6
+	`, append(args, offset, limit)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var routes []domain.Route
+	for rows.Next() {
+		var rt domain.Route
+		if err := rows.Scan(&rt.ID, &rt.RouteID, &rt.AgencyID, &rt.ShortName, &rt.LongName,
+			&rt.RouteType, &rt.Color, &rt.TextColor, &rt.CreatedAt, &rt.UpdatedAt); err != nil {
+			return nil, 0, err
+		}
+		routes = append(routes, rt)
+	}
+	return routes, total, rows.Err()
+}
+
 // ListByStop returns the distinct routes that serve a given stop (via stop_times + trips).
 func (r *RouteRepo) ListByStop(ctx context.Context, stopUUID string) ([]domain.Route, error) {
 	rows, err := r.db.Pool.Query(ctx, `
 		SELECT DISTINCT r.id, r.route_id, r.agency_id, r.short_name, r.long_name,
-		       r.route_type, r.color, r.text_color, r.created_at
+		       r.route_type, r.color, r.text_color, r.created_at, r.updated_at
 		FROM routes r
 		JOIN trips t ON t.route_id = r.id
 		JOIN stop_times st ON st.trip_id = t.id
@@ -103,10 +179,44 @@ func (r *RouteRepo) ListByStop(ctx context.Context, stopUUID string) ([]domain.R
 	for rows.Next() {
 		var rt domain.Route
 		if err := rows.Scan(&rt.ID, &rt.RouteID, &rt.AgencyID, &rt.ShortName, &rt.LongName,
-			&rt.RouteType, &rt.Color, &rt.TextColor, &rt.CreatedAt); err != nil {
+			&rt.RouteType, &rt.Color, &rt.TextColor, &rt.CreatedAt, &rt.UpdatedAt); err != nil {
 			return nil, err
 		}
 		routes = append(routes, rt)
 	}
 	return routes, rows.Err()
 }
+
+// GetShape returns the route geometry. Shape is nil when the feed had no shapes.txt.
+func (r *RouteRepo) GetShape(ctx context.Context, id string) (*domain.GeoLineString, error) {
+	var raw []byte
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT ST_AsGeoJSON(shape::geometry) FROM routes WHERE id = $1
+	`, id).Scan(&raw)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, nil
+	}
+	return decodeLineString(raw)
+}
+
+// decodeLineString parses a GeoJSON LineString as produced by ST_AsGeoJSON.
+func decodeLineString(raw []byte) (*domain.GeoLineString, error) {
+	var geom struct {
+		Coordinates [][]float64 `json:"coordinates"`
+	}
+	if err := json.Unmarshal(raw, &geom); err != nil {
+		return nil, fmt.Errorf("decode shape: %w", err)
+	}
+
+	ls := &domain.GeoLineString{Coordinates: make([]domain.GeoPoint, 0, len(geom.Coordinates))}
+	for _, c := range geom.Coordinates {
+		if len(c) < 2 {
+			continue
+		}
+		ls.Coordinates = append(ls.Coordinates, domain.GeoPoint{Lat: c[1], Lon: c[0]})
+	}
+	return ls, nil
+}
@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// RealtimeFeedStatusRepo implements ports.RealtimeFeedStatusRepository.
+type RealtimeFeedStatusRepo struct {
+	db *DB
+}
+
+func NewRealtimeFeedStatusRepo(db *DB) *RealtimeFeedStatusRepo {
+	return &RealtimeFeedStatusRepo{db: db}
+}
+
+func (r *RealtimeFeedStatusRepo) RecordPoll(ctx context.Context, p *domain.RealtimeFeedPoll) error {
+	var feedTS *time.Time
+	if !p.FeedTimestamp.IsZero() {
+		feedTS = &p.FeedTimestamp
+	}
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO rt_feed_status AS s
+			(agency_id, feed, last_poll_at, last_success_at, feed_timestamp, entities, failures, last_error)
+		VALUES ($1, $2, $3, CASE WHEN $6 = '' THEN $3::timestamptz END, $4, $5,
+		        CASE WHEN $6 = '' THEN 0 ELSE 1 END, NULLIF($6, ''))
+		ON CONFLICT (agency_id, feed) DO UPDATE
+		SET last_poll_at = EXCLUDED.last_poll_at,
+		    last_success_at = COALESCE(EXCLUDED.last_success_at, s.last_success_at),
+		    feed_timestamp = CASE WHEN EXCLUDED.last_error IS NULL THEN EXCLUDED.feed_timestamp ELSE s.feed_timestamp END,
+		    entities = CASE WHEN EXCLUDED.last_error IS NULL THEN EXCLUDED.entities ELSE s.entities END,
+		    failures = CASE WHEN EXCLUDED.last_error IS NULL THEN 0 ELSE s.failures + 1 END,
+		    last_error = EXCLUDED.last_error
+	`, p.AgencyID, p.Feed, p.At, feedTS, p.Entities, p.Err)
+	return err
+}
+
+func (r *RealtimeFeedStatusRepo) List(ctx context.Context) ([]domain.RealtimeFeedStatus, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT s.agency_id, a.slug, s.feed, s.last_poll_at, s.last_success_at, s.feed_timestamp,
+		       s.entities, s.failures, COALESCE(s.last_error, '')
+		FROM rt_feed_status s
+		JOIN agencies a ON a.id = s.agency_id
+		ORDER BY a.slug, s.feed
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var statuses []domain.RealtimeFeedStatus
+	for rows.Next() {
+		var st domain.RealtimeFeedStatus
+		if err := rows.Scan(&st.AgencyID, &st.AgencySlug, &st.Feed, &st.LastPollAt, &st.LastSuccessAt,
+			&st.FeedTimestamp, &st.Entities, &st.Failures, &st.LastError); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses, rows.Err()
+}
@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// SearchLogRepo implements ports.SearchLogRepository.
+type SearchLogRepo struct {
+	db *DB
+}
+
+func NewSearchLogRepo(db *DB) *SearchLogRepo {
+	return &SearchLogRepo{db: db}
+}
+
+// Record stores the query normalized like stop names are for search, so
+// variants of a missed query count together.
+func (r *SearchLogRepo) Record(ctx context.Context, query string, results int, at time.Time) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO stop_searches (time, query, results)
+		VALUES ($1, lower(immutable_unaccent(btrim($2))), $3)
+	`, at, query, results)
+	return err
+}
+
+func (r *SearchLogRepo) Stats(ctx context.Context, from, to time.Time, limit int) (*domain.SearchStats, error) {
+	stats := &domain.SearchStats{From: from, To: to, TopMisses: []domain.SearchMiss{}}
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT COUNT(*)::int, COUNT(*) FILTER (WHERE results > 0)::int
+		FROM stop_searches
+		WHERE time >= $1 AND time < $2
+	`, from, to).Scan(&stats.Searches, &stats.Hits)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT query, COUNT(*)::int AS searches
+		FROM stop_searches
+		WHERE time >= $1 AND time < $2 AND results = 0
+		GROUP BY query
+		ORDER BY searches DESC, query
+		LIMIT $3
+	`, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m domain.SearchMiss
+		if err := rows.Scan(&m.Query, &m.Searches); err != nil {
+			return nil, err
+		}
+		stats.TopMisses = append(stats.TopMisses, m)
+	}
+	return stats, rows.Err()
+}
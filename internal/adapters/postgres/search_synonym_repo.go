@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// SearchSynonymRepo implements ports.SearchSynonymRepository.
+type SearchSynonymRepo struct {
+	db *DB
+}
+
+func NewSearchSynonymRepo(db *DB) *SearchSynonymRepo {
+	return &SearchSynonymRepo{db: db}
+}
+
+func (r *SearchSynonymRepo) List(ctx context.Context) ([]domain.SearchSynonym, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, term, synonym, one_way, boost, created_at
+		FROM search_synonyms
+		ORDER BY term, synonym
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.SearchSynonym
+	for rows.Next() {
+		var s domain.SearchSynonym
+		if err := rows.Scan(&s.ID, &s.Term, &s.Synonym, &s.OneWay, &s.Boost, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (r *SearchSynonymRepo) Create(ctx context.Context, s *domain.SearchSynonym) (bool, error) {
+	err := r.db.Pool.QueryRow(ctx, `
+		INSERT INTO search_synonyms (term, synonym, one_way, boost)
+		VALUES (lower(immutable_unaccent($1)), lower(immutable_unaccent($2)), $3, $4)
+		ON CONFLICT (term, synonym) DO NOTHING
+		RETURNING id, term, synonym, created_at
+	`, s.Term, s.Synonym, s.OneWay, s.Boost).Scan(&s.ID, &s.Term, &s.Synonym, &s.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *SearchSynonymRepo) Delete(ctx context.Context, id string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `DELETE FROM search_synonyms WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// segmentVisitMargin is how far either side of the hour positions are read,
+// so visits to stops near its edges are whole.
+const segmentVisitMargin = 30 * time.Minute
+
+// SegmentStatsRepo implements ports.SegmentStatsRepository.
+type SegmentStatsRepo struct {
+	db *DB
+}
+
+func NewSegmentStatsRepo(db *DB) *SegmentStatsRepo { return &SegmentStatsRepo{db: db} }
+
+// RollupHour finds each trip's visits to its stops, from its first to its
+// last position near the stop, and sums the time between leaving a stop and
+// reaching the next one and the time spent at the stop. Segments whose
+// stops were not both visited are left out; so is the dwell at the first
+// stop visited, which includes layovers at the terminus.
+func (r *SegmentStatsRepo) RollupHour(ctx context.Context, hour time.Time, radiusMeters float64) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		WITH visits AS (
+			SELECT vp.trip_id, vp.route_id, st.stop_id, st.stop_sequence,
+			       MIN(vp.time) AS arrived, MAX(vp.time) AS departed
+			FROM vehicle_positions vp
+			JOIN stop_times st ON st.trip_id = vp.trip_id
+			JOIN stops s ON s.id = st.stop_id
+			WHERE vp.time >= $1 AND vp.time < $2
+			  AND vp.route_id IS NOT NULL
+			  AND ST_DWithin(vp.location, s.location, $5)
+			GROUP BY vp.trip_id, vp.route_id, st.stop_id, st.stop_sequence
+		), segments AS (
+			SELECT route_id, stop_id, stop_sequence, arrived, departed,
+			       LAG(stop_id) OVER w AS prev_stop_id,
+			       LEAD(stop_id) OVER w AS next_stop_id,
+			       LEAD(stop_sequence) OVER w AS next_sequence,
+			       LEAD(arrived) OVER w AS next_arrived
+			FROM visits
+			WINDOW w AS (PARTITION BY trip_id ORDER BY stop_sequence)
+		)
+		INSERT INTO route_segment_hourly (hour, route_id, from_stop_id, to_stop_id,
+			samples, travel_secs, dwell_samples, dwell_secs)
+		SELECT $3, route_id, stop_id, next_stop_id,
+		       COUNT(*),
+		       SUM(EXTRACT(EPOCH FROM next_arrived - departed)),
+		       COUNT(*) FILTER (WHERE prev_stop_id IS NOT NULL),
+		       COALESCE(SUM(EXTRACT(EPOCH FROM departed - arrived)) FILTER (WHERE prev_stop_id IS NOT NULL), 0)
+		FROM segments
+		WHERE next_sequence = stop_sequence + 1
+		  AND departed >= $3 AND departed < $4
+		  AND next_arrived > departed
+		GROUP BY route_id, stop_id, next_stop_id
+		ON CONFLICT (route_id, from_stop_id, to_stop_id, hour) DO UPDATE
+		SET samples = EXCLUDED.samples, travel_secs = EXCLUDED.travel_secs,
+		    dwell_samples = EXCLUDED.dwell_samples, dwell_secs = EXCLUDED.dwell_secs
+	`, hour.Add(-segmentVisitMargin), hour.Add(time.Hour+segmentVisitMargin), hour, hour.Add(time.Hour), radiusMeters)
+	return err
+}
+
+// RouteSegments orders segments by the earliest position of their first
+// stop in the route's trips.
+func (r *SegmentStatsRepo) RouteSegments(ctx context.Context, routeID string, since time.Time) ([]domain.RouteSegment, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		WITH seg AS (
+			SELECT from_stop_id, to_stop_id,
+			       SUM(samples) AS samples, SUM(travel_secs) AS travel_secs,
+			       SUM(dwell_samples) AS dwell_samples, SUM(dwell_secs) AS dwell_secs
+			FROM route_segment_hourly
+			WHERE route_id = $1 AND hour >= $2
+			GROUP BY from_stop_id, to_stop_id
+		)
+		SELECT seg.from_stop_id, fs.name, seg.to_stop_id, ts.name,
+		       ST_Distance(fs.location, ts.location),
+		       seg.samples, seg.travel_secs / seg.samples,
+		       CASE WHEN seg.dwell_samples > 0 THEN seg.dwell_secs / seg.dwell_samples END
+		FROM seg
+		JOIN stops fs ON fs.id = seg.from_stop_id
+		JOIN stops ts ON ts.id = seg.to_stop_id
+		ORDER BY (
+			SELECT MIN(st.stop_sequence)
+			FROM stop_times st
+			JOIN trips t ON t.id = st.trip_id
+			WHERE t.route_id = $1 AND st.stop_id = seg.from_stop_id
+		), fs.name, ts.name
+	`, routeID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var segments []domain.RouteSegment
+	for rows.Next() {
+		var s domain.RouteSegment
+		if err := rows.Scan(&s.FromStopID, &s.FromStopName, &s.ToStopID, &s.ToStopName,
+			&s.Distance, &s.Samples, &s.TravelSecs, &s.DwellSecs); err != nil {
+			return nil, err
+		}
+		segments = append(segments, s)
+	}
+	return segments, rows.Err()
+}
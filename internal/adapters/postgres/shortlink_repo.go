@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// ShortLinkRepo implements ports.ShortLinkRepository.
+type ShortLinkRepo struct {
+	db *DB
+}
+
+func NewShortLinkRepo(db *DB) *ShortLinkRepo {
+	return &ShortLinkRepo{db: db}
+}
+
+// Create inserts a code for a stop. A stop keeps its first code, so on conflict
+// the existing code is returned into link instead.
+func (r *ShortLinkRepo) Create(ctx context.Context, link *domain.StopShortLink) error {
+	return r.db.Pool.QueryRow(ctx, `
+		INSERT INTO stop_short_links (code, stop_id)
+		VALUES ($1, $2)
+		ON CONFLICT (stop_id) DO UPDATE SET stop_id = EXCLUDED.stop_id
+		RETURNING code, created_at
+	`, link.Code, link.StopID).Scan(&link.Code, &link.CreatedAt)
+}
+
+func (r *ShortLinkRepo) GetByCode(ctx context.Context, code string) (*domain.StopShortLink, error) {
+	var l domain.StopShortLink
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT l.code, l.stop_id, s.name, l.created_at
+		FROM stop_short_links l
+		JOIN stops s ON s.id = l.stop_id
+		WHERE l.code = $1
+	`, code).Scan(&l.Code, &l.StopID, &l.StopName, &l.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// ListByAgency returns all short links for an agency's stops, ordered by stop name.
+func (r *ShortLinkRepo) ListByAgency(ctx context.Context, agencyID string) ([]domain.StopShortLink, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT l.code, l.stop_id, s.name, l.created_at
+		FROM stop_short_links l
+		JOIN stops s ON s.id = l.stop_id
+		WHERE s.agency_id = $1
+		ORDER BY s.name
+	`, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []domain.StopShortLink
+	for rows.Next() {
+		var l domain.StopShortLink
+		if err := rows.Scan(&l.Code, &l.StopID, &l.StopName, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// StopsWithoutLink returns the UUIDs of an agency's stops that have no code yet.
+func (r *ShortLinkRepo) StopsWithoutLink(ctx context.Context, agencyID string) ([]string, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT s.id FROM stops s
+		LEFT JOIN stop_short_links l ON l.stop_id = s.id
+		WHERE s.agency_id = $1 AND l.code IS NULL
+	`, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
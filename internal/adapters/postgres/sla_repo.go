@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// SLARepo implements ports.SLARepository.
+type SLARepo struct {
+	db *DB
+}
+
+func NewSLARepo(db *DB) *SLARepo { return &SLARepo{db: db} }
+
+func (r *SLARepo) CreateContract(ctx context.Context, c *domain.SLAContract) error {
+	return r.db.Pool.QueryRow(ctx, `
+		INSERT INTO sla_contracts (agency_id, name, threshold_seconds, target_percent, active)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, c.AgencyID, c.Name, c.ThresholdSeconds, c.TargetPercent, c.Active).Scan(&c.ID, &c.CreatedAt)
+}
+
+func (r *SLARepo) ListContracts(ctx context.Context, agencyID string) ([]domain.SLAContract, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, agency_id, name, threshold_seconds, target_percent, active, created_at
+		FROM sla_contracts
+		WHERE active AND ($1::uuid IS NULL OR agency_id = $1)
+		ORDER BY name
+	`, nilIfEmpty(agencyID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contracts []domain.SLAContract
+	for rows.Next() {
+		var c domain.SLAContract
+		if err := rows.Scan(&c.ID, &c.AgencyID, &c.Name, &c.ThresholdSeconds, &c.TargetPercent,
+			&c.Active, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		contracts = append(contracts, c)
+	}
+	return contracts, rows.Err()
+}
+
+// RollupDay counts the latest prediction per trip stop during the day that
+// starts at day. Skipped stops and predictions without a delay are ignored.
+// An existing rollup is never overwritten with an empty one, so days whose raw
+// predictions have expired keep their counts.
+func (r *SLARepo) RollupDay(ctx context.Context, c *domain.SLAContract, day time.Time) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO sla_daily_stats (contract_id, day, observed, on_time)
+		SELECT $1, $2::date, COUNT(*), COUNT(*) FILTER (WHERE delay <= $5)
+		FROM (
+			SELECT DISTINCT ON (p.trip_id, p.stop_sequence)
+				COALESCE(p.departure_delay, p.arrival_delay) AS delay
+			FROM stop_time_predictions p
+			JOIN trips t ON t.id = p.trip_id
+			JOIN routes r ON r.id = t.route_id
+			WHERE r.agency_id = $6 AND p.time >= $3 AND p.time < $4
+			  AND COALESCE(p.schedule_relationship, 0) = 0
+			ORDER BY p.trip_id, p.stop_sequence, p.time DESC
+		) latest
+		WHERE delay IS NOT NULL
+		ON CONFLICT (contract_id, day) DO UPDATE
+		SET observed = EXCLUDED.observed, on_time = EXCLUDED.on_time, updated_at = NOW()
+		WHERE EXCLUDED.observed > 0
+	`, c.ID, day.Format("2006-01-02"), day, day.AddDate(0, 0, 1), c.ThresholdSeconds, c.AgencyID)
+	return err
+}
+
+func (r *SLARepo) MonthStats(ctx context.Context, contractID string, from, to time.Time) (int, int, error) {
+	var observed, onTime int
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT COALESCE(SUM(observed), 0), COALESCE(SUM(on_time), 0)
+		FROM sla_daily_stats
+		WHERE contract_id = $1 AND day >= $2::date AND day < $3::date
+	`, contractID, from.Format("2006-01-02"), to.Format("2006-01-02")).Scan(&observed, &onTime)
+	return observed, onTime, err
+}
+
+func (r *SLARepo) SaveEvaluation(ctx context.Context, e *domain.SLAEvaluation) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO sla_evaluations (contract_id, month, observed, on_time, percent, breached, evaluated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (contract_id, month) DO NOTHING
+	`, e.ContractID, e.Month, e.Observed, e.OnTime, e.Percent, e.Breached, e.EvaluatedAt)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *SLARepo) GetEvaluation(ctx context.Context, contractID, month string) (*domain.SLAEvaluation, error) {
+	e := domain.SLAEvaluation{Final: true}
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT contract_id, month, observed, on_time, percent, breached, evaluated_at
+		FROM sla_evaluations WHERE contract_id = $1 AND month = $2
+	`, contractID, month).Scan(&e.ContractID, &e.Month, &e.Observed, &e.OnTime, &e.Percent, &e.Breached, &e.EvaluatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"context"
+	"time"
+)
+
+// StaticVersionRepo implements ports.StaticVersionRepository. A new feed
+// version covers rows a feed deletes; updated_at covers edits between feeds,
+// such as stop amenities.
+type StaticVersionRepo struct {
+	db *DB
+}
+
+func NewStaticVersionRepo(db *DB) *StaticVersionRepo {
+	return &StaticVersionRepo{db: db}
+}
+
+func (r *StaticVersionRepo) StaticVersion(ctx context.Context) (time.Time, error) {
+	var version time.Time
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT GREATEST(
+			(SELECT COALESCE(MAX(activated_at), 'epoch') FROM feed_versions),
+			(SELECT COALESCE(MAX(updated_at), 'epoch') FROM stops),
+			(SELECT COALESCE(MAX(updated_at), 'epoch') FROM routes),
+			(SELECT COALESCE(MAX(updated_at), 'epoch') FROM trips)
+		)
+	`).Scan(&version)
+	return version, err
+}
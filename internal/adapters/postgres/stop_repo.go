@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/jackc/pgx/v5"
@@ -21,15 +22,23 @@ func NewStopRepo(db *DB) *StopRepo {
 // Upsert inserts or updates a single stop.
 func (r *StopRepo) Upsert(ctx context.Context, s *domain.Stop) error {
 	_, err := r.db.Pool.Exec(ctx, `
-		INSERT INTO stops (stop_id, agency_id, name, location, platform_code, wheelchair_accessible, metadata)
-		VALUES ($1, $2, $3, ST_SetSRID(ST_MakePoint($4, $5), 4326)::geography, $6, $7, $8)
+		INSERT INTO stops (stop_id, agency_id, name, location, platform_code, wheelchair_accessible, metadata,
+		                   location_type, parent_id, stop_code, description, url, zone_id)
+		VALUES ($1, $2, $3, ST_SetSRID(ST_MakePoint($4, $5), 4326)::geography, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 		ON CONFLICT (agency_id, stop_id) DO UPDATE
 		SET name = EXCLUDED.name, location = EXCLUDED.location,
 		    platform_code = EXCLUDED.platform_code,
 		    wheelchair_accessible = EXCLUDED.wheelchair_accessible,
-		    metadata = EXCLUDED.metadata
+		    metadata = EXCLUDED.metadata,
+		    location_type = EXCLUDED.location_type,
+		    parent_id = EXCLUDED.parent_id,
+		    stop_code = EXCLUDED.stop_code,
+		    description = EXCLUDED.description,
+		    url = EXCLUDED.url,
+		    zone_id = EXCLUDED.zone_id
 	`, s.StopID, s.AgencyID, s.Name, s.Location.Lon, s.Location.Lat,
-		s.PlatformCode, s.WheelchairAccessible, s.Metadata)
+		s.PlatformCode, s.WheelchairAccessible, s.Metadata, s.LocationType, nilIfEmpty(s.ParentID),
+		nilIfEmpty(s.StopCode), nilIfEmpty(s.Description), nilIfEmpty(s.URL), nilIfEmpty(s.ZoneID))
 	return err
 }
 
@@ -59,16 +68,28 @@ func (r *StopRepo) UpsertBatch(ctx context.Context, stops []domain.Stop) error {
 func (r *StopRepo) GetByID(ctx context.Context, id string) (*domain.Stop, error) {
 	var s domain.Stop
 	err := r.db.Pool.QueryRow(ctx, `
-		SELECT id, stop_id, agency_id, name,
+		SELECT id, stop_id, COALESCE(stop_code, ''), agency_id, name,
 		       ST_Y(location::geometry) as lat,
 		       ST_X(location::geometry) as lon,
-		       COALESCE(platform_code, ''), wheelchair_accessible, COALESCE(metadata, '{}'), created_at
+		       COALESCE(platform_code, ''), wheelchair_accessible,
+		       location_type, COALESCE(parent_id::text, ''),
+		       shelter, bench, realtime_display, COALESCE(amenities_source, ''), amenities_updated_at,
+		       COALESCE(description, ''), COALESCE(url, ''), COALESCE(zone_id, ''),
+		       COALESCE(metadata, '{}'), created_at, updated_at
 		FROM stops WHERE id = $1
 	`, id).Scan(
-		&s.ID, &s.StopID, &s.AgencyID, &s.Name,
+		&s.ID, &s.StopID, &s.StopCode, &s.AgencyID, &s.Name,
 		&s.Location.Lat, &s.Location.Lon,
-		&s.PlatformCode, &s.WheelchairAccessible, &s.Metadata, &s.CreatedAt,
+		&s.PlatformCode, &s.WheelchairAccessible,
+		&s.LocationType, &s.ParentID,
+		&s.Amenities.Shelter, &s.Amenities.Bench, &s.Amenities.RealtimeDisplay,
+		&s.Amenities.Source, &s.Amenities.UpdatedAt,
+		&s.Description, &s.URL, &s.ZoneID,
+		&s.Metadata, &s.CreatedAt, &s.UpdatedAt,
 	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -82,10 +103,14 @@ func (r *StopRepo) GetByIDs(ctx context.Context, ids []string) ([]domain.Stop, e
 	}
 
 	rows, err := r.db.Pool.Query(ctx, `
-		SELECT id, stop_id, agency_id, name,
+		SELECT id, stop_id, COALESCE(stop_code, ''), agency_id, name,
 		       ST_Y(location::geometry) as lat,
 		       ST_X(location::geometry) as lon,
-		       COALESCE(platform_code, ''), wheelchair_accessible, COALESCE(metadata, '{}'), created_at
+		       COALESCE(platform_code, ''), wheelchair_accessible,
+		       location_type, COALESCE(parent_id::text, ''),
+		       shelter, bench, realtime_display, COALESCE(amenities_source, ''), amenities_updated_at,
+		       COALESCE(description, ''), COALESCE(url, ''), COALESCE(zone_id, ''),
+		       COALESCE(metadata, '{}'), created_at, updated_at
 		FROM stops WHERE id = ANY($1)
 		ORDER BY name
 	`, ids)
@@ -98,9 +123,14 @@ func (r *StopRepo) GetByIDs(ctx context.Context, ids []string) ([]domain.Stop, e
 	for rows.Next() {
 		var s domain.Stop
 		if err := rows.Scan(
-			&s.ID, &s.StopID, &s.AgencyID, &s.Name,
+			&s.ID, &s.StopID, &s.StopCode, &s.AgencyID, &s.Name,
 			&s.Location.Lat, &s.Location.Lon,
-			&s.PlatformCode, &s.WheelchairAccessible, &s.Metadata, &s.CreatedAt,
+			&s.PlatformCode, &s.WheelchairAccessible,
+			&s.LocationType, &s.ParentID,
+			&s.Amenities.Shelter, &s.Amenities.Bench, &s.Amenities.RealtimeDisplay,
+			&s.Amenities.Source, &s.Amenities.UpdatedAt,
+			&s.Description, &s.URL, &s.ZoneID,
+			&s.Metadata, &s.CreatedAt, &s.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -109,20 +139,37 @@ func (r *StopRepo) GetByIDs(ctx context.Context, ids []string) ([]domain.Stop, e
 	return stops, rows.Err()
 }
 
-// FindNearby returns stops within radiusMeters using PostGIS ST_DWithin.
-func (r *StopRepo) FindNearby(ctx context.Context, lat, lon, radiusMeters float64, limit int) ([]domain.Stop, error) {
+// FindNearby returns boarding stops within radiusMeters using PostGIS
+// ST_DWithin, keeping only those known to have the amenities the filter asks
+// for and, with a route type filter, those served by a route it keeps.
+func (r *StopRepo) FindNearby(ctx context.Context, lat, lon, radiusMeters float64, limit int, filter domain.StopFilter) ([]domain.Stop, error) {
 	rows, err := r.db.Pool.Query(ctx, `
-		SELECT id, stop_id, agency_id, name,
+		SELECT id, stop_id, COALESCE(stop_code, ''), agency_id, name,
 		       ST_Y(location::geometry) as lat,
 		       ST_X(location::geometry) as lon,
 		       COALESCE(platform_code, ''), wheelchair_accessible,
+		       location_type, COALESCE(parent_id::text, ''),
+		       shelter, bench, realtime_display, COALESCE(amenities_source, ''), amenities_updated_at,
 		       ST_Distance(location, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography) as distance,
-		       created_at
+		       created_at, updated_at
 		FROM stops
 		WHERE ST_DWithin(location, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography, $3)
+		  AND location_type = 0
+		  AND (NOT $5 OR shelter)
+		  AND (NOT $6 OR bench)
+		  AND (NOT $7 OR realtime_display)
+		  AND (($8::int[] IS NULL AND $9::int[] IS NULL) OR EXISTS (
+		      SELECT 1
+		      FROM stop_times st
+		      JOIN trips t ON t.id = st.trip_id
+		      JOIN routes r ON r.id = t.route_id
+		      WHERE st.stop_id = stops.id
+		        AND ($8::int[] IS NULL OR r.route_type = ANY($8))
+		        AND ($9::int[] IS NULL OR r.route_type <> ALL($9))))
 		ORDER BY distance
 		LIMIT $4
-	`, lon, lat, radiusMeters, limit)
+	`, lon, lat, radiusMeters, limit, filter.HasShelter, filter.HasBench, filter.HasRealtimeDisplay,
+		filter.RouteTypes.Include, filter.RouteTypes.Exclude)
 	if err != nil {
 		return nil, err
 	}
@@ -133,10 +180,13 @@ func (r *StopRepo) FindNearby(ctx context.Context, lat, lon, radiusMeters float6
 		var s domain.Stop
 		var dist float64
 		if err := rows.Scan(
-			&s.ID, &s.StopID, &s.AgencyID, &s.Name,
+			&s.ID, &s.StopID, &s.StopCode, &s.AgencyID, &s.Name,
 			&s.Location.Lat, &s.Location.Lon,
 			&s.PlatformCode, &s.WheelchairAccessible,
-			&dist, &s.CreatedAt,
+			&s.LocationType, &s.ParentID,
+			&s.Amenities.Shelter, &s.Amenities.Bench, &s.Amenities.RealtimeDisplay,
+			&s.Amenities.Source, &s.Amenities.UpdatedAt,
+			&dist, &s.CreatedAt, &s.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -146,20 +196,59 @@ func (r *StopRepo) FindNearby(ctx context.Context, lat, lon, radiusMeters float6
 	return stops, rows.Err()
 }
 
-// Search performs fuzzy + full-text search on stop names.
+// searchProximityScale is the distance in meters at which a stop's search
+// score halves: nearer stops outrank better matches further away.
+const searchProximityScale = 2000.0
+
+// suggestThreshold is the word similarity a stop name needs to be suggested
+// for a search that found nothing; Search uses pg_trgm's default of 0.6.
+// Checking it scans every stop, which only misses pay for.
+const suggestThreshold = 0.3
+
+// Search matches boarding stops by name, ignoring case and accents, or by
+// exact stop_code. Names are scored by how well the query matches a word run
+// in them, so "Sarriko" fully matches "Sarriko/Sarriko"; a code match scores
+// 1. The query's search synonyms are matched too, their scores boosted, and
+// each stop keeps its best score. Near a point, scores shrink with distance
+// and stops carry it.
 func (r *StopRepo) Search(ctx context.Context, query string, near *domain.GeoPoint, limit int) ([]domain.Stop, error) {
+	var lat, lon *float64
+	if near != nil {
+		lat, lon = &near.Lat, &near.Lon
+	}
 	rows, err := r.db.Pool.Query(ctx, `
-		SELECT id, stop_id, agency_id, name,
+		WITH q AS (
+			SELECT lower(immutable_unaccent(btrim($1))) AS text,
+			       ST_SetSRID(ST_MakePoint($4::float8, $3::float8), 4326)::geography AS near
+		), terms AS (
+			SELECT q.text, 1::float8 AS boost, true AS original FROM q
+			UNION ALL
+			SELECT ss.synonym, ss.boost, false FROM search_synonyms ss, q WHERE ss.term = q.text
+			UNION ALL
+			SELECT ss.term, ss.boost, false FROM search_synonyms ss, q WHERE ss.synonym = q.text AND NOT ss.one_way
+		), matched AS (
+			SELECT DISTINCT ON (s.id) s.*,
+			       CASE WHEN t.original AND lower(s.stop_code) = lower($1) THEN 1
+			            ELSE word_similarity(t.text, s.search_name) * t.boost END AS sim,
+			       ST_Distance(s.location, q.near) AS distance
+			FROM stops s, q, terms t
+			WHERE s.location_type = 0
+			  AND ((t.original AND s.name_vector @@ plainto_tsquery('spanish', $1))
+			       OR s.search_name %> t.text
+			       OR (t.original AND lower(s.stop_code) = lower($1)))
+			ORDER BY s.id, sim DESC
+		)
+		SELECT id, stop_id, COALESCE(stop_code, ''), agency_id, name,
 		       ST_Y(location::geometry) as lat,
 		       ST_X(location::geometry) as lon,
-		       COALESCE(platform_code, ''), wheelchair_accessible, created_at,
-		       similarity(name, $1) as sim
-		FROM stops
-		WHERE name_vector @@ plainto_tsquery('spanish', $1)
-		   OR name %> $1
-		ORDER BY sim DESC
+		       COALESCE(platform_code, ''), wheelchair_accessible,
+		       location_type, COALESCE(parent_id::text, ''),
+		       shelter, bench, realtime_display, COALESCE(amenities_source, ''), amenities_updated_at,
+		       created_at, updated_at, distance
+		FROM matched
+		ORDER BY sim / (1 + COALESCE(distance, 0) / $5) DESC, name
 		LIMIT $2
-	`, query, limit)
+	`, query, limit, lat, lon, searchProximityScale)
 	if err != nil {
 		return nil, err
 	}
@@ -168,12 +257,14 @@ func (r *StopRepo) Search(ctx context.Context, query string, near *domain.GeoPoi
 	var stops []domain.Stop
 	for rows.Next() {
 		var s domain.Stop
-		var sim float64
 		if err := rows.Scan(
-			&s.ID, &s.StopID, &s.AgencyID, &s.Name,
+			&s.ID, &s.StopID, &s.StopCode, &s.AgencyID, &s.Name,
 			&s.Location.Lat, &s.Location.Lon,
-			&s.PlatformCode, &s.WheelchairAccessible, &s.CreatedAt,
-			&sim,
+			&s.PlatformCode, &s.WheelchairAccessible,
+			&s.LocationType, &s.ParentID,
+			&s.Amenities.Shelter, &s.Amenities.Bench, &s.Amenities.RealtimeDisplay,
+			&s.Amenities.Source, &s.Amenities.UpdatedAt,
+			&s.CreatedAt, &s.UpdatedAt, &s.Distance,
 		); err != nil {
 			return nil, err
 		}
@@ -181,3 +272,94 @@ func (r *StopRepo) Search(ctx context.Context, query string, near *domain.GeoPoi
 	}
 	return stops, rows.Err()
 }
+
+// Suggest returns the distinct names of boarding stops a query matches a
+// word run of at least suggestThreshold, below the threshold Search uses,
+// best match first.
+func (r *StopRepo) Suggest(ctx context.Context, query string, limit int) ([]string, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		WITH q AS (
+			SELECT lower(immutable_unaccent(btrim($1))) AS text
+		), matched AS (
+			SELECT DISTINCT ON (s.name) s.name, word_similarity(q.text, s.search_name) AS sim
+			FROM stops s, q
+			WHERE s.location_type = 0
+			  AND word_similarity(q.text, s.search_name) >= $3
+			ORDER BY s.name, sim DESC
+		)
+		SELECT name FROM matched
+		ORDER BY sim DESC, name
+		LIMIT $2
+	`, query, limit, suggestThreshold)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// ListPlatforms returns the boarding stops whose parent is one of the
+// stations.
+func (r *StopRepo) ListPlatforms(ctx context.Context, stationIDs []string) ([]domain.Stop, error) {
+	if len(stationIDs) == 0 {
+		return nil, nil
+	}
+
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, stop_id, COALESCE(stop_code, ''), agency_id, name,
+		       ST_Y(location::geometry) as lat,
+		       ST_X(location::geometry) as lon,
+		       COALESCE(platform_code, ''), wheelchair_accessible,
+		       location_type, COALESCE(parent_id::text, ''),
+		       shelter, bench, realtime_display, COALESCE(amenities_source, ''), amenities_updated_at,
+		       created_at, updated_at
+		FROM stops
+		WHERE parent_id = ANY($1) AND location_type = 0
+		ORDER BY parent_id, platform_code, name
+	`, stationIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stops []domain.Stop
+	for rows.Next() {
+		var s domain.Stop
+		if err := rows.Scan(
+			&s.ID, &s.StopID, &s.StopCode, &s.AgencyID, &s.Name,
+			&s.Location.Lat, &s.Location.Lon,
+			&s.PlatformCode, &s.WheelchairAccessible,
+			&s.LocationType, &s.ParentID,
+			&s.Amenities.Shelter, &s.Amenities.Bench, &s.Amenities.RealtimeDisplay,
+			&s.Amenities.Source, &s.Amenities.UpdatedAt,
+			&s.CreatedAt, &s.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		stops = append(stops, s)
+	}
+	return stops, rows.Err()
+}
+
+// UpdateAmenities replaces a stop's amenities.
+func (r *StopRepo) UpdateAmenities(ctx context.Context, id string, a *domain.StopAmenities) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		UPDATE stops
+		SET shelter = $2, bench = $3, realtime_display = $4,
+		    amenities_source = $5, amenities_updated_at = $6
+		WHERE id = $1
+	`, id, a.Shelter, a.Bench, a.RealtimeDisplay, nilIfEmpty(a.Source), a.UpdatedAt)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,161 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// SyncRepo implements ports.SyncRepository. Change logs are read in two
+// steps: the positions of changed and deleted entities, then the changed
+// entities themselves.
+type SyncRepo struct {
+	db *DB
+}
+
+func NewSyncRepo(db *DB) *SyncRepo { return &SyncRepo{db: db} }
+
+// Change log queries take the agency ID, the position after which to start,
+// the time until which to read and the limit as $1 to $5. Rows are the
+// entity ID, its change time and whether it was deleted.
+const (
+	stopChangeLog = `
+		SELECT id::text, updated_at, false FROM stops
+		WHERE ($1::uuid IS NULL OR agency_id = $1)
+		  AND (updated_at, id::text) > ($2, $3) AND updated_at <= $4
+		UNION ALL
+		SELECT id::text, deleted_at, true FROM sync_deletions
+		WHERE entity = 'stop' AND ($1::uuid IS NULL OR agency_id = $1)
+		  AND (deleted_at, id::text) > ($2, $3) AND deleted_at <= $4
+		ORDER BY 2, 1
+		LIMIT $5`
+
+	routeChangeLog = `
+		SELECT id::text, updated_at, false FROM routes
+		WHERE ($1::uuid IS NULL OR agency_id = $1)
+		  AND (updated_at, id::text) > ($2, $3) AND updated_at <= $4
+		UNION ALL
+		SELECT id::text, deleted_at, true FROM sync_deletions
+		WHERE entity = 'route' AND ($1::uuid IS NULL OR agency_id = $1)
+		  AND (deleted_at, id::text) > ($2, $3) AND deleted_at <= $4
+		ORDER BY 2, 1
+		LIMIT $5`
+
+	alertChangeLog = `
+		SELECT id::text, changed_at, removed_at IS NOT NULL FROM service_alerts
+		WHERE ($1::uuid IS NULL OR agency_id = $1)
+		  AND (changed_at, id::text) > ($2, $3) AND changed_at <= $4
+		ORDER BY 2, 1
+		LIMIT $5`
+)
+
+type syncEntry struct {
+	pos     domain.SyncPosition
+	deleted bool
+}
+
+// changeLog runs a change log query and returns its entries and the IDs of
+// the entities that still exist.
+func (r *SyncRepo) changeLog(ctx context.Context, query, agencyID string, after domain.SyncPosition, until time.Time, limit int) ([]syncEntry, []string, error) {
+	rows, err := r.db.Pool.Query(ctx, query, nilIfEmpty(agencyID), after.At, after.ID, until, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var (
+		entries []syncEntry
+		live    []string
+	)
+	for rows.Next() {
+		var e syncEntry
+		if err := rows.Scan(&e.pos.ID, &e.pos.At, &e.deleted); err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, e)
+		if !e.deleted {
+			live = append(live, e.pos.ID)
+		}
+	}
+	return entries, live, rows.Err()
+}
+
+func (r *SyncRepo) StopChanges(ctx context.Context, agencyID string, after domain.SyncPosition, until time.Time, limit int) ([]domain.StopChange, error) {
+	entries, live, err := r.changeLog(ctx, stopChangeLog, agencyID, after, until, limit)
+	if err != nil {
+		return nil, err
+	}
+	stops, err := (&StopRepo{db: r.db}).GetByIDs(ctx, live)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*domain.Stop, len(stops))
+	for i := range stops {
+		byID[stops[i].ID] = &stops[i]
+	}
+
+	// Stops deleted since the change log was read count as deleted.
+	changes := make([]domain.StopChange, 0, len(entries))
+	for _, e := range entries {
+		changes = append(changes, domain.StopChange{SyncPosition: e.pos, Stop: byID[e.pos.ID]})
+	}
+	return changes, nil
+}
+
+func (r *SyncRepo) RouteChanges(ctx context.Context, agencyID string, after domain.SyncPosition, until time.Time, limit int) ([]domain.RouteChange, error) {
+	entries, live, err := r.changeLog(ctx, routeChangeLog, agencyID, after, until, limit)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, route_id, agency_id, COALESCE(short_name, ''), long_name, route_type,
+		       COALESCE(color, ''), COALESCE(text_color, ''), metadata, created_at, updated_at
+		FROM routes WHERE id = ANY($1)
+	`, live)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	byID := make(map[string]*domain.Route, len(live))
+	for rows.Next() {
+		var rt domain.Route
+		if err := rows.Scan(&rt.ID, &rt.RouteID, &rt.AgencyID, &rt.ShortName, &rt.LongName,
+			&rt.RouteType, &rt.Color, &rt.TextColor, &rt.Metadata, &rt.CreatedAt, &rt.UpdatedAt); err != nil {
+			return nil, err
+		}
+		byID[rt.ID] = &rt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	changes := make([]domain.RouteChange, 0, len(entries))
+	for _, e := range entries {
+		changes = append(changes, domain.RouteChange{SyncPosition: e.pos, Route: byID[e.pos.ID]})
+	}
+	return changes, nil
+}
+
+func (r *SyncRepo) AlertChanges(ctx context.Context, agencyID string, after domain.SyncPosition, until time.Time, limit int) ([]domain.AlertChange, error) {
+	entries, live, err := r.changeLog(ctx, alertChangeLog, agencyID, after, until, limit)
+	if err != nil {
+		return nil, err
+	}
+	alerts, err := (&AlertRepo{db: r.db}).list(ctx, `
+		SELECT `+alertColumns+` FROM service_alerts a WHERE a.id = ANY($1)
+	`, live)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*domain.ServiceAlert, len(alerts))
+	for i := range alerts {
+		byID[alerts[i].ID] = &alerts[i]
+	}
+
+	changes := make([]domain.AlertChange, 0, len(entries))
+	for _, e := range entries {
+		changes = append(changes, domain.AlertChange{SyncPosition: e.pos, Alert: byID[e.pos.ID]})
+	}
+	return changes, nil
+}
@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// TileRepo implements ports.TileRepository using PostGIS tile functions.
+// Tiles are addressed in the Web Mercator (EPSG:3857) XYZ scheme.
+type TileRepo struct {
+	db *DB
+}
+
+func NewTileRepo(db *DB) *TileRepo { return &TileRepo{db: db} }
+
+// MVT renders a Mapbox Vector Tile with a "routes" layer and, if withStops is
+// set, a "stops" layer. Empty tiles are returned as an empty slice.
+func (r *TileRepo) MVT(ctx context.Context, z, x, y int, withStops bool) ([]byte, error) {
+	var tile []byte
+	err := r.db.Pool.QueryRow(ctx, `
+		WITH bounds AS (
+			SELECT ST_TileEnvelope($1, $2, $3) AS geom,
+			       ST_Transform(ST_TileEnvelope($1, $2, $3), 4326) AS geom4326
+		),
+		route_layer AS (
+			SELECT ST_AsMVTGeom(ST_Transform(rt.shape::geometry, 3857), b.geom) AS geom,
+			       rt.id, rt.agency_id, rt.short_name, rt.route_type,
+			       COALESCE(rt.color, '') AS color
+			FROM routes rt, bounds b
+			WHERE rt.shape && b.geom4326::geography
+		),
+		stop_layer AS (
+			SELECT ST_AsMVTGeom(ST_Transform(s.location::geometry, 3857), b.geom) AS geom,
+			       s.id, s.agency_id, s.name, s.wheelchair_accessible
+			FROM stops s, bounds b
+			WHERE $4 AND s.location && b.geom4326::geography
+		)
+		SELECT COALESCE((SELECT ST_AsMVT(route_layer, 'routes', 4096, 'geom') FROM route_layer), ''::bytea)
+		    || COALESCE((SELECT ST_AsMVT(stop_layer, 'stops', 4096, 'geom') FROM stop_layer), ''::bytea)
+	`, z, x, y, withStops).Scan(&tile)
+	return tile, err
+}
+
+// StopsInTile returns the stops located inside the tile.
+func (r *TileRepo) StopsInTile(ctx context.Context, z, x, y int) ([]domain.Stop, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, stop_id, agency_id, name,
+		       ST_Y(location::geometry) as lat,
+		       ST_X(location::geometry) as lon,
+		       COALESCE(platform_code, ''), wheelchair_accessible, created_at, updated_at
+		FROM stops
+		WHERE location && ST_Transform(ST_TileEnvelope($1, $2, $3), 4326)::geography
+	`, z, x, y)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stops []domain.Stop
+	for rows.Next() {
+		var s domain.Stop
+		if err := rows.Scan(
+			&s.ID, &s.StopID, &s.AgencyID, &s.Name,
+			&s.Location.Lat, &s.Location.Lon,
+			&s.PlatformCode, &s.WheelchairAccessible, &s.CreatedAt, &s.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		stops = append(stops, s)
+	}
+	return stops, rows.Err()
+}
+
+// RouteShapesInTile returns route shapes clipped to the tile. A route that
+// leaves and re-enters the tile is returned once per clipped segment.
+func (r *TileRepo) RouteShapesInTile(ctx context.Context, z, x, y int) ([]domain.Route, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		WITH bounds AS (
+			SELECT ST_Transform(ST_TileEnvelope($1, $2, $3), 4326) AS geom
+		),
+		clipped AS (
+			SELECT rt.id, rt.route_id, rt.agency_id, rt.short_name, rt.long_name, rt.route_type,
+			       rt.color, rt.text_color,
+			       (ST_Dump(ST_Intersection(rt.shape::geometry, b.geom))).geom AS part
+			FROM routes rt, bounds b
+			WHERE rt.shape && b.geom::geography
+		)
+		SELECT id, route_id, agency_id, short_name, long_name, route_type,
+		       COALESCE(color, ''), COALESCE(text_color, ''), ST_AsGeoJSON(part)
+		FROM clipped
+		WHERE GeometryType(part) = 'LINESTRING'
+	`, z, x, y)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var routes []domain.Route
+	for rows.Next() {
+		var rt domain.Route
+		var raw []byte
+		if err := rows.Scan(&rt.ID, &rt.RouteID, &rt.AgencyID, &rt.ShortName, &rt.LongName,
+			&rt.RouteType, &rt.Color, &rt.TextColor, &raw); err != nil {
+			return nil, err
+		}
+		if rt.Shape, err = decodeLineString(raw); err != nil {
+			return nil, err
+		}
+		routes = append(routes, rt)
+	}
+	return routes, rows.Err()
+}
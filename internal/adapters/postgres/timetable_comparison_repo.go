@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// TimetableComparisonRepo implements ports.TimetableComparisonRepository.
+type TimetableComparisonRepo struct {
+	db *DB
+}
+
+func NewTimetableComparisonRepo(db *DB) *TimetableComparisonRepo {
+	return &TimetableComparisonRepo{db: db}
+}
+
+// Compare finds the service date of each observation from the time it was
+// recorded less the scheduled departure and the delay, in the agency's time
+// zone, so trips running past midnight keep their date. Each stop takes the
+// latest observation within hours of its scheduled departure that day.
+func (r *TimetableComparisonRepo) Compare(ctx context.Context, agencyID string, from, to time.Time) ([]domain.TimetableComparison, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		WITH agency AS (
+			SELECT id, COALESCE(timezone, 'Europe/Madrid') AS tz FROM agencies WHERE id = $1
+		), observed AS (
+			SELECT DISTINCT o.trip_id,
+			       ((o.time AT TIME ZONE ag.tz) - st.departure_time - make_interval(secs => o.delay))::date AS service_date
+			FROM stop_delay_observations o
+			JOIN routes r ON r.id = o.route_id
+			JOIN agency ag ON ag.id = r.agency_id
+			JOIN stop_times st ON st.trip_id = o.trip_id AND st.stop_id = o.stop_id
+			WHERE o.time >= $2::date::timestamp AT TIME ZONE ag.tz
+			  AND o.time < ($3::date + 2)::timestamp AT TIME ZONE ag.tz
+		)
+		SELECT ob.service_date::text, r.route_id, COALESCE(r.short_name, ''), t.trip_id, COALESCE(t.direction_id, 0),
+		       st.stop_sequence, s.stop_id, s.name,
+		       (ob.service_date + st.arrival_time) AT TIME ZONE ag.tz,
+		       (ob.service_date + st.departure_time) AT TIME ZONE ag.tz,
+		       obs.delay
+		FROM observed ob
+		CROSS JOIN agency ag
+		JOIN trips t ON t.id = ob.trip_id
+		JOIN routes r ON r.id = t.route_id
+		JOIN stop_times st ON st.trip_id = t.id
+		JOIN stops s ON s.id = st.stop_id
+		LEFT JOIN LATERAL (
+			SELECT o.delay
+			FROM stop_delay_observations o
+			WHERE o.trip_id = t.id AND o.stop_id = st.stop_id
+			  AND o.time >= (ob.service_date + st.departure_time - INTERVAL '3 hours') AT TIME ZONE ag.tz
+			  AND o.time < (ob.service_date + st.departure_time + INTERVAL '6 hours') AT TIME ZONE ag.tz
+			ORDER BY o.time DESC
+			LIMIT 1
+		) obs ON true
+		WHERE ob.service_date BETWEEN $2::date AND $3::date
+		ORDER BY ob.service_date, r.route_id, t.trip_id, st.stop_sequence
+	`, agencyID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.TimetableComparison
+	for rows.Next() {
+		var c domain.TimetableComparison
+		if err := rows.Scan(&c.ServiceDate, &c.RouteID, &c.RouteShortName, &c.TripID, &c.DirectionID,
+			&c.StopSequence, &c.StopID, &c.StopName, &c.ScheduledArrival, &c.ScheduledDeparture, &c.Delay); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,195 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// TimetableRepo implements ports.TimetableRepository.
+type TimetableRepo struct {
+	db *DB
+}
+
+func NewTimetableRepo(db *DB) *TimetableRepo { return &TimetableRepo{db: db} }
+
+const timetableVersionQuery = `SELECT COALESCE(MAX(activated_at), 'epoch') FROM feed_versions`
+
+func (r *TimetableRepo) Version(ctx context.Context) (time.Time, error) {
+	var version time.Time
+	err := r.db.Pool.QueryRow(ctx, timetableVersionQuery).Scan(&version)
+	return version, err
+}
+
+// Load reads the timetable in one read-only snapshot, so a feed applied
+// meanwhile is not seen half-way.
+func (r *TimetableRepo) Load(ctx context.Context, walkRadiusMeters float64) (*domain.Timetable, error) {
+	tx, err := r.db.Pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	tt := &domain.Timetable{}
+	if err := tx.QueryRow(ctx, timetableVersionQuery).Scan(&tt.Version); err != nil {
+		return nil, err
+	}
+	// The agencies share a zone; should they not, the most common is taken.
+	if err := tx.QueryRow(ctx, `
+		SELECT COALESCE((
+			SELECT COALESCE(timezone, 'Europe/Madrid') FROM agencies
+			GROUP BY 1 ORDER BY count(*) DESC, 1 LIMIT 1
+		), 'Europe/Madrid')
+	`).Scan(&tt.Timezone); err != nil {
+		return nil, err
+	}
+
+	// Stops
+	rows, err := tx.Query(ctx, `
+		SELECT id, stop_id, agency_id, name, ST_Y(location::geometry), ST_X(location::geometry),
+		       COALESCE(platform_code, ''), wheelchair_accessible, COALESCE(zone_id, '')
+		FROM stops
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var s domain.Stop
+		if err := rows.Scan(&s.ID, &s.StopID, &s.AgencyID, &s.Name, &s.Location.Lat, &s.Location.Lon,
+			&s.PlatformCode, &s.WheelchairAccessible, &s.ZoneID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tt.Stops = append(tt.Stops, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Routes
+	rows, err = tx.Query(ctx, `
+		SELECT id, route_id, agency_id, COALESCE(short_name, ''), long_name, route_type, color, text_color
+		FROM routes
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var rt domain.Route
+		if err := rows.Scan(&rt.ID, &rt.RouteID, &rt.AgencyID, &rt.ShortName, &rt.LongName, &rt.RouteType,
+			&rt.Color, &rt.TextColor); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tt.Routes = append(tt.Routes, rt)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Trips, then their stop times in sequence
+	rows, err = tx.Query(ctx, `
+		SELECT id, trip_id, route_id, service_id, COALESCE(headsign, ''), COALESCE(direction_id, 0),
+		       COALESCE(wheelchair_accessible, false), COALESCE(bikes_allowed, false)
+		FROM trips
+	`)
+	if err != nil {
+		return nil, err
+	}
+	tripIdx := make(map[string]int)
+	for rows.Next() {
+		var t domain.Trip
+		if err := rows.Scan(&t.ID, &t.TripID, &t.RouteID, &t.ServiceID, &t.Headsign, &t.DirectionID,
+			&t.WheelchairAccessible, &t.BikesAllowed); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tripIdx[t.ID] = len(tt.Trips)
+		tt.Trips = append(tt.Trips, domain.TimetableTrip{Trip: t})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query(ctx, `
+		SELECT trip_id, stop_id, arrival_time, departure_time, stop_sequence,
+		       COALESCE(pickup_type, 0), COALESCE(drop_off_type, 0)
+		FROM stop_times
+		ORDER BY trip_id, stop_sequence
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var st domain.StopTime
+		if err := rows.Scan(&st.TripID, &st.StopID, &st.ArrivalTime, &st.DepartureTime, &st.StopSequence,
+			&st.PickupType, &st.DropOffType); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if i, ok := tripIdx[st.TripID]; ok {
+			tt.Trips[i].StopTimes = append(tt.Trips[i].StopTimes, st)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Frequency-based trips
+	rows, err = tx.Query(ctx, `
+		SELECT trip_id, start_time, end_time, headway_secs, exact_times
+		FROM frequencies
+		ORDER BY trip_id, start_time
+	`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var tripID string
+		var f domain.Frequency
+		var headwaySecs int
+		if err := rows.Scan(&tripID, &f.StartTime, &f.EndTime, &headwaySecs, &f.ExactTimes); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		f.Headway = time.Duration(headwaySecs) * time.Second
+		if i, ok := tripIdx[tripID]; ok {
+			tt.Trips[i].Frequencies = append(tt.Trips[i].Frequencies, f)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Walking transfers, across agencies too
+	rows, err = tx.Query(ctx, `
+		SELECT a.id, b.id, ST_Distance(a.location, b.location)
+		FROM stops a
+		JOIN stops b ON b.id <> a.id AND ST_DWithin(a.location, b.location, $1)
+	`, walkRadiusMeters)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var t domain.Transfer
+		if err := rows.Scan(&t.FromStopID, &t.ToStopID, &t.Distance); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tt.Transfers = append(tt.Transfers, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tt, tx.Commit(ctx)
+}
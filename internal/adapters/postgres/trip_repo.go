@@ -35,17 +35,54 @@ func (r *TripRepo) UpsertBatch(ctx context.Context, trips []domain.Trip) error {
 	return nil
 }
 
+// GetByID returns a trip by UUID, with the trip its vehicle continues as.
 func (r *TripRepo) GetByID(ctx context.Context, id string) (*domain.Trip, error) {
 	tr := &domain.Trip{}
+	var next nextTrip
 	err := r.db.Pool.QueryRow(ctx, `
-		SELECT id, trip_id, route_id, service_id, COALESCE(headsign, ''), COALESCE(direction_id, 0),
-		       COALESCE(shape_id, ''), wheelchair_accessible, bikes_allowed, created_at
-		FROM trips WHERE id = $1
+		SELECT t.id, t.trip_id, t.route_id, t.service_id, COALESCE(t.headsign, ''), COALESCE(t.direction_id, 0),
+		       COALESCE(t.shape_id, ''), t.wheelchair_accessible, t.bikes_allowed, COALESCE(t.block_id, ''),
+		       `+nextTripColumns+`, t.created_at, t.updated_at
+		FROM trips t `+nextTripJoin+`
+		WHERE t.id = $1
 	`, id).Scan(&tr.ID, &tr.TripID, &tr.RouteID, &tr.ServiceID, &tr.Headsign,
-		&tr.DirectionID, &tr.ShapeID, &tr.WheelchairAccessible, &tr.BikesAllowed, &tr.CreatedAt)
+		&tr.DirectionID, &tr.ShapeID, &tr.WheelchairAccessible, &tr.BikesAllowed, &tr.BlockID,
+		&next.id, &next.routeID, &next.shortName, &next.longName, &next.headsign, &tr.CreatedAt, &tr.UpdatedAt)
+	tr.ContinuesAs = next.continuation()
 	return tr, err
 }
 
+// nextTripColumns and nextTripJoin read the trip t's vehicle continues as
+// into a nextTrip.
+const (
+	nextTripColumns = `nt.id::text, nr.id::text, nr.short_name, nr.long_name, nt.headsign`
+	nextTripJoin    = `LEFT JOIN trips nt ON nt.id = t.next_trip_id LEFT JOIN routes nr ON nr.id = nt.route_id`
+)
+
+// nextTrip is a continuation as scanned, null without one.
+type nextTrip struct {
+	id, routeID, shortName, longName, headsign *string
+}
+
+func (n nextTrip) continuation() *domain.TripContinuation {
+	if n.id == nil || n.routeID == nil {
+		return nil
+	}
+	deref := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	return &domain.TripContinuation{
+		ID:             *n.id,
+		RouteID:        *n.routeID,
+		RouteShortName: deref(n.shortName),
+		RouteLongName:  deref(n.longName),
+		Headsign:       deref(n.headsign),
+	}
+}
+
 func (r *TripRepo) UpsertStopTimes(ctx context.Context, stopTimes []domain.StopTime) error {
 	// Batch insert (used by ingestor, not API)
 	return nil
@@ -74,45 +111,70 @@ func (r *TripRepo) GetStopTimes(ctx context.Context, tripID string) ([]domain.St
 }
 
 // NextDeparturesAtStop returns the next departures at a stop (schedule-based).
-// It matches stop_times where the departure_time interval is >= current time-of-day.
-func (r *TripRepo) NextDeparturesAtStop(ctx context.Context, stopUUID string, limit int) ([]domain.Departure, error) {
-	now := time.Now()
-	// Current time of day as interval
-	todSeconds := now.Hour()*3600 + now.Minute()*60 + now.Second()
+// It matches stop_times where the departure_time interval is >= the time of
+// day of filter.After (now when nil) and, with filter.Until, before it; Until
+// may be on a later day. The filter's bikes, route and direction conditions
+// narrow the trips matched. Trips carry the trip their vehicle continues as.
+func (r *TripRepo) NextDeparturesAtStop(ctx context.Context, stopUUID string, limit int, filter domain.DepartureFilter) ([]domain.Departure, error) {
+	from := time.Now()
+	if filter.After != nil {
+		from = *filter.After
+	}
+	today := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	// Time of day as interval
+	todSeconds := int(from.Sub(today).Seconds())
+	var untilSeconds *int
+	if filter.Until != nil {
+		secs := int(filter.Until.Sub(today).Seconds())
+		untilSeconds = &secs
+	}
+	var routeID *string
+	if filter.RouteID != "" {
+		routeID = &filter.RouteID
+	}
 
 	rows, err := r.db.Pool.Query(ctx, `
 		SELECT
 			st.departure_time,
 			t.id, t.trip_id, COALESCE(t.headsign, ''), COALESCE(t.direction_id, 0),
-			r.id, r.route_id, COALESCE(r.short_name, ''), r.long_name, r.route_type, r.color, r.text_color
+			COALESCE(t.wheelchair_accessible, false), COALESCE(t.bikes_allowed, false),
+			r.id, r.route_id, COALESCE(r.short_name, ''), r.long_name, r.route_type, r.color, r.text_color,
+			COALESCE(t.block_id, ''), `+nextTripColumns+`
 		FROM stop_times st
 		JOIN trips t ON t.id = st.trip_id
 		JOIN routes r ON r.id = t.route_id
+		`+nextTripJoin+`
 		WHERE st.stop_id = $1
 		  AND st.departure_time >= make_interval(secs => $2)
+		  AND ($5::int IS NULL OR st.departure_time < make_interval(secs => $5))
+		  AND (NOT $4 OR t.bikes_allowed)
+		  AND ($6::uuid IS NULL OR t.route_id = $6)
+		  AND ($7::int IS NULL OR t.direction_id = $7)
 		ORDER BY st.departure_time
 		LIMIT $3
-	`, stopUUID, todSeconds, limit)
+	`, stopUUID, todSeconds, limit, filter.Bikes, untilSeconds, routeID, filter.DirectionID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-
 	var departures []domain.Departure
 	for rows.Next() {
 		var depInterval time.Duration
 		var trip domain.Trip
 		var route domain.Route
+		var next nextTrip
 
 		if err := rows.Scan(
 			&depInterval,
 			&trip.ID, &trip.TripID, &trip.Headsign, &trip.DirectionID,
+			&trip.WheelchairAccessible, &trip.BikesAllowed,
 			&route.ID, &route.RouteID, &route.ShortName, &route.LongName, &route.RouteType, &route.Color, &route.TextColor,
+			&trip.BlockID, &next.id, &next.routeID, &next.shortName, &next.longName, &next.headsign,
 		); err != nil {
 			return nil, err
 		}
+		trip.ContinuesAs = next.continuation()
 
 		scheduledTime := today.Add(depInterval)
 
@@ -127,3 +189,160 @@ func (r *TripRepo) NextDeparturesAtStop(ctx context.Context, stopUUID string, li
 	}
 	return departures, rows.Err()
 }
+
+// NextDeparturesNearby returns the next departures, within horizon of the
+// current time of day, from the stops within radiusMeters of a point. A trip
+// calling at several of those stops departs from the nearest one.
+func (r *TripRepo) NextDeparturesNearby(ctx context.Context, lat, lon, radiusMeters float64, horizon time.Duration, limit int, filter domain.DepartureFilter) ([]domain.NearbyDeparture, error) {
+	now := time.Now()
+	todSeconds := now.Hour()*3600 + now.Minute()*60 + now.Second()
+
+	rows, err := r.db.Pool.Query(ctx, `
+		WITH nearby AS (
+			SELECT id, stop_id, agency_id, name,
+			       ST_Y(location::geometry) AS lat,
+			       ST_X(location::geometry) AS lon,
+			       COALESCE(platform_code, '') AS platform_code, wheelchair_accessible,
+			       ST_Distance(location, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography) AS distance
+			FROM stops
+			WHERE ST_DWithin(location, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography, $3)
+		), next AS (
+			SELECT DISTINCT ON (t.id)
+				st.departure_time, n.*,
+				t.id AS trip_uuid, t.trip_id, COALESCE(t.headsign, '') AS headsign, COALESCE(t.direction_id, 0) AS direction_id,
+				COALESCE(t.wheelchair_accessible, false) AS trip_wheelchair, COALESCE(t.bikes_allowed, false) AS bikes_allowed,
+				t.route_id
+			FROM nearby n
+			JOIN stop_times st ON st.stop_id = n.id
+			JOIN trips t ON t.id = st.trip_id
+			WHERE st.departure_time >= make_interval(secs => $4)
+			  AND st.departure_time < make_interval(secs => $4 + $5)
+			  AND (NOT $7 OR t.bikes_allowed)
+			ORDER BY t.id, n.distance
+		)
+		SELECT
+			departure_time,
+			id, stop_id, agency_id, name, lat, lon, platform_code, wheelchair_accessible, distance,
+			trip_uuid, trip_id, headsign, direction_id, trip_wheelchair, bikes_allowed, route_id
+		FROM next
+		ORDER BY departure_time, distance
+		LIMIT $6
+	`, lon, lat, radiusMeters, todSeconds, int(horizon.Seconds()), limit, filter.Bikes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var departures []domain.NearbyDeparture
+	for rows.Next() {
+		var depInterval time.Duration
+		var stop domain.Stop
+		var dist float64
+		var trip domain.Trip
+		if err := rows.Scan(
+			&depInterval,
+			&stop.ID, &stop.StopID, &stop.AgencyID, &stop.Name, &stop.Location.Lat, &stop.Location.Lon,
+			&stop.PlatformCode, &stop.WheelchairAccessible, &dist,
+			&trip.ID, &trip.TripID, &trip.Headsign, &trip.DirectionID,
+			&trip.WheelchairAccessible, &trip.BikesAllowed, &trip.RouteID,
+		); err != nil {
+			return nil, err
+		}
+		stop.Distance = &dist
+		departures = append(departures, domain.NearbyDeparture{
+			Departure: domain.Departure{Trip: &trip, ScheduledTime: today.Add(depInterval)},
+			Stop:      &stop,
+		})
+	}
+	return departures, rows.Err()
+}
+
+// Resolve implements ports.TripResolver.
+func (r *TripRepo) Resolve(ctx context.Context, agencyID, gtfsTripID, gtfsRouteID string) (string, string, error) {
+	var tripID, routeID *string
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT
+			(SELECT t.id::text FROM trips t JOIN routes r ON r.id = t.route_id
+			 WHERE t.trip_id = $2 AND r.agency_id = $1 LIMIT 1),
+			(SELECT id::text FROM routes WHERE route_id = $3 AND agency_id = $1 LIMIT 1)
+	`, agencyID, gtfsTripID, gtfsRouteID).Scan(&tripID, &routeID)
+	if err != nil {
+		return "", "", err
+	}
+	var tid, rid string
+	if tripID != nil {
+		tid = *tripID
+	}
+	if routeID != nil {
+		rid = *routeID
+	}
+	return tid, rid, nil
+}
+
+// TripStops implements ports.FeedIDResolver. A GTFS trip ID the agency's
+// schedule has twice resolves to one of them.
+func (r *TripRepo) TripStops(ctx context.Context, agencyID string, gtfsTripIDs []string) (map[string][]domain.TripStop, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT t.trip_id, t.id::text, s.id::text, s.stop_id, st.stop_sequence
+		FROM (
+			SELECT DISTINCT ON (t.trip_id) t.id, t.trip_id
+			FROM trips t
+			JOIN routes r ON r.id = t.route_id
+			WHERE r.agency_id = $1 AND t.trip_id = ANY($2::text[])
+			ORDER BY t.trip_id, t.id
+		) t
+		JOIN stop_times st ON st.trip_id = t.id
+		JOIN stops s ON s.id = st.stop_id
+		ORDER BY t.trip_id, st.stop_sequence
+	`, agencyID, gtfsTripIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stops := map[string][]domain.TripStop{}
+	for rows.Next() {
+		var gtfsTripID string
+		var ts domain.TripStop
+		if err := rows.Scan(&gtfsTripID, &ts.TripID, &ts.StopID, &ts.GTFSStopID, &ts.StopSequence); err != nil {
+			return nil, err
+		}
+		stops[gtfsTripID] = append(stops[gtfsTripID], ts)
+	}
+	return stops, rows.Err()
+}
+
+// RouteIDs implements ports.FeedIDResolver.
+func (r *TripRepo) RouteIDs(ctx context.Context, agencyID string, gtfsRouteIDs []string) (map[string]string, error) {
+	return r.feedIDs(ctx, `SELECT route_id, id::text FROM routes WHERE agency_id = $1 AND route_id = ANY($2::text[])`,
+		agencyID, gtfsRouteIDs)
+}
+
+// StopIDs implements ports.FeedIDResolver.
+func (r *TripRepo) StopIDs(ctx context.Context, agencyID string, gtfsStopIDs []string) (map[string]string, error) {
+	return r.feedIDs(ctx, `SELECT stop_id, id::text FROM stops WHERE agency_id = $1 AND stop_id = ANY($2::text[])`,
+		agencyID, gtfsStopIDs)
+}
+
+// feedIDs maps the GTFS IDs the query selects to the internal IDs it selects.
+func (r *TripRepo) feedIDs(ctx context.Context, query, agencyID string, gtfsIDs []string) (map[string]string, error) {
+	ids := map[string]string{}
+	if len(gtfsIDs) == 0 {
+		return ids, nil
+	}
+	rows, err := r.db.Pool.Query(ctx, query, agencyID, gtfsIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var gtfsID, id string
+		if err := rows.Scan(&gtfsID, &id); err != nil {
+			return nil, err
+		}
+		ids[gtfsID] = id
+	}
+	return ids, rows.Err()
+}
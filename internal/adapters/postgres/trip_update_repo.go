@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// TripUpdateRepo implements ports.TripUpdateRepository.
+type TripUpdateRepo struct {
+	db *DB
+}
+
+func NewTripUpdateRepo(db *DB) *TripUpdateRepo {
+	return &TripUpdateRepo{db: db}
+}
+
+// InsertBatch inserts predictions using pgx.Batch.
+func (r *TripUpdateRepo) InsertBatch(ctx context.Context, predictions []domain.StopTimePrediction) error {
+	if len(predictions) == 0 {
+		return nil
+	}
+	batch := &pgx.Batch{}
+	for _, p := range predictions {
+		batch.Queue(`
+			INSERT INTO stop_time_predictions (time, trip_id, stop_id, stop_sequence, arrival_delay, departure_delay,
+				predicted_arrival, predicted_departure, schedule_relationship)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`, p.Time, p.TripID, p.StopID, p.StopSequence, p.ArrivalDelay, p.DepartureDelay,
+			p.PredictedArrival, p.PredictedDeparture, p.ScheduleRelationship)
+	}
+	br := r.db.Pool.SendBatch(ctx, batch)
+	defer br.Close()
+	for range predictions {
+		if _, err := br.Exec(); err != nil {
+			return fmt.Errorf("batch exec: %w", err)
+		}
+	}
+	return nil
+}
+
+// LatestAtStop returns the newest prediction per trip at a stop recorded since the given time.
+func (r *TripUpdateRepo) LatestAtStop(ctx context.Context, stopUUID string, since time.Time) ([]domain.StopTimePrediction, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT DISTINCT ON (trip_id)
+			time, trip_id, stop_id, stop_sequence, arrival_delay, departure_delay,
+			predicted_arrival, predicted_departure, COALESCE(schedule_relationship, 0)
+		FROM stop_time_predictions
+		WHERE stop_id = $1 AND time >= $2
+		ORDER BY trip_id, time DESC
+	`, stopUUID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var predictions []domain.StopTimePrediction
+	for rows.Next() {
+		var p domain.StopTimePrediction
+		if err := rows.Scan(
+			&p.Time, &p.TripID, &p.StopID, &p.StopSequence, &p.ArrivalDelay, &p.DepartureDelay,
+			&p.PredictedArrival, &p.PredictedDeparture, &p.ScheduleRelationship,
+		); err != nil {
+			return nil, err
+		}
+		predictions = append(predictions, p)
+	}
+	return predictions, rows.Err()
+}
+
+// LatestAtStops returns the newest prediction per trip and stop at the given
+// stops recorded since the given time.
+func (r *TripUpdateRepo) LatestAtStops(ctx context.Context, stopUUIDs []string, since time.Time) ([]domain.StopTimePrediction, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT DISTINCT ON (trip_id, stop_id)
+			time, trip_id, stop_id, stop_sequence, arrival_delay, departure_delay,
+			predicted_arrival, predicted_departure, COALESCE(schedule_relationship, 0)
+		FROM stop_time_predictions
+		WHERE stop_id = ANY($1) AND time >= $2
+		ORDER BY trip_id, stop_id, time DESC
+	`, stopUUIDs, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var predictions []domain.StopTimePrediction
+	for rows.Next() {
+		var p domain.StopTimePrediction
+		if err := rows.Scan(
+			&p.Time, &p.TripID, &p.StopID, &p.StopSequence, &p.ArrivalDelay, &p.DepartureDelay,
+			&p.PredictedArrival, &p.PredictedDeparture, &p.ScheduleRelationship,
+		); err != nil {
+			return nil, err
+		}
+		predictions = append(predictions, p)
+	}
+	return predictions, rows.Err()
+}
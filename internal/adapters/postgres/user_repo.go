@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// UserRepo implements ports.UserRepository.
+type UserRepo struct {
+	db *DB
+}
+
+func NewUserRepo(db *DB) *UserRepo { return &UserRepo{db: db} }
+
+const userColumns = `id, email, password_hash, COALESCE(display_name, ''), role, COALESCE(affiliate_id::text, ''), created_at`
+
+func (r *UserRepo) Create(ctx context.Context, u *domain.User) (bool, error) {
+	err := r.db.Pool.QueryRow(ctx, `
+		INSERT INTO users (email, password_hash, display_name, role)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (email) DO NOTHING
+		RETURNING id, created_at
+	`, u.Email, u.PasswordHash, nilIfEmpty(u.DisplayName), u.Role).Scan(&u.ID, &u.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *UserRepo) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	return r.get(ctx, `SELECT `+userColumns+` FROM users WHERE email = $1`, email)
+}
+
+func (r *UserRepo) GetByID(ctx context.Context, id string) (*domain.User, error) {
+	return r.get(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id)
+}
+
+func (r *UserRepo) get(ctx context.Context, query string, arg string) (*domain.User, error) {
+	var u domain.User
+	err := r.db.Pool.QueryRow(ctx, query, arg).Scan(&u.ID, &u.Email, &u.PasswordHash, &u.DisplayName, &u.Role, &u.AffiliateID, &u.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/samirrijal/bilbopass/internal/core/domain"
 )
@@ -16,13 +17,26 @@ func NewVehiclePositionRepo(db *DB) *VehiclePositionRepo {
 	return &VehiclePositionRepo{db: db}
 }
 
+// Insert stores a position. The feed's stop ID and stop sequence are
+// resolved against the trip's stop times; the stop is left empty when
+// neither is reported or they do not match the trip.
 func (r *VehiclePositionRepo) Insert(ctx context.Context, vp *domain.VehiclePosition) error {
 	_, err := r.db.Pool.Exec(ctx, `
-		INSERT INTO vehicle_positions (time, vehicle_id, trip_id, route_id, location, bearing, speed, congestion_level, occupancy_status, metadata)
-		VALUES ($1, $2, $3, $4, ST_SetSRID(ST_MakePoint($5, $6), 4326)::geography, $7, $8, $9, $10, $11)
+		INSERT INTO vehicle_positions (time, vehicle_id, trip_id, route_id, stop_id, location, bearing, speed, congestion_level, occupancy_status, metadata)
+		VALUES ($1, $2, $3, $4, (
+			SELECT st.stop_id FROM stop_times st
+			JOIN stops s ON s.id = st.stop_id
+			WHERE st.trip_id = $3::uuid
+			  AND ($12::text IS NOT NULL OR $13::int IS NOT NULL)
+			  AND ($12::text IS NULL OR s.stop_id = $12)
+			  AND ($13::int IS NULL OR st.stop_sequence = $13)
+			ORDER BY st.stop_sequence
+			LIMIT 1
+		), ST_SetSRID(ST_MakePoint($5, $6), 4326)::geography, $7, $8, $9, $10, $11)
 	`, vp.Time, vp.VehicleID, nilIfEmpty(vp.TripID), nilIfEmpty(vp.RouteID),
 		vp.Location.Lon, vp.Location.Lat, vp.Bearing, vp.Speed,
-		vp.CongestionLevel, vp.OccupancyStatus, vp.Metadata)
+		vp.CongestionLevel, vp.OccupancyStatus, vp.Metadata,
+		nilIfEmpty(vp.StopID), vp.StopSequence)
 	return err
 }
 
@@ -36,16 +50,77 @@ func (r *VehiclePositionRepo) InsertBatch(ctx context.Context, vps []domain.Vehi
 }
 
 func (r *VehiclePositionRepo) LatestByRoute(ctx context.Context, routeID string) ([]domain.VehiclePosition, error) {
+	return r.LatestByRoutes(ctx, []string{routeID})
+}
+
+// LatestByRoutes returns the latest position of each vehicle on each of the
+// routes, by route and vehicle.
+func (r *VehiclePositionRepo) LatestByRoutes(ctx context.Context, routeIDs []string) ([]domain.VehiclePosition, error) {
+	if len(routeIDs) == 0 {
+		return nil, nil
+	}
+
 	rows, err := r.db.Pool.Query(ctx, `
-		SELECT DISTINCT ON (vehicle_id)
+		SELECT DISTINCT ON (route_id, vehicle_id)
 			time, vehicle_id, trip_id, route_id,
 			ST_Y(location::geometry) as lat,
 			ST_X(location::geometry) as lon,
-			bearing, speed, congestion_level, occupancy_status
+			bearing, speed, congestion_level, occupancy_status, metadata
 		FROM vehicle_positions
-		WHERE route_id = $1
-		ORDER BY vehicle_id, time DESC
-	`, routeID)
+		WHERE route_id = ANY($1)
+		ORDER BY route_id, vehicle_id, time DESC
+	`, routeIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var positions []domain.VehiclePosition
+	for rows.Next() {
+		var vp domain.VehiclePosition
+		var tripID, routeIDVal sql.NullString
+		if err := rows.Scan(
+			&vp.Time, &vp.VehicleID, &tripID, &routeIDVal,
+			&vp.Location.Lat, &vp.Location.Lon,
+			&vp.Bearing, &vp.Speed, &vp.CongestionLevel, &vp.OccupancyStatus, &vp.Metadata,
+		); err != nil {
+			return nil, err
+		}
+		vp.TripID = tripID.String
+		vp.RouteID = routeIDVal.String
+		positions = append(positions, vp)
+	}
+	return positions, rows.Err()
+}
+
+func (r *VehiclePositionRepo) LatestNearby(ctx context.Context, lat, lon, radiusMeters float64, since time.Time, limit int, routeTypes domain.RouteTypeFilter) ([]domain.VehiclePosition, error) {
+	// Pick each vehicle's latest position before filtering by distance, so a
+	// vehicle that has left the area is not shown where it was.
+	rows, err := r.db.Pool.Query(ctx, `
+		WITH latest AS (
+			SELECT DISTINCT ON (metadata->>'agency', vehicle_id)
+				time, vehicle_id, trip_id, route_id, location,
+				bearing, speed, congestion_level, occupancy_status, metadata
+			FROM vehicle_positions
+			WHERE time > $4
+			ORDER BY metadata->>'agency', vehicle_id, time DESC
+		)
+		SELECT v.time, v.vehicle_id, v.trip_id, v.route_id,
+			ST_Y(v.location::geometry) as lat,
+			ST_X(v.location::geometry) as lon,
+			v.bearing, v.speed, v.congestion_level, v.occupancy_status, v.metadata,
+			COALESCE(NULLIF(r.short_name, ''), r.long_name, ''), COALESCE(r.color, ''),
+			COALESCE(t.headsign, ''),
+			ST_Distance(v.location, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography) as distance
+		FROM latest v
+		LEFT JOIN routes r ON r.id = v.route_id
+		LEFT JOIN trips t ON t.id = v.trip_id
+		WHERE ST_DWithin(v.location, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography, $3)
+		  AND ($6::int[] IS NULL OR r.route_type = ANY($6))
+		  AND ($7::int[] IS NULL OR r.route_type IS NULL OR r.route_type <> ALL($7))
+		ORDER BY distance
+		LIMIT $5
+	`, lat, lon, radiusMeters, since, limit, routeTypes.Include, routeTypes.Exclude)
 	if err != nil {
 		return nil, err
 	}
@@ -55,20 +130,117 @@ func (r *VehiclePositionRepo) LatestByRoute(ctx context.Context, routeID string)
 	for rows.Next() {
 		var vp domain.VehiclePosition
 		var tripID, routeIDVal sql.NullString
+		var dist float64
 		if err := rows.Scan(
 			&vp.Time, &vp.VehicleID, &tripID, &routeIDVal,
 			&vp.Location.Lat, &vp.Location.Lon,
-			&vp.Bearing, &vp.Speed, &vp.CongestionLevel, &vp.OccupancyStatus,
+			&vp.Bearing, &vp.Speed, &vp.CongestionLevel, &vp.OccupancyStatus, &vp.Metadata,
+			&vp.RouteName, &vp.RouteColor, &vp.Headsign, &dist,
 		); err != nil {
 			return nil, err
 		}
 		vp.TripID = tripID.String
 		vp.RouteID = routeIDVal.String
+		vp.Distance = &dist
 		positions = append(positions, vp)
 	}
 	return positions, rows.Err()
 }
 
+func (r *VehiclePositionRepo) History(ctx context.Context, agency, vehicleID string, from, to time.Time, bucket time.Duration) ([]domain.VehiclePosition, error) {
+	// Keep real fixes rather than averaging them, so a downsampled track
+	// still runs along the streets the vehicle used.
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT DISTINCT ON (time_bucket($5::int * interval '1 second', time))
+			time, vehicle_id, trip_id, route_id,
+			ST_Y(location::geometry) as lat,
+			ST_X(location::geometry) as lon,
+			bearing, speed, congestion_level, occupancy_status, metadata
+		FROM vehicle_positions
+		WHERE vehicle_id = $1 AND ($2 = '' OR metadata->>'agency' = $2)
+		  AND time >= $3 AND time < $4
+		ORDER BY time_bucket($5::int * interval '1 second', time), time DESC
+	`, vehicleID, agency, from, to, int(bucket.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var positions []domain.VehiclePosition
+	for rows.Next() {
+		var vp domain.VehiclePosition
+		var tripID, routeIDVal sql.NullString
+		if err := rows.Scan(
+			&vp.Time, &vp.VehicleID, &tripID, &routeIDVal,
+			&vp.Location.Lat, &vp.Location.Lon,
+			&vp.Bearing, &vp.Speed, &vp.CongestionLevel, &vp.OccupancyStatus, &vp.Metadata,
+		); err != nil {
+			return nil, err
+		}
+		vp.TripID = tripID.String
+		vp.RouteID = routeIDVal.String
+		positions = append(positions, vp)
+	}
+	return positions, rows.Err()
+}
+
+// Fleet aggregates each vehicle's latest position in one query over the
+// hypertable. Vehicles without a route only count towards the total.
+func (r *VehiclePositionRepo) Fleet(ctx context.Context, agency string, since, now time.Time) (*domain.Fleet, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		WITH latest AS (
+			SELECT DISTINCT ON (vehicle_id) vehicle_id, route_id, time, speed,
+			       EXTRACT(EPOCH FROM $3::timestamptz - time)::float8 AS age
+			FROM vehicle_positions
+			WHERE metadata->>'agency' = $1 AND time >= $2
+			ORDER BY vehicle_id, time DESC
+		)
+		SELECT GROUPING(l.route_id) = 1, l.route_id, COALESCE(MAX(rt.short_name), ''), COUNT(*)::int,
+		       MIN(l.speed), percentile_cont(0.5) WITHIN GROUP (ORDER BY l.speed), MAX(l.speed),
+		       MIN(l.age), percentile_cont(0.5) WITHIN GROUP (ORDER BY l.age), MAX(l.age)
+		FROM latest l
+		LEFT JOIN routes rt ON rt.id = l.route_id
+		GROUP BY GROUPING SETS ((l.route_id), ())
+	`, agency, since, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fleet := &domain.Fleet{AgencySlug: agency, Since: since, Routes: []domain.RouteFleet{}}
+	for rows.Next() {
+		var (
+			total                        bool
+			routeID                      *string
+			shortName                    string
+			stats                        domain.FleetStats
+			minSpeed, medSpeed, maxSpeed *float64
+			minAge, medAge, maxAge       *float64
+		)
+		if err := rows.Scan(&total, &routeID, &shortName, &stats.Active,
+			&minSpeed, &medSpeed, &maxSpeed, &minAge, &medAge, &maxAge); err != nil {
+			return nil, err
+		}
+		stats.Speed = spreadStats(minSpeed, medSpeed, maxSpeed)
+		stats.Staleness = spreadStats(minAge, medAge, maxAge)
+		switch {
+		case total:
+			fleet.FleetStats = stats
+		case routeID != nil:
+			fleet.Routes = append(fleet.Routes, domain.RouteFleet{RouteID: *routeID, ShortName: shortName, FleetStats: stats})
+		}
+	}
+	return fleet, rows.Err()
+}
+
+// spreadStats returns nil when the aggregates had no values.
+func spreadStats(least, median, greatest *float64) *domain.SpreadStats {
+	if least == nil || median == nil || greatest == nil {
+		return nil
+	}
+	return &domain.SpreadStats{Min: *least, Median: *median, Max: *greatest}
+}
+
 func nilIfEmpty(s string) interface{} {
 	if s == "" {
 		return nil
@@ -0,0 +1,78 @@
+// Package streets routes on foot and by bike along the street network with
+// OSRM (Open Source Routing Machine).
+package streets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// OSRM implements ports.StreetRouter with the OSRM route service. An OSRM
+// server routes one profile, so walking and cycling each have their own.
+type OSRM struct {
+	client *http.Client
+	urls   map[string]string // base URL per street mode
+}
+
+// NewOSRM creates an OSRM router from the base URLs of the foot and bicycle
+// servers, either of which may be empty to leave that mode out.
+func NewOSRM(walkURL, bikeURL string) (*OSRM, error) {
+	urls := make(map[string]string)
+	for mode, raw := range map[string]string{domain.StreetWalk: walkURL, domain.StreetBike: bikeURL} {
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(strings.TrimSuffix(raw, "/"))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("osrm %s url must be an http(s) URL", mode)
+		}
+		urls[mode] = u.String()
+	}
+	return &OSRM{client: &http.Client{Timeout: 5 * time.Second}, urls: urls}, nil
+}
+
+// osrmProfiles names the profile in request paths; servers ignore it, but it
+// documents the request.
+var osrmProfiles = map[string]string{domain.StreetWalk: "foot", domain.StreetBike: "bike"}
+
+// Route returns the length and travel time of the fastest street route.
+func (o *OSRM) Route(ctx context.Context, mode string, from, to domain.GeoPoint) (float64, time.Duration, error) {
+	base, ok := o.urls[mode]
+	if !ok {
+		return 0, 0, fmt.Errorf("osrm: no server for %s", mode)
+	}
+	endpoint := fmt.Sprintf("%s/route/v1/%s/%f,%f;%f,%f?overview=false",
+		base, osrmProfiles[mode], from.Lon, from.Lat, to.Lon, to.Lat)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Code   string `json:"code"`
+		Routes []struct {
+			Distance float64 `json:"distance"` // meters
+			Duration float64 `json:"duration"` // seconds
+		} `json:"routes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, 0, fmt.Errorf("osrm: %s: %w", resp.Status, err)
+	}
+	if body.Code != "Ok" || len(body.Routes) == 0 {
+		return 0, 0, fmt.Errorf("osrm: %s: %s", resp.Status, body.Code)
+	}
+	r := body.Routes[0]
+	return r.Distance, time.Duration(r.Duration * float64(time.Second)), nil
+}
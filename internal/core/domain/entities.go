@@ -6,26 +6,280 @@ import (
 
 // Agency represents a transit agency (e.g. Bilbobus, EuskoTren).
 type Agency struct {
+	ID        string        `json:"id"`
+	Slug      string        `json:"slug"`
+	Name      string        `json:"name"`
+	URL       string        `json:"url,omitempty"`
+	Timezone  string        `json:"timezone"`
+	Contact   AgencyContact `json:"contact"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// AgencyContact holds an operator's customer service details, so apps can
+// offer "contact the operator" flows.
+type AgencyContact struct {
+	Phone        string `json:"phone,omitempty"` // customer service
+	LostFoundURL string `json:"lost_found_url,omitempty"`
+	ComplaintURL string `json:"complaint_url,omitempty"`
+}
+
+// AgencyBranding holds an operator's logo, colors and route icons, so apps
+// can draw line badges that look like the operator's own without bundling
+// assets. Colors are RRGGBB hex, like GTFS route colors.
+type AgencyBranding struct {
+	AgencyID       string       `json:"agency_id"`
+	LogoURL        string       `json:"logo_url,omitempty"`
+	PrimaryColor   string       `json:"primary_color,omitempty"`
+	SecondaryColor string       `json:"secondary_color,omitempty"`
+	TextColor      string       `json:"text_color,omitempty"`
+	Routes         []RouteBadge `json:"routes"`
+	UpdatedAt      time.Time    `json:"updated_at,omitempty"`
+}
+
+// RouteBadge is what a client needs to draw a route's line badge. IconURL
+// is empty when the agency uploaded no icon for the route; clients then
+// draw ShortName on Color.
+type RouteBadge struct {
+	RouteID   string `json:"route_id"`
+	ShortName string `json:"short_name"`
+	Color     string `json:"color,omitempty"`
+	TextColor string `json:"text_color,omitempty"`
+	IconURL   string `json:"icon_url,omitempty"`
+}
+
+// AgencyAlias maps a GTFS agency in another source feed to a canonical
+// agency, so its routes and stops are only ingested once. An empty
+// SourceAgencyID covers the whole source feed.
+type AgencyAlias struct {
+	AgencyID       string    `json:"agency_id"`
+	SourceSlug     string    `json:"source_slug"`
+	SourceAgencyID string    `json:"source_agency_id"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// AgencyFeeds is an agency the ingestor and realtime poller fetch feeds of,
+// with the shape of a manifest.json entry. GTFSRT is nil for agencies
+// without realtime feeds.
+type AgencyFeeds struct {
+	AgencyID  string       `json:"agency_id"`
+	Slug      string       `json:"slug"`
+	Name      string       `json:"name"`
+	GTFSURL   string       `json:"gtfs_url"`
+	GTFSRT    *GTFSRTFeeds `json:"gtfs_rt,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// GTFSRTFeeds holds an agency's GTFS-RT feed URLs; empty ones are not
+// polled.
+type GTFSRTFeeds struct {
+	VehiclePositions string `json:"vehicle_positions,omitempty"`
+	TripUpdates      string `json:"trip_updates,omitempty"`
+	Alerts           string `json:"alerts,omitempty"`
+	PollInterval     int    `json:"poll_interval,omitempty"` // seconds; 0 for the poller's default
+	VehiclePush      bool   `json:"vehicle_push,omitempty"`  // positions pushed to transit.push.<slug>.vehicles
+}
+
+// PushKey is a key an agency signs the realtime data it pushes with. The
+// secret is only shown when the key is created.
+type PushKey struct {
 	ID        string    `json:"id"`
-	Slug      string    `json:"slug"`
-	Name      string    `json:"name"`
-	URL       string    `json:"url,omitempty"`
-	Timezone  string    `json:"timezone"`
+	AgencyID  string    `json:"agency_id"`
+	Label     string    `json:"label"`
+	Secret    string    `json:"secret,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// PushEnvelope carries pushed realtime data, signed with a PushKey: the
+// hex HMAC-SHA256 of "<timestamp>.<nonce>." followed by the payload.
+type PushEnvelope struct {
+	KeyID     string `json:"key_id"`
+	Timestamp int64  `json:"timestamp"` // Unix seconds
+	Nonce     string `json:"nonce"`     // used once
+	Payload   []byte `json:"payload"`   // base64 in JSON
+	Signature string `json:"signature"`
+}
+
 // Stop represents a transit stop or station.
 type Stop struct {
 	ID                   string         `json:"id"`
 	StopID               string         `json:"stop_id"`
+	StopCode             string         `json:"stop_code,omitempty"` // short code shown to riders, e.g. on the stop's pole
 	AgencyID             string         `json:"agency_id"`
 	Name                 string         `json:"name"`
+	Description          string         `json:"description,omitempty"` // stops.txt stop_desc
+	URL                  string         `json:"url,omitempty"`         // the agency's page about the stop
+	ZoneID               string         `json:"zone_id,omitempty"`     // fare zone, in the agency's feed
 	Location             GeoPoint       `json:"location"`
 	PlatformCode         string         `json:"platform_code,omitempty"`
 	WheelchairAccessible bool           `json:"wheelchair_accessible"`
+	LocationType         int            `json:"location_type"`       // GTFS location_type, one of the Location constants
+	ParentID             string         `json:"parent_id,omitempty"` // station of a platform
+	Platforms            []Stop         `json:"platforms,omitempty"` // a station's platforms, filled in by nearby and search
+	Amenities            StopAmenities  `json:"amenities"`
 	Metadata             map[string]any `json:"metadata,omitempty"`
 	Distance             *float64       `json:"distance,omitempty"` // computed field
 	CreatedAt            time.Time      `json:"created_at"`
+	UpdatedAt            time.Time      `json:"updated_at"` // last change to the stop's data
+}
+
+// GTFS location types of a stop.
+const (
+	LocationStop         = 0 // a stop or platform where riders board
+	LocationStation      = 1 // a station grouping platforms
+	LocationEntrance     = 2 // an entrance or exit of a station
+	LocationNode         = 3 // a generic node inside a station
+	LocationBoardingArea = 4 // an area of a platform
+)
+
+// IsStation reports whether the stop is a station rather than a place
+// riders board.
+func (s Stop) IsStation() bool { return s.LocationType == LocationStation }
+
+// SearchSynonym makes stop searches for Term also find stops matching
+// Synonym, scored Boost times. Unless OneWay, searches for Synonym also find
+// Term, as for Casco Viejo and Zazpikaleak; a misspelling maps one way to
+// the right name.
+type SearchSynonym struct {
+	ID        string    `json:"id"`
+	Term      string    `json:"term"`
+	Synonym   string    `json:"synonym"`
+	OneWay    bool      `json:"one_way"`
+	Boost     float64   `json:"boost"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SearchStats measures how often stop searches find something, with the
+// queries that most often found nothing.
+type SearchStats struct {
+	From      time.Time    `json:"from"`
+	To        time.Time    `json:"to"`
+	Searches  int          `json:"searches"`
+	Hits      int          `json:"hits"` // searches that found at least one stop
+	HitRate   float64      `json:"hit_rate"`
+	TopMisses []SearchMiss `json:"top_misses"`
+}
+
+// SearchMiss is a query that found no stops, and how often it was searched.
+type SearchMiss struct {
+	Query    string `json:"query"`
+	Searches int    `json:"searches"`
+}
+
+// SearchHit is one result of a search across agencies, routes and stops.
+// Exactly one of Agency, Route and Stop is set, as Type says. Score is how
+// well it matched, 1 for an exact slug, route short name or stop code; a
+// boosted synonym can score a stop above 1.
+type SearchHit struct {
+	Type   string  `json:"type"`
+	Score  float64 `json:"score"`
+	Agency *Agency `json:"agency,omitempty"`
+	Route  *Route  `json:"route,omitempty"`
+	Stop   *Stop   `json:"stop,omitempty"`
+}
+
+// Search hit types.
+const (
+	SearchHitAgency = "agency"
+	SearchHitRoute  = "route"
+	SearchHitStop   = "stop"
+)
+
+// SearchLimits caps how many hits of each type a search returns; zero
+// leaves a type out.
+type SearchLimits struct {
+	Agencies int
+	Routes   int
+	Stops    int
+}
+
+// StopAmenities describes the facilities at a stop. A nil field is unknown.
+type StopAmenities struct {
+	Shelter         *bool      `json:"shelter"`
+	Bench           *bool      `json:"bench"`
+	RealtimeDisplay *bool      `json:"realtime_display"`
+	Source          string     `json:"source,omitempty"` // agency, osm, admin or community
+	UpdatedAt       *time.Time `json:"updated_at,omitempty"`
+}
+
+// Stop amenity sources.
+const (
+	AmenitySourceAgency    = "agency"
+	AmenitySourceOSM       = "osm"
+	AmenitySourceAdmin     = "admin"
+	AmenitySourceCommunity = "community"
+)
+
+// StopFilter narrows nearby stop queries to stops known to have amenities.
+type StopFilter struct {
+	HasShelter         bool
+	HasBench           bool
+	HasRealtimeDisplay bool
+	RouteTypes         RouteTypeFilter // of the routes serving the stop
+}
+
+// RouteMode is a kind of transport and the GTFS route_types that are it:
+// the basic route type and the extended ones mapping to it.
+type RouteMode struct {
+	Mode       string `json:"mode"`
+	Label      string `json:"label"`
+	RouteTypes []int  `json:"route_types"` // basic route type first
+}
+
+// RouteTypeFilter selects routes by GTFS route_type. Include keeps only the
+// listed route types, and is ignored when empty; Exclude leaves out the
+// listed ones.
+type RouteTypeFilter struct {
+	Include []int
+	Exclude []int
+}
+
+// RouteFilter selects the routes a route listing returns. An empty AgencyID
+// lists every agency's routes; Query matches short and long names.
+type RouteFilter struct {
+	AgencyID   string
+	RouteTypes RouteTypeFilter
+	Query      string
+}
+
+// JourneyFilter restricts the services a journey may take. Wheelchair keeps
+// only wheelchair-accessible trips, boarded and left at wheelchair-accessible
+// stops; Bikes keeps only trips allowing bikes. A Mode of StreetWalk or
+// StreetBike takes no services at all, only the streets.
+type JourneyFilter struct {
+	RouteTypes RouteTypeFilter
+	Wheelchair bool
+	Bikes      bool
+	Mode       string
+}
+
+// Street modes, for journeys on foot or by bike only.
+const (
+	StreetWalk = "walk"
+	StreetBike = "bike"
+)
+
+// StreetRoute is a journey along the streets, on foot or by bike.
+type StreetRoute struct {
+	Mode            string  `json:"mode"` // walk | bike
+	DistanceKm      float64 `json:"distance_km"`
+	DurationMinutes int     `json:"duration_minutes"`
+	Estimated       bool    `json:"estimated,omitempty"` // from straight-line distance, not routed
+}
+
+// Journey ranking preferences.
+const (
+	PreferEarliestArrival = "earliest_arrival"
+	PreferFewestTransfers = "fewest_transfers"
+	PreferLeastWalking    = "least_walking"
+)
+
+// JourneyRanking chooses the journeys a plan lists: up to Limit of them, best
+// first by Prefer, which is PreferEarliestArrival when empty.
+type JourneyRanking struct {
+	Prefer string
+	Limit  int
 }
 
 // Route represents a transit route.
@@ -39,21 +293,36 @@ type Route struct {
 	Color     string         `json:"color"`
 	TextColor string         `json:"text_color"`
 	Shape     *GeoLineString `json:"shape,omitempty"`
+	Metadata  map[string]any `json:"metadata,omitempty"` // original_long_name when the name was cleaned up
 	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"` // last change to the route's data, shape included
 }
 
 // Trip represents a single trip on a route.
 type Trip struct {
-	ID                   string    `json:"id"`
-	TripID               string    `json:"trip_id"`
-	RouteID              string    `json:"route_id"`
-	ServiceID            string    `json:"service_id"`
-	Headsign             string    `json:"headsign,omitempty"`
-	DirectionID          int       `json:"direction_id"`
-	ShapeID              string    `json:"shape_id,omitempty"`
-	WheelchairAccessible bool      `json:"wheelchair_accessible"`
-	BikesAllowed         bool      `json:"bikes_allowed"`
-	CreatedAt            time.Time `json:"created_at"`
+	ID                   string            `json:"id"`
+	TripID               string            `json:"trip_id"`
+	RouteID              string            `json:"route_id"`
+	ServiceID            string            `json:"service_id"`
+	Headsign             string            `json:"headsign,omitempty"`
+	DirectionID          int               `json:"direction_id"`
+	ShapeID              string            `json:"shape_id,omitempty"`
+	WheelchairAccessible bool              `json:"wheelchair_accessible"`
+	BikesAllowed         bool              `json:"bikes_allowed"`
+	BlockID              string            `json:"block_id,omitempty"`     // trips.txt block_id: trips run by the same vehicle
+	ContinuesAs          *TripContinuation `json:"continues_as,omitempty"` // the vehicle's next trip, when riders may stay on board
+	CreatedAt            time.Time         `json:"created_at"`
+	UpdatedAt            time.Time         `json:"updated_at"`
+}
+
+// TripContinuation names the trip a vehicle continues as at the end of a
+// trip: "continues as line RouteShortName towards Headsign".
+type TripContinuation struct {
+	ID             string `json:"id"` // the trip's UUID
+	RouteID        string `json:"route_id"`
+	RouteShortName string `json:"route_short_name,omitempty"`
+	RouteLongName  string `json:"route_long_name"`
+	Headsign       string `json:"headsign,omitempty"`
 }
 
 // StopTime represents a scheduled stop on a trip.
@@ -69,6 +338,19 @@ type StopTime struct {
 	CreatedAt     time.Time     `json:"created_at"`
 }
 
+// Sources of vehicle positions, recorded in their "source" metadata.
+const (
+	VehicleSourceFeed = "gtfs_rt" // polled from the agency's GTFS-RT feed
+	VehicleSourcePush = "push"    // pushed by the agency
+)
+
+// Metadata keys of published vehicle positions, which carry the feed's
+// trip and route IDs, holding the internal IDs those resolved to.
+const (
+	VehicleTripUUID  = "trip_uuid"
+	VehicleRouteUUID = "route_uuid"
+)
+
 // VehiclePosition is a real-time vehicle location reading.
 type VehiclePosition struct {
 	Time            time.Time      `json:"time"`
@@ -79,8 +361,120 @@ type VehiclePosition struct {
 	Bearing         float64        `json:"bearing"`
 	Speed           float64        `json:"speed"` // m/s
 	CongestionLevel int            `json:"congestion_level"`
-	OccupancyStatus int            `json:"occupancy_status"`
+	OccupancyStatus *int           `json:"occupancy_status,omitempty"` // GTFS-RT OccupancyStatus, nil when not reported
+	StopID          string         `json:"stop_id,omitempty"`          // feed's ID of the stop the vehicle is at or approaching
+	StopSequence    *int           `json:"stop_sequence,omitempty"`    // that stop's sequence in the trip
 	Metadata        map[string]any `json:"metadata,omitempty"`
+	RouteName       string         `json:"route_name,omitempty"`  // filled in by nearby queries
+	RouteColor      string         `json:"route_color,omitempty"` // filled in by nearby queries
+	Headsign        string         `json:"headsign,omitempty"`    // filled in by nearby queries
+	Distance        *float64       `json:"distance,omitempty"`    // computed field
+	Place           *Place         `json:"place,omitempty"`       // street and neighborhood, when asked for
+}
+
+// StopTimePrediction is a GTFS-RT trip update prediction for one stop of a trip.
+type StopTimePrediction struct {
+	Time                 time.Time  `json:"time"` // feed timestamp
+	TripID               string     `json:"trip_id"`
+	StopID               string     `json:"stop_id"`
+	StopSequence         int        `json:"stop_sequence"`
+	ArrivalDelay         *int       `json:"arrival_delay,omitempty"`   // seconds
+	DepartureDelay       *int       `json:"departure_delay,omitempty"` // seconds
+	PredictedArrival     *time.Time `json:"predicted_arrival,omitempty"`
+	PredictedDeparture   *time.Time `json:"predicted_departure,omitempty"`
+	ScheduleRelationship int        `json:"schedule_relationship"`
+}
+
+// StopTimeUpdate is a GTFS-RT trip update for one stop of a trip as the
+// feed reports it, before it is resolved to a StopTimePrediction.
+type StopTimeUpdate struct {
+	Time                 time.Time
+	TripID               string // feed trip ID
+	StopID               string // feed stop ID, empty when only the sequence is given
+	StopSequence         *int   // nil when the feed gives only the stop
+	ArrivalDelay         *int   // seconds
+	DepartureDelay       *int   // seconds
+	PredictedArrival     *time.Time
+	PredictedDeparture   *time.Time
+	ScheduleRelationship int
+}
+
+// TripStop is a scheduled stop of a trip, with internal and feed IDs.
+type TripStop struct {
+	TripID       string // UUID
+	StopID       string // UUID
+	GTFSStopID   string
+	StopSequence int
+}
+
+// ServiceAlert is a rider-facing service disruption notice, e.g. from a
+// GTFS-RT alerts feed. Texts are keyed by language code.
+type ServiceAlert struct {
+	ID            string            `json:"id"`
+	AgencyID      string            `json:"agency_id"`
+	Source        string            `json:"source"` // "gtfs-rt" | "anomaly"
+	SourceID      string            `json:"source_id"`
+	Cause         string            `json:"cause,omitempty"`
+	Effect        string            `json:"effect,omitempty"`
+	Header        map[string]string `json:"header"`
+	Description   map[string]string `json:"description,omitempty"`
+	URL           map[string]string `json:"url,omitempty"`
+	ActivePeriods []AlertPeriod     `json:"active_periods"`
+	RouteIDs      []string          `json:"route_ids"`
+	StopIDs       []string          `json:"stop_ids"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// Event kinds.
+const (
+	EventKindSport    = "sport"
+	EventKindFestival = "festival"
+	EventKindConcert  = "concert"
+	EventKindOther    = "other"
+)
+
+// Event impact effects: service boosts and disruptions.
+const (
+	EventEffectExtraService   = "extra_service"
+	EventEffectReducedService = "reduced_service"
+	EventEffectDetour         = "detour"
+	EventEffectStopClosed     = "stop_closed"
+)
+
+// Event is a planned happening, such as an Athletic match at San Mamés or
+// Aste Nagusia, that changes service around a venue. Its overlay applies to
+// stops within RadiusMeters of Location from ServiceFrom until ServiceUntil.
+type Event struct {
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	Kind         string            `json:"kind"`
+	Venue        string            `json:"venue,omitempty"`
+	Location     GeoPoint          `json:"location"`
+	RadiusMeters int               `json:"radius_meters"`
+	StartsAt     time.Time         `json:"starts_at"`
+	EndsAt       time.Time         `json:"ends_at"`
+	ServiceFrom  time.Time         `json:"service_from"`
+	ServiceUntil time.Time         `json:"service_until"`
+	Description  map[string]string `json:"description,omitempty"` // by language code
+	Impacts      []EventImpact     `json:"impacts"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+// EventImpact is a service change scheduled for an event, on a route and
+// optionally at one of its stops.
+type EventImpact struct {
+	Effect  string            `json:"effect"`
+	RouteID string            `json:"route_id,omitempty"`
+	StopID  string            `json:"stop_id,omitempty"`
+	Note    map[string]string `json:"note,omitempty"` // by language code
+}
+
+// AlertPeriod is a window in which an alert applies. A nil bound is open.
+type AlertPeriod struct {
+	Start *time.Time `json:"start,omitempty"`
+	End   *time.Time `json:"end,omitempty"`
 }
 
 // DelayEvent records a detected delay at a stop.
@@ -97,6 +491,20 @@ type DelayEvent struct {
 	Metadata           map[string]any `json:"metadata,omitempty"`
 }
 
+// DelayObservation is a delayed stop time update seen in an agency's GTFS-RT
+// feed, before it is matched to the static schedule.
+type DelayObservation struct {
+	Time          time.Time
+	AgencyID      string // UUID
+	TripID        string // feed trip ID
+	RouteID       string // feed route ID, may be empty
+	StopID        string // feed stop ID, empty when only the sequence is given
+	StopSequence  *int   // nil when the feed gives only the stop
+	StartDate     string // YYYYMMDD service date, empty for the current one
+	DelaySeconds  int
+	ActualArrival *time.Time // predicted time, nil to add the delay to the schedule
+}
+
 // Affiliate is a partner shop that offers compensations.
 type Affiliate struct {
 	ID         string    `json:"id"`
@@ -107,7 +515,9 @@ type Affiliate struct {
 	OfferText  string    `json:"offer_text"`
 	OfferValue float64   `json:"offer_value"`
 	Active     bool      `json:"active"`
+	Distance   *float64  `json:"distance,omitempty"` // computed field
 	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
 }
 
 // Compensation is a coupon issued to a user after a delay.
@@ -123,6 +533,25 @@ type Compensation struct {
 	Metadata     map[string]any `json:"metadata,omitempty"`
 }
 
+// CouponCheck is the state of a coupon as seen by an affiliate terminal.
+type CouponCheck struct {
+	Code        string     `json:"code"`
+	Status      string     `json:"status"` // "valid" | "redeemed" | "expired"
+	AffiliateID string     `json:"affiliate_id"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RedeemedAt  *time.Time `json:"redeemed_at,omitempty"`
+}
+
+// CompensationRequest asks for one rider to be compensated for a delay event.
+type CompensationRequest struct {
+	DelayEventID string  `json:"delay_event_id"`
+	UserID       string  `json:"user_id"`
+	StopID       string  `json:"stop_id"`
+	StopLat      float64 `json:"stop_lat"`
+	StopLon      float64 `json:"stop_lon"`
+	DelayMinutes int     `json:"delay_minutes"`
+}
+
 // Departure is a computed next-departure at a stop.
 type Departure struct {
 	Trip          *Trip      `json:"trip"`
@@ -130,6 +559,37 @@ type Departure struct {
 	EstimatedTime *time.Time `json:"estimated_time,omitempty"`
 	Delay         *int       `json:"delay,omitempty"` // seconds
 	Platform      string     `json:"platform,omitempty"`
+	// Forecast is set for departures without real-time data when requested.
+	Forecast *DelayForecast `json:"forecast,omitempty"`
+	// Occupancy is set when the trip's vehicle has recently reported it.
+	Occupancy *VehicleOccupancy `json:"occupancy,omitempty"`
+}
+
+// NearbyDeparture is a departure from a stop near a point. Stop carries its
+// distance from the point.
+type NearbyDeparture struct {
+	Departure
+	Stop *Stop `json:"stop"`
+}
+
+// DepartureFilter narrows the departures listed at a stop. With Bikes, only
+// trips that allow bikes are listed; RouteID (a route UUID) and DirectionID
+// keep one line or direction. Departures are listed from After (now when
+// nil) and, with Until, before it.
+type DepartureFilter struct {
+	Bikes       bool
+	RouteID     string
+	DirectionID *int
+	After       *time.Time
+	Until       *time.Time
+}
+
+// DelayForecast is the delay band a departure usually runs with, from history.
+type DelayForecast struct {
+	Median  int `json:"median"`  // seconds
+	Low     int `json:"low"`     // 25th percentile, seconds
+	High    int `json:"high"`    // 75th percentile, seconds
+	Samples int `json:"samples"` // past observations the band is built from
 }
 
 // Journey represents a possible route between two stops.
@@ -139,13 +599,1105 @@ type Journey struct {
 	DepartureTime time.Time     `json:"departure_time"`
 	ArrivalTime   time.Time     `json:"arrival_time"`
 	Transfers     int           `json:"transfers"`
+	Zones         []FareZone    `json:"zones,omitempty"` // fare zones its rides board and alight in, in order
 }
 
-// JourneyLeg is a single segment inside a journey.
+// FareZone is a fare zone of an agency, the zone_id of its stops; zones of
+// different agencies may share an ID.
+type FareZone struct {
+	AgencyID string `json:"agency_id"`
+	ZoneID   string `json:"zone_id"`
+}
+
+// JourneyLeg is a single segment inside a journey. Walking legs between
+// nearby stops have Walk set and no Route or Departure.Trip.
 type JourneyLeg struct {
-	Route       *Route    `json:"route"`
-	FromStop    *Stop     `json:"from_stop"`
-	ToStop      *Stop     `json:"to_stop"`
-	Departure   Departure `json:"departure"`
+	Walk            bool             `json:"walk,omitempty"`
+	Route           *Route           `json:"route"`
+	FromStop        *Stop            `json:"from_stop"`
+	ToStop          *Stop            `json:"to_stop"`
+	Departure       Departure        `json:"departure"`
+	ArrivalTime     time.Time        `json:"arrival_time"`
+	AccessibleSpace *AccessibleSpace `json:"accessible_space,omitempty"` // set in accessible mode
+	// HeadwaySecs is set for trips running about this often rather than to a
+	// timetable; their departure is then an estimate.
+	HeadwaySecs int `json:"headway_secs,omitempty"`
+	// BikesRestricted is set when the trip carries bikes but other trips of
+	// its route do not, so bike carriage depends on the time of day.
+	BikesRestricted bool `json:"bikes_restricted,omitempty"`
+	// Along names the street and neighborhood of a walk, when known.
+	Along *Place `json:"along,omitempty"`
+}
+
+// StopAccess is a stop a journey may start or end at, and the walk between
+// it and the requested point.
+type StopAccess struct {
+	StopID string
+	Walk   time.Duration
+}
+
+// ReachableStop is a stop an isochrone reaches: the earliest arrival there,
+// the minutes it takes from the departure and the changes on the way.
+type ReachableStop struct {
+	Stop        *Stop     `json:"stop"`
 	ArrivalTime time.Time `json:"arrival_time"`
+	Minutes     int       `json:"minutes"`
+	Transfers   int       `json:"transfers"`
+}
+
+// Isochrone is where transit and walking reach from a place within a number
+// of minutes. Hull is the concave hull of the place and the stops reached,
+// as a closed ring; it is nil when fewer than three places are reached.
+type Isochrone struct {
+	Origin   GeoPoint        `json:"origin"`
+	DepartAt time.Time       `json:"depart_at"`
+	Minutes  int             `json:"minutes"`
+	Stops    []ReachableStop `json:"stops"`
+	Hull     []GeoPoint      `json:"hull,omitempty"`
+}
+
+// JourneyPlan is the result of journey planning. Events lists event overlays
+// covering the origin or destination at departure. Street is set for plans
+// on foot or by bike, and when walking beats every transit journey; Fallback
+// is set only when no transit journey was found.
+type JourneyPlan struct {
+	Journeys []Journey
+	Events   []Event
+	Street   *StreetRoute
+	Fallback *JourneyFallback
+}
+
+// JourneyFallback suggests other ways to travel when no transit journey is
+// found, typically late at night.
+type JourneyFallback struct {
+	NightLines []NightLine   `json:"night_lines"`
+	Taxi       *TaxiEstimate `json:"taxi,omitempty"`
+}
+
+// NightLine is a later service from a stop within walking distance of the
+// origin to a stop within walking distance of the destination.
+type NightLine struct {
+	Route         *Route    `json:"route"`
+	FromStop      *Stop     `json:"from_stop"`
+	ToStop        *Stop     `json:"to_stop"`
+	DepartureTime time.Time `json:"departure_time"`
+	ArrivalTime   time.Time `json:"arrival_time"`
+}
+
+// Timetable is the scheduled service of every agency, loaded whole for
+// in-memory journey planning.
+type Timetable struct {
+	Version   time.Time // when the newest static feed was activated
+	Timezone  string    // IANA zone of the service days, UTC when empty
+	Stops     []Stop
+	Routes    []Route
+	Trips     []TimetableTrip
+	Transfers []Transfer
+}
+
+// TimetableTrip is a trip with its stop times in stop sequence. A trip with
+// frequencies runs once per headway in each of them, its stop times only
+// giving the times between stops.
+type TimetableTrip struct {
+	Trip        Trip
+	StopTimes   []StopTime
+	Frequencies []Frequency
+}
+
+// Frequency is a window in which a trip runs every Headway, from StartTime
+// until before EndTime (GTFS frequencies.txt). Without ExactTimes, it runs
+// about that often rather than to a timetable.
+type Frequency struct {
+	StartTime  time.Duration
+	EndTime    time.Duration
+	Headway    time.Duration
+	ExactTimes bool
+}
+
+// Transfer is a walk between two nearby stops, possibly of different agencies.
+type Transfer struct {
+	FromStopID string
+	ToStopID   string
+	Distance   float64 // meters, straight line
+}
+
+// TaxiEstimate is an approximate taxi or VTC ride.
+type TaxiEstimate struct {
+	DistanceKm      float64 `json:"distance_km"` // estimated road distance
+	DurationMinutes int     `json:"duration_minutes"`
+	Fare            float64 `json:"fare"`   // EUR, rounded to 50 cents
+	Tariff          string  `json:"tariff"` // day | night
+}
+
+// TaxiTariffs are the fares taxi estimates use. The night tariff applies from
+// NightStartHour until NightEndHour, local time. A zero DayPerKm disables
+// estimates.
+type TaxiTariffs struct {
+	DayBaseFare     float64
+	DayPerKm        float64
+	NightBaseFare   float64
+	NightPerKm      float64
+	MinimumFare     float64
+	NightStartHour  int
+	NightEndHour    int
+	AverageSpeedKmh float64
+}
+
+// StopShortLink maps a short printable code to a stop, used for QR signage.
+type StopShortLink struct {
+	Code      string    `json:"code"`
+	StopID    string    `json:"stop_id"`
+	StopName  string    `json:"stop_name,omitempty"`
+	URL       string    `json:"url,omitempty"` // computed field
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RiderJourney is a journey a user actually took, logged with their consent.
+type RiderJourney struct {
+	ID             string     `json:"id"`
+	UserID         string     `json:"user_id"`
+	TripID         string     `json:"trip_id,omitempty"`
+	RouteID        string     `json:"route_id,omitempty"`
+	RouteType      int        `json:"route_type"`
+	FromStopID     string     `json:"from_stop_id,omitempty"`
+	ToStopID       string     `json:"to_stop_id,omitempty"`
+	DepartedAt     time.Time  `json:"departed_at"`
+	ArrivedAt      *time.Time `json:"arrived_at,omitempty"`
+	DistanceMeters float64    `json:"distance_meters"`
+	CO2SavedGrams  float64    `json:"co2_saved_grams"`
+	Source         string     `json:"source"` // "checkin" | "planned"
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// CheckIn is a rider's declaration that they boarded a trip at a stop.
+type CheckIn struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	TripID    string    `json:"trip_id"`
+	StopID    string    `json:"stop_id"`
+	Time      time.Time `json:"time"` // boarding time
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HistorySummary aggregates a user's journeys for one calendar month.
+type HistorySummary struct {
+	Month            string      `json:"month"` // YYYY-MM
+	Trips            int         `json:"trips"`
+	DistanceMeters   float64     `json:"distance_meters"`
+	CO2SavedGrams    float64     `json:"co2_saved_grams"`
+	TripsByRouteType map[int]int `json:"trips_by_route_type"`
+}
+
+// Challenge metrics, computed from rider journey history.
+const (
+	ChallengeMetricTrips    = "trips"
+	ChallengeMetricDistance = "distance_meters"
+	ChallengeMetricCO2      = "co2_saved_grams"
+)
+
+// Challenge is a time-boxed sustainability goal riders can enroll in.
+type Challenge struct {
+	ID          string    `json:"id"`
+	Slug        string    `json:"slug"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	Metric      string    `json:"metric"` // trips | distance_meters | co2_saved_grams
+	Target      float64   `json:"target"`
+	RouteType   *int      `json:"route_type,omitempty"`
+	StartsAt    time.Time `json:"starts_at"`
+	EndsAt      time.Time `json:"ends_at"`
+	RewardText  string    `json:"reward_text,omitempty"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ChallengeEnrollment records a user taking part in a challenge.
+type ChallengeEnrollment struct {
+	ChallengeID string     `json:"challenge_id"`
+	UserID      string     `json:"user_id"`
+	EnrolledAt  time.Time  `json:"enrolled_at"`
+	RewardedAt  *time.Time `json:"rewarded_at,omitempty"`
+	RewardCode  string     `json:"reward_code,omitempty"`
+}
+
+// ChallengeProgress is a challenge as seen by one user.
+type ChallengeProgress struct {
+	Challenge  Challenge  `json:"challenge"`
+	Enrolled   bool       `json:"enrolled"`
+	Progress   float64    `json:"progress"`
+	Completed  bool       `json:"completed"`
+	RewardedAt *time.Time `json:"rewarded_at,omitempty"`
+	RewardCode string     `json:"reward_code,omitempty"`
+}
+
+// SLAContract is an agency's punctuality commitment, e.g. 95% of departures
+// no more than 5 minutes late.
+type SLAContract struct {
+	ID               string    `json:"id"`
+	AgencyID         string    `json:"agency_id"`
+	Name             string    `json:"name"`
+	ThresholdSeconds int       `json:"threshold_seconds"`
+	TargetPercent    float64   `json:"target_percent"`
+	Active           bool      `json:"active"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// SLAEvaluation is a contract's measured punctuality over one calendar month.
+type SLAEvaluation struct {
+	ContractID  string    `json:"contract_id"`
+	Month       string    `json:"month"` // YYYY-MM
+	Observed    int       `json:"observed"`
+	OnTime      int       `json:"on_time"`
+	Percent     float64   `json:"percent"`
+	Breached    bool      `json:"breached"`
+	Final       bool      `json:"final"` // false while the month is still running
+	EvaluatedAt time.Time `json:"evaluated_at"`
+}
+
+// SLAResult pairs a contract with its evaluation for a month.
+type SLAResult struct {
+	Contract   SLAContract   `json:"contract"`
+	Evaluation SLAEvaluation `json:"evaluation"`
+}
+
+// SLAReport is an agency's SLA standing for one month.
+type SLAReport struct {
+	Agency  string      `json:"agency"`
+	Month   string      `json:"month"`
+	Results []SLAResult `json:"results"`
+}
+
+// RouteDelayStat is a route's mean delay over one hour.
+type RouteDelayStat struct {
+	RouteID   string    `json:"route_id"`
+	AgencyID  string    `json:"agency_id"`
+	RouteName string    `json:"route_name"`
+	Hour      time.Time `json:"hour"`
+	MeanDelay float64   `json:"mean_delay"` // seconds
+	Samples   int       `json:"samples"`
+}
+
+// DelayBaseline is the usual hourly mean delay of a route at one hour of day.
+type DelayBaseline struct {
+	RouteID string  `json:"route_id"`
+	Mean    float64 `json:"mean"`   // seconds
+	StdDev  float64 `json:"stddev"` // seconds
+	Hours   int     `json:"hours"`  // past hours the baseline is built from
+}
+
+// DelayProfile summarises past delays of a route at a stop for one hour of
+// one weekday (agency local time).
+type DelayProfile struct {
+	RouteID string       `json:"route_id"`
+	Weekday time.Weekday `json:"weekday"`
+	Hour    int          `json:"hour"`
+	Median  float64      `json:"median"` // seconds
+	Low     float64      `json:"low"`    // 25th percentile, seconds
+	High    float64      `json:"high"`   // 75th percentile, seconds
+	Samples int          `json:"samples"`
+}
+
+// VehicleOccupancy is how full a vehicle was at its latest report.
+type VehicleOccupancy struct {
+	VehicleID string    `json:"vehicle_id"`
+	TripID    string    `json:"trip_id,omitempty"`
+	Status    int       `json:"status"` // GTFS-RT OccupancyStatus
+	Level     string    `json:"level"`  // Status by name, e.g. "few_seats_available"
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RouteOccupancy is the current occupancy of a route's live vehicles.
+type RouteOccupancy struct {
+	RouteID  string             `json:"route_id"`
+	Vehicles []VehicleOccupancy `json:"vehicles"`
+}
+
+// CrowdingSlot is the average occupancy at a stop for one hour of one
+// weekday (agency local time).
+type CrowdingSlot struct {
+	Weekday   time.Weekday `json:"weekday"`
+	Hour      int          `json:"hour"`
+	AvgStatus float64      `json:"avg_status"` // mean GTFS-RT OccupancyStatus
+	Level     string       `json:"level"`      // AvgStatus rounded, by name
+	Samples   int          `json:"samples"`
+}
+
+// StopCrowding is how crowded vehicles calling at a stop usually are.
+type StopCrowding struct {
+	StopID  string         `json:"stop_id"`
+	RouteID string         `json:"route_id,omitempty"`
+	Since   time.Time      `json:"since"`
+	Slots   []CrowdingSlot `json:"slots"`
+}
+
+// PunctualityStats summarises how punctual observed trip stops were. A trip
+// stop is on time from 1 minute early to 5 minutes late.
+type PunctualityStats struct {
+	Observed      int     `json:"observed"`
+	OnTime        int     `json:"on_time"`
+	Early         int     `json:"early"`
+	Late          int     `json:"late"`
+	OnTimePercent float64 `json:"on_time_percent"`
+	AvgDelay      float64 `json:"avg_delay"` // seconds
+	MaxDelay      int     `json:"max_delay"` // seconds
+}
+
+// PunctualityFilter selects the observations punctuality is computed from:
+// those in [From, To) of a route, of an agency's routes, or of all routes
+// when both are empty.
+type PunctualityFilter struct {
+	AgencyID string
+	RouteID  string
+	From     time.Time
+	To       time.Time
+}
+
+// HourPunctuality is punctuality at one hour of the day (agency local time).
+type HourPunctuality struct {
+	Hour int `json:"hour"`
+	PunctualityStats
+}
+
+// DayPunctuality is punctuality on one day (agency local time).
+type DayPunctuality struct {
+	Date string `json:"date"` // YYYY-MM-DD
+	PunctualityStats
+}
+
+// StopPunctuality is punctuality at one stop.
+type StopPunctuality struct {
+	StopID   string `json:"stop_id"`
+	StopName string `json:"stop_name"`
+	PunctualityStats
+}
+
+// RoutePunctualityStats is punctuality of one route.
+type RoutePunctualityStats struct {
+	RouteID   string `json:"route_id"`
+	RouteName string `json:"route_name"`
+	AgencyID  string `json:"agency_id"`
+	PunctualityStats
+}
+
+// RoutePunctuality is a route's on-time performance over a period, with
+// the stops where it is least punctual.
+type RoutePunctuality struct {
+	RouteID    string            `json:"route_id"`
+	RouteName  string            `json:"route_name"`
+	From       time.Time         `json:"from"`
+	To         time.Time         `json:"to"`
+	Summary    PunctualityStats  `json:"summary"`
+	ByHour     []HourPunctuality `json:"by_hour"`
+	ByDay      []DayPunctuality  `json:"by_day"`
+	WorstStops []StopPunctuality `json:"worst_stops"`
+}
+
+// NetworkPunctuality is the on-time performance of an agency, or of every
+// agency, over a period, with its least punctual routes.
+type NetworkPunctuality struct {
+	Agency      string                  `json:"agency,omitempty"`
+	From        time.Time               `json:"from"`
+	To          time.Time               `json:"to"`
+	Summary     PunctualityStats        `json:"summary"`
+	ByHour      []HourPunctuality       `json:"by_hour"`
+	ByDay       []DayPunctuality        `json:"by_day"`
+	WorstRoutes []RoutePunctualityStats `json:"worst_routes"`
+}
+
+// DelayAnomaly is an hour in which a route ran unusually late.
+type DelayAnomaly struct {
+	RouteID      string    `json:"route_id"`
+	AgencyID     string    `json:"agency_id"`
+	RouteName    string    `json:"route_name"`
+	Hour         time.Time `json:"hour"`
+	MeanDelay    float64   `json:"mean_delay"`
+	BaselineMean float64   `json:"baseline_mean"`
+	BaselineStd  float64   `json:"baseline_stddev"`
+	Sigma        float64   `json:"sigma"` // standard deviations above the baseline
+	AlertID      string    `json:"alert_id,omitempty"`
+}
+
+// FeedQualityReport reconciles one day of an agency's GTFS-RT identifiers
+// against its static schedule.
+type FeedQualityReport struct {
+	AgencyID  string       `json:"agency_id"`
+	Day       string       `json:"day"` // YYYY-MM-DD
+	TripIDs   IDMatchStats `json:"trip_ids"`
+	StopIDs   IDMatchStats `json:"stop_ids"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// IDMatchStats counts how many distinct RT identifiers of one kind resolved.
+type IDMatchStats struct {
+	Seen      int           `json:"seen"`
+	Matched   int           `json:"matched"`
+	MatchRate float64       `json:"match_rate"` // percent; 100 when nothing was seen
+	Unmatched []UnmatchedID `json:"unmatched"`  // most frequent samples
+}
+
+// UnmatchedID is an RT identifier missing from the static schedule, with the
+// static identifier it most likely means when one could be found.
+type UnmatchedID struct {
+	ID          string `json:"id"`
+	Occurrences int    `json:"occurrences"`
+	Suggestion  string `json:"suggestion,omitempty"`
+	Rule        string `json:"rule,omitempty"` // how Suggestion was derived
+}
+
+// FeedConfig holds an agency's feed settings. With RequireApproval, new
+// static feeds are staged until an admin approves them.
+type FeedConfig struct {
+	AgencyID        string         `json:"agency_id"`
+	IDRules         []IDRule       `json:"id_rules"`
+	RequireApproval bool           `json:"require_approval"`
+	RouteNames      RouteNameRules `json:"route_names"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+}
+
+// RouteNameRules clean up the route long names of an agency's feed as it
+// is ingested; the name as given is kept in the route's metadata.
+type RouteNameRules struct {
+	FixMojibake bool `json:"fix_mojibake"` // mend Windows-1252 and doubly encoded UTF-8
+	StripCodes  bool `json:"strip_codes"`  // drop the short name and bracketed codes repeated in it
+	TitleCase   bool `json:"title_case"`   // title-case names written all in one case
+}
+
+// IDRule rewrites an RT identifier of one kind ("trip", "stop" or "route")
+// before it is resolved against the static schedule. Op is one of
+// strip_prefix, strip_suffix, add_prefix (Value), pad (left-pad with zeros
+// to Width), trim_zeros, or regex (Value pattern, Replacement).
+type IDRule struct {
+	Kind        string `json:"kind"`
+	Op          string `json:"op"`
+	Value       string `json:"value,omitempty"`
+	Width       int    `json:"width,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// SeenID is an identifier referenced by an RT feed on a given day.
+type SeenID struct {
+	ID          string
+	Occurrences int
+	Matched     bool
+}
+
+// User is a rider account. Role is "rider", "affiliate" (staff of the
+// affiliate AffiliateID) or "admin".
+type User struct {
+	ID           string    `json:"id"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"-"`
+	DisplayName  string    `json:"display_name,omitempty"`
+	Role         string    `json:"role"`
+	AffiliateID  string    `json:"affiliate_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Device push platforms.
+const (
+	DevicePlatformFCM     = "fcm"
+	DevicePlatformWebPush = "webpush"
+)
+
+// Device is a rider's app install or browser that receives push notifications.
+// FCM also delivers to iOS apps through APNs.
+type Device struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"-"`
+	Platform  string    `json:"platform"`         // fcm | webpush
+	Token     string    `json:"token"`            // FCM registration token or Web Push endpoint
+	P256dh    string    `json:"p256dh,omitempty"` // Web Push subscription keys (base64url)
+	Auth      string    `json:"auth,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Notification categories, which riders opt in and out of.
+const (
+	NotificationDelays    = "delays"    // delays matching an alert subscription
+	NotificationAlerts    = "alerts"    // service changes to favorite stops and routes
+	NotificationCoupons   = "coupons"   // delay compensations and challenge rewards
+	NotificationMarketing = "marketing" // promotions; opt-in
+)
+
+// DefaultQuietHoursTimezone is the time zone of quiet hours set without one.
+const DefaultQuietHoursTimezone = "Europe/Madrid"
+
+// NotificationPreferences are what a rider is pushed, and on which devices.
+// Riders who never set them get DefaultNotificationPreferences.
+type NotificationPreferences struct {
+	Channels   NotificationChannels   `json:"channels"`
+	Categories NotificationCategories `json:"categories"`
+	QuietHours *QuietHours            `json:"quiet_hours"`          // nil when off
+	UpdatedAt  *time.Time             `json:"updated_at,omitempty"` // nil until first set
+}
+
+// NotificationChannels are the device platforms a rider is pushed on.
+type NotificationChannels struct {
+	FCM     bool `json:"fcm"`     // Android and iOS apps
+	WebPush bool `json:"webpush"` // browsers
+}
+
+// NotificationCategories are the categories a rider receives.
+type NotificationCategories struct {
+	Delays    bool `json:"delays"`
+	Alerts    bool `json:"alerts"`
+	Coupons   bool `json:"coupons"`
+	Marketing bool `json:"marketing"`
+}
+
+// QuietHours is a daily period in which nothing is pushed.
+type QuietHours struct {
+	From     string `json:"from"`     // HH:MM
+	Until    string `json:"until"`    // HH:MM, exclusive; before From wraps past midnight
+	Timezone string `json:"timezone"` // IANA name, DefaultQuietHoursTimezone when empty
+}
+
+// DefaultNotificationPreferences returns the preferences of riders who set
+// none: every channel and every category but marketing, without quiet
+// hours.
+func DefaultNotificationPreferences() *NotificationPreferences {
+	return &NotificationPreferences{
+		Channels:   NotificationChannels{FCM: true, WebPush: true},
+		Categories: NotificationCategories{Delays: true, Alerts: true, Coupons: true},
+	}
+}
+
+// Channel reports whether the rider is pushed on devices of platform.
+func (p *NotificationPreferences) Channel(platform string) bool {
+	switch platform {
+	case DevicePlatformFCM:
+		return p.Channels.FCM
+	case DevicePlatformWebPush:
+		return p.Channels.WebPush
+	}
+	return false
+}
+
+// Category reports whether the rider receives notifications of category.
+func (p *NotificationPreferences) Category(category string) bool {
+	switch category {
+	case NotificationDelays:
+		return p.Categories.Delays
+	case NotificationAlerts:
+		return p.Categories.Alerts
+	case NotificationCoupons:
+		return p.Categories.Coupons
+	case NotificationMarketing:
+		return p.Categories.Marketing
+	}
+	return false
+}
+
+// Quiet reports whether at falls in the rider's quiet hours. Quiet hours
+// that do not parse are ignored.
+func (p *NotificationPreferences) Quiet(at time.Time) bool {
+	q := p.QuietHours
+	if q == nil {
+		return false
+	}
+	from, err1 := time.Parse("15:04", q.From)
+	until, err2 := time.Parse("15:04", q.Until)
+	tz := q.Timezone
+	if tz == "" {
+		tz = DefaultQuietHoursTimezone
+	}
+	loc, err3 := time.LoadLocation(tz)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return false
+	}
+	local := at.In(loc)
+	now := time.Date(0, 1, 1, local.Hour(), local.Minute(), 0, 0, time.UTC)
+	if from.Before(until) {
+		return !now.Before(from) && now.Before(until)
+	}
+	return !now.Before(from) || now.Before(until)
+}
+
+// Favorite kinds.
+const (
+	FavoriteStop    = "stop"
+	FavoriteRoute   = "route"
+	FavoriteJourney = "journey"
+)
+
+// Favorite is a stop, route or journey (origin and destination stop) a rider
+// saved. The names are filled in when favorites are read.
+type Favorite struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"-"`
+	Kind       string    `json:"kind"`                 // stop | route | journey
+	StopID     string    `json:"stop_id,omitempty"`    // stop, or journey origin
+	ToStopID   string    `json:"to_stop_id,omitempty"` // journey destination
+	RouteID    string    `json:"route_id,omitempty"`
+	Label      string    `json:"label,omitempty"` // rider's own name, e.g. "Work"
+	StopName   string    `json:"stop_name,omitempty"`
+	ToStopName string    `json:"to_stop_name,omitempty"`
+	RouteName  string    `json:"route_name,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Dashboard is a rider's favorites with the next departures at each favorite stop.
+type Dashboard struct {
+	Stops    []FavoriteDepartures `json:"stops"`
+	Routes   []Favorite           `json:"routes"`
+	Journeys []Favorite           `json:"journeys"`
+}
+
+// FavoriteDepartures is a favorite stop and its next departures.
+type FavoriteDepartures struct {
+	Favorite   Favorite    `json:"favorite"`
+	Departures []Departure `json:"departures"`
+}
+
+// AuthToken is a bearer token issued to a user on registration or login.
+type AuthToken struct {
+	Token     string    `json:"token"`
+	TokenType string    `json:"token_type"`
+	ExpiresAt time.Time `json:"expires_at"`
+	User      *User     `json:"user"`
+}
+
+// AlertSubscription asks for a push notification when a route, a stop, or a
+// route at a stop is delayed on the given weekdays within a local time window.
+// The names are filled in when subscriptions are read.
+type AlertSubscription struct {
+	ID              string    `json:"id"`
+	UserID          string    `json:"-"`
+	RouteID         string    `json:"route_id,omitempty"`
+	StopID          string    `json:"stop_id,omitempty"`
+	Days            []int     `json:"days"`  // ISO weekdays, 1 = Monday
+	From            string    `json:"from"`  // HH:MM local time
+	Until           string    `json:"until"` // HH:MM, exclusive; before From wraps past midnight, equal to From means all day
+	MinDelaySeconds int       `json:"min_delay_seconds"`
+	RouteName       string    `json:"route_name,omitempty"`
+	StopName        string    `json:"stop_name,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// DelayAlert is a delay event matched to a subscription, with the names its
+// push notification needs.
+type DelayAlert struct {
+	SubscriptionID string
+	UserID         string
+	RouteName      string
+	Headsign       string
+	StopName       string
+	DelaySeconds   int
+}
+
+// Accessible space kinds and states.
+const (
+	SpaceWheelchair = "wheelchair"
+	SpaceStroller   = "stroller"
+
+	SpaceAvailable = "available"
+	SpaceFull      = "full"
+	SpaceUnknown   = "unknown"
+)
+
+// Accessible space report sources.
+const (
+	SpaceSourceVehicle = "vehicle"
+	SpaceSourceRider   = "rider"
+)
+
+// AccessibleSpaceReport is one observation of the wheelchair and stroller
+// spaces on board a trip, from the vehicle or from a rider. An empty state
+// was not reported.
+type AccessibleSpaceReport struct {
+	Time       time.Time `json:"time"`
+	TripID     string    `json:"trip_id"`
+	VehicleID  string    `json:"vehicle_id,omitempty"`
+	Source     string    `json:"source"` // vehicle | rider
+	UserID     string    `json:"-"`
+	Wheelchair string    `json:"wheelchair,omitempty"` // available | full
+	Stroller   string    `json:"stroller,omitempty"`
+}
+
+// AccessibleSpace is the current availability of a trip's accessible spaces,
+// summarized from recent reports.
+type AccessibleSpace struct {
+	TripID     string     `json:"trip_id"`
+	Wheelchair string     `json:"wheelchair"`       // available | full | unknown
+	Stroller   string     `json:"stroller"`         // available | full | unknown
+	Source     string     `json:"source,omitempty"` // vehicle | rider; empty without reports
+	Reports    int        `json:"reports"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+}
+
+// Service change kinds, found by comparing a GTFS feed with the previous one.
+const (
+	ServiceChangeStopRemoved     = "stop_removed"
+	ServiceChangeStopRenamed     = "stop_renamed"
+	ServiceChangeRouteRemoved    = "route_removed"
+	ServiceChangeRouteRenamed    = "route_renamed"
+	ServiceChangeScheduleChanged = "schedule_changed"
+)
+
+// FeedSnapshot is what a GTFS feed says about its stops and routes, keyed by
+// GTFS ID. Routes carry their display name and scheduled trip count.
+type FeedSnapshot struct {
+	Stops  map[string]string
+	Routes map[string]FeedRoute
+}
+
+// FeedRoute is a route as a GTFS feed describes it.
+type FeedRoute struct {
+	Name  string
+	Trips int
+}
+
+// FeedSubject is a favorited stop or route as the previous ingest saw it,
+// with the riders who favorited it. Known is false until an ingest has
+// recorded it; Name then falls back to the stored stop or route name.
+type FeedSubject struct {
+	Kind    string // stop | route
+	ID      string
+	GTFSID  string
+	Name    string
+	Trips   int
+	Present bool
+	Known   bool
+	UserIDs []string
+}
+
+// ServiceChange is a change to a favorited stop or route between two feeds.
+type ServiceChange struct {
+	Kind      string
+	SubjectID string
+	OldName   string
+	NewName   string
+	OldTrips  int
+	NewTrips  int
+	UserIDs   []string
+}
+
+// FeedVersion is one GTFS feed an agency published, current from ActivatedAt
+// until the next version replaced it.
+type FeedVersion struct {
+	ID           string     `json:"id"`
+	AgencyID     string     `json:"agency_id"`
+	SHA256       string     `json:"sha256"`
+	ActivatedAt  time.Time  `json:"activated_at"`
+	SupersededAt *time.Time `json:"superseded_at,omitempty"`
+	Stops        int        `json:"stops"`
+	Routes       int        `json:"routes"`
+	Trips        int        `json:"trips"`
+}
+
+// RealtimeFeedStatus is the health of one of an agency's GTFS-RT feeds as
+// last recorded by the realtime poller.
+type RealtimeFeedStatus struct {
+	AgencyID      string     `json:"agency_id"`
+	AgencySlug    string     `json:"agency"`
+	Feed          string     `json:"feed"` // vehicle_positions, trip_updates or alerts
+	LastPollAt    time.Time  `json:"last_poll_at"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+	FeedTimestamp *time.Time `json:"feed_timestamp,omitempty"` // header timestamp of the last successful poll
+	Entities      int        `json:"entities"`                 // entities in the last successful poll
+	Failures      int        `json:"consecutive_failures"`
+	LastError     string     `json:"last_error,omitempty"`
+	Stale         bool       `json:"stale"`
+}
+
+// FeedStage is a static feed the ingestor downloaded: held for approval
+// (staged), approved and waiting to be applied, rejected, or applied and
+// kept for rollbacks. Rollback stages re-apply an earlier feed.
+type FeedStage struct {
+	ID        string     `json:"id"`
+	AgencyID  string     `json:"agency_id"`
+	SHA256    string     `json:"sha256"`
+	Stops     int        `json:"stops"`
+	Routes    int        `json:"routes"`
+	Trips     int        `json:"trips"`
+	Status    string     `json:"status"` // staged, approved, rejected or applied
+	Rollback  bool       `json:"rollback"`
+	StagedAt  time.Time  `json:"staged_at"`
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// FeedHealth is a snapshot of one agency's feeds for monitoring.
+type FeedHealth struct {
+	AgencySlug        string
+	LastStaticIngest  *time.Time // nil before the first recorded ingest
+	LastRealtime      *time.Time // newest feed timestamp of its GTFS-RT feeds
+	ActiveVehicles    int
+	UnresolvedTripIDs int // on the latest day its trip updates were polled
+	UnresolvedStopIDs int
+}
+
+// RealtimeFeedPoll is the outcome of one fetch of a GTFS-RT feed.
+type RealtimeFeedPoll struct {
+	AgencyID      string
+	Feed          string
+	At            time.Time
+	FeedTimestamp time.Time // zero when the header has none
+	Entities      int
+	Err           string // empty on success
+}
+
+// CoverageSample is one sample of an agency's realtime coverage: the trips
+// running and how many of them had realtime data, in total and for each
+// route with trips running.
+type CoverageSample struct {
+	At        time.Time
+	Scheduled int
+	Live      int
+	Routes    []RouteCoverage
+}
+
+// RouteCoverage is how many of a route's running trips had realtime data.
+type RouteCoverage struct {
+	RouteID   string   `json:"route_id"`
+	ShortName string   `json:"short_name"`
+	Scheduled int      `json:"scheduled_trips"`
+	Live      int      `json:"live_trips"`
+	Coverage  *float64 `json:"coverage"` // Live / Scheduled; nil when no trip is running
+}
+
+// RealtimeCoverage is an agency's or a route's latest realtime coverage.
+// LiveData tells riders whether to expect live departures.
+type RealtimeCoverage struct {
+	AgencySlug string          `json:"agency,omitempty"`
+	RouteID    string          `json:"route_id,omitempty"`
+	SampledAt  *time.Time      `json:"sampled_at"` // nil before the first sample
+	Stale      bool            `json:"stale"`
+	Scheduled  int             `json:"scheduled_trips"`
+	Live       int             `json:"live_trips"`
+	Coverage   *float64        `json:"coverage"` // Live / Scheduled; nil when no trip is running
+	LiveData   bool            `json:"live_data"`
+	Routes     []RouteCoverage `json:"routes,omitempty"` // lowest coverage first
+}
+
+// Fleet is an agency's vehicles reporting positions since Since, with the
+// speeds of their latest positions and how long ago those were reported.
+type Fleet struct {
+	AgencySlug string       `json:"agency"`
+	Since      time.Time    `json:"since"`
+	FleetStats              // vehicles of any route, or none
+	Routes     []RouteFleet `json:"routes"` // most vehicles first
+}
+
+// RouteFleet is the part of a fleet running a route.
+type RouteFleet struct {
+	RouteID   string `json:"route_id"`
+	ShortName string `json:"short_name"`
+	FleetStats
+}
+
+// FleetStats summarises vehicles' latest positions. Speed is nil when none
+// of them reports one; Staleness is nil without vehicles.
+type FleetStats struct {
+	Active    int          `json:"active"`
+	Speed     *SpreadStats `json:"speed"`          // m/s
+	Staleness *SpreadStats `json:"staleness_secs"` // since each vehicle's latest report
+}
+
+// SpreadStats is the least, median and greatest of some values.
+type SpreadStats struct {
+	Min    float64 `json:"min"`
+	Median float64 `json:"median"`
+	Max    float64 `json:"max"`
+}
+
+// RouteSegment is how vehicles usually fare between two consecutive stops of
+// a route. Dwell is spent at the From stop; it is nil when no vehicle was
+// seen arriving there.
+type RouteSegment struct {
+	FromStopID   string   `json:"from_stop_id"`
+	FromStopName string   `json:"from_stop_name"`
+	ToStopID     string   `json:"to_stop_id"`
+	ToStopName   string   `json:"to_stop_name"`
+	Distance     float64  `json:"distance_m"` // straight line
+	Samples      int      `json:"samples"`
+	TravelSecs   float64  `json:"avg_travel_secs"`
+	DwellSecs    *float64 `json:"avg_dwell_secs"`
+	SpeedKmh     float64  `json:"speed_kmh"`
+	Slowest      bool     `json:"slowest"` // among the route's slowest segments
+}
+
+// RouteSegments is a route's segments observed since Since, in route order.
+type RouteSegments struct {
+	RouteID  string         `json:"route_id"`
+	Since    time.Time      `json:"since"`
+	Segments []RouteSegment `json:"segments"`
+}
+
+// TimetableComparison is a stop of a trip on a service date as scheduled and
+// as observed. IDs are the feed's; Delay is the trip's last predicted delay
+// at the stop, nil when none was observed.
+type TimetableComparison struct {
+	ServiceDate        string // YYYY-MM-DD
+	RouteID            string
+	RouteShortName     string
+	TripID             string
+	DirectionID        int
+	StopSequence       int
+	StopID             string
+	StopName           string
+	ScheduledArrival   time.Time
+	ScheduledDeparture time.Time
+	Delay              *int // seconds
+}
+
+// TimetableExport is an uploaded scheduled vs observed timetable of an
+// agency's trips observed from From to To (service dates, inclusive).
+type TimetableExport struct {
+	Agency      string    `json:"agency"`
+	From        string    `json:"from"`
+	To          string    `json:"to"`
+	Trips       int       `json:"trips"` // trip runs, a trip once per service date
+	Rows        int       `json:"rows"`
+	URL         string    `json:"url"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// ArchivePartition is a day of a realtime table uploaded as Parquet.
+type ArchivePartition struct {
+	Table string `json:"table"`
+	Date  string `json:"date"`
+	Rows  int    `json:"rows"`
+	URL   string `json:"url"`
+}
+
+// OfflineBundle is the data the PWA keeps to show stops, lines and scheduled
+// departures without a connection. A delta bundle (Since set) leaves out
+// the stops and routes, which have not changed since that version.
+type OfflineBundle struct {
+	Agency      string             `json:"agency"`
+	Version     string             `json:"version"` // changes with the agency's feed and the bbox
+	Since       string             `json:"since,omitempty"`
+	Bounds      Bounds             `json:"bounds"`
+	GeneratedAt time.Time          `json:"generated_at"`
+	ValidUntil  time.Time          `json:"valid_until"` // end of the departures window
+	Stops       []OfflineStop      `json:"stops,omitempty"`
+	Routes      []OfflineRoute     `json:"routes,omitempty"`
+	Departures  []OfflineDeparture `json:"departures"`
+}
+
+// OfflineStop is a stop in an offline bundle.
+type OfflineStop struct {
+	ID                   string  `json:"id"`
+	Name                 string  `json:"name"`
+	Lat                  float64 `json:"lat"`
+	Lon                  float64 `json:"lon"`
+	PlatformCode         string  `json:"platform_code,omitempty"`
+	WheelchairAccessible bool    `json:"wheelchair_accessible,omitempty"`
+}
+
+// OfflineRoute is a route in an offline bundle.
+type OfflineRoute struct {
+	ID        string `json:"id"`
+	ShortName string `json:"short_name"`
+	LongName  string `json:"long_name"`
+	RouteType int    `json:"route_type"`
+	Color     string `json:"color"`
+	TextColor string `json:"text_color"`
+}
+
+// OfflineDeparture is a scheduled departure in an offline bundle.
+type OfflineDeparture struct {
+	StopID   string    `json:"stop_id"`
+	RouteID  string    `json:"route_id"`
+	TripID   string    `json:"trip_id"`
+	Headsign string    `json:"headsign,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// SyncPosition is a point in an entity's change log: a change time, with
+// the entity ID breaking ties.
+type SyncPosition struct {
+	At time.Time
+	ID string
+}
+
+// StopChange is a stop that changed, or was deleted when Stop is nil.
+type StopChange struct {
+	SyncPosition
+	Stop *Stop
+}
+
+// RouteChange is a route that changed, or was deleted when Route is nil.
+type RouteChange struct {
+	SyncPosition
+	Route *Route
+}
+
+// AlertChange is an alert that changed, or was removed when Alert is nil.
+type AlertChange struct {
+	SyncPosition
+	Alert *ServiceAlert
+}
+
+// SyncPage ends a page of delta sync changes. Cursor asks for the next page,
+// or for the changes after this sync when HasMore is false.
+type SyncPage struct {
+	Deleted []string `json:"deleted"` // IDs of deleted entities
+	Cursor  string   `json:"cursor"`
+	HasMore bool     `json:"has_more"`
+}
+
+// StopSync is a page of stop changes.
+type StopSync struct {
+	Stops []Stop `json:"stops"`
+	SyncPage
+}
+
+// RouteSync is a page of route changes.
+type RouteSync struct {
+	Routes []Route `json:"routes"`
+	SyncPage
+}
+
+// AlertSync is a page of service alert changes.
+type AlertSync struct {
+	Alerts []ServiceAlert `json:"alerts"`
+	SyncPage
+}
+
+// Kinds of workflow runs.
+const (
+	WorkflowCompensation = "compensation"
+	WorkflowIngest       = "ingest"
+	WorkflowJob          = "job"
+)
+
+// WorkflowRun is a run of a Temporal workflow: a rider's compensation, an
+// agency's feed ingest or a scheduled job.
+type WorkflowRun struct {
+	WorkflowID string `json:"workflow_id"`
+	RunID      string `json:"run_id"`
+	Kind       string `json:"kind"`
+	// Status is running, completed, failed, canceled, terminated,
+	// continued_as_new or timed_out.
+	Status    string     `json:"status"`
+	StartedAt time.Time  `json:"started_at"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty"`
+	// DelayEventID and UserID are set for compensations, Agency for ingests
+	// and Job for scheduled jobs.
+	DelayEventID string `json:"delay_event_id,omitempty"`
+	UserID       string `json:"user_id,omitempty"`
+	Agency       string `json:"agency,omitempty"`
+	Job          string `json:"job,omitempty"`
+	// Activity is the activity a running run is retrying, or the last one a
+	// failed run started, and Attempts how many times it was attempted.
+	Activity string `json:"activity,omitempty"`
+	Attempts int    `json:"attempts,omitempty"`
+	// Failure is why a failed run failed, or why the activity a running run
+	// is retrying last failed.
+	Failure string `json:"failure,omitempty"`
+}
+
+// WorkflowFilter selects workflow runs. Empty fields match any run; UserID
+// and DelayEventID only match compensations, Agency only ingests.
+type WorkflowFilter struct {
+	Kind         string
+	Status       string
+	DelayEventID string
+	UserID       string
+	Agency       string
+	Since        time.Time
+	Limit        int
 }
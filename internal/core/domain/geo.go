@@ -11,6 +11,25 @@ type GeoLineString struct {
 	Coordinates []GeoPoint `json:"coordinates"`
 }
 
+// Place is an address or point of interest a geocoder resolved.
+type Place struct {
+	Name         string   `json:"name"`
+	Label        string   `json:"label"` // full address, for display
+	Location     GeoPoint `json:"location"`
+	Kind         string   `json:"kind,omitempty"`         // the geocoder's place type, e.g. house or street
+	Street       string   `json:"street,omitempty"`       // the street it is on
+	Neighborhood string   `json:"neighborhood,omitempty"` // its neighborhood or district
+}
+
+// GeocodeQuery is a free-text address lookup. Results near Near, when set,
+// rank higher, and are labelled in Lang when the geocoder has it.
+type GeocodeQuery struct {
+	Text  string
+	Near  *GeoPoint
+	Lang  string
+	Limit int
+}
+
 // Bounds represents a geographic bounding box.
 type Bounds struct {
 	MinLat float64 `json:"min_lat"`
@@ -18,3 +37,8 @@ type Bounds struct {
 	MaxLat float64 `json:"max_lat"`
 	MaxLon float64 `json:"max_lon"`
 }
+
+// Contains reports whether p lies inside the box, edges included.
+func (b Bounds) Contains(p GeoPoint) bool {
+	return p.Lat >= b.MinLat && p.Lat <= b.MaxLat && p.Lon >= b.MinLon && p.Lon <= b.MaxLon
+}
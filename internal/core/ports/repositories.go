@@ -12,25 +12,114 @@ type AgencyRepository interface {
 	Upsert(ctx context.Context, agency *domain.Agency) error
 	GetBySlug(ctx context.Context, slug string) (*domain.Agency, error)
 	List(ctx context.Context) ([]domain.Agency, error)
+	// ListPage returns a page of the agencies by name, with how many there
+	// are in all.
+	ListPage(ctx context.Context, offset, limit int) ([]domain.Agency, int, error)
+	UpdateContact(ctx context.Context, agencyID string, c *domain.AgencyContact) error
+}
+
+// AgencyAliasRepository persists source feed mappings to canonical agencies.
+type AgencyAliasRepository interface {
+	ListByAgency(ctx context.Context, agencyID string) ([]domain.AgencyAlias, error)
+	// Create reports false when the source is already aliased.
+	Create(ctx context.Context, a *domain.AgencyAlias) (bool, error)
+	Delete(ctx context.Context, agencyID, sourceSlug, sourceAgencyID string) (bool, error)
+}
+
+// AgencyFeedsRepository persists the feed URLs of the agencies that are
+// ingested and polled.
+type AgencyFeedsRepository interface {
+	List(ctx context.Context) ([]domain.AgencyFeeds, error)
+	GetBySlug(ctx context.Context, slug string) (*domain.AgencyFeeds, error)
+	// Create creates the agency unless it exists, and its feeds. It reports
+	// false when the agency already has feeds.
+	Create(ctx context.Context, f *domain.AgencyFeeds) (bool, error)
+	// Update reports false when the agency has no feeds.
+	Update(ctx context.Context, f *domain.AgencyFeeds) (bool, error)
+	Delete(ctx context.Context, slug string) (bool, error)
+}
+
+// PushKeyRepository persists the keys agencies sign pushed realtime data
+// with, and the nonces of the pushes.
+type PushKeyRepository interface {
+	// ListByAgency returns the agency's keys, without their secrets.
+	ListByAgency(ctx context.Context, agencyID string) ([]domain.PushKey, error)
+	// Get returns the agency's key with its secret, or nil, nil when it
+	// does not exist.
+	Get(ctx context.Context, agencyID, id string) (*domain.PushKey, error)
+	Create(ctx context.Context, k *domain.PushKey) error
+	Delete(ctx context.Context, agencyID, id string) (bool, error)
+	// UseNonce records the agency's nonce as seen at `at`, reporting false
+	// when it already was. The agency's nonces seen before `forget` are
+	// dropped.
+	UseNonce(ctx context.Context, agencyID, nonce string, at, forget time.Time) (bool, error)
 }
 
 // StopRepository persists stops.
 type StopRepository interface {
 	Upsert(ctx context.Context, stop *domain.Stop) error
 	UpsertBatch(ctx context.Context, stops []domain.Stop) error
+	// GetByID returns nil, nil when the stop does not exist.
 	GetByID(ctx context.Context, id string) (*domain.Stop, error)
 	GetByIDs(ctx context.Context, ids []string) ([]domain.Stop, error)
-	FindNearby(ctx context.Context, lat, lon, radiusMeters float64, limit int) ([]domain.Stop, error)
+	// FindNearby and Search return only stops riders board at, not stations
+	// or their entrances.
+	FindNearby(ctx context.Context, lat, lon, radiusMeters float64, limit int, filter domain.StopFilter) ([]domain.Stop, error)
 	Search(ctx context.Context, query string, near *domain.GeoPoint, limit int) ([]domain.Stop, error)
+	// ListPlatforms returns the platforms of the given stations, by station
+	// and platform code.
+	ListPlatforms(ctx context.Context, stationIDs []string) ([]domain.Stop, error)
+	// Suggest returns the names of boarding stops loosely matching query,
+	// best first, for searches that found nothing.
+	Suggest(ctx context.Context, query string, limit int) ([]string, error)
+	// UpdateAmenities replaces a stop's amenities. It reports false if the
+	// stop does not exist.
+	UpdateAmenities(ctx context.Context, id string, a *domain.StopAmenities) (bool, error)
+}
+
+// SearchSynonymRepository persists stop search synonyms.
+type SearchSynonymRepository interface {
+	List(ctx context.Context) ([]domain.SearchSynonym, error)
+	// Create normalizes the term and synonym like stop names are for search.
+	// It reports false when the pair already exists.
+	Create(ctx context.Context, s *domain.SearchSynonym) (bool, error)
+	Delete(ctx context.Context, id string) (bool, error)
+}
+
+// SearchLogRepository records stop searches.
+type SearchLogRepository interface {
+	Record(ctx context.Context, query string, results int, at time.Time) error
+	// Stats sums the searches in [from, to) with the limit most searched
+	// queries that found nothing.
+	Stats(ctx context.Context, from, to time.Time, limit int) (*domain.SearchStats, error)
+}
+
+// GlobalSearchRepository matches a query against agencies, routes and
+// stops, each type best first.
+type GlobalSearchRepository interface {
+	// Agencies matches names, or slugs exactly.
+	Agencies(ctx context.Context, query string, limit int) ([]domain.SearchHit, error)
+	// Routes matches short and long names.
+	Routes(ctx context.Context, query string, limit int) ([]domain.SearchHit, error)
+	// Stops matches boarding stops like StopRepository.Search.
+	Stops(ctx context.Context, query string, limit int) ([]domain.SearchHit, error)
 }
 
 // RouteRepository persists routes.
 type RouteRepository interface {
 	Upsert(ctx context.Context, route *domain.Route) error
 	UpsertBatch(ctx context.Context, routes []domain.Route) error
+	// GetByID returns nil, nil when the route does not exist.
 	GetByID(ctx context.Context, id string) (*domain.Route, error)
+	// GetByIDs returns the routes that exist of ids, in no particular order.
+	GetByIDs(ctx context.Context, ids []string) ([]domain.Route, error)
 	ListByAgency(ctx context.Context, agencyID string) ([]domain.Route, error)
+	// ListAll returns a page of the routes the filter keeps, best name
+	// match first when it has a query and by short name otherwise, with
+	// how many it keeps in all.
+	ListAll(ctx context.Context, filter domain.RouteFilter, offset, limit int) ([]domain.Route, int, error)
 	ListByStop(ctx context.Context, stopUUID string) ([]domain.Route, error)
+	GetShape(ctx context.Context, id string) (*domain.GeoLineString, error)
 }
 
 // TripRepository persists trips and stop-times.
@@ -40,7 +129,11 @@ type TripRepository interface {
 	GetByID(ctx context.Context, id string) (*domain.Trip, error)
 	UpsertStopTimes(ctx context.Context, stopTimes []domain.StopTime) error
 	GetStopTimes(ctx context.Context, tripID string) ([]domain.StopTime, error)
-	NextDeparturesAtStop(ctx context.Context, stopUUID string, limit int) ([]domain.Departure, error)
+	NextDeparturesAtStop(ctx context.Context, stopUUID string, limit int, filter domain.DepartureFilter) ([]domain.Departure, error)
+	// NextDeparturesNearby returns the next departures within horizon from
+	// the stops within radiusMeters of a point, each trip once from its
+	// nearest stop, soonest first.
+	NextDeparturesNearby(ctx context.Context, lat, lon, radiusMeters float64, horizon time.Duration, limit int, filter domain.DepartureFilter) ([]domain.NearbyDeparture, error)
 }
 
 // VehiclePositionRepository persists real-time vehicle positions.
@@ -48,6 +141,82 @@ type VehiclePositionRepository interface {
 	Insert(ctx context.Context, vp *domain.VehiclePosition) error
 	InsertBatch(ctx context.Context, vps []domain.VehiclePosition) error
 	LatestByRoute(ctx context.Context, routeID string) ([]domain.VehiclePosition, error)
+	// LatestByRoutes is LatestByRoute for several routes at once, by route.
+	LatestByRoutes(ctx context.Context, routeIDs []string) ([]domain.VehiclePosition, error)
+	// LatestNearby returns the latest position of each vehicle reported since
+	// `since` that is within radiusMeters of the point, with route and
+	// headsign, nearest first. Vehicles of an unknown route are only left
+	// out when routeTypes includes some route types.
+	LatestNearby(ctx context.Context, lat, lon, radiusMeters float64, since time.Time, limit int, routeTypes domain.RouteTypeFilter) ([]domain.VehiclePosition, error)
+	// History returns a vehicle's positions between from and to, oldest
+	// first, keeping the last one in each bucket. An empty agency slug
+	// matches vehicles of any agency.
+	History(ctx context.Context, agency, vehicleID string, from, to time.Time, bucket time.Duration) ([]domain.VehiclePosition, error)
+	// Fleet summarises the latest position of each vehicle of the agency
+	// (by slug) reported since `since`, in total and per route, counting
+	// staleness up to now. Routes come in no particular order.
+	Fleet(ctx context.Context, agency string, since, now time.Time) (*domain.Fleet, error)
+}
+
+// TripResolver maps the GTFS trip and route IDs a realtime feed reports to
+// internal IDs.
+type TripResolver interface {
+	// Resolve returns the internal IDs of the agency's GTFS trip and route;
+	// each is empty when the static schedule does not know it.
+	Resolve(ctx context.Context, agencyID, gtfsTripID, gtfsRouteID string) (tripID, routeID string, err error)
+}
+
+// FeedIDResolver maps the GTFS IDs of a whole realtime feed to internal IDs
+// at once.
+type FeedIDResolver interface {
+	// TripStops returns the scheduled stops of the agency's GTFS trips in
+	// sequence, by GTFS trip ID; trips the static schedule does not know
+	// are left out.
+	TripStops(ctx context.Context, agencyID string, gtfsTripIDs []string) (map[string][]domain.TripStop, error)
+	// RouteIDs and StopIDs return the internal IDs of the agency's GTFS
+	// routes and stops, by GTFS ID; unknown IDs are left out.
+	RouteIDs(ctx context.Context, agencyID string, gtfsRouteIDs []string) (map[string]string, error)
+	StopIDs(ctx context.Context, agencyID string, gtfsStopIDs []string) (map[string]string, error)
+}
+
+// TripUpdateRepository persists per-stop GTFS-RT trip update predictions.
+type TripUpdateRepository interface {
+	// InsertBatch stores predictions in one round trip.
+	InsertBatch(ctx context.Context, predictions []domain.StopTimePrediction) error
+	// LatestAtStop returns the most recent prediction per trip at a stop, ignoring those older than since.
+	LatestAtStop(ctx context.Context, stopUUID string, since time.Time) ([]domain.StopTimePrediction, error)
+	// LatestAtStops is LatestAtStop for several stops at once, per trip and stop.
+	LatestAtStops(ctx context.Context, stopUUIDs []string, since time.Time) ([]domain.StopTimePrediction, error)
+}
+
+// AlertRepository persists service alerts. Listing methods return alerts that
+// are live (still in their feed) and whose active periods contain at.
+type AlertRepository interface {
+	Upsert(ctx context.Context, alert *domain.ServiceAlert) error
+	// ListActive returns active alerts; an empty agencyID lists all agencies.
+	ListActive(ctx context.Context, agencyID string, at time.Time) ([]domain.ServiceAlert, error)
+	// ListActiveByRoute includes agency-wide alerts of the route's agency.
+	ListActiveByRoute(ctx context.Context, routeID string, at time.Time) ([]domain.ServiceAlert, error)
+	// ListActiveByStop includes alerts on routes serving the stop and agency-wide alerts.
+	ListActiveByStop(ctx context.Context, stopID string, at time.Time) ([]domain.ServiceAlert, error)
+	// RemoveMissing marks the agency's live alerts from source whose source
+	// IDs are not in live as removed.
+	RemoveMissing(ctx context.Context, agencyID, source string, live []string) error
+}
+
+// EventRepository persists planned events and their service overlays.
+type EventRepository interface {
+	Create(ctx context.Context, e *domain.Event) error
+	// Update replaces an event and reports false if it does not exist.
+	Update(ctx context.Context, e *domain.Event) (bool, error)
+	Delete(ctx context.Context, id string) (bool, error)
+	// GetByID returns nil if the event does not exist.
+	GetByID(ctx context.Context, id string) (*domain.Event, error)
+	// List returns events whose service window overlaps [from, to), soonest first.
+	List(ctx context.Context, from, to time.Time) ([]domain.Event, error)
+	// ListActiveNear returns events in their service window at `at` whose
+	// geofence contains the point.
+	ListActiveNear(ctx context.Context, point domain.GeoPoint, at time.Time) ([]domain.Event, error)
 }
 
 // DelayEventRepository persists delay events.
@@ -57,22 +226,388 @@ type DelayEventRepository interface {
 	MarkCompensated(ctx context.Context, id string) error
 }
 
+// DelayObservationRepository records the delays seen in realtime feeds as
+// delay events.
+type DelayObservationRepository interface {
+	// Record inserts a delay event for each observation the static schedule
+	// matches, unless the trip already has one at the stop within window.
+	// It returns the event inserted for each observation, nil for none.
+	Record(ctx context.Context, obs []domain.DelayObservation, window time.Duration) ([]*domain.DelayEvent, error)
+}
+
 // AffiliateRepository persists affiliate shops.
 type AffiliateRepository interface {
-	FindNearby(ctx context.Context, lat, lon float64, limit int) ([]domain.Affiliate, error)
+	// FindNearby returns active affiliates within radiusMeters, nearest first.
+	FindNearby(ctx context.Context, lat, lon, radiusMeters float64, limit int) ([]domain.Affiliate, error)
+	// GetByID returns nil, nil when the affiliate does not exist.
 	GetByID(ctx context.Context, id string) (*domain.Affiliate, error)
+	List(ctx context.Context) ([]domain.Affiliate, error)
+	Create(ctx context.Context, a *domain.Affiliate) error
+	Update(ctx context.Context, a *domain.Affiliate) (bool, error)
+	Deactivate(ctx context.Context, id string) (bool, error)
 }
 
 // CompensationRepository persists compensation coupons.
 type CompensationRepository interface {
 	Create(ctx context.Context, comp *domain.Compensation) error
 	GetByCode(ctx context.Context, code string) (*domain.Compensation, error)
-	Redeem(ctx context.Context, code string) error
+	ListByUser(ctx context.Context, userID string) ([]domain.Compensation, error)
+	// Redeem marks an unredeemed, unexpired coupon as redeemed at at and
+	// reports whether it did.
+	Redeem(ctx context.Context, code string, at time.Time) (bool, error)
 	Delete(ctx context.Context, code string) error
 }
 
 // JourneyRepository finds routes between stops.
 type JourneyRepository interface {
-	// FindJourneys returns possible journeys from one stop to another at a given time.
-	FindJourneys(ctx context.Context, fromStopID, toStopID string, departAfter time.Time, maxTransfers int, limit int) ([]domain.Journey, error)
+	// FindJourneys returns possible journeys from one stop to another at a
+	// given time, on the services the filter keeps.
+	FindJourneys(ctx context.Context, fromStopID, toStopID string, departAfter time.Time, maxTransfers int, limit int, filter domain.JourneyFilter) ([]domain.Journey, error)
+	// FindJourneysBetween returns journeys from any of the origin stops to any
+	// of the destination stops, both nearest first, counting the walks to and
+	// from them: an origin is left no earlier than departAfter plus its walk.
+	// The walks themselves are not among the legs.
+	FindJourneysBetween(ctx context.Context, origins, destinations []domain.StopAccess, departAfter time.Time, maxTransfers int, limit int, filter domain.JourneyFilter) ([]domain.Journey, error)
+	// FindNightLines returns, one per route, the trips departing within window
+	// of departAfter from a stop within radiusMeters of the origin stop and
+	// later calling within radiusMeters of the destination stop.
+	FindNightLines(ctx context.Context, fromStopID, toStopID string, departAfter time.Time, window time.Duration, radiusMeters float64, limit int) ([]domain.NightLine, error)
+	// FindReachable returns the stops reached from any of the origin stops,
+	// counting the walks to them as FindJourneysBetween does, arriving no
+	// later than departAfter plus within, on the services the filter keeps.
+	// The origins themselves are among them. Minutes is left unset.
+	FindReachable(ctx context.Context, origins []domain.StopAccess, departAfter time.Time, within time.Duration, maxTransfers int, filter domain.JourneyFilter) ([]domain.ReachableStop, error)
+}
+
+// TimetableRepository loads the static timetable for in-memory journey planning.
+type TimetableRepository interface {
+	// Version returns when the newest static feed was activated; a new
+	// version means the timetable must be loaded again.
+	Version(ctx context.Context) (time.Time, error)
+	// Load returns the whole timetable, with walking transfers between stops
+	// within walkRadiusMeters of each other.
+	Load(ctx context.Context, walkRadiusMeters float64) (*domain.Timetable, error)
+}
+
+// StaticVersionRepository reports when the static data served by the API
+// (stops, routes, trips and their shapes) last changed.
+type StaticVersionRepository interface {
+	// StaticVersion returns the latest of the newest feed activation and
+	// the newest stop, route or trip change.
+	StaticVersion(ctx context.Context) (time.Time, error)
+}
+
+// ShortLinkRepository persists stop short-link codes.
+type ShortLinkRepository interface {
+	// Create stores a link. If the stop already has a code, link is filled with the existing one.
+	Create(ctx context.Context, link *domain.StopShortLink) error
+	GetByCode(ctx context.Context, code string) (*domain.StopShortLink, error)
+	ListByAgency(ctx context.Context, agencyID string) ([]domain.StopShortLink, error)
+	StopsWithoutLink(ctx context.Context, agencyID string) ([]string, error)
+}
+
+// HistoryRepository persists rider journey history and the consent that gates it.
+type HistoryRepository interface {
+	SetConsent(ctx context.Context, userID string, granted bool) error
+	HasConsent(ctx context.Context, userID string) (bool, error)
+	Insert(ctx context.Context, j *domain.RiderJourney) error
+	ListByUser(ctx context.Context, userID string, from, to time.Time) ([]domain.RiderJourney, error)
+	DeleteByUser(ctx context.Context, userID string) error
+	// RidersOnTrip returns the users with a journey on the trip departed in [from, to).
+	RidersOnTrip(ctx context.Context, tripID string, from, to time.Time) ([]string, error)
+}
+
+// CheckInRepository persists rider trip check-ins.
+type CheckInRepository interface {
+	Create(ctx context.Context, c *domain.CheckIn) error
+	ListByUser(ctx context.Context, userID string, limit int) ([]domain.CheckIn, error)
+	// RidersOnTrip returns the users who checked in to a trip between from and to.
+	RidersOnTrip(ctx context.Context, tripID string, from, to time.Time) ([]string, error)
+}
+
+// TileRepository renders map tiles (XYZ, Web Mercator) of stops and route shapes.
+type TileRepository interface {
+	MVT(ctx context.Context, z, x, y int, withStops bool) ([]byte, error)
+	StopsInTile(ctx context.Context, z, x, y int) ([]domain.Stop, error)
+	RouteShapesInTile(ctx context.Context, z, x, y int) ([]domain.Route, error)
+}
+
+// ChallengeRepository persists sustainability challenges and user enrollments.
+type ChallengeRepository interface {
+	Create(ctx context.Context, ch *domain.Challenge) error
+	GetByID(ctx context.Context, id string) (*domain.Challenge, error)
+	ListActive(ctx context.Context, at time.Time) ([]domain.Challenge, error)
+	Enroll(ctx context.Context, challengeID, userID string) error
+	GetEnrollment(ctx context.Context, challengeID, userID string) (*domain.ChallengeEnrollment, error)
+	ListEnrollments(ctx context.Context, userID string) ([]domain.ChallengeEnrollment, error)
+	MarkRewarded(ctx context.Context, challengeID, userID, code string) error
+}
+
+// SLARepository persists agency SLA contracts, their daily punctuality rollups
+// and monthly evaluations.
+type SLARepository interface {
+	CreateContract(ctx context.Context, c *domain.SLAContract) error
+	// ListContracts returns active contracts; an empty agencyID lists all agencies.
+	ListContracts(ctx context.Context, agencyID string) ([]domain.SLAContract, error)
+	// RollupDay recomputes a contract's on-time counts for one day from trip update predictions.
+	RollupDay(ctx context.Context, c *domain.SLAContract, day time.Time) error
+	// MonthStats sums daily rollups for the days in [from, to).
+	MonthStats(ctx context.Context, contractID string, from, to time.Time) (observed, onTime int, err error)
+	// SaveEvaluation stores a final evaluation and reports false if one already existed.
+	SaveEvaluation(ctx context.Context, e *domain.SLAEvaluation) (bool, error)
+	// GetEvaluation returns nil without error when the month has not been evaluated.
+	GetEvaluation(ctx context.Context, contractID, month string) (*domain.SLAEvaluation, error)
+}
+
+// DelayStatsRepository aggregates trip update delays into hourly per-route statistics.
+type DelayStatsRepository interface {
+	// RollupHour recomputes route means for the hour starting at hour and
+	// records the final delay of each trip stop for delay profiles.
+	RollupHour(ctx context.Context, hour time.Time) error
+	HourlyDelays(ctx context.Context, hour time.Time) ([]domain.RouteDelayStat, error)
+	// Baselines summarises each route's hourly means since since, at the same
+	// hour of day (agency local time) as hour, excluding hour itself.
+	Baselines(ctx context.Context, hour, since time.Time) ([]domain.DelayBaseline, error)
+}
+
+// DelayProfileRepository reads historical delay patterns at stops.
+type DelayProfileRepository interface {
+	// StopProfiles returns the delay profiles of every route at a stop,
+	// built from observations since since.
+	StopProfiles(ctx context.Context, stopUUID string, since time.Time) ([]domain.DelayProfile, error)
+}
+
+// OccupancyRepository reads the occupancy vehicles report in GTFS-RT feeds.
+type OccupancyRepository interface {
+	// LatestByRoute returns the latest occupancy of each of the route's
+	// vehicles that reported one since since.
+	LatestByRoute(ctx context.Context, routeID string, since time.Time) ([]domain.VehicleOccupancy, error)
+	// LatestByTrips returns, for each of the trips whose vehicle reported
+	// occupancy since since, the latest report.
+	LatestByTrips(ctx context.Context, tripIDs []string, since time.Time) ([]domain.VehicleOccupancy, error)
+	// StopCrowding averages occupancy at a stop since since by weekday and
+	// hour of day in the agency's timezone. An empty routeID matches all
+	// routes. Level is left empty.
+	StopCrowding(ctx context.Context, stopUUID, routeID string, since time.Time) ([]domain.CrowdingSlot, error)
+}
+
+// PunctualityRepository reads on-time performance from hourly rollups of
+// stop delay observations. Each method groups the observations f selects;
+// on-time percentages are left to the caller.
+type PunctualityRepository interface {
+	// ByHour groups by hour of day in the agency's timezone.
+	ByHour(ctx context.Context, f domain.PunctualityFilter) ([]domain.HourPunctuality, error)
+	// ByDay groups by day in the agency's timezone, oldest first.
+	ByDay(ctx context.Context, f domain.PunctualityFilter) ([]domain.DayPunctuality, error)
+	// ByStop groups by stop, leaving out stops with fewer than minObserved observations.
+	ByStop(ctx context.Context, f domain.PunctualityFilter, minObserved int) ([]domain.StopPunctuality, error)
+	// ByRoute groups by route, leaving out routes with fewer than minObserved observations.
+	ByRoute(ctx context.Context, f domain.PunctualityFilter, minObserved int) ([]domain.RoutePunctualityStats, error)
+}
+
+// FeedQualityRepository counts RT identifiers seen by the realtime poller and
+// stores feed quality reports. kind is "trip" or "stop".
+type FeedQualityRepository interface {
+	// RecordSeenIDs adds one poll's occurrences of identifiers of kind to
+	// the agency's counts for day.
+	RecordSeenIDs(ctx context.Context, agencyID string, day time.Time, kind string, seen map[string]int) error
+	// SeenIDs returns the distinct identifiers of kind seen on day, marking
+	// those that resolve against the agency's static data.
+	SeenIDs(ctx context.Context, agencyID string, day time.Time, kind string) ([]domain.SeenID, error)
+	// StaticIDs returns all static identifiers of kind for the agency.
+	StaticIDs(ctx context.Context, agencyID, kind string) ([]string, error)
+	SaveReport(ctx context.Context, r *domain.FeedQualityReport) error
+	// GetReport returns nil, nil when no report exists.
+	GetReport(ctx context.Context, agencyID, day string) (*domain.FeedQualityReport, error)
+}
+
+// UserRepository persists rider accounts. Emails are stored lower-cased.
+type UserRepository interface {
+	// Create inserts the user and reports false if the email is taken.
+	Create(ctx context.Context, u *domain.User) (bool, error)
+	// GetByEmail and GetByID return nil, nil when no user matches.
+	GetByEmail(ctx context.Context, email string) (*domain.User, error)
+	GetByID(ctx context.Context, id string) (*domain.User, error)
+}
+
+// FavoriteRepository persists riders' saved stops, routes and journeys.
+type FavoriteRepository interface {
+	// Create stores f and reports false if the user already saved the same favorite.
+	Create(ctx context.Context, f *domain.Favorite) (bool, error)
+	// ListByUser returns the user's favorites with names, oldest first.
+	ListByUser(ctx context.Context, userID string) ([]domain.Favorite, error)
+	// Delete removes one of the user's favorites and reports false if none matched.
+	Delete(ctx context.Context, userID, id string) (bool, error)
+}
+
+// AlertSubscriptionRepository persists riders' delay alert subscriptions.
+type AlertSubscriptionRepository interface {
+	Create(ctx context.Context, sub *domain.AlertSubscription) error
+	// ListByUser returns the user's subscriptions with names, oldest first.
+	ListByUser(ctx context.Context, userID string) ([]domain.AlertSubscription, error)
+	// Delete removes one of the user's subscriptions and reports false if none matched.
+	Delete(ctx context.Context, userID, id string) (bool, error)
+	// ClaimDelayAlerts returns the subscriptions e matches that have not been
+	// alerted about its trip within the last `within`, and records them as
+	// alerted so concurrent or later calls skip them.
+	ClaimDelayAlerts(ctx context.Context, e *domain.DelayEvent, within time.Duration) ([]domain.DelayAlert, error)
+}
+
+// AccessibleSpaceRepository persists accessible space reports.
+type AccessibleSpaceRepository interface {
+	Insert(ctx context.Context, r *domain.AccessibleSpaceReport) error
+	// RecentByTrips returns the reports on the trips made since `since`, newest first.
+	RecentByTrips(ctx context.Context, tripIDs []string, since time.Time) ([]domain.AccessibleSpaceReport, error)
+}
+
+// FeedStateRepository records favorited stops and routes as each GTFS
+// ingest saw them, so the next ingest can tell what changed.
+type FeedStateRepository interface {
+	// FavoritedSubjects returns the agency's stops and routes riders
+	// favorited, directly or as a journey's end, with their recorded state.
+	FavoritedSubjects(ctx context.Context, agencyID string) ([]domain.FeedSubject, error)
+	Save(ctx context.Context, subjects []domain.FeedSubject) error
+}
+
+// FeedHistoryRepository reads stops, routes and trip timetables as the feed
+// version current at a past time had them. Each returns nil, nil when the
+// entity was not in that version.
+type FeedHistoryRepository interface {
+	// Versions returns the agency's feed versions, newest first.
+	Versions(ctx context.Context, agencyID string) ([]domain.FeedVersion, error)
+	StopAsOf(ctx context.Context, id string, at time.Time) (*domain.Stop, error)
+	RouteAsOf(ctx context.Context, id string, at time.Time) (*domain.Route, error)
+	RoutesAsOf(ctx context.Context, agencyID string, at time.Time) ([]domain.Route, error)
+	// StopTimesAsOf returns the trip's ordered stop times.
+	StopTimesAsOf(ctx context.Context, tripID string, at time.Time) ([]domain.StopTime, error)
+}
+
+// DeviceRepository persists riders' push notification devices.
+type DeviceRepository interface {
+	// Register inserts the device, or moves an already known token to
+	// d.UserID and refreshes its keys.
+	Register(ctx context.Context, d *domain.Device) error
+	ListByUser(ctx context.Context, userID string) ([]domain.Device, error)
+	// Delete removes one of the user's devices and reports false if none matched.
+	Delete(ctx context.Context, userID, id string) (bool, error)
+	// DeleteByToken forgets a token the push service no longer accepts.
+	DeleteByToken(ctx context.Context, token string) error
+}
+
+// NotificationPreferenceRepository persists riders' notification
+// preferences.
+type NotificationPreferenceRepository interface {
+	// Get returns nil when the user never set any.
+	Get(ctx context.Context, userID string) (*domain.NotificationPreferences, error)
+	Save(ctx context.Context, userID string, p *domain.NotificationPreferences) error
+}
+
+// FeedConfigRepository persists per-agency feed settings.
+type FeedConfigRepository interface {
+	// Get returns nil, nil when the agency has no feed config.
+	Get(ctx context.Context, agencyID string) (*domain.FeedConfig, error)
+	Save(ctx context.Context, c *domain.FeedConfig) error
+}
+
+// FeedStageRepository keeps static feeds held for approval and the applied
+// feeds rollbacks are made from. The feeds themselves are only read by the
+// ingestor.
+type FeedStageRepository interface {
+	// List returns the agency's stages, newest first.
+	List(ctx context.Context, agencyID string) ([]domain.FeedStage, error)
+	// Decide moves a staged feed to status (approved or rejected). It
+	// returns nil, nil when the agency has no such stage and the unchanged
+	// stage when it was not staged.
+	Decide(ctx context.Context, agencyID, id, status string) (*domain.FeedStage, error)
+	// Rollback queues the feed of the agency's previous feed version for
+	// the ingestor, approved, and rejects any feed waiting to be applied.
+	// It returns nil, nil when that feed is not kept.
+	Rollback(ctx context.Context, agencyID string) (*domain.FeedStage, error)
+}
+
+// RealtimeCoverageRepository samples and reads how many running trips have
+// realtime data.
+type RealtimeCoverageRepository interface {
+	// Sample records, per agency and route, the trips running at at and how
+	// many of them had a vehicle position or trip update since liveSince.
+	Sample(ctx context.Context, at, liveSince time.Time) error
+	// Latest returns the agency's newest sample, or nil, nil before the
+	// first one.
+	Latest(ctx context.Context, agencyID string) (*domain.CoverageSample, error)
+}
+
+// BrandingRepository persists agency logos, colors and route icons.
+type BrandingRepository interface {
+	// Get returns nil, nil when the agency has no branding; Routes is not set.
+	Get(ctx context.Context, agencyID string) (*domain.AgencyBranding, error)
+	Save(ctx context.Context, b *domain.AgencyBranding) error
+	// RouteIcons returns icon URLs of the agency's routes by routes.id.
+	RouteIcons(ctx context.Context, agencyID string) (map[string]string, error)
+	SetRouteIcon(ctx context.Context, routeID, url string) error
+}
+
+// OfflineRepository reads the stops and departures offline bundles are
+// built from.
+type OfflineRepository interface {
+	// Stops returns the agency's stops inside b, ordered by ID.
+	Stops(ctx context.Context, agencyID string, b domain.Bounds) ([]domain.OfflineStop, error)
+	// Departures returns the scheduled departures from stopIDs in
+	// [from, from+window), ordered by time.
+	Departures(ctx context.Context, stopIDs []string, from time.Time, window time.Duration) ([]domain.OfflineDeparture, error)
+}
+
+// SyncRepository reads the change logs of stops, routes and alerts. Each
+// method returns up to limit changes after pos and no later than until,
+// oldest first. agencyID may be empty for all agencies.
+type SyncRepository interface {
+	StopChanges(ctx context.Context, agencyID string, after domain.SyncPosition, until time.Time, limit int) ([]domain.StopChange, error)
+	RouteChanges(ctx context.Context, agencyID string, after domain.SyncPosition, until time.Time, limit int) ([]domain.RouteChange, error)
+	AlertChanges(ctx context.Context, agencyID string, after domain.SyncPosition, until time.Time, limit int) ([]domain.AlertChange, error)
+}
+
+// RealtimeFeedStatusRepository keeps the outcome of the realtime poller's
+// latest fetch of each feed.
+type RealtimeFeedStatusRepository interface {
+	// RecordPoll updates the feed's status. A failed poll keeps the feed
+	// timestamp and entity count of the last successful one.
+	RecordPoll(ctx context.Context, p *domain.RealtimeFeedPoll) error
+	// List returns all feeds ordered by agency slug and feed; Stale is not set.
+	List(ctx context.Context) ([]domain.RealtimeFeedStatus, error)
+}
+
+// FeedHealthRepository reads the health of every agency's feeds.
+type FeedHealthRepository interface {
+	// FeedHealth returns one snapshot per agency; vehicles count as active
+	// when they reported a position after activeSince.
+	FeedHealth(ctx context.Context, activeSince time.Time) ([]domain.FeedHealth, error)
+}
+
+// SegmentStatsRepository derives and reads travel times between consecutive
+// stops and dwell times at stops from vehicle positions.
+type SegmentStatsRepository interface {
+	// RollupHour recomputes the segments vehicles left their first stop of
+	// during the hour starting at hour, with a vehicle at a stop while within
+	// radiusMeters of it.
+	RollupHour(ctx context.Context, hour time.Time, radiusMeters float64) error
+	// RouteSegments sums the route's segments since since, in route order;
+	// SpeedKmh and Slowest are not set.
+	RouteSegments(ctx context.Context, routeID string, since time.Time) ([]domain.RouteSegment, error)
+}
+
+// TimetableComparisonRepository pairs scheduled stop times with observed delays.
+type TimetableComparisonRepository interface {
+	// Compare returns every stop of the agency's trips that had a delay
+	// observed on a service date from from to to (inclusive), by service
+	// date, route, trip and stop sequence.
+	Compare(ctx context.Context, agencyID string, from, to time.Time) ([]domain.TimetableComparison, error)
+}
+
+// ArchiveRepository streams the realtime hypertables for archiving.
+type ArchiveRepository interface {
+	// VehiclePositions calls fn for each position recorded in [from, to),
+	// oldest first, and stops at the first error fn returns.
+	VehiclePositions(ctx context.Context, from, to time.Time, fn func(domain.VehiclePosition) error) error
+	// DelayEvents calls fn for each delay event recorded in [from, to),
+	// oldest first, and stops at the first error fn returns.
+	DelayEvents(ctx context.Context, from, to time.Time, fn func(domain.DelayEvent) error) error
 }
@@ -2,6 +2,8 @@ package ports
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/samirrijal/bilbopass/internal/core/domain"
 )
@@ -12,6 +14,8 @@ type EventPublisher interface {
 	PublishDelayEvent(ctx context.Context, event *domain.DelayEvent) error
 	PublishDetourAlert(ctx context.Context, tripID string) error
 	PublishBroadcast(ctx context.Context, data []byte) error
+	PublishSLABreach(ctx context.Context, result *domain.SLAResult) error
+	PublishDelayAnomaly(ctx context.Context, anomaly *domain.DelayAnomaly) error
 }
 
 // EventSubscriber subscribes to domain events from a message broker.
@@ -21,6 +25,13 @@ type EventSubscriber interface {
 	SubscribeDetourAlerts(ctx context.Context, handler func(ctx context.Context, tripID string) error) error
 }
 
+// CompensationStarter starts compensating a rider for a delay.
+type CompensationStarter interface {
+	// StartCompensation starts the compensation process for req. Starting
+	// the same delay event and user again is a no-op.
+	StartCompensation(ctx context.Context, req domain.CompensationRequest) error
+}
+
 // CacheService provides read-through caching.
 type CacheService interface {
 	Get(ctx context.Context, key string) ([]byte, error)
@@ -28,7 +39,69 @@ type CacheService interface {
 	Delete(ctx context.Context, key string) error
 }
 
+// ObjectStore stores publicly readable assets such as agency logos.
+type ObjectStore interface {
+	// Put stores body under key and returns the URL clients fetch it from.
+	Put(ctx context.Context, key, contentType string, body []byte) (string, error)
+}
+
+// StreetRouter routes along the street network on foot or by bike.
+type StreetRouter interface {
+	// Route returns the length in meters and the travel time of the route
+	// from one point to another in a street mode (domain.StreetWalk or
+	// domain.StreetBike).
+	Route(ctx context.Context, mode string, from, to domain.GeoPoint) (float64, time.Duration, error)
+}
+
+// Geocoder resolves free-text addresses to places, and points back to the
+// street they are on.
+type Geocoder interface {
+	// Geocode returns the places matching the query, best first.
+	Geocode(ctx context.Context, q domain.GeocodeQuery) ([]domain.Place, error)
+	// Reverse returns the place nearest p, with its street and neighborhood
+	// named in lang when the geocoder has it, or nil when there is none.
+	Reverse(ctx context.Context, p domain.GeoPoint, lang string) (*domain.Place, error)
+}
+
+// WorkflowHistory lists the runs of the workflows in Temporal's visibility
+// store.
+type WorkflowHistory interface {
+	// ListRuns returns up to f.Limit runs matching f started since f.Since,
+	// newest first, with the activity and failure of those not completed.
+	ListRuns(ctx context.Context, f domain.WorkflowFilter) ([]domain.WorkflowRun, error)
+}
+
+// CDNPurger evicts cached API responses from a CDN by surrogate key.
+type CDNPurger interface {
+	// Purge evicts every response tagged with any of keys.
+	Purge(ctx context.Context, keys []string) error
+}
+
 // NotificationService sends notifications (push, email, etc.).
+//
+// SendPush sends a notification of category (domain.NotificationDelays...)
+// as the user's preferences allow. It returns ErrPushMuted when they do not,
+// ErrNoDevices when the user has nowhere to receive the notification, and a
+// *PushError when delivery failed.
 type NotificationService interface {
-	SendPush(ctx context.Context, userID, title, body string) error
+	SendPush(ctx context.Context, userID, category, title, body string) error
+}
+
+var (
+	// ErrNoDevices means the user has no devices registered for push.
+	ErrNoDevices = errors.New("no devices registered for push")
+	// ErrPushMuted means the user's notification preferences hold the
+	// notification back: its category or their devices' channels are off,
+	// or it is their quiet hours.
+	ErrPushMuted = errors.New("notification muted by the user's preferences")
+)
+
+// PushError is a failed push delivery. Temporary errors (rate limits, push
+// service outages) may succeed if retried later; others will not.
+type PushError struct {
+	Temporary bool
+	Err       error
 }
+
+func (e *PushError) Error() string { return "push: " + e.Err.Error() }
+func (e *PushError) Unwrap() error { return e.Err }
@@ -0,0 +1,151 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+const (
+	// accessibleSpaceWindow is how long a report describes the spaces on board.
+	accessibleSpaceWindow = 15 * time.Minute
+	// maxVehicleSpaceReports bounds one batch of vehicle reports.
+	maxVehicleSpaceReports = 500
+)
+
+// AccessibleSpaceService tracks live wheelchair and stroller space
+// availability on trips, from vehicles and from riders on board.
+type AccessibleSpaceService struct {
+	reports ports.AccessibleSpaceRepository
+	trips   ports.TripRepository
+}
+
+// NewAccessibleSpaceService creates a new AccessibleSpaceService.
+func NewAccessibleSpaceService(reports ports.AccessibleSpaceRepository, trips ports.TripRepository) *AccessibleSpaceService {
+	return &AccessibleSpaceService{reports: reports, trips: trips}
+}
+
+// Report records what a rider on board sees on a trip.
+func (s *AccessibleSpaceService) Report(ctx context.Context, userID string, r *domain.AccessibleSpaceReport, now time.Time) error {
+	if err := validateSpaceReport(r); err != nil {
+		return err
+	}
+	trip, err := s.trips.GetByID(ctx, r.TripID)
+	if err != nil || trip == nil {
+		return ErrTripNotFound
+	}
+	r.Source = domain.SpaceSourceRider
+	r.UserID = userID
+	r.VehicleID = ""
+	r.Time = now
+	return s.reports.Insert(ctx, r)
+}
+
+// ReportFromVehicles records a batch of reports from on-board systems. The
+// batch is validated as a whole before anything is stored.
+func (s *AccessibleSpaceService) ReportFromVehicles(ctx context.Context, reports []domain.AccessibleSpaceReport, now time.Time) error {
+	if len(reports) == 0 || len(reports) > maxVehicleSpaceReports {
+		return fmt.Errorf("between 1 and %d reports are required", maxVehicleSpaceReports)
+	}
+	for i := range reports {
+		r := &reports[i]
+		if r.TripID == "" {
+			return fmt.Errorf("report %d: trip_id is required", i)
+		}
+		if err := validateSpaceReport(r); err != nil {
+			return fmt.Errorf("report %d: %w", i, err)
+		}
+		r.Source = domain.SpaceSourceVehicle
+		r.UserID = ""
+		if r.Time.IsZero() || r.Time.After(now) {
+			r.Time = now
+		}
+	}
+	for i := range reports {
+		if err := s.reports.Insert(ctx, &reports[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForTrip returns the current accessible space availability on a trip.
+func (s *AccessibleSpaceService) ForTrip(ctx context.Context, tripID string, now time.Time) (*domain.AccessibleSpace, error) {
+	trip, err := s.trips.GetByID(ctx, tripID)
+	if err != nil || trip == nil {
+		return nil, ErrTripNotFound
+	}
+	spaces, err := recentAccessibleSpace(ctx, s.reports, []string{tripID}, now)
+	if err != nil {
+		return nil, err
+	}
+	space := spaces[tripID]
+	return &space, nil
+}
+
+// recentAccessibleSpace summarizes the recent reports on each trip. Trips
+// without reports are unknown.
+func recentAccessibleSpace(ctx context.Context, repo ports.AccessibleSpaceRepository, tripIDs []string, now time.Time) (map[string]domain.AccessibleSpace, error) {
+	reports, err := repo.RecentByTrips(ctx, tripIDs, now.Add(-accessibleSpaceWindow))
+	if err != nil {
+		return nil, err
+	}
+	byTrip := make(map[string][]domain.AccessibleSpaceReport)
+	for _, r := range reports {
+		byTrip[r.TripID] = append(byTrip[r.TripID], r)
+	}
+	out := make(map[string]domain.AccessibleSpace, len(tripIDs))
+	for _, id := range tripIDs {
+		out[id] = summarizeAccessibleSpace(id, byTrip[id])
+	}
+	return out, nil
+}
+
+// summarizeAccessibleSpace takes each space's state from the newest report
+// mentioning it. Vehicles count their spaces, so any vehicle report outranks
+// riders' impressions.
+func summarizeAccessibleSpace(tripID string, reports []domain.AccessibleSpaceReport) domain.AccessibleSpace {
+	space := domain.AccessibleSpace{TripID: tripID, Wheelchair: domain.SpaceUnknown, Stroller: domain.SpaceUnknown}
+	source := domain.SpaceSourceRider
+	for _, r := range reports {
+		if r.Source == domain.SpaceSourceVehicle {
+			source = domain.SpaceSourceVehicle
+			break
+		}
+	}
+	for _, r := range reports { // newest first
+		if r.Source != source {
+			continue
+		}
+		space.Reports++
+		if space.UpdatedAt == nil {
+			t := r.Time
+			space.UpdatedAt = &t
+		}
+		if space.Wheelchair == domain.SpaceUnknown && r.Wheelchair != "" {
+			space.Wheelchair = r.Wheelchair
+		}
+		if space.Stroller == domain.SpaceUnknown && r.Stroller != "" {
+			space.Stroller = r.Stroller
+		}
+	}
+	if space.Reports > 0 {
+		space.Source = source
+	}
+	return space
+}
+
+func validateSpaceReport(r *domain.AccessibleSpaceReport) error {
+	if r.Wheelchair == "" && r.Stroller == "" {
+		return fmt.Errorf("wheelchair or stroller is required")
+	}
+	for _, state := range []string{r.Wheelchair, r.Stroller} {
+		if state != "" && state != domain.SpaceAvailable && state != domain.SpaceFull {
+			return fmt.Errorf("space states must be %q or %q", domain.SpaceAvailable, domain.SpaceFull)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock AccessibleSpaceRepository ---
+
+type mockAccessibleSpaceRepo struct {
+	reports []domain.AccessibleSpaceReport // newest first
+}
+
+func (m *mockAccessibleSpaceRepo) Insert(ctx context.Context, r *domain.AccessibleSpaceReport) error {
+	m.reports = append([]domain.AccessibleSpaceReport{*r}, m.reports...)
+	return nil
+}
+
+func (m *mockAccessibleSpaceRepo) RecentByTrips(ctx context.Context, tripIDs []string, since time.Time) ([]domain.AccessibleSpaceReport, error) {
+	var out []domain.AccessibleSpaceReport
+	for _, r := range m.reports {
+		for _, id := range tripIDs {
+			if r.TripID == id && r.Time.After(since) {
+				out = append(out, r)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+func TestAccessibleSpaceService_Report(t *testing.T) {
+	repo := &mockAccessibleSpaceRepo{}
+	trips := &mockTripRepo{trips: map[string]*domain.Trip{"t1": {ID: "t1"}}}
+	svc := usecases.NewAccessibleSpaceService(repo, trips)
+	ctx := context.Background()
+	now := time.Now()
+
+	bad := []domain.AccessibleSpaceReport{
+		{TripID: "t1"},
+		{TripID: "t1", Wheelchair: "some"},
+	}
+	for _, r := range bad {
+		if err := svc.Report(ctx, "u1", &r, now); err == nil {
+			t.Errorf("expected validation error for %+v", r)
+		}
+	}
+	if err := svc.Report(ctx, "u1", &domain.AccessibleSpaceReport{TripID: "nope", Wheelchair: domain.SpaceFull}, now); !errors.Is(err, usecases.ErrTripNotFound) {
+		t.Errorf("expected ErrTripNotFound, got %v", err)
+	}
+
+	r := domain.AccessibleSpaceReport{TripID: "t1", Source: domain.SpaceSourceVehicle, Wheelchair: domain.SpaceFull}
+	if err := svc.Report(ctx, "u1", &r, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Source != domain.SpaceSourceRider || r.UserID != "u1" {
+		t.Errorf("expected a rider report, got %+v", r)
+	}
+}
+
+func TestAccessibleSpaceService_ForTrip(t *testing.T) {
+	now := time.Now()
+	repo := &mockAccessibleSpaceRepo{reports: []domain.AccessibleSpaceReport{
+		{TripID: "t1", Source: domain.SpaceSourceRider, Stroller: domain.SpaceFull, Time: now.Add(-time.Minute)},
+		{TripID: "t1", Source: domain.SpaceSourceRider, Wheelchair: domain.SpaceAvailable, Stroller: domain.SpaceAvailable, Time: now.Add(-2 * time.Minute)},
+		{TripID: "t1", Source: domain.SpaceSourceRider, Wheelchair: domain.SpaceFull, Time: now.Add(-time.Hour)},
+		{TripID: "t2", Source: domain.SpaceSourceRider, Wheelchair: domain.SpaceFull, Time: now.Add(-time.Minute)},
+		{TripID: "t2", Source: domain.SpaceSourceVehicle, Wheelchair: domain.SpaceAvailable, Time: now.Add(-3 * time.Minute)},
+	}}
+	trips := &mockTripRepo{trips: map[string]*domain.Trip{"t1": {ID: "t1"}, "t2": {ID: "t2"}, "t3": {ID: "t3"}}}
+	svc := usecases.NewAccessibleSpaceService(repo, trips)
+	ctx := context.Background()
+
+	// Newest report per space; the stale one is ignored.
+	space, err := svc.ForTrip(ctx, "t1", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if space.Wheelchair != domain.SpaceAvailable || space.Stroller != domain.SpaceFull || space.Reports != 2 {
+		t.Errorf("expected free wheelchair space and full stroller space from 2 reports, got %+v", space)
+	}
+
+	// The vehicle outranks a newer rider report.
+	space, _ = svc.ForTrip(ctx, "t2", now)
+	if space.Wheelchair != domain.SpaceAvailable || space.Source != domain.SpaceSourceVehicle {
+		t.Errorf("expected the vehicle's report, got %+v", space)
+	}
+
+	space, _ = svc.ForTrip(ctx, "t3", now)
+	if space.Wheelchair != domain.SpaceUnknown || space.Stroller != domain.SpaceUnknown || space.UpdatedAt != nil {
+		t.Errorf("expected unknown without reports, got %+v", space)
+	}
+
+	if _, err := svc.ForTrip(ctx, "nope", now); !errors.Is(err, usecases.ErrTripNotFound) {
+		t.Errorf("expected ErrTripNotFound, got %v", err)
+	}
+}
+
+func TestAccessibleSpaceService_ReportFromVehicles(t *testing.T) {
+	repo := &mockAccessibleSpaceRepo{}
+	svc := usecases.NewAccessibleSpaceService(repo, &mockTripRepo{})
+	ctx := context.Background()
+	now := time.Now()
+
+	err := svc.ReportFromVehicles(ctx, []domain.AccessibleSpaceReport{
+		{TripID: "t1", VehicleID: "v1", Wheelchair: domain.SpaceFull},
+		{TripID: "t2", Wheelchair: "maybe"},
+	}, now)
+	if err == nil || len(repo.reports) != 0 {
+		t.Fatalf("expected the batch to be rejected as a whole, got %v with %d stored", err, len(repo.reports))
+	}
+
+	err = svc.ReportFromVehicles(ctx, []domain.AccessibleSpaceReport{
+		{TripID: "t1", VehicleID: "v1", Wheelchair: domain.SpaceFull, Time: now.Add(time.Hour)},
+	}, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r := repo.reports[0]; r.Source != domain.SpaceSourceVehicle || !r.Time.Equal(now) {
+		t.Errorf("expected a vehicle report clamped to now, got %+v", r)
+	}
+}
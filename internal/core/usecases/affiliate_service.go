@@ -0,0 +1,106 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+// CouponAffiliateRadius is how far from the rider (meters) an affiliate may be
+// to receive their coupon.
+const CouponAffiliateRadius = 2000.0
+
+var ErrAffiliateNotFound = errors.New("affiliate not found")
+
+// AffiliateService manages the shops that honour BilboPass coupons.
+type AffiliateService struct {
+	affiliates ports.AffiliateRepository
+}
+
+// NewAffiliateService creates a new AffiliateService.
+func NewAffiliateService(affiliates ports.AffiliateRepository) *AffiliateService {
+	return &AffiliateService{affiliates: affiliates}
+}
+
+// Nearby returns active affiliates within radiusMeters of a point, nearest first.
+func (s *AffiliateService) Nearby(ctx context.Context, lat, lon, radiusMeters float64, limit int) ([]domain.Affiliate, error) {
+	if limit <= 0 || limit > 50 {
+		limit = 20
+	}
+	return s.affiliates.FindNearby(ctx, lat, lon, radiusMeters, limit)
+}
+
+// Get returns an affiliate by ID.
+func (s *AffiliateService) Get(ctx context.Context, id string) (*domain.Affiliate, error) {
+	a, err := s.affiliates.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, ErrAffiliateNotFound
+	}
+	return a, nil
+}
+
+// List returns all affiliates, including inactive ones.
+func (s *AffiliateService) List(ctx context.Context) ([]domain.Affiliate, error) {
+	return s.affiliates.List(ctx)
+}
+
+// Create validates and adds an affiliate.
+func (s *AffiliateService) Create(ctx context.Context, a *domain.Affiliate) error {
+	if err := validateAffiliate(a); err != nil {
+		return err
+	}
+	return s.affiliates.Create(ctx, a)
+}
+
+// Update replaces an affiliate's details.
+func (s *AffiliateService) Update(ctx context.Context, a *domain.Affiliate) error {
+	if err := validateAffiliate(a); err != nil {
+		return err
+	}
+	ok, err := s.affiliates.Update(ctx, a)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAffiliateNotFound
+	}
+	return nil
+}
+
+// Deactivate stops an affiliate from being offered or receiving new coupons.
+func (s *AffiliateService) Deactivate(ctx context.Context, id string) error {
+	ok, err := s.affiliates.Deactivate(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAffiliateNotFound
+	}
+	return nil
+}
+
+func validateAffiliate(a *domain.Affiliate) error {
+	a.Name = strings.TrimSpace(a.Name)
+	a.Category = strings.TrimSpace(a.Category)
+	switch {
+	case a.Name == "":
+		return fmt.Errorf("name is required")
+	case a.Category == "":
+		return fmt.Errorf("category is required")
+	case strings.TrimSpace(a.OfferText) == "":
+		return fmt.Errorf("offer_text is required")
+	case a.OfferValue < 0:
+		return fmt.Errorf("offer_value must not be negative")
+	case a.Location.Lat < -90 || a.Location.Lat > 90 || a.Location.Lon < -180 || a.Location.Lon > 180,
+		a.Location.Lat == 0 && a.Location.Lon == 0:
+		return fmt.Errorf("a valid location is required")
+	}
+	return nil
+}
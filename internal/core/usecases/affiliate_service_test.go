@@ -0,0 +1,87 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+func TestAffiliateService_CreateValidates(t *testing.T) {
+	repo := &mockAffiliateRepo{affiliates: map[string]*domain.Affiliate{}}
+	svc := usecases.NewAffiliateService(repo)
+	ctx := context.Background()
+
+	valid := domain.Affiliate{
+		Name: " Café Iruña ", Category: "cafe", OfferText: "Free coffee",
+		Location: domain.GeoPoint{Lat: 43.263, Lon: -2.935}, Active: true,
+	}
+	bad := []domain.Affiliate{
+		{Category: "cafe", OfferText: "x", Location: valid.Location},
+		{Name: "x", OfferText: "x", Location: valid.Location},
+		{Name: "x", Category: "cafe", Location: valid.Location},
+		{Name: "x", Category: "cafe", OfferText: "x", OfferValue: -1, Location: valid.Location},
+		{Name: "x", Category: "cafe", OfferText: "x"},
+		{Name: "x", Category: "cafe", OfferText: "x", Location: domain.GeoPoint{Lat: 91, Lon: 0}},
+	}
+	for _, a := range bad {
+		if err := svc.Create(ctx, &a); err == nil {
+			t.Errorf("expected validation error for %+v", a)
+		}
+	}
+
+	if err := svc.Create(ctx, &valid); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if valid.Name != "Café Iruña" || valid.ID == "" {
+		t.Errorf("expected trimmed, stored affiliate, got %+v", valid)
+	}
+}
+
+func TestAffiliateService_UpdateAndDeactivate(t *testing.T) {
+	repo := &mockAffiliateRepo{affiliates: map[string]*domain.Affiliate{
+		"aff1": {ID: "aff1", Name: "Café Iruña", Category: "cafe", OfferText: "Free coffee",
+			Location: domain.GeoPoint{Lat: 43.263, Lon: -2.935}, Active: true},
+	}}
+	svc := usecases.NewAffiliateService(repo)
+	ctx := context.Background()
+
+	if _, err := svc.Get(ctx, "missing"); !errors.Is(err, usecases.ErrAffiliateNotFound) {
+		t.Errorf("expected ErrAffiliateNotFound, got %v", err)
+	}
+
+	upd := *repo.affiliates["aff1"]
+	upd.OfferText = "Free pintxo"
+	if err := svc.Update(ctx, &upd); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	upd.ID = "missing"
+	if err := svc.Update(ctx, &upd); !errors.Is(err, usecases.ErrAffiliateNotFound) {
+		t.Errorf("expected ErrAffiliateNotFound, got %v", err)
+	}
+
+	if err := svc.Deactivate(ctx, "aff1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, _ := svc.Get(ctx, "aff1")
+	if got.Active || got.OfferText != "Free pintxo" {
+		t.Errorf("expected updated, inactive affiliate, got %+v", got)
+	}
+	if err := svc.Deactivate(ctx, "missing"); !errors.Is(err, usecases.ErrAffiliateNotFound) {
+		t.Errorf("expected ErrAffiliateNotFound, got %v", err)
+	}
+}
+
+func TestAffiliateService_NearbyClampsLimit(t *testing.T) {
+	repo := &mockAffiliateRepo{}
+	svc := usecases.NewAffiliateService(repo)
+
+	if _, err := svc.Nearby(context.Background(), 43.26, -2.93, 500, 999); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.lastLimit != 20 {
+		t.Errorf("expected limit clamped to 20, got %d", repo.lastLimit)
+	}
+}
@@ -0,0 +1,87 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+var (
+	ErrAliasExists   = errors.New("source is already aliased to an agency")
+	ErrAliasNotFound = errors.New("alias not found")
+)
+
+// AgencyAliasService manages which agencies in other source feeds are
+// duplicates of a canonical agency. Aliases apply on the next ingestion.
+type AgencyAliasService struct {
+	aliases  ports.AgencyAliasRepository
+	agencies ports.AgencyRepository
+}
+
+// NewAgencyAliasService creates a new AgencyAliasService.
+func NewAgencyAliasService(aliases ports.AgencyAliasRepository, agencies ports.AgencyRepository) *AgencyAliasService {
+	return &AgencyAliasService{aliases: aliases, agencies: agencies}
+}
+
+// List returns the aliases of a canonical agency.
+func (s *AgencyAliasService) List(ctx context.Context, agencySlug string) ([]domain.AgencyAlias, error) {
+	agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+	if err != nil || agency == nil {
+		return nil, ErrAgencyNotFound
+	}
+	aliases, err := s.aliases.ListByAgency(ctx, agency.ID)
+	if err != nil {
+		return nil, err
+	}
+	if aliases == nil {
+		aliases = []domain.AgencyAlias{}
+	}
+	return aliases, nil
+}
+
+// Add marks a.SourceAgencyID in the feed a.SourceSlug as a duplicate of the
+// canonical agency. A source can alias only one agency.
+func (s *AgencyAliasService) Add(ctx context.Context, agencySlug string, a *domain.AgencyAlias) error {
+	a.SourceSlug = strings.TrimSpace(a.SourceSlug)
+	a.SourceAgencyID = strings.TrimSpace(a.SourceAgencyID)
+	if a.SourceSlug == "" {
+		return fmt.Errorf("source_slug is required")
+	}
+	if a.SourceSlug == agencySlug {
+		return fmt.Errorf("an agency's own feed cannot alias it")
+	}
+	agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+	if err != nil || agency == nil {
+		return ErrAgencyNotFound
+	}
+	a.AgencyID = agency.ID
+
+	created, err := s.aliases.Create(ctx, a)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return ErrAliasExists
+	}
+	return nil
+}
+
+// Remove deletes an alias of the canonical agency.
+func (s *AgencyAliasService) Remove(ctx context.Context, agencySlug, sourceSlug, sourceAgencyID string) error {
+	agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+	if err != nil || agency == nil {
+		return ErrAgencyNotFound
+	}
+	deleted, err := s.aliases.Delete(ctx, agency.ID, sourceSlug, sourceAgencyID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrAliasNotFound
+	}
+	return nil
+}
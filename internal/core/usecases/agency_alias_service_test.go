@@ -0,0 +1,105 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock AgencyAliasRepository ---
+
+type mockAgencyAliasRepo struct {
+	aliases []domain.AgencyAlias
+}
+
+func (m *mockAgencyAliasRepo) ListByAgency(ctx context.Context, agencyID string) ([]domain.AgencyAlias, error) {
+	var out []domain.AgencyAlias
+	for _, a := range m.aliases {
+		if a.AgencyID == agencyID {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockAgencyAliasRepo) Create(ctx context.Context, a *domain.AgencyAlias) (bool, error) {
+	for _, existing := range m.aliases {
+		if existing.SourceSlug == a.SourceSlug && existing.SourceAgencyID == a.SourceAgencyID {
+			return false, nil
+		}
+	}
+	m.aliases = append(m.aliases, *a)
+	return true, nil
+}
+
+func (m *mockAgencyAliasRepo) Delete(ctx context.Context, agencyID, sourceSlug, sourceAgencyID string) (bool, error) {
+	for i, a := range m.aliases {
+		if a.AgencyID == agencyID && a.SourceSlug == sourceSlug && a.SourceAgencyID == sourceAgencyID {
+			m.aliases = append(m.aliases[:i], m.aliases[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func newAliasAgencies() *mockAgencyRepo {
+	ids := map[string]string{"bilbobus": "a1", "bizkaibus": "a2"}
+	return &mockAgencyRepo{
+		getBySlugFn: func(ctx context.Context, slug string) (*domain.Agency, error) {
+			id, ok := ids[slug]
+			if !ok {
+				return nil, errors.New("no rows")
+			}
+			return &domain.Agency{ID: id, Slug: slug}, nil
+		},
+	}
+}
+
+func TestAgencyAliasService_AddListRemove(t *testing.T) {
+	repo := &mockAgencyAliasRepo{}
+	svc := usecases.NewAgencyAliasService(repo, newAliasAgencies())
+	ctx := context.Background()
+
+	a := domain.AgencyAlias{SourceSlug: " euskadi ", SourceAgencyID: "BB"}
+	if err := svc.Add(ctx, "bilbobus", &a); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a.AgencyID != "a1" || a.SourceSlug != "euskadi" {
+		t.Errorf("expected trimmed alias of a1, got %+v", a)
+	}
+
+	dup := domain.AgencyAlias{SourceSlug: "euskadi", SourceAgencyID: "BB"}
+	if err := svc.Add(ctx, "bizkaibus", &dup); !errors.Is(err, usecases.ErrAliasExists) {
+		t.Errorf("expected ErrAliasExists, got %v", err)
+	}
+	if err := svc.Add(ctx, "bilbobus", &domain.AgencyAlias{SourceSlug: "bilbobus"}); err == nil {
+		t.Error("expected error aliasing an agency's own feed")
+	}
+	if err := svc.Add(ctx, "bilbobus", &domain.AgencyAlias{}); err == nil {
+		t.Error("expected error without source_slug")
+	}
+	if err := svc.Add(ctx, "unknown", &domain.AgencyAlias{SourceSlug: "euskadi"}); !errors.Is(err, usecases.ErrAgencyNotFound) {
+		t.Errorf("expected ErrAgencyNotFound, got %v", err)
+	}
+
+	aliases, err := svc.List(ctx, "bilbobus")
+	if err != nil || len(aliases) != 1 {
+		t.Fatalf("expected one alias, got %d (%v)", len(aliases), err)
+	}
+	if aliases, _ := svc.List(ctx, "bizkaibus"); aliases == nil || len(aliases) != 0 {
+		t.Errorf("expected empty non-nil list, got %v", aliases)
+	}
+
+	if err := svc.Remove(ctx, "bizkaibus", "euskadi", "BB"); !errors.Is(err, usecases.ErrAliasNotFound) {
+		t.Errorf("expected ErrAliasNotFound for another agency's alias, got %v", err)
+	}
+	if err := svc.Remove(ctx, "bilbobus", "euskadi", "BB"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(repo.aliases) != 0 {
+		t.Errorf("expected alias removed, got %v", repo.aliases)
+	}
+}
@@ -0,0 +1,136 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+// minPollInterval and maxPollInterval bound an agency's GTFS-RT poll
+// interval, in seconds.
+const (
+	minPollInterval = 5
+	maxPollInterval = 3600
+)
+
+var (
+	ErrAgencyFeedsExist    = errors.New("agency already has feeds")
+	ErrAgencyFeedsNotFound = errors.New("agency has no feeds")
+	// ErrInvalidAgencyFeeds is returned for malformed slugs and feed URLs.
+	ErrInvalidAgencyFeeds = errors.New("invalid agency feeds")
+)
+
+// AgencyFeedsService manages which agencies are ingested and polled, and
+// from where, so onboarding an operator needs no redeploy. The ingestor
+// reads them on each run and the realtime poller reloads them every
+// minute.
+type AgencyFeedsService struct {
+	feeds ports.AgencyFeedsRepository
+}
+
+// NewAgencyFeedsService creates a new AgencyFeedsService.
+func NewAgencyFeedsService(feeds ports.AgencyFeedsRepository) *AgencyFeedsService {
+	return &AgencyFeedsService{feeds: feeds}
+}
+
+// List returns the agencies with feeds, by slug.
+func (s *AgencyFeedsService) List(ctx context.Context) ([]domain.AgencyFeeds, error) {
+	feeds, err := s.feeds.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if feeds == nil {
+		feeds = []domain.AgencyFeeds{}
+	}
+	return feeds, nil
+}
+
+// Create adds an agency's feeds, creating the agency unless it was
+// ingested before. Its schedule is loaded on the ingestor's next run.
+func (s *AgencyFeedsService) Create(ctx context.Context, f *domain.AgencyFeeds) error {
+	if err := validateAgencyFeeds(f); err != nil {
+		return err
+	}
+	created, err := s.feeds.Create(ctx, f)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return ErrAgencyFeedsExist
+	}
+	return nil
+}
+
+// Update replaces the name and feeds of the agency slug.
+func (s *AgencyFeedsService) Update(ctx context.Context, slug string, f *domain.AgencyFeeds) error {
+	f.Slug = slug
+	if err := validateAgencyFeeds(f); err != nil {
+		return err
+	}
+	updated, err := s.feeds.Update(ctx, f)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrAgencyFeedsNotFound
+	}
+	return nil
+}
+
+// Delete stops ingesting and polling the agency slug. Its schedule and
+// history are kept.
+func (s *AgencyFeedsService) Delete(ctx context.Context, slug string) error {
+	deleted, err := s.feeds.Delete(ctx, slug)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrAgencyFeedsNotFound
+	}
+	return nil
+}
+
+// validateAgencyFeeds trims f and checks its slug, name and URLs. GTFSRT is
+// dropped when it sets nothing.
+func validateAgencyFeeds(f *domain.AgencyFeeds) error {
+	f.Slug = strings.TrimSpace(f.Slug)
+	f.Name = strings.TrimSpace(f.Name)
+	f.GTFSURL = strings.TrimSpace(f.GTFSURL)
+	switch {
+	case !validSlug(f.Slug):
+		return fmt.Errorf("%w: slug must be lowercase letters, digits and underscores", ErrInvalidAgencyFeeds)
+	case f.Name == "" || len(f.Name) > 200:
+		return fmt.Errorf("%w: name is required, up to 200 characters", ErrInvalidAgencyFeeds)
+	case !validHTTPURL(f.GTFSURL):
+		return fmt.Errorf("%w: gtfs_url must be an http(s) URL", ErrInvalidAgencyFeeds)
+	}
+
+	rt := f.GTFSRT
+	if rt == nil {
+		return nil
+	}
+	for _, u := range []struct {
+		name string
+		url  *string
+	}{
+		{"vehicle_positions", &rt.VehiclePositions},
+		{"trip_updates", &rt.TripUpdates},
+		{"alerts", &rt.Alerts},
+	} {
+		*u.url = strings.TrimSpace(*u.url)
+		if *u.url != "" && !validHTTPURL(*u.url) {
+			return fmt.Errorf("%w: gtfs_rt.%s must be an http(s) URL", ErrInvalidAgencyFeeds, u.name)
+		}
+	}
+	if rt.PollInterval != 0 && (rt.PollInterval < minPollInterval || rt.PollInterval > maxPollInterval) {
+		return fmt.Errorf("%w: gtfs_rt.poll_interval must be between %d and %d seconds", ErrInvalidAgencyFeeds, minPollInterval, maxPollInterval)
+	}
+	if *rt == (domain.GTFSRTFeeds{}) {
+		f.GTFSRT = nil
+	}
+	return nil
+}
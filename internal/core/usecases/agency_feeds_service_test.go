@@ -0,0 +1,110 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock AgencyFeedsRepository ---
+
+type mockAgencyFeedsRepo struct {
+	feeds map[string]domain.AgencyFeeds // by slug
+}
+
+func (m *mockAgencyFeedsRepo) List(ctx context.Context) ([]domain.AgencyFeeds, error) {
+	var out []domain.AgencyFeeds
+	for _, f := range m.feeds {
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+func (m *mockAgencyFeedsRepo) GetBySlug(ctx context.Context, slug string) (*domain.AgencyFeeds, error) {
+	if f, ok := m.feeds[slug]; ok {
+		return &f, nil
+	}
+	return nil, nil
+}
+
+func (m *mockAgencyFeedsRepo) Create(ctx context.Context, f *domain.AgencyFeeds) (bool, error) {
+	if _, ok := m.feeds[f.Slug]; ok {
+		return false, nil
+	}
+	m.feeds[f.Slug] = *f
+	return true, nil
+}
+
+func (m *mockAgencyFeedsRepo) Update(ctx context.Context, f *domain.AgencyFeeds) (bool, error) {
+	if _, ok := m.feeds[f.Slug]; !ok {
+		return false, nil
+	}
+	m.feeds[f.Slug] = *f
+	return true, nil
+}
+
+func (m *mockAgencyFeedsRepo) Delete(ctx context.Context, slug string) (bool, error) {
+	if _, ok := m.feeds[slug]; !ok {
+		return false, nil
+	}
+	delete(m.feeds, slug)
+	return true, nil
+}
+
+func TestAgencyFeedsService(t *testing.T) {
+	repo := &mockAgencyFeedsRepo{feeds: map[string]domain.AgencyFeeds{}}
+	svc := usecases.NewAgencyFeedsService(repo)
+	ctx := context.Background()
+
+	feeds, err := svc.List(ctx)
+	if err != nil || feeds == nil {
+		t.Fatalf("expected an empty list, got %v, %v", feeds, err)
+	}
+
+	metro := domain.AgencyFeeds{
+		Slug: " metro_bilbao ", Name: "Metro Bilbao", GTFSURL: "https://example.com/metro.zip",
+		GTFSRT: &domain.GTFSRTFeeds{},
+	}
+	if err := svc.Create(ctx, &metro); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, ok := repo.feeds["metro_bilbao"]; !ok || got.GTFSRT != nil {
+		t.Errorf("expected metro_bilbao stored without GTFS-RT feeds, got %+v", repo.feeds)
+	}
+	if err := svc.Create(ctx, &metro); !errors.Is(err, usecases.ErrAgencyFeedsExist) {
+		t.Errorf("expected ErrAgencyFeedsExist, got %v", err)
+	}
+
+	metro.GTFSRT = &domain.GTFSRTFeeds{TripUpdates: "https://example.com/tu.pb", PollInterval: 15}
+	if err := svc.Update(ctx, "metro_bilbao", &metro); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rt := repo.feeds["metro_bilbao"].GTFSRT; rt == nil || rt.PollInterval != 15 {
+		t.Errorf("expected the GTFS-RT feeds updated, got %+v", rt)
+	}
+	if err := svc.Update(ctx, "bilbobus", &metro); !errors.Is(err, usecases.ErrAgencyFeedsNotFound) {
+		t.Errorf("expected ErrAgencyFeedsNotFound, got %v", err)
+	}
+
+	for name, f := range map[string]domain.AgencyFeeds{
+		"uppercase slug":  {Slug: "Bilbobus", Name: "Bilbobus", GTFSURL: "https://example.com/bus.zip"},
+		"no name":         {Slug: "bilbobus", GTFSURL: "https://example.com/bus.zip"},
+		"ftp gtfs_url":    {Slug: "bilbobus", Name: "Bilbobus", GTFSURL: "ftp://example.com/bus.zip"},
+		"relative alerts": {Slug: "bilbobus", Name: "Bilbobus", GTFSURL: "https://example.com/bus.zip", GTFSRT: &domain.GTFSRTFeeds{Alerts: "/alerts.pb"}},
+		"fast polls":      {Slug: "bilbobus", Name: "Bilbobus", GTFSURL: "https://example.com/bus.zip", GTFSRT: &domain.GTFSRTFeeds{PollInterval: 1}},
+	} {
+		if err := svc.Create(ctx, &f); !errors.Is(err, usecases.ErrInvalidAgencyFeeds) {
+			t.Errorf("%s: expected ErrInvalidAgencyFeeds, got %v", name, err)
+		}
+	}
+
+	if err := svc.Delete(ctx, "metro_bilbao"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.Delete(ctx, "metro_bilbao"); !errors.Is(err, usecases.ErrAgencyFeedsNotFound) {
+		t.Errorf("expected ErrAgencyFeedsNotFound, got %v", err)
+	}
+}
@@ -2,6 +2,9 @@ package usecases
 
 import (
 	"context"
+	"fmt"
+	"net/url"
+	"strings"
 
 	"github.com/samirrijal/bilbopass/internal/core/domain"
 	"github.com/samirrijal/bilbopass/internal/core/ports"
@@ -22,7 +25,72 @@ func (s *AgencyService) List(ctx context.Context) ([]domain.Agency, error) {
 	return s.agencies.List(ctx)
 }
 
+// ListPage returns a page of the agencies by name, with how many there are
+// in all.
+func (s *AgencyService) ListPage(ctx context.Context, offset, limit int) ([]domain.Agency, int, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 || limit > 200 {
+		limit = 100
+	}
+	agencies, total, err := s.agencies.ListPage(ctx, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	if agencies == nil {
+		agencies = []domain.Agency{}
+	}
+	return agencies, total, nil
+}
+
 // GetBySlug returns an agency by slug.
 func (s *AgencyService) GetBySlug(ctx context.Context, slug string) (*domain.Agency, error) {
 	return s.agencies.GetBySlug(ctx, slug)
 }
+
+// UpdateContact validates and replaces an agency's customer service details.
+// Empty fields are cleared.
+func (s *AgencyService) UpdateContact(ctx context.Context, slug string, c *domain.AgencyContact) (*domain.Agency, error) {
+	c.Phone = strings.TrimSpace(c.Phone)
+	c.LostFoundURL = strings.TrimSpace(c.LostFoundURL)
+	c.ComplaintURL = strings.TrimSpace(c.ComplaintURL)
+	if c.Phone != "" && !validPhone(c.Phone) {
+		return nil, fmt.Errorf("phone must contain only digits, spaces and + - ( )")
+	}
+	if c.LostFoundURL != "" && !validHTTPURL(c.LostFoundURL) {
+		return nil, fmt.Errorf("lost_found_url must be an http(s) URL")
+	}
+	if c.ComplaintURL != "" && !validHTTPURL(c.ComplaintURL) {
+		return nil, fmt.Errorf("complaint_url must be an http(s) URL")
+	}
+
+	agency, err := s.agencies.GetBySlug(ctx, slug)
+	if err != nil || agency == nil {
+		return nil, ErrAgencyNotFound
+	}
+	if err := s.agencies.UpdateContact(ctx, agency.ID, c); err != nil {
+		return nil, err
+	}
+	agency.Contact = *c
+	return agency, nil
+}
+
+func validPhone(p string) bool {
+	digits := 0
+	for _, r := range p {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case strings.ContainsRune(" +-()", r):
+		default:
+			return false
+		}
+	}
+	return digits >= 3 && digits <= 15
+}
+
+func validHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -2,6 +2,7 @@ package usecases_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/samirrijal/bilbopass/internal/core/domain"
@@ -13,6 +14,7 @@ import (
 type mockAgencyRepo struct {
 	listFn      func(ctx context.Context) ([]domain.Agency, error)
 	getBySlugFn func(ctx context.Context, slug string) (*domain.Agency, error)
+	contacts    map[string]domain.AgencyContact
 }
 
 func (m *mockAgencyRepo) Upsert(ctx context.Context, a *domain.Agency) error { return nil }
@@ -24,6 +26,14 @@ func (m *mockAgencyRepo) List(ctx context.Context) ([]domain.Agency, error) {
 	return nil, nil
 }
 
+func (m *mockAgencyRepo) ListPage(ctx context.Context, offset, limit int) ([]domain.Agency, int, error) {
+	agencies, err := m.List(ctx)
+	if err != nil || offset >= len(agencies) {
+		return nil, len(agencies), err
+	}
+	return agencies[offset:min(offset+limit, len(agencies))], len(agencies), nil
+}
+
 func (m *mockAgencyRepo) GetBySlug(ctx context.Context, slug string) (*domain.Agency, error) {
 	if m.getBySlugFn != nil {
 		return m.getBySlugFn(ctx, slug)
@@ -31,6 +41,14 @@ func (m *mockAgencyRepo) GetBySlug(ctx context.Context, slug string) (*domain.Ag
 	return nil, nil
 }
 
+func (m *mockAgencyRepo) UpdateContact(ctx context.Context, agencyID string, c *domain.AgencyContact) error {
+	if m.contacts == nil {
+		m.contacts = map[string]domain.AgencyContact{}
+	}
+	m.contacts[agencyID] = *c
+	return nil
+}
+
 func TestAgencyService_List(t *testing.T) {
 	repo := &mockAgencyRepo{
 		listFn: func(ctx context.Context) ([]domain.Agency, error) {
@@ -51,6 +69,31 @@ func TestAgencyService_List(t *testing.T) {
 	}
 }
 
+func TestAgencyService_ListPage(t *testing.T) {
+	repo := &mockAgencyRepo{
+		listFn: func(ctx context.Context) ([]domain.Agency, error) {
+			return []domain.Agency{{Slug: "bizkaibus"}, {Slug: "euskotren"}, {Slug: "metro_bilbao"}}, nil
+		},
+	}
+
+	svc := usecases.NewAgencyService(repo)
+	agencies, total, err := svc.ListPage(context.Background(), -5, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if total != 3 || len(agencies) != 2 || agencies[0].Slug != "bizkaibus" {
+		t.Errorf("expected the first 2 of 3 agencies, got %v of %d", agencies, total)
+	}
+
+	agencies, total, err = svc.ListPage(context.Background(), 3, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if agencies == nil || len(agencies) != 0 || total != 3 {
+		t.Errorf("expected an empty, non-nil page of 3, got %#v of %d", agencies, total)
+	}
+}
+
 func TestAgencyService_GetBySlug(t *testing.T) {
 	repo := &mockAgencyRepo{
 		getBySlugFn: func(ctx context.Context, slug string) (*domain.Agency, error) {
@@ -67,3 +110,40 @@ func TestAgencyService_GetBySlug(t *testing.T) {
 		t.Errorf("expected metro_bilbao, got %s", a.Slug)
 	}
 }
+
+func TestAgencyService_UpdateContact(t *testing.T) {
+	repo := &mockAgencyRepo{
+		getBySlugFn: func(ctx context.Context, slug string) (*domain.Agency, error) {
+			if slug != "metro_bilbao" {
+				return nil, errors.New("no rows")
+			}
+			return &domain.Agency{ID: "a1", Slug: slug}, nil
+		},
+	}
+	svc := usecases.NewAgencyService(repo)
+	ctx := context.Background()
+
+	bad := []domain.AgencyContact{
+		{Phone: "call us"},
+		{Phone: "12"},
+		{LostFoundURL: "metrobilbao.eus/lost"},
+		{ComplaintURL: "javascript:alert(1)"},
+	}
+	for _, c := range bad {
+		if _, err := svc.UpdateContact(ctx, "metro_bilbao", &c); err == nil {
+			t.Errorf("expected validation error for %+v", c)
+		}
+	}
+
+	c := domain.AgencyContact{Phone: " +34 944 254 025 ", LostFoundURL: "https://www.metrobilbao.eus/lost-found"}
+	if _, err := svc.UpdateContact(ctx, "unknown", &c); !errors.Is(err, usecases.ErrAgencyNotFound) {
+		t.Fatalf("expected ErrAgencyNotFound, got %v", err)
+	}
+	a, err := svc.UpdateContact(ctx, "metro_bilbao", &c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a.Contact.Phone != "+34 944 254 025" || repo.contacts["a1"] != a.Contact {
+		t.Errorf("expected trimmed contact stored for a1, got %+v / %+v", a.Contact, repo.contacts["a1"])
+	}
+}
@@ -0,0 +1,43 @@
+package usecases
+
+import (
+	"context"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+// AlertService serves active service alerts.
+type AlertService struct {
+	alerts   ports.AlertRepository
+	agencies ports.AgencyRepository
+}
+
+// NewAlertService creates a new AlertService.
+func NewAlertService(alerts ports.AlertRepository, agencies ports.AgencyRepository) *AlertService {
+	return &AlertService{alerts: alerts, agencies: agencies}
+}
+
+// Active returns alerts in effect at now, optionally limited to one agency.
+func (s *AlertService) Active(ctx context.Context, agencySlug string, now time.Time) ([]domain.ServiceAlert, error) {
+	agencyID := ""
+	if agencySlug != "" {
+		agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+		if err != nil || agency == nil {
+			return nil, ErrAgencyNotFound
+		}
+		agencyID = agency.ID
+	}
+	return s.alerts.ListActive(ctx, agencyID, now)
+}
+
+// ForRoute returns alerts in effect at now that affect a route.
+func (s *AlertService) ForRoute(ctx context.Context, routeID string, now time.Time) ([]domain.ServiceAlert, error) {
+	return s.alerts.ListActiveByRoute(ctx, routeID, now)
+}
+
+// ForStop returns alerts in effect at now that affect a stop or a route serving it.
+func (s *AlertService) ForStop(ctx context.Context, stopID string, now time.Time) ([]domain.ServiceAlert, error) {
+	return s.alerts.ListActiveByStop(ctx, stopID, now)
+}
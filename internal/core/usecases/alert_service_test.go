@@ -0,0 +1,77 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock AlertRepository ---
+
+type mockAlertRepo struct {
+	alerts      []domain.ServiceAlert
+	gotAgencyID string
+	upsertErr   map[string]error // by source ID
+	live        []string         // of the last RemoveMissing
+	removeErr   error
+}
+
+func (m *mockAlertRepo) Upsert(ctx context.Context, a *domain.ServiceAlert) error {
+	if err := m.upsertErr[a.SourceID]; err != nil {
+		return err
+	}
+	m.alerts = append(m.alerts, *a)
+	return nil
+}
+
+func (m *mockAlertRepo) RemoveMissing(ctx context.Context, agencyID, source string, live []string) error {
+	m.live = live
+	return m.removeErr
+}
+
+func (m *mockAlertRepo) ListActive(ctx context.Context, agencyID string, at time.Time) ([]domain.ServiceAlert, error) {
+	m.gotAgencyID = agencyID
+	return m.alerts, nil
+}
+
+func (m *mockAlertRepo) ListActiveByRoute(ctx context.Context, routeID string, at time.Time) ([]domain.ServiceAlert, error) {
+	return m.alerts, nil
+}
+
+func (m *mockAlertRepo) ListActiveByStop(ctx context.Context, stopID string, at time.Time) ([]domain.ServiceAlert, error) {
+	return m.alerts, nil
+}
+
+func TestAlertService_ActiveByAgency(t *testing.T) {
+	repo := &mockAlertRepo{alerts: []domain.ServiceAlert{{SourceID: "a1", Header: map[string]string{"es": "Obras"}}}}
+	agencies := &mockAgencyRepo{
+		getBySlugFn: func(ctx context.Context, slug string) (*domain.Agency, error) {
+			if slug != "metro_bilbao" {
+				return nil, errors.New("no rows")
+			}
+			return &domain.Agency{ID: "agency-uuid", Slug: slug}, nil
+		},
+	}
+	svc := usecases.NewAlertService(repo, agencies)
+	ctx := context.Background()
+
+	alerts, err := svc.Active(ctx, "metro_bilbao", time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(alerts) != 1 || repo.gotAgencyID != "agency-uuid" {
+		t.Errorf("expected agency-filtered alerts, got %d for %q", len(alerts), repo.gotAgencyID)
+	}
+
+	if _, err := svc.Active(ctx, "", time.Now()); err != nil || repo.gotAgencyID != "" {
+		t.Errorf("expected unfiltered listing, got %v / %q", err, repo.gotAgencyID)
+	}
+
+	if _, err := svc.Active(ctx, "nope", time.Now()); !errors.Is(err, usecases.ErrAgencyNotFound) {
+		t.Errorf("expected ErrAgencyNotFound, got %v", err)
+	}
+}
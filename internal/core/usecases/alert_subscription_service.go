@@ -0,0 +1,173 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+const (
+	// maxAlertSubscriptions bounds how many delay alerts a rider can set up.
+	maxAlertSubscriptions = 20
+	// defaultAlertDelay is the delay (seconds) alerts fire at unless the rider
+	// picks another. The realtime poller only records delays above three
+	// minutes, so nothing lower can be asked for.
+	defaultAlertDelay = 5 * 60
+	minAlertDelay     = 3 * 60
+	maxAlertDelay     = 60 * 60
+	// alertResendWindow is how long a subscription stays quiet about a trip it
+	// was alerted about, however many stops the trip is late at.
+	alertResendWindow = 2 * time.Hour
+)
+
+var (
+	ErrAlertSubscriptionNotFound = errors.New("alert subscription not found")
+	ErrTooManyAlertSubscriptions = fmt.Errorf("at most %d alert subscriptions can be set up", maxAlertSubscriptions)
+)
+
+// AlertSubscriptionService manages riders' delay alert subscriptions and
+// pushes detected delays to the riders subscribed to them.
+type AlertSubscriptionService struct {
+	subs     ports.AlertSubscriptionRepository
+	stops    ports.StopRepository
+	routes   ports.RouteRepository
+	notifier ports.NotificationService
+}
+
+// NewAlertSubscriptionService creates a new AlertSubscriptionService. notifier
+// is only needed by NotifyDelay.
+func NewAlertSubscriptionService(subs ports.AlertSubscriptionRepository, stops ports.StopRepository, routes ports.RouteRepository, notifier ports.NotificationService) *AlertSubscriptionService {
+	return &AlertSubscriptionService{subs: subs, stops: stops, routes: routes, notifier: notifier}
+}
+
+// Subscribe validates sub and stores it for userID. Days default to every
+// day, the window to the whole day and the delay to five minutes.
+func (s *AlertSubscriptionService) Subscribe(ctx context.Context, userID string, sub *domain.AlertSubscription) error {
+	if err := validateAlertSubscription(sub); err != nil {
+		return err
+	}
+	if sub.RouteID != "" {
+		route, err := s.routes.GetByID(ctx, sub.RouteID)
+		if err != nil || route == nil {
+			return ErrRouteNotFound
+		}
+		sub.RouteName = route.ShortName
+		if sub.RouteName == "" {
+			sub.RouteName = route.LongName
+		}
+	}
+	if sub.StopID != "" {
+		stop, err := s.stops.GetByID(ctx, sub.StopID)
+		if err != nil || stop == nil {
+			return ErrStopNotFound
+		}
+		sub.StopName = stop.Name
+	}
+
+	existing, err := s.subs.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if len(existing) >= maxAlertSubscriptions {
+		return ErrTooManyAlertSubscriptions
+	}
+	sub.UserID = userID
+	return s.subs.Create(ctx, sub)
+}
+
+// List returns the user's alert subscriptions, oldest first.
+func (s *AlertSubscriptionService) List(ctx context.Context, userID string) ([]domain.AlertSubscription, error) {
+	return s.subs.ListByUser(ctx, userID)
+}
+
+// Remove deletes one of the user's alert subscriptions.
+func (s *AlertSubscriptionService) Remove(ctx context.Context, userID, id string) error {
+	ok, err := s.subs.Delete(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAlertSubscriptionNotFound
+	}
+	return nil
+}
+
+// NotifyDelay pushes e to every rider whose subscription it matches, at most
+// once per subscription and trip within alertResendWindow. Matches are claimed
+// before sending, so a failed push is not retried. It returns how many pushes
+// were delivered; riders without devices or who muted delays are skipped
+// silently.
+func (s *AlertSubscriptionService) NotifyDelay(ctx context.Context, e *domain.DelayEvent) (int, error) {
+	alerts, err := s.subs.ClaimDelayAlerts(ctx, e, alertResendWindow)
+	if err != nil {
+		return 0, fmt.Errorf("match subscriptions: %w", err)
+	}
+
+	sent := 0
+	var errs []error
+	for _, a := range alerts {
+		title, body := delayAlertText(&a)
+		err := s.notifier.SendPush(ctx, a.UserID, domain.NotificationDelays, title, body)
+		switch {
+		case err == nil:
+			sent++
+		case errors.Is(err, ports.ErrNoDevices), errors.Is(err, ports.ErrPushMuted):
+		default:
+			errs = append(errs, fmt.Errorf("subscription %s: %w", a.SubscriptionID, err))
+		}
+	}
+	return sent, errors.Join(errs...)
+}
+
+func delayAlertText(a *domain.DelayAlert) (title, body string) {
+	minutes := (a.DelaySeconds + 30) / 60
+	title = fmt.Sprintf("%s running %d min late", a.RouteName, minutes)
+	body = fmt.Sprintf("%d min late at %s.", minutes, a.StopName)
+	if a.Headsign != "" {
+		body = fmt.Sprintf("Towards %s, %d min late at %s.", a.Headsign, minutes, a.StopName)
+	}
+	return title, body
+}
+
+func validateAlertSubscription(sub *domain.AlertSubscription) error {
+	if sub.RouteID == "" && sub.StopID == "" {
+		return fmt.Errorf("route_id or stop_id is required")
+	}
+
+	if len(sub.Days) == 0 {
+		sub.Days = []int{1, 2, 3, 4, 5, 6, 7}
+	}
+	for _, d := range sub.Days {
+		if d < 1 || d > 7 {
+			return fmt.Errorf("days must be ISO weekdays between 1 (Monday) and 7 (Sunday)")
+		}
+	}
+	slices.Sort(sub.Days)
+	sub.Days = slices.Compact(sub.Days)
+
+	if sub.From == "" && sub.Until == "" {
+		sub.From, sub.Until = "00:00", "00:00"
+	}
+	from, err := time.Parse("15:04", sub.From)
+	if err != nil {
+		return fmt.Errorf("from must be a time as HH:MM")
+	}
+	until, err := time.Parse("15:04", sub.Until)
+	if err != nil {
+		return fmt.Errorf("until must be a time as HH:MM")
+	}
+	sub.From, sub.Until = from.Format("15:04"), until.Format("15:04")
+
+	if sub.MinDelaySeconds == 0 {
+		sub.MinDelaySeconds = defaultAlertDelay
+	}
+	if sub.MinDelaySeconds < minAlertDelay || sub.MinDelaySeconds > maxAlertDelay {
+		return fmt.Errorf("min_delay_seconds must be between %d and %d", minAlertDelay, maxAlertDelay)
+	}
+	return nil
+}
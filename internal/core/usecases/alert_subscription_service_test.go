@@ -0,0 +1,181 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock AlertSubscriptionRepository ---
+
+type mockAlertSubscriptionRepo struct {
+	subs   []domain.AlertSubscription
+	alerts []domain.DelayAlert
+	within time.Duration
+}
+
+func (m *mockAlertSubscriptionRepo) Create(ctx context.Context, sub *domain.AlertSubscription) error {
+	sub.ID = fmt.Sprintf("sub-%d", len(m.subs)+1)
+	m.subs = append(m.subs, *sub)
+	return nil
+}
+
+func (m *mockAlertSubscriptionRepo) ListByUser(ctx context.Context, userID string) ([]domain.AlertSubscription, error) {
+	var out []domain.AlertSubscription
+	for _, s := range m.subs {
+		if s.UserID == userID {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockAlertSubscriptionRepo) Delete(ctx context.Context, userID, id string) (bool, error) {
+	for i, s := range m.subs {
+		if s.UserID == userID && s.ID == id {
+			m.subs = append(m.subs[:i], m.subs[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *mockAlertSubscriptionRepo) ClaimDelayAlerts(ctx context.Context, e *domain.DelayEvent, within time.Duration) ([]domain.DelayAlert, error) {
+	m.within = within
+	return m.alerts, nil
+}
+
+// --- Mock NotificationService ---
+
+type sentPush struct {
+	userID, category, title, body string
+}
+
+type mockNotifier struct {
+	sent []sentPush
+	errs map[string]error // by user
+}
+
+func (m *mockNotifier) SendPush(ctx context.Context, userID, category, title, body string) error {
+	if err := m.errs[userID]; err != nil {
+		return err
+	}
+	m.sent = append(m.sent, sentPush{userID, category, title, body})
+	return nil
+}
+
+func newAlertSubscriptionService(repo *mockAlertSubscriptionRepo, notifier *mockNotifier) *usecases.AlertSubscriptionService {
+	stops := &mockStopRepo{
+		getByIDFn: func(ctx context.Context, id string) (*domain.Stop, error) {
+			if id != "s1" {
+				return nil, errors.New("no rows")
+			}
+			return &domain.Stop{ID: id, Name: "Deusto"}, nil
+		},
+	}
+	routes := &mockRouteRepo{
+		getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
+			if id != "r3" {
+				return nil, errors.New("no rows")
+			}
+			return &domain.Route{ID: id, ShortName: "L3", LongName: "Matiko - Kukullaga"}, nil
+		},
+	}
+	return usecases.NewAlertSubscriptionService(repo, stops, routes, notifier)
+}
+
+func TestAlertSubscriptionService_Subscribe(t *testing.T) {
+	repo := &mockAlertSubscriptionRepo{}
+	svc := newAlertSubscriptionService(repo, nil)
+	ctx := context.Background()
+
+	bad := []domain.AlertSubscription{
+		{},
+		{RouteID: "r3", Days: []int{0}},
+		{RouteID: "r3", From: "07:30"},
+		{RouteID: "r3", From: "7h30", Until: "09:00"},
+		{RouteID: "r3", MinDelaySeconds: 60},
+	}
+	for _, sub := range bad {
+		if err := svc.Subscribe(ctx, "u1", &sub); err == nil {
+			t.Errorf("expected validation error for %+v", sub)
+		}
+	}
+	if err := svc.Subscribe(ctx, "u1", &domain.AlertSubscription{RouteID: "nope"}); !errors.Is(err, usecases.ErrRouteNotFound) {
+		t.Errorf("expected ErrRouteNotFound, got %v", err)
+	}
+	if err := svc.Subscribe(ctx, "u1", &domain.AlertSubscription{StopID: "nope"}); !errors.Is(err, usecases.ErrStopNotFound) {
+		t.Errorf("expected ErrStopNotFound, got %v", err)
+	}
+
+	commute := domain.AlertSubscription{RouteID: "r3", StopID: "s1", Days: []int{5, 1, 2, 3, 4, 1}, From: "7:30", Until: "09:00"}
+	if err := svc.Subscribe(ctx, "u1", &commute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(commute.Days, []int{1, 2, 3, 4, 5}) || commute.From != "07:30" || commute.MinDelaySeconds != 300 {
+		t.Errorf("expected normalized weekday commute, got %+v", commute)
+	}
+	if commute.RouteName != "L3" || commute.StopName != "Deusto" {
+		t.Errorf("expected names filled in, got %+v", commute)
+	}
+
+	allDay := domain.AlertSubscription{StopID: "s1"}
+	if err := svc.Subscribe(ctx, "u1", &allDay); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(allDay.Days) != 7 || allDay.From != "00:00" || allDay.Until != "00:00" {
+		t.Errorf("expected every day, all day, got %+v", allDay)
+	}
+}
+
+func TestAlertSubscriptionService_Remove(t *testing.T) {
+	repo := &mockAlertSubscriptionRepo{subs: []domain.AlertSubscription{{ID: "a1", UserID: "u1", RouteID: "r3"}}}
+	svc := newAlertSubscriptionService(repo, nil)
+	ctx := context.Background()
+
+	if err := svc.Remove(ctx, "u2", "a1"); !errors.Is(err, usecases.ErrAlertSubscriptionNotFound) {
+		t.Errorf("expected ErrAlertSubscriptionNotFound for another user's subscription, got %v", err)
+	}
+	if err := svc.Remove(ctx, "u1", "a1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAlertSubscriptionService_NotifyDelay(t *testing.T) {
+	repo := &mockAlertSubscriptionRepo{alerts: []domain.DelayAlert{
+		{SubscriptionID: "a1", UserID: "u1", RouteName: "L3", Headsign: "Kukullaga", StopName: "Deusto", DelaySeconds: 370},
+		{SubscriptionID: "a2", UserID: "u2", RouteName: "L3", StopName: "Deusto", DelaySeconds: 370},
+		{SubscriptionID: "a3", UserID: "u3", RouteName: "L3", StopName: "Deusto", DelaySeconds: 370},
+		{SubscriptionID: "a4", UserID: "u4", RouteName: "L3", StopName: "Deusto", DelaySeconds: 370},
+	}}
+	notifier := &mockNotifier{errs: map[string]error{
+		"u2": ports.ErrNoDevices,
+		"u3": &ports.PushError{Temporary: true, Err: errors.New("unavailable")},
+		"u4": ports.ErrPushMuted,
+	}}
+	svc := newAlertSubscriptionService(repo, notifier)
+
+	sent, err := svc.NotifyDelay(context.Background(), &domain.DelayEvent{TripID: "t1", StopID: "s1", DelaySeconds: 370})
+	if sent != 1 || len(notifier.sent) != 1 {
+		t.Fatalf("expected one push, got %d", sent)
+	}
+	var pe *ports.PushError
+	if !errors.As(err, &pe) || errors.Is(err, ports.ErrPushMuted) {
+		t.Errorf("expected only the failed push to be reported, got %v", err)
+	}
+	if repo.within != 2*time.Hour {
+		t.Errorf("expected a 2h resend window, got %s", repo.within)
+	}
+
+	p := notifier.sent[0]
+	if p.userID != "u1" || p.category != domain.NotificationDelays || p.title != "L3 running 6 min late" || p.body != "Towards Kukullaga, 6 min late at Deusto." {
+		t.Errorf("unexpected push %+v", p)
+	}
+}
@@ -0,0 +1,138 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+const (
+	// anomalySigma is how many standard deviations above its baseline a
+	// route's hourly mean delay must be to count as an anomaly.
+	anomalySigma = 3.0
+	// anomalyMinExcess ignores statistically unusual but harmless deviations (seconds).
+	anomalyMinExcess = 120.0
+	// anomalyMinStdDev keeps very regular routes from flagging small wobbles (seconds).
+	anomalyMinStdDev = 30.0
+	// anomalyMinSamples is the fewest trip stops an hour needs to be judged.
+	anomalyMinSamples = 10
+	// anomalyMinHours is the fewest past hours a baseline needs to be trusted.
+	anomalyMinHours = 5
+	// baselineWindow is how far back baselines look.
+	baselineWindow = 28 * 24 * time.Hour
+	// anomalyAlertTTL is how long an anomaly alert stays active.
+	anomalyAlertTTL = 2 * time.Hour
+)
+
+// AnomalyService detects routes whose delays are far above their usual level
+// for the hour of day, and raises them as service alerts and events.
+type AnomalyService struct {
+	stats     ports.DelayStatsRepository
+	alerts    ports.AlertRepository
+	publisher ports.EventPublisher
+}
+
+// NewAnomalyService creates a new AnomalyService. publisher may be nil, in
+// which case anomalies are only stored as alerts.
+func NewAnomalyService(stats ports.DelayStatsRepository, alerts ports.AlertRepository, publisher ports.EventPublisher) *AnomalyService {
+	return &AnomalyService{stats: stats, alerts: alerts, publisher: publisher}
+}
+
+// Detect rolls up the last complete hour before now and compares each route
+// against its baseline. Each anomaly is stored as an alert; it is published
+// only the first time it is seen, so Detect can safely run more than once an hour.
+func (s *AnomalyService) Detect(ctx context.Context, now time.Time) ([]domain.DelayAnomaly, error) {
+	hour := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location()).Add(-time.Hour)
+
+	if err := s.stats.RollupHour(ctx, hour); err != nil {
+		return nil, fmt.Errorf("rollup: %w", err)
+	}
+	current, err := s.stats.HourlyDelays(ctx, hour)
+	if err != nil {
+		return nil, fmt.Errorf("hourly delays: %w", err)
+	}
+	baselines, err := s.stats.Baselines(ctx, hour, hour.Add(-baselineWindow))
+	if err != nil {
+		return nil, fmt.Errorf("baselines: %w", err)
+	}
+	byRoute := make(map[string]domain.DelayBaseline, len(baselines))
+	for _, b := range baselines {
+		byRoute[b.RouteID] = b
+	}
+
+	var anomalies []domain.DelayAnomaly
+	for _, st := range current {
+		b, ok := byRoute[st.RouteID]
+		if !ok {
+			continue
+		}
+		a, ok := checkAnomaly(st, b)
+		if !ok {
+			continue
+		}
+
+		alert := anomalyAlert(&a)
+		if err := s.alerts.Upsert(ctx, alert); err != nil {
+			return anomalies, fmt.Errorf("store alert: %w", err)
+		}
+		a.AlertID = alert.ID
+		if s.publisher != nil && alert.CreatedAt.Equal(alert.UpdatedAt) {
+			_ = s.publisher.PublishDelayAnomaly(ctx, &a)
+		}
+		anomalies = append(anomalies, a)
+	}
+	return anomalies, nil
+}
+
+// checkAnomaly reports whether an hour's mean delay is anomalous for its baseline.
+func checkAnomaly(st domain.RouteDelayStat, b domain.DelayBaseline) (domain.DelayAnomaly, bool) {
+	if st.Samples < anomalyMinSamples || b.Hours < anomalyMinHours {
+		return domain.DelayAnomaly{}, false
+	}
+	excess := st.MeanDelay - b.Mean
+	sigma := excess / math.Max(b.StdDev, anomalyMinStdDev)
+	if sigma < anomalySigma || excess < anomalyMinExcess {
+		return domain.DelayAnomaly{}, false
+	}
+	return domain.DelayAnomaly{
+		RouteID:      st.RouteID,
+		AgencyID:     st.AgencyID,
+		RouteName:    st.RouteName,
+		Hour:         st.Hour,
+		MeanDelay:    st.MeanDelay,
+		BaselineMean: b.Mean,
+		BaselineStd:  b.StdDev,
+		Sigma:        sigma,
+	}, true
+}
+
+func anomalyAlert(a *domain.DelayAnomaly) *domain.ServiceAlert {
+	start := a.Hour
+	end := a.Hour.Add(time.Hour + anomalyAlertTTL)
+	return &domain.ServiceAlert{
+		AgencyID: a.AgencyID,
+		Source:   "anomaly",
+		SourceID: fmt.Sprintf("delay:%s:%s", a.RouteID, a.Hour.UTC().Format(time.RFC3339)),
+		Cause:    "UNKNOWN_CAUSE",
+		Effect:   "SIGNIFICANT_DELAYS",
+		Header: map[string]string{
+			"en": fmt.Sprintf("%s delays %.1fσ above normal", a.RouteName, a.Sigma),
+		},
+		Description: map[string]string{
+			"en": fmt.Sprintf("Average delay %s between %s and %s, usually %s.",
+				roundSeconds(a.MeanDelay), a.Hour.Format("15:04"), a.Hour.Add(time.Hour).Format("15:04"),
+				roundSeconds(a.BaselineMean)),
+		},
+		ActivePeriods: []domain.AlertPeriod{{Start: &start, End: &end}},
+		RouteIDs:      []string{a.RouteID},
+		StopIDs:       []string{},
+	}
+}
+
+func roundSeconds(secs float64) time.Duration {
+	return time.Duration(math.Round(secs)) * time.Second
+}
@@ -0,0 +1,104 @@
+package usecases_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock DelayStatsRepository ---
+
+type mockDelayStatsRepo struct {
+	current    []domain.RouteDelayStat
+	baselines  []domain.DelayBaseline
+	rolledUp   time.Time
+	baselineAt time.Time
+}
+
+func (m *mockDelayStatsRepo) RollupHour(ctx context.Context, hour time.Time) error {
+	m.rolledUp = hour
+	return nil
+}
+
+func (m *mockDelayStatsRepo) HourlyDelays(ctx context.Context, hour time.Time) ([]domain.RouteDelayStat, error) {
+	return m.current, nil
+}
+
+func (m *mockDelayStatsRepo) Baselines(ctx context.Context, hour, since time.Time) ([]domain.DelayBaseline, error) {
+	m.baselineAt = hour
+	return m.baselines, nil
+}
+
+// upsertingAlertRepo mimics the database: re-upserting an alert keeps its
+// creation time and bumps its update time.
+type upsertingAlertRepo struct {
+	mockAlertRepo
+	byKey map[string]domain.ServiceAlert
+}
+
+func (m *upsertingAlertRepo) Upsert(ctx context.Context, a *domain.ServiceAlert) error {
+	now := time.Now()
+	if prev, ok := m.byKey[a.SourceID]; ok {
+		a.ID, a.CreatedAt, a.UpdatedAt = prev.ID, prev.CreatedAt, now
+	} else {
+		a.ID, a.CreatedAt, a.UpdatedAt = "alert-"+a.SourceID, now, now
+	}
+	m.byKey[a.SourceID] = *a
+	return nil
+}
+
+func TestAnomalyService_Detect(t *testing.T) {
+	now := time.Date(2026, 3, 2, 9, 20, 0, 0, time.UTC)
+	hour := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+
+	stats := &mockDelayStatsRepo{
+		current: []domain.RouteDelayStat{
+			{RouteID: "l3", AgencyID: "metro", RouteName: "L3", Hour: hour, MeanDelay: 600, Samples: 40},
+			{RouteID: "l1", AgencyID: "metro", RouteName: "L1", Hour: hour, MeanDelay: 100, Samples: 40},
+			{RouteID: "l2", AgencyID: "metro", RouteName: "L2", Hour: hour, MeanDelay: 900, Samples: 3},
+			{RouteID: "new", AgencyID: "metro", RouteName: "L9", Hour: hour, MeanDelay: 900, Samples: 40},
+		},
+		baselines: []domain.DelayBaseline{
+			{RouteID: "l3", Mean: 90, StdDev: 60, Hours: 20},
+			{RouteID: "l1", Mean: 60, StdDev: 5, Hours: 20},
+			{RouteID: "l2", Mean: 60, StdDev: 5, Hours: 20},
+			{RouteID: "new", Mean: 60, StdDev: 5, Hours: 2},
+		},
+	}
+	alerts := &upsertingAlertRepo{byKey: map[string]domain.ServiceAlert{}}
+	pub := &mockPublisher{}
+	svc := usecases.NewAnomalyService(stats, alerts, pub)
+
+	anomalies, err := svc.Detect(context.Background(), now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !stats.rolledUp.Equal(hour) || !stats.baselineAt.Equal(hour) {
+		t.Errorf("expected last complete hour %v, got %v / %v", hour, stats.rolledUp, stats.baselineAt)
+	}
+	if len(anomalies) != 1 || anomalies[0].RouteID != "l3" {
+		t.Fatalf("expected only l3 to be anomalous, got %+v", anomalies)
+	}
+	if a := anomalies[0]; a.Sigma != 8.5 || a.AlertID == "" {
+		t.Errorf("unexpected anomaly %+v", a)
+	}
+
+	alert := alerts.byKey["delay:l3:2026-03-02T08:00:00Z"]
+	if alert.Source != "anomaly" || alert.Header["en"] != "L3 delays 8.5σ above normal" {
+		t.Errorf("unexpected alert %+v", alert)
+	}
+	if len(alert.RouteIDs) != 1 || alert.RouteIDs[0] != "l3" {
+		t.Errorf("expected alert on l3, got %v", alert.RouteIDs)
+	}
+
+	// A second run within the hour refreshes the alert but does not re-publish.
+	if _, err := svc.Detect(context.Background(), now.Add(10*time.Minute)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pub.anomalies) != 1 {
+		t.Errorf("expected one published anomaly, got %d", len(pub.anomalies))
+	}
+}
@@ -0,0 +1,125 @@
+package usecases
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+	"github.com/samirrijal/bilbopass/internal/pkg/parquet"
+)
+
+// Parquet columns of the archived tables. IDs are BilboPass UUIDs, as the
+// API returns them.
+var (
+	vehiclePositionColumns = []parquet.Column{
+		{Name: "time", Type: parquet.Timestamp},
+		{Name: "agency", Type: parquet.String, Optional: true},
+		{Name: "vehicle_id", Type: parquet.String},
+		{Name: "trip_id", Type: parquet.String, Optional: true},
+		{Name: "route_id", Type: parquet.String, Optional: true},
+		{Name: "stop_id", Type: parquet.String, Optional: true},
+		{Name: "lat", Type: parquet.Double},
+		{Name: "lon", Type: parquet.Double},
+		{Name: "bearing", Type: parquet.Double},
+		{Name: "speed", Type: parquet.Double},
+		{Name: "congestion_level", Type: parquet.Int32},
+		{Name: "occupancy_status", Type: parquet.Int32, Optional: true},
+	}
+	delayEventColumns = []parquet.Column{
+		{Name: "id", Type: parquet.String},
+		{Name: "time", Type: parquet.Timestamp},
+		{Name: "trip_id", Type: parquet.String, Optional: true},
+		{Name: "stop_id", Type: parquet.String, Optional: true},
+		{Name: "scheduled_arrival", Type: parquet.Timestamp},
+		{Name: "actual_arrival", Type: parquet.Timestamp},
+		{Name: "delay_seconds", Type: parquet.Int32},
+		{Name: "is_compensated", Type: parquet.Bool},
+		{Name: "compensation_sent_at", Type: parquet.Timestamp, Optional: true},
+	}
+)
+
+// ArchiveService copies the realtime hypertables to object storage as daily
+// Parquet partitions, so they can be analysed offline with DuckDB or Spark
+// instead of querying the production database.
+type ArchiveService struct {
+	repo  ports.ArchiveRepository
+	store ports.ObjectStore // nil disables archiving
+}
+
+// NewArchiveService creates a new ArchiveService. store may be nil, in which
+// case archiving is unavailable.
+func NewArchiveService(repo ports.ArchiveRepository, store ports.ObjectStore) *ArchiveService {
+	return &ArchiveService{repo: repo, store: store}
+}
+
+// Archive uploads the vehicle positions and delay events recorded on day, in
+// its location, to archive/<table>/date=YYYY-MM-DD/part-0.parquet. Archiving
+// a day again replaces its partitions.
+func (s *ArchiveService) Archive(ctx context.Context, day time.Time) ([]domain.ArchivePartition, error) {
+	if s.store == nil {
+		return nil, ErrUploadsUnavailable
+	}
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	to := from.AddDate(0, 0, 1)
+	date := from.Format("2006-01-02")
+
+	positions := func(w *parquet.Writer) error {
+		return s.repo.VehiclePositions(ctx, from, to, func(vp domain.VehiclePosition) error {
+			agency, _ := vp.Metadata["agency"].(string)
+			var occupancy any
+			if vp.OccupancyStatus != nil {
+				occupancy = *vp.OccupancyStatus
+			}
+			return w.Write(vp.Time, optional(agency), vp.VehicleID, optional(vp.TripID), optional(vp.RouteID),
+				optional(vp.StopID), vp.Location.Lat, vp.Location.Lon, vp.Bearing, vp.Speed,
+				vp.CongestionLevel, occupancy)
+		})
+	}
+	delays := func(w *parquet.Writer) error {
+		return s.repo.DelayEvents(ctx, from, to, func(e domain.DelayEvent) error {
+			var sent any
+			if e.CompensationSentAt != nil {
+				sent = *e.CompensationSentAt
+			}
+			return w.Write(e.ID, e.Time, optional(e.TripID), optional(e.StopID), e.ScheduledArrival,
+				e.ActualArrival, e.DelaySeconds, e.IsCompensated, sent)
+		})
+	}
+
+	var partitions []domain.ArchivePartition
+	for _, t := range []struct {
+		table   string
+		columns []parquet.Column
+		rows    func(*parquet.Writer) error
+	}{
+		{"vehicle_positions", vehiclePositionColumns, positions},
+		{"delay_events", delayEventColumns, delays},
+	} {
+		var buf bytes.Buffer
+		w := parquet.NewWriter(&buf, t.columns)
+		if err := t.rows(w); err != nil {
+			return partitions, fmt.Errorf("archive %s: %w", t.table, err)
+		}
+		if err := w.Close(); err != nil {
+			return partitions, fmt.Errorf("archive %s: %w", t.table, err)
+		}
+		key := fmt.Sprintf("archive/%s/date=%s/part-0.parquet", t.table, date)
+		url, err := s.store.Put(ctx, key, "application/vnd.apache.parquet", buf.Bytes())
+		if err != nil {
+			return partitions, fmt.Errorf("store %s: %w", t.table, err)
+		}
+		partitions = append(partitions, domain.ArchivePartition{Table: t.table, Date: date, Rows: w.Rows(), URL: url})
+	}
+	return partitions, nil
+}
+
+// optional maps an empty string to a null column value.
+func optional(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,87 @@
+package usecases_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock ArchiveRepository ---
+
+type mockArchiveRepo struct {
+	positions []domain.VehiclePosition
+	delays    []domain.DelayEvent
+	from, to  time.Time
+}
+
+func (m *mockArchiveRepo) VehiclePositions(ctx context.Context, from, to time.Time, fn func(domain.VehiclePosition) error) error {
+	m.from, m.to = from, to
+	for _, vp := range m.positions {
+		if err := fn(vp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *mockArchiveRepo) DelayEvents(ctx context.Context, from, to time.Time, fn func(domain.DelayEvent) error) error {
+	for _, e := range m.delays {
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestArchiveService_Archive(t *testing.T) {
+	ctx := context.Background()
+	madrid, _ := time.LoadLocation("Europe/Madrid")
+	day := time.Date(2026, 3, 2, 15, 0, 0, 0, madrid)
+	occupancy := 2
+	repo := &mockArchiveRepo{
+		positions: []domain.VehiclePosition{
+			{Time: day, VehicleID: "v1", TripID: "t1", RouteID: "r1", OccupancyStatus: &occupancy, Metadata: map[string]any{"agency": "bizkaibus"}},
+			{Time: day.Add(time.Minute), VehicleID: "v2"},
+		},
+		delays: []domain.DelayEvent{
+			{ID: "d1", Time: day, TripID: "t1", StopID: "s1", ScheduledArrival: day, ActualArrival: day.Add(4 * time.Minute), DelaySeconds: 240},
+		},
+	}
+	store := &mockObjectStore{}
+	svc := usecases.NewArchiveService(repo, store)
+
+	partitions, err := svc.Archive(ctx, day)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !repo.from.Equal(time.Date(2026, 3, 2, 0, 0, 0, 0, madrid)) || repo.to.Sub(repo.from) != 24*time.Hour {
+		t.Errorf("expected the local day to be read, got %v to %v", repo.from, repo.to)
+	}
+	if len(partitions) != 2 || partitions[0].Rows != 2 || partitions[1].Rows != 1 {
+		t.Fatalf("expected 2 positions and 1 delay event, got %+v", partitions)
+	}
+	wantKeys := []string{
+		"archive/vehicle_positions/date=2026-03-02/part-0.parquet",
+		"archive/delay_events/date=2026-03-02/part-0.parquet",
+	}
+	for i, key := range wantKeys {
+		if store.keys[i] != key || partitions[i].URL != "https://cdn.example/"+key {
+			t.Errorf("expected partition %s, got %s (%s)", key, store.keys[i], partitions[i].URL)
+		}
+	}
+	if !bytes.HasPrefix(store.body, []byte("PAR1")) || !bytes.HasSuffix(store.body, []byte("PAR1")) {
+		t.Error("expected a Parquet file")
+	}
+}
+
+func TestArchiveService_NoStore(t *testing.T) {
+	svc := usecases.NewArchiveService(&mockArchiveRepo{}, nil)
+	if _, err := svc.Archive(context.Background(), time.Now()); !errors.Is(err, usecases.ErrUploadsUnavailable) {
+		t.Errorf("expected ErrUploadsUnavailable, got %v", err)
+	}
+}
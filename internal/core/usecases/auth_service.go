@@ -0,0 +1,99 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+	"github.com/samirrijal/bilbopass/internal/pkg/auth"
+)
+
+// minPasswordLength is the shortest password accepted on registration.
+const minPasswordLength = 8
+
+// Auth errors surfaced to the API.
+var (
+	ErrEmailTaken         = errors.New("email is already registered")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrUserNotFound       = errors.New("user not found")
+)
+
+// AuthService registers riders and logs them in with bearer tokens.
+type AuthService struct {
+	users  ports.UserRepository
+	tokens *auth.JWT
+}
+
+// NewAuthService creates a new AuthService.
+func NewAuthService(users ports.UserRepository, tokens *auth.JWT) *AuthService {
+	return &AuthService{users: users, tokens: tokens}
+}
+
+// Register creates a rider account and returns a token for it.
+func (s *AuthService) Register(ctx context.Context, email, password, displayName string) (*domain.AuthToken, error) {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != strings.TrimSpace(email) {
+		return nil, fmt.Errorf("a valid email is required")
+	}
+	if len(password) < minPasswordLength {
+		return nil, fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("hash password: %w", err)
+	}
+
+	u := &domain.User{
+		Email:        strings.ToLower(addr.Address),
+		PasswordHash: string(hash),
+		DisplayName:  strings.TrimSpace(displayName),
+		Role:         "rider",
+	}
+	created, err := s.users.Create(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, ErrEmailTaken
+	}
+	return s.issue(u)
+}
+
+// Login checks a rider's credentials and returns a fresh token.
+func (s *AuthService) Login(ctx context.Context, email, password string) (*domain.AuthToken, error) {
+	u, err := s.users.GetByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		return nil, err
+	}
+	if u == nil || bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return s.issue(u)
+}
+
+// Me returns the account of an authenticated user.
+func (s *AuthService) Me(ctx context.Context, userID string) (*domain.User, error) {
+	u, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrUserNotFound
+	}
+	return u, nil
+}
+
+func (s *AuthService) issue(u *domain.User) (*domain.AuthToken, error) {
+	token, expires, err := s.tokens.Issue(u.ID, u.Role, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("issue token: %w", err)
+	}
+	return &domain.AuthToken{Token: token, TokenType: "Bearer", ExpiresAt: expires, User: u}, nil
+}
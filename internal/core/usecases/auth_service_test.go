@@ -0,0 +1,119 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+	"github.com/samirrijal/bilbopass/internal/pkg/auth"
+)
+
+// --- Mock UserRepository ---
+
+type mockUserRepo struct {
+	users map[string]*domain.User // email -> user
+}
+
+func (m *mockUserRepo) Create(ctx context.Context, u *domain.User) (bool, error) {
+	if m.users == nil {
+		m.users = map[string]*domain.User{}
+	}
+	if _, ok := m.users[u.Email]; ok {
+		return false, nil
+	}
+	u.ID = "user-" + u.Email
+	m.users[u.Email] = u
+	return true, nil
+}
+
+func (m *mockUserRepo) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	return m.users[email], nil
+}
+
+func (m *mockUserRepo) GetByID(ctx context.Context, id string) (*domain.User, error) {
+	for _, u := range m.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func TestAuthService_RegisterAndLogin(t *testing.T) {
+	tokens := auth.NewJWT("test-secret", time.Hour)
+	svc := usecases.NewAuthService(&mockUserRepo{}, tokens)
+	ctx := context.Background()
+
+	reg, err := svc.Register(ctx, "Ane@Example.eus", "correct horse", "Ane")
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if reg.User.Email != "ane@example.eus" || reg.User.Role != "rider" || reg.TokenType != "Bearer" {
+		t.Errorf("unexpected registration %+v / %+v", reg, reg.User)
+	}
+	if strings.Contains(reg.User.PasswordHash, "correct horse") {
+		t.Error("password stored in clear")
+	}
+	claims, err := tokens.Verify(reg.Token, time.Now())
+	if err != nil || claims.Subject != reg.User.ID {
+		t.Errorf("expected token for %s, got %+v, %v", reg.User.ID, claims, err)
+	}
+
+	if _, err := svc.Register(ctx, "ane@example.eus", "another pass", ""); !errors.Is(err, usecases.ErrEmailTaken) {
+		t.Errorf("expected ErrEmailTaken, got %v", err)
+	}
+
+	login, err := svc.Login(ctx, " ANE@example.eus", "correct horse")
+	if err != nil || login.User.ID != reg.User.ID {
+		t.Fatalf("login: %+v, %v", login, err)
+	}
+	if _, err := svc.Login(ctx, "ane@example.eus", "wrong password"); !errors.Is(err, usecases.ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials for wrong password, got %v", err)
+	}
+	if _, err := svc.Login(ctx, "nobody@example.eus", "correct horse"); !errors.Is(err, usecases.ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials for unknown email, got %v", err)
+	}
+
+	me, err := svc.Me(ctx, reg.User.ID)
+	if err != nil || me.Email != "ane@example.eus" {
+		t.Errorf("me: %+v, %v", me, err)
+	}
+}
+
+func TestAuthService_RegisterValidation(t *testing.T) {
+	svc := usecases.NewAuthService(&mockUserRepo{}, auth.NewJWT("test-secret", time.Hour))
+	for _, tc := range []struct{ email, password string }{
+		{"not-an-email", "long enough"},
+		{"Ane <ane@example.eus>", "long enough"},
+		{"ane@example.eus", "short"},
+	} {
+		if _, err := svc.Register(context.Background(), tc.email, tc.password, ""); err == nil {
+			t.Errorf("expected validation error for %q / %q", tc.email, tc.password)
+		}
+	}
+}
+
+func TestJWT_RejectsTamperedAndExpiredTokens(t *testing.T) {
+	tokens := auth.NewJWT("test-secret", time.Minute)
+	now := time.Now()
+	token, _, err := tokens.Issue("u1", "rider", now)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+
+	if _, err := auth.NewJWT("other-secret", time.Minute).Verify(token, now); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken for wrong secret, got %v", err)
+	}
+	parts := strings.Split(token, ".")
+	forged, _, _ := auth.NewJWT("other-secret", time.Minute).Issue("admin", "admin", now)
+	if _, err := tokens.Verify(parts[0]+"."+strings.Split(forged, ".")[1]+"."+parts[2], now); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken for swapped payload, got %v", err)
+	}
+	if _, err := tokens.Verify(token, now.Add(2*time.Minute)); !errors.Is(err, auth.ErrExpiredToken) {
+		t.Errorf("expected ErrExpiredToken, got %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package usecases
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+var (
+	// ErrUploadsUnavailable is returned when no object storage is configured.
+	ErrUploadsUnavailable = errors.New("asset uploads are not available")
+	ErrInvalidAsset       = errors.New("invalid image")
+)
+
+// maxBrandingAsset caps uploaded logos and route icons.
+const maxBrandingAsset = 512 << 10
+
+// brandingAssetTypes maps the accepted image types to file extensions.
+var brandingAssetTypes = map[string]string{
+	"image/png":     "png",
+	"image/svg+xml": "svg",
+	"image/webp":    "webp",
+}
+
+// BrandingService manages agency logos, colors and route icons.
+type BrandingService struct {
+	branding ports.BrandingRepository
+	agencies ports.AgencyRepository
+	routes   ports.RouteRepository
+	store    ports.ObjectStore // nil disables uploads
+}
+
+// NewBrandingService creates a new BrandingService. store may be nil, in
+// which case branding can be read and recolored but not uploaded.
+func NewBrandingService(branding ports.BrandingRepository, agencies ports.AgencyRepository, routes ports.RouteRepository, store ports.ObjectStore) *BrandingService {
+	return &BrandingService{branding: branding, agencies: agencies, routes: routes, store: store}
+}
+
+// Get returns the agency's branding with a badge for each of its routes.
+// Agencies without branding get badges from their GTFS route colors only.
+func (s *BrandingService) Get(ctx context.Context, agencySlug string) (*domain.AgencyBranding, error) {
+	agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+	if err != nil || agency == nil {
+		return nil, ErrAgencyNotFound
+	}
+	b, err := s.load(ctx, agency.ID)
+	if err != nil {
+		return nil, err
+	}
+	routes, err := s.routes.ListByAgency(ctx, agency.ID)
+	if err != nil {
+		return nil, err
+	}
+	icons, err := s.branding.RouteIcons(ctx, agency.ID)
+	if err != nil {
+		return nil, err
+	}
+	b.Routes = make([]domain.RouteBadge, 0, len(routes))
+	for _, rt := range routes {
+		b.Routes = append(b.Routes, domain.RouteBadge{
+			RouteID:   rt.ID,
+			ShortName: rt.ShortName,
+			Color:     rt.Color,
+			TextColor: rt.TextColor,
+			IconURL:   icons[rt.ID],
+		})
+	}
+	return b, nil
+}
+
+// UpdateColors validates and replaces the agency's brand colors. Empty
+// colors are cleared; a leading # is dropped.
+func (s *BrandingService) UpdateColors(ctx context.Context, agencySlug string, colors *domain.AgencyBranding) (*domain.AgencyBranding, error) {
+	fields := []struct {
+		name  string
+		value *string
+	}{
+		{"primary_color", &colors.PrimaryColor},
+		{"secondary_color", &colors.SecondaryColor},
+		{"text_color", &colors.TextColor},
+	}
+	for _, f := range fields {
+		*f.value = strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(*f.value), "#"))
+		if *f.value != "" && !validHexColor(*f.value) {
+			return nil, fmt.Errorf("%s must be a RRGGBB hex color", f.name)
+		}
+	}
+
+	agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+	if err != nil || agency == nil {
+		return nil, ErrAgencyNotFound
+	}
+	b, err := s.load(ctx, agency.ID)
+	if err != nil {
+		return nil, err
+	}
+	b.PrimaryColor, b.SecondaryColor, b.TextColor = colors.PrimaryColor, colors.SecondaryColor, colors.TextColor
+	if err := s.branding.Save(ctx, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// UploadLogo stores a new logo for the agency and returns its URL.
+func (s *BrandingService) UploadLogo(ctx context.Context, agencySlug, contentType string, body []byte) (string, error) {
+	if err := s.checkAsset(contentType, body); err != nil {
+		return "", err
+	}
+	agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+	if err != nil || agency == nil {
+		return "", ErrAgencyNotFound
+	}
+	b, err := s.load(ctx, agency.ID)
+	if err != nil {
+		return "", err
+	}
+	url, err := s.store.Put(ctx, assetKey(agency.Slug, "logo", contentType, body), contentType, body)
+	if err != nil {
+		return "", fmt.Errorf("store logo: %w", err)
+	}
+	b.LogoURL = url
+	if err := s.branding.Save(ctx, b); err != nil {
+		return "", err
+	}
+	return url, nil
+}
+
+// UploadRouteIcon stores a new line badge icon for one of the agency's
+// routes and returns its URL.
+func (s *BrandingService) UploadRouteIcon(ctx context.Context, agencySlug, routeID, contentType string, body []byte) (string, error) {
+	if err := s.checkAsset(contentType, body); err != nil {
+		return "", err
+	}
+	agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+	if err != nil || agency == nil {
+		return "", ErrAgencyNotFound
+	}
+	route, err := s.routes.GetByID(ctx, routeID)
+	if err != nil {
+		return "", err
+	}
+	if route == nil || route.AgencyID != agency.ID {
+		return "", ErrRouteNotFound
+	}
+	url, err := s.store.Put(ctx, assetKey(agency.Slug, "routes/"+route.ID, contentType, body), contentType, body)
+	if err != nil {
+		return "", fmt.Errorf("store route icon: %w", err)
+	}
+	if err := s.branding.SetRouteIcon(ctx, route.ID, url); err != nil {
+		return "", err
+	}
+	return url, nil
+}
+
+// load returns the agency's saved branding, or an empty one.
+func (s *BrandingService) load(ctx context.Context, agencyID string) (*domain.AgencyBranding, error) {
+	b, err := s.branding.Get(ctx, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		b = &domain.AgencyBranding{AgencyID: agencyID}
+	}
+	return b, nil
+}
+
+func (s *BrandingService) checkAsset(contentType string, body []byte) error {
+	if s.store == nil {
+		return ErrUploadsUnavailable
+	}
+	if _, ok := brandingAssetTypes[contentType]; !ok {
+		return fmt.Errorf("%w: content type must be image/png, image/svg+xml or image/webp", ErrInvalidAsset)
+	}
+	if len(body) == 0 || len(body) > maxBrandingAsset {
+		return fmt.Errorf("%w: size must be between 1 byte and %d KiB", ErrInvalidAsset, maxBrandingAsset>>10)
+	}
+	return nil
+}
+
+// assetKey names an asset by its content, so a new upload gets a new URL
+// and clients may cache every URL forever.
+func assetKey(agencySlug, name, contentType string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf("branding/%s/%s-%s.%s", agencySlug, name, hex.EncodeToString(sum[:6]), brandingAssetTypes[contentType])
+}
+
+func validHexColor(c string) bool {
+	if len(c) != 6 {
+		return false
+	}
+	for _, r := range c {
+		if !strings.ContainsRune("0123456789ABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,166 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock BrandingRepository ---
+
+type mockBrandingRepo struct {
+	branding map[string]domain.AgencyBranding // by agency ID
+	icons    map[string]string                // by route ID
+}
+
+func (m *mockBrandingRepo) Get(ctx context.Context, agencyID string) (*domain.AgencyBranding, error) {
+	b, ok := m.branding[agencyID]
+	if !ok {
+		return nil, nil
+	}
+	return &b, nil
+}
+
+func (m *mockBrandingRepo) Save(ctx context.Context, b *domain.AgencyBranding) error {
+	if m.branding == nil {
+		m.branding = map[string]domain.AgencyBranding{}
+	}
+	m.branding[b.AgencyID] = *b
+	return nil
+}
+
+func (m *mockBrandingRepo) RouteIcons(ctx context.Context, agencyID string) (map[string]string, error) {
+	return m.icons, nil
+}
+
+func (m *mockBrandingRepo) SetRouteIcon(ctx context.Context, routeID, url string) error {
+	if m.icons == nil {
+		m.icons = map[string]string{}
+	}
+	m.icons[routeID] = url
+	return nil
+}
+
+// --- Mock ObjectStore ---
+
+type mockObjectStore struct {
+	keys []string
+	body []byte // last stored
+}
+
+func (m *mockObjectStore) Put(ctx context.Context, key, contentType string, body []byte) (string, error) {
+	m.keys = append(m.keys, key)
+	m.body = body
+	return "https://cdn.example/" + key, nil
+}
+
+func newBrandingAgencies() *mockAgencyRepo {
+	return &mockAgencyRepo{
+		getBySlugFn: func(ctx context.Context, slug string) (*domain.Agency, error) {
+			if slug != "metro_bilbao" {
+				return nil, nil
+			}
+			return &domain.Agency{ID: "a1", Slug: slug}, nil
+		},
+	}
+}
+
+func newBrandingRoutes() *mockRouteRepo {
+	routes := map[string]domain.Route{
+		"r1": {ID: "r1", AgencyID: "a1", ShortName: "L1", Color: "E30613", TextColor: "FFFFFF"},
+		"r2": {ID: "r2", AgencyID: "a1", ShortName: "L2"},
+		"r9": {ID: "r9", AgencyID: "a2", ShortName: "A1"},
+	}
+	return &mockRouteRepo{
+		getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
+			rt, ok := routes[id]
+			if !ok {
+				return nil, nil
+			}
+			return &rt, nil
+		},
+		listByAgencyFn: func(ctx context.Context, agencyID string) ([]domain.Route, error) {
+			return []domain.Route{routes["r1"], routes["r2"]}, nil
+		},
+	}
+}
+
+func TestBrandingService_Get(t *testing.T) {
+	repo := &mockBrandingRepo{
+		branding: map[string]domain.AgencyBranding{"a1": {AgencyID: "a1", PrimaryColor: "E30613"}},
+		icons:    map[string]string{"r1": "https://cdn.example/l1.svg"},
+	}
+	svc := usecases.NewBrandingService(repo, newBrandingAgencies(), newBrandingRoutes(), nil)
+
+	b, err := svc.Get(context.Background(), "metro_bilbao")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b.PrimaryColor != "E30613" || len(b.Routes) != 2 {
+		t.Fatalf("unexpected branding: %+v", b)
+	}
+	if b.Routes[0].IconURL != "https://cdn.example/l1.svg" || b.Routes[0].Color != "E30613" {
+		t.Errorf("expected L1 badge with icon and color, got %+v", b.Routes[0])
+	}
+	if b.Routes[1].IconURL != "" || b.Routes[1].ShortName != "L2" {
+		t.Errorf("expected L2 badge without icon, got %+v", b.Routes[1])
+	}
+	if _, err := svc.Get(context.Background(), "nope"); !errors.Is(err, usecases.ErrAgencyNotFound) {
+		t.Errorf("expected ErrAgencyNotFound, got %v", err)
+	}
+}
+
+func TestBrandingService_UpdateColors(t *testing.T) {
+	repo := &mockBrandingRepo{
+		branding: map[string]domain.AgencyBranding{"a1": {AgencyID: "a1", LogoURL: "https://cdn.example/logo.svg"}},
+	}
+	svc := usecases.NewBrandingService(repo, newBrandingAgencies(), newBrandingRoutes(), nil)
+	ctx := context.Background()
+
+	b, err := svc.UpdateColors(ctx, "metro_bilbao", &domain.AgencyBranding{PrimaryColor: "#e30613", TextColor: "ffffff"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b.PrimaryColor != "E30613" || b.TextColor != "FFFFFF" || b.LogoURL != "https://cdn.example/logo.svg" {
+		t.Errorf("expected normalized colors and the logo kept, got %+v", b)
+	}
+	if _, err := svc.UpdateColors(ctx, "metro_bilbao", &domain.AgencyBranding{PrimaryColor: "red"}); err == nil {
+		t.Error("expected an invalid color to be rejected")
+	}
+}
+
+func TestBrandingService_Uploads(t *testing.T) {
+	repo := &mockBrandingRepo{}
+	store := &mockObjectStore{}
+	svc := usecases.NewBrandingService(repo, newBrandingAgencies(), newBrandingRoutes(), store)
+	ctx := context.Background()
+	png := []byte("\x89PNG\r\n\x1a\n")
+
+	url, err := svc.UploadLogo(ctx, "metro_bilbao", "image/png", png)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(url, "https://cdn.example/branding/metro_bilbao/logo-") || repo.branding["a1"].LogoURL != url {
+		t.Errorf("expected the logo URL to be saved, got %q", url)
+	}
+
+	url, err = svc.UploadRouteIcon(ctx, "metro_bilbao", "r1", "image/png", png)
+	if err != nil || repo.icons["r1"] != url {
+		t.Fatalf("expected the route icon to be saved, got %q, %v", url, err)
+	}
+	if _, err := svc.UploadRouteIcon(ctx, "metro_bilbao", "r9", "image/png", png); !errors.Is(err, usecases.ErrRouteNotFound) {
+		t.Errorf("expected ErrRouteNotFound for another agency's route, got %v", err)
+	}
+	if _, err := svc.UploadLogo(ctx, "metro_bilbao", "image/gif", png); !errors.Is(err, usecases.ErrInvalidAsset) {
+		t.Errorf("expected ErrInvalidAsset for a GIF, got %v", err)
+	}
+
+	noStore := usecases.NewBrandingService(repo, newBrandingAgencies(), newBrandingRoutes(), nil)
+	if _, err := noStore.UploadLogo(ctx, "metro_bilbao", "image/png", png); !errors.Is(err, usecases.ErrUploadsUnavailable) {
+		t.Errorf("expected ErrUploadsUnavailable without storage, got %v", err)
+	}
+}
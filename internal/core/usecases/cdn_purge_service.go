@@ -0,0 +1,173 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+const (
+	// maxPurgeKeys is how many surrogate keys one purge request carries,
+	// Fastly's limit; more are sent in several.
+	maxPurgeKeys = 256
+	// purgeChangesPage is how many changes of each kind are read at a time.
+	purgeChangesPage = 2000
+)
+
+var (
+	// ErrCDNUnavailable is returned when no CDN is configured.
+	ErrCDNUnavailable = errors.New("cdn purging is not configured")
+	// ErrInvalidPurge is returned for malformed surrogate keys.
+	ErrInvalidPurge = errors.New("invalid purge")
+)
+
+// Surrogate key prefixes, for StopKey, RouteKey and AgencyKey.
+var surrogateKeyPrefixes = []string{"stop:", "route:", "agency:"}
+
+// StopKey is the surrogate key of responses showing the stop.
+func StopKey(id string) string { return "stop:" + id }
+
+// RouteKey is the surrogate key of responses showing the route.
+func RouteKey(id string) string { return "route:" + id }
+
+// AgencyKey is the surrogate key of responses showing the agency.
+func AgencyKey(slug string) string { return "agency:" + slug }
+
+// CDNPurgeService evicts API responses from the CDN when the data they show
+// changes, so the CDN may keep static data long. Responses are tagged with
+// surrogate keys naming the stops, routes and agencies they show.
+type CDNPurgeService struct {
+	purger   ports.CDNPurger
+	sync     ports.SyncRepository
+	agencies ports.AgencyRepository
+}
+
+// NewCDNPurgeService creates a new CDNPurgeService. purger may be nil,
+// leaving purging off.
+func NewCDNPurgeService(purger ports.CDNPurger, sync ports.SyncRepository, agencies ports.AgencyRepository) *CDNPurgeService {
+	return &CDNPurgeService{purger: purger, sync: sync, agencies: agencies}
+}
+
+// Enabled reports whether a CDN is configured.
+func (s *CDNPurgeService) Enabled() bool {
+	return s != nil && s.purger != nil
+}
+
+// Purge evicts the responses tagged with any of keys and returns the keys
+// purged, without duplicates.
+func (s *CDNPurgeService) Purge(ctx context.Context, keys []string) ([]string, error) {
+	if !s.Enabled() {
+		return nil, ErrCDNUnavailable
+	}
+	seen := make(map[string]bool, len(keys))
+	unique := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if !validSurrogateKey(k) {
+			return nil, fmt.Errorf("%w: key %q must be stop:<id>, route:<id> or agency:<slug>", ErrInvalidPurge, k)
+		}
+		if !seen[k] {
+			seen[k] = true
+			unique = append(unique, k)
+		}
+	}
+	for start := 0; start < len(unique); start += maxPurgeKeys {
+		if err := s.purger.Purge(ctx, unique[start:min(start+maxPurgeKeys, len(unique))]); err != nil {
+			return nil, err
+		}
+	}
+	return unique, nil
+}
+
+// PurgeChanges evicts the responses showing the agency's stops, routes and
+// alerts that changed after since and up to now, as after a feed is applied
+// or alerts are polled, and the agency's own when its stops or routes
+// changed. It returns the keys purged; with no changes nothing is sent.
+func (s *CDNPurgeService) PurgeChanges(ctx context.Context, agencySlug string, since, now time.Time) ([]string, error) {
+	if !s.Enabled() {
+		return nil, ErrCDNUnavailable
+	}
+	agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+	if err != nil {
+		return nil, err
+	}
+	if agency == nil {
+		return nil, ErrAgencyNotFound
+	}
+
+	var keys []string
+	after := domain.SyncPosition{At: since}
+	for {
+		changes, err := s.sync.StopChanges(ctx, agency.ID, after, now, purgeChangesPage)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range changes {
+			keys = append(keys, StopKey(c.ID))
+		}
+		if len(changes) < purgeChangesPage {
+			break
+		}
+		after = changes[len(changes)-1].SyncPosition
+	}
+	after = domain.SyncPosition{At: since}
+	for {
+		changes, err := s.sync.RouteChanges(ctx, agency.ID, after, now, purgeChangesPage)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range changes {
+			keys = append(keys, RouteKey(c.ID))
+		}
+		if len(changes) < purgeChangesPage {
+			break
+		}
+		after = changes[len(changes)-1].SyncPosition
+	}
+	if len(keys) > 0 {
+		keys = append(keys, AgencyKey(agency.Slug))
+	}
+	// Alerts show on the pages of the stops and routes they affect; those of
+	// removed alerts are unknown, so they wait out their TTL.
+	after = domain.SyncPosition{At: since}
+	for {
+		changes, err := s.sync.AlertChanges(ctx, agency.ID, after, now, purgeChangesPage)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range changes {
+			if c.Alert == nil {
+				continue
+			}
+			for _, id := range c.Alert.StopIDs {
+				keys = append(keys, StopKey(id))
+			}
+			for _, id := range c.Alert.RouteIDs {
+				keys = append(keys, RouteKey(id))
+			}
+		}
+		if len(changes) < purgeChangesPage {
+			break
+		}
+		after = changes[len(changes)-1].SyncPosition
+	}
+	if len(keys) == 0 {
+		return []string{}, nil
+	}
+	return s.Purge(ctx, keys)
+}
+
+// validSurrogateKey reports whether key is a known kind of key naming one
+// entity; keys are sent space-separated, so they hold no spaces.
+func validSurrogateKey(key string) bool {
+	for _, prefix := range surrogateKeyPrefixes {
+		if rest, ok := strings.CutPrefix(key, prefix); ok {
+			return rest != "" && len(key) <= 256 && !strings.ContainsAny(rest, " \t\r\n,")
+		}
+	}
+	return false
+}
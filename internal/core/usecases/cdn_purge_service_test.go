@@ -0,0 +1,134 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock CDNPurger ---
+
+type mockPurger struct {
+	requests [][]string
+	err      error
+}
+
+func (m *mockPurger) Purge(ctx context.Context, keys []string) error {
+	m.requests = append(m.requests, keys)
+	return m.err
+}
+
+// --- Tests ---
+
+func TestCDNPurgeService_Purge(t *testing.T) {
+	ctx := context.Background()
+	purger := &mockPurger{}
+	svc := usecases.NewCDNPurgeService(purger, &mockSyncRepo{}, newBrandingAgencies())
+
+	purged, err := svc.Purge(ctx, []string{"stop:s1", "route:r1", "stop:s1", "agency:metro_bilbao"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"stop:s1", "route:r1", "agency:metro_bilbao"}; !slices.Equal(purged, want) {
+		t.Errorf("expected %v, got %v", want, purged)
+	}
+
+	for _, key := range []string{"trip:t1", "stop:", "stop:a b", "s1"} {
+		if _, err := svc.Purge(ctx, []string{key}); !errors.Is(err, usecases.ErrInvalidPurge) {
+			t.Errorf("%q: expected ErrInvalidPurge, got %v", key, err)
+		}
+	}
+	if len(purger.requests) != 1 {
+		t.Errorf("expected invalid keys not to be sent, got %d requests", len(purger.requests))
+	}
+
+	// Keys are sent in batches the CDN accepts.
+	purger.requests = nil
+	keys := make([]string, 600)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("stop:s%d", i)
+	}
+	if _, err := svc.Purge(ctx, keys); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(purger.requests) != 3 || len(purger.requests[0]) != 256 || len(purger.requests[2]) != 88 {
+		t.Errorf("expected batches of 256, 256 and 88, got %d requests", len(purger.requests))
+	}
+
+	purger.err = errors.New("cdn down")
+	if _, err := svc.Purge(ctx, []string{"stop:s1"}); err == nil {
+		t.Error("expected the purger's error")
+	}
+}
+
+func TestCDNPurgeService_Unavailable(t *testing.T) {
+	svc := usecases.NewCDNPurgeService(nil, &mockSyncRepo{}, newBrandingAgencies())
+	if svc.Enabled() {
+		t.Error("expected purging off without a purger")
+	}
+	if _, err := svc.Purge(context.Background(), []string{"stop:s1"}); !errors.Is(err, usecases.ErrCDNUnavailable) {
+		t.Errorf("expected ErrCDNUnavailable, got %v", err)
+	}
+	var none *usecases.CDNPurgeService
+	if none.Enabled() {
+		t.Error("expected a nil service to be off")
+	}
+}
+
+func TestCDNPurgeService_PurgeChanges(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2026, 3, 2, 4, 0, 0, 0, time.UTC)
+	at := func(d time.Duration, id string) domain.SyncPosition {
+		return domain.SyncPosition{At: t0.Add(d), ID: id}
+	}
+	sync := &mockSyncRepo{
+		stops: []domain.StopChange{
+			{SyncPosition: at(-time.Hour, "s0")}, // before the feed
+			{SyncPosition: at(time.Minute, "s1"), Stop: &domain.Stop{ID: "s1"}},
+			{SyncPosition: at(2*time.Minute, "s2")}, // deleted
+		},
+		routes: []domain.RouteChange{
+			{SyncPosition: at(time.Minute, "r1"), Route: &domain.Route{ID: "r1"}},
+		},
+		alerts: []domain.AlertChange{
+			{SyncPosition: at(3*time.Minute, "al1"), Alert: &domain.ServiceAlert{ID: "al1", StopIDs: []string{"s1", "s3"}, RouteIDs: []string{"r2"}}},
+			{SyncPosition: at(3*time.Minute, "al2")}, // removed
+		},
+	}
+	purger := &mockPurger{}
+	svc := usecases.NewCDNPurgeService(purger, sync, newBrandingAgencies())
+
+	purged, err := svc.PurgeChanges(ctx, "metro_bilbao", t0, t0.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"stop:s1", "stop:s2", "route:r1", "agency:metro_bilbao", "stop:s3", "route:r2"}
+	if !slices.Equal(purged, want) {
+		t.Errorf("expected %v, got %v", want, purged)
+	}
+
+	// Alert changes alone leave the agency's responses be.
+	purged, _ = svc.PurgeChanges(ctx, "metro_bilbao", t0.Add(150*time.Second), t0.Add(time.Hour))
+	if want := []string{"stop:s1", "stop:s3", "route:r2"}; !slices.Equal(purged, want) {
+		t.Errorf("expected %v, got %v", want, purged)
+	}
+
+	// Without changes nothing is sent.
+	purger.requests = nil
+	if purged, err := svc.PurgeChanges(ctx, "metro_bilbao", t0.Add(time.Hour), t0.Add(2*time.Hour)); err != nil || len(purged) != 0 {
+		t.Errorf("expected nothing purged, got %v, %v", purged, err)
+	}
+	if len(purger.requests) != 0 {
+		t.Errorf("expected no requests, got %v", purger.requests)
+	}
+
+	if _, err := svc.PurgeChanges(ctx, "nope", t0, t0.Add(time.Hour)); !errors.Is(err, usecases.ErrAgencyNotFound) {
+		t.Errorf("expected ErrAgencyNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+// Challenge errors surfaced to the API.
+var (
+	ErrChallengeNotFound   = errors.New("challenge not found")
+	ErrChallengeClosed     = errors.New("challenge is not running")
+	ErrNotEnrolled         = errors.New("not enrolled in challenge")
+	ErrChallengeIncomplete = errors.New("challenge target not reached")
+	ErrRewardClaimed       = errors.New("reward already claimed")
+	ErrRewardsUnavailable  = errors.New("rewards are not available")
+)
+
+// ChallengeService manages sustainability challenges. Progress is computed
+// from the rider's trip history and rewards are issued as affiliate coupons.
+type ChallengeService struct {
+	challenges ports.ChallengeRepository
+	history    ports.HistoryRepository
+	rewards    *CompensationService
+}
+
+// NewChallengeService creates a new ChallengeService. rewards may be nil, in
+// which case progress is tracked but rewards cannot be claimed.
+func NewChallengeService(challenges ports.ChallengeRepository, history ports.HistoryRepository, rewards *CompensationService) *ChallengeService {
+	return &ChallengeService{challenges: challenges, history: history, rewards: rewards}
+}
+
+// Create validates and stores a new challenge.
+func (s *ChallengeService) Create(ctx context.Context, ch *domain.Challenge) error {
+	switch ch.Metric {
+	case domain.ChallengeMetricTrips, domain.ChallengeMetricDistance, domain.ChallengeMetricCO2:
+	default:
+		return fmt.Errorf("metric must be trips, distance_meters or co2_saved_grams")
+	}
+	if ch.Slug == "" || ch.Title == "" {
+		return fmt.Errorf("slug and title are required")
+	}
+	if ch.Target <= 0 {
+		return fmt.Errorf("target must be positive")
+	}
+	if !ch.EndsAt.After(ch.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	return s.challenges.Create(ctx, ch)
+}
+
+// List returns the challenges running at now with the user's progress.
+func (s *ChallengeService) List(ctx context.Context, userID string, now time.Time) ([]domain.ChallengeProgress, error) {
+	challenges, err := s.challenges.ListActive(ctx, now)
+	if err != nil {
+		return nil, fmt.Errorf("list challenges: %w", err)
+	}
+	enrollments, err := s.challenges.ListEnrollments(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list enrollments: %w", err)
+	}
+	byChallenge := make(map[string]domain.ChallengeEnrollment, len(enrollments))
+	for _, e := range enrollments {
+		byChallenge[e.ChallengeID] = e
+	}
+
+	out := make([]domain.ChallengeProgress, 0, len(challenges))
+	for _, ch := range challenges {
+		p := domain.ChallengeProgress{Challenge: ch}
+		if e, ok := byChallenge[ch.ID]; ok {
+			p.Enrolled = true
+			p.RewardedAt = e.RewardedAt
+			p.RewardCode = e.RewardCode
+			if p.Progress, err = s.progress(ctx, userID, &ch); err != nil {
+				return nil, err
+			}
+			p.Completed = p.Progress >= ch.Target
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// Enroll signs the user up for a running challenge. Enrolling twice is a no-op.
+func (s *ChallengeService) Enroll(ctx context.Context, userID, challengeID string, now time.Time) (*domain.ChallengeProgress, error) {
+	ch, err := s.challenges.GetByID(ctx, challengeID)
+	if err != nil || ch == nil {
+		return nil, ErrChallengeNotFound
+	}
+	if !ch.Active || now.Before(ch.StartsAt) || !now.Before(ch.EndsAt) {
+		return nil, ErrChallengeClosed
+	}
+	if err := s.challenges.Enroll(ctx, challengeID, userID); err != nil {
+		return nil, fmt.Errorf("enroll: %w", err)
+	}
+
+	progress, err := s.progress(ctx, userID, ch)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.ChallengeProgress{
+		Challenge: *ch,
+		Enrolled:  true,
+		Progress:  progress,
+		Completed: progress >= ch.Target,
+	}, nil
+}
+
+// Claim issues the reward coupon for a completed challenge at the affiliate
+// nearest to lat/lon. Rewards can be claimed after the challenge ends.
+func (s *ChallengeService) Claim(ctx context.Context, userID, challengeID string, lat, lon float64) (*domain.Compensation, error) {
+	if s.rewards == nil {
+		return nil, ErrRewardsUnavailable
+	}
+	ch, err := s.challenges.GetByID(ctx, challengeID)
+	if err != nil || ch == nil {
+		return nil, ErrChallengeNotFound
+	}
+	enrollment, err := s.challenges.GetEnrollment(ctx, challengeID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get enrollment: %w", err)
+	}
+	if enrollment == nil {
+		return nil, ErrNotEnrolled
+	}
+	if enrollment.RewardedAt != nil {
+		return nil, ErrRewardClaimed
+	}
+
+	progress, err := s.progress(ctx, userID, ch)
+	if err != nil {
+		return nil, err
+	}
+	if progress < ch.Target {
+		return nil, ErrChallengeIncomplete
+	}
+
+	comp, err := s.rewards.IssueReward(ctx, userID, lat, lon, map[string]any{
+		"reason":       "challenge",
+		"challenge_id": ch.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.challenges.MarkRewarded(ctx, challengeID, userID, comp.Code); err != nil {
+		return nil, fmt.Errorf("mark rewarded: %w", err)
+	}
+	return comp, nil
+}
+
+// progress sums the challenge metric over journeys taken during its window.
+func (s *ChallengeService) progress(ctx context.Context, userID string, ch *domain.Challenge) (float64, error) {
+	journeys, err := s.history.ListByUser(ctx, userID, ch.StartsAt, ch.EndsAt)
+	if err != nil {
+		return 0, fmt.Errorf("list journeys: %w", err)
+	}
+
+	var total float64
+	for _, j := range journeys {
+		if ch.RouteType != nil && j.RouteType != *ch.RouteType {
+			continue
+		}
+		switch ch.Metric {
+		case domain.ChallengeMetricTrips:
+			total++
+		case domain.ChallengeMetricDistance:
+			total += j.DistanceMeters
+		case domain.ChallengeMetricCO2:
+			total += j.CO2SavedGrams
+		}
+	}
+	return total, nil
+}
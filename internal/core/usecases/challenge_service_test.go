@@ -0,0 +1,273 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock ChallengeRepository ---
+
+type mockChallengeRepo struct {
+	challenges  map[string]*domain.Challenge
+	enrollments map[string]*domain.ChallengeEnrollment
+}
+
+func (m *mockChallengeRepo) Create(ctx context.Context, ch *domain.Challenge) error {
+	ch.ID = "c-" + ch.Slug
+	m.challenges[ch.ID] = ch
+	return nil
+}
+
+func (m *mockChallengeRepo) GetByID(ctx context.Context, id string) (*domain.Challenge, error) {
+	if ch, ok := m.challenges[id]; ok {
+		return ch, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (m *mockChallengeRepo) ListActive(ctx context.Context, at time.Time) ([]domain.Challenge, error) {
+	var out []domain.Challenge
+	for _, ch := range m.challenges {
+		if ch.Active && !at.Before(ch.StartsAt) && at.Before(ch.EndsAt) {
+			out = append(out, *ch)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockChallengeRepo) Enroll(ctx context.Context, challengeID, userID string) error {
+	if _, ok := m.enrollments[challengeID+userID]; !ok {
+		m.enrollments[challengeID+userID] = &domain.ChallengeEnrollment{ChallengeID: challengeID, UserID: userID}
+	}
+	return nil
+}
+
+func (m *mockChallengeRepo) GetEnrollment(ctx context.Context, challengeID, userID string) (*domain.ChallengeEnrollment, error) {
+	return m.enrollments[challengeID+userID], nil
+}
+
+func (m *mockChallengeRepo) ListEnrollments(ctx context.Context, userID string) ([]domain.ChallengeEnrollment, error) {
+	var out []domain.ChallengeEnrollment
+	for _, e := range m.enrollments {
+		if e.UserID == userID {
+			out = append(out, *e)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockChallengeRepo) MarkRewarded(ctx context.Context, challengeID, userID, code string) error {
+	now := time.Now()
+	e := m.enrollments[challengeID+userID]
+	e.RewardedAt = &now
+	e.RewardCode = code
+	return nil
+}
+
+// --- Mock reward dependencies ---
+
+type mockAffiliateRepo struct {
+	affiliates map[string]*domain.Affiliate
+	lastLimit  int
+}
+
+func (m *mockAffiliateRepo) FindNearby(ctx context.Context, lat, lon, radiusMeters float64, limit int) ([]domain.Affiliate, error) {
+	m.lastLimit = limit
+	return []domain.Affiliate{{ID: "aff1", Name: "Café Iruña"}}, nil
+}
+
+func (m *mockAffiliateRepo) GetByID(ctx context.Context, id string) (*domain.Affiliate, error) {
+	if m.affiliates != nil {
+		return m.affiliates[id], nil
+	}
+	return &domain.Affiliate{ID: id}, nil
+}
+
+func (m *mockAffiliateRepo) List(ctx context.Context) ([]domain.Affiliate, error) {
+	var out []domain.Affiliate
+	for _, a := range m.affiliates {
+		out = append(out, *a)
+	}
+	return out, nil
+}
+
+func (m *mockAffiliateRepo) Create(ctx context.Context, a *domain.Affiliate) error {
+	a.ID = "aff-" + a.Name
+	m.affiliates[a.ID] = a
+	return nil
+}
+
+func (m *mockAffiliateRepo) Update(ctx context.Context, a *domain.Affiliate) (bool, error) {
+	if _, ok := m.affiliates[a.ID]; !ok {
+		return false, nil
+	}
+	m.affiliates[a.ID] = a
+	return true, nil
+}
+
+func (m *mockAffiliateRepo) Deactivate(ctx context.Context, id string) (bool, error) {
+	a, ok := m.affiliates[id]
+	if !ok {
+		return false, nil
+	}
+	a.Active = false
+	return true, nil
+}
+
+type mockCompensationRepo struct {
+	created []*domain.Compensation
+}
+
+func (m *mockCompensationRepo) Create(ctx context.Context, comp *domain.Compensation) error {
+	m.created = append(m.created, comp)
+	return nil
+}
+
+func (m *mockCompensationRepo) GetByCode(ctx context.Context, code string) (*domain.Compensation, error) {
+	for _, c := range m.created {
+		if c.Code == code {
+			cp := *c
+			return &cp, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockCompensationRepo) ListByUser(ctx context.Context, userID string) ([]domain.Compensation, error) {
+	var out []domain.Compensation
+	for _, c := range m.created {
+		if c.UserID == userID {
+			out = append(out, *c)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockCompensationRepo) Redeem(ctx context.Context, code string, at time.Time) (bool, error) {
+	for _, c := range m.created {
+		if c.Code == code && c.RedeemedAt == nil && at.Before(c.ExpiresAt) {
+			c.RedeemedAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *mockCompensationRepo) Delete(ctx context.Context, code string) error { return nil }
+
+// --- Tests ---
+
+func newTestChallenge(metric string, target float64, routeType *int) *mockChallengeRepo {
+	return &mockChallengeRepo{
+		challenges: map[string]*domain.Challenge{
+			"c1": {
+				ID: "c1", Slug: "march", Title: "March challenge", Metric: metric, Target: target, RouteType: routeType,
+				StartsAt: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+				EndsAt:   time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC),
+				Active:   true,
+			},
+		},
+		enrollments: map[string]*domain.ChallengeEnrollment{},
+	}
+}
+
+func marchJourneys() *mockHistoryRepo {
+	return &mockHistoryRepo{journeys: []domain.RiderJourney{
+		{UserID: "u1", RouteType: 1, DepartedAt: time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC), DistanceMeters: 3000},
+		{UserID: "u1", RouteType: 3, DepartedAt: time.Date(2026, 3, 3, 8, 0, 0, 0, time.UTC), DistanceMeters: 1000},
+		{UserID: "u1", RouteType: 1, DepartedAt: time.Date(2026, 2, 27, 8, 0, 0, 0, time.UTC), DistanceMeters: 5000},
+	}}
+}
+
+func TestChallengeService_ProgressFromHistory(t *testing.T) {
+	metro := 1
+	now := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		metric    string
+		routeType *int
+		want      float64
+	}{
+		{"trips", domain.ChallengeMetricTrips, nil, 2},
+		{"distance", domain.ChallengeMetricDistance, nil, 4000},
+		{"metro trips", domain.ChallengeMetricTrips, &metro, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := usecases.NewChallengeService(newTestChallenge(tt.metric, 10, tt.routeType), marchJourneys(), nil)
+			p, err := svc.Enroll(context.Background(), "u1", "c1", now)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if p.Progress != tt.want {
+				t.Errorf("expected progress %v, got %v", tt.want, p.Progress)
+			}
+		})
+	}
+}
+
+func TestChallengeService_EnrollClosed(t *testing.T) {
+	svc := usecases.NewChallengeService(newTestChallenge(domain.ChallengeMetricTrips, 2, nil), marchJourneys(), nil)
+
+	_, err := svc.Enroll(context.Background(), "u1", "c1", time.Date(2026, 4, 2, 0, 0, 0, 0, time.UTC))
+	if !errors.Is(err, usecases.ErrChallengeClosed) {
+		t.Fatalf("expected ErrChallengeClosed, got %v", err)
+	}
+	_, err = svc.Enroll(context.Background(), "u1", "missing", time.Now())
+	if !errors.Is(err, usecases.ErrChallengeNotFound) {
+		t.Fatalf("expected ErrChallengeNotFound, got %v", err)
+	}
+}
+
+func TestChallengeService_ClaimReward(t *testing.T) {
+	repo := newTestChallenge(domain.ChallengeMetricTrips, 2, nil)
+	comps := &mockCompensationRepo{}
+	rewards := usecases.NewCompensationService(nil, &mockAffiliateRepo{}, comps, nil)
+	svc := usecases.NewChallengeService(repo, marchJourneys(), rewards)
+	ctx := context.Background()
+
+	if _, err := svc.Claim(ctx, "u1", "c1", 43.26, -2.93); !errors.Is(err, usecases.ErrNotEnrolled) {
+		t.Fatalf("expected ErrNotEnrolled, got %v", err)
+	}
+	if _, err := svc.Enroll(ctx, "u1", "c1", time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+
+	comp, err := svc.Claim(ctx, "u1", "c1", 43.26, -2.93)
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if comp.AffiliateID != "aff1" || comp.Code == "" || comp.DelayEventID != "" {
+		t.Errorf("unexpected coupon: %+v", comp)
+	}
+	if comp.Metadata["challenge_id"] != "c1" {
+		t.Errorf("expected challenge_id metadata, got %v", comp.Metadata)
+	}
+	if len(comps.created) != 1 {
+		t.Errorf("expected 1 coupon persisted, got %d", len(comps.created))
+	}
+
+	if _, err := svc.Claim(ctx, "u1", "c1", 43.26, -2.93); !errors.Is(err, usecases.ErrRewardClaimed) {
+		t.Fatalf("expected ErrRewardClaimed, got %v", err)
+	}
+}
+
+func TestChallengeService_ClaimIncomplete(t *testing.T) {
+	repo := newTestChallenge(domain.ChallengeMetricTrips, 10, nil)
+	rewards := usecases.NewCompensationService(nil, &mockAffiliateRepo{}, &mockCompensationRepo{}, nil)
+	svc := usecases.NewChallengeService(repo, marchJourneys(), rewards)
+	ctx := context.Background()
+
+	if _, err := svc.Enroll(ctx, "u1", "c1", time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+	if _, err := svc.Claim(ctx, "u1", "c1", 43.26, -2.93); !errors.Is(err, usecases.ErrChallengeIncomplete) {
+		t.Fatalf("expected ErrChallengeIncomplete, got %v", err)
+	}
+}
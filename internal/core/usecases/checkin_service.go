@@ -0,0 +1,70 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+const (
+	// checkInMaxAge is how long after boarding a rider can still check in.
+	checkInMaxAge = 24 * time.Hour
+	// checkInMaxSkew tolerates client clocks slightly ahead of ours.
+	checkInMaxSkew = 10 * time.Minute
+)
+
+var (
+	ErrTripNotFound       = errors.New("trip not found")
+	ErrStopNotOnTrip      = errors.New("stop is not served by the trip")
+	ErrInvalidCheckInTime = errors.New("check-in time must be within the last 24 hours")
+)
+
+// CheckInService records the trips riders declare they boarded.
+type CheckInService struct {
+	checkins ports.CheckInRepository
+	trips    ports.TripRepository
+}
+
+// NewCheckInService creates a new CheckInService.
+func NewCheckInService(checkins ports.CheckInRepository, trips ports.TripRepository) *CheckInService {
+	return &CheckInService{checkins: checkins, trips: trips}
+}
+
+// CheckIn records that c.UserID boarded c.TripID at c.StopID. A zero time
+// means now.
+func (s *CheckInService) CheckIn(ctx context.Context, c *domain.CheckIn, now time.Time) error {
+	if c.Time.IsZero() {
+		c.Time = now
+	}
+	if c.Time.Before(now.Add(-checkInMaxAge)) || c.Time.After(now.Add(checkInMaxSkew)) {
+		return ErrInvalidCheckInTime
+	}
+
+	stopTimes, err := s.trips.GetStopTimes(ctx, c.TripID)
+	if err != nil || len(stopTimes) == 0 {
+		return ErrTripNotFound
+	}
+	served := false
+	for _, st := range stopTimes {
+		if st.StopID == c.StopID {
+			served = true
+			break
+		}
+	}
+	if !served {
+		return ErrStopNotOnTrip
+	}
+
+	return s.checkins.Create(ctx, c)
+}
+
+// List returns the rider's most recent check-ins.
+func (s *CheckInService) List(ctx context.Context, userID string, limit int) ([]domain.CheckIn, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	return s.checkins.ListByUser(ctx, userID, limit)
+}
@@ -0,0 +1,109 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock CheckInRepository ---
+
+type mockCheckInRepo struct {
+	checkins []domain.CheckIn
+}
+
+func (m *mockCheckInRepo) Create(ctx context.Context, c *domain.CheckIn) error {
+	c.ID = "c" + c.UserID
+	m.checkins = append(m.checkins, *c)
+	return nil
+}
+
+func (m *mockCheckInRepo) ListByUser(ctx context.Context, userID string, limit int) ([]domain.CheckIn, error) {
+	var out []domain.CheckIn
+	for _, c := range m.checkins {
+		if c.UserID == userID && len(out) < limit {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockCheckInRepo) RidersOnTrip(ctx context.Context, tripID string, from, to time.Time) ([]string, error) {
+	var users []string
+	for _, c := range m.checkins {
+		if c.TripID == tripID && !c.Time.Before(from) && c.Time.Before(to) {
+			users = append(users, c.UserID)
+		}
+	}
+	return users, nil
+}
+
+func TestCheckInService_CheckIn(t *testing.T) {
+	repo := &mockCheckInRepo{}
+	trips := &mockTripRepo{stopTimes: map[string][]domain.StopTime{
+		"t1": {{TripID: "t1", StopID: "s1", StopSequence: 1}, {TripID: "t1", StopID: "s2", StopSequence: 2}},
+	}}
+	svc := usecases.NewCheckInService(repo, trips)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+
+	c := domain.CheckIn{UserID: "u1", TripID: "t1", StopID: "s2"}
+	if err := svc.CheckIn(ctx, &c, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.ID == "" || !c.Time.Equal(now) {
+		t.Errorf("expected stored check-in at now, got %+v", c)
+	}
+
+	cases := []struct {
+		c    domain.CheckIn
+		want error
+	}{
+		{domain.CheckIn{UserID: "u1", TripID: "t9", StopID: "s1"}, usecases.ErrTripNotFound},
+		{domain.CheckIn{UserID: "u1", TripID: "t1", StopID: "s9"}, usecases.ErrStopNotOnTrip},
+		{domain.CheckIn{UserID: "u1", TripID: "t1", StopID: "s1", Time: now.Add(-25 * time.Hour)}, usecases.ErrInvalidCheckInTime},
+		{domain.CheckIn{UserID: "u1", TripID: "t1", StopID: "s1", Time: now.Add(time.Hour)}, usecases.ErrInvalidCheckInTime},
+	}
+	for _, tc := range cases {
+		if err := svc.CheckIn(ctx, &tc.c, now); !errors.Is(err, tc.want) {
+			t.Errorf("%+v: expected %v, got %v", tc.c, tc.want, err)
+		}
+	}
+
+	list, err := svc.List(ctx, "u1", 0)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("expected one check-in, got %d (%v)", len(list), err)
+	}
+}
+
+func TestDelayOrchestrator_IncludesCheckedInRiders(t *testing.T) {
+	at := time.Date(2026, 3, 2, 8, 30, 0, 0, time.UTC)
+	riders := &mockHistoryRepo{journeys: []domain.RiderJourney{
+		{UserID: "u1", TripID: "t1", DepartedAt: at.Add(-20 * time.Minute)},
+	}}
+	checkins := &mockCheckInRepo{checkins: []domain.CheckIn{
+		{UserID: "u1", TripID: "t1", Time: at.Add(-20 * time.Minute)},
+		{UserID: "u2", TripID: "t1", Time: at.Add(-10 * time.Minute)},
+		{UserID: "u3", TripID: "t1", Time: at.Add(10 * time.Minute)},
+	}}
+	stops := &mockStopRepo{
+		getByIDFn: func(ctx context.Context, id string) (*domain.Stop, error) {
+			return &domain.Stop{ID: id}, nil
+		},
+	}
+	starter := &mockStarter{}
+	o := usecases.NewDelayOrchestrator(riders, checkins, stops, starter)
+
+	e := &domain.DelayEvent{ID: "d1", Time: at, TripID: "t1", StopID: "s1", DelaySeconds: 600}
+	started, err := o.HandleDelay(context.Background(), e)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if started != 2 || starter.started[0].UserID != "u1" || starter.started[1].UserID != "u2" {
+		t.Errorf("expected compensations for u1 and u2, got %+v", starter.started)
+	}
+}
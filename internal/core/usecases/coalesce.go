@@ -0,0 +1,36 @@
+package usecases
+
+import (
+	"context"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// missingTTL is how long, in seconds, a lookup that found nothing is
+// remembered, so requests for unknown IDs do not each reach the database.
+// New stops and routes show up after at most this long.
+const missingTTL = 30
+
+// missingMarker is cached for lookups that found nothing.
+var missingMarker = []byte("null")
+
+// coalesce runs load once for the concurrent callers asking for the same
+// key, so an expired cache entry of a popular stop costs one query rather
+// than one per waiting request. load runs detached from the caller that
+// started it, which may give up without failing the others; each caller
+// still stops waiting when its own context is done. shared reports whether
+// the result went to several callers, who must not modify it.
+func coalesce[T any](ctx context.Context, group *singleflight.Group, key string, load func(context.Context) (T, error)) (v T, shared bool, err error) {
+	ch := group.DoChan(key, func() (any, error) {
+		return load(context.WithoutCancel(ctx))
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return v, res.Shared, res.Err
+		}
+		return res.Val.(T), res.Shared, nil
+	case <-ctx.Done():
+		return v, false, ctx.Err()
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"time"
 
@@ -11,6 +12,14 @@ import (
 	"github.com/samirrijal/bilbopass/internal/core/ports"
 )
 
+var (
+	ErrCouponNotFound       = errors.New("coupon not found")
+	ErrCouponRedeemed       = errors.New("coupon already redeemed")
+	ErrCouponExpired        = errors.New("coupon expired")
+	ErrCouponOtherAffiliate = errors.New("coupon belongs to another affiliate")
+	ErrNotAffiliateStaff    = errors.New("only affiliate staff can verify or redeem coupons")
+)
+
 // CompensationService handles delay-compensation business logic.
 type CompensationService struct {
 	delays        ports.DelayEventRepository
@@ -36,8 +45,52 @@ func NewCompensationService(
 
 // IssueCompensation finds the nearest affiliate and creates a coupon for the user.
 func (s *CompensationService) IssueCompensation(ctx context.Context, userID string, delayEvent *domain.DelayEvent, stopLat, stopLon float64) (*domain.Compensation, error) {
+	comp := &domain.Compensation{
+		UserID:       userID,
+		DelayEventID: delayEvent.ID,
+	}
+	affiliate, err := s.issueCoupon(ctx, comp, stopLat, stopLon)
+	if err != nil {
+		return nil, err
+	}
+
+	// Mark the delay event as compensated
+	if err := s.delays.MarkCompensated(ctx, delayEvent.ID); err != nil {
+		// Best-effort; coupon already created
+		_ = err
+	}
+
+	// Send push notification (best-effort)
+	title := "Free coffee — sorry for the delay!"
+	body := fmt.Sprintf("Show code %s at %s. Valid for 72 hours.", comp.Code, affiliate.Name)
+	_ = s.notifier.SendPush(ctx, userID, domain.NotificationCoupons, title, body)
+
+	return comp, nil
+}
+
+// IssueReward creates a coupon at the affiliate nearest to lat/lon that is not
+// tied to a delay, e.g. for a completed challenge. metadata records the reason.
+func (s *CompensationService) IssueReward(ctx context.Context, userID string, lat, lon float64, metadata map[string]any) (*domain.Compensation, error) {
+	comp := &domain.Compensation{UserID: userID, Metadata: metadata}
+	affiliate, err := s.issueCoupon(ctx, comp, lat, lon)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.notifier != nil {
+		title := "Challenge complete — enjoy your reward!"
+		body := fmt.Sprintf("Show code %s at %s. Valid for 72 hours.", comp.Code, affiliate.Name)
+		_ = s.notifier.SendPush(ctx, userID, domain.NotificationCoupons, title, body)
+	}
+
+	return comp, nil
+}
+
+// issueCoupon picks the nearest active affiliate, fills in the coupon fields of
+// comp, and persists it.
+func (s *CompensationService) issueCoupon(ctx context.Context, comp *domain.Compensation, lat, lon float64) (*domain.Affiliate, error) {
 	// Find nearest active affiliate
-	affiliates, err := s.affiliates.FindNearby(ctx, stopLat, stopLon, 5)
+	affiliates, err := s.affiliates.FindNearby(ctx, lat, lon, CouponAffiliateRadius, 5)
 	if err != nil {
 		return nil, fmt.Errorf("find affiliates: %w", err)
 	}
@@ -53,36 +106,91 @@ func (s *CompensationService) IssueCompensation(ctx context.Context, userID stri
 		return nil, fmt.Errorf("generate code: %w", err)
 	}
 
-	comp := &domain.Compensation{
-		UserID:       userID,
-		DelayEventID: delayEvent.ID,
-		AffiliateID:  affiliate.ID,
-		Code:         code,
-		IssuedAt:     time.Now(),
-		ExpiresAt:    time.Now().Add(72 * time.Hour),
-	}
+	comp.AffiliateID = affiliate.ID
+	comp.Code = code
+	comp.IssuedAt = time.Now()
+	comp.ExpiresAt = comp.IssuedAt.Add(72 * time.Hour)
 
 	if err := s.compensations.Create(ctx, comp); err != nil {
 		return nil, fmt.Errorf("create compensation: %w", err)
 	}
+	return &affiliate, nil
+}
 
-	// Mark the delay event as compensated
-	if err := s.delays.MarkCompensated(ctx, delayEvent.ID); err != nil {
-		// Best-effort; coupon already created
-		_ = err
+// ListForUser returns the coupons issued to a user, newest first.
+func (s *CompensationService) ListForUser(ctx context.Context, userID string) ([]domain.Compensation, error) {
+	return s.compensations.ListByUser(ctx, userID)
+}
+
+// Verify reports whether a coupon can be redeemed, for affiliate staff or admins.
+func (s *CompensationService) Verify(ctx context.Context, code string, staff *domain.User, now time.Time) (*domain.CouponCheck, error) {
+	comp, err := s.couponForStaff(ctx, code, staff)
+	if err != nil {
+		return nil, err
 	}
+	return &domain.CouponCheck{
+		Code:        comp.Code,
+		Status:      couponStatus(comp, now),
+		AffiliateID: comp.AffiliateID,
+		ExpiresAt:   comp.ExpiresAt,
+		RedeemedAt:  comp.RedeemedAt,
+	}, nil
+}
 
-	// Send push notification (best-effort)
-	title := "Free coffee — sorry for the delay!"
-	body := fmt.Sprintf("Show code %s at %s. Valid for 72 hours.", code, affiliate.Name)
-	_ = s.notifier.SendPush(ctx, userID, title, body)
+// Redeem marks a coupon as redeemed on behalf of affiliate staff or an admin.
+// A coupon can be redeemed only once and only before it expires.
+func (s *CompensationService) Redeem(ctx context.Context, code string, staff *domain.User, now time.Time) (*domain.Compensation, error) {
+	comp, err := s.couponForStaff(ctx, code, staff)
+	if err != nil {
+		return nil, err
+	}
+	switch couponStatus(comp, now) {
+	case "redeemed":
+		return nil, ErrCouponRedeemed
+	case "expired":
+		return nil, ErrCouponExpired
+	}
+
+	ok, err := s.compensations.Redeem(ctx, code, now)
+	if err != nil {
+		return nil, fmt.Errorf("redeem: %w", err)
+	}
+	if !ok {
+		// Redeemed concurrently by another terminal.
+		return nil, ErrCouponRedeemed
+	}
+	comp.RedeemedAt = &now
+	return comp, nil
+}
 
+// couponForStaff loads a coupon that staff may act on: admins may act on any
+// coupon, affiliate staff only on their own affiliate's.
+func (s *CompensationService) couponForStaff(ctx context.Context, code string, staff *domain.User) (*domain.Compensation, error) {
+	if staff == nil || (staff.Role != "admin" && (staff.Role != "affiliate" || staff.AffiliateID == "")) {
+		return nil, ErrNotAffiliateStaff
+	}
+	comp, err := s.compensations.GetByCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if comp == nil {
+		return nil, ErrCouponNotFound
+	}
+	if staff.Role == "affiliate" && comp.AffiliateID != staff.AffiliateID {
+		return nil, ErrCouponOtherAffiliate
+	}
 	return comp, nil
 }
 
-// RedeemCompensation marks a coupon as redeemed.
-func (s *CompensationService) RedeemCompensation(ctx context.Context, code string) error {
-	return s.compensations.Redeem(ctx, code)
+func couponStatus(c *domain.Compensation, now time.Time) string {
+	switch {
+	case c.RedeemedAt != nil:
+		return "redeemed"
+	case !now.Before(c.ExpiresAt):
+		return "expired"
+	default:
+		return "valid"
+	}
 }
 
 func generateCode() (string, error) {
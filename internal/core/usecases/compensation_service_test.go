@@ -0,0 +1,79 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+func newCouponRepo(now time.Time) *mockCompensationRepo {
+	return &mockCompensationRepo{created: []*domain.Compensation{
+		{Code: "BP-1", UserID: "u1", AffiliateID: "aff1", IssuedAt: now.Add(-time.Hour), ExpiresAt: now.Add(71 * time.Hour)},
+		{Code: "BP-2", UserID: "u1", AffiliateID: "aff1", IssuedAt: now.Add(-80 * time.Hour), ExpiresAt: now.Add(-8 * time.Hour)},
+		{Code: "BP-3", UserID: "u2", AffiliateID: "aff2", IssuedAt: now.Add(-time.Hour), ExpiresAt: now.Add(71 * time.Hour)},
+	}}
+}
+
+func TestCompensationService_Redeem(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	repo := newCouponRepo(now)
+	svc := usecases.NewCompensationService(nil, nil, repo, nil)
+	ctx := context.Background()
+	staff := &domain.User{ID: "s1", Role: "affiliate", AffiliateID: "aff1"}
+
+	comp, err := svc.Redeem(ctx, "BP-1", staff, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if comp.RedeemedAt == nil || !comp.RedeemedAt.Equal(now) {
+		t.Errorf("expected coupon redeemed at now, got %+v", comp)
+	}
+
+	cases := []struct {
+		code  string
+		staff *domain.User
+		want  error
+	}{
+		{"BP-1", staff, usecases.ErrCouponRedeemed},
+		{"BP-2", staff, usecases.ErrCouponExpired},
+		{"BP-3", staff, usecases.ErrCouponOtherAffiliate},
+		{"BP-9", staff, usecases.ErrCouponNotFound},
+		{"BP-3", &domain.User{ID: "u1", Role: "rider"}, usecases.ErrNotAffiliateStaff},
+		{"BP-3", &domain.User{ID: "s2", Role: "affiliate"}, usecases.ErrNotAffiliateStaff},
+	}
+	for _, c := range cases {
+		if _, err := svc.Redeem(ctx, c.code, c.staff, now); !errors.Is(err, c.want) {
+			t.Errorf("redeem %s as %+v: expected %v, got %v", c.code, c.staff, c.want, err)
+		}
+	}
+
+	if _, err := svc.Redeem(ctx, "BP-3", &domain.User{ID: "a1", Role: "admin"}, now); err != nil {
+		t.Errorf("admin should redeem any coupon, got %v", err)
+	}
+}
+
+func TestCompensationService_VerifyAndList(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	svc := usecases.NewCompensationService(nil, nil, newCouponRepo(now), nil)
+	ctx := context.Background()
+	staff := &domain.User{ID: "s1", Role: "affiliate", AffiliateID: "aff1"}
+
+	for code, want := range map[string]string{"BP-1": "valid", "BP-2": "expired"} {
+		check, err := svc.Verify(ctx, code, staff, now)
+		if err != nil {
+			t.Fatalf("verify %s: %v", code, err)
+		}
+		if check.Status != want {
+			t.Errorf("verify %s: expected %s, got %s", code, want, check.Status)
+		}
+	}
+
+	comps, err := svc.ListForUser(ctx, "u1")
+	if err != nil || len(comps) != 2 {
+		t.Errorf("expected two coupons for u1, got %d (%v)", len(comps), err)
+	}
+}
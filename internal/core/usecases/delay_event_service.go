@@ -0,0 +1,115 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+// DelayEventWindow is how long after a delay event the same trip and stop
+// are not recorded again: every poll in between sees the same delay.
+const DelayEventWindow = 2 * time.Hour
+
+// maxRecentDelays is how many recorded trip stops are remembered before
+// those older than DelayEventWindow are forgotten.
+const maxRecentDelays = 50000
+
+// DelayEventService records the delays seen in realtime feeds as delay
+// events, which the compensator turns into coupons.
+type DelayEventService struct {
+	observations ports.DelayObservationRepository
+	publisher    ports.EventPublisher
+
+	mu     sync.Mutex
+	recent map[string]time.Time // by agency, trip and stop; see Record
+}
+
+// NewDelayEventService creates a new DelayEventService.
+func NewDelayEventService(observations ports.DelayObservationRepository, publisher ports.EventPublisher) *DelayEventService {
+	return &DelayEventService{observations: observations, publisher: publisher, recent: map[string]time.Time{}}
+}
+
+// Record records the delays seen in one poll of a feed and publishes each
+// new event. A trip stop recorded within DelayEventWindow is skipped
+// without a query; the repository checks the window again, for events
+// recorded before a restart. It returns the new events, and any failure
+// to publish them.
+func (s *DelayEventService) Record(ctx context.Context, obs []domain.DelayObservation) ([]domain.DelayEvent, error) {
+	fresh := s.unrecorded(obs)
+	if len(fresh) == 0 {
+		return nil, nil
+	}
+	inserted, err := s.observations.Record(ctx, fresh, DelayEventWindow)
+	if err != nil {
+		return nil, fmt.Errorf("record delay events: %w", err)
+	}
+
+	var recorded []domain.DelayEvent
+	s.mu.Lock()
+	for i, e := range inserted {
+		if e == nil {
+			continue
+		}
+		s.remember(delayKey(&fresh[i]), fresh[i].Time)
+		recorded = append(recorded, *e)
+	}
+	s.mu.Unlock()
+
+	var errs []error
+	for i := range recorded {
+		if err := s.publisher.PublishDelayEvent(ctx, &recorded[i]); err != nil {
+			errs = append(errs, fmt.Errorf("publish delay event %s: %w", recorded[i].ID, err))
+		}
+	}
+	return recorded, errors.Join(errs...)
+}
+
+// unrecorded returns the observations of trip stops without an event
+// recorded within DelayEventWindow, once each.
+func (s *DelayEventService) unrecorded(obs []domain.DelayObservation) []domain.DelayObservation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := map[string]bool{}
+	var fresh []domain.DelayObservation
+	for _, o := range obs {
+		key := delayKey(&o)
+		if at, ok := s.recent[key]; ok && o.Time.Sub(at) < DelayEventWindow {
+			continue
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		fresh = append(fresh, o)
+	}
+	return fresh
+}
+
+// remember records that the trip stop of key had an event at `at`. Called
+// with s.mu held.
+func (s *DelayEventService) remember(key string, at time.Time) {
+	if _, ok := s.recent[key]; !ok && len(s.recent) >= maxRecentDelays {
+		for k, t := range s.recent {
+			if at.Sub(t) >= DelayEventWindow {
+				delete(s.recent, k)
+			}
+		}
+	}
+	s.recent[key] = at
+}
+
+// delayKey identifies the trip stop of an observation: by its stop, or its
+// stop sequence when the feed gives no stop.
+func delayKey(o *domain.DelayObservation) string {
+	stop := o.StopID
+	if stop == "" && o.StopSequence != nil {
+		stop = "#" + strconv.Itoa(*o.StopSequence)
+	}
+	return o.AgencyID + "/" + o.TripID + "/" + stop
+}
@@ -0,0 +1,137 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock DelayObservationRepository ---
+
+// mockDelayObservationRepo matches trips "t1" and "t2" and, like the
+// database, records a trip stop once per window.
+type mockDelayObservationRepo struct {
+	calls    [][]domain.DelayObservation
+	recorded map[string]time.Time
+	err      error
+}
+
+func (m *mockDelayObservationRepo) Record(ctx context.Context, obs []domain.DelayObservation, window time.Duration) ([]*domain.DelayEvent, error) {
+	m.calls = append(m.calls, obs)
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.recorded == nil {
+		m.recorded = map[string]time.Time{}
+	}
+	out := make([]*domain.DelayEvent, len(obs))
+	for i, o := range obs {
+		if o.TripID != "t1" && o.TripID != "t2" {
+			continue
+		}
+		key := o.TripID + "/" + o.StopID
+		if at, ok := m.recorded[key]; ok && o.Time.Sub(at) < window {
+			continue
+		}
+		m.recorded[key] = o.Time
+		out[i] = &domain.DelayEvent{ID: "ev-" + key, TripID: "uuid-" + o.TripID, StopID: "uuid-" + o.StopID, Time: o.Time, DelaySeconds: o.DelaySeconds}
+	}
+	return out, nil
+}
+
+func TestDelayEventService_Record(t *testing.T) {
+	repo := &mockDelayObservationRepo{}
+	pub := &mockPublisher{}
+	svc := usecases.NewDelayEventService(repo, pub)
+	ctx := context.Background()
+	t0 := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+
+	obs := func(at time.Time, trip, stop string) domain.DelayObservation {
+		return domain.DelayObservation{Time: at, AgencyID: "a1", TripID: trip, StopID: stop, DelaySeconds: 300}
+	}
+
+	events, err := svc.Record(ctx, []domain.DelayObservation{
+		obs(t0, "t1", "s1"),
+		obs(t0, "t1", "s1"), // repeated in the same poll
+		obs(t0, "t2", "s1"),
+		obs(t0, "unknown", "s1"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || len(repo.calls[0]) != 3 {
+		t.Fatalf("expected 2 events from 3 queried observations, got %+v from %d", events, len(repo.calls[0]))
+	}
+	if len(pub.delays) != 2 || pub.delays[0].TripID != "uuid-t1" || pub.delays[1].TripID != "uuid-t2" {
+		t.Errorf("expected both events published, got %+v", pub.delays)
+	}
+
+	// The next poll sees the same delay: it is not queried again, while
+	// the unmatched trip and a new stop are.
+	events, err = svc.Record(ctx, []domain.DelayObservation{
+		obs(t0.Add(30*time.Second), "t1", "s1"),
+		obs(t0.Add(30*time.Second), "t1", "s2"),
+		obs(t0.Add(30*time.Second), "unknown", "s1"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := repo.calls[1]; len(q) != 2 || q[0].StopID != "s2" || q[1].TripID != "unknown" {
+		t.Errorf("expected only t1/s2 and the unmatched trip queried, got %+v", q)
+	}
+	if len(events) != 1 || events[0].StopID != "uuid-s2" || len(pub.delays) != 3 {
+		t.Errorf("expected one new event at s2, got %+v", events)
+	}
+
+	// After the window the delay counts again.
+	events, _ = svc.Record(ctx, []domain.DelayObservation{obs(t0.Add(usecases.DelayEventWindow), "t1", "s1")})
+	if len(events) != 1 {
+		t.Errorf("expected the delay recorded again after the window, got %+v", events)
+	}
+}
+
+func TestDelayEventService_RecordStopSequence(t *testing.T) {
+	repo := &mockDelayObservationRepo{}
+	svc := usecases.NewDelayEventService(repo, &mockPublisher{})
+	t0 := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	seq := func(n int) *int { return &n }
+
+	// Feeds giving only the stop sequence: distinct sequences are distinct
+	// stops.
+	svc.Record(context.Background(), []domain.DelayObservation{
+		{Time: t0, AgencyID: "a1", TripID: "t1", StopSequence: seq(3)},
+		{Time: t0, AgencyID: "a1", TripID: "t1", StopSequence: seq(4)},
+		{Time: t0, AgencyID: "a1", TripID: "t1", StopSequence: seq(3)},
+	})
+	if len(repo.calls[0]) != 2 {
+		t.Errorf("expected sequences 3 and 4 queried once each, got %+v", repo.calls[0])
+	}
+}
+
+func TestDelayEventService_RecordErrors(t *testing.T) {
+	t0 := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	obs := []domain.DelayObservation{{Time: t0, AgencyID: "a1", TripID: "t1", StopID: "s1"}}
+
+	// A failed insert records nothing, so the next poll retries.
+	repo := &mockDelayObservationRepo{err: errors.New("db down")}
+	svc := usecases.NewDelayEventService(repo, &mockPublisher{})
+	if _, err := svc.Record(context.Background(), obs); err == nil {
+		t.Fatal("expected the repository error")
+	}
+	repo.err = nil
+	if events, err := svc.Record(context.Background(), obs); err != nil || len(events) != 1 {
+		t.Errorf("expected the retry recorded, got %+v, %v", events, err)
+	}
+
+	// Events recorded but not published are returned with the error.
+	pub := &mockPublisher{delayErr: errors.New("nats down")}
+	svc = usecases.NewDelayEventService(&mockDelayObservationRepo{}, pub)
+	events, err := svc.Record(context.Background(), obs)
+	if err == nil || len(events) != 1 {
+		t.Errorf("expected the event and the publish error, got %+v, %v", events, err)
+	}
+}
@@ -0,0 +1,106 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+const (
+	// compensationMinDelay is the delay from which riders are compensated.
+	compensationMinDelay = 5 * time.Minute
+	// riderWindow is how long before a delay event a rider's journey on the
+	// trip may have departed, or the rider checked in, and still count as
+	// being on board.
+	riderWindow = 3 * time.Hour
+)
+
+// DelayOrchestrator turns detected delay events into compensation requests
+// for the riders who were on the delayed trip.
+type DelayOrchestrator struct {
+	riders   ports.HistoryRepository
+	checkins ports.CheckInRepository
+	stops    ports.StopRepository
+	starter  ports.CompensationStarter
+}
+
+// NewDelayOrchestrator creates a new DelayOrchestrator. Riders on a trip are
+// found from their logged journeys and their check-ins; checkins may be nil.
+func NewDelayOrchestrator(riders ports.HistoryRepository, checkins ports.CheckInRepository, stops ports.StopRepository, starter ports.CompensationStarter) *DelayOrchestrator {
+	return &DelayOrchestrator{riders: riders, checkins: checkins, stops: stops, starter: starter}
+}
+
+// HandleDelay starts a compensation for every rider on the event's trip and
+// returns how many were started. Starts are idempotent per event and rider,
+// so a redelivered event never compensates anyone twice; on error the event
+// can simply be retried.
+func (o *DelayOrchestrator) HandleDelay(ctx context.Context, e *domain.DelayEvent) (int, error) {
+	if time.Duration(e.DelaySeconds)*time.Second < compensationMinDelay || e.IsCompensated {
+		return 0, nil
+	}
+
+	users, err := o.ridersOnTrip(ctx, e)
+	if err != nil {
+		return 0, err
+	}
+	if len(users) == 0 {
+		return 0, nil
+	}
+
+	stop, err := o.stops.GetByID(ctx, e.StopID)
+	if err != nil {
+		return 0, fmt.Errorf("get stop %s: %w", e.StopID, err)
+	}
+	if stop == nil {
+		return 0, fmt.Errorf("stop %s not found", e.StopID)
+	}
+
+	started := 0
+	for _, userID := range users {
+		req := domain.CompensationRequest{
+			DelayEventID: e.ID,
+			UserID:       userID,
+			StopID:       e.StopID,
+			StopLat:      stop.Location.Lat,
+			StopLon:      stop.Location.Lon,
+			DelayMinutes: e.DelaySeconds / 60,
+		}
+		if err := o.starter.StartCompensation(ctx, req); err != nil {
+			return started, fmt.Errorf("start compensation for %s: %w", userID, err)
+		}
+		started++
+	}
+	return started, nil
+}
+
+// ridersOnTrip returns the distinct users who logged a journey on, or checked
+// in to, the event's trip within riderWindow before it.
+func (o *DelayOrchestrator) ridersOnTrip(ctx context.Context, e *domain.DelayEvent) ([]string, error) {
+	from := e.Time.Add(-riderWindow)
+	users, err := o.riders.RidersOnTrip(ctx, e.TripID, from, e.Time)
+	if err != nil {
+		return nil, fmt.Errorf("riders on trip %s: %w", e.TripID, err)
+	}
+	if o.checkins == nil {
+		return users, nil
+	}
+	checkedIn, err := o.checkins.RidersOnTrip(ctx, e.TripID, from, e.Time)
+	if err != nil {
+		return nil, fmt.Errorf("check-ins on trip %s: %w", e.TripID, err)
+	}
+
+	seen := make(map[string]bool, len(users))
+	for _, u := range users {
+		seen[u] = true
+	}
+	for _, u := range checkedIn {
+		if !seen[u] {
+			seen[u] = true
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
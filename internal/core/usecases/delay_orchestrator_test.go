@@ -0,0 +1,85 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock CompensationStarter ---
+
+type mockStarter struct {
+	started []domain.CompensationRequest
+	err     error
+}
+
+func (m *mockStarter) StartCompensation(ctx context.Context, req domain.CompensationRequest) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.started = append(m.started, req)
+	return nil
+}
+
+func TestDelayOrchestrator_StartsForRidersOnTrip(t *testing.T) {
+	at := time.Date(2026, 3, 2, 8, 30, 0, 0, time.UTC)
+	riders := &mockHistoryRepo{journeys: []domain.RiderJourney{
+		{UserID: "u1", TripID: "t1", DepartedAt: at.Add(-20 * time.Minute)},
+		{UserID: "u2", TripID: "t2", DepartedAt: at.Add(-20 * time.Minute)},
+		{UserID: "u3", TripID: "t1", DepartedAt: at.Add(-24 * time.Hour)},
+	}}
+	stops := &mockStopRepo{
+		getByIDFn: func(ctx context.Context, id string) (*domain.Stop, error) {
+			return &domain.Stop{ID: id, Location: domain.GeoPoint{Lat: 43.26, Lon: -2.93}}, nil
+		},
+	}
+	starter := &mockStarter{}
+	o := usecases.NewDelayOrchestrator(riders, nil, stops, starter)
+
+	e := &domain.DelayEvent{ID: "d1", Time: at, TripID: "t1", StopID: "s1", DelaySeconds: 420}
+	started, err := o.HandleDelay(context.Background(), e)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if started != 1 || len(starter.started) != 1 {
+		t.Fatalf("expected one compensation, got %d", started)
+	}
+	want := domain.CompensationRequest{DelayEventID: "d1", UserID: "u1", StopID: "s1", StopLat: 43.26, StopLon: -2.93, DelayMinutes: 7}
+	if starter.started[0] != want {
+		t.Errorf("expected %+v, got %+v", want, starter.started[0])
+	}
+}
+
+func TestDelayOrchestrator_IgnoresShortDelays(t *testing.T) {
+	riders := &mockHistoryRepo{journeys: []domain.RiderJourney{
+		{UserID: "u1", TripID: "t1", DepartedAt: time.Now().Add(-time.Minute)},
+	}}
+	starter := &mockStarter{}
+	o := usecases.NewDelayOrchestrator(riders, nil, &mockStopRepo{}, starter)
+
+	e := &domain.DelayEvent{ID: "d1", Time: time.Now(), TripID: "t1", DelaySeconds: 200}
+	if started, err := o.HandleDelay(context.Background(), e); err != nil || started != 0 {
+		t.Errorf("expected nothing started, got %d, %v", started, err)
+	}
+}
+
+func TestDelayOrchestrator_StartErrorIsReturned(t *testing.T) {
+	riders := &mockHistoryRepo{journeys: []domain.RiderJourney{
+		{UserID: "u1", TripID: "t1", DepartedAt: time.Now().Add(-time.Minute)},
+	}}
+	stops := &mockStopRepo{
+		getByIDFn: func(ctx context.Context, id string) (*domain.Stop, error) {
+			return &domain.Stop{ID: id}, nil
+		},
+	}
+	o := usecases.NewDelayOrchestrator(riders, nil, stops, &mockStarter{err: errors.New("temporal down")})
+
+	e := &domain.DelayEvent{ID: "d1", Time: time.Now(), TripID: "t1", StopID: "s1", DelaySeconds: 600}
+	if _, err := o.HandleDelay(context.Background(), e); err == nil {
+		t.Error("expected error so the event is redelivered")
+	}
+}
@@ -2,25 +2,259 @@ package usecases
 
 import (
 	"context"
+	"math"
+	"sort"
+	"time"
 
 	"github.com/samirrijal/bilbopass/internal/core/domain"
 	"github.com/samirrijal/bilbopass/internal/core/ports"
 )
 
+// predictionMaxAge is how old a trip update may be and still override the schedule.
+const predictionMaxAge = 10 * time.Minute
+
+// realtimeWindowStart is how far ahead a departure window may start and
+// still get real-time data; trips run under the same IDs every day, so later
+// windows would pick up today's predictions.
+const realtimeWindowStart = 2 * time.Hour
+
+const (
+	// forecastHorizon is how far ahead departures get a historical forecast.
+	forecastHorizon = time.Hour
+	// forecastHistory is how far back delay profiles look.
+	forecastHistory = 8 * 7 * 24 * time.Hour
+	// forecastMinSamples is the fewest observations a profile needs to be used.
+	forecastMinSamples = 5
+)
+
+// nearbyDepartureHorizon is how far ahead nearby departures are listed.
+const nearbyDepartureHorizon = 2 * time.Hour
+
 // DepartureService computes next departures at a stop.
 type DepartureService struct {
-	trips ports.TripRepository
+	trips       ports.TripRepository
+	predictions ports.TripUpdateRepository
+	profiles    ports.DelayProfileRepository
+	occupancy   ports.OccupancyRepository
 }
 
-// NewDepartureService creates a new DepartureService.
-func NewDepartureService(trips ports.TripRepository) *DepartureService {
-	return &DepartureService{trips: trips}
+// NewDepartureService creates a new DepartureService. predictions may be nil,
+// in which case departures are schedule-only; profiles may be nil, in which
+// case no forecasts are made; occupancy may be nil, in which case departures
+// carry no occupancy.
+func NewDepartureService(trips ports.TripRepository, predictions ports.TripUpdateRepository, profiles ports.DelayProfileRepository, occupancy ports.OccupancyRepository) *DepartureService {
+	return &DepartureService{trips: trips, predictions: predictions, profiles: profiles, occupancy: occupancy}
 }
 
-// NextDeparturesAtStop returns the next scheduled departures at a stop.
-func (s *DepartureService) NextDeparturesAtStop(ctx context.Context, stopUUID string, limit int) ([]domain.Departure, error) {
+// NextDeparturesAtStop returns the next departures at a stop, with estimated
+// times and delays filled in from recent real-time predictions, and the
+// occupancy of trips whose vehicle is reporting it, where available. Windows
+// starting more than realtimeWindowStart ahead are schedule-only.
+func (s *DepartureService) NextDeparturesAtStop(ctx context.Context, stopUUID string, limit int, filter domain.DepartureFilter) ([]domain.Departure, error) {
 	if limit <= 0 || limit > 50 {
 		limit = 10
 	}
-	return s.trips.NextDeparturesAtStop(ctx, stopUUID, limit)
+	departures, err := s.trips.NextDeparturesAtStop(ctx, stopUUID, limit, filter)
+	if err != nil || len(departures) == 0 {
+		return departures, err
+	}
+	if filter.After != nil && filter.After.After(time.Now().Add(realtimeWindowStart)) {
+		return departures, nil
+	}
+	s.applyPredictions(ctx, stopUUID, departures)
+	s.applyOccupancy(ctx, departures)
+	return departures, nil
+}
+
+// NextDeparturesNearby returns the next departures from the stops within
+// radiusMeters of a point, each trip from the nearest stop it calls at, with
+// real-time predictions merged in and ordered by when they actually leave.
+func (s *DepartureService) NextDeparturesNearby(ctx context.Context, lat, lon, radiusMeters float64, limit int, filter domain.DepartureFilter) ([]domain.NearbyDeparture, error) {
+	if limit <= 0 || limit > 50 {
+		limit = 20
+	}
+	departures, err := s.trips.NextDeparturesNearby(ctx, lat, lon, radiusMeters, nearbyDepartureHorizon, limit, filter)
+	if err != nil || len(departures) == 0 {
+		return departures, err
+	}
+	s.applyNearbyPredictions(ctx, departures)
+	sort.SliceStable(departures, func(i, j int) bool {
+		return departs(departures[i].Departure).Before(departs(departures[j].Departure))
+	})
+	return departures, nil
+}
+
+// applyNearbyPredictions is applyPredictions for departures from several
+// stops, read at once.
+func (s *DepartureService) applyNearbyPredictions(ctx context.Context, departures []domain.NearbyDeparture) {
+	if s.predictions == nil {
+		return
+	}
+	seen := make(map[string]bool)
+	var stopIDs []string
+	for _, d := range departures {
+		if !seen[d.Stop.ID] {
+			seen[d.Stop.ID] = true
+			stopIDs = append(stopIDs, d.Stop.ID)
+		}
+	}
+	preds, err := s.predictions.LatestAtStops(ctx, stopIDs, time.Now().Add(-predictionMaxAge))
+	if err != nil {
+		return
+	}
+	type tripStop struct{ trip, stop string }
+	byTripStop := make(map[tripStop]domain.StopTimePrediction, len(preds))
+	for _, p := range preds {
+		byTripStop[tripStop{p.TripID, p.StopID}] = p
+	}
+
+	for i := range departures {
+		d := &departures[i]
+		if d.Trip == nil {
+			continue
+		}
+		if p, ok := byTripStop[tripStop{d.Trip.ID, d.Stop.ID}]; ok {
+			applyPrediction(&d.Departure, p)
+		}
+	}
+}
+
+// departs is when a departure is expected to leave.
+func departs(d domain.Departure) time.Time {
+	if d.EstimatedTime != nil {
+		return *d.EstimatedTime
+	}
+	return d.ScheduledTime
+}
+
+// applyPredictions sets estimated times from recent predictions at the stop.
+// Predictions are best-effort: departures keep the schedule if they cannot
+// be read.
+func (s *DepartureService) applyPredictions(ctx context.Context, stopUUID string, departures []domain.Departure) {
+	if s.predictions == nil {
+		return
+	}
+	preds, err := s.predictions.LatestAtStop(ctx, stopUUID, time.Now().Add(-predictionMaxAge))
+	if err != nil {
+		return
+	}
+	byTrip := make(map[string]domain.StopTimePrediction, len(preds))
+	for _, p := range preds {
+		byTrip[p.TripID] = p
+	}
+
+	for i := range departures {
+		d := &departures[i]
+		if d.Trip == nil {
+			continue
+		}
+		if p, ok := byTrip[d.Trip.ID]; ok {
+			applyPrediction(d, p)
+		}
+	}
+}
+
+// applyOccupancy sets the occupancy last reported by each trip's vehicle.
+// Like predictions, it is best-effort.
+func (s *DepartureService) applyOccupancy(ctx context.Context, departures []domain.Departure) {
+	if s.occupancy == nil {
+		return
+	}
+	var tripIDs []string
+	for _, d := range departures {
+		if d.Trip != nil {
+			tripIDs = append(tripIDs, d.Trip.ID)
+		}
+	}
+	if len(tripIDs) == 0 {
+		return
+	}
+	reports, err := s.occupancy.LatestByTrips(ctx, tripIDs, time.Now().Add(-occupancyMaxAge))
+	if err != nil {
+		return
+	}
+	byTrip := make(map[string]domain.VehicleOccupancy, len(reports))
+	for _, o := range reports {
+		o.Level = occupancyLevel(o.Status)
+		byTrip[o.TripID] = o
+	}
+
+	for i := range departures {
+		d := &departures[i]
+		if d.Trip == nil {
+			continue
+		}
+		if o, ok := byTrip[d.Trip.ID]; ok {
+			d.Occupancy = &o
+		}
+	}
+}
+
+// NextDeparturesWithForecast is NextDeparturesAtStop, with departures in the
+// next hour that have no real-time data annotated with the delay band their
+// route usually has at the stop for that weekday and hour.
+func (s *DepartureService) NextDeparturesWithForecast(ctx context.Context, stopUUID string, limit int, filter domain.DepartureFilter) ([]domain.Departure, error) {
+	departures, err := s.NextDeparturesAtStop(ctx, stopUUID, limit, filter)
+	if err != nil || s.profiles == nil || len(departures) == 0 {
+		return departures, err
+	}
+
+	// Forecasts are best-effort, like predictions.
+	now := time.Now()
+	profiles, err := s.profiles.StopProfiles(ctx, stopUUID, now.Add(-forecastHistory))
+	if err != nil {
+		return departures, nil
+	}
+	type slot struct {
+		route   string
+		weekday time.Weekday
+		hour    int
+	}
+	bySlot := make(map[slot]domain.DelayProfile, len(profiles))
+	for _, p := range profiles {
+		if p.Samples >= forecastMinSamples {
+			bySlot[slot{p.RouteID, p.Weekday, p.Hour}] = p
+		}
+	}
+
+	horizon := now.Add(forecastHorizon)
+	for i := range departures {
+		d := &departures[i]
+		if d.Trip == nil || d.Delay != nil || d.ScheduledTime.After(horizon) {
+			continue
+		}
+		p, ok := bySlot[slot{d.Trip.RouteID, d.ScheduledTime.Weekday(), d.ScheduledTime.Hour()}]
+		if !ok {
+			continue
+		}
+		d.Forecast = &domain.DelayForecast{
+			Median:  int(math.Round(p.Median)),
+			Low:     int(math.Round(p.Low)),
+			High:    int(math.Round(p.High)),
+			Samples: p.Samples,
+		}
+	}
+	return departures, nil
+}
+
+// applyPrediction sets EstimatedTime and Delay on a departure. Departure-side
+// values win over arrival-side ones; an absolute predicted time wins over a delay.
+func applyPrediction(d *domain.Departure, p domain.StopTimePrediction) {
+	predicted, delay := p.PredictedDeparture, p.DepartureDelay
+	if predicted == nil && delay == nil {
+		predicted, delay = p.PredictedArrival, p.ArrivalDelay
+	}
+
+	switch {
+	case predicted != nil:
+		est := *predicted
+		secs := int(est.Sub(d.ScheduledTime).Seconds())
+		d.EstimatedTime = &est
+		d.Delay = &secs
+	case delay != nil:
+		est := d.ScheduledTime.Add(time.Duration(*delay) * time.Second)
+		secs := *delay
+		d.EstimatedTime = &est
+		d.Delay = &secs
+	}
 }
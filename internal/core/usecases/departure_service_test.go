@@ -2,7 +2,9 @@ package usecases_test
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/samirrijal/bilbopass/internal/core/domain"
 	"github.com/samirrijal/bilbopass/internal/core/usecases"
@@ -12,23 +14,35 @@ import (
 
 type mockTripRepo struct {
 	nextDeparturesFn func(ctx context.Context, stopUUID string, limit int) ([]domain.Departure, error)
+	stopTimes        map[string][]domain.StopTime // trip ID -> stop times
+	trips            map[string]*domain.Trip
+	filter           domain.DepartureFilter // last filter asked for
+	nearby           []domain.NearbyDeparture
 }
 
-func (m *mockTripRepo) Upsert(ctx context.Context, trip *domain.Trip) error             { return nil }
-func (m *mockTripRepo) UpsertBatch(ctx context.Context, trips []domain.Trip) error      { return nil }
-func (m *mockTripRepo) GetByID(ctx context.Context, id string) (*domain.Trip, error)    { return nil, nil }
+func (m *mockTripRepo) Upsert(ctx context.Context, trip *domain.Trip) error        { return nil }
+func (m *mockTripRepo) UpsertBatch(ctx context.Context, trips []domain.Trip) error { return nil }
+func (m *mockTripRepo) GetByID(ctx context.Context, id string) (*domain.Trip, error) {
+	return m.trips[id], nil
+}
 func (m *mockTripRepo) UpsertStopTimes(ctx context.Context, st []domain.StopTime) error { return nil }
 func (m *mockTripRepo) GetStopTimes(ctx context.Context, tripID string) ([]domain.StopTime, error) {
-	return nil, nil
+	return m.stopTimes[tripID], nil
 }
 
-func (m *mockTripRepo) NextDeparturesAtStop(ctx context.Context, stopUUID string, limit int) ([]domain.Departure, error) {
+func (m *mockTripRepo) NextDeparturesAtStop(ctx context.Context, stopUUID string, limit int, filter domain.DepartureFilter) ([]domain.Departure, error) {
+	m.filter = filter
 	if m.nextDeparturesFn != nil {
 		return m.nextDeparturesFn(ctx, stopUUID, limit)
 	}
 	return nil, nil
 }
 
+func (m *mockTripRepo) NextDeparturesNearby(ctx context.Context, lat, lon, radiusMeters float64, horizon time.Duration, limit int, filter domain.DepartureFilter) ([]domain.NearbyDeparture, error) {
+	m.filter = filter
+	return m.nearby, nil
+}
+
 func TestDepartureService_NextDepartures(t *testing.T) {
 	repo := &mockTripRepo{
 		nextDeparturesFn: func(ctx context.Context, stopUUID string, limit int) ([]domain.Departure, error) {
@@ -39,8 +53,8 @@ func TestDepartureService_NextDepartures(t *testing.T) {
 		},
 	}
 
-	svc := usecases.NewDepartureService(repo)
-	deps, err := svc.NextDeparturesAtStop(context.Background(), "stop-uuid", 5)
+	svc := usecases.NewDepartureService(repo, nil, nil, nil)
+	deps, err := svc.NextDeparturesAtStop(context.Background(), "stop-uuid", 5, domain.DepartureFilter{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -62,8 +76,8 @@ func TestDepartureService_ClampLimit(t *testing.T) {
 		},
 	}
 
-	svc := usecases.NewDepartureService(repo)
-	_, _ = svc.NextDeparturesAtStop(context.Background(), "stop-uuid", -5)
+	svc := usecases.NewDepartureService(repo, nil, nil, nil)
+	_, _ = svc.NextDeparturesAtStop(context.Background(), "stop-uuid", -5, domain.DepartureFilter{})
 }
 
 func TestDepartureService_MaxLimit(t *testing.T) {
@@ -76,6 +90,225 @@ func TestDepartureService_MaxLimit(t *testing.T) {
 		},
 	}
 
-	svc := usecases.NewDepartureService(repo)
-	_, _ = svc.NextDeparturesAtStop(context.Background(), "stop-uuid", 100)
+	svc := usecases.NewDepartureService(repo, nil, nil, nil)
+	_, _ = svc.NextDeparturesAtStop(context.Background(), "stop-uuid", 100, domain.DepartureFilter{})
+}
+
+func TestDepartureService_BikesFilter(t *testing.T) {
+	repo := &mockTripRepo{}
+	svc := usecases.NewDepartureService(repo, nil, nil, nil)
+
+	_, _ = svc.NextDeparturesWithForecast(context.Background(), "stop-uuid", 5, domain.DepartureFilter{Bikes: true})
+	if !repo.filter.Bikes {
+		t.Error("expected the bikes filter passed to the repository")
+	}
+}
+
+// --- Mock TripUpdateRepository ---
+
+type mockTripUpdateRepo struct {
+	preds    []domain.StopTimePrediction
+	inserted []domain.StopTimePrediction
+	err      error
+}
+
+func (m *mockTripUpdateRepo) InsertBatch(ctx context.Context, p []domain.StopTimePrediction) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.inserted = append(m.inserted, p...)
+	return nil
+}
+
+func (m *mockTripUpdateRepo) LatestAtStop(ctx context.Context, stopUUID string, since time.Time) ([]domain.StopTimePrediction, error) {
+	return m.preds, m.err
+}
+
+func (m *mockTripUpdateRepo) LatestAtStops(ctx context.Context, stopUUIDs []string, since time.Time) ([]domain.StopTimePrediction, error) {
+	return m.preds, m.err
+}
+
+func TestDepartureService_MergesPredictions(t *testing.T) {
+	sched := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	predicted := sched.Add(4 * time.Minute)
+	delay := 90
+
+	trips := &mockTripRepo{
+		nextDeparturesFn: func(ctx context.Context, stopUUID string, limit int) ([]domain.Departure, error) {
+			return []domain.Departure{
+				{Trip: &domain.Trip{ID: "t1"}, ScheduledTime: sched},
+				{Trip: &domain.Trip{ID: "t2"}, ScheduledTime: sched},
+				{Trip: &domain.Trip{ID: "t3"}, ScheduledTime: sched},
+			}, nil
+		},
+	}
+	preds := &mockTripUpdateRepo{preds: []domain.StopTimePrediction{
+		{TripID: "t1", PredictedDeparture: &predicted},
+		{TripID: "t2", ArrivalDelay: &delay},
+	}}
+
+	svc := usecases.NewDepartureService(trips, preds, nil, nil)
+	deps, err := svc.NextDeparturesAtStop(context.Background(), "stop-uuid", 5, domain.DepartureFilter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if deps[0].EstimatedTime == nil || !deps[0].EstimatedTime.Equal(predicted) {
+		t.Errorf("t1: expected estimated %v, got %v", predicted, deps[0].EstimatedTime)
+	}
+	if deps[0].Delay == nil || *deps[0].Delay != 240 {
+		t.Errorf("t1: expected delay 240, got %v", deps[0].Delay)
+	}
+	if deps[1].EstimatedTime == nil || !deps[1].EstimatedTime.Equal(sched.Add(90*time.Second)) {
+		t.Errorf("t2: expected estimated from arrival delay, got %v", deps[1].EstimatedTime)
+	}
+	if deps[1].Delay == nil || *deps[1].Delay != 90 {
+		t.Errorf("t2: expected delay 90, got %v", deps[1].Delay)
+	}
+	if deps[2].EstimatedTime != nil || deps[2].Delay != nil {
+		t.Errorf("t3: expected schedule only, got %v / %v", deps[2].EstimatedTime, deps[2].Delay)
+	}
+}
+
+func TestDepartureService_LaterWindowIsScheduleOnly(t *testing.T) {
+	delay := 90
+	trips := &mockTripRepo{
+		nextDeparturesFn: func(ctx context.Context, stopUUID string, limit int) ([]domain.Departure, error) {
+			return []domain.Departure{{Trip: &domain.Trip{ID: "t1"}}}, nil
+		},
+	}
+	preds := &mockTripUpdateRepo{preds: []domain.StopTimePrediction{{TripID: "t1", DepartureDelay: &delay}}}
+	svc := usecases.NewDepartureService(trips, preds, nil, nil)
+
+	// Tomorrow's run of t1 must not take today's delay.
+	tomorrow := time.Now().Add(24 * time.Hour)
+	deps, err := svc.NextDeparturesAtStop(context.Background(), "stop-uuid", 5, domain.DepartureFilter{After: &tomorrow})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deps[0].Delay != nil {
+		t.Errorf("expected schedule only, got a %ds delay", *deps[0].Delay)
+	}
+	if trips.filter.After == nil || !trips.filter.After.Equal(tomorrow) {
+		t.Errorf("expected the window passed to the repository, got %+v", trips.filter)
+	}
+
+	soon := time.Now().Add(30 * time.Minute)
+	deps, _ = svc.NextDeparturesAtStop(context.Background(), "stop-uuid", 5, domain.DepartureFilter{After: &soon})
+	if deps[0].Delay == nil {
+		t.Error("expected the delay merged into a window starting soon")
+	}
+}
+
+func TestDepartureService_PredictionErrorFallsBack(t *testing.T) {
+	trips := &mockTripRepo{
+		nextDeparturesFn: func(ctx context.Context, stopUUID string, limit int) ([]domain.Departure, error) {
+			return []domain.Departure{{Trip: &domain.Trip{ID: "t1"}}}, nil
+		},
+	}
+	svc := usecases.NewDepartureService(trips, &mockTripUpdateRepo{err: errors.New("db down")}, nil, nil)
+	deps, err := svc.NextDeparturesAtStop(context.Background(), "stop-uuid", 5, domain.DepartureFilter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(deps) != 1 || deps[0].Delay != nil {
+		t.Errorf("expected unchanged schedule departure, got %+v", deps)
+	}
+}
+
+// --- Mock DelayProfileRepository ---
+
+type mockDelayProfileRepo struct {
+	profiles []domain.DelayProfile
+}
+
+func (m *mockDelayProfileRepo) StopProfiles(ctx context.Context, stopUUID string, since time.Time) ([]domain.DelayProfile, error) {
+	return m.profiles, nil
+}
+
+func TestDepartureService_Forecast(t *testing.T) {
+	soon := time.Now().Add(20 * time.Minute)
+	later := time.Now().Add(3 * time.Hour)
+	delay := 60
+
+	trips := &mockTripRepo{
+		nextDeparturesFn: func(ctx context.Context, stopUUID string, limit int) ([]domain.Departure, error) {
+			return []domain.Departure{
+				{Trip: &domain.Trip{ID: "t1", RouteID: "r1"}, ScheduledTime: soon},
+				{Trip: &domain.Trip{ID: "t2", RouteID: "r1"}, ScheduledTime: soon, Delay: &delay},
+				{Trip: &domain.Trip{ID: "t3", RouteID: "r2"}, ScheduledTime: soon},
+				{Trip: &domain.Trip{ID: "t4", RouteID: "r1"}, ScheduledTime: later},
+			}, nil
+		},
+	}
+	profiles := &mockDelayProfileRepo{profiles: []domain.DelayProfile{
+		{RouteID: "r1", Weekday: soon.Weekday(), Hour: soon.Hour(), Median: 119.6, Low: 30, High: 240, Samples: 12},
+		{RouteID: "r2", Weekday: soon.Weekday(), Hour: soon.Hour(), Median: 600, Samples: 2},
+		{RouteID: "r1", Weekday: later.Weekday(), Hour: later.Hour(), Median: 60, Samples: 12},
+	}}
+
+	svc := usecases.NewDepartureService(trips, nil, profiles, nil)
+	deps, err := svc.NextDeparturesWithForecast(context.Background(), "stop-uuid", 5, domain.DepartureFilter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := domain.DelayForecast{Median: 120, Low: 30, High: 240, Samples: 12}
+	if deps[0].Forecast == nil || *deps[0].Forecast != want {
+		t.Errorf("t1: expected forecast %+v, got %+v", want, deps[0].Forecast)
+	}
+	if deps[1].Forecast != nil {
+		t.Errorf("t2: expected no forecast with real-time delay, got %+v", deps[1].Forecast)
+	}
+	if deps[2].Forecast != nil {
+		t.Errorf("t3: expected no forecast from too few samples, got %+v", deps[2].Forecast)
+	}
+	if deps[3].Forecast != nil {
+		t.Errorf("t4: expected no forecast beyond the horizon, got %+v", deps[3].Forecast)
+	}
+
+	plain, _ := svc.NextDeparturesAtStop(context.Background(), "stop-uuid", 5, domain.DepartureFilter{})
+	if plain[0].Forecast != nil {
+		t.Errorf("expected no forecast without include_forecast, got %+v", plain[0].Forecast)
+	}
+}
+
+func TestDepartureService_NextDeparturesNearby(t *testing.T) {
+	sched := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	near := &domain.Stop{ID: "s1"}
+	far := &domain.Stop{ID: "s2"}
+	delay := 300
+
+	trips := &mockTripRepo{nearby: []domain.NearbyDeparture{
+		{Departure: domain.Departure{Trip: &domain.Trip{ID: "t1"}, ScheduledTime: sched}, Stop: near},
+		{Departure: domain.Departure{Trip: &domain.Trip{ID: "t2"}, ScheduledTime: sched.Add(2 * time.Minute)}, Stop: far},
+		{Departure: domain.Departure{Trip: &domain.Trip{ID: "t3"}, ScheduledTime: sched.Add(4 * time.Minute)}, Stop: near},
+	}}
+	preds := &mockTripUpdateRepo{preds: []domain.StopTimePrediction{
+		{TripID: "t1", StopID: "s1", DepartureDelay: &delay},
+		// The same trip at another stop does not apply.
+		{TripID: "t2", StopID: "s1", DepartureDelay: &delay},
+	}}
+
+	svc := usecases.NewDepartureService(trips, preds, nil, nil)
+	deps, err := svc.NextDeparturesNearby(context.Background(), 43.26, -2.93, 400, 10, domain.DepartureFilter{Bikes: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !trips.filter.Bikes {
+		t.Error("expected the bikes filter passed to the repository")
+	}
+	var order []string
+	for _, d := range deps {
+		order = append(order, d.Trip.ID)
+	}
+	if len(order) != 3 || order[0] != "t2" || order[1] != "t3" || order[2] != "t1" {
+		t.Fatalf("expected departures by actual time t2, t3, t1, got %v", order)
+	}
+	if deps[2].Delay == nil || *deps[2].Delay != 300 {
+		t.Errorf("expected t1 delayed 300s, got %v", deps[2].Delay)
+	}
+	if deps[0].Delay != nil {
+		t.Errorf("expected t2 on schedule, got a %ds delay", *deps[0].Delay)
+	}
 }
@@ -0,0 +1,80 @@
+package usecases
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+// maxDeviceToken bounds FCM tokens and Web Push endpoints.
+const maxDeviceToken = 4096
+
+var ErrDeviceNotFound = errors.New("device not found")
+
+// DeviceService manages the devices riders receive push notifications on.
+type DeviceService struct {
+	devices ports.DeviceRepository
+}
+
+// NewDeviceService creates a new DeviceService.
+func NewDeviceService(devices ports.DeviceRepository) *DeviceService {
+	return &DeviceService{devices: devices}
+}
+
+// Register validates and stores d for userID. Registering a known token again
+// refreshes it.
+func (s *DeviceService) Register(ctx context.Context, userID string, d *domain.Device) error {
+	d.Token = strings.TrimSpace(d.Token)
+	if err := validateDevice(d); err != nil {
+		return err
+	}
+	d.UserID = userID
+	return s.devices.Register(ctx, d)
+}
+
+// List returns the user's devices, most recently registered first.
+func (s *DeviceService) List(ctx context.Context, userID string) ([]domain.Device, error) {
+	return s.devices.ListByUser(ctx, userID)
+}
+
+// Remove unregisters one of the user's devices.
+func (s *DeviceService) Remove(ctx context.Context, userID, id string) error {
+	ok, err := s.devices.Delete(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+func validateDevice(d *domain.Device) error {
+	if d.Token == "" || len(d.Token) > maxDeviceToken {
+		return fmt.Errorf("token is required (max %d characters)", maxDeviceToken)
+	}
+	switch d.Platform {
+	case domain.DevicePlatformFCM:
+		d.P256dh, d.Auth = "", ""
+	case domain.DevicePlatformWebPush:
+		u, err := url.Parse(d.Token)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("webpush token must be the subscription's https endpoint")
+		}
+		if k, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(d.P256dh, "=")); err != nil || len(k) != 65 {
+			return fmt.Errorf("p256dh must be a base64url P-256 public key")
+		}
+		if k, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(d.Auth, "=")); err != nil || len(k) != 16 {
+			return fmt.Errorf("auth must be a base64url 16-byte secret")
+		}
+	default:
+		return fmt.Errorf("platform must be %q or %q", domain.DevicePlatformFCM, domain.DevicePlatformWebPush)
+	}
+	return nil
+}
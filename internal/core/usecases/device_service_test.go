@@ -0,0 +1,103 @@
+package usecases_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock DeviceRepository ---
+
+type mockDeviceRepo struct {
+	devices []domain.Device
+}
+
+func (m *mockDeviceRepo) Register(ctx context.Context, d *domain.Device) error {
+	d.ID = "dev-" + d.Platform
+	m.devices = append(m.devices, *d)
+	return nil
+}
+
+func (m *mockDeviceRepo) ListByUser(ctx context.Context, userID string) ([]domain.Device, error) {
+	var out []domain.Device
+	for _, d := range m.devices {
+		if d.UserID == userID {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockDeviceRepo) Delete(ctx context.Context, userID, id string) (bool, error) {
+	for i, d := range m.devices {
+		if d.UserID == userID && d.ID == id {
+			m.devices = append(m.devices[:i], m.devices[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *mockDeviceRepo) DeleteByToken(ctx context.Context, token string) error { return nil }
+
+func TestDeviceService_Register(t *testing.T) {
+	repo := &mockDeviceRepo{}
+	svc := usecases.NewDeviceService(repo)
+	ctx := context.Background()
+
+	p256dh := base64.RawURLEncoding.EncodeToString(append([]byte{4}, make([]byte, 64)...))
+	auth := base64.RawURLEncoding.EncodeToString(make([]byte, 16))
+
+	bad := []domain.Device{
+		{Platform: "apns", Token: "abc"},
+		{Platform: domain.DevicePlatformFCM, Token: "  "},
+		{Platform: domain.DevicePlatformWebPush, Token: "http://push.example.com/x", P256dh: p256dh, Auth: auth},
+		{Platform: domain.DevicePlatformWebPush, Token: "https://push.example.com/x", P256dh: "short", Auth: auth},
+		{Platform: domain.DevicePlatformWebPush, Token: "https://push.example.com/x", P256dh: p256dh},
+	}
+	for _, d := range bad {
+		if err := svc.Register(ctx, "u1", &d); err == nil {
+			t.Errorf("expected validation error for %+v", d)
+		}
+	}
+	if len(repo.devices) != 0 {
+		t.Fatalf("invalid devices were stored: %+v", repo.devices)
+	}
+
+	fcm := domain.Device{Platform: domain.DevicePlatformFCM, Token: " fcm-token ", P256dh: p256dh}
+	if err := svc.Register(ctx, "u1", &fcm); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fcm.Token != "fcm-token" || fcm.P256dh != "" || fcm.UserID != "u1" {
+		t.Errorf("expected trimmed fcm device without keys, got %+v", fcm)
+	}
+	wp := domain.Device{Platform: domain.DevicePlatformWebPush, Token: "https://push.example.com/x", P256dh: p256dh, Auth: auth}
+	if err := svc.Register(ctx, "u1", &wp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	list, _ := svc.List(ctx, "u1")
+	if len(list) != 2 {
+		t.Errorf("expected 2 devices, got %d", len(list))
+	}
+}
+
+func TestDeviceService_Remove(t *testing.T) {
+	repo := &mockDeviceRepo{devices: []domain.Device{{ID: "d1", UserID: "u1"}}}
+	svc := usecases.NewDeviceService(repo)
+	ctx := context.Background()
+
+	if err := svc.Remove(ctx, "u2", "d1"); !errors.Is(err, usecases.ErrDeviceNotFound) {
+		t.Errorf("expected ErrDeviceNotFound for another user's device, got %v", err)
+	}
+	if err := svc.Remove(ctx, "u1", "d1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(repo.devices) != 0 {
+		t.Errorf("expected device removed, got %+v", repo.devices)
+	}
+}
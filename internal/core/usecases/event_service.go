@@ -0,0 +1,163 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+const (
+	// eventDefaultRadius is the geofence (meters) of events created without one.
+	eventDefaultRadius = 800
+	// eventMaxRadius keeps a geofence to a neighbourhood; city-wide changes are alerts.
+	eventMaxRadius = 5000
+	// eventServiceLead and eventServiceTail widen an event to its default
+	// service window, covering the crowds arriving and leaving.
+	eventServiceLead = 2 * time.Hour
+	eventServiceTail = 2 * time.Hour
+	// eventMaxListRange bounds the window events can be listed for.
+	eventMaxListRange = 31 * 24 * time.Hour
+)
+
+var ErrEventNotFound = errors.New("event not found")
+
+// EventService manages planned events and the service overlays riders see
+// around them.
+type EventService struct {
+	events ports.EventRepository
+	stops  ports.StopRepository
+}
+
+// NewEventService creates a new EventService.
+func NewEventService(events ports.EventRepository, stops ports.StopRepository) *EventService {
+	return &EventService{events: events, stops: stops}
+}
+
+// List returns events whose service window overlaps [from, to). A zero to
+// means a week after from.
+func (s *EventService) List(ctx context.Context, from, to time.Time) ([]domain.Event, error) {
+	if to.IsZero() {
+		to = from.Add(7 * 24 * time.Hour)
+	}
+	if !to.After(from) || to.Sub(from) > eventMaxListRange {
+		return nil, fmt.Errorf("to must be after from and at most 31 days later")
+	}
+	return s.events.List(ctx, from, to)
+}
+
+// Get returns an event by ID.
+func (s *EventService) Get(ctx context.Context, id string) (*domain.Event, error) {
+	e, err := s.events.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, ErrEventNotFound
+	}
+	return e, nil
+}
+
+// ForStop returns the events whose overlay covers a stop at `at`.
+func (s *EventService) ForStop(ctx context.Context, stopID string, at time.Time) ([]domain.Event, error) {
+	stop, err := s.stops.GetByID(ctx, stopID)
+	if err != nil || stop == nil {
+		return nil, ErrStopNotFound
+	}
+	return s.events.ListActiveNear(ctx, stop.Location, at)
+}
+
+// Create validates and adds an event.
+func (s *EventService) Create(ctx context.Context, e *domain.Event) error {
+	if err := validateEvent(e); err != nil {
+		return err
+	}
+	return s.events.Create(ctx, e)
+}
+
+// Update replaces an event.
+func (s *EventService) Update(ctx context.Context, e *domain.Event) error {
+	if err := validateEvent(e); err != nil {
+		return err
+	}
+	ok, err := s.events.Update(ctx, e)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrEventNotFound
+	}
+	return nil
+}
+
+// Delete removes an event and its overlay.
+func (s *EventService) Delete(ctx context.Context, id string) error {
+	ok, err := s.events.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrEventNotFound
+	}
+	return nil
+}
+
+// validateEvent checks an event and fills in the default geofence and
+// service window.
+func validateEvent(e *domain.Event) error {
+	e.Name = strings.TrimSpace(e.Name)
+	e.Venue = strings.TrimSpace(e.Venue)
+	if e.Kind == "" {
+		e.Kind = domain.EventKindOther
+	}
+	if e.RadiusMeters == 0 {
+		e.RadiusMeters = eventDefaultRadius
+	}
+	switch {
+	case e.Name == "":
+		return fmt.Errorf("name is required")
+	case e.Kind != domain.EventKindSport && e.Kind != domain.EventKindFestival &&
+		e.Kind != domain.EventKindConcert && e.Kind != domain.EventKindOther:
+		return fmt.Errorf("kind must be sport, festival, concert or other")
+	case e.Location.Lat < -90 || e.Location.Lat > 90 || e.Location.Lon < -180 || e.Location.Lon > 180,
+		e.Location.Lat == 0 && e.Location.Lon == 0:
+		return fmt.Errorf("a valid location is required")
+	case e.RadiusMeters < 0 || e.RadiusMeters > eventMaxRadius:
+		return fmt.Errorf("radius_meters must be between 1 and %d", eventMaxRadius)
+	case e.StartsAt.IsZero() || !e.EndsAt.After(e.StartsAt):
+		return fmt.Errorf("starts_at is required and ends_at must be after it")
+	}
+
+	if e.ServiceFrom.IsZero() {
+		e.ServiceFrom = e.StartsAt.Add(-eventServiceLead)
+	}
+	if e.ServiceUntil.IsZero() {
+		e.ServiceUntil = e.EndsAt.Add(eventServiceTail)
+	}
+	if e.ServiceFrom.After(e.StartsAt) || e.ServiceUntil.Before(e.EndsAt) {
+		return fmt.Errorf("the service window must cover the event")
+	}
+
+	for i, imp := range e.Impacts {
+		switch imp.Effect {
+		case domain.EventEffectExtraService, domain.EventEffectReducedService,
+			domain.EventEffectDetour, domain.EventEffectStopClosed:
+		default:
+			return fmt.Errorf("impacts[%d]: unknown effect %q", i, imp.Effect)
+		}
+		if imp.RouteID == "" && imp.StopID == "" {
+			return fmt.Errorf("impacts[%d]: route_id or stop_id is required", i)
+		}
+	}
+	if e.Impacts == nil {
+		e.Impacts = []domain.EventImpact{}
+	}
+	if e.Description == nil {
+		e.Description = map[string]string{}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock EventRepository ---
+
+type mockEventRepo struct {
+	events    map[string]*domain.Event
+	nearPoint domain.GeoPoint
+}
+
+func (m *mockEventRepo) Create(ctx context.Context, e *domain.Event) error {
+	e.ID = "ev-" + e.Name
+	m.events[e.ID] = e
+	return nil
+}
+
+func (m *mockEventRepo) Update(ctx context.Context, e *domain.Event) (bool, error) {
+	if _, ok := m.events[e.ID]; !ok {
+		return false, nil
+	}
+	m.events[e.ID] = e
+	return true, nil
+}
+
+func (m *mockEventRepo) Delete(ctx context.Context, id string) (bool, error) {
+	_, ok := m.events[id]
+	delete(m.events, id)
+	return ok, nil
+}
+
+func (m *mockEventRepo) GetByID(ctx context.Context, id string) (*domain.Event, error) {
+	return m.events[id], nil
+}
+
+func (m *mockEventRepo) List(ctx context.Context, from, to time.Time) ([]domain.Event, error) {
+	var out []domain.Event
+	for _, e := range m.events {
+		if e.ServiceUntil.After(from) && e.ServiceFrom.Before(to) {
+			out = append(out, *e)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockEventRepo) ListActiveNear(ctx context.Context, point domain.GeoPoint, at time.Time) ([]domain.Event, error) {
+	m.nearPoint = point
+	var out []domain.Event
+	for _, e := range m.events {
+		if !e.ServiceFrom.After(at) && e.ServiceUntil.After(at) {
+			out = append(out, *e)
+		}
+	}
+	return out, nil
+}
+
+var sanMames = domain.GeoPoint{Lat: 43.2641, Lon: -2.9494}
+
+func TestEventService_CreateFillsDefaults(t *testing.T) {
+	repo := &mockEventRepo{events: map[string]*domain.Event{}}
+	svc := usecases.NewEventService(repo, &mockStopRepo{})
+	ctx := context.Background()
+	kickoff := time.Date(2026, 3, 14, 18, 30, 0, 0, time.UTC)
+
+	bad := []domain.Event{
+		{Location: sanMames, StartsAt: kickoff, EndsAt: kickoff.Add(2 * time.Hour)},
+		{Name: "Athletic - Real", Kind: "derby", Location: sanMames, StartsAt: kickoff, EndsAt: kickoff.Add(2 * time.Hour)},
+		{Name: "Athletic - Real", StartsAt: kickoff, EndsAt: kickoff.Add(2 * time.Hour)},
+		{Name: "Athletic - Real", Location: sanMames, StartsAt: kickoff, EndsAt: kickoff},
+		{Name: "Athletic - Real", Location: sanMames, StartsAt: kickoff, EndsAt: kickoff.Add(2 * time.Hour),
+			ServiceFrom: kickoff.Add(time.Hour)},
+		{Name: "Athletic - Real", Location: sanMames, StartsAt: kickoff, EndsAt: kickoff.Add(2 * time.Hour),
+			Impacts: []domain.EventImpact{{Effect: "teleport", RouteID: "r1"}}},
+		{Name: "Athletic - Real", Location: sanMames, StartsAt: kickoff, EndsAt: kickoff.Add(2 * time.Hour),
+			Impacts: []domain.EventImpact{{Effect: domain.EventEffectExtraService}}},
+	}
+	for _, e := range bad {
+		if err := svc.Create(ctx, &e); err == nil {
+			t.Errorf("expected validation error for %+v", e)
+		}
+	}
+
+	e := domain.Event{
+		Name: " Athletic - Real ", Kind: domain.EventKindSport, Location: sanMames,
+		StartsAt: kickoff, EndsAt: kickoff.Add(2 * time.Hour),
+		Impacts: []domain.EventImpact{{Effect: domain.EventEffectExtraService, RouteID: "metro-l1"}},
+	}
+	if err := svc.Create(ctx, &e); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e.Name != "Athletic - Real" || e.RadiusMeters != 800 || e.Description == nil {
+		t.Errorf("expected trimmed name, default radius and description, got %+v", e)
+	}
+	if !e.ServiceFrom.Equal(kickoff.Add(-2*time.Hour)) || !e.ServiceUntil.Equal(kickoff.Add(4*time.Hour)) {
+		t.Errorf("expected service window two hours either side, got %v - %v", e.ServiceFrom, e.ServiceUntil)
+	}
+
+	if err := svc.Update(ctx, &domain.Event{ID: "missing", Name: "x", Location: sanMames,
+		StartsAt: kickoff, EndsAt: kickoff.Add(time.Hour)}); !errors.Is(err, usecases.ErrEventNotFound) {
+		t.Errorf("expected ErrEventNotFound, got %v", err)
+	}
+}
+
+func TestEventService_ForStop(t *testing.T) {
+	kickoff := time.Date(2026, 3, 14, 18, 30, 0, 0, time.UTC)
+	repo := &mockEventRepo{events: map[string]*domain.Event{
+		"e1": {ID: "e1", ServiceFrom: kickoff.Add(-2 * time.Hour), ServiceUntil: kickoff.Add(4 * time.Hour)},
+	}}
+	stops := &mockStopRepo{
+		getByIDFn: func(ctx context.Context, id string) (*domain.Stop, error) {
+			if id != "san-mames" {
+				return nil, errors.New("no rows")
+			}
+			return &domain.Stop{ID: id, Location: sanMames}, nil
+		},
+	}
+	svc := usecases.NewEventService(repo, stops)
+	ctx := context.Background()
+
+	events, err := svc.ForStop(ctx, "san-mames", kickoff)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 1 || repo.nearPoint != sanMames {
+		t.Errorf("expected the match near the stop, got %+v", events)
+	}
+	if events, _ := svc.ForStop(ctx, "san-mames", kickoff.Add(5*time.Hour)); len(events) != 0 {
+		t.Errorf("expected no events after the service window, got %+v", events)
+	}
+	if _, err := svc.ForStop(ctx, "unknown", kickoff); !errors.Is(err, usecases.ErrStopNotFound) {
+		t.Errorf("expected ErrStopNotFound, got %v", err)
+	}
+
+	if _, err := svc.List(ctx, kickoff, kickoff.Add(-time.Hour)); err == nil {
+		t.Error("expected error for an inverted range")
+	}
+}
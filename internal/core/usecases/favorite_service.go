@@ -0,0 +1,176 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+const (
+	// maxFavorites bounds how many favorites a rider can save, which also
+	// bounds the departure lookups a dashboard makes.
+	maxFavorites = 50
+	// maxFavoriteLabel is the longest label a rider can give a favorite.
+	maxFavoriteLabel = 60
+)
+
+var (
+	ErrFavoriteExists   = errors.New("already a favorite")
+	ErrFavoriteNotFound = errors.New("favorite not found")
+	ErrTooManyFavorites = fmt.Errorf("at most %d favorites can be saved", maxFavorites)
+	ErrRouteNotFound    = errors.New("route not found")
+)
+
+// FavoriteService manages riders' saved stops, routes and journeys and builds
+// their dashboard.
+type FavoriteService struct {
+	favorites  ports.FavoriteRepository
+	stops      ports.StopRepository
+	routes     ports.RouteRepository
+	departures *DepartureService
+}
+
+// NewFavoriteService creates a new FavoriteService.
+func NewFavoriteService(favorites ports.FavoriteRepository, stops ports.StopRepository, routes ports.RouteRepository, departures *DepartureService) *FavoriteService {
+	return &FavoriteService{favorites: favorites, stops: stops, routes: routes, departures: departures}
+}
+
+// Add saves f for userID. Only the fields of f.Kind are kept; the stops and
+// route it refers to must exist.
+func (s *FavoriteService) Add(ctx context.Context, userID string, f *domain.Favorite) error {
+	f.Label = strings.TrimSpace(f.Label)
+	if len(f.Label) > maxFavoriteLabel {
+		return fmt.Errorf("label must be at most %d characters", maxFavoriteLabel)
+	}
+	switch f.Kind {
+	case domain.FavoriteStop:
+		if f.StopID == "" {
+			return fmt.Errorf("stop_id is required")
+		}
+		f.ToStopID, f.RouteID = "", ""
+	case domain.FavoriteRoute:
+		if f.RouteID == "" {
+			return fmt.Errorf("route_id is required")
+		}
+		f.StopID, f.ToStopID = "", ""
+	case domain.FavoriteJourney:
+		if f.StopID == "" || f.ToStopID == "" {
+			return fmt.Errorf("stop_id and to_stop_id are required")
+		}
+		if f.StopID == f.ToStopID {
+			return fmt.Errorf("a journey needs different origin and destination stops")
+		}
+		f.RouteID = ""
+	default:
+		return fmt.Errorf("kind must be %q, %q or %q", domain.FavoriteStop, domain.FavoriteRoute, domain.FavoriteJourney)
+	}
+
+	if f.StopID != "" {
+		stop, err := s.stops.GetByID(ctx, f.StopID)
+		if err != nil || stop == nil {
+			return ErrStopNotFound
+		}
+		f.StopName = stop.Name
+	}
+	if f.ToStopID != "" {
+		stop, err := s.stops.GetByID(ctx, f.ToStopID)
+		if err != nil || stop == nil {
+			return ErrStopNotFound
+		}
+		f.ToStopName = stop.Name
+	}
+	if f.RouteID != "" {
+		route, err := s.routes.GetByID(ctx, f.RouteID)
+		if err != nil || route == nil {
+			return ErrRouteNotFound
+		}
+		f.RouteName = route.ShortName
+		if f.RouteName == "" {
+			f.RouteName = route.LongName
+		}
+	}
+
+	existing, err := s.favorites.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if len(existing) >= maxFavorites {
+		return ErrTooManyFavorites
+	}
+
+	f.UserID = userID
+	created, err := s.favorites.Create(ctx, f)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return ErrFavoriteExists
+	}
+	return nil
+}
+
+// List returns the rider's favorites, oldest first.
+func (s *FavoriteService) List(ctx context.Context, userID string) ([]domain.Favorite, error) {
+	return s.favorites.ListByUser(ctx, userID)
+}
+
+// Remove deletes one of the rider's favorites.
+func (s *FavoriteService) Remove(ctx context.Context, userID, id string) error {
+	deleted, err := s.favorites.Delete(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrFavoriteNotFound
+	}
+	return nil
+}
+
+// Dashboard returns the rider's favorites with up to limit next departures at
+// each favorite stop. Departures are best-effort: a stop whose departures
+// cannot be read is listed without any.
+func (s *FavoriteService) Dashboard(ctx context.Context, userID string, limit int) (*domain.Dashboard, error) {
+	if limit <= 0 || limit > 10 {
+		limit = 3
+	}
+	favorites, err := s.favorites.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &domain.Dashboard{
+		Stops:    []domain.FavoriteDepartures{},
+		Routes:   []domain.Favorite{},
+		Journeys: []domain.Favorite{},
+	}
+	for _, f := range favorites {
+		switch f.Kind {
+		case domain.FavoriteStop:
+			d.Stops = append(d.Stops, domain.FavoriteDepartures{Favorite: f})
+		case domain.FavoriteRoute:
+			d.Routes = append(d.Routes, f)
+		case domain.FavoriteJourney:
+			d.Journeys = append(d.Journeys, f)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range d.Stops {
+		wg.Add(1)
+		go func(fd *domain.FavoriteDepartures) {
+			defer wg.Done()
+			departures, err := s.departures.NextDeparturesAtStop(ctx, fd.Favorite.StopID, limit, domain.DepartureFilter{})
+			if err != nil || departures == nil {
+				departures = []domain.Departure{}
+			}
+			fd.Departures = departures
+		}(&d.Stops[i])
+	}
+	wg.Wait()
+	return d, nil
+}
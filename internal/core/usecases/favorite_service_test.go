@@ -0,0 +1,162 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock FavoriteRepository ---
+
+type mockFavoriteRepo struct {
+	favorites []domain.Favorite
+}
+
+func (m *mockFavoriteRepo) Create(ctx context.Context, f *domain.Favorite) (bool, error) {
+	for _, e := range m.favorites {
+		if e.UserID == f.UserID && e.Kind == f.Kind && e.StopID == f.StopID &&
+			e.ToStopID == f.ToStopID && e.RouteID == f.RouteID {
+			return false, nil
+		}
+	}
+	f.ID = fmt.Sprintf("fav-%d", len(m.favorites)+1)
+	m.favorites = append(m.favorites, *f)
+	return true, nil
+}
+
+func (m *mockFavoriteRepo) ListByUser(ctx context.Context, userID string) ([]domain.Favorite, error) {
+	var out []domain.Favorite
+	for _, f := range m.favorites {
+		if f.UserID == userID {
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockFavoriteRepo) Delete(ctx context.Context, userID, id string) (bool, error) {
+	for i, f := range m.favorites {
+		if f.UserID == userID && f.ID == id {
+			m.favorites = append(m.favorites[:i], m.favorites[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func newFavoriteService(repo *mockFavoriteRepo, trips *mockTripRepo) *usecases.FavoriteService {
+	stops := &mockStopRepo{
+		getByIDFn: func(ctx context.Context, id string) (*domain.Stop, error) {
+			if id != "s1" && id != "s2" {
+				return nil, errors.New("no rows")
+			}
+			return &domain.Stop{ID: id, Name: "Stop " + id}, nil
+		},
+	}
+	routes := &mockRouteRepo{
+		getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
+			if id != "r1" {
+				return nil, errors.New("no rows")
+			}
+			return &domain.Route{ID: id, ShortName: "L1", LongName: "Etxebarri - Plentzia"}, nil
+		},
+	}
+	return usecases.NewFavoriteService(repo, stops, routes, usecases.NewDepartureService(trips, nil, nil, nil))
+}
+
+func TestFavoriteService_Add(t *testing.T) {
+	repo := &mockFavoriteRepo{}
+	svc := newFavoriteService(repo, &mockTripRepo{})
+	ctx := context.Background()
+
+	bad := []domain.Favorite{
+		{Kind: "line", StopID: "s1"},
+		{Kind: domain.FavoriteStop},
+		{Kind: domain.FavoriteJourney, StopID: "s1"},
+		{Kind: domain.FavoriteJourney, StopID: "s1", ToStopID: "s1"},
+	}
+	for _, f := range bad {
+		if err := svc.Add(ctx, "u1", &f); err == nil {
+			t.Errorf("expected validation error for %+v", f)
+		}
+	}
+
+	if err := svc.Add(ctx, "u1", &domain.Favorite{Kind: domain.FavoriteStop, StopID: "nope"}); !errors.Is(err, usecases.ErrStopNotFound) {
+		t.Errorf("expected ErrStopNotFound, got %v", err)
+	}
+	if err := svc.Add(ctx, "u1", &domain.Favorite{Kind: domain.FavoriteRoute, RouteID: "nope"}); !errors.Is(err, usecases.ErrRouteNotFound) {
+		t.Errorf("expected ErrRouteNotFound, got %v", err)
+	}
+
+	route := domain.Favorite{Kind: domain.FavoriteRoute, RouteID: "r1", StopID: "s1", Label: " Metro "}
+	if err := svc.Add(ctx, "u1", &route); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if route.StopID != "" || route.RouteName != "L1" || route.Label != "Metro" {
+		t.Errorf("expected route favorite with only route fields, got %+v", route)
+	}
+
+	journey := domain.Favorite{Kind: domain.FavoriteJourney, StopID: "s1", ToStopID: "s2"}
+	if err := svc.Add(ctx, "u1", &journey); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if journey.StopName != "Stop s1" || journey.ToStopName != "Stop s2" {
+		t.Errorf("expected stop names filled in, got %+v", journey)
+	}
+	if err := svc.Add(ctx, "u1", &domain.Favorite{Kind: domain.FavoriteJourney, StopID: "s1", ToStopID: "s2"}); !errors.Is(err, usecases.ErrFavoriteExists) {
+		t.Errorf("expected ErrFavoriteExists, got %v", err)
+	}
+}
+
+func TestFavoriteService_Remove(t *testing.T) {
+	repo := &mockFavoriteRepo{favorites: []domain.Favorite{{ID: "f1", UserID: "u1", Kind: domain.FavoriteStop, StopID: "s1"}}}
+	svc := newFavoriteService(repo, &mockTripRepo{})
+	ctx := context.Background()
+
+	if err := svc.Remove(ctx, "u2", "f1"); !errors.Is(err, usecases.ErrFavoriteNotFound) {
+		t.Errorf("expected ErrFavoriteNotFound for another user's favorite, got %v", err)
+	}
+	if err := svc.Remove(ctx, "u1", "f1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestFavoriteService_Dashboard(t *testing.T) {
+	repo := &mockFavoriteRepo{favorites: []domain.Favorite{
+		{ID: "f1", UserID: "u1", Kind: domain.FavoriteStop, StopID: "s1"},
+		{ID: "f2", UserID: "u1", Kind: domain.FavoriteStop, StopID: "s2"},
+		{ID: "f3", UserID: "u1", Kind: domain.FavoriteRoute, RouteID: "r1"},
+		{ID: "f4", UserID: "u1", Kind: domain.FavoriteJourney, StopID: "s1", ToStopID: "s2"},
+	}}
+	trips := &mockTripRepo{
+		nextDeparturesFn: func(ctx context.Context, stopUUID string, limit int) ([]domain.Departure, error) {
+			if stopUUID == "s2" {
+				return nil, errors.New("db down")
+			}
+			out := make([]domain.Departure, limit)
+			for i := range out {
+				out[i].Trip = &domain.Trip{TripID: fmt.Sprintf("%s-%d", stopUUID, i)}
+			}
+			return out, nil
+		},
+	}
+	svc := newFavoriteService(repo, trips)
+
+	d, err := svc.Dashboard(context.Background(), "u1", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(d.Stops) != 2 || len(d.Routes) != 1 || len(d.Journeys) != 1 {
+		t.Fatalf("unexpected dashboard %+v", d)
+	}
+	if got := len(d.Stops[0].Departures); got != 3 {
+		t.Errorf("expected 3 departures by default, got %d", got)
+	}
+	if d.Stops[1].Departures == nil || len(d.Stops[1].Departures) != 0 {
+		t.Errorf("expected empty departures for failing stop, got %v", d.Stops[1].Departures)
+	}
+}
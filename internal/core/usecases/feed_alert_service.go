@@ -0,0 +1,76 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+// FeedAlertSource is the source of the alerts read from GTFS-RT feeds.
+const FeedAlertSource = "gtfs-rt"
+
+// FeedAlertService keeps the service alerts of the agencies' GTFS-RT feeds.
+type FeedAlertService struct {
+	ids    ports.FeedIDResolver
+	alerts ports.AlertRepository
+}
+
+// NewFeedAlertService creates a new FeedAlertService.
+func NewFeedAlertService(ids ports.FeedIDResolver, alerts ports.AlertRepository) *FeedAlertService {
+	return &FeedAlertService{ids: ids, alerts: alerts}
+}
+
+// Replace stores one poll of an agency's alert feed. The alerts are keyed
+// by SourceID and name the feed's routes and stops in RouteIDs and StopIDs,
+// which are resolved to internal IDs; unknown ones are dropped. Alerts no
+// longer in the feed are removed. An alert that fails to store is kept as
+// it was, and the failures are returned together.
+func (s *FeedAlertService) Replace(ctx context.Context, agencyID string, alerts []domain.ServiceAlert) error {
+	var gtfsRoutes, gtfsStops []string
+	for _, a := range alerts {
+		gtfsRoutes = append(gtfsRoutes, a.RouteIDs...)
+		gtfsStops = append(gtfsStops, a.StopIDs...)
+	}
+	routes, err := s.ids.RouteIDs(ctx, agencyID, gtfsRoutes)
+	if err != nil {
+		return fmt.Errorf("resolve routes: %w", err)
+	}
+	stops, err := s.ids.StopIDs(ctx, agencyID, gtfsStops)
+	if err != nil {
+		return fmt.Errorf("resolve stops: %w", err)
+	}
+
+	var errs []error
+	live := make([]string, 0, len(alerts))
+	for _, a := range alerts {
+		a.AgencyID = agencyID
+		a.Source = FeedAlertSource
+		a.RouteIDs = resolveFeedIDs(a.RouteIDs, routes)
+		a.StopIDs = resolveFeedIDs(a.StopIDs, stops)
+		if err := s.alerts.Upsert(ctx, &a); err != nil {
+			errs = append(errs, fmt.Errorf("upsert alert %s: %w", a.SourceID, err))
+		}
+		live = append(live, a.SourceID)
+	}
+	if err := s.alerts.RemoveMissing(ctx, agencyID, FeedAlertSource, live); err != nil {
+		errs = append(errs, fmt.Errorf("expire alerts: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// resolveFeedIDs returns the internal IDs of the GTFS IDs, once each, in
+// order; IDs missing from resolved are dropped.
+func resolveFeedIDs(gtfsIDs []string, resolved map[string]string) []string {
+	ids := []string{}
+	seen := map[string]bool{}
+	for _, gtfsID := range gtfsIDs {
+		if id, ok := resolved[gtfsID]; ok && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,72 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+func TestFeedAlertService_Replace(t *testing.T) {
+	ids := &mockFeedIDResolver{
+		routes: map[string]string{"L1": "route-1", "L2": "route-2"},
+		stops:  map[string]string{"S1": "stop-1"},
+	}
+	alerts := &mockAlertRepo{}
+	svc := usecases.NewFeedAlertService(ids, alerts)
+
+	err := svc.Replace(context.Background(), "a1", []domain.ServiceAlert{
+		{SourceID: "works", Header: map[string]string{"es": "Obras"}, RouteIDs: []string{"L1", "L9", "L1"}, StopIDs: []string{"S1"}},
+		{SourceID: "strike", Header: map[string]string{"es": "Huelga"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts.alerts) != 2 {
+		t.Fatalf("expected 2 alerts stored, got %d", len(alerts.alerts))
+	}
+	works := alerts.alerts[0]
+	if works.AgencyID != "a1" || works.Source != usecases.FeedAlertSource {
+		t.Errorf("expected the agency's feed alert, got %+v", works)
+	}
+	if !reflect.DeepEqual(works.RouteIDs, []string{"route-1"}) || !reflect.DeepEqual(works.StopIDs, []string{"stop-1"}) {
+		t.Errorf("expected known routes and stops resolved once each, got %v and %v", works.RouteIDs, works.StopIDs)
+	}
+	if strike := alerts.alerts[1]; strike.RouteIDs == nil || len(strike.RouteIDs) != 0 {
+		t.Errorf("expected an agency-wide alert with no routes, got %#v", strike.RouteIDs)
+	}
+	if !reflect.DeepEqual(alerts.live, []string{"works", "strike"}) {
+		t.Errorf("expected alerts other than the feed's removed, got %v kept", alerts.live)
+	}
+}
+
+func TestFeedAlertService_Replace_Errors(t *testing.T) {
+	feed := []domain.ServiceAlert{{SourceID: "works"}, {SourceID: "strike"}}
+
+	// A failed alert is still kept live; the rest are stored.
+	alerts := &mockAlertRepo{upsertErr: map[string]error{"works": errors.New("db down")}}
+	err := usecases.NewFeedAlertService(&mockFeedIDResolver{}, alerts).Replace(context.Background(), "a1", feed)
+	if err == nil || !strings.Contains(err.Error(), "upsert alert works: db down") {
+		t.Errorf("expected the upsert error, got %v", err)
+	}
+	if len(alerts.alerts) != 1 || !reflect.DeepEqual(alerts.live, []string{"works", "strike"}) {
+		t.Errorf("expected strike stored and both kept, got %d stored and %v kept", len(alerts.alerts), alerts.live)
+	}
+
+	alerts = &mockAlertRepo{removeErr: errors.New("db down")}
+	err = usecases.NewFeedAlertService(&mockFeedIDResolver{}, alerts).Replace(context.Background(), "a1", feed)
+	if err == nil || !strings.Contains(err.Error(), "expire alerts") {
+		t.Errorf("expected the expiry error, got %v", err)
+	}
+
+	// Nothing is stored or removed when IDs cannot be resolved.
+	alerts = &mockAlertRepo{}
+	err = usecases.NewFeedAlertService(&mockFeedIDResolver{err: errors.New("db down")}, alerts).Replace(context.Background(), "a1", feed)
+	if err == nil || len(alerts.alerts) != 0 || alerts.live != nil {
+		t.Errorf("expected nothing stored or removed, got %v, %d stored, %v kept", err, len(alerts.alerts), alerts.live)
+	}
+}
@@ -0,0 +1,54 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+// FeedConfigService manages per-agency feed settings.
+type FeedConfigService struct {
+	configs  ports.FeedConfigRepository
+	agencies ports.AgencyRepository
+}
+
+// NewFeedConfigService creates a new FeedConfigService.
+func NewFeedConfigService(configs ports.FeedConfigRepository, agencies ports.AgencyRepository) *FeedConfigService {
+	return &FeedConfigService{configs: configs, agencies: agencies}
+}
+
+// Get returns the agency's feed config, or an empty one if none was saved.
+func (s *FeedConfigService) Get(ctx context.Context, agencySlug string) (*domain.FeedConfig, error) {
+	agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+	if err != nil || agency == nil {
+		return nil, ErrAgencyNotFound
+	}
+	c, err := s.configs.Get(ctx, agency.ID)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		c = &domain.FeedConfig{AgencyID: agency.ID}
+	}
+	if c.IDRules == nil {
+		c.IDRules = []domain.IDRule{}
+	}
+	return c, nil
+}
+
+// Update validates and replaces the agency's feed config.
+func (s *FeedConfigService) Update(ctx context.Context, agencySlug string, c *domain.FeedConfig) error {
+	if _, err := NewIDMapper(c.IDRules); err != nil {
+		return err
+	}
+	agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+	if err != nil || agency == nil {
+		return ErrAgencyNotFound
+	}
+	c.AgencyID = agency.ID
+	if c.IDRules == nil {
+		c.IDRules = []domain.IDRule{}
+	}
+	return s.configs.Save(ctx, c)
+}
@@ -0,0 +1,143 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock FeedConfigRepository ---
+
+type mockFeedConfigRepo struct {
+	configs map[string]*domain.FeedConfig
+}
+
+func (m *mockFeedConfigRepo) Get(ctx context.Context, agencyID string) (*domain.FeedConfig, error) {
+	return m.configs[agencyID], nil
+}
+
+func (m *mockFeedConfigRepo) Save(ctx context.Context, c *domain.FeedConfig) error {
+	m.configs[c.AgencyID] = c
+	return nil
+}
+
+func TestIDMapper_Map(t *testing.T) {
+	m, err := usecases.NewIDMapper([]domain.IDRule{
+		{Kind: "trip", Op: "strip_prefix", Value: "BIZKAIBUS:"},
+		{Kind: "trip", Op: "regex", Value: `_(\d+)$`, Replacement: "-$1"},
+		{Kind: "stop", Op: "trim_zeros"},
+		{Kind: "stop", Op: "pad", Width: 4},
+		{Kind: "route", Op: "add_prefix", Value: "MB"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cases := []struct{ kind, in, want string }{
+		{"trip", "BIZKAIBUS:A3247_12", "A3247-12"},
+		{"trip", "A3247", "A3247"},
+		{"stop", "000012", "0012"},
+		{"stop", "12345", "12345"},
+		{"route", "1", "MB1"},
+		{"route", "MB1", "MB1"},
+		{"route", "", ""},
+	}
+	for _, c := range cases {
+		if got := m.Map(c.kind, c.in); got != c.want {
+			t.Errorf("Map(%s, %q) = %q, want %q", c.kind, c.in, got, c.want)
+		}
+	}
+
+	var none *usecases.IDMapper
+	if got := none.Map("trip", "x"); got != "x" {
+		t.Errorf("nil mapper should not change ids, got %q", got)
+	}
+}
+
+func TestIDMapper_RejectsInvalidRules(t *testing.T) {
+	bad := []domain.IDRule{
+		{Kind: "vehicle", Op: "trim_zeros"},
+		{Kind: "trip", Op: "uppercase"},
+		{Kind: "trip", Op: "strip_prefix"},
+		{Kind: "stop", Op: "pad"},
+		{Kind: "stop", Op: "regex", Value: "("},
+	}
+	for _, r := range bad {
+		if _, err := usecases.NewIDMapper([]domain.IDRule{r}); err == nil {
+			t.Errorf("expected error for %+v", r)
+		}
+	}
+}
+
+func TestNormalizeRouteName(t *testing.T) {
+	all := domain.RouteNameRules{FixMojibake: true, StripCodes: true, TitleCase: true}
+	cases := []struct {
+		rules           domain.RouteNameRules
+		short, in, want string
+	}{
+		{all, "A3122", "A3122 - BILBAO-LEIOA (UPV)", "Bilbao-Leioa (Upv)"},
+		{all, "A3122", "BILBAO - LEIOA [A3122]", "Bilbao - Leioa"},
+		{all, "L1", "L1: ETXEBARRI - PLENTZIA", "Etxebarri - Plentzia"},
+		{all, "L1", "L10 ETXEBARRI", "L10 Etxebarri"},
+		{all, "", "HOSPITAL DE CRUCES POR BI-631", "Hospital de Cruces por BI-631"},
+		{all, "", "ZONA III  -  AEROPUERTO", "Zona III - Aeropuerto"},
+		{all, "", "Casco Viejo - DEUSTO", "Casco Viejo - DEUSTO"},
+		{all, "", "SANTURTZI â€“ MUSKIZ", "Santurtzi – Muskiz"},
+		{all, "", "AbandoÃ±", "Abandoñ"},
+		{all, "", "Legaz\xf1a", "Legazña"},
+		{all, "", "Mendizábal", "Mendizábal"},
+		{all, "E1", "E1", "E1"},
+		{domain.RouteNameRules{}, "", "BILBAO  -  LEIOA", "BILBAO  -  LEIOA"},
+		{domain.RouteNameRules{TitleCase: true}, "A1", "A1 - BILBAO", "A1 - Bilbao"},
+	}
+	for _, c := range cases {
+		if got := usecases.NormalizeRouteName(c.rules, c.short, c.in); got != c.want {
+			t.Errorf("NormalizeRouteName(%+v, %q, %q) = %q, want %q", c.rules, c.short, c.in, got, c.want)
+		}
+	}
+}
+
+func TestFeedConfigService_GetAndUpdate(t *testing.T) {
+	repo := &mockFeedConfigRepo{configs: map[string]*domain.FeedConfig{}}
+	agencies := &mockAgencyRepo{
+		getBySlugFn: func(ctx context.Context, slug string) (*domain.Agency, error) {
+			if slug != "bizkaibus" {
+				return nil, errors.New("no rows")
+			}
+			return &domain.Agency{ID: "a1", Slug: slug}, nil
+		},
+	}
+	svc := usecases.NewFeedConfigService(repo, agencies)
+	ctx := context.Background()
+
+	cfg, err := svc.Get(ctx, "bizkaibus")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.AgencyID != "a1" || cfg.IDRules == nil || len(cfg.IDRules) != 0 {
+		t.Errorf("expected empty config for a1, got %+v", cfg)
+	}
+
+	bad := &domain.FeedConfig{IDRules: []domain.IDRule{{Kind: "trip", Op: "pad", Width: 0}}}
+	if err := svc.Update(ctx, "bizkaibus", bad); err == nil {
+		t.Error("expected validation error")
+	}
+	if len(repo.configs) != 0 {
+		t.Error("invalid config should not be saved")
+	}
+
+	good := &domain.FeedConfig{IDRules: []domain.IDRule{{Kind: "trip", Op: "strip_prefix", Value: "BB:"}}}
+	if err := svc.Update(ctx, "unknown", good); !errors.Is(err, usecases.ErrAgencyNotFound) {
+		t.Fatalf("expected ErrAgencyNotFound, got %v", err)
+	}
+	if err := svc.Update(ctx, "bizkaibus", good); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg, _ = svc.Get(ctx, "bizkaibus")
+	if len(cfg.IDRules) != 1 || cfg.IDRules[0].Value != "BB:" {
+		t.Errorf("expected saved rule, got %+v", cfg.IDRules)
+	}
+}
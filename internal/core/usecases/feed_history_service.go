@@ -0,0 +1,72 @@
+package usecases
+
+import (
+	"context"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+// FeedHistoryService answers stop, route and timetable lookups against the
+// feed version that was current at a past time, for research, disputes and
+// checking compensation claims.
+type FeedHistoryService struct {
+	history  ports.FeedHistoryRepository
+	agencies ports.AgencyRepository
+}
+
+// NewFeedHistoryService creates a new FeedHistoryService.
+func NewFeedHistoryService(history ports.FeedHistoryRepository, agencies ports.AgencyRepository) *FeedHistoryService {
+	return &FeedHistoryService{history: history, agencies: agencies}
+}
+
+// Versions returns an agency's feed versions, newest first.
+func (s *FeedHistoryService) Versions(ctx context.Context, agencySlug string) ([]domain.FeedVersion, error) {
+	agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+	if err != nil || agency == nil {
+		return nil, ErrAgencyNotFound
+	}
+	return s.history.Versions(ctx, agency.ID)
+}
+
+// Stop returns the stop as of at.
+func (s *FeedHistoryService) Stop(ctx context.Context, id string, at time.Time) (*domain.Stop, error) {
+	stop, err := s.history.StopAsOf(ctx, id, at)
+	if err != nil {
+		return nil, err
+	}
+	if stop == nil {
+		return nil, ErrStopNotFound
+	}
+	return stop, nil
+}
+
+// Route returns the route as of at.
+func (s *FeedHistoryService) Route(ctx context.Context, id string, at time.Time) (*domain.Route, error) {
+	route, err := s.history.RouteAsOf(ctx, id, at)
+	if err != nil {
+		return nil, err
+	}
+	if route == nil {
+		return nil, ErrRouteNotFound
+	}
+	return route, nil
+}
+
+// Routes returns the agency's routes as of at.
+func (s *FeedHistoryService) Routes(ctx context.Context, agencyID string, at time.Time) ([]domain.Route, error) {
+	return s.history.RoutesAsOf(ctx, agencyID, at)
+}
+
+// StopTimes returns the trip's timetable as of at.
+func (s *FeedHistoryService) StopTimes(ctx context.Context, tripID string, at time.Time) ([]domain.StopTime, error) {
+	times, err := s.history.StopTimesAsOf(ctx, tripID, at)
+	if err != nil {
+		return nil, err
+	}
+	if times == nil {
+		return nil, ErrTripNotFound
+	}
+	return times, nil
+}
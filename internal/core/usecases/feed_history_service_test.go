@@ -0,0 +1,132 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock FeedHistoryRepository ---
+
+// mockFeedHistoryRepo holds stop names and trip timetables as of the
+// versions they were valid from, oldest first.
+type mockFeedHistoryRepo struct {
+	versions  map[string][]domain.FeedVersion // by agency ID
+	stops     map[string][]stopVersion
+	stopTimes map[string][]timetableVersion
+}
+
+type stopVersion struct {
+	from time.Time
+	name string
+}
+
+type timetableVersion struct {
+	from  time.Time
+	times []domain.StopTime
+}
+
+func (m *mockFeedHistoryRepo) Versions(ctx context.Context, agencyID string) ([]domain.FeedVersion, error) {
+	return m.versions[agencyID], nil
+}
+
+func (m *mockFeedHistoryRepo) StopAsOf(ctx context.Context, id string, at time.Time) (*domain.Stop, error) {
+	var stop *domain.Stop
+	for _, v := range m.stops[id] {
+		if !v.from.After(at) {
+			stop = &domain.Stop{ID: id, Name: v.name}
+		}
+	}
+	return stop, nil
+}
+
+func (m *mockFeedHistoryRepo) RouteAsOf(ctx context.Context, id string, at time.Time) (*domain.Route, error) {
+	return nil, nil
+}
+
+func (m *mockFeedHistoryRepo) RoutesAsOf(ctx context.Context, agencyID string, at time.Time) ([]domain.Route, error) {
+	return nil, nil
+}
+
+func (m *mockFeedHistoryRepo) StopTimesAsOf(ctx context.Context, tripID string, at time.Time) ([]domain.StopTime, error) {
+	var times []domain.StopTime
+	for _, v := range m.stopTimes[tripID] {
+		if !v.from.After(at) {
+			times = v.times
+		}
+	}
+	return times, nil
+}
+
+var (
+	feedV1 = time.Date(2026, 1, 10, 3, 0, 0, 0, time.UTC)
+	feedV2 = time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC)
+)
+
+func newFeedHistoryRepo() *mockFeedHistoryRepo {
+	return &mockFeedHistoryRepo{
+		versions: map[string][]domain.FeedVersion{
+			"a1": {{ID: "v2", ActivatedAt: feedV2}, {ID: "v1", ActivatedAt: feedV1, SupersededAt: &feedV2}},
+		},
+		stops: map[string][]stopVersion{
+			"s1": {{feedV1, "Abando"}, {feedV2, "Abando Indalecio Prieto"}},
+		},
+		stopTimes: map[string][]timetableVersion{
+			"t1": {{feedV1, []domain.StopTime{{StopID: "s1", DepartureTime: 8 * time.Hour}}}},
+		},
+	}
+}
+
+func TestFeedHistoryService_Stop(t *testing.T) {
+	svc := usecases.NewFeedHistoryService(newFeedHistoryRepo(), &mockAgencyRepo{})
+	ctx := context.Background()
+
+	stop, err := svc.Stop(ctx, "s1", time.Date(2026, 2, 14, 0, 0, 0, 0, time.UTC))
+	if err != nil || stop.Name != "Abando" {
+		t.Fatalf("expected the name from the first version, got %+v, %v", stop, err)
+	}
+	stop, err = svc.Stop(ctx, "s1", time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC))
+	if err != nil || stop.Name != "Abando Indalecio Prieto" {
+		t.Fatalf("expected the renamed stop, got %+v, %v", stop, err)
+	}
+	if _, err := svc.Stop(ctx, "s1", time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)); !errors.Is(err, usecases.ErrStopNotFound) {
+		t.Errorf("expected ErrStopNotFound before the first version, got %v", err)
+	}
+}
+
+func TestFeedHistoryService_StopTimes(t *testing.T) {
+	svc := usecases.NewFeedHistoryService(newFeedHistoryRepo(), &mockAgencyRepo{})
+	ctx := context.Background()
+
+	times, err := svc.StopTimes(ctx, "t1", time.Date(2026, 2, 14, 0, 0, 0, 0, time.UTC))
+	if err != nil || len(times) != 1 || times[0].DepartureTime != 8*time.Hour {
+		t.Fatalf("expected the archived timetable, got %+v, %v", times, err)
+	}
+	if _, err := svc.StopTimes(ctx, "t2", time.Date(2026, 2, 14, 0, 0, 0, 0, time.UTC)); !errors.Is(err, usecases.ErrTripNotFound) {
+		t.Errorf("expected ErrTripNotFound, got %v", err)
+	}
+}
+
+func TestFeedHistoryService_Versions(t *testing.T) {
+	agencies := &mockAgencyRepo{
+		getBySlugFn: func(ctx context.Context, slug string) (*domain.Agency, error) {
+			if slug != "metro_bilbao" {
+				return nil, nil
+			}
+			return &domain.Agency{ID: "a1", Slug: slug}, nil
+		},
+	}
+	svc := usecases.NewFeedHistoryService(newFeedHistoryRepo(), agencies)
+
+	versions, err := svc.Versions(context.Background(), "metro_bilbao")
+	if err != nil || len(versions) != 2 || versions[0].ID != "v2" {
+		t.Fatalf("unexpected versions: %+v, %v", versions, err)
+	}
+	if _, err := svc.Versions(context.Background(), "nope"); !errors.Is(err, usecases.ErrAgencyNotFound) {
+		t.Errorf("expected ErrAgencyNotFound, got %v", err)
+	}
+}
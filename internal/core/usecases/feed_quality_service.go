@@ -0,0 +1,128 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+// feedQualitySamples is how many unmatched identifiers a report keeps per kind.
+const feedQualitySamples = 20
+
+// Feed quality errors surfaced to the API.
+var (
+	ErrInvalidDay         = errors.New("day must be YYYY-MM-DD")
+	ErrFeedReportNotFound = errors.New("feed quality report not found")
+)
+
+// FeedQualityService reconciles the trip and stop identifiers used by GTFS-RT
+// trip updates against the static schedule.
+type FeedQualityService struct {
+	repo     ports.FeedQualityRepository
+	agencies ports.AgencyRepository
+}
+
+// NewFeedQualityService creates a new FeedQualityService.
+func NewFeedQualityService(repo ports.FeedQualityRepository, agencies ports.AgencyRepository) *FeedQualityService {
+	return &FeedQualityService{repo: repo, agencies: agencies}
+}
+
+// Reconcile stores a report for day for every agency whose RT feed referenced
+// any identifier that day, and returns how many reports were stored. Running
+// it again for the same day replaces the reports.
+func (s *FeedQualityService) Reconcile(ctx context.Context, day time.Time) (int, error) {
+	agencies, err := s.agencies.List(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("list agencies: %w", err)
+	}
+
+	stored := 0
+	for _, a := range agencies {
+		report := &domain.FeedQualityReport{AgencyID: a.ID, Day: day.Format("2006-01-02")}
+		if report.TripIDs, err = s.reconcileKind(ctx, a.ID, day, "trip"); err != nil {
+			return stored, fmt.Errorf("%s trips: %w", a.Slug, err)
+		}
+		if report.StopIDs, err = s.reconcileKind(ctx, a.ID, day, "stop"); err != nil {
+			return stored, fmt.Errorf("%s stops: %w", a.Slug, err)
+		}
+		if report.TripIDs.Seen == 0 && report.StopIDs.Seen == 0 {
+			continue
+		}
+		if err := s.repo.SaveReport(ctx, report); err != nil {
+			return stored, fmt.Errorf("%s save report: %w", a.Slug, err)
+		}
+		stored++
+	}
+	return stored, nil
+}
+
+func (s *FeedQualityService) reconcileKind(ctx context.Context, agencyID string, day time.Time, kind string) (domain.IDMatchStats, error) {
+	stats := domain.IDMatchStats{MatchRate: 100, Unmatched: []domain.UnmatchedID{}}
+	seen, err := s.repo.SeenIDs(ctx, agencyID, day, kind)
+	if err != nil {
+		return stats, err
+	}
+	stats.Seen = len(seen)
+	for _, id := range seen {
+		if id.Matched {
+			stats.Matched++
+		} else {
+			stats.Unmatched = append(stats.Unmatched, domain.UnmatchedID{ID: id.ID, Occurrences: id.Occurrences})
+		}
+	}
+	if stats.Seen > 0 {
+		stats.MatchRate = float64(stats.Matched) / float64(stats.Seen) * 100
+	}
+	if len(stats.Unmatched) == 0 {
+		return stats, nil
+	}
+
+	sort.Slice(stats.Unmatched, func(i, j int) bool {
+		a, b := stats.Unmatched[i], stats.Unmatched[j]
+		if a.Occurrences != b.Occurrences {
+			return a.Occurrences > b.Occurrences
+		}
+		return a.ID < b.ID
+	})
+	if len(stats.Unmatched) > feedQualitySamples {
+		stats.Unmatched = stats.Unmatched[:feedQualitySamples]
+	}
+
+	static, err := s.repo.StaticIDs(ctx, agencyID, kind)
+	if err != nil {
+		return stats, err
+	}
+	matcher := newIDMatcher(static)
+	for i := range stats.Unmatched {
+		u := &stats.Unmatched[i]
+		u.Suggestion, u.Rule = matcher.suggest(u.ID)
+	}
+	return stats, nil
+}
+
+// Report returns the agency's feed quality report for day (YYYY-MM-DD,
+// default: the day before now).
+func (s *FeedQualityService) Report(ctx context.Context, agencySlug, day string, now time.Time) (*domain.FeedQualityReport, error) {
+	if day == "" {
+		day = now.AddDate(0, 0, -1).Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", day); err != nil {
+		return nil, ErrInvalidDay
+	}
+	agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+	if err != nil || agency == nil {
+		return nil, ErrAgencyNotFound
+	}
+	report, err := s.repo.GetReport(ctx, agency.ID, day)
+	if err != nil {
+		return nil, err
+	}
+	if report == nil {
+		return nil, ErrFeedReportNotFound
+	}
+	return report, nil
+}
@@ -0,0 +1,128 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock FeedQualityRepository ---
+
+type mockFeedQualityRepo struct {
+	seen    map[string][]domain.SeenID // agency/kind -> ids
+	static  map[string][]string        // kind -> ids
+	reports map[string]*domain.FeedQualityReport
+}
+
+func (m *mockFeedQualityRepo) RecordSeenIDs(ctx context.Context, agencyID string, day time.Time, kind string, seen map[string]int) error {
+	return nil
+}
+
+func (m *mockFeedQualityRepo) SeenIDs(ctx context.Context, agencyID string, day time.Time, kind string) ([]domain.SeenID, error) {
+	return m.seen[agencyID+"/"+kind], nil
+}
+
+func (m *mockFeedQualityRepo) StaticIDs(ctx context.Context, agencyID, kind string) ([]string, error) {
+	return m.static[kind], nil
+}
+
+func (m *mockFeedQualityRepo) SaveReport(ctx context.Context, r *domain.FeedQualityReport) error {
+	m.reports[r.AgencyID+r.Day] = r
+	return nil
+}
+
+func (m *mockFeedQualityRepo) GetReport(ctx context.Context, agencyID, day string) (*domain.FeedQualityReport, error) {
+	return m.reports[agencyID+day], nil
+}
+
+func newFeedQualityService(repo *mockFeedQualityRepo) *usecases.FeedQualityService {
+	return usecases.NewFeedQualityService(repo, &mockAgencyRepo{
+		listFn: func(ctx context.Context) ([]domain.Agency, error) {
+			return []domain.Agency{{ID: "a1", Slug: "metro_bilbao"}, {ID: "a2", Slug: "no_rt"}}, nil
+		},
+		getBySlugFn: func(ctx context.Context, slug string) (*domain.Agency, error) {
+			if slug != "metro_bilbao" {
+				return nil, errors.New("no rows")
+			}
+			return &domain.Agency{ID: "a1", Slug: slug}, nil
+		},
+	})
+}
+
+func TestFeedQualityService_Reconcile(t *testing.T) {
+	repo := &mockFeedQualityRepo{
+		seen: map[string][]domain.SeenID{
+			"a1/trip": {
+				{ID: "T100", Occurrences: 50, Matched: true},
+				{ID: "METRO_T101", Occurrences: 40},
+				{ID: "t102", Occurrences: 30},
+				{ID: "X999", Occurrences: 90},
+			},
+			"a1/stop": {
+				{ID: "0042", Occurrences: 10},
+			},
+		},
+		static: map[string][]string{
+			"trip": {"T100", "T101", "T102"},
+			"stop": {"42"},
+		},
+		reports: map[string]*domain.FeedQualityReport{},
+	}
+	svc := newFeedQualityService(repo)
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	stored, err := svc.Reconcile(context.Background(), day)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stored != 1 {
+		t.Fatalf("expected a report only for the agency with RT data, got %d", stored)
+	}
+
+	r := repo.reports["a12026-03-01"]
+	if r.TripIDs.Seen != 4 || r.TripIDs.Matched != 1 || r.TripIDs.MatchRate != 25 {
+		t.Errorf("unexpected trip stats %+v", r.TripIDs)
+	}
+	want := []domain.UnmatchedID{
+		{ID: "X999", Occurrences: 90},
+		{ID: "METRO_T101", Occurrences: 40, Suggestion: "T101", Rule: "strip_prefix"},
+		{ID: "t102", Occurrences: 30, Suggestion: "T102", Rule: "case"},
+	}
+	if len(r.TripIDs.Unmatched) != len(want) {
+		t.Fatalf("expected %d unmatched trips, got %+v", len(want), r.TripIDs.Unmatched)
+	}
+	for i, u := range want {
+		if r.TripIDs.Unmatched[i] != u {
+			t.Errorf("unmatched[%d]: expected %+v, got %+v", i, u, r.TripIDs.Unmatched[i])
+		}
+	}
+	if u := r.StopIDs.Unmatched; len(u) != 1 || u[0].Suggestion != "42" || u[0].Rule != "leading_zeros" {
+		t.Errorf("expected zero-padded stop to match 42, got %+v", u)
+	}
+}
+
+func TestFeedQualityService_Report(t *testing.T) {
+	repo := &mockFeedQualityRepo{reports: map[string]*domain.FeedQualityReport{
+		"a12026-02-28": {AgencyID: "a1", Day: "2026-02-28"},
+	}}
+	svc := newFeedQualityService(repo)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	if r, err := svc.Report(ctx, "metro_bilbao", "", now); err != nil || r.Day != "2026-02-28" {
+		t.Errorf("expected yesterday's report, got %+v, %v", r, err)
+	}
+	if _, err := svc.Report(ctx, "metro_bilbao", "2026-02-27", now); !errors.Is(err, usecases.ErrFeedReportNotFound) {
+		t.Errorf("expected ErrFeedReportNotFound, got %v", err)
+	}
+	if _, err := svc.Report(ctx, "metro_bilbao", "yesterday", now); !errors.Is(err, usecases.ErrInvalidDay) {
+		t.Errorf("expected ErrInvalidDay, got %v", err)
+	}
+	if _, err := svc.Report(ctx, "unknown", "", now); !errors.Is(err, usecases.ErrAgencyNotFound) {
+		t.Errorf("expected ErrAgencyNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+var (
+	ErrFeedStageNotFound = errors.New("feed stage not found")
+	ErrFeedStageDecided  = errors.New("feed stage is no longer waiting for approval")
+	ErrNoPreviousFeed    = errors.New("the previous feed version is not kept, nothing to roll back to")
+)
+
+// FeedStageService lets admins approve or reject static feeds staged by the
+// ingestor, and roll back to the previous feed version. Approved feeds are
+// applied by the ingestor's activate run.
+type FeedStageService struct {
+	stages   ports.FeedStageRepository
+	agencies ports.AgencyRepository
+}
+
+// NewFeedStageService creates a new FeedStageService.
+func NewFeedStageService(stages ports.FeedStageRepository, agencies ports.AgencyRepository) *FeedStageService {
+	return &FeedStageService{stages: stages, agencies: agencies}
+}
+
+// List returns the agency's staged, decided and applied feeds, newest first.
+func (s *FeedStageService) List(ctx context.Context, agencySlug string) ([]domain.FeedStage, error) {
+	agencyID, err := s.agencyID(ctx, agencySlug)
+	if err != nil {
+		return nil, err
+	}
+	stages, err := s.stages.List(ctx, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	if stages == nil {
+		stages = []domain.FeedStage{}
+	}
+	return stages, nil
+}
+
+// Approve queues a staged feed to be applied.
+func (s *FeedStageService) Approve(ctx context.Context, agencySlug, id string) (*domain.FeedStage, error) {
+	return s.decide(ctx, agencySlug, id, "approved")
+}
+
+// Reject discards a staged feed; the same feed is not staged again.
+func (s *FeedStageService) Reject(ctx context.Context, agencySlug, id string) (*domain.FeedStage, error) {
+	return s.decide(ctx, agencySlug, id, "rejected")
+}
+
+// Rollback queues the feed of the agency's previous feed version to be
+// applied again, and rejects any feed still waiting to be applied. Agencies
+// that do not require approval get the upstream feed back on the next ingest.
+func (s *FeedStageService) Rollback(ctx context.Context, agencySlug string) (*domain.FeedStage, error) {
+	agencyID, err := s.agencyID(ctx, agencySlug)
+	if err != nil {
+		return nil, err
+	}
+	stage, err := s.stages.Rollback(ctx, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	if stage == nil {
+		return nil, ErrNoPreviousFeed
+	}
+	return stage, nil
+}
+
+func (s *FeedStageService) decide(ctx context.Context, agencySlug, id, status string) (*domain.FeedStage, error) {
+	agencyID, err := s.agencyID(ctx, agencySlug)
+	if err != nil {
+		return nil, err
+	}
+	stage, err := s.stages.Decide(ctx, agencyID, id, status)
+	if err != nil {
+		return nil, err
+	}
+	if stage == nil {
+		return nil, ErrFeedStageNotFound
+	}
+	if stage.Status != status {
+		return nil, ErrFeedStageDecided
+	}
+	return stage, nil
+}
+
+func (s *FeedStageService) agencyID(ctx context.Context, slug string) (string, error) {
+	agency, err := s.agencies.GetBySlug(ctx, slug)
+	if err != nil || agency == nil {
+		return "", ErrAgencyNotFound
+	}
+	return agency.ID, nil
+}
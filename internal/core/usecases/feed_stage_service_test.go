@@ -0,0 +1,97 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock FeedStageRepository ---
+
+type mockFeedStageRepo struct {
+	stages   []domain.FeedStage
+	previous *domain.FeedStage // returned by Rollback
+}
+
+func (m *mockFeedStageRepo) List(ctx context.Context, agencyID string) ([]domain.FeedStage, error) {
+	var out []domain.FeedStage
+	for _, s := range m.stages {
+		if s.AgencyID == agencyID {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockFeedStageRepo) Decide(ctx context.Context, agencyID, id, status string) (*domain.FeedStage, error) {
+	for i := range m.stages {
+		s := &m.stages[i]
+		if s.AgencyID != agencyID || s.ID != id {
+			continue
+		}
+		if s.Status == "staged" {
+			s.Status = status
+		}
+		out := *s
+		return &out, nil
+	}
+	return nil, nil
+}
+
+func (m *mockFeedStageRepo) Rollback(ctx context.Context, agencyID string) (*domain.FeedStage, error) {
+	return m.previous, nil
+}
+
+func TestFeedStageService_Decide(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockFeedStageRepo{stages: []domain.FeedStage{
+		{ID: "s1", AgencyID: "a1", Status: "staged"},
+		{ID: "s2", AgencyID: "a1", Status: "staged"},
+		{ID: "s3", AgencyID: "a2", Status: "staged"},
+	}}
+	svc := usecases.NewFeedStageService(repo, newBrandingAgencies())
+
+	stage, err := svc.Approve(ctx, "metro_bilbao", "s1")
+	if err != nil || stage.Status != "approved" {
+		t.Fatalf("expected s1 approved, got %+v, %v", stage, err)
+	}
+	if _, err := svc.Reject(ctx, "metro_bilbao", "s1"); !errors.Is(err, usecases.ErrFeedStageDecided) {
+		t.Errorf("expected ErrFeedStageDecided rejecting an approved feed, got %v", err)
+	}
+	if stage, err := svc.Reject(ctx, "metro_bilbao", "s2"); err != nil || stage.Status != "rejected" {
+		t.Errorf("expected s2 rejected, got %+v, %v", stage, err)
+	}
+	if _, err := svc.Approve(ctx, "metro_bilbao", "s3"); !errors.Is(err, usecases.ErrFeedStageNotFound) {
+		t.Errorf("expected another agency's stage not found, got %v", err)
+	}
+	if _, err := svc.Approve(ctx, "nope", "s1"); !errors.Is(err, usecases.ErrAgencyNotFound) {
+		t.Errorf("expected ErrAgencyNotFound, got %v", err)
+	}
+
+	stages, err := svc.List(ctx, "metro_bilbao")
+	if err != nil || len(stages) != 2 {
+		t.Errorf("expected the agency's 2 stages, got %+v, %v", stages, err)
+	}
+}
+
+func TestFeedStageService_Rollback(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockFeedStageRepo{}
+	svc := usecases.NewFeedStageService(repo, newBrandingAgencies())
+
+	if _, err := svc.Rollback(ctx, "metro_bilbao"); !errors.Is(err, usecases.ErrNoPreviousFeed) {
+		t.Errorf("expected ErrNoPreviousFeed without a kept feed, got %v", err)
+	}
+	if stages, err := svc.List(ctx, "metro_bilbao"); err != nil || stages == nil {
+		t.Errorf("expected an empty list, got %v, %v", stages, err)
+	}
+
+	repo.previous = &domain.FeedStage{ID: "s9", AgencyID: "a1", Status: "approved", Rollback: true}
+	stage, err := svc.Rollback(ctx, "metro_bilbao")
+	if err != nil || stage.ID != "s9" || !stage.Rollback {
+		t.Errorf("expected the rollback stage, got %+v, %v", stage, err)
+	}
+}
@@ -0,0 +1,46 @@
+package usecases
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+// fleetActiveWindow is how recent a vehicle's latest position must be for
+// the vehicle to count as active.
+const fleetActiveWindow = 10 * time.Minute
+
+// FleetService summarises agencies' live fleets for operators.
+type FleetService struct {
+	vehicles ports.VehiclePositionRepository
+	agencies ports.AgencyRepository
+}
+
+// NewFleetService creates a new FleetService.
+func NewFleetService(vehicles ports.VehiclePositionRepository, agencies ports.AgencyRepository) *FleetService {
+	return &FleetService{vehicles: vehicles, agencies: agencies}
+}
+
+// Agency returns the agency's vehicles active in the last fleetActiveWindow,
+// in total and by route, routes with most vehicles first.
+func (s *FleetService) Agency(ctx context.Context, slug string, now time.Time) (*domain.Fleet, error) {
+	agency, err := s.agencies.GetBySlug(ctx, slug)
+	if err != nil || agency == nil {
+		return nil, ErrAgencyNotFound
+	}
+	fleet, err := s.vehicles.Fleet(ctx, agency.Slug, now.Add(-fleetActiveWindow), now)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(fleet.Routes, func(i, j int) bool {
+		a, b := fleet.Routes[i], fleet.Routes[j]
+		if a.Active != b.Active {
+			return a.Active > b.Active
+		}
+		return a.ShortName < b.ShortName
+	})
+	return fleet, nil
+}
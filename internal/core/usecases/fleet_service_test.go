@@ -0,0 +1,45 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+func TestFleetService_Agency(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC)
+	var since time.Time
+	vehicles := &mockVehicleRepo{
+		fleetFn: func(ctx context.Context, agency string, s, n time.Time) (*domain.Fleet, error) {
+			since = s
+			return &domain.Fleet{
+				AgencySlug: agency,
+				FleetStats: domain.FleetStats{Active: 5},
+				Routes: []domain.RouteFleet{
+					{RouteID: "r2", ShortName: "L2", FleetStats: domain.FleetStats{Active: 2}},
+					{RouteID: "r1", ShortName: "L1", FleetStats: domain.FleetStats{Active: 3}},
+				},
+			}, nil
+		},
+	}
+	svc := usecases.NewFleetService(vehicles, newBrandingAgencies())
+
+	fleet, err := svc.Agency(ctx, "metro_bilbao", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !since.Equal(now.Add(-10 * time.Minute)) {
+		t.Errorf("expected vehicles of the last 10 minutes, got since %s", since)
+	}
+	if fleet.Active != 5 || len(fleet.Routes) != 2 || fleet.Routes[0].RouteID != "r1" {
+		t.Errorf("expected routes with most vehicles first, got %+v", fleet)
+	}
+	if _, err := svc.Agency(ctx, "nope", now); !errors.Is(err, usecases.ErrAgencyNotFound) {
+		t.Errorf("expected ErrAgencyNotFound, got %v", err)
+	}
+}
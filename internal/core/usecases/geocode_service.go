@@ -0,0 +1,203 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+const (
+	// maxGeocodeResults bounds how many places a lookup returns.
+	maxGeocodeResults = 10
+	// describeBudget bounds how long naming the streets of a response may
+	// delay it; points not named by then are left out. The geocoder is
+	// throttled, so cold points mostly wait for their turn.
+	describeBudget = 1500 * time.Millisecond
+	// describeWorkers bounds the lookups naming a response's streets at once.
+	describeWorkers = 8
+)
+
+// ErrGeocoderUnavailable is returned when no geocoder is configured.
+var ErrGeocoderUnavailable = errors.New("geocoding is not configured")
+
+// GeocodeService resolves addresses through a Nominatim or Photon server,
+// caching answers so repeated lookups neither wait for nor load it.
+type GeocodeService struct {
+	geocoder ports.Geocoder
+	cache    ports.CacheService
+	ttl      int // seconds
+}
+
+// NewGeocodeService creates a new GeocodeService caching answers for
+// ttlSeconds. geocoder may be nil, leaving geocoding off.
+func NewGeocodeService(geocoder ports.Geocoder, cache ports.CacheService, ttlSeconds int) *GeocodeService {
+	return &GeocodeService{geocoder: geocoder, cache: cache, ttl: ttlSeconds}
+}
+
+// Geocode returns up to q.Limit places matching q.Text, best first (5 by
+// default). Queries differing only in case and spacing share a cache entry,
+// as do those near points within about a kilometer.
+func (s *GeocodeService) Geocode(ctx context.Context, q domain.GeocodeQuery) ([]domain.Place, error) {
+	if s.geocoder == nil {
+		return nil, ErrGeocoderUnavailable
+	}
+	q.Text = strings.Join(strings.Fields(q.Text), " ")
+	if q.Text == "" {
+		return nil, fmt.Errorf("q must not be empty")
+	}
+	if q.Limit <= 0 || q.Limit > maxGeocodeResults {
+		q.Limit = 5
+	}
+	q.Lang = strings.ToLower(strings.TrimSpace(q.Lang))
+
+	cacheKey := fmt.Sprintf("geocode:%s:%d:%s", q.Lang, q.Limit, strings.ToLower(q.Text))
+	if q.Near != nil {
+		cacheKey += fmt.Sprintf(":%.2f:%.2f", q.Near.Lat, q.Near.Lon)
+	}
+	if s.cache != nil {
+		if data, err := s.cache.Get(ctx, cacheKey); err == nil {
+			var places []domain.Place
+			if err := json.Unmarshal(data, &places); err == nil {
+				return places, nil
+			}
+		}
+	}
+
+	places, err := s.geocoder.Geocode(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	if places == nil {
+		places = []domain.Place{}
+	}
+
+	// Addresses rarely move, so answers, empty ones included, are kept long.
+	if s.cache != nil {
+		if data, err := json.Marshal(places); err == nil {
+			_ = s.cache.Set(ctx, cacheKey, data, s.ttl)
+		}
+	}
+	return places, nil
+}
+
+// Reverse returns the place nearest p with its street and neighborhood, or
+// nil when the geocoder has none. Points within about ten meters share a
+// cache entry.
+func (s *GeocodeService) Reverse(ctx context.Context, p domain.GeoPoint, lang string) (*domain.Place, error) {
+	if s.geocoder == nil {
+		return nil, ErrGeocoderUnavailable
+	}
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	p = domain.GeoPoint{Lat: math.Round(p.Lat*1e4) / 1e4, Lon: math.Round(p.Lon*1e4) / 1e4}
+
+	cacheKey := fmt.Sprintf("geocode:reverse:%s:%.4f:%.4f", lang, p.Lat, p.Lon)
+	if s.cache != nil {
+		if data, err := s.cache.Get(ctx, cacheKey); err == nil {
+			var place *domain.Place
+			if err := json.Unmarshal(data, &place); err == nil {
+				return place, nil
+			}
+		}
+	}
+
+	place, err := s.geocoder.Reverse(ctx, p, lang)
+	if err != nil {
+		return nil, err
+	}
+	// Points without a place are cached too, as null.
+	if s.cache != nil {
+		if data, err := json.Marshal(place); err == nil {
+			_ = s.cache.Set(ctx, cacheKey, data, s.ttl)
+		}
+	}
+	return place, nil
+}
+
+// DescribeWalks names the street and neighborhood of the journeys' walks,
+// by their midpoint, so instructions read "walk along Gran Vía". It is
+// best-effort: walks not named within describeBudget, or without a
+// geocoder, are left as they are.
+func (s *GeocodeService) DescribeWalks(ctx context.Context, journeys []domain.Journey, lang string) {
+	var legs []*domain.JourneyLeg
+	var points []domain.GeoPoint
+	for i := range journeys {
+		for j := range journeys[i].Legs {
+			l := &journeys[i].Legs[j]
+			if !l.Walk || l.FromStop == nil || l.ToStop == nil {
+				continue
+			}
+			legs = append(legs, l)
+			points = append(points, domain.GeoPoint{
+				Lat: (l.FromStop.Location.Lat + l.ToStop.Location.Lat) / 2,
+				Lon: (l.FromStop.Location.Lon + l.ToStop.Location.Lon) / 2,
+			})
+		}
+	}
+	for i, place := range s.describe(ctx, points, lang) {
+		legs[i].Along = place
+	}
+}
+
+// DescribeVehicles names the street and neighborhood each vehicle is on,
+// best-effort like DescribeWalks.
+func (s *GeocodeService) DescribeVehicles(ctx context.Context, vehicles []domain.VehiclePosition, lang string) {
+	points := make([]domain.GeoPoint, len(vehicles))
+	for i, v := range vehicles {
+		points[i] = v.Location
+	}
+	for i, place := range s.describe(ctx, points, lang) {
+		vehicles[i].Place = place
+	}
+}
+
+// describe reverse geocodes points within describeBudget, each distinct
+// point once; places it could not find are nil.
+func (s *GeocodeService) describe(ctx context.Context, points []domain.GeoPoint, lang string) []*domain.Place {
+	places := make([]*domain.Place, len(points))
+	if s.geocoder == nil || len(points) == 0 {
+		return places
+	}
+	ctx, cancel := context.WithTimeout(ctx, describeBudget)
+	defer cancel()
+
+	var mu sync.Mutex
+	found := make(map[domain.GeoPoint]*domain.Place, len(points))
+	unique := make(chan domain.GeoPoint, len(points))
+	seen := make(map[domain.GeoPoint]bool, len(points))
+	for _, p := range points {
+		if !seen[p] {
+			seen[p] = true
+			unique <- p
+		}
+	}
+	close(unique)
+
+	var wg sync.WaitGroup
+	for range min(describeWorkers, len(seen)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range unique {
+				if place, err := s.Reverse(ctx, p, lang); err == nil && place != nil {
+					mu.Lock()
+					found[p] = place
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, p := range points {
+		places[i] = found[p]
+	}
+	return places
+}
@@ -0,0 +1,143 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock Geocoder ---
+
+type mockGeocoder struct {
+	calls int
+	last  domain.GeocodeQuery
+
+	mu       sync.Mutex
+	reversed []domain.GeoPoint
+}
+
+func (m *mockGeocoder) Geocode(ctx context.Context, q domain.GeocodeQuery) ([]domain.Place, error) {
+	m.calls++
+	m.last = q
+	return []domain.Place{{Name: "Gran Vía 1", Location: domain.GeoPoint{Lat: 43.2627, Lon: -2.9353}}}, nil
+}
+
+// Reverse finds Gran Vía north of 43.25 and nothing south of it.
+func (m *mockGeocoder) Reverse(ctx context.Context, p domain.GeoPoint, lang string) (*domain.Place, error) {
+	m.mu.Lock()
+	m.reversed = append(m.reversed, p)
+	m.mu.Unlock()
+	if p.Lat < 43.25 {
+		return nil, nil
+	}
+	return &domain.Place{Name: "Gran Vía", Location: p, Street: "Gran Vía", Neighborhood: "Indautxu"}, nil
+}
+
+// --- Tests ---
+
+func TestGeocodeService_Caches(t *testing.T) {
+	geocoder := &mockGeocoder{}
+	svc := usecases.NewGeocodeService(geocoder, &mockCache{}, 3600)
+
+	places, err := svc.Geocode(context.Background(), domain.GeocodeQuery{Text: "  Gran Vía  1 ", Limit: 50})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(places) != 1 || places[0].Name != "Gran Vía 1" {
+		t.Fatalf("expected Gran Vía 1, got %v", places)
+	}
+	if geocoder.last.Text != "Gran Vía 1" || geocoder.last.Limit != 5 {
+		t.Errorf("expected a normalized query with the default limit, got %+v", geocoder.last)
+	}
+
+	if _, err := svc.Geocode(context.Background(), domain.GeocodeQuery{Text: "gran vía 1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if geocoder.calls != 1 {
+		t.Errorf("expected the second lookup to be served from cache, got %d geocoder calls", geocoder.calls)
+	}
+
+	near := &domain.GeoPoint{Lat: 43.26, Lon: -2.93}
+	if _, err := svc.Geocode(context.Background(), domain.GeocodeQuery{Text: "gran vía 1", Near: near}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if geocoder.calls != 2 {
+		t.Errorf("expected a lookup near a point to miss the cache, got %d geocoder calls", geocoder.calls)
+	}
+}
+
+func TestGeocodeService_Unavailable(t *testing.T) {
+	svc := usecases.NewGeocodeService(nil, nil, 3600)
+	if _, err := svc.Geocode(context.Background(), domain.GeocodeQuery{Text: "Gran Vía 1"}); !errors.Is(err, usecases.ErrGeocoderUnavailable) {
+		t.Errorf("expected ErrGeocoderUnavailable, got %v", err)
+	}
+}
+
+func TestGeocodeService_ReverseCaches(t *testing.T) {
+	geocoder := &mockGeocoder{}
+	svc := usecases.NewGeocodeService(geocoder, &mockCache{}, 3600)
+
+	place, err := svc.Reverse(context.Background(), domain.GeoPoint{Lat: 43.26271, Lon: -2.93532}, "ES")
+	if err != nil || place == nil || place.Street != "Gran Vía" {
+		t.Fatalf("expected Gran Vía, got %+v, %v", place, err)
+	}
+	// Within ten meters, from cache.
+	if place, _ := svc.Reverse(context.Background(), domain.GeoPoint{Lat: 43.26273, Lon: -2.93534}, "es"); place == nil {
+		t.Fatal("expected the cached place")
+	}
+	// Nowhere is cached too.
+	for range 2 {
+		if place, err := svc.Reverse(context.Background(), domain.GeoPoint{Lat: 43.1, Lon: -2.9}, "es"); err != nil || place != nil {
+			t.Fatalf("expected no place, got %+v, %v", place, err)
+		}
+	}
+	if len(geocoder.reversed) != 2 {
+		t.Errorf("expected 2 geocoder lookups, got %v", geocoder.reversed)
+	}
+}
+
+func TestGeocodeService_DescribeWalks(t *testing.T) {
+	geocoder := &mockGeocoder{}
+	svc := usecases.NewGeocodeService(geocoder, nil, 3600)
+
+	abando := &domain.Stop{Name: "Abando", Location: domain.GeoPoint{Lat: 43.2610, Lon: -2.9270}}
+	moyua := &domain.Stop{Name: "Moyua", Location: domain.GeoPoint{Lat: 43.2630, Lon: -2.9350}}
+	walk := domain.JourneyLeg{Walk: true, FromStop: abando, ToStop: moyua}
+	ride := domain.JourneyLeg{Route: &domain.Route{ShortName: "L1"}, FromStop: moyua, ToStop: abando}
+	journeys := []domain.Journey{{Legs: []domain.JourneyLeg{walk, ride}}, {Legs: []domain.JourneyLeg{walk}}}
+
+	svc.DescribeWalks(context.Background(), journeys, "es")
+	for i, j := range journeys {
+		if j.Legs[0].Along == nil || j.Legs[0].Along.Street != "Gran Vía" {
+			t.Errorf("journey %d: expected the walk along Gran Vía, got %+v", i, j.Legs[0].Along)
+		}
+	}
+	if journeys[0].Legs[1].Along != nil {
+		t.Error("expected the ride left alone")
+	}
+	if len(geocoder.reversed) != 1 || geocoder.reversed[0] != (domain.GeoPoint{Lat: 43.262, Lon: -2.931}) {
+		t.Errorf("expected one lookup at the walk's midpoint, got %v", geocoder.reversed)
+	}
+}
+
+func TestGeocodeService_DescribeVehicles(t *testing.T) {
+	svc := usecases.NewGeocodeService(&mockGeocoder{}, nil, 3600)
+	vehicles := []domain.VehiclePosition{
+		{VehicleID: "north", Location: domain.GeoPoint{Lat: 43.2627, Lon: -2.9353}},
+		{VehicleID: "south", Location: domain.GeoPoint{Lat: 43.1, Lon: -2.9}},
+	}
+	svc.DescribeVehicles(context.Background(), vehicles, "")
+	if vehicles[0].Place == nil || vehicles[0].Place.Neighborhood != "Indautxu" {
+		t.Errorf("expected the north vehicle in Indautxu, got %+v", vehicles[0].Place)
+	}
+	if vehicles[1].Place != nil {
+		t.Errorf("expected no place for the south vehicle, got %+v", vehicles[1].Place)
+	}
+
+	// Without a geocoder, vehicles are left as they are.
+	usecases.NewGeocodeService(nil, nil, 3600).DescribeVehicles(context.Background(), vehicles[1:], "")
+}
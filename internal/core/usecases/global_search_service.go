@@ -0,0 +1,102 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+// maxSearchHitsPerType bounds each per-type search limit.
+const maxSearchHitsPerType = 50
+
+// ErrInvalidSearch is returned for searches without a query or with limits
+// out of range.
+var ErrInvalidSearch = errors.New("invalid search")
+
+// DefaultSearchLimits is how many hits of each type a search returns
+// unless asked otherwise.
+var DefaultSearchLimits = domain.SearchLimits{Agencies: 3, Routes: 5, Stops: 10}
+
+// GlobalSearchService searches agencies, routes and stops at once, so
+// frontends need not call stop search and filter route lists themselves.
+type GlobalSearchService struct {
+	repo  ports.GlobalSearchRepository
+	cache ports.CacheService
+}
+
+// NewGlobalSearchService creates a new GlobalSearchService.
+func NewGlobalSearchService(repo ports.GlobalSearchRepository, cache ports.CacheService) *GlobalSearchService {
+	return &GlobalSearchService{repo: repo, cache: cache}
+}
+
+// Search returns the agencies, routes and stops matching query, up to the
+// limit of each type, as one list by score. Ties go to agencies, then
+// routes, then stops.
+func (s *GlobalSearchService) Search(ctx context.Context, query string, limits domain.SearchLimits) ([]domain.SearchHit, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("%w: query must not be empty", ErrInvalidSearch)
+	}
+	for _, n := range []int{limits.Agencies, limits.Routes, limits.Stops} {
+		if n < 0 || n > maxSearchHitsPerType {
+			return nil, fmt.Errorf("%w: limits must be between 0 and %d", ErrInvalidSearch, maxSearchHitsPerType)
+		}
+	}
+
+	cacheKey := fmt.Sprintf("search:%s:%d:%d:%d", query, limits.Agencies, limits.Routes, limits.Stops)
+	if s.cache != nil {
+		if data, err := s.cache.Get(ctx, cacheKey); err == nil {
+			var hits []domain.SearchHit
+			if err := json.Unmarshal(data, &hits); err == nil {
+				return hits, nil
+			}
+		}
+	}
+
+	lookups := []struct {
+		limit int
+		fn    func(context.Context, string, int) ([]domain.SearchHit, error)
+	}{
+		{limits.Agencies, s.repo.Agencies},
+		{limits.Routes, s.repo.Routes},
+		{limits.Stops, s.repo.Stops},
+	}
+	results := make([][]domain.SearchHit, len(lookups))
+	errs := make([]error, len(lookups))
+	var wg sync.WaitGroup
+	for i, l := range lookups {
+		if l.limit == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = lookups[i].fn(ctx, query, lookups[i].limit)
+		}(i)
+	}
+	wg.Wait()
+
+	hits := []domain.SearchHit{}
+	for i := range lookups {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		hits = append(hits, results[i]...)
+	}
+	// The stable sort keeps each type's own order and the type order on ties.
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+
+	if s.cache != nil {
+		if data, err := json.Marshal(hits); err == nil {
+			_ = s.cache.Set(ctx, cacheKey, data, 300)
+		}
+	}
+	return hits, nil
+}
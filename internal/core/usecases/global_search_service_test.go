@@ -0,0 +1,113 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock GlobalSearchRepository ---
+
+type mockGlobalSearchRepo struct {
+	agencies, routes, stops []domain.SearchHit
+
+	mu    sync.Mutex     // types are searched concurrently
+	calls map[string]int // limit asked per type
+}
+
+func (m *mockGlobalSearchRepo) hits(kind string, hits []domain.SearchHit, limit int) ([]domain.SearchHit, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.calls == nil {
+		m.calls = map[string]int{}
+	}
+	m.calls[kind] = limit
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
+
+func (m *mockGlobalSearchRepo) Agencies(ctx context.Context, query string, limit int) ([]domain.SearchHit, error) {
+	return m.hits(domain.SearchHitAgency, m.agencies, limit)
+}
+
+func (m *mockGlobalSearchRepo) Routes(ctx context.Context, query string, limit int) ([]domain.SearchHit, error) {
+	return m.hits(domain.SearchHitRoute, m.routes, limit)
+}
+
+func (m *mockGlobalSearchRepo) Stops(ctx context.Context, query string, limit int) ([]domain.SearchHit, error) {
+	return m.hits(domain.SearchHitStop, m.stops, limit)
+}
+
+// --- Tests ---
+
+func TestGlobalSearchService_Search(t *testing.T) {
+	repo := &mockGlobalSearchRepo{
+		agencies: []domain.SearchHit{
+			{Type: domain.SearchHitAgency, Score: 0.4, Agency: &domain.Agency{Slug: "metro-bilbao"}},
+		},
+		routes: []domain.SearchHit{
+			{Type: domain.SearchHitRoute, Score: 1, Route: &domain.Route{ShortName: "L1"}},
+			{Type: domain.SearchHitRoute, Score: 0.7, Route: &domain.Route{ShortName: "L2"}},
+		},
+		stops: []domain.SearchHit{
+			{Type: domain.SearchHitStop, Score: 1, Stop: &domain.Stop{Name: "Abando"}},
+			{Type: domain.SearchHitStop, Score: 0.8, Stop: &domain.Stop{Name: "Moyua"}},
+			{Type: domain.SearchHitStop, Score: 0.6, Stop: &domain.Stop{Name: "Indautxu"}},
+		},
+	}
+	svc := usecases.NewGlobalSearchService(repo, nil)
+
+	hits, err := svc.Search(context.Background(), " l1 ", domain.SearchLimits{Agencies: 3, Routes: 5, Stops: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, h := range hits {
+		got = append(got, h.Type)
+	}
+	want := []string{"route", "stop", "stop", "route", "agency"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+	if repo.calls[domain.SearchHitStop] != 2 {
+		t.Errorf("expected stops limited to 2, got %d", repo.calls[domain.SearchHitStop])
+	}
+}
+
+func TestGlobalSearchService_Search_SkipsTypes(t *testing.T) {
+	repo := &mockGlobalSearchRepo{}
+	svc := usecases.NewGlobalSearchService(repo, nil)
+
+	hits, err := svc.Search(context.Background(), "abando", domain.SearchLimits{Stops: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hits == nil || len(hits) != 0 {
+		t.Errorf("expected an empty, non-nil list, got %#v", hits)
+	}
+	if _, ok := repo.calls[domain.SearchHitAgency]; ok {
+		t.Error("expected agencies not to be searched with a limit of 0")
+	}
+}
+
+func TestGlobalSearchService_Search_Invalid(t *testing.T) {
+	svc := usecases.NewGlobalSearchService(&mockGlobalSearchRepo{}, nil)
+
+	if _, err := svc.Search(context.Background(), "  ", usecases.DefaultSearchLimits); !errors.Is(err, usecases.ErrInvalidSearch) {
+		t.Errorf("expected ErrInvalidSearch for a blank query, got %v", err)
+	}
+	if _, err := svc.Search(context.Background(), "abando", domain.SearchLimits{Stops: 51}); !errors.Is(err, usecases.ErrInvalidSearch) {
+		t.Errorf("expected ErrInvalidSearch for a limit over 50, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+	"github.com/samirrijal/bilbopass/internal/pkg/geospatial"
+)
+
+// ErrHistoryConsentRequired is returned when logging a journey for a user who
+// has not opted in to trip history.
+var ErrHistoryConsentRequired = errors.New("trip history consent required")
+
+// carGramsPerKm is the per-passenger CO2 baseline for the same trip by car.
+const carGramsPerKm = 170.0
+
+// modeGramsPerKm holds per-passenger CO2 emissions by basic GTFS route_type.
+var modeGramsPerKm = map[int]float64{
+	0:  35,  // tram
+	1:  30,  // metro
+	2:  40,  // rail
+	3:  100, // bus
+	4:  120, // ferry
+	5:  30,  // cable tram
+	6:  30,  // aerial lift
+	7:  30,  // funicular
+	11: 30,  // trolleybus
+	12: 30,  // monorail
+}
+
+// HistoryService records the journeys riders take and summarises them.
+type HistoryService struct {
+	history ports.HistoryRepository
+	stops   ports.StopRepository
+	routes  ports.RouteRepository
+}
+
+// NewHistoryService creates a new HistoryService.
+func NewHistoryService(history ports.HistoryRepository, stops ports.StopRepository, routes ports.RouteRepository) *HistoryService {
+	return &HistoryService{history: history, stops: stops, routes: routes}
+}
+
+// SetConsent grants or revokes trip history logging. Revoking also erases the history.
+func (s *HistoryService) SetConsent(ctx context.Context, userID string, granted bool) error {
+	if err := s.history.SetConsent(ctx, userID, granted); err != nil {
+		return err
+	}
+	if !granted {
+		return s.history.DeleteByUser(ctx, userID)
+	}
+	return nil
+}
+
+// HasConsent reports whether the user opted in to trip history.
+func (s *HistoryService) HasConsent(ctx context.Context, userID string) (bool, error) {
+	return s.history.HasConsent(ctx, userID)
+}
+
+// RecordJourney stores a journey, filling in mode, distance, and CO2 savings.
+// It returns ErrHistoryConsentRequired if the user has not opted in.
+func (s *HistoryService) RecordJourney(ctx context.Context, j *domain.RiderJourney) error {
+	if j.UserID == "" {
+		return fmt.Errorf("user id is required")
+	}
+	if j.DepartedAt.IsZero() {
+		return fmt.Errorf("departed_at is required")
+	}
+
+	ok, err := s.history.HasConsent(ctx, j.UserID)
+	if err != nil {
+		return fmt.Errorf("check consent: %w", err)
+	}
+	if !ok {
+		return ErrHistoryConsentRequired
+	}
+
+	if j.Source == "" {
+		j.Source = "planned"
+	}
+
+	if j.RouteID != "" {
+		if route, err := s.routes.GetByID(ctx, j.RouteID); err == nil && route != nil {
+			j.RouteType = route.RouteType
+		}
+	}
+
+	if j.FromStopID != "" && j.ToStopID != "" {
+		stops, err := s.stops.GetByIDs(ctx, []string{j.FromStopID, j.ToStopID})
+		if err == nil && len(stops) == 2 {
+			j.DistanceMeters = geospatial.Haversine(
+				stops[0].Location.Lat, stops[0].Location.Lon,
+				stops[1].Location.Lat, stops[1].Location.Lon,
+			)
+		}
+	}
+	j.CO2SavedGrams = co2Saved(j.RouteType, j.DistanceMeters)
+
+	return s.history.Insert(ctx, j)
+}
+
+// ListMonth returns the user's journeys in the calendar month containing month.
+func (s *HistoryService) ListMonth(ctx context.Context, userID string, month time.Time) ([]domain.RiderJourney, error) {
+	start := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	return s.history.ListByUser(ctx, userID, start, start.AddDate(0, 1, 0))
+}
+
+// MonthlySummaries returns one summary per month for the last n months up to now,
+// oldest first. Months without journeys are included with zero totals.
+func (s *HistoryService) MonthlySummaries(ctx context.Context, userID string, n int, now time.Time) ([]domain.HistorySummary, error) {
+	if n <= 0 || n > 24 {
+		n = 6
+	}
+	end := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, 1, 0)
+	start := end.AddDate(0, -n, 0)
+
+	journeys, err := s.history.ListByUser(ctx, userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]domain.HistorySummary, n)
+	index := make(map[string]int, n)
+	for i := range summaries {
+		key := start.AddDate(0, i, 0).Format("2006-01")
+		summaries[i] = domain.HistorySummary{Month: key, TripsByRouteType: map[int]int{}}
+		index[key] = i
+	}
+
+	for _, j := range journeys {
+		i, ok := index[j.DepartedAt.In(now.Location()).Format("2006-01")]
+		if !ok {
+			continue
+		}
+		summaries[i].Trips++
+		summaries[i].DistanceMeters += j.DistanceMeters
+		summaries[i].CO2SavedGrams += j.CO2SavedGrams
+		summaries[i].TripsByRouteType[j.RouteType]++
+	}
+	return summaries, nil
+}
+
+// co2Saved estimates grams of CO2 avoided by riding instead of driving.
+func co2Saved(routeType int, distanceMeters float64) float64 {
+	mode, ok := modeGramsPerKm[basicRouteType(routeType)]
+	if !ok {
+		mode = modeGramsPerKm[3]
+	}
+	saved := (carGramsPerKm - mode) * distanceMeters / 1000
+	if saved < 0 {
+		return 0
+	}
+	return saved
+}
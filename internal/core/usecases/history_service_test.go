@@ -0,0 +1,153 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock HistoryRepository ---
+
+type mockHistoryRepo struct {
+	consent  map[string]bool
+	journeys []domain.RiderJourney
+	deleted  bool
+}
+
+func (m *mockHistoryRepo) SetConsent(ctx context.Context, userID string, granted bool) error {
+	if m.consent == nil {
+		m.consent = map[string]bool{}
+	}
+	m.consent[userID] = granted
+	return nil
+}
+
+func (m *mockHistoryRepo) HasConsent(ctx context.Context, userID string) (bool, error) {
+	return m.consent[userID], nil
+}
+
+func (m *mockHistoryRepo) Insert(ctx context.Context, j *domain.RiderJourney) error {
+	j.ID = "j1"
+	m.journeys = append(m.journeys, *j)
+	return nil
+}
+
+func (m *mockHistoryRepo) ListByUser(ctx context.Context, userID string, from, to time.Time) ([]domain.RiderJourney, error) {
+	var out []domain.RiderJourney
+	for _, j := range m.journeys {
+		if j.UserID == userID && !j.DepartedAt.Before(from) && j.DepartedAt.Before(to) {
+			out = append(out, j)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockHistoryRepo) DeleteByUser(ctx context.Context, userID string) error {
+	m.deleted = true
+	m.journeys = nil
+	return nil
+}
+
+func (m *mockHistoryRepo) RidersOnTrip(ctx context.Context, tripID string, from, to time.Time) ([]string, error) {
+	var out []string
+	for _, j := range m.journeys {
+		if j.TripID == tripID && !j.DepartedAt.Before(from) && j.DepartedAt.Before(to) {
+			out = append(out, j.UserID)
+		}
+	}
+	return out, nil
+}
+
+// --- Tests ---
+
+func TestHistoryService_RecordJourney_RequiresConsent(t *testing.T) {
+	svc := usecases.NewHistoryService(&mockHistoryRepo{}, &mockStopRepo{}, &mockRouteRepo{})
+
+	err := svc.RecordJourney(context.Background(), &domain.RiderJourney{UserID: "u1", DepartedAt: time.Now()})
+	if !errors.Is(err, usecases.ErrHistoryConsentRequired) {
+		t.Fatalf("expected ErrHistoryConsentRequired, got %v", err)
+	}
+}
+
+func TestHistoryService_RecordJourney_ComputesCO2(t *testing.T) {
+	repo := &mockHistoryRepo{consent: map[string]bool{"u1": true}}
+	stops := &mockStopRepo{
+		getByIDsFn: func(ctx context.Context, ids []string) ([]domain.Stop, error) {
+			return []domain.Stop{
+				{ID: "s1", Location: domain.GeoPoint{Lat: 43.2630, Lon: -2.9350}},
+				{ID: "s2", Location: domain.GeoPoint{Lat: 43.2720, Lon: -2.9350}},
+			}, nil
+		},
+	}
+	routes := &mockRouteRepo{
+		getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
+			return &domain.Route{ID: id, RouteType: 1}, nil
+		},
+	}
+	svc := usecases.NewHistoryService(repo, stops, routes)
+
+	j := &domain.RiderJourney{UserID: "u1", RouteID: "r1", FromStopID: "s1", ToStopID: "s2", DepartedAt: time.Now()}
+	if err := svc.RecordJourney(context.Background(), j); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if j.Source != "planned" {
+		t.Errorf("expected default source planned, got %q", j.Source)
+	}
+	if j.RouteType != 1 {
+		t.Errorf("expected route type 1, got %d", j.RouteType)
+	}
+	if j.DistanceMeters < 950 || j.DistanceMeters > 1050 {
+		t.Errorf("expected ~1000m, got %f", j.DistanceMeters)
+	}
+	// Metro saves (170 - 30) g/km
+	if j.CO2SavedGrams < 133 || j.CO2SavedGrams > 147 {
+		t.Errorf("expected ~140g CO2 saved, got %f", j.CO2SavedGrams)
+	}
+}
+
+func TestHistoryService_MonthlySummaries(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	repo := &mockHistoryRepo{journeys: []domain.RiderJourney{
+		{UserID: "u1", RouteType: 3, DepartedAt: time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC), DistanceMeters: 2000, CO2SavedGrams: 140},
+		{UserID: "u1", RouteType: 1, DepartedAt: time.Date(2026, 3, 3, 8, 0, 0, 0, time.UTC), DistanceMeters: 1000, CO2SavedGrams: 140},
+		{UserID: "u1", RouteType: 1, DepartedAt: time.Date(2026, 1, 9, 8, 0, 0, 0, time.UTC), DistanceMeters: 500, CO2SavedGrams: 70},
+	}}
+	svc := usecases.NewHistoryService(repo, &mockStopRepo{}, &mockRouteRepo{})
+
+	summaries, err := svc.MonthlySummaries(context.Background(), "u1", 3, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(summaries) != 3 {
+		t.Fatalf("expected 3 months, got %d", len(summaries))
+	}
+	if summaries[0].Month != "2026-01" || summaries[0].Trips != 1 {
+		t.Errorf("unexpected January summary: %+v", summaries[0])
+	}
+	if summaries[1].Trips != 0 {
+		t.Errorf("expected empty February, got %+v", summaries[1])
+	}
+	mar := summaries[2]
+	if mar.Month != "2026-03" || mar.Trips != 2 || mar.DistanceMeters != 3000 || mar.CO2SavedGrams != 280 {
+		t.Errorf("unexpected March summary: %+v", mar)
+	}
+	if mar.TripsByRouteType[1] != 1 || mar.TripsByRouteType[3] != 1 {
+		t.Errorf("unexpected route type breakdown: %v", mar.TripsByRouteType)
+	}
+}
+
+func TestHistoryService_RevokeConsentErasesHistory(t *testing.T) {
+	repo := &mockHistoryRepo{consent: map[string]bool{"u1": true}}
+	svc := usecases.NewHistoryService(repo, &mockStopRepo{}, &mockRouteRepo{})
+
+	if err := svc.SetConsent(context.Background(), "u1", false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !repo.deleted {
+		t.Error("expected history to be deleted on revoke")
+	}
+}
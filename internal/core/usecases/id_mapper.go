@@ -0,0 +1,90 @@
+package usecases
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// maxPadWidth bounds the width of pad rules.
+const maxPadWidth = 64
+
+// IDMapper applies an agency's ID rules to RT identifiers. A nil IDMapper
+// leaves identifiers unchanged.
+type IDMapper struct {
+	rules map[string][]idMapping // kind -> rules in order
+}
+
+type idMapping struct {
+	rule domain.IDRule
+	re   *regexp.Regexp
+}
+
+// NewIDMapper validates rules and compiles them into a mapper.
+func NewIDMapper(rules []domain.IDRule) (*IDMapper, error) {
+	m := &IDMapper{rules: make(map[string][]idMapping)}
+	for i, r := range rules {
+		switch r.Kind {
+		case "trip", "stop", "route":
+		default:
+			return nil, fmt.Errorf("id_rules[%d]: kind must be trip, stop or route", i)
+		}
+		mapping := idMapping{rule: r}
+		switch r.Op {
+		case "strip_prefix", "strip_suffix", "add_prefix":
+			if r.Value == "" {
+				return nil, fmt.Errorf("id_rules[%d]: %s needs a value", i, r.Op)
+			}
+		case "pad":
+			if r.Width <= 0 || r.Width > maxPadWidth {
+				return nil, fmt.Errorf("id_rules[%d]: pad width must be 1-%d", i, maxPadWidth)
+			}
+		case "trim_zeros":
+		case "regex":
+			re, err := regexp.Compile(r.Value)
+			if err != nil || r.Value == "" {
+				return nil, fmt.Errorf("id_rules[%d]: invalid regex %q", i, r.Value)
+			}
+			mapping.re = re
+		default:
+			return nil, fmt.Errorf("id_rules[%d]: unknown op %q", i, r.Op)
+		}
+		m.rules[r.Kind] = append(m.rules[r.Kind], mapping)
+	}
+	return m, nil
+}
+
+// Map rewrites an identifier of kind with the rules for that kind, in order.
+func (m *IDMapper) Map(kind, id string) string {
+	if m == nil || id == "" {
+		return id
+	}
+	for _, mp := range m.rules[kind] {
+		r := mp.rule
+		switch r.Op {
+		case "strip_prefix":
+			id = strings.TrimPrefix(id, r.Value)
+		case "strip_suffix":
+			id = strings.TrimSuffix(id, r.Value)
+		case "add_prefix":
+			if !strings.HasPrefix(id, r.Value) {
+				id = r.Value + id
+			}
+		case "pad":
+			if len(id) < r.Width {
+				id = strings.Repeat("0", r.Width-len(id)) + id
+			}
+		case "trim_zeros":
+			if t := strings.TrimLeft(id, "0"); t != "" {
+				id = t
+			} else {
+				id = "0"
+			}
+		case "regex":
+			id = mp.re.ReplaceAllString(id, r.Replacement)
+		}
+	}
+	return id
+}
@@ -0,0 +1,67 @@
+package usecases
+
+import "strings"
+
+// idSeparators split the parts of transit identifiers such as "METRO_1234".
+const idSeparators = "_:-."
+
+// idRule normalises an identifier; two identifiers with the same normal form
+// under a rule are taken to mean the same thing.
+type idRule struct {
+	name      string
+	normalize func(string) string
+}
+
+// idRules are tried in order, from the most to the least conservative.
+var idRules = []idRule{
+	{"case", strings.ToLower},
+	{"leading_zeros", func(s string) string {
+		if t := strings.TrimLeft(s, "0"); t != "" {
+			return t
+		}
+		return "0"
+	}},
+	{"strip_prefix", func(s string) string {
+		if i := strings.IndexAny(s, idSeparators); i >= 0 {
+			return s[i+1:]
+		}
+		return s
+	}},
+	{"strip_suffix", func(s string) string {
+		if i := strings.LastIndexAny(s, idSeparators); i > 0 {
+			return s[:i]
+		}
+		return s
+	}},
+}
+
+// idMatcher suggests the static identifier an unmatched RT identifier most
+// likely refers to, for common prefix, suffix, case and zero-padding mismatches.
+type idMatcher struct {
+	byRule []map[string][]string // per rule: normal form -> static ids
+}
+
+func newIDMatcher(static []string) *idMatcher {
+	m := &idMatcher{byRule: make([]map[string][]string, len(idRules))}
+	for i, rule := range idRules {
+		index := make(map[string][]string, len(static))
+		for _, id := range static {
+			key := rule.normalize(id)
+			index[key] = append(index[key], id)
+		}
+		m.byRule[i] = index
+	}
+	return m
+}
+
+// suggest returns the single static identifier id normalises to under the
+// first rule that gives an unambiguous answer, and that rule's name.
+func (m *idMatcher) suggest(id string) (string, string) {
+	for i, rule := range idRules {
+		candidates := m.byRule[i][rule.normalize(id)]
+		if len(candidates) == 1 && candidates[0] != id {
+			return candidates[0], rule.name
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,78 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/pkg/geospatial"
+)
+
+const (
+	// maxIsochroneMinutes is the longest time budget of an isochrone.
+	maxIsochroneMinutes = 120
+	// isochroneHullNeighbours is how many nearby stops the hull considers at
+	// each turn; fewer follows the reached stops more closely.
+	isochroneHullNeighbours = 5
+)
+
+// ErrInvalidIsochrone is returned for a time budget out of range.
+var ErrInvalidIsochrone = errors.New("minutes must be between 1 and 120")
+
+// Isochrone returns the stops reachable within minutes of departAt (now when
+// nil) from a stop, or from a point walking to the stops near it, and the
+// concave hull around them. Only the services the filter keeps are taken.
+func (s *JourneyService) Isochrone(ctx context.Context, fromStopID string, from *domain.GeoPoint, departAt *time.Time, minutes, maxTransfers int, filter domain.JourneyFilter) (*domain.Isochrone, error) {
+	if minutes < 1 || minutes > maxIsochroneMinutes {
+		return nil, ErrInvalidIsochrone
+	}
+	if maxTransfers < 0 || maxTransfers > 2 {
+		maxTransfers = 1
+	}
+	depTime := time.Now()
+	if departAt != nil {
+		depTime = *departAt
+	}
+	within := time.Duration(minutes) * time.Minute
+
+	iso := &domain.Isochrone{DepartAt: depTime, Minutes: minutes, Stops: []domain.ReachableStop{}}
+	var origins []domain.StopAccess
+	if from != nil {
+		iso.Origin = *from
+		access, err := s.accessStops(ctx, *from, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range access {
+			if a.Walk <= within {
+				origins = append(origins, a)
+			}
+		}
+	} else {
+		stop, err := s.stops.GetByID(ctx, fromStopID)
+		if err != nil || stop == nil {
+			return nil, ErrStopNotFound
+		}
+		iso.Origin = stop.Location
+		origins = []domain.StopAccess{{StopID: stop.ID}}
+	}
+
+	points := []domain.GeoPoint{iso.Origin}
+	if len(origins) > 0 {
+		reached, err := s.journeys.FindReachable(ctx, origins, depTime, within, maxTransfers, filter)
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(reached, func(a, b int) bool { return reached[a].ArrivalTime.Before(reached[b].ArrivalTime) })
+		for _, r := range reached {
+			r.Minutes = int(math.Ceil(r.ArrivalTime.Sub(depTime).Minutes()))
+			iso.Stops = append(iso.Stops, r)
+			points = append(points, r.Stop.Location)
+		}
+	}
+	iso.Hull = geospatial.ConcaveHull(points, isochroneHullNeighbours)
+	return iso, nil
+}
@@ -0,0 +1,902 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+const (
+	// PlannerWalkRadius is how far apart (meters) two stops may be for a
+	// walking transfer between them.
+	PlannerWalkRadius = 400.0
+	// plannerWalkSpeed is the walking speed (m/s) over the straight-line
+	// distance, low enough to allow for detours and crossings.
+	plannerWalkSpeed = 1.0
+	// minTransferSeconds is the shortest change between two vehicles, at the
+	// same stop or after a walk.
+	minTransferSeconds = 120
+
+	// serviceDay is the length of a service day in seconds; trip times past
+	// it run after midnight.
+	serviceDay = int32(24 * 3600)
+	// serviceDays is how many service days a search spans: the previous one,
+	// for trips still running after midnight, the requested one and the next.
+	serviceDays = 3
+	// lastBoarding is the latest a vehicle is boarded, in seconds from the
+	// start of the previous service day: four hours into the next one, so
+	// journeys carry on past midnight without waiting for the morning.
+	lastBoarding = 2*serviceDay + 4*3600
+
+	noTime = int32(math.MaxInt32)
+)
+
+// modePreference weighs the time on board by basic route type when ranking
+// journeys. Below 1, a ride counts for less than it takes, so a funicular or
+// ferry hop is listed before a journey arriving a little earlier by bus or on
+// foot. Modes not listed weigh 1.
+var modePreference = map[int]float64{
+	4: 0.7, // ferry
+	6: 0.8, // aerial lift
+	7: 0.6, // funicular
+}
+
+// JourneyPlanner implements ports.JourneyRepository with RAPTOR (round-based
+// public transit routing) over the timetable held in memory. Each round adds
+// one vehicle, so journeys with up to maxTransfers changes are found, walking
+// between nearby stops of any agency in between. Trips run by frequency take
+// part once per departure. Every trip runs on every service day, so trips of
+// the previous day still running after midnight are taken, and journeys may
+// carry on with the next day's. Until a timetable is loaded, and for night
+// lines, it uses the fallback repository.
+type JourneyPlanner struct {
+	timetables ports.TimetableRepository
+	fallback   ports.JourneyRepository
+
+	mu sync.RWMutex
+	tt *raptorTimetable
+}
+
+// NewJourneyPlanner creates a JourneyPlanner; call Refresh to load the
+// timetable.
+func NewJourneyPlanner(timetables ports.TimetableRepository, fallback ports.JourneyRepository) *JourneyPlanner {
+	return &JourneyPlanner{timetables: timetables, fallback: fallback}
+}
+
+// Refresh loads the timetable again when a static feed was activated since
+// the last load, and reports whether it did. Journeys are planned on the
+// previous timetable while loading.
+func (p *JourneyPlanner) Refresh(ctx context.Context) (bool, error) {
+	version, err := p.timetables.Version(ctx)
+	if err != nil {
+		return false, err
+	}
+	p.mu.RLock()
+	current := p.tt != nil && p.tt.version.Equal(version)
+	p.mu.RUnlock()
+	if current {
+		return false, nil
+	}
+
+	tt, err := p.timetables.Load(ctx, PlannerWalkRadius)
+	if err != nil {
+		return false, fmt.Errorf("load timetable: %w", err)
+	}
+	zone, err := time.LoadLocation(tt.Timezone)
+	if err != nil {
+		return false, fmt.Errorf("load timetable: %w", err)
+	}
+	rt := buildRaptorTimetable(tt, zone)
+	p.mu.Lock()
+	p.tt = rt
+	p.mu.Unlock()
+	return true, nil
+}
+
+// FindJourneys returns the fastest journeys departing after departAfter,
+// earliest arrival first, with funicular and ferry rides weighed lighter
+// (see modePreference). Journeys needing more changes are only kept when
+// they arrive earlier; later departures are searched until limit journeys
+// are found. Only the services the filter keeps are taken: with Wheelchair,
+// vehicles are only boarded and left at wheelchair-accessible stops.
+func (p *JourneyPlanner) FindJourneys(ctx context.Context, fromStopID, toStopID string, departAfter time.Time, maxTransfers int, limit int, filter domain.JourneyFilter) ([]domain.Journey, error) {
+	tt := p.timetable()
+	if tt == nil {
+		return p.fallback.FindJourneys(ctx, fromStopID, toStopID, departAfter, maxTransfers, limit, filter)
+	}
+	origins := tt.access([]domain.StopAccess{{StopID: fromStopID}})
+	targets := tt.access([]domain.StopAccess{{StopID: toStopID}})
+	return tt.plan(ctx, origins, targets, departAfter, maxTransfers, limit, filter)
+}
+
+// FindJourneysBetween is FindJourneys from and to several stops, each with
+// a walk to or from it.
+func (p *JourneyPlanner) FindJourneysBetween(ctx context.Context, origins, destinations []domain.StopAccess, departAfter time.Time, maxTransfers int, limit int, filter domain.JourneyFilter) ([]domain.Journey, error) {
+	tt := p.timetable()
+	if tt == nil {
+		return p.fallback.FindJourneysBetween(ctx, origins, destinations, departAfter, maxTransfers, limit, filter)
+	}
+	return tt.plan(ctx, tt.access(origins), tt.access(destinations), departAfter, maxTransfers, limit, filter)
+}
+
+// FindReachable runs one search from the origins to every stop, keeping the
+// stops reached in time.
+func (p *JourneyPlanner) FindReachable(ctx context.Context, origins []domain.StopAccess, departAfter time.Time, within time.Duration, maxTransfers int, filter domain.JourneyFilter) ([]domain.ReachableStop, error) {
+	tt := p.timetable()
+	if tt == nil {
+		return p.fallback.FindReachable(ctx, origins, departAfter, within, maxTransfers, filter)
+	}
+	access := tt.access(origins)
+	if len(access) == 0 {
+		return nil, nil
+	}
+
+	clock, at := tt.serviceClock(departAfter)
+
+	search := tt.newSearch(maxTransfers + 1)
+	search.restrict(filter)
+	search.horizon = at + seconds(within) + 1
+	search.run(access, nil, at)
+
+	var out []domain.ReachableStop
+	for stop, a := range search.best {
+		if a >= search.horizon {
+			continue
+		}
+		vehicles := 0
+		for k := len(search.labels) - 1; k > 0; k-- {
+			if l := search.labels[k][stop]; min(l.tripArr, l.walkArr) == a {
+				vehicles = k
+				break
+			}
+		}
+		s := tt.stops[stop]
+		out = append(out, domain.ReachableStop{
+			Stop:        &s,
+			ArrivalTime: clock.time(a),
+			Transfers:   max(vehicles-1, 0),
+		})
+	}
+	return out, nil
+}
+
+// FindNightLines is served by the fallback repository.
+func (p *JourneyPlanner) FindNightLines(ctx context.Context, fromStopID, toStopID string, departAfter time.Time, window time.Duration, radiusMeters float64, limit int) ([]domain.NightLine, error) {
+	return p.fallback.FindNightLines(ctx, fromStopID, toStopID, departAfter, window, radiusMeters, limit)
+}
+
+func (p *JourneyPlanner) timetable() *raptorTimetable {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.tt
+}
+
+// raptorTimetable is the timetable indexed for RAPTOR. Times are seconds
+// since the start of the service day.
+type raptorTimetable struct {
+	version      time.Time
+	zone         *time.Location // of the service days
+	stops        []domain.Stop
+	stopIdx      map[string]int
+	patterns     []*raptorPattern
+	stopPatterns [][]patternStop // patterns calling at each stop
+	transfers    [][]raptorTransfer
+	// Routes with trips both allowing and not allowing bikes
+	bikesRestricted map[string]bool
+}
+
+// raptorPattern is a set of trips of one route calling at the same stops in
+// the same order, none overtaking another, so the first trip departing a
+// stop after some time is also the first to reach every later stop.
+type raptorPattern struct {
+	route *domain.Route
+	stops []int
+	trips []*domain.Trip
+	// Per trip, the headway of trips without exact times, or 0
+	headways []int32
+	// Per trip and stop, at trip*len(stops)+stop
+	arr, dep        []int32
+	pickup, dropOff []bool
+}
+
+type patternStop struct {
+	pattern, pos int
+}
+
+type raptorTransfer struct {
+	to   int
+	walk int32
+}
+
+// raptorAccess is a stop a search starts or ends at, walk seconds away from
+// the requested point.
+type raptorAccess struct {
+	stop int
+	walk int32
+}
+
+// access indexes stops, leaving out those not in the timetable.
+func (tt *raptorTimetable) access(stops []domain.StopAccess) []raptorAccess {
+	var out []raptorAccess
+	for _, a := range stops {
+		if i, ok := tt.stopIdx[a.StopID]; ok {
+			out = append(out, raptorAccess{stop: i, walk: seconds(a.Walk)})
+		}
+	}
+	return out
+}
+
+func (tt *raptorTimetable) plan(ctx context.Context, origins, targets []raptorAccess, departAfter time.Time, maxTransfers int, limit int, filter domain.JourneyFilter) ([]domain.Journey, error) {
+	if limit <= 0 || limit > 20 {
+		limit = 5
+	}
+	if len(origins) == 0 || len(targets) == 0 {
+		return nil, nil
+	}
+
+	// Search from the start of the previous service day
+	clock, at := tt.serviceClock(departAfter)
+
+	search := tt.newSearch(maxTransfers + 1)
+	search.restrict(filter)
+	seen := make(map[string]bool)
+	var journeys []domain.Journey
+	for run := 0; run < limit && len(journeys) < limit; run++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		found := search.run(origins, targets, at)
+		if len(found) == 0 {
+			break
+		}
+		next := noTime
+		for _, j := range found {
+			next = min(next, j.start)
+			if key := j.key(); !seen[key] {
+				seen[key] = true
+				journeys = append(journeys, tt.journey(j, clock))
+			}
+		}
+		at = next + 1
+	}
+
+	rankJourneys(journeys, departAfter)
+	if len(journeys) > limit {
+		journeys = journeys[:limit]
+	}
+	return journeys, nil
+}
+
+// rankJourneys orders journeys by how long they take from departAfter to the
+// arrival, with the time on board of modes in modePreference weighed, then by
+// fewest transfers.
+func rankJourneys(journeys []domain.Journey, departAfter time.Time) {
+	cost := func(j domain.Journey) time.Duration {
+		c := j.ArrivalTime.Sub(departAfter)
+		for _, l := range j.Legs {
+			if l.Route == nil {
+				continue
+			}
+			if w, ok := modePreference[basicRouteType(l.Route.RouteType)]; ok {
+				c -= time.Duration((1 - w) * float64(l.ArrivalTime.Sub(l.Departure.ScheduledTime)))
+			}
+		}
+		return c
+	}
+	sort.SliceStable(journeys, func(a, b int) bool {
+		if ca, cb := cost(journeys[a]), cost(journeys[b]); ca != cb {
+			return ca < cb
+		}
+		return journeys[a].Transfers < journeys[b].Transfers
+	})
+}
+
+// serviceClock reads the times of a search, which count from midnight of
+// the service day before the one searched, in the timetable's zone. Times
+// are read on the wall clock, as timetables are written: on the days clocks
+// change, a service day has an hour more or less in it.
+type serviceClock struct {
+	previous time.Time // midnight of the previous service day
+}
+
+// serviceClock returns the clock of a search departing at t, and t as its
+// time. The service day is t's date in the timetable's zone.
+func (tt *raptorTimetable) serviceClock(t time.Time) (serviceClock, int32) {
+	local := t.In(tt.zone)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, tt.zone)
+	c := serviceClock{previous: today.AddDate(0, 0, -1)}
+	return c, serviceDay + int32(local.Hour()*3600+local.Minute()*60+local.Second())
+}
+
+func (c serviceClock) time(t int32) time.Time {
+	p := c.previous
+	return time.Date(p.Year(), p.Month(), p.Day(), 0, 0, int(t), 0, p.Location())
+}
+
+func buildRaptorTimetable(tt *domain.Timetable, zone *time.Location) *raptorTimetable {
+	rt := &raptorTimetable{
+		version:      tt.Version,
+		zone:         zone,
+		stops:        tt.Stops,
+		stopIdx:      make(map[string]int, len(tt.Stops)),
+		stopPatterns: make([][]patternStop, len(tt.Stops)),
+		transfers:    make([][]raptorTransfer, len(tt.Stops)),
+	}
+	for i, s := range tt.Stops {
+		rt.stopIdx[s.ID] = i
+	}
+	routes := make(map[string]*domain.Route, len(tt.Routes))
+	for i := range tt.Routes {
+		routes[tt.Routes[i].ID] = &tt.Routes[i]
+	}
+	rt.bikesRestricted = bikesRestrictedRoutes(tt.Trips)
+
+	// Group trips by route and stop sequence
+	trips, headways := expandFrequencies(tt.Trips)
+	groups := make(map[string][]int)
+	var keys []string
+	for i := range trips {
+		t := &trips[i]
+		if len(t.StopTimes) < 2 || routes[t.Trip.RouteID] == nil {
+			continue
+		}
+		key := []string{t.Trip.RouteID}
+		for _, st := range t.StopTimes {
+			if _, ok := rt.stopIdx[st.StopID]; !ok {
+				key = nil
+				break
+			}
+			key = append(key, st.StopID)
+		}
+		if key == nil {
+			continue
+		}
+		k := strings.Join(key, "|")
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], i)
+	}
+
+	for _, k := range keys {
+		group := groups[k]
+		sort.SliceStable(group, func(a, b int) bool {
+			return trips[group[a]].StopTimes[0].DepartureTime < trips[group[b]].StopTimes[0].DepartureTime
+		})
+		var patterns []*raptorPattern
+		for _, i := range group {
+			t := &trips[i]
+			var target *raptorPattern
+			for _, p := range patterns {
+				if !p.overtakenBy(t) {
+					target = p
+					break
+				}
+			}
+			if target == nil {
+				target = &raptorPattern{route: routes[t.Trip.RouteID]}
+				for _, st := range t.StopTimes {
+					target.stops = append(target.stops, rt.stopIdx[st.StopID])
+				}
+				patterns = append(patterns, target)
+			}
+			target.add(t, headways[i])
+		}
+		for _, p := range patterns {
+			for pos, s := range p.stops {
+				rt.stopPatterns[s] = append(rt.stopPatterns[s], patternStop{pattern: len(rt.patterns), pos: pos})
+			}
+			rt.patterns = append(rt.patterns, p)
+		}
+	}
+
+	for _, tr := range tt.Transfers {
+		from, ok := rt.stopIdx[tr.FromStopID]
+		if !ok {
+			continue
+		}
+		to, ok := rt.stopIdx[tr.ToStopID]
+		if !ok {
+			continue
+		}
+		walk := max(seconds(walkTime(tr.Distance)), minTransferSeconds)
+		rt.transfers[from] = append(rt.transfers[from], raptorTransfer{to: to, walk: walk})
+	}
+	return rt
+}
+
+// bikesRestrictedRoutes returns the routes where only some trips allow bikes.
+func bikesRestrictedRoutes(trips []domain.TimetableTrip) map[string]bool {
+	allowed := make(map[string]bool)
+	refused := make(map[string]bool)
+	for _, t := range trips {
+		if t.Trip.BikesAllowed {
+			allowed[t.Trip.RouteID] = true
+		} else {
+			refused[t.Trip.RouteID] = true
+		}
+	}
+	restricted := make(map[string]bool)
+	for route := range allowed {
+		if refused[route] {
+			restricted[route] = true
+		}
+	}
+	return restricted
+}
+
+// expandFrequencies returns the trips with one trip per departure of each
+// frequency-based trip, its stop times moved to start then, and per trip the
+// headway of those without exact times, or 0.
+func expandFrequencies(trips []domain.TimetableTrip) ([]domain.TimetableTrip, []int32) {
+	out := make([]domain.TimetableTrip, 0, len(trips))
+	var headways []int32
+	for _, t := range trips {
+		if len(t.Frequencies) == 0 || len(t.StopTimes) == 0 {
+			out = append(out, t)
+			headways = append(headways, 0)
+			continue
+		}
+		first := t.StopTimes[0].DepartureTime
+		for _, f := range t.Frequencies {
+			if f.Headway <= 0 {
+				continue
+			}
+			var headway int32
+			if !f.ExactTimes {
+				headway = seconds(f.Headway)
+			}
+			for start := f.StartTime; start < f.EndTime; start += f.Headway {
+				shift := start - first
+				run := domain.TimetableTrip{Trip: t.Trip, StopTimes: make([]domain.StopTime, len(t.StopTimes))}
+				for i, st := range t.StopTimes {
+					st.ArrivalTime += shift
+					st.DepartureTime += shift
+					run.StopTimes[i] = st
+				}
+				out = append(out, run)
+				headways = append(headways, headway)
+			}
+		}
+	}
+	return out, headways
+}
+
+// overtakenBy reports whether t, departing no earlier than the pattern's
+// last trip, would depart or arrive anywhere before it.
+func (p *raptorPattern) overtakenBy(t *domain.TimetableTrip) bool {
+	n := len(p.stops)
+	last := (len(p.trips) - 1) * n
+	for i, st := range t.StopTimes {
+		if seconds(st.ArrivalTime) < p.arr[last+i] || seconds(st.DepartureTime) < p.dep[last+i] {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *raptorPattern) add(t *domain.TimetableTrip, headway int32) {
+	p.trips = append(p.trips, &t.Trip)
+	p.headways = append(p.headways, headway)
+	for _, st := range t.StopTimes {
+		p.arr = append(p.arr, seconds(st.ArrivalTime))
+		p.dep = append(p.dep, seconds(st.DepartureTime))
+		p.pickup = append(p.pickup, st.PickupType != 1)
+		p.dropOff = append(p.dropOff, st.DropOffType != 1)
+	}
+}
+
+// A search boards trips on each of its service days. Trip i of a search is
+// trip i%len(p.trips) of the pattern on service day i/len(p.trips), its
+// times shifted by as many days.
+
+// tripAt returns the pattern trip of search trip i and the shift of its times.
+func (p *raptorPattern) tripAt(i int) (int, int32) {
+	return i % len(p.trips), int32(i/len(p.trips)) * serviceDay
+}
+
+func (p *raptorPattern) depAt(i, pos int) int32 {
+	t, shift := p.tripAt(i)
+	return p.dep[t*len(p.stops)+pos] + shift
+}
+
+func (p *raptorPattern) arrAt(i, pos int) int32 {
+	t, shift := p.tripAt(i)
+	return p.arr[t*len(p.stops)+pos] + shift
+}
+
+func (p *raptorPattern) dropOffAt(i, pos int) bool {
+	t, _ := p.tripAt(i)
+	return p.dropOff[t*len(p.stops)+pos]
+}
+
+// earliestTrip returns the search trip first boarding at pos at or after at,
+// on any service day up to lastBoarding, or -1. Trips marked in skip, when
+// not nil, are passed over.
+func (p *raptorPattern) earliestTrip(pos int, at int32, skip []bool) int {
+	n := len(p.stops)
+	best, bestDep := -1, lastBoarding+1
+	for day := 0; day < serviceDays; day++ {
+		shift := int32(day) * serviceDay
+		t := sort.Search(len(p.trips), func(t int) bool { return p.dep[t*n+pos]+shift >= at })
+		for ; t < len(p.trips) && p.dep[t*n+pos]+shift < bestDep; t++ {
+			if p.pickup[t*n+pos] && (skip == nil || !skip[t]) {
+				best, bestDep = day*len(p.trips)+t, p.dep[t*n+pos]+shift
+				break
+			}
+		}
+	}
+	return best
+}
+
+func seconds(d time.Duration) int32 { return int32(d / time.Second) }
+
+// walkTime estimates the walk over a straight-line distance in meters.
+func walkTime(meters float64) time.Duration {
+	return time.Duration(math.Ceil(meters/plannerWalkSpeed)) * time.Second
+}
+
+// raptorLabel is how a stop was reached within a round: by a vehicle, and
+// by walking from a stop reached by a vehicle in the same round.
+type raptorLabel struct {
+	tripArr                      int32
+	pattern, trip, board, alight int
+	walkArr                      int32
+	walkFrom                     int
+}
+
+// raptorReady is when a vehicle can be boarded at a stop, and the label it
+// comes from.
+type raptorReady struct {
+	at    int32
+	round int
+	via   int8
+}
+
+const (
+	readyOrigin int8 = iota
+	readyTrip
+	readyWalk
+)
+
+type raptorSearch struct {
+	tt     *raptorTimetable
+	labels [][]raptorLabel // per round, then stop
+	ready  [][]raptorReady
+	best   []int32
+	queued []int    // earliest stop position per pattern to scan, or -1
+	skip   []bool   // patterns left out, nil when none is
+	trips  [][]bool // per pattern, trips left out; nil when none is
+	avoid  []bool   // stops not to board or leave a vehicle at, nil when none
+	egress []int32  // walk from each target stop, or noTime
+	at     int32    // leaving the requested point
+	bound  int32    // earliest arrival at the destination so far
+	// No arrival at or after horizon is kept; noTime unless set
+	horizon int32
+}
+
+type raptorLeg struct {
+	walk                         bool
+	pattern, trip, board, alight int
+	from, to                     int
+	dep, arr                     int32
+}
+
+type raptorJourney struct {
+	legs      []raptorLeg
+	transfers int
+	start     int32 // leaving the requested point, walk to the first stop included
+}
+
+func (j raptorJourney) key() string {
+	var b strings.Builder
+	for _, l := range j.legs {
+		if l.walk {
+			fmt.Fprintf(&b, "w%d-%d;", l.from, l.to)
+		} else {
+			fmt.Fprintf(&b, "t%d.%d:%d-%d;", l.pattern, l.trip, l.board, l.alight)
+		}
+	}
+	return b.String()
+}
+
+func (tt *raptorTimetable) newSearch(rounds int) *raptorSearch {
+	s := &raptorSearch{
+		tt:     tt,
+		labels: make([][]raptorLabel, rounds+1),
+		ready:  make([][]raptorReady, rounds+1),
+		best:   make([]int32, len(tt.stops)),
+		queued: make([]int, len(tt.patterns)),
+		egress: make([]int32, len(tt.stops)),
+	}
+	s.horizon = noTime
+	for i := range s.egress {
+		s.egress[i] = noTime
+	}
+	for k := range s.labels {
+		s.labels[k] = make([]raptorLabel, len(tt.stops))
+		s.ready[k] = make([]raptorReady, len(tt.stops))
+	}
+	return s
+}
+
+// run returns the Pareto-optimal journeys from the origins to the targets
+// leaving the requested point at or after at: the fastest one per number of
+// vehicles, when it arrives before all journeys with fewer.
+func (s *raptorSearch) run(origins, targets []raptorAccess, at int32) []raptorJourney {
+	tt := s.tt
+	for k := range s.labels {
+		for i := range s.labels[k] {
+			s.labels[k][i] = raptorLabel{tripArr: noTime, walkArr: noTime}
+			s.ready[k][i] = raptorReady{at: noTime}
+		}
+	}
+	for i := range s.best {
+		s.best[i] = noTime
+	}
+	for i := range s.queued {
+		s.queued[i] = -1
+	}
+	for _, t := range targets {
+		s.egress[t.stop] = min(s.egress[t.stop], t.walk)
+	}
+	defer func() {
+		for _, t := range targets {
+			s.egress[t.stop] = noTime
+		}
+	}()
+	s.at, s.bound = at, s.horizon
+
+	var marked []int
+	for _, o := range origins {
+		if a := at + o.walk; a < s.best[o.stop] {
+			s.best[o.stop] = a
+			s.ready[0][o.stop] = raptorReady{at: a, via: readyOrigin}
+			marked = append(marked, o.stop)
+		}
+	}
+	// Walking on from a stop the search starts at; points already have all
+	// stops within walking distance among the origins
+	if len(origins) == 1 && origins[0].walk == 0 {
+		from := origins[0].stop
+		for _, tr := range tt.transfers[from] {
+			// Walking all the way is not a transit journey, and must not hide them
+			a := at + tr.walk
+			if s.egress[tr.to] == noTime && a < s.best[tr.to] {
+				s.best[tr.to] = a
+				s.labels[0][tr.to].walkArr = a
+				s.labels[0][tr.to].walkFrom = from
+				s.ready[0][tr.to] = raptorReady{at: a, via: readyWalk}
+				marked = append(marked, tr.to)
+			}
+		}
+	}
+
+	var found []raptorJourney
+	bestAtTarget := noTime
+	for k := 1; k < len(s.labels) && len(marked) > 0; k++ {
+		copy(s.ready[k], s.ready[k-1])
+
+		// Scan the patterns calling at stops improved last round, from the
+		// earliest of them
+		var patterns []int
+		for _, stop := range marked {
+			for _, ps := range tt.stopPatterns[stop] {
+				if s.skip != nil && s.skip[ps.pattern] {
+					continue
+				}
+				if q := s.queued[ps.pattern]; q < 0 {
+					s.queued[ps.pattern] = ps.pos
+					patterns = append(patterns, ps.pattern)
+				} else if ps.pos < q {
+					s.queued[ps.pattern] = ps.pos
+				}
+			}
+		}
+		sort.Ints(patterns)
+
+		var improved []int
+		for _, pi := range patterns {
+			p := tt.patterns[pi]
+			n := len(p.stops)
+			trip, board := -1, 0
+			for i := s.queued[pi]; i < n; i++ {
+				stop := p.stops[i]
+				if trip >= 0 && p.dropOffAt(trip, i) && !s.avoids(stop) {
+					if a := p.arrAt(trip, i); a < s.best[stop] && a < s.bound {
+						l := &s.labels[k][stop]
+						if l.tripArr == noTime {
+							improved = append(improved, stop)
+						}
+						l.tripArr, l.pattern, l.trip, l.board, l.alight = a, pi, trip, board, i
+						s.reached(stop, a)
+					}
+				}
+				r := s.ready[k-1][stop].at
+				if r == noTime || (trip >= 0 && p.depAt(trip, i) < r) || s.avoids(stop) {
+					continue
+				}
+				var skip []bool
+				if s.trips != nil {
+					skip = s.trips[pi]
+				}
+				if t := p.earliestTrip(i, r, skip); t >= 0 && (trip < 0 || p.depAt(t, i) < p.depAt(trip, i)) {
+					trip, board = t, i
+				}
+			}
+			s.queued[pi] = -1
+		}
+
+		// Changing vehicles at the same stop, or walking to a nearby one
+		marked = marked[:0]
+		for _, stop := range improved {
+			if a := s.labels[k][stop].tripArr + minTransferSeconds; a < s.ready[k][stop].at {
+				s.ready[k][stop] = raptorReady{at: a, round: k, via: readyTrip}
+				marked = append(marked, stop)
+			}
+		}
+		for _, stop := range improved {
+			from := s.labels[k][stop].tripArr
+			for _, tr := range tt.transfers[stop] {
+				a := from + tr.walk
+				if a >= s.best[tr.to] || a >= s.bound {
+					continue
+				}
+				s.reached(tr.to, a)
+				s.labels[k][tr.to].walkArr = a
+				s.labels[k][tr.to].walkFrom = stop
+				if a < s.ready[k][tr.to].at {
+					if s.ready[k][tr.to].round != k {
+						marked = append(marked, tr.to)
+					}
+					s.ready[k][tr.to] = raptorReady{at: a, round: k, via: readyWalk}
+				}
+			}
+		}
+
+		to, arrival := -1, bestAtTarget
+		for _, t := range targets {
+			l := s.labels[k][t.stop]
+			if a := min(l.tripArr, l.walkArr); a != noTime && a+s.egress[t.stop] < arrival {
+				to, arrival = t.stop, a+s.egress[t.stop]
+			}
+		}
+		if to >= 0 {
+			bestAtTarget = arrival
+			found = append(found, s.journey(k, to))
+		}
+	}
+	return found
+}
+
+// restrict leaves out the patterns, trips and stops the filter does not keep.
+func (s *raptorSearch) restrict(f domain.JourneyFilter) {
+	tt := s.tt
+	if len(f.RouteTypes.Include) > 0 || len(f.RouteTypes.Exclude) > 0 {
+		s.skip = make([]bool, len(tt.patterns))
+		for i, p := range tt.patterns {
+			s.skip[i] = !routeTypeAllowed(f.RouteTypes, p.route.RouteType)
+		}
+	}
+	if f.Wheelchair || f.Bikes {
+		s.trips = make([][]bool, len(tt.patterns))
+		for i, p := range tt.patterns {
+			s.trips[i] = make([]bool, len(p.trips))
+			for t, trip := range p.trips {
+				s.trips[i][t] = (f.Wheelchair && !trip.WheelchairAccessible) || (f.Bikes && !trip.BikesAllowed)
+			}
+		}
+	}
+	if f.Wheelchair {
+		s.avoid = make([]bool, len(tt.stops))
+		for i, st := range tt.stops {
+			s.avoid[i] = !st.WheelchairAccessible
+		}
+	}
+}
+
+func (s *raptorSearch) avoids(stop int) bool { return s.avoid != nil && s.avoid[stop] }
+
+// reached records an arrival at stop, tightening the bound at targets.
+func (s *raptorSearch) reached(stop int, a int32) {
+	s.best[stop] = a
+	if e := s.egress[stop]; e != noTime {
+		s.bound = min(s.bound, a+e)
+	}
+}
+
+// journey follows the labels back from the target reached in round k.
+func (s *raptorSearch) journey(k, to int) raptorJourney {
+	tt := s.tt
+	var legs []raptorLeg
+	stop := to
+	walk := s.labels[k][to].walkArr < s.labels[k][to].tripArr
+	for {
+		l := s.labels[k][stop]
+		if walk {
+			leg := raptorLeg{walk: true, from: l.walkFrom, to: stop, arr: l.walkArr}
+			if k > 0 {
+				leg.dep = s.labels[k][l.walkFrom].tripArr
+			}
+			legs = append(legs, leg)
+			if k == 0 {
+				break
+			}
+			stop, walk = l.walkFrom, false
+			continue
+		}
+
+		p := tt.patterns[l.pattern]
+		boardStop := p.stops[l.board]
+		legs = append(legs, raptorLeg{
+			pattern: l.pattern, trip: l.trip, board: l.board, alight: l.alight,
+			from: boardStop, to: stop,
+			dep: p.depAt(l.trip, l.board), arr: l.tripArr,
+		})
+		r := s.ready[k-1][boardStop]
+		if r.via == readyOrigin {
+			break
+		}
+		stop, k, walk = boardStop, r.round, r.via == readyWalk
+	}
+
+	for i, j := 0, len(legs)-1; i < j; i, j = i+1, j-1 {
+		legs[i], legs[j] = legs[j], legs[i]
+	}
+	// Leave the origin just in time to walk to the first vehicle
+	if first := &legs[0]; first.walk && len(legs) > 1 {
+		walkTime := first.arr - s.ready[0][first.from].at
+		first.arr = legs[1].dep
+		first.dep = first.arr - walkTime
+	}
+	vehicles := 0
+	for _, l := range legs {
+		if !l.walk {
+			vehicles++
+		}
+	}
+	access := s.ready[0][legs[0].from].at - s.at
+	return raptorJourney{legs: legs, transfers: vehicles - 1, start: legs[0].dep - access}
+}
+
+// journey converts a search result into a domain journey, its times read
+// with clock.
+func (tt *raptorTimetable) journey(j raptorJourney, clock serviceClock) domain.Journey {
+	at := clock.time
+	var legs []domain.JourneyLeg
+	for _, l := range j.legs {
+		from, to := tt.stops[l.from], tt.stops[l.to]
+		leg := domain.JourneyLeg{
+			Walk:        l.walk,
+			FromStop:    &from,
+			ToStop:      &to,
+			Departure:   domain.Departure{ScheduledTime: at(l.dep)},
+			ArrivalTime: at(l.arr),
+		}
+		if !l.walk {
+			p := tt.patterns[l.pattern]
+			t, _ := p.tripAt(l.trip)
+			route, trip := *p.route, *p.trips[t]
+			leg.Route = &route
+			leg.Departure.Trip = &trip
+			leg.HeadwaySecs = int(p.headways[t])
+			leg.BikesRestricted = trip.BikesAllowed && tt.bikesRestricted[trip.RouteID]
+		}
+		legs = append(legs, leg)
+	}
+	dep, arr := j.legs[0].dep, j.legs[len(j.legs)-1].arr
+	return domain.Journey{
+		Legs:          legs,
+		Duration:      time.Duration(arr-dep) * time.Second,
+		DepartureTime: at(dep),
+		ArrivalTime:   at(arr),
+		Transfers:     j.transfers,
+	}
+}
@@ -0,0 +1,496 @@
+package usecases_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock TimetableRepository ---
+
+type mockTimetableRepo struct {
+	timetable *domain.Timetable
+	loads     int
+}
+
+func (m *mockTimetableRepo) Version(ctx context.Context) (time.Time, error) {
+	return m.timetable.Version, nil
+}
+
+func (m *mockTimetableRepo) Load(ctx context.Context, walkRadiusMeters float64) (*domain.Timetable, error) {
+	m.loads++
+	return m.timetable, nil
+}
+
+// plannerTrip builds a trip calling at stops at the given times (HH:MM).
+func plannerTrip(id, routeID string, calls ...string) domain.TimetableTrip {
+	t := domain.TimetableTrip{Trip: domain.Trip{ID: id, RouteID: routeID}}
+	for i := 0; i+1 < len(calls); i += 2 {
+		// Hours may run past 23, as in GTFS
+		var h, m int
+		fmt.Sscanf(calls[i+1], "%d:%d", &h, &m)
+		d := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute
+		t.StopTimes = append(t.StopTimes, domain.StopTime{
+			TripID: id, StopID: calls[i], ArrivalTime: d, DepartureTime: d, StopSequence: i / 2,
+		})
+	}
+	return t
+}
+
+// newPlannerTimetable is a small network: metro A-B-C, a bus from X (a short
+// walk from C, another agency) to D, and a tram from D to E.
+func newPlannerTimetable() *domain.Timetable {
+	return &domain.Timetable{
+		Version: time.Date(2026, 5, 1, 3, 0, 0, 0, time.UTC),
+		Stops: []domain.Stop{
+			{ID: "A", Name: "A"}, {ID: "B", Name: "B"}, {ID: "C", Name: "C"},
+			{ID: "X", Name: "X"}, {ID: "D", Name: "D"}, {ID: "E", Name: "E"},
+		},
+		Routes: []domain.Route{
+			{ID: "metro", ShortName: "L1", RouteType: 1},
+			{ID: "bus", ShortName: "A3", RouteType: 3},
+			{ID: "tram", ShortName: "TR", RouteType: 900},
+		},
+		Trips: []domain.TimetableTrip{
+			plannerTrip("m1", "metro", "A", "08:00", "B", "08:10", "C", "08:15"),
+			plannerTrip("m2", "metro", "A", "08:30", "B", "08:40", "C", "08:45"),
+			plannerTrip("b1", "bus", "X", "08:20", "D", "08:30"),
+			// Too soon after the bus arrives to change
+			plannerTrip("t1", "tram", "D", "08:31", "E", "08:41"),
+			plannerTrip("t2", "tram", "D", "08:40", "E", "08:50"),
+		},
+		Transfers: []domain.Transfer{
+			{FromStopID: "C", ToStopID: "X", Distance: 100},
+			{FromStopID: "X", ToStopID: "C", Distance: 100},
+		},
+	}
+}
+
+func TestJourneyPlanner_Transfers(t *testing.T) {
+	ctx := context.Background()
+	planner := usecases.NewJourneyPlanner(&mockTimetableRepo{timetable: newPlannerTimetable()}, &mockJourneyRepo{})
+	if _, err := planner.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	departAt := time.Date(2026, 5, 4, 7, 55, 0, 0, time.UTC)
+
+	journeys, err := planner.FindJourneys(ctx, "A", "E", departAt, 2, 10, domain.JourneyFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(journeys) != 1 {
+		t.Fatalf("expected 1 journey, got %d", len(journeys))
+	}
+	j := journeys[0]
+	if j.Transfers != 2 || len(j.Legs) != 4 {
+		t.Fatalf("expected metro, walk, bus and tram with 2 transfers, got %d legs, %d transfers", len(j.Legs), j.Transfers)
+	}
+	walk := j.Legs[1]
+	if !walk.Walk || walk.Route != nil || walk.FromStop.ID != "C" || walk.ToStop.ID != "X" {
+		t.Errorf("expected a walk from C to X, got %+v", walk)
+	}
+	if j.Legs[3].Departure.Trip.ID != "t2" {
+		t.Errorf("expected the 08:40 tram, the 08:31 one leaves too soon, got %s", j.Legs[3].Departure.Trip.ID)
+	}
+	if want := time.Date(2026, 5, 4, 8, 50, 0, 0, time.UTC); !j.ArrivalTime.Equal(want) || j.Duration != 50*time.Minute {
+		t.Errorf("expected arrival 08:50 after 50m, got %v after %v", j.ArrivalTime, j.Duration)
+	}
+
+	journeys, _ = planner.FindJourneys(ctx, "A", "E", departAt, 1, 10, domain.JourneyFilter{})
+	if len(journeys) != 0 {
+		t.Errorf("expected no journey with 1 transfer, got %d", len(journeys))
+	}
+}
+
+func TestJourneyPlanner_RouteTypes(t *testing.T) {
+	ctx := context.Background()
+	planner := usecases.NewJourneyPlanner(&mockTimetableRepo{timetable: newPlannerTimetable()}, &mockJourneyRepo{})
+	if _, err := planner.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	departAt := time.Date(2026, 5, 4, 7, 55, 0, 0, time.UTC)
+
+	noBus, _ := usecases.ParseRouteTypes("", "bus")
+	journeys, err := planner.FindJourneys(ctx, "A", "E", departAt, 2, 10, domain.JourneyFilter{RouteTypes: noBus})
+	if err != nil || len(journeys) != 0 {
+		t.Errorf("expected no journey avoiding the bus, got %d, %v", len(journeys), err)
+	}
+	// The extended tram type is a tram
+	rail, _ := usecases.ParseRouteTypes("metro,tram", "")
+	railOnly := domain.JourneyFilter{RouteTypes: rail}
+	journeys, _ = planner.FindJourneys(ctx, "A", "C", departAt, 2, 10, railOnly)
+	if len(journeys) != 2 {
+		t.Errorf("expected both metro trips, got %d", len(journeys))
+	}
+	journeys, _ = planner.FindJourneys(ctx, "X", "E", departAt, 2, 10, railOnly)
+	if len(journeys) != 0 {
+		t.Errorf("expected no journey without the bus to D, got %d", len(journeys))
+	}
+}
+
+func TestJourneyPlanner_Accessibility(t *testing.T) {
+	ctx := context.Background()
+	tt := newPlannerTimetable()
+	for i := range tt.Stops {
+		tt.Stops[i].WheelchairAccessible = tt.Stops[i].ID != "B"
+	}
+	for i := range tt.Trips {
+		trip := &tt.Trips[i].Trip
+		trip.WheelchairAccessible = trip.ID != "m1"
+		trip.BikesAllowed = trip.ID == "m1"
+	}
+	planner := usecases.NewJourneyPlanner(&mockTimetableRepo{timetable: tt}, &mockJourneyRepo{})
+	if _, err := planner.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	departAt := time.Date(2026, 5, 4, 7, 55, 0, 0, time.UTC)
+
+	wheelchair := domain.JourneyFilter{Wheelchair: true}
+	journeys, err := planner.FindJourneys(ctx, "A", "C", departAt, 1, 5, wheelchair)
+	if err != nil || len(journeys) != 1 || journeys[0].Legs[0].Departure.Trip.ID != "m2" {
+		t.Fatalf("expected only the accessible m2, got %+v, %v", journeys, err)
+	}
+	// Passing through B on board is fine, getting off there is not
+	journeys, _ = planner.FindJourneys(ctx, "A", "B", departAt, 1, 5, wheelchair)
+	if len(journeys) != 0 {
+		t.Errorf("expected no journey to the inaccessible B, got %d", len(journeys))
+	}
+
+	journeys, _ = planner.FindJourneys(ctx, "A", "C", departAt, 1, 5, domain.JourneyFilter{Bikes: true})
+	if len(journeys) != 1 || journeys[0].Legs[0].Departure.Trip.ID != "m1" {
+		t.Fatalf("expected only m1, which allows bikes, got %+v", journeys)
+	}
+	// m2 on the same route does not carry bikes
+	if !journeys[0].Legs[0].BikesRestricted {
+		t.Error("expected the m1 leg flagged as bikes restricted")
+	}
+	journeys, _ = planner.FindJourneys(ctx, "A", "C", departAt, 1, 5, domain.JourneyFilter{Wheelchair: true, Bikes: true})
+	if len(journeys) != 0 {
+		t.Errorf("expected no trip both accessible and allowing bikes, got %d", len(journeys))
+	}
+}
+
+func TestJourneyPlanner_Frequencies(t *testing.T) {
+	ctx := context.Background()
+	// A funicular every 10 minutes from 08:00 to 09:00, and one bus a minute
+	// faster
+	funicular := plannerTrip("f", "funicular", "P", "08:00", "Q", "08:03")
+	funicular.Frequencies = []domain.Frequency{{StartTime: 8 * time.Hour, EndTime: 9 * time.Hour, Headway: 10 * time.Minute}}
+	tt := &domain.Timetable{
+		Version: time.Date(2026, 5, 1, 3, 0, 0, 0, time.UTC),
+		Stops:   []domain.Stop{{ID: "P", Name: "P"}, {ID: "Q", Name: "Q"}},
+		Routes: []domain.Route{
+			{ID: "funicular", ShortName: "FA", RouteType: 7},
+			{ID: "bus", ShortName: "B1", RouteType: 3},
+		},
+		Trips: []domain.TimetableTrip{funicular, plannerTrip("b", "bus", "P", "08:29", "Q", "08:32")},
+	}
+	planner := usecases.NewJourneyPlanner(&mockTimetableRepo{timetable: tt}, &mockJourneyRepo{})
+	if _, err := planner.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	journeys, err := planner.FindJourneys(ctx, "P", "Q", time.Date(2026, 5, 4, 8, 21, 0, 0, time.UTC), 0, 2, domain.JourneyFilter{})
+	if err != nil || len(journeys) != 2 {
+		t.Fatalf("expected the bus and a funicular, got %+v, %v", journeys, err)
+	}
+	leg := journeys[0].Legs[0]
+	if leg.Route.ID != "funicular" || leg.Departure.ScheduledTime.Format("15:04") != "08:30" || leg.HeadwaySecs != 600 {
+		t.Errorf("expected the 08:30 funicular every 10 minutes ranked first, got %+v", leg)
+	}
+	if journeys[1].Legs[0].Route.ID != "bus" || journeys[1].Legs[0].HeadwaySecs != 0 {
+		t.Errorf("expected the bus second, got %+v", journeys[1].Legs[0])
+	}
+
+	journeys, _ = planner.FindJourneys(ctx, "P", "Q", time.Date(2026, 5, 4, 8, 51, 0, 0, time.UTC), 0, 2, domain.JourneyFilter{})
+	if len(journeys) != 0 {
+		t.Errorf("expected no funicular after the last one at 08:50, got %+v", journeys)
+	}
+}
+
+func TestJourneyPlanner_Overnight(t *testing.T) {
+	ctx := context.Background()
+	// A night bus P-Q running past midnight, and a first bus Q-R at 00:20
+	// counted on the service day it starts
+	tt := &domain.Timetable{
+		Version: time.Date(2026, 5, 1, 3, 0, 0, 0, time.UTC),
+		Stops:   []domain.Stop{{ID: "P", Name: "P"}, {ID: "Q", Name: "Q"}, {ID: "R", Name: "R"}},
+		Routes:  []domain.Route{{ID: "night", ShortName: "G1", RouteType: 3}, {ID: "early", ShortName: "G2", RouteType: 3}},
+		Trips: []domain.TimetableTrip{
+			plannerTrip("n1", "night", "P", "23:50", "Q", "24:10"),
+			plannerTrip("n2", "night", "P", "24:50", "Q", "25:10"),
+			plannerTrip("e1", "early", "Q", "00:20", "R", "00:30"),
+			plannerTrip("e2", "early", "Q", "06:00", "R", "06:10"),
+		},
+	}
+	planner := usecases.NewJourneyPlanner(&mockTimetableRepo{timetable: tt}, &mockJourneyRepo{})
+	if _, err := planner.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// After midnight, the previous day's night bus is still running
+	journeys, err := planner.FindJourneys(ctx, "P", "Q", time.Date(2026, 5, 5, 0, 30, 0, 0, time.UTC), 0, 1, domain.JourneyFilter{})
+	if err != nil || len(journeys) != 1 {
+		t.Fatalf("expected the night bus, got %+v, %v", journeys, err)
+	}
+	if dep := journeys[0].DepartureTime; !dep.Equal(time.Date(2026, 5, 5, 0, 50, 0, 0, time.UTC)) || journeys[0].Legs[0].Departure.Trip.ID != "n2" {
+		t.Errorf("expected n2 leaving at 00:50 that night, got %s on %+v", dep, journeys[0].Legs[0].Departure.Trip)
+	}
+
+	// Before midnight, a journey changing to the next day's first bus
+	journeys, _ = planner.FindJourneys(ctx, "P", "R", time.Date(2026, 5, 4, 23, 45, 0, 0, time.UTC), 1, 1, domain.JourneyFilter{})
+	if len(journeys) != 1 || !journeys[0].ArrivalTime.Equal(time.Date(2026, 5, 5, 0, 30, 0, 0, time.UTC)) {
+		t.Fatalf("expected arriving at 00:30 the next day, got %+v", journeys)
+	}
+	if legs := journeys[0].Legs; len(legs) != 2 || legs[0].Departure.Trip.ID != "n1" || legs[1].Departure.Trip.ID != "e1" {
+		t.Errorf("expected n1 then e1, got %+v", legs)
+	}
+
+	// The next morning's buses are not waited for
+	journeys, _ = planner.FindJourneys(ctx, "Q", "R", time.Date(2026, 5, 4, 23, 45, 0, 0, time.UTC), 0, 5, domain.JourneyFilter{})
+	if len(journeys) != 1 || journeys[0].Legs[0].Departure.Trip.ID != "e1" {
+		t.Errorf("expected only the 00:20 bus, got %+v", journeys)
+	}
+}
+
+func TestJourneyPlanner_Timezone(t *testing.T) {
+	ctx := context.Background()
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A bus P-Q at 08:00 and a night bus at 24:30, both on a server in UTC
+	tt := &domain.Timetable{
+		Version:  time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC),
+		Timezone: "Europe/Madrid",
+		Stops:    []domain.Stop{{ID: "P", Name: "P"}, {ID: "Q", Name: "Q"}},
+		Routes:   []domain.Route{{ID: "bus", ShortName: "A1", RouteType: 3}},
+		Trips: []domain.TimetableTrip{
+			plannerTrip("day", "bus", "P", "08:00", "Q", "08:20"),
+			plannerTrip("night", "bus", "P", "24:30", "Q", "24:50"),
+		},
+	}
+	planner := usecases.NewJourneyPlanner(&mockTimetableRepo{timetable: tt}, &mockJourneyRepo{})
+	if _, err := planner.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name        string
+		departAfter time.Time
+		trip        string
+		want        time.Time
+	}{
+		{
+			// 23:10 UTC is already the next day in Madrid
+			name:        "after midnight in the zone only",
+			departAfter: time.Date(2026, 3, 28, 23, 10, 0, 0, time.UTC),
+			trip:        "night",
+			want:        time.Date(2026, 3, 29, 0, 30, 0, 0, madrid),
+		},
+		{
+			name:        "clocks forward, a 23 hour day",
+			departAfter: time.Date(2026, 3, 29, 5, 0, 0, 0, time.UTC),
+			trip:        "day",
+			want:        time.Date(2026, 3, 29, 8, 0, 0, 0, madrid),
+		},
+		{
+			name:        "the day after clocks forward",
+			departAfter: time.Date(2026, 3, 30, 5, 0, 0, 0, time.UTC),
+			trip:        "day",
+			want:        time.Date(2026, 3, 30, 8, 0, 0, 0, madrid),
+		},
+		{
+			name:        "clocks back, a 25 hour day",
+			departAfter: time.Date(2026, 10, 25, 6, 0, 0, 0, time.UTC),
+			trip:        "day",
+			want:        time.Date(2026, 10, 25, 8, 0, 0, 0, madrid),
+		},
+		{
+			name:        "the day after clocks back",
+			departAfter: time.Date(2026, 10, 26, 6, 0, 0, 0, time.UTC),
+			trip:        "day",
+			want:        time.Date(2026, 10, 26, 8, 0, 0, 0, madrid),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			journeys, err := planner.FindJourneys(ctx, "P", "Q", tc.departAfter, 0, 1, domain.JourneyFilter{})
+			if err != nil || len(journeys) != 1 {
+				t.Fatalf("expected a journey, got %+v, %v", journeys, err)
+			}
+			j := journeys[0]
+			if j.Legs[0].Departure.Trip.ID != tc.trip || !j.DepartureTime.Equal(tc.want) || j.Duration != 20*time.Minute {
+				t.Errorf("expected %s leaving at %s, got %s leaving at %s after %s",
+					tc.trip, tc.want, j.Legs[0].Departure.Trip.ID, j.DepartureTime, j.Duration)
+			}
+		})
+	}
+
+	reached, err := planner.FindReachable(ctx, []domain.StopAccess{{StopID: "P"}},
+		time.Date(2026, 10, 25, 6, 0, 0, 0, time.UTC), 3*time.Hour, 0, domain.JourneyFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range reached {
+		if want := time.Date(2026, 10, 25, 8, 20, 0, 0, madrid); r.Stop.ID == "Q" && !r.ArrivalTime.Equal(want) {
+			t.Errorf("expected Q reached at %s, got %s", want, r.ArrivalTime)
+		}
+	}
+}
+
+func TestJourneyPlanner_Reachable(t *testing.T) {
+	ctx := context.Background()
+	planner := usecases.NewJourneyPlanner(&mockTimetableRepo{timetable: newPlannerTimetable()}, &mockJourneyRepo{})
+	if _, err := planner.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	departAt := time.Date(2026, 5, 4, 7, 55, 0, 0, time.UTC)
+	reach := func(within time.Duration) map[string]domain.ReachableStop {
+		reached, err := planner.FindReachable(ctx, []domain.StopAccess{{StopID: "A"}}, departAt, within, 1, domain.JourneyFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		byStop := make(map[string]domain.ReachableStop)
+		for _, r := range reached {
+			byStop[r.Stop.ID] = r
+		}
+		return byStop
+	}
+
+	// The metro to C by 08:15, then a short walk to X; the bus from X only
+	// reaches D at 08:30
+	byStop := reach(30 * time.Minute)
+	if len(byStop) != 4 {
+		t.Fatalf("expected A, B, C and X, got %+v", byStop)
+	}
+	if a := byStop["A"]; !a.ArrivalTime.Equal(departAt) || a.Transfers != 0 {
+		t.Errorf("expected the origin reached on departure, got %+v", a)
+	}
+	if c := byStop["C"]; c.ArrivalTime.Format("15:04") != "08:15" {
+		t.Errorf("expected C at 08:15, got %+v", c)
+	}
+	if _, ok := byStop["X"]; !ok {
+		t.Error("expected X reached walking from C")
+	}
+
+	byStop = reach(40 * time.Minute)
+	if d, ok := byStop["D"]; !ok || d.ArrivalTime.Format("15:04") != "08:30" || d.Transfers != 1 {
+		t.Errorf("expected D at 08:30 after one change, got %+v", d)
+	}
+	if _, ok := byStop["E"]; ok {
+		t.Error("expected E out of reach")
+	}
+}
+
+func TestJourneyPlanner_LaterDepartures(t *testing.T) {
+	ctx := context.Background()
+	planner := usecases.NewJourneyPlanner(&mockTimetableRepo{timetable: newPlannerTimetable()}, &mockJourneyRepo{})
+	if _, err := planner.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	journeys, err := planner.FindJourneys(ctx, "A", "C", time.Date(2026, 5, 4, 7, 55, 0, 0, time.UTC), 1, 5, domain.JourneyFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(journeys) != 2 || journeys[0].Legs[0].Departure.Trip.ID != "m1" || journeys[1].Legs[0].Departure.Trip.ID != "m2" {
+		t.Fatalf("expected both direct metro trips in order, got %+v", journeys)
+	}
+	if journeys[0].Transfers != 0 || journeys[0].Legs[0].Route.ShortName != "L1" {
+		t.Errorf("expected a direct L1 journey, got %+v", journeys[0])
+	}
+
+	// Starting with a walk: leave just in time to catch the bus
+	journeys, _ = planner.FindJourneys(ctx, "C", "D", time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC), 1, 5, domain.JourneyFilter{})
+	if len(journeys) != 1 || !journeys[0].Legs[0].Walk {
+		t.Fatalf("expected a walk then the bus, got %+v", journeys)
+	}
+	if want := time.Date(2026, 5, 4, 8, 18, 0, 0, time.UTC); !journeys[0].DepartureTime.Equal(want) {
+		t.Errorf("expected to leave at 08:18, got %v", journeys[0].DepartureTime)
+	}
+}
+
+func TestJourneyPlanner_Refresh(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockTimetableRepo{timetable: newPlannerTimetable()}
+	fallback := &mockJourneyRepo{journeys: []domain.Journey{{Transfers: 9}}}
+	planner := usecases.NewJourneyPlanner(repo, fallback)
+
+	journeys, _ := planner.FindJourneys(ctx, "A", "C", time.Now(), 1, 5, domain.JourneyFilter{})
+	if len(journeys) != 1 || journeys[0].Transfers != 9 {
+		t.Errorf("expected the fallback before the timetable is loaded, got %+v", journeys)
+	}
+
+	if loaded, err := planner.Refresh(ctx); !loaded || err != nil {
+		t.Fatalf("expected the first refresh to load, got %v, %v", loaded, err)
+	}
+	if loaded, _ := planner.Refresh(ctx); loaded || repo.loads != 1 {
+		t.Errorf("expected no reload without a new feed, got %d loads", repo.loads)
+	}
+	repo.timetable.Version = repo.timetable.Version.Add(time.Hour)
+	if loaded, _ := planner.Refresh(ctx); !loaded || repo.loads != 2 {
+		t.Errorf("expected a reload after a new feed, got %d loads", repo.loads)
+	}
+}
+
+func TestJourneyService_PlanJourneyBetween(t *testing.T) {
+	ctx := context.Background()
+	tt := newPlannerTimetable()
+	planner := usecases.NewJourneyPlanner(&mockTimetableRepo{timetable: tt}, &mockJourneyRepo{})
+	if _, err := planner.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	origin := domain.GeoPoint{Lat: 43.0, Lon: -2.0}
+	destination := domain.GeoPoint{Lat: 43.1009, Lon: -2.0}
+	stops := &mockStopRepo{
+		findNearbyFn: func(ctx context.Context, lat, lon, radius float64, limit int) ([]domain.Stop, error) {
+			if lat < 43.05 {
+				// A is nearer, but B (~850 m) catches the earlier metro
+				return []domain.Stop{
+					{ID: "A", Location: domain.GeoPoint{Lat: 43.0054, Lon: -2.0}},
+					{ID: "B", Location: domain.GeoPoint{Lat: 43.00764, Lon: -2.0}},
+				}, nil
+			}
+			return []domain.Stop{{ID: "C", Location: domain.GeoPoint{Lat: 43.1, Lon: -2.0}}}, nil
+		},
+	}
+	svc := usecases.NewJourneyService(planner, stops, nil, nil, nil, testTaxiTariffs)
+
+	departAt := time.Date(2026, 5, 4, 7, 55, 0, 0, time.UTC)
+	plan, err := svc.PlanJourneyBetween(ctx, origin, destination, &departAt, 1, "", domain.JourneyFilter{}, domain.JourneyRanking{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Journeys) == 0 || plan.Fallback != nil {
+		t.Fatalf("expected journeys without fallback, got %+v", plan)
+	}
+	j := plan.Journeys[0]
+	if len(j.Legs) != 3 || !j.Legs[0].Walk || !j.Legs[2].Walk {
+		t.Fatalf("expected walk, metro, walk, got %+v", j.Legs)
+	}
+	if j.Legs[1].FromStop.ID != "B" || j.Legs[1].Departure.Trip.ID != "m1" {
+		t.Errorf("expected the 08:00 metro from B, got %s from %s", j.Legs[1].Departure.Trip.ID, j.Legs[1].FromStop.ID)
+	}
+	if j.Legs[0].FromStop.Location != origin || j.Legs[2].ToStop.Location != destination {
+		t.Errorf("expected walks from and to the requested points, got %+v and %+v", j.Legs[0].FromStop, j.Legs[2].ToStop)
+	}
+	if !j.Legs[0].ArrivalTime.Equal(j.Legs[1].Departure.ScheduledTime) || j.DepartureTime.Before(departAt) {
+		t.Errorf("expected to leave after %v and reach B as the metro leaves, got %+v", departAt, j.Legs[0])
+	}
+	if arr := j.ArrivalTime.Sub(departAt); arr < 21*time.Minute || arr > 22*time.Minute {
+		t.Errorf("expected to arrive about 08:16, got %v", j.ArrivalTime)
+	}
+
+	// No stops near the origin: taxi only
+	stops.findNearbyFn = func(ctx context.Context, lat, lon, radius float64, limit int) ([]domain.Stop, error) {
+		return nil, nil
+	}
+	plan, err = svc.PlanJourneyBetween(ctx, origin, destination, &departAt, 1, "", domain.JourneyFilter{}, domain.JourneyRanking{})
+	if err != nil || len(plan.Journeys) != 0 || plan.Fallback == nil || plan.Fallback.Taxi == nil {
+		t.Errorf("expected a taxi fallback, got %+v, %v", plan, err)
+	}
+}
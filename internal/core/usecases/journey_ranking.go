@@ -0,0 +1,89 @@
+package usecases
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+const (
+	// defaultJourneyOptions is how many journeys a plan lists by default.
+	defaultJourneyOptions = 5
+	// maxJourneyOptions is the most journeys a plan lists.
+	maxJourneyOptions = 10
+)
+
+// validRanking checks the preference of a ranking.
+func validRanking(r domain.JourneyRanking) error {
+	switch r.Prefer {
+	case "", domain.PreferEarliestArrival, domain.PreferFewestTransfers, domain.PreferLeastWalking:
+		return nil
+	}
+	return fmt.Errorf("prefer must be %q, %q or %q",
+		domain.PreferEarliestArrival, domain.PreferFewestTransfers, domain.PreferLeastWalking)
+}
+
+// selectJourneys leaves out the journeys another one dominates, ranks the
+// rest by the preference and keeps up to the ranking's limit. Ties keep the
+// order of rankJourneys, which weighs modes in from departAt.
+func selectJourneys(journeys []domain.Journey, departAt time.Time, r domain.JourneyRanking) []domain.Journey {
+	limit := r.Limit
+	if limit <= 0 {
+		limit = defaultJourneyOptions
+	}
+	limit = min(limit, maxJourneyOptions)
+
+	dominated := make([]bool, len(journeys))
+	for i, j := range journeys {
+		for _, other := range journeys {
+			if dominates(other, j) {
+				dominated[i] = true
+				break
+			}
+		}
+	}
+	kept := journeys[:0]
+	for i, j := range journeys {
+		if !dominated[i] {
+			kept = append(kept, j)
+		}
+	}
+
+	rankJourneys(kept, departAt)
+	switch r.Prefer {
+	case domain.PreferFewestTransfers:
+		sort.SliceStable(kept, func(a, b int) bool { return kept[a].Transfers < kept[b].Transfers })
+	case domain.PreferLeastWalking:
+		sort.SliceStable(kept, func(a, b int) bool { return walking(kept[a]) < walking(kept[b]) })
+	}
+	if len(kept) > limit {
+		kept = kept[:limit]
+	}
+	return kept
+}
+
+// dominates reports whether a departs no later, takes no longer and has no
+// more transfers or walking than b, and is better in one of them: a later
+// departure on the same line is no better option than an earlier one.
+// Journeys alike in all four are both kept, as they ride different trips.
+func dominates(a, b domain.Journey) bool {
+	if a.DepartureTime.After(b.DepartureTime) || a.Duration > b.Duration ||
+		a.Transfers > b.Transfers || walking(a) > walking(b) {
+		return false
+	}
+	return a.DepartureTime.Before(b.DepartureTime) || a.Duration < b.Duration ||
+		a.Transfers < b.Transfers || walking(a) < walking(b)
+}
+
+// walking returns the time a journey spends on foot.
+func walking(j domain.Journey) time.Duration {
+	var d time.Duration
+	for _, l := range j.Legs {
+		if l.Walk {
+			d += l.ArrivalTime.Sub(l.Departure.ScheduledTime)
+		}
+	}
+	return d
+}
@@ -1,58 +1,498 @@
 package usecases
 
 import (
-    "context"
-    "fmt"
-    "time"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
 
-    "github.com/samirrijal/bilbopass/internal/core/domain"
-    "github.com/samirrijal/bilbopass/internal/core/ports"
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+	"github.com/samirrijal/bilbopass/internal/pkg/geospatial"
+)
+
+const (
+	// nightLineWindow is how far ahead fallback night lines may depart.
+	nightLineWindow = 4 * time.Hour
+	// nightLineRadius is how far (meters) a fallback line's stops may be from
+	// the requested origin and destination.
+	nightLineRadius = 500.0
+	// roadFactor converts straight-line distance to an estimated road distance.
+	roadFactor = 1.3
+	// journeyAccessRadius is how far (meters) from a requested point its
+	// candidate stops may be.
+	journeyAccessRadius = 800.0
+	// journeyAccessStops is how many candidate stops each point gets, nearest
+	// first.
+	journeyAccessStops = 10
+	// walkCompareRadius is how far apart (meters, straight line) two places
+	// may be for their transit journeys to be compared with walking.
+	walkCompareRadius = 2000.0
+	// walkingMargin is how much earlier than walking a transit journey has to
+	// arrive to be worth waiting for a vehicle.
+	walkingMargin = 5 * time.Minute
+	// bikeSpeed is the cycling speed (m/s) over the straight-line distance.
+	bikeSpeed = 3.5
 )
 
 // JourneyService handles journey planning between stops.
 type JourneyService struct {
-    journeys ports.JourneyRepository
-    stops    ports.StopRepository
+	journeys ports.JourneyRepository
+	stops    ports.StopRepository
+	events   ports.EventRepository
+	spaces   ports.AccessibleSpaceRepository
+	streets  ports.StreetRouter
+	taxi     domain.TaxiTariffs
+}
+
+// NewJourneyService creates a new JourneyService. events may be nil, in which
+// case plans carry no event overlays, spaces may be nil, in which case the
+// accessible mode cannot rank by free space, and streets may be nil, in which
+// case walks and rides are estimated from straight-line distance; taxi prices
+// the taxi fallback offered when no transit journey is found.
+func NewJourneyService(journeys ports.JourneyRepository, stops ports.StopRepository, events ports.EventRepository, spaces ports.AccessibleSpaceRepository, streets ports.StreetRouter, taxi domain.TaxiTariffs) *JourneyService {
+	return &JourneyService{journeys: journeys, stops: stops, events: events, spaces: spaces, streets: streets, taxi: taxi}
+}
+
+// PlanJourney finds routes between two stops, with the events affecting
+// either end. When there are no routes, the plan carries a fallback with
+// night lines and a taxi estimate instead.
+//
+// accessible selects the accessible mode: "wheelchair" or "stroller" ranks
+// journeys whose vehicles have that space free first and annotates every leg
+// with its trip's accessible space; "" plans normally. Journeys only take
+// the services the filter keeps, and so do the night lines, which are left
+// out when the filter needs wheelchair or bike access they cannot show.
+//
+// Journeys another one beats on departure, duration, transfers and walking
+// are left out, and the rest are listed as the ranking prefers. Between stops
+// close enough to walk, journeys not saving walkingMargin over walking are
+// left out too, and the walk is offered when none is left. With a street
+// mode in the filter, the plan only has the walk or ride.
+func (s *JourneyService) PlanJourney(ctx context.Context, fromStopID, toStopID string, departAt *time.Time, maxTransfers int, accessible string, filter domain.JourneyFilter, ranking domain.JourneyRanking) (*domain.JourneyPlan, error) {
+	if fromStopID == "" || toStopID == "" {
+		return nil, fmt.Errorf("from and to stop IDs are required")
+	}
+	if fromStopID == toStopID {
+		return nil, fmt.Errorf("from and to stops must be different")
+	}
+	if accessible != "" && accessible != domain.SpaceWheelchair && accessible != domain.SpaceStroller {
+		return nil, fmt.Errorf("accessible must be %q or %q", domain.SpaceWheelchair, domain.SpaceStroller)
+	}
+	if err := validRanking(ranking); err != nil {
+		return nil, err
+	}
+	if err := validMode(filter.Mode); err != nil {
+		return nil, err
+	}
+
+	// Default departure time is now
+	depTime := time.Now()
+	if departAt != nil {
+		depTime = *departAt
+	}
+
+	if filter.Mode != "" {
+		stops, err := s.stops.GetByIDs(ctx, []string{fromStopID, toStopID})
+		if err != nil {
+			return nil, err
+		}
+		if len(stops) != 2 {
+			return nil, fmt.Errorf("from and to stops not found")
+		}
+		from, to := stops[0], stops[1]
+		if from.ID != fromStopID {
+			from, to = to, from
+		}
+		return s.streetPlan(ctx, filter.Mode, from.Location, to.Location, depTime), nil
+	}
+
+	if maxTransfers < 0 || maxTransfers > 2 {
+		maxTransfers = 1
+	}
+
+	journeys, err := s.journeys.FindJourneys(ctx, fromStopID, toStopID, depTime, maxTransfers, 10, filter)
+	if err != nil {
+		return nil, err
+	}
+	journeys = selectJourneys(journeys, depTime, ranking)
+	setFareZones(journeys)
+	if accessible != "" && s.spaces != nil && len(journeys) > 0 {
+		s.preferFreeSpace(ctx, journeys, accessible, time.Now())
+	}
+	plan := &domain.JourneyPlan{Journeys: journeys}
+
+	// Overlays, the walk and fallbacks are best-effort and need both stops.
+	stops, err := s.stops.GetByIDs(ctx, []string{fromStopID, toStopID})
+	if err != nil || len(stops) != 2 {
+		return plan, nil
+	}
+	from, to := stops[0], stops[1]
+	if from.ID != fromStopID {
+		from, to = to, from
+	}
+	if s.events != nil {
+		plan.Events = s.eventsAt(ctx, depTime, from.Location, to.Location)
+	}
+	plan.Journeys, plan.Street = s.againstWalking(ctx, plan.Journeys, from.Location, to.Location, depTime)
+	if len(journeys) == 0 {
+		plan.Fallback = s.fallback(ctx, from.ID, to.ID, from.Location, to.Location, depTime, filter)
+	}
+	return plan, nil
+}
+
+// PlanJourneyBetween plans a journey between two points, walking from the
+// origin to one of the stops within walking distance of it and from the last
+// stop to the destination. Without stops near either point, the plan only
+// carries the taxi fallback.
+func (s *JourneyService) PlanJourneyBetween(ctx context.Context, from, to domain.GeoPoint, departAt *time.Time, maxTransfers int, accessible string, filter domain.JourneyFilter, ranking domain.JourneyRanking) (*domain.JourneyPlan, error) {
+	if accessible != "" && accessible != domain.SpaceWheelchair && accessible != domain.SpaceStroller {
+		return nil, fmt.Errorf("accessible must be %q or %q", domain.SpaceWheelchair, domain.SpaceStroller)
+	}
+	if err := validRanking(ranking); err != nil {
+		return nil, err
+	}
+	if err := validMode(filter.Mode); err != nil {
+		return nil, err
+	}
+	depTime := time.Now()
+	if departAt != nil {
+		depTime = *departAt
+	}
+	if filter.Mode != "" {
+		return s.streetPlan(ctx, filter.Mode, from, to, depTime), nil
+	}
+	if maxTransfers < 0 || maxTransfers > 2 {
+		maxTransfers = 1
+	}
+
+	origins, err := s.accessStops(ctx, from, filter)
+	if err != nil {
+		return nil, err
+	}
+	destinations, err := s.accessStops(ctx, to, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var journeys []domain.Journey
+	if len(origins) > 0 && len(destinations) > 0 {
+		found, err := s.journeys.FindJourneysBetween(ctx, origins, destinations, depTime, maxTransfers, 10, filter)
+		if err != nil {
+			return nil, err
+		}
+		walks := make(map[string]time.Duration, len(origins)+len(destinations))
+		for _, a := range origins {
+			walks[a.StopID] = a.Walk
+		}
+		for _, a := range destinations {
+			walks[a.StopID] = a.Walk
+		}
+		for _, j := range found {
+			journeys = append(journeys, withWalks(j, from, to, walks))
+		}
+		journeys = selectJourneys(journeys, depTime, ranking)
+		setFareZones(journeys)
+	}
+	if accessible != "" && s.spaces != nil && len(journeys) > 0 {
+		s.preferFreeSpace(ctx, journeys, accessible, time.Now())
+	}
+
+	plan := &domain.JourneyPlan{Journeys: journeys}
+	if s.events != nil {
+		plan.Events = s.eventsAt(ctx, depTime, from, to)
+	}
+	plan.Journeys, plan.Street = s.againstWalking(ctx, plan.Journeys, from, to, depTime)
+	if len(journeys) == 0 {
+		var fromID, toID string
+		if len(origins) > 0 && len(destinations) > 0 {
+			fromID, toID = origins[0].StopID, destinations[0].StopID
+		}
+		plan.Fallback = s.fallback(ctx, fromID, toID, from, to, depTime, filter)
+	}
+	return plan, nil
+}
+
+// validMode checks the street mode of a journey filter.
+func validMode(mode string) error {
+	if mode != "" && mode != domain.StreetWalk && mode != domain.StreetBike {
+		return fmt.Errorf("mode must be %q or %q", domain.StreetWalk, domain.StreetBike)
+	}
+	return nil
+}
+
+// streetPlan plans a journey on foot or by bike only.
+func (s *JourneyService) streetPlan(ctx context.Context, mode string, from, to domain.GeoPoint, depTime time.Time) *domain.JourneyPlan {
+	plan := &domain.JourneyPlan{Street: s.streetRoute(ctx, mode, from, to)}
+	if s.events != nil {
+		plan.Events = s.eventsAt(ctx, depTime, from, to)
+	}
+	return plan
 }
 
-// NewJourneyService creates a new JourneyService.
-func NewJourneyService(journeys ports.JourneyRepository, stops ports.StopRepository) *JourneyService {
-    return &JourneyService{journeys: journeys, stops: stops}
+// streetRoute routes between two points on foot or by bike, estimating from
+// the straight-line distance without a street router or when it fails.
+func (s *JourneyService) streetRoute(ctx context.Context, mode string, from, to domain.GeoPoint) *domain.StreetRoute {
+	meters := geospatial.Haversine(from.Lat, from.Lon, to.Lat, to.Lon)
+	route := &domain.StreetRoute{Mode: mode, Estimated: true}
+	var d time.Duration
+	if s.streets != nil {
+		if m, rd, err := s.streets.Route(ctx, mode, from, to); err == nil {
+			meters, d, route.Estimated = m, rd, false
+		}
+	}
+	if route.Estimated {
+		d = walkTime(meters)
+		if mode == domain.StreetBike {
+			d = time.Duration(math.Ceil(meters/bikeSpeed)) * time.Second
+		}
+		meters *= roadFactor
+	}
+	route.DistanceKm = math.Round(meters/100) / 10
+	route.DurationMinutes = max(1, int(math.Ceil(d.Minutes())))
+	return route
 }
 
-// PlanJourney finds routes between two stops.
-func (s *JourneyService) PlanJourney(ctx context.Context, fromStopID, toStopID string, departAt *time.Time, maxTransfers int) ([]domain.Journey, error) {
-    if fromStopID == "" || toStopID == "" {
-        return nil, fmt.Errorf("from and to stop IDs are required")
-    }
-    if fromStopID == toStopID {
-        return nil, fmt.Errorf("from and to stops must be different")
-    }
+// againstWalking leaves out the journeys between two places close enough to
+// walk that do not arrive walkingMargin before walking would, and returns the
+// walk when no journey is left.
+func (s *JourneyService) againstWalking(ctx context.Context, journeys []domain.Journey, from, to domain.GeoPoint, depTime time.Time) ([]domain.Journey, *domain.StreetRoute) {
+	if geospatial.Haversine(from.Lat, from.Lon, to.Lat, to.Lon) > walkCompareRadius {
+		return journeys, nil
+	}
+	walk := s.streetRoute(ctx, domain.StreetWalk, from, to)
+	latest := depTime.Add(time.Duration(walk.DurationMinutes)*time.Minute - walkingMargin)
+	kept := journeys[:0]
+	for _, j := range journeys {
+		if !j.ArrivalTime.After(latest) {
+			kept = append(kept, j)
+		}
+	}
+	if len(kept) > 0 {
+		return kept, nil
+	}
+	return kept, walk
+}
 
-    // Default departure time is now
-    depTime := time.Now()
-    if departAt != nil {
-        depTime = *departAt
-    }
+// accessStops returns the stops within walking distance of a point served by
+// routes of the route types the filter keeps, nearest first, with the walk to
+// each. When the filter needs wheelchair access, only accessible stops are
+// returned.
+func (s *JourneyService) accessStops(ctx context.Context, p domain.GeoPoint, filter domain.JourneyFilter) ([]domain.StopAccess, error) {
+	stops, err := s.stops.FindNearby(ctx, p.Lat, p.Lon, journeyAccessRadius, journeyAccessStops, domain.StopFilter{RouteTypes: filter.RouteTypes})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]domain.StopAccess, 0, len(stops))
+	for _, st := range stops {
+		if filter.Wheelchair && !st.WheelchairAccessible {
+			continue
+		}
+		meters := geospatial.Haversine(p.Lat, p.Lon, st.Location.Lat, st.Location.Lon)
+		out = append(out, domain.StopAccess{StopID: st.ID, Walk: walkTime(meters)})
+	}
+	return out, nil
+}
 
-    if maxTransfers < 0 || maxTransfers > 2 {
-        maxTransfers = 1
-    }
+// withWalks adds the walk from the origin point to the journey's first stop,
+// arriving as its first vehicle leaves, and from its last stop to the
+// destination point.
+func withWalks(j domain.Journey, from, to domain.GeoPoint, walks map[string]time.Duration) domain.Journey {
+	if len(j.Legs) == 0 {
+		return j
+	}
+	first, last := j.Legs[0], j.Legs[len(j.Legs)-1]
+	var legs []domain.JourneyLeg
+	if walk := walks[first.FromStop.ID]; walk > 0 {
+		legs = append(legs, domain.JourneyLeg{
+			Walk:        true,
+			FromStop:    &domain.Stop{Location: from},
+			ToStop:      first.FromStop,
+			Departure:   domain.Departure{ScheduledTime: first.Departure.ScheduledTime.Add(-walk)},
+			ArrivalTime: first.Departure.ScheduledTime,
+		})
+	}
+	legs = append(legs, j.Legs...)
+	if walk := walks[last.ToStop.ID]; walk > 0 {
+		legs = append(legs, domain.JourneyLeg{
+			Walk:        true,
+			FromStop:    last.ToStop,
+			ToStop:      &domain.Stop{Location: to},
+			Departure:   domain.Departure{ScheduledTime: last.ArrivalTime},
+			ArrivalTime: last.ArrivalTime.Add(walk),
+		})
+	}
 
-    return s.journeys.FindJourneys(ctx, fromStopID, toStopID, depTime, maxTransfers, 10)
+	j.Legs = legs
+	j.DepartureTime = legs[0].Departure.ScheduledTime
+	j.ArrivalTime = legs[len(legs)-1].ArrivalTime
+	j.Duration = j.ArrivalTime.Sub(j.DepartureTime)
+	return j
 }
 
 // PlanJourneyByName finds stops by name first, then plans a journey.
-func (s *JourneyService) PlanJourneyByName(ctx context.Context, fromName, toName string, departAt *time.Time) ([]domain.Journey, error) {
-    fromStops, err := s.stops.Search(ctx, fromName, nil, 1)
-    if err != nil || len(fromStops) == 0 {
-        return nil, fmt.Errorf("origin stop not found: %s", fromName)
-    }
-
-    toStops, err := s.stops.Search(ctx, toName, nil, 1)
-    if err != nil || len(toStops) == 0 {
-        return nil, fmt.Errorf("destination stop not found: %s", toName)
-    }
-
-    return s.PlanJourney(ctx, fromStops[0].ID, toStops[0].ID, departAt, 1)
+func (s *JourneyService) PlanJourneyByName(ctx context.Context, fromName, toName string, departAt *time.Time, accessible string, filter domain.JourneyFilter, ranking domain.JourneyRanking) (*domain.JourneyPlan, error) {
+	fromStops, err := s.stops.Search(ctx, fromName, nil, 1)
+	if err != nil || len(fromStops) == 0 {
+		return nil, fmt.Errorf("origin stop not found: %s", fromName)
+	}
+
+	toStops, err := s.stops.Search(ctx, toName, nil, 1)
+	if err != nil || len(toStops) == 0 {
+		return nil, fmt.Errorf("destination stop not found: %s", toName)
+	}
+
+	return s.PlanJourney(ctx, fromStops[0].ID, toStops[0].ID, departAt, 1, accessible, filter, ranking)
+}
+
+// preferFreeSpace annotates each leg with its trip's accessible space and
+// stably reorders journeys by their worst leg for the given space: all free
+// first, then unknown, then those with a full vehicle. When the reports
+// cannot be read the journeys are left as they are.
+func (s *JourneyService) preferFreeSpace(ctx context.Context, journeys []domain.Journey, space string, now time.Time) {
+	var tripIDs []string
+	for _, j := range journeys {
+		for _, l := range j.Legs {
+			if l.Departure.Trip != nil {
+				tripIDs = append(tripIDs, l.Departure.Trip.ID)
+			}
+		}
+	}
+	if len(tripIDs) == 0 {
+		return
+	}
+	spaces, err := recentAccessibleSpace(ctx, s.spaces, tripIDs, now)
+	if err != nil {
+		return
+	}
+
+	rank := map[string]int{domain.SpaceAvailable: 0, domain.SpaceUnknown: 1, domain.SpaceFull: 2}
+	worst := make([]int, len(journeys))
+	for i := range journeys {
+		for k := range journeys[i].Legs {
+			l := &journeys[i].Legs[k]
+			if l.Departure.Trip == nil {
+				continue
+			}
+			sp := spaces[l.Departure.Trip.ID]
+			l.AccessibleSpace = &sp
+			state := sp.Wheelchair
+			if space == domain.SpaceStroller {
+				state = sp.Stroller
+			}
+			worst[i] = max(worst[i], rank[state])
+		}
+	}
+
+	order := make([]int, len(journeys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return worst[order[a]] < worst[order[b]] })
+	sorted := make([]domain.Journey, len(journeys))
+	for i, idx := range order {
+		sorted[i] = journeys[idx]
+	}
+	copy(journeys, sorted)
+}
+
+// eventsAt returns the events whose overlay covers any of points at `at`.
+func (s *JourneyService) eventsAt(ctx context.Context, at time.Time, points ...domain.GeoPoint) []domain.Event {
+	var events []domain.Event
+	seen := make(map[string]bool)
+	for _, p := range points {
+		found, err := s.events.ListActiveNear(ctx, p, at)
+		if err != nil {
+			continue
+		}
+		for _, e := range found {
+			if !seen[e.ID] {
+				seen[e.ID] = true
+				events = append(events, e)
+			}
+		}
+	}
+	return events
+}
+
+// fallback suggests night lines of the route types the filter keeps
+// between two stops and a taxi between two points. Without stops, or when the
+// filter needs wheelchair or bike access, only the taxi is suggested.
+func (s *JourneyService) fallback(ctx context.Context, fromStopID, toStopID string, from, to domain.GeoPoint, departAt time.Time, filter domain.JourneyFilter) *domain.JourneyFallback {
+	fb := &domain.JourneyFallback{NightLines: []domain.NightLine{}}
+	if fromStopID != "" && toStopID != "" && !filter.Wheelchair && !filter.Bikes {
+		lines, err := s.journeys.FindNightLines(ctx, fromStopID, toStopID, departAt, nightLineWindow, nightLineRadius, 5)
+		if err == nil {
+			for _, l := range lines {
+				if l.Route == nil || routeTypeAllowed(filter.RouteTypes, l.Route.RouteType) {
+					fb.NightLines = append(fb.NightLines, l)
+				}
+			}
+		}
+	}
+	meters := geospatial.Haversine(from.Lat, from.Lon, to.Lat, to.Lon)
+	fb.Taxi = estimateTaxi(s.taxi, meters*roadFactor, departAt)
+	return fb
+}
+
+// estimateTaxi prices a ride of roadMeters starting at t, or returns nil when
+// no tariffs are configured.
+func estimateTaxi(tariffs domain.TaxiTariffs, roadMeters float64, t time.Time) *domain.TaxiEstimate {
+	if tariffs.DayPerKm <= 0 || tariffs.AverageSpeedKmh <= 0 {
+		return nil
+	}
+	km := roadMeters / 1000
+	est := &domain.TaxiEstimate{
+		DistanceKm:      math.Round(km*10) / 10,
+		DurationMinutes: max(1, int(math.Ceil(km/tariffs.AverageSpeedKmh*60))),
+		Tariff:          "day",
+	}
+	base, perKm := tariffs.DayBaseFare, tariffs.DayPerKm
+	if inNightTariff(tariffs, t.Hour()) {
+		base, perKm = tariffs.NightBaseFare, tariffs.NightPerKm
+		est.Tariff = "night"
+	}
+	fare := math.Max(base+perKm*km, tariffs.MinimumFare)
+	est.Fare = math.Round(fare*2) / 2
+	return est
+}
+
+// inNightTariff reports whether hour falls in the night tariff, which may
+// wrap past midnight.
+func inNightTariff(tariffs domain.TaxiTariffs, hour int) bool {
+	start, end := tariffs.NightStartHour, tariffs.NightEndHour
+	if start == end {
+		return false
+	}
+	if start < end {
+		return hour >= start && hour < end
+	}
+	return hour >= start || hour < end
+}
+
+// setFareZones lists the fare zones each journey's rides board and alight
+// in, once each in the order they are reached, for pricing the journey.
+// Stops without a zone_id are skipped.
+func setFareZones(journeys []domain.Journey) {
+	for i := range journeys {
+		var zones []domain.FareZone
+		seen := map[domain.FareZone]bool{}
+		for _, leg := range journeys[i].Legs {
+			if leg.Walk {
+				continue
+			}
+			for _, stop := range []*domain.Stop{leg.FromStop, leg.ToStop} {
+				if stop == nil || stop.ZoneID == "" {
+					continue
+				}
+				zone := domain.FareZone{AgencyID: stop.AgencyID, ZoneID: stop.ZoneID}
+				if !seen[zone] {
+					seen[zone] = true
+					zones = append(zones, zone)
+				}
+			}
+		}
+		journeys[i].Zones = zones
+	}
 }
@@ -0,0 +1,361 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock JourneyRepository ---
+
+type mockJourneyRepo struct {
+	journeys   []domain.Journey
+	nightLines []domain.NightLine
+	reachable  []domain.ReachableStop
+	origins    []domain.StopAccess // last asked to reach from
+}
+
+func (m *mockJourneyRepo) FindJourneys(ctx context.Context, fromStopID, toStopID string, departAfter time.Time, maxTransfers int, limit int, filter domain.JourneyFilter) ([]domain.Journey, error) {
+	return m.journeys, nil
+}
+
+func (m *mockJourneyRepo) FindJourneysBetween(ctx context.Context, origins, destinations []domain.StopAccess, departAfter time.Time, maxTransfers int, limit int, filter domain.JourneyFilter) ([]domain.Journey, error) {
+	return m.journeys, nil
+}
+
+func (m *mockJourneyRepo) FindNightLines(ctx context.Context, fromStopID, toStopID string, departAfter time.Time, window time.Duration, radiusMeters float64, limit int) ([]domain.NightLine, error) {
+	return m.nightLines, nil
+}
+
+func (m *mockJourneyRepo) FindReachable(ctx context.Context, origins []domain.StopAccess, departAfter time.Time, within time.Duration, maxTransfers int, filter domain.JourneyFilter) ([]domain.ReachableStop, error) {
+	m.origins = origins
+	return m.reachable, nil
+}
+
+var testTaxiTariffs = domain.TaxiTariffs{
+	DayBaseFare: 3, DayPerKm: 1, NightBaseFare: 4, NightPerKm: 2, MinimumFare: 5,
+	NightStartHour: 22, NightEndHour: 6, AverageSpeedKmh: 30,
+}
+
+func newJourneyService(repo *mockJourneyRepo) *usecases.JourneyService {
+	stops := &mockStopRepo{
+		getByIDsFn: func(ctx context.Context, ids []string) ([]domain.Stop, error) {
+			// Out of order, as the repository does not sort.
+			return []domain.Stop{
+				{ID: "to", Location: domain.GeoPoint{Lat: 43.2630, Lon: -2.8500}},
+				{ID: "from", Location: domain.GeoPoint{Lat: 43.2630, Lon: -2.9500}},
+			}, nil
+		},
+	}
+	return usecases.NewJourneyService(repo, stops, nil, nil, nil, testTaxiTariffs)
+}
+
+func TestJourneyService_NoFallbackWhenJourneysFound(t *testing.T) {
+	svc := newJourneyService(&mockJourneyRepo{journeys: []domain.Journey{{Transfers: 0}}})
+	plan, err := svc.PlanJourney(context.Background(), "from", "to", nil, 1, "", domain.JourneyFilter{}, domain.JourneyRanking{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan.Journeys) != 1 || plan.Fallback != nil {
+		t.Errorf("expected journeys without fallback, got %+v", plan)
+	}
+}
+
+func TestJourneyService_FareZones(t *testing.T) {
+	stop := func(id, zone string) *domain.Stop { return &domain.Stop{ID: id, AgencyID: "metro", ZoneID: zone} }
+	repo := &mockJourneyRepo{journeys: []domain.Journey{{Legs: []domain.JourneyLeg{
+		{FromStop: stop("from", "1"), ToStop: stop("san-ines", "2")},
+		{Walk: true, FromStop: stop("san-ines", "2"), ToStop: stop("elcano", "2")},
+		{FromStop: stop("elcano", "2"), ToStop: stop("to", "3")},
+	}}}}
+	plan, err := newJourneyService(repo).PlanJourney(context.Background(), "from", "to", nil, 1, "", domain.JourneyFilter{}, domain.JourneyRanking{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := fmt.Sprint(plan.Journeys[0].Zones); got != "[{metro 1} {metro 2} {metro 3}]" {
+		t.Errorf("expected zones 1, 2 and 3 once each, got %s", got)
+	}
+}
+
+func TestJourneyService_FallbackAtNight(t *testing.T) {
+	repo := &mockJourneyRepo{nightLines: []domain.NightLine{{Route: &domain.Route{ShortName: "G1"}}}}
+	svc := newJourneyService(repo)
+	night := time.Date(2026, 3, 7, 2, 30, 0, 0, time.UTC)
+
+	plan, err := svc.PlanJourney(context.Background(), "from", "to", &night, 1, "", domain.JourneyFilter{}, domain.JourneyRanking{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fb := plan.Fallback
+	if fb == nil || len(fb.NightLines) != 1 || fb.Taxi == nil {
+		t.Fatalf("expected night lines and taxi fallback, got %+v", fb)
+	}
+	// ~8.1 km straight line, ~10.5 km by road at 30 km/h.
+	if fb.Taxi.Tariff != "night" || fb.Taxi.DistanceKm < 10 || fb.Taxi.DistanceKm > 11 {
+		t.Errorf("unexpected taxi estimate %+v", fb.Taxi)
+	}
+	if fb.Taxi.DurationMinutes < 20 || fb.Taxi.DurationMinutes > 22 {
+		t.Errorf("expected about 21 minutes, got %d", fb.Taxi.DurationMinutes)
+	}
+	if fb.Taxi.Fare < 24.5 || fb.Taxi.Fare > 26 {
+		t.Errorf("expected night fare around 25 EUR, got %.2f", fb.Taxi.Fare)
+	}
+
+	// Night lines cannot show wheelchair access, so only the taxi is offered
+	plan, _ = svc.PlanJourney(context.Background(), "from", "to", &night, 1, "", domain.JourneyFilter{Wheelchair: true}, domain.JourneyRanking{})
+	if fb := plan.Fallback; fb == nil || len(fb.NightLines) != 0 || fb.Taxi == nil {
+		t.Errorf("expected only the taxi with wheelchair=true, got %+v", fb)
+	}
+
+	day := time.Date(2026, 3, 9, 12, 0, 0, 0, time.UTC)
+	plan, _ = svc.PlanJourney(context.Background(), "from", "to", &day, 1, "", domain.JourneyFilter{}, domain.JourneyRanking{})
+	if plan.Fallback.Taxi.Tariff != "day" || plan.Fallback.Taxi.Fare > plan.Fallback.Taxi.DistanceKm+3.5 {
+		t.Errorf("expected day tariff, got %+v", plan.Fallback.Taxi)
+	}
+}
+
+func TestJourneyService_FallbackWithoutTariffs(t *testing.T) {
+	stops := &mockStopRepo{
+		getByIDsFn: func(ctx context.Context, ids []string) ([]domain.Stop, error) {
+			return []domain.Stop{{ID: "from"}, {ID: "to"}}, nil
+		},
+	}
+	svc := usecases.NewJourneyService(&mockJourneyRepo{}, stops, nil, nil, nil, domain.TaxiTariffs{})
+	plan, err := svc.PlanJourney(context.Background(), "from", "to", nil, 1, "", domain.JourneyFilter{}, domain.JourneyRanking{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if plan.Fallback == nil || plan.Fallback.Taxi != nil || plan.Fallback.NightLines == nil {
+		t.Errorf("expected empty night lines and no taxi estimate, got %+v", plan.Fallback)
+	}
+}
+
+func TestJourneyService_EventOverlays(t *testing.T) {
+	kickoff := time.Date(2026, 3, 14, 18, 30, 0, 0, time.UTC)
+	events := &mockEventRepo{events: map[string]*domain.Event{
+		"e1": {ID: "e1", ServiceFrom: kickoff.Add(-2 * time.Hour), ServiceUntil: kickoff.Add(4 * time.Hour)},
+	}}
+	stops := &mockStopRepo{
+		getByIDsFn: func(ctx context.Context, ids []string) ([]domain.Stop, error) {
+			return []domain.Stop{{ID: "from"}, {ID: "to"}}, nil
+		},
+	}
+	svc := usecases.NewJourneyService(&mockJourneyRepo{journeys: []domain.Journey{{}}}, stops, events, nil, nil, testTaxiTariffs)
+
+	depart := kickoff.Add(-time.Hour)
+	plan, err := svc.PlanJourney(context.Background(), "from", "to", &depart, 1, "", domain.JourneyFilter{}, domain.JourneyRanking{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Both ends are inside the geofence; the event is listed once.
+	if len(plan.Events) != 1 {
+		t.Errorf("expected one event overlay, got %+v", plan.Events)
+	}
+}
+
+func TestJourneyService_AccessibleModePrefersFreeSpace(t *testing.T) {
+	leg := func(tripID string) domain.JourneyLeg {
+		return domain.JourneyLeg{Departure: domain.Departure{Trip: &domain.Trip{ID: tripID}}}
+	}
+	repo := &mockJourneyRepo{journeys: []domain.Journey{
+		{Legs: []domain.JourneyLeg{leg("full")}},
+		{Legs: []domain.JourneyLeg{leg("free"), leg("unknown")}},
+		{Legs: []domain.JourneyLeg{leg("free")}},
+	}}
+	now := time.Now()
+	spaces := &mockAccessibleSpaceRepo{reports: []domain.AccessibleSpaceReport{
+		{TripID: "full", Source: domain.SpaceSourceRider, Wheelchair: domain.SpaceFull, Stroller: domain.SpaceAvailable, Time: now},
+		{TripID: "free", Source: domain.SpaceSourceVehicle, Wheelchair: domain.SpaceAvailable, Time: now},
+	}}
+	svc := usecases.NewJourneyService(repo, &mockStopRepo{}, nil, spaces, nil, testTaxiTariffs)
+
+	if _, err := svc.PlanJourney(context.Background(), "from", "to", nil, 1, "scooter", domain.JourneyFilter{}, domain.JourneyRanking{}); err == nil {
+		t.Error("expected an error for an unknown accessible mode")
+	}
+
+	plan, err := svc.PlanJourney(context.Background(), "from", "to", nil, 1, domain.SpaceWheelchair, domain.JourneyFilter{}, domain.JourneyRanking{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var order []string
+	for _, j := range plan.Journeys {
+		order = append(order, j.Legs[0].Departure.Trip.ID+"/"+j.Legs[len(j.Legs)-1].Departure.Trip.ID)
+	}
+	if want := "free/free free/unknown full/full"; fmt.Sprint(order) != "["+want+"]" {
+		t.Errorf("expected %s, got %v", want, order)
+	}
+	if sp := plan.Journeys[0].Legs[0].AccessibleSpace; sp == nil || sp.Wheelchair != domain.SpaceAvailable {
+		t.Errorf("expected legs annotated with accessible space, got %+v", sp)
+	}
+
+	// Stroller space on the "full" trip is free, so it ranks ahead of unknown.
+	plan, _ = svc.PlanJourney(context.Background(), "from", "to", nil, 1, domain.SpaceStroller, domain.JourneyFilter{}, domain.JourneyRanking{})
+	if got := plan.Journeys[2].Legs[1].Departure.Trip.ID; got != "unknown" {
+		t.Errorf("expected the journey with an unknown leg last, got %v", got)
+	}
+}
+
+func TestJourneyService_Ranking(t *testing.T) {
+	depart := time.Date(2026, 5, 4, 7, 55, 0, 0, time.UTC)
+	journey := func(tripID, dep string, minutes, transfers, walkMinutes int) domain.Journey {
+		at, _ := time.Parse("15:04", dep)
+		start := time.Date(2026, 5, 4, at.Hour(), at.Minute(), 0, 0, time.UTC)
+		end := start.Add(time.Duration(minutes) * time.Minute)
+		legs := []domain.JourneyLeg{{Departure: domain.Departure{Trip: &domain.Trip{ID: tripID}, ScheduledTime: start}, ArrivalTime: end}}
+		if walkMinutes > 0 {
+			legs = append(legs, domain.JourneyLeg{Walk: true, Departure: domain.Departure{ScheduledTime: end.Add(-time.Duration(walkMinutes) * time.Minute)}, ArrivalTime: end})
+		}
+		return domain.Journey{Legs: legs, DepartureTime: start, ArrivalTime: end, Duration: end.Sub(start), Transfers: transfers}
+	}
+	plan := func(ranking domain.JourneyRanking) []string {
+		repo := &mockJourneyRepo{journeys: []domain.Journey{
+			journey("metro", "08:00", 15, 0, 0),
+			// The same line later on is no other option
+			journey("metro-later", "08:30", 15, 0, 0),
+			journey("change", "08:05", 8, 1, 0),
+			journey("walk", "08:02", 12, 0, 5),
+		}}
+		p, err := newJourneyService(repo).PlanJourney(context.Background(), "from", "to", &depart, 1, "", domain.JourneyFilter{}, ranking)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var ids []string
+		for _, j := range p.Journeys {
+			ids = append(ids, j.Legs[0].Departure.Trip.ID)
+		}
+		return ids
+	}
+
+	if got := fmt.Sprint(plan(domain.JourneyRanking{})); got != "[change walk metro]" {
+		t.Errorf("expected earliest arrival first without the later metro, got %s", got)
+	}
+	if got := fmt.Sprint(plan(domain.JourneyRanking{Prefer: domain.PreferFewestTransfers})); got != "[walk metro change]" {
+		t.Errorf("expected fewest transfers first, got %s", got)
+	}
+	if got := fmt.Sprint(plan(domain.JourneyRanking{Prefer: domain.PreferLeastWalking, Limit: 2})); got != "[change metro]" {
+		t.Errorf("expected the 2 journeys without walking, got %s", got)
+	}
+
+	svc := newJourneyService(&mockJourneyRepo{})
+	if _, err := svc.PlanJourney(context.Background(), "from", "to", nil, 1, "", domain.JourneyFilter{}, domain.JourneyRanking{Prefer: "scenic"}); err == nil {
+		t.Error("expected an error for an unknown preference")
+	}
+}
+
+// --- Mock StreetRouter ---
+
+type mockStreetRouter struct {
+	meters   float64
+	duration time.Duration
+}
+
+func (m *mockStreetRouter) Route(ctx context.Context, mode string, from, to domain.GeoPoint) (float64, time.Duration, error) {
+	return m.meters, m.duration, nil
+}
+
+func TestJourneyService_Walking(t *testing.T) {
+	depart := time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC)
+	stops := &mockStopRepo{
+		getByIDsFn: func(ctx context.Context, ids []string) ([]domain.Stop, error) {
+			// About 400 m apart
+			return []domain.Stop{
+				{ID: "from", Location: domain.GeoPoint{Lat: 43.2630, Lon: -2.9500}},
+				{ID: "to", Location: domain.GeoPoint{Lat: 43.2666, Lon: -2.9500}},
+			}, nil
+		},
+	}
+	bus := func(minutes int) []domain.Journey {
+		arrive := depart.Add(time.Duration(minutes) * time.Minute)
+		return []domain.Journey{{DepartureTime: depart.Add(time.Minute), ArrivalTime: arrive, Duration: arrive.Sub(depart) - time.Minute}}
+	}
+	repo := &mockJourneyRepo{journeys: bus(6)}
+	svc := usecases.NewJourneyService(repo, stops, nil, nil, nil, testTaxiTariffs)
+
+	// A two-stop bus ride is not worth it over a 7 minute walk
+	plan, err := svc.PlanJourney(context.Background(), "from", "to", &depart, 1, "", domain.JourneyFilter{}, domain.JourneyRanking{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan.Journeys) != 0 || plan.Fallback != nil {
+		t.Errorf("expected the bus left out without fallback, got %+v", plan)
+	}
+	if w := plan.Street; w == nil || w.Mode != domain.StreetWalk || !w.Estimated || w.DurationMinutes != 7 || w.DistanceKm != 0.5 {
+		t.Errorf("expected an estimated 7 minute walk of 0.5 km, got %+v", w)
+	}
+
+	repo.journeys = bus(1)
+	plan, _ = svc.PlanJourney(context.Background(), "from", "to", &depart, 1, "", domain.JourneyFilter{}, domain.JourneyRanking{})
+	if len(plan.Journeys) != 1 || plan.Street != nil {
+		t.Errorf("expected a much faster journey kept without the walk, got %+v", plan)
+	}
+
+	svc = usecases.NewJourneyService(repo, stops, nil, nil, &mockStreetRouter{meters: 640, duration: 150 * time.Second}, testTaxiTariffs)
+	plan, err = svc.PlanJourney(context.Background(), "from", "to", &depart, 1, "", domain.JourneyFilter{Mode: domain.StreetBike}, domain.JourneyRanking{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w := plan.Street; len(plan.Journeys) != 0 || w == nil || w.Mode != domain.StreetBike || w.Estimated || w.DurationMinutes != 3 || w.DistanceKm != 0.6 {
+		t.Errorf("expected only a routed 3 minute ride of 0.6 km, got %+v", plan)
+	}
+	if _, err := svc.PlanJourney(context.Background(), "from", "to", &depart, 1, "", domain.JourneyFilter{Mode: "scooter"}, domain.JourneyRanking{}); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func TestJourneyService_Isochrone(t *testing.T) {
+	ctx := context.Background()
+	departAt := time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC)
+	stop := func(id string, lat, lon float64) *domain.Stop {
+		return &domain.Stop{ID: id, Location: domain.GeoPoint{Lat: lat, Lon: lon}}
+	}
+	repo := &mockJourneyRepo{reachable: []domain.ReachableStop{
+		{Stop: stop("c", 43.27, -2.92), ArrivalTime: departAt.Add(20*time.Minute + 10*time.Second), Transfers: 1},
+		{Stop: stop("from", 43.26, -2.93), ArrivalTime: departAt},
+		{Stop: stop("b", 43.25, -2.91), ArrivalTime: departAt.Add(12 * time.Minute)},
+	}}
+	stops := &mockStopRepo{
+		getByIDFn: func(ctx context.Context, id string) (*domain.Stop, error) {
+			if id == "from" {
+				return stop("from", 43.26, -2.93), nil
+			}
+			return nil, nil
+		},
+		findNearbyFn: func(ctx context.Context, lat, lon, radius float64, limit int) ([]domain.Stop, error) {
+			// far is 1.1 km away, over 18 minutes on foot
+			return []domain.Stop{*stop("near", 43.26, -2.93), *stop("far", 43.27, -2.93)}, nil
+		},
+	}
+	svc := usecases.NewJourneyService(repo, stops, nil, nil, nil, testTaxiTariffs)
+
+	iso, err := svc.Isochrone(ctx, "from", nil, &departAt, 30, 1, domain.JourneyFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(iso.Stops) != 3 || iso.Stops[0].Stop.ID != "from" || iso.Stops[2].Minutes != 21 {
+		t.Errorf("expected the stops by arrival, C 21 minutes away, got %+v", iso.Stops)
+	}
+	if len(iso.Hull) != 4 || iso.Hull[0] != iso.Hull[3] {
+		t.Errorf("expected a closed triangle around the stops, got %+v", iso.Hull)
+	}
+
+	// From a point, stops too far to walk in time are not started from
+	point := domain.GeoPoint{Lat: 43.2601, Lon: -2.9301}
+	if _, err := svc.Isochrone(ctx, "", &point, &departAt, 15, 1, domain.JourneyFilter{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.origins) != 1 || repo.origins[0].StopID != "near" {
+		t.Errorf("expected only the near stop as origin, got %+v", repo.origins)
+	}
+
+	if _, err := svc.Isochrone(ctx, "nope", nil, &departAt, 30, 1, domain.JourneyFilter{}); !errors.Is(err, usecases.ErrStopNotFound) {
+		t.Errorf("expected ErrStopNotFound, got %v", err)
+	}
+	if _, err := svc.Isochrone(ctx, "from", nil, &departAt, 121, 1, domain.JourneyFilter{}); !errors.Is(err, usecases.ErrInvalidIsochrone) {
+		t.Errorf("expected ErrInvalidIsochrone, got %v", err)
+	}
+}
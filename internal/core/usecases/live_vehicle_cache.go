@@ -0,0 +1,235 @@
+package usecases
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+	"github.com/samirrijal/bilbopass/internal/pkg/geospatial"
+)
+
+// liveVehicleMaxAge is how long a vehicle's latest position is kept in
+// memory; it is as long as the vehicle stream keeps positions, so a warmed
+// up cache holds the same vehicles as one running since long before.
+const liveVehicleMaxAge = time.Hour
+
+// maxLiveNames bounds the route and trip names remembered for nearby
+// vehicles before they are all forgotten.
+const maxLiveNames = 50000
+
+// LiveVehicleCache is a VehiclePositionRepository that keeps the latest
+// position of each vehicle in memory, as published by the realtime
+// service, and answers the latest-position queries from it rather than
+// with scans of the hypertable. Until Ready is called, as when it has
+// caught up with the published positions, and for everything else, it
+// defers to the repository it wraps.
+type LiveVehicleCache struct {
+	ports.VehiclePositionRepository
+	routes ports.RouteRepository
+	trips  ports.TripRepository
+
+	ready atomic.Bool
+
+	mu       sync.RWMutex
+	vehicles map[string]domain.VehiclePosition // by agency and vehicle
+
+	namesMu   sync.Mutex
+	routeInfo map[string]*domain.Route // by UUID; nil for unknown routes
+	headsigns map[string]string        // by trip UUID
+}
+
+// NewLiveVehicleCache creates a LiveVehicleCache in front of vehicles.
+// Nearby vehicles are named after their route and trip, looked up once.
+func NewLiveVehicleCache(vehicles ports.VehiclePositionRepository, routes ports.RouteRepository, trips ports.TripRepository) *LiveVehicleCache {
+	return &LiveVehicleCache{
+		VehiclePositionRepository: vehicles,
+		routes:                    routes,
+		trips:                     trips,
+		vehicles:                  map[string]domain.VehiclePosition{},
+		routeInfo:                 map[string]*domain.Route{},
+		headsigns:                 map[string]string{},
+	}
+}
+
+// Apply records a position as published, with the feed's IDs and the
+// internal ones in its metadata, unless the vehicle has a fresher one.
+// It is kept as stored: with the internal IDs and without the stop.
+func (c *LiveVehicleCache) Apply(vp domain.VehiclePosition, now time.Time) {
+	if now.Sub(vp.Time) > liveVehicleMaxAge {
+		return
+	}
+	agency, _ := vp.Metadata["agency"].(string)
+	vp.TripID, _ = vp.Metadata[domain.VehicleTripUUID].(string)
+	vp.RouteID, _ = vp.Metadata[domain.VehicleRouteUUID].(string)
+	meta := make(map[string]any, len(vp.Metadata))
+	for k, v := range vp.Metadata {
+		if k != domain.VehicleTripUUID && k != domain.VehicleRouteUUID {
+			meta[k] = v
+		}
+	}
+	vp.Metadata = meta
+	vp.StopID, vp.StopSequence = "", nil
+
+	key := agency + "/" + vp.VehicleID
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if latest, ok := c.vehicles[key]; ok && !vp.Time.After(latest.Time) {
+		return
+	}
+	if len(c.vehicles) >= maxTrackedVehicles {
+		for k, old := range c.vehicles {
+			if now.Sub(old.Time) > liveVehicleMaxAge {
+				delete(c.vehicles, k)
+			}
+		}
+	}
+	c.vehicles[key] = vp
+}
+
+// Ready starts answering queries from memory.
+func (c *LiveVehicleCache) Ready() {
+	c.ready.Store(true)
+}
+
+// latest returns the positions under liveVehicleMaxAge that keep passes.
+func (c *LiveVehicleCache) latest(now time.Time, keep func(domain.VehiclePosition) bool) []domain.VehiclePosition {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var out []domain.VehiclePosition
+	for _, vp := range c.vehicles {
+		if now.Sub(vp.Time) <= liveVehicleMaxAge && keep(vp) {
+			out = append(out, vp)
+		}
+	}
+	return out
+}
+
+// LatestByRoute is LatestByRoutes for one route.
+func (c *LiveVehicleCache) LatestByRoute(ctx context.Context, routeID string) ([]domain.VehiclePosition, error) {
+	return c.LatestByRoutes(ctx, []string{routeID})
+}
+
+// LatestByRoutes returns the latest position of each vehicle on the routes
+// reported within liveVehicleMaxAge, by route and vehicle.
+func (c *LiveVehicleCache) LatestByRoutes(ctx context.Context, routeIDs []string) ([]domain.VehiclePosition, error) {
+	if !c.ready.Load() {
+		return c.VehiclePositionRepository.LatestByRoutes(ctx, routeIDs)
+	}
+	if len(routeIDs) == 0 {
+		return nil, nil
+	}
+	out := c.latest(time.Now(), func(vp domain.VehiclePosition) bool {
+		return vp.RouteID != "" && slices.Contains(routeIDs, vp.RouteID)
+	})
+	slices.SortFunc(out, func(a, b domain.VehiclePosition) int {
+		return cmp.Or(cmp.Compare(a.RouteID, b.RouteID), cmp.Compare(a.VehicleID, b.VehicleID))
+	})
+	return out, nil
+}
+
+// LatestNearby returns the latest position of each vehicle reported since
+// `since` within radiusMeters of the point, nearest first, named after its
+// route and trip. Vehicles of an unknown route are only left out when
+// routeTypes includes some route types.
+func (c *LiveVehicleCache) LatestNearby(ctx context.Context, lat, lon, radiusMeters float64, since time.Time, limit int, routeTypes domain.RouteTypeFilter) ([]domain.VehiclePosition, error) {
+	if !c.ready.Load() {
+		return c.VehiclePositionRepository.LatestNearby(ctx, lat, lon, radiusMeters, since, limit, routeTypes)
+	}
+	var near []domain.VehiclePosition
+	for _, vp := range c.latest(time.Now(), func(vp domain.VehiclePosition) bool { return vp.Time.After(since) }) {
+		d := geospatial.Haversine(lat, lon, vp.Location.Lat, vp.Location.Lon)
+		if d <= radiusMeters {
+			vp.Distance = &d
+			near = append(near, vp)
+		}
+	}
+	slices.SortFunc(near, func(a, b domain.VehiclePosition) int {
+		return cmp.Compare(*a.Distance, *b.Distance)
+	})
+
+	routes, err := c.routesOf(ctx, near)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]domain.VehiclePosition, 0, min(len(near), limit))
+	for _, vp := range near {
+		if len(out) >= limit {
+			break
+		}
+		route := routes[vp.RouteID]
+		if route == nil && len(routeTypes.Include) > 0 || route != nil && !routeTypeAllowed(routeTypes, route.RouteType) {
+			continue
+		}
+		if route != nil {
+			vp.RouteName = route.ShortName
+			if vp.RouteName == "" {
+				vp.RouteName = route.LongName
+			}
+			vp.RouteColor = route.Color
+		}
+		if vp.TripID != "" {
+			vp.Headsign = c.headsign(ctx, vp.TripID)
+		}
+		out = append(out, vp)
+	}
+	return out, nil
+}
+
+// routesOf returns the routes of the positions, looking up those not seen
+// before at once.
+func (c *LiveVehicleCache) routesOf(ctx context.Context, positions []domain.VehiclePosition) (map[string]*domain.Route, error) {
+	c.namesMu.Lock()
+	defer c.namesMu.Unlock()
+	var missing []string
+	for _, vp := range positions {
+		if _, ok := c.routeInfo[vp.RouteID]; !ok && vp.RouteID != "" && !slices.Contains(missing, vp.RouteID) {
+			missing = append(missing, vp.RouteID)
+		}
+	}
+	if len(missing) > 0 {
+		found, err := c.routes.GetByIDs(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		if len(c.routeInfo)+len(missing) > maxLiveNames {
+			c.routeInfo = map[string]*domain.Route{}
+		}
+		for _, id := range missing {
+			c.routeInfo[id] = nil
+		}
+		for i := range found {
+			route := found[i]
+			route.Shape = nil
+			c.routeInfo[route.ID] = &route
+		}
+	}
+	out := make(map[string]*domain.Route, len(positions))
+	for _, vp := range positions {
+		out[vp.RouteID] = c.routeInfo[vp.RouteID]
+	}
+	return out, nil
+}
+
+// headsign returns the headsign of a trip, looked up the first time; it
+// is empty for unknown trips, and when the lookup fails.
+func (c *LiveVehicleCache) headsign(ctx context.Context, tripID string) string {
+	c.namesMu.Lock()
+	defer c.namesMu.Unlock()
+	if h, ok := c.headsigns[tripID]; ok {
+		return h
+	}
+	trip, err := c.trips.GetByID(ctx, tripID)
+	if err != nil || trip == nil {
+		return ""
+	}
+	if len(c.headsigns) >= maxLiveNames {
+		c.headsigns = map[string]string{}
+	}
+	c.headsigns[tripID] = trip.Headsign
+	return trip.Headsign
+}
@@ -0,0 +1,96 @@
+package usecases_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+func TestLiveVehicleCache(t *testing.T) {
+	queried := 0
+	vehicles := &mockVehicleRepo{
+		latestByRouteFn: func(ctx context.Context, routeID string) ([]domain.VehiclePosition, error) {
+			queried++
+			return []domain.VehiclePosition{{VehicleID: "from-db", RouteID: routeID}}, nil
+		},
+	}
+	routes := &mockRouteRepo{getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
+		switch id {
+		case "r-bus":
+			return &domain.Route{ID: id, ShortName: "A3", Color: "E30613", RouteType: 3}, nil
+		case "r-tram":
+			return &domain.Route{ID: id, LongName: "Tranvía", RouteType: 0}, nil
+		}
+		return nil, nil
+	}}
+	trips := &mockTripRepo{trips: map[string]*domain.Trip{"t-1": {ID: "t-1", Headsign: "Basurto"}}}
+	cache := usecases.NewLiveVehicleCache(vehicles, routes, trips)
+	ctx := context.Background()
+	now := time.Now()
+
+	published := func(vehicleID, routeUUID string, at time.Time, lat float64) domain.VehiclePosition {
+		meta := map[string]any{"agency": "bizkaibus", "source": domain.VehicleSourceFeed, domain.VehicleTripUUID: "t-1"}
+		if routeUUID != "" {
+			meta[domain.VehicleRouteUUID] = routeUUID
+		}
+		return domain.VehiclePosition{
+			Time: at, VehicleID: vehicleID, TripID: "T1", RouteID: "A3411", StopID: "S1",
+			Location: domain.GeoPoint{Lat: lat, Lon: -2.93}, Metadata: meta,
+		}
+	}
+	cache.Apply(published("bus-1", "r-bus", now.Add(-time.Minute), 43.2600), now)
+	cache.Apply(published("bus-1", "r-bus", now.Add(-2*time.Minute), 43.3000), now) // older: ignored
+	cache.Apply(published("tram-1", "r-tram", now.Add(-time.Minute), 43.2610), now)
+	cache.Apply(published("bus-2", "", now.Add(-time.Minute), 43.2620), now)
+	cache.Apply(published("bus-3", "r-bus", now.Add(-2*time.Hour), 43.2600), now) // too old: ignored
+
+	// Until ready, the database answers.
+	if got, _ := cache.LatestByRoute(ctx, "r-bus"); len(got) != 1 || got[0].VehicleID != "from-db" || queried != 1 {
+		t.Fatalf("expected the database queried before the cache is ready, got %+v", got)
+	}
+	cache.Ready()
+
+	got, err := cache.LatestByRoute(ctx, "r-bus")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 || queried != 1 {
+		t.Fatalf("expected bus-1 from memory, got %+v", got)
+	}
+	vp := got[0]
+	if vp.Location.Lat != 43.26 || vp.RouteID != "r-bus" || vp.TripID != "t-1" || vp.StopID != "" {
+		t.Errorf("expected the latest position with internal IDs and no stop, got %+v", vp)
+	}
+	if _, ok := vp.Metadata[domain.VehicleRouteUUID]; ok || vp.Metadata["agency"] != "bizkaibus" {
+		t.Errorf("expected the metadata as stored, got %v", vp.Metadata)
+	}
+
+	near, err := cache.LatestNearby(ctx, 43.26, -2.93, 500, now.Add(-10*time.Minute), 10, domain.RouteTypeFilter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(near) != 3 || near[0].VehicleID != "bus-1" || near[1].VehicleID != "tram-1" || near[2].VehicleID != "bus-2" {
+		t.Fatalf("expected the three vehicles nearest first, got %+v", near)
+	}
+	if near[0].RouteName != "A3" || near[0].RouteColor != "E30613" || near[0].Headsign != "Basurto" || near[0].Distance == nil {
+		t.Errorf("expected bus-1 named after its route and trip, got %+v", near[0])
+	}
+	if near[1].RouteName != "Tranvía" {
+		t.Errorf("expected the long name of a route without a short one, got %q", near[1].RouteName)
+	}
+
+	buses, _ := cache.LatestNearby(ctx, 43.26, -2.93, 500, now.Add(-10*time.Minute), 10, domain.RouteTypeFilter{Include: []int{3}})
+	if len(buses) != 1 || buses[0].VehicleID != "bus-1" {
+		t.Errorf("expected only the bus of a known route, got %+v", buses)
+	}
+	notTrams, _ := cache.LatestNearby(ctx, 43.26, -2.93, 500, now.Add(-10*time.Minute), 10, domain.RouteTypeFilter{Exclude: []int{0}})
+	if len(notTrams) != 2 {
+		t.Errorf("expected the vehicles of unknown routes kept when excluding, got %+v", notTrams)
+	}
+	if recent, _ := cache.LatestNearby(ctx, 43.26, -2.93, 500, now, 10, domain.RouteTypeFilter{}); len(recent) != 0 {
+		t.Errorf("expected vehicles not reported since left out, got %+v", recent)
+	}
+}
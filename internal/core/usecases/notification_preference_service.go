@@ -0,0 +1,66 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+// ErrInvalidPreferences is returned for malformed quiet hours.
+var ErrInvalidPreferences = errors.New("invalid notification preferences")
+
+// NotificationPreferenceService manages what riders are pushed and on which
+// devices. The notification service enforces the preferences on every push.
+type NotificationPreferenceService struct {
+	prefs ports.NotificationPreferenceRepository
+}
+
+// NewNotificationPreferenceService creates a new NotificationPreferenceService.
+func NewNotificationPreferenceService(prefs ports.NotificationPreferenceRepository) *NotificationPreferenceService {
+	return &NotificationPreferenceService{prefs: prefs}
+}
+
+// Get returns the user's preferences, the defaults when they set none.
+func (s *NotificationPreferenceService) Get(ctx context.Context, userID string) (*domain.NotificationPreferences, error) {
+	p, err := s.prefs.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		p = domain.DefaultNotificationPreferences()
+	}
+	return p, nil
+}
+
+// Update validates and replaces the user's preferences. Quiet hours
+// without a time zone are in domain.DefaultQuietHoursTimezone.
+func (s *NotificationPreferenceService) Update(ctx context.Context, userID string, p *domain.NotificationPreferences) error {
+	if q := p.QuietHours; q != nil {
+		from, err := time.Parse("15:04", q.From)
+		if err != nil {
+			return fmt.Errorf("%w: quiet_hours.from must be a time as HH:MM", ErrInvalidPreferences)
+		}
+		until, err := time.Parse("15:04", q.Until)
+		if err != nil {
+			return fmt.Errorf("%w: quiet_hours.until must be a time as HH:MM", ErrInvalidPreferences)
+		}
+		if from.Equal(until) {
+			return fmt.Errorf("%w: quiet_hours.from and until must differ", ErrInvalidPreferences)
+		}
+		q.From, q.Until = from.Format("15:04"), until.Format("15:04")
+
+		q.Timezone = strings.TrimSpace(q.Timezone)
+		if q.Timezone == "" {
+			q.Timezone = domain.DefaultQuietHoursTimezone
+		}
+		if _, err := time.LoadLocation(q.Timezone); err != nil || q.Timezone == "Local" {
+			return fmt.Errorf("%w: unknown quiet_hours.timezone %q", ErrInvalidPreferences, q.Timezone)
+		}
+	}
+	return s.prefs.Save(ctx, userID, p)
+}
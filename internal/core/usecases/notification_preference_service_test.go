@@ -0,0 +1,76 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock NotificationPreferenceRepository ---
+
+type mockPreferenceRepo struct {
+	saved *domain.NotificationPreferences
+}
+
+func (m *mockPreferenceRepo) Get(ctx context.Context, userID string) (*domain.NotificationPreferences, error) {
+	return m.saved, nil
+}
+
+func (m *mockPreferenceRepo) Save(ctx context.Context, userID string, p *domain.NotificationPreferences) error {
+	m.saved = p
+	return nil
+}
+
+func TestNotificationPreferenceService(t *testing.T) {
+	repo := &mockPreferenceRepo{}
+	svc := usecases.NewNotificationPreferenceService(repo)
+	ctx := context.Background()
+
+	prefs, err := svc.Get(ctx, "u1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !prefs.Category(domain.NotificationCoupons) || prefs.Category(domain.NotificationMarketing) || !prefs.Channel(domain.DevicePlatformFCM) {
+		t.Errorf("expected every channel and category but marketing, got %+v", prefs)
+	}
+
+	prefs.QuietHours = &domain.QuietHours{From: "22:30", Until: "7:00"}
+	if err := svc.Update(ctx, "u1", prefs); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if q := repo.saved.QuietHours; q.Until != "07:00" || q.Timezone != domain.DefaultQuietHoursTimezone {
+		t.Errorf("expected normalized quiet hours in the default time zone, got %+v", q)
+	}
+
+	// 22:30-07:00 in Madrid, which is UTC+1 in winter
+	for at, quiet := range map[string]bool{
+		"2026-01-12T21:29:00Z": false,
+		"2026-01-12T21:30:00Z": true,
+		"2026-01-13T03:00:00Z": true,
+		"2026-01-13T05:59:00Z": true,
+		"2026-01-13T06:00:00Z": false,
+		"2026-01-13T12:00:00Z": false,
+	} {
+		tm, _ := time.Parse(time.RFC3339, at)
+		if got := repo.saved.Quiet(tm); got != quiet {
+			t.Errorf("%s: expected quiet %v, got %v", at, quiet, got)
+		}
+	}
+
+	for name, q := range map[string]domain.QuietHours{
+		"malformed from":   {From: "22h", Until: "07:00"},
+		"malformed until":  {From: "22:00", Until: "25:00"},
+		"empty":            {From: "08:00", Until: "08:00"},
+		"unknown timezone": {From: "22:00", Until: "07:00", Timezone: "Europe/Bilbao"},
+	} {
+		p := domain.DefaultNotificationPreferences()
+		p.QuietHours = &q
+		if err := svc.Update(ctx, "u1", p); !errors.Is(err, usecases.ErrInvalidPreferences) {
+			t.Errorf("%s: expected ErrInvalidPreferences, got %v", name, err)
+		}
+	}
+}
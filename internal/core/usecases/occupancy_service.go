@@ -0,0 +1,105 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+const (
+	// occupancyMaxAge is how old a vehicle's occupancy may be and still be
+	// shown as current.
+	occupancyMaxAge = 5 * time.Minute
+	// defaultCrowdingWeeks and maxCrowdingWeeks bound how far back crowding
+	// aggregates look; the hourly aggregate is kept for 180 days.
+	defaultCrowdingWeeks = 8
+	maxCrowdingWeeks     = 25
+)
+
+// ErrInvalidCrowdingWeeks is returned for a crowding history outside the
+// allowed range.
+var ErrInvalidCrowdingWeeks = errors.New("weeks must be between 1 and 25")
+
+// occupancyLevels names GTFS-RT OccupancyStatus values.
+var occupancyLevels = []string{
+	"empty",
+	"many_seats_available",
+	"few_seats_available",
+	"standing_room_only",
+	"crushed_standing_room_only",
+	"full",
+	"not_accepting_passengers",
+	"no_data_available",
+	"not_boardable",
+}
+
+// occupancyLevel returns the name of a GTFS-RT OccupancyStatus.
+func occupancyLevel(status int) string {
+	if status < 0 || status >= len(occupancyLevels) {
+		return "unknown"
+	}
+	return occupancyLevels[status]
+}
+
+// OccupancyService surfaces how full vehicles are, now and usually.
+type OccupancyService struct {
+	repo   ports.OccupancyRepository
+	routes ports.RouteRepository
+	stops  ports.StopRepository
+}
+
+// NewOccupancyService creates a new OccupancyService.
+func NewOccupancyService(repo ports.OccupancyRepository, routes ports.RouteRepository, stops ports.StopRepository) *OccupancyService {
+	return &OccupancyService{repo: repo, routes: routes, stops: stops}
+}
+
+// Route returns the current occupancy of the route's vehicles that reported
+// one in the last occupancyMaxAge.
+func (s *OccupancyService) Route(ctx context.Context, routeID string, now time.Time) (*domain.RouteOccupancy, error) {
+	route, err := s.routes.GetByID(ctx, routeID)
+	if err != nil || route == nil {
+		return nil, ErrRouteNotFound
+	}
+	vehicles, err := s.repo.LatestByRoute(ctx, route.ID, now.Add(-occupancyMaxAge))
+	if err != nil {
+		return nil, err
+	}
+	out := &domain.RouteOccupancy{RouteID: route.ID, Vehicles: []domain.VehicleOccupancy{}}
+	for _, v := range vehicles {
+		v.Level = occupancyLevel(v.Status)
+		out.Vehicles = append(out.Vehicles, v)
+	}
+	return out, nil
+}
+
+// StopCrowding returns the average occupancy of vehicles at a stop by
+// weekday and hour over the last weeks weeks (defaultCrowdingWeeks when 0).
+// routeID may be empty for all routes calling at the stop.
+func (s *OccupancyService) StopCrowding(ctx context.Context, stopID, routeID string, weeks int, now time.Time) (*domain.StopCrowding, error) {
+	if weeks == 0 {
+		weeks = defaultCrowdingWeeks
+	}
+	if weeks < 1 || weeks > maxCrowdingWeeks {
+		return nil, ErrInvalidCrowdingWeeks
+	}
+	stop, err := s.stops.GetByID(ctx, stopID)
+	if err != nil || stop == nil {
+		return nil, ErrStopNotFound
+	}
+
+	since := now.Add(-time.Duration(weeks) * 7 * 24 * time.Hour).Truncate(time.Hour)
+	slots, err := s.repo.StopCrowding(ctx, stop.ID, routeID, since)
+	if err != nil {
+		return nil, err
+	}
+	out := &domain.StopCrowding{StopID: stop.ID, RouteID: routeID, Since: since, Slots: []domain.CrowdingSlot{}}
+	for _, sl := range slots {
+		sl.Level = occupancyLevel(int(math.Round(sl.AvgStatus)))
+		out.Slots = append(out.Slots, sl)
+	}
+	return out, nil
+}
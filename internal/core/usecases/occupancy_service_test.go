@@ -0,0 +1,144 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock OccupancyRepository ---
+
+type mockOccupancyRepo struct {
+	latest    []domain.VehicleOccupancy
+	slots     []domain.CrowdingSlot
+	since     time.Time
+	routeID   string
+	tripIDs   []string
+	latestErr error
+}
+
+func (m *mockOccupancyRepo) LatestByRoute(ctx context.Context, routeID string, since time.Time) ([]domain.VehicleOccupancy, error) {
+	m.since = since
+	return m.latest, nil
+}
+
+func (m *mockOccupancyRepo) LatestByTrips(ctx context.Context, tripIDs []string, since time.Time) ([]domain.VehicleOccupancy, error) {
+	m.tripIDs, m.since = tripIDs, since
+	return m.latest, m.latestErr
+}
+
+func (m *mockOccupancyRepo) StopCrowding(ctx context.Context, stopUUID, routeID string, since time.Time) ([]domain.CrowdingSlot, error) {
+	m.routeID, m.since = routeID, since
+	return m.slots, nil
+}
+
+func TestOccupancyService_Route(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 2, 8, 30, 0, 0, time.UTC)
+	repo := &mockOccupancyRepo{latest: []domain.VehicleOccupancy{
+		{VehicleID: "v1", TripID: "t1", Status: 2, UpdatedAt: now.Add(-time.Minute)},
+		{VehicleID: "v2", Status: 42, UpdatedAt: now},
+	}}
+	routes := &mockRouteRepo{getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
+		if id != "r1" {
+			return nil, errors.New("no rows in result set")
+		}
+		return &domain.Route{ID: "r1"}, nil
+	}}
+	svc := usecases.NewOccupancyService(repo, routes, &mockStopRepo{})
+
+	occ, err := svc.Route(ctx, "r1", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(occ.Vehicles) != 2 || occ.Vehicles[0].Level != "few_seats_available" || occ.Vehicles[1].Level != "unknown" {
+		t.Errorf("unexpected vehicles: %+v", occ.Vehicles)
+	}
+	if !repo.since.Equal(now.Add(-5 * time.Minute)) {
+		t.Errorf("expected reports since 5 minutes ago, got %v", repo.since)
+	}
+
+	if _, err := svc.Route(ctx, "nope", now); !errors.Is(err, usecases.ErrRouteNotFound) {
+		t.Errorf("expected ErrRouteNotFound, got %v", err)
+	}
+}
+
+func TestOccupancyService_StopCrowding(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 2, 8, 30, 0, 0, time.UTC)
+	repo := &mockOccupancyRepo{slots: []domain.CrowdingSlot{
+		{Weekday: time.Monday, Hour: 8, AvgStatus: 2.6, Samples: 40},
+		{Weekday: time.Monday, Hour: 9, AvgStatus: 1.2, Samples: 35},
+	}}
+	stops := &mockStopRepo{getByIDFn: func(ctx context.Context, id string) (*domain.Stop, error) {
+		if id != "s1" {
+			return nil, nil
+		}
+		return &domain.Stop{ID: "s1"}, nil
+	}}
+	svc := usecases.NewOccupancyService(repo, &mockRouteRepo{}, stops)
+
+	crowding, err := svc.StopCrowding(ctx, "s1", "r1", 0, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := time.Date(2026, 1, 5, 8, 0, 0, 0, time.UTC); !crowding.Since.Equal(want) || !repo.since.Equal(want) {
+		t.Errorf("expected 8 weeks from the hour, since %v, got %v", want, crowding.Since)
+	}
+	if repo.routeID != "r1" {
+		t.Errorf("expected route filter r1, got %q", repo.routeID)
+	}
+	if crowding.Slots[0].Level != "standing_room_only" || crowding.Slots[1].Level != "many_seats_available" {
+		t.Errorf("unexpected levels: %+v", crowding.Slots)
+	}
+
+	for _, weeks := range []int{-1, 26} {
+		if _, err := svc.StopCrowding(ctx, "s1", "", weeks, now); !errors.Is(err, usecases.ErrInvalidCrowdingWeeks) {
+			t.Errorf("weeks=%d: expected ErrInvalidCrowdingWeeks, got %v", weeks, err)
+		}
+	}
+	if _, err := svc.StopCrowding(ctx, "nope", "", 0, now); !errors.Is(err, usecases.ErrStopNotFound) {
+		t.Errorf("expected ErrStopNotFound, got %v", err)
+	}
+}
+
+func TestDepartureService_Occupancy(t *testing.T) {
+	trips := &mockTripRepo{
+		nextDeparturesFn: func(ctx context.Context, stopUUID string, limit int) ([]domain.Departure, error) {
+			return []domain.Departure{
+				{Trip: &domain.Trip{ID: "t1"}},
+				{Trip: &domain.Trip{ID: "t2"}},
+				{},
+			}, nil
+		},
+	}
+	occ := &mockOccupancyRepo{latest: []domain.VehicleOccupancy{
+		{VehicleID: "v1", TripID: "t1", Status: 5},
+	}}
+	svc := usecases.NewDepartureService(trips, nil, nil, occ)
+
+	deps, err := svc.NextDeparturesAtStop(context.Background(), "stop-uuid", 5, domain.DepartureFilter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(occ.tripIDs) != 2 {
+		t.Errorf("expected occupancy looked up for 2 trips, got %v", occ.tripIDs)
+	}
+	if deps[0].Occupancy == nil || deps[0].Occupancy.VehicleID != "v1" || deps[0].Occupancy.Level != "full" {
+		t.Errorf("t1: unexpected occupancy %+v", deps[0].Occupancy)
+	}
+	if deps[1].Occupancy != nil {
+		t.Errorf("t2: expected no occupancy without a live vehicle, got %+v", deps[1].Occupancy)
+	}
+
+	// Occupancy is best-effort.
+	occ.latestErr = errors.New("db down")
+	deps, err = svc.NextDeparturesAtStop(context.Background(), "stop-uuid", 5, domain.DepartureFilter{})
+	if err != nil || len(deps) != 3 || deps[0].Occupancy != nil {
+		t.Errorf("expected departures without occupancy, got %v, %+v", err, deps)
+	}
+}
@@ -0,0 +1,136 @@
+package usecases
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+const (
+	// offlineWindow is how far ahead an offline bundle lists departures.
+	offlineWindow = 24 * time.Hour
+	// maxOfflineSpan bounds each side of a bundle's bbox, in degrees, so a
+	// bundle stays small enough to keep on a phone.
+	maxOfflineSpan = 0.5
+)
+
+// ErrInvalidBounds is returned for a missing, inverted or oversized bbox.
+var ErrInvalidBounds = errors.New("invalid bbox")
+
+// OfflineService builds the bundles the PWA keeps for the Metro's dead
+// zones: stops, routes and the next day's scheduled departures of an area.
+type OfflineService struct {
+	offline  ports.OfflineRepository
+	agencies ports.AgencyRepository
+	routes   ports.RouteRepository
+	history  ports.FeedHistoryRepository
+}
+
+// NewOfflineService creates a new OfflineService.
+func NewOfflineService(
+	offline ports.OfflineRepository,
+	agencies ports.AgencyRepository,
+	routes ports.RouteRepository,
+	history ports.FeedHistoryRepository,
+) *OfflineService {
+	return &OfflineService{offline: offline, agencies: agencies, routes: routes, history: history}
+}
+
+// Bundle returns the agency's stops inside b, its routes and the departures
+// from those stops in the next 24 hours. Its version changes when the agency
+// publishes a new feed; when since is the current version the stops and
+// routes are left out, as the client already has them.
+func (s *OfflineService) Bundle(ctx context.Context, agencySlug string, b domain.Bounds, since string, now time.Time) (*domain.OfflineBundle, error) {
+	if err := validateBounds(b); err != nil {
+		return nil, err
+	}
+	agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+	if err != nil || agency == nil {
+		return nil, ErrAgencyNotFound
+	}
+
+	versions, err := s.history.Versions(ctx, agency.ID)
+	if err != nil {
+		return nil, fmt.Errorf("feed versions: %w", err)
+	}
+	feed := ""
+	if len(versions) > 0 {
+		feed = versions[0].SHA256
+	}
+
+	now = now.Truncate(time.Minute)
+	bundle := &domain.OfflineBundle{
+		Agency:      agency.Slug,
+		Version:     offlineVersion(feed, b),
+		Bounds:      b,
+		GeneratedAt: now,
+		ValidUntil:  now.Add(offlineWindow),
+		Departures:  []domain.OfflineDeparture{},
+	}
+
+	stops, err := s.offline.Stops(ctx, agency.ID, b)
+	if err != nil {
+		return nil, fmt.Errorf("stops: %w", err)
+	}
+	if len(stops) > 0 {
+		ids := make([]string, len(stops))
+		for i, st := range stops {
+			ids[i] = st.ID
+		}
+		departures, err := s.offline.Departures(ctx, ids, now, offlineWindow)
+		if err != nil {
+			return nil, fmt.Errorf("departures: %w", err)
+		}
+		if departures != nil {
+			bundle.Departures = departures
+		}
+	}
+
+	if since != "" && since == bundle.Version {
+		bundle.Since = since
+		return bundle, nil
+	}
+
+	routes, err := s.routes.ListByAgency(ctx, agency.ID)
+	if err != nil {
+		return nil, fmt.Errorf("routes: %w", err)
+	}
+	bundle.Stops = stops
+	bundle.Routes = make([]domain.OfflineRoute, len(routes))
+	for i, rt := range routes {
+		bundle.Routes[i] = domain.OfflineRoute{
+			ID:        rt.ID,
+			ShortName: rt.ShortName,
+			LongName:  rt.LongName,
+			RouteType: rt.RouteType,
+			Color:     rt.Color,
+			TextColor: rt.TextColor,
+		}
+	}
+	return bundle, nil
+}
+
+func validateBounds(b domain.Bounds) error {
+	switch {
+	case b.MinLat < -90 || b.MaxLat > 90 || b.MinLon < -180 || b.MaxLon > 180:
+		return fmt.Errorf("%w: coordinates out of range", ErrInvalidBounds)
+	case b.MinLat >= b.MaxLat || b.MinLon >= b.MaxLon:
+		return fmt.Errorf("%w: min must be below max", ErrInvalidBounds)
+	case b.MaxLat-b.MinLat > maxOfflineSpan || b.MaxLon-b.MinLon > maxOfflineSpan:
+		return fmt.Errorf("%w: each side must be at most %g degrees", ErrInvalidBounds, maxOfflineSpan)
+	}
+	return nil
+}
+
+// offlineVersion identifies the stops and routes of a bundle: the agency's
+// feed version and the area it covers.
+func offlineVersion(feedSHA string, b domain.Bounds) string {
+	h := sha256.Sum256(fmt.Appendf(nil, "%s|%.5f,%.5f,%.5f,%.5f", feedSHA, b.MinLon, b.MinLat, b.MaxLon, b.MaxLat))
+	return hex.EncodeToString(h[:8])
+}
@@ -0,0 +1,97 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock OfflineRepository ---
+
+type mockOfflineRepo struct {
+	stops      []domain.OfflineStop
+	departures []domain.OfflineDeparture
+	from       time.Time
+}
+
+func (m *mockOfflineRepo) Stops(ctx context.Context, agencyID string, b domain.Bounds) ([]domain.OfflineStop, error) {
+	return m.stops, nil
+}
+
+func (m *mockOfflineRepo) Departures(ctx context.Context, stopIDs []string, from time.Time, window time.Duration) ([]domain.OfflineDeparture, error) {
+	m.from = from
+	return m.departures, nil
+}
+
+func newOfflineService(offline *mockOfflineRepo, feedSHA string) *usecases.OfflineService {
+	history := &mockFeedHistoryRepo{versions: map[string][]domain.FeedVersion{
+		"a1": {{ID: "v2", AgencyID: "a1", SHA256: feedSHA}},
+	}}
+	routes := &mockRouteRepo{
+		listByAgencyFn: func(ctx context.Context, agencyID string) ([]domain.Route, error) {
+			return []domain.Route{{ID: "r1", ShortName: "L1", Color: "E30613"}}, nil
+		},
+	}
+	return usecases.NewOfflineService(offline, newBrandingAgencies(), routes, history)
+}
+
+func TestOfflineService_Bundle(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 2, 8, 15, 42, 0, time.UTC)
+	bilbao := domain.Bounds{MinLon: -2.96, MinLat: 43.24, MaxLon: -2.90, MaxLat: 43.28}
+	offline := &mockOfflineRepo{
+		stops:      []domain.OfflineStop{{ID: "s1", Name: "Moyua"}},
+		departures: []domain.OfflineDeparture{{StopID: "s1", RouteID: "r1", Time: now.Add(5 * time.Minute)}},
+	}
+	svc := newOfflineService(offline, "aaaa")
+
+	full, err := svc.Bundle(ctx, "metro_bilbao", bilbao, "", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(full.Stops) != 1 || len(full.Routes) != 1 || len(full.Departures) != 1 || full.Since != "" {
+		t.Fatalf("expected a full bundle, got %+v", full)
+	}
+	if !offline.from.Equal(now.Truncate(time.Minute)) || !full.ValidUntil.Equal(offline.from.Add(24*time.Hour)) {
+		t.Errorf("expected a 24h window from %s, got %s until %s", now, offline.from, full.ValidUntil)
+	}
+
+	delta, _ := svc.Bundle(ctx, "metro_bilbao", bilbao, full.Version, now.Add(time.Hour))
+	if delta.Since != full.Version || delta.Stops != nil || delta.Routes != nil || len(delta.Departures) != 1 {
+		t.Errorf("expected departures only for the current version, got %+v", delta)
+	}
+
+	// A new feed or another area is a new version.
+	renewed, _ := newOfflineService(offline, "bbbb").Bundle(ctx, "metro_bilbao", bilbao, full.Version, now)
+	if renewed.Version == full.Version || renewed.Stops == nil {
+		t.Errorf("expected a full bundle after a new feed, got %+v", renewed)
+	}
+	moved := bilbao
+	moved.MaxLon = -2.80
+	if other, _ := svc.Bundle(ctx, "metro_bilbao", moved, "", now); other.Version == full.Version {
+		t.Error("expected another version for another bbox")
+	}
+}
+
+func TestOfflineService_Bundle_Invalid(t *testing.T) {
+	ctx := context.Background()
+	svc := newOfflineService(&mockOfflineRepo{}, "aaaa")
+
+	for _, b := range []domain.Bounds{
+		{MinLon: -2.90, MinLat: 43.24, MaxLon: -2.96, MaxLat: 43.28}, // inverted
+		{MinLon: -3.50, MinLat: 43.00, MaxLon: -2.50, MaxLat: 43.40}, // a degree wide
+		{MinLon: -2.96, MinLat: 95, MaxLon: -2.90, MaxLat: 96},       // out of range
+	} {
+		if _, err := svc.Bundle(ctx, "metro_bilbao", b, "", time.Now()); !errors.Is(err, usecases.ErrInvalidBounds) {
+			t.Errorf("%+v: expected ErrInvalidBounds, got %v", b, err)
+		}
+	}
+	valid := domain.Bounds{MinLon: -2.96, MinLat: 43.24, MaxLon: -2.90, MaxLat: 43.28}
+	if _, err := svc.Bundle(ctx, "nope", valid, "", time.Now()); !errors.Is(err, usecases.ErrAgencyNotFound) {
+		t.Errorf("expected ErrAgencyNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+const (
+	// defaultPunctualityRange is the period reported when none is given.
+	defaultPunctualityRange = 30 * 24 * time.Hour
+	// maxPunctualityRange is about as long as the hourly rollups are kept.
+	maxPunctualityRange = 366 * 24 * time.Hour
+	// worstOffenders is how many stops or routes are ranked as least punctual.
+	worstOffenders = 10
+	// Stops and routes with fewer observations than these are not ranked,
+	// so a handful of late trips do not top the list.
+	minStopObservations  = 20
+	minRouteObservations = 100
+)
+
+// ErrInvalidPunctualityRange is returned for an empty or too long period.
+var ErrInvalidPunctualityRange = errors.New("from must be before to and at most 366 days earlier")
+
+// PunctualityService reports on-time performance for routes and the network,
+// for transit advocates and the compensation scheme.
+type PunctualityService struct {
+	repo     ports.PunctualityRepository
+	routes   ports.RouteRepository
+	agencies ports.AgencyRepository
+}
+
+// NewPunctualityService creates a new PunctualityService.
+func NewPunctualityService(repo ports.PunctualityRepository, routes ports.RouteRepository, agencies ports.AgencyRepository) *PunctualityService {
+	return &PunctualityService{repo: repo, routes: routes, agencies: agencies}
+}
+
+// Route returns a route's punctuality in [from, to), by hour and by day, and
+// its least punctual stops. Zero times default to the 30 days up to now.
+func (s *PunctualityService) Route(ctx context.Context, routeID string, from, to, now time.Time) (*domain.RoutePunctuality, error) {
+	from, to, err := punctualityRange(from, to, now)
+	if err != nil {
+		return nil, err
+	}
+	route, err := s.routes.GetByID(ctx, routeID)
+	if err != nil || route == nil {
+		return nil, ErrRouteNotFound
+	}
+
+	f := domain.PunctualityFilter{RouteID: route.ID, From: from, To: to}
+	summary, byHour, byDay, err := s.periods(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	stops, err := s.repo.ByStop(ctx, f, minStopObservations)
+	if err != nil {
+		return nil, err
+	}
+	if stops == nil {
+		stops = []domain.StopPunctuality{}
+	}
+	for i := range stops {
+		finishPunctuality(&stops[i].PunctualityStats)
+	}
+	sort.SliceStable(stops, func(i, j int) bool {
+		return lessPunctual(&stops[i].PunctualityStats, &stops[j].PunctualityStats)
+	})
+
+	name := route.ShortName
+	if name == "" {
+		name = route.LongName
+	}
+	return &domain.RoutePunctuality{
+		RouteID:    route.ID,
+		RouteName:  name,
+		From:       from,
+		To:         to,
+		Summary:    summary,
+		ByHour:     byHour,
+		ByDay:      byDay,
+		WorstStops: stops[:min(len(stops), worstOffenders)],
+	}, nil
+}
+
+// Network returns the punctuality of an agency's routes, or of all routes
+// when agencySlug is empty, in [from, to), by hour and by day, and the least
+// punctual routes.
+func (s *PunctualityService) Network(ctx context.Context, agencySlug string, from, to, now time.Time) (*domain.NetworkPunctuality, error) {
+	from, to, err := punctualityRange(from, to, now)
+	if err != nil {
+		return nil, err
+	}
+	f := domain.PunctualityFilter{From: from, To: to}
+	if agencySlug != "" {
+		agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+		if err != nil || agency == nil {
+			return nil, ErrAgencyNotFound
+		}
+		f.AgencyID = agency.ID
+	}
+
+	summary, byHour, byDay, err := s.periods(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	routes, err := s.repo.ByRoute(ctx, f, minRouteObservations)
+	if err != nil {
+		return nil, err
+	}
+	if routes == nil {
+		routes = []domain.RoutePunctualityStats{}
+	}
+	for i := range routes {
+		finishPunctuality(&routes[i].PunctualityStats)
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		return lessPunctual(&routes[i].PunctualityStats, &routes[j].PunctualityStats)
+	})
+
+	return &domain.NetworkPunctuality{
+		Agency:      agencySlug,
+		From:        from,
+		To:          to,
+		Summary:     summary,
+		ByHour:      byHour,
+		ByDay:       byDay,
+		WorstRoutes: routes[:min(len(routes), worstOffenders)],
+	}, nil
+}
+
+// periods reads punctuality by hour and by day, never nil, and totals it.
+func (s *PunctualityService) periods(ctx context.Context, f domain.PunctualityFilter) (domain.PunctualityStats, []domain.HourPunctuality, []domain.DayPunctuality, error) {
+	byHour, err := s.repo.ByHour(ctx, f)
+	if err != nil {
+		return domain.PunctualityStats{}, nil, nil, err
+	}
+	byDay, err := s.repo.ByDay(ctx, f)
+	if err != nil {
+		return domain.PunctualityStats{}, nil, nil, err
+	}
+	summary := sumPunctuality(byHour)
+	for i := range byHour {
+		finishPunctuality(&byHour[i].PunctualityStats)
+	}
+	for i := range byDay {
+		finishPunctuality(&byDay[i].PunctualityStats)
+	}
+	if byHour == nil {
+		byHour = []domain.HourPunctuality{}
+	}
+	if byDay == nil {
+		byDay = []domain.DayPunctuality{}
+	}
+	return summary, byHour, byDay, nil
+}
+
+// punctualityRange applies the default period and checks its length.
+func punctualityRange(from, to, now time.Time) (time.Time, time.Time, error) {
+	if to.IsZero() {
+		to = now
+	}
+	if from.IsZero() {
+		from = to.Add(-defaultPunctualityRange)
+	}
+	if !from.Before(to) || to.Sub(from) > maxPunctualityRange {
+		return from, to, ErrInvalidPunctualityRange
+	}
+	return from, to, nil
+}
+
+// sumPunctuality totals the hours of a period, before they are rounded.
+// Every observation falls in exactly one hour of the day.
+func sumPunctuality(hours []domain.HourPunctuality) domain.PunctualityStats {
+	var total domain.PunctualityStats
+	var delaySum float64
+	for i, h := range hours {
+		if i == 0 || h.MaxDelay > total.MaxDelay {
+			total.MaxDelay = h.MaxDelay
+		}
+		total.Observed += h.Observed
+		total.OnTime += h.OnTime
+		total.Early += h.Early
+		total.Late += h.Late
+		delaySum += h.AvgDelay * float64(h.Observed)
+	}
+	if total.Observed > 0 {
+		total.AvgDelay = delaySum / float64(total.Observed)
+	}
+	finishPunctuality(&total)
+	return total
+}
+
+// finishPunctuality sets the on-time percentage and rounds the figures to
+// one decimal place.
+func finishPunctuality(s *domain.PunctualityStats) {
+	if s.Observed > 0 {
+		s.OnTimePercent = math.Round(float64(s.OnTime)/float64(s.Observed)*1000) / 10
+	}
+	s.AvgDelay = math.Round(s.AvgDelay*10) / 10
+}
+
+// lessPunctual orders by on-time percentage, then by average delay.
+func lessPunctual(a, b *domain.PunctualityStats) bool {
+	if a.OnTimePercent != b.OnTimePercent {
+		return a.OnTimePercent < b.OnTimePercent
+	}
+	return a.AvgDelay > b.AvgDelay
+}
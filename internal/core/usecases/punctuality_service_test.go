@@ -0,0 +1,131 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock PunctualityRepository ---
+
+type mockPunctualityRepo struct {
+	hours       []domain.HourPunctuality
+	stops       []domain.StopPunctuality
+	routes      []domain.RoutePunctualityStats
+	filter      domain.PunctualityFilter
+	minObserved int
+}
+
+func (m *mockPunctualityRepo) ByHour(ctx context.Context, f domain.PunctualityFilter) ([]domain.HourPunctuality, error) {
+	m.filter = f
+	return m.hours, nil
+}
+func (m *mockPunctualityRepo) ByDay(ctx context.Context, f domain.PunctualityFilter) ([]domain.DayPunctuality, error) {
+	return nil, nil
+}
+func (m *mockPunctualityRepo) ByStop(ctx context.Context, f domain.PunctualityFilter, minObserved int) ([]domain.StopPunctuality, error) {
+	m.minObserved = minObserved
+	return m.stops, nil
+}
+func (m *mockPunctualityRepo) ByRoute(ctx context.Context, f domain.PunctualityFilter, minObserved int) ([]domain.RoutePunctualityStats, error) {
+	m.minObserved = minObserved
+	return m.routes, nil
+}
+
+func punctualityStats(observed, onTime int, avgDelay float64, maxDelay int) domain.PunctualityStats {
+	return domain.PunctualityStats{Observed: observed, OnTime: onTime, Late: observed - onTime, AvgDelay: avgDelay, MaxDelay: maxDelay}
+}
+
+func TestPunctualityService_Route(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	repo := &mockPunctualityRepo{
+		hours: []domain.HourPunctuality{
+			{Hour: 8, PunctualityStats: punctualityStats(300, 240, 150, 900)},
+			{Hour: 9, PunctualityStats: punctualityStats(100, 96, 30, 400)},
+		},
+		stops: []domain.StopPunctuality{
+			{StopID: "s1", PunctualityStats: punctualityStats(50, 45, 40, 300)},
+			{StopID: "s2", PunctualityStats: punctualityStats(50, 30, 200, 900)},
+			{StopID: "s3", PunctualityStats: punctualityStats(50, 30, 260, 800)},
+		},
+	}
+	routes := &mockRouteRepo{getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
+		if id != "r1" {
+			return nil, errors.New("no rows in result set")
+		}
+		return &domain.Route{ID: "r1", ShortName: "L1"}, nil
+	}}
+	svc := usecases.NewPunctualityService(repo, routes, &mockAgencyRepo{})
+
+	p, err := svc.Route(ctx, "r1", time.Time{}, time.Time{}, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !p.From.Equal(now.AddDate(0, 0, -30)) || !p.To.Equal(now) || repo.filter.RouteID != "r1" {
+		t.Errorf("expected the last 30 days of r1, got %v to %v, filter %+v", p.From, p.To, repo.filter)
+	}
+	want := domain.PunctualityStats{Observed: 400, OnTime: 336, Late: 64, OnTimePercent: 84, AvgDelay: 120, MaxDelay: 900}
+	if p.Summary != want {
+		t.Errorf("expected summary %+v, got %+v", want, p.Summary)
+	}
+	if p.ByHour[0].OnTimePercent != 80 || p.ByDay == nil {
+		t.Errorf("unexpected periods: %+v, %+v", p.ByHour, p.ByDay)
+	}
+	if repo.minObserved != 20 {
+		t.Errorf("expected stops with at least 20 observations, got %d", repo.minObserved)
+	}
+	if len(p.WorstStops) != 3 || p.WorstStops[0].StopID != "s3" || p.WorstStops[1].StopID != "s2" {
+		t.Errorf("expected s3 then s2 as least punctual, got %+v", p.WorstStops)
+	}
+
+	if _, err := svc.Route(ctx, "nope", time.Time{}, time.Time{}, now); !errors.Is(err, usecases.ErrRouteNotFound) {
+		t.Errorf("expected ErrRouteNotFound, got %v", err)
+	}
+	for _, r := range [][2]time.Time{
+		{now, now.Add(-time.Hour)},
+		{now.AddDate(-2, 0, 0), now},
+	} {
+		if _, err := svc.Route(ctx, "r1", r[0], r[1], now); !errors.Is(err, usecases.ErrInvalidPunctualityRange) {
+			t.Errorf("%v to %v: expected ErrInvalidPunctualityRange, got %v", r[0], r[1], err)
+		}
+	}
+}
+
+func TestPunctualityService_Network(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	repo := &mockPunctualityRepo{}
+	for i := range 12 {
+		repo.routes = append(repo.routes, domain.RoutePunctualityStats{
+			RouteID:          string(rune('a' + i)),
+			PunctualityStats: punctualityStats(1000, 900-i*10, 60, 600),
+		})
+	}
+	svc := usecases.NewPunctualityService(repo, &mockRouteRepo{}, newBrandingAgencies())
+
+	p, err := svc.Network(ctx, "metro_bilbao", time.Time{}, time.Time{}, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.filter.AgencyID != "a1" || p.Agency != "metro_bilbao" {
+		t.Errorf("expected agency a1, got filter %+v", repo.filter)
+	}
+	if repo.minObserved != 100 {
+		t.Errorf("expected routes with at least 100 observations, got %d", repo.minObserved)
+	}
+	if len(p.WorstRoutes) != 10 || p.WorstRoutes[0].RouteID != "l" || p.WorstRoutes[0].OnTimePercent != 79 {
+		t.Errorf("expected the 10 least punctual routes, worst first, got %+v", p.WorstRoutes)
+	}
+	if p.Summary.Observed != 0 || p.ByHour == nil {
+		t.Errorf("expected an empty summary without hourly data, got %+v, %+v", p.Summary, p.ByHour)
+	}
+
+	if _, err := svc.Network(ctx, "nope", time.Time{}, time.Time{}, now); !errors.Is(err, usecases.ErrAgencyNotFound) {
+		t.Errorf("expected ErrAgencyNotFound, got %v", err)
+	}
+}
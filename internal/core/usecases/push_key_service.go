@@ -0,0 +1,157 @@
+package usecases
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+var (
+	ErrPushKeyNotFound = errors.New("push key not found")
+	// ErrPushRejected wraps why a push failed verification.
+	ErrPushRejected = errors.New("push rejected")
+)
+
+// MaxPushSkew is how far a push's timestamp may be from now. Nonces are
+// remembered for twice as long, so a push cannot be replayed while its
+// timestamp is still accepted.
+const MaxPushSkew = 5 * time.Minute
+
+// maxPushNonce bounds the nonces pushers pick.
+const maxPushNonce = 128
+
+// PushKeyService manages the keys agencies sign the realtime data they
+// push with, and verifies the pushes.
+type PushKeyService struct {
+	keys     ports.PushKeyRepository
+	agencies ports.AgencyRepository
+}
+
+// NewPushKeyService creates a new PushKeyService.
+func NewPushKeyService(keys ports.PushKeyRepository, agencies ports.AgencyRepository) *PushKeyService {
+	return &PushKeyService{keys: keys, agencies: agencies}
+}
+
+// List returns the keys of an agency, without their secrets.
+func (s *PushKeyService) List(ctx context.Context, agencySlug string) ([]domain.PushKey, error) {
+	agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+	if err != nil || agency == nil {
+		return nil, ErrAgencyNotFound
+	}
+	keys, err := s.keys.ListByAgency(ctx, agency.ID)
+	if err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		keys = []domain.PushKey{}
+	}
+	return keys, nil
+}
+
+// Create issues a key to an agency. Its secret is returned only here.
+func (s *PushKeyService) Create(ctx context.Context, agencySlug, label string) (*domain.PushKey, error) {
+	label = strings.TrimSpace(label)
+	if len(label) > 100 {
+		return nil, fmt.Errorf("label is longer than 100 characters")
+	}
+	agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+	if err != nil || agency == nil {
+		return nil, ErrAgencyNotFound
+	}
+
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	key := &domain.PushKey{
+		ID:       "pk_" + hex.EncodeToString(id),
+		AgencyID: agency.ID,
+		Label:    label,
+		Secret:   base64.RawURLEncoding.EncodeToString(secret),
+	}
+	if err := s.keys.Create(ctx, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Revoke deletes an agency's key; pushes signed with it are rejected from
+// then on.
+func (s *PushKeyService) Revoke(ctx context.Context, agencySlug, id string) error {
+	agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+	if err != nil || agency == nil {
+		return ErrAgencyNotFound
+	}
+	deleted, err := s.keys.Delete(ctx, agency.ID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrPushKeyNotFound
+	}
+	return nil
+}
+
+// Verify checks that a push was signed with one of the agency's keys,
+// within MaxPushSkew of now, and not seen before, and returns its payload.
+// Failures wrap ErrPushRejected.
+func (s *PushKeyService) Verify(ctx context.Context, agencyID string, env *domain.PushEnvelope, now time.Time) ([]byte, error) {
+	switch {
+	case env.KeyID == "" || env.Signature == "":
+		return nil, fmt.Errorf("%w: key_id and signature are required", ErrPushRejected)
+	case env.Nonce == "" || len(env.Nonce) > maxPushNonce:
+		return nil, fmt.Errorf("%w: nonce must be 1 to %d characters", ErrPushRejected, maxPushNonce)
+	case len(env.Payload) == 0:
+		return nil, fmt.Errorf("%w: payload is empty", ErrPushRejected)
+	}
+	at := time.Unix(env.Timestamp, 0)
+	if skew := now.Sub(at); skew > MaxPushSkew || skew < -MaxPushSkew {
+		return nil, fmt.Errorf("%w: timestamp %d is more than %s from now", ErrPushRejected, env.Timestamp, MaxPushSkew)
+	}
+
+	key, err := s.keys.Get(ctx, agencyID, env.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrPushRejected, env.KeyID)
+	}
+	want := SignPush(key.Secret, env.Timestamp, env.Nonce, env.Payload)
+	if !hmac.Equal([]byte(strings.ToLower(env.Signature)), []byte(want)) {
+		return nil, fmt.Errorf("%w: bad signature", ErrPushRejected)
+	}
+
+	// Only signed pushes record their nonce, so others cannot use them up.
+	fresh, err := s.keys.UseNonce(ctx, agencyID, env.Nonce, now, now.Add(-2*MaxPushSkew))
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, fmt.Errorf("%w: nonce %q was already used", ErrPushRejected, env.Nonce)
+	}
+	return env.Payload, nil
+}
+
+// SignPush returns the signature of a push: the hex HMAC-SHA256, keyed
+// with the secret, of "<timestamp>.<nonce>." followed by the payload.
+func SignPush(secret string, timestamp int64, nonce string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "." + nonce + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,156 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock PushKeyRepository ---
+
+type mockPushKeyRepo struct {
+	keys   []domain.PushKey
+	nonces map[string]time.Time // agency/nonce -> seen at
+}
+
+func (m *mockPushKeyRepo) ListByAgency(ctx context.Context, agencyID string) ([]domain.PushKey, error) {
+	var out []domain.PushKey
+	for _, k := range m.keys {
+		if k.AgencyID == agencyID {
+			k.Secret = ""
+			out = append(out, k)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockPushKeyRepo) Get(ctx context.Context, agencyID, id string) (*domain.PushKey, error) {
+	for _, k := range m.keys {
+		if k.AgencyID == agencyID && k.ID == id {
+			return &k, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockPushKeyRepo) Create(ctx context.Context, k *domain.PushKey) error {
+	m.keys = append(m.keys, *k)
+	return nil
+}
+
+func (m *mockPushKeyRepo) Delete(ctx context.Context, agencyID, id string) (bool, error) {
+	for i, k := range m.keys {
+		if k.AgencyID == agencyID && k.ID == id {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *mockPushKeyRepo) UseNonce(ctx context.Context, agencyID, nonce string, at, forget time.Time) (bool, error) {
+	if m.nonces == nil {
+		m.nonces = map[string]time.Time{}
+	}
+	for key, seen := range m.nonces {
+		if seen.Before(forget) {
+			delete(m.nonces, key)
+		}
+	}
+	key := agencyID + "/" + nonce
+	if _, ok := m.nonces[key]; ok {
+		return false, nil
+	}
+	m.nonces[key] = at
+	return true, nil
+}
+
+func TestPushKeyService_CreateListRevoke(t *testing.T) {
+	repo := &mockPushKeyRepo{}
+	svc := usecases.NewPushKeyService(repo, newAliasAgencies())
+	ctx := context.Background()
+
+	key, err := svc.Create(ctx, "bilbobus", " AVL gateway ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if key.AgencyID != "a1" || key.Label != "AVL gateway" || key.Secret == "" || len(key.ID) != len("pk_")+16 {
+		t.Errorf("expected a labelled key of a1 with a secret, got %+v", key)
+	}
+	if _, err := svc.Create(ctx, "unknown", ""); !errors.Is(err, usecases.ErrAgencyNotFound) {
+		t.Errorf("expected ErrAgencyNotFound, got %v", err)
+	}
+
+	keys, err := svc.List(ctx, "bilbobus")
+	if err != nil || len(keys) != 1 || keys[0].Secret != "" {
+		t.Fatalf("expected one key without its secret, got %+v (%v)", keys, err)
+	}
+
+	if err := svc.Revoke(ctx, "bizkaibus", key.ID); !errors.Is(err, usecases.ErrPushKeyNotFound) {
+		t.Errorf("expected ErrPushKeyNotFound for another agency's key, got %v", err)
+	}
+	if err := svc.Revoke(ctx, "bilbobus", key.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if keys, _ := svc.List(ctx, "bilbobus"); keys == nil || len(keys) != 0 {
+		t.Errorf("expected empty non-nil list, got %v", keys)
+	}
+}
+
+func TestPushKeyService_Verify(t *testing.T) {
+	repo := &mockPushKeyRepo{keys: []domain.PushKey{{ID: "pk_1", AgencyID: "a1", Secret: "s3cret"}}}
+	svc := usecases.NewPushKeyService(repo, newAliasAgencies())
+	ctx := context.Background()
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	payload := []byte(`{"vehicle_id":"bus-1","lat":43.26,"lon":-2.93}`)
+
+	signed := func(nonce string, at time.Time) *domain.PushEnvelope {
+		return &domain.PushEnvelope{
+			KeyID:     "pk_1",
+			Timestamp: at.Unix(),
+			Nonce:     nonce,
+			Payload:   payload,
+			Signature: usecases.SignPush("s3cret", at.Unix(), nonce, payload),
+		}
+	}
+
+	got, err := svc.Verify(ctx, "a1", signed("n-1", now.Add(-time.Minute)), now)
+	if err != nil || string(got) != string(payload) {
+		t.Fatalf("expected the payload of a signed push, got %q (%v)", got, err)
+	}
+
+	tampered := signed("n-2", now)
+	tampered.Payload = []byte(`{"vehicle_id":"bus-1","lat":0.1,"lon":0.1}`)
+	otherAgency := signed("n-3", now)
+	unknownKey := signed("n-4", now)
+	unknownKey.KeyID = "pk_2"
+	unsigned := signed("n-5", now)
+	unsigned.Signature = ""
+
+	for name, c := range map[string]struct {
+		agencyID string
+		env      *domain.PushEnvelope
+	}{
+		"replayed":     {"a1", signed("n-1", now.Add(-time.Minute))},
+		"tampered":     {"a1", tampered},
+		"stale":        {"a1", signed("n-6", now.Add(-usecases.MaxPushSkew-time.Second))},
+		"future":       {"a1", signed("n-7", now.Add(usecases.MaxPushSkew+time.Second))},
+		"other agency": {"a2", otherAgency},
+		"unknown key":  {"a1", unknownKey},
+		"unsigned":     {"a1", unsigned},
+		"no nonce":     {"a1", signed("", now)},
+	} {
+		if _, err := svc.Verify(ctx, c.agencyID, c.env, now); !errors.Is(err, usecases.ErrPushRejected) {
+			t.Errorf("%s: expected ErrPushRejected, got %v", name, err)
+		}
+	}
+
+	// A tampered push must not use up the nonce of the genuine one.
+	if _, err := svc.Verify(ctx, "a1", signed("n-2", now), now); err != nil {
+		t.Errorf("expected the genuine push of a tampered nonce to pass, got %v", err)
+	}
+}
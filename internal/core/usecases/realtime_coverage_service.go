@@ -0,0 +1,120 @@
+package usecases
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+const (
+	// CoverageSampleInterval is how often the coverage job samples
+	// coverage.
+	CoverageSampleInterval = 5 * time.Minute
+	// coverageLiveWindow is how recent a trip's vehicle position or trip
+	// update must be for the trip to count as live.
+	coverageLiveWindow = 5 * time.Minute
+	// coverageMaxAge is how old a sample may be and still be shown as
+	// current; a few missed samples.
+	coverageMaxAge = 3 * CoverageSampleInterval
+	// liveDataCoverage is the share of running trips with realtime data
+	// from which the live data badge is shown.
+	liveDataCoverage = 0.5
+)
+
+// RealtimeCoverageService reports what share of the trips running have
+// realtime data, per agency and per route.
+type RealtimeCoverageService struct {
+	repo     ports.RealtimeCoverageRepository
+	agencies ports.AgencyRepository
+	routes   ports.RouteRepository
+}
+
+// NewRealtimeCoverageService creates a new RealtimeCoverageService.
+func NewRealtimeCoverageService(repo ports.RealtimeCoverageRepository, agencies ports.AgencyRepository, routes ports.RouteRepository) *RealtimeCoverageService {
+	return &RealtimeCoverageService{repo: repo, agencies: agencies, routes: routes}
+}
+
+// Sample records the coverage of every agency and route at now.
+func (s *RealtimeCoverageService) Sample(ctx context.Context, now time.Time) error {
+	return s.repo.Sample(ctx, now, now.Add(-coverageLiveWindow))
+}
+
+// Agency returns the agency's latest coverage with its routes, lowest
+// coverage first.
+func (s *RealtimeCoverageService) Agency(ctx context.Context, slug string, now time.Time) (*domain.RealtimeCoverage, error) {
+	agency, err := s.agencies.GetBySlug(ctx, slug)
+	if err != nil || agency == nil {
+		return nil, ErrAgencyNotFound
+	}
+	sample, err := s.repo.Latest(ctx, agency.ID)
+	if err != nil {
+		return nil, err
+	}
+	out := &domain.RealtimeCoverage{AgencySlug: agency.Slug, Routes: []domain.RouteCoverage{}}
+	if sample == nil {
+		return out, nil
+	}
+	fillCoverage(out, sample.At, sample.Scheduled, sample.Live, now)
+	for _, r := range sample.Routes {
+		r.Coverage = coverageShare(r.Scheduled, r.Live)
+		out.Routes = append(out.Routes, r)
+	}
+	sort.SliceStable(out.Routes, func(i, j int) bool {
+		a, b := out.Routes[i], out.Routes[j]
+		if *a.Coverage != *b.Coverage {
+			return *a.Coverage < *b.Coverage
+		}
+		if a.Scheduled != b.Scheduled {
+			return a.Scheduled > b.Scheduled
+		}
+		return a.ShortName < b.ShortName
+	})
+	return out, nil
+}
+
+// Route returns the route's latest coverage. A route missing from the
+// agency's latest sample had no trip running.
+func (s *RealtimeCoverageService) Route(ctx context.Context, routeID string, now time.Time) (*domain.RealtimeCoverage, error) {
+	route, err := s.routes.GetByID(ctx, routeID)
+	if err != nil || route == nil {
+		return nil, ErrRouteNotFound
+	}
+	sample, err := s.repo.Latest(ctx, route.AgencyID)
+	if err != nil {
+		return nil, err
+	}
+	out := &domain.RealtimeCoverage{RouteID: route.ID}
+	if sample == nil {
+		return out, nil
+	}
+	scheduled, live := 0, 0
+	for _, r := range sample.Routes {
+		if r.RouteID == route.ID {
+			scheduled, live = r.Scheduled, r.Live
+			break
+		}
+	}
+	fillCoverage(out, sample.At, scheduled, live, now)
+	return out, nil
+}
+
+// fillCoverage sets out from counts sampled at at.
+func fillCoverage(out *domain.RealtimeCoverage, at time.Time, scheduled, live int, now time.Time) {
+	out.SampledAt = &at
+	out.Stale = now.Sub(at) > coverageMaxAge
+	out.Scheduled, out.Live = scheduled, live
+	out.Coverage = coverageShare(scheduled, live)
+	out.LiveData = !out.Stale && out.Coverage != nil && *out.Coverage >= liveDataCoverage
+}
+
+// coverageShare returns live / scheduled, or nil when nothing is scheduled.
+func coverageShare(scheduled, live int) *float64 {
+	if scheduled == 0 {
+		return nil
+	}
+	share := float64(live) / float64(scheduled)
+	return &share
+}
@@ -0,0 +1,96 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock RealtimeCoverageRepository ---
+
+type mockCoverageRepo struct {
+	sample    *domain.CoverageSample // agency a1's latest sample
+	liveSince time.Time
+}
+
+func (m *mockCoverageRepo) Sample(ctx context.Context, at, liveSince time.Time) error {
+	m.liveSince = liveSince
+	return nil
+}
+
+func (m *mockCoverageRepo) Latest(ctx context.Context, agencyID string) (*domain.CoverageSample, error) {
+	if agencyID != "a1" {
+		return nil, nil
+	}
+	return m.sample, nil
+}
+
+func TestRealtimeCoverageService_Agency(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC)
+	repo := &mockCoverageRepo{}
+	svc := usecases.NewRealtimeCoverageService(repo, newBrandingAgencies(), newBrandingRoutes())
+
+	coverage, err := svc.Agency(ctx, "metro_bilbao", now)
+	if err != nil || coverage.SampledAt != nil || coverage.Coverage != nil || coverage.LiveData {
+		t.Fatalf("expected no coverage before the first sample, got %+v, %v", coverage, err)
+	}
+	if _, err := svc.Agency(ctx, "nope", now); !errors.Is(err, usecases.ErrAgencyNotFound) {
+		t.Errorf("expected ErrAgencyNotFound, got %v", err)
+	}
+
+	repo.sample = &domain.CoverageSample{
+		At: now.Add(-2 * time.Minute), Scheduled: 10, Live: 7,
+		Routes: []domain.RouteCoverage{
+			{RouteID: "r1", ShortName: "L1", Scheduled: 6, Live: 6},
+			{RouteID: "r2", ShortName: "L2", Scheduled: 4, Live: 1},
+		},
+	}
+	coverage, err = svc.Agency(ctx, "metro_bilbao", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coverage.Coverage == nil || *coverage.Coverage != 0.7 || !coverage.LiveData || coverage.Stale {
+		t.Errorf("expected 70%% fresh coverage with the badge, got %+v", coverage)
+	}
+	if len(coverage.Routes) != 2 || coverage.Routes[0].RouteID != "r2" || *coverage.Routes[0].Coverage != 0.25 {
+		t.Errorf("expected L2's coverage gap first, got %+v", coverage.Routes)
+	}
+
+	repo.sample.At = now.Add(-time.Hour)
+	coverage, _ = svc.Agency(ctx, "metro_bilbao", now)
+	if !coverage.Stale || coverage.LiveData {
+		t.Errorf("expected a stale sample without the badge, got %+v", coverage)
+	}
+}
+
+func TestRealtimeCoverageService_Route(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC)
+	repo := &mockCoverageRepo{sample: &domain.CoverageSample{
+		At: now.Add(-time.Minute), Scheduled: 4, Live: 1,
+		Routes: []domain.RouteCoverage{{RouteID: "r2", ShortName: "L2", Scheduled: 4, Live: 1}},
+	}}
+	svc := usecases.NewRealtimeCoverageService(repo, newBrandingAgencies(), newBrandingRoutes())
+
+	coverage, err := svc.Route(ctx, "r2", now)
+	if err != nil || coverage.Coverage == nil || *coverage.Coverage != 0.25 || coverage.LiveData {
+		t.Errorf("expected 25%% coverage without the badge, got %+v, %v", coverage, err)
+	}
+	// Sampled, but no trip running
+	coverage, err = svc.Route(ctx, "r1", now)
+	if err != nil || coverage.SampledAt == nil || coverage.Scheduled != 0 || coverage.Coverage != nil {
+		t.Errorf("expected no trips running on r1, got %+v, %v", coverage, err)
+	}
+	if _, err := svc.Route(ctx, "nope", now); !errors.Is(err, usecases.ErrRouteNotFound) {
+		t.Errorf("expected ErrRouteNotFound, got %v", err)
+	}
+
+	if err := svc.Sample(ctx, now); err != nil || !repo.liveSince.Equal(now.Add(-5*time.Minute)) {
+		t.Errorf("expected realtime data from the last 5 minutes to count, got %v, %v", repo.liveSince, err)
+	}
+}
@@ -0,0 +1,47 @@
+package usecases
+
+import (
+	"context"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+// RealtimeFeedStatusService reports the health of the agencies' GTFS-RT
+// feeds from what the realtime poller recorded.
+type RealtimeFeedStatusService struct {
+	repo       ports.RealtimeFeedStatusRepository
+	staleAfter time.Duration
+}
+
+// NewRealtimeFeedStatusService creates a new RealtimeFeedStatusService. Feeds
+// whose data is older than staleAfter are reported stale.
+func NewRealtimeFeedStatusService(repo ports.RealtimeFeedStatusRepository, staleAfter time.Duration) *RealtimeFeedStatusService {
+	return &RealtimeFeedStatusService{repo: repo, staleAfter: staleAfter}
+}
+
+// StaleAfter returns the age from which a feed is reported stale.
+func (s *RealtimeFeedStatusService) StaleAfter() time.Duration { return s.staleAfter }
+
+// Status returns the status of every polled feed. A feed is stale when it
+// never answered, or when its header timestamp (its last successful poll,
+// for feeds that send none) is older than staleAfter.
+func (s *RealtimeFeedStatusService) Status(ctx context.Context, now time.Time) ([]domain.RealtimeFeedStatus, error) {
+	statuses, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range statuses {
+		st := &statuses[i]
+		fresh := st.FeedTimestamp
+		if fresh == nil {
+			fresh = st.LastSuccessAt
+		}
+		st.Stale = fresh == nil || now.Sub(*fresh) > s.staleAfter
+	}
+	if statuses == nil {
+		statuses = []domain.RealtimeFeedStatus{}
+	}
+	return statuses, nil
+}
@@ -0,0 +1,55 @@
+package usecases_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock RealtimeFeedStatusRepository ---
+
+type mockFeedStatusRepo struct {
+	statuses []domain.RealtimeFeedStatus
+}
+
+func (m *mockFeedStatusRepo) RecordPoll(ctx context.Context, p *domain.RealtimeFeedPoll) error {
+	return nil
+}
+
+func (m *mockFeedStatusRepo) List(ctx context.Context) ([]domain.RealtimeFeedStatus, error) {
+	return m.statuses, nil
+}
+
+func TestRealtimeFeedStatusService_Stale(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	at := func(ago time.Duration) *time.Time {
+		ts := now.Add(-ago)
+		return &ts
+	}
+	repo := &mockFeedStatusRepo{statuses: []domain.RealtimeFeedStatus{
+		{Feed: "vehicle_positions", LastSuccessAt: at(0), FeedTimestamp: at(time.Minute)},
+		{Feed: "trip_updates", LastSuccessAt: at(0), FeedTimestamp: at(20 * time.Minute)}, // frozen header
+		{Feed: "alerts", LastSuccessAt: at(2 * time.Minute)},                              // no header timestamp
+		{Feed: "vehicle_positions", Failures: 3, LastError: "HTTP 503"},                   // never answered
+	}}
+	svc := usecases.NewRealtimeFeedStatusService(repo, 5*time.Minute)
+
+	statuses, err := svc.Status(context.Background(), now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []bool{false, true, false, true}
+	for i, st := range statuses {
+		if st.Stale != want[i] {
+			t.Errorf("feed %d: expected stale=%v, got %v", i, want[i], st.Stale)
+		}
+	}
+
+	empty, _ := usecases.NewRealtimeFeedStatusService(&mockFeedStatusRepo{}, 5*time.Minute).Status(context.Background(), now)
+	if empty == nil {
+		t.Error("expected an empty list, got nil")
+	}
+}
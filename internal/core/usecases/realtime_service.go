@@ -2,40 +2,214 @@ package usecases
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"sync"
 	"time"
 
 	"github.com/samirrijal/bilbopass/internal/core/domain"
 	"github.com/samirrijal/bilbopass/internal/core/ports"
 )
 
+var (
+	// ErrInvalidPosition is returned for pushed vehicle positions that
+	// cannot be right.
+	ErrInvalidPosition = errors.New("invalid vehicle position")
+	// ErrSupersededPosition is returned for positions older than the
+	// vehicle's latest, which are dropped.
+	ErrSupersededPosition = errors.New("vehicle position superseded by a fresher one")
+)
+
+const (
+	// maxPositionLead is how far ahead of now a pushed position may be
+	// dated, for clock skew.
+	maxPositionLead = 5 * time.Minute
+	// maxPositionAge is how old a pushed position may be; older ones are
+	// no use on a live map.
+	maxPositionAge = time.Hour
+	// maxPositionSpeed bounds reported speeds, in m/s (300 km/h).
+	maxPositionSpeed = 83.0
+)
+
+// maxTrackedVehicles is how many vehicles' latest positions are kept. Past
+// it, those not heard from for maxPositionAge are forgotten, or else the
+// one heard from longest ago.
+const maxTrackedVehicles = 20000
+
 // RealtimeService processes incoming GTFS-RT vehicle positions.
 type RealtimeService struct {
 	vehicles  ports.VehiclePositionRepository
-	routes    ports.RouteRepository
+	trips     ports.TripResolver
 	publisher ports.EventPublisher
+
+	mu     sync.Mutex
+	latest map[string]sourcedTime // by agency and vehicle
+}
+
+// sourcedTime is when a vehicle's latest position was taken, and by which
+// source.
+type sourcedTime struct {
+	at     time.Time
+	source string
 }
 
 // NewRealtimeService creates a new RealtimeService.
 func NewRealtimeService(
 	vehicles ports.VehiclePositionRepository,
-	routes ports.RouteRepository,
+	trips ports.TripResolver,
 	publisher ports.EventPublisher,
 ) *RealtimeService {
-	return &RealtimeService{vehicles: vehicles, routes: routes, publisher: publisher}
+	return &RealtimeService{vehicles: vehicles, trips: trips, publisher: publisher, latest: map[string]sourcedTime{}}
 }
 
-// ProcessVehicleUpdate stores a position and checks for detours.
-func (s *RealtimeService) ProcessVehicleUpdate(ctx context.Context, vp *domain.VehiclePosition) error {
-	vp.Time = time.Now()
+// ProcessVehicleUpdate stores a position reported by the agency's feed and
+// publishes it to live map clients. vp carries the feed's trip and route
+// IDs: they are resolved to internal IDs for storage, and dropped when the
+// static schedule does not know them. Clients receive the feed's IDs, and
+// the internal ones in the domain.VehicleTripUUID and VehicleRouteUUID
+// metadata.
+//
+// Vehicles may be both polled and pushed: the freshest position wins, and
+// the source is recorded in its metadata (domain.VehicleSourceFeed unless
+// set). Positions older than the vehicle's latest, or as old but from
+// another source, are dropped with ErrSupersededPosition.
+func (s *RealtimeService) ProcessVehicleUpdate(ctx context.Context, agencyID string, vp *domain.VehiclePosition) error {
+	if vp.Time.IsZero() {
+		vp.Time = time.Now()
+	}
+	if vp.Metadata == nil {
+		vp.Metadata = map[string]any{}
+	}
+	source, _ := vp.Metadata["source"].(string)
+	if source == "" {
+		source = domain.VehicleSourceFeed
+		vp.Metadata["source"] = source
+	}
+	latest, undo, ok := s.claim(agencyID, vp.VehicleID, vp.Time, source)
+	if !ok {
+		return fmt.Errorf("%w: %s has one from %s at %s", ErrSupersededPosition,
+			vp.VehicleID, latest.source, latest.at.Format(time.RFC3339))
+	}
 
-	if err := s.vehicles.Insert(ctx, vp); err != nil {
+	stored := *vp
+	if vp.TripID != "" || vp.RouteID != "" {
+		tripID, routeID, err := s.trips.Resolve(ctx, agencyID, vp.TripID, vp.RouteID)
+		if err != nil {
+			undo()
+			return fmt.Errorf("resolve trip: %w", err)
+		}
+		stored.TripID, stored.RouteID = tripID, routeID
+	}
+	if err := s.vehicles.Insert(ctx, &stored); err != nil {
+		undo()
 		return fmt.Errorf("insert vehicle position: %w", err)
 	}
 
-	// Broadcast to WebSocket clients
+	// Broadcast to WebSocket clients and the API's live vehicle cache.
 	// Serialization is left to the publisher implementation.
-	_ = s.publisher.PublishVehiclePosition(ctx, vp)
+	published := *vp
+	published.Metadata = maps.Clone(vp.Metadata)
+	if stored.TripID != "" {
+		published.Metadata[domain.VehicleTripUUID] = stored.TripID
+	}
+	if stored.RouteID != "" {
+		published.Metadata[domain.VehicleRouteUUID] = stored.RouteID
+	}
+	_ = s.publisher.PublishVehiclePosition(ctx, &published)
+
+	return nil
+}
+
+// ProcessPushedUpdate validates a position an agency pushed, rather than
+// one polled from its feed, and processes it as ProcessVehicleUpdate does,
+// as from domain.VehicleSourcePush. Positions without a time are taken as
+// of now.
+func (s *RealtimeService) ProcessPushedUpdate(ctx context.Context, agencyID string, vp *domain.VehiclePosition, now time.Time) error {
+	if vp.Time.IsZero() {
+		vp.Time = now
+	}
+	if err := validatePosition(vp, now); err != nil {
+		return err
+	}
+	if vp.Metadata == nil {
+		vp.Metadata = map[string]any{}
+	}
+	vp.Metadata["source"] = domain.VehicleSourcePush
+	return s.ProcessVehicleUpdate(ctx, agencyID, vp)
+}
+
+// claim records a position taken at `at` from source as the vehicle's
+// latest, unless it has a fresher one, or one as fresh from another
+// source, which it returns. The claim is made before the position is
+// stored, so that of two positions processed at once only the fresher is;
+// undo gives it up when the position is not stored after all.
+func (s *RealtimeService) claim(agencyID, vehicleID string, at time.Time, source string) (latest sourcedTime, undo func(), ok bool) {
+	key := agencyID + "/" + vehicleID
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous, known := s.latest[key]
+	if known && (at.Before(previous.at) || at.Equal(previous.at) && source != previous.source) {
+		return previous, nil, false
+	}
+	if !known && len(s.latest) >= maxTrackedVehicles {
+		s.forget(at)
+	}
+	claimed := sourcedTime{at: at, source: source}
+	s.latest[key] = claimed
 
+	undo = func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		// Unless a fresher position was claimed since
+		if s.latest[key] != claimed {
+			return
+		}
+		if known {
+			s.latest[key] = previous
+		} else {
+			delete(s.latest, key)
+		}
+	}
+	return sourcedTime{}, undo, true
+}
+
+// forget drops the vehicles not heard from for maxPositionAge before now,
+// or, if there are none, the one heard from longest ago. s.mu is held.
+func (s *RealtimeService) forget(now time.Time) {
+	oldest := ""
+	for k, t := range s.latest {
+		if now.Sub(t.at) > maxPositionAge {
+			delete(s.latest, k)
+		} else if oldest == "" || t.at.Before(s.latest[oldest].at) {
+			oldest = k
+		}
+	}
+	if len(s.latest) >= maxTrackedVehicles {
+		delete(s.latest, oldest)
+	}
+}
+
+// validatePosition rejects positions without a vehicle, off the map, at
+// 0,0 (unset coordinates), with impossible bearings or speeds, or dated
+// too far from now.
+func validatePosition(vp *domain.VehiclePosition, now time.Time) error {
+	lat, lon := vp.Location.Lat, vp.Location.Lon
+	switch {
+	case vp.VehicleID == "":
+		return fmt.Errorf("%w: vehicle_id is required", ErrInvalidPosition)
+	case lat < -90 || lat > 90 || lon < -180 || lon > 180:
+		return fmt.Errorf("%w: %g,%g is off the map", ErrInvalidPosition, lat, lon)
+	case lat == 0 && lon == 0:
+		return fmt.Errorf("%w: no coordinates", ErrInvalidPosition)
+	case vp.Bearing < 0 || vp.Bearing > 360:
+		return fmt.Errorf("%w: bearing %g is not 0-360", ErrInvalidPosition, vp.Bearing)
+	case vp.Speed < 0 || vp.Speed > maxPositionSpeed:
+		return fmt.Errorf("%w: speed %g m/s", ErrInvalidPosition, vp.Speed)
+	case vp.Time.After(now.Add(maxPositionLead)):
+		return fmt.Errorf("%w: dated %s, in the future", ErrInvalidPosition, vp.Time.Format(time.RFC3339))
+	case vp.Time.Before(now.Add(-maxPositionAge)):
+		return fmt.Errorf("%w: dated %s, over an hour ago", ErrInvalidPosition, vp.Time.Format(time.RFC3339))
+	}
 	return nil
 }
@@ -0,0 +1,225 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock TripResolver ---
+
+// mockTripResolver knows GTFS trip and route IDs by agency ID and GTFS ID.
+type mockTripResolver struct {
+	trips  map[string]string
+	routes map[string]string
+}
+
+func (m *mockTripResolver) Resolve(ctx context.Context, agencyID, gtfsTripID, gtfsRouteID string) (string, string, error) {
+	return m.trips[agencyID+"/"+gtfsTripID], m.routes[agencyID+"/"+gtfsRouteID], nil
+}
+
+func TestRealtimeService_ProcessVehicleUpdate(t *testing.T) {
+	vehicles := &mockVehicleRepo{}
+	pub := &mockPublisher{}
+	resolver := &mockTripResolver{
+		trips:  map[string]string{"a1/T1": "trip-uuid"},
+		routes: map[string]string{"a1/L1": "route-uuid"},
+	}
+	svc := usecases.NewRealtimeService(vehicles, resolver, pub)
+	ctx := context.Background()
+	at := time.Date(2026, 3, 14, 8, 0, 0, 0, time.UTC)
+
+	vp := &domain.VehiclePosition{Time: at, VehicleID: "v1", TripID: "T1", RouteID: "L1"}
+	if err := svc.ProcessVehicleUpdate(ctx, "a1", vp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(vehicles.inserted) != 1 || vehicles.inserted[0].TripID != "trip-uuid" || vehicles.inserted[0].RouteID != "route-uuid" {
+		t.Fatalf("expected the position stored with internal IDs, got %+v", vehicles.inserted)
+	}
+	if !vehicles.inserted[0].Time.Equal(at) {
+		t.Errorf("expected the feed time kept, got %s", vehicles.inserted[0].Time)
+	}
+	if len(pub.positions) != 1 || pub.positions[0].TripID != "T1" {
+		t.Errorf("expected the position published with feed IDs, got %+v", pub.positions)
+	}
+	if meta := pub.positions[0].Metadata; meta[domain.VehicleTripUUID] != "trip-uuid" || meta[domain.VehicleRouteUUID] != "route-uuid" {
+		t.Errorf("expected the internal IDs in the published metadata, got %v", meta)
+	}
+	if _, ok := vehicles.inserted[0].Metadata[domain.VehicleRouteUUID]; ok {
+		t.Errorf("expected the stored metadata without the internal IDs, got %v", vehicles.inserted[0].Metadata)
+	}
+
+	// Another agency's trip is not resolved; the position is still stored.
+	if err := svc.ProcessVehicleUpdate(ctx, "a2", &domain.VehiclePosition{VehicleID: "v2", TripID: "T1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := vehicles.inserted[1]; got.TripID != "" || got.Time.IsZero() {
+		t.Errorf("expected an unresolved trip dropped and the time set, got %+v", got)
+	}
+}
+
+func TestRealtimeService_ProcessPushedUpdate(t *testing.T) {
+	vehicles := &mockVehicleRepo{}
+	pub := &mockPublisher{}
+	svc := usecases.NewRealtimeService(vehicles, &mockTripResolver{}, pub)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 14, 8, 0, 0, 0, time.UTC)
+	bilbao := domain.GeoPoint{Lat: 43.263, Lon: -2.935}
+
+	for name, vp := range map[string]domain.VehiclePosition{
+		"no vehicle":  {Location: bilbao},
+		"off the map": {VehicleID: "v1", Location: domain.GeoPoint{Lat: 95, Lon: -2.9}},
+		"unset":       {VehicleID: "v1"},
+		"bearing":     {VehicleID: "v1", Location: bilbao, Bearing: 400},
+		"speed":       {VehicleID: "v1", Location: bilbao, Speed: 120},
+		"future":      {VehicleID: "v1", Location: bilbao, Time: now.Add(10 * time.Minute)},
+		"stale":       {VehicleID: "v1", Location: bilbao, Time: now.Add(-2 * time.Hour)},
+	} {
+		if err := svc.ProcessPushedUpdate(ctx, "a1", &vp, now); !errors.Is(err, usecases.ErrInvalidPosition) {
+			t.Errorf("%s: expected ErrInvalidPosition, got %v", name, err)
+		}
+	}
+	if len(vehicles.inserted) != 0 || len(pub.positions) != 0 {
+		t.Fatalf("expected invalid positions dropped, got %+v", vehicles.inserted)
+	}
+
+	vp := &domain.VehiclePosition{VehicleID: "v1", Location: bilbao, Bearing: 90, Speed: 8}
+	if err := svc.ProcessPushedUpdate(ctx, "a1", vp, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(vehicles.inserted) != 1 || !vehicles.inserted[0].Time.Equal(now) {
+		t.Errorf("expected the position stored as of now, got %+v", vehicles.inserted)
+	}
+	if len(pub.positions) != 1 {
+		t.Errorf("expected the position published, got %+v", pub.positions)
+	}
+}
+
+func TestRealtimeService_FreshestSourceWins(t *testing.T) {
+	vehicles := &mockVehicleRepo{}
+	svc := usecases.NewRealtimeService(vehicles, &mockTripResolver{}, &mockPublisher{})
+	ctx := context.Background()
+	now := time.Date(2026, 3, 14, 8, 0, 0, 0, time.UTC)
+	bilbao := domain.GeoPoint{Lat: 43.263, Lon: -2.935}
+
+	polled := func(at time.Time) *domain.VehiclePosition {
+		return &domain.VehiclePosition{Time: at, VehicleID: "v1", Location: bilbao, Metadata: map[string]any{"agency": "bilbobus"}}
+	}
+	pushed := func(at time.Time) *domain.VehiclePosition {
+		return &domain.VehiclePosition{Time: at, VehicleID: "v1", Location: bilbao}
+	}
+
+	if err := svc.ProcessVehicleUpdate(ctx, "a1", polled(now.Add(-time.Minute))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.ProcessPushedUpdate(ctx, "a1", pushed(now.Add(-30*time.Second)), now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The feed lags behind the pushes: its older position is dropped, as is
+	// one as old as the pushed one.
+	for _, at := range []time.Time{now.Add(-45 * time.Second), now.Add(-30 * time.Second)} {
+		if err := svc.ProcessVehicleUpdate(ctx, "a1", polled(at)); !errors.Is(err, usecases.ErrSupersededPosition) {
+			t.Errorf("expected ErrSupersededPosition for a polled position at %s, got %v", at, err)
+		}
+	}
+	// The same position pushed again, and other agencies' vehicles, pass.
+	if err := svc.ProcessPushedUpdate(ctx, "a1", pushed(now.Add(-30*time.Second)), now); err != nil {
+		t.Errorf("expected a repeated position of the same source accepted, got %v", err)
+	}
+	if err := svc.ProcessVehicleUpdate(ctx, "a2", polled(now.Add(-time.Hour))); err != nil {
+		t.Errorf("expected another agency's vehicle accepted, got %v", err)
+	}
+	if err := svc.ProcessVehicleUpdate(ctx, "a1", polled(now)); err != nil {
+		t.Fatalf("expected a fresher polled position accepted, got %v", err)
+	}
+
+	var sources []string
+	for _, vp := range vehicles.inserted {
+		source, _ := vp.Metadata["source"].(string)
+		sources = append(sources, source)
+	}
+	want := []string{domain.VehicleSourceFeed, domain.VehicleSourcePush, domain.VehicleSourcePush, domain.VehicleSourceFeed, domain.VehicleSourceFeed}
+	if len(sources) != len(want) {
+		t.Fatalf("expected %d positions stored, got %v", len(want), sources)
+	}
+	for i := range want {
+		if sources[i] != want[i] {
+			t.Errorf("position %d: expected source %s, got %s", i, want[i], sources[i])
+		}
+	}
+	if vehicles.inserted[0].Metadata["agency"] != "bilbobus" {
+		t.Errorf("expected the position's metadata kept, got %v", vehicles.inserted[0].Metadata)
+	}
+}
+
+func TestRealtimeService_FailedInsertKeepsLatest(t *testing.T) {
+	vehicles := &mockVehicleRepo{}
+	svc := usecases.NewRealtimeService(vehicles, &mockTripResolver{}, &mockPublisher{})
+	ctx := context.Background()
+	now := time.Date(2026, 3, 14, 8, 0, 0, 0, time.UTC)
+	position := func(vehicleID string, at time.Time) *domain.VehiclePosition {
+		return &domain.VehiclePosition{Time: at, VehicleID: vehicleID, Location: domain.GeoPoint{Lat: 43.263, Lon: -2.935}}
+	}
+
+	if err := svc.ProcessVehicleUpdate(ctx, "a1", position("v1", now.Add(-time.Minute))); err != nil {
+		t.Fatal(err)
+	}
+	vehicles.insertErr = errors.New("db down")
+	for _, v := range []string{"v1", "v2"} {
+		if err := svc.ProcessVehicleUpdate(ctx, "a1", position(v, now)); err == nil {
+			t.Fatalf("%s: expected the insert error", v)
+		}
+	}
+	vehicles.insertErr = nil
+
+	// The failed positions are not the vehicles' latest: older ones are
+	// still taken, but not ones older than v1's stored position.
+	if err := svc.ProcessVehicleUpdate(ctx, "a1", position("v1", now.Add(-2*time.Minute))); !errors.Is(err, usecases.ErrSupersededPosition) {
+		t.Errorf("expected v1's stored position kept as its latest, got %v", err)
+	}
+	for _, v := range []string{"v1", "v2"} {
+		if err := svc.ProcessVehicleUpdate(ctx, "a1", position(v, now.Add(-30*time.Second))); err != nil {
+			t.Errorf("%s: expected a retry older than the failed position accepted, got %v", v, err)
+		}
+	}
+	if len(vehicles.inserted) != 3 {
+		t.Errorf("expected 3 positions stored, got %d", len(vehicles.inserted))
+	}
+}
+
+func TestRealtimeService_TrackedVehiclesCapped(t *testing.T) {
+	const maxTrackedVehicles = 20000 // as in realtime_service.go
+
+	svc := usecases.NewRealtimeService(&mockVehicleRepo{}, &mockTripResolver{}, &mockPublisher{})
+	ctx := context.Background()
+	now := time.Date(2026, 3, 14, 8, 0, 0, 0, time.UTC)
+	position := func(vehicleID string, at time.Time) *domain.VehiclePosition {
+		return &domain.VehiclePosition{Time: at, VehicleID: vehicleID, Location: domain.GeoPoint{Lat: 43.263, Lon: -2.935}}
+	}
+
+	// All heard from within maxPositionAge, v0 longest ago.
+	if err := svc.ProcessVehicleUpdate(ctx, "a1", position("v0", now.Add(-10*time.Minute))); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < maxTrackedVehicles; i++ {
+		if err := svc.ProcessVehicleUpdate(ctx, "a1", position(fmt.Sprintf("v%d", i), now)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := svc.ProcessVehicleUpdate(ctx, "a1", position("v0", now.Add(-20*time.Minute))); !errors.Is(err, usecases.ErrSupersededPosition) {
+		t.Fatalf("expected v0's latest tracked, got %v", err)
+	}
+
+	// One more vehicle forgets v0, so its older position is taken again.
+	if err := svc.ProcessVehicleUpdate(ctx, "a1", position("new", now)); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.ProcessVehicleUpdate(ctx, "a1", position("v0", now.Add(-20*time.Minute))); err != nil {
+		t.Errorf("expected v0 forgotten, got %v", err)
+	}
+}
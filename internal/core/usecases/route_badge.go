@@ -0,0 +1,110 @@
+package usecases
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// ErrInvalidBadgeSize is returned for badge sizes outside the allowed range.
+var ErrInvalidBadgeSize = errors.New("invalid badge size")
+
+const (
+	defaultBadgeHeight = 24
+	minBadgeHeight     = 12
+	maxBadgeHeight     = 256
+	maxBadgeWidth      = 1024
+)
+
+// BadgeSize is the size a route badge is drawn at. Zero values take the
+// defaults: 24px tall and as wide as the label needs.
+type BadgeSize struct {
+	Height   int
+	MinWidth int // widens short labels so a row of badges lines up
+}
+
+// Badge renders the route's line badge as SVG: its short name in its text
+// color on its color. Routes without colors use the GTFS defaults, black on
+// white.
+func (s *RouteService) Badge(ctx context.Context, id string, size BadgeSize) ([]byte, error) {
+	if size.Height == 0 {
+		size.Height = defaultBadgeHeight
+	}
+	if size.Height < minBadgeHeight || size.Height > maxBadgeHeight {
+		return nil, fmt.Errorf("%w: height must be between %d and %d", ErrInvalidBadgeSize, minBadgeHeight, maxBadgeHeight)
+	}
+	if size.MinWidth < 0 || size.MinWidth > maxBadgeWidth {
+		return nil, fmt.Errorf("%w: min_width must be between 0 and %d", ErrInvalidBadgeSize, maxBadgeWidth)
+	}
+
+	route, err := s.routes.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if route == nil {
+		return nil, ErrRouteNotFound
+	}
+	return routeBadgeSVG(route, size), nil
+}
+
+// routeBadgeSVG draws the badge. Text width is estimated from the label's
+// length, since the client's font is unknown; labels are short line names.
+func routeBadgeSVG(route *domain.Route, size BadgeSize) []byte {
+	label := route.ShortName
+	if label == "" {
+		label = route.RouteID
+	}
+	bg := badgeColor(route.Color, "FFFFFF")
+	fg := badgeColor(route.TextColor, "000000")
+
+	h := float64(size.Height)
+	fontSize := h * 0.6
+	padding := h * 0.3
+	width := max(float64(utf8.RuneCountInString(label))*fontSize*0.62+2*padding, h, float64(size.MinWidth))
+
+	title := label
+	if route.LongName != "" {
+		title += " " + route.LongName
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %[1]s %[2]s" role="img" aria-label="%s">`,
+		px(width), px(h), escapeXML(title))
+	fmt.Fprintf(&b, `<title>%s</title>`, escapeXML(title))
+	fmt.Fprintf(&b, `<rect width="%s" height="%s" rx="%s" fill="#%s"/>`, px(width), px(h), px(h*0.2), bg)
+	if bg == "FFFFFF" {
+		// Keep white badges visible on white backgrounds.
+		fmt.Fprintf(&b, `<rect x="0.5" y="0.5" width="%s" height="%s" rx="%s" fill="none" stroke="#%s" stroke-opacity="0.3"/>`,
+			px(width-1), px(h-1), px(h*0.2), fg)
+	}
+	fmt.Fprintf(&b, `<text x="%s" y="%s" fill="#%s" font-family="Helvetica,Arial,sans-serif" font-size="%s" font-weight="bold" text-anchor="middle">%s</text>`,
+		px(width/2), px(h/2+fontSize*0.35), fg, px(fontSize), escapeXML(label))
+	b.WriteString(`</svg>`)
+	return []byte(b.String())
+}
+
+// badgeColor returns c as RRGGBB, or def when the feed's color is unusable.
+func badgeColor(c, def string) string {
+	c = strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(c), "#"))
+	if !validHexColor(c) {
+		return def
+	}
+	return c
+}
+
+// px formats an SVG length to one decimal place.
+func px(f float64) string {
+	return strconv.FormatFloat(float64(int(f*10+0.5))/10, 'f', -1, 64)
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
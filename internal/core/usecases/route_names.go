@@ -0,0 +1,212 @@
+package usecases
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// OriginalLongNameKey is the route metadata key holding the long name as
+// the feed gave it, when NormalizeRouteName changed it.
+const OriginalLongNameKey = "original_long_name"
+
+// lowerWords stay lower case in title-cased names, but first.
+var lowerWords = map[string]bool{
+	"a": true, "al": true, "con": true, "de": true, "del": true, "e": true, "el": true,
+	"en": true, "la": true, "las": true, "los": true, "o": true, "por": true, "u": true, "y": true,
+}
+
+// romanNumerals stay upper case in title-cased names, as in "Zona III".
+var romanNumerals = map[string]bool{
+	"I": true, "II": true, "III": true, "IV": true, "V": true, "VI": true,
+	"VII": true, "VIII": true, "IX": true, "X": true, "XI": true, "XII": true,
+}
+
+// cp1252 holds the characters Windows-1252 puts at 0x80-0x9F; the bytes it
+// leaves undefined decode to the C1 controls, as in Latin-1.
+var cp1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// NormalizeRouteName cleans up a route long name with the agency's rules:
+// mending mojibake, then stripping route codes, then title-casing names
+// written all in one case. Whitespace is collapsed with any rule on. A name
+// the rules would leave empty is kept as it is.
+func NormalizeRouteName(rules domain.RouteNameRules, shortName, longName string) string {
+	if !rules.FixMojibake && !rules.StripCodes && !rules.TitleCase {
+		return longName
+	}
+	name := longName
+	if rules.FixMojibake {
+		name = fixMojibake(name)
+	}
+	name = strings.Join(strings.Fields(name), " ")
+	if rules.StripCodes {
+		name = stripRouteCodes(name, fixMojibake(shortName))
+	}
+	if rules.TitleCase {
+		name = titleCase(name)
+	}
+	if name == "" {
+		return longName
+	}
+	return name
+}
+
+// fixMojibake mends text in a legacy encoding: Windows-1252 bytes, which
+// are not valid UTF-8, and UTF-8 that was read as Windows-1252 and encoded
+// again, such as "BasurtoÃ±" for "Basurtoñ".
+func fixMojibake(s string) string {
+	if !utf8.ValidString(s) {
+		var b strings.Builder
+		for i := 0; i < len(s); i++ {
+			switch c := s[i]; {
+			case c < 0x80:
+				b.WriteByte(c)
+			case c < 0xA0:
+				b.WriteRune(cp1252[c-0x80])
+			default:
+				b.WriteRune(rune(c))
+			}
+		}
+		return b.String()
+	}
+
+	raw := make([]byte, 0, len(s))
+	multibyte := false
+	for _, r := range s {
+		c, ok := cp1252Byte(r)
+		if !ok {
+			return s
+		}
+		multibyte = multibyte || c >= 0x80
+		raw = append(raw, c)
+	}
+	if !multibyte || !utf8.Valid(raw) {
+		return s
+	}
+	return string(raw)
+}
+
+// cp1252Byte returns the Windows-1252 (or Latin-1) byte encoding r.
+func cp1252Byte(r rune) (byte, bool) {
+	if r < 0x80 || (r >= 0xA0 && r <= 0xFF) {
+		return byte(r), true
+	}
+	for i, c := range cp1252 {
+		if c == r {
+			return byte(0x80 + i), true
+		}
+	}
+	return 0, false
+}
+
+// stripRouteCodes removes route codes repeated in a long name: the short
+// name before it ("L1 - Etxebarri-Basauri", "A3122: Bilbao-Leioa") and
+// codes in brackets or parentheses ("Bilbao-Leioa (A3122)").
+func stripRouteCodes(name, shortName string) string {
+	if shortName != "" && len(name) > len(shortName) && strings.EqualFold(name[:len(shortName)], shortName) {
+		rest := name[len(shortName):]
+		if trimmed := strings.TrimLeft(rest, " -–—:.|/"); trimmed != rest && trimmed != "" {
+			name = trimmed
+		}
+	}
+
+	var b strings.Builder
+	for name != "" {
+		open := strings.IndexAny(name, "([")
+		if open < 0 {
+			b.WriteString(name)
+			break
+		}
+		closer := ")"
+		if name[open] == '[' {
+			closer = "]"
+		}
+		end := strings.Index(name[open:], closer)
+		if end < 0 {
+			b.WriteString(name)
+			break
+		}
+		code := name[open+1 : open+end]
+		if isRouteCode(code, shortName) {
+			b.WriteString(name[:open])
+		} else {
+			b.WriteString(name[:open+end+1])
+		}
+		name = name[open+end+1:]
+	}
+	return strings.Trim(strings.Join(strings.Fields(b.String()), " "), " -–—:|/")
+}
+
+// isRouteCode reports whether the text in brackets is a route code: the
+// short name, or a single word with a digit in it.
+func isRouteCode(code, shortName string) bool {
+	if code == "" || strings.ContainsAny(code, " \t") {
+		return false
+	}
+	if shortName != "" && strings.EqualFold(code, shortName) {
+		return true
+	}
+	return strings.ContainsAny(code, "0123456789")
+}
+
+// titleCase capitalizes the words of a name written all in upper or all in
+// lower case; names in mixed case were cased on purpose and are kept.
+// Words with digits, such as "BI-631", are kept, Roman numerals are upper
+// case and articles and prepositions lower case but for the first word.
+func titleCase(name string) string {
+	hasUpper, hasLower := false, false
+	for _, r := range name {
+		hasUpper = hasUpper || unicode.IsUpper(r)
+		hasLower = hasLower || unicode.IsLower(r)
+	}
+	if hasUpper == hasLower {
+		return name
+	}
+
+	tokens := strings.Split(name, " ")
+	for i, token := range tokens {
+		if strings.ContainsFunc(token, unicode.IsDigit) {
+			continue
+		}
+		first := i == 0
+		var b strings.Builder
+		for token != "" {
+			start := strings.IndexFunc(token, isWordRune)
+			if start < 0 {
+				b.WriteString(token)
+				break
+			}
+			b.WriteString(token[:start])
+			token = token[start:]
+			end := strings.IndexFunc(token, func(r rune) bool { return !isWordRune(r) })
+			if end < 0 {
+				end = len(token)
+			}
+			b.WriteString(titleWord(token[:end], first))
+			first = false
+			token = token[end:]
+		}
+		tokens[i] = b.String()
+	}
+	return strings.Join(tokens, " ")
+}
+
+func isWordRune(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+func titleWord(word string, first bool) string {
+	upper := strings.ToUpper(word)
+	lower := strings.ToLower(word)
+	switch {
+	case romanNumerals[upper]:
+		return upper
+	case lowerWords[lower] && !first:
+		return lower
+	}
+	r, size := utf8.DecodeRuneInString(lower)
+	return string(unicode.ToUpper(r)) + lower[size:]
+}
@@ -1,31 +1,176 @@
 package usecases
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
 
 	"github.com/samirrijal/bilbopass/internal/core/domain"
 	"github.com/samirrijal/bilbopass/internal/core/ports"
 )
 
+// vehicleStaleAfter is how long a vehicle that stopped reporting is still
+// shown; feeds are polled every 30 seconds.
+const vehicleStaleAfter = 3 * time.Minute
+
+// maxVehicleHistoryPoints bounds the positions a vehicle history returns;
+// longer ranges are thinned to one position per time bucket.
+const maxVehicleHistoryPoints = 2000
+
+// Route cache TTLs, in seconds. Cached routes are also dropped when the
+// static data version moves (see RouteService.Invalidate).
+const (
+	routeTTL       = 600
+	routeListTTL   = 600
+	routeByStopTTL = 300
+)
+
 // RouteService handles route-related business logic.
 type RouteService struct {
 	routes   ports.RouteRepository
 	vehicles ports.VehiclePositionRepository
+	cache    ports.CacheService
+	lookups  singleflight.Group
+	version  atomic.Int64 // static data version in Unix microseconds, in cache keys; 0 when unknown
+}
+
+// NewRouteService creates a new RouteService. cache may be nil.
+func NewRouteService(routes ports.RouteRepository, vehicles ports.VehiclePositionRepository, cache ports.CacheService) *RouteService {
+	return &RouteService{routes: routes, vehicles: vehicles, cache: cache}
 }
 
-// NewRouteService creates a new RouteService.
-func NewRouteService(routes ports.RouteRepository, vehicles ports.VehiclePositionRepository) *RouteService {
-	return &RouteService{routes: routes, vehicles: vehicles}
+// Invalidate drops the routes cached before the static data reached
+// version, as when a feed is applied. Cache keys name the version, so every
+// API instance reading the same version shares entries, and those of older
+// versions expire unread. Until it is called with a version, routes are not
+// cached: every process would share the keys of the unknown version,
+// whatever data they were cached from.
+func (s *RouteService) Invalidate(version time.Time) {
+	if version.IsZero() {
+		s.version.Store(0)
+		return
+	}
+	s.version.Store(version.UnixMicro())
 }
 
-// GetByID returns a route by its UUID.
+// cached reports whether lookups go through the cache, which needs the
+// static data version.
+func (s *RouteService) cached() bool {
+	return s.cache != nil && s.version.Load() != 0
+}
+
+// cacheKey names a cached lookup under the current static data version.
+func (s *RouteService) cacheKey(op, id string) string {
+	return "routes:" + op + ":" + strconv.FormatInt(s.version.Load(), 36) + ":" + id
+}
+
+// GetByID returns a route by its UUID, or ErrRouteNotFound. Routes are
+// cached for routeTTL and unknown ones for missingTTL; concurrent lookups
+// of the same route share one query.
 func (s *RouteService) GetByID(ctx context.Context, id string) (*domain.Route, error) {
-	return s.routes.GetByID(ctx, id)
+	cacheKey := s.cacheKey("id", id)
+	if s.cached() {
+		if data, err := s.cache.Get(ctx, cacheKey); err == nil {
+			if bytes.Equal(data, missingMarker) {
+				return nil, ErrRouteNotFound
+			}
+			var route domain.Route
+			if err := json.Unmarshal(data, &route); err == nil {
+				return &route, nil
+			}
+		}
+	}
+
+	route, shared, err := coalesce(ctx, &s.lookups, cacheKey, func(ctx context.Context) (*domain.Route, error) {
+		route, err := s.routes.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if s.cached() {
+			if route == nil {
+				_ = s.cache.Set(ctx, cacheKey, missingMarker, missingTTL)
+			} else if data, err := json.Marshal(route); err == nil {
+				_ = s.cache.Set(ctx, cacheKey, data, routeTTL)
+			}
+		}
+		return route, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if route == nil {
+		return nil, ErrRouteNotFound
+	}
+	if shared {
+		copied := *route
+		route = &copied
+	}
+	return route, nil
 }
 
-// ListByAgency returns all routes for a given agency.
+// ListByAgency returns all routes for a given agency, cached for
+// routeListTTL.
 func (s *RouteService) ListByAgency(ctx context.Context, agencyID string) ([]domain.Route, error) {
-	return s.routes.ListByAgency(ctx, agencyID)
+	return s.cachedList(ctx, s.cacheKey("agency", agencyID), routeListTTL, func() ([]domain.Route, error) {
+		return s.routes.ListByAgency(ctx, agencyID)
+	})
+}
+
+// cachedList reads a list of routes through the cache.
+func (s *RouteService) cachedList(ctx context.Context, cacheKey string, ttl int, load func() ([]domain.Route, error)) ([]domain.Route, error) {
+	if s.cached() {
+		if data, err := s.cache.Get(ctx, cacheKey); err == nil {
+			var routes []domain.Route
+			if err := json.Unmarshal(data, &routes); err == nil {
+				return routes, nil
+			}
+		}
+	}
+
+	routes, err := load()
+	if err != nil {
+		return nil, err
+	}
+	if s.cached() {
+		if data, err := json.Marshal(routes); err == nil {
+			_ = s.cache.Set(ctx, cacheKey, data, ttl)
+		}
+	}
+	return routes, nil
+}
+
+// List returns a page of the routes the filter keeps, of any agency unless
+// it names one, with how many it keeps in all.
+func (s *RouteService) List(ctx context.Context, filter domain.RouteFilter, offset, limit int) ([]domain.Route, int, error) {
+	filter.Query = strings.TrimSpace(filter.Query)
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	routes, total, err := s.routes.ListAll(ctx, filter, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	if routes == nil {
+		routes = []domain.Route{}
+	}
+	return routes, total, nil
+}
+
+// GetByIDs returns multiple routes by their IDs, uncached.
+func (s *RouteService) GetByIDs(ctx context.Context, ids []string) ([]domain.Route, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return s.routes.GetByIDs(ctx, ids)
 }
 
 // GetLiveVehicles returns the latest vehicle positions on a route.
@@ -33,7 +178,64 @@ func (s *RouteService) GetLiveVehicles(ctx context.Context, routeID string) ([]d
 	return s.vehicles.LatestByRoute(ctx, routeID)
 }
 
-// ListByStop returns the distinct routes that serve a given stop.
+// GetLiveVehiclesByRoutes returns the latest vehicle positions on several
+// routes at once, by route ID.
+func (s *RouteService) GetLiveVehiclesByRoutes(ctx context.Context, routeIDs []string) (map[string][]domain.VehiclePosition, error) {
+	byRoute := make(map[string][]domain.VehiclePosition, len(routeIDs))
+	if len(routeIDs) == 0 {
+		return byRoute, nil
+	}
+	positions, err := s.vehicles.LatestByRoutes(ctx, routeIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, vp := range positions {
+		byRoute[vp.RouteID] = append(byRoute[vp.RouteID], vp)
+	}
+	return byRoute, nil
+}
+
+// NearbyVehicles returns vehicles within radiusMeters of a point, nearest
+// first, of the route types the filter keeps. Vehicles that have not
+// reported for vehicleStaleAfter are left out.
+func (s *RouteService) NearbyVehicles(ctx context.Context, lat, lon, radiusMeters float64, limit int, routeTypes domain.RouteTypeFilter) ([]domain.VehiclePosition, error) {
+	return s.vehicles.LatestNearby(ctx, lat, lon, radiusMeters, time.Now().Add(-vehicleStaleAfter), limit, routeTypes)
+}
+
+// VehicleHistory returns a vehicle's positions between from and to, oldest
+// first, and the bucket they were thinned to so that at most
+// maxVehicleHistoryPoints are returned. agency may be empty.
+func (s *RouteService) VehicleHistory(ctx context.Context, agency, vehicleID string, from, to time.Time) ([]domain.VehiclePosition, time.Duration, error) {
+	bucket := historyBucket(to.Sub(from))
+	positions, err := s.vehicles.History(ctx, agency, vehicleID, from, to, bucket)
+	return positions, bucket, err
+}
+
+// historyBucket is the shortest whole-second bucket that fits span into
+// maxVehicleHistoryPoints.
+func historyBucket(span time.Duration) time.Duration {
+	perBucket := maxVehicleHistoryPoints * time.Second
+	return max((span+perBucket-1)/perBucket*time.Second, time.Second)
+}
+
+// ListByStop returns the distinct routes that serve a given stop, cached
+// for routeByStopTTL.
 func (s *RouteService) ListByStop(ctx context.Context, stopUUID string) ([]domain.Route, error) {
-	return s.routes.ListByStop(ctx, stopUUID)
+	return s.cachedList(ctx, s.cacheKey("stop", stopUUID), routeByStopTTL, func() ([]domain.Route, error) {
+		return s.routes.ListByStop(ctx, stopUUID)
+	})
+}
+
+// GetShape returns the route with its geometry populated.
+func (s *RouteService) GetShape(ctx context.Context, id string) (*domain.Route, error) {
+	route, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	shape, err := s.routes.GetShape(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	route.Shape = shape
+	return route, nil
 }
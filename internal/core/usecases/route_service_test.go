@@ -2,7 +2,10 @@ package usecases_test
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/samirrijal/bilbopass/internal/core/domain"
 	"github.com/samirrijal/bilbopass/internal/core/usecases"
@@ -13,6 +16,10 @@ import (
 type mockRouteRepo struct {
 	getByIDFn      func(ctx context.Context, id string) (*domain.Route, error)
 	listByAgencyFn func(ctx context.Context, agencyID string) ([]domain.Route, error)
+	getShapeFn     func(ctx context.Context, id string) (*domain.GeoLineString, error)
+	routes         []domain.Route
+	lastFilter     domain.RouteFilter
+	lastLimit      int
 }
 
 func (m *mockRouteRepo) Upsert(ctx context.Context, r *domain.Route) error        { return nil }
@@ -25,6 +32,16 @@ func (m *mockRouteRepo) GetByID(ctx context.Context, id string) (*domain.Route,
 	return nil, nil
 }
 
+func (m *mockRouteRepo) GetByIDs(ctx context.Context, ids []string) ([]domain.Route, error) {
+	var routes []domain.Route
+	for _, id := range ids {
+		if route, _ := m.GetByID(ctx, id); route != nil {
+			routes = append(routes, *route)
+		}
+	}
+	return routes, nil
+}
+
 func (m *mockRouteRepo) ListByAgency(ctx context.Context, agencyID string) ([]domain.Route, error) {
 	if m.listByAgencyFn != nil {
 		return m.listByAgencyFn(ctx, agencyID)
@@ -32,17 +49,43 @@ func (m *mockRouteRepo) ListByAgency(ctx context.Context, agencyID string) ([]do
 	return nil, nil
 }
 
+func (m *mockRouteRepo) ListAll(ctx context.Context, filter domain.RouteFilter, offset, limit int) ([]domain.Route, int, error) {
+	m.lastFilter, m.lastLimit = filter, limit
+	if offset >= len(m.routes) {
+		return nil, len(m.routes), nil
+	}
+	return m.routes[offset:min(offset+limit, len(m.routes))], len(m.routes), nil
+}
+
 func (m *mockRouteRepo) ListByStop(ctx context.Context, stopUUID string) ([]domain.Route, error) {
 	return nil, nil
 }
 
+func (m *mockRouteRepo) GetShape(ctx context.Context, id string) (*domain.GeoLineString, error) {
+	if m.getShapeFn != nil {
+		return m.getShapeFn(ctx, id)
+	}
+	return nil, nil
+}
+
 // --- Mock VehiclePositionRepository ---
 
 type mockVehicleRepo struct {
 	latestByRouteFn func(ctx context.Context, routeID string) ([]domain.VehiclePosition, error)
+	latestNearbyFn  func(ctx context.Context, lat, lon, radiusMeters float64, since time.Time, limit int) ([]domain.VehiclePosition, error)
+	historyFn       func(ctx context.Context, agency, vehicleID string, from, to time.Time, bucket time.Duration) ([]domain.VehiclePosition, error)
+	fleetFn         func(ctx context.Context, agency string, since, now time.Time) (*domain.Fleet, error)
+	inserted        []domain.VehiclePosition
+	insertErr       error
 }
 
-func (m *mockVehicleRepo) Insert(ctx context.Context, vp *domain.VehiclePosition) error { return nil }
+func (m *mockVehicleRepo) Insert(ctx context.Context, vp *domain.VehiclePosition) error {
+	if m.insertErr != nil {
+		return m.insertErr
+	}
+	m.inserted = append(m.inserted, *vp)
+	return nil
+}
 func (m *mockVehicleRepo) InsertBatch(ctx context.Context, vps []domain.VehiclePosition) error {
 	return nil
 }
@@ -54,6 +97,39 @@ func (m *mockVehicleRepo) LatestByRoute(ctx context.Context, routeID string) ([]
 	return nil, nil
 }
 
+func (m *mockVehicleRepo) LatestByRoutes(ctx context.Context, routeIDs []string) ([]domain.VehiclePosition, error) {
+	var positions []domain.VehiclePosition
+	for _, id := range routeIDs {
+		vps, err := m.LatestByRoute(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		positions = append(positions, vps...)
+	}
+	return positions, nil
+}
+
+func (m *mockVehicleRepo) LatestNearby(ctx context.Context, lat, lon, radiusMeters float64, since time.Time, limit int, routeTypes domain.RouteTypeFilter) ([]domain.VehiclePosition, error) {
+	if m.latestNearbyFn != nil {
+		return m.latestNearbyFn(ctx, lat, lon, radiusMeters, since, limit)
+	}
+	return nil, nil
+}
+
+func (m *mockVehicleRepo) History(ctx context.Context, agency, vehicleID string, from, to time.Time, bucket time.Duration) ([]domain.VehiclePosition, error) {
+	if m.historyFn != nil {
+		return m.historyFn(ctx, agency, vehicleID, from, to, bucket)
+	}
+	return nil, nil
+}
+
+func (m *mockVehicleRepo) Fleet(ctx context.Context, agency string, since, now time.Time) (*domain.Fleet, error) {
+	if m.fleetFn != nil {
+		return m.fleetFn(ctx, agency, since, now)
+	}
+	return &domain.Fleet{AgencySlug: agency, Since: since}, nil
+}
+
 func TestRouteService_GetByID(t *testing.T) {
 	repo := &mockRouteRepo{
 		getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
@@ -61,7 +137,7 @@ func TestRouteService_GetByID(t *testing.T) {
 		},
 	}
 
-	svc := usecases.NewRouteService(repo, &mockVehicleRepo{})
+	svc := usecases.NewRouteService(repo, &mockVehicleRepo{}, nil)
 	route, err := svc.GetByID(context.Background(), "route-1")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -71,6 +147,30 @@ func TestRouteService_GetByID(t *testing.T) {
 	}
 }
 
+func TestRouteService_GetByID_NotFound(t *testing.T) {
+	lookups := 0
+	repo := &mockRouteRepo{
+		getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
+			lookups++
+			return nil, nil
+		},
+	}
+	svc := usecases.NewRouteService(repo, &mockVehicleRepo{}, &mockCache{})
+	svc.Invalidate(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+
+	for range 3 {
+		if _, err := svc.GetByID(context.Background(), "nope"); !errors.Is(err, usecases.ErrRouteNotFound) {
+			t.Fatalf("expected ErrRouteNotFound, got %v", err)
+		}
+	}
+	if _, err := svc.GetShape(context.Background(), "nope"); !errors.Is(err, usecases.ErrRouteNotFound) {
+		t.Errorf("expected ErrRouteNotFound for the shape, got %v", err)
+	}
+	if lookups != 1 {
+		t.Errorf("expected the miss to be remembered, got %d lookups", lookups)
+	}
+}
+
 func TestRouteService_ListByAgency(t *testing.T) {
 	repo := &mockRouteRepo{
 		listByAgencyFn: func(ctx context.Context, agencyID string) ([]domain.Route, error) {
@@ -81,7 +181,7 @@ func TestRouteService_ListByAgency(t *testing.T) {
 		},
 	}
 
-	svc := usecases.NewRouteService(repo, &mockVehicleRepo{})
+	svc := usecases.NewRouteService(repo, &mockVehicleRepo{}, nil)
 	routes, err := svc.ListByAgency(context.Background(), "metro_bilbao")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -91,6 +191,113 @@ func TestRouteService_ListByAgency(t *testing.T) {
 	}
 }
 
+func TestRouteService_CachedUntilInvalidated(t *testing.T) {
+	byID, byAgency := 0, 0
+	repo := &mockRouteRepo{
+		getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
+			byID++
+			return &domain.Route{ID: id, ShortName: "L1"}, nil
+		},
+		listByAgencyFn: func(ctx context.Context, agencyID string) ([]domain.Route, error) {
+			byAgency++
+			return []domain.Route{{ShortName: "L1"}, {ShortName: "L2"}}, nil
+		},
+	}
+	svc := usecases.NewRouteService(repo, &mockVehicleRepo{}, &mockCache{})
+	svc.Invalidate(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		route, err := svc.GetByID(ctx, "r1")
+		if err != nil || route.ShortName != "L1" {
+			t.Fatalf("GetByID: got %+v, %v", route, err)
+		}
+		routes, err := svc.ListByAgency(ctx, "metro_bilbao")
+		if err != nil || len(routes) != 2 {
+			t.Fatalf("ListByAgency: got %d routes, %v", len(routes), err)
+		}
+	}
+	if byID != 1 || byAgency != 1 {
+		t.Fatalf("expected one query each, got %d and %d", byID, byAgency)
+	}
+
+	svc.Invalidate(time.Date(2026, 3, 2, 4, 0, 0, 0, time.UTC))
+	if _, err := svc.GetByID(ctx, "r1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.ListByAgency(ctx, "metro_bilbao"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if byID != 2 || byAgency != 2 {
+		t.Errorf("expected a reload after Invalidate, got %d and %d queries", byID, byAgency)
+	}
+}
+
+func TestRouteService_UncachedWithoutVersion(t *testing.T) {
+	byID, byAgency := 0, 0
+	repo := &mockRouteRepo{
+		getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
+			byID++
+			return &domain.Route{ID: id, ShortName: "L1"}, nil
+		},
+		listByAgencyFn: func(ctx context.Context, agencyID string) ([]domain.Route, error) {
+			byAgency++
+			return []domain.Route{{ShortName: "L1"}}, nil
+		},
+	}
+	cache := &mockCache{}
+	svc := usecases.NewRouteService(repo, &mockVehicleRepo{}, cache)
+	ctx := context.Background()
+
+	// Before the static data version is read, and while none exists,
+	// nothing is read from or written to the shared cache.
+	lookup := func() {
+		if _, err := svc.GetByID(ctx, "r1"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := svc.ListByAgency(ctx, "metro_bilbao"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	lookup()
+	svc.Invalidate(time.Time{})
+	lookup()
+	if byID != 2 || byAgency != 2 {
+		t.Errorf("expected every lookup queried, got %d and %d queries", byID, byAgency)
+	}
+	if len(cache.data) != 0 {
+		t.Errorf("expected nothing cached, got %v", cache.data)
+	}
+}
+
+func TestRouteService_List(t *testing.T) {
+	repo := &mockRouteRepo{routes: []domain.Route{{ShortName: "L1"}, {ShortName: "L2"}, {ShortName: "L3"}}}
+	svc := usecases.NewRouteService(repo, &mockVehicleRepo{}, nil)
+
+	filter := domain.RouteFilter{RouteTypes: domain.RouteTypeFilter{Include: []int{1}}, Query: " etxebarri "}
+	routes, total, err := svc.List(context.Background(), filter, 2, 1000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if total != 3 || len(routes) != 1 || routes[0].ShortName != "L3" {
+		t.Errorf("expected L3 of 3, got %v of %d", routes, total)
+	}
+	if repo.lastFilter.Query != "etxebarri" {
+		t.Errorf("expected the query trimmed, got %q", repo.lastFilter.Query)
+	}
+	if repo.lastLimit != 100 {
+		t.Errorf("expected an out-of-range limit to default to 100, got %d", repo.lastLimit)
+	}
+
+	routes, total, err = svc.List(context.Background(), filter, 10, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if routes == nil || len(routes) != 0 || total != 3 {
+		t.Errorf("expected an empty, non-nil page of 3, got %#v of %d", routes, total)
+	}
+}
+
 func TestRouteService_GetLiveVehicles(t *testing.T) {
 	vRepo := &mockVehicleRepo{
 		latestByRouteFn: func(ctx context.Context, routeID string) ([]domain.VehiclePosition, error) {
@@ -100,7 +307,7 @@ func TestRouteService_GetLiveVehicles(t *testing.T) {
 		},
 	}
 
-	svc := usecases.NewRouteService(&mockRouteRepo{}, vRepo)
+	svc := usecases.NewRouteService(&mockRouteRepo{}, vRepo, nil)
 	vehicles, err := svc.GetLiveVehicles(context.Background(), "route-1")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -109,3 +316,99 @@ func TestRouteService_GetLiveVehicles(t *testing.T) {
 		t.Fatalf("expected 1 vehicle, got %d", len(vehicles))
 	}
 }
+
+func TestRouteService_NearbyVehicles(t *testing.T) {
+	var gotSince time.Time
+	vRepo := &mockVehicleRepo{
+		latestNearbyFn: func(ctx context.Context, lat, lon, radiusMeters float64, since time.Time, limit int) ([]domain.VehiclePosition, error) {
+			gotSince = since
+			return []domain.VehiclePosition{
+				{VehicleID: "v1", RouteName: "L1", Headsign: "Plentzia", Location: domain.GeoPoint{Lat: 43.26, Lon: -2.93}},
+			}, nil
+		},
+	}
+
+	svc := usecases.NewRouteService(&mockRouteRepo{}, vRepo, nil)
+	vehicles, err := svc.NearbyVehicles(context.Background(), 43.26, -2.93, 500, 20, domain.RouteTypeFilter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(vehicles) != 1 || vehicles[0].Headsign != "Plentzia" {
+		t.Fatalf("expected the enriched vehicle, got %+v", vehicles)
+	}
+	if age := time.Since(gotSince); age < 2*time.Minute || age > 5*time.Minute {
+		t.Errorf("expected only recently reported vehicles, got since %s ago", age)
+	}
+}
+
+func TestRouteService_VehicleHistory_Downsamples(t *testing.T) {
+	var gotBucket time.Duration
+	vRepo := &mockVehicleRepo{
+		historyFn: func(ctx context.Context, agency, vehicleID string, from, to time.Time, bucket time.Duration) ([]domain.VehiclePosition, error) {
+			gotBucket = bucket
+			return nil, nil
+		},
+	}
+	svc := usecases.NewRouteService(&mockRouteRepo{}, vRepo, nil)
+	to := time.Date(2026, 3, 14, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		span time.Duration
+		want time.Duration
+	}{
+		{time.Hour, 2 * time.Second},
+		{time.Minute, time.Second},
+		{24 * time.Hour, 44 * time.Second},
+		{7 * 24 * time.Hour, 303 * time.Second},
+	}
+	for _, tt := range tests {
+		_, bucket, err := svc.VehicleHistory(context.Background(), "", "v1", to.Add(-tt.span), to)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if bucket != tt.want || gotBucket != tt.want {
+			t.Errorf("span %s: expected %s buckets, got %s", tt.span, tt.want, bucket)
+		}
+	}
+}
+
+func TestRouteService_Badge(t *testing.T) {
+	repo := &mockRouteRepo{
+		getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
+			switch id {
+			case "r1":
+				return &domain.Route{ID: id, ShortName: "L1", Color: "e30613", TextColor: "FFFFFF"}, nil
+			case "r2":
+				return &domain.Route{ID: id, ShortName: "A&B", Color: "red\"/>"}, nil
+			}
+			return nil, nil
+		},
+	}
+	svc := usecases.NewRouteService(repo, &mockVehicleRepo{}, nil)
+	ctx := context.Background()
+
+	svg, err := svc.Badge(ctx, "r1", usecases.BadgeSize{Height: 32, MinWidth: 80})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{`width="80" height="32"`, `fill="#E30613"`, `fill="#FFFFFF"`, `>L1</text>`} {
+		if !strings.Contains(string(svg), want) {
+			t.Errorf("expected %s in %s", want, svg)
+		}
+	}
+
+	svg, err = svc.Badge(ctx, "r2", usecases.BadgeSize{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(svg), `height="24"`) || !strings.Contains(string(svg), `>A&amp;B</text>`) || strings.Contains(string(svg), "red") {
+		t.Errorf("expected a default-sized, escaped badge on white, got %s", svg)
+	}
+
+	if _, err := svc.Badge(ctx, "r1", usecases.BadgeSize{Height: 4}); !errors.Is(err, usecases.ErrInvalidBadgeSize) {
+		t.Errorf("expected ErrInvalidBadgeSize, got %v", err)
+	}
+	if _, err := svc.Badge(ctx, "nope", usecases.BadgeSize{}); !errors.Is(err, usecases.ErrRouteNotFound) {
+		t.Errorf("expected ErrRouteNotFound, got %v", err)
+	}
+}
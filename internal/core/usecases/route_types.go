@@ -0,0 +1,120 @@
+package usecases
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// ErrInvalidRouteType is returned for a route type filter naming neither a
+// mode nor a GTFS route_type.
+var ErrInvalidRouteType = errors.New("invalid route type")
+
+// routeModes maps the basic GTFS route_types and the extended ones (Google's
+// Hierarchical Vehicle Types) to modes riders know.
+var routeModes = []domain.RouteMode{
+	{Mode: "tram", Label: "Tram", RouteTypes: append([]int{0}, typeRange(900, 906)...)},
+	{Mode: "metro", Label: "Metro", RouteTypes: append([]int{1}, typeRange(400, 404)...)},
+	{Mode: "rail", Label: "Train", RouteTypes: append([]int{2}, typeRange(100, 117)...)},
+	{Mode: "bus", Label: "Bus", RouteTypes: append(append([]int{3}, typeRange(200, 209)...), typeRange(700, 716)...)},
+	{Mode: "ferry", Label: "Ferry", RouteTypes: []int{4, 1000, 1200}},
+	{Mode: "cable_tram", Label: "Cable tram", RouteTypes: []int{5}},
+	{Mode: "aerial_lift", Label: "Aerial lift", RouteTypes: append([]int{6}, typeRange(1300, 1307)...)},
+	{Mode: "funicular", Label: "Funicular", RouteTypes: []int{7, 1400}},
+	{Mode: "trolleybus", Label: "Trolleybus", RouteTypes: []int{11, 800}},
+	{Mode: "monorail", Label: "Monorail", RouteTypes: []int{12, 405}},
+}
+
+func typeRange(from, to int) []int {
+	out := make([]int, 0, to-from+1)
+	for t := from; t <= to; t++ {
+		out = append(out, t)
+	}
+	return out
+}
+
+// RouteModes returns the route type taxonomy, in GTFS basic route type order.
+func RouteModes() []domain.RouteMode {
+	return routeModes
+}
+
+// routeMode returns the mode of a basic or extended route type, or nil for
+// route types outside the taxonomy.
+func routeMode(routeType int) *domain.RouteMode {
+	for i := range routeModes {
+		if slices.Contains(routeModes[i].RouteTypes, routeType) {
+			return &routeModes[i]
+		}
+	}
+	return nil
+}
+
+// basicRouteType returns the basic route type an extended one maps to;
+// route types outside the taxonomy are returned as they are.
+func basicRouteType(routeType int) int {
+	if m := routeMode(routeType); m != nil {
+		return m.RouteTypes[0]
+	}
+	return routeType
+}
+
+// ParseRouteTypes builds a filter from comma-separated lists of modes
+// ("metro,tram") or GTFS route_types ("1,400") to keep and to leave out. A
+// mode stands for all of its route types.
+func ParseRouteTypes(include, exclude string) (domain.RouteTypeFilter, error) {
+	var f domain.RouteTypeFilter
+	var err error
+	if f.Include, err = parseRouteTypeList(include); err != nil {
+		return f, err
+	}
+	if f.Exclude, err = parseRouteTypeList(exclude); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+func parseRouteTypeList(raw string) ([]int, error) {
+	var out []int
+	for _, v := range strings.Split(raw, ",") {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v == "" {
+			continue
+		}
+		if t, err := strconv.Atoi(v); err == nil && t >= 0 {
+			out = append(out, t)
+			continue
+		}
+		i := slices.IndexFunc(routeModes, func(m domain.RouteMode) bool { return m.Mode == v })
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %q is not a mode or a GTFS route_type", ErrInvalidRouteType, v)
+		}
+		out = append(out, routeModes[i].RouteTypes...)
+	}
+	return out, nil
+}
+
+// routeTypeAllowed reports whether the filter keeps a route type.
+func routeTypeAllowed(f domain.RouteTypeFilter, routeType int) bool {
+	if len(f.Include) > 0 && !slices.Contains(f.Include, routeType) {
+		return false
+	}
+	return !slices.Contains(f.Exclude, routeType)
+}
+
+// FilterRoutes returns the routes the filter keeps, in order.
+func FilterRoutes(routes []domain.Route, f domain.RouteTypeFilter) []domain.Route {
+	if len(f.Include) == 0 && len(f.Exclude) == 0 {
+		return routes
+	}
+	out := make([]domain.Route, 0, len(routes))
+	for _, rt := range routes {
+		if routeTypeAllowed(f, rt.RouteType) {
+			out = append(out, rt)
+		}
+	}
+	return out
+}
@@ -0,0 +1,55 @@
+package usecases_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+func TestParseRouteTypes(t *testing.T) {
+	f, err := usecases.ParseRouteTypes("Metro, funicular,715", "bus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rt := range []int{1, 401, 7, 1400, 715} {
+		if !slices.Contains(f.Include, rt) {
+			t.Errorf("expected route type %d included, got %v", rt, f.Include)
+		}
+	}
+	if !slices.Contains(f.Exclude, 3) || !slices.Contains(f.Exclude, 700) || slices.Contains(f.Exclude, 1) {
+		t.Errorf("expected bus route types excluded, got %v", f.Exclude)
+	}
+
+	if f, err := usecases.ParseRouteTypes("", ""); err != nil || f.Include != nil || f.Exclude != nil {
+		t.Errorf("expected an empty filter, got %+v, %v", f, err)
+	}
+	if _, err := usecases.ParseRouteTypes("hovercraft", ""); !errors.Is(err, usecases.ErrInvalidRouteType) {
+		t.Errorf("expected ErrInvalidRouteType, got %v", err)
+	}
+}
+
+func TestFilterRoutes(t *testing.T) {
+	routes := []domain.Route{
+		{ID: "metro", RouteType: 1},
+		{ID: "bus", RouteType: 3},
+		{ID: "coach", RouteType: 202},
+		{ID: "funicular", RouteType: 7},
+	}
+	noBus, _ := usecases.ParseRouteTypes("", "bus")
+	got := usecases.FilterRoutes(routes, noBus)
+	if len(got) != 2 || got[0].ID != "metro" || got[1].ID != "funicular" {
+		t.Errorf("expected metro and funicular, got %+v", got)
+	}
+	if got := usecases.FilterRoutes(routes, domain.RouteTypeFilter{}); len(got) != 4 {
+		t.Errorf("expected every route without a filter, got %d", len(got))
+	}
+
+	modes := usecases.RouteModes()
+	i := slices.IndexFunc(modes, func(m domain.RouteMode) bool { return m.Mode == "funicular" })
+	if i < 0 || modes[i].Label != "Funicular" || modes[i].RouteTypes[0] != 7 {
+		t.Errorf("expected the funicular mode, got %+v", modes)
+	}
+}
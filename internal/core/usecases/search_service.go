@@ -0,0 +1,120 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+const (
+	// defaultSearchStatsDays is how far back search stats look by default.
+	defaultSearchStatsDays = 30
+	// searchTopMisses is how many missed queries search stats list.
+	searchTopMisses = 20
+	// maxSynonymBoost bounds how much a synonym's matches are boosted.
+	maxSynonymBoost = 2.0
+)
+
+var (
+	ErrSynonymExists   = errors.New("synonym already exists")
+	ErrSynonymNotFound = errors.New("synonym not found")
+	// ErrInvalidSearchRange is returned for stats ranges that end before
+	// they start.
+	ErrInvalidSearchRange = errors.New("from must be before to")
+)
+
+// SearchService manages the synonyms stop search expands queries with and
+// measures how often searches find something.
+type SearchService struct {
+	synonyms ports.SearchSynonymRepository
+	log      ports.SearchLogRepository
+}
+
+// NewSearchService creates a new SearchService.
+func NewSearchService(synonyms ports.SearchSynonymRepository, log ports.SearchLogRepository) *SearchService {
+	return &SearchService{synonyms: synonyms, log: log}
+}
+
+// Synonyms returns every search synonym, by term.
+func (s *SearchService) Synonyms(ctx context.Context) ([]domain.SearchSynonym, error) {
+	synonyms, err := s.synonyms.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if synonyms == nil {
+		synonyms = []domain.SearchSynonym{}
+	}
+	return synonyms, nil
+}
+
+// AddSynonym adds a synonym, with a boost of 1 when none is given. Searches
+// cached before it was added do not use it until they expire.
+func (s *SearchService) AddSynonym(ctx context.Context, syn *domain.SearchSynonym) error {
+	syn.Term = strings.TrimSpace(syn.Term)
+	syn.Synonym = strings.TrimSpace(syn.Synonym)
+	if syn.Term == "" || syn.Synonym == "" {
+		return fmt.Errorf("term and synonym are required")
+	}
+	if strings.EqualFold(syn.Term, syn.Synonym) {
+		return fmt.Errorf("term and synonym must differ")
+	}
+	if syn.Boost == 0 {
+		syn.Boost = 1
+	}
+	if syn.Boost < 0 || syn.Boost > maxSynonymBoost {
+		return fmt.Errorf("boost must be between 0 and %g", maxSynonymBoost)
+	}
+
+	created, err := s.synonyms.Create(ctx, syn)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return ErrSynonymExists
+	}
+	return nil
+}
+
+// RemoveSynonym deletes a synonym.
+func (s *SearchService) RemoveSynonym(ctx context.Context, id string) error {
+	deleted, err := s.synonyms.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrSynonymNotFound
+	}
+	return nil
+}
+
+// Record logs a stop search and how many stops it found.
+func (s *SearchService) Record(ctx context.Context, query string, results int, at time.Time) error {
+	return s.log.Record(ctx, query, results, at)
+}
+
+// Stats returns the stop search hit rate from from to to, by default over
+// the last 30 days, with the queries that most often found nothing.
+func (s *SearchService) Stats(ctx context.Context, from, to, now time.Time) (*domain.SearchStats, error) {
+	if to.IsZero() {
+		to = now
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -defaultSearchStatsDays)
+	}
+	if !from.Before(to) {
+		return nil, ErrInvalidSearchRange
+	}
+	stats, err := s.log.Stats(ctx, from, to, searchTopMisses)
+	if err != nil {
+		return nil, err
+	}
+	if stats.Searches > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(stats.Searches)
+	}
+	return stats, nil
+}
@@ -0,0 +1,123 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock SearchSynonymRepository ---
+
+type mockSynonymRepo struct {
+	synonyms []domain.SearchSynonym
+}
+
+func (m *mockSynonymRepo) List(ctx context.Context) ([]domain.SearchSynonym, error) {
+	return m.synonyms, nil
+}
+
+func (m *mockSynonymRepo) Create(ctx context.Context, s *domain.SearchSynonym) (bool, error) {
+	s.Term, s.Synonym = strings.ToLower(s.Term), strings.ToLower(s.Synonym)
+	for _, existing := range m.synonyms {
+		if existing.Term == s.Term && existing.Synonym == s.Synonym {
+			return false, nil
+		}
+	}
+	s.ID = "syn" + string(rune('1'+len(m.synonyms)))
+	m.synonyms = append(m.synonyms, *s)
+	return true, nil
+}
+
+func (m *mockSynonymRepo) Delete(ctx context.Context, id string) (bool, error) {
+	for i, s := range m.synonyms {
+		if s.ID == id {
+			m.synonyms = append(m.synonyms[:i], m.synonyms[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// --- Mock SearchLogRepository ---
+
+type mockSearchLogRepo struct {
+	searches, hits int
+	from, to       time.Time
+}
+
+func (m *mockSearchLogRepo) Record(ctx context.Context, query string, results int, at time.Time) error {
+	m.searches++
+	if results > 0 {
+		m.hits++
+	}
+	return nil
+}
+
+func (m *mockSearchLogRepo) Stats(ctx context.Context, from, to time.Time, limit int) (*domain.SearchStats, error) {
+	m.from, m.to = from, to
+	return &domain.SearchStats{From: from, To: to, Searches: m.searches, Hits: m.hits, TopMisses: []domain.SearchMiss{}}, nil
+}
+
+func TestSearchService_AddSynonym(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockSynonymRepo{}
+	svc := usecases.NewSearchService(repo, &mockSearchLogRepo{})
+
+	syn := &domain.SearchSynonym{Term: " Casco Viejo ", Synonym: "Zazpikaleak"}
+	if err := svc.AddSynonym(ctx, syn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if syn.Term != "casco viejo" || syn.Boost != 1 || syn.OneWay {
+		t.Errorf("expected a trimmed two-way synonym with boost 1, got %+v", syn)
+	}
+	if err := svc.AddSynonym(ctx, &domain.SearchSynonym{Term: "casco viejo", Synonym: "zazpikaleak"}); !errors.Is(err, usecases.ErrSynonymExists) {
+		t.Errorf("expected ErrSynonymExists, got %v", err)
+	}
+
+	for _, bad := range []domain.SearchSynonym{
+		{Term: "Abando"},
+		{Term: "Abando", Synonym: "abando"},
+		{Term: "Abandoo", Synonym: "Abando", Boost: 3},
+	} {
+		if err := svc.AddSynonym(ctx, &bad); err == nil || errors.Is(err, usecases.ErrSynonymExists) {
+			t.Errorf("expected %+v to be rejected, got %v", bad, err)
+		}
+	}
+
+	if err := svc.RemoveSynonym(ctx, syn.ID); err != nil {
+		t.Errorf("unexpected error removing: %v", err)
+	}
+	if err := svc.RemoveSynonym(ctx, syn.ID); !errors.Is(err, usecases.ErrSynonymNotFound) {
+		t.Errorf("expected ErrSynonymNotFound, got %v", err)
+	}
+}
+
+func TestSearchService_Stats(t *testing.T) {
+	ctx := context.Background()
+	log := &mockSearchLogRepo{}
+	svc := usecases.NewSearchService(&mockSynonymRepo{}, log)
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	for _, results := range []int{3, 0, 1, 5} {
+		_ = svc.Record(ctx, "abando", results, now)
+	}
+	stats, err := svc.Stats(ctx, time.Time{}, time.Time{}, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Searches != 4 || stats.HitRate != 0.75 {
+		t.Errorf("expected 4 searches with a 75%% hit rate, got %d and %v", stats.Searches, stats.HitRate)
+	}
+	if !log.to.Equal(now) || !log.from.Equal(now.AddDate(0, 0, -30)) {
+		t.Errorf("expected the last 30 days, got %v to %v", log.from, log.to)
+	}
+
+	if _, err := svc.Stats(ctx, now, now.Add(-time.Hour), now); !errors.Is(err, usecases.ErrInvalidSearchRange) {
+		t.Errorf("expected ErrInvalidSearchRange, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+const (
+	// SegmentRollupInterval is how often the segments job rolls up segment
+	// times.
+	SegmentRollupInterval = 15 * time.Minute
+	// segmentRollupLag is how long after an hour ends it is rolled up, so
+	// vehicles that left a stop late in the hour have reached the next one.
+	segmentRollupLag = 45 * time.Minute
+	// segmentStopRadius is how close a vehicle must be to a stop to be at it.
+	segmentStopRadius = 40.0
+	// defaultSegmentDays and maxSegmentDays bound how far back segment
+	// times are summed.
+	defaultSegmentDays = 7
+	maxSegmentDays     = 90
+	// segmentMinSamples is the fewest trips a segment needs to be ranked.
+	segmentMinSamples = 5
+	// segmentSlowest is how many of a route's slowest segments are flagged.
+	segmentSlowest = 3
+)
+
+// ErrInvalidSegmentDays is returned for a segment history outside the
+// allowed range.
+var ErrInvalidSegmentDays = errors.New("days must be between 1 and 90")
+
+// SegmentService derives how fast vehicles travel between stops and how
+// long they dwell at them, to show where buses lose time.
+type SegmentService struct {
+	repo   ports.SegmentStatsRepository
+	routes ports.RouteRepository
+}
+
+// NewSegmentService creates a new SegmentService.
+func NewSegmentService(repo ports.SegmentStatsRepository, routes ports.RouteRepository) *SegmentService {
+	return &SegmentService{repo: repo, routes: routes}
+}
+
+// Rollup recomputes the last hour that ended at least segmentRollupLag
+// before now. Rolling up an hour again replaces it, so Rollup can run more
+// than once an hour.
+func (s *SegmentService) Rollup(ctx context.Context, now time.Time) error {
+	hour := now.Add(-time.Hour - segmentRollupLag).Truncate(time.Hour)
+	return s.repo.RollupHour(ctx, hour, segmentStopRadius)
+}
+
+// Route returns the route's segments over the last days days
+// (defaultSegmentDays when 0) with their average speed, flagging the
+// slowest ones among those seen often enough.
+func (s *SegmentService) Route(ctx context.Context, routeID string, days int, now time.Time) (*domain.RouteSegments, error) {
+	if days == 0 {
+		days = defaultSegmentDays
+	}
+	if days < 1 || days > maxSegmentDays {
+		return nil, ErrInvalidSegmentDays
+	}
+	route, err := s.routes.GetByID(ctx, routeID)
+	if err != nil || route == nil {
+		return nil, ErrRouteNotFound
+	}
+	since := now.Add(-time.Duration(days) * 24 * time.Hour)
+	segments, err := s.repo.RouteSegments(ctx, route.ID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	var ranked []int
+	for i := range segments {
+		seg := &segments[i]
+		if seg.TravelSecs > 0 {
+			seg.SpeedKmh = seg.Distance * 3.6 / seg.TravelSecs
+		}
+		if seg.Samples >= segmentMinSamples && seg.TravelSecs > 0 {
+			ranked = append(ranked, i)
+		}
+	}
+	sort.SliceStable(ranked, func(a, b int) bool { return segments[ranked[a]].SpeedKmh < segments[ranked[b]].SpeedKmh })
+	for _, i := range ranked[:min(len(ranked), segmentSlowest)] {
+		segments[i].Slowest = true
+	}
+
+	if segments == nil {
+		segments = []domain.RouteSegment{}
+	}
+	return &domain.RouteSegments{RouteID: route.ID, Since: since, Segments: segments}, nil
+}
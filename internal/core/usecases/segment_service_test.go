@@ -0,0 +1,104 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock SegmentStatsRepository ---
+
+type mockSegmentRepo struct {
+	segments []domain.RouteSegment
+	since    time.Time
+	hour     time.Time
+	radius   float64
+}
+
+func (m *mockSegmentRepo) RollupHour(ctx context.Context, hour time.Time, radiusMeters float64) error {
+	m.hour, m.radius = hour, radiusMeters
+	return nil
+}
+
+func (m *mockSegmentRepo) RouteSegments(ctx context.Context, routeID string, since time.Time) ([]domain.RouteSegment, error) {
+	m.since = since
+	return m.segments, nil
+}
+
+func TestSegmentService_Rollup(t *testing.T) {
+	repo := &mockSegmentRepo{}
+	svc := usecases.NewSegmentService(repo, &mockRouteRepo{})
+
+	// At 10:40 the 09:00 hour's last segments may still be under way.
+	now := time.Date(2026, 3, 2, 10, 40, 0, 0, time.UTC)
+	if err := svc.Rollup(context.Background(), now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC); !repo.hour.Equal(want) {
+		t.Errorf("expected the 08:00 hour rolled up, got %v", repo.hour)
+	}
+	_ = svc.Rollup(context.Background(), now.Add(5*time.Minute))
+	if want := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC); !repo.hour.Equal(want) {
+		t.Errorf("expected the 09:00 hour rolled up at 10:45, got %v", repo.hour)
+	}
+	if repo.radius <= 0 {
+		t.Error("expected a stop radius")
+	}
+}
+
+func TestSegmentService_Route(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 2, 8, 30, 0, 0, time.UTC)
+	dwell := 20.0
+	repo := &mockSegmentRepo{segments: []domain.RouteSegment{
+		{FromStopID: "a", ToStopID: "b", Distance: 500, Samples: 10, TravelSecs: 60, DwellSecs: &dwell}, // 30 km/h
+		{FromStopID: "b", ToStopID: "c", Distance: 500, Samples: 10, TravelSecs: 180},                   // 10 km/h
+		{FromStopID: "c", ToStopID: "d", Distance: 500, Samples: 2, TravelSecs: 600},                    // too few trips
+		{FromStopID: "d", ToStopID: "e", Distance: 500, Samples: 10, TravelSecs: 90},                    // 20 km/h
+		{FromStopID: "e", ToStopID: "f", Distance: 500, Samples: 10, TravelSecs: 120},                   // 15 km/h
+	}}
+	routes := &mockRouteRepo{getByIDFn: func(ctx context.Context, id string) (*domain.Route, error) {
+		if id != "r1" {
+			return nil, errors.New("no rows in result set")
+		}
+		return &domain.Route{ID: "r1"}, nil
+	}}
+	svc := usecases.NewSegmentService(repo, routes)
+
+	out, err := svc.Route(ctx, "r1", 0, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !repo.since.Equal(now.Add(-7 * 24 * time.Hour)) {
+		t.Errorf("expected a week by default, got since %v", repo.since)
+	}
+	if got := out.Segments[0].SpeedKmh; got != 30 {
+		t.Errorf("expected 30 km/h, got %v", got)
+	}
+	var slowest []string
+	for _, s := range out.Segments {
+		if s.Slowest {
+			slowest = append(slowest, s.FromStopID)
+		}
+	}
+	if len(slowest) != 3 || slowest[0] != "b" || slowest[1] != "d" || slowest[2] != "e" {
+		t.Errorf("expected b, d and e flagged slowest, got %v", slowest)
+	}
+
+	if _, err := svc.Route(ctx, "r1", 91, now); !errors.Is(err, usecases.ErrInvalidSegmentDays) {
+		t.Errorf("expected ErrInvalidSegmentDays, got %v", err)
+	}
+	if _, err := svc.Route(ctx, "nope", 7, now); !errors.Is(err, usecases.ErrRouteNotFound) {
+		t.Errorf("expected ErrRouteNotFound, got %v", err)
+	}
+
+	repo.segments = nil
+	out, _ = svc.Route(ctx, "r1", 1, now)
+	if out.Segments == nil || len(out.Segments) != 0 {
+		t.Errorf("expected an empty list, got %v", out.Segments)
+	}
+}
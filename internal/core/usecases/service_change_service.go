@@ -0,0 +1,172 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+const (
+	// scheduleChangeRatio is the share of a route's scheduled trips that must
+	// appear or disappear between feeds for riders to hear about it.
+	scheduleChangeRatio = 0.25
+	// minScheduleChange keeps small routes from flagging one or two trips.
+	minScheduleChange = 5
+)
+
+// ServiceChangeService compares each GTFS feed with the previous one for the
+// stops and routes riders favorited, and tells them when one was removed,
+// renamed or had its timetable overhauled.
+type ServiceChangeService struct {
+	states   ports.FeedStateRepository
+	notifier ports.NotificationService
+}
+
+// NewServiceChangeService creates a new ServiceChangeService.
+func NewServiceChangeService(states ports.FeedStateRepository, notifier ports.NotificationService) *ServiceChangeService {
+	return &ServiceChangeService{states: states, notifier: notifier}
+}
+
+// Detect returns how feed changes the agency's favorited stops and routes and
+// records it as the new state, so each change is found once. A feed without
+// stops or routes leaves them alone rather than reporting everything removed.
+// Removals and timetable changes need a state recorded by an earlier ingest.
+func (s *ServiceChangeService) Detect(ctx context.Context, agencyID string, feed *domain.FeedSnapshot) ([]domain.ServiceChange, error) {
+	subjects, err := s.states.FavoritedSubjects(ctx, agencyID)
+	if err != nil {
+		return nil, fmt.Errorf("favorited subjects: %w", err)
+	}
+
+	var changes []domain.ServiceChange
+	var seen []domain.FeedSubject
+	for _, sub := range subjects {
+		var name string
+		var trips int
+		var found bool
+		switch sub.Kind {
+		case domain.FavoriteStop:
+			if len(feed.Stops) == 0 {
+				continue
+			}
+			name, found = feed.Stops[sub.GTFSID]
+		case domain.FavoriteRoute:
+			if len(feed.Routes) == 0 {
+				continue
+			}
+			var r domain.FeedRoute
+			r, found = feed.Routes[sub.GTFSID]
+			name, trips = r.Name, r.Trips
+		default:
+			continue
+		}
+
+		change := domain.ServiceChange{SubjectID: sub.ID, OldName: sub.Name, UserIDs: sub.UserIDs}
+		switch {
+		case !found:
+			if sub.Known && sub.Present {
+				change.Kind = removedKind(sub.Kind)
+				changes = append(changes, change)
+			}
+			sub.Present = false
+		default:
+			if sub.Present && name != sub.Name {
+				change.Kind = renamedKind(sub.Kind)
+				change.NewName = name
+				changes = append(changes, change)
+			}
+			if sub.Kind == domain.FavoriteRoute && sub.Known && sub.Present && scheduleChanged(sub.Trips, trips) {
+				change.Kind = domain.ServiceChangeScheduleChanged
+				change.NewName = name
+				change.OldTrips, change.NewTrips = sub.Trips, trips
+				changes = append(changes, change)
+			}
+			sub.Name, sub.Trips, sub.Present = name, trips, true
+		}
+		sub.Known = true
+		seen = append(seen, sub)
+	}
+
+	if len(seen) > 0 {
+		if err := s.states.Save(ctx, seen); err != nil {
+			return nil, fmt.Errorf("save state: %w", err)
+		}
+	}
+	return changes, nil
+}
+
+// Notify pushes a summary of each change to the riders who favorited its stop
+// or route. It returns how many pushes were delivered; riders without devices
+// or who muted alerts are skipped silently.
+func (s *ServiceChangeService) Notify(ctx context.Context, changes []domain.ServiceChange) (int, error) {
+	sent := 0
+	var errs []error
+	for _, c := range changes {
+		title, body := serviceChangeText(&c)
+		for _, userID := range c.UserIDs {
+			err := s.notifier.SendPush(ctx, userID, domain.NotificationAlerts, title, body)
+			switch {
+			case err == nil:
+				sent++
+			case errors.Is(err, ports.ErrNoDevices), errors.Is(err, ports.ErrPushMuted):
+			default:
+				errs = append(errs, fmt.Errorf("%s %s: %w", c.Kind, c.SubjectID, err))
+			}
+		}
+	}
+	return sent, errors.Join(errs...)
+}
+
+func removedKind(kind string) string {
+	if kind == domain.FavoriteStop {
+		return domain.ServiceChangeStopRemoved
+	}
+	return domain.ServiceChangeRouteRemoved
+}
+
+func renamedKind(kind string) string {
+	if kind == domain.FavoriteStop {
+		return domain.ServiceChangeStopRenamed
+	}
+	return domain.ServiceChangeRouteRenamed
+}
+
+// scheduleChanged reports whether a route's trip count moved enough between
+// feeds to count as a new timetable.
+func scheduleChanged(before, after int) bool {
+	if before == 0 {
+		return false
+	}
+	diff := after - before
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff >= minScheduleChange && float64(diff) >= scheduleChangeRatio*float64(before)
+}
+
+func serviceChangeText(c *domain.ServiceChange) (title, body string) {
+	switch c.Kind {
+	case domain.ServiceChangeStopRemoved:
+		return fmt.Sprintf("Stop %s no longer served", c.OldName),
+			fmt.Sprintf("Your favorite stop %s is not in the new timetable.", c.OldName)
+	case domain.ServiceChangeStopRenamed:
+		return fmt.Sprintf("Stop %s renamed", c.OldName),
+			fmt.Sprintf("Your favorite stop %s is now called %s.", c.OldName, c.NewName)
+	case domain.ServiceChangeRouteRemoved:
+		return fmt.Sprintf("Line %s discontinued", c.OldName),
+			fmt.Sprintf("Your favorite line %s is not in the new timetable.", c.OldName)
+	case domain.ServiceChangeRouteRenamed:
+		return fmt.Sprintf("Line %s renamed", c.OldName),
+			fmt.Sprintf("Your favorite line %s is now called %s.", c.OldName, c.NewName)
+	default:
+		direction := "up"
+		if c.NewTrips < c.OldTrips {
+			direction = "down"
+		}
+		return fmt.Sprintf("New timetable for %s", c.NewName),
+			fmt.Sprintf("%s now has %d scheduled trips, %s from %d. Check your usual departures.",
+				c.NewName, c.NewTrips, direction, c.OldTrips)
+	}
+}
@@ -0,0 +1,149 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock FeedStateRepository ---
+
+type mockFeedStateRepo struct {
+	subjects []domain.FeedSubject
+	saved    []domain.FeedSubject
+}
+
+func (m *mockFeedStateRepo) FavoritedSubjects(ctx context.Context, agencyID string) ([]domain.FeedSubject, error) {
+	return m.subjects, nil
+}
+
+func (m *mockFeedStateRepo) Save(ctx context.Context, subjects []domain.FeedSubject) error {
+	m.saved = subjects
+	return nil
+}
+
+func TestServiceChangeService_Detect(t *testing.T) {
+	repo := &mockFeedStateRepo{subjects: []domain.FeedSubject{
+		{Kind: "stop", ID: "s1", GTFSID: "100", Name: "Deusto", Present: true, Known: true, UserIDs: []string{"u1"}},
+		{Kind: "stop", ID: "s2", GTFSID: "200", Name: "Abando", Present: true, Known: true, UserIDs: []string{"u2"}},
+		{Kind: "stop", ID: "s3", GTFSID: "300", Name: "Moyua", Present: true, Known: true, UserIDs: []string{"u1"}},
+		{Kind: "route", ID: "r1", GTFSID: "L1", Name: "L1", Trips: 200, Present: true, Known: true, UserIDs: []string{"u1", "u2"}},
+		{Kind: "route", ID: "r2", GTFSID: "L2", Name: "L2", Trips: 100, Present: true, Known: true, UserIDs: []string{"u2"}},
+		// First seen: no removal or timetable to compare with yet.
+		{Kind: "route", ID: "r3", GTFSID: "L3", Name: "L3", Present: true, UserIDs: []string{"u3"}},
+	}}
+	svc := usecases.NewServiceChangeService(repo, &mockNotifier{})
+
+	feed := &domain.FeedSnapshot{
+		Stops: map[string]string{"100": "Deusto", "200": "Abando Indalecio Prieto"},
+		Routes: map[string]domain.FeedRoute{
+			"L1": {Name: "L1", Trips: 140},
+			"L2": {Name: "L2", Trips: 98},
+			"L3": {Name: "L3", Trips: 30},
+		},
+	}
+	changes, err := svc.Detect(context.Background(), "a1", feed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	kinds := map[string]string{}
+	for _, c := range changes {
+		kinds[c.SubjectID] = c.Kind
+	}
+	want := map[string]string{
+		"s2": domain.ServiceChangeStopRenamed,
+		"s3": domain.ServiceChangeStopRemoved,
+		"r1": domain.ServiceChangeScheduleChanged,
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("expected %d changes, got %+v", len(want), changes)
+	}
+	for id, kind := range want {
+		if kinds[id] != kind {
+			t.Errorf("%s: expected %s, got %q", id, kind, kinds[id])
+		}
+	}
+
+	if len(repo.saved) != 6 {
+		t.Fatalf("expected 6 saved states, got %d", len(repo.saved))
+	}
+	for _, s := range repo.saved {
+		if !s.Known {
+			t.Errorf("%s should be recorded as known", s.ID)
+		}
+		switch s.ID {
+		case "s2":
+			if s.Name != "Abando Indalecio Prieto" {
+				t.Errorf("expected new name saved, got %q", s.Name)
+			}
+		case "s3", "r3":
+			if s.Present != (s.ID == "r3") {
+				t.Errorf("%s: unexpected present=%v", s.ID, s.Present)
+			}
+		case "r1":
+			if s.Trips != 140 {
+				t.Errorf("expected 140 trips saved, got %d", s.Trips)
+			}
+		}
+	}
+}
+
+func TestServiceChangeService_Detect_RemovedOnce(t *testing.T) {
+	repo := &mockFeedStateRepo{subjects: []domain.FeedSubject{
+		{Kind: "route", ID: "r1", GTFSID: "L1", Name: "L1", Trips: 50, Present: false, Known: true, UserIDs: []string{"u1"}},
+	}}
+	svc := usecases.NewServiceChangeService(repo, &mockNotifier{})
+
+	feed := &domain.FeedSnapshot{Routes: map[string]domain.FeedRoute{"L2": {Name: "L2", Trips: 10}}}
+	changes, err := svc.Detect(context.Background(), "a1", feed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("an already removed route should not be reported again, got %+v", changes)
+	}
+}
+
+func TestServiceChangeService_Detect_EmptyFeed(t *testing.T) {
+	repo := &mockFeedStateRepo{subjects: []domain.FeedSubject{
+		{Kind: "stop", ID: "s1", GTFSID: "100", Name: "Deusto", Present: true, Known: true, UserIDs: []string{"u1"}},
+	}}
+	svc := usecases.NewServiceChangeService(repo, &mockNotifier{})
+
+	changes, err := svc.Detect(context.Background(), "a1", &domain.FeedSnapshot{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changes) != 0 || len(repo.saved) != 0 {
+		t.Errorf("a feed without stops should change nothing, got %+v, saved %+v", changes, repo.saved)
+	}
+}
+
+func TestServiceChangeService_Notify(t *testing.T) {
+	notifier := &mockNotifier{errs: map[string]error{
+		"u2": ports.ErrNoDevices,
+		"u3": &ports.PushError{Err: errors.New("boom")},
+	}}
+	svc := usecases.NewServiceChangeService(&mockFeedStateRepo{}, notifier)
+
+	changes := []domain.ServiceChange{
+		{Kind: domain.ServiceChangeRouteRenamed, SubjectID: "r1", OldName: "L1", NewName: "L1 Express", UserIDs: []string{"u1", "u2"}},
+		{Kind: domain.ServiceChangeScheduleChanged, SubjectID: "r2", OldName: "L2", NewName: "L2", OldTrips: 100, NewTrips: 60, UserIDs: []string{"u3"}},
+	}
+	sent, err := svc.Notify(context.Background(), changes)
+	if err == nil {
+		t.Fatal("expected the failed push to be reported")
+	}
+	if sent != 1 || len(notifier.sent) != 1 {
+		t.Fatalf("expected 1 push, got %d", sent)
+	}
+	p := notifier.sent[0]
+	if p.userID != "u1" || p.category != domain.NotificationAlerts || p.title != "Line L1 renamed" || p.body != "Your favorite line L1 is now called L1 Express." {
+		t.Errorf("unexpected push: %+v", p)
+	}
+}
@@ -0,0 +1,119 @@
+package usecases
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"strings"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+// shortCodeAlphabet omits look-alike characters (0/o, 1/l/i) so codes survive printing.
+const shortCodeAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+
+// ShortLinkService issues and resolves short codes for stop signage.
+type ShortLinkService struct {
+	links    ports.ShortLinkRepository
+	baseURL  string
+	boardURL string
+}
+
+// NewShortLinkService creates a new ShortLinkService.
+// baseURL is the public host used in QR payloads (e.g. https://bilbopass.eus) and
+// boardURL is the redirect target, with {stop_id} replaced by the stop UUID.
+func NewShortLinkService(links ports.ShortLinkRepository, baseURL, boardURL string) *ShortLinkService {
+	return &ShortLinkService{
+		links:    links,
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		boardURL: boardURL,
+	}
+}
+
+// LinkForStop returns the short link of a stop, assigning a new code if needed.
+func (s *ShortLinkService) LinkForStop(ctx context.Context, stopUUID string) (*domain.StopShortLink, error) {
+	if stopUUID == "" {
+		return nil, fmt.Errorf("stop id is required")
+	}
+
+	var err error
+	// Retry on the (unlikely) event of a code collision
+	for attempt := 0; attempt < 3; attempt++ {
+		var code string
+		code, err = generateShortCode(6)
+		if err != nil {
+			return nil, fmt.Errorf("generate code: %w", err)
+		}
+		link := &domain.StopShortLink{Code: code, StopID: stopUUID}
+		if err = s.links.Create(ctx, link); err == nil {
+			link.URL = s.ShortURL(link.Code)
+			return link, nil
+		}
+	}
+	return nil, fmt.Errorf("create short link: %w", err)
+}
+
+// Resolve looks up a code and returns the link with its redirect target in URL.
+func (s *ShortLinkService) Resolve(ctx context.Context, code string) (*domain.StopShortLink, error) {
+	link, err := s.links.GetByCode(ctx, strings.ToLower(code))
+	if err != nil {
+		return nil, err
+	}
+	link.URL = s.BoardURL(link.StopID)
+	return link, nil
+}
+
+// ExportAgency assigns codes to every stop of an agency that lacks one and
+// returns the full list, ready for printing.
+func (s *ShortLinkService) ExportAgency(ctx context.Context, agencyID string) ([]domain.StopShortLink, error) {
+	missing, err := s.links.StopsWithoutLink(ctx, agencyID)
+	if err != nil {
+		return nil, fmt.Errorf("find stops without link: %w", err)
+	}
+	for _, stopID := range missing {
+		if _, err := s.LinkForStop(ctx, stopID); err != nil {
+			return nil, err
+		}
+	}
+
+	links, err := s.links.ListByAgency(ctx, agencyID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range links {
+		links[i].URL = s.ShortURL(links[i].Code)
+	}
+	return links, nil
+}
+
+// ShortURL returns the public URL encoded in the QR code.
+func (s *ShortLinkService) ShortURL(code string) string {
+	return s.baseURL + "/s/" + code
+}
+
+// BoardURL returns the live departures board URL for a stop.
+func (s *ShortLinkService) BoardURL(stopUUID string) string {
+	return strings.ReplaceAll(s.boardURL, "{stop_id}", stopUUID)
+}
+
+// generateShortCode returns n characters drawn uniformly from
+// shortCodeAlphabet. Random bytes at or above the largest multiple of the
+// alphabet's length are discarded, or the first characters would be more
+// likely.
+func generateShortCode(n int) (string, error) {
+	const limit = 256 - 256%len(shortCodeAlphabet)
+	code := make([]byte, 0, n)
+	b := make([]byte, n)
+	for len(code) < n {
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		for _, r := range b {
+			if int(r) < limit && len(code) < n {
+				code = append(code, shortCodeAlphabet[int(r)%len(shortCodeAlphabet)])
+			}
+		}
+	}
+	return string(code), nil
+}
@@ -0,0 +1,70 @@
+package usecases_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock ShortLinkRepository ---
+
+type mockShortLinkRepo struct {
+	codes []string
+}
+
+func (m *mockShortLinkRepo) Create(ctx context.Context, l *domain.StopShortLink) error {
+	m.codes = append(m.codes, l.Code)
+	return nil
+}
+
+func (m *mockShortLinkRepo) GetByCode(ctx context.Context, code string) (*domain.StopShortLink, error) {
+	return nil, fmt.Errorf("not found")
+}
+
+func (m *mockShortLinkRepo) ListByAgency(ctx context.Context, agencyID string) ([]domain.StopShortLink, error) {
+	return nil, nil
+}
+
+func (m *mockShortLinkRepo) StopsWithoutLink(ctx context.Context, agencyID string) ([]string, error) {
+	return nil, nil
+}
+
+func TestShortLinkService_CodesAreUniform(t *testing.T) {
+	const alphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+	repo := &mockShortLinkRepo{}
+	svc := usecases.NewShortLinkService(repo, "https://bilbopass.eus/", "https://bilbopass.eus/board/{stop_id}")
+
+	const links = 10000
+	counts := map[rune]int{}
+	for i := 0; i < links; i++ {
+		link, err := svc.LinkForStop(context.Background(), "stop-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(link.Code) != 6 || link.URL != "https://bilbopass.eus/s/"+link.Code {
+			t.Fatalf("unexpected link %+v", link)
+		}
+		for _, r := range link.Code {
+			if !strings.ContainsRune(alphabet, r) {
+				t.Fatalf("code %q has %q, not in the alphabet", link.Code, r)
+			}
+			counts[r]++
+		}
+	}
+
+	// Chi-squared over 30 degrees of freedom: about 30 when uniform, above
+	// 150 with the modulo bias towards the first 8 characters.
+	expected := float64(links*6) / float64(len(alphabet))
+	var chi2 float64
+	for _, r := range alphabet {
+		d := float64(counts[r]) - expected
+		chi2 += d * d / expected
+	}
+	if chi2 > 80 {
+		t.Errorf("characters are not uniform: chi-squared %.1f, counts %v", chi2, counts)
+	}
+}
@@ -0,0 +1,162 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+// SLA errors surfaced to the API.
+var (
+	ErrAgencyNotFound = errors.New("agency not found")
+	ErrInvalidMonth   = errors.New("month must be YYYY-MM")
+)
+
+// SLAService tracks agency punctuality commitments. Trip update predictions
+// are rolled up per day and evaluated once per calendar month; breaches are
+// published as events.
+type SLAService struct {
+	sla       ports.SLARepository
+	agencies  ports.AgencyRepository
+	publisher ports.EventPublisher
+}
+
+// NewSLAService creates a new SLAService. publisher may be nil, in which case
+// breaches are recorded but not published.
+func NewSLAService(sla ports.SLARepository, agencies ports.AgencyRepository, publisher ports.EventPublisher) *SLAService {
+	return &SLAService{sla: sla, agencies: agencies, publisher: publisher}
+}
+
+// CreateContract validates and stores a contract for the agency with the given slug.
+func (s *SLAService) CreateContract(ctx context.Context, agencySlug string, c *domain.SLAContract) error {
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if c.ThresholdSeconds <= 0 {
+		return fmt.Errorf("threshold_seconds must be positive")
+	}
+	if c.TargetPercent <= 0 || c.TargetPercent > 100 {
+		return fmt.Errorf("target_percent must be in (0, 100]")
+	}
+	agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+	if err != nil || agency == nil {
+		return ErrAgencyNotFound
+	}
+	c.AgencyID = agency.ID
+	c.Active = true
+	return s.sla.CreateContract(ctx, c)
+}
+
+// ListContracts returns the active contracts of an agency.
+func (s *SLAService) ListContracts(ctx context.Context, agencySlug string) ([]domain.SLAContract, error) {
+	agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+	if err != nil || agency == nil {
+		return nil, ErrAgencyNotFound
+	}
+	return s.sla.ListContracts(ctx, agency.ID)
+}
+
+// Run refreshes the daily rollups for yesterday and today and, once a month
+// has ended, stores its final evaluation. It is safe to call repeatedly: each
+// month is evaluated, and each breach published, only once. It returns the
+// number of new breaches.
+func (s *SLAService) Run(ctx context.Context, now time.Time) (int, error) {
+	contracts, err := s.sla.ListContracts(ctx, "")
+	if err != nil {
+		return 0, fmt.Errorf("list contracts: %w", err)
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	lastMonth := thisMonth.AddDate(0, -1, 0)
+
+	breaches := 0
+	for _, c := range contracts {
+		for _, day := range []time.Time{today.AddDate(0, 0, -1), today} {
+			if err := s.sla.RollupDay(ctx, &c, day); err != nil {
+				return breaches, fmt.Errorf("rollup %s: %w", c.ID, err)
+			}
+		}
+
+		eval, err := s.evaluate(ctx, &c, lastMonth, thisMonth, now)
+		if err != nil {
+			return breaches, err
+		}
+		eval.Final = true
+		inserted, err := s.sla.SaveEvaluation(ctx, eval)
+		if err != nil {
+			return breaches, fmt.Errorf("save evaluation %s: %w", c.ID, err)
+		}
+		if inserted && eval.Breached {
+			breaches++
+			if s.publisher != nil {
+				_ = s.publisher.PublishSLABreach(ctx, &domain.SLAResult{Contract: c, Evaluation: *eval})
+			}
+		}
+	}
+	return breaches, nil
+}
+
+// Report returns the agency's SLA standing for month (YYYY-MM, default: the
+// month containing now). Finished months use the stored evaluation; the
+// running month is computed on the fly and marked provisional.
+func (s *SLAService) Report(ctx context.Context, agencySlug, month string, now time.Time) (*domain.SLAReport, error) {
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	if month != "" {
+		m, err := time.ParseInLocation("2006-01", month, now.Location())
+		if err != nil {
+			return nil, ErrInvalidMonth
+		}
+		start = m
+	}
+	end := start.AddDate(0, 1, 0)
+
+	contracts, err := s.ListContracts(ctx, agencySlug)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &domain.SLAReport{
+		Agency:  agencySlug,
+		Month:   start.Format("2006-01"),
+		Results: make([]domain.SLAResult, 0, len(contracts)),
+	}
+	for _, c := range contracts {
+		eval, err := s.sla.GetEvaluation(ctx, c.ID, report.Month)
+		if err != nil {
+			return nil, fmt.Errorf("get evaluation %s: %w", c.ID, err)
+		}
+		if eval == nil {
+			if eval, err = s.evaluate(ctx, &c, start, end, now); err != nil {
+				return nil, err
+			}
+		}
+		report.Results = append(report.Results, domain.SLAResult{Contract: c, Evaluation: *eval})
+	}
+	return report, nil
+}
+
+// evaluate measures a contract over [from, to). A month without observations
+// is not a breach.
+func (s *SLAService) evaluate(ctx context.Context, c *domain.SLAContract, from, to, now time.Time) (*domain.SLAEvaluation, error) {
+	observed, onTime, err := s.sla.MonthStats(ctx, c.ID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("month stats %s: %w", c.ID, err)
+	}
+	eval := &domain.SLAEvaluation{
+		ContractID:  c.ID,
+		Month:       from.Format("2006-01"),
+		Observed:    observed,
+		OnTime:      onTime,
+		EvaluatedAt: now,
+	}
+	if observed > 0 {
+		eval.Percent = float64(onTime) / float64(observed) * 100
+		eval.Breached = eval.Percent < c.TargetPercent
+	}
+	return eval, nil
+}
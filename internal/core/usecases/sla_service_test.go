@@ -0,0 +1,214 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock SLARepository ---
+
+type mockSLARepo struct {
+	contracts   []domain.SLAContract
+	stats       map[string][2]int // month -> observed, on time
+	evaluations map[string]*domain.SLAEvaluation
+	rollups     []time.Time
+}
+
+func (m *mockSLARepo) CreateContract(ctx context.Context, c *domain.SLAContract) error {
+	c.ID = "sla-" + c.Name
+	m.contracts = append(m.contracts, *c)
+	return nil
+}
+
+func (m *mockSLARepo) ListContracts(ctx context.Context, agencyID string) ([]domain.SLAContract, error) {
+	var out []domain.SLAContract
+	for _, c := range m.contracts {
+		if agencyID == "" || c.AgencyID == agencyID {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockSLARepo) RollupDay(ctx context.Context, c *domain.SLAContract, day time.Time) error {
+	m.rollups = append(m.rollups, day)
+	return nil
+}
+
+func (m *mockSLARepo) MonthStats(ctx context.Context, contractID string, from, to time.Time) (int, int, error) {
+	s := m.stats[from.Format("2006-01")]
+	return s[0], s[1], nil
+}
+
+func (m *mockSLARepo) SaveEvaluation(ctx context.Context, e *domain.SLAEvaluation) (bool, error) {
+	key := e.ContractID + e.Month
+	if _, ok := m.evaluations[key]; ok {
+		return false, nil
+	}
+	m.evaluations[key] = e
+	return true, nil
+}
+
+func (m *mockSLARepo) GetEvaluation(ctx context.Context, contractID, month string) (*domain.SLAEvaluation, error) {
+	return m.evaluations[contractID+month], nil
+}
+
+// --- Mock EventPublisher ---
+
+type mockPublisher struct {
+	breaches  []domain.SLAResult
+	anomalies []domain.DelayAnomaly
+	positions []domain.VehiclePosition
+	delays    []domain.DelayEvent
+	delayErr  error
+}
+
+func (m *mockPublisher) PublishVehiclePosition(ctx context.Context, vp *domain.VehiclePosition) error {
+	m.positions = append(m.positions, *vp)
+	return nil
+}
+func (m *mockPublisher) PublishDelayEvent(ctx context.Context, e *domain.DelayEvent) error {
+	if m.delayErr != nil {
+		return m.delayErr
+	}
+	m.delays = append(m.delays, *e)
+	return nil
+}
+func (m *mockPublisher) PublishDetourAlert(ctx context.Context, tripID string) error { return nil }
+func (m *mockPublisher) PublishBroadcast(ctx context.Context, data []byte) error     { return nil }
+func (m *mockPublisher) PublishDelayAnomaly(ctx context.Context, a *domain.DelayAnomaly) error {
+	m.anomalies = append(m.anomalies, *a)
+	return nil
+}
+func (m *mockPublisher) PublishSLABreach(ctx context.Context, r *domain.SLAResult) error {
+	m.breaches = append(m.breaches, *r)
+	return nil
+}
+
+func newSLAService(repo *mockSLARepo, pub *mockPublisher) *usecases.SLAService {
+	agencies := &mockAgencyRepo{
+		getBySlugFn: func(ctx context.Context, slug string) (*domain.Agency, error) {
+			if slug != "metro_bilbao" {
+				return nil, errors.New("no rows")
+			}
+			return &domain.Agency{ID: "a1", Slug: slug}, nil
+		},
+	}
+	return usecases.NewSLAService(repo, agencies, pub)
+}
+
+func TestSLAService_CreateContract(t *testing.T) {
+	repo := &mockSLARepo{}
+	svc := newSLAService(repo, nil)
+	ctx := context.Background()
+
+	bad := []domain.SLAContract{
+		{ThresholdSeconds: 300, TargetPercent: 95},
+		{Name: "x", TargetPercent: 95},
+		{Name: "x", ThresholdSeconds: 300, TargetPercent: 120},
+	}
+	for _, c := range bad {
+		if err := svc.CreateContract(ctx, "metro_bilbao", &c); err == nil {
+			t.Errorf("expected validation error for %+v", c)
+		}
+	}
+
+	c := domain.SLAContract{Name: "punctuality", ThresholdSeconds: 300, TargetPercent: 95}
+	if err := svc.CreateContract(ctx, "unknown", &c); !errors.Is(err, usecases.ErrAgencyNotFound) {
+		t.Fatalf("expected ErrAgencyNotFound, got %v", err)
+	}
+	if err := svc.CreateContract(ctx, "metro_bilbao", &c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.AgencyID != "a1" || !c.Active {
+		t.Errorf("expected active contract for a1, got %+v", c)
+	}
+}
+
+func TestSLAService_RunEvaluatesLastMonthOnce(t *testing.T) {
+	repo := &mockSLARepo{
+		contracts:   []domain.SLAContract{{ID: "c1", AgencyID: "a1", ThresholdSeconds: 300, TargetPercent: 95}},
+		stats:       map[string][2]int{"2026-02": {1000, 900}},
+		evaluations: map[string]*domain.SLAEvaluation{},
+	}
+	pub := &mockPublisher{}
+	svc := newSLAService(repo, pub)
+	now := time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		breaches, err := svc.Run(context.Background(), now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := 1 - i; breaches != want {
+			t.Errorf("run %d: expected %d breaches, got %d", i, want, breaches)
+		}
+	}
+
+	if len(pub.breaches) != 1 {
+		t.Fatalf("expected one published breach, got %d", len(pub.breaches))
+	}
+	eval := pub.breaches[0].Evaluation
+	if eval.Month != "2026-02" || eval.Percent != 90 || !eval.Breached || !eval.Final {
+		t.Errorf("unexpected evaluation %+v", eval)
+	}
+	if len(repo.rollups) != 4 || !repo.rollups[0].Equal(time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected rollups of yesterday and today, got %v", repo.rollups)
+	}
+}
+
+func TestSLAService_NoObservationsIsNotBreach(t *testing.T) {
+	repo := &mockSLARepo{
+		contracts:   []domain.SLAContract{{ID: "c1", AgencyID: "a1", ThresholdSeconds: 300, TargetPercent: 95}},
+		evaluations: map[string]*domain.SLAEvaluation{},
+	}
+	pub := &mockPublisher{}
+	breaches, err := newSLAService(repo, pub).Run(context.Background(), time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if breaches != 0 || len(pub.breaches) != 0 {
+		t.Errorf("expected no breach without data, got %d", breaches)
+	}
+}
+
+func TestSLAService_Report(t *testing.T) {
+	repo := &mockSLARepo{
+		contracts: []domain.SLAContract{{ID: "c1", AgencyID: "a1", ThresholdSeconds: 300, TargetPercent: 95}},
+		stats:     map[string][2]int{"2026-03": {200, 198}},
+		evaluations: map[string]*domain.SLAEvaluation{
+			"c12026-02": {ContractID: "c1", Month: "2026-02", Percent: 90, Breached: true, Final: true},
+		},
+	}
+	svc := newSLAService(repo, nil)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	report, err := svc.Report(ctx, "metro_bilbao", "", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Month != "2026-03" || len(report.Results) != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if e := report.Results[0].Evaluation; e.Final || e.Breached || e.Percent != 99 {
+		t.Errorf("expected provisional 99%% for running month, got %+v", e)
+	}
+
+	report, err = svc.Report(ctx, "metro_bilbao", "2026-02", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e := report.Results[0].Evaluation; !e.Final || !e.Breached {
+		t.Errorf("expected stored breached evaluation, got %+v", e)
+	}
+
+	if _, err := svc.Report(ctx, "metro_bilbao", "March", now); !errors.Is(err, usecases.ErrInvalidMonth) {
+		t.Errorf("expected ErrInvalidMonth, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package usecases
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+// StaticVersionService keeps the version of the static data in memory, so
+// conditional requests for stops, routes and trips are answered without a
+// query. It is only as fresh as the last Refresh.
+type StaticVersionService struct {
+	repo ports.StaticVersionRepository
+
+	mu      sync.RWMutex
+	version time.Time
+}
+
+// NewStaticVersionService creates a new StaticVersionService. Until the
+// first Refresh there is no version.
+func NewStaticVersionService(repo ports.StaticVersionRepository) *StaticVersionService {
+	return &StaticVersionService{repo: repo}
+}
+
+// Refresh reads the version again and reports whether it changed.
+func (s *StaticVersionService) Refresh(ctx context.Context) (bool, error) {
+	version, err := s.repo.StaticVersion(ctx)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if version.Equal(s.version) {
+		return false, nil
+	}
+	s.version = version
+	return true, nil
+}
+
+// Current returns the version last read, or the zero time before then.
+func (s *StaticVersionService) Current() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.version
+}
@@ -0,0 +1,56 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock StaticVersionRepository ---
+
+type mockStaticVersionRepo struct {
+	version time.Time
+	err     error
+}
+
+func (m *mockStaticVersionRepo) StaticVersion(ctx context.Context) (time.Time, error) {
+	return m.version, m.err
+}
+
+// --- Tests ---
+
+func TestStaticVersionService_Refresh(t *testing.T) {
+	repo := &mockStaticVersionRepo{version: time.Date(2026, 5, 4, 3, 0, 0, 0, time.UTC)}
+	svc := usecases.NewStaticVersionService(repo)
+	if !svc.Current().IsZero() {
+		t.Fatalf("expected no version before the first refresh, got %v", svc.Current())
+	}
+
+	if changed, err := svc.Refresh(context.Background()); err != nil || !changed {
+		t.Fatalf("expected the first refresh to change the version, got %v, %v", changed, err)
+	}
+	if !svc.Current().Equal(repo.version) {
+		t.Errorf("expected %v, got %v", repo.version, svc.Current())
+	}
+	if changed, _ := svc.Refresh(context.Background()); changed {
+		t.Error("expected no change for the same version")
+	}
+
+	// A failed refresh keeps the version last read.
+	last := repo.version
+	repo.version, repo.err = last.Add(time.Hour), errors.New("db down")
+	if _, err := svc.Refresh(context.Background()); err == nil {
+		t.Fatal("expected the repository error")
+	}
+	if !svc.Current().Equal(last) {
+		t.Errorf("expected %v kept, got %v", last, svc.Current())
+	}
+
+	repo.err = nil
+	if changed, _ := svc.Refresh(context.Background()); !changed || !svc.Current().Equal(repo.version) {
+		t.Errorf("expected the new version %v, got %v", repo.version, svc.Current())
+	}
+}
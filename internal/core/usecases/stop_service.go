@@ -1,18 +1,30 @@
 package usecases
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
+
+	"golang.org/x/sync/singleflight"
 
 	"github.com/samirrijal/bilbopass/internal/core/domain"
 	"github.com/samirrijal/bilbopass/internal/core/ports"
 )
 
+var (
+	ErrStopNotFound = errors.New("stop not found")
+	// ErrAmenitiesLocked is returned when a rider edits amenities an admin has set.
+	ErrAmenitiesLocked = errors.New("stop amenities were set by an admin")
+)
+
 // StopService handles stop-related business logic.
 type StopService struct {
-	stops ports.StopRepository
-	cache ports.CacheService
+	stops   ports.StopRepository
+	cache   ports.CacheService
+	lookups singleflight.Group
 }
 
 // NewStopService creates a new StopService.
@@ -20,14 +32,18 @@ func NewStopService(stops ports.StopRepository, cache ports.CacheService) *StopS
 	return &StopService{stops: stops, cache: cache}
 }
 
-// FindNearby returns stops within radiusMeters of the given point.
-func (s *StopService) FindNearby(ctx context.Context, lat, lon, radiusMeters float64, limit int) ([]domain.Stop, error) {
+// FindNearby returns stops within radiusMeters of the given point that have
+// the amenities the filter asks for and are served by a route of the route
+// types it keeps, nearest first. Platforms of a station are grouped into it,
+// so fewer than limit entries may be returned.
+func (s *StopService) FindNearby(ctx context.Context, lat, lon, radiusMeters float64, limit int, filter domain.StopFilter) ([]domain.Stop, error) {
 	if limit <= 0 || limit > 50 {
 		limit = 50
 	}
 
 	// Try cache
-	cacheKey := fmt.Sprintf("stops:nearby:%.4f:%.4f:%.0f:%d", lat, lon, radiusMeters, limit)
+	cacheKey := fmt.Sprintf("stops:nearby:%.4f:%.4f:%.0f:%d:%t:%t:%t:%v:%v", lat, lon, radiusMeters, limit,
+		filter.HasShelter, filter.HasBench, filter.HasRealtimeDisplay, filter.RouteTypes.Include, filter.RouteTypes.Exclude)
 	if s.cache != nil {
 		if data, err := s.cache.Get(ctx, cacheKey); err == nil {
 			var stops []domain.Stop
@@ -37,10 +53,13 @@ func (s *StopService) FindNearby(ctx context.Context, lat, lon, radiusMeters flo
 		}
 	}
 
-	stops, err := s.stops.FindNearby(ctx, lat, lon, radiusMeters, limit)
+	stops, err := s.stops.FindNearby(ctx, lat, lon, radiusMeters, limit, filter)
 	if err != nil {
 		return nil, err
 	}
+	if stops, err = s.groupStations(ctx, stops); err != nil {
+		return nil, err
+	}
 
 	// Cache for 5 minutes (stops don't change frequently)
 	if s.cache != nil {
@@ -52,7 +71,9 @@ func (s *StopService) FindNearby(ctx context.Context, lat, lon, radiusMeters flo
 	return stops, nil
 }
 
-// Search performs fuzzy + full-text search on stop names.
+// Search finds stops by name, ignoring accents, or by stop code, ranking
+// those near near (when set) higher and grouping platforms into their
+// stations like FindNearby.
 func (s *StopService) Search(ctx context.Context, query string, near *domain.GeoPoint, limit int) ([]domain.Stop, error) {
 	if query == "" {
 		return nil, fmt.Errorf("search query must not be empty")
@@ -63,6 +84,9 @@ func (s *StopService) Search(ctx context.Context, query string, near *domain.Geo
 
 	// Try cache
 	cacheKey := fmt.Sprintf("stops:search:%s:%d", query, limit)
+	if near != nil {
+		cacheKey += fmt.Sprintf(":%.3f:%.3f", near.Lat, near.Lon)
+	}
 	if s.cache != nil {
 		if data, err := s.cache.Get(ctx, cacheKey); err == nil {
 			var stops []domain.Stop
@@ -76,6 +100,9 @@ func (s *StopService) Search(ctx context.Context, query string, near *domain.Geo
 	if err != nil {
 		return nil, err
 	}
+	if stops, err = s.groupStations(ctx, stops); err != nil {
+		return nil, err
+	}
 
 	// Cache for 5 minutes
 	if s.cache != nil {
@@ -87,11 +114,104 @@ func (s *StopService) Search(ctx context.Context, query string, near *domain.Geo
 	return stops, nil
 }
 
-// GetByID returns a single stop.
+// Suggest returns up to limit stop names close to a query Search found
+// nothing for, best first.
+func (s *StopService) Suggest(ctx context.Context, query string, limit int) ([]string, error) {
+	if limit <= 0 || limit > 10 {
+		limit = 5
+	}
+
+	cacheKey := fmt.Sprintf("stops:suggest:%s:%d", query, limit)
+	if s.cache != nil {
+		if data, err := s.cache.Get(ctx, cacheKey); err == nil {
+			var names []string
+			if err := json.Unmarshal(data, &names); err == nil {
+				return names, nil
+			}
+		}
+	}
+
+	names, err := s.stops.Suggest(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	if names == nil {
+		names = []string{}
+	}
+
+	if s.cache != nil {
+		if data, err := json.Marshal(names); err == nil {
+			_ = s.cache.Set(ctx, cacheKey, data, 300)
+		}
+	}
+	return names, nil
+}
+
+// groupStations replaces each platform of a station with the station and
+// all its platforms, keeping the order of the first platform found. The
+// station takes that platform's distance.
+func (s *StopService) groupStations(ctx context.Context, stops []domain.Stop) ([]domain.Stop, error) {
+	var parentIDs []string
+	seen := make(map[string]bool)
+	for _, st := range stops {
+		if st.ParentID != "" && !seen[st.ParentID] {
+			seen[st.ParentID] = true
+			parentIDs = append(parentIDs, st.ParentID)
+		}
+	}
+	if len(parentIDs) == 0 {
+		return stops, nil
+	}
+
+	parents, err := s.stops.GetByIDs(ctx, parentIDs)
+	if err != nil {
+		return nil, err
+	}
+	stations := make(map[string]*domain.Stop, len(parents))
+	for i := range parents {
+		if parents[i].IsStation() {
+			stations[parents[i].ID] = &parents[i]
+		}
+	}
+	platforms, err := s.stops.ListPlatforms(ctx, parentIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range platforms {
+		if station := stations[p.ParentID]; station != nil {
+			station.Platforms = append(station.Platforms, p)
+		}
+	}
+
+	grouped := make([]domain.Stop, 0, len(stops))
+	added := make(map[string]bool)
+	for _, st := range stops {
+		station := stations[st.ParentID]
+		if station == nil {
+			grouped = append(grouped, st)
+			continue
+		}
+		if added[station.ID] {
+			continue
+		}
+		added[station.ID] = true
+		g := *station
+		g.Distance = st.Distance
+		grouped = append(grouped, g)
+	}
+	return grouped, nil
+}
+
+// GetByID returns a single stop, or ErrStopNotFound. Concurrent lookups of
+// the same stop share one query, and unknown stops are remembered for
+// missingTTL.
 func (s *StopService) GetByID(ctx context.Context, id string) (*domain.Stop, error) {
 	cacheKey := "stops:id:" + id
 	if s.cache != nil {
 		if data, err := s.cache.Get(ctx, cacheKey); err == nil {
+			if bytes.Equal(data, missingMarker) {
+				return nil, ErrStopNotFound
+			}
 			var stop domain.Stop
 			if err := json.Unmarshal(data, &stop); err == nil {
 				return &stop, nil
@@ -99,17 +219,30 @@ func (s *StopService) GetByID(ctx context.Context, id string) (*domain.Stop, err
 		}
 	}
 
-	stop, err := s.stops.GetByID(ctx, id)
+	stop, shared, err := coalesce(ctx, &s.lookups, id, func(ctx context.Context) (*domain.Stop, error) {
+		stop, err := s.stops.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if s.cache != nil {
+			if stop == nil {
+				_ = s.cache.Set(ctx, cacheKey, missingMarker, missingTTL)
+			} else if data, err := json.Marshal(stop); err == nil {
+				_ = s.cache.Set(ctx, cacheKey, data, 600) // 10 min for single stop
+			}
+		}
+		return stop, nil
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	if s.cache != nil {
-		if data, err := json.Marshal(stop); err == nil {
-			_ = s.cache.Set(ctx, cacheKey, data, 600) // 10 min for single stop
-		}
+	if stop == nil {
+		return nil, ErrStopNotFound
+	}
+	if shared {
+		copied := *stop
+		stop = &copied
 	}
-
 	return stop, nil
 }
 
@@ -120,3 +253,45 @@ func (s *StopService) GetByIDs(ctx context.Context, ids []string) ([]domain.Stop
 	}
 	return s.stops.GetByIDs(ctx, ids)
 }
+
+// UpdateAmenities records amenities reported by source (admin or community).
+// Only the fields set in update change. Riders cannot change amenities an
+// admin has set.
+func (s *StopService) UpdateAmenities(ctx context.Context, id string, update domain.StopAmenities, source string, now time.Time) (*domain.Stop, error) {
+	if update.Shelter == nil && update.Bench == nil && update.RealtimeDisplay == nil {
+		return nil, fmt.Errorf("at least one of shelter, bench or realtime_display is required")
+	}
+	stop, err := s.stops.GetByID(ctx, id)
+	if err != nil || stop == nil {
+		return nil, ErrStopNotFound
+	}
+	if source == domain.AmenitySourceCommunity && stop.Amenities.Source == domain.AmenitySourceAdmin {
+		return nil, ErrAmenitiesLocked
+	}
+
+	a := stop.Amenities
+	if update.Shelter != nil {
+		a.Shelter = update.Shelter
+	}
+	if update.Bench != nil {
+		a.Bench = update.Bench
+	}
+	if update.RealtimeDisplay != nil {
+		a.RealtimeDisplay = update.RealtimeDisplay
+	}
+	a.Source = source
+	a.UpdatedAt = &now
+
+	ok, err := s.stops.UpdateAmenities(ctx, id, &a)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrStopNotFound
+	}
+	if s.cache != nil {
+		_ = s.cache.Delete(ctx, "stops:id:"+id)
+	}
+	stop.Amenities = a
+	return stop, nil
+}
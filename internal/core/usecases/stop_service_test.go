@@ -2,7 +2,13 @@ package usecases_test
 
 import (
 	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/samirrijal/bilbopass/internal/core/domain"
 	"github.com/samirrijal/bilbopass/internal/core/usecases"
@@ -15,12 +21,17 @@ type mockStopRepo struct {
 	getByIDFn    func(ctx context.Context, id string) (*domain.Stop, error)
 	getByIDsFn   func(ctx context.Context, ids []string) ([]domain.Stop, error)
 	searchFn     func(ctx context.Context, query string, near *domain.GeoPoint, limit int) ([]domain.Stop, error)
+	platforms    []domain.Stop
+	suggestions  []string
+	lastFilter   domain.StopFilter
+	amenities    map[string]domain.StopAmenities
 }
 
 func (m *mockStopRepo) Upsert(ctx context.Context, stop *domain.Stop) error        { return nil }
 func (m *mockStopRepo) UpsertBatch(ctx context.Context, stops []domain.Stop) error { return nil }
 
-func (m *mockStopRepo) FindNearby(ctx context.Context, lat, lon, radius float64, limit int) ([]domain.Stop, error) {
+func (m *mockStopRepo) FindNearby(ctx context.Context, lat, lon, radius float64, limit int, filter domain.StopFilter) ([]domain.Stop, error) {
+	m.lastFilter = filter
 	if m.findNearbyFn != nil {
 		return m.findNearbyFn(ctx, lat, lon, radius, limit)
 	}
@@ -48,6 +59,25 @@ func (m *mockStopRepo) Search(ctx context.Context, query string, near *domain.Ge
 	return nil, nil
 }
 
+func (m *mockStopRepo) ListPlatforms(ctx context.Context, stationIDs []string) ([]domain.Stop, error) {
+	return m.platforms, nil
+}
+
+func (m *mockStopRepo) Suggest(ctx context.Context, query string, limit int) ([]string, error) {
+	if len(m.suggestions) > limit {
+		return m.suggestions[:limit], nil
+	}
+	return m.suggestions, nil
+}
+
+func (m *mockStopRepo) UpdateAmenities(ctx context.Context, id string, a *domain.StopAmenities) (bool, error) {
+	if m.amenities == nil {
+		m.amenities = map[string]domain.StopAmenities{}
+	}
+	m.amenities[id] = *a
+	return true, nil
+}
+
 // --- Tests ---
 
 func TestStopService_FindNearby(t *testing.T) {
@@ -62,7 +92,7 @@ func TestStopService_FindNearby(t *testing.T) {
 
 	svc := usecases.NewStopService(repo, nil)
 
-	stops, err := svc.FindNearby(context.Background(), 43.263, -2.935, 500, 10)
+	stops, err := svc.FindNearby(context.Background(), 43.263, -2.935, 500, 10, domain.StopFilter{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -74,6 +104,42 @@ func TestStopService_FindNearby(t *testing.T) {
 	}
 }
 
+func TestStopService_FindNearby_GroupsStations(t *testing.T) {
+	d1, d2, d3 := 40.0, 60.0, 90.0
+	repo := &mockStopRepo{
+		findNearbyFn: func(ctx context.Context, lat, lon, radius float64, limit int) ([]domain.Stop, error) {
+			return []domain.Stop{
+				{ID: "p1", Name: "Abando", ParentID: "st1", PlatformCode: "1", Distance: &d1},
+				{ID: "b1", Name: "Abando bus", Distance: &d2},
+				{ID: "p2", Name: "Abando", ParentID: "st1", PlatformCode: "2", Distance: &d3},
+			}, nil
+		},
+		getByIDsFn: func(ctx context.Context, ids []string) ([]domain.Stop, error) {
+			if !reflect.DeepEqual(ids, []string{"st1"}) {
+				t.Errorf("expected the station to be fetched once, got %v", ids)
+			}
+			return []domain.Stop{{ID: "st1", Name: "Abando", LocationType: domain.LocationStation}}, nil
+		},
+		platforms: []domain.Stop{
+			{ID: "p1", ParentID: "st1", PlatformCode: "1"},
+			{ID: "p2", ParentID: "st1", PlatformCode: "2"},
+			{ID: "p3", ParentID: "st1", PlatformCode: "3"},
+		},
+	}
+	svc := usecases.NewStopService(repo, nil)
+
+	stops, err := svc.FindNearby(context.Background(), 43.263, -2.935, 500, 10, domain.StopFilter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stops) != 2 || stops[0].ID != "st1" || stops[1].ID != "b1" {
+		t.Fatalf("expected the station then the bus stop, got %+v", stops)
+	}
+	if *stops[0].Distance != d1 || len(stops[0].Platforms) != 3 {
+		t.Errorf("expected the nearest platform's distance and all 3 platforms, got %v and %d", *stops[0].Distance, len(stops[0].Platforms))
+	}
+}
+
 func TestStopService_FindNearby_ClampLimit(t *testing.T) {
 	called := false
 	repo := &mockStopRepo{
@@ -87,7 +153,7 @@ func TestStopService_FindNearby_ClampLimit(t *testing.T) {
 	}
 
 	svc := usecases.NewStopService(repo, nil)
-	_, _ = svc.FindNearby(context.Background(), 43.0, -2.0, 500, 999)
+	_, _ = svc.FindNearby(context.Background(), 43.0, -2.0, 500, 999, domain.StopFilter{})
 	if !called {
 		t.Error("repo was not called")
 	}
@@ -123,6 +189,27 @@ func TestStopService_Search_Success(t *testing.T) {
 	}
 }
 
+func TestStopService_Suggest(t *testing.T) {
+	svc := usecases.NewStopService(&mockStopRepo{}, nil)
+	names, err := svc.Suggest(context.Background(), "abandu", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if names == nil || len(names) != 0 {
+		t.Errorf("expected an empty, non-nil list, got %#v", names)
+	}
+
+	repo := &mockStopRepo{suggestions: []string{"Abando", "Abaroa", "Abusu", "Ametzola", "Anoeta", "Areeta"}}
+	svc = usecases.NewStopService(repo, nil)
+	names, err = svc.Suggest(context.Background(), "abandu", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(names) != 5 || names[0] != "Abando" {
+		t.Errorf("expected the 5 best suggestions, got %v", names)
+	}
+}
+
 func TestStopService_GetByID(t *testing.T) {
 	repo := &mockStopRepo{
 		getByIDFn: func(ctx context.Context, id string) (*domain.Stop, error) {
@@ -139,3 +226,141 @@ func TestStopService_GetByID(t *testing.T) {
 		t.Errorf("expected id abc-123, got %s", stop.ID)
 	}
 }
+
+func TestStopService_GetByID_NotFoundCached(t *testing.T) {
+	lookups := 0
+	repo := &mockStopRepo{
+		getByIDFn: func(ctx context.Context, id string) (*domain.Stop, error) {
+			lookups++
+			return nil, nil
+		},
+	}
+	svc := usecases.NewStopService(repo, &mockCache{})
+
+	for range 3 {
+		if _, err := svc.GetByID(context.Background(), "nope"); !errors.Is(err, usecases.ErrStopNotFound) {
+			t.Fatalf("expected ErrStopNotFound, got %v", err)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("expected the miss to be remembered, got %d lookups", lookups)
+	}
+}
+
+func TestStopService_GetByID_Coalesces(t *testing.T) {
+	var lookups atomic.Int32
+	release := make(chan struct{})
+	repo := &mockStopRepo{
+		getByIDFn: func(ctx context.Context, id string) (*domain.Stop, error) {
+			lookups.Add(1)
+			<-release
+			return &domain.Stop{ID: id, Name: "Moyua"}, nil
+		},
+	}
+	svc := usecases.NewStopService(repo, nil)
+
+	// The caller that started the lookup gives up; the others still get
+	// the stop.
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := svc.GetByID(ctx, "moyua")
+		first <- err
+	}()
+	for lookups.Load() == 0 {
+		runtime.Gosched()
+	}
+	var wg sync.WaitGroup
+	stops := make([]*domain.Stop, 10)
+	for i := range stops {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stop, err := svc.GetByID(context.Background(), "moyua")
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			stops[i] = stop
+		}()
+	}
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the first caller to give up, got %v", err)
+	}
+	time.Sleep(50 * time.Millisecond) // let the others join the lookup
+	close(release)
+	wg.Wait()
+
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("expected one lookup, got %d", n)
+	}
+	for _, stop := range stops {
+		if stop == nil || stop.Name != "Moyua" {
+			t.Fatalf("expected Moyua, got %+v", stop)
+		}
+	}
+	if stops[0] == stops[1] {
+		t.Error("expected each caller to get its own copy")
+	}
+}
+
+func TestStopService_FindNearby_PassesFilter(t *testing.T) {
+	repo := &mockStopRepo{}
+	svc := usecases.NewStopService(repo, nil)
+
+	filter := domain.StopFilter{HasShelter: true, HasRealtimeDisplay: true,
+		RouteTypes: domain.RouteTypeFilter{Include: []int{1, 401}}}
+	if _, err := svc.FindNearby(context.Background(), 43.263, -2.935, 500, 10, filter); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(repo.lastFilter, filter) {
+		t.Errorf("expected filter %+v, got %+v", filter, repo.lastFilter)
+	}
+}
+
+func TestStopService_UpdateAmenities(t *testing.T) {
+	yes, no := true, false
+	stops := map[string]*domain.Stop{
+		"s1": {ID: "s1", Amenities: domain.StopAmenities{Shelter: &no, Source: domain.AmenitySourceOSM}},
+		"s2": {ID: "s2", Amenities: domain.StopAmenities{Shelter: &yes, Source: domain.AmenitySourceAdmin}},
+	}
+	repo := &mockStopRepo{
+		getByIDFn: func(ctx context.Context, id string) (*domain.Stop, error) {
+			if s, ok := stops[id]; ok {
+				return s, nil
+			}
+			return nil, errors.New("no rows")
+		},
+	}
+	svc := usecases.NewStopService(repo, nil)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	stop, err := svc.UpdateAmenities(ctx, "s1", domain.StopAmenities{Bench: &yes}, domain.AmenitySourceCommunity, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	a := repo.amenities["s1"]
+	if a.Shelter == nil || *a.Shelter || a.Bench == nil || !*a.Bench || a.RealtimeDisplay != nil {
+		t.Errorf("expected shelter kept and bench set, got %+v", a)
+	}
+	if a.Source != domain.AmenitySourceCommunity || a.UpdatedAt == nil || !a.UpdatedAt.Equal(now) {
+		t.Errorf("expected community edit at %v, got %+v", now, a)
+	}
+	if stop.Amenities.Bench == nil || !*stop.Amenities.Bench {
+		t.Errorf("expected updated stop, got %+v", stop.Amenities)
+	}
+
+	if _, err := svc.UpdateAmenities(ctx, "s2", domain.StopAmenities{Shelter: &no}, domain.AmenitySourceCommunity, now); !errors.Is(err, usecases.ErrAmenitiesLocked) {
+		t.Errorf("expected ErrAmenitiesLocked, got %v", err)
+	}
+	if _, err := svc.UpdateAmenities(ctx, "s2", domain.StopAmenities{Shelter: &no}, domain.AmenitySourceAdmin, now); err != nil {
+		t.Errorf("expected admin edit to succeed, got %v", err)
+	}
+	if _, err := svc.UpdateAmenities(ctx, "missing", domain.StopAmenities{Bench: &yes}, domain.AmenitySourceAdmin, now); !errors.Is(err, usecases.ErrStopNotFound) {
+		t.Errorf("expected ErrStopNotFound, got %v", err)
+	}
+	if _, err := svc.UpdateAmenities(ctx, "s1", domain.StopAmenities{}, domain.AmenitySourceAdmin, now); err == nil {
+		t.Error("expected error for empty update")
+	}
+}
@@ -0,0 +1,185 @@
+package usecases
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+const (
+	defaultSyncLimit = 500
+	maxSyncLimit     = 2000
+	// syncOverlap is how far back the cursor of a finished sync is set, so
+	// rows committed late with an earlier change time are not missed.
+	// Clients may receive the same change twice.
+	syncOverlap = 5 * time.Minute
+)
+
+// ErrInvalidCursor is returned for a sync cursor this server did not issue.
+var ErrInvalidCursor = errors.New("invalid sync cursor")
+
+// SyncService lets apps keep stops, routes and alerts up to date by
+// fetching only what changed since their last sync. An empty cursor starts
+// a full sync; clients ignore deletions of entities they do not hold.
+type SyncService struct {
+	repo     ports.SyncRepository
+	agencies ports.AgencyRepository
+}
+
+// NewSyncService creates a new SyncService.
+func NewSyncService(repo ports.SyncRepository, agencies ports.AgencyRepository) *SyncService {
+	return &SyncService{repo: repo, agencies: agencies}
+}
+
+// Stops returns a page of stops changed or deleted after cursor. agencySlug
+// may be empty for all agencies.
+func (s *SyncService) Stops(ctx context.Context, agencySlug, cursor string, limit int, now time.Time) (*domain.StopSync, error) {
+	agencyID, after, limit, err := s.start(ctx, agencySlug, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := s.repo.StopChanges(ctx, agencyID, after, now, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &domain.StopSync{Stops: []domain.Stop{}}
+	positions := make([]domain.SyncPosition, len(changes))
+	for i, c := range changes {
+		positions[i] = c.SyncPosition
+	}
+	n := syncPage(&out.SyncPage, positions, after, now, limit)
+	for _, c := range changes[:n] {
+		if c.Stop != nil {
+			out.Stops = append(out.Stops, *c.Stop)
+		} else {
+			out.Deleted = append(out.Deleted, c.ID)
+		}
+	}
+	return out, nil
+}
+
+// Routes returns a page of routes changed or deleted after cursor, without
+// their shapes. agencySlug may be empty for all agencies.
+func (s *SyncService) Routes(ctx context.Context, agencySlug, cursor string, limit int, now time.Time) (*domain.RouteSync, error) {
+	agencyID, after, limit, err := s.start(ctx, agencySlug, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := s.repo.RouteChanges(ctx, agencyID, after, now, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &domain.RouteSync{Routes: []domain.Route{}}
+	positions := make([]domain.SyncPosition, len(changes))
+	for i, c := range changes {
+		positions[i] = c.SyncPosition
+	}
+	n := syncPage(&out.SyncPage, positions, after, now, limit)
+	for _, c := range changes[:n] {
+		if c.Route != nil {
+			out.Routes = append(out.Routes, *c.Route)
+		} else {
+			out.Deleted = append(out.Deleted, c.ID)
+		}
+	}
+	return out, nil
+}
+
+// Alerts returns a page of service alerts changed or removed after cursor.
+// agencySlug may be empty for all agencies.
+func (s *SyncService) Alerts(ctx context.Context, agencySlug, cursor string, limit int, now time.Time) (*domain.AlertSync, error) {
+	agencyID, after, limit, err := s.start(ctx, agencySlug, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := s.repo.AlertChanges(ctx, agencyID, after, now, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &domain.AlertSync{Alerts: []domain.ServiceAlert{}}
+	positions := make([]domain.SyncPosition, len(changes))
+	for i, c := range changes {
+		positions[i] = c.SyncPosition
+	}
+	n := syncPage(&out.SyncPage, positions, after, now, limit)
+	for _, c := range changes[:n] {
+		if c.Alert != nil {
+			out.Alerts = append(out.Alerts, *c.Alert)
+		} else {
+			out.Deleted = append(out.Deleted, c.ID)
+		}
+	}
+	return out, nil
+}
+
+// start resolves the agency and decodes the cursor and page size of a sync.
+func (s *SyncService) start(ctx context.Context, agencySlug, cursor string, limit int) (string, domain.SyncPosition, int, error) {
+	after, err := decodeSyncCursor(cursor)
+	if err != nil {
+		return "", after, 0, err
+	}
+	if limit <= 0 {
+		limit = defaultSyncLimit
+	}
+	limit = min(limit, maxSyncLimit)
+
+	if agencySlug == "" {
+		return "", after, limit, nil
+	}
+	agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+	if err != nil || agency == nil {
+		return "", after, 0, ErrAgencyNotFound
+	}
+	return agency.ID, after, limit, nil
+}
+
+// syncPage fills p for changes at positions, fetched with one more than limit
+// to tell whether more follow, and returns how many of them belong to the
+// page. A page that has more ends its cursor at its last change; the last
+// page moves the cursor to until minus syncOverlap, or keeps it when the
+// previous sync was more recent.
+func syncPage(p *domain.SyncPage, positions []domain.SyncPosition, after domain.SyncPosition, until time.Time, limit int) int {
+	p.Deleted = []string{}
+	if len(positions) > limit {
+		p.HasMore = true
+		p.Cursor = encodeSyncCursor(positions[limit-1])
+		return limit
+	}
+	next := domain.SyncPosition{At: until.Add(-syncOverlap)}
+	if !after.At.Before(next.At) {
+		next = after
+	}
+	p.Cursor = encodeSyncCursor(next)
+	return len(positions)
+}
+
+// encodeSyncCursor encodes a position as an opaque cursor.
+func encodeSyncCursor(pos domain.SyncPosition) string {
+	raw := strconv.FormatInt(pos.At.UnixMicro(), 10) + "|" + pos.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSyncCursor(cursor string) (domain.SyncPosition, error) {
+	if cursor == "" {
+		return domain.SyncPosition{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return domain.SyncPosition{}, ErrInvalidCursor
+	}
+	micros, id, ok := strings.Cut(string(raw), "|")
+	us, err := strconv.ParseInt(micros, 10, 64)
+	if !ok || err != nil {
+		return domain.SyncPosition{}, ErrInvalidCursor
+	}
+	return domain.SyncPosition{At: time.UnixMicro(us).UTC(), ID: id}, nil
+}
@@ -0,0 +1,117 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock SyncRepository ---
+
+// mockSyncRepo holds change logs, oldest first.
+type mockSyncRepo struct {
+	stops  []domain.StopChange
+	routes []domain.RouteChange
+	alerts []domain.AlertChange
+}
+
+func syncAfter(p, q domain.SyncPosition) bool {
+	return p.At.After(q.At) || (p.At.Equal(q.At) && p.ID > q.ID)
+}
+
+func (m *mockSyncRepo) StopChanges(ctx context.Context, agencyID string, pos domain.SyncPosition, until time.Time, limit int) ([]domain.StopChange, error) {
+	var out []domain.StopChange
+	for _, c := range m.stops {
+		if syncAfter(c.SyncPosition, pos) && !c.At.After(until) && len(out) < limit {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockSyncRepo) RouteChanges(ctx context.Context, agencyID string, pos domain.SyncPosition, until time.Time, limit int) ([]domain.RouteChange, error) {
+	var out []domain.RouteChange
+	for _, c := range m.routes {
+		if syncAfter(c.SyncPosition, pos) && !c.At.After(until) && len(out) < limit {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockSyncRepo) AlertChanges(ctx context.Context, agencyID string, pos domain.SyncPosition, until time.Time, limit int) ([]domain.AlertChange, error) {
+	var out []domain.AlertChange
+	for _, c := range m.alerts {
+		if syncAfter(c.SyncPosition, pos) && !c.At.After(until) && len(out) < limit {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func TestSyncService_Stops(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	stop := func(id string) *domain.Stop { return &domain.Stop{ID: id, Name: "Stop " + id} }
+	repo := &mockSyncRepo{stops: []domain.StopChange{
+		{SyncPosition: domain.SyncPosition{At: t0, ID: "s1"}, Stop: stop("s1")},
+		{SyncPosition: domain.SyncPosition{At: t0, ID: "s2"}, Stop: stop("s2")},
+		{SyncPosition: domain.SyncPosition{At: t0.Add(time.Minute), ID: "s3"}}, // deleted
+	}}
+	svc := usecases.NewSyncService(repo, newBrandingAgencies())
+	now := t0.Add(time.Hour)
+
+	// A full sync pages through the change log.
+	first, err := svc.Stops(ctx, "metro_bilbao", "", 2, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(first.Stops) != 2 || !first.HasMore {
+		t.Fatalf("expected a first page of 2 stops, got %+v", first)
+	}
+	last, _ := svc.Stops(ctx, "metro_bilbao", first.Cursor, 2, now)
+	if len(last.Stops) != 0 || len(last.Deleted) != 1 || last.HasMore {
+		t.Fatalf("expected a last page with s3 deleted, got %+v", last)
+	}
+
+	// A delta sync returns what changed since.
+	repo.stops = append(repo.stops,
+		domain.StopChange{SyncPosition: domain.SyncPosition{At: now.Add(time.Minute), ID: "s2"}, Stop: stop("s2")},
+		domain.StopChange{SyncPosition: domain.SyncPosition{At: now.Add(2 * time.Minute), ID: "s1"}},
+	)
+	delta, _ := svc.Stops(ctx, "metro_bilbao", last.Cursor, 0, now.Add(time.Hour))
+	if len(delta.Stops) != 1 || delta.Stops[0].ID != "s2" || len(delta.Deleted) != 1 || delta.Deleted[0] != "s1" {
+		t.Errorf("expected s2 changed and s1 deleted, got %+v", delta)
+	}
+
+	// Changes within the overlap are sent again.
+	again, _ := svc.Stops(ctx, "metro_bilbao", delta.Cursor, 0, now.Add(time.Hour+time.Minute))
+	if len(again.Stops) != 0 || len(again.Deleted) != 0 {
+		t.Errorf("expected nothing new, got %+v", again)
+	}
+	recent, _ := svc.Stops(ctx, "metro_bilbao", last.Cursor, 0, now.Add(3*time.Minute))
+	overlap, _ := svc.Stops(ctx, "metro_bilbao", recent.Cursor, 0, now.Add(4*time.Minute))
+	if len(overlap.Stops) != 1 || len(overlap.Deleted) != 1 {
+		t.Errorf("expected the changes within the overlap again, got %+v", overlap)
+	}
+}
+
+func TestSyncService_Errors(t *testing.T) {
+	ctx := context.Background()
+	svc := usecases.NewSyncService(&mockSyncRepo{}, newBrandingAgencies())
+
+	if _, err := svc.Stops(ctx, "", "not a cursor!", 0, time.Now()); !errors.Is(err, usecases.ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor, got %v", err)
+	}
+	if _, err := svc.Routes(ctx, "nope", "", 0, time.Now()); !errors.Is(err, usecases.ErrAgencyNotFound) {
+		t.Errorf("expected ErrAgencyNotFound, got %v", err)
+	}
+	page, err := svc.Alerts(ctx, "", "", 0, time.Now())
+	if err != nil || page.Alerts == nil || page.Deleted == nil || page.Cursor == "" {
+		t.Errorf("expected an empty page with a cursor, got %+v, %v", page, err)
+	}
+}
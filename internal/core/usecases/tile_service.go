@@ -0,0 +1,125 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+	"github.com/samirrijal/bilbopass/internal/pkg/geospatial"
+)
+
+// ErrInvalidTile is returned for tile coordinates outside the XYZ grid.
+var ErrInvalidTile = errors.New("invalid tile coordinates")
+
+const (
+	// maxTileZoom is the deepest zoom level served.
+	maxTileZoom = 20
+	// stopsMinZoom hides stops on overview tiles, where they would be unreadable.
+	stopsMinZoom = 13
+	// tileCacheTTL is how long rendered tiles stay in the cache (seconds).
+	tileCacheTTL = 3600
+)
+
+// TileService serves stop and route map tiles with read-through caching.
+type TileService struct {
+	tiles ports.TileRepository
+	cache ports.CacheService
+}
+
+// NewTileService creates a new TileService.
+func NewTileService(tiles ports.TileRepository, cache ports.CacheService) *TileService {
+	return &TileService{tiles: tiles, cache: cache}
+}
+
+// MVT returns the Mapbox Vector Tile at z/x/y.
+func (s *TileService) MVT(ctx context.Context, z, x, y int) ([]byte, error) {
+	if err := validateTile(z, x, y); err != nil {
+		return nil, err
+	}
+	return s.cached(ctx, fmt.Sprintf("tiles:mvt:%d:%d:%d", z, x, y), func() ([]byte, error) {
+		return s.tiles.MVT(ctx, z, x, y, z >= stopsMinZoom)
+	})
+}
+
+// GeoJSON returns the tile at z/x/y as an encoded GeoJSON FeatureCollection.
+func (s *TileService) GeoJSON(ctx context.Context, z, x, y int) ([]byte, error) {
+	if err := validateTile(z, x, y); err != nil {
+		return nil, err
+	}
+	return s.cached(ctx, fmt.Sprintf("tiles:geojson:%d:%d:%d", z, x, y), func() ([]byte, error) {
+		routes, err := s.tiles.RouteShapesInTile(ctx, z, x, y)
+		if err != nil {
+			return nil, fmt.Errorf("route shapes: %w", err)
+		}
+
+		features := make([]geospatial.Feature, 0, len(routes))
+		for i := range routes {
+			rt := &routes[i]
+			features = append(features, geospatial.Feature{
+				Type:     "Feature",
+				Geometry: geospatial.LineStringGeometry(rt.Shape),
+				Properties: map[string]any{
+					"layer":      "routes",
+					"id":         rt.ID,
+					"agency_id":  rt.AgencyID,
+					"short_name": rt.ShortName,
+					"route_type": rt.RouteType,
+					"color":      "#" + rt.Color,
+				},
+			})
+		}
+
+		if z >= stopsMinZoom {
+			stops, err := s.tiles.StopsInTile(ctx, z, x, y)
+			if err != nil {
+				return nil, fmt.Errorf("stops: %w", err)
+			}
+			for _, st := range stops {
+				features = append(features, geospatial.Feature{
+					Type:     "Feature",
+					ID:       st.ID,
+					Geometry: geospatial.PointGeometry(st.Location),
+					Properties: map[string]any{
+						"layer":                 "stops",
+						"agency_id":             st.AgencyID,
+						"name":                  st.Name,
+						"wheelchair_accessible": st.WheelchairAccessible,
+					},
+				})
+			}
+		}
+
+		return json.Marshal(geospatial.NewFeatureCollection(features...))
+	})
+}
+
+func (s *TileService) cached(ctx context.Context, key string, render func() ([]byte, error)) ([]byte, error) {
+	if s.cache != nil {
+		if data, err := s.cache.Get(ctx, key); err == nil {
+			return data, nil
+		}
+	}
+
+	data, err := render()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.cache != nil {
+		_ = s.cache.Set(ctx, key, data, tileCacheTTL)
+	}
+	return data, nil
+}
+
+func validateTile(z, x, y int) error {
+	if z < 0 || z > maxTileZoom {
+		return ErrInvalidTile
+	}
+	n := 1 << z
+	if x < 0 || x >= n || y < 0 || y >= n {
+		return ErrInvalidTile
+	}
+	return nil
+}
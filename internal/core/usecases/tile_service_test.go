@@ -0,0 +1,130 @@
+package usecases_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock TileRepository ---
+
+type mockTileRepo struct {
+	mvtCalls  int
+	withStops bool
+	stopsFn   func(ctx context.Context, z, x, y int) ([]domain.Stop, error)
+	routesFn  func(ctx context.Context, z, x, y int) ([]domain.Route, error)
+}
+
+func (m *mockTileRepo) MVT(ctx context.Context, z, x, y int, withStops bool) ([]byte, error) {
+	m.mvtCalls++
+	m.withStops = withStops
+	return []byte{0x1a, 0x00}, nil
+}
+
+func (m *mockTileRepo) StopsInTile(ctx context.Context, z, x, y int) ([]domain.Stop, error) {
+	if m.stopsFn != nil {
+		return m.stopsFn(ctx, z, x, y)
+	}
+	return nil, nil
+}
+
+func (m *mockTileRepo) RouteShapesInTile(ctx context.Context, z, x, y int) ([]domain.Route, error) {
+	if m.routesFn != nil {
+		return m.routesFn(ctx, z, x, y)
+	}
+	return nil, nil
+}
+
+// --- Mock CacheService ---
+
+type mockCache struct {
+	data map[string][]byte
+}
+
+func (m *mockCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if v, ok := m.data[key]; ok {
+		return v, nil
+	}
+	return nil, errors.New("miss")
+}
+
+func (m *mockCache) Set(ctx context.Context, key string, value []byte, ttlSeconds int) error {
+	if m.data == nil {
+		m.data = map[string][]byte{}
+	}
+	m.data[key] = value
+	return nil
+}
+
+func (m *mockCache) Delete(ctx context.Context, key string) error {
+	delete(m.data, key)
+	return nil
+}
+
+// --- Tests ---
+
+func TestTileService_InvalidTile(t *testing.T) {
+	svc := usecases.NewTileService(&mockTileRepo{}, nil)
+
+	for _, tc := range [][3]int{{-1, 0, 0}, {2, 4, 0}, {2, 0, -1}, {21, 0, 0}} {
+		if _, err := svc.MVT(context.Background(), tc[0], tc[1], tc[2]); !errors.Is(err, usecases.ErrInvalidTile) {
+			t.Errorf("tile %v: expected ErrInvalidTile, got %v", tc, err)
+		}
+	}
+}
+
+func TestTileService_MVTCached(t *testing.T) {
+	repo := &mockTileRepo{}
+	svc := usecases.NewTileService(repo, &mockCache{})
+
+	for i := 0; i < 2; i++ {
+		if _, err := svc.MVT(context.Background(), 14, 8020, 6050); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if repo.mvtCalls != 1 {
+		t.Errorf("expected 1 render, got %d", repo.mvtCalls)
+	}
+	if !repo.withStops {
+		t.Error("expected stops layer at zoom 14")
+	}
+}
+
+func TestTileService_GeoJSON_HidesStopsWhenZoomedOut(t *testing.T) {
+	repo := &mockTileRepo{
+		stopsFn: func(ctx context.Context, z, x, y int) ([]domain.Stop, error) {
+			return []domain.Stop{{ID: "s1", Name: "Abando", Location: domain.GeoPoint{Lat: 43.263, Lon: -2.935}}}, nil
+		},
+		routesFn: func(ctx context.Context, z, x, y int) ([]domain.Route, error) {
+			return []domain.Route{{ID: "r1", Color: "FF0000", Shape: &domain.GeoLineString{
+				Coordinates: []domain.GeoPoint{{Lat: 43.26, Lon: -2.93}, {Lat: 43.27, Lon: -2.92}},
+			}}}, nil
+		},
+	}
+	svc := usecases.NewTileService(repo, nil)
+
+	count := func(z, x, y int) int {
+		data, err := svc.GeoJSON(context.Background(), z, x, y)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var fc struct {
+			Features []json.RawMessage `json:"features"`
+		}
+		if err := json.Unmarshal(data, &fc); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return len(fc.Features)
+	}
+
+	if n := count(10, 504, 377); n != 1 {
+		t.Errorf("expected routes only at zoom 10, got %d features", n)
+	}
+	if n := count(15, 16127, 12106); n != 2 {
+		t.Errorf("expected routes and stops at zoom 15, got %d features", n)
+	}
+}
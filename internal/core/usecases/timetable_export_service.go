@@ -0,0 +1,131 @@
+package usecases
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+// maxExportDays is the most service dates an export covers.
+const maxExportDays = 31
+
+// ErrInvalidExportRange is returned for export dates that do not parse or
+// span too many days.
+var ErrInvalidExportRange = errors.New("from and to must be YYYY-MM-DD dates, from not after to, at most 31 days")
+
+// timetableExportHeader names the export's CSV columns.
+var timetableExportHeader = []string{
+	"service_date", "route_id", "route_short_name", "trip_id", "direction_id",
+	"stop_sequence", "stop_id", "stop_name",
+	"scheduled_arrival", "scheduled_departure", "observed_departure", "delay_secs",
+}
+
+// TimetableExportService exports scheduled against observed timetables for
+// researchers and transit authorities.
+type TimetableExportService struct {
+	repo     ports.TimetableComparisonRepository
+	agencies ports.AgencyRepository
+	store    ports.ObjectStore // nil disables exports
+}
+
+// NewTimetableExportService creates a new TimetableExportService. store may
+// be nil, in which case exports are unavailable.
+func NewTimetableExportService(repo ports.TimetableComparisonRepository, agencies ports.AgencyRepository, store ports.ObjectStore) *TimetableExportService {
+	return &TimetableExportService{repo: repo, agencies: agencies, store: store}
+}
+
+// Export writes the agency's observed trips from from to to (service dates,
+// YYYY-MM-DD; to defaults to from) as gzipped CSV, one row per stop with its
+// scheduled times and observed departure, and uploads it.
+func (s *TimetableExportService) Export(ctx context.Context, agencySlug, from, to string, now time.Time) (*domain.TimetableExport, error) {
+	if to == "" {
+		to = from
+	}
+	start, err1 := time.Parse("2006-01-02", from)
+	end, err2 := time.Parse("2006-01-02", to)
+	if err1 != nil || err2 != nil || end.Before(start) || end.Sub(start) >= maxExportDays*24*time.Hour {
+		return nil, ErrInvalidExportRange
+	}
+	if s.store == nil {
+		return nil, ErrUploadsUnavailable
+	}
+	agency, err := s.agencies.GetBySlug(ctx, agencySlug)
+	if err != nil || agency == nil {
+		return nil, ErrAgencyNotFound
+	}
+	rows, err := s.repo.Compare(ctx, agency.ID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := time.LoadLocation(agency.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	body, trips, err := timetableCSV(rows, loc)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	key := fmt.Sprintf("exports/timetable/%s/%s_%s-%s.csv.gz", agency.Slug, from, to, hex.EncodeToString(sum[:6]))
+	url, err := s.store.Put(ctx, key, "application/gzip", body)
+	if err != nil {
+		return nil, fmt.Errorf("store export: %w", err)
+	}
+	return &domain.TimetableExport{
+		Agency:      agency.Slug,
+		From:        from,
+		To:          to,
+		Trips:       trips,
+		Rows:        len(rows),
+		URL:         url,
+		GeneratedAt: now,
+	}, nil
+}
+
+// timetableCSV writes rows as gzipped CSV with times in loc and counts the
+// trip runs in them.
+func timetableCSV(rows []domain.TimetableComparison, loc *time.Location) ([]byte, int, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	w := csv.NewWriter(gz)
+	_ = w.Write(timetableExportHeader)
+
+	trips := 0
+	var lastDate, lastTrip string
+	for _, r := range rows {
+		if r.ServiceDate != lastDate || r.TripID != lastTrip {
+			trips++
+			lastDate, lastTrip = r.ServiceDate, r.TripID
+		}
+		observed, delay := "", ""
+		if r.Delay != nil {
+			observed = r.ScheduledDeparture.Add(time.Duration(*r.Delay) * time.Second).In(loc).Format(time.RFC3339)
+			delay = strconv.Itoa(*r.Delay)
+		}
+		_ = w.Write([]string{
+			r.ServiceDate, r.RouteID, r.RouteShortName, r.TripID, strconv.Itoa(r.DirectionID),
+			strconv.Itoa(r.StopSequence), r.StopID, r.StopName,
+			r.ScheduledArrival.In(loc).Format(time.RFC3339), r.ScheduledDeparture.In(loc).Format(time.RFC3339),
+			observed, delay,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, 0, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), trips, nil
+}
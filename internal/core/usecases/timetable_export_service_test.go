@@ -0,0 +1,99 @@
+package usecases_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock TimetableComparisonRepository ---
+
+type mockComparisonRepo struct {
+	rows     []domain.TimetableComparison
+	from, to time.Time
+}
+
+func (m *mockComparisonRepo) Compare(ctx context.Context, agencyID string, from, to time.Time) ([]domain.TimetableComparison, error) {
+	m.from, m.to = from, to
+	return m.rows, nil
+}
+
+func TestTimetableExportService_Export(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	sched := time.Date(2026, 3, 2, 23, 50, 0, 0, time.UTC)
+	delay := 120
+	repo := &mockComparisonRepo{rows: []domain.TimetableComparison{
+		{ServiceDate: "2026-03-02", RouteID: "L1", TripID: "T1", StopSequence: 1, StopID: "S1", StopName: "Abando",
+			ScheduledArrival: sched, ScheduledDeparture: sched, Delay: &delay},
+		{ServiceDate: "2026-03-02", RouteID: "L1", TripID: "T1", StopSequence: 2, StopID: "S2", StopName: "Casco Viejo",
+			ScheduledArrival: sched.Add(20 * time.Minute), ScheduledDeparture: sched.Add(20 * time.Minute)},
+		{ServiceDate: "2026-03-03", RouteID: "L1", TripID: "T1", StopSequence: 1, StopID: "S1", StopName: "Abando",
+			ScheduledArrival: sched.Add(24 * time.Hour), ScheduledDeparture: sched.Add(24 * time.Hour)},
+	}}
+	agencies := &mockAgencyRepo{getBySlugFn: func(ctx context.Context, slug string) (*domain.Agency, error) {
+		if slug != "metro_bilbao" {
+			return nil, errors.New("no rows in result set")
+		}
+		return &domain.Agency{ID: "a1", Slug: slug, Timezone: "UTC"}, nil
+	}}
+	store := &mockObjectStore{}
+	svc := usecases.NewTimetableExportService(repo, agencies, store)
+
+	export, err := svc.Export(ctx, "metro_bilbao", "2026-03-02", "2026-03-03", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if export.Rows != 3 || export.Trips != 2 {
+		t.Errorf("expected 3 rows over 2 trip runs, got %d over %d", export.Rows, export.Trips)
+	}
+	if !strings.HasPrefix(store.keys[0], "exports/timetable/metro_bilbao/2026-03-02_2026-03-03-") || export.URL != "https://cdn.example/"+store.keys[0] {
+		t.Errorf("unexpected key %q or URL %q", store.keys[0], export.URL)
+	}
+	if !repo.from.Equal(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)) || !repo.to.Equal(time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected range %v to %v", repo.from, repo.to)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(store.body))
+	if err != nil {
+		t.Fatalf("export is not gzipped: %v", err)
+	}
+	records, err := csv.NewReader(gz).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not CSV: %v", err)
+	}
+	if len(records) != 4 || records[0][0] != "service_date" {
+		t.Fatalf("expected a header and 3 rows, got %v", records)
+	}
+	if got := records[1][10:]; got[0] != "2026-03-02T23:52:00Z" || got[1] != "120" {
+		t.Errorf("expected observed departure 2 minutes late, got %v", got)
+	}
+	if got := records[2][10:]; got[0] != "" || got[1] != "" {
+		t.Errorf("expected an unobserved stop left blank, got %v", got)
+	}
+
+	// to defaults to from.
+	if _, err := svc.Export(ctx, "metro_bilbao", "2026-03-02", "", now); err != nil || !repo.to.Equal(repo.from) {
+		t.Errorf("expected a single day, got %v to %v (%v)", repo.from, repo.to, err)
+	}
+	for _, r := range [][2]string{{"", ""}, {"2026-03-02", "2026-03-01"}, {"2026-03-01", "2026-04-01"}, {"02/03/2026", ""}} {
+		if _, err := svc.Export(ctx, "metro_bilbao", r[0], r[1], now); !errors.Is(err, usecases.ErrInvalidExportRange) {
+			t.Errorf("%v: expected ErrInvalidExportRange, got %v", r, err)
+		}
+	}
+	if _, err := svc.Export(ctx, "nope", "2026-03-02", "", now); !errors.Is(err, usecases.ErrAgencyNotFound) {
+		t.Errorf("expected ErrAgencyNotFound, got %v", err)
+	}
+	noStore := usecases.NewTimetableExportService(repo, agencies, nil)
+	if _, err := noStore.Export(ctx, "metro_bilbao", "2026-03-02", "", now); !errors.Is(err, usecases.ErrUploadsUnavailable) {
+		t.Errorf("expected ErrUploadsUnavailable, got %v", err)
+	}
+}
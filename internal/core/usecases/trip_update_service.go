@@ -0,0 +1,79 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+// TripUpdateService stores the stop time predictions of realtime trip
+// updates.
+type TripUpdateService struct {
+	trips       ports.FeedIDResolver
+	predictions ports.TripUpdateRepository
+}
+
+// NewTripUpdateService creates a new TripUpdateService.
+func NewTripUpdateService(trips ports.FeedIDResolver, predictions ports.TripUpdateRepository) *TripUpdateService {
+	return &TripUpdateService{trips: trips, predictions: predictions}
+}
+
+// RecordPredictions stores one poll's stop time updates as predictions.
+// Each is resolved against the static schedule to the first stop of its
+// trip matching its stop ID and sequence, whichever it gives; updates of
+// trips or stops the schedule does not know are dropped. It returns how
+// many were stored.
+func (s *TripUpdateService) RecordPredictions(ctx context.Context, agencyID string, updates []domain.StopTimeUpdate) (int, error) {
+	if len(updates) == 0 {
+		return 0, nil
+	}
+	var tripIDs []string
+	seen := map[string]bool{}
+	for _, u := range updates {
+		if !seen[u.TripID] {
+			seen[u.TripID] = true
+			tripIDs = append(tripIDs, u.TripID)
+		}
+	}
+	stops, err := s.trips.TripStops(ctx, agencyID, tripIDs)
+	if err != nil {
+		return 0, fmt.Errorf("resolve trips: %w", err)
+	}
+
+	var predictions []domain.StopTimePrediction
+	for _, u := range updates {
+		stop, ok := matchTripStop(stops[u.TripID], &u)
+		if !ok {
+			continue
+		}
+		predictions = append(predictions, domain.StopTimePrediction{
+			Time:                 u.Time,
+			TripID:               stop.TripID,
+			StopID:               stop.StopID,
+			StopSequence:         stop.StopSequence,
+			ArrivalDelay:         u.ArrivalDelay,
+			DepartureDelay:       u.DepartureDelay,
+			PredictedArrival:     u.PredictedArrival,
+			PredictedDeparture:   u.PredictedDeparture,
+			ScheduleRelationship: u.ScheduleRelationship,
+		})
+	}
+	if err := s.predictions.InsertBatch(ctx, predictions); err != nil {
+		return 0, fmt.Errorf("insert predictions: %w", err)
+	}
+	return len(predictions), nil
+}
+
+// matchTripStop returns the first of a trip's stops, in sequence, with the
+// update's stop ID and sequence.
+func matchTripStop(stops []domain.TripStop, u *domain.StopTimeUpdate) (domain.TripStop, bool) {
+	for _, st := range stops {
+		if (u.StopID == "" || st.GTFSStopID == u.StopID) &&
+			(u.StopSequence == nil || st.StopSequence == *u.StopSequence) {
+			return st, true
+		}
+	}
+	return domain.TripStop{}, false
+}
@@ -0,0 +1,106 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock FeedIDResolver ---
+
+type mockFeedIDResolver struct {
+	tripStops map[string][]domain.TripStop
+	routes    map[string]string
+	stops     map[string]string
+	asked     [][]string // trip IDs of each TripStops
+	err       error
+}
+
+func (m *mockFeedIDResolver) TripStops(ctx context.Context, agencyID string, gtfsTripIDs []string) (map[string][]domain.TripStop, error) {
+	m.asked = append(m.asked, gtfsTripIDs)
+	if m.err != nil {
+		return nil, m.err
+	}
+	stops := map[string][]domain.TripStop{}
+	for _, id := range gtfsTripIDs {
+		if s, ok := m.tripStops[id]; ok {
+			stops[id] = s
+		}
+	}
+	return stops, nil
+}
+
+func (m *mockFeedIDResolver) RouteIDs(ctx context.Context, agencyID string, gtfsRouteIDs []string) (map[string]string, error) {
+	return m.routes, m.err
+}
+
+func (m *mockFeedIDResolver) StopIDs(ctx context.Context, agencyID string, gtfsStopIDs []string) (map[string]string, error) {
+	return m.stops, m.err
+}
+
+// A loop trip: stop S1 is both its first and last stop.
+var loopTrip = map[string][]domain.TripStop{
+	"T1": {
+		{TripID: "trip-1", StopID: "stop-1", GTFSStopID: "S1", StopSequence: 1},
+		{TripID: "trip-1", StopID: "stop-2", GTFSStopID: "S2", StopSequence: 2},
+		{TripID: "trip-1", StopID: "stop-1", GTFSStopID: "S1", StopSequence: 3},
+	},
+}
+
+func TestTripUpdateService_RecordPredictions(t *testing.T) {
+	at := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	seq := func(n int) *int { return &n }
+	delay := 120
+
+	trips := &mockFeedIDResolver{tripStops: loopTrip}
+	preds := &mockTripUpdateRepo{}
+	svc := usecases.NewTripUpdateService(trips, preds)
+
+	n, err := svc.RecordPredictions(context.Background(), "a1", []domain.StopTimeUpdate{
+		{Time: at, TripID: "T1", StopID: "S1", ArrivalDelay: &delay},    // first S1
+		{Time: at, TripID: "T1", StopID: "S1", StopSequence: seq(3)},    // last S1
+		{Time: at, TripID: "T1", StopSequence: seq(2)},                  // by sequence only
+		{Time: at, TripID: "T1", StopID: "S9"},                          // unknown stop
+		{Time: at, TripID: "T1", StopID: "S2", StopSequence: seq(3)},    // S2 is not third
+		{Time: at, TripID: "T2", StopID: "S1", ScheduleRelationship: 1}, // unknown trip
+	})
+	if err != nil || n != 3 {
+		t.Fatalf("expected 3 predictions stored, got %d, %v", n, err)
+	}
+	if len(trips.asked) != 1 || !reflect.DeepEqual(trips.asked[0], []string{"T1", "T2"}) {
+		t.Errorf("expected each trip resolved once in one call, got %v", trips.asked)
+	}
+	want := []domain.StopTimePrediction{
+		{Time: at, TripID: "trip-1", StopID: "stop-1", StopSequence: 1, ArrivalDelay: &delay},
+		{Time: at, TripID: "trip-1", StopID: "stop-1", StopSequence: 3},
+		{Time: at, TripID: "trip-1", StopID: "stop-2", StopSequence: 2},
+	}
+	if !reflect.DeepEqual(preds.inserted, want) {
+		t.Errorf("expected\n  %+v\ngot\n  %+v", want, preds.inserted)
+	}
+}
+
+func TestTripUpdateService_RecordPredictions_Errors(t *testing.T) {
+	updates := []domain.StopTimeUpdate{{TripID: "T1", StopID: "S1"}}
+
+	svc := usecases.NewTripUpdateService(&mockFeedIDResolver{err: errors.New("db down")}, &mockTripUpdateRepo{})
+	if _, err := svc.RecordPredictions(context.Background(), "a1", updates); err == nil {
+		t.Error("expected the resolver's error")
+	}
+
+	svc = usecases.NewTripUpdateService(&mockFeedIDResolver{tripStops: loopTrip}, &mockTripUpdateRepo{err: errors.New("db down")})
+	if n, err := svc.RecordPredictions(context.Background(), "a1", updates); err == nil || n != 0 {
+		t.Errorf("expected the insert's error and nothing stored, got %d, %v", n, err)
+	}
+
+	// Nothing to store asks nothing.
+	trips := &mockFeedIDResolver{}
+	if n, err := usecases.NewTripUpdateService(trips, &mockTripUpdateRepo{}).RecordPredictions(context.Background(), "a1", nil); err != nil || n != 0 || len(trips.asked) != 0 {
+		t.Errorf("expected no lookups for no updates, got %d, %v, %v", n, err, trips.asked)
+	}
+}
@@ -0,0 +1,135 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+const (
+	defaultWorkflowRuns = 50
+	maxWorkflowRuns     = 200
+	// defaultWorkflowDays and maxWorkflowDays bound how far back runs are
+	// listed from.
+	defaultWorkflowDays = 7
+	maxWorkflowDays     = 90
+)
+
+var (
+	// ErrWorkflowsUnavailable is returned when no Temporal is configured.
+	ErrWorkflowsUnavailable = errors.New("workflow history is not configured")
+	// ErrInvalidWorkflowFilter is returned for unknown kinds and statuses,
+	// and malformed IDs.
+	ErrInvalidWorkflowFilter = errors.New("invalid workflow filter")
+)
+
+var workflowStatuses = map[string]bool{
+	"running": true, "completed": true, "failed": true, "canceled": true,
+	"terminated": true, "continued_as_new": true, "timed_out": true,
+}
+
+// WorkflowService lets operators follow the compensation, feed ingest and
+// scheduled job workflows without access to the Temporal UI.
+type WorkflowService struct {
+	history ports.WorkflowHistory // nil when Temporal is not configured
+}
+
+// NewWorkflowService creates a new WorkflowService. history may be nil, in
+// which case runs are unavailable.
+func NewWorkflowService(history ports.WorkflowHistory) *WorkflowService {
+	return &WorkflowService{history: history}
+}
+
+// Runs returns the runs matching f started in the last days days
+// (defaultWorkflowDays when 0), newest first. Filtering by user or delay
+// event lists compensations, and by agency, ingests.
+func (s *WorkflowService) Runs(ctx context.Context, f domain.WorkflowFilter, days int, now time.Time) ([]domain.WorkflowRun, error) {
+	if s.history == nil {
+		return nil, ErrWorkflowsUnavailable
+	}
+	if days == 0 {
+		days = defaultWorkflowDays
+	}
+	if f.Limit == 0 {
+		f.Limit = defaultWorkflowRuns
+	}
+	switch {
+	case days < 1 || days > maxWorkflowDays:
+		return nil, fmt.Errorf("%w: days must be between 1 and %d", ErrInvalidWorkflowFilter, maxWorkflowDays)
+	case f.Limit < 1 || f.Limit > maxWorkflowRuns:
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidWorkflowFilter, maxWorkflowRuns)
+	case f.Status != "" && !workflowStatuses[f.Status]:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidWorkflowFilter, f.Status)
+	case f.UserID != "" && !validUUID(f.UserID), f.DelayEventID != "" && !validUUID(f.DelayEventID):
+		return nil, fmt.Errorf("%w: user_id and delay_event_id must be UUIDs", ErrInvalidWorkflowFilter)
+	case f.Agency != "" && !validSlug(f.Agency):
+		return nil, fmt.Errorf("%w: invalid agency %q", ErrInvalidWorkflowFilter, f.Agency)
+	}
+
+	kind := f.Kind
+	if f.UserID != "" || f.DelayEventID != "" {
+		kind = domain.WorkflowCompensation
+	}
+	if f.Agency != "" {
+		if kind == domain.WorkflowCompensation {
+			return nil, fmt.Errorf("%w: agency cannot be combined with user_id or delay_event_id", ErrInvalidWorkflowFilter)
+		}
+		kind = domain.WorkflowIngest
+	}
+	switch {
+	case f.Kind != "" && f.Kind != kind:
+		return nil, fmt.Errorf("%w: filters do not apply to %s workflows", ErrInvalidWorkflowFilter, f.Kind)
+	case kind != "" && kind != domain.WorkflowCompensation && kind != domain.WorkflowIngest && kind != domain.WorkflowJob:
+		return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidWorkflowFilter, kind)
+	}
+	f.Kind = kind
+	f.Since = now.AddDate(0, 0, -days)
+
+	runs, err := s.history.ListRuns(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	if runs == nil {
+		runs = []domain.WorkflowRun{}
+	}
+	return runs, nil
+}
+
+// validUUID reports whether id is a UUID in its canonical form.
+func validUUID(id string) bool {
+	if len(id) != 36 {
+		return false
+	}
+	for i, r := range id {
+		switch i {
+		case 8, 13, 18, 23:
+			if r != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// validSlug reports whether slug is an agency slug: lowercase letters,
+// digits and underscores.
+func validSlug(slug string) bool {
+	if slug == "" || len(slug) > 64 {
+		return false
+	}
+	for _, r := range slug {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
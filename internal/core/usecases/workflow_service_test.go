@@ -0,0 +1,78 @@
+package usecases_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// --- Mock WorkflowHistory ---
+
+type mockWorkflowHistory struct {
+	runs   []domain.WorkflowRun
+	filter *domain.WorkflowFilter
+}
+
+func (m *mockWorkflowHistory) ListRuns(ctx context.Context, f domain.WorkflowFilter) ([]domain.WorkflowRun, error) {
+	m.filter = &f
+	return m.runs, nil
+}
+
+func TestWorkflowService_Runs(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	const user = "6f1c2d3e-4a5b-4c6d-8e7f-0a1b2c3d4e5f"
+	history := &mockWorkflowHistory{}
+	svc := usecases.NewWorkflowService(history)
+	ctx := context.Background()
+
+	runs, err := svc.Runs(ctx, domain.WorkflowFilter{}, 0, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if runs == nil {
+		t.Error("expected an empty list, got nil")
+	}
+	if f := history.filter; f.Limit != 50 || !f.Since.Equal(now.AddDate(0, 0, -7)) || f.Kind != "" {
+		t.Errorf("expected 50 runs of any kind from the last 7 days, got %+v", f)
+	}
+
+	if _, err := svc.Runs(ctx, domain.WorkflowFilter{UserID: user, Status: "failed"}, 30, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f := history.filter; f.Kind != domain.WorkflowCompensation || f.UserID != user || !f.Since.Equal(now.AddDate(0, 0, -30)) {
+		t.Errorf("expected a user's compensations from the last 30 days, got %+v", f)
+	}
+
+	if _, err := svc.Runs(ctx, domain.WorkflowFilter{Agency: "metro_bilbao"}, 0, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if history.filter.Kind != domain.WorkflowIngest {
+		t.Errorf("expected an agency's ingests, got %+v", history.filter)
+	}
+
+	for name, f := range map[string]domain.WorkflowFilter{
+		"unknown kind":        {Kind: "backup"},
+		"unknown status":      {Status: "Running"},
+		"malformed user":      {UserID: "6f1c2d3e' OR 1=1"},
+		"malformed agency":    {Agency: "metro-bilbao'"},
+		"user of an ingest":   {Kind: domain.WorkflowIngest, UserID: user},
+		"agency and user":     {Agency: "metro_bilbao", UserID: user},
+		"too many runs":       {Limit: 201},
+		"agency of a job run": {Kind: domain.WorkflowJob, Agency: "bilbobus"},
+	} {
+		if _, err := svc.Runs(ctx, f, 0, now); !errors.Is(err, usecases.ErrInvalidWorkflowFilter) {
+			t.Errorf("%s: expected ErrInvalidWorkflowFilter, got %v", name, err)
+		}
+	}
+	if _, err := svc.Runs(ctx, domain.WorkflowFilter{}, 91, now); !errors.Is(err, usecases.ErrInvalidWorkflowFilter) {
+		t.Errorf("expected ErrInvalidWorkflowFilter for 91 days, got %v", err)
+	}
+
+	if _, err := usecases.NewWorkflowService(nil).Runs(ctx, domain.WorkflowFilter{}, 0, now); !errors.Is(err, usecases.ErrWorkflowsUnavailable) {
+		t.Errorf("expected ErrWorkflowsUnavailable without Temporal, got %v", err)
+	}
+}
@@ -1782,7 +1782,7 @@ const file_gtfs_realtime_proto_rawDesc = "" +
 	"\vtranslation\x18\x01 \x03(\v2..transit_realtime.TranslatedString.TranslationR\vtranslation\x1a=\n" +
 	"\vTranslation\x12\x12\n" +
 	"\x04text\x18\x01 \x02(\tR\x04text\x12\x1a\n" +
-	"\blanguage\x18\x02 \x01(\tR\blanguageB3Z1github.com/samirrijal/bilbopass/internal/gtfsrt"
+	"\blanguage\x18\x02 \x01(\tR\blanguageB1Z/github.com/samirrijal/bilbopass/internal/gtfsrt"
 
 var (
 	file_gtfs_realtime_proto_rawDescOnce sync.Once
@@ -0,0 +1,91 @@
+// Package auth issues and verifies the HS256 JSON Web Tokens used to
+// authenticate riders.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Token errors.
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token expired")
+)
+
+// issuer is the iss claim of every token.
+const issuer = "bilbopass"
+
+// header is the fixed, pre-encoded JWT header.
+var header = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Claims are the registered claims carried by a token.
+type Claims struct {
+	Subject   string `json:"sub"` // user ID
+	Role      string `json:"role,omitempty"`
+	Issuer    string `json:"iss"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// JWT signs and verifies tokens with a shared secret.
+type JWT struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewJWT creates a JWT whose tokens are valid for ttl.
+func NewJWT(secret string, ttl time.Duration) *JWT {
+	return &JWT{secret: []byte(secret), ttl: ttl}
+}
+
+// Issue returns a signed token for the user and when it expires.
+func (j *JWT) Issue(userID, role string, now time.Time) (string, time.Time, error) {
+	expires := now.Add(j.ttl)
+	payload, err := json.Marshal(Claims{
+		Subject:   userID,
+		Role:      role,
+		Issuer:    issuer,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expires.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + j.sign(signed), expires, nil
+}
+
+// Verify checks a token's signature, issuer and expiry and returns its claims.
+func (j *JWT) Verify(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != header {
+		return nil, ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(j.sign(parts[0]+"."+parts[1]))) {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Subject == "" || claims.Issuer != issuer {
+		return nil, ErrInvalidToken
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+	return &claims, nil
+}
+
+func (j *JWT) sign(s string) string {
+	mac := hmac.New(sha256.New, j.secret)
+	mac.Write([]byte(s))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -4,16 +4,31 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/nats-io/nats.go"
 	"github.com/spf13/viper"
 )
 
 // Config holds all application configuration.
 type Config struct {
-	Server    ServerConfig    `mapstructure:"server"`
-	Database  DatabaseConfig  `mapstructure:"database"`
-	NATS      NATSConfig      `mapstructure:"nats"`
-	Valkey    ValkeyConfig    `mapstructure:"valkey"`
-	Telemetry TelemetryConfig `mapstructure:"telemetry"`
+	Server      ServerConfig      `mapstructure:"server"`
+	Database    DatabaseConfig    `mapstructure:"database"`
+	NATS        NATSConfig        `mapstructure:"nats"`
+	Valkey      ValkeyConfig      `mapstructure:"valkey"`
+	Telemetry   TelemetryConfig   `mapstructure:"telemetry"`
+	ShortLinks  ShortLinksConfig  `mapstructure:"shortlinks"`
+	Admin       AdminConfig       `mapstructure:"admin"`
+	Temporal    TemporalConfig    `mapstructure:"temporal"`
+	Auth        AuthConfig        `mapstructure:"auth"`
+	Push        PushConfig        `mapstructure:"push"`
+	Taxi        TaxiConfig        `mapstructure:"taxi"`
+	Storage     StorageConfig     `mapstructure:"storage"`
+	Realtime    RealtimeConfig    `mapstructure:"realtime"`
+	Streets     StreetsConfig     `mapstructure:"streets"`
+	Geocoder    GeocoderConfig    `mapstructure:"geocoder"`
+	Compression CompressionConfig `mapstructure:"compression"`
+	CDN         CDNConfig         `mapstructure:"cdn"`
+	GraphQL     GraphQLConfig     `mapstructure:"graphql"`
+	Timescale   TimescaleConfig   `mapstructure:"timescale"`
 }
 
 type ServerConfig struct {
@@ -38,8 +53,76 @@ func (d DatabaseConfig) DSN() string {
 	)
 }
 
+// NATSConfig points at the NATS server. Services authenticate with
+// NATSAuth, anonymously when it is unset; Relay, when set, is the account
+// the API relays realtime subjects to WebSocket and SSE clients with,
+// which may be read-only.
 type NATSConfig struct {
-	URL string `mapstructure:"url"`
+	URL      string `mapstructure:"url"`
+	NATSAuth `mapstructure:",squash"`
+	Relay    NATSAuth `mapstructure:"relay"`
+}
+
+// NATSAuth authenticates a NATS connection by one of: a user and password,
+// a token, a credentials file (user JWT and nkey seed, as nsc writes it) or
+// an nkey seed file.
+type NATSAuth struct {
+	User         string `mapstructure:"user"`
+	Password     string `mapstructure:"password"`
+	Token        string `mapstructure:"token"`
+	CredsFile    string `mapstructure:"creds_file"`
+	NKeySeedFile string `mapstructure:"nkey_seed_file"`
+}
+
+// IsSet reports whether any credentials are configured.
+func (a NATSAuth) IsSet() bool {
+	return a != NATSAuth{}
+}
+
+// Options returns the connection options of the credentials.
+func (a NATSAuth) Options() ([]nats.Option, error) {
+	switch {
+	case a.User != "":
+		return []nats.Option{nats.UserInfo(a.User, a.Password)}, nil
+	case a.Token != "":
+		return []nats.Option{nats.Token(a.Token)}, nil
+	case a.CredsFile != "":
+		return []nats.Option{nats.UserCredentials(a.CredsFile)}, nil
+	case a.NKeySeedFile != "":
+		opt, err := nats.NkeyOptionFromSeed(a.NKeySeedFile)
+		if err != nil {
+			return nil, fmt.Errorf("nkey seed: %w", err)
+		}
+		return []nats.Option{opt}, nil
+	}
+	return nil, nil
+}
+
+// RelayOptions returns the connection options of the relay account,
+// which is the services' own when Relay is unset.
+func (n NATSConfig) RelayOptions() ([]nats.Option, error) {
+	if n.Relay.IsSet() {
+		return n.Relay.Options()
+	}
+	return n.Options()
+}
+
+// validate reports the errors of credentials configured under prefix.
+func (a NATSAuth) validate(prefix string) []string {
+	var errs []string
+	methods := 0
+	for _, set := range []bool{a.User != "", a.Token != "", a.CredsFile != "", a.NKeySeedFile != ""} {
+		if set {
+			methods++
+		}
+	}
+	if methods > 1 {
+		errs = append(errs, fmt.Sprintf("only one of %[1]suser, %[1]stoken, %[1]screds_file and %[1]snkey_seed_file may be set", prefix))
+	}
+	if a.Password != "" && a.User == "" {
+		errs = append(errs, prefix+"password requires "+prefix+"user")
+	}
+	return errs
 }
 
 type ValkeyConfig struct {
@@ -52,6 +135,115 @@ type TelemetryConfig struct {
 	Enabled     bool   `mapstructure:"enabled"`
 }
 
+type ShortLinksConfig struct {
+	BaseURL  string `mapstructure:"base_url"`  // public host encoded in stop QR codes
+	BoardURL string `mapstructure:"board_url"` // redirect target; {stop_id} is substituted
+}
+
+type AdminConfig struct {
+	Token string `mapstructure:"token"` // bearer token for /v1/admin; empty disables admin routes
+}
+
+type AuthConfig struct {
+	JWTSecret     string `mapstructure:"jwt_secret"`      // HS256 signing key for rider tokens
+	TokenTTLHours int    `mapstructure:"token_ttl_hours"` // lifetime of issued tokens
+}
+
+type PushConfig struct {
+	FCMCredentialsFile string `mapstructure:"fcm_credentials_file"` // Firebase service account key; empty disables FCM
+	VAPIDPublicKey     string `mapstructure:"vapid_public_key"`     // base64url Web Push key pair; empty disables Web Push
+	VAPIDPrivateKey    string `mapstructure:"vapid_private_key"`
+	VAPIDSubject       string `mapstructure:"vapid_subject"` // mailto: or https: contact sent to push services
+}
+
+// StorageConfig points at the S3-compatible bucket that holds uploaded
+// agency branding assets.
+type StorageConfig struct {
+	Endpoint  string `mapstructure:"endpoint"` // e.g. https://s3.eu-south-2.amazonaws.com; empty disables uploads
+	Bucket    string `mapstructure:"bucket"`
+	Region    string `mapstructure:"region"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	PublicURL string `mapstructure:"public_url"` // CDN or public bucket URL; defaults to endpoint/bucket
+}
+
+// RealtimeConfig tunes GTFS-RT feed health reporting.
+type RealtimeConfig struct {
+	StaleAfterMinutes int `mapstructure:"stale_after_minutes"` // feed data older than this is stale
+}
+
+// TaxiConfig prices the taxi estimates offered when no transit journey exists.
+type TaxiConfig struct {
+	DayBaseFare     float64 `mapstructure:"day_base_fare"` // EUR
+	DayPerKm        float64 `mapstructure:"day_per_km"`    // 0 disables estimates
+	NightBaseFare   float64 `mapstructure:"night_base_fare"`
+	NightPerKm      float64 `mapstructure:"night_per_km"`
+	MinimumFare     float64 `mapstructure:"minimum_fare"`
+	NightStartHour  int     `mapstructure:"night_start_hour"` // night tariff from this hour...
+	NightEndHour    int     `mapstructure:"night_end_hour"`   // ...until this one, local time
+	AverageSpeedKmh float64 `mapstructure:"average_speed_kmh"`
+}
+
+// StreetsConfig points at the OSRM servers walking and cycling journeys are
+// routed with. Without one, they are estimated from straight-line distance.
+type StreetsConfig struct {
+	WalkURL string `mapstructure:"walk_url"` // OSRM server with the foot profile
+	BikeURL string `mapstructure:"bike_url"` // OSRM server with the bicycle profile
+}
+
+// GeocoderConfig points /v1/geocode at a Nominatim or Photon server.
+type GeocoderConfig struct {
+	Provider          string  `mapstructure:"provider"`            // nominatim or photon
+	URL               string  `mapstructure:"url"`                 // empty disables geocoding
+	UserAgent         string  `mapstructure:"user_agent"`          // identifies BilboPass to the server, as Nominatim requires
+	UpstreamPerSecond float64 `mapstructure:"upstream_per_second"` // requests a second sent to the server
+	PerMinute         int     `mapstructure:"per_minute"`          // lookups a minute allowed per client IP
+	CacheHours        int     `mapstructure:"cache_hours"`
+}
+
+// CompressionConfig tunes API response compression. Clients accepting
+// brotli get it when it is on, others gzip.
+type CompressionConfig struct {
+	Brotli      bool `mapstructure:"brotli"`
+	BrotliLevel int  `mapstructure:"brotli_level"` // 0-11
+	GzipLevel   int  `mapstructure:"gzip_level"`   // 1-9
+	MinBytes    int  `mapstructure:"min_bytes"`    // smaller responses are sent as they are
+}
+
+// CDNConfig points purges of changed stops, routes and agencies at the CDN
+// in front of the API.
+type CDNConfig struct {
+	Provider string `mapstructure:"provider"`  // fastly or varnish
+	PurgeURL string `mapstructure:"purge_url"` // empty disables purging
+	Token    string `mapstructure:"token"`
+}
+
+// GraphQLConfig bounds what GraphQL operations may cost. Fields that look
+// data up cost 1, dearer ones more, and lists multiply the cost of their
+// items by their limit.
+type GraphQLConfig struct {
+	MaxDepth      int `mapstructure:"max_depth"`      // deepest field nesting allowed
+	MaxCost       int `mapstructure:"max_cost"`       // costliest operation allowed
+	Budget        int `mapstructure:"budget"`         // cost a client IP may spend a window
+	BudgetSeconds int `mapstructure:"budget_seconds"` // length of the budget window
+}
+
+// TimescaleConfig sets the policies `migrate policies` gives the
+// vehicle_positions hypertable and its hourly vehicle counts.
+type TimescaleConfig struct {
+	CompressAfterDays   int `mapstructure:"compress_after_days"`   // positions older are compressed
+	RetainDays          int `mapstructure:"retain_days"`           // positions older are dropped
+	AggregateRetainDays int `mapstructure:"aggregate_retain_days"` // of vehicle_counts_hourly
+}
+
+type TemporalConfig struct {
+	HostPort  string `mapstructure:"host_port"`
+	TaskQueue string `mapstructure:"task_queue"` // workflows, run by the compensator
+	// IngestTaskQueue is the queue of the feed ingest activities, run by
+	// `ingestor worker`.
+	IngestTaskQueue string `mapstructure:"ingest_task_queue"`
+}
+
 // Load reads configuration from file and environment variables.
 func Load(service string) (*Config, error) {
 	v := viper.New()
@@ -67,10 +259,66 @@ func Load(service string) (*Config, error) {
 	v.SetDefault("database.dbname", "bilbopass")
 	v.SetDefault("database.sslmode", "disable")
 	v.SetDefault("nats.url", "nats://localhost:4222")
+	for _, prefix := range []string{"nats.", "nats.relay."} {
+		v.SetDefault(prefix+"user", "")
+		v.SetDefault(prefix+"password", "")
+		v.SetDefault(prefix+"token", "")
+		v.SetDefault(prefix+"creds_file", "")
+		v.SetDefault(prefix+"nkey_seed_file", "")
+	}
 	v.SetDefault("valkey.addr", "localhost:6379")
 	v.SetDefault("telemetry.service_name", service)
 	v.SetDefault("telemetry.tempo_addr", "tempo:4317")
 	v.SetDefault("telemetry.enabled", true)
+	v.SetDefault("shortlinks.base_url", "http://localhost:8080")
+	v.SetDefault("shortlinks.board_url", "/v1/stops/{stop_id}/departures")
+	v.SetDefault("admin.token", "")
+	v.SetDefault("auth.jwt_secret", "")
+	v.SetDefault("auth.token_ttl_hours", 24*7)
+	v.SetDefault("push.fcm_credentials_file", "")
+	v.SetDefault("push.vapid_public_key", "")
+	v.SetDefault("push.vapid_private_key", "")
+	v.SetDefault("push.vapid_subject", "")
+	v.SetDefault("taxi.day_base_fare", 2.95)
+	v.SetDefault("taxi.day_per_km", 1.20)
+	v.SetDefault("taxi.night_base_fare", 3.85)
+	v.SetDefault("taxi.night_per_km", 1.45)
+	v.SetDefault("taxi.minimum_fare", 5.0)
+	v.SetDefault("taxi.night_start_hour", 22)
+	v.SetDefault("taxi.night_end_hour", 6)
+	v.SetDefault("taxi.average_speed_kmh", 30.0)
+	v.SetDefault("storage.endpoint", "")
+	v.SetDefault("storage.bucket", "")
+	v.SetDefault("storage.region", "")
+	v.SetDefault("storage.access_key", "")
+	v.SetDefault("storage.secret_key", "")
+	v.SetDefault("storage.public_url", "")
+	v.SetDefault("realtime.stale_after_minutes", 5)
+	v.SetDefault("streets.walk_url", "")
+	v.SetDefault("streets.bike_url", "")
+	v.SetDefault("geocoder.provider", "nominatim")
+	v.SetDefault("geocoder.url", "")
+	v.SetDefault("geocoder.user_agent", "BilboPass/1.0 (+https://bilbopass.eus)")
+	v.SetDefault("geocoder.upstream_per_second", 1.0)
+	v.SetDefault("geocoder.per_minute", 30)
+	v.SetDefault("geocoder.cache_hours", 24*7)
+	v.SetDefault("compression.brotli", true)
+	v.SetDefault("compression.brotli_level", 4)
+	v.SetDefault("compression.gzip_level", 1)
+	v.SetDefault("compression.min_bytes", 1024)
+	v.SetDefault("cdn.provider", "fastly")
+	v.SetDefault("cdn.purge_url", "")
+	v.SetDefault("cdn.token", "")
+	v.SetDefault("graphql.max_depth", 10)
+	v.SetDefault("graphql.max_cost", 1000)
+	v.SetDefault("graphql.budget", 20000)
+	v.SetDefault("graphql.budget_seconds", 60)
+	v.SetDefault("timescale.compress_after_days", 7)
+	v.SetDefault("timescale.retain_days", 90)
+	v.SetDefault("timescale.aggregate_retain_days", 400)
+	v.SetDefault("temporal.host_port", "localhost:7233")
+	v.SetDefault("temporal.task_queue", "compensation-queue")
+	v.SetDefault("temporal.ingest_task_queue", "ingest-queue")
 
 	// Config file (optional)
 	v.SetConfigName("config")
@@ -118,6 +366,8 @@ func (c *Config) Validate() error {
 	if c.NATS.URL == "" {
 		errs = append(errs, "nats.url is required")
 	}
+	errs = append(errs, c.NATS.NATSAuth.validate("nats.")...)
+	errs = append(errs, c.NATS.Relay.validate("nats.relay.")...)
 	if c.Valkey.Addr == "" {
 		errs = append(errs, "valkey.addr is required")
 	}
@@ -127,6 +377,46 @@ func (c *Config) Validate() error {
 	if c.Server.WriteTimeout <= 0 {
 		errs = append(errs, "server.write_timeout must be positive")
 	}
+	if c.Auth.TokenTTLHours <= 0 {
+		errs = append(errs, "auth.token_ttl_hours must be positive")
+	}
+	if c.Realtime.StaleAfterMinutes <= 0 {
+		errs = append(errs, "realtime.stale_after_minutes must be positive")
+	}
+	if c.Geocoder.PerMinute <= 0 {
+		errs = append(errs, "geocoder.per_minute must be positive")
+	}
+	if c.Geocoder.CacheHours <= 0 {
+		errs = append(errs, "geocoder.cache_hours must be positive")
+	}
+	if c.Compression.BrotliLevel < 0 || c.Compression.BrotliLevel > 11 {
+		errs = append(errs, fmt.Sprintf("compression.brotli_level must be 0-11, got %d", c.Compression.BrotliLevel))
+	}
+	if c.Compression.GzipLevel < 1 || c.Compression.GzipLevel > 9 {
+		errs = append(errs, fmt.Sprintf("compression.gzip_level must be 1-9, got %d", c.Compression.GzipLevel))
+	}
+	if c.Compression.MinBytes < 0 {
+		errs = append(errs, "compression.min_bytes must not be negative")
+	}
+	if c.GraphQL.MaxDepth <= 0 || c.GraphQL.MaxCost <= 0 || c.GraphQL.Budget <= 0 || c.GraphQL.BudgetSeconds <= 0 {
+		errs = append(errs, "graphql.max_depth, max_cost, budget and budget_seconds must be positive")
+	}
+	if c.GraphQL.Budget < c.GraphQL.MaxCost {
+		errs = append(errs, fmt.Sprintf("graphql.budget (%d) must be at least graphql.max_cost (%d)", c.GraphQL.Budget, c.GraphQL.MaxCost))
+	}
+	if c.CDN.PurgeURL != "" && c.CDN.Provider != "fastly" && c.CDN.Provider != "varnish" {
+		errs = append(errs, fmt.Sprintf("cdn.provider must be fastly or varnish, got %q", c.CDN.Provider))
+	}
+	// Vehicle histories go back 7 days
+	if c.Timescale.RetainDays < 7 {
+		errs = append(errs, fmt.Sprintf("timescale.retain_days must be at least 7, got %d", c.Timescale.RetainDays))
+	}
+	if c.Timescale.CompressAfterDays <= 0 || c.Timescale.CompressAfterDays >= c.Timescale.RetainDays {
+		errs = append(errs, fmt.Sprintf("timescale.compress_after_days must be positive and under timescale.retain_days, got %d", c.Timescale.CompressAfterDays))
+	}
+	if c.Timescale.AggregateRetainDays <= 0 {
+		errs = append(errs, "timescale.aggregate_retain_days must be positive")
+	}
 
 	if len(errs) > 0 {
 		return fmt.Errorf("config validation failed:\n  - %s", strings.Join(errs, "\n  - "))
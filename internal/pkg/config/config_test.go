@@ -0,0 +1,179 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+)
+
+// natsOptions applies connection options as nats.Connect would.
+func natsOptions(t *testing.T, opts []nats.Option) nats.Options {
+	t.Helper()
+	var o nats.Options
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return o
+}
+
+func TestNATSAuthOptions(t *testing.T) {
+	user, err := nkeys.CreateUser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed, _ := user.Seed()
+	pub, _ := user.PublicKey()
+	dir := t.TempDir()
+	seedFile := filepath.Join(dir, "user.nk")
+	if err := os.WriteFile(seedFile, seed, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// A credentials file as nsc writes it; the JWT is only read here.
+	const userJWT = "eyJhbGciOiJlZDI1NTE5LW5rZXkifQ.e30.c2ln"
+	credsFile := filepath.Join(dir, "user.creds")
+	creds := "-----BEGIN NATS USER JWT-----\n" + userJWT + "\n------END NATS USER JWT------\n\n" +
+		"-----BEGIN USER NKEY SEED-----\n" + string(seed) + "\n------END USER NKEY SEED------\n"
+	if err := os.WriteFile(credsFile, []byte(creds), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		auth  NATSAuth
+		check func(nats.Options) bool
+	}{
+		{
+			name:  "anonymous",
+			check: func(o nats.Options) bool { return o.User == "" && o.Token == "" && o.UserJWT == nil && o.Nkey == "" },
+		},
+		{
+			name:  "user and password",
+			auth:  NATSAuth{User: "realtime", Password: "secret"},
+			check: func(o nats.Options) bool { return o.User == "realtime" && o.Password == "secret" && o.Token == "" },
+		},
+		{
+			name:  "token",
+			auth:  NATSAuth{Token: "s3cr3t"},
+			check: func(o nats.Options) bool { return o.Token == "s3cr3t" && o.User == "" },
+		},
+		{
+			name: "credentials file",
+			auth: NATSAuth{CredsFile: credsFile},
+			check: func(o nats.Options) bool {
+				jwt, err := o.UserJWT()
+				return err == nil && jwt == userJWT && o.SignatureCB != nil && o.Token == ""
+			},
+		},
+		{
+			name:  "nkey seed file",
+			auth:  NATSAuth{NKeySeedFile: seedFile},
+			check: func(o nats.Options) bool { return o.Nkey == pub && o.SignatureCB != nil },
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts, err := tc.auth.Options()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if o := natsOptions(t, opts); !tc.check(o) {
+				t.Errorf("unexpected options for %+v: %+v", tc.auth, o)
+			}
+		})
+	}
+
+	if _, err := (NATSAuth{NKeySeedFile: filepath.Join(t.TempDir(), "missing.nk")}).Options(); err == nil ||
+		!strings.HasPrefix(err.Error(), "nkey seed: ") {
+		t.Errorf("expected a missing seed file reported, got %v", err)
+	}
+}
+
+func TestNATSConfigRelayOptions(t *testing.T) {
+	services := NATSAuth{User: "realtime", Password: "secret"}
+
+	// Without a relay account, the services' own is used.
+	opts, err := NATSConfig{NATSAuth: services}.RelayOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := natsOptions(t, opts); o.User != "realtime" || o.Password != "secret" {
+		t.Errorf("expected the services' credentials, got %+v", o)
+	}
+
+	opts, err = NATSConfig{NATSAuth: services, Relay: NATSAuth{Token: "read-only"}}.RelayOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := natsOptions(t, opts); o.Token != "read-only" || o.User != "" {
+		t.Errorf("expected the relay's credentials only, got %+v", o)
+	}
+}
+
+func TestNATSAuthValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		auth   NATSAuth
+		prefix string
+		want   []string
+	}{
+		{name: "anonymous", prefix: "nats."},
+		{name: "user and password", auth: NATSAuth{User: "realtime", Password: "secret"}, prefix: "nats."},
+		{name: "user without password", auth: NATSAuth{User: "realtime"}, prefix: "nats."},
+		{name: "credentials file", auth: NATSAuth{CredsFile: "realtime.creds"}, prefix: "nats.relay."},
+		{
+			name:   "token and user",
+			auth:   NATSAuth{User: "realtime", Token: "s3cr3t"},
+			prefix: "nats.",
+			want:   []string{"only one of nats.user, nats.token, nats.creds_file and nats.nkey_seed_file may be set"},
+		},
+		{
+			name:   "credentials file and nkey seed",
+			auth:   NATSAuth{CredsFile: "relay.creds", NKeySeedFile: "relay.nk"},
+			prefix: "nats.relay.",
+			want:   []string{"only one of nats.relay.user, nats.relay.token, nats.relay.creds_file and nats.relay.nkey_seed_file may be set"},
+		},
+		{
+			name:   "password without user",
+			auth:   NATSAuth{Token: "s3cr3t", Password: "secret"},
+			prefix: "nats.relay.",
+			want:   []string{"nats.relay.password requires nats.relay.user"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.auth.validate(tc.prefix); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLoad_NATSAuth(t *testing.T) {
+	t.Setenv("BILBOPASS_NATS_TOKEN", "s3cr3t")
+	t.Setenv("BILBOPASS_NATS_RELAY_USER", "relay")
+	t.Setenv("BILBOPASS_NATS_RELAY_PASSWORD", "read-only")
+
+	cfg, err := Load("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := NATSConfig{
+		URL:      "nats://localhost:4222",
+		NATSAuth: NATSAuth{Token: "s3cr3t"},
+		Relay:    NATSAuth{User: "relay", Password: "read-only"},
+	}
+	if cfg.NATS != want {
+		t.Errorf("expected %+v, got %+v", want, cfg.NATS)
+	}
+
+	// Conflicting methods are reported under the relay's keys.
+	t.Setenv("BILBOPASS_NATS_RELAY_TOKEN", "s3cr3t")
+	if _, err := Load("test"); err == nil || !strings.Contains(err.Error(), "only one of nats.relay.user") {
+		t.Errorf("expected the relay's conflict reported, got %v", err)
+	}
+}
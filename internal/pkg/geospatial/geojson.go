@@ -0,0 +1,54 @@
+package geospatial
+
+import "github.com/samirrijal/bilbopass/internal/core/domain"
+
+// FeatureCollection is a GeoJSON FeatureCollection (RFC 7946).
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON Feature.
+type Feature struct {
+	Type       string         `json:"type"`
+	ID         string         `json:"id,omitempty"`
+	Geometry   Geometry       `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+// Geometry is a GeoJSON geometry. Coordinates are [lon, lat] ordered.
+type Geometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// NewFeatureCollection wraps features in a FeatureCollection.
+func NewFeatureCollection(features ...Feature) FeatureCollection {
+	if features == nil {
+		features = []Feature{}
+	}
+	return FeatureCollection{Type: "FeatureCollection", Features: features}
+}
+
+// PointGeometry converts a GeoPoint to a GeoJSON Point.
+func PointGeometry(p domain.GeoPoint) Geometry {
+	return Geometry{Type: "Point", Coordinates: []float64{p.Lon, p.Lat}}
+}
+
+// LineStringGeometry converts a GeoLineString to a GeoJSON LineString.
+func LineStringGeometry(ls *domain.GeoLineString) Geometry {
+	coords := make([][]float64, 0, len(ls.Coordinates))
+	for _, p := range ls.Coordinates {
+		coords = append(coords, []float64{p.Lon, p.Lat})
+	}
+	return Geometry{Type: "LineString", Coordinates: coords}
+}
+
+// PolygonGeometry converts a closed ring to a GeoJSON Polygon without holes.
+func PolygonGeometry(ring []domain.GeoPoint) Geometry {
+	coords := make([][]float64, 0, len(ring))
+	for _, p := range ring {
+		coords = append(coords, []float64{p.Lon, p.Lat})
+	}
+	return Geometry{Type: "Polygon", Coordinates: [][][]float64{coords}}
+}
@@ -0,0 +1,199 @@
+package geospatial
+
+import (
+	"math"
+	"sort"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// ConcaveHull returns a concave hull of the points as a closed,
+// counter-clockwise ring, or nil with fewer than three distinct points. It
+// walks the boundary turning towards one of the k nearest points at each
+// step (Moreira and Santos, 2007), with more neighbours when that fails to
+// enclose every point, and the convex hull as a last resort. Lower k follows
+// the points more closely. Coordinates are treated as planar, which holds
+// over a city.
+func ConcaveHull(points []domain.GeoPoint, k int) []domain.GeoPoint {
+	pts := distinct(points)
+	if len(pts) < 3 {
+		return nil
+	}
+	for k = max(k, 3); k < len(pts); k++ {
+		if hull := nearestHull(pts, k); hull != nil {
+			return hull
+		}
+	}
+	return ConvexHull(pts)
+}
+
+// ConvexHull returns the convex hull of the points as a closed,
+// counter-clockwise ring, or nil with fewer than three distinct points.
+func ConvexHull(points []domain.GeoPoint) []domain.GeoPoint {
+	pts := distinct(points)
+	if len(pts) < 3 {
+		return nil
+	}
+	sort.Slice(pts, func(a, b int) bool {
+		if pts[a].Lon != pts[b].Lon {
+			return pts[a].Lon < pts[b].Lon
+		}
+		return pts[a].Lat < pts[b].Lat
+	})
+	// Andrew's monotone chain: the lower hull, then the upper one
+	hull := make([]domain.GeoPoint, 0, 2*len(pts))
+	for pass := 0; pass < 2; pass++ {
+		floor := len(hull)
+		for _, p := range pts {
+			for len(hull) >= floor+2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+				hull = hull[:len(hull)-1]
+			}
+			hull = append(hull, p)
+		}
+		hull = hull[:len(hull)-1]
+		for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+			pts[i], pts[j] = pts[j], pts[i]
+		}
+	}
+	if len(hull) < 3 {
+		return nil // all on a line
+	}
+	return append(hull, hull[0])
+}
+
+// nearestHull builds the hull considering the k nearest points at each step,
+// or returns nil when that leaves points out or crosses itself.
+func nearestHull(pts []domain.GeoPoint, k int) []domain.GeoPoint {
+	first := 0
+	for i, p := range pts {
+		if p.Lat < pts[first].Lat || (p.Lat == pts[first].Lat && p.Lon < pts[first].Lon) {
+			first = i
+		}
+	}
+	used := make([]bool, len(pts))
+	used[first] = true
+	hull := []domain.GeoPoint{pts[first]}
+	current := first
+	dir := [2]float64{1, 0} // heading east, from the lowest point
+
+	for len(hull) <= len(pts) {
+		if len(hull) == 4 {
+			used[first] = false // the ring may close now
+		}
+		candidates := nearest(pts, used, current, k)
+		if len(candidates) == 0 {
+			return nil
+		}
+		// Turn as far right as possible, keeping the points on the left
+		sort.Slice(candidates, func(a, b int) bool {
+			return turn(dir, pts[current], pts[candidates[a]]) < turn(dir, pts[current], pts[candidates[b]])
+		})
+		next := -1
+		for _, c := range candidates {
+			if !crossesHull(hull, pts[current], pts[c], c == first) {
+				next = c
+				break
+			}
+		}
+		if next < 0 {
+			return nil
+		}
+		dir = [2]float64{pts[next].Lon - pts[current].Lon, pts[next].Lat - pts[current].Lat}
+		hull = append(hull, pts[next])
+		used[next] = true
+		current = next
+		if current == first {
+			break
+		}
+	}
+	if current != first {
+		return nil
+	}
+	for _, p := range pts {
+		if !inRing(hull, p) {
+			return nil
+		}
+	}
+	return hull
+}
+
+// nearest returns up to k unused points nearest to pts[from].
+func nearest(pts []domain.GeoPoint, used []bool, from, k int) []int {
+	var idx []int
+	for i := range pts {
+		if !used[i] && i != from {
+			idx = append(idx, i)
+		}
+	}
+	dist := func(i int) float64 {
+		return math.Hypot(pts[i].Lon-pts[from].Lon, pts[i].Lat-pts[from].Lat)
+	}
+	sort.Slice(idx, func(a, b int) bool { return dist(idx[a]) < dist(idx[b]) })
+	return idx[:min(k, len(idx))]
+}
+
+// turn is the signed angle from heading dir at p to heading for q, negative
+// to the right.
+func turn(dir [2]float64, p, q domain.GeoPoint) float64 {
+	vx, vy := q.Lon-p.Lon, q.Lat-p.Lat
+	return math.Atan2(dir[0]*vy-dir[1]*vx, dir[0]*vx+dir[1]*vy)
+}
+
+// crossesHull reports whether the edge a-b crosses an edge of the open hull
+// other than the last one, which ends at a, and the first one when closing
+// the ring at b.
+func crossesHull(hull []domain.GeoPoint, a, b domain.GeoPoint, closing bool) bool {
+	for i := 0; i+2 < len(hull); i++ {
+		if closing && i == 0 {
+			continue
+		}
+		if segmentsCross(hull[i], hull[i+1], a, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// segmentsCross reports whether segments p1-p2 and q1-q2 properly intersect.
+func segmentsCross(p1, p2, q1, q2 domain.GeoPoint) bool {
+	d1, d2 := cross(q1, q2, p1), cross(q1, q2, p2)
+	d3, d4 := cross(p1, p2, q1), cross(p1, p2, q2)
+	return ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0))
+}
+
+// inRing reports whether p is inside the closed ring or on its boundary.
+func inRing(ring []domain.GeoPoint, p domain.GeoPoint) bool {
+	const eps = 1e-12
+	inside := false
+	for i := 0; i+1 < len(ring); i++ {
+		a, b := ring[i], ring[i+1]
+		if math.Abs(cross(a, b, p)) < eps &&
+			p.Lon >= math.Min(a.Lon, b.Lon)-eps && p.Lon <= math.Max(a.Lon, b.Lon)+eps &&
+			p.Lat >= math.Min(a.Lat, b.Lat)-eps && p.Lat <= math.Max(a.Lat, b.Lat)+eps {
+			return true
+		}
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lon < a.Lon+(p.Lat-a.Lat)*(b.Lon-a.Lon)/(b.Lat-a.Lat) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// cross is the z component of (b-a) x (c-a): positive when c is left of a-b.
+func cross(a, b, c domain.GeoPoint) float64 {
+	return (b.Lon-a.Lon)*(c.Lat-a.Lat) - (b.Lat-a.Lat)*(c.Lon-a.Lon)
+}
+
+// distinct returns the points without duplicates, in their first order.
+func distinct(points []domain.GeoPoint) []domain.GeoPoint {
+	seen := make(map[domain.GeoPoint]bool, len(points))
+	var out []domain.GeoPoint
+	for _, p := range points {
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+// instrumentedCache counts the hits and misses of a cache in CacheHits and
+// CacheMisses.
+type instrumentedCache struct {
+	ports.CacheService
+}
+
+// InstrumentCache counts the cache's hits and misses by operation: the key
+// up to its second colon, as "routes:id" for "routes:id:<version>:<id>".
+// Only caches whose keys start with fixed "<service>:<operation>:" prefixes
+// should be instrumented, or the label would take unbounded values.
+func InstrumentCache(cache ports.CacheService) ports.CacheService {
+	if cache == nil {
+		return nil
+	}
+	return instrumentedCache{cache}
+}
+
+func (c instrumentedCache) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := c.CacheService.Get(ctx, key)
+	if err != nil {
+		CacheMisses.WithLabelValues(cacheOperation(key)).Inc()
+	} else {
+		CacheHits.WithLabelValues(cacheOperation(key)).Inc()
+	}
+	return data, err
+}
+
+// cacheOperation returns the key up to its second colon.
+func cacheOperation(key string) string {
+	service, rest, _ := strings.Cut(key, ":")
+	op, _, _ := strings.Cut(rest, ":")
+	return service + ":" + op
+}
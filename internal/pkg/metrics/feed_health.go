@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/samirrijal/bilbopass/internal/core/ports"
+)
+
+const (
+	// activeVehicleWindow is how recent a position must be for its vehicle
+	// to count as active.
+	activeVehicleWindow = 5 * time.Minute
+	// feedHealthTimeout bounds the database read behind each scrape.
+	feedHealthTimeout = 10 * time.Second
+)
+
+var (
+	staticIngestAgeDesc = prometheus.NewDesc(
+		"bilbopass_feed_static_ingest_age_seconds",
+		"Seconds since the agency's static GTFS feed was last ingested",
+		[]string{"agency"}, nil)
+	realtimeAgeDesc = prometheus.NewDesc(
+		"bilbopass_feed_realtime_age_seconds",
+		"Seconds since the newest feed timestamp of the agency's GTFS-RT feeds",
+		[]string{"agency"}, nil)
+	activeVehiclesDesc = prometheus.NewDesc(
+		"bilbopass_feed_active_vehicles",
+		"Vehicles that reported a position in the last 5 minutes",
+		[]string{"agency"}, nil)
+	unresolvedIDsDesc = prometheus.NewDesc(
+		"bilbopass_feed_unresolved_rt_ids",
+		"GTFS-RT trip or stop IDs not in the static feed, on the latest day trip updates were polled",
+		[]string{"agency", "kind"}, nil)
+)
+
+// FeedHealthCollector exports the health of every agency's feeds, read from
+// the database on each scrape, so dashboards and alert rules need not call
+// the REST API. Ages are omitted for agencies that never had the feed.
+type FeedHealthCollector struct {
+	repo ports.FeedHealthRepository
+	now  func() time.Time
+}
+
+// NewFeedHealthCollector creates a collector; register it with
+// prometheus.MustRegister.
+func NewFeedHealthCollector(repo ports.FeedHealthRepository) *FeedHealthCollector {
+	return &FeedHealthCollector{repo: repo, now: time.Now}
+}
+
+// Describe implements prometheus.Collector.
+func (c *FeedHealthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- staticIngestAgeDesc
+	ch <- realtimeAgeDesc
+	ch <- activeVehiclesDesc
+	ch <- unresolvedIDsDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *FeedHealthCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), feedHealthTimeout)
+	defer cancel()
+
+	now := c.now()
+	feeds, err := c.repo.FeedHealth(ctx, now.Add(-activeVehicleWindow))
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(activeVehiclesDesc, err)
+		return
+	}
+	for _, f := range feeds {
+		if f.LastStaticIngest != nil {
+			ch <- prometheus.MustNewConstMetric(staticIngestAgeDesc, prometheus.GaugeValue,
+				now.Sub(*f.LastStaticIngest).Seconds(), f.AgencySlug)
+		}
+		if f.LastRealtime != nil {
+			ch <- prometheus.MustNewConstMetric(realtimeAgeDesc, prometheus.GaugeValue,
+				now.Sub(*f.LastRealtime).Seconds(), f.AgencySlug)
+		}
+		ch <- prometheus.MustNewConstMetric(activeVehiclesDesc, prometheus.GaugeValue,
+			float64(f.ActiveVehicles), f.AgencySlug)
+		ch <- prometheus.MustNewConstMetric(unresolvedIDsDesc, prometheus.GaugeValue,
+			float64(f.UnresolvedTripIDs), f.AgencySlug, "trip")
+		ch <- prometheus.MustNewConstMetric(unresolvedIDsDesc, prometheus.GaugeValue,
+			float64(f.UnresolvedStopIDs), f.AgencySlug, "stop")
+	}
+}
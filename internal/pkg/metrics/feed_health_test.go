@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+type mockFeedHealthRepo struct {
+	feeds       []domain.FeedHealth
+	err         error
+	activeSince time.Time
+}
+
+func (m *mockFeedHealthRepo) FeedHealth(ctx context.Context, activeSince time.Time) ([]domain.FeedHealth, error) {
+	m.activeSince = activeSince
+	return m.feeds, m.err
+}
+
+func TestFeedHealthCollector(t *testing.T) {
+	now := time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)
+	ingested := now.Add(-26 * time.Hour)
+	polled := now.Add(-45 * time.Second)
+	repo := &mockFeedHealthRepo{feeds: []domain.FeedHealth{
+		{AgencySlug: "metro_bilbao", LastStaticIngest: &ingested, LastRealtime: &polled,
+			ActiveVehicles: 12, UnresolvedTripIDs: 3, UnresolvedStopIDs: 1},
+		{AgencySlug: "bizkaibus"}, // never ingested, no GTFS-RT feeds
+	}}
+	c := NewFeedHealthCollector(repo)
+	c.now = func() time.Time { return now }
+
+	expected := `
+# HELP bilbopass_feed_active_vehicles Vehicles that reported a position in the last 5 minutes
+# TYPE bilbopass_feed_active_vehicles gauge
+bilbopass_feed_active_vehicles{agency="bizkaibus"} 0
+bilbopass_feed_active_vehicles{agency="metro_bilbao"} 12
+# HELP bilbopass_feed_realtime_age_seconds Seconds since the newest feed timestamp of the agency's GTFS-RT feeds
+# TYPE bilbopass_feed_realtime_age_seconds gauge
+bilbopass_feed_realtime_age_seconds{agency="metro_bilbao"} 45
+# HELP bilbopass_feed_static_ingest_age_seconds Seconds since the agency's static GTFS feed was last ingested
+# TYPE bilbopass_feed_static_ingest_age_seconds gauge
+bilbopass_feed_static_ingest_age_seconds{agency="metro_bilbao"} 93600
+# HELP bilbopass_feed_unresolved_rt_ids GTFS-RT trip or stop IDs not in the static feed, on the latest day trip updates were polled
+# TYPE bilbopass_feed_unresolved_rt_ids gauge
+bilbopass_feed_unresolved_rt_ids{agency="bizkaibus",kind="stop"} 0
+bilbopass_feed_unresolved_rt_ids{agency="bizkaibus",kind="trip"} 0
+bilbopass_feed_unresolved_rt_ids{agency="metro_bilbao",kind="stop"} 1
+bilbopass_feed_unresolved_rt_ids{agency="metro_bilbao",kind="trip"} 3
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+	if !repo.activeSince.Equal(now.Add(-activeVehicleWindow)) {
+		t.Errorf("expected vehicles active since %s, got %s", now.Add(-activeVehicleWindow), repo.activeSince)
+	}
+}
+
+func TestFeedHealthCollector_Error(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewFeedHealthCollector(&mockFeedHealthRepo{err: errors.New("db down")}))
+
+	// A failed read fails the scrape rather than reporting zeros.
+	if _, err := reg.Gather(); err == nil || !strings.Contains(err.Error(), "db down") {
+		t.Errorf("expected the scrape to fail with the database error, got %v", err)
+	}
+}
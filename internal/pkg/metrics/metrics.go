@@ -36,6 +36,21 @@ var (
 		Buckets:   prometheus.ExponentialBuckets(100, 10, 6),
 	}, []string{"method", "path"})
 
+	compressionRatio = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "bilbopass",
+		Subsystem: "http",
+		Name:      "compression_ratio",
+		Help:      "Compressed to uncompressed size of compressed responses",
+		Buckets:   prometheus.LinearBuckets(0.1, 0.1, 10),
+	}, []string{"encoding", "path"})
+
+	compressionSavedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bilbopass",
+		Subsystem: "http",
+		Name:      "compression_saved_bytes_total",
+		Help:      "Total response bytes saved by compression",
+	}, []string{"encoding", "path"})
+
 	// Transit-specific metrics
 	VehiclePositionsIngested = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "bilbopass",
@@ -73,6 +88,29 @@ var (
 		Help:      "Current number of active WebSocket connections",
 	})
 
+	// WSMessagesSent and WSMessagesDropped are labelled with the NATS
+	// subject up to its third token, e.g. transit.vehicle.<agency>.
+	WSMessagesSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bilbopass",
+		Subsystem: "ws",
+		Name:      "messages_sent_total",
+		Help:      "Total NATS messages relayed to WebSocket clients",
+	}, []string{"subject"})
+
+	WSMessagesDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bilbopass",
+		Subsystem: "ws",
+		Name:      "messages_dropped_total",
+		Help:      "Total NATS messages dropped because a WebSocket client's send queue was full",
+	}, []string{"subject"})
+
+	WSSlowDisconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "bilbopass",
+		Subsystem: "ws",
+		Name:      "slow_client_disconnects_total",
+		Help:      "Total WebSocket clients disconnected for not keeping up, by write_timeout or pong_timeout",
+	}, []string{"reason"})
+
 	CacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "bilbopass",
 		Subsystem: "cache",
@@ -162,12 +200,25 @@ func Middleware() fiber.Handler {
 
 		httpRequestsTotal.WithLabelValues(method, path, status).Inc()
 		httpRequestDuration.WithLabelValues(method, path).Observe(duration)
-		httpResponseSize.WithLabelValues(method, path).Observe(float64(len(c.Response().Body())))
+		// Streamed responses, still being written, have no size yet.
+		if !c.Response().IsBodyStream() {
+			httpResponseSize.WithLabelValues(method, path).Observe(float64(len(c.Response().Body())))
+		}
 
 		return err
 	}
 }
 
+// ObserveCompression records a response to the route path compressed with
+// encoding from size to compressed bytes.
+func ObserveCompression(encoding, path string, size, compressed int) {
+	if size <= 0 {
+		return
+	}
+	compressionRatio.WithLabelValues(encoding, path).Observe(float64(compressed) / float64(size))
+	compressionSavedBytes.WithLabelValues(encoding, path).Add(float64(size - compressed))
+}
+
 // Handler returns a Fiber handler serving Prometheus /metrics endpoint.
 func Handler() fiber.Handler {
 	handler := promhttp.Handler()
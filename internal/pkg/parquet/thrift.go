@@ -0,0 +1,86 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Thrift compact protocol type codes.
+const (
+	tI32    = 5
+	tI64    = 6
+	tBinary = 8
+	tList   = 9
+	tStruct = 12
+)
+
+// compact writes the Thrift compact protocol Parquet metadata is encoded
+// in. Fields must be written in increasing ID order within a struct.
+type compact struct {
+	buf   bytes.Buffer
+	last  int16   // ID of the last field written in the current struct
+	stack []int16 // last field IDs of the enclosing structs
+}
+
+func (c *compact) field(typ byte, id int16) {
+	if delta := id - c.last; delta > 0 && delta <= 15 {
+		c.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		c.buf.WriteByte(typ)
+		c.varint(zigzag(int64(id)))
+	}
+	c.last = id
+}
+
+func (c *compact) varint(v uint64) {
+	c.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func zigzag(v int64) uint64 { return uint64(v<<1) ^ uint64(v>>63) }
+
+func (c *compact) i32(id int16, v int32) {
+	c.field(tI32, id)
+	c.varint(zigzag(int64(v)))
+}
+
+func (c *compact) i64(id int16, v int64) {
+	c.field(tI64, id)
+	c.varint(zigzag(v))
+}
+
+func (c *compact) binary(id int16, s string) {
+	c.field(tBinary, id)
+	c.str(s)
+}
+
+func (c *compact) str(s string) {
+	c.varint(uint64(len(s)))
+	c.buf.WriteString(s)
+}
+
+// begin starts a struct, as field id or, with id 0, as a list element.
+func (c *compact) begin(id int16) {
+	if id != 0 {
+		c.field(tStruct, id)
+	}
+	c.stack = append(c.stack, c.last)
+	c.last = 0
+}
+
+// end writes the stop field of the current struct.
+func (c *compact) end() {
+	c.buf.WriteByte(0)
+	c.last = c.stack[len(c.stack)-1]
+	c.stack = c.stack[:len(c.stack)-1]
+}
+
+// list starts a list field of size elements of type elem, which follow.
+func (c *compact) list(id int16, elem byte, size int) {
+	c.field(tList, id)
+	if size < 15 {
+		c.buf.WriteByte(byte(size)<<4 | elem)
+	} else {
+		c.buf.WriteByte(0xF0 | elem)
+		c.varint(uint64(size))
+	}
+}
@@ -0,0 +1,368 @@
+// Package parquet writes flat Apache Parquet files, as read by DuckDB, Spark
+// and pandas: required or optional columns of primitive types, PLAIN
+// encoded and gzip compressed, with one data page per column chunk.
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Type is a column's value type.
+type Type int
+
+const (
+	Int32     Type = iota // int or int32
+	Int64                 // int or int64
+	Double                // float64
+	String                // string, UTF-8
+	Bool                  // bool
+	Timestamp             // time.Time, stored as UTC microseconds
+)
+
+// Physical types, converted types, encodings and codecs of the format.
+const (
+	physBoolean   = 0
+	physInt32     = 1
+	physInt64     = 2
+	physDouble    = 5
+	physByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMicros = 10
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecGzip = 2
+)
+
+// RowGroupSize is how many rows a row group holds; the writer keeps one row
+// group's values in memory.
+const RowGroupSize = 100_000
+
+var magic = []byte("PAR1")
+
+// Column describes a column. Optional columns accept nil values.
+type Column struct {
+	Name     string
+	Type     Type
+	Optional bool
+}
+
+func (c Column) physical() int32 {
+	switch c.Type {
+	case Int32:
+		return physInt32
+	case Int64, Timestamp:
+		return physInt64
+	case Double:
+		return physDouble
+	case String:
+		return physByteArray
+	default:
+		return physBoolean
+	}
+}
+
+// columnBuffer holds a column's values in the current row group.
+type columnBuffer struct {
+	values bytes.Buffer // PLAIN encoded, except booleans
+	bools  []bool
+	levels []byte // definition levels of an optional column: 0 null, 1 set
+}
+
+type chunkMeta struct {
+	offset       int64
+	uncompressed int64
+	compressed   int64
+}
+
+type rowGroup struct {
+	rows   int64
+	chunks []chunkMeta
+}
+
+// Writer writes rows to a Parquet file. Close writes the footer; the file is
+// not readable before.
+type Writer struct {
+	w       io.Writer
+	columns []Column
+	buffers []columnBuffer
+	rows    int // in the current row group
+	offset  int64
+	groups  []rowGroup
+}
+
+// NewWriter creates a Writer of rows of columns to w.
+func NewWriter(w io.Writer, columns []Column) *Writer {
+	return &Writer{w: w, columns: columns, buffers: make([]columnBuffer, len(columns))}
+}
+
+// Write appends a row, with a value per column in order.
+func (w *Writer) Write(values ...any) error {
+	if len(values) != len(w.columns) {
+		return fmt.Errorf("parquet: %d values for %d columns", len(values), len(w.columns))
+	}
+	for i, v := range values {
+		if err := w.buffers[i].append(w.columns[i], v); err != nil {
+			return err
+		}
+	}
+	w.rows++
+	if w.rows == RowGroupSize {
+		return w.flush()
+	}
+	return nil
+}
+
+func (b *columnBuffer) append(col Column, v any) error {
+	if v == nil {
+		if !col.Optional {
+			return fmt.Errorf("parquet: column %s is required", col.Name)
+		}
+		b.levels = append(b.levels, 0)
+		return nil
+	}
+	ok := true
+	switch col.Type {
+	case Int32:
+		switch n := v.(type) {
+		case int:
+			b.values.Write(binary.LittleEndian.AppendUint32(nil, uint32(int32(n))))
+		case int32:
+			b.values.Write(binary.LittleEndian.AppendUint32(nil, uint32(n)))
+		default:
+			ok = false
+		}
+	case Int64:
+		switch n := v.(type) {
+		case int:
+			b.values.Write(binary.LittleEndian.AppendUint64(nil, uint64(n)))
+		case int64:
+			b.values.Write(binary.LittleEndian.AppendUint64(nil, uint64(n)))
+		default:
+			ok = false
+		}
+	case Double:
+		f, isFloat := v.(float64)
+		b.values.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(f)))
+		ok = isFloat
+	case String:
+		s, isString := v.(string)
+		b.values.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(s))))
+		b.values.WriteString(s)
+		ok = isString
+	case Bool:
+		t, isBool := v.(bool)
+		b.bools = append(b.bools, t)
+		ok = isBool
+	case Timestamp:
+		t, isTime := v.(time.Time)
+		b.values.Write(binary.LittleEndian.AppendUint64(nil, uint64(t.UnixMicro())))
+		ok = isTime
+	}
+	if !ok {
+		return fmt.Errorf("parquet: column %s: unexpected %T", col.Name, v)
+	}
+	if col.Optional {
+		b.levels = append(b.levels, 1)
+	}
+	return nil
+}
+
+// flush writes the current row group, a data page per column.
+func (w *Writer) flush() error {
+	if w.offset == 0 {
+		if err := w.write(magic); err != nil {
+			return err
+		}
+	}
+	if w.rows == 0 {
+		return nil
+	}
+	group := rowGroup{rows: int64(w.rows)}
+	for i, col := range w.columns {
+		b := &w.buffers[i]
+		var page bytes.Buffer
+		if col.Optional {
+			levels := rleLevels(b.levels)
+			page.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(levels))))
+			page.Write(levels)
+		}
+		if col.Type == Bool {
+			page.Write(packBools(b.bools))
+		}
+		page.Write(b.values.Bytes())
+
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		gz.Write(page.Bytes())
+		if err := gz.Close(); err != nil {
+			return err
+		}
+		header := pageHeader(page.Len(), compressed.Len(), w.rows)
+
+		chunk := chunkMeta{
+			offset:       w.offset,
+			uncompressed: int64(len(header) + page.Len()),
+			compressed:   int64(len(header) + compressed.Len()),
+		}
+		if err := w.write(header); err != nil {
+			return err
+		}
+		if err := w.write(compressed.Bytes()); err != nil {
+			return err
+		}
+		group.chunks = append(group.chunks, chunk)
+		*b = columnBuffer{}
+	}
+	w.groups = append(w.groups, group)
+	w.rows = 0
+	return nil
+}
+
+// Rows returns how many rows have been written.
+func (w *Writer) Rows() int {
+	n := w.rows
+	for _, g := range w.groups {
+		n += int(g.rows)
+	}
+	return n
+}
+
+// Close writes the remaining rows and the footer. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	meta := w.fileMetaData()
+	if err := w.write(meta); err != nil {
+		return err
+	}
+	if err := w.write(binary.LittleEndian.AppendUint32(nil, uint32(len(meta)))); err != nil {
+		return err
+	}
+	return w.write(magic)
+}
+
+func (w *Writer) write(p []byte) error {
+	n, err := w.w.Write(p)
+	w.offset += int64(n)
+	return err
+}
+
+// pageHeader encodes the PageHeader of a v1 data page.
+func pageHeader(uncompressed, compressed, values int) []byte {
+	var c compact
+	c.i32(1, 0) // DATA_PAGE
+	c.i32(2, int32(uncompressed))
+	c.i32(3, int32(compressed))
+	c.begin(5)
+	c.i32(1, int32(values))
+	c.i32(2, encodingPlain)
+	c.i32(3, encodingRLE) // definition levels
+	c.i32(4, encodingRLE) // repetition levels
+	c.end()
+	c.buf.WriteByte(0)
+	return c.buf.Bytes()
+}
+
+// fileMetaData encodes the FileMetaData footer.
+func (w *Writer) fileMetaData() []byte {
+	var rows int64
+	for _, g := range w.groups {
+		rows += g.rows
+	}
+
+	var c compact
+	c.i32(1, 1) // version
+	c.list(2, tStruct, len(w.columns)+1)
+	c.begin(0)
+	c.binary(4, "schema")
+	c.i32(5, int32(len(w.columns)))
+	c.end()
+	for _, col := range w.columns {
+		c.begin(0)
+		c.i32(1, col.physical())
+		repetition := int32(0) // REQUIRED
+		if col.Optional {
+			repetition = 1 // OPTIONAL
+		}
+		c.i32(3, repetition)
+		c.binary(4, col.Name)
+		switch col.Type {
+		case String:
+			c.i32(6, convertedUTF8)
+		case Timestamp:
+			c.i32(6, convertedTimestampMicros)
+		}
+		c.end()
+	}
+	c.i64(3, rows)
+
+	c.list(4, tStruct, len(w.groups))
+	for _, g := range w.groups {
+		c.begin(0)
+		c.list(1, tStruct, len(g.chunks))
+		var size int64
+		for i, ch := range g.chunks {
+			col := w.columns[i]
+			size += ch.uncompressed
+			c.begin(0)
+			c.i64(2, ch.offset)
+			c.begin(3)
+			c.i32(1, col.physical())
+			c.list(2, tI32, 2)
+			c.varint(zigzag(encodingPlain))
+			c.varint(zigzag(encodingRLE))
+			c.list(3, tBinary, 1)
+			c.str(col.Name)
+			c.i32(4, codecGzip)
+			c.i64(5, g.rows)
+			c.i64(6, ch.uncompressed)
+			c.i64(7, ch.compressed)
+			c.i64(9, ch.offset)
+			c.end()
+			c.end()
+		}
+		c.i64(2, size)
+		c.i64(3, g.rows)
+		c.end()
+	}
+	c.binary(6, "bilbopass")
+	c.buf.WriteByte(0)
+	return c.buf.Bytes()
+}
+
+// rleLevels encodes definition levels of bit width 1 as RLE runs.
+func rleLevels(levels []byte) []byte {
+	var out []byte
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		out = append(out, levels[i])
+		i = j
+	}
+	return out
+}
+
+// packBools PLAIN encodes booleans, a bit each, least significant first.
+func packBools(bools []bool) []byte {
+	out := make([]byte, (len(bools)+7)/8)
+	for i, t := range bools {
+		if t {
+			out[i/8] |= 1 << (i % 8)
+		}
+	}
+	return out
+}
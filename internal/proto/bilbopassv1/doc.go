@@ -1,3 +1,4 @@
-// Package bilbopassv1 will contain generated Go code from transit.proto.
-// Run `buf generate` or `protoc` to generate the code.
+// Package bilbopassv1 contains the Go code generated from transit.proto,
+// the schema of the gRPC API and of /ws binary frames. Run `buf generate`
+// in internal/proto after changing it.
 package bilbopassv1
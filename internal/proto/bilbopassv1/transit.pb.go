@@ -0,0 +1,921 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: transit.proto
+
+package bilbopassv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Severity int32
+
+const (
+	Severity_SEVERITY_UNKNOWN Severity = 0
+	Severity_SEVERITY_INFO    Severity = 1
+	Severity_SEVERITY_WARNING Severity = 2
+	Severity_SEVERITY_SEVERE  Severity = 3
+)
+
+// Enum value maps for Severity.
+var (
+	Severity_name = map[int32]string{
+		0: "SEVERITY_UNKNOWN",
+		1: "SEVERITY_INFO",
+		2: "SEVERITY_WARNING",
+		3: "SEVERITY_SEVERE",
+	}
+	Severity_value = map[string]int32{
+		"SEVERITY_UNKNOWN": 0,
+		"SEVERITY_INFO":    1,
+		"SEVERITY_WARNING": 2,
+		"SEVERITY_SEVERE":  3,
+	}
+)
+
+func (x Severity) Enum() *Severity {
+	p := new(Severity)
+	*p = x
+	return p
+}
+
+func (x Severity) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Severity) Descriptor() protoreflect.EnumDescriptor {
+	return file_transit_proto_enumTypes[0].Descriptor()
+}
+
+func (Severity) Type() protoreflect.EnumType {
+	return &file_transit_proto_enumTypes[0]
+}
+
+func (x Severity) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Severity.Descriptor instead.
+func (Severity) EnumDescriptor() ([]byte, []int) {
+	return file_transit_proto_rawDescGZIP(), []int{0}
+}
+
+// Vehicle position from GTFS-RT feed. Also sent, one per binary frame,
+// to /ws clients that negotiate the bilbopass.v1.protobuf subprotocol.
+type VehiclePosition struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	VehicleId       string                 `protobuf:"bytes,1,opt,name=vehicle_id,json=vehicleId,proto3" json:"vehicle_id,omitempty"`
+	TripId          string                 `protobuf:"bytes,2,opt,name=trip_id,json=tripId,proto3" json:"trip_id,omitempty"`
+	RouteId         string                 `protobuf:"bytes,3,opt,name=route_id,json=routeId,proto3" json:"route_id,omitempty"`
+	Position        *Position              `protobuf:"bytes,4,opt,name=position,proto3" json:"position,omitempty"`
+	Bearing         float32                `protobuf:"fixed32,5,opt,name=bearing,proto3" json:"bearing,omitempty"`
+	Speed           float32                `protobuf:"fixed32,6,opt,name=speed,proto3" json:"speed,omitempty"`
+	CongestionLevel int32                  `protobuf:"varint,7,opt,name=congestion_level,json=congestionLevel,proto3" json:"congestion_level,omitempty"`
+	OccupancyStatus *int32                 `protobuf:"varint,8,opt,name=occupancy_status,json=occupancyStatus,proto3,oneof" json:"occupancy_status,omitempty"` // unset when the feed does not report it
+	Timestamp       *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Agency          string                 `protobuf:"bytes,10,opt,name=agency,proto3" json:"agency,omitempty"` // agency slug
+	StopId          string                 `protobuf:"bytes,11,opt,name=stop_id,json=stopId,proto3" json:"stop_id,omitempty"`
+	StopSequence    *int32                 `protobuf:"varint,12,opt,name=stop_sequence,json=stopSequence,proto3,oneof" json:"stop_sequence,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *VehiclePosition) Reset() {
+	*x = VehiclePosition{}
+	mi := &file_transit_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VehiclePosition) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VehiclePosition) ProtoMessage() {}
+
+func (x *VehiclePosition) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VehiclePosition.ProtoReflect.Descriptor instead.
+func (*VehiclePosition) Descriptor() ([]byte, []int) {
+	return file_transit_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *VehiclePosition) GetVehicleId() string {
+	if x != nil {
+		return x.VehicleId
+	}
+	return ""
+}
+
+func (x *VehiclePosition) GetTripId() string {
+	if x != nil {
+		return x.TripId
+	}
+	return ""
+}
+
+func (x *VehiclePosition) GetRouteId() string {
+	if x != nil {
+		return x.RouteId
+	}
+	return ""
+}
+
+func (x *VehiclePosition) GetPosition() *Position {
+	if x != nil {
+		return x.Position
+	}
+	return nil
+}
+
+func (x *VehiclePosition) GetBearing() float32 {
+	if x != nil {
+		return x.Bearing
+	}
+	return 0
+}
+
+func (x *VehiclePosition) GetSpeed() float32 {
+	if x != nil {
+		return x.Speed
+	}
+	return 0
+}
+
+func (x *VehiclePosition) GetCongestionLevel() int32 {
+	if x != nil {
+		return x.CongestionLevel
+	}
+	return 0
+}
+
+func (x *VehiclePosition) GetOccupancyStatus() int32 {
+	if x != nil && x.OccupancyStatus != nil {
+		return *x.OccupancyStatus
+	}
+	return 0
+}
+
+func (x *VehiclePosition) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *VehiclePosition) GetAgency() string {
+	if x != nil {
+		return x.Agency
+	}
+	return ""
+}
+
+func (x *VehiclePosition) GetStopId() string {
+	if x != nil {
+		return x.StopId
+	}
+	return ""
+}
+
+func (x *VehiclePosition) GetStopSequence() int32 {
+	if x != nil && x.StopSequence != nil {
+		return *x.StopSequence
+	}
+	return 0
+}
+
+type Position struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Latitude      float64                `protobuf:"fixed64,1,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude     float64                `protobuf:"fixed64,2,opt,name=longitude,proto3" json:"longitude,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Position) Reset() {
+	*x = Position{}
+	mi := &file_transit_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Position) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Position) ProtoMessage() {}
+
+func (x *Position) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Position.ProtoReflect.Descriptor instead.
+func (*Position) Descriptor() ([]byte, []int) {
+	return file_transit_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Position) GetLatitude() float64 {
+	if x != nil {
+		return x.Latitude
+	}
+	return 0
+}
+
+func (x *Position) GetLongitude() float64 {
+	if x != nil {
+		return x.Longitude
+	}
+	return 0
+}
+
+// Trip update from GTFS-RT feed.
+type TripUpdate struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	TripId          string                 `protobuf:"bytes,1,opt,name=trip_id,json=tripId,proto3" json:"trip_id,omitempty"`
+	RouteId         string                 `protobuf:"bytes,2,opt,name=route_id,json=routeId,proto3" json:"route_id,omitempty"`
+	StopTimeUpdates []*StopTimeUpdate      `protobuf:"bytes,3,rep,name=stop_time_updates,json=stopTimeUpdates,proto3" json:"stop_time_updates,omitempty"`
+	Timestamp       *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *TripUpdate) Reset() {
+	*x = TripUpdate{}
+	mi := &file_transit_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TripUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TripUpdate) ProtoMessage() {}
+
+func (x *TripUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TripUpdate.ProtoReflect.Descriptor instead.
+func (*TripUpdate) Descriptor() ([]byte, []int) {
+	return file_transit_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *TripUpdate) GetTripId() string {
+	if x != nil {
+		return x.TripId
+	}
+	return ""
+}
+
+func (x *TripUpdate) GetRouteId() string {
+	if x != nil {
+		return x.RouteId
+	}
+	return ""
+}
+
+func (x *TripUpdate) GetStopTimeUpdates() []*StopTimeUpdate {
+	if x != nil {
+		return x.StopTimeUpdates
+	}
+	return nil
+}
+
+func (x *TripUpdate) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+type StopTimeUpdate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	StopId        string                 `protobuf:"bytes,1,opt,name=stop_id,json=stopId,proto3" json:"stop_id,omitempty"`
+	StopSequence  int32                  `protobuf:"varint,2,opt,name=stop_sequence,json=stopSequence,proto3" json:"stop_sequence,omitempty"`
+	Arrival       *StopTimeEvent         `protobuf:"bytes,3,opt,name=arrival,proto3" json:"arrival,omitempty"`
+	Departure     *StopTimeEvent         `protobuf:"bytes,4,opt,name=departure,proto3" json:"departure,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StopTimeUpdate) Reset() {
+	*x = StopTimeUpdate{}
+	mi := &file_transit_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StopTimeUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StopTimeUpdate) ProtoMessage() {}
+
+func (x *StopTimeUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StopTimeUpdate.ProtoReflect.Descriptor instead.
+func (*StopTimeUpdate) Descriptor() ([]byte, []int) {
+	return file_transit_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StopTimeUpdate) GetStopId() string {
+	if x != nil {
+		return x.StopId
+	}
+	return ""
+}
+
+func (x *StopTimeUpdate) GetStopSequence() int32 {
+	if x != nil {
+		return x.StopSequence
+	}
+	return 0
+}
+
+func (x *StopTimeUpdate) GetArrival() *StopTimeEvent {
+	if x != nil {
+		return x.Arrival
+	}
+	return nil
+}
+
+func (x *StopTimeUpdate) GetDeparture() *StopTimeEvent {
+	if x != nil {
+		return x.Departure
+	}
+	return nil
+}
+
+type StopTimeEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Delay         int32                  `protobuf:"varint,1,opt,name=delay,proto3" json:"delay,omitempty"` // seconds
+	Time          *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=time,proto3" json:"time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StopTimeEvent) Reset() {
+	*x = StopTimeEvent{}
+	mi := &file_transit_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StopTimeEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StopTimeEvent) ProtoMessage() {}
+
+func (x *StopTimeEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StopTimeEvent.ProtoReflect.Descriptor instead.
+func (*StopTimeEvent) Descriptor() ([]byte, []int) {
+	return file_transit_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *StopTimeEvent) GetDelay() int32 {
+	if x != nil {
+		return x.Delay
+	}
+	return 0
+}
+
+func (x *StopTimeEvent) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+// Service alert from GTFS-RT feed.
+type ServiceAlert struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	AlertId         string                 `protobuf:"bytes,1,opt,name=alert_id,json=alertId,proto3" json:"alert_id,omitempty"`
+	RouteIds        []string               `protobuf:"bytes,2,rep,name=route_ids,json=routeIds,proto3" json:"route_ids,omitempty"`
+	StopIds         []string               `protobuf:"bytes,3,rep,name=stop_ids,json=stopIds,proto3" json:"stop_ids,omitempty"`
+	HeaderText      string                 `protobuf:"bytes,4,opt,name=header_text,json=headerText,proto3" json:"header_text,omitempty"`
+	DescriptionText string                 `protobuf:"bytes,5,opt,name=description_text,json=descriptionText,proto3" json:"description_text,omitempty"`
+	Severity        Severity               `protobuf:"varint,6,opt,name=severity,proto3,enum=bilbopass.v1.Severity" json:"severity,omitempty"`
+	Start           *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=start,proto3" json:"start,omitempty"`
+	End             *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=end,proto3" json:"end,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ServiceAlert) Reset() {
+	*x = ServiceAlert{}
+	mi := &file_transit_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServiceAlert) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServiceAlert) ProtoMessage() {}
+
+func (x *ServiceAlert) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServiceAlert.ProtoReflect.Descriptor instead.
+func (*ServiceAlert) Descriptor() ([]byte, []int) {
+	return file_transit_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ServiceAlert) GetAlertId() string {
+	if x != nil {
+		return x.AlertId
+	}
+	return ""
+}
+
+func (x *ServiceAlert) GetRouteIds() []string {
+	if x != nil {
+		return x.RouteIds
+	}
+	return nil
+}
+
+func (x *ServiceAlert) GetStopIds() []string {
+	if x != nil {
+		return x.StopIds
+	}
+	return nil
+}
+
+func (x *ServiceAlert) GetHeaderText() string {
+	if x != nil {
+		return x.HeaderText
+	}
+	return ""
+}
+
+func (x *ServiceAlert) GetDescriptionText() string {
+	if x != nil {
+		return x.DescriptionText
+	}
+	return ""
+}
+
+func (x *ServiceAlert) GetSeverity() Severity {
+	if x != nil {
+		return x.Severity
+	}
+	return Severity_SEVERITY_UNKNOWN
+}
+
+func (x *ServiceAlert) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *ServiceAlert) GetEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.End
+	}
+	return nil
+}
+
+type GetVehiclePositionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RouteId       string                 `protobuf:"bytes,1,opt,name=route_id,json=routeId,proto3" json:"route_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetVehiclePositionsRequest) Reset() {
+	*x = GetVehiclePositionsRequest{}
+	mi := &file_transit_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetVehiclePositionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetVehiclePositionsRequest) ProtoMessage() {}
+
+func (x *GetVehiclePositionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetVehiclePositionsRequest.ProtoReflect.Descriptor instead.
+func (*GetVehiclePositionsRequest) Descriptor() ([]byte, []int) {
+	return file_transit_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetVehiclePositionsRequest) GetRouteId() string {
+	if x != nil {
+		return x.RouteId
+	}
+	return ""
+}
+
+type GetVehiclePositionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Positions     []*VehiclePosition     `protobuf:"bytes,1,rep,name=positions,proto3" json:"positions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetVehiclePositionsResponse) Reset() {
+	*x = GetVehiclePositionsResponse{}
+	mi := &file_transit_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetVehiclePositionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetVehiclePositionsResponse) ProtoMessage() {}
+
+func (x *GetVehiclePositionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetVehiclePositionsResponse.ProtoReflect.Descriptor instead.
+func (*GetVehiclePositionsResponse) Descriptor() ([]byte, []int) {
+	return file_transit_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetVehiclePositionsResponse) GetPositions() []*VehiclePosition {
+	if x != nil {
+		return x.Positions
+	}
+	return nil
+}
+
+type StreamRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RouteIds      []string               `protobuf:"bytes,1,rep,name=route_ids,json=routeIds,proto3" json:"route_ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamRequest) Reset() {
+	*x = StreamRequest{}
+	mi := &file_transit_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamRequest) ProtoMessage() {}
+
+func (x *StreamRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamRequest.ProtoReflect.Descriptor instead.
+func (*StreamRequest) Descriptor() ([]byte, []int) {
+	return file_transit_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *StreamRequest) GetRouteIds() []string {
+	if x != nil {
+		return x.RouteIds
+	}
+	return nil
+}
+
+type GetTripUpdatesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripId        string                 `protobuf:"bytes,1,opt,name=trip_id,json=tripId,proto3" json:"trip_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTripUpdatesRequest) Reset() {
+	*x = GetTripUpdatesRequest{}
+	mi := &file_transit_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTripUpdatesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTripUpdatesRequest) ProtoMessage() {}
+
+func (x *GetTripUpdatesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTripUpdatesRequest.ProtoReflect.Descriptor instead.
+func (*GetTripUpdatesRequest) Descriptor() ([]byte, []int) {
+	return file_transit_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GetTripUpdatesRequest) GetTripId() string {
+	if x != nil {
+		return x.TripId
+	}
+	return ""
+}
+
+type GetTripUpdatesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Updates       []*TripUpdate          `protobuf:"bytes,1,rep,name=updates,proto3" json:"updates,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTripUpdatesResponse) Reset() {
+	*x = GetTripUpdatesResponse{}
+	mi := &file_transit_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTripUpdatesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTripUpdatesResponse) ProtoMessage() {}
+
+func (x *GetTripUpdatesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_transit_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTripUpdatesResponse.ProtoReflect.Descriptor instead.
+func (*GetTripUpdatesResponse) Descriptor() ([]byte, []int) {
+	return file_transit_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *GetTripUpdatesResponse) GetUpdates() []*TripUpdate {
+	if x != nil {
+		return x.Updates
+	}
+	return nil
+}
+
+var File_transit_proto protoreflect.FileDescriptor
+
+const file_transit_proto_rawDesc = "" +
+	"\n" +
+	"\rtransit.proto\x12\fbilbopass.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xdf\x03\n" +
+	"\x0fVehiclePosition\x12\x1d\n" +
+	"\n" +
+	"vehicle_id\x18\x01 \x01(\tR\tvehicleId\x12\x17\n" +
+	"\atrip_id\x18\x02 \x01(\tR\x06tripId\x12\x19\n" +
+	"\broute_id\x18\x03 \x01(\tR\arouteId\x122\n" +
+	"\bposition\x18\x04 \x01(\v2\x16.bilbopass.v1.PositionR\bposition\x12\x18\n" +
+	"\abearing\x18\x05 \x01(\x02R\abearing\x12\x14\n" +
+	"\x05speed\x18\x06 \x01(\x02R\x05speed\x12)\n" +
+	"\x10congestion_level\x18\a \x01(\x05R\x0fcongestionLevel\x12.\n" +
+	"\x10occupancy_status\x18\b \x01(\x05H\x00R\x0foccupancyStatus\x88\x01\x01\x128\n" +
+	"\ttimestamp\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12\x16\n" +
+	"\x06agency\x18\n" +
+	" \x01(\tR\x06agency\x12\x17\n" +
+	"\astop_id\x18\v \x01(\tR\x06stopId\x12(\n" +
+	"\rstop_sequence\x18\f \x01(\x05H\x01R\fstopSequence\x88\x01\x01B\x13\n" +
+	"\x11_occupancy_statusB\x10\n" +
+	"\x0e_stop_sequence\"D\n" +
+	"\bPosition\x12\x1a\n" +
+	"\blatitude\x18\x01 \x01(\x01R\blatitude\x12\x1c\n" +
+	"\tlongitude\x18\x02 \x01(\x01R\tlongitude\"\xc4\x01\n" +
+	"\n" +
+	"TripUpdate\x12\x17\n" +
+	"\atrip_id\x18\x01 \x01(\tR\x06tripId\x12\x19\n" +
+	"\broute_id\x18\x02 \x01(\tR\arouteId\x12H\n" +
+	"\x11stop_time_updates\x18\x03 \x03(\v2\x1c.bilbopass.v1.StopTimeUpdateR\x0fstopTimeUpdates\x128\n" +
+	"\ttimestamp\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\"\xc0\x01\n" +
+	"\x0eStopTimeUpdate\x12\x17\n" +
+	"\astop_id\x18\x01 \x01(\tR\x06stopId\x12#\n" +
+	"\rstop_sequence\x18\x02 \x01(\x05R\fstopSequence\x125\n" +
+	"\aarrival\x18\x03 \x01(\v2\x1b.bilbopass.v1.StopTimeEventR\aarrival\x129\n" +
+	"\tdeparture\x18\x04 \x01(\v2\x1b.bilbopass.v1.StopTimeEventR\tdeparture\"U\n" +
+	"\rStopTimeEvent\x12\x14\n" +
+	"\x05delay\x18\x01 \x01(\x05R\x05delay\x12.\n" +
+	"\x04time\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\"\xc1\x02\n" +
+	"\fServiceAlert\x12\x19\n" +
+	"\balert_id\x18\x01 \x01(\tR\aalertId\x12\x1b\n" +
+	"\troute_ids\x18\x02 \x03(\tR\brouteIds\x12\x19\n" +
+	"\bstop_ids\x18\x03 \x03(\tR\astopIds\x12\x1f\n" +
+	"\vheader_text\x18\x04 \x01(\tR\n" +
+	"headerText\x12)\n" +
+	"\x10description_text\x18\x05 \x01(\tR\x0fdescriptionText\x122\n" +
+	"\bseverity\x18\x06 \x01(\x0e2\x16.bilbopass.v1.SeverityR\bseverity\x120\n" +
+	"\x05start\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12,\n" +
+	"\x03end\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\x03end\"7\n" +
+	"\x1aGetVehiclePositionsRequest\x12\x19\n" +
+	"\broute_id\x18\x01 \x01(\tR\arouteId\"Z\n" +
+	"\x1bGetVehiclePositionsResponse\x12;\n" +
+	"\tpositions\x18\x01 \x03(\v2\x1d.bilbopass.v1.VehiclePositionR\tpositions\",\n" +
+	"\rStreamRequest\x12\x1b\n" +
+	"\troute_ids\x18\x01 \x03(\tR\brouteIds\"0\n" +
+	"\x15GetTripUpdatesRequest\x12\x17\n" +
+	"\atrip_id\x18\x01 \x01(\tR\x06tripId\"L\n" +
+	"\x16GetTripUpdatesResponse\x122\n" +
+	"\aupdates\x18\x01 \x03(\v2\x18.bilbopass.v1.TripUpdateR\aupdates*^\n" +
+	"\bSeverity\x12\x14\n" +
+	"\x10SEVERITY_UNKNOWN\x10\x00\x12\x11\n" +
+	"\rSEVERITY_INFO\x10\x01\x12\x14\n" +
+	"\x10SEVERITY_WARNING\x10\x02\x12\x13\n" +
+	"\x0fSEVERITY_SEVERE\x10\x032\xb1\x02\n" +
+	"\x0eTransitService\x12j\n" +
+	"\x13GetVehiclePositions\x12(.bilbopass.v1.GetVehiclePositionsRequest\x1a).bilbopass.v1.GetVehiclePositionsResponse\x12V\n" +
+	"\x16StreamVehiclePositions\x12\x1b.bilbopass.v1.StreamRequest\x1a\x1d.bilbopass.v1.VehiclePosition0\x01\x12[\n" +
+	"\x0eGetTripUpdates\x12#.bilbopass.v1.GetTripUpdatesRequest\x1a$.bilbopass.v1.GetTripUpdatesResponseB<Z:github.com/samirrijal/bilbopass/internal/proto/bilbopassv1b\x06proto3"
+
+var (
+	file_transit_proto_rawDescOnce sync.Once
+	file_transit_proto_rawDescData []byte
+)
+
+func file_transit_proto_rawDescGZIP() []byte {
+	file_transit_proto_rawDescOnce.Do(func() {
+		file_transit_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_transit_proto_rawDesc), len(file_transit_proto_rawDesc)))
+	})
+	return file_transit_proto_rawDescData
+}
+
+var file_transit_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_transit_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_transit_proto_goTypes = []any{
+	(Severity)(0),                       // 0: bilbopass.v1.Severity
+	(*VehiclePosition)(nil),             // 1: bilbopass.v1.VehiclePosition
+	(*Position)(nil),                    // 2: bilbopass.v1.Position
+	(*TripUpdate)(nil),                  // 3: bilbopass.v1.TripUpdate
+	(*StopTimeUpdate)(nil),              // 4: bilbopass.v1.StopTimeUpdate
+	(*StopTimeEvent)(nil),               // 5: bilbopass.v1.StopTimeEvent
+	(*ServiceAlert)(nil),                // 6: bilbopass.v1.ServiceAlert
+	(*GetVehiclePositionsRequest)(nil),  // 7: bilbopass.v1.GetVehiclePositionsRequest
+	(*GetVehiclePositionsResponse)(nil), // 8: bilbopass.v1.GetVehiclePositionsResponse
+	(*StreamRequest)(nil),               // 9: bilbopass.v1.StreamRequest
+	(*GetTripUpdatesRequest)(nil),       // 10: bilbopass.v1.GetTripUpdatesRequest
+	(*GetTripUpdatesResponse)(nil),      // 11: bilbopass.v1.GetTripUpdatesResponse
+	(*timestamppb.Timestamp)(nil),       // 12: google.protobuf.Timestamp
+}
+var file_transit_proto_depIdxs = []int32{
+	2,  // 0: bilbopass.v1.VehiclePosition.position:type_name -> bilbopass.v1.Position
+	12, // 1: bilbopass.v1.VehiclePosition.timestamp:type_name -> google.protobuf.Timestamp
+	4,  // 2: bilbopass.v1.TripUpdate.stop_time_updates:type_name -> bilbopass.v1.StopTimeUpdate
+	12, // 3: bilbopass.v1.TripUpdate.timestamp:type_name -> google.protobuf.Timestamp
+	5,  // 4: bilbopass.v1.StopTimeUpdate.arrival:type_name -> bilbopass.v1.StopTimeEvent
+	5,  // 5: bilbopass.v1.StopTimeUpdate.departure:type_name -> bilbopass.v1.StopTimeEvent
+	12, // 6: bilbopass.v1.StopTimeEvent.time:type_name -> google.protobuf.Timestamp
+	0,  // 7: bilbopass.v1.ServiceAlert.severity:type_name -> bilbopass.v1.Severity
+	12, // 8: bilbopass.v1.ServiceAlert.start:type_name -> google.protobuf.Timestamp
+	12, // 9: bilbopass.v1.ServiceAlert.end:type_name -> google.protobuf.Timestamp
+	1,  // 10: bilbopass.v1.GetVehiclePositionsResponse.positions:type_name -> bilbopass.v1.VehiclePosition
+	3,  // 11: bilbopass.v1.GetTripUpdatesResponse.updates:type_name -> bilbopass.v1.TripUpdate
+	7,  // 12: bilbopass.v1.TransitService.GetVehiclePositions:input_type -> bilbopass.v1.GetVehiclePositionsRequest
+	9,  // 13: bilbopass.v1.TransitService.StreamVehiclePositions:input_type -> bilbopass.v1.StreamRequest
+	10, // 14: bilbopass.v1.TransitService.GetTripUpdates:input_type -> bilbopass.v1.GetTripUpdatesRequest
+	8,  // 15: bilbopass.v1.TransitService.GetVehiclePositions:output_type -> bilbopass.v1.GetVehiclePositionsResponse
+	1,  // 16: bilbopass.v1.TransitService.StreamVehiclePositions:output_type -> bilbopass.v1.VehiclePosition
+	11, // 17: bilbopass.v1.TransitService.GetTripUpdates:output_type -> bilbopass.v1.GetTripUpdatesResponse
+	15, // [15:18] is the sub-list for method output_type
+	12, // [12:15] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
+}
+
+func init() { file_transit_proto_init() }
+func file_transit_proto_init() {
+	if File_transit_proto != nil {
+		return
+	}
+	file_transit_proto_msgTypes[0].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_transit_proto_rawDesc), len(file_transit_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_transit_proto_goTypes,
+		DependencyIndexes: file_transit_proto_depIdxs,
+		EnumInfos:         file_transit_proto_enumTypes,
+		MessageInfos:      file_transit_proto_msgTypes,
+	}.Build()
+	File_transit_proto = out.File
+	file_transit_proto_goTypes = nil
+	file_transit_proto_depIdxs = nil
+}
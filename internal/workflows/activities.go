@@ -2,15 +2,22 @@ package workflows
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
+	"go.temporal.io/sdk/temporal"
+
 	"github.com/samirrijal/bilbopass/internal/core/domain"
 	"github.com/samirrijal/bilbopass/internal/core/ports"
 	"github.com/samirrijal/bilbopass/internal/core/usecases"
 )
 
+// PushFailedError is the application error type of push notifications that
+// retrying will not deliver.
+const PushFailedError = "PushFailed"
+
 // CompensationActivities holds the activity implementations for the compensation workflow.
 type CompensationActivities struct {
 	CompensationService *usecases.CompensationService
@@ -22,7 +29,7 @@ type CompensationActivities struct {
 
 // FindNearestAffiliate returns the ID of the nearest active affiliate.
 func (a *CompensationActivities) FindNearestAffiliate(ctx context.Context, lat, lon float64) (string, error) {
-	affiliates, err := a.Affiliates.FindNearby(ctx, lat, lon, 5)
+	affiliates, err := a.Affiliates.FindNearby(ctx, lat, lon, usecases.CouponAffiliateRadius, 5)
 	if err != nil {
 		return "", fmt.Errorf("find nearby affiliates: %w", err)
 	}
@@ -38,6 +45,9 @@ func (a *CompensationActivities) GetAffiliateName(ctx context.Context, affiliate
 	if err != nil {
 		return "", fmt.Errorf("get affiliate %s: %w", affiliateID, err)
 	}
+	if aff == nil {
+		return "", fmt.Errorf("affiliate %s not found", affiliateID)
+	}
 	return aff.Name, nil
 }
 
@@ -53,7 +63,11 @@ func (a *CompensationActivities) GenerateCouponCode(ctx context.Context, userID,
 	return comp.Code, nil
 }
 
-// SendPushNotification sends a push notification to the user.
+// SendPushNotification sends a push notification to the user. Riders without
+// devices, or who muted coupons, still find the coupon in the app, so that is
+// not a failure.
+// Temporary delivery failures are retried by the workflow's retry policy;
+// others fail with the non-retryable type PushFailedError.
 func (a *CompensationActivities) SendPushNotification(ctx context.Context, userID, affiliateName, code string) error {
 	if a.Notifier == nil {
 		log.Printf("PUSH (no notifier) → user=%s affiliate=%s code=%s", userID, affiliateName, code)
@@ -61,7 +75,23 @@ func (a *CompensationActivities) SendPushNotification(ctx context.Context, userI
 	}
 	title := "Free coffee — sorry for the delay!"
 	body := fmt.Sprintf("Show code %s at %s. Valid for 72 hours.", code, affiliateName)
-	return a.Notifier.SendPush(ctx, userID, title, body)
+
+	err := a.Notifier.SendPush(ctx, userID, domain.NotificationCoupons, title, body)
+	var pe *ports.PushError
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ports.ErrNoDevices):
+		log.Printf("PUSH (no devices) → user=%s code=%s", userID, code)
+		return nil
+	case errors.Is(err, ports.ErrPushMuted):
+		log.Printf("PUSH (muted) → user=%s code=%s", userID, code)
+		return nil
+	case errors.As(err, &pe) && pe.Temporary:
+		return err
+	default:
+		return temporal.NewNonRetryableApplicationError(err.Error(), PushFailedError, err)
+	}
 }
 
 // ScheduleExpiry sets a timer to auto-expire the coupon after its TTL.
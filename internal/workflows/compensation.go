@@ -48,8 +48,19 @@ func CompensationWorkflow(ctx workflow.Context, input CompensationInput) error {
 		return err
 	}
 
-	// Step 3: Send push notification
-	err = workflow.ExecuteActivity(ctx, "SendPushNotification", input.UserID, affiliateName, code).Get(ctx, nil)
+	// Step 3: Send push notification, backing off while push services are
+	// unavailable or rate limiting
+	pushCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 30 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:        10 * time.Second,
+			BackoffCoefficient:     2,
+			MaximumInterval:        5 * time.Minute,
+			MaximumAttempts:        8,
+			NonRetryableErrorTypes: []string{PushFailedError},
+		},
+	})
+	err = workflow.ExecuteActivity(pushCtx, "SendPushNotification", input.UserID, affiliateName, code).Get(ctx, nil)
 	if err != nil {
 		logger.Warn("push notification failed, compensating", "error", err)
 		// Compensate: delete the coupon
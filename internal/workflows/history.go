@@ -0,0 +1,217 @@
+package workflows
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	failurepb "go.temporal.io/api/failure/v1"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// maxUserScan is how many compensation runs ListRuns looks through for a
+// user's: compensation workflow IDs end with the user ID, which visibility
+// queries cannot match.
+const maxUserScan = 2000
+
+// workflowTypes are the workflow types of each kind of run.
+var workflowTypes = map[string]string{
+	domain.WorkflowCompensation: "CompensationWorkflow",
+	domain.WorkflowIngest:       "FeedIngestWorkflow",
+	domain.WorkflowJob:          "ScheduledJobWorkflow",
+}
+
+// runStatuses are the run statuses of the API, and their names in
+// visibility queries.
+var runStatuses = map[enumspb.WorkflowExecutionStatus][2]string{
+	enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING:          {"running", "Running"},
+	enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED:        {"completed", "Completed"},
+	enumspb.WORKFLOW_EXECUTION_STATUS_FAILED:           {"failed", "Failed"},
+	enumspb.WORKFLOW_EXECUTION_STATUS_CANCELED:         {"canceled", "Canceled"},
+	enumspb.WORKFLOW_EXECUTION_STATUS_TERMINATED:       {"terminated", "Terminated"},
+	enumspb.WORKFLOW_EXECUTION_STATUS_CONTINUED_AS_NEW: {"continued_as_new", "ContinuedAsNew"},
+	enumspb.WORKFLOW_EXECUTION_STATUS_TIMED_OUT:        {"timed_out", "TimedOut"},
+}
+
+// History implements ports.WorkflowHistory with Temporal's visibility API.
+type History struct {
+	client client.Client
+}
+
+// NewHistory creates a History listing the runs of c's namespace.
+func NewHistory(c client.Client) *History {
+	return &History{client: c}
+}
+
+// ListRuns implements ports.WorkflowHistory. Filter values are expected to
+// be validated: they are put in the visibility query as they are.
+func (h *History) ListRuns(ctx context.Context, f domain.WorkflowFilter) ([]domain.WorkflowRun, error) {
+	var conds []string
+	if f.Kind != "" {
+		conds = append(conds, fmt.Sprintf("WorkflowType = '%s'", workflowTypes[f.Kind]))
+	} else {
+		var types []string
+		for _, kind := range []string{domain.WorkflowCompensation, domain.WorkflowIngest, domain.WorkflowJob} {
+			types = append(types, "'"+workflowTypes[kind]+"'")
+		}
+		conds = append(conds, "WorkflowType IN ("+strings.Join(types, ", ")+")")
+	}
+	switch {
+	case f.DelayEventID != "" && f.UserID != "":
+		conds = append(conds, fmt.Sprintf("WorkflowId = '%s'", CompensationWorkflowID(f.DelayEventID, f.UserID)))
+	case f.DelayEventID != "":
+		conds = append(conds, fmt.Sprintf("WorkflowId STARTS_WITH '%s'", CompensationWorkflowID(f.DelayEventID, "")))
+	case f.Agency != "":
+		conds = append(conds, fmt.Sprintf("WorkflowId = '%s'", FeedIngestWorkflowID(f.Agency)))
+	}
+	if f.Status != "" {
+		for _, names := range runStatuses {
+			if names[0] == f.Status {
+				conds = append(conds, fmt.Sprintf("ExecutionStatus = '%s'", names[1]))
+			}
+		}
+	}
+	conds = append(conds, fmt.Sprintf("StartTime >= '%s'", f.Since.UTC().Format(time.RFC3339)))
+	query := strings.Join(conds, " AND ")
+
+	// A user's compensations are found among all of them
+	userSuffix := ""
+	if f.UserID != "" && f.DelayEventID == "" {
+		userSuffix = "-" + f.UserID
+	}
+
+	var runs []domain.WorkflowRun
+	var token []byte
+	scanned := 0
+	for len(runs) < f.Limit {
+		pageSize := f.Limit - len(runs)
+		if userSuffix != "" {
+			pageSize = 500
+		}
+		resp, err := h.client.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+			Query:         query,
+			PageSize:      int32(pageSize),
+			NextPageToken: token,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list workflows: %w", err)
+		}
+		for _, info := range resp.GetExecutions() {
+			scanned++
+			if userSuffix != "" && !strings.HasSuffix(info.GetExecution().GetWorkflowId(), userSuffix) {
+				continue
+			}
+			if len(runs) < f.Limit {
+				runs = append(runs, newWorkflowRun(info))
+			}
+		}
+		token = resp.GetNextPageToken()
+		if len(token) == 0 || userSuffix != "" && scanned >= maxUserScan {
+			break
+		}
+	}
+
+	for i := range runs {
+		if err := h.explain(ctx, &runs[i]); err != nil {
+			return nil, fmt.Errorf("%s: %w", runs[i].WorkflowID, err)
+		}
+	}
+	return runs, nil
+}
+
+// newWorkflowRun returns the run of info, with the IDs its workflow ID holds.
+func newWorkflowRun(info *workflowpb.WorkflowExecutionInfo) domain.WorkflowRun {
+	run := domain.WorkflowRun{
+		WorkflowID: info.GetExecution().GetWorkflowId(),
+		RunID:      info.GetExecution().GetRunId(),
+		Status:     runStatuses[info.GetStatus()][0],
+		StartedAt:  info.GetStartTime().AsTime(),
+	}
+	if info.GetCloseTime() != nil {
+		closed := info.GetCloseTime().AsTime()
+		run.ClosedAt = &closed
+	}
+	for kind, typ := range workflowTypes {
+		if info.GetType().GetName() == typ {
+			run.Kind = kind
+		}
+	}
+	switch run.Kind {
+	case domain.WorkflowCompensation:
+		// compensation-<delay event UUID>-<user ID>
+		if ids, ok := strings.CutPrefix(run.WorkflowID, "compensation-"); ok && len(ids) > 37 {
+			run.DelayEventID, run.UserID = ids[:36], ids[37:]
+		}
+	case domain.WorkflowIngest:
+		run.Agency = strings.TrimPrefix(run.WorkflowID, FeedIngestWorkflowID(""))
+	case domain.WorkflowJob:
+		run.Job = strings.TrimPrefix(run.WorkflowID, ScheduledJobWorkflowID(""))
+	}
+	return run
+}
+
+// explain sets the activity a running run is retrying, from its pending
+// activities, or the last activity of a run that did not complete and why
+// it closed, from its history.
+func (h *History) explain(ctx context.Context, run *domain.WorkflowRun) error {
+	switch run.Status {
+	case "completed", "continued_as_new":
+		return nil
+	case "running":
+		resp, err := h.client.DescribeWorkflowExecution(ctx, run.WorkflowID, run.RunID)
+		if err != nil {
+			return err
+		}
+		for _, pa := range resp.GetPendingActivities() {
+			if int(pa.GetAttempt()) > run.Attempts {
+				run.Activity = pa.GetActivityType().GetName()
+				run.Attempts = int(pa.GetAttempt())
+				run.Failure = failureMessage(pa.GetLastFailure())
+			}
+		}
+		return nil
+	}
+
+	scheduled := map[int64]string{}
+	iter := h.client.GetWorkflowHistory(ctx, run.WorkflowID, run.RunID, false, enumspb.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		switch event.GetEventType() {
+		case enumspb.EVENT_TYPE_ACTIVITY_TASK_SCHEDULED:
+			scheduled[event.GetEventId()] = event.GetActivityTaskScheduledEventAttributes().GetActivityType().GetName()
+		case enumspb.EVENT_TYPE_ACTIVITY_TASK_STARTED:
+			attrs := event.GetActivityTaskStartedEventAttributes()
+			run.Activity = scheduled[attrs.GetScheduledEventId()]
+			run.Attempts = int(attrs.GetAttempt())
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_FAILED:
+			run.Failure = failureMessage(event.GetWorkflowExecutionFailedEventAttributes().GetFailure())
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_TERMINATED:
+			run.Failure = "terminated: " + event.GetWorkflowExecutionTerminatedEventAttributes().GetReason()
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_TIMED_OUT:
+			run.Failure = "workflow timed out"
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_CANCELED:
+			run.Failure = "canceled"
+		}
+	}
+	return nil
+}
+
+// failureMessage returns the messages of f and its causes, outermost first.
+func failureMessage(f *failurepb.Failure) string {
+	var msgs []string
+	for ; f != nil; f = f.GetCause() {
+		if msg := f.GetMessage(); msg != "" {
+			msgs = append(msgs, msg)
+		}
+	}
+	return strings.Join(msgs, ": ")
+}
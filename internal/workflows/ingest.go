@@ -0,0 +1,130 @@
+package workflows
+
+import (
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// InvalidFeedError is the application error type of downloaded feeds that
+// retrying will not make valid.
+const InvalidFeedError = "InvalidFeed"
+
+// FeedIngestInput is the input for the feed ingest workflow: the agency of
+// the manifest whose static feed is ingested, and the task queue of the
+// ingestor workers running its activities.
+type FeedIngestInput struct {
+	Name          string
+	Slug          string
+	GTFSURL       string
+	ActivityQueue string
+}
+
+// DownloadedFeed is a feed downloaded by the DownloadFeed activity. The feed
+// itself is kept as a feed stage between activities, being too big for the
+// workflow's history.
+type DownloadedFeed struct {
+	AgencyID string
+	Slug     string
+	Checksum string
+	// Held is set for feeds staged for an admin's approval, which the
+	// workflow leaves to `ingestor activate`.
+	Held bool
+	// StageID is the stage holding the feed. Reused is set when it is an
+	// applied stage of the same feed, which failures leave in place.
+	StageID string
+	Reused  bool
+	// Previous is the applied stage that was live when the feed was
+	// downloaded, which a failed load rolls back to; empty for an agency's
+	// first feed.
+	Previous string
+}
+
+// FeedIngestWorkflow downloads, validates, loads and activates an agency's
+// static feed, then invalidates and warms the caches serving it and tells
+// riders about the service changes. Should loading or activating fail once
+// retries run out, the feed that was live before is applied again (saga
+// compensation). Cache and notification failures leave the new feed live.
+func FeedIngestWorkflow(ctx workflow.Context, input FeedIngestInput) error {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting feed ingest workflow", "agency", input.Slug)
+
+	// A minute early, in case the workflow's clock is ahead of the database's
+	started := workflow.Now(ctx).Add(-time.Minute)
+
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		TaskQueue:           input.ActivityQueue,
+		StartToCloseTimeout: 5 * time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:        10 * time.Second,
+			BackoffCoefficient:     2,
+			MaximumAttempts:        3,
+			NonRetryableErrorTypes: []string{InvalidFeedError},
+		},
+	})
+
+	// Step 1: Download the feed and stage it
+	var feed DownloadedFeed
+	if err := workflow.ExecuteActivity(ctx, "DownloadFeed", input).Get(ctx, &feed); err != nil {
+		return err
+	}
+	if feed.Held {
+		logger.Info("Feed held for approval", "agency", input.Slug)
+		return nil
+	}
+
+	// Step 2: Validate it before anything live is touched
+	if err := workflow.ExecuteActivity(ctx, "ValidateFeed", feed).Get(ctx, nil); err != nil {
+		logger.Warn("feed invalid, discarding", "error", err)
+		_ = workflow.ExecuteActivity(ctx, "DiscardFeed", feed).Get(ctx, nil)
+		return err
+	}
+
+	// Step 3: Load the schedule and activate the feed version. Loading the
+	// stop times of the largest feeds takes a while.
+	loadCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		TaskQueue:           input.ActivityQueue,
+		StartToCloseTimeout: time.Hour,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval: time.Minute,
+			MaximumAttempts: 2,
+		},
+	})
+	var changes []domain.ServiceChange
+	err := workflow.ExecuteActivity(loadCtx, "LoadFeed", feed).Get(ctx, &changes)
+	if err == nil {
+		err = workflow.ExecuteActivity(ctx, "ActivateFeed", feed).Get(ctx, nil)
+	}
+	if err != nil {
+		logger.Warn("feed failed to load, compensating", "error", err)
+		// Compensate: re-apply the previous feed, even if the workflow was
+		// cancelled halfway through the load
+		rollbackCtx, _ := workflow.NewDisconnectedContext(loadCtx)
+		if rbErr := workflow.ExecuteActivity(rollbackCtx, "RollbackFeed", feed).Get(rollbackCtx, nil); rbErr != nil {
+			logger.Error("rollback failed", "error", rbErr)
+		}
+		return err
+	}
+
+	// Step 4: Drop what the feed changed from the CDN, and warm the route
+	// cache of the new static data version
+	if err := workflow.ExecuteActivity(ctx, "InvalidateCaches", feed.Slug, started).Get(ctx, nil); err != nil {
+		logger.Warn("cache invalidation failed", "error", err)
+	}
+	if err := workflow.ExecuteActivity(ctx, "WarmCaches", feed.AgencyID).Get(ctx, nil); err != nil {
+		logger.Warn("cache warming failed", "error", err)
+	}
+
+	// Step 5: Tell riders about changes to their favorite stops and routes
+	if len(changes) > 0 {
+		if err := workflow.ExecuteActivity(ctx, "NotifyServiceChanges", feed.Slug, changes).Get(ctx, nil); err != nil {
+			logger.Warn("service change notifications failed", "error", err)
+		}
+	}
+
+	logger.Info("Feed ingested", "agency", input.Slug, "serviceChanges", len(changes))
+	return nil
+}
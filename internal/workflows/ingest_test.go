@@ -0,0 +1,155 @@
+package workflows_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+	"github.com/samirrijal/bilbopass/internal/workflows"
+)
+
+// fakeIngest stands in for the ingestor's activities, recording each
+// attempt and failing the activities named in fail.
+type fakeIngest struct {
+	fail map[string]bool
+	held bool
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (f *fakeIngest) call(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, name)
+	if !f.fail[name] {
+		return nil
+	}
+	if name == "ValidateFeed" {
+		return temporal.NewNonRetryableApplicationError("feed invalid", workflows.InvalidFeedError, nil)
+	}
+	return errors.New(name + " failed")
+}
+
+func (f *fakeIngest) register(env *testsuite.TestWorkflowEnvironment) {
+	feed := func(name string) func(context.Context, workflows.DownloadedFeed) error {
+		return func(context.Context, workflows.DownloadedFeed) error { return f.call(name) }
+	}
+	for name, fn := range map[string]any{
+		"DownloadFeed": func(_ context.Context, input workflows.FeedIngestInput) (workflows.DownloadedFeed, error) {
+			return workflows.DownloadedFeed{AgencyID: "a1", Slug: input.Slug, StageID: "s2", Previous: "s1", Held: f.held}, f.call("DownloadFeed")
+		},
+		"ValidateFeed": feed("ValidateFeed"),
+		"DiscardFeed":  feed("DiscardFeed"),
+		"LoadFeed": func(context.Context, workflows.DownloadedFeed) ([]domain.ServiceChange, error) {
+			return []domain.ServiceChange{{Kind: "route_removed", SubjectID: "L9"}}, f.call("LoadFeed")
+		},
+		"ActivateFeed": feed("ActivateFeed"),
+		"RollbackFeed": feed("RollbackFeed"),
+		"InvalidateCaches": func(context.Context, string, time.Time) error {
+			return f.call("InvalidateCaches")
+		},
+		"WarmCaches": func(context.Context, string) error { return f.call("WarmCaches") },
+		"NotifyServiceChanges": func(context.Context, string, []domain.ServiceChange) error {
+			return f.call("NotifyServiceChanges")
+		},
+	} {
+		env.RegisterActivityWithOptions(fn, activity.RegisterOptions{Name: name})
+	}
+}
+
+func TestFeedIngestWorkflow(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		fail    []string
+		held    bool
+		wantErr string   // the failed activity's error, if the workflow fails
+		calls   []string // attempts, in order
+	}{
+		{
+			name: "ingested",
+			calls: []string{"DownloadFeed", "ValidateFeed", "LoadFeed", "ActivateFeed",
+				"InvalidateCaches", "WarmCaches", "NotifyServiceChanges"},
+		},
+		{
+			name:  "held for approval",
+			held:  true,
+			calls: []string{"DownloadFeed"},
+		},
+		{
+			name:    "download failed",
+			fail:    []string{"DownloadFeed"},
+			wantErr: "DownloadFeed failed",
+			calls:   []string{"DownloadFeed", "DownloadFeed", "DownloadFeed"},
+		},
+		{
+			name:    "invalid feed discarded, not retried or rolled back",
+			fail:    []string{"ValidateFeed"},
+			wantErr: "feed invalid",
+			calls:   []string{"DownloadFeed", "ValidateFeed", "DiscardFeed"},
+		},
+		{
+			name:    "load failed, rolled back",
+			fail:    []string{"LoadFeed"},
+			wantErr: "LoadFeed failed",
+			calls:   []string{"DownloadFeed", "ValidateFeed", "LoadFeed", "LoadFeed", "RollbackFeed"},
+		},
+		{
+			name:    "activation failed, rolled back",
+			fail:    []string{"ActivateFeed"},
+			wantErr: "ActivateFeed failed",
+			calls: []string{"DownloadFeed", "ValidateFeed", "LoadFeed",
+				"ActivateFeed", "ActivateFeed", "ActivateFeed", "RollbackFeed"},
+		},
+		{
+			name:    "rollback failure keeps the load error",
+			fail:    []string{"LoadFeed", "RollbackFeed"},
+			wantErr: "LoadFeed failed",
+			calls: []string{"DownloadFeed", "ValidateFeed", "LoadFeed", "LoadFeed",
+				"RollbackFeed", "RollbackFeed"},
+		},
+		{
+			name: "cache and notification failures leave the feed live",
+			fail: []string{"InvalidateCaches", "WarmCaches", "NotifyServiceChanges"},
+			calls: []string{"DownloadFeed", "ValidateFeed", "LoadFeed", "ActivateFeed",
+				"InvalidateCaches", "InvalidateCaches", "InvalidateCaches",
+				"WarmCaches", "WarmCaches", "WarmCaches",
+				"NotifyServiceChanges", "NotifyServiceChanges", "NotifyServiceChanges"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var s testsuite.WorkflowTestSuite
+			env := s.NewTestWorkflowEnvironment()
+			f := &fakeIngest{fail: map[string]bool{}, held: tc.held}
+			for _, name := range tc.fail {
+				f.fail[name] = true
+			}
+			f.register(env)
+
+			env.ExecuteWorkflow(workflows.FeedIngestWorkflow, workflows.FeedIngestInput{
+				Name: "Bilbobus", Slug: "bilbobus", GTFSURL: "https://example.com/gtfs.zip", ActivityQueue: "ingest",
+			})
+			if !env.IsWorkflowCompleted() {
+				t.Fatal("workflow did not complete")
+			}
+			err := env.GetWorkflowError()
+			var appErr *temporal.ApplicationError
+			if tc.wantErr == "" && err != nil {
+				t.Errorf("expected the workflow to succeed, got %v", err)
+			} else if tc.wantErr != "" && (!errors.As(err, &appErr) || appErr.Message() != tc.wantErr) {
+				t.Errorf("expected the workflow to fail with %q, got %v", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(f.calls, tc.calls) {
+				t.Errorf("expected attempts\n  %v\ngot\n  %v", tc.calls, f.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/samirrijal/bilbopass/internal/core/usecases"
+)
+
+// ScheduleTimezone is the time zone of the nightly jobs, and of the days
+// they process.
+const ScheduleTimezone = "Europe/Madrid"
+
+// ScheduledJob is a periodic job: an activity that ScheduledJobWorkflow runs
+// on a cron schedule.
+type ScheduledJob struct {
+	Name     string
+	Cron     string
+	Activity string
+	// Timeout is how long one attempt may take.
+	Timeout time.Duration
+}
+
+// ScheduledJobs are the periodic jobs run by the compensator's worker, which
+// starts them as cron workflows. Vehicle position retention is left to
+// TimescaleDB's policies, and cache warming to FeedIngestWorkflow, as the
+// route cache only changes with the static data version.
+var ScheduledJobs = []ScheduledJob{
+	{Name: "anomalies", Cron: "*/15 * * * *", Activity: "DetectAnomalies", Timeout: 10 * time.Minute},
+	// Shortly after each hour, so that the previous hour's predictions are complete
+	{Name: "sla", Cron: "5 * * * *", Activity: "EvaluateSLAs", Timeout: 30 * time.Minute},
+	{Name: "reconcile", Cron: "CRON_TZ=" + ScheduleTimezone + " 0 3 * * *", Activity: "ReconcileFeeds", Timeout: 30 * time.Minute},
+	// After late delay events for the day before have been recorded
+	{Name: "archive", Cron: "CRON_TZ=" + ScheduleTimezone + " 0 4 * * *", Activity: "ArchiveRealtime", Timeout: 2 * time.Hour},
+	// Every usecases.CoverageSampleInterval and usecases.SegmentRollupInterval
+	{Name: "coverage", Cron: "*/5 * * * *", Activity: "SampleCoverage", Timeout: 4 * time.Minute},
+	{Name: "segments", Cron: "*/15 * * * *", Activity: "RollupSegments", Timeout: 10 * time.Minute},
+}
+
+// ScheduledJobWorkflow runs a job's activity once, as of the time the run
+// started, so that retries process the same hour or day. Temporal starts a
+// run on every tick of the job's cron schedule; a tick is skipped while the
+// previous run is still retrying.
+func ScheduledJobWorkflow(ctx workflow.Context, job ScheduledJob) error {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: job.Timeout,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    30 * time.Second,
+			BackoffCoefficient: 2,
+			MaximumInterval:    5 * time.Minute,
+			MaximumAttempts:    4,
+		},
+	})
+	return workflow.ExecuteActivity(ctx, job.Activity, workflow.Now(ctx)).Get(ctx, nil)
+}
+
+// JobActivities holds the activity implementations of the scheduled jobs.
+type JobActivities struct {
+	Anomalies *usecases.AnomalyService
+	SLAs      *usecases.SLAService
+	Quality   *usecases.FeedQualityService
+	Archive   *usecases.ArchiveService
+	Coverage  *usecases.RealtimeCoverageService
+	Segments  *usecases.SegmentService
+	// Zone is ScheduleTimezone, in which the nightly jobs take days.
+	Zone *time.Location
+}
+
+// DetectAnomalies raises the delay anomalies of the hour before at.
+func (a *JobActivities) DetectAnomalies(ctx context.Context, at time.Time) error {
+	anomalies, err := a.Anomalies.Detect(ctx, at.In(a.Zone))
+	if err != nil {
+		return fmt.Errorf("detect: %w", err)
+	}
+	for _, an := range anomalies {
+		log.Printf("anomaly: route %s mean delay %.0fs (%.1fσ above %.0fs)", an.RouteName, an.MeanDelay, an.Sigma, an.BaselineMean)
+	}
+	return nil
+}
+
+// EvaluateSLAs rolls up punctuality and publishes the breaches of finished
+// months.
+func (a *JobActivities) EvaluateSLAs(ctx context.Context, at time.Time) error {
+	breaches, err := a.SLAs.Run(ctx, at.In(a.Zone))
+	if err != nil {
+		return fmt.Errorf("sla run: %w", err)
+	}
+	if breaches > 0 {
+		log.Printf("%d SLA breaches published", breaches)
+	}
+	return nil
+}
+
+// ReconcileFeeds stores the feed quality reports of the day before at.
+func (a *JobActivities) ReconcileFeeds(ctx context.Context, at time.Time) error {
+	yesterday := at.In(a.Zone).AddDate(0, 0, -1)
+	reports, err := a.Quality.Reconcile(ctx, yesterday)
+	if err != nil {
+		return fmt.Errorf("reconcile: %w", err)
+	}
+	log.Printf("%d feed quality reports for %s", reports, yesterday.Format("2006-01-02"))
+	return nil
+}
+
+// ArchiveRealtime archives the realtime data of the day before at, unless no
+// object storage is configured. Partitions are replaced, so a retry archives
+// the whole day again.
+func (a *JobActivities) ArchiveRealtime(ctx context.Context, at time.Time) error {
+	partitions, err := a.Archive.Archive(ctx, at.In(a.Zone).AddDate(0, 0, -1))
+	for _, p := range partitions {
+		log.Printf("archived %d %s rows for %s to %s", p.Rows, p.Table, p.Date, p.URL)
+	}
+	if errors.Is(err, usecases.ErrUploadsUnavailable) {
+		log.Printf("archive: no object storage configured, skipping")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	return nil
+}
+
+// SampleCoverage samples the realtime coverage of every agency.
+func (a *JobActivities) SampleCoverage(ctx context.Context, at time.Time) error {
+	if err := a.Coverage.Sample(ctx, at); err != nil {
+		return fmt.Errorf("realtime coverage: %w", err)
+	}
+	return nil
+}
+
+// RollupSegments rolls up segment travel and dwell times.
+func (a *JobActivities) RollupSegments(ctx context.Context, at time.Time) error {
+	if err := a.Segments.Rollup(ctx, at); err != nil {
+		return fmt.Errorf("segment rollup: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+
+	"github.com/samirrijal/bilbopass/internal/core/domain"
+)
+
+// Starter implements ports.CompensationStarter by starting CompensationWorkflow,
+// and starts FeedIngestWorkflow and the scheduled jobs.
+type Starter struct {
+	client    client.Client
+	taskQueue string
+}
+
+// NewStarter creates a Starter that schedules workflows on taskQueue.
+func NewStarter(c client.Client, taskQueue string) *Starter {
+	return &Starter{client: c, taskQueue: taskQueue}
+}
+
+// CompensationWorkflowID is the workflow ID for a delay event and user. It is
+// the idempotency key that keeps a user to one coupon per delay event.
+func CompensationWorkflowID(delayEventID, userID string) string {
+	return fmt.Sprintf("compensation-%s-%s", delayEventID, userID)
+}
+
+// StartCompensation starts CompensationWorkflow for req. The workflow ID may
+// never be reused, so a request that was already started, or has finished,
+// is ignored.
+func (s *Starter) StartCompensation(ctx context.Context, req domain.CompensationRequest) error {
+	opts := client.StartWorkflowOptions{
+		ID:                                       CompensationWorkflowID(req.DelayEventID, req.UserID),
+		TaskQueue:                                s.taskQueue,
+		WorkflowIDReusePolicy:                    enumspb.WORKFLOW_ID_REUSE_POLICY_REJECT_DUPLICATE,
+		WorkflowExecutionErrorWhenAlreadyStarted: true,
+	}
+	input := CompensationInput{
+		DelayEventID: req.DelayEventID,
+		UserID:       req.UserID,
+		StopID:       req.StopID,
+		StopLat:      req.StopLat,
+		StopLon:      req.StopLon,
+		DelayMinutes: req.DelayMinutes,
+	}
+	_, err := s.client.ExecuteWorkflow(ctx, opts, CompensationWorkflow, input)
+	if temporal.IsWorkflowExecutionAlreadyStartedError(err) {
+		return nil
+	}
+	return err
+}
+
+// FeedIngestWorkflowID is the workflow ID of an agency's feed ingest, which
+// keeps an agency to one ingest at a time.
+func FeedIngestWorkflowID(slug string) string {
+	return "ingest-" + slug
+}
+
+// StartFeedIngest starts FeedIngestWorkflow for an agency, unless one is
+// still running. Finished ingests do not keep the next from starting.
+func (s *Starter) StartFeedIngest(ctx context.Context, input FeedIngestInput) error {
+	opts := client.StartWorkflowOptions{
+		ID:                                       FeedIngestWorkflowID(input.Slug),
+		TaskQueue:                                s.taskQueue,
+		WorkflowIDReusePolicy:                    enumspb.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE,
+		WorkflowExecutionErrorWhenAlreadyStarted: true,
+	}
+	_, err := s.client.ExecuteWorkflow(ctx, opts, FeedIngestWorkflow, input)
+	if temporal.IsWorkflowExecutionAlreadyStartedError(err) {
+		return nil
+	}
+	return err
+}
+
+// ScheduledJobWorkflowID is the workflow ID of a scheduled job, which keeps
+// it to one cron workflow.
+func ScheduledJobWorkflowID(name string) string {
+	return "job-" + name
+}
+
+// StartScheduledJobs starts a cron workflow for each job, returning how many
+// were started. Jobs already running as defined are left alone; those whose
+// definition changed, which their runs keep as input, are restarted.
+func (s *Starter) StartScheduledJobs(ctx context.Context, jobs []ScheduledJob) (int, error) {
+	started := 0
+	for _, job := range jobs {
+		id := ScheduledJobWorkflowID(job.Name)
+		spec := fmt.Sprintf("%s|%s|%s", job.Cron, job.Activity, job.Timeout)
+		running, err := s.runningSpec(ctx, id)
+		if err != nil {
+			return started, fmt.Errorf("%s: %w", job.Name, err)
+		}
+		if running == spec {
+			continue
+		}
+		if running != "" {
+			if err := s.client.TerminateWorkflow(ctx, id, "", "job changed"); err != nil && !isNotFound(err) {
+				return started, fmt.Errorf("%s: terminate: %w", job.Name, err)
+			}
+		}
+		opts := client.StartWorkflowOptions{
+			ID:                                       id,
+			TaskQueue:                                s.taskQueue,
+			CronSchedule:                             job.Cron,
+			WorkflowIDReusePolicy:                    enumspb.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE,
+			Memo:                                     map[string]any{"job": spec},
+			WorkflowExecutionErrorWhenAlreadyStarted: true,
+		}
+		_, err = s.client.ExecuteWorkflow(ctx, opts, ScheduledJobWorkflow, job)
+		if temporal.IsWorkflowExecutionAlreadyStartedError(err) {
+			continue
+		}
+		if err != nil {
+			return started, fmt.Errorf("%s: %w", job.Name, err)
+		}
+		started++
+	}
+	return started, nil
+}
+
+// runningSpec returns the job definition of the running cron workflow id, as
+// recorded in its memo; empty when it is not running.
+func (s *Starter) runningSpec(ctx context.Context, id string) (string, error) {
+	resp, err := s.client.DescribeWorkflowExecution(ctx, id, "")
+	if isNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	info := resp.GetWorkflowExecutionInfo()
+	if info.GetStatus() != enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING {
+		return "", nil
+	}
+	var spec string
+	if payload := info.GetMemo().GetFields()["job"]; payload != nil {
+		if err := converter.GetDefaultDataConverter().FromPayload(payload, &spec); err != nil {
+			return "", fmt.Errorf("memo: %w", err)
+		}
+	}
+	return spec, nil
+}
+
+func isNotFound(err error) bool {
+	var notFound *serviceerror.NotFound
+	return errors.As(err, &notFound)
+}
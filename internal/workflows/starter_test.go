@@ -0,0 +1,159 @@
+package workflows_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron"
+	commonpb "go.temporal.io/api/common/v1"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+
+	"github.com/samirrijal/bilbopass/internal/workflows"
+)
+
+// fakeTemporal is a Temporal client running cron workflows as far as
+// StartScheduledJobs can tell: by ID, with the memo they were started with.
+type fakeTemporal struct {
+	client.Client
+
+	running     map[string]*commonpb.Memo
+	started     []client.StartWorkflowOptions
+	terminated  []string
+	raceStart   bool // another starter wins the race to start each workflow
+	describeErr error
+}
+
+func (f *fakeTemporal) DescribeWorkflowExecution(_ context.Context, id, _ string) (*workflowservice.DescribeWorkflowExecutionResponse, error) {
+	if f.describeErr != nil {
+		return nil, f.describeErr
+	}
+	memo, ok := f.running[id]
+	if !ok {
+		return nil, serviceerror.NewNotFound("workflow not found")
+	}
+	return &workflowservice.DescribeWorkflowExecutionResponse{
+		WorkflowExecutionInfo: &workflowpb.WorkflowExecutionInfo{
+			Status: enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING,
+			Memo:   memo,
+		},
+	}, nil
+}
+
+func (f *fakeTemporal) TerminateWorkflow(_ context.Context, id, _, _ string, _ ...interface{}) error {
+	f.terminated = append(f.terminated, id)
+	delete(f.running, id)
+	return nil
+}
+
+func (f *fakeTemporal) ExecuteWorkflow(_ context.Context, opts client.StartWorkflowOptions, _ interface{}, _ ...interface{}) (client.WorkflowRun, error) {
+	if f.raceStart {
+		return nil, serviceerror.NewWorkflowExecutionAlreadyStarted("workflow already started", "", "")
+	}
+	if _, ok := f.running[opts.ID]; ok {
+		return nil, serviceerror.NewWorkflowExecutionAlreadyStarted("workflow already started", "", "")
+	}
+	memo := &commonpb.Memo{Fields: map[string]*commonpb.Payload{}}
+	for k, v := range opts.Memo {
+		payload, err := converter.GetDefaultDataConverter().ToPayload(v)
+		if err != nil {
+			return nil, err
+		}
+		memo.Fields[k] = payload
+	}
+	f.running[opts.ID] = memo
+	f.started = append(f.started, opts)
+	return nil, nil
+}
+
+func TestStartScheduledJobs(t *testing.T) {
+	ctx := context.Background()
+	jobs := []workflows.ScheduledJob{
+		{Name: "sla", Cron: "5 * * * *", Activity: "EvaluateSLAs", Timeout: 30 * time.Minute},
+		{Name: "archive", Cron: "CRON_TZ=Europe/Madrid 0 4 * * *", Activity: "ArchiveRealtime", Timeout: 2 * time.Hour},
+	}
+	f := &fakeTemporal{running: map[string]*commonpb.Memo{}}
+	s := workflows.NewStarter(f, "compensation")
+
+	n, err := s.StartScheduledJobs(ctx, jobs)
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 jobs started, got %d, %v", n, err)
+	}
+	for i, opts := range f.started {
+		if opts.ID != "job-"+jobs[i].Name || opts.CronSchedule != jobs[i].Cron || opts.TaskQueue != "compensation" {
+			t.Errorf("job %s started with %+v", jobs[i].Name, opts)
+		}
+	}
+
+	// Starting them again leaves them running.
+	if n, err := s.StartScheduledJobs(ctx, jobs); err != nil || n != 0 {
+		t.Errorf("expected running jobs left alone, got %d started, %v", n, err)
+	}
+	if len(f.started) != 2 || len(f.terminated) != 0 {
+		t.Errorf("expected no jobs restarted, got %d started and %v terminated", len(f.started), f.terminated)
+	}
+
+	// A changed job is restarted.
+	jobs[0].Cron = "10 * * * *"
+	if n, err := s.StartScheduledJobs(ctx, jobs); err != nil || n != 1 {
+		t.Errorf("expected the changed job restarted, got %d started, %v", n, err)
+	}
+	if len(f.terminated) != 1 || f.terminated[0] != "job-sla" || f.started[2].CronSchedule != "10 * * * *" {
+		t.Errorf("expected job-sla terminated and restarted, got %v terminated, %+v started", f.terminated, f.started[2:])
+	}
+}
+
+func TestStartScheduledJobs_AlreadyStarted(t *testing.T) {
+	// Another compensator starts the jobs between the lookup and the start.
+	f := &fakeTemporal{running: map[string]*commonpb.Memo{}, raceStart: true}
+	n, err := workflows.NewStarter(f, "compensation").StartScheduledJobs(context.Background(), workflows.ScheduledJobs)
+	if err != nil || n != 0 {
+		t.Errorf("expected jobs already started ignored, got %d started, %v", n, err)
+	}
+}
+
+func TestStartScheduledJobs_Error(t *testing.T) {
+	f := &fakeTemporal{running: map[string]*commonpb.Memo{}, describeErr: errors.New("unavailable")}
+	_, err := workflows.NewStarter(f, "compensation").StartScheduledJobs(context.Background(), workflows.ScheduledJobs)
+	if err == nil || !strings.HasPrefix(err.Error(), workflows.ScheduledJobs[0].Name+": ") {
+		t.Errorf("expected the job's error, got %v", err)
+	}
+}
+
+// TestScheduledJobs checks the jobs' cron specs with the parser of
+// Temporal's test environment, which takes no CRON_TZ; the zone is
+// checked apart.
+func TestScheduledJobs(t *testing.T) {
+	names := map[string]bool{}
+	for _, job := range workflows.ScheduledJobs {
+		if names[job.Name] {
+			t.Errorf("%s: two jobs have the name", job.Name)
+		}
+		names[job.Name] = true
+		if job.Activity == "" || job.Timeout <= 0 {
+			t.Errorf("%s: expected an activity and a timeout, got %+v", job.Name, job)
+		}
+
+		spec := job.Cron
+		if tz, ok := strings.CutPrefix(spec, "CRON_TZ="); ok {
+			zone, rest, _ := strings.Cut(tz, " ")
+			if zone != workflows.ScheduleTimezone {
+				t.Errorf("%s: expected %s, got %s", job.Name, workflows.ScheduleTimezone, zone)
+			}
+			if _, err := time.LoadLocation(zone); err != nil {
+				t.Errorf("%s: %v", job.Name, err)
+			}
+			spec = rest
+		}
+		if _, err := cron.ParseStandard(spec); err != nil {
+			t.Errorf("%s: %q: %v", job.Name, job.Cron, err)
+		}
+	}
+}
@@ -96,7 +96,8 @@ SELECT
     AVG(speed) as avg_speed,
     COUNT(*) as position_count
 FROM vehicle_positions
-GROUP BY bucket, vehicle_id, route_id;
+GROUP BY bucket, vehicle_id, route_id
+WITH NO DATA;
 
 CREATE TABLE delay_events (
     id UUID DEFAULT gen_random_uuid(),
@@ -0,0 +1,5 @@
+CREATE TABLE stop_short_links (
+    code TEXT PRIMARY KEY,
+    stop_id UUID NOT NULL UNIQUE REFERENCES stops(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
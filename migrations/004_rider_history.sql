@@ -0,0 +1,22 @@
+CREATE TABLE history_consents (
+    user_id TEXT PRIMARY KEY,
+    granted_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE rider_journeys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id TEXT NOT NULL,
+    trip_id UUID REFERENCES trips(id) ON DELETE SET NULL,
+    route_id UUID REFERENCES routes(id) ON DELETE SET NULL,
+    route_type INT,
+    from_stop_id UUID REFERENCES stops(id) ON DELETE SET NULL,
+    to_stop_id UUID REFERENCES stops(id) ON DELETE SET NULL,
+    departed_at TIMESTAMPTZ NOT NULL,
+    arrived_at TIMESTAMPTZ,
+    distance_meters FLOAT DEFAULT 0,
+    co2_saved_grams FLOAT DEFAULT 0,
+    source TEXT NOT NULL DEFAULT 'planned',
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_rider_journeys_user ON rider_journeys(user_id, departed_at DESC);
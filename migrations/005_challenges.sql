@@ -0,0 +1,28 @@
+CREATE TABLE challenges (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    slug TEXT UNIQUE NOT NULL,
+    title TEXT NOT NULL,
+    description TEXT,
+    metric TEXT NOT NULL,          -- trips | distance_meters | co2_saved_grams
+    target FLOAT NOT NULL,
+    route_type INT,                -- only count journeys of this GTFS route_type
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    reward_text TEXT,
+    active BOOLEAN DEFAULT true,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX idx_challenges_window ON challenges(starts_at, ends_at) WHERE active = true;
+
+CREATE TABLE challenge_enrollments (
+    challenge_id UUID NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    enrolled_at TIMESTAMPTZ DEFAULT NOW(),
+    rewarded_at TIMESTAMPTZ,
+    reward_code TEXT,
+    PRIMARY KEY (challenge_id, user_id)
+);
+
+CREATE INDEX idx_challenge_enrollments_user ON challenge_enrollments(user_id);
@@ -0,0 +1,17 @@
+CREATE TABLE stop_time_predictions (
+    time TIMESTAMPTZ NOT NULL,               -- feed timestamp of the trip update
+    trip_id UUID NOT NULL REFERENCES trips(id),
+    stop_id UUID NOT NULL REFERENCES stops(id),
+    stop_sequence INT NOT NULL,
+    arrival_delay INT,                       -- seconds
+    departure_delay INT,                     -- seconds
+    predicted_arrival TIMESTAMPTZ,
+    predicted_departure TIMESTAMPTZ,
+    schedule_relationship INT DEFAULT 0      -- GTFS-RT StopTimeUpdate.ScheduleRelationship
+);
+
+SELECT create_hypertable('stop_time_predictions', 'time');
+
+SELECT add_retention_policy('stop_time_predictions', INTERVAL '7 days');
+
+CREATE INDEX idx_stop_time_predictions_stop ON stop_time_predictions(stop_id, time DESC);
@@ -0,0 +1,34 @@
+CREATE TABLE sla_contracts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    agency_id UUID NOT NULL REFERENCES agencies(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    threshold_seconds INT NOT NULL,     -- a departure is on time if delayed at most this much
+    target_percent FLOAT NOT NULL,      -- committed share of on-time departures
+    active BOOLEAN DEFAULT true,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (threshold_seconds > 0),
+    CHECK (target_percent > 0 AND target_percent <= 100)
+);
+
+CREATE INDEX idx_sla_contracts_agency ON sla_contracts(agency_id) WHERE active = true;
+
+-- Daily rollup of stop_time_predictions, kept after the raw predictions expire.
+CREATE TABLE sla_daily_stats (
+    contract_id UUID NOT NULL REFERENCES sla_contracts(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    observed INT NOT NULL,
+    on_time INT NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (contract_id, day)
+);
+
+CREATE TABLE sla_evaluations (
+    contract_id UUID NOT NULL REFERENCES sla_contracts(id) ON DELETE CASCADE,
+    month TEXT NOT NULL,                -- YYYY-MM
+    observed INT NOT NULL,
+    on_time INT NOT NULL,
+    percent FLOAT NOT NULL,
+    breached BOOLEAN NOT NULL,
+    evaluated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (contract_id, month)
+);
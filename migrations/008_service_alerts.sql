@@ -0,0 +1,22 @@
+CREATE TABLE service_alerts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    agency_id UUID NOT NULL REFERENCES agencies(id) ON DELETE CASCADE,
+    source TEXT NOT NULL DEFAULT 'gtfs-rt',
+    source_id TEXT NOT NULL,                 -- feed entity id
+    cause TEXT,
+    effect TEXT,
+    header JSONB DEFAULT '{}',               -- language -> text
+    description JSONB DEFAULT '{}',
+    url JSONB DEFAULT '{}',
+    active_periods JSONB DEFAULT '[]',       -- [{"start": ts, "end": ts}], empty = always
+    route_ids UUID[] DEFAULT '{}',
+    stop_ids UUID[] DEFAULT '{}',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    removed_at TIMESTAMPTZ,                  -- set when the alert leaves the feed
+    UNIQUE(agency_id, source, source_id)
+);
+
+CREATE INDEX idx_service_alerts_live ON service_alerts(agency_id) WHERE removed_at IS NULL;
+CREATE INDEX idx_service_alerts_routes ON service_alerts USING GIN(route_ids);
+CREATE INDEX idx_service_alerts_stops ON service_alerts USING GIN(stop_ids);
@@ -0,0 +1,13 @@
+-- Hourly mean delay per route, rolled up from stop_time_predictions and kept
+-- long enough to build per-hour-of-day baselines.
+CREATE TABLE route_delay_hourly (
+    hour TIMESTAMPTZ NOT NULL,
+    route_id UUID NOT NULL REFERENCES routes(id) ON DELETE CASCADE,
+    mean_delay FLOAT NOT NULL,          -- seconds
+    samples INT NOT NULL,               -- trip stops with a prediction
+    PRIMARY KEY (route_id, hour)
+);
+
+SELECT create_hypertable('route_delay_hourly', 'hour');
+
+SELECT add_retention_policy('route_delay_hourly', INTERVAL '90 days');
@@ -0,0 +1,3 @@
+-- The realtime poller records at most one delay event per trip and stop within
+-- a window; this index backs that lookup.
+CREATE INDEX idx_delay_events_trip_stop ON delay_events(trip_id, stop_id, time DESC);
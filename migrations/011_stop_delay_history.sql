@@ -0,0 +1,16 @@
+-- Final observed delay of each trip at each stop, kept long enough to learn
+-- weekly patterns (stop_time_predictions only keeps 7 days).
+CREATE TABLE stop_delay_observations (
+    time TIMESTAMPTZ NOT NULL,               -- feed timestamp of the last prediction for the trip stop
+    route_id UUID NOT NULL REFERENCES routes(id),
+    stop_id UUID NOT NULL REFERENCES stops(id),
+    trip_id UUID NOT NULL REFERENCES trips(id),
+    delay INT NOT NULL                       -- seconds
+);
+
+SELECT create_hypertable('stop_delay_observations', 'time');
+
+SELECT add_retention_policy('stop_delay_observations', INTERVAL '90 days');
+
+CREATE UNIQUE INDEX idx_stop_delay_observations_trip ON stop_delay_observations(trip_id, stop_id, time);
+CREATE INDEX idx_stop_delay_observations_stop ON stop_delay_observations(stop_id, time DESC);
@@ -0,0 +1,2 @@
+-- Delayed trips are matched against the riders who were on them.
+CREATE INDEX idx_rider_journeys_trip ON rider_journeys(trip_id, departed_at);
@@ -0,0 +1,21 @@
+-- Trip and stop IDs referenced by each agency's GTFS-RT trip updates, counted
+-- per day by the realtime poller.
+CREATE TABLE rt_feed_ids (
+    agency_id UUID NOT NULL REFERENCES agencies(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('trip', 'stop')),
+    rt_id TEXT NOT NULL,
+    occurrences INT NOT NULL DEFAULT 1,
+    last_seen TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (agency_id, day, kind, rt_id)
+);
+
+-- Nightly reconciliation of rt_feed_ids against the static schedule.
+CREATE TABLE feed_quality_reports (
+    agency_id UUID NOT NULL REFERENCES agencies(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    trip_ids JSONB NOT NULL,               -- domain.IDMatchStats
+    stop_ids JSONB NOT NULL,               -- domain.IDMatchStats
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (agency_id, day)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE users (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    email TEXT NOT NULL,                     -- stored lower-cased
+    password_hash TEXT NOT NULL,             -- bcrypt
+    display_name TEXT,
+    role TEXT NOT NULL DEFAULT 'rider',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_users_email ON users(email);
@@ -0,0 +1,6 @@
+-- Per-agency GTFS-RT feed settings managed through the admin API.
+CREATE TABLE agency_feed_configs (
+    agency_id UUID PRIMARY KEY REFERENCES agencies(id) ON DELETE CASCADE,
+    id_rules JSONB NOT NULL DEFAULT '[]',    -- []domain.IDRule, applied in order
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
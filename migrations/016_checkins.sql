@@ -0,0 +1,12 @@
+-- Riders declare the trip they boarded so delays can be attributed to them.
+CREATE TABLE checkins (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id TEXT NOT NULL,
+    trip_id UUID NOT NULL REFERENCES trips(id) ON DELETE CASCADE,
+    stop_id UUID NOT NULL REFERENCES stops(id) ON DELETE CASCADE,
+    boarded_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_checkins_user ON checkins(user_id, boarded_at DESC);
+CREATE INDEX idx_checkins_trip ON checkins(trip_id, boarded_at);
@@ -0,0 +1,6 @@
+-- Staff accounts of affiliate terminals (role 'affiliate') belong to one
+-- affiliate and may only verify and redeem its coupons.
+ALTER TABLE users ADD COLUMN affiliate_id UUID REFERENCES affiliates(id) ON DELETE SET NULL;
+
+-- Riders list their own coupons, newest first.
+CREATE INDEX idx_compensations_user_issued ON compensations(user_id, issued_at DESC);
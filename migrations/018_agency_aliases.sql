@@ -0,0 +1,13 @@
+-- Agencies that a feed duplicates, e.g. operators inside a consortium feed
+-- that are also ingested from their own feed. Routes of source_agency_id in
+-- the feed source_slug are left to the canonical agency_id; an empty
+-- source_agency_id aliases the whole feed.
+CREATE TABLE agency_aliases (
+    source_slug TEXT NOT NULL,
+    source_agency_id TEXT NOT NULL DEFAULT '',
+    agency_id UUID NOT NULL REFERENCES agencies(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (source_slug, source_agency_id)
+);
+
+CREATE INDEX idx_agency_aliases_agency ON agency_aliases(agency_id);
@@ -0,0 +1,3 @@
+-- affiliates was created with the core tables; the management API also
+-- tracks when an affiliate was last edited.
+ALTER TABLE affiliates ADD COLUMN updated_at TIMESTAMPTZ DEFAULT NOW();
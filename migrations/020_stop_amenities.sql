@@ -0,0 +1,12 @@
+-- Stop amenities. NULL means unknown. amenities_source records who set them
+-- last (agency, osm, admin or community); feed and OSM imports never
+-- overwrite admin or community edits.
+ALTER TABLE stops
+    ADD COLUMN shelter BOOLEAN,
+    ADD COLUMN bench BOOLEAN,
+    ADD COLUMN realtime_display BOOLEAN,
+    ADD COLUMN amenities_source TEXT,
+    ADD COLUMN amenities_updated_at TIMESTAMPTZ;
+
+-- Bad-weather routing asks for sheltered stops nearby.
+CREATE INDEX idx_stops_location_shelter ON stops USING GIST(location) WHERE shelter;
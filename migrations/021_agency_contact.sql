@@ -0,0 +1,6 @@
+-- Customer service details apps show in "contact the operator" flows,
+-- managed through the admin API.
+ALTER TABLE agencies
+    ADD COLUMN contact_phone TEXT,
+    ADD COLUMN lost_found_url TEXT,
+    ADD COLUMN complaint_url TEXT;
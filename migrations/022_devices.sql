@@ -0,0 +1,15 @@
+-- Rider devices registered for push notifications. A token belongs to one
+-- rider at a time; registering it again moves it to the new account.
+CREATE TABLE devices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    platform TEXT NOT NULL,                  -- fcm | webpush
+    token TEXT NOT NULL,                     -- FCM registration token or Web Push endpoint
+    p256dh TEXT,                             -- Web Push subscription keys
+    auth TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_devices_token ON devices(token);
+CREATE INDEX idx_devices_user ON devices(user_id);
@@ -0,0 +1,15 @@
+-- Stops, routes and journeys (origin and destination stop) riders saved for
+-- quick access from their dashboard.
+CREATE TABLE user_favorites (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('stop', 'route', 'journey')),
+    stop_id UUID REFERENCES stops(id) ON DELETE CASCADE,      -- stop, or journey origin
+    to_stop_id UUID REFERENCES stops(id) ON DELETE CASCADE,   -- journey destination
+    route_id UUID REFERENCES routes(id) ON DELETE CASCADE,
+    label TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE NULLS NOT DISTINCT (user_id, kind, stop_id, to_stop_id, route_id)
+);
+
+CREATE INDEX idx_user_favorites_user ON user_favorites(user_id, created_at);
@@ -0,0 +1,22 @@
+-- Planned events (matches, festivals, concerts) with the service changes
+-- operators schedule around them. The overlay applies to stops inside the
+-- geofence between service_from and service_until.
+CREATE TABLE events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL,                       -- sport | festival | concert | other
+    venue TEXT,
+    location GEOGRAPHY(Point, 4326) NOT NULL,
+    radius_meters INTEGER NOT NULL,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    service_from TIMESTAMPTZ NOT NULL,
+    service_until TIMESTAMPTZ NOT NULL,
+    description JSONB NOT NULL DEFAULT '{}',  -- by language code
+    impacts JSONB NOT NULL DEFAULT '[]',      -- [{effect, route_id, stop_id, note}]
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_events_service ON events(service_from, service_until);
+CREATE INDEX idx_events_location ON events USING GIST(location);
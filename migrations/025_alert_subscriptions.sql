@@ -0,0 +1,28 @@
+-- Riders' delay alert subscriptions: a route, a stop, or a route at a stop,
+-- on some weekdays within a local time window (e.g. L3, Mon-Fri 07:30-09:00).
+CREATE TABLE alert_subscriptions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    route_id UUID REFERENCES routes(id) ON DELETE CASCADE,
+    stop_id UUID REFERENCES stops(id) ON DELETE CASCADE,
+    days INTEGER[] NOT NULL,                 -- ISO weekdays, 1 = Monday
+    start_time TIME NOT NULL,                -- agency local time
+    end_time TIME NOT NULL,                  -- exclusive; before start_time wraps past midnight,
+                                             -- equal to start_time means all day
+    min_delay_seconds INTEGER NOT NULL DEFAULT 300,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (route_id IS NOT NULL OR stop_id IS NOT NULL)
+);
+
+CREATE INDEX idx_alert_subscriptions_user ON alert_subscriptions(user_id);
+CREATE INDEX idx_alert_subscriptions_route ON alert_subscriptions(route_id);
+CREATE INDEX idx_alert_subscriptions_stop ON alert_subscriptions(stop_id);
+
+-- Last alert per subscription and trip, so one delayed trip is pushed once
+-- rather than at every stop it is late at.
+CREATE TABLE alert_subscription_sends (
+    subscription_id UUID NOT NULL REFERENCES alert_subscriptions(id) ON DELETE CASCADE,
+    trip_id UUID NOT NULL,
+    sent_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (subscription_id, trip_id)
+);
@@ -0,0 +1,17 @@
+-- Live availability of wheelchair and stroller spaces on board, reported by
+-- vehicles (AVL systems) or by riders on the trip.
+CREATE TABLE accessible_space_reports (
+    time TIMESTAMPTZ NOT NULL,
+    trip_id UUID NOT NULL REFERENCES trips(id),
+    vehicle_id TEXT,
+    source TEXT NOT NULL,                    -- vehicle | rider
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    wheelchair TEXT,                         -- available | full; NULL if not reported
+    stroller TEXT
+);
+
+SELECT create_hypertable('accessible_space_reports', 'time');
+
+SELECT add_retention_policy('accessible_space_reports', INTERVAL '7 days');
+
+CREATE INDEX idx_accessible_space_reports_trip ON accessible_space_reports(trip_id, time DESC);
@@ -0,0 +1,12 @@
+-- Favorited stops and routes as the last GTFS ingest saw them. The ingestor
+-- compares each new feed against this to tell riders about removed stops,
+-- renamed lines and timetable overhauls.
+CREATE TABLE favorite_feed_states (
+    kind TEXT NOT NULL CHECK (kind IN ('stop', 'route')),
+    subject_id UUID NOT NULL,              -- stops.id or routes.id
+    name TEXT NOT NULL,
+    trips INTEGER NOT NULL DEFAULT 0,      -- scheduled trips in the feed (routes)
+    present BOOLEAN NOT NULL DEFAULT TRUE, -- false once dropped from the feed
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (kind, subject_id)
+);
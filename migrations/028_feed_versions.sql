@@ -0,0 +1,56 @@
+-- Each GTFS feed an agency published, and the stops, routes and trip
+-- timetables as each version had them, so the API can answer as of a past
+-- date. History rows are open (valid_until IS NULL) while their version is
+-- current; a changed or dropped entity closes its row when the next version
+-- is ingested. Nothing is recorded before the first versioned ingest.
+CREATE TABLE feed_versions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    agency_id UUID NOT NULL REFERENCES agencies(id) ON DELETE CASCADE,
+    sha256 TEXT NOT NULL,                  -- of the GTFS zip
+    activated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    stops INTEGER NOT NULL DEFAULT 0,
+    routes INTEGER NOT NULL DEFAULT 0,
+    trips INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_feed_versions_agency ON feed_versions(agency_id, activated_at DESC);
+
+CREATE TABLE stop_history (
+    stop_id UUID NOT NULL REFERENCES stops(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    lat DOUBLE PRECISION NOT NULL,
+    lon DOUBLE PRECISION NOT NULL,
+    platform_code TEXT,
+    wheelchair_accessible BOOLEAN NOT NULL DEFAULT FALSE,
+    valid_from TIMESTAMPTZ NOT NULL,
+    valid_until TIMESTAMPTZ
+);
+
+CREATE INDEX idx_stop_history_stop ON stop_history(stop_id, valid_from DESC);
+
+CREATE TABLE route_history (
+    route_id UUID NOT NULL REFERENCES routes(id) ON DELETE CASCADE,
+    short_name TEXT,
+    long_name TEXT NOT NULL,
+    route_type INTEGER NOT NULL,
+    color TEXT,
+    text_color TEXT,
+    valid_from TIMESTAMPTZ NOT NULL,
+    valid_until TIMESTAMPTZ
+);
+
+CREATE INDEX idx_route_history_route ON route_history(route_id, valid_from DESC);
+
+-- stop_times holds the trip's timetable as a JSON array of
+-- {stop_id, arrival, departure, stop_sequence, pickup_type, drop_off_type},
+-- times in seconds after midnight.
+CREATE TABLE trip_history (
+    trip_id UUID NOT NULL REFERENCES trips(id) ON DELETE CASCADE,
+    service_id TEXT NOT NULL,
+    headsign TEXT,
+    stop_times JSONB NOT NULL DEFAULT '[]',
+    valid_from TIMESTAMPTZ NOT NULL,
+    valid_until TIMESTAMPTZ
+);
+
+CREATE INDEX idx_trip_history_trip ON trip_history(trip_id, valid_from DESC);
@@ -0,0 +1,16 @@
+-- Agency logos, colors and route icons managed through the admin API. The
+-- images live in object storage; only their public URLs are kept here.
+CREATE TABLE agency_branding (
+    agency_id UUID PRIMARY KEY REFERENCES agencies(id) ON DELETE CASCADE,
+    logo_url TEXT,
+    primary_color TEXT,                    -- RRGGBB
+    secondary_color TEXT,
+    text_color TEXT,
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE route_icons (
+    route_id UUID PRIMARY KEY REFERENCES routes(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
@@ -0,0 +1,13 @@
+-- Outcome of the realtime poller's latest fetch of each GTFS-RT feed, so the
+-- API can report feed health without talking to the poller.
+CREATE TABLE rt_feed_status (
+    agency_id UUID NOT NULL REFERENCES agencies(id) ON DELETE CASCADE,
+    feed TEXT NOT NULL CHECK (feed IN ('vehicle_positions', 'trip_updates', 'alerts')),
+    last_poll_at TIMESTAMPTZ NOT NULL,
+    last_success_at TIMESTAMPTZ,
+    feed_timestamp TIMESTAMPTZ,            -- header timestamp of the last successful poll
+    entities INT NOT NULL DEFAULT 0,       -- entities in the last successful poll
+    failures INT NOT NULL DEFAULT 0,       -- consecutive failed polls
+    last_error TEXT,
+    PRIMARY KEY (agency_id, feed)
+);
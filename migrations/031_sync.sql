@@ -0,0 +1,73 @@
+-- Change tracking for the delta sync API. The ingestor and the realtime
+-- poller rewrite unchanged rows on every run, so these columns are moved by
+-- triggers only when a row's content changes.
+ALTER TABLE stops ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+ALTER TABLE routes ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+-- service_alerts.updated_at is bumped on every poll; changed_at is not.
+ALTER TABLE service_alerts ADD COLUMN changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+CREATE INDEX idx_stops_sync ON stops(updated_at, id);
+CREATE INDEX idx_routes_sync ON routes(updated_at, id);
+CREATE INDEX idx_service_alerts_sync ON service_alerts(changed_at, id);
+
+CREATE FUNCTION touch_stop() RETURNS trigger AS $$
+BEGIN
+    IF (NEW.name, NEW.location::text, NEW.platform_code, NEW.wheelchair_accessible, NEW.metadata,
+        NEW.shelter, NEW.bench, NEW.realtime_display)
+       IS DISTINCT FROM
+       (OLD.name, OLD.location::text, OLD.platform_code, OLD.wheelchair_accessible, OLD.metadata,
+        OLD.shelter, OLD.bench, OLD.realtime_display) THEN
+        NEW.updated_at := clock_timestamp();
+    END IF;
+    RETURN NEW;
+END $$ LANGUAGE plpgsql;
+
+CREATE FUNCTION touch_route() RETURNS trigger AS $$
+BEGIN
+    IF (NEW.short_name, NEW.long_name, NEW.route_type, NEW.color, NEW.text_color, NEW.shape::text)
+       IS DISTINCT FROM
+       (OLD.short_name, OLD.long_name, OLD.route_type, OLD.color, OLD.text_color, OLD.shape::text) THEN
+        NEW.updated_at := clock_timestamp();
+    END IF;
+    RETURN NEW;
+END $$ LANGUAGE plpgsql;
+
+CREATE FUNCTION touch_service_alert() RETURNS trigger AS $$
+BEGIN
+    IF (NEW.cause, NEW.effect, NEW.header, NEW.description, NEW.url, NEW.active_periods,
+        NEW.route_ids, NEW.stop_ids, NEW.removed_at IS NULL)
+       IS DISTINCT FROM
+       (OLD.cause, OLD.effect, OLD.header, OLD.description, OLD.url, OLD.active_periods,
+        OLD.route_ids, OLD.stop_ids, OLD.removed_at IS NULL) THEN
+        NEW.changed_at := clock_timestamp();
+    END IF;
+    RETURN NEW;
+END $$ LANGUAGE plpgsql;
+
+CREATE TRIGGER stops_touch BEFORE UPDATE ON stops
+    FOR EACH ROW EXECUTE FUNCTION touch_stop();
+CREATE TRIGGER routes_touch BEFORE UPDATE ON routes
+    FOR EACH ROW EXECUTE FUNCTION touch_route();
+CREATE TRIGGER service_alerts_touch BEFORE UPDATE ON service_alerts
+    FOR EACH ROW EXECUTE FUNCTION touch_service_alert();
+
+-- Deleted stops and routes, so clients can drop them.
+CREATE TABLE sync_deletions (
+    entity TEXT NOT NULL CHECK (entity IN ('stop', 'route')),
+    id UUID NOT NULL,
+    agency_id UUID,                        -- no FK: kept after the agency is gone
+    deleted_at TIMESTAMPTZ NOT NULL DEFAULT clock_timestamp()
+);
+
+CREATE INDEX idx_sync_deletions ON sync_deletions(entity, deleted_at, id);
+
+CREATE FUNCTION record_sync_deletion() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO sync_deletions (entity, id, agency_id) VALUES (TG_ARGV[0], OLD.id, OLD.agency_id);
+    RETURN OLD;
+END $$ LANGUAGE plpgsql;
+
+CREATE TRIGGER stops_sync_deletion AFTER DELETE ON stops
+    FOR EACH ROW EXECUTE FUNCTION record_sync_deletion('stop');
+CREATE TRIGGER routes_sync_deletion AFTER DELETE ON routes
+    FOR EACH ROW EXECUTE FUNCTION record_sync_deletion('route');
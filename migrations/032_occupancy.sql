@@ -0,0 +1,29 @@
+-- Occupancy and crowding. Vehicle positions record the stop the vehicle is
+-- at or approaching, resolved against its trip, so the occupancy feeds
+-- report can be aggregated per stop. No foreign key: deleting a stop must
+-- not wait on the hypertable.
+ALTER TABLE vehicle_positions ADD COLUMN stop_id UUID;
+
+CREATE INDEX idx_vehicle_positions_trip ON vehicle_positions(trip_id, time DESC);
+
+-- Hourly occupancy per stop and route. Raw positions are kept for 7 days;
+-- the aggregate is refreshed well inside that and kept for much longer.
+CREATE MATERIALIZED VIEW stop_occupancy_hourly
+WITH (timescaledb.continuous) AS
+SELECT
+    time_bucket('1 hour', time) AS bucket,
+    stop_id,
+    route_id,
+    AVG(occupancy_status)::float8 AS avg_occupancy,
+    COUNT(*) AS samples
+FROM vehicle_positions
+WHERE stop_id IS NOT NULL AND occupancy_status IS NOT NULL
+GROUP BY bucket, stop_id, route_id
+WITH NO DATA;
+
+SELECT add_continuous_aggregate_policy('stop_occupancy_hourly',
+    start_offset => INTERVAL '3 days',
+    end_offset => INTERVAL '1 hour',
+    schedule_interval => INTERVAL '30 minutes');
+
+SELECT add_retention_policy('stop_occupancy_hourly', INTERVAL '180 days');
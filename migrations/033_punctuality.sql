@@ -0,0 +1,29 @@
+-- On-time performance. Hourly punctuality per route and stop, aggregated from
+-- stop_delay_observations and kept well past its 90 days. A trip stop is on
+-- time from 1 minute early to 5 minutes late.
+CREATE MATERIALIZED VIEW stop_punctuality_hourly
+WITH (timescaledb.continuous) AS
+SELECT
+    time_bucket('1 hour', time) AS bucket,
+    route_id,
+    stop_id,
+    COUNT(*) AS observed,
+    SUM(CASE WHEN delay BETWEEN -60 AND 300 THEN 1 ELSE 0 END) AS on_time,
+    SUM(CASE WHEN delay < -60 THEN 1 ELSE 0 END) AS early,
+    SUM(CASE WHEN delay > 300 THEN 1 ELSE 0 END) AS late,
+    SUM(delay)::float8 AS delay_sum,
+    MAX(delay) AS max_delay
+FROM stop_delay_observations
+GROUP BY bucket, route_id, stop_id
+WITH NO DATA;
+
+-- Observations are recorded once their hour has passed; later inserts into
+-- a refreshed hour are picked up by the next refresh inside start_offset.
+SELECT add_continuous_aggregate_policy('stop_punctuality_hourly',
+    start_offset => INTERVAL '3 days',
+    end_offset => INTERVAL '1 hour',
+    schedule_interval => INTERVAL '1 hour');
+
+SELECT add_retention_policy('stop_punctuality_hourly', INTERVAL '400 days');
+
+CREATE INDEX idx_stop_punctuality_hourly_route ON stop_punctuality_hourly(route_id, bucket);
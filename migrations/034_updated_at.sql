@@ -0,0 +1,19 @@
+-- Change tracking for agencies and trips, like stops and routes in 031.
+-- Upserts rewrite unchanged rows, so updated_at only moves when a row's
+-- content does.
+ALTER TABLE agencies ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+ALTER TABLE trips ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+CREATE FUNCTION touch_updated_at() RETURNS trigger AS $$
+BEGIN
+    NEW.updated_at := OLD.updated_at;
+    IF NEW IS DISTINCT FROM OLD THEN
+        NEW.updated_at := clock_timestamp();
+    END IF;
+    RETURN NEW;
+END $$ LANGUAGE plpgsql;
+
+CREATE TRIGGER agencies_touch BEFORE UPDATE ON agencies
+    FOR EACH ROW EXECUTE FUNCTION touch_updated_at();
+CREATE TRIGGER trips_touch BEFORE UPDATE ON trips
+    FOR EACH ROW EXECUTE FUNCTION touch_updated_at();
@@ -0,0 +1,10 @@
+-- When the ingestor last finished each agency's static feed, changed or not,
+-- for the feed health gauges on /metrics. feed_versions only records feeds
+-- that changed.
+CREATE TABLE feed_ingests (
+    agency_id UUID PRIMARY KEY REFERENCES agencies(id) ON DELETE CASCADE,
+    last_ingest_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO feed_ingests (agency_id, last_ingest_at)
+SELECT agency_id, MAX(activated_at) FROM feed_versions GROUP BY agency_id;
@@ -0,0 +1,27 @@
+-- Agencies can require an admin to approve each new static feed before it
+-- replaces the live schedule.
+ALTER TABLE agency_feed_configs ADD COLUMN require_approval BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Static feeds held for approval, and the last applied feeds of each agency
+-- that a rollback re-applies. The ingestor stages new feeds of agencies that
+-- require approval and applies approved ones with `ingestor activate`.
+-- Rejected stages keep only their checksum, so the same feed is not staged
+-- again.
+CREATE TABLE feed_stages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    agency_id UUID NOT NULL REFERENCES agencies(id) ON DELETE CASCADE,
+    sha256 TEXT NOT NULL,                  -- of the GTFS zip
+    feed BYTEA NOT NULL,                   -- the GTFS zip
+    stops INTEGER NOT NULL DEFAULT 0,
+    routes INTEGER NOT NULL DEFAULT 0,
+    trips INTEGER NOT NULL DEFAULT 0,
+    status TEXT NOT NULL DEFAULT 'staged'
+        CHECK (status IN ('staged', 'approved', 'rejected', 'applied')),
+    rollback BOOLEAN NOT NULL DEFAULT FALSE,
+    staged_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    decided_at TIMESTAMPTZ,
+    applied_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_feed_stages_agency ON feed_stages(agency_id, staged_at DESC);
+CREATE INDEX idx_feed_stages_approved ON feed_stages(decided_at) WHERE status = 'approved';
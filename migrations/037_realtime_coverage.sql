@@ -0,0 +1,18 @@
+-- Realtime coverage: how many of each route's running trips had a vehicle
+-- position or trip update, sampled by the realtime poller. Trips run from
+-- their first departure to their last arrival, in the agency's time zone;
+-- there is no service calendar, so every trip is taken to run every day.
+CREATE TABLE realtime_coverage (
+    time TIMESTAMPTZ NOT NULL,
+    agency_id UUID NOT NULL,               -- no FK: deleting an agency must not wait on the hypertable
+    route_id UUID NOT NULL,
+    scheduled INT NOT NULL,                -- trips running at time
+    live INT NOT NULL                      -- of those, trips with realtime data
+);
+
+SELECT create_hypertable('realtime_coverage', 'time');
+
+SELECT add_retention_policy('realtime_coverage', INTERVAL '30 days');
+
+CREATE INDEX idx_realtime_coverage_agency ON realtime_coverage(agency_id, time DESC);
+CREATE INDEX idx_realtime_coverage_route ON realtime_coverage(route_id, time DESC);
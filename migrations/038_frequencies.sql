@@ -0,0 +1,12 @@
+-- GTFS frequencies.txt: trips run every headway_secs from start_time until
+-- end_time instead of once. The trip's stop times give the times between
+-- stops, starting from its first departure. Without exact_times, the trips
+-- run about that often rather than to a timetable.
+CREATE TABLE frequencies (
+    trip_id UUID NOT NULL REFERENCES trips(id) ON DELETE CASCADE,
+    start_time INTERVAL NOT NULL,
+    end_time INTERVAL NOT NULL,
+    headway_secs INT NOT NULL CHECK (headway_secs > 0),
+    exact_times BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (trip_id, start_time)
+);
@@ -0,0 +1,19 @@
+-- Travel times between consecutive stops and dwell times at stops, per route
+-- and hour, derived from vehicle positions (kept only 7 days) by the realtime
+-- poller. A vehicle is at a stop while its positions are within 40 m of it;
+-- a segment counts in the hour the vehicle left its first stop.
+CREATE TABLE route_segment_hourly (
+    hour TIMESTAMPTZ NOT NULL,
+    route_id UUID NOT NULL,                -- no FK: deleting a route must not wait on the hypertable
+    from_stop_id UUID NOT NULL,
+    to_stop_id UUID NOT NULL,
+    samples INT NOT NULL,                  -- trips seen at both stops
+    travel_secs FLOAT NOT NULL,            -- summed, from leaving from_stop to reaching to_stop
+    dwell_samples INT NOT NULL,            -- of those, trips seen at the stop before from_stop
+    dwell_secs FLOAT NOT NULL,             -- summed over dwell_samples, at from_stop
+    PRIMARY KEY (route_id, from_stop_id, to_stop_id, hour)
+);
+
+SELECT create_hypertable('route_segment_hourly', 'hour');
+
+SELECT add_retention_policy('route_segment_hourly', INTERVAL '400 days');
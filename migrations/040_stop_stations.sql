@@ -0,0 +1,9 @@
+-- GTFS stations and their platforms. location_type is the GTFS value: 0 a
+-- stop or platform, 1 a station, 2 an entrance or exit, 3 a generic node, 4
+-- a boarding area. parent_id is the station a platform, entrance or node
+-- belongs to, or the platform of a boarding area.
+ALTER TABLE stops
+    ADD COLUMN location_type SMALLINT NOT NULL DEFAULT 0,
+    ADD COLUMN parent_id UUID REFERENCES stops(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_stops_parent ON stops(parent_id) WHERE parent_id IS NOT NULL;
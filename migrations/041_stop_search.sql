@@ -0,0 +1,15 @@
+-- Accent-insensitive stop search and search by stop_code. unaccent() is only
+-- stable, as it looks its dictionary up through the search path, so it is
+-- wrapped with the dictionary pinned to be usable in a generated column.
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+CREATE FUNCTION immutable_unaccent(text) RETURNS text AS $$
+    SELECT public.unaccent('public.unaccent'::regdictionary, $1)
+$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT;
+
+ALTER TABLE stops
+    ADD COLUMN stop_code TEXT,
+    ADD COLUMN search_name TEXT GENERATED ALWAYS AS (lower(immutable_unaccent(name))) STORED;
+
+CREATE INDEX idx_stops_search_name_trgm ON stops USING GIN(search_name gin_trgm_ops);
+CREATE INDEX idx_stops_code ON stops(lower(stop_code)) WHERE stop_code IS NOT NULL;
@@ -0,0 +1,28 @@
+-- Stop search synonyms and misspellings, managed by admins. A search whose
+-- whole query (lowercased and unaccented, like stops.search_name) equals a
+-- term also matches its synonym, scored boost times; two-way synonyms also
+-- map the synonym back to the term. Misspellings are one-way.
+CREATE TABLE search_synonyms (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    term TEXT NOT NULL,
+    synonym TEXT NOT NULL,
+    one_way BOOLEAN NOT NULL DEFAULT false,
+    boost REAL NOT NULL DEFAULT 1 CHECK (boost > 0 AND boost <= 2),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (term, synonym),
+    CHECK (term <> synonym)
+);
+
+CREATE INDEX idx_search_synonyms_synonym ON search_synonyms(synonym) WHERE NOT one_way;
+
+-- Stop searches and how many stops they found, to measure the hit rate and
+-- find queries worth a synonym.
+CREATE TABLE stop_searches (
+    time TIMESTAMPTZ NOT NULL,
+    query TEXT NOT NULL,                   -- lowercased and unaccented
+    results INT NOT NULL
+);
+
+SELECT create_hypertable('stop_searches', 'time');
+
+SELECT add_retention_policy('stop_searches', INTERVAL '90 days');
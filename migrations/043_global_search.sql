@@ -0,0 +1,13 @@
+-- Accent-insensitive search over agency and route names, for /v1/search,
+-- normalized like stops.search_name.
+ALTER TABLE agencies
+    ADD COLUMN search_name TEXT GENERATED ALWAYS AS (lower(immutable_unaccent(name))) STORED;
+
+ALTER TABLE routes
+    ADD COLUMN search_name TEXT GENERATED ALWAYS AS (
+        lower(immutable_unaccent(COALESCE(short_name || ' ', '') || long_name))
+    ) STORED;
+
+CREATE INDEX idx_agencies_search_name_trgm ON agencies USING GIN(search_name gin_trgm_ops);
+CREATE INDEX idx_routes_search_name_trgm ON routes USING GIN(search_name gin_trgm_ops);
+CREATE INDEX idx_routes_short_name ON routes(lower(short_name)) WHERE short_name IS NOT NULL;
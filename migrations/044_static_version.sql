@@ -0,0 +1,4 @@
+-- The static data version behind the API's ETags is polled every minute;
+-- this keeps its MAX(trips.updated_at) off a full scan. stops and routes
+-- are covered by their sync indexes from 031.
+CREATE INDEX idx_trips_updated_at ON trips(updated_at);
@@ -0,0 +1,17 @@
+-- Route long names are cleaned up at ingest with per-agency rules
+-- (domain.RouteNameRules); the name the feed gave is kept in the route's
+-- metadata under original_long_name.
+ALTER TABLE agency_feed_configs ADD COLUMN route_name_rules JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE routes ADD COLUMN metadata JSONB NOT NULL DEFAULT '{}';
+
+-- Metadata is served with the route, so changing it counts as a change for
+-- the sync API.
+CREATE OR REPLACE FUNCTION touch_route() RETURNS trigger AS $$
+BEGIN
+    IF (NEW.short_name, NEW.long_name, NEW.route_type, NEW.color, NEW.text_color, NEW.shape::text, NEW.metadata)
+       IS DISTINCT FROM
+       (OLD.short_name, OLD.long_name, OLD.route_type, OLD.color, OLD.text_color, OLD.shape::text, OLD.metadata) THEN
+        NEW.updated_at := clock_timestamp();
+    END IF;
+    RETURN NEW;
+END $$ LANGUAGE plpgsql;
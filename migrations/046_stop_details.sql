@@ -0,0 +1,20 @@
+-- stops.txt stop_desc, stop_url and zone_id. Fare zones are per agency, so
+-- zone IDs repeat across agencies.
+ALTER TABLE stops
+    ADD COLUMN description TEXT,
+    ADD COLUMN url TEXT,
+    ADD COLUMN zone_id TEXT;
+
+-- They are served with the stop, so changing them counts as a change for
+-- the sync API, and so does stop_code.
+CREATE OR REPLACE FUNCTION touch_stop() RETURNS trigger AS $$
+BEGIN
+    IF (NEW.name, NEW.location::text, NEW.platform_code, NEW.wheelchair_accessible, NEW.metadata,
+        NEW.shelter, NEW.bench, NEW.realtime_display, NEW.stop_code, NEW.description, NEW.url, NEW.zone_id)
+       IS DISTINCT FROM
+       (OLD.name, OLD.location::text, OLD.platform_code, OLD.wheelchair_accessible, OLD.metadata,
+        OLD.shelter, OLD.bench, OLD.realtime_display, OLD.stop_code, OLD.description, OLD.url, OLD.zone_id) THEN
+        NEW.updated_at := clock_timestamp();
+    END IF;
+    RETURN NEW;
+END $$ LANGUAGE plpgsql;
@@ -0,0 +1,8 @@
+-- trips.txt block_id, and the trip the same vehicle runs next on its block
+-- when riders can stay on board: set by the ingestor once stop times are
+-- loaded (see linkBlocks).
+ALTER TABLE trips
+    ADD COLUMN block_id TEXT,
+    ADD COLUMN next_trip_id UUID REFERENCES trips(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_trips_block ON trips(block_id, service_id) WHERE block_id IS NOT NULL;
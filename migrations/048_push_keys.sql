@@ -0,0 +1,21 @@
+-- Keys agencies sign the realtime data they push with (see
+-- PushKeyService.Verify), and the nonces of signed pushes, kept while their
+-- timestamp is still accepted so that a push cannot be replayed.
+CREATE TABLE agency_push_keys (
+    id TEXT PRIMARY KEY,
+    agency_id UUID NOT NULL REFERENCES agencies(id) ON DELETE CASCADE,
+    label TEXT NOT NULL DEFAULT '',
+    secret TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_agency_push_keys_agency ON agency_push_keys(agency_id);
+
+CREATE TABLE push_nonces (
+    agency_id UUID NOT NULL REFERENCES agencies(id) ON DELETE CASCADE,
+    nonce TEXT NOT NULL,
+    seen_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (agency_id, nonce)
+);
+
+CREATE INDEX idx_push_nonces_seen ON push_nonces(agency_id, seen_at);
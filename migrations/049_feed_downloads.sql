@@ -0,0 +1,6 @@
+-- The feed ingest workflow keeps the feed it downloaded as a stage between
+-- its activities, too big for the workflow's history. Downloaded stages are
+-- applied or dropped by the workflow and never listed to admins.
+ALTER TABLE feed_stages DROP CONSTRAINT feed_stages_status_check;
+ALTER TABLE feed_stages ADD CONSTRAINT feed_stages_status_check
+    CHECK (status IN ('downloaded', 'staged', 'approved', 'rejected', 'applied'));
@@ -0,0 +1,32 @@
+-- Vehicle positions are compressed once a week old and kept for 90 days,
+-- rather than dropped after 7. `migrate policies` sets both from the
+-- timescale section of the config.
+ALTER TABLE vehicle_positions SET (
+    timescaledb.compress,
+    timescaledb.compress_segmentby = 'vehicle_id',
+    timescaledb.compress_orderby = 'time DESC'
+);
+
+SELECT remove_retention_policy('vehicle_positions', if_exists => true);
+SELECT add_retention_policy('vehicle_positions', INTERVAL '90 days');
+SELECT add_compression_policy('vehicle_positions', INTERVAL '7 days');
+
+-- Hourly vehicle counts per route, for capacity dashboards long after the
+-- raw positions are gone. Refreshed well before positions are compressed.
+CREATE MATERIALIZED VIEW vehicle_counts_hourly
+WITH (timescaledb.continuous) AS
+SELECT
+    time_bucket('1 hour', time) AS bucket,
+    route_id,
+    COUNT(DISTINCT vehicle_id) AS vehicles,
+    COUNT(*) AS positions
+FROM vehicle_positions
+GROUP BY bucket, route_id
+WITH NO DATA;
+
+SELECT add_continuous_aggregate_policy('vehicle_counts_hourly',
+    start_offset => INTERVAL '3 days',
+    end_offset => INTERVAL '1 hour',
+    schedule_interval => INTERVAL '30 minutes');
+
+SELECT add_retention_policy('vehicle_counts_hourly', INTERVAL '400 days');
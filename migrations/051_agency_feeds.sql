@@ -0,0 +1,13 @@
+-- Feed URLs of the agencies the ingestor and realtime poller fetch, managed
+-- through the admin API rather than manifest.json.
+CREATE TABLE agency_feeds (
+    agency_id UUID PRIMARY KEY REFERENCES agencies(id) ON DELETE CASCADE,
+    gtfs_url TEXT NOT NULL,
+    vehicle_positions_url TEXT,
+    trip_updates_url TEXT,
+    alerts_url TEXT,
+    poll_interval INTEGER CHECK (poll_interval > 0),   -- seconds; NULL for the poller's default
+    vehicle_push BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
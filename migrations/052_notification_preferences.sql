@@ -0,0 +1,17 @@
+-- What riders are pushed and on which devices (GET/PUT /v1/me/preferences).
+-- Riders without a row get every channel and every category but marketing,
+-- without quiet hours.
+CREATE TABLE notification_preferences (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    fcm BOOLEAN NOT NULL,                    -- channels
+    webpush BOOLEAN NOT NULL,
+    delays BOOLEAN NOT NULL,                 -- categories
+    alerts BOOLEAN NOT NULL,
+    coupons BOOLEAN NOT NULL,
+    marketing BOOLEAN NOT NULL,
+    quiet_from TIME,                         -- NULL when quiet hours are off
+    quiet_until TIME,                        -- exclusive; before quiet_from wraps past midnight
+    quiet_timezone TEXT,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((quiet_from IS NULL) = (quiet_until IS NULL) AND (quiet_from IS NULL) = (quiet_timezone IS NULL))
+);
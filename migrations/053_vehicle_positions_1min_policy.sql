@@ -0,0 +1,10 @@
+-- vehicle_positions_1min is created WITH NO DATA, as continuous aggregates
+-- must be to be created in a migration's transaction, and had no refresh
+-- policy, so it stayed empty. Refreshed every few minutes, well before
+-- positions are compressed. Positions older than start_offset are not
+-- materialized by the policy; fill them once, outside a transaction, with
+-- CALL refresh_continuous_aggregate('vehicle_positions_1min', NULL, NULL).
+SELECT add_continuous_aggregate_policy('vehicle_positions_1min',
+    start_offset => INTERVAL '3 days',
+    end_offset => INTERVAL '1 minute',
+    schedule_interval => INTERVAL '5 minutes');
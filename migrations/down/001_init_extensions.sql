@@ -0,0 +1,6 @@
+-- TimescaleDB can only be dropped as the first command of a session, so it
+-- is left installed: psql -X -c 'DROP EXTENSION timescaledb' removes it.
+DROP EXTENSION IF EXISTS pgcrypto;
+DROP EXTENSION IF EXISTS btree_gist;
+DROP EXTENSION IF EXISTS pg_trgm;
+DROP EXTENSION IF EXISTS postgis;
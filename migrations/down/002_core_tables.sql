@@ -0,0 +1,10 @@
+DROP TABLE compensations;
+DROP TABLE affiliates;
+DROP TABLE delay_events;
+DROP MATERIALIZED VIEW vehicle_positions_1min;
+DROP TABLE vehicle_positions;
+DROP TABLE stop_times;
+DROP TABLE trips;
+DROP TABLE routes;
+DROP TABLE stops;
+DROP TABLE agencies;
@@ -0,0 +1 @@
+DROP TABLE stop_short_links;
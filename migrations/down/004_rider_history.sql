@@ -0,0 +1,2 @@
+DROP TABLE rider_journeys;
+DROP TABLE history_consents;
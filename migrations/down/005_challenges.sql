@@ -0,0 +1,2 @@
+DROP TABLE challenge_enrollments;
+DROP TABLE challenges;
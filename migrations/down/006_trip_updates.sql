@@ -0,0 +1 @@
+DROP TABLE stop_time_predictions;
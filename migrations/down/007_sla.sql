@@ -0,0 +1,3 @@
+DROP TABLE sla_evaluations;
+DROP TABLE sla_daily_stats;
+DROP TABLE sla_contracts;
@@ -0,0 +1 @@
+DROP INDEX idx_delay_events_trip_stop;
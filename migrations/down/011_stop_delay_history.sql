@@ -0,0 +1 @@
+DROP TABLE stop_delay_observations;
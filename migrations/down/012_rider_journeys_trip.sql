@@ -0,0 +1 @@
+DROP INDEX idx_rider_journeys_trip;
@@ -0,0 +1,2 @@
+DROP TABLE feed_quality_reports;
+DROP TABLE rt_feed_ids;
@@ -0,0 +1,2 @@
+DROP INDEX idx_compensations_user_issued;
+ALTER TABLE users DROP COLUMN affiliate_id;
@@ -0,0 +1 @@
+ALTER TABLE affiliates DROP COLUMN updated_at;
@@ -0,0 +1,7 @@
+DROP INDEX idx_stops_location_shelter;
+ALTER TABLE stops
+    DROP COLUMN shelter,
+    DROP COLUMN bench,
+    DROP COLUMN realtime_display,
+    DROP COLUMN amenities_source,
+    DROP COLUMN amenities_updated_at;
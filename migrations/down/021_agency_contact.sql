@@ -0,0 +1,4 @@
+ALTER TABLE agencies
+    DROP COLUMN contact_phone,
+    DROP COLUMN lost_found_url,
+    DROP COLUMN complaint_url;
@@ -0,0 +1,2 @@
+DROP TABLE alert_subscription_sends;
+DROP TABLE alert_subscriptions;
@@ -0,0 +1 @@
+DROP TABLE accessible_space_reports;
@@ -0,0 +1 @@
+DROP TABLE favorite_feed_states;
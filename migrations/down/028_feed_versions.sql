@@ -0,0 +1,4 @@
+DROP TABLE trip_history;
+DROP TABLE route_history;
+DROP TABLE stop_history;
+DROP TABLE feed_versions;
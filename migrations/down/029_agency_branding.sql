@@ -0,0 +1,2 @@
+DROP TABLE route_icons;
+DROP TABLE agency_branding;
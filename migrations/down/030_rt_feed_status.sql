@@ -0,0 +1 @@
+DROP TABLE rt_feed_status;
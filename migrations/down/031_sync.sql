@@ -0,0 +1,15 @@
+DROP TRIGGER routes_sync_deletion ON routes;
+DROP TRIGGER stops_sync_deletion ON stops;
+DROP FUNCTION record_sync_deletion();
+DROP TABLE sync_deletions;
+
+DROP TRIGGER service_alerts_touch ON service_alerts;
+DROP TRIGGER routes_touch ON routes;
+DROP TRIGGER stops_touch ON stops;
+DROP FUNCTION touch_service_alert();
+DROP FUNCTION touch_route();
+DROP FUNCTION touch_stop();
+
+ALTER TABLE service_alerts DROP COLUMN changed_at;
+ALTER TABLE routes DROP COLUMN updated_at;
+ALTER TABLE stops DROP COLUMN updated_at;
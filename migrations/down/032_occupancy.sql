@@ -0,0 +1,3 @@
+DROP MATERIALIZED VIEW stop_occupancy_hourly;
+DROP INDEX idx_vehicle_positions_trip;
+ALTER TABLE vehicle_positions DROP COLUMN stop_id;
@@ -0,0 +1 @@
+DROP MATERIALIZED VIEW stop_punctuality_hourly;
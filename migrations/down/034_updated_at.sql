@@ -0,0 +1,5 @@
+DROP TRIGGER trips_touch ON trips;
+DROP TRIGGER agencies_touch ON agencies;
+DROP FUNCTION touch_updated_at();
+ALTER TABLE trips DROP COLUMN updated_at;
+ALTER TABLE agencies DROP COLUMN updated_at;
@@ -0,0 +1,2 @@
+DROP TABLE feed_stages;
+ALTER TABLE agency_feed_configs DROP COLUMN require_approval;
@@ -0,0 +1 @@
+DROP TABLE realtime_coverage;
@@ -0,0 +1 @@
+DROP TABLE route_segment_hourly;
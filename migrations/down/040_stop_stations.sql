@@ -0,0 +1,4 @@
+DROP INDEX idx_stops_parent;
+ALTER TABLE stops
+    DROP COLUMN location_type,
+    DROP COLUMN parent_id;
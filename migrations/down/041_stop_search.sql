@@ -0,0 +1,7 @@
+DROP INDEX idx_stops_code;
+DROP INDEX idx_stops_search_name_trgm;
+ALTER TABLE stops
+    DROP COLUMN search_name,
+    DROP COLUMN stop_code;
+DROP FUNCTION immutable_unaccent(text);
+DROP EXTENSION IF EXISTS unaccent;
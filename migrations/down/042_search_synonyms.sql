@@ -0,0 +1,2 @@
+DROP TABLE stop_searches;
+DROP TABLE search_synonyms;
@@ -0,0 +1,5 @@
+DROP INDEX idx_routes_short_name;
+DROP INDEX idx_routes_search_name_trgm;
+DROP INDEX idx_agencies_search_name_trgm;
+ALTER TABLE routes DROP COLUMN search_name;
+ALTER TABLE agencies DROP COLUMN search_name;
@@ -0,0 +1 @@
+DROP INDEX idx_trips_updated_at;
@@ -0,0 +1,13 @@
+-- touch_route as it was before routes had metadata
+CREATE OR REPLACE FUNCTION touch_route() RETURNS trigger AS $$
+BEGIN
+    IF (NEW.short_name, NEW.long_name, NEW.route_type, NEW.color, NEW.text_color, NEW.shape::text)
+       IS DISTINCT FROM
+       (OLD.short_name, OLD.long_name, OLD.route_type, OLD.color, OLD.text_color, OLD.shape::text) THEN
+        NEW.updated_at := clock_timestamp();
+    END IF;
+    RETURN NEW;
+END $$ LANGUAGE plpgsql;
+
+ALTER TABLE routes DROP COLUMN metadata;
+ALTER TABLE agency_feed_configs DROP COLUMN route_name_rules;
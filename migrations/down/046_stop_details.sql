@@ -0,0 +1,17 @@
+-- touch_stop as it was before stops had details
+CREATE OR REPLACE FUNCTION touch_stop() RETURNS trigger AS $$
+BEGIN
+    IF (NEW.name, NEW.location::text, NEW.platform_code, NEW.wheelchair_accessible, NEW.metadata,
+        NEW.shelter, NEW.bench, NEW.realtime_display)
+       IS DISTINCT FROM
+       (OLD.name, OLD.location::text, OLD.platform_code, OLD.wheelchair_accessible, OLD.metadata,
+        OLD.shelter, OLD.bench, OLD.realtime_display) THEN
+        NEW.updated_at := clock_timestamp();
+    END IF;
+    RETURN NEW;
+END $$ LANGUAGE plpgsql;
+
+ALTER TABLE stops
+    DROP COLUMN description,
+    DROP COLUMN url,
+    DROP COLUMN zone_id;
@@ -0,0 +1,4 @@
+DROP INDEX idx_trips_block;
+ALTER TABLE trips
+    DROP COLUMN block_id,
+    DROP COLUMN next_trip_id;
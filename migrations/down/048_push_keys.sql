@@ -0,0 +1,2 @@
+DROP TABLE push_nonces;
+DROP TABLE agency_push_keys;
@@ -0,0 +1,4 @@
+DELETE FROM feed_stages WHERE status = 'downloaded';
+ALTER TABLE feed_stages DROP CONSTRAINT feed_stages_status_check;
+ALTER TABLE feed_stages ADD CONSTRAINT feed_stages_status_check
+    CHECK (status IN ('staged', 'approved', 'rejected', 'applied'));
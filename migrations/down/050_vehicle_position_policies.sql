@@ -0,0 +1,9 @@
+DROP MATERIALIZED VIEW vehicle_counts_hourly;
+
+-- Back to dropping positions after 7 days, uncompressed
+SELECT remove_compression_policy('vehicle_positions', if_exists => true);
+SELECT drop_chunks('vehicle_positions', older_than => INTERVAL '7 days');
+SELECT decompress_chunk(c, if_compressed => true) FROM show_chunks('vehicle_positions') c;
+ALTER TABLE vehicle_positions SET (timescaledb.compress = false);
+SELECT remove_retention_policy('vehicle_positions', if_exists => true);
+SELECT add_retention_policy('vehicle_positions', INTERVAL '7 days');
@@ -0,0 +1 @@
+DROP TABLE agency_feeds;
@@ -0,0 +1 @@
+SELECT remove_continuous_aggregate_policy('vehicle_positions_1min', if_exists => true);
@@ -0,0 +1,11 @@
+// Package migrations embeds the SQL migrations, so that cmd/migrate is
+// self-contained. Migrations are applied in the order of the version that
+// prefixes their file name, NNN_name.sql; down/NNN_name.sql reverts one.
+// seed/*.sql are fixtures for development and integration tests, loaded by
+// `migrate seed`.
+package migrations
+
+import "embed"
+
+//go:embed *.sql down/*.sql seed/*.sql
+var FS embed.FS
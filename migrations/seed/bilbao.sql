@@ -0,0 +1,89 @@
+-- A small Bilbao dataset for local development and integration tests: two
+-- agencies, a metro and a bus line with trips all day, and two affiliates.
+-- Its IDs are fixed, so loading it again restores it. Load it into a
+-- database without the real metro_bilbao and bilbobus feeds, whose slugs
+-- it takes.
+
+INSERT INTO agencies (id, slug, name, url, timezone) VALUES
+    ('0000b1b0-0001-4000-8000-000000000001', 'metro_bilbao', 'Metro Bilbao', 'https://www.metrobilbao.eus', 'Europe/Madrid'),
+    ('0000b1b0-0001-4000-8000-000000000002', 'bilbobus', 'BilboBus', 'https://www.bilbao.eus/bilbobus', 'Europe/Madrid')
+ON CONFLICT (id) DO UPDATE SET
+    slug = EXCLUDED.slug, name = EXCLUDED.name, url = EXCLUDED.url, timezone = EXCLUDED.timezone;
+
+INSERT INTO stops (id, agency_id, stop_id, name, location, wheelchair_accessible) VALUES
+    ('0000b1b0-0002-4000-8000-000000000001', '0000b1b0-0001-4000-8000-000000000001', 'CAV', 'Casco Viejo', ST_Point(-2.9237, 43.2597, 4326), true),
+    ('0000b1b0-0002-4000-8000-000000000002', '0000b1b0-0001-4000-8000-000000000001', 'ABA', 'Abando', ST_Point(-2.9278, 43.2617, 4326), true),
+    ('0000b1b0-0002-4000-8000-000000000003', '0000b1b0-0001-4000-8000-000000000001', 'MOY', 'Moyua', ST_Point(-2.9350, 43.2630, 4326), true),
+    ('0000b1b0-0002-4000-8000-000000000004', '0000b1b0-0001-4000-8000-000000000001', 'IND', 'Indautxu', ST_Point(-2.9425, 43.2616, 4326), true),
+    ('0000b1b0-0002-4000-8000-000000000005', '0000b1b0-0001-4000-8000-000000000001', 'SAM', 'San Mamés', ST_Point(-2.9497, 43.2617, 4326), true),
+    ('0000b1b0-0002-4000-8000-000000000011', '0000b1b0-0001-4000-8000-000000000002', '0011', 'Plaza Moyua', ST_Point(-2.9352, 43.2632, 4326), true),
+    ('0000b1b0-0002-4000-8000-000000000012', '0000b1b0-0001-4000-8000-000000000002', '0012', 'Gran Vía - Elcano', ST_Point(-2.9390, 43.2625, 4326), true),
+    ('0000b1b0-0002-4000-8000-000000000013', '0000b1b0-0001-4000-8000-000000000002', '0013', 'Sabino Arana', ST_Point(-2.9488, 43.2652, 4326), false),
+    ('0000b1b0-0002-4000-8000-000000000014', '0000b1b0-0001-4000-8000-000000000002', '0014', 'Termibus', ST_Point(-2.9508, 43.2610, 4326), true)
+ON CONFLICT (id) DO UPDATE SET
+    name = EXCLUDED.name, location = EXCLUDED.location, wheelchair_accessible = EXCLUDED.wheelchair_accessible;
+
+INSERT INTO routes (id, agency_id, route_id, short_name, long_name, route_type, color, text_color) VALUES
+    ('0000b1b0-0003-4000-8000-000000000001', '0000b1b0-0001-4000-8000-000000000001', 'L1', 'L1', 'Casco Viejo - San Mamés', 1, 'F37021', 'FFFFFF'),
+    ('0000b1b0-0003-4000-8000-000000000002', '0000b1b0-0001-4000-8000-000000000002', '01', '01', 'Plaza Moyua - Termibus', 3, 'E30613', 'FFFFFF')
+ON CONFLICT (id) DO UPDATE SET
+    short_name = EXCLUDED.short_name, long_name = EXCLUDED.long_name, route_type = EXCLUDED.route_type,
+    color = EXCLUDED.color, text_color = EXCLUDED.text_color;
+
+-- Each way of a line: its headsign, how often it runs and how many minutes
+-- it takes between stops. Its stops are in direction 0 order.
+CREATE TEMP TABLE fixture_patterns (route_id UUID, direction_id INT, headsign TEXT, headway INT, hop INT) ON COMMIT DROP;
+INSERT INTO fixture_patterns VALUES
+    ('0000b1b0-0003-4000-8000-000000000001', 0, 'San Mamés', 15, 2),
+    ('0000b1b0-0003-4000-8000-000000000001', 1, 'Casco Viejo', 15, 2),
+    ('0000b1b0-0003-4000-8000-000000000002', 0, 'Termibus', 30, 3),
+    ('0000b1b0-0003-4000-8000-000000000002', 1, 'Plaza Moyua', 30, 3);
+
+CREATE TEMP TABLE fixture_pattern_stops (route_id UUID, stop_sequence INT, stop_id UUID) ON COMMIT DROP;
+INSERT INTO fixture_pattern_stops VALUES
+    ('0000b1b0-0003-4000-8000-000000000001', 1, '0000b1b0-0002-4000-8000-000000000001'),
+    ('0000b1b0-0003-4000-8000-000000000001', 2, '0000b1b0-0002-4000-8000-000000000002'),
+    ('0000b1b0-0003-4000-8000-000000000001', 3, '0000b1b0-0002-4000-8000-000000000003'),
+    ('0000b1b0-0003-4000-8000-000000000001', 4, '0000b1b0-0002-4000-8000-000000000004'),
+    ('0000b1b0-0003-4000-8000-000000000001', 5, '0000b1b0-0002-4000-8000-000000000005'),
+    ('0000b1b0-0003-4000-8000-000000000002', 1, '0000b1b0-0002-4000-8000-000000000011'),
+    ('0000b1b0-0003-4000-8000-000000000002', 2, '0000b1b0-0002-4000-8000-000000000012'),
+    ('0000b1b0-0003-4000-8000-000000000002', 3, '0000b1b0-0002-4000-8000-000000000013'),
+    ('0000b1b0-0003-4000-8000-000000000002', 4, '0000b1b0-0002-4000-8000-000000000014');
+
+-- A trip every headway minutes from 06:00 to midnight, named after the line,
+-- its direction and its first departure, e.g. L1-0-0815. The IDs of trips
+-- are hashes of their names.
+CREATE TEMP TABLE fixture_trips ON COMMIT DROP AS
+SELECT md5('bilbao-fixture/' || r.route_id || '-' || p.direction_id || '-' || to_char(d.start, 'HH24MI'))::uuid AS id,
+       r.route_id || '-' || p.direction_id || '-' || to_char(d.start, 'HH24MI') AS trip_id,
+       p.route_id, p.direction_id, p.headsign, p.hop, d.start
+FROM fixture_patterns p
+JOIN routes r ON r.id = p.route_id
+CROSS JOIN LATERAL generate_series(0, (18 * 60 - 1) / p.headway) AS n
+CROSS JOIN LATERAL (SELECT INTERVAL '6 hours' + n * p.headway * INTERVAL '1 minute' AS start) d;
+
+INSERT INTO trips (id, route_id, trip_id, service_id, headsign, direction_id, wheelchair_accessible)
+SELECT id, route_id, trip_id, 'DAILY', headsign, direction_id, true FROM fixture_trips
+ON CONFLICT (id) DO UPDATE SET headsign = EXCLUDED.headsign, direction_id = EXCLUDED.direction_id;
+
+DELETE FROM stop_times WHERE trip_id IN (SELECT id FROM fixture_trips);
+INSERT INTO stop_times (trip_id, stop_id, arrival_time, departure_time, stop_sequence)
+SELECT t.id, s.stop_id, t.start + (seq - 1) * t.hop * INTERVAL '1 minute',
+       t.start + (seq - 1) * t.hop * INTERVAL '1 minute', seq
+FROM fixture_trips t
+JOIN fixture_pattern_stops s ON s.route_id = t.route_id
+CROSS JOIN LATERAL (
+    SELECT CASE WHEN t.direction_id = 0 THEN s.stop_sequence
+                ELSE (SELECT COUNT(*) FROM fixture_pattern_stops WHERE route_id = t.route_id) + 1 - s.stop_sequence
+           END AS seq
+) o;
+
+INSERT INTO affiliates (id, name, category, location, address, offer_text, offer_value) VALUES
+    ('0000b1b0-0006-4000-8000-000000000001', 'Café Moyua', 'cafe', ST_Point(-2.9346, 43.2634, 4326),
+     'Plaza Moyua 1, Bilbao', 'Free coffee with your delay code', 1.80),
+    ('0000b1b0-0006-4000-8000-000000000002', 'Pastelería Abando', 'bakery', ST_Point(-2.9270, 43.2612, 4326),
+     'Calle Hurtado de Amézaga 2, Bilbao', 'A pastry on us', 2.50)
+ON CONFLICT (id) DO UPDATE SET
+    name = EXCLUDED.name, category = EXCLUDED.category, location = EXCLUDED.location, address = EXCLUDED.address,
+    offer_text = EXCLUDED.offer_text, offer_value = EXCLUDED.offer_value, active = true;
@@ -4,7 +4,7 @@ set -euo pipefail
 REGISTRY="${REGISTRY:-ghcr.io/bilbopass}"
 VERSION="${VERSION:-$(git describe --tags --always --dirty 2>/dev/null || echo 'dev')}"
 
-SERVICES=("api" "ingestor" "realtime" "compensator")
+SERVICES=("api" "ingestor" "realtime" "compensator" "sla" "anomaly" "reconcile")
 
 GREEN='\033[0;32m'
 NC='\033[0m'
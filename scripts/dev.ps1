@@ -21,7 +21,7 @@ Write-Host "[bilbopass] TimescaleDB ready" -ForegroundColor Green
 # Check ingested data
 $stopCount = docker compose exec -T timescale psql -U transit -d bilbopass -t -c "SELECT count(*) FROM stops;" 2>$null
 if ([string]::IsNullOrWhiteSpace($stopCount) -or $stopCount.Trim() -eq "0") {
-    Write-Host "[bilbopass] No stops in database. Run: go run cmd/ingestor/main.go manifest.json" -ForegroundColor Yellow
+    Write-Host "[bilbopass] No stops in database. Run: scripts\ingest.ps1" -ForegroundColor Yellow
 }
 
 Write-Host ""
@@ -38,7 +38,7 @@ log "TimescaleDB ready"
 # Check if data is already ingested
 STOP_COUNT=$(docker compose exec -T timescale psql -U transit -d bilbopass -t -c "SELECT count(*) FROM stops;" 2>/dev/null | tr -d ' ' || echo "0")
 if [ "$STOP_COUNT" = "0" ] || [ "$STOP_COUNT" = "" ]; then
-  warn "No stops found in database. Run: go run cmd/ingestor/main.go manifest.json"
+  warn "No stops found in database. Run: scripts/ingest.sh"
 fi
 
 # Start API server
@@ -129,7 +129,8 @@ Assert-Status         "Bad radius"                 "$Base/v1/stops/nearby?lat=43
 # Routes
 Write-Host ""
 Write-Host "[Routes]" -ForegroundColor Cyan
-Assert-Status "Routes missing agency_id" "$Base/v1/routes" 400
+Assert-Status "All routes" "$Base/v1/routes?route_type=metro" 200
+Assert-Status "as_of missing agency_id" "$Base/v1/routes?as_of=2026-01-01" 400
 
 # GraphQL
 Write-Host ""
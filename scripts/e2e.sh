@@ -107,7 +107,8 @@ assert_status  "Bad radius"                    "$BASE/v1/stops/nearby?lat=43.26&
 # Routes
 bold ""
 bold "[Routes]"
-assert_status  "Routes missing agency_id"  "$BASE/v1/routes"  400
+assert_status  "All routes"                "$BASE/v1/routes?route_type=metro"  200
+assert_status  "as_of missing agency_id"   "$BASE/v1/routes?as_of=2026-01-01"  400
 
 # GraphQL
 bold ""
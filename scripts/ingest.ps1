@@ -8,14 +8,15 @@ if (-not (Test-Path $Manifest)) {
     exit 1
 }
 
-Write-Host "[ingestor] Manifest: $Manifest" -ForegroundColor Green
+Write-Host "[ingestor] Importing manifest: $Manifest" -ForegroundColor Green
+go run cmd/ingestor/main.go import $Manifest
 
 if ($Filter) {
     Write-Host "[ingestor] Filtering to agency slug: $Filter" -ForegroundColor Green
-    go run cmd/ingestor/main.go $Manifest $Filter
+    go run cmd/ingestor/main.go $Filter
 } else {
     Write-Host "[ingestor] Ingesting all agencies..." -ForegroundColor Green
-    go run cmd/ingestor/main.go $Manifest
+    go run cmd/ingestor/main.go
 }
 
 Write-Host "[ingestor] Ingestion complete" -ForegroundColor Green
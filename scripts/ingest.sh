@@ -15,14 +15,15 @@ if [ ! -f "$MANIFEST" ]; then
 fi
 
 AGENCY_COUNT=$(jq '.agencies | length' "$MANIFEST" 2>/dev/null || echo "?")
-log "Manifest: $MANIFEST ($AGENCY_COUNT agencies)"
+log "Importing manifest: $MANIFEST ($AGENCY_COUNT agencies)"
+go run cmd/ingestor/main.go import "$MANIFEST"
 
 if [ -n "$FILTER" ]; then
   log "Filtering to agency slug: $FILTER"
-  go run cmd/ingestor/main.go "$MANIFEST" "$FILTER"
+  go run cmd/ingestor/main.go "$FILTER"
 else
   log "Ingesting all agencies..."
-  go run cmd/ingestor/main.go "$MANIFEST"
+  go run cmd/ingestor/main.go
 fi
 
 log "Ingestion complete"